@@ -11,19 +11,35 @@ import (
 	"time"
 
 	"github.com/agentserver/agentserver/internal/credentialproxy"
+	"github.com/agentserver/agentserver/internal/credentialproxy/httpcred"
 	"github.com/agentserver/agentserver/internal/credentialproxy/k8s"
+	"github.com/agentserver/agentserver/internal/secrets"
 )
 
 func main() {
+	// Resolve vault:<path>#<key> references (e.g. ANTHROPIC_API_KEY) first.
+	backend, err := secrets.FromEnv()
+	if err != nil {
+		log.Fatalf("secrets backend: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err = secrets.ResolveEnv(ctx, backend)
+	cancel()
+	if err != nil {
+		log.Fatalf("resolve secrets: %v", err)
+	}
+
 	cfg, err := credentialproxy.LoadConfigFromEnv()
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
+	cfg.Secrets = backend
 
 	logger := credentialproxy.NewLogger(cfg.LogLevel)
 
-	// Configure K8s provider settings.
+	// Configure provider settings.
 	k8s.AllowPrivateUpstreams = cfg.AllowPrivateUpstreams
+	httpcred.AllowPrivateUpstreams = cfg.AllowPrivateUpstreams
 	if cfg.AllowPrivateUpstreams {
 		logger.Warn("SSRF dial guard disabled: CREDPROXY_ALLOW_PRIVATE_UPSTREAMS=true")
 	}
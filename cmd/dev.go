@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/devcert"
+	"github.com/agentserver/agentserver/internal/sandboxproxy"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/tunnel"
+	"github.com/agentserver/agentserver/opencodeweb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	devMode   bool
+	devDomain string
+	devDir    string
+)
+
+// setupDevEnv prepares the environment for `serve --dev`: it points
+// BASE_DOMAIN at devDomain (with the port, unless 443), shares the session
+// cookie across its subdomains, drops the Secure cookie flag, and ensures a
+// local CA and wildcard certificate. Variables already set are kept.
+func setupDevEnv(port int) devcert.Files {
+	baseDomain := devDomain
+	if port != 443 {
+		baseDomain = net.JoinHostPort(devDomain, fmt.Sprint(port))
+	}
+	setenvDefault("BASE_DOMAIN", baseDomain)
+	setenvDefault("AGENTSERVER_COOKIE_DOMAIN", devDomain)
+	setenvDefault("AGENTSERVER_INSECURE_COOKIES", "true")
+
+	if devDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Fatalf("--dev-dir is required: %v", err)
+		}
+		devDir = filepath.Join(home, ".agentserver", "dev")
+	}
+	files, err := devcert.Ensure(devDir, []string{devDomain, "*." + devDomain, "localhost", "127.0.0.1"})
+	if err != nil {
+		log.Fatalf("Dev certificate: %v", err)
+	}
+	log.Printf("Dev mode: https://%s/ (sandboxes at https://<prefix>-<id>.%s/)", os.Getenv("BASE_DOMAIN"), os.Getenv("BASE_DOMAIN"))
+	log.Printf("Dev mode: trust %s in your browser or OS to avoid certificate warnings", files.CACert)
+	if !strings.HasSuffix(devDomain, "localtest.me") && !strings.Contains(devDomain, "nip.io") {
+		log.Printf("Dev mode: %s may not resolve to this machine; see `agentserver dev-hosts`", devDomain)
+	}
+	return files
+}
+
+func setenvDefault(key, value string) {
+	if os.Getenv(key) == "" {
+		os.Setenv(key, value)
+	}
+}
+
+// devHandler serves the API and the sandbox proxy from one listener:
+// requests for subdomains of the dev domain, and agent tunnels, go to the
+// sandbox proxy, everything else to the API server.
+func devHandler(api, proxy http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.HasSuffix(host, "."+devDomain) || strings.HasPrefix(r.URL.Path, "/api/tunnel/") {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+}
+
+// newDevProxy builds the in-process sandbox proxy for dev mode. It shares
+// the API server's tunnel registry so local agents are visible to both.
+func newDevProxy(authSvc *auth.Auth, database *db.DB, sandboxStore *sbxstore.Store, tunnelReg *tunnel.Registry) http.Handler {
+	var opcodeStaticFS fs.FS
+	if ocDistFS, err := fs.Sub(opencodeweb.StaticFS, "dist"); err != nil {
+		log.Printf("Warning: embedded opencode static files not available: %v", err)
+	} else {
+		opcodeStaticFS = ocDistFS
+	}
+	proxy := sandboxproxy.New(sandboxproxy.LoadConfigFromEnv(), authSvc, database, sandboxStore, tunnelReg, opcodeStaticFS)
+	return proxy.Router()
+}
+
+var devHostsCmd = &cobra.Command{
+	Use:   "dev-hosts",
+	Short: "Print /etc/hosts entries for local development",
+	Long: `Print /etc/hosts lines mapping the dev domain, the asset domain and the
+subdomain of every sandbox to 127.0.0.1.
+
+Only needed when the dev domain does not resolve to localhost by itself
+(localtest.me and nip.io do) or when working offline. /etc/hosts has no
+wildcards, so re-run it after creating sandboxes:
+
+  agentserver dev-hosts | sudo tee -a /etc/hosts`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if dbURL == "" {
+			dbURL = os.Getenv("DATABASE_URL")
+		}
+		cfg := sandboxproxy.LoadConfigFromEnv()
+		hosts := []string{devDomain, "opencodeapp." + devDomain}
+		if dbURL != "" {
+			database, err := db.Open(dbURL)
+			if err != nil {
+				log.Fatalf("Database connection failed: %v", err)
+			}
+			defer database.Close()
+			sandboxes, err := database.ListAllSandboxes()
+			if err != nil {
+				log.Fatalf("List sandboxes: %v", err)
+			}
+			for _, sbx := range sandboxes {
+				id := sbx.ID
+				if sbx.ShortID.Valid && sbx.ShortID.String != "" {
+					id = sbx.ShortID.String
+				}
+				prefix := cfg.OpencodeSubdomainPrefix
+				switch sbx.Type {
+				case "nanoclaw":
+					continue // no web UI
+				case "openclaw":
+					prefix = cfg.OpenclawSubdomainPrefix
+				case "claudecode":
+					prefix = cfg.ClaudeCodeSubdomainPrefix
+				case "jupyter":
+					prefix = cfg.JupyterSubdomainPrefix
+				}
+				hosts = append(hosts, prefix+"-"+id+"."+devDomain)
+			}
+		}
+		fmt.Println("# agentserver dev")
+		for _, h := range hosts {
+			fmt.Printf("127.0.0.1\t%s\n", h)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(devHostsCmd)
+	devHostsCmd.Flags().StringVar(&devDomain, "dev-domain", "localtest.me", "Development base domain")
+	devHostsCmd.Flags().StringVar(&dbURL, "db-url", "", "PostgreSQL connection URL (or use DATABASE_URL env)")
+}
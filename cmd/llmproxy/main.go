@@ -11,9 +11,22 @@ import (
 	"time"
 
 	"github.com/agentserver/agentserver/internal/llmproxy"
+	"github.com/agentserver/agentserver/internal/secrets"
 )
 
 func main() {
+	// Resolve vault:<path>#<key> references (e.g. ANTHROPIC_API_KEY) first.
+	backend, err := secrets.FromEnv()
+	if err != nil {
+		log.Fatalf("secrets backend: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err = secrets.ResolveEnv(ctx, backend)
+	cancel()
+	if err != nil {
+		log.Fatalf("resolve secrets: %v", err)
+	}
+
 	cfg := llmproxy.LoadConfigFromEnv()
 
 	hasAnthropic := cfg.AnthropicAPIKey != "" || cfg.AnthropicAuthToken != ""
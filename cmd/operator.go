@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/operator"
+	"github.com/agentserver/agentserver/internal/server"
+)
+
+// startOperator runs the Workspace/SandboxClaim reconciler when
+// OPERATOR_ENABLED=true. With several replicas, a Lease in
+// AGENTSERVER_NAMESPACE elects the one that reconciles.
+func startOperator(ctx context.Context, srv *server.Server, database *db.DB) error {
+	if os.Getenv("OPERATOR_ENABLED") != "true" {
+		return nil
+	}
+	email := os.Getenv("OPERATOR_USER_EMAIL")
+	if email == "" {
+		return fmt.Errorf("OPERATOR_USER_EMAIL is required with OPERATOR_ENABLED")
+	}
+	user, err := database.GetUserByEmail(email)
+	if err != nil {
+		return fmt.Errorf("look up operator user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("operator user %s does not exist", email)
+	}
+	interval, err := time.ParseDuration(envOrDefault("OPERATOR_INTERVAL", "15s"))
+	if err != nil {
+		return fmt.Errorf("invalid OPERATOR_INTERVAL: %w", err)
+	}
+
+	restCfg, err := buildRESTConfig()
+	if err != nil {
+		return err
+	}
+	k8sClient, err := client.New(restCfg, client.Options{})
+	if err != nil {
+		return fmt.Errorf("controller-runtime client: %w", err)
+	}
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return fmt.Errorf("kubernetes clientset: %w", err)
+	}
+	op := operator.New(k8sClient, srv.OperatorBackend(user.ID), os.Getenv("OPERATOR_NAMESPACE"))
+
+	lockNamespace := os.Getenv("AGENTSERVER_NAMESPACE")
+	if lockNamespace == "" {
+		lockNamespace = "default"
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: "agentserver-operator", Namespace: lockNamespace},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: hostname()},
+	}
+	go leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   30 * time.Second,
+		RenewDeadline:   20 * time.Second,
+		RetryPeriod:     5 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Printf("Operator: reconciling Workspaces and SandboxClaims as %s (every %s)", email, interval)
+				op.OnReconcile = srv.ControllerHeartbeat(server.ControllerOperator, interval)
+				op.Run(ctx, interval)
+			},
+			OnStoppedLeading: func() {
+				log.Printf("Operator: lost leadership")
+				srv.ForgetController(server.ControllerOperator)
+			},
+		},
+	})
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"os"
 	"os/signal"
@@ -22,15 +24,21 @@ import (
 	"github.com/agentserver/agentserver/internal/auth"
 	"github.com/agentserver/agentserver/internal/codexauth"
 	"github.com/agentserver/agentserver/internal/crypto"
+	_ "github.com/agentserver/agentserver/internal/credentialproxy/httpcred" // register http credential provider
 	_ "github.com/agentserver/agentserver/internal/credentialproxy/k8s" // register k8s credential provider
 	"github.com/agentserver/agentserver/internal/container"
 	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/devcert"
 	"github.com/agentserver/agentserver/internal/namespace"
+	"github.com/agentserver/agentserver/internal/notify"
+	"github.com/agentserver/agentserver/internal/policy"
 	"github.com/agentserver/agentserver/internal/process"
 	"github.com/agentserver/agentserver/internal/sandbox"
 	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/secrets"
 	"github.com/agentserver/agentserver/internal/server"
 	"github.com/agentserver/agentserver/internal/storage"
+	"github.com/agentserver/agentserver/internal/templatebundle"
 	"github.com/agentserver/agentserver/internal/tunnel"
 	"github.com/agentserver/agentserver/web"
 	"github.com/spf13/cobra"
@@ -48,6 +56,17 @@ var serveCmd = &cobra.Command{
 	Short: "Start the agentserver HTTP server",
 	Long:  `Start the web server that provides a browser-based interface to opencode.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		// Dev mode sets BASE_DOMAIN and friends, so it runs before anything
+		// reads them.
+		var devCert devcert.Files
+		if devMode {
+			devCert = setupDevEnv(port)
+		}
+
+		// Resolve vault:<path>#<key> references in the environment before
+		// anything reads it.
+		resolveSecretEnv()
+
 		// Resolve DB URL from flag or env.
 		if dbURL == "" {
 			dbURL = os.Getenv("DATABASE_URL")
@@ -75,6 +94,7 @@ var serveCmd = &cobra.Command{
 		var procMgr process.Manager
 		var driveMgr storage.DriveManager
 		var nsMgr *namespace.Manager
+		var storageReport *storage.PreflightReport
 
 		// Load known sandbox/container names from DB to avoid cleaning paused sandboxes.
 		knownNames, err := database.ListAllActiveSandboxNames()
@@ -82,9 +102,20 @@ var serveCmd = &cobra.Command{
 			log.Printf("Warning: failed to load known sandbox names: %v", err)
 		}
 
+		probes, err := process.ParseProbeConfigs(os.Getenv("SANDBOX_PROBES"))
+		if err != nil {
+			log.Fatalf("Invalid SANDBOX_PROBES: %v", err)
+		}
+		browser, err := process.ParseBrowserSidecar(os.Getenv)
+		if err != nil {
+			log.Fatalf("Invalid browser sidecar config: %v", err)
+		}
+
 		switch backend {
 		case "docker":
 			cfg := container.DefaultConfig()
+			cfg.Probes = probes
+			cfg.Browser = browser
 			if agentImage != "" {
 				cfg.Image = agentImage
 			}
@@ -95,10 +126,12 @@ var serveCmd = &cobra.Command{
 			mgr.CleanOrphans(knownNames)
 			log.Printf("Using Docker backend (image: %s)", cfg.Image)
 			procMgr = mgr
-			driveMgr = storage.NewDockerDriveAdapter(storage.NewDockerWorkspaceDriveManager(database))
+			driveMgr = storage.NewDockerDriveAdapter(storage.NewDockerWorkspaceDriveManager(database, mgr.Client()))
 
 		case "k8s":
 			cfg := sandbox.DefaultConfig()
+			cfg.Probes = probes
+			cfg.Browser = browser
 			if agentImage != "" {
 				cfg.Image = agentImage
 			}
@@ -126,6 +159,7 @@ var serveCmd = &cobra.Command{
 					Enabled:            npEnabled,
 					DenyCIDRs:          npDenyCIDRs,
 					AgentserverNamespace: os.Getenv("AGENTSERVER_NAMESPACE"),
+					DNSFilterImage:     os.Getenv("NETWORKPOLICY_DNS_FILTER_IMAGE"),
 				},
 			})
 
@@ -164,6 +198,12 @@ var serveCmd = &cobra.Command{
 				workspaceDriveStorageClass = storageClass
 			}
 			driveMgr = createK8sDriveManager(database, workspaceDriveSize, workspaceDriveStorageClass)
+			if pf, ok := driveMgr.(storage.Preflighter); ok {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				storageReport = pf.Preflight(ctx, cfg.StorageClassName, cfg.SessionStorageSize)
+				cancel()
+				storageReport.Log()
+			}
 
 		default:
 			log.Fatalf("Unknown backend: %s (supported: docker, k8s)", backend)
@@ -183,7 +223,17 @@ var serveCmd = &cobra.Command{
 		oidcClientID := os.Getenv("OIDC_CLIENT_ID")
 		oidcClientSecret := os.Getenv("OIDC_CLIENT_SECRET")
 
-		if ghClientID != "" || oidcIssuer != "" {
+		// Further generic providers, each with its own routes.
+		var oidcProviders []auth.OIDCProviderConfig
+		if f := os.Getenv("OIDC_PROVIDERS_FILE"); f != "" {
+			var err error
+			oidcProviders, err = auth.LoadOIDCProviders(f)
+			if err != nil {
+				log.Fatalf("Failed to load OIDC_PROVIDERS_FILE: %v", err)
+			}
+		}
+
+		if ghClientID != "" || oidcIssuer != "" || len(oidcProviders) > 0 {
 			if oidcBaseURL == "" {
 				log.Fatal("OIDC_REDIRECT_BASE_URL is required when OIDC providers are configured")
 			}
@@ -203,7 +253,7 @@ var serveCmd = &cobra.Command{
 
 			if oidcIssuer != "" && oidcClientID != "" && oidcClientSecret != "" {
 				genericRedirect := oidcBaseURL + "/api/auth/oidc/oidc/callback"
-				genericProvider, err := auth.NewGenericOIDCProvider(context.Background(), oidcIssuer, oidcClientID, oidcClientSecret, genericRedirect)
+				genericProvider, err := auth.NewGenericOIDCProvider(context.Background(), "oidc", "", oidcIssuer, oidcClientID, oidcClientSecret, genericRedirect)
 				if err != nil {
 					log.Fatalf("Failed to initialize generic OIDC provider: %v", err)
 				}
@@ -211,10 +261,39 @@ var serveCmd = &cobra.Command{
 				oidcMgr.RegisterProviderWithDomains(genericProvider, oidcDomains)
 				log.Printf("OIDC: Generic provider registered (domains: %v)", oidcDomains)
 			}
+
+			for _, pc := range oidcProviders {
+				if oidcMgr.HasProvider(pc.Name) {
+					log.Fatalf("OIDC_PROVIDERS_FILE: provider %q is already configured", pc.Name)
+				}
+				baseURL := pc.RedirectBaseURL
+				if baseURL == "" {
+					baseURL = oidcBaseURL
+				}
+				redirect := baseURL + "/api/auth/oidc/" + pc.Name + "/callback"
+				p, err := auth.NewGenericOIDCProvider(context.Background(), pc.Name, pc.Label, pc.IssuerURL, pc.ClientID, pc.ClientSecret, redirect)
+				if err != nil {
+					log.Fatalf("Failed to initialize OIDC provider %s: %v", pc.Name, err)
+				}
+				oidcMgr.RegisterProviderWithDomains(p, pc.AllowedDomains)
+				log.Printf("OIDC: %s provider registered (issuer %s, domains: %v)", pc.Name, pc.IssuerURL, pc.AllowedDomains)
+			}
 		}
 
 		srv := server.New(authSvc, oidcMgr, database, sandboxStore, procMgr, driveMgr, nsMgr, tunnel.NewRegistry(), staticFS, !strings.EqualFold(os.Getenv("PASSWORD_AUTH_ENABLED"), "false"))
+		if err := srv.BootstrapAdmin(auth.AdminBootstrapFromEnv()); err != nil {
+			log.Fatalf("Admin bootstrap failed: %v", err)
+		}
+		trusted, err := auth.TrustedHeaderFromEnv()
+		if err != nil {
+			log.Fatalf("Trusted header auth: %v", err)
+		}
+		if trusted != nil {
+			srv.EnableTrustedHeaderAuth(trusted)
+			log.Printf("Trusted header auth enabled (header %s, proxies %v)", trusted.EmailHeader, trusted.Proxies)
+		}
 		srv.DatabaseURL = dbURL
+		srv.StorageReport = storageReport
 		srv.IMBridgeURL = os.Getenv("IMBRIDGE_URL")
 		srv.LLMProxyURL = os.Getenv("LLMPROXY_URL")
 		srv.ModelserverOAuthClientID = os.Getenv("MODELSERVER_OAUTH_CLIENT_ID")
@@ -283,6 +362,149 @@ var serveCmd = &cobra.Command{
 		}
 		srv.OperationsRetention = time.Duration(retentionDays) * 24 * time.Hour
 
+		// Controller metrics at /metrics. Disabled without METRICS_TOKEN.
+		srv.MetricsToken = os.Getenv("METRICS_TOKEN")
+
+		// Orphan cleanup ran before the server existed.
+		srv.ControllerHeartbeat(server.ControllerOrphanClean, 0)(nil)
+
+		// Email delivery of monthly usage statements and notifications.
+		// Disabled without SMTP_ADDR.
+		var notifyBackends []notify.Backend
+		if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+			srv.StatementMailer = &server.Mailer{
+				Addr:     addr,
+				From:     os.Getenv("SMTP_FROM"),
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+			}
+			if srv.StatementMailer.From == "" {
+				srv.StatementMailer.From = "agentserver@localhost"
+			}
+			m := srv.StatementMailer
+			notifyBackends = append(notifyBackends, &notify.SMTP{Addr: m.Addr, From: m.From, Username: m.Username, Password: m.Password})
+		}
+		// Notifications are also POSTed to NOTIFY_WEBHOOK_URL, signed with
+		// NOTIFY_WEBHOOK_SECRET if set.
+		if u := os.Getenv("NOTIFY_WEBHOOK_URL"); u != "" {
+			if pu, err := url.Parse(u); err != nil || (pu.Scheme != "http" && pu.Scheme != "https") || pu.Host == "" {
+				log.Printf("Warning: NOTIFY_WEBHOOK_URL=%q invalid, notification webhook disabled", u)
+			} else {
+				notifyBackends = append(notifyBackends, &notify.Webhook{URL: u, Secret: os.Getenv("NOTIFY_WEBHOOK_SECRET")})
+			}
+		}
+		if len(notifyBackends) > 0 {
+			srv.Notifier = notify.New(database, notifyBackends...)
+		}
+
+		// Admin digest period (ADMIN_DIGEST_INTERVAL, default weekly; 0
+		// disables). Digests are emailed to the admins when SMTP_ADDR is set.
+		srv.AdminDigestInterval = 7 * 24 * time.Hour
+		if v := os.Getenv("ADMIN_DIGEST_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				srv.AdminDigestInterval = d
+			} else {
+				log.Printf("Warning: ADMIN_DIGEST_INTERVAL=%q invalid, using default %s", v, srv.AdminDigestInterval)
+			}
+		}
+
+		// Poll interval suggested to clients on list endpoints
+		// (CLIENT_POLL_INTERVAL, default 2s).
+		if v := os.Getenv("CLIENT_POLL_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= time.Second {
+				srv.ClientPollInterval = d
+			} else {
+				log.Printf("Warning: CLIENT_POLL_INTERVAL=%q invalid, using default 2s", v)
+			}
+		}
+
+		// Deletion of long-paused sandboxes (PAUSED_SANDBOX_RETENTION,
+		// default 0: kept forever; workspace quotas can override it), with a
+		// warning PAUSED_SANDBOX_RETENTION_WARNING before (default 72h).
+		if v := os.Getenv("PAUSED_SANDBOX_RETENTION"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				srv.PausedRetention = d
+			} else {
+				log.Printf("Warning: PAUSED_SANDBOX_RETENTION=%q invalid, keeping paused sandboxes", v)
+			}
+		}
+		if v := os.Getenv("PAUSED_SANDBOX_RETENTION_WARNING"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				srv.PausedRetentionWarning = d
+			} else {
+				log.Printf("Warning: PAUSED_SANDBOX_RETENTION_WARNING=%q invalid, using default 72h", v)
+			}
+		}
+
+		// Commit identity of drive mirrors (DRIVE_MIRROR_AUTHOR, e.g.
+		// "Agent Bot <bot@example.com>").
+		if v := os.Getenv("DRIVE_MIRROR_AUTHOR"); v != "" {
+			if addr, err := mail.ParseAddress(v); err == nil {
+				srv.MirrorAuthorName, srv.MirrorAuthorEmail = addr.Name, addr.Address
+			} else {
+				log.Printf("Warning: DRIVE_MIRROR_AUTHOR=%q invalid, using default agentserver identity", v)
+			}
+		}
+
+		// Forwarding of security events to syslog or a SIEM webhook.
+		if sink := os.Getenv("SECURITY_EVENT_SINK"); sink != "" {
+			fwd, err := server.NewSecurityEventSink(sink, os.Getenv("SECURITY_EVENT_MIN_SEVERITY"))
+			if err != nil {
+				log.Printf("Warning: security event forwarding disabled: %v", err)
+			} else {
+				srv.SecurityEventSink = fwd
+			}
+		}
+
+		// Periodic exports of opencode session storage (SESSION_HISTORY_STORE
+		// = db or s3). Disabled when unset.
+		if kind := os.Getenv("SESSION_HISTORY_STORE"); kind != "" {
+			store, err := server.NewSessionHistoryStore(kind, database, server.S3SessionHistoryConfig{
+				Endpoint:        os.Getenv("SESSION_HISTORY_S3_ENDPOINT"),
+				Region:          envOrDefault("SESSION_HISTORY_S3_REGION", "us-east-1"),
+				Bucket:          os.Getenv("SESSION_HISTORY_S3_BUCKET"),
+				Prefix:          os.Getenv("SESSION_HISTORY_S3_PREFIX"),
+				AccessKeyID:     os.Getenv("SESSION_HISTORY_S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("SESSION_HISTORY_S3_SECRET_ACCESS_KEY"),
+				PathStyle:       os.Getenv("SESSION_HISTORY_S3_PATH_STYLE") == "true",
+			})
+			if err != nil {
+				log.Printf("Warning: session history export disabled: %v", err)
+			} else {
+				srv.SessionHistory = store
+			}
+		}
+		if v := os.Getenv("SESSION_HISTORY_RETENTION"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				srv.SessionHistoryRetention = n
+			} else {
+				log.Printf("Warning: SESSION_HISTORY_RETENTION=%q invalid, using default", v)
+			}
+		}
+
+		// Template bundle signing and import trust.
+		if key := os.Getenv("TEMPLATE_BUNDLE_SIGNING_KEY"); key != "" {
+			priv, err := templatebundle.ParsePrivateKey(key)
+			if err != nil {
+				log.Printf("Warning: template bundle signing disabled: %v", err)
+			} else {
+				srv.TemplateSigningKey = priv
+				srv.TemplateSigningKeyID = os.Getenv("TEMPLATE_BUNDLE_KEY_ID")
+				if srv.TemplateSigningKeyID == "" {
+					srv.TemplateSigningKeyID = "agentserver"
+				}
+			}
+		}
+		if keys := os.Getenv("TEMPLATE_BUNDLE_TRUSTED_KEYS"); keys != "" {
+			trusted, err := templatebundle.ParseTrustedKeys(keys)
+			if err != nil {
+				log.Printf("Warning: no template bundle keys trusted: %v", err)
+			} else {
+				srv.TemplateTrustedKeys = trusted
+			}
+		}
+		srv.AllowUnsignedTemplates = os.Getenv("TEMPLATE_BUNDLE_ALLOW_UNSIGNED") == "true"
+
 		// Hydra OAuth2 for agent Device Flow.
 		hydraAdminURL := os.Getenv("HYDRA_ADMIN_URL")
 		hydraPublicURL := os.Getenv("HYDRA_PUBLIC_URL")
@@ -303,6 +525,28 @@ var serveCmd = &cobra.Command{
 			log.Printf("Credential proxy enabled (credproxy URL: %s)", srv.CredproxyPublicURL)
 		}
 
+		// Workspace secrets master key.
+		if os.Getenv("WORKSPACE_SECRETS_KEY") != "" {
+			key, err := crypto.LoadKeyFromEnv("WORKSPACE_SECRETS_KEY")
+			if err != nil {
+				log.Fatalf("Failed to load WORKSPACE_SECRETS_KEY: %v", err)
+			}
+			srv.SecretsKey = key
+		}
+
+		// GitOps policy file (quotas, allowed types/images, templates).
+		if policyFile := os.Getenv("POLICY_FILE"); policyFile != "" {
+			w, err := policy.NewWatcher(policyFile)
+			if err != nil {
+				log.Fatalf("Failed to load POLICY_FILE: %v", err)
+			}
+			srv.Policy = w
+		}
+
+		// Report settings whose env value is shadowed by an admin override
+		// or the policy file (see GET /api/admin/config-drift).
+		srv.LogConfigDrift()
+
 		addr := fmt.Sprintf(":%d", port)
 
 		// Start idle watcher with a dynamic timeout getter that reads from the settings chain.
@@ -312,18 +556,147 @@ var serveCmd = &cobra.Command{
 		// No OnPrePause callback needed — the poller skips forwarding
 		// when the sandbox is not running (checks status='running' and pod_ip != '').
 		// The channel binding is preserved so messages resume on unpause.
+		idleWatcher.SetOnCheck(srv.ControllerHeartbeat(server.ControllerIdleWatcher, time.Minute))
+		// Warn before pausing idle sandboxes (IDLE_PAUSE_WARNING, default
+		// 10m; 0 disables). Only delivered when notifications are set up.
+		if srv.Notifier != nil {
+			idleWarning := 10 * time.Minute
+			if v := os.Getenv("IDLE_PAUSE_WARNING"); v != "" {
+				if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+					idleWarning = d
+				} else {
+					log.Printf("Warning: IDLE_PAUSE_WARNING=%q invalid, using default %s", v, idleWarning)
+				}
+			}
+			idleWatcher.SetOnPauseWarning(idleWarning, srv.NotifyIdlePause)
+		}
 		idleWatcher.Start()
 		log.Printf("Idle watcher started (effective timeout: %s)", srv.GetEffectiveIdleTimeout())
 
 		// Agent health monitor
 		healthCtx, healthCancel := context.WithCancel(context.Background())
 		healthMon := server.NewAgentHealthMonitor(database)
+		healthMon.OnOffline = srv.NotifyAgentsOffline
 		go healthMon.Run(healthCtx)
 
+		// Sandbox migrations run in-process; any still marked running were
+		// cut short by the previous shutdown.
+		if n, err := database.FailInterruptedSandboxMigrations(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else if n > 0 {
+			log.Printf("Marked %d interrupted sandbox migrations failed", n)
+		}
+		if n, err := database.FailInterruptedSandboxStorage(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else if n > 0 {
+			log.Printf("Marked %d sandboxes with interrupted drive provisioning storage-failed", n)
+		}
+
 		// Operations retention background loop. Disabled when TTL is 0.
 		go srv.StartRetentionLoop(healthCtx, srv.OperationsRetention, time.Hour)
 
-		httpServer := &http.Server{Addr: addr, Handler: srv.Router()}
+		// Hourly cleanup of expired tokens and registration codes.
+		go srv.StartCredentialPruneLoop(healthCtx, time.Hour)
+
+		// Hourly removal of sandbox tombstones older than 90 days.
+		go srv.StartSandboxTombstonePruneLoop(healthCtx, time.Hour)
+
+		// Monthly usage statements: generates (and emails) last month's
+		// statements once the month is over.
+		go srv.StartStatementLoop(healthCtx, time.Hour)
+
+		// Periodic admin digest of users, sandboxes, token usage and storage.
+		go srv.StartAdminDigestLoop(healthCtx)
+
+		// Scheduled pausing and resuming of sandboxes (e.g. nightly
+		// shutdowns), alongside the idle watcher.
+		go srv.StartSandboxScheduleLoop(healthCtx, time.Minute)
+
+		// Warns of and deletes sandboxes paused for longer than their
+		// workspace's retention period.
+		go srv.StartPausedSandboxReaperLoop(healthCtx, time.Hour)
+
+		// Periodic commit, pull and push of workspace drive mirrors.
+		go srv.StartDriveMirrorLoop(healthCtx, time.Minute)
+
+		// Re-checks cluster capacity for sandbox starts queued while the
+		// cluster is full (SANDBOX_SCHEDULING_QUEUE=true).
+		go srv.StartSchedulingQueueLoop(healthCtx)
+
+		// Alerts on controllers (idle watcher, operator, the loops above)
+		// that stopped running; see GET /api/admin/controllers.
+		go srv.StartControllerWatchdogLoop(healthCtx, time.Minute)
+
+		// Hourly removal of drive volumes left behind by deleted workspaces
+		// (Docker backend).
+		go srv.StartDriveGCLoop(healthCtx, time.Hour)
+
+		// Daily vulnerability scans of sandbox images (IMAGE_SCANNER).
+		// IMAGE_SCAN_INTERVAL overrides the interval.
+		imageScanInterval := 24 * time.Hour
+		if v := os.Getenv("IMAGE_SCAN_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				imageScanInterval = d
+			} else {
+				log.Printf("ignoring invalid IMAGE_SCAN_INTERVAL %q", v)
+			}
+		}
+		go srv.StartImageScanLoop(healthCtx, imageScanInterval)
+
+		if srv.Policy != nil {
+			go srv.Policy.Run(healthCtx, 10*time.Second)
+		}
+
+		// Hourly session history exports of running opencode sandboxes.
+		// SESSION_HISTORY_INTERVAL overrides the interval.
+		sessionHistoryInterval := time.Hour
+		if v := os.Getenv("SESSION_HISTORY_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d > 0 {
+				sessionHistoryInterval = d
+			} else {
+				log.Printf("Warning: SESSION_HISTORY_INTERVAL=%q invalid, using default %s", v, sessionHistoryInterval)
+			}
+		}
+		go srv.StartSessionHistoryLoop(healthCtx, sessionHistoryInterval)
+
+		// Sandbox tool-version probes for drift detection — hourly by
+		// default, 0 disables. Env var ENVIRONMENT_PROBE_INTERVAL overrides.
+		envProbeInterval := time.Hour
+		if v := os.Getenv("ENVIRONMENT_PROBE_INTERVAL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				envProbeInterval = d
+			} else {
+				log.Printf("Warning: ENVIRONMENT_PROBE_INTERVAL=%q invalid, using default %s", v, envProbeInterval)
+			}
+		}
+		go srv.StartEnvironmentProbeLoop(healthCtx, envProbeInterval)
+
+		if backend == "k8s" {
+			if err := startOperator(healthCtx, srv, database); err != nil {
+				log.Fatalf("Operator: %v", err)
+			}
+		}
+
+		var handler http.Handler = srv.Router()
+		if devMode {
+			handler = devHandler(handler, newDevProxy(authSvc, database, sandboxStore, srv.TunnelRegistry))
+		}
+		httpServer := &http.Server{Addr: addr, Handler: handler}
+
+		// Optional gRPC sandbox lifecycle API on its own listener.
+		grpcServer := srv.GRPCServer()
+		if grpcAddr := os.Getenv("GRPC_LISTEN_ADDR"); grpcAddr != "" {
+			lis, err := net.Listen("tcp", grpcAddr)
+			if err != nil {
+				log.Fatalf("gRPC listen on %s: %v", grpcAddr, err)
+			}
+			log.Printf("Starting gRPC sandbox API on %s", grpcAddr)
+			go func() {
+				if err := grpcServer.Serve(lis); err != nil {
+					log.Printf("gRPC server: %v", err)
+				}
+			}()
+		}
 
 		// Graceful shutdown on SIGTERM/SIGINT
 		go func() {
@@ -332,6 +705,7 @@ var serveCmd = &cobra.Command{
 			sig := <-sigCh
 			log.Printf("Received %v, shutting down...", sig)
 			httpServer.Shutdown(context.Background())
+			grpcServer.Stop() // not GracefulStop: watch streams never end on their own
 			srv.Close()
 			idleWatcher.Stop()
 			healthCancel()
@@ -340,7 +714,12 @@ var serveCmd = &cobra.Command{
 		}()
 
 		log.Printf("Starting agentserver on %s", addr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if devMode {
+			err = httpServer.ListenAndServeTLS(devCert.Cert, devCert.Key)
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
 	},
@@ -358,6 +737,18 @@ func createK8sDriveManager(database *db.DB, storageSize int64, storageClassName
 		return storage.NilDriveManager{}
 	}
 	mgr := storage.NewWorkspaceDriveManager(database, clientset, storageSize, storageClassName)
+	mgr.SetSnapshotClassName(os.Getenv("VOLUME_SNAPSHOT_CLASS"))
+
+	strategies, err := storage.ParseAccessModeStrategies(os.Getenv("WORKSPACE_DRIVE_ACCESS_MODE"))
+	if err != nil {
+		log.Fatalf("Invalid WORKSPACE_DRIVE_ACCESS_MODE: %v", err)
+	}
+	mgr.SetAccessModeStrategies(strategies)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := mgr.ResolveAccessMode(ctx); err != nil {
+		log.Printf("Warning: workspace drive storage class validation failed, defaulting to ReadWriteMany: %v", err)
+	}
 	return storage.NewK8sDriveAdapter(mgr)
 }
 
@@ -464,4 +855,21 @@ func init() {
 	serveCmd.Flags().StringVar(&agentImage, "agent-image", "", "Container image for agent sessions")
 	serveCmd.Flags().StringVar(&backend, "backend", "docker", "Session backend: docker or k8s")
 	serveCmd.Flags().StringVar(&dbURL, "db-url", "", "PostgreSQL connection URL (or use DATABASE_URL env)")
+	serveCmd.Flags().BoolVar(&devMode, "dev", false, "Local development: HTTPS with a generated wildcard certificate and the sandbox proxy in-process")
+	serveCmd.Flags().StringVar(&devDomain, "dev-domain", "localtest.me", "Base domain in dev mode; must resolve to this machine, subdomains included")
+	serveCmd.Flags().StringVar(&devDir, "dev-dir", "", "Directory for the dev CA and certificate (default ~/.agentserver/dev)")
+}
+
+// resolveSecretEnv replaces environment variables that reference a secret
+// in the configured secrets backend (Vault) with the secret.
+func resolveSecretEnv() {
+	backend, err := secrets.FromEnv()
+	if err != nil {
+		log.Fatalf("Secrets backend: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := secrets.ResolveEnv(ctx, backend); err != nil {
+		log.Fatalf("Failed to resolve secret: %v", err)
+	}
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/smoketest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	smokeServer       string
+	smokeEmailDomain  string
+	smokeSandboxType  string
+	smokeReadyTimeout time.Duration
+	smokeTimeout      time.Duration
+	smokeInsecure     bool
+	smokeJSON         bool
+)
+
+var smokeTestCmd = &cobra.Command{
+	Use:   "smoke-test",
+	Short: "Exercise the critical path of a live deployment",
+	Long: `Register a throwaway account, create a sandbox, wait until it is running,
+load its web UI through the sandbox proxy, pause and resume it, then delete
+the sandbox and workspace. Prints the duration of each step and exits
+non-zero if any step fails, for post-deploy and canary checks.
+
+Password login must be enabled. The throwaway account (smoke-<random>@
+--email-domain) is left behind; erase it as an admin.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if smokeServer == "" {
+			fmt.Fprintln(os.Stderr, "--server is required")
+			os.Exit(2)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), smokeTimeout)
+		defer cancel()
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		report := smoketest.Run(ctx, smoketest.Config{
+			Server:       smokeServer,
+			EmailDomain:  smokeEmailDomain,
+			SandboxType:  smokeSandboxType,
+			ReadyTimeout: smokeReadyTimeout,
+			Insecure:     smokeInsecure,
+		})
+		if smokeJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(report)
+		} else {
+			printSmokeReport(report)
+		}
+		if !report.OK {
+			os.Exit(1)
+		}
+	},
+}
+
+func printSmokeReport(report *smoketest.Report) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tRESULT\tDURATION")
+	for _, st := range report.Steps {
+		result := "ok"
+		switch {
+		case st.Skipped:
+			result = "skipped"
+		case st.Error != "":
+			result = "FAIL: " + st.Error
+		}
+		d := "-"
+		if !st.Skipped {
+			d = st.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", st.Name, result, d)
+	}
+	tw.Flush()
+	if len(report.LeftOver) > 0 {
+		fmt.Printf("\nLeft over: %s\n", strings.Join(report.LeftOver, ", "))
+	}
+	status := "PASS"
+	if !report.OK {
+		status = "FAIL"
+	}
+	fmt.Printf("\n%s %s in %s\n", status, report.Server, (time.Duration(report.DurationMS) * time.Millisecond).String())
+}
+
+func init() {
+	rootCmd.AddCommand(smokeTestCmd)
+	smokeTestCmd.Flags().StringVar(&smokeServer, "server", "", "Base URL of the deployment, e.g. https://cli.example.com")
+	smokeTestCmd.Flags().StringVar(&smokeEmailDomain, "email-domain", "smoke-test.invalid", "Email domain of the throwaway account")
+	smokeTestCmd.Flags().StringVar(&smokeSandboxType, "type", "opencode", "Type of the sandbox to create")
+	smokeTestCmd.Flags().DurationVar(&smokeReadyTimeout, "ready-timeout", 5*time.Minute, "How long to wait for the sandbox to start, pause or resume")
+	smokeTestCmd.Flags().DurationVar(&smokeTimeout, "timeout", 15*time.Minute, "Overall time limit; cleanup runs after it")
+	smokeTestCmd.Flags().BoolVar(&smokeInsecure, "insecure", false, "Skip TLS certificate verification")
+	smokeTestCmd.Flags().BoolVar(&smokeJSON, "json", false, "Print the report as JSON")
+}
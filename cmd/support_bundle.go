@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerclient "github.com/docker/docker/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/supportbundle"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleOutput    string
+	bundleBackend   string
+	bundleDBURL     string
+	bundleNamespace string
+	bundleLogLines  int64
+	bundleLogFiles  []string
+)
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect a sanitized support bundle for filing issues",
+	Long: `Gather sanitized configuration, recent logs, database statistics,
+backend health and Kubernetes object summaries into a .tar.gz archive.
+
+Secrets are redacted from environment variables, URLs and log lines, and
+the database section contains counts only. Review the archive before
+sharing it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if bundleDBURL == "" {
+			bundleDBURL = os.Getenv("DATABASE_URL")
+		}
+		now := time.Now().UTC()
+		dir := "agentserver-support-" + now.Format("20060102T150405Z")
+		if bundleOutput == "" {
+			bundleOutput = dir + ".tar.gz"
+		}
+
+		f, err := os.Create(bundleOutput)
+		if err != nil {
+			log.Fatalf("Create %s: %v", bundleOutput, err)
+		}
+		defer f.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+
+		b := supportbundle.NewWriter(f, dir)
+		step := func(name string, err error) {
+			if err != nil {
+				log.Printf("support-bundle: %s: %v", name, err)
+			}
+		}
+
+		step("version", b.AddJSON("version.json", map[string]string{
+			"version":   Version,
+			"go":        runtime.Version(),
+			"os":        runtime.GOOS,
+			"arch":      runtime.GOARCH,
+			"collected": now.Format(time.RFC3339),
+			"backend":   bundleBackend,
+			"hostname":  hostname(),
+		}))
+		step("config", b.AddJSON("config.json", supportbundle.SanitizeEnv(os.Environ())))
+
+		if bundleDBURL == "" {
+			step("database", b.AddResult("db.json", nil, fmt.Errorf("--db-url or DATABASE_URL not set")))
+		} else {
+			database, err := db.Connect(bundleDBURL)
+			if err != nil {
+				step("database", b.AddResult("db.json", nil, err))
+			} else {
+				stats, err := database.SupportStats()
+				step("database", b.AddResult("db.json", stats, err))
+				database.Close()
+			}
+		}
+
+		switch bundleBackend {
+		case "docker":
+			collectDockerSummary(ctx, b, step)
+		case "k8s":
+			collectK8sSummary(ctx, b, step)
+		default:
+			step("backend", b.AddResult("backend.json", nil, fmt.Errorf("unknown backend %q", bundleBackend)))
+		}
+
+		for _, path := range bundleLogFiles {
+			data, err := tailFile(path, bundleLogLines)
+			name := "logs/files/" + strings.TrimLeft(strings.ReplaceAll(path, "/", "_"), "_")
+			if err != nil {
+				step("log file", b.Add(name+".error", []byte(err.Error()+"\n")))
+				continue
+			}
+			step("log file", b.Add(name, []byte(supportbundle.SanitizeText(string(data)))))
+		}
+
+		if err := b.Close(); err != nil {
+			log.Fatalf("Write %s: %v", bundleOutput, err)
+		}
+		fmt.Printf("Support bundle written to %s\n", bundleOutput)
+	},
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
+
+// tailFile returns the last n lines of a file.
+func tailFile(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if int64(len(lines)) > n {
+			lines = lines[1:]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func collectDockerSummary(ctx context.Context, b *supportbundle.Writer, step func(string, error)) {
+	cli, err := dockerclient.NewClientWithOpts(dockerclient.FromEnv, dockerclient.WithAPIVersionNegotiation())
+	if err != nil {
+		step("docker", b.AddResult("backend/docker.json", nil, err))
+		return
+	}
+	defer cli.Close()
+
+	info, err := cli.Info(ctx)
+	step("docker", b.AddResult("backend/docker.json", map[string]interface{}{
+		"server_version":     info.ServerVersion,
+		"os":                 info.OperatingSystem,
+		"kernel":             info.KernelVersion,
+		"storage_driver":     info.Driver,
+		"cpus":               info.NCPU,
+		"memory_bytes":       info.MemTotal,
+		"containers":         info.Containers,
+		"containers_running": info.ContainersRunning,
+		"images":             info.Images,
+	}, err))
+
+	list, err := cli.ContainerList(ctx, dockercontainer.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "managed-by=agentserver")),
+	})
+	type containerSummary struct {
+		Name   string `json:"name"`
+		Image  string `json:"image"`
+		State  string `json:"state"`
+		Status string `json:"status"`
+	}
+	var containers []containerSummary
+	for _, c := range list {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		containers = append(containers, containerSummary{Name: name, Image: c.Image, State: c.State, Status: c.Status})
+	}
+	step("docker containers", b.AddResult("backend/containers.json", containers, err))
+}
+
+func collectK8sSummary(ctx context.Context, b *supportbundle.Writer, step func(string, error)) {
+	restCfg, err := buildRESTConfig()
+	if err != nil {
+		step("k8s", b.AddResult("backend/k8s.json", nil, err))
+		return
+	}
+	clientset, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		step("k8s", b.AddResult("backend/k8s.json", nil, err))
+		return
+	}
+
+	version, err := clientset.Discovery().ServerVersion()
+	step("k8s version", b.AddResult("backend/k8s-version.json", version, err))
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	type nodeSummary struct {
+		Name          string `json:"name"`
+		Ready         bool   `json:"ready"`
+		Kubelet       string `json:"kubelet"`
+		CPU           string `json:"allocatable_cpu"`
+		Memory        string `json:"allocatable_memory"`
+		Unschedulable bool   `json:"unschedulable,omitempty"`
+	}
+	var nodeList []nodeSummary
+	if err == nil {
+		for _, n := range nodes.Items {
+			ready := false
+			for _, c := range n.Status.Conditions {
+				if c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue {
+					ready = true
+				}
+			}
+			nodeList = append(nodeList, nodeSummary{
+				Name:          n.Name,
+				Ready:         ready,
+				Kubelet:       n.Status.NodeInfo.KubeletVersion,
+				CPU:           n.Status.Allocatable.Cpu().String(),
+				Memory:        n.Status.Allocatable.Memory().String(),
+				Unschedulable: n.Spec.Unschedulable,
+			})
+		}
+	}
+	step("k8s nodes", b.AddResult("backend/nodes.json", nodeList, err))
+
+	managed := metav1.ListOptions{LabelSelector: "managed-by=agentserver"}
+	summary := map[string]interface{}{}
+	if nss, err := clientset.CoreV1().Namespaces().List(ctx, managed); err == nil {
+		summary["workspace_namespaces"] = len(nss.Items)
+	} else {
+		summary["workspace_namespaces_error"] = err.Error()
+	}
+	if pods, err := clientset.CoreV1().Pods("").List(ctx, managed); err == nil {
+		phases := map[string]int{}
+		reasons := map[string]int{}
+		for _, p := range pods.Items {
+			phases[string(p.Status.Phase)]++
+			for _, cs := range p.Status.ContainerStatuses {
+				if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+					reasons[cs.State.Waiting.Reason]++
+				}
+			}
+		}
+		summary["sandbox_pods_by_phase"] = phases
+		summary["sandbox_containers_waiting"] = reasons
+	} else {
+		summary["sandbox_pods_error"] = err.Error()
+	}
+	if pvcs, err := clientset.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{}); err == nil {
+		phases := map[string]int{}
+		for _, pvc := range pvcs.Items {
+			if _, ok := pvc.Labels["managed-by"]; ok || strings.HasPrefix(pvc.Namespace, envOrDefault("SANDBOX_NAMESPACE_PREFIX", "agent-ws")+"-") {
+				phases[string(pvc.Status.Phase)]++
+			}
+		}
+		summary["pvcs_by_phase"] = phases
+	} else {
+		summary["pvcs_error"] = err.Error()
+	}
+	step("k8s objects", b.AddJSON("backend/objects.json", summary))
+
+	if bundleNamespace == "" {
+		return
+	}
+	pods, err := clientset.CoreV1().Pods(bundleNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		step("k8s logs", b.AddResult("logs/pods.json", nil, err))
+		return
+	}
+	for _, p := range pods.Items {
+		for _, c := range p.Spec.Containers {
+			tail := bundleLogLines
+			data, err := clientset.CoreV1().Pods(bundleNamespace).GetLogs(p.Name, &corev1.PodLogOptions{
+				Container:  c.Name,
+				TailLines:  &tail,
+				Timestamps: true,
+			}).DoRaw(ctx)
+			name := "logs/" + p.Name + "/" + c.Name + ".log"
+			if err != nil {
+				step("k8s logs", b.Add(name+".error", []byte(err.Error()+"\n")))
+				continue
+			}
+			step("k8s logs", b.Add(name, bytes.TrimSpace([]byte(supportbundle.SanitizeText(string(data))))))
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(supportBundleCmd)
+	supportBundleCmd.Flags().StringVarP(&bundleOutput, "output", "o", "", "Archive path (default agentserver-support-<time>.tar.gz)")
+	supportBundleCmd.Flags().StringVar(&bundleBackend, "backend", "docker", "Session backend: docker or k8s")
+	supportBundleCmd.Flags().StringVar(&bundleDBURL, "db-url", "", "PostgreSQL connection URL (or use DATABASE_URL env)")
+	supportBundleCmd.Flags().StringVar(&bundleNamespace, "namespace", os.Getenv("AGENTSERVER_NAMESPACE"), "Namespace of the agentserver pods whose logs to include (k8s)")
+	supportBundleCmd.Flags().Int64Var(&bundleLogLines, "log-lines", 2000, "Number of log lines to include per log source")
+	supportBundleCmd.Flags().StringSliceVar(&bundleLogFiles, "log-file", nil, "Additional log file to include (repeatable)")
+}
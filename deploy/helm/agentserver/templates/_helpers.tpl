@@ -53,3 +53,22 @@ override via externalUrl.
 postgres://{{ .Values.postgresql.auth.username }}:{{ .Values.postgresql.auth.password }}@{{ .Release.Name }}-postgresql:5432/{{ $dbName }}?sslmode=disable
 {{- end -}}
 {{- end -}}
+
+{{/*
+Name of the PriorityClass created for a sandbox priority tier.
+*/}}
+{{- define "agentserver.sandboxPriorityClassName" -}}
+{{ .release }}-sandbox-{{ .tier }}
+{{- end -}}
+
+{{/*
+SANDBOX_PRIORITY_CLASSES: "tier=PriorityClass,..." for the tiers in
+sandboxPriority.classes.
+*/}}
+{{- define "agentserver.sandboxPriorityClasses" -}}
+{{- $pairs := list -}}
+{{- range $tier, $_ := .Values.sandboxPriority.classes -}}
+{{- $pairs = append $pairs (printf "%s=%s" $tier (include "agentserver.sandboxPriorityClassName" (dict "release" $.Release.Name "tier" $tier))) -}}
+{{- end -}}
+{{ join "," $pairs }}
+{{- end -}}
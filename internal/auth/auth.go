@@ -4,11 +4,14 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/agentserver/agentserver/internal/clientmeta"
 	"github.com/agentserver/agentserver/internal/db"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,6 +19,8 @@ import (
 const (
 	cookieName = "agentserver-token"
 	tokenTTL   = 7 * 24 * time.Hour
+
+	maxUserAgentLen = 512
 )
 
 // cookieDomain returns the Domain attribute for the session cookie.
@@ -27,12 +32,21 @@ func cookieDomain() string {
 	return os.Getenv("AGENTSERVER_COOKIE_DOMAIN")
 }
 
+// SecureCookies reports whether cookies get the Secure attribute. Only
+// `serve --dev` turns it off (AGENTSERVER_INSECURE_COOKIES=true), so local
+// setups without a trusted certificate can still log in over plain HTTP.
+func SecureCookies() bool {
+	return os.Getenv("AGENTSERVER_INSECURE_COOKIES") != "true"
+}
+
 type contextKey string
 
 const userIDKey contextKey = "userID"
 
 type Auth struct {
-	db *db.DB
+	db      *db.DB
+	admins  AdminBootstrap
+	trusted *TrustedHeader
 }
 
 func New(database *db.DB) *Auth {
@@ -51,10 +65,11 @@ func (a *Auth) Register(id, email, password string) error {
 	return nil
 }
 
-// Login verifies credentials by email and returns a token.
+// Login verifies credentials by email and returns a token. Disabled users
+// can't log in.
 func (a *Auth) Login(email, password string) (string, string, bool) {
 	user, err := a.db.GetUserByEmail(email)
-	if err != nil || user == nil {
+	if err != nil || user == nil || user.DisabledAt != nil {
 		return "", "", false
 	}
 	hash, err := a.db.GetPasswordHash(user.ID)
@@ -79,9 +94,72 @@ func (a *Auth) IssueToken(userID string) (string, error) {
 	if err := a.db.CreateToken(token, userID, time.Now().Add(tokenTTL)); err != nil {
 		return "", err
 	}
+	a.enforceSessionPolicy(userID)
 	return token, nil
 }
 
+// System setting keys for the login session policy.
+const (
+	SettingKeyMaxActiveTokens = "session_max_active_tokens"
+	SettingKeySingleSession   = "session_single_session"
+)
+
+// SessionPolicy limits how many login sessions a user may hold at once.
+// When a new session would exceed the limit, the oldest sessions are evicted.
+type SessionPolicy struct {
+	MaxActiveTokens int  // 0 means unlimited
+	SingleSession   bool // equivalent to MaxActiveTokens = 1
+}
+
+// Limit returns the effective number of sessions a user may keep, or 0 if
+// unlimited.
+func (p SessionPolicy) Limit() int {
+	if p.SingleSession {
+		return 1
+	}
+	return p.MaxActiveTokens
+}
+
+// SessionPolicy resolves the policy from system settings, falling back to the
+// SESSION_MAX_ACTIVE_TOKENS and SESSION_SINGLE_SESSION environment variables.
+func (a *Auth) SessionPolicy() SessionPolicy {
+	var p SessionPolicy
+	if v := os.Getenv("SESSION_MAX_ACTIVE_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			p.MaxActiveTokens = n
+		}
+	}
+	if v := os.Getenv("SESSION_SINGLE_SESSION"); v != "" {
+		p.SingleSession, _ = strconv.ParseBool(v)
+	}
+	if v, err := a.db.GetSystemSetting(SettingKeyMaxActiveTokens); err == nil && v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			p.MaxActiveTokens = n
+		}
+	}
+	if v, err := a.db.GetSystemSetting(SettingKeySingleSession); err == nil && v != "" {
+		p.SingleSession, _ = strconv.ParseBool(v)
+	}
+	return p
+}
+
+// enforceSessionPolicy evicts the user's oldest tokens beyond the policy
+// limit. The token just issued is the newest, so it is always kept.
+func (a *Auth) enforceSessionPolicy(userID string) {
+	limit := a.SessionPolicy().Limit()
+	if limit <= 0 {
+		return
+	}
+	n, err := a.db.EvictOldestTokens(userID, limit)
+	if err != nil {
+		log.Printf("auth: failed to enforce session limit for %s: %v", userID, err)
+		return
+	}
+	if n > 0 {
+		log.Printf("auth: evicted %d session(s) for user %s (limit %d)", n, userID, limit)
+	}
+}
+
 // ValidateToken checks the token against the database and returns the user ID.
 func (a *Auth) ValidateToken(token string) (string, bool) {
 	userID, err := a.db.ValidateToken(token)
@@ -91,31 +169,67 @@ func (a *Auth) ValidateToken(token string) (string, bool) {
 	return userID, true
 }
 
-// Middleware authenticates web requests via session cookie. The TUI / agent
-// CLI does NOT use this — it goes through BearerMiddleware on /api/agents/*.
+// Middleware authenticates web requests via session cookie, or API clients
+// via a personal access token ("Authorization: Bearer pat_..."). The TUI /
+// agent CLI does NOT use this — it goes through BearerMiddleware on
+// /api/agents/*.
 func (a *Auth) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := bearerPAT(r); ok {
+			if ctx, ok := a.patUser(w, r, token); ok {
+				next.ServeHTTP(w, r.WithContext(ctx))
+			}
+			return
+		}
+		// With a trusted auth proxy in front, its identity header wins
+		// over any session cookie, except an impersonation session of
+		// that identity.
+		if a.trusted != nil {
+			if userID, ok := a.trustedUser(w, r); ok {
+				impersonator := ""
+				if token := SessionToken(r); token != "" {
+					if uid, admin, ok := a.Impersonation(token); ok && admin == userID {
+						userID, impersonator = uid, admin
+						a.touchSession(r, token)
+					}
+				}
+				next.ServeHTTP(w, r.WithContext(withSession(r.Context(), userID, impersonator)))
+				return
+			}
+		}
 		cookie, err := r.Cookie(cookieName)
 		if err != nil {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		userID, ok := a.ValidateToken(cookie.Value)
+		userID, impersonator, ok := a.session(cookie.Value)
 		if !ok {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		ctx := context.WithValue(r.Context(), userIDKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		a.touchSession(r, cookie.Value)
+		next.ServeHTTP(w, r.WithContext(withSession(r.Context(), userID, impersonator)))
 	})
 }
 
+// touchSession records the client a login token is used from, for the
+// session list.
+func (a *Auth) touchSession(r *http.Request, token string) {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLen {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentLen], "")
+	}
+	if err := a.db.TouchToken(token, clientmeta.ClientIP(r), ua); err != nil {
+		log.Printf("auth: %v", err)
+	}
+}
+
 // BearerMiddleware authenticates TUI / agent CLI requests via OAuth Bearer
 // token, using Hydra introspection. The web app does NOT use this — it goes
 // through Middleware (cookie auth). Token must be Active and have a non-empty
-// Subject (= user ID), which is then injected into request context under the
-// same key Middleware uses.
-func BearerMiddleware(h *HydraClient) func(http.Handler) http.Handler {
+// Subject (= user ID) of a user who is not disabled, which is then injected
+// into request context under the same key Middleware uses.
+func (a *Auth) BearerMiddleware(h *HydraClient) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authz := r.Header.Get("Authorization")
@@ -129,14 +243,32 @@ func BearerMiddleware(h *HydraClient) func(http.Handler) http.Handler {
 				http.Error(w, "unauthorized", http.StatusUnauthorized)
 				return
 			}
+			// Hydra tokens outlive a disable; sessions and PATs are
+			// rejected in their queries, these here.
+			user, err := a.db.GetUserByID(intro.Subject)
+			if err != nil {
+				log.Printf("auth: bearer user lookup %s: %v", intro.Subject, err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if user == nil || user.DisabledAt != nil {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
 			ctx := context.WithValue(r.Context(), userIDKey, intro.Subject)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-// ValidateRequest checks whether a request has a valid auth cookie and returns the user ID.
+// ValidateRequest checks whether a request has a valid auth cookie (or a
+// trusted proxy identity header) and returns the user ID.
 func (a *Auth) ValidateRequest(r *http.Request) (string, bool) {
+	if a.trusted != nil {
+		if userID, ok := a.trustedUser(nil, r); ok {
+			return userID, true
+		}
+	}
 	cookie, err := r.Cookie(cookieName)
 	if err != nil {
 		return "", false
@@ -173,14 +305,23 @@ func (a *Auth) DB() *db.DB {
 }
 
 func SetTokenCookie(w http.ResponseWriter, token string) {
+	setTokenCookie(w, token, tokenTTL)
+}
+
+// ClearTokenCookie removes the session cookie.
+func ClearTokenCookie(w http.ResponseWriter) {
+	setTokenCookie(w, "", -time.Second)
+}
+
+func setTokenCookie(w http.ResponseWriter, token string, ttl time.Duration) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     cookieName,
 		Value:    token,
 		Path:     "/",
 		Domain:   cookieDomain(),
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(tokenTTL.Seconds()),
+		MaxAge:   int(ttl.Seconds()),
 	})
 }
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestBearerMiddlewareRejectsDisabledUsers(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	d, err := db.Open(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	userID := uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	// The fake Hydra reports every token active, for the subject it names.
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		json.NewEncoder(w).Encode(IntrospectionResult{Active: r.PostForm.Get("token") != "expired", Subject: r.PostForm.Get("token")})
+	}))
+	defer hydra.Close()
+
+	var got string
+	h := New(d).BearerMiddleware(NewHydraClient(hydra.URL, hydra.URL))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = UserIDFromContext(r.Context())
+	}))
+	do := func(authz string) int {
+		got = ""
+		req := httptest.NewRequest(http.MethodGet, "/api/agents/sessions", nil)
+		if authz != "" {
+			req.Header.Set("Authorization", authz)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do("Bearer " + userID); code != http.StatusOK || got != userID {
+		t.Fatalf("enabled user: %d, user %q", code, got)
+	}
+	if _, err := d.SetUserDisabled(userID, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, authz := range []string{"Bearer " + userID, "Bearer " + uuid.NewString(), "Bearer expired", ""} {
+		if code := do(authz); code != http.StatusUnauthorized || got != "" {
+			t.Errorf("%q: %d, user %q; want 401", authz, code, got)
+		}
+	}
+	if _, err := d.SetUserDisabled(userID, false); err != nil {
+		t.Fatal(err)
+	}
+	if code := do("Bearer " + userID); code != http.StatusOK {
+		t.Errorf("re-enabled user: %d", code)
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// AdminBootstrap describes the administrators that must exist on startup.
+// When any is configured, the legacy "first registered user becomes admin"
+// rule is disabled.
+type AdminBootstrap struct {
+	// Email and Password define a local admin account, created if missing.
+	Email    string
+	Password string
+	// OIDCEmails lists emails that are granted admin when a user with that
+	// email exists or first signs in through an OIDC provider.
+	OIDCEmails []string
+}
+
+// AdminBootstrapFromEnv reads ADMIN_EMAIL (or ADMIN_USERNAME), ADMIN_PASSWORD
+// and ADMIN_OIDC_EMAILS (comma-separated).
+func AdminBootstrapFromEnv() AdminBootstrap {
+	b := AdminBootstrap{
+		Email:    strings.TrimSpace(os.Getenv("ADMIN_EMAIL")),
+		Password: os.Getenv("ADMIN_PASSWORD"),
+	}
+	if b.Email == "" {
+		b.Email = strings.TrimSpace(os.Getenv("ADMIN_USERNAME"))
+	}
+	for _, e := range strings.Split(os.Getenv("ADMIN_OIDC_EMAILS"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			b.OIDCEmails = append(b.OIDCEmails, e)
+		}
+	}
+	return b
+}
+
+// Enabled reports whether any bootstrap admin is configured.
+func (b AdminBootstrap) Enabled() bool {
+	return b.Email != "" || len(b.OIDCEmails) > 0
+}
+
+func (b AdminBootstrap) isAdminEmail(email string) bool {
+	if email == "" {
+		return false
+	}
+	if strings.EqualFold(email, b.Email) {
+		return true
+	}
+	for _, e := range b.OIDCEmails {
+		if strings.EqualFold(email, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// BootstrapAdmin records the bootstrap configuration and makes sure the
+// configured admins exist. It is idempotent and safe to run on every start
+// and from several replicas; an existing local admin's password is never
+// overwritten. It returns the ID of the local admin if it was just created.
+func (a *Auth) BootstrapAdmin(b AdminBootstrap) (createdID string, err error) {
+	a.admins = b
+	if !b.Enabled() {
+		return "", nil
+	}
+
+	if b.Email != "" {
+		if b.Password == "" {
+			return "", fmt.Errorf("ADMIN_PASSWORD is required when ADMIN_EMAIL is set")
+		}
+		user, err := a.db.GetUserByEmail(b.Email)
+		if err != nil {
+			return "", fmt.Errorf("lookup admin user: %w", err)
+		}
+		if user == nil {
+			id := uuid.New().String()
+			if err := a.Register(id, b.Email, b.Password); err != nil {
+				// Another replica may have created it concurrently.
+				if user, _ = a.db.GetUserByEmail(b.Email); user == nil {
+					return "", fmt.Errorf("create admin user: %w", err)
+				}
+			} else {
+				createdID = id
+				log.Printf("auth: created bootstrap admin %s", b.Email)
+				user, err = a.db.GetUserByID(id)
+				if err != nil || user == nil {
+					return "", fmt.Errorf("lookup admin user: %w", err)
+				}
+			}
+		}
+		if err := a.ensureAdmin(user.ID, user.Role, user.Email); err != nil {
+			return "", err
+		}
+	}
+
+	for _, email := range b.OIDCEmails {
+		user, err := a.db.GetUserByEmail(email)
+		if err != nil {
+			return "", fmt.Errorf("lookup admin user: %w", err)
+		}
+		if user == nil {
+			continue // promoted on first sign-in
+		}
+		if err := a.ensureAdmin(user.ID, user.Role, user.Email); err != nil {
+			return "", err
+		}
+	}
+	return createdID, nil
+}
+
+func (a *Auth) ensureAdmin(userID, role, email string) error {
+	if role == "admin" {
+		return nil
+	}
+	if err := a.db.UpdateUserRole(userID, "admin"); err != nil {
+		return fmt.Errorf("promote bootstrap admin: %w", err)
+	}
+	log.Printf("auth: granted admin to bootstrap user %s", email)
+	return nil
+}
+
+// AssignNewUserRole grants admin to a newly created user when appropriate:
+// if bootstrap admins are configured, only matching emails are promoted;
+// otherwise the first user in the system becomes admin.
+func (a *Auth) AssignNewUserRole(userID, email string) {
+	promote := false
+	if a.admins.Enabled() {
+		promote = a.admins.isAdminEmail(email)
+	} else if count, err := a.db.CountUsers(); err == nil && count == 1 {
+		promote = true
+	}
+	if !promote {
+		return
+	}
+	if err := a.db.UpdateUserRole(userID, "admin"); err != nil {
+		log.Printf("auth: failed to grant admin to %s: %v", email, err)
+	}
+}
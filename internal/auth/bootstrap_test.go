@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAdminBootstrapFromEnv(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "")
+	t.Setenv("ADMIN_USERNAME", " root@example.com ")
+	t.Setenv("ADMIN_PASSWORD", "s3cret")
+	t.Setenv("ADMIN_OIDC_EMAILS", "alice@example.com, ,bob@example.com")
+
+	b := AdminBootstrapFromEnv()
+	if b.Email != "root@example.com" || b.Password != "s3cret" {
+		t.Errorf("local admin = %q/%q", b.Email, b.Password)
+	}
+	if want := []string{"alice@example.com", "bob@example.com"}; !reflect.DeepEqual(b.OIDCEmails, want) {
+		t.Errorf("OIDCEmails = %v, want %v", b.OIDCEmails, want)
+	}
+	if !b.Enabled() {
+		t.Error("expected bootstrap to be enabled")
+	}
+}
+
+func TestAdminBootstrap_IsAdminEmail(t *testing.T) {
+	b := AdminBootstrap{Email: "root@example.com", OIDCEmails: []string{"Alice@Example.com"}}
+	for email, want := range map[string]bool{
+		"root@example.com":  true,
+		"alice@example.com": true,
+		"eve@example.com":   false,
+		"":                  false,
+	} {
+		if got := b.isAdminEmail(email); got != want {
+			t.Errorf("isAdminEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+	if (AdminBootstrap{}).Enabled() {
+		t.Error("empty bootstrap should be disabled")
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// Admins can impersonate a user for support: they get a short-lived login
+// token for the user, marked with the admin's ID. Requests made with it act
+// as the user, and ImpersonatorFromContext returns the admin.
+
+// Impersonation token lifetimes.
+const (
+	DefaultImpersonationTTL = 30 * time.Minute
+	MaxImpersonationTTL     = 2 * time.Hour
+)
+
+const impersonatorKey contextKey = "impersonator"
+
+// IssueImpersonationToken issues a login token for userID to the admin
+// adminID, valid for ttl. It doesn't count towards the user's session
+// limit.
+func (a *Auth) IssueImpersonationToken(adminID, userID string, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+	expiresAt := time.Now().Add(ttl)
+	if err := a.db.CreateImpersonationToken(token, userID, adminID, expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// session returns the user of a login token and the admin impersonating
+// them, if any.
+func (a *Auth) session(token string) (userID, impersonator string, ok bool) {
+	userID, impersonator, err := a.db.ValidateSessionToken(token)
+	if err != nil || userID == "" {
+		return "", "", false
+	}
+	return userID, impersonator, true
+}
+
+// Impersonation returns the user and admin of an impersonation token, and
+// false for other tokens.
+func (a *Auth) Impersonation(token string) (userID, adminID string, ok bool) {
+	userID, adminID, ok = a.session(token)
+	return userID, adminID, ok && adminID != ""
+}
+
+// EndImpersonation deletes an impersonation token.
+func (a *Auth) EndImpersonation(token string) error {
+	return a.db.DeleteToken(token)
+}
+
+// SessionToken returns the login token of a request's session cookie.
+func SessionToken(r *http.Request) string {
+	c, err := r.Cookie(cookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// withSession returns ctx acting as userID, impersonated by impersonator
+// unless it is empty.
+func withSession(ctx context.Context, userID, impersonator string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey, userID)
+	if impersonator != "" {
+		ctx = context.WithValue(ctx, impersonatorKey, impersonator)
+	}
+	return ctx
+}
+
+// ImpersonatorFromContext returns the ID of the admin impersonating the
+// request's user, or "".
+func ImpersonatorFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(impersonatorKey).(string)
+	return v
+}
+
+// SetImpersonationCookie sets an impersonation token as the session cookie
+// until it expires.
+func SetImpersonationCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	setTokenCookie(w, token, time.Until(expiresAt))
+}
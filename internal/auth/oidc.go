@@ -29,9 +29,15 @@ type Provider interface {
 	GetIdentity(ctx context.Context, token *oauth2.Token) (subject, email, displayName, login, avatarURL string, err error)
 }
 
+// Labeler is implemented by providers with a configured login button text.
+type Labeler interface {
+	Label() string
+}
+
 // OIDCManager orchestrates multiple OIDC/OAuth2 providers.
 type OIDCManager struct {
 	providers      map[string]Provider
+	order          []string            // provider names in registration order
 	allowedDomains map[string][]string // provider name → allowed base domains (empty = all)
 	baseURL        string
 	auth           *Auth
@@ -50,26 +56,46 @@ func NewOIDCManager(baseURL string, authSvc *Auth) *OIDCManager {
 
 // RegisterProvider adds a provider available on all domains.
 func (m *OIDCManager) RegisterProvider(p Provider) {
-	m.providers[p.Name()] = p
+	m.RegisterProviderWithDomains(p, nil)
 }
 
 // RegisterProviderWithDomains adds a provider restricted to specific base domains.
 // If domains is empty, the provider is available on all domains.
 func (m *OIDCManager) RegisterProviderWithDomains(p Provider, domains []string) {
+	if _, ok := m.providers[p.Name()]; !ok {
+		m.order = append(m.order, p.Name())
+	}
 	m.providers[p.Name()] = p
+	delete(m.allowedDomains, p.Name())
 	if len(domains) > 0 {
 		m.allowedDomains[p.Name()] = domains
 	}
 }
 
-// ProviderNamesForHost returns provider names available for the given request host.
+// HasProvider reports whether a provider of that name is registered.
+func (m *OIDCManager) HasProvider(name string) bool {
+	_, ok := m.providers[name]
+	return ok
+}
+
+// ProviderLabel returns the configured login button text of a provider, or
+// "" to let the UI pick one.
+func (m *OIDCManager) ProviderLabel(name string) string {
+	if l, ok := m.providers[name].(Labeler); ok {
+		return l.Label()
+	}
+	return ""
+}
+
+// ProviderNamesForHost returns provider names available for the given
+// request host, in registration order.
 func (m *OIDCManager) ProviderNamesForHost(host string) []string {
 	// Strip port if present.
 	if idx := strings.LastIndex(host, ":"); idx != -1 {
 		host = host[:idx]
 	}
 	names := make([]string, 0, len(m.providers))
-	for n := range m.providers {
+	for _, n := range m.order {
 		domains := m.allowedDomains[n]
 		if len(domains) == 0 {
 			// No restriction — available on all domains.
@@ -153,7 +179,7 @@ func (m *OIDCManager) HandleLogin(w http.ResponseWriter, r *http.Request, provid
 		Value:    state,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   int(stateCookieTTL.Seconds()),
 	})
@@ -164,7 +190,7 @@ func (m *OIDCManager) HandleLogin(w http.ResponseWriter, r *http.Request, provid
 			Value:    next,
 			Path:     "/",
 			HttpOnly: true,
-			Secure:   true,
+			Secure:   SecureCookies(),
 			SameSite: http.SameSiteLaxMode,
 			MaxAge:   int(stateCookieTTL.Seconds()),
 		})
@@ -199,7 +225,7 @@ func (m *OIDCManager) HandleCallback(w http.ResponseWriter, r *http.Request, pro
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   SecureCookies(),
 	})
 
 	// Check for error from IdP.
@@ -244,6 +270,11 @@ func (m *OIDCManager) HandleCallback(w http.ResponseWriter, r *http.Request, pro
 	if isNew && m.OnUserCreated != nil {
 		m.OnUserCreated(userID)
 	}
+	if user, err := m.auth.GetUserByID(userID); err == nil && user != nil && user.DisabledAt != nil {
+		log.Printf("OIDC login of disabled user %s refused", userID)
+		http.Error(w, "account disabled", http.StatusForbidden)
+		return
+	}
 
 	// Issue session token.
 	authToken, err := m.auth.IssueToken(userID)
@@ -266,7 +297,7 @@ func (m *OIDCManager) HandleCallback(w http.ResponseWriter, r *http.Request, pro
 			Path:     "/",
 			MaxAge:   -1,
 			HttpOnly: true,
-			Secure:   true,
+			Secure:   SecureCookies(),
 		})
 	}
 	http.Redirect(w, r, dest, http.StatusFound)
@@ -327,9 +358,7 @@ func (m *OIDCManager) resolveUser(provider, subject, email, displayName, _, avat
 	if err := database.CreateUserWithEmail(userID, nil, email); err != nil {
 		return "", false, fmt.Errorf("create user: %w", err)
 	}
-	if count, err := database.CountUsers(); err == nil && count == 1 {
-		_ = database.UpdateUserRole(userID, "admin")
-	}
+	m.auth.AssignNewUserRole(userID, email)
 	if avatarURL != "" {
 		_ = database.UpdateUserPicture(userID, avatarURL)
 	}
@@ -451,6 +480,7 @@ func (g *GitHubProvider) fetchPrimaryEmail(ctx context.Context, client *http.Cli
 
 type GenericOIDCProvider struct {
 	name         string
+	label        string
 	clientID     string
 	clientSecret string
 	redirectURL  string
@@ -458,14 +488,18 @@ type GenericOIDCProvider struct {
 	verifier     *gooidc.IDTokenVerifier
 }
 
-func NewGenericOIDCProvider(ctx context.Context, issuerURL, clientID, clientSecret, redirectURL string) (*GenericOIDCProvider, error) {
+// NewGenericOIDCProvider discovers the issuer's endpoints. name is the
+// provider's route segment, "oidc" for the one configured with
+// OIDC_ISSUER_URL; label is its login button text, "" for the default.
+func NewGenericOIDCProvider(ctx context.Context, name, label, issuerURL, clientID, clientSecret, redirectURL string) (*GenericOIDCProvider, error) {
 	provider, err := gooidc.NewProvider(ctx, issuerURL)
 	if err != nil {
 		return nil, fmt.Errorf("oidc discovery for %s: %w", issuerURL, err)
 	}
 	verifier := provider.Verifier(&gooidc.Config{ClientID: clientID})
 	return &GenericOIDCProvider{
-		name:         "oidc",
+		name:         name,
+		label:        label,
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		redirectURL:  redirectURL,
@@ -476,6 +510,8 @@ func NewGenericOIDCProvider(ctx context.Context, issuerURL, clientID, clientSecr
 
 func (g *GenericOIDCProvider) Name() string { return g.name }
 
+func (g *GenericOIDCProvider) Label() string { return g.label }
+
 func (g *GenericOIDCProvider) OAuth2Config() *oauth2.Config {
 	return &oauth2.Config{
 		ClientID:     g.clientID,
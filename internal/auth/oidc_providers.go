@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// OIDCProviderConfig is a generic OIDC identity provider of the file named
+// by OIDC_PROVIDERS_FILE. Each one gets its own login and callback routes,
+// /api/auth/oidc/{name}/login and /api/auth/oidc/{name}/callback.
+type OIDCProviderConfig struct {
+	Name         string `json:"name"`  // in routes and linked identities; don't rename
+	Label        string `json:"label"` // login button text, e.g. "Sign in with Okta"
+	IssuerURL    string `json:"issuerUrl"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+	// ClientSecretEnv names an environment variable holding the client
+	// secret, to keep it out of the file.
+	ClientSecretEnv string `json:"clientSecretEnv"`
+	// RedirectBaseURL overrides OIDC_REDIRECT_BASE_URL for the callback.
+	RedirectBaseURL string   `json:"redirectBaseUrl"`
+	AllowedDomains  []string `json:"allowedDomains"` // empty = all domains
+}
+
+type oidcProvidersFile struct {
+	Providers []OIDCProviderConfig `json:"providers"`
+}
+
+var oidcProviderNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// LoadOIDCProviders reads a YAML or JSON file of generic OIDC providers:
+//
+//	providers:
+//	  - name: okta
+//	    label: Sign in with Okta
+//	    issuerUrl: https://example.okta.com
+//	    clientId: agentserver
+//	    clientSecretEnv: OKTA_CLIENT_SECRET
+func LoadOIDCProviders(path string) ([]OIDCProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseOIDCProviders(data)
+}
+
+func parseOIDCProviders(data []byte) ([]OIDCProviderConfig, error) {
+	var f oidcProvidersFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i := range f.Providers {
+		p := &f.Providers[i]
+		if !oidcProviderNameRe.MatchString(p.Name) {
+			return nil, fmt.Errorf("providers[%d]: name %q must be lowercase letters, digits and dashes", i, p.Name)
+		}
+		if p.Name == "github" {
+			return nil, fmt.Errorf("providers[%d]: name %q is reserved", i, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("providers[%d]: duplicate name %q", i, p.Name)
+		}
+		seen[p.Name] = true
+		if p.ClientSecretEnv != "" {
+			if p.ClientSecret != "" {
+				return nil, fmt.Errorf("provider %s: set clientSecret or clientSecretEnv, not both", p.Name)
+			}
+			p.ClientSecret = os.Getenv(p.ClientSecretEnv)
+		}
+		if p.IssuerURL == "" || p.ClientID == "" || p.ClientSecret == "" {
+			return nil, fmt.Errorf("provider %s: issuerUrl, clientId and a client secret are required", p.Name)
+		}
+		p.RedirectBaseURL = strings.TrimRight(p.RedirectBaseURL, "/")
+	}
+	return f.Providers, nil
+}
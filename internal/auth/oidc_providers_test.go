@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestParseOIDCProviders(t *testing.T) {
+	t.Setenv("OKTA_SECRET", "s3cret")
+	got, err := parseOIDCProviders([]byte(`
+providers:
+  - name: okta
+    label: Sign in with Okta
+    issuerUrl: https://example.okta.com
+    clientId: agentserver
+    clientSecretEnv: OKTA_SECRET
+    redirectBaseUrl: https://app.example.com/
+    allowedDomains: [example.com]
+  - name: keycloak
+    issuerUrl: https://sso.example.org/realms/main
+    clientId: agentserver
+    clientSecret: kc
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OIDCProviderConfig{
+		{Name: "okta", Label: "Sign in with Okta", IssuerURL: "https://example.okta.com", ClientID: "agentserver",
+			ClientSecret: "s3cret", ClientSecretEnv: "OKTA_SECRET", RedirectBaseURL: "https://app.example.com", AllowedDomains: []string{"example.com"}},
+		{Name: "keycloak", IssuerURL: "https://sso.example.org/realms/main", ClientID: "agentserver", ClientSecret: "kc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOIDCProviders =\n%+v\nwant\n%+v", got, want)
+	}
+
+	for _, tc := range []struct{ yaml, err string }{
+		{`providers: [{name: Okta, issuerUrl: i, clientId: c, clientSecret: s}]`, "lowercase"},
+		{`providers: [{name: github, issuerUrl: i, clientId: c, clientSecret: s}]`, "reserved"},
+		{`providers: [{name: a, issuerUrl: i, clientId: c, clientSecret: s}, {name: a, issuerUrl: i, clientId: c, clientSecret: s}]`, "duplicate"},
+		{`providers: [{name: a, issuerUrl: i, clientId: c}]`, "required"},
+		{`providers: [{name: a, issuerUrl: i, clientId: c, clientSecretEnv: UNSET_SECRET_ENV}]`, "required"},
+		{`providers: [{name: a, issuerUrl: i, clientId: c, clientSecret: s, clientSecretEnv: OKTA_SECRET}]`, "not both"},
+		{`providers: [{name: a, issuer: i}]`, "unknown field"},
+	} {
+		if _, err := parseOIDCProviders([]byte(tc.yaml)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("parseOIDCProviders(%s) error = %v, want %q", tc.yaml, err, tc.err)
+		}
+	}
+}
+
+type fakeProvider struct{ name, label string }
+
+func (p fakeProvider) Name() string                 { return p.name }
+func (p fakeProvider) Label() string                { return p.label }
+func (p fakeProvider) OAuth2Config() *oauth2.Config { return &oauth2.Config{} }
+func (p fakeProvider) GetIdentity(context.Context, *oauth2.Token) (string, string, string, string, string, error) {
+	return "", "", "", "", "", nil
+}
+
+func TestOIDCManagerProviders(t *testing.T) {
+	m := NewOIDCManager("https://app.example.com", nil)
+	m.RegisterProvider(fakeProvider{name: "oidc"})
+	m.RegisterProviderWithDomains(fakeProvider{name: "okta", label: "Okta"}, []string{"example.com"})
+	m.RegisterProvider(fakeProvider{name: "azure", label: "Microsoft"})
+
+	if got := m.ProviderNamesForHost("app.example.com:443"); !reflect.DeepEqual(got, []string{"oidc", "okta", "azure"}) {
+		t.Errorf("ProviderNamesForHost(example.com) = %v", got)
+	}
+	if got := m.ProviderNamesForHost("example.org"); !reflect.DeepEqual(got, []string{"oidc", "azure"}) {
+		t.Errorf("ProviderNamesForHost(example.org) = %v", got)
+	}
+	if m.ProviderLabel("okta") != "Okta" || m.ProviderLabel("oidc") != "" || m.ProviderLabel("missing") != "" {
+		t.Errorf("labels = %q, %q", m.ProviderLabel("okta"), m.ProviderLabel("oidc"))
+	}
+	if !m.HasProvider("azure") || m.HasProvider("github") {
+		t.Error("HasProvider")
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/clientmeta"
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+// PATPrefix starts every personal access token, which tells them apart
+// from the other bearer tokens (Hydra, codex) the server sees.
+const PATPrefix = "pat_"
+
+// patDisplayLen is how much of a token is kept in clear as its prefix.
+const patDisplayLen = len(PATPrefix) + 8
+
+const patKey contextKey = "pat"
+
+// GeneratePAT returns a new personal access token and the prefix stored
+// to identify it.
+func GeneratePAT() (token, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = PATPrefix + hex.EncodeToString(b)
+	return token, token[:patDisplayLen], nil
+}
+
+// HashPAT returns the hex SHA-256 under which a personal access token is
+// stored. Tokens are random, so an unsalted fast hash suffices.
+func HashPAT(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerPAT returns the personal access token of a request, if its
+// Authorization header carries one.
+func bearerPAT(r *http.Request) (string, bool) {
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, "Bearer "+PATPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(authz, "Bearer ")), true
+}
+
+// patAllows reports whether a token's scopes cover a request: read covers
+// GET, HEAD and OPTIONS, write everything. WebSocket upgrades (the web
+// terminal) are GETs but need write.
+func patAllows(t *db.PersonalAccessToken, r *http.Request) bool {
+	if t.HasScope(db.TokenScopeWrite) {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.HasScope(db.TokenScopeRead) && r.Header.Get("Upgrade") == ""
+	}
+	return false
+}
+
+// patUser authenticates a request by its personal access token. It writes
+// the error response and returns false if the token is invalid or lacks
+// the scope for the request.
+func (a *Auth) patUser(w http.ResponseWriter, r *http.Request, token string) (context.Context, bool) {
+	t, err := a.db.ValidatePersonalAccessToken(HashPAT(token))
+	if err != nil {
+		log.Printf("auth: failed to validate personal access token: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if t == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if !patAllows(t, r) {
+		http.Error(w, "token lacks the write scope", http.StatusForbidden)
+		return nil, false
+	}
+	if err := a.db.TouchPersonalAccessToken(t.ID, clientmeta.ClientIP(r)); err != nil {
+		log.Printf("auth: %v", err)
+	}
+	ctx := context.WithValue(r.Context(), userIDKey, t.UserID)
+	return context.WithValue(ctx, patKey, t), true
+}
+
+// PATFromContext returns the personal access token a request was
+// authenticated with, or nil for a login session.
+func PATFromContext(ctx context.Context) *db.PersonalAccessToken {
+	t, _ := ctx.Value(patKey).(*db.PersonalAccessToken)
+	return t
+}
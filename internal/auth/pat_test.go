@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestGeneratePAT(t *testing.T) {
+	token, prefix, err := GeneratePAT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, PATPrefix) || len(token) != len(PATPrefix)+64 || !strings.HasPrefix(token, prefix) || len(prefix) != patDisplayLen {
+		t.Errorf("token %q, prefix %q", token, prefix)
+	}
+	other, _, _ := GeneratePAT()
+	if other == token || HashPAT(other) == HashPAT(token) || len(HashPAT(token)) != 64 {
+		t.Error("tokens or hashes collide")
+	}
+
+	r := httptest.NewRequest("GET", "/api/workspaces", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if got, ok := bearerPAT(r); !ok || got != token {
+		t.Errorf("bearerPAT = %q, %v", got, ok)
+	}
+	r.Header.Set("Authorization", "Bearer ory_at_hydra")
+	if _, ok := bearerPAT(r); ok {
+		t.Error("a Hydra token was taken for a personal access token")
+	}
+}
+
+func TestPATAllows(t *testing.T) {
+	read := &db.PersonalAccessToken{Scopes: []string{db.TokenScopeRead}}
+	write := &db.PersonalAccessToken{Scopes: []string{db.TokenScopeWrite}}
+	admin := &db.PersonalAccessToken{Scopes: []string{db.TokenScopeAdmin}}
+
+	get := httptest.NewRequest("GET", "/api/workspaces", nil)
+	post := httptest.NewRequest("POST", "/api/workspaces", nil)
+	terminal := httptest.NewRequest("GET", "/api/sandboxes/s1/terminal", nil)
+	terminal.Header.Set("Upgrade", "websocket")
+
+	tests := []struct {
+		token *db.PersonalAccessToken
+		name  string
+		want  [3]bool // get, post, terminal
+	}{
+		{read, "read", [3]bool{true, false, false}},
+		{write, "write", [3]bool{true, true, true}},
+		{admin, "admin", [3]bool{false, false, false}},
+	}
+	for _, tt := range tests {
+		got := [3]bool{patAllows(tt.token, get), patAllows(tt.token, post), patAllows(tt.token, terminal)}
+		if got != tt.want {
+			t.Errorf("%s: get, post, terminal = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
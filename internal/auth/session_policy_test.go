@@ -0,0 +1,20 @@
+package auth
+
+import "testing"
+
+func TestSessionPolicyLimit(t *testing.T) {
+	cases := []struct {
+		p    SessionPolicy
+		want int
+	}{
+		{SessionPolicy{}, 0},
+		{SessionPolicy{MaxActiveTokens: 5}, 5},
+		{SessionPolicy{SingleSession: true}, 1},
+		{SessionPolicy{MaxActiveTokens: 5, SingleSession: true}, 1},
+	}
+	for _, c := range cases {
+		if got := c.p.Limit(); got != c.want {
+			t.Errorf("%+v.Limit() = %d, want %d", c.p, got, c.want)
+		}
+	}
+}
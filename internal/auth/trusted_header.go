@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// TrustedHeader delegates authentication to a fronting auth proxy
+// (oauth2-proxy, Pomerium, ...) that sets the signed-in user's email in a
+// request header. Users are created on first sight. The header is only
+// honored on connections from Proxies, since anyone else could set it.
+type TrustedHeader struct {
+	EmailHeader string         // e.g. X-Auth-Request-Email
+	NameHeader  string         // optional display name, e.g. X-Auth-Request-User
+	Proxies     []netip.Prefix // addresses the auth proxy connects from
+	// OnUserCreated is called when a brand-new user is created.
+	OnUserCreated func(userID string)
+}
+
+// TrustedHeaderFromEnv reads AUTH_TRUSTED_HEADER, AUTH_TRUSTED_NAME_HEADER
+// and AUTH_TRUSTED_PROXIES (comma-separated IPs or CIDRs). It returns nil
+// when AUTH_TRUSTED_HEADER is unset.
+func TrustedHeaderFromEnv() (*TrustedHeader, error) {
+	header := strings.TrimSpace(os.Getenv("AUTH_TRUSTED_HEADER"))
+	if header == "" {
+		return nil, nil
+	}
+	t := &TrustedHeader{
+		EmailHeader: header,
+		NameHeader:  strings.TrimSpace(os.Getenv("AUTH_TRUSTED_NAME_HEADER")),
+	}
+	for _, s := range strings.Split(os.Getenv("AUTH_TRUSTED_PROXIES"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			addr, aerr := netip.ParseAddr(s)
+			if aerr != nil {
+				return nil, fmt.Errorf("AUTH_TRUSTED_PROXIES: invalid address %q", s)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		t.Proxies = append(t.Proxies, p.Masked())
+	}
+	if len(t.Proxies) == 0 {
+		return nil, fmt.Errorf("AUTH_TRUSTED_PROXIES is required when AUTH_TRUSTED_HEADER is set")
+	}
+	return t, nil
+}
+
+// fromProxy reports whether r arrived directly from a trusted proxy.
+func (t *TrustedHeader) fromProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range t.Proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// identity returns the email (and name) asserted by the proxy, or ok=false
+// if the request did not come from the proxy or carries no identity.
+func (t *TrustedHeader) identity(r *http.Request) (email, name string, ok bool) {
+	if t == nil || !t.fromProxy(r) {
+		return "", "", false
+	}
+	email = strings.TrimSpace(r.Header.Get(t.EmailHeader))
+	if email == "" || !strings.Contains(email, "@") {
+		return "", "", false
+	}
+	if t.NameHeader != "" {
+		name = strings.TrimSpace(r.Header.Get(t.NameHeader))
+	}
+	return email, name, true
+}
+
+// SetTrustedHeader enables trusted header authentication.
+func (a *Auth) SetTrustedHeader(t *TrustedHeader) {
+	a.trusted = t
+}
+
+// TrustedHeaderEnabled reports whether trusted header authentication is on.
+func (a *Auth) TrustedHeaderEnabled() bool {
+	return a.trusted != nil
+}
+
+// trustedUser authenticates r by the proxy's identity header, creating the
+// user if needed. Disabled users are rejected. When w is non-nil and the
+// request has no session for that user, a session cookie is issued too,
+// since sandbox subdomains authenticate with the session token.
+func (a *Auth) trustedUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	email, name, ok := a.trusted.identity(r)
+	if !ok {
+		return "", false
+	}
+	user, err := a.db.GetUserByEmail(email)
+	if err != nil {
+		log.Printf("auth: trusted header lookup %s: %v", email, err)
+		return "", false
+	}
+	var userID string
+	if user != nil {
+		if user.DisabledAt != nil {
+			return "", false
+		}
+		userID = user.ID
+	} else {
+		userID = uuid.New().String()
+		if err := a.db.CreateUserWithEmail(userID, nil, email); err != nil {
+			// Another request may have created it concurrently.
+			if user, _ = a.db.GetUserByEmail(email); user == nil {
+				log.Printf("auth: trusted header create user %s: %v", email, err)
+				return "", false
+			}
+			userID = user.ID
+		} else {
+			log.Printf("auth: created user %s from trusted header", email)
+			a.AssignNewUserRole(userID, email)
+			if name != "" {
+				_ = a.db.UpdateUserName(userID, name)
+			}
+			if a.trusted.OnUserCreated != nil {
+				a.trusted.OnUserCreated(userID)
+			}
+		}
+	}
+
+	if w != nil {
+		if cookie, err := r.Cookie(cookieName); err != nil || !a.tokenBelongsTo(cookie.Value, userID) {
+			token, err := a.IssueToken(userID)
+			if err != nil {
+				log.Printf("auth: trusted header issue token for %s: %v", email, err)
+			} else {
+				SetTokenCookie(w, token)
+			}
+		}
+	}
+	return userID, true
+}
+
+// tokenBelongsTo reports whether token is a session of userID, or an
+// impersonation session userID started.
+func (a *Auth) tokenBelongsTo(token, userID string) bool {
+	id, impersonator, ok := a.session(token)
+	return ok && (id == userID || impersonator == userID)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedHeaderFromEnv(t *testing.T) {
+	t.Setenv("AUTH_TRUSTED_HEADER", "")
+	if th, err := TrustedHeaderFromEnv(); th != nil || err != nil {
+		t.Fatalf("unset: got %v, %v", th, err)
+	}
+
+	t.Setenv("AUTH_TRUSTED_HEADER", "X-Auth-Request-Email")
+	t.Setenv("AUTH_TRUSTED_PROXIES", "")
+	if _, err := TrustedHeaderFromEnv(); err == nil {
+		t.Error("expected error without AUTH_TRUSTED_PROXIES")
+	}
+	t.Setenv("AUTH_TRUSTED_PROXIES", "10.0.0.0/8, nonsense")
+	if _, err := TrustedHeaderFromEnv(); err == nil {
+		t.Error("expected error for an invalid proxy")
+	}
+
+	t.Setenv("AUTH_TRUSTED_PROXIES", "10.1.0.0/16, 192.168.1.5, fd00::/8")
+	th, err := TrustedHeaderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(th.Proxies) != 3 || th.Proxies[1].String() != "192.168.1.5/32" {
+		t.Errorf("proxies = %v", th.Proxies)
+	}
+}
+
+func TestTrustedHeaderIdentity(t *testing.T) {
+	t.Setenv("AUTH_TRUSTED_HEADER", "X-Auth-Request-Email")
+	t.Setenv("AUTH_TRUSTED_NAME_HEADER", "X-Auth-Request-User")
+	t.Setenv("AUTH_TRUSTED_PROXIES", "10.1.0.0/16,fd00::/8")
+	th, err := TrustedHeaderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remote, email string
+		want          bool
+	}{
+		{"10.1.2.3:5000", "alice@example.com", true},
+		{"[fd00::1]:5000", "alice@example.com", true},
+		{"[::ffff:10.1.2.3]:5000", "alice@example.com", true},
+		{"10.2.0.1:5000", "alice@example.com", false}, // not the proxy
+		{"10.1.2.3:5000", "", false},
+		{"10.1.2.3:5000", "alice", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/auth/me", nil)
+		r.RemoteAddr = tt.remote
+		if tt.email != "" {
+			r.Header.Set("X-Auth-Request-Email", tt.email)
+		}
+		r.Header.Set("X-Auth-Request-User", "Alice")
+		email, name, ok := th.identity(r)
+		if ok != tt.want {
+			t.Errorf("%s %q: ok = %v, want %v", tt.remote, tt.email, ok, tt.want)
+			continue
+		}
+		if ok && (email != tt.email || name != "Alice") {
+			t.Errorf("%s: identity = %q, %q", tt.remote, email, name)
+		}
+	}
+
+	var nilTH *TrustedHeader
+	if _, _, ok := nilTH.identity(httptest.NewRequest("GET", "/", nil)); ok {
+		t.Error("nil TrustedHeader authenticated a request")
+	}
+}
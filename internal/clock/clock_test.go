@@ -0,0 +1,99 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(epoch)
+	tk := f.NewTicker(time.Minute)
+
+	f.Advance(59 * time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	f.Advance(time.Second)
+	if got := <-tk.C(); !got.Equal(epoch.Add(time.Minute)) {
+		t.Errorf("tick at %v, want %v", got, epoch.Add(time.Minute))
+	}
+
+	// Ticks nobody reads are dropped rather than queued.
+	f.Advance(3 * time.Minute)
+	if got := <-tk.C(); !got.Equal(epoch.Add(2 * time.Minute)) {
+		t.Errorf("tick at %v, want %v", got, epoch.Add(2*time.Minute))
+	}
+	select {
+	case <-tk.C():
+		t.Fatal("dropped ticks were queued")
+	default:
+	}
+	if got := f.Now(); !got.Equal(epoch.Add(4 * time.Minute)) {
+		t.Errorf("Now() = %v after advancing 4m", got)
+	}
+
+	tk.Stop()
+	f.Advance(time.Hour)
+	select {
+	case <-tk.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(epoch)
+	fired := f.NewTimer(time.Second)
+	stopped := f.NewTimer(time.Second)
+	if !stopped.Stop() {
+		t.Error("Stop() of an active timer = false")
+	}
+	f.Advance(time.Second)
+	<-fired.C()
+	if fired.Stop() {
+		t.Error("Stop() of a fired timer = true")
+	}
+	select {
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-f.NewTimer(time.Minute).C()
+	}()
+	f.BlockUntil(1)
+	f.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting goroutine not woken")
+	}
+}
+
+func TestIn(t *testing.T) {
+	f := NewFake(epoch)
+	got, err := In(f, "Asia/Tokyo")
+	if err != nil {
+		t.Skipf("tz database unavailable: %v", err)
+	}
+	if got.Hour() != 21 || !got.Equal(epoch) {
+		t.Errorf("In(Asia/Tokyo) = %v, want 21:00 JST", got)
+	}
+	if got, _ := In(f, ""); got.Location() != time.UTC {
+		t.Errorf("In(\"\") location = %v, want UTC", got.Location())
+	}
+	if _, err := In(f, "Mars/Olympus"); err == nil {
+		t.Error("In(unknown zone) succeeded")
+	}
+}
@@ -34,6 +34,11 @@ type ServeConfig struct {
 	CapTokenTTL               time.Duration
 	LogLevel                  slog.Level
 
+	// StorageDir, when set, stores CODEX_HOME tarballs as files in this
+	// directory instead of S3, for air-gapped installs without object
+	// storage. The CXG_S3_* settings are then not required.
+	StorageDir string
+
 	// Model provider config — written verbatim into each per-thread
 	// config.toml. The codex subprocess reads ModelProviderEnvKey from its
 	// own env (forwarded from CodexAPIKey here) to authenticate to the
@@ -94,6 +99,7 @@ func LoadServeConfigFromEnv() (ServeConfig, error) {
 			SecretAccessKey: os.Getenv("CXG_S3_SECRET_ACCESS_KEY"),
 			PathStyle:       strings.EqualFold(os.Getenv("CXG_S3_PATH_STYLE"), "true"),
 		},
+		StorageDir:                os.Getenv("CXG_STORAGE_DIR"),
 		InboundHMACSecret:         []byte(os.Getenv("CXG_INBOUND_HMAC_SECRET")),
 		ExecGatewayWSURL:          os.Getenv("CXG_EXEC_GATEWAY_URL"),
 		ExecGatewayInternalURL:    os.Getenv("CXG_EXEC_GATEWAY_INTERNAL_URL"),
@@ -125,16 +131,18 @@ func LoadServeConfigFromEnv() (ServeConfig, error) {
 		}
 		cfg.OperationLogChan = n
 	}
-	if cfg.S3.Endpoint == "" {
-		return cfg, fmt.Errorf("CXG_S3_ENDPOINT is required")
-	}
-	if u, err := url.Parse(cfg.S3.Endpoint); err != nil {
-		return cfg, fmt.Errorf("CXG_S3_ENDPOINT not a valid URL: %w", err)
-	} else if u.Scheme != "http" && u.Scheme != "https" {
-		return cfg, fmt.Errorf("CXG_S3_ENDPOINT must use http:// or https:// scheme, got %q", cfg.S3.Endpoint)
-	}
-	if cfg.S3.Bucket == "" {
-		return cfg, fmt.Errorf("CXG_S3_BUCKET is required")
+	if cfg.StorageDir == "" {
+		if cfg.S3.Endpoint == "" {
+			return cfg, fmt.Errorf("CXG_S3_ENDPOINT is required (or CXG_STORAGE_DIR)")
+		}
+		if u, err := url.Parse(cfg.S3.Endpoint); err != nil {
+			return cfg, fmt.Errorf("CXG_S3_ENDPOINT not a valid URL: %w", err)
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			return cfg, fmt.Errorf("CXG_S3_ENDPOINT must use http:// or https:// scheme, got %q", cfg.S3.Endpoint)
+		}
+		if cfg.S3.Bucket == "" {
+			return cfg, fmt.Errorf("CXG_S3_BUCKET is required")
+		}
 	}
 	if cfg.ExecGatewayWSURL == "" {
 		return cfg, fmt.Errorf("CXG_EXEC_GATEWAY_URL is required")
@@ -105,3 +105,20 @@ func TestLoadServeConfig_OverridesIdleShutdown(t *testing.T) {
 		t.Errorf("IdleShutdown = %v", cfg.IdleShutdown)
 	}
 }
+
+func TestLoadServeConfig_StorageDirReplacesS3(t *testing.T) {
+	setRequired(t)
+	t.Setenv("CXG_S3_ENDPOINT", "")
+	t.Setenv("CXG_S3_BUCKET", "")
+	if _, err := LoadServeConfigFromEnv(); err == nil {
+		t.Fatal("want error without S3 or storage dir")
+	}
+	t.Setenv("CXG_STORAGE_DIR", "/var/lib/codex-app-gateway")
+	cfg, err := LoadServeConfigFromEnv()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.StorageDir != "/var/lib/codex-app-gateway" {
+		t.Errorf("StorageDir = %q", cfg.StorageDir)
+	}
+}
@@ -0,0 +1,95 @@
+package codexappgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/codexappgateway/codexhome"
+)
+
+// fsStore is the filesystem-backed ObjectStore for air-gapped installs
+// without S3-compatible storage. Keys map to files under dir; with more
+// than one gateway replica, dir must be a shared (ReadWriteMany) volume.
+type fsStore struct {
+	dir string
+}
+
+func newFSStore(dir string) (codexhome.ObjectStore, error) {
+	if dir == "" {
+		return nil, errors.New("fs: directory required")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("fs: %w", err)
+	}
+	return &fsStore{dir: dir}, nil
+}
+
+// path returns the file for key, rejecting keys that would escape dir.
+func (s *fsStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("fs: invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("fs: invalid key %q", key)
+		}
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes data to a temp file and renames it over the key's file, so a
+// concurrent Get never sees a partial object.
+func (s *fsStore) Put(ctx context.Context, key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *fsStore) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, codexhome.ErrObjectNotFound
+	}
+	return data, err
+}
+
+func (s *fsStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
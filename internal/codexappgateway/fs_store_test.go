@@ -0,0 +1,52 @@
+package codexappgateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/codexappgateway/codexhome"
+)
+
+func TestFSStore_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := newFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "codex-app-gateway/ws-1.tar.gz"
+	if _, err := store.Get(ctx, key); !errors.Is(err, codexhome.ErrObjectNotFound) {
+		t.Fatalf("Get missing key: err = %v, want ErrObjectNotFound", err)
+	}
+	if err := store.Put(ctx, key, []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, key, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, key)
+	if err != nil || string(got) != "v2" {
+		t.Fatalf("Get = %q, %v; want v2", got, err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete missing key: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, codexhome.ErrObjectNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestFSStore_RejectsEscapingKeys(t *testing.T) {
+	store, err := newFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b"} {
+		if err := store.Put(context.Background(), key, []byte("x")); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+	}
+}
@@ -63,7 +63,7 @@ func TestServer_RealCodexAppServer_FullRPCRoundtrip(t *testing.T) {
 		sup:     sup,
 		homeMgr: mgr,
 		logger:  logger,
-		buildConfig: func(_ context.Context, ws string) (supervisor.SpawnConfig, error) {
+		buildConfig: func(_ context.Context, ws, _ string) (supervisor.SpawnConfig, error) {
 			return supervisor.SpawnConfig{Config: codexhome.ConfigInput{
 				ModelProvider: "modelserver",
 				Model:         "gpt-5.5",
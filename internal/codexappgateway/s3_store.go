@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/agentserver/agentserver/internal/codexappgateway/codexhome"
@@ -13,6 +14,23 @@ import (
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// newObjectStore returns the filesystem store when cfg.StorageDir is set,
+// otherwise the S3 store.
+func newObjectStore(cfg ServeConfig) (codexhome.ObjectStore, error) {
+	if cfg.StorageDir != "" {
+		store, err := newFSStore(cfg.StorageDir)
+		if err != nil {
+			return nil, fmt.Errorf("fs store: %w", err)
+		}
+		return store, nil
+	}
+	store, err := newS3Store(cfg.S3)
+	if err != nil {
+		return nil, fmt.Errorf("s3 store: %w", err)
+	}
+	return store, nil
+}
+
 type s3Store struct {
 	client *s3.Client
 	bucket string
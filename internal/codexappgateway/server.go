@@ -74,9 +74,9 @@ const maxWSFrameBytes int64 = 64 << 20
 // each per-executor `[mcp_servers.exe_*]` entry (codex spawns it as the
 // env-mcp child).
 func NewServer(cfg ServeConfig, codexBin, selfBin string, logger *slog.Logger) (*Server, error) {
-	store, err := newS3Store(cfg.S3)
+	store, err := newObjectStore(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("s3 store: %w", err)
+		return nil, err
 	}
 	mgr := codexhome.NewManager(cfg.TmpRoot)
 	// Static fallback env: only used if the per-spawn ModelServer token
@@ -105,7 +105,7 @@ func (s *Server) handleDeviceToken(w http.ResponseWriter, r *http.Request) {
 }
 
 const verifyFormHTML = `<!doctype html>
-<html><head><meta charset="utf-8"><title>Codex device login</title>
+<html lang="en"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Codex device login</title>
 <style>
   body { font-family: -apple-system, sans-serif; max-width: 480px; margin: 4em auto; padding: 0 1em; }
   input[name=user_code] { font-family: monospace; font-size: 1.4em; letter-spacing: 0.1em;
@@ -116,15 +116,17 @@ const verifyFormHTML = `<!doctype html>
 <h1>Codex device login</h1>
 <p>Signed in as <code>%s</code>.</p>
 <form method="POST" action="/codex/device">
-  <p><label>Enter the code shown by <code>codex login --device-auth</code>:</label></p>
-  <p><input name="user_code" autocomplete="off" autofocus required pattern="[A-Z0-9]{4}-[A-Z0-9]{4}"></p>
+  <p><label for="user_code">Enter the code shown by <code>codex login --device-auth</code>:</label></p>
+  <p><input id="user_code" name="user_code" autocomplete="off" autofocus required pattern="[A-Z0-9]{4}-[A-Z0-9]{4}"
+            aria-describedby="user_code_format"></p>
+  <p id="user_code_format"><small>Format: XXXX-XXXX (letters and digits).</small></p>
   <p><button name="action" value="approve">Approve</button>
      <button name="action" value="deny" class="deny">Deny</button></p>
 </form>
 </body></html>`
 
 const verifyResultHTML = `<!doctype html>
-<html><head><meta charset="utf-8"><title>Codex device login</title>
+<html lang="en"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Codex device login</title>
 <style>body{font-family:-apple-system,sans-serif;max-width:480px;margin:4em auto;padding:0 1em;}</style>
 </head><body><h1>%s</h1><p>You may now return to your terminal.</p></body></html>`
 
@@ -0,0 +1,115 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// labelBrowserFor marks a browser sidecar container; the value is the
+// sandbox ID it belongs to.
+const labelBrowserFor = "agentserver.io/browser-for"
+
+func browserContainerName(id string) string {
+	return "cli-browser-" + id
+}
+
+// findBrowser returns the ID of the sandbox's browser sidecar container, or
+// "" if it has none.
+func (m *Manager) findBrowser(ctx context.Context, id string) (string, error) {
+	f := filters.NewArgs(
+		filters.Arg("label", labelBrowserFor+"="+id),
+		filters.Arg("label", labelManagedBy+"="+labelValue),
+	)
+	containers, err := m.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: f})
+	if err != nil {
+		return "", fmt.Errorf("find browser container: %w", err)
+	}
+	if len(containers) == 0 {
+		return "", nil
+	}
+	return containers[0].ID, nil
+}
+
+// ensureBrowser creates and starts the headless Chromium sidecar of a
+// sandbox. The sidecar joins the sandbox container's network namespace, so
+// the agent reaches CDP on localhost and nothing is published on the host.
+// The sandbox container must be running.
+func (m *Manager) ensureBrowser(ctx context.Context, id, sandboxContainerID string) error {
+	browserID, err := m.findBrowser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if browserID == "" {
+		pidsLimit := m.cfg.PidsLimit
+		resp, err := m.cli.ContainerCreate(ctx,
+			&container.Config{
+				Image: m.cfg.Browser.Image,
+				Cmd:   m.cfg.Browser.Args,
+				Labels: map[string]string{
+					labelManagedBy:  labelValue,
+					labelBrowserFor: id,
+				},
+			},
+			&container.HostConfig{
+				CapDrop:     []string{"ALL"},
+				SecurityOpt: []string{"no-new-privileges"},
+				NetworkMode: container.NetworkMode("container:" + sandboxContainerID),
+				RestartPolicy: container.RestartPolicy{
+					Name:              container.RestartPolicyOnFailure,
+					MaximumRetryCount: 5,
+				},
+				Resources: container.Resources{
+					Memory:    m.cfg.Browser.Memory,
+					NanoCPUs:  int64(m.cfg.Browser.CPU) * 1_000_000,
+					PidsLimit: &pidsLimit,
+				},
+			},
+			nil, nil, browserContainerName(id),
+		)
+		if err != nil {
+			return fmt.Errorf("browser container create: %w", err)
+		}
+		browserID = resp.ID
+	}
+	if err := m.cli.ContainerStart(ctx, browserID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("browser container start: %w", err)
+	}
+	return nil
+}
+
+// startBrowserIfPresent restarts an existing browser sidecar after its
+// sandbox container was started again (resume).
+func (m *Manager) startBrowserIfPresent(ctx context.Context, id string) error {
+	browserID, err := m.findBrowser(ctx, id)
+	if err != nil || browserID == "" {
+		return err
+	}
+	if err := m.cli.ContainerStart(ctx, browserID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("browser container start: %w", err)
+	}
+	return nil
+}
+
+// stopBrowser stops, and with remove also deletes, the sandbox's browser
+// sidecar. It is stopped before the sandbox container whose network
+// namespace it uses.
+func (m *Manager) stopBrowser(ctx context.Context, id string, remove bool) {
+	browserID, err := m.findBrowser(ctx, id)
+	if err != nil || browserID == "" {
+		return
+	}
+	m.cli.ContainerStop(ctx, browserID, container.StopOptions{})
+	if remove {
+		m.cli.ContainerRemove(ctx, browserID, container.RemoveOptions{Force: true})
+	}
+}
+
+// sandboxIDFromContainerName returns the sandbox ID of a "cli-sandbox-<id>"
+// container name.
+func sandboxIDFromContainerName(name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "/"), "cli-sandbox-")
+}
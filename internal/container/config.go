@@ -1,6 +1,10 @@
 package container
 
-import "os"
+import (
+	"os"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
 
 type Config struct {
 	Image                 string
@@ -11,6 +15,20 @@ type Config struct {
 	NetworkMode           string
 	OpencodeConfigContent string
 	OpenclawWeixinEnabled bool
+	// Probes enables Docker health checks per sandbox type ("*" for all
+	// types); see process.ParseProbeConfigs.
+	Probes map[string]process.ProbeConfig
+	// Browser configures the optional headless Chromium sidecar.
+	Browser process.BrowserSidecar
+	// HibernateToImage commits sandbox containers to images on pause (see
+	// hibernate.go). Images are tagged HibernateRepository:<sandbox id>.
+	HibernateToImage    bool
+	HibernateRepository string
+	// HibernatePush pushes hibernated images, so sandboxes can resume on
+	// another host. HibernateRegistryAuth is the base64url-encoded JSON
+	// registry auth sent with pushes and pulls.
+	HibernatePush         bool
+	HibernateRegistryAuth string
 }
 
 func DefaultConfig() Config {
@@ -23,6 +41,10 @@ func DefaultConfig() Config {
 		NetworkMode:           envOrDefault("AGENT_NETWORK_MODE", "bridge"),
 		OpencodeConfigContent: os.Getenv("OPENCODE_CONFIG_CONTENT"),
 		OpenclawWeixinEnabled: os.Getenv("OPENCLAW_WEIXIN_ENABLED") == "true",
+		HibernateToImage:      os.Getenv("AGENT_HIBERNATE_TO_IMAGE") == "true",
+		HibernateRepository:   envOrDefault("AGENT_HIBERNATE_REPOSITORY", "agentserver-hibernate"),
+		HibernatePush:         os.Getenv("AGENT_HIBERNATE_PUSH") == "true",
+		HibernateRegistryAuth: os.Getenv("AGENT_HIBERNATE_REGISTRY_AUTH"),
 	}
 }
 
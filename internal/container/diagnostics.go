@@ -0,0 +1,154 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// opencodeLogScript prints the tail of opencode's most recent log file.
+const opencodeLogScript = `f=$(ls -t "$HOME"/.local/share/opencode/log/*.log 2>/dev/null | head -n 1); [ -n "$f" ] && tail -n 1000 "$f"`
+
+// Diagnostics collects the state of a sandbox container for a diagnostics
+// bundle: docker inspect output (env values redacted), recent logs, a stats
+// snapshot and, for opencode, its own log. Parts that cannot be collected
+// are recorded as "<name>.error" files.
+func (m *Manager) Diagnostics(ctx context.Context, id, sandboxType string) ([]process.DiagnosticFile, error) {
+	containerID, err := m.findContainerID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []process.DiagnosticFile
+	add := func(name string, data []byte, err error) {
+		if err != nil {
+			files = append(files, process.DiagnosticFile{Name: name + ".error", Data: []byte(err.Error())})
+			return
+		}
+		files = append(files, process.DiagnosticFile{Name: name, Data: data})
+	}
+
+	info, err := m.cli.ContainerInspect(ctx, containerID)
+	if err == nil && info.Config != nil {
+		for i, kv := range info.Config.Env {
+			if k, _, ok := strings.Cut(kv, "="); ok {
+				info.Config.Env[i] = k + "=[redacted]"
+			}
+		}
+	}
+	data, _ := json.MarshalIndent(info, "", "  ")
+	add("inspect.json", data, err)
+
+	logs, err := m.containerLogs(ctx, containerID)
+	add("logs/container.log", logs, err)
+
+	stats, err := m.cli.ContainerStatsOneShot(ctx, containerID)
+	if err == nil {
+		data, err = io.ReadAll(stats.Body)
+		stats.Body.Close()
+	}
+	add("usage.json", data, err)
+
+	if sandboxType == "opencode" && info.State != nil && info.State.Running {
+		out, err := m.execOutput(ctx, containerID, []string{"sh", "-c", opencodeLogScript})
+		add("opencode.log", out, err)
+	}
+	return files, nil
+}
+
+func (m *Manager) containerLogs(ctx context.Context, containerID string) ([]byte, error) {
+	rc, err := m.cli.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Tail:       "1000",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, rc); err != nil {
+		return nil, fmt.Errorf("read logs: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// execOutput runs a one-shot command in the container and returns its
+// combined output.
+func (m *Manager) execOutput(ctx context.Context, containerID string, cmd []string) ([]byte, error) {
+	exec, err := m.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exec create: %w", err)
+	}
+	resp, err := m.cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("exec attach: %w", err)
+	}
+	defer resp.Close()
+	var buf bytes.Buffer
+	if _, err := stdcopy.StdCopy(&buf, &buf, resp.Reader); err != nil {
+		return nil, fmt.Errorf("read exec output: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ExecSimple runs a one-shot command (no stdin/TTY) in a sandbox container
+// and returns its stdout. A non-zero exit status is returned as an error
+// carrying the command's stderr.
+func (m *Manager) ExecSimple(ctx context.Context, sandboxID string, command []string) (string, error) {
+	return m.ExecInput(ctx, sandboxID, command, nil)
+}
+
+// ExecInput is ExecSimple with stdin streamed to the command; a nil stdin
+// attaches none.
+func (m *Manager) ExecInput(ctx context.Context, sandboxID string, command []string, stdin io.Reader) (string, error) {
+	containerID, err := m.findContainerID(ctx, sandboxID)
+	if err != nil {
+		return "", err
+	}
+	exec, err := m.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          command,
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("exec create: %w", err)
+	}
+	resp, err := m.cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", fmt.Errorf("exec attach: %w", err)
+	}
+	defer resp.Close()
+	if stdin != nil {
+		go func() {
+			io.Copy(resp.Conn, stdin)
+			resp.CloseWrite()
+		}()
+	}
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader); err != nil {
+		return "", fmt.Errorf("read exec output: %w", err)
+	}
+	info, err := m.cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return "", fmt.Errorf("exec inspect: %w", err)
+	}
+	if info.ExitCode != 0 {
+		return stdout.String(), fmt.Errorf("command exited with code %d (stderr: %s)", info.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,62 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// CopyFromSandbox reads a regular file out of a sandbox container
+// (docker cp).
+func (m *Manager) CopyFromSandbox(ctx context.Context, sandboxID, filePath string) ([]byte, error) {
+	containerID, err := m.findContainerID(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	rc, stat, err := m.cli.CopyFromContainer(ctx, containerID, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("copy from container: %w", err)
+	}
+	defer rc.Close()
+	if !stat.Mode.IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", filePath)
+	}
+	tr := tar.NewReader(rc)
+	if _, err := tr.Next(); err != nil {
+		return nil, fmt.Errorf("read copied file: %w", err)
+	}
+	return io.ReadAll(tr)
+}
+
+// CopyToSandbox writes a file into a sandbox container (docker cp), owned
+// by the agent user. The parent directory must exist.
+func (m *Manager) CopyToSandbox(ctx context.Context, sandboxID, filePath string, data []byte) error {
+	containerID, err := m.findContainerID(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name: path.Base(filePath), Typeflag: tar.TypeReg, Mode: 0o644, Uid: agentUID, Gid: agentUID,
+		Size: int64(len(data)), ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := m.cli.CopyToContainer(ctx, containerID, path.Dir(filePath), &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("copy to container: %w", err)
+	}
+	return nil
+}
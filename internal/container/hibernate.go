@@ -0,0 +1,256 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	dockermount "github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/errdefs"
+)
+
+// Hibernation (Config.HibernateToImage) commits a sandbox container to an
+// image when it is paused and removes the container. Resume recreates the
+// container from the image, pulling it first when it was pushed from
+// another host. The image keeps everything outside the volumes: installed
+// packages, system configuration and the container's environment.
+
+// labelHibernate holds the JSON hibernateState on a hibernated image.
+const labelHibernate = "agentserver.io/hibernate"
+
+// hibernateState is the host configuration a container restored from a
+// hibernated image needs; images only carry the container config.
+type hibernateState struct {
+	Mounts    []dockermount.Mount `json:"mounts"`
+	Memory    int64               `json:"memory"`
+	NanoCPUs  int64               `json:"nanoCPUs"`
+	PidsLimit int64               `json:"pidsLimit"`
+	Browser   bool                `json:"browser,omitempty"`
+}
+
+// hibernateRef is the image reference sandbox id hibernates to.
+func (m *Manager) hibernateRef(id string) string {
+	return m.cfg.HibernateRepository + ":" + id
+}
+
+// HibernatedImage returns the hibernated image of a sandbox, pulling it
+// from the registry if it is not present locally, or "" if the sandbox has
+// none.
+func (m *Manager) HibernatedImage(ctx context.Context, id string) (string, error) {
+	if !m.cfg.HibernateToImage {
+		return "", nil
+	}
+	ref := m.hibernateRef(id)
+	ok, err := m.ensureImage(ctx, ref)
+	if err != nil || !ok {
+		return "", err
+	}
+	return ref, nil
+}
+
+// ensureImage reports whether ref is available locally, pulling it when
+// images are pushed to a registry.
+func (m *Manager) ensureImage(ctx context.Context, ref string) (bool, error) {
+	_, err := m.cli.ImageInspect(ctx, ref)
+	if err == nil {
+		return true, nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return false, fmt.Errorf("inspect image %s: %w", ref, err)
+	}
+	if !m.cfg.HibernatePush {
+		return false, nil
+	}
+	rc, err := m.cli.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: m.cfg.HibernateRegistryAuth})
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("pull %s: %w", ref, err)
+	}
+	defer rc.Close()
+	if err := readProgress(rc); err != nil {
+		return false, fmt.Errorf("pull %s: %w", ref, err)
+	}
+	return true, nil
+}
+
+// hibernate commits the stopped container of sandbox id, pushes the image
+// when configured, and removes the container and its browser sidecar. On
+// error the container is left in place, so a plain resume still works.
+func (m *Manager) hibernate(ctx context.Context, id, containerID string) error {
+	info, err := m.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("inspect container: %w", err)
+	}
+	browserID, err := m.findBrowser(ctx, id)
+	if err != nil {
+		return err
+	}
+	state := hibernateState{
+		Mounts:   info.HostConfig.Mounts,
+		Memory:   info.HostConfig.Memory,
+		NanoCPUs: info.HostConfig.NanoCPUs,
+		Browser:  browserID != "",
+	}
+	if info.HostConfig.PidsLimit != nil {
+		state.PidsLimit = *info.HostConfig.PidsLimit
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	cfg := *info.Config
+	cfg.Labels = maps.Clone(cfg.Labels)
+	if cfg.Labels == nil {
+		cfg.Labels = make(map[string]string)
+	}
+	cfg.Labels[labelHibernate] = string(data)
+
+	ref := m.hibernateRef(id)
+	if _, err := m.cli.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: ref,
+		Comment:   "agentserver: hibernated sandbox " + id,
+		Config:    &cfg,
+	}); err != nil {
+		return fmt.Errorf("commit %s: %w", ref, err)
+	}
+	if m.cfg.HibernatePush {
+		rc, err := m.cli.ImagePush(ctx, ref, image.PushOptions{RegistryAuth: m.cfg.HibernateRegistryAuth})
+		if err != nil {
+			return fmt.Errorf("push %s: %w", ref, err)
+		}
+		err = readProgress(rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("push %s: %w", ref, err)
+		}
+	}
+
+	m.stopBrowser(ctx, id, true)
+	if err := m.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{}); err != nil {
+		return fmt.Errorf("remove container: %w", err)
+	}
+	log.Printf("sandbox %s: hibernated to %s", id, ref)
+	return nil
+}
+
+// ResumeContainer starts the stopped container of a paused sandbox. With
+// hibernation, a container removed on pause is recreated from its image.
+func (m *Manager) ResumeContainer(id string) error {
+	ctx := context.Background()
+	containerName := "cli-sandbox-" + id
+	f := filters.NewArgs(
+		filters.Arg("name", containerName),
+		filters.Arg("label", labelManagedBy+"="+labelValue),
+	)
+	existing, err := m.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: f})
+	if err != nil {
+		return fmt.Errorf("find container for resume: %w", err)
+	}
+	if len(existing) > 0 {
+		if err := m.cli.ContainerStart(ctx, existing[0].ID, container.StartOptions{}); err != nil {
+			return fmt.Errorf("container start on resume: %w", err)
+		}
+		if err := m.startBrowserIfPresent(ctx, id); err != nil {
+			log.Printf("sandbox %s: %v", id, err)
+		}
+		return nil
+	}
+
+	ref, err := m.HibernatedImage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ref == "" {
+		return fmt.Errorf("container %s not found for resume", containerName)
+	}
+	return m.restore(ctx, id, ref)
+}
+
+// restore creates and starts the container of sandbox id from its
+// hibernated image. Env, command and health check come from the image.
+func (m *Manager) restore(ctx context.Context, id, ref string) error {
+	img, err := m.cli.ImageInspect(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("inspect image %s: %w", ref, err)
+	}
+	var state hibernateState
+	if img.Config != nil {
+		if err := json.Unmarshal([]byte(img.Config.Labels[labelHibernate]), &state); err != nil {
+			return fmt.Errorf("image %s is not a hibernated sandbox: %w", ref, err)
+		}
+	}
+	pidsLimit := state.PidsLimit
+	resp, err := m.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:  ref,
+			Labels: map[string]string{labelManagedBy: labelValue},
+		},
+		&container.HostConfig{
+			CapDrop:     []string{"ALL"},
+			SecurityOpt: []string{"no-new-privileges"},
+			NetworkMode: container.NetworkMode(m.cfg.NetworkMode),
+			Mounts:      state.Mounts,
+			Resources: container.Resources{
+				Memory:    state.Memory,
+				NanoCPUs:  state.NanoCPUs,
+				PidsLimit: &pidsLimit,
+			},
+		},
+		nil, nil, "cli-sandbox-"+id,
+	)
+	if err != nil {
+		return fmt.Errorf("container create from %s: %w", ref, err)
+	}
+	if err := m.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+		return fmt.Errorf("container start: %w", err)
+	}
+	if state.Browser && m.cfg.Browser.Image != "" {
+		if err := m.ensureBrowser(ctx, id, resp.ID); err != nil {
+			log.Printf("sandbox %s: %v", id, err)
+		}
+	}
+	log.Printf("sandbox %s: restored from %s", id, ref)
+	return nil
+}
+
+// removeHibernatedImage deletes the local hibernated image of a deleted
+// sandbox. Pushed copies stay in the registry. Images still used by
+// sandboxes created from them are kept.
+func (m *Manager) removeHibernatedImage(ctx context.Context, id string) {
+	if !m.cfg.HibernateToImage {
+		return
+	}
+	ref := m.hibernateRef(id)
+	if _, err := m.cli.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil && !errdefs.IsNotFound(err) {
+		log.Printf("sandbox %s: remove hibernated image: %v", id, err)
+	}
+}
+
+// readProgress drains a pull or push progress stream and returns the error
+// it reports, if any; the API call itself succeeds even when the transfer
+// fails.
+func readProgress(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%s", msg.Error)
+		}
+	}
+}
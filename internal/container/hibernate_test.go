@@ -0,0 +1,28 @@
+package container
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadProgress(t *testing.T) {
+	ok := `{"status":"Preparing","id":"a1"}
+{"status":"Pushed","id":"a1"}
+{"status":"latest: digest: sha256:abc size: 1234"}
+`
+	if err := readProgress(strings.NewReader(ok)); err != nil {
+		t.Fatalf("readProgress(ok) = %v", err)
+	}
+
+	failed := `{"status":"Preparing","id":"a1"}
+{"errorDetail":{"message":"denied"},"error":"denied: requested access to the resource is denied"}
+`
+	err := readProgress(strings.NewReader(failed))
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("readProgress(failed) = %v, want denied error", err)
+	}
+
+	if err := readProgress(strings.NewReader("not json")); err == nil {
+		t.Fatal("readProgress(garbage) = nil, want error")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -78,6 +79,12 @@ func NewManager(cfg Config) (*Manager, error) {
 	return m, nil
 }
 
+// Client returns the manager's Docker API client, shared with the workspace
+// drive manager.
+func (m *Manager) Client() *client.Client {
+	return m.cli
+}
+
 // CleanOrphans removes containers labelled managed-by=agentserver that are NOT in the known set.
 func (m *Manager) CleanOrphans(knownContainerNames []string) {
 	ctx := context.Background()
@@ -101,6 +108,10 @@ func (m *Manager) CleanOrphans(knownContainerNames []string) {
 				break
 			}
 		}
+		// Browser sidecars live as long as their sandbox container.
+		if sbxID, ok := c.Labels[labelBrowserFor]; ok && known["/cli-sandbox-"+sbxID] {
+			isKnown = true
+		}
 		if isKnown {
 			continue
 		}
@@ -138,6 +149,9 @@ func (m *Manager) EnsureContainer(id string, opts process.StartOptions) (string,
 			if err := m.cli.ContainerStart(ctx, ctr.ID, container.StartOptions{}); err != nil {
 				return "", fmt.Errorf("container restart: %w", err)
 			}
+			if err := m.startBrowserIfPresent(ctx, id); err != nil {
+				log.Printf("sandbox %s: %v", id, err)
+			}
 		}
 		return ctr.ID, nil
 	}
@@ -169,6 +183,27 @@ func (m *Manager) EnsureContainer(id string, opts process.StartOptions) (string,
 		containerEnv = append(containerEnv, "OPENCODE_CONFIG_CONTENT="+opcodeConfig)
 	}
 
+	if opts.Image != "" {
+		containerImage = opts.Image
+	}
+
+	browser := m.cfg.Browser.Enabled(opts.SandboxType, opts.Browser)
+	if browser {
+		containerEnv = append(containerEnv, "BROWSER_CDP_URL="+process.BrowserCDPURL())
+	}
+	// Workspace secrets, then template env vars; the variables set above
+	// win.
+	for _, name := range process.EnvNames(opts.SecretEnv) {
+		if !envSet(containerEnv, name) {
+			containerEnv = append(containerEnv, name+"="+opts.SecretEnv[name])
+		}
+	}
+	for _, name := range process.EnvNames(opts.Env) {
+		if !envSet(containerEnv, name) {
+			containerEnv = append(containerEnv, name+"="+opts.Env[name])
+		}
+	}
+
 	// Volume mounts for persistence.
 	mounts := []dockermount.Mount{
 		{
@@ -217,6 +252,19 @@ func (m *Manager) EnsureContainer(id string, opts process.StartOptions) (string,
 CFGEOF
 exec node openclaw.mjs gateway --allow-unconfigured --bind lan`}
 	}
+	if len(opts.OpencodeWorkers) > 0 {
+		// The opencode image's entrypoint is the server itself; replace it
+		// with the supervisor that also runs the project workers.
+		containerConfig.Entrypoint = process.OpencodeCommand(opts.OpencodeWorkers, 4096)
+	}
+	if len(opts.Command) > 0 {
+		containerConfig.Entrypoint = opts.Command
+		containerConfig.Cmd = nil
+	}
+	probe, probed := m.probeFor(opts.SandboxType)
+	if probed {
+		containerConfig.Healthcheck = healthcheck(probe, opts.SandboxType)
+	}
 	resp, err := m.cli.ContainerCreate(ctx,
 		containerConfig,
 		&container.HostConfig{
@@ -235,12 +283,31 @@ exec node openclaw.mjs gateway --allow-unconfigured --bind lan`}
 	if err != nil {
 		return "", fmt.Errorf("container create: %w", err)
 	}
+	if err := m.copySecretFiles(ctx, resp.ID, opts.SecretFiles); err != nil {
+		m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+		return "", err
+	}
 
 	if err := m.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
 		return "", fmt.Errorf("container start: %w", err)
 	}
 
+	if probed {
+		if err := m.waitHealthy(ctx, resp.ID, probe.StartupTimeout); err != nil {
+			m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+			return "", fmt.Errorf("container not ready: %w", err)
+		}
+	}
+
+	if browser {
+		if err := m.ensureBrowser(ctx, id, resp.ID); err != nil {
+			m.stopBrowser(ctx, id, true)
+			m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+			return "", err
+		}
+	}
+
 	return resp.ID, nil
 }
 
@@ -268,7 +335,9 @@ func (m *Manager) Pause(id string) error {
 		p.once.Do(func() { close(p.done) })
 
 		ctx := context.Background()
+		m.stopBrowser(ctx, id, false)
 		m.cli.ContainerStop(ctx, p.containerID, container.StopOptions{})
+		m.hibernateIfEnabled(ctx, id, p.containerID)
 		return nil
 	}
 
@@ -283,10 +352,23 @@ func (m *Manager) Pause(id string) error {
 	if err != nil || len(containers) == 0 {
 		return fmt.Errorf("session %s: container not found for pause", id)
 	}
+	m.stopBrowser(ctx, id, false)
 	m.cli.ContainerStop(ctx, containers[0].ID, container.StopOptions{})
+	m.hibernateIfEnabled(ctx, id, containers[0].ID)
 	return nil
 }
 
+// hibernateIfEnabled hibernates a just-stopped container. Failures only
+// cost the hibernation: the sandbox stays paused as a stopped container.
+func (m *Manager) hibernateIfEnabled(ctx context.Context, id, containerID string) {
+	if !m.cfg.HibernateToImage {
+		return
+	}
+	if err := m.hibernate(ctx, id, containerID); err != nil {
+		log.Printf("sandbox %s: hibernate: %v", id, err)
+	}
+}
+
 // Resume starts the stopped container and exec's into it.
 func (m *Manager) Resume(id, containerName, command string, args []string) (process.Process, error) {
 	ctx := context.Background()
@@ -310,6 +392,9 @@ func (m *Manager) Resume(id, containerName, command string, args []string) (proc
 	if err := m.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
 		return nil, fmt.Errorf("container start on resume: %w", err)
 	}
+	if err := m.startBrowserIfPresent(ctx, id); err != nil {
+		log.Printf("sandbox %s: %v", id, err)
+	}
 
 	return m.execInContainer(id, containerID, command, args, nil)
 }
@@ -345,6 +430,14 @@ func (m *Manager) execInContainer(id, containerID, command string, args, env []s
 	return p, nil
 }
 
+// ImageForType returns the image a container of the given type runs.
+func (m *Manager) ImageForType(sandboxType string) string {
+	if sandboxType == "openclaw" && m.cfg.OpenclawImage != "" {
+		return m.cfg.OpenclawImage
+	}
+	return m.cfg.Image
+}
+
 func (m *Manager) Get(id string) (process.Process, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -373,11 +466,13 @@ func (m *Manager) Stop(id string) error {
 	p.once.Do(func() { close(p.done) })
 
 	ctx := context.Background()
+	m.stopBrowser(ctx, id, true)
 	m.cli.ContainerStop(ctx, p.containerID, container.StopOptions{})
 	m.cli.ContainerRemove(ctx, p.containerID, container.RemoveOptions{Force: true})
 
 	// Also remove the session data volume.
 	m.cli.VolumeRemove(ctx, "cli-sandbox-"+id+"-data", true)
+	m.removeHibernatedImage(ctx, id)
 	return nil
 }
 
@@ -392,10 +487,12 @@ func (m *Manager) StopByContainerName(containerName string) error {
 	if err != nil {
 		return err
 	}
+	m.stopBrowser(ctx, sandboxIDFromContainerName(containerName), true)
 	for _, c := range containers {
 		m.cli.ContainerStop(ctx, c.ID, container.StopOptions{})
 		m.cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
 	}
+	m.removeHibernatedImage(ctx, sandboxIDFromContainerName(containerName))
 	return nil
 }
 
@@ -416,3 +513,13 @@ func (m *Manager) Close() error {
 	m.StopAll()
 	return m.cli.Close()
 }
+
+// envSet reports whether env ("NAME=value" entries) sets name.
+func envSet(env []string, name string) bool {
+	for _, e := range env {
+		if strings.HasPrefix(e, name+"=") {
+			return true
+		}
+	}
+	return false
+}
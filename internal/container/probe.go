@@ -0,0 +1,75 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// defaultPort returns the main port of a sandbox type's container.
+func defaultPort(sandboxType string) int {
+	if sandboxType == "openclaw" {
+		return 18789
+	}
+	return 4096
+}
+
+// probeFor returns the configured probe for a sandbox type. Docker sandboxes
+// are not health-checked unless a probe is configured for the type or "*",
+// which keeps the previous start-and-return behaviour by default.
+func (m *Manager) probeFor(sandboxType string) (process.ProbeConfig, bool) {
+	_, typed := m.cfg.Probes[sandboxType]
+	_, all := m.cfg.Probes["*"]
+	if !typed && !all {
+		return process.ProbeConfig{}, false
+	}
+	return process.ResolveProbe(m.cfg.Probes, sandboxType), true
+}
+
+// healthcheck converts a probe config into a Docker HEALTHCHECK. The check
+// runs inside the container, so it relies on bash (TCP) or curl (HTTP).
+func healthcheck(pc process.ProbeConfig, sandboxType string) *container.HealthConfig {
+	port := pc.Port
+	if port == 0 {
+		port = defaultPort(sandboxType)
+	}
+	test := []string{"CMD-SHELL", "bash -c '</dev/tcp/127.0.0.1/" + strconv.Itoa(port) + "'"}
+	if pc.Type == process.ProbeHTTP {
+		test = []string{"CMD-SHELL", "curl -fsS -o /dev/null http://127.0.0.1:" + strconv.Itoa(port) + pc.Path}
+	}
+	return &container.HealthConfig{
+		Test:        test,
+		Interval:    pc.Period,
+		StartPeriod: pc.InitialDelay,
+		Retries:     pc.FailureThreshold,
+	}
+}
+
+// waitHealthy polls the container until Docker reports it healthy.
+func (m *Manager) waitHealthy(ctx context.Context, containerID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		info, err := m.cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("inspect container: %w", err)
+		}
+		if info.State != nil && info.State.Health != nil {
+			switch info.State.Health.Status {
+			case "healthy":
+				return nil
+			case "unhealthy":
+				return fmt.Errorf("container %s is unhealthy", containerID)
+			}
+		}
+		if info.State != nil && !info.State.Running {
+			return fmt.Errorf("container %s exited", containerID)
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Errorf("timed out waiting for container %s", containerID)
+}
@@ -0,0 +1,52 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// findContainerID returns the Docker container ID for a sandbox.
+func (m *Manager) findContainerID(ctx context.Context, id string) (string, error) {
+	f := filters.NewArgs(
+		filters.Arg("name", "cli-sandbox-"+id),
+		filters.Arg("label", labelManagedBy+"="+labelValue),
+	)
+	containers, err := m.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: f})
+	if err != nil {
+		return "", fmt.Errorf("find container: %w", err)
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("container for sandbox %s not found", id)
+	}
+	return containers[0].ID, nil
+}
+
+// QuarantineContainer disconnects the sandbox container from its network.
+// The container and its volumes are left in place for inspection.
+func (m *Manager) QuarantineContainer(id string) error {
+	ctx := context.Background()
+	containerID, err := m.findContainerID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := m.cli.NetworkDisconnect(ctx, m.cfg.NetworkMode, containerID, true); err != nil {
+		return fmt.Errorf("disconnect network: %w", err)
+	}
+	return nil
+}
+
+// ReleaseContainer reconnects a quarantined sandbox container to its network.
+func (m *Manager) ReleaseContainer(id string) error {
+	ctx := context.Background()
+	containerID, err := m.findContainerID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := m.cli.NetworkConnect(ctx, m.cfg.NetworkMode, containerID, nil); err != nil {
+		return fmt.Errorf("connect network: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/docker/docker/api/types/container"
+)
+
+// agentUID is the user the agent runs as in sandbox images.
+const agentUID = 1000
+
+// secretFilesTar builds a tar of files placed in process.SecretFilesDir,
+// owned by the agent and readable only by it, for copying to the root of a
+// container.
+func secretFilesTar(files map[string]string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	dir := strings.TrimPrefix(process.SecretFilesDir, "/")
+	if err := tw.WriteHeader(&tar.Header{
+		Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0o500, Uid: agentUID, Gid: agentUID,
+	}); err != nil {
+		return nil, err
+	}
+	for _, name := range process.EnvNames(files) {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: dir + "/" + name, Typeflag: tar.TypeReg, Mode: 0o400, Uid: agentUID, Gid: agentUID,
+			Size: int64(len(files[name])),
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write([]byte(files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// copySecretFiles writes the workspace secret files into a created
+// container before it starts.
+func (m *Manager) copySecretFiles(ctx context.Context, containerID string, files map[string]string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	content, err := secretFilesTar(files)
+	if err != nil {
+		return fmt.Errorf("build secret files: %w", err)
+	}
+	if err := m.cli.CopyToContainer(ctx, containerID, "/", content, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("copy secret files: %w", err)
+	}
+	return nil
+}
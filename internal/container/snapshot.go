@@ -0,0 +1,119 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/docker/docker/api/types/container"
+	dockermount "github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// Session-data snapshots are Docker volumes holding a copy of the sandbox's
+// data volume, made by a short-lived helper container running the agent
+// image. Restoring replaces the data volume's contents, so the server only
+// restores paused sandboxes. Cloning fills the data volume of a sandbox
+// before its container is created, so Docker doesn't populate it from the
+// image.
+
+func dataVolume(id string) string {
+	return "cli-sandbox-" + id + "-data"
+}
+
+func snapshotVolume(id, snapshotID string) string {
+	return "cli-sandbox-" + id + "-snap-" + snapshotID
+}
+
+// SnapshotSessionData copies the data volume of sandbox id into a new
+// volume and returns its name.
+func (m *Manager) SnapshotSessionData(ctx context.Context, id, snapshotID string) (string, error) {
+	name := snapshotVolume(id, snapshotID)
+	if _, err := m.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: map[string]string{labelManagedBy: labelValue, "sandbox-id": id},
+	}); err != nil {
+		return "", fmt.Errorf("create snapshot volume %s: %w", name, err)
+	}
+	if err := m.copyVolume(ctx, dataVolume(id), name); err != nil {
+		m.cli.VolumeRemove(ctx, name, true)
+		return "", err
+	}
+	log.Printf("sandbox %s: snapshotted session data to volume %s", id, name)
+	return name, nil
+}
+
+// RestoreSessionData replaces the contents of the data volume of sandbox id
+// with snapshot volume ref.
+func (m *Manager) RestoreSessionData(ctx context.Context, id, ref string) error {
+	if err := m.copyVolume(ctx, ref, dataVolume(id)); err != nil {
+		return err
+	}
+	log.Printf("sandbox %s: restored session data from volume %s", id, ref)
+	return nil
+}
+
+// CloneSessionData copies the data volume of sandbox srcID into that of
+// sandbox dstID, which must not have started yet.
+func (m *Manager) CloneSessionData(ctx context.Context, srcID, dstID string) error {
+	name := dataVolume(dstID)
+	if _, err := m.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: map[string]string{labelManagedBy: labelValue, "sandbox-id": dstID},
+	}); err != nil {
+		return fmt.Errorf("create data volume %s: %w", name, err)
+	}
+	if err := m.copyVolume(ctx, dataVolume(srcID), name); err != nil {
+		m.cli.VolumeRemove(ctx, name, true)
+		return err
+	}
+	log.Printf("sandbox %s: cloned session data of sandbox %s", dstID, srcID)
+	return nil
+}
+
+// DeleteSessionDataSnapshot removes snapshot volume ref. A volume that is
+// already gone is not an error.
+func (m *Manager) DeleteSessionDataSnapshot(ctx context.Context, id, ref string) error {
+	if err := m.cli.VolumeRemove(ctx, ref, true); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("remove snapshot volume %s: %w", ref, err)
+	}
+	return nil
+}
+
+// copyVolume replaces the contents of volume dst with those of src,
+// preserving ownership and permissions.
+func (m *Manager) copyVolume(ctx context.Context, src, dst string) error {
+	resp, err := m.cli.ContainerCreate(ctx, &container.Config{
+		Image:      m.cfg.Image,
+		User:       "0:0",
+		Entrypoint: []string{"sh", "-c", "find /dst -mindepth 1 -delete && cp -a /src/. /dst/"},
+		Labels:     map[string]string{labelManagedBy: labelValue},
+	}, &container.HostConfig{
+		Mounts: []dockermount.Mount{
+			{Type: dockermount.TypeVolume, Source: src, Target: "/src", ReadOnly: true},
+			{Type: dockermount.TypeVolume, Source: dst, Target: "/dst"},
+		},
+	}, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("create copy container: %w", err)
+	}
+	defer m.cli.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+
+	waitCh, errCh := m.cli.ContainerWait(ctx, resp.ID, container.WaitConditionNextExit)
+	if err := m.cli.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("start copy container: %w", err)
+	}
+	select {
+	case res := <-waitCh:
+		if res.Error != nil {
+			return fmt.Errorf("copy %s to %s: %s", src, dst, res.Error.Message)
+		}
+		if res.StatusCode != 0 {
+			return fmt.Errorf("copy %s to %s: exit status %d", src, dst, res.StatusCode)
+		}
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("wait for copy container: %w", err)
+	}
+}
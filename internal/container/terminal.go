@@ -0,0 +1,38 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/creack/pty"
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// OpenTerminal starts an interactive command with a PTY in a sandbox's
+// container, e.g. a shell for the web terminal. It is independent of the
+// sandbox's session process and ends when ctx is cancelled.
+func (m *Manager) OpenTerminal(ctx context.Context, sandboxID string, command []string) (process.Process, error) {
+	containerID, err := m.findContainerID(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"exec", "-it", containerID}, command...)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	ptyFile, err := pty.Start(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("pty start: %w", err)
+	}
+	p := &containerProcess{
+		containerID: containerID,
+		cmd:         cmd,
+		ptyFile:     ptyFile,
+		done:        make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		ptyFile.Close()
+		p.once.Do(func() { close(p.done) })
+	}()
+	return p, nil
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/agentserver/agentserver/internal/crypto"
+	"github.com/agentserver/agentserver/internal/secrets"
 )
 
 // Config holds all configuration for the credential proxy.
@@ -19,6 +20,12 @@ type Config struct {
 	LogLevel              slog.Level
 	UpstreamTimeout       time.Duration
 	AllowPrivateUpstreams bool
+
+	// Secrets resolves credential bindings whose secret is a
+	// vault:<path>#<key> reference, relative to
+	// WorkspaceSecretsPrefix/<workspace id>. Nil disables references.
+	Secrets                secrets.Backend
+	WorkspaceSecretsPrefix string
 }
 
 // LoadConfigFromEnv reads configuration from environment variables.
@@ -64,6 +71,7 @@ func LoadConfigFromEnv() (Config, error) {
 	}
 
 	cfg.AllowPrivateUpstreams = os.Getenv("CREDPROXY_ALLOW_PRIVATE_UPSTREAMS") == "true"
+	cfg.WorkspaceSecretsPrefix = envOr("VAULT_WORKSPACE_PREFIX", "agentserver/workspaces")
 
 	return cfg, nil
 }
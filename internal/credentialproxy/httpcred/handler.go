@@ -0,0 +1,251 @@
+package httpcred
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/credentialproxy/provider"
+)
+
+const redacted = "[REDACTED]"
+
+// serveHTTPProxy handles a single proxied request to the binding's upstream.
+func serveHTTPProxy(w http.ResponseWriter, r *http.Request, binding *provider.DecryptedBinding, allowPrivateUpstreams bool) {
+	var sec secret
+	if err := json.Unmarshal(binding.AuthSecret, &sec); err != nil {
+		http.Error(w, "credential injection error", http.StatusInternalServerError)
+		return
+	}
+
+	upstream, err := url.Parse(binding.ServerURL)
+	if err != nil {
+		http.Error(w, "invalid upstream URL", http.StatusInternalServerError)
+		return
+	}
+
+	// Strip the /http/{binding_id} prefix from the request path.
+	prefix := fmt.Sprintf("/http/%s", binding.ID)
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "internal routing error: unexpected path prefix", http.StatusInternalServerError)
+		return
+	}
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+	r.URL.RawPath = ""
+
+	// Remove the sandbox's proxy token and inject the real credential.
+	r.Header.Del("Authorization")
+	switch binding.AuthType {
+	case "bearer":
+		r.Header.Set("Authorization", "Bearer "+sec.Token)
+	case "basic":
+		r.SetBasicAuth(sec.Username, sec.Password)
+	case "header":
+		r.Header.Set(sec.HeaderName, sec.Token)
+	default:
+		http.Error(w, "unsupported auth type", http.StatusInternalServerError)
+		return
+	}
+	// Let the transport negotiate (and transparently decode) compression so
+	// response bodies can be scrubbed as plain text.
+	r.Header.Del("Accept-Encoding")
+
+	secrets := secretValues(binding.AuthType, sec)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.Out.Host = upstream.Host
+		},
+		Transport: upstreamTransport(allowPrivateUpstreams),
+		ModifyResponse: func(resp *http.Response) error {
+			scrubResponse(resp, secrets)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, _ error) {
+			http.Error(w, "upstream unreachable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// secretValues returns every string form in which the credential could be
+// echoed back by an upstream.
+func secretValues(authType string, sec secret) []string {
+	var out []string
+	switch authType {
+	case "basic":
+		out = append(out, sec.Password, base64.StdEncoding.EncodeToString([]byte(sec.Username+":"+sec.Password)))
+	default:
+		out = append(out, sec.Token)
+	}
+	return out
+}
+
+// scrubResponse removes the credential from response headers and body.
+func scrubResponse(resp *http.Response, secrets []string) {
+	for name, values := range resp.Header {
+		for i, v := range values {
+			for _, s := range secrets {
+				v = strings.ReplaceAll(v, s, redacted)
+			}
+			values[i] = v
+		}
+		resp.Header[name] = values
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = newRedactingReader(resp.Body, secrets)
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+}
+
+// redactingReader replaces occurrences of any secret in a stream. It holds
+// back the last (longest secret - 1) bytes of each read so that a secret
+// split across reads is still caught.
+type redactingReader struct {
+	src     io.ReadCloser
+	secrets [][]byte
+	keep    int
+	pending []byte
+	out     []byte
+	eof     bool
+}
+
+func newRedactingReader(src io.ReadCloser, secrets []string) *redactingReader {
+	rr := &redactingReader{src: src}
+	for _, s := range secrets {
+		if s == "" {
+			continue
+		}
+		rr.secrets = append(rr.secrets, []byte(s))
+		if len(s)-1 > rr.keep {
+			rr.keep = len(s) - 1
+		}
+	}
+	return rr
+}
+
+func (rr *redactingReader) Read(p []byte) (int, error) {
+	for len(rr.out) == 0 {
+		if rr.eof {
+			return 0, io.EOF
+		}
+		buf := make([]byte, 32*1024)
+		n, err := rr.src.Read(buf)
+		rr.pending = append(rr.pending, buf[:n]...)
+		for _, s := range rr.secrets {
+			rr.pending = bytes.ReplaceAll(rr.pending, s, []byte(redacted))
+		}
+		if err != nil {
+			if err != io.EOF {
+				return 0, err
+			}
+			rr.eof = true
+			rr.out, rr.pending = rr.pending, nil
+			continue
+		}
+		if len(rr.pending) > rr.keep {
+			cut := len(rr.pending) - rr.keep
+			rr.out = append(rr.out, rr.pending[:cut]...)
+			rr.pending = append([]byte(nil), rr.pending[cut:]...)
+		}
+	}
+	n := copy(p, rr.out)
+	rr.out = rr.out[n:]
+	return n, nil
+}
+
+func (rr *redactingReader) Close() error {
+	return rr.src.Close()
+}
+
+// Upstream transports are shared by all requests so connections are pooled
+// and reused.
+var (
+	guardedTransport = buildUpstreamTransport(false)
+	privateTransport = buildUpstreamTransport(true)
+)
+
+// upstreamTransport returns the shared transport for upstream requests.
+func upstreamTransport(allowPrivateUpstreams bool) *http.Transport {
+	if allowPrivateUpstreams {
+		return privateTransport
+	}
+	return guardedTransport
+}
+
+// buildUpstreamTransport returns a transport with the SSRF dial-time guard.
+// The guard resolves the host once and dials the addresses it vetted, so a
+// DNS answer that changes between the check and the dial (rebinding) can't
+// reach a private address.
+func buildUpstreamTransport(allowPrivateUpstreams bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	transport := &http.Transport{
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+	}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("split host port: %w", err)
+		}
+		if allowPrivateUpstreams {
+			return dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+		}
+
+		ips, err := resolvePublicIPs(ctx, net.DefaultResolver.LookupIPAddr, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+	return transport
+}
+
+// resolvePublicIPs resolves host with lookup and returns its addresses,
+// refusing the host if any of them is private, loopback, link-local or
+// unspecified.
+func resolvePublicIPs(ctx context.Context, lookup func(context.Context, string) ([]net.IPAddr, error), host string) ([]net.IP, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := lookup(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s: %w", host, err)
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("resolve %s: no addresses", host)
+	}
+	for _, ip := range ips {
+		if provider.IsPrivateIP(ip) || ip.IsUnspecified() {
+			return nil, fmt.Errorf("dial refused: upstream address %s resolved to private IP %s", host, ip)
+		}
+	}
+	return ips, nil
+}
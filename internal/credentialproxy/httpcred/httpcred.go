@@ -0,0 +1,88 @@
+// Package httpcred implements a generic HTTP credential provider. Sandboxes
+// talk to an upstream service (package registry, internal API, ...) through
+// the credential proxy at /http/{binding_id}/..., authenticating with their
+// sandbox proxy token. The proxy swaps that for the real credential on the
+// way out and scrubs the credential from responses on the way back, so the
+// secret never exists inside the sandbox.
+package httpcred
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/credentialproxy/provider"
+)
+
+// AllowPrivateUpstreams disables the SSRF dial-time guard when true.
+// Set by cmd/credentialproxy/main.go from config.
+var AllowPrivateUpstreams bool
+
+// HTTPProvider implements provider.Provider for generic HTTP upstreams.
+type HTTPProvider struct{}
+
+func init() {
+	provider.Register("http", &HTTPProvider{})
+}
+
+func (p *HTTPProvider) Kind() string { return "http" }
+
+func (p *HTTPProvider) ParseUpload(contentType string, raw []byte) (*provider.UploadResult, error) {
+	return ParseConfig(contentType, raw)
+}
+
+// BuildSandboxConfig writes an endpoints file mapping each binding's display
+// name to its proxied URL, and sets the binding's env_var (if configured) to
+// that URL. The proxy token is embedded as basic-auth password so tools that
+// only take a registry URL (npm, pip, cargo) authenticate transparently.
+func (p *HTTPProvider) BuildSandboxConfig(
+	bindings []*provider.BindingMeta,
+	proxyToken string,
+	credproxyPublicURL string,
+) ([]*provider.SandboxConfigFile, error) {
+	base, err := url.Parse(strings.TrimSuffix(credproxyPublicURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid credproxy public URL: %w", err)
+	}
+
+	endpoints := make(map[string]string, len(bindings))
+	envVars := make(map[string]string)
+	for _, b := range bindings {
+		u := *base
+		u.User = url.UserPassword("sandbox", proxyToken)
+		u.Path = base.Path + "/http/" + b.ID + "/"
+		endpoints[b.DisplayName] = u.String()
+		if envVar, ok := b.PublicMeta["env_var"].(string); ok && envVar != "" {
+			envVars[envVar] = u.String()
+		}
+	}
+
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ordered := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		ordered = append(ordered, map[string]string{"name": name, "url": endpoints[name]})
+	}
+	data, err := json.MarshalIndent(ordered, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal endpoints: %w", err)
+	}
+	envVars["AGENTSERVER_HTTP_ENDPOINTS"] = "/var/run/agentserver/http-endpoints.json"
+
+	return []*provider.SandboxConfigFile{{
+		SubPath: "http-endpoints.json",
+		Mode:    0o644,
+		Content: data,
+		EnvVars: envVars,
+	}}, nil
+}
+
+func (p *HTTPProvider) ServeHTTP(w http.ResponseWriter, r *http.Request, b *provider.DecryptedBinding) {
+	serveHTTPProxy(w, r, b, AllowPrivateUpstreams)
+}
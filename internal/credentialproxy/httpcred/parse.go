@@ -0,0 +1,125 @@
+package httpcred
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/credentialproxy/provider"
+)
+
+var envVarPattern = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+// uploadConfig is the JSON document accepted for "http" bindings.
+type uploadConfig struct {
+	ServerURL  string `json:"server_url"`
+	AuthType   string `json:"auth_type"` // "bearer", "basic", or "header"
+	Token      string `json:"token"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	HeaderName string `json:"header_name"`
+	EnvVar     string `json:"env_var"`
+}
+
+// secret is the encrypted part of an "http" binding.
+type secret struct {
+	Token      string `json:"token,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	HeaderName string `json:"header_name,omitempty"`
+}
+
+// ParseConfig validates an uploaded http credential config and extracts the
+// fields to persist.
+func ParseConfig(_ string, raw []byte) (*provider.UploadResult, error) {
+	var cfg uploadConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	serverURL, err := validateServerURL(cfg.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var sec secret
+	switch cfg.AuthType {
+	case "bearer":
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("token is required for bearer auth")
+		}
+		sec.Token = cfg.Token
+	case "basic":
+		if cfg.Username == "" || cfg.Password == "" {
+			return nil, fmt.Errorf("username and password are required for basic auth")
+		}
+		sec.Username = cfg.Username
+		sec.Password = cfg.Password
+	case "header":
+		if cfg.HeaderName == "" || cfg.Token == "" {
+			return nil, fmt.Errorf("header_name and token are required for header auth")
+		}
+		name := http.CanonicalHeaderKey(cfg.HeaderName)
+		if name == "Host" || name == "Cookie" || strings.HasPrefix(name, "Proxy-") {
+			return nil, fmt.Errorf("header %q cannot carry credentials", cfg.HeaderName)
+		}
+		sec.HeaderName = name
+		sec.Token = cfg.Token
+	default:
+		return nil, fmt.Errorf("auth_type must be bearer, basic, or header")
+	}
+
+	publicMeta := map[string]any{}
+	if cfg.EnvVar != "" {
+		if !envVarPattern.MatchString(cfg.EnvVar) {
+			return nil, fmt.Errorf("invalid env_var %q", cfg.EnvVar)
+		}
+		publicMeta["env_var"] = cfg.EnvVar
+	}
+	if sec.HeaderName != "" {
+		publicMeta["header_name"] = sec.HeaderName
+	}
+
+	authSecret, err := json.Marshal(sec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal secret: %w", err)
+	}
+
+	u, _ := url.Parse(serverURL)
+	return &provider.UploadResult{
+		DisplayName: u.Host,
+		ServerURL:   serverURL,
+		PublicMeta:  publicMeta,
+		AuthType:    cfg.AuthType,
+		AuthSecret:  authSecret,
+	}, nil
+}
+
+func validateServerURL(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %w", server, err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("server URL must use https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("server URL %q has no host", server)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("server URL must not contain credentials; use the auth fields instead")
+	}
+
+	// Check for literal private IPs.
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if provider.IsPrivateIP(ip) {
+			return "", fmt.Errorf("server URL %q points to a private IP address", server)
+		}
+	}
+
+	return strings.TrimSuffix(server, "/"), nil
+}
@@ -1,33 +1,12 @@
 package k8s
 
-import "net"
+import (
+	"net"
 
-// privateRanges lists all RFC 1918, loopback, link-local, and metadata IP ranges.
-var privateRanges []*net.IPNet
-
-func init() {
-	for _, cidr := range []string{
-		"10.0.0.0/8",
-		"172.16.0.0/12",
-		"192.168.0.0/16",
-		"127.0.0.0/8",
-		"169.254.0.0/16",
-		"0.0.0.0/8",
-		"::1/128",
-		"fc00::/7",
-		"fe80::/10",
-	} {
-		_, ipNet, _ := net.ParseCIDR(cidr)
-		privateRanges = append(privateRanges, ipNet)
-	}
-}
+	"github.com/agentserver/agentserver/internal/credentialproxy/provider"
+)
 
 // IsPrivateIP reports whether ip falls in any private/reserved range.
 func IsPrivateIP(ip net.IP) bool {
-	for _, r := range privateRanges {
-		if r.Contains(ip) {
-			return true
-		}
-	}
-	return false
+	return provider.IsPrivateIP(ip)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/agentserver/agentserver/internal/credentialproxy/provider"
 	"github.com/agentserver/agentserver/internal/crypto"
+	"github.com/agentserver/agentserver/internal/secrets"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -71,13 +72,19 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract proxy token from Authorization header.
+	// Extract proxy token from Authorization header. Basic auth (token as
+	// password) is accepted for tools that only take credentials in a URL.
+	var proxyToken string
 	authHeader := r.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		proxyToken = strings.TrimPrefix(authHeader, "Bearer ")
+	} else if _, password, ok := r.BasicAuth(); ok {
+		proxyToken = password
+	}
+	if proxyToken == "" {
 		http.Error(w, "missing or invalid authorization", http.StatusUnauthorized)
 		return
 	}
-	proxyToken := strings.TrimPrefix(authHeader, "Bearer ")
 
 	// Validate proxy token.
 	start := time.Now()
@@ -111,6 +118,15 @@ func (s *Server) handleProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "credential decryption failed", http.StatusInternalServerError)
 		return
 	}
+	if secrets.IsRef(string(plaintext)) {
+		v, err := secrets.ResolveWorkspace(r.Context(), s.config.Secrets, s.config.WorkspaceSecretsPrefix, info.WorkspaceID, string(plaintext))
+		if err != nil {
+			s.logger.Error("credential secret lookup failed", "error", err, "binding_id", bid)
+			http.Error(w, "credential secret lookup failed", http.StatusBadGateway)
+			return
+		}
+		plaintext = []byte(v)
+	}
 
 	// Look up provider.
 	prov, err := provider.Lookup(kind)
@@ -0,0 +1,64 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* * * * fri-mon",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	// 2030-01-04 is a Friday.
+	fri := time.Date(2030, 1, 4, 18, 30, 0, 0, berlin)
+	for _, tc := range []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"0 19 * * mon-fri", fri, time.Date(2030, 1, 4, 19, 0, 0, 0, berlin)},
+		{"0 19 * * mon-fri", fri.Add(time.Hour), time.Date(2030, 1, 7, 19, 0, 0, 0, berlin)},
+		{"0 8 * * 1-5", fri, time.Date(2030, 1, 7, 8, 0, 0, 0, berlin)},
+		{"*/15 * * * *", fri.Add(time.Minute), time.Date(2030, 1, 4, 18, 45, 0, 0, berlin)},
+		{"30 18 * * *", fri, time.Date(2030, 1, 5, 18, 30, 0, 0, berlin)},
+		{"0 0 1 jan,jul *", fri, time.Date(2030, 7, 1, 0, 0, 0, 0, berlin)},
+		{"0 12 13 * 5", fri, time.Date(2030, 1, 11, 12, 0, 0, 0, berlin)}, // Friday or the 13th
+		{"0 9 * * 7", fri, time.Date(2030, 1, 6, 9, 0, 0, 0, berlin)},
+		// 02:30 does not exist on the spring-forward day; the next match is
+		// the following day.
+		{"30 2 * * *", time.Date(2030, 3, 30, 12, 0, 0, 0, berlin), time.Date(2030, 4, 1, 2, 30, 0, 0, berlin)},
+	} {
+		s, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.expr, err)
+		}
+		if got := s.Next(tc.from); !got.Equal(tc.want) {
+			t.Errorf("%q after %v = %v, want %v", tc.expr, tc.from, got, tc.want)
+		}
+	}
+
+	never, _ := Parse("0 0 30 2 *")
+	if got := never.Next(fri); !got.IsZero() {
+		t.Errorf("Feb 30 = %v, want zero", got)
+	}
+}
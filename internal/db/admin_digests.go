@@ -0,0 +1,223 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AdminDigest is a stored admin digest: the summary of one period, emailed
+// to the admins.
+type AdminDigest struct {
+	ID          int64           `json:"id"`
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end"`
+	Summary     json.RawMessage `json:"summary"`
+	CreatedAt   time.Time       `json:"created_at"`
+	SentAt      *time.Time      `json:"sent_at,omitempty"`
+}
+
+// DigestSandboxFailure is a sandbox left in a failed state, with the
+// reason recorded in its status message.
+type DigestSandboxFailure struct {
+	SandboxID   string `json:"sandbox_id"`
+	Name        string `json:"name"`
+	WorkspaceID string `json:"workspace_id"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+}
+
+// DigestActivity is the database side of an admin digest for a period.
+type DigestActivity struct {
+	UsersTotal         int64                  `json:"users_total"`
+	NewUsers           int64                  `json:"new_users"`
+	NewUserEmails      []string               `json:"new_user_emails"`
+	SandboxesByStatus  map[string]int64       `json:"sandboxes_by_status"`
+	SandboxesCreated   int64                  `json:"sandboxes_created"`
+	SandboxesRun       int64                  `json:"sandboxes_run"`
+	ComputeHours       float64                `json:"compute_hours"`
+	FailingSandboxes   []DigestSandboxFailure `json:"failing_sandboxes"`
+	FailedHookRuns     int64                  `json:"failed_hook_runs"`
+	FailedMigrations   int64                  `json:"failed_migrations"`
+	VolumesByWorkspace map[string]int64       `json:"-"`
+}
+
+// maxDigestListItems bounds the new users and failing sandboxes listed in
+// a digest.
+const maxDigestListItems = 50
+
+// AdminDigestActivity gathers user, sandbox and storage activity within
+// [since, until). Failing sandboxes and sandbox counts are as of now.
+func (db *DB) AdminDigestActivity(since, until time.Time) (*DigestActivity, error) {
+	a := &DigestActivity{
+		NewUserEmails:      []string{},
+		SandboxesByStatus:  make(map[string]int64),
+		FailingSandboxes:   []DigestSandboxFailure{},
+		VolumesByWorkspace: make(map[string]int64),
+	}
+	err := db.QueryRow(
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE created_at >= $1 AND created_at < $2) FROM users`,
+		since, until,
+	).Scan(&a.UsersTotal, &a.NewUsers)
+	if err != nil {
+		return nil, fmt.Errorf("digest users: %w", err)
+	}
+	if err := db.scanStrings(&a.NewUserEmails,
+		`SELECT email FROM users WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at LIMIT $3`,
+		since, until, maxDigestListItems); err != nil {
+		return nil, fmt.Errorf("digest new users: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT status, COUNT(*) FROM sandboxes GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("digest sandboxes by status: %w", err)
+	}
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("digest sandboxes by status: %w", err)
+		}
+		a.SandboxesByStatus[status] = n
+	}
+	rows.Close()
+
+	err = db.QueryRow(
+		`SELECT
+		   (SELECT COUNT(*) FROM sandboxes WHERE created_at >= $1 AND created_at < $2),
+		   (SELECT COUNT(*) FROM sandbox_hook_runs WHERE NOT success AND started_at >= $1 AND started_at < $2),
+		   (SELECT COUNT(*) FROM sandbox_migrations WHERE status = 'failed' AND created_at >= $1 AND created_at < $2)`,
+		since, until,
+	).Scan(&a.SandboxesCreated, &a.FailedHookRuns, &a.FailedMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("digest sandbox counts: %w", err)
+	}
+	err = db.QueryRow(
+		`SELECT COUNT(DISTINCT sandbox_id),
+		        COALESCE(SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(stopped_at, NOW()), $2) - GREATEST(started_at, $1)) / 3600), 0)
+		 FROM sandbox_runs
+		 WHERE started_at < $2 AND COALESCE(stopped_at, NOW()) > $1`,
+		since, until,
+	).Scan(&a.SandboxesRun, &a.ComputeHours)
+	if err != nil {
+		return nil, fmt.Errorf("digest sandbox runs: %w", err)
+	}
+
+	rows, err = db.Query(
+		`SELECT id, name, workspace_id, status, status_message FROM sandboxes
+		 WHERE status_message IS NOT NULL AND status_message <> ''
+		 ORDER BY created_at DESC LIMIT $1`, maxDigestListItems)
+	if err != nil {
+		return nil, fmt.Errorf("digest failing sandboxes: %w", err)
+	}
+	for rows.Next() {
+		var f DigestSandboxFailure
+		if err := rows.Scan(&f.SandboxID, &f.Name, &f.WorkspaceID, &f.Status, &f.Message); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("digest failing sandboxes: %w", err)
+		}
+		a.FailingSandboxes = append(a.FailingSandboxes, f)
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT workspace_id, COUNT(*) FROM workspace_volumes GROUP BY workspace_id`)
+	if err != nil {
+		return nil, fmt.Errorf("digest workspace volumes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var wsID string
+		var n int64
+		if err := rows.Scan(&wsID, &n); err != nil {
+			return nil, fmt.Errorf("digest workspace volumes: %w", err)
+		}
+		a.VolumesByWorkspace[wsID] = n
+	}
+	return a, rows.Err()
+}
+
+// scanStrings appends the single text column of a query's rows to dst.
+func (db *DB) scanStrings(dst *[]string, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return err
+		}
+		*dst = append(*dst, s)
+	}
+	return rows.Err()
+}
+
+// ListAdminEmails returns the email addresses of the admins.
+func (db *DB) ListAdminEmails() ([]string, error) {
+	emails := []string{}
+	if err := db.scanStrings(&emails,
+		`SELECT email FROM users WHERE role = 'admin' AND email <> '' ORDER BY created_at`); err != nil {
+		return nil, fmt.Errorf("list admin emails: %w", err)
+	}
+	return emails, nil
+}
+
+// CreateAdminDigest stores a digest. d.ID and d.CreatedAt are set from the
+// database.
+func (db *DB) CreateAdminDigest(d *AdminDigest) error {
+	err := db.QueryRow(
+		`INSERT INTO admin_digests (period_start, period_end, summary) VALUES ($1, $2, $3)
+		 RETURNING id, created_at`,
+		d.PeriodStart, d.PeriodEnd, []byte(d.Summary),
+	).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create admin digest: %w", err)
+	}
+	return nil
+}
+
+// MarkAdminDigestSent records that a digest was emailed.
+func (db *DB) MarkAdminDigestSent(id int64) error {
+	if _, err := db.Exec(`UPDATE admin_digests SET sent_at = NOW() WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("mark admin digest sent: %w", err)
+	}
+	return nil
+}
+
+// ListAdminDigests returns the newest digests, newest first.
+func (db *DB) ListAdminDigests(limit int) ([]*AdminDigest, error) {
+	rows, err := db.Query(
+		`SELECT id, period_start, period_end, summary, created_at, sent_at
+		 FROM admin_digests ORDER BY period_end DESC, id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list admin digests: %w", err)
+	}
+	defer rows.Close()
+
+	digests := []*AdminDigest{}
+	for rows.Next() {
+		d := &AdminDigest{}
+		var sentAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.PeriodStart, &d.PeriodEnd, &d.Summary, &d.CreatedAt, &sentAt); err != nil {
+			return nil, fmt.Errorf("scan admin digest: %w", err)
+		}
+		if sentAt.Valid {
+			d.SentAt = &sentAt.Time
+		}
+		digests = append(digests, d)
+	}
+	return digests, rows.Err()
+}
+
+// LatestAdminDigest returns the digest with the latest period, or nil if
+// there is none.
+func (db *DB) LatestAdminDigest() (*AdminDigest, error) {
+	digests, err := db.ListAdminDigests(1)
+	if err != nil || len(digests) == 0 {
+		return nil, err
+	}
+	return digests[0], nil
+}
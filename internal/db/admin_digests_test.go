@@ -0,0 +1,41 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAdminDigests(t *testing.T) {
+	d := newTestDB(t)
+	end := time.Now().Add(100 * 365 * 24 * time.Hour).Truncate(time.Second)
+	digest := &AdminDigest{PeriodStart: end.Add(-7 * 24 * time.Hour), PeriodEnd: end, Summary: json.RawMessage(`{"storage_bytes":42}`)}
+	if err := d.CreateAdminDigest(digest); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM admin_digests WHERE id = $1`, digest.ID) })
+
+	latest, err := d.LatestAdminDigest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || latest.ID != digest.ID || !latest.PeriodEnd.Equal(end) || latest.SentAt != nil {
+		t.Fatalf("LatestAdminDigest = %+v", latest)
+	}
+	var summary struct {
+		StorageBytes int64 `json:"storage_bytes"`
+	}
+	if err := json.Unmarshal(latest.Summary, &summary); err != nil || summary.StorageBytes != 42 {
+		t.Errorf("summary = %s, %v", latest.Summary, err)
+	}
+
+	if err := d.MarkAdminDigestSent(digest.ID); err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := d.LatestAdminDigest(); latest == nil || latest.SentAt == nil {
+		t.Errorf("sent_at not set: %+v", latest)
+	}
+	if _, err := d.AdminDigestActivity(end.Add(-7*24*time.Hour), end); err != nil {
+		t.Errorf("AdminDigestActivity: %v", err)
+	}
+}
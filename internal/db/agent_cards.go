@@ -100,16 +100,40 @@ func (db *DB) UpsertAgentCardFromCapabilities(sandboxID, workspaceID, displayNam
 
 // MarkStaleAgentCardsOffline marks agents as offline if their sandbox heartbeat is stale.
 func (db *DB) MarkStaleAgentCardsOffline(threshold time.Duration) (int64, error) {
-	result, err := db.Exec(
+	return db.MarkAgentCardsOfflineSince(time.Now().Add(-threshold))
+}
+
+// MarkAgentCardsOfflineSince marks agents offline whose sandbox has not sent
+// a heartbeat after cutoff.
+func (db *DB) MarkAgentCardsOfflineSince(cutoff time.Time) (int64, error) {
+	cards, err := db.MarkAgentCardsOffline(cutoff)
+	return int64(len(cards)), err
+}
+
+// MarkAgentCardsOffline is MarkAgentCardsOfflineSince returning the agents
+// it marked offline.
+func (db *DB) MarkAgentCardsOffline(cutoff time.Time) ([]AgentCard, error) {
+	rows, err := db.Query(
 		`UPDATE agent_cards SET agent_status = 'offline', updated_at = NOW()
 		 WHERE agent_status != 'offline'
 		   AND sandbox_id NOT IN (
-		     SELECT id FROM sandboxes WHERE last_heartbeat_at > NOW() - $1::interval
-		   )`,
-		threshold.String(),
+		     SELECT id FROM sandboxes WHERE last_heartbeat_at > $1
+		   )
+		 RETURNING sandbox_id, workspace_id, agent_type, display_name, description, card_json, agent_status, version, updated_at`,
+		cutoff,
 	)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected()
+	defer rows.Close()
+
+	var cards []AgentCard
+	for rows.Next() {
+		var c AgentCard
+		if err := rows.Scan(&c.SandboxID, &c.WorkspaceID, &c.AgentType, &c.DisplayName, &c.Description, &c.CardJSON, &c.AgentStatus, &c.Version, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, rows.Err()
 }
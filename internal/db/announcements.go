@@ -0,0 +1,160 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Announcement is a banner shown to users inside the product.
+type Announcement struct {
+	ID          string
+	WorkspaceID *string // nil for a global announcement
+	Title       string
+	Body        string
+	Severity    string   // info, warning or critical
+	Roles       []string // audience roles; empty for everyone
+	StartsAt    time.Time
+	EndsAt      *time.Time
+	CreatedBy   *string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+const announcementColumns = `a.id, a.workspace_id, a.title, a.body, a.severity, a.roles, a.starts_at, a.ends_at,
+	a.created_by, a.created_at, a.updated_at`
+
+func scanAnnouncement(row interface{ Scan(...interface{}) error }) (*Announcement, error) {
+	a := &Announcement{}
+	var workspaceID, createdBy sql.NullString
+	var endsAt sql.NullTime
+	var rolesJSON []byte
+	if err := row.Scan(&a.ID, &workspaceID, &a.Title, &a.Body, &a.Severity, &rolesJSON, &a.StartsAt, &endsAt,
+		&createdBy, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if workspaceID.Valid {
+		a.WorkspaceID = &workspaceID.String
+	}
+	if createdBy.Valid {
+		a.CreatedBy = &createdBy.String
+	}
+	if endsAt.Valid {
+		a.EndsAt = &endsAt.Time
+	}
+	if err := json.Unmarshal(rolesJSON, &a.Roles); err != nil {
+		return nil, fmt.Errorf("unmarshal roles: %w", err)
+	}
+	return a, nil
+}
+
+func marshalRoles(roles []string) ([]byte, error) {
+	if roles == nil {
+		roles = []string{}
+	}
+	return json.Marshal(roles)
+}
+
+func (db *DB) CreateAnnouncement(a *Announcement) error {
+	rolesJSON, err := marshalRoles(a.Roles)
+	if err != nil {
+		return fmt.Errorf("create announcement: marshal roles: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO announcements (id, workspace_id, title, body, severity, roles, starts_at, ends_at, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		a.ID, a.WorkspaceID, a.Title, a.Body, a.Severity, rolesJSON, a.StartsAt, a.EndsAt, a.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("create announcement: %w", err)
+	}
+	return nil
+}
+
+// UpdateAnnouncement replaces the content, audience and schedule of an
+// announcement. Its scope (workspace) doesn't change.
+func (db *DB) UpdateAnnouncement(a *Announcement) error {
+	rolesJSON, err := marshalRoles(a.Roles)
+	if err != nil {
+		return fmt.Errorf("update announcement: marshal roles: %w", err)
+	}
+	_, err = db.Exec(
+		`UPDATE announcements SET title = $2, body = $3, severity = $4, roles = $5, starts_at = $6, ends_at = $7,
+		   updated_at = NOW()
+		 WHERE id = $1`,
+		a.ID, a.Title, a.Body, a.Severity, rolesJSON, a.StartsAt, a.EndsAt,
+	)
+	if err != nil {
+		return fmt.Errorf("update announcement: %w", err)
+	}
+	return nil
+}
+
+// GetAnnouncement returns an announcement by ID, or nil if it doesn't exist.
+func (db *DB) GetAnnouncement(id string) (*Announcement, error) {
+	a, err := scanAnnouncement(db.QueryRow(`SELECT `+announcementColumns+` FROM announcements a WHERE a.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get announcement: %w", err)
+	}
+	return a, nil
+}
+
+func (db *DB) DeleteAnnouncement(id string) error {
+	_, err := db.Exec("DELETE FROM announcements WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("delete announcement: %w", err)
+	}
+	return nil
+}
+
+// ListAnnouncements returns the announcements of a workspace, or all
+// announcements when workspaceID is empty, including scheduled and ended
+// ones, newest first.
+func (db *DB) ListAnnouncements(workspaceID string) ([]*Announcement, error) {
+	return db.queryAnnouncements(
+		`SELECT `+announcementColumns+` FROM announcements a
+		 WHERE $1 = '' OR a.workspace_id = $1
+		 ORDER BY a.starts_at DESC`,
+		workspaceID,
+	)
+}
+
+// ListActiveAnnouncements returns the announcements currently shown to a
+// user: global ones targeting their platform role and those of workspaces
+// they are a member of targeting their workspace role. A non-empty
+// workspaceID limits workspace announcements to that workspace. Critical
+// announcements come first.
+func (db *DB) ListActiveAnnouncements(userID, platformRole, workspaceID string) ([]*Announcement, error) {
+	return db.queryAnnouncements(
+		`SELECT `+announcementColumns+` FROM announcements a
+		 LEFT JOIN workspace_members m ON m.workspace_id = a.workspace_id AND m.user_id = $1
+		 WHERE a.starts_at <= NOW() AND (a.ends_at IS NULL OR a.ends_at > NOW())
+		   AND ((a.workspace_id IS NULL AND (a.roles = '[]' OR a.roles @> jsonb_build_array($2::text)))
+		     OR (m.user_id IS NOT NULL AND (a.roles = '[]' OR a.roles @> jsonb_build_array(m.role))
+		         AND ($3 = '' OR a.workspace_id = $3)))
+		 ORDER BY CASE a.severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, a.starts_at DESC`,
+		userID, platformRole, workspaceID,
+	)
+}
+
+func (db *DB) queryAnnouncements(query string, args ...interface{}) ([]*Announcement, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []*Announcement
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan announcement: %w", err)
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, rows.Err()
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestListActiveAnnouncements(t *testing.T) {
+	d := newTestDB(t)
+	userID := uuid.NewString()
+	wsID := uuid.NewString()
+	otherWS := uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, ws := range []string{wsID, otherWS} {
+		if err := d.CreateWorkspace(ws, "announcements"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.AddWorkspaceMember(wsID, userID, "developer"); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	t.Cleanup(func() {
+		for _, id := range ids {
+			d.DeleteAnnouncement(id)
+		}
+		d.Exec(`DELETE FROM workspaces WHERE id IN ($1, $2)`, wsID, otherWS)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	add := func(title string, ws *string, roles []string, starts time.Time, ends *time.Time) {
+		a := &Announcement{ID: uuid.NewString(), WorkspaceID: ws, Title: title, Severity: "info", Roles: roles, StartsAt: starts, EndsAt: ends}
+		if err := d.CreateAnnouncement(a); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, a.ID)
+	}
+	add("ws-developers", &wsID, []string{"developer"}, past, nil)
+	add("ws-owners", &wsID, []string{"owner"}, past, nil)
+	add("other-ws", &otherWS, nil, past, nil)
+	add("ws-scheduled", &wsID, nil, future, nil)
+	add("ws-ended", &wsID, nil, past.Add(-time.Hour), &past)
+	add("admins-"+userID, nil, []string{"admin"}, past, nil)
+	add("everyone-"+userID, nil, nil, past, nil)
+
+	got, err := d.ListActiveAnnouncements(userID, "user", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]bool{}
+	for _, a := range got {
+		titles[a.Title] = true
+	}
+	if !titles["ws-developers"] || !titles["everyone-"+userID] {
+		t.Errorf("missing announcements addressed to the user: %v", titles)
+	}
+	for _, title := range []string{"ws-owners", "other-ws", "ws-scheduled", "ws-ended", "admins-" + userID} {
+		if titles[title] {
+			t.Errorf("%s shown to the user", title)
+		}
+	}
+
+	got, err = d.ListActiveAnnouncements(userID, "user", otherWS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range got {
+		if a.WorkspaceID != nil {
+			t.Errorf("workspace filter returned %s", a.Title)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// credentialPruneRules are the DELETEs run by PruneExpiredCredentials, keyed
+// by a metrics name. $1 is the cutoff: rows expired (or used, or revoked)
+// before it are removed.
+var credentialPruneRules = []struct {
+	name  string
+	query string
+}{
+	{"auth_tokens", "DELETE FROM auth_tokens WHERE expires_at < $1"},
+	{"agent_registration_codes", "DELETE FROM agent_registration_codes WHERE expires_at < $1 OR (used AND created_at < $1)"},
+	{"codex_remote_tokens", "DELETE FROM codex_remote_tokens WHERE expires_at < $1 OR revoked_at < $1"},
+	{"codex_access_tokens", "DELETE FROM codex_access_tokens WHERE expires_at < $1"},
+	{"codex_refresh_tokens", "DELETE FROM codex_refresh_tokens WHERE expires_at < $1"},
+	{"codex_pkce_requests", "DELETE FROM codex_pkce_requests WHERE expires_at < $1"},
+	{"codex_device_codes", "DELETE FROM codex_device_codes WHERE expires_at < $1"},
+	{"codex_agent_identities", "DELETE FROM codex_agent_identities WHERE expires_at < $1 OR revoked_at < $1"},
+	{"codex_agent_tasks", "DELETE FROM codex_agent_tasks WHERE expires_at < $1"},
+	{"sandbox_access_tokens", "DELETE FROM sandbox_access_tokens WHERE expires_at < $1"},
+	{"personal_access_tokens", "DELETE FROM personal_access_tokens WHERE expires_at < $1 OR revoked_at < $1"},
+}
+
+// PruneExpiredCredentials deletes expired tokens, used or expired agent
+// registration codes, and expired codex auth flows that ended before cutoff.
+// It returns the number of rows deleted per table; on error, the counts of
+// the tables pruned so far.
+func (db *DB) PruneExpiredCredentials(ctx context.Context, cutoff time.Time) (map[string]int64, error) {
+	deleted := make(map[string]int64, len(credentialPruneRules))
+	for _, rule := range credentialPruneRules {
+		res, err := db.ExecContext(ctx, rule.query, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("prune %s: %w", rule.name, err)
+		}
+		n, _ := res.RowsAffected()
+		deleted[rule.name] = n
+	}
+	return deleted, nil
+}
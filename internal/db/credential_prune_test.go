@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPruneExpiredCredentials(t *testing.T) {
+	d := newTestDB(t)
+	userID := uuid.NewString()
+	wsID := uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateWorkspace(wsID, "prune"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+
+	now := time.Now()
+	d.CreateToken("old-"+userID, userID, now.Add(-48*time.Hour))
+	d.CreateToken("fresh-"+userID, userID, now.Add(time.Hour))
+	d.CreateAgentRegistrationCode("expired-"+userID, userID, wsID, now.Add(-48*time.Hour))
+	d.CreateAgentRegistrationCode("live-"+userID, userID, wsID, now.Add(time.Hour))
+
+	deleted, err := d.PruneExpiredCredentials(context.Background(), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted["auth_tokens"] < 1 || deleted["agent_registration_codes"] < 1 {
+		t.Errorf("deleted = %v", deleted)
+	}
+	if id, _ := d.ValidateToken("fresh-" + userID); id != userID {
+		t.Error("unexpired token was pruned")
+	}
+	var n int
+	d.QueryRow(`SELECT COUNT(*) FROM agent_registration_codes WHERE user_id = $1`, userID).Scan(&n)
+	if n != 1 {
+		t.Errorf("registration codes left = %d, want 1", n)
+	}
+}
@@ -20,6 +20,20 @@ type DB struct {
 
 // Open connects to PostgreSQL and runs migrations.
 func Open(databaseURL string) (*DB, error) {
+	db, err := Connect(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("run migrations: %w", err)
+	}
+	return db, nil
+}
+
+// Connect connects to PostgreSQL without running migrations, for tools
+// that only inspect the database.
+func Connect(databaseURL string) (*DB, error) {
 	sqlDB, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
@@ -28,12 +42,7 @@ func Open(databaseURL string) (*DB, error) {
 		sqlDB.Close()
 		return nil, fmt.Errorf("ping database: %w", err)
 	}
-	db := &DB{DB: sqlDB}
-	if err := db.migrate(); err != nil {
-		sqlDB.Close()
-		return nil, fmt.Errorf("run migrations: %w", err)
-	}
-	return db, nil
+	return &DB{DB: sqlDB}, nil
 }
 
 func (db *DB) migrate() error {
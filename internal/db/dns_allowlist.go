@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type WorkspaceDNSAllowlist struct {
+	WorkspaceID string
+	Domains     []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetWorkspaceDNSAllowlist returns the DNS allowlist for a workspace, or nil
+// if DNS filtering is not enabled for it.
+func (db *DB) GetWorkspaceDNSAllowlist(workspaceID string) (*WorkspaceDNSAllowlist, error) {
+	a := &WorkspaceDNSAllowlist{}
+	var domainsJSON []byte
+	err := db.QueryRow(
+		`SELECT workspace_id, domains, created_at, updated_at
+		 FROM workspace_dns_allowlist WHERE workspace_id = $1`,
+		workspaceID,
+	).Scan(&a.WorkspaceID, &domainsJSON, &a.CreatedAt, &a.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get workspace dns allowlist: %w", err)
+	}
+	if err := json.Unmarshal(domainsJSON, &a.Domains); err != nil {
+		return nil, fmt.Errorf("get workspace dns allowlist: unmarshal domains: %w", err)
+	}
+	return a, nil
+}
+
+func (db *DB) SetWorkspaceDNSAllowlist(workspaceID string, domains []string) error {
+	if domains == nil {
+		domains = []string{}
+	}
+	domainsJSON, err := json.Marshal(domains)
+	if err != nil {
+		return fmt.Errorf("set workspace dns allowlist: marshal domains: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO workspace_dns_allowlist (workspace_id, domains, updated_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT (workspace_id) DO UPDATE SET
+		   domains = EXCLUDED.domains,
+		   updated_at = NOW()`,
+		workspaceID, domainsJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("set workspace dns allowlist: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteWorkspaceDNSAllowlist(workspaceID string) error {
+	_, err := db.Exec("DELETE FROM workspace_dns_allowlist WHERE workspace_id = $1", workspaceID)
+	if err != nil {
+		return fmt.Errorf("delete workspace dns allowlist: %w", err)
+	}
+	return nil
+}
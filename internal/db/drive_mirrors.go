@@ -0,0 +1,170 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Drive mirror sync results.
+const (
+	MirrorStatusOK       = "ok"
+	MirrorStatusConflict = "conflict" // local and remote changes don't merge
+	MirrorStatusError    = "error"
+	MirrorStatusSkipped  = "skipped" // no running sandbox mounts the drive
+)
+
+// DriveMirror keeps a directory of a workspace drive in sync with a branch
+// of a remote git repository.
+type DriveMirror struct {
+	ID              string     `json:"id"`
+	WorkspaceID     string     `json:"workspace_id"`
+	Path            string     `json:"path"` // relative to the drive root
+	RemoteURL       string     `json:"remote_url"`
+	Branch          string     `json:"branch"`
+	TokenSecret     string     `json:"token_secret,omitempty"` // workspace secret with the access token
+	IntervalSeconds int        `json:"interval_seconds"`
+	Enabled         bool       `json:"enabled"`
+	CreatedBy       string     `json:"created_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastSyncAt      *time.Time `json:"last_sync_at,omitempty"`
+	LastStatus      string     `json:"last_status,omitempty"`
+	LastMessage     string     `json:"last_message,omitempty"`
+	LastCommit      string     `json:"last_commit,omitempty"`
+}
+
+const driveMirrorColumns = `id, workspace_id, path, remote_url, branch, token_secret, interval_seconds, enabled,
+	created_by, created_at, last_sync_at, last_status, last_message, last_commit`
+
+func scanDriveMirror(row interface{ Scan(...interface{}) error }) (*DriveMirror, error) {
+	m := &DriveMirror{}
+	var tokenSecret, createdBy, status, message, commit sql.NullString
+	var lastSync sql.NullTime
+	if err := row.Scan(&m.ID, &m.WorkspaceID, &m.Path, &m.RemoteURL, &m.Branch, &tokenSecret, &m.IntervalSeconds,
+		&m.Enabled, &createdBy, &m.CreatedAt, &lastSync, &status, &message, &commit); err != nil {
+		return nil, err
+	}
+	m.TokenSecret, m.CreatedBy = tokenSecret.String, createdBy.String
+	m.LastStatus, m.LastMessage, m.LastCommit = status.String, message.String, commit.String
+	if lastSync.Valid {
+		m.LastSyncAt = &lastSync.Time
+	}
+	return m, nil
+}
+
+func (db *DB) queryDriveMirrors(query string, args ...interface{}) ([]*DriveMirror, error) {
+	rows, err := db.Query(`SELECT `+driveMirrorColumns+` FROM drive_mirrors `+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list drive mirrors: %w", err)
+	}
+	defer rows.Close()
+
+	mirrors := []*DriveMirror{}
+	for rows.Next() {
+		m, err := scanDriveMirror(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan drive mirror: %w", err)
+		}
+		mirrors = append(mirrors, m)
+	}
+	return mirrors, rows.Err()
+}
+
+// CreateDriveMirror stores a new mirror. It reports false if the workspace
+// already mirrors the path. m.CreatedAt is set from the database.
+func (db *DB) CreateDriveMirror(m *DriveMirror) (bool, error) {
+	err := db.QueryRow(
+		`INSERT INTO drive_mirrors (id, workspace_id, path, remote_url, branch, token_secret, interval_seconds, enabled, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 ON CONFLICT (workspace_id, path) DO NOTHING
+		 RETURNING created_at`,
+		m.ID, m.WorkspaceID, m.Path, m.RemoteURL, m.Branch, nullIfEmpty(m.TokenSecret), m.IntervalSeconds, m.Enabled,
+		nullIfEmpty(m.CreatedBy),
+	).Scan(&m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("create drive mirror: %w", err)
+	}
+	return true, nil
+}
+
+// UpdateDriveMirror replaces a mirror's remote, branch, token secret,
+// interval and enabled flag. The path can't change.
+func (db *DB) UpdateDriveMirror(m *DriveMirror) error {
+	_, err := db.Exec(
+		`UPDATE drive_mirrors SET remote_url = $2, branch = $3, token_secret = $4, interval_seconds = $5, enabled = $6
+		 WHERE id = $1`,
+		m.ID, m.RemoteURL, m.Branch, nullIfEmpty(m.TokenSecret), m.IntervalSeconds, m.Enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("update drive mirror: %w", err)
+	}
+	return nil
+}
+
+// GetDriveMirror returns a mirror of a workspace, or nil if not found.
+func (db *DB) GetDriveMirror(workspaceID, id string) (*DriveMirror, error) {
+	m, err := scanDriveMirror(db.QueryRow(
+		`SELECT `+driveMirrorColumns+` FROM drive_mirrors WHERE workspace_id = $1 AND id = $2`,
+		workspaceID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get drive mirror: %w", err)
+	}
+	return m, nil
+}
+
+// ListDriveMirrors returns a workspace's mirrors by path.
+func (db *DB) ListDriveMirrors(workspaceID string) ([]*DriveMirror, error) {
+	return db.queryDriveMirrors(`WHERE workspace_id = $1 ORDER BY path`, workspaceID)
+}
+
+// ListEnabledDriveMirrors returns the enabled mirrors of all workspaces.
+func (db *DB) ListEnabledDriveMirrors() ([]*DriveMirror, error) {
+	return db.queryDriveMirrors(`WHERE enabled ORDER BY last_sync_at NULLS FIRST, id`)
+}
+
+// DeleteDriveMirror removes a mirror. The directory and its .git are left
+// on the drive.
+func (db *DB) DeleteDriveMirror(workspaceID, id string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM drive_mirrors WHERE workspace_id = $1 AND id = $2`, workspaceID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete drive mirror: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ClaimDriveMirrorSync sets a mirror's last sync to at if it is still
+// prev, and reports whether it did; of several servers seeing the same due
+// sync, only one claims it.
+func (db *DB) ClaimDriveMirrorSync(id string, prev *time.Time, at time.Time) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE drive_mirrors SET last_sync_at = $3
+		 WHERE id = $1 AND last_sync_at IS NOT DISTINCT FROM $2`,
+		id, prev, at,
+	)
+	if err != nil {
+		return false, fmt.Errorf("claim drive mirror sync: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// RecordDriveMirrorResult stores the outcome of a sync. An empty commit
+// keeps the last one.
+func (db *DB) RecordDriveMirrorResult(id, status, message, commit string) error {
+	_, err := db.Exec(
+		`UPDATE drive_mirrors SET last_status = $2, last_message = $3, last_commit = COALESCE($4, last_commit)
+		 WHERE id = $1`,
+		id, status, nullIfEmpty(message), nullIfEmpty(commit),
+	)
+	if err != nil {
+		return fmt.Errorf("record drive mirror result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDriveMirrors(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "mirrors"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	m := &DriveMirror{ID: uuid.NewString(), WorkspaceID: wsID, Path: "docs", RemoteURL: "https://git.example.com/team/docs.git",
+		Branch: "main", TokenSecret: "GIT_TOKEN", IntervalSeconds: 600, Enabled: true, CreatedBy: "u-1"}
+	if ok, err := d.CreateDriveMirror(m); err != nil || !ok {
+		t.Fatalf("CreateDriveMirror = %v, %v", ok, err)
+	}
+	dup := *m
+	dup.ID = uuid.NewString()
+	if ok, err := d.CreateDriveMirror(&dup); err != nil || ok {
+		t.Errorf("second mirror of the same path = %v, %v", ok, err)
+	}
+
+	got, err := d.GetDriveMirror(wsID, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Path != "docs" || got.TokenSecret != "GIT_TOKEN" || got.IntervalSeconds != 600 || got.LastSyncAt != nil {
+		t.Fatalf("GetDriveMirror = %+v", got)
+	}
+	if other, err := d.GetDriveMirror(uuid.NewString(), m.ID); err != nil || other != nil {
+		t.Errorf("mirror visible from another workspace: %+v, %v", other, err)
+	}
+
+	// Only one of two servers that saw the same last sync claims the next.
+	at := time.Now().Truncate(time.Second)
+	if ok, err := d.ClaimDriveMirrorSync(m.ID, nil, at); err != nil || !ok {
+		t.Fatalf("first claim = %v, %v", ok, err)
+	}
+	if ok, _ := d.ClaimDriveMirrorSync(m.ID, nil, at.Add(time.Second)); ok {
+		t.Error("second claim of the same sync succeeded")
+	}
+
+	if err := d.RecordDriveMirrorResult(m.ID, MirrorStatusOK, "", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordDriveMirrorResult(m.ID, MirrorStatusConflict, "merge conflict in README.md", ""); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = d.GetDriveMirror(wsID, m.ID)
+	if got.LastStatus != MirrorStatusConflict || got.LastMessage == "" || got.LastCommit != "abc123" || got.LastSyncAt == nil {
+		t.Errorf("after sync: %+v", got)
+	}
+
+	got.Enabled, got.Branch, got.TokenSecret = false, "backup", ""
+	if err := d.UpdateDriveMirror(got); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.ListDriveMirrors(wsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Enabled || list[0].Branch != "backup" || list[0].TokenSecret != "" {
+		t.Errorf("ListDriveMirrors = %+v", list)
+	}
+	enabled, err := d.ListEnabledDriveMirrors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range enabled {
+		if e.ID == m.ID {
+			t.Error("disabled mirror listed as enabled")
+		}
+	}
+
+	if ok, err := d.DeleteDriveMirror(wsID, m.ID); err != nil || !ok {
+		t.Errorf("DeleteDriveMirror = %v, %v", ok, err)
+	}
+	if ok, _ := d.DeleteDriveMirror(wsID, m.ID); ok {
+		t.Error("mirror deleted twice")
+	}
+}
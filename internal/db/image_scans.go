@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ImageFinding is one vulnerability found in an image.
+type ImageFinding struct {
+	ID               string `json:"id"` // CVE or advisory ID
+	Severity         string `json:"severity"`
+	Package          string `json:"package"`
+	InstalledVersion string `json:"installed_version,omitempty"`
+	FixedVersion     string `json:"fixed_version,omitempty"`
+	Title            string `json:"title,omitempty"`
+}
+
+// ImageScan is the result of scanning a sandbox image.
+type ImageScan struct {
+	ID        int64
+	Image     string
+	Scanner   string
+	Status    string // completed or failed
+	Error     string
+	Critical  int
+	High      int
+	Medium    int
+	Low       int
+	Findings  []ImageFinding
+	ScannedAt time.Time
+}
+
+const imageScanColumns = `id, image, scanner, status, error, critical, high, medium, low, findings, scanned_at`
+
+func scanImageScan(row interface{ Scan(...interface{}) error }) (*ImageScan, error) {
+	s := &ImageScan{}
+	var scanErr sql.NullString
+	var findingsJSON []byte
+	if err := row.Scan(&s.ID, &s.Image, &s.Scanner, &s.Status, &scanErr, &s.Critical, &s.High, &s.Medium, &s.Low,
+		&findingsJSON, &s.ScannedAt); err != nil {
+		return nil, err
+	}
+	s.Error = scanErr.String
+	if err := json.Unmarshal(findingsJSON, &s.Findings); err != nil {
+		return nil, fmt.Errorf("unmarshal findings: %w", err)
+	}
+	return s, nil
+}
+
+// SaveImageScan records a scan result and sets its ID and time.
+func (db *DB) SaveImageScan(s *ImageScan) error {
+	findings := s.Findings
+	if findings == nil {
+		findings = []ImageFinding{}
+	}
+	findingsJSON, err := json.Marshal(findings)
+	if err != nil {
+		return fmt.Errorf("save image scan: marshal findings: %w", err)
+	}
+	err = db.QueryRow(
+		`INSERT INTO image_scans (image, scanner, status, error, critical, high, medium, low, findings)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+		 RETURNING id, scanned_at`,
+		s.Image, s.Scanner, s.Status, s.Error, s.Critical, s.High, s.Medium, s.Low, findingsJSON,
+	).Scan(&s.ID, &s.ScannedAt)
+	if err != nil {
+		return fmt.Errorf("save image scan: %w", err)
+	}
+	return nil
+}
+
+// LatestImageScan returns the most recent completed scan of an image, or
+// nil if it was never scanned successfully.
+func (db *DB) LatestImageScan(image string) (*ImageScan, error) {
+	s, err := scanImageScan(db.QueryRow(
+		`SELECT `+imageScanColumns+` FROM image_scans
+		 WHERE image = $1 AND status = 'completed'
+		 ORDER BY scanned_at DESC LIMIT 1`,
+		image,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest image scan: %w", err)
+	}
+	return s, nil
+}
+
+// ListLatestImageScans returns the most recent scan (completed or failed)
+// of every scanned image, ordered by image.
+func (db *DB) ListLatestImageScans() ([]*ImageScan, error) {
+	rows, err := db.Query(
+		`SELECT DISTINCT ON (image) ` + imageScanColumns + ` FROM image_scans
+		 ORDER BY image, scanned_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list image scans: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []*ImageScan
+	for rows.Next() {
+		s, err := scanImageScan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan image scan: %w", err)
+		}
+		scans = append(scans, s)
+	}
+	return scans, rows.Err()
+}
+
+// DeleteImageScansBefore deletes scan results older than cutoff, keeping
+// the latest scan and the latest completed scan of each image.
+func (db *DB) DeleteImageScansBefore(cutoff time.Time) (int64, error) {
+	res, err := db.Exec(
+		`DELETE FROM image_scans s
+		 WHERE s.scanned_at < $1
+		   AND s.id <> (SELECT id FROM image_scans l WHERE l.image = s.image ORDER BY scanned_at DESC LIMIT 1)
+		   AND s.id IS DISTINCT FROM (SELECT id FROM image_scans l WHERE l.image = s.image AND l.status = 'completed'
+		                              ORDER BY scanned_at DESC LIMIT 1)`,
+		cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete image scans: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestImageScans(t *testing.T) {
+	d := newTestDB(t)
+	image := "ghcr.io/test/" + uuid.NewString() + ":latest"
+	t.Cleanup(func() { d.Exec(`DELETE FROM image_scans WHERE image = $1`, image) })
+
+	if s, err := d.LatestImageScan(image); err != nil || s != nil {
+		t.Fatalf("LatestImageScan before scanning = %v, %v", s, err)
+	}
+
+	completed := &ImageScan{
+		Image: image, Scanner: "trivy", Status: "completed", Critical: 1, High: 2,
+		Findings: []ImageFinding{{ID: "CVE-2026-0001", Severity: "CRITICAL", Package: "openssl", FixedVersion: "3.0.99"}},
+	}
+	if err := d.SaveImageScan(completed); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SaveImageScan(&ImageScan{Image: image, Scanner: "trivy", Status: "failed", Error: "registry unreachable"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.LatestImageScan(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || s.ID != completed.ID || s.Critical != 1 || len(s.Findings) != 1 || s.Findings[0].Package != "openssl" {
+		t.Fatalf("LatestImageScan = %+v, want the completed scan", s)
+	}
+
+	scans, err := d.ListLatestImageScans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *ImageScan
+	for _, sc := range scans {
+		if sc.Image == image {
+			found = sc
+		}
+	}
+	if found == nil || found.Status != "failed" || found.Error != "registry unreachable" {
+		t.Errorf("ListLatestImageScans entry = %+v, want the failed scan", found)
+	}
+
+	// Both the latest scan and the latest completed scan survive pruning.
+	if _, err := d.DeleteImageScansBefore(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := d.LatestImageScan(image); s == nil {
+		t.Error("latest completed scan was pruned")
+	}
+}
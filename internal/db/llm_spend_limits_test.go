@@ -0,0 +1,67 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLLMSpendLimitWindowStart(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	monthStart := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	if got := (&LLMSpendLimit{}).WindowStart(now); !got.Equal(monthStart) {
+		t.Errorf("without reset: %v, want %v", got, monthStart)
+	}
+	reset := time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)
+	if got := (&LLMSpendLimit{ResetAt: &reset}).WindowStart(now); !got.Equal(reset) {
+		t.Errorf("after reset: %v, want %v", got, reset)
+	}
+	old := time.Date(2026, 9, 20, 0, 0, 0, 0, time.UTC)
+	if got := (&LLMSpendLimit{ResetAt: &old}).WindowStart(now); !got.Equal(monthStart) {
+		t.Errorf("reset last month: %v, want %v", got, monthStart)
+	}
+}
+
+func TestLLMSpendLimits(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "spend"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	if l, err := d.GetLLMSpendLimit(SpendScopeWorkspace, wsID); err != nil || l != nil {
+		t.Fatalf("before set: %+v, %v", l, err)
+	}
+	if ok, err := d.ResetLLMSpendLimit(SpendScopeWorkspace, wsID); err != nil || ok {
+		t.Fatalf("reset without limit: %v, %v", ok, err)
+	}
+	tokens, usd := int64(1000000), 12.5
+	if err := d.SetLLMSpendLimit(SpendScopeWorkspace, wsID, &tokens, &usd); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.ResetLLMSpendLimit(SpendScopeWorkspace, wsID); err != nil || !ok {
+		t.Fatalf("reset: %v, %v", ok, err)
+	}
+	// Changing the limit keeps the reset.
+	if err := d.SetLLMSpendLimit(SpendScopeWorkspace, wsID, nil, &usd); err != nil {
+		t.Fatal(err)
+	}
+	l, err := d.GetLLMSpendLimit(SpendScopeWorkspace, wsID)
+	if err != nil || l == nil {
+		t.Fatalf("get: %+v, %v", l, err)
+	}
+	if l.MaxTokensPerMonth != nil || l.MaxUSDPerMonth == nil || *l.MaxUSDPerMonth != usd || l.ResetAt == nil {
+		t.Errorf("limit = %+v", l)
+	}
+	if err := d.DeleteLLMSpendLimit(SpendScopeWorkspace, wsID); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := d.GetLLMSpendLimit(SpendScopeWorkspace, wsID); l != nil {
+		t.Errorf("after delete: %+v", l)
+	}
+	if _, err := d.GetLLMSpendLimit("sandbox", wsID); err == nil {
+		t.Error("invalid scope accepted")
+	}
+}
@@ -0,0 +1,9 @@
+-- Per-workspace DNS egress allowlist. When a row exists the workspace
+-- namespace runs a filtering resolver that only answers for the listed
+-- domains (and their subdomains); everything else gets NXDOMAIN.
+CREATE TABLE workspace_dns_allowlist (
+    workspace_id  TEXT PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    domains       JSONB NOT NULL DEFAULT '[]',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+-- Sandbox quarantine: an admin can isolate a sandbox (no network, no proxy
+-- traffic) while keeping its filesystem for forensics.
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMPTZ;
+
+-- Quarantine actions are kept after the sandbox is deleted, so no FK.
+CREATE TABLE sandbox_quarantine_events (
+    id            BIGSERIAL PRIMARY KEY,
+    sandbox_id    TEXT NOT NULL,
+    workspace_id  TEXT NOT NULL,
+    action        TEXT NOT NULL,   -- 'quarantine' | 'release'
+    reason        TEXT,
+    actor_id      TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_sandbox_quarantine_events_sandbox ON sandbox_quarantine_events(sandbox_id, created_at DESC);
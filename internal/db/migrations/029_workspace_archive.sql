@@ -0,0 +1,5 @@
+-- Workspace archival: an archived workspace has all sandboxes paused, rejects
+-- new sandboxes, and is hidden from default listings. archive_snapshots holds
+-- the names of drive snapshots taken at archive time (JSON array).
+ALTER TABLE workspaces ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE workspaces ADD COLUMN IF NOT EXISTS archive_snapshots JSONB;
@@ -0,0 +1,3 @@
+-- Human-readable detail for the current sandbox status, e.g. why workspace
+-- drive provisioning failed. Cleared on every status change.
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS status_message TEXT;
@@ -0,0 +1,10 @@
+-- Per-workspace dedicated node pool. When a row exists, new sandbox pods of
+-- the workspace get the node selector and tolerations below, so they only
+-- run on (and may run on tainted) dedicated nodes.
+CREATE TABLE workspace_node_pools (
+    workspace_id  TEXT PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    node_selector JSONB NOT NULL DEFAULT '{}',
+    tolerations   JSONB NOT NULL DEFAULT '[]',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
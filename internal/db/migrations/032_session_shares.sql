@@ -0,0 +1,18 @@
+-- Read-only snapshots of opencode session transcripts, served at a stable
+-- public URL (/share/{id}) that outlives the sandbox. Exporting the same
+-- session again refreshes the snapshot and keeps the URL.
+CREATE TABLE session_shares (
+    id            TEXT PRIMARY KEY,
+    workspace_id  TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    sandbox_id    TEXT NOT NULL, -- no FK: shares survive sandbox deletion
+    sandbox_name  TEXT NOT NULL DEFAULT '',
+    session_id    TEXT NOT NULL,
+    title         TEXT NOT NULL DEFAULT '',
+    transcript    JSONB NOT NULL,
+    created_by    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (sandbox_id, session_id)
+);
+
+CREATE INDEX idx_session_shares_workspace ON session_shares(workspace_id, created_at DESC);
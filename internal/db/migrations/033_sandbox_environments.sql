@@ -0,0 +1,14 @@
+-- Tool versions (node, python, claude CLI, opencode, ...) probed inside
+-- running sandboxes. initial_tools is the first capture, i.e. the sandbox
+-- as created from its image; the newest sandbox's initial_tools per type is
+-- the baseline other sandboxes are compared against.
+CREATE TABLE sandbox_environments (
+    sandbox_id        TEXT PRIMARY KEY REFERENCES sandboxes(id) ON DELETE CASCADE,
+    sandbox_type      TEXT NOT NULL,
+    tools             JSONB NOT NULL,
+    initial_tools     JSONB NOT NULL,
+    captured_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    first_captured_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_sandbox_environments_type ON sandbox_environments(sandbox_type, first_captured_at DESC);
@@ -0,0 +1,11 @@
+-- Per-workspace model policy for the LLM proxy: which models sandboxes may
+-- request and the model used when a request names none (or, with
+-- on_disallowed = 'rewrite', a disallowed one).
+CREATE TABLE workspace_model_policies (
+    workspace_id   TEXT PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    allowed_models JSONB NOT NULL DEFAULT '[]',
+    default_model  TEXT NOT NULL DEFAULT '',
+    on_disallowed  TEXT NOT NULL DEFAULT 'reject',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
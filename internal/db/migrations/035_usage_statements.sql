@@ -0,0 +1,33 @@
+-- Running intervals of sandboxes, for compute-hours in usage statements.
+-- A row is opened when a sandbox becomes running and closed when it leaves
+-- that state or is deleted. Rows outlive their sandbox.
+CREATE TABLE sandbox_runs (
+    id           BIGSERIAL PRIMARY KEY,
+    sandbox_id   TEXT NOT NULL,
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    cpu          INTEGER NOT NULL DEFAULT 0,  -- millicores
+    memory       BIGINT NOT NULL DEFAULT 0,   -- bytes
+    started_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    stopped_at   TIMESTAMPTZ
+);
+CREATE INDEX idx_sandbox_runs_workspace ON sandbox_runs(workspace_id, started_at);
+CREATE UNIQUE INDEX idx_sandbox_runs_open ON sandbox_runs(sandbox_id) WHERE stopped_at IS NULL;
+
+-- Monthly usage rollups per workspace. month is the first day of the month
+-- (UTC); regenerating a month replaces its statement.
+CREATE TABLE usage_statements (
+    workspace_id    TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    month           DATE NOT NULL,
+    input_tokens    BIGINT NOT NULL DEFAULT 0,
+    output_tokens   BIGINT NOT NULL DEFAULT 0,
+    cache_tokens    BIGINT NOT NULL DEFAULT 0,
+    llm_requests    BIGINT NOT NULL DEFAULT 0,
+    models          JSONB NOT NULL DEFAULT '[]',
+    compute_hours   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cpu_hours       DOUBLE PRECISION NOT NULL DEFAULT 0,   -- vCPU-hours
+    memory_gb_hours DOUBLE PRECISION NOT NULL DEFAULT 0,
+    storage_bytes   BIGINT NOT NULL DEFAULT 0,
+    generated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    emailed_at      TIMESTAMPTZ,
+    PRIMARY KEY (workspace_id, month)
+);
@@ -0,0 +1,8 @@
+-- Indexes for the credential prune job (expiry scans) and for auth lookups
+-- that otherwise scan whole tables as they grow.
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_expires_at ON auth_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_user_created ON auth_tokens (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_agent_registration_codes_expires_at ON agent_registration_codes (expires_at);
+CREATE INDEX IF NOT EXISTS idx_codex_remote_tokens_expires_at ON codex_remote_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS idx_codex_refresh_tokens_expires_at ON codex_refresh_tokens (expires_at);
+CREATE INDEX IF NOT EXISTS idx_codex_agent_identities_expires_at ON codex_agent_identities (expires_at);
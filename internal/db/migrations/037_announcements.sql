@@ -0,0 +1,18 @@
+-- In-product announcements (maintenance windows, policy changes). A NULL
+-- workspace_id is global; roles limits the audience to users with one of
+-- the roles (workspace roles for workspace announcements, platform roles for
+-- global ones), empty meaning everyone.
+CREATE TABLE announcements (
+    id           TEXT PRIMARY KEY,
+    workspace_id TEXT REFERENCES workspaces(id) ON DELETE CASCADE,
+    title        TEXT NOT NULL,
+    body         TEXT NOT NULL DEFAULT '',
+    severity     TEXT NOT NULL DEFAULT 'info', -- info|warning|critical
+    roles        JSONB NOT NULL DEFAULT '[]',
+    starts_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at      TIMESTAMPTZ,
+    created_by   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_announcements_workspace ON announcements(workspace_id, starts_at);
@@ -0,0 +1,4 @@
+-- Pinned sandboxes are exempt from idle pause and automatic cleanup, and
+-- can't be deleted until unpinned.
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMPTZ;
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS pinned_by TEXT;
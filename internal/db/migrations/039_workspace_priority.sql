@@ -0,0 +1,3 @@
+-- Scheduling priority tier of a workspace's sandboxes: mapped to a K8s
+-- PriorityClass at creation and used to order starts when they are gated.
+ALTER TABLE workspaces ADD COLUMN IF NOT EXISTS priority_tier TEXT NOT NULL DEFAULT 'normal';
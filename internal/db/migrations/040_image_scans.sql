@@ -0,0 +1,17 @@
+-- Vulnerability scan results of sandbox images, from the built-in scanner
+-- (Trivy/Grype) or submitted through the admin API.
+CREATE TABLE IF NOT EXISTS image_scans (
+    id          BIGSERIAL PRIMARY KEY,
+    image       TEXT NOT NULL,
+    scanner     TEXT NOT NULL,
+    status      TEXT NOT NULL,          -- completed, failed
+    error       TEXT,
+    critical    INTEGER NOT NULL DEFAULT 0,
+    high        INTEGER NOT NULL DEFAULT 0,
+    medium      INTEGER NOT NULL DEFAULT 0,
+    low         INTEGER NOT NULL DEFAULT 0,
+    findings    JSONB NOT NULL DEFAULT '[]',
+    scanned_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_scans_image ON image_scans (image, scanned_at DESC);
@@ -0,0 +1,21 @@
+-- Local agent tunnel traffic, flushed by sandbox-proxy on every heartbeat.
+-- The first columns describe the current connection; the *_total columns
+-- accumulate across connections for bandwidth accounting.
+CREATE TABLE IF NOT EXISTS tunnel_stats (
+    sandbox_id      TEXT PRIMARY KEY REFERENCES sandboxes(id) ON DELETE CASCADE,
+    connected       BOOLEAN NOT NULL DEFAULT FALSE,
+    connected_at    TIMESTAMPTZ,
+    bytes_in        BIGINT NOT NULL DEFAULT 0,
+    bytes_out       BIGINT NOT NULL DEFAULT 0,
+    active_streams  INTEGER NOT NULL DEFAULT 0,
+    requests        BIGINT NOT NULL DEFAULT 0,
+    request_errors  BIGINT NOT NULL DEFAULT 0,
+    avg_latency_ms  INTEGER NOT NULL DEFAULT 0,
+    bytes_in_total  BIGINT NOT NULL DEFAULT 0,
+    bytes_out_total BIGINT NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Per-agent tunnel bandwidth cap in bytes per second and direction; 0 uses
+-- the sandbox-proxy default (TUNNEL_BANDWIDTH_LIMIT).
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS tunnel_bandwidth_limit BIGINT NOT NULL DEFAULT 0;
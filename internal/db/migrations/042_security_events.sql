@@ -0,0 +1,28 @@
+-- Security-relevant events (failed logins, revoked token reuse, role and
+-- quota changes, impersonation). Kept after users and workspaces are
+-- deleted, so no FKs, and append-only: rows can be neither updated nor
+-- deleted.
+CREATE TABLE IF NOT EXISTS security_events (
+    id          BIGSERIAL PRIMARY KEY,
+    event_type  TEXT NOT NULL,
+    severity    TEXT NOT NULL,         -- info, warning, critical
+    actor_id    TEXT,
+    target_id   TEXT,
+    ip          TEXT,
+    details     JSONB NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_created ON security_events (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_security_events_type ON security_events (event_type, created_at DESC);
+
+CREATE OR REPLACE FUNCTION security_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS security_events_append_only ON security_events;
+CREATE TRIGGER security_events_append_only
+    BEFORE UPDATE OR DELETE ON security_events
+    FOR EACH ROW EXECUTE FUNCTION security_events_append_only();
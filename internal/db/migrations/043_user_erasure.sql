@@ -0,0 +1,29 @@
+-- GDPR erasure requests: a user asks for erasure and an admin approves or
+-- rejects it. Once the user is erased, user_id is replaced by the
+-- pseudonym used in the audit records and the email is cleared, so no FK.
+CREATE TABLE IF NOT EXISTS user_erasure_requests (
+    id             BIGSERIAL PRIMARY KEY,
+    user_id        TEXT NOT NULL,
+    email          TEXT,
+    reason         TEXT,
+    status         TEXT NOT NULL DEFAULT 'pending',  -- pending, rejected, completed
+    requested_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_by     TEXT,
+    decided_at     TIMESTAMPTZ,
+    decision_note  TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_erasure_requests_pending
+    ON user_erasure_requests (user_id) WHERE status = 'pending';
+
+-- Erasure pseudonymizes the actor and target of security events. The
+-- append-only trigger lets such updates through only in a transaction
+-- that sets agentserver.erasure; deletes stay forbidden.
+CREATE OR REPLACE FUNCTION security_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND current_setting('agentserver.erasure', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,13 @@
+-- Named snapshots of a sandbox's session data (home directory). ref is the
+-- backend's name for the copy: a VolumeSnapshot in the workspace namespace
+-- or a Docker volume.
+CREATE TABLE IF NOT EXISTS sandbox_snapshots (
+    id           TEXT PRIMARY KEY,
+    sandbox_id   TEXT NOT NULL REFERENCES sandboxes(id) ON DELETE CASCADE,
+    workspace_id TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    ref          TEXT NOT NULL,
+    created_by   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (sandbox_id, name)
+);
@@ -0,0 +1,11 @@
+-- Sandbox template bundles imported from other instances or the
+-- community. They are usable like policy file templates; bundle is the
+-- verified document as imported, signature included.
+CREATE TABLE IF NOT EXISTS template_bundles (
+    name        TEXT PRIMARY KEY,
+    version     TEXT,
+    bundle      JSONB NOT NULL,
+    signed_by   TEXT,                   -- trusted key ID, NULL if unsigned
+    imported_by TEXT,
+    imported_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
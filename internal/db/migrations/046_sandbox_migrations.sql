@@ -0,0 +1,21 @@
+-- Admin-triggered moves of a sandbox to another node pool. A migration is a
+-- job: status is running until it completes or fails, and phase records
+-- the step it is in. At most one migration per sandbox runs at a time.
+CREATE TABLE IF NOT EXISTS sandbox_migrations (
+    id           TEXT PRIMARY KEY,
+    sandbox_id   TEXT NOT NULL REFERENCES sandboxes(id) ON DELETE CASCADE,
+    target       JSONB NOT NULL DEFAULT '{}',
+    status       TEXT NOT NULL DEFAULT 'running',
+    phase        TEXT NOT NULL DEFAULT 'pending',
+    error        TEXT,
+    snapshot_id  TEXT,
+    requested_by TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS sandbox_migrations_running
+    ON sandbox_migrations (sandbox_id) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS sandbox_migrations_sandbox
+    ON sandbox_migrations (sandbox_id, created_at DESC);
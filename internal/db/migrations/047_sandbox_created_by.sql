@@ -0,0 +1,3 @@
+-- The user who created a sandbox, to attribute its LLM usage to. NULL for
+-- sandboxes created before this column and for local sandboxes.
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS created_by TEXT;
@@ -0,0 +1,18 @@
+-- Monthly LLM budgets per user and per workspace, enforced by the LLM proxy.
+-- NULL limits are unlimited. Spend counts from the start of the month (UTC)
+-- or reset_at, whichever is later.
+CREATE TABLE IF NOT EXISTS user_llm_spend_limits (
+    user_id              TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    max_tokens_per_month BIGINT,
+    max_usd_per_month    NUMERIC(14, 4),
+    reset_at             TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS workspace_llm_spend_limits (
+    workspace_id         TEXT PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    max_tokens_per_month BIGINT,
+    max_usd_per_month    NUMERIC(14, 4),
+    reset_at             TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
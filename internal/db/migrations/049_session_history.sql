@@ -0,0 +1,30 @@
+-- Exports of a sandbox's opencode session storage (a tar.gz of
+-- ~/.local/share/opencode/storage), taken periodically so conversations can
+-- be restored into another sandbox after the original is deleted or its
+-- image upgraded. Exports outlive their sandbox; the archive itself lives
+-- in the configured store (session_history_blobs or object storage) under
+-- object_key.
+CREATE TABLE IF NOT EXISTS session_history_exports (
+    id            TEXT PRIMARY KEY,
+    workspace_id  TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    sandbox_id    TEXT NOT NULL,
+    sandbox_name  TEXT NOT NULL DEFAULT '',
+    store         TEXT NOT NULL,
+    object_key    TEXT NOT NULL,
+    size_bytes    BIGINT NOT NULL,
+    sha256        TEXT NOT NULL, -- of the uncompressed tar
+    session_count INTEGER NOT NULL DEFAULT 0,
+    created_by    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS session_history_exports_sandbox
+    ON session_history_exports (sandbox_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS session_history_exports_workspace
+    ON session_history_exports (workspace_id, created_at DESC);
+
+-- Archive contents for the database store.
+CREATE TABLE IF NOT EXISTS session_history_blobs (
+    key  TEXT PRIMARY KEY,
+    data BYTEA NOT NULL
+);
@@ -0,0 +1,20 @@
+-- Workspace sandbox templates: standardized environments (image, resource
+-- sizes, startup command, env vars) that sandboxes are created from with
+-- template_id. cpu is in millicores and memory in bytes; NULL uses the
+-- workspace default.
+CREATE TABLE IF NOT EXISTS sandbox_templates (
+    id              TEXT PRIMARY KEY,
+    workspace_id    TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    description     TEXT NOT NULL DEFAULT '',
+    type            TEXT NOT NULL DEFAULT 'opencode',
+    image           TEXT NOT NULL DEFAULT '',
+    cpu             INTEGER,
+    memory          BIGINT,
+    startup_command TEXT NOT NULL DEFAULT '',
+    env             JSONB NOT NULL DEFAULT '{}',
+    created_by      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, name)
+);
@@ -0,0 +1,18 @@
+-- Post-start hooks of workspace sandbox templates: scripts run with exec in
+-- every sandbox created from the template each time it starts or resumes.
+ALTER TABLE sandbox_templates ADD COLUMN IF NOT EXISTS post_start_hooks JSONB NOT NULL DEFAULT '[]';
+
+-- The output of hook runs, newest kept per sandbox.
+CREATE TABLE IF NOT EXISTS sandbox_hook_runs (
+    id          BIGSERIAL PRIMARY KEY,
+    sandbox_id  TEXT NOT NULL REFERENCES sandboxes(id) ON DELETE CASCADE,
+    template_id TEXT NOT NULL,
+    hook        TEXT NOT NULL,
+    trigger     TEXT NOT NULL,
+    success     BOOLEAN NOT NULL,
+    error       TEXT NOT NULL DEFAULT '',
+    output      TEXT NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_sandbox_hook_runs_sandbox ON sandbox_hook_runs(sandbox_id, id DESC);
@@ -0,0 +1,16 @@
+-- Workspace secrets, shared by the workspace's members and materialized in
+-- its sandboxes at start as env vars (target 'env') or read-only files
+-- (target 'file'). value is AES-256-GCM encrypted with the server's
+-- WORKSPACE_SECRETS_KEY: nonce || ciphertext || tag.
+CREATE TABLE IF NOT EXISTS workspace_secrets (
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    target       TEXT NOT NULL DEFAULT 'env',
+    description  TEXT NOT NULL DEFAULT '',
+    value        BYTEA NOT NULL,
+    created_by   TEXT,
+    updated_by   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, name)
+);
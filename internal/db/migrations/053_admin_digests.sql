@@ -0,0 +1,12 @@
+-- Periodic admin digest emails. Each row is the summary of one period; the
+-- latest row's period_end is where the next digest starts, and its storage
+-- total is the baseline for storage growth.
+CREATE TABLE IF NOT EXISTS admin_digests (
+    id           BIGSERIAL PRIMARY KEY,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end   TIMESTAMPTZ NOT NULL,
+    summary      JSONB NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_admin_digests_period_end ON admin_digests(period_end DESC);
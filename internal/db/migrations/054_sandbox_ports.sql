@@ -0,0 +1,10 @@
+-- Extra sandbox ports exposed as preview URLs
+-- (port-{port}-{shortid}.{baseDomain}) through the sandbox proxy.
+CREATE TABLE IF NOT EXISTS sandbox_ports (
+    sandbox_id TEXT NOT NULL REFERENCES sandboxes(id) ON DELETE CASCADE,
+    port       INTEGER NOT NULL,
+    label      TEXT NOT NULL DEFAULT '',
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (sandbox_id, port)
+);
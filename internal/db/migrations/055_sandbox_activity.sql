@@ -0,0 +1,17 @@
+-- Active-usage intervals of sandboxes, for time tracking. The sandbox proxy
+-- records a user's requests as kind 'user' and the opencode events relayed
+-- to them as kind 'agent'; an interval is extended while marks keep coming
+-- within the idle gap and a new one opened after it. Rows outlive their
+-- sandbox, so the name is kept for reports.
+CREATE TABLE sandbox_activity (
+    id           BIGSERIAL PRIMARY KEY,
+    sandbox_id   TEXT NOT NULL,
+    sandbox_name TEXT NOT NULL DEFAULT '',
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    user_id      TEXT NOT NULL,
+    kind         TEXT NOT NULL,
+    started_at   TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_sandbox_activity_open ON sandbox_activity(sandbox_id, user_id, kind, last_seen_at);
+CREATE INDEX idx_sandbox_activity_workspace ON sandbox_activity(workspace_id, started_at);
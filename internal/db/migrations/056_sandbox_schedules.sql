@@ -0,0 +1,18 @@
+-- Cron schedules that pause or resume sandboxes, e.g. pausing weekdays at
+-- 19:00 and resuming at 08:00. A schedule without a sandbox applies to all
+-- cloud sandboxes of its workspace. last_run_at is the last time the
+-- scheduler acted on it, skipped a missed run or the schedule was changed,
+-- so a run is not repeated across restarts or replicas.
+CREATE TABLE sandbox_schedules (
+    id           TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    sandbox_id   TEXT REFERENCES sandboxes(id) ON DELETE CASCADE,
+    action       TEXT NOT NULL,  -- pause or resume
+    cron         TEXT NOT NULL,
+    timezone     TEXT NOT NULL DEFAULT 'UTC',
+    enabled      BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_run_at  TIMESTAMPTZ
+);
+CREATE INDEX idx_sandbox_schedules_workspace ON sandbox_schedules(workspace_id);
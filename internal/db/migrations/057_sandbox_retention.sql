@@ -0,0 +1,15 @@
+-- Deletion of sandboxes paused for longer than the retention period of
+-- their workspace. paused_retention overrides the server default, in
+-- seconds; 0 keeps paused sandboxes forever.
+ALTER TABLE workspace_quotas ADD COLUMN IF NOT EXISTS paused_retention INTEGER;
+
+-- Admin holds that keep a paused sandbox from deletion, indefinitely or
+-- until hold_until, and when the deletion warning was last sent, for the
+-- pause at warned_paused_at.
+CREATE TABLE sandbox_retention (
+    sandbox_id       TEXT PRIMARY KEY REFERENCES sandboxes(id) ON DELETE CASCADE,
+    held             BOOLEAN NOT NULL DEFAULT FALSE,
+    hold_until       TIMESTAMPTZ,
+    warned_at        TIMESTAMPTZ,
+    warned_paused_at TIMESTAMPTZ
+);
@@ -0,0 +1,23 @@
+-- Git mirrors of workspace drive directories. A mirror keeps the directory
+-- path (relative to the drive root) in sync with the branch of a remote
+-- repository: local changes are committed and pushed, remote ones merged.
+-- token_secret names the workspace secret holding the HTTPS access token.
+-- last_sync_at is claimed before each sync, so a sync runs once across
+-- replicas.
+CREATE TABLE drive_mirrors (
+    id               TEXT PRIMARY KEY,
+    workspace_id     TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    path             TEXT NOT NULL,
+    remote_url       TEXT NOT NULL,
+    branch           TEXT NOT NULL DEFAULT 'main',
+    token_secret     TEXT,
+    interval_seconds INTEGER NOT NULL DEFAULT 900,
+    enabled          BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by       TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_sync_at     TIMESTAMPTZ,
+    last_status      TEXT,           -- ok, conflict, error or skipped
+    last_message     TEXT,
+    last_commit      TEXT,
+    UNIQUE (workspace_id, path)
+);
@@ -0,0 +1,24 @@
+-- Short-lived tokens scoped to one sandbox, for CI jobs that must run a
+-- command or fetch artifacts without holding a user's token. Only the
+-- SHA-256 of the token is stored. operations lists what the token allows:
+-- "exec" runs command (the only command it can run), "artifacts" downloads
+-- files under artifacts_path. max_uses = 0 means unlimited until expiry.
+-- Tokens go with their sandbox and with the user who minted them.
+CREATE TABLE sandbox_access_tokens (
+    id             TEXT PRIMARY KEY,
+    sandbox_id     TEXT NOT NULL REFERENCES sandboxes(id) ON DELETE CASCADE,
+    workspace_id   TEXT NOT NULL,
+    token_hash     TEXT NOT NULL UNIQUE,
+    operations     TEXT[] NOT NULL,
+    command        TEXT,
+    artifacts_path TEXT,
+    expires_at     TIMESTAMPTZ NOT NULL,
+    max_uses       INTEGER NOT NULL DEFAULT 0,
+    uses           INTEGER NOT NULL DEFAULT 0,
+    created_by     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at   TIMESTAMPTZ,
+    revoked_at     TIMESTAMPTZ
+);
+
+CREATE INDEX idx_sandbox_access_tokens_sandbox ON sandbox_access_tokens (sandbox_id, created_at DESC);
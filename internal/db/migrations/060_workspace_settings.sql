@@ -0,0 +1,12 @@
+-- Per-workspace defaults for new sandboxes, set by workspace owners. Unlike
+-- workspace_quotas (admin limits), these only seed what a create request
+-- leaves out: the sandbox type, CPU (millicores), memory (bytes) and idle
+-- timeout (seconds). NULL falls back to the system default.
+CREATE TABLE workspace_settings (
+    workspace_id         TEXT PRIMARY KEY REFERENCES workspaces(id) ON DELETE CASCADE,
+    default_sandbox_type TEXT,
+    default_cpu          INTEGER,
+    default_memory       BIGINT,
+    default_idle_timeout INTEGER,
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,19 @@
+-- Personal access tokens: named, scoped, expiring API credentials a user
+-- creates for CI jobs and CLIs, sent as "Authorization: Bearer". Only the
+-- SHA-256 of the token is stored; prefix is its first characters, shown so
+-- users can tell their tokens apart. scopes holds read, write and admin.
+CREATE TABLE personal_access_tokens (
+    id           TEXT PRIMARY KEY,
+    user_id      TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    prefix       TEXT NOT NULL,
+    scopes       TEXT[] NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    last_used_ip TEXT,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX idx_personal_access_tokens_user ON personal_access_tokens (user_id, created_at DESC);
@@ -0,0 +1,24 @@
+-- The final state of deleted sandboxes: who deleted them, when and why,
+-- their last status and their compute totals from sandbox_runs. Rows
+-- outlive the sandbox and its workspace (no foreign keys) and are pruned
+-- after 90 days. deleted_by is NULL when the server deleted the sandbox.
+CREATE TABLE sandbox_tombstones (
+    sandbox_id      TEXT PRIMARY KEY,
+    workspace_id    TEXT NOT NULL,
+    name            TEXT NOT NULL,
+    type            TEXT NOT NULL,
+    created_by      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL,
+    deleted_by      TEXT,
+    deleted_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reason          TEXT NOT NULL,
+    final_status    TEXT NOT NULL,
+    cpu             INTEGER NOT NULL DEFAULT 0,  -- millicores
+    memory          BIGINT NOT NULL DEFAULT 0,   -- bytes
+    compute_hours   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    cpu_hours       DOUBLE PRECISION NOT NULL DEFAULT 0,   -- vCPU-hours
+    memory_gb_hours DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_sandbox_tombstones_workspace ON sandbox_tombstones (workspace_id, deleted_at DESC);
+CREATE INDEX idx_sandbox_tombstones_deleted ON sandbox_tombstones (deleted_at);
@@ -0,0 +1,5 @@
+-- Impersonation sessions: login tokens an admin issued to act as another
+-- user for support. impersonated_by is the admin; the token's user_id is
+-- the impersonated user. They don't count towards the user's session
+-- limit and go with the admin's account.
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS impersonated_by TEXT REFERENCES users(id) ON DELETE CASCADE;
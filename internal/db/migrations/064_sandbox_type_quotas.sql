@@ -0,0 +1,4 @@
+-- Per-type sandbox limits of a workspace, e.g. {"openclaw": 2}, on top of
+-- max_sandboxes. A type's limit replaces the server default for that type;
+-- 0 is unlimited. NULL keeps the server defaults.
+ALTER TABLE workspace_quotas ADD COLUMN IF NOT EXISTS max_sandboxes_by_type JSONB;
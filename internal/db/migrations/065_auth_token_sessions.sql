@@ -0,0 +1,9 @@
+-- Login sessions: an ID to list and revoke them by without exposing the
+-- token, and the client each was last used from (updated at most once a
+-- minute).
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS id TEXT NOT NULL DEFAULT gen_random_uuid()::text;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMPTZ;
+ALTER TABLE auth_tokens ADD COLUMN IF NOT EXISTS last_used_ip TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_auth_tokens_id ON auth_tokens (id);
@@ -0,0 +1,4 @@
+-- Disabled users: set by an admin (or an IdP sync through the admin API).
+-- Their sessions and tokens are rejected and they can't log in until they
+-- are enabled again.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
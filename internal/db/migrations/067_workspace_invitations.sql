@@ -0,0 +1,21 @@
+-- Invitations to join a workspace, addressed to an email. Only the hash of
+-- the invite token is stored. invited_by and accepted_by are audit
+-- columns, pseudonymized on user erasure.
+CREATE TABLE workspace_invitations (
+    id           TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    email        TEXT NOT NULL,
+    role         TEXT NOT NULL,
+    token_hash   TEXT NOT NULL UNIQUE,
+    invited_by   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    accepted_at  TIMESTAMPTZ,
+    accepted_by  TEXT,
+    revoked_at   TIMESTAMPTZ
+);
+
+-- At most one open invitation per workspace and email.
+CREATE UNIQUE INDEX idx_workspace_invitations_open
+    ON workspace_invitations (workspace_id, lower(email))
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
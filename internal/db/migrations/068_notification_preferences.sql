@@ -0,0 +1,10 @@
+-- Notification kinds a user turned on or off per delivery channel. A
+-- missing row means on.
+CREATE TABLE notification_preferences (
+    user_id    TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL,
+    channel    TEXT NOT NULL,
+    enabled    BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, kind, channel)
+);
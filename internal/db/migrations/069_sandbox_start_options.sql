@@ -0,0 +1,3 @@
+-- Start options of a sandbox waiting for a storage or start retry, without
+-- credentials, so the retry survives a server restart. NULL otherwise.
+ALTER TABLE sandboxes ADD COLUMN IF NOT EXISTS start_options JSONB;
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// WorkspaceModelPolicy restricts the models a workspace's sandboxes may use
+// through the LLM proxy.
+type WorkspaceModelPolicy struct {
+	WorkspaceID   string
+	AllowedModels []string // path.Match patterns; empty allows all
+	DefaultModel  string   // used when a request names no model
+	OnDisallowed  string   // "reject" or "rewrite" (to DefaultModel)
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// GetWorkspaceModelPolicy returns the model policy of a workspace, or nil if
+// it has none.
+func (db *DB) GetWorkspaceModelPolicy(workspaceID string) (*WorkspaceModelPolicy, error) {
+	p := &WorkspaceModelPolicy{}
+	var modelsJSON []byte
+	err := db.QueryRow(
+		`SELECT workspace_id, allowed_models, default_model, on_disallowed, created_at, updated_at
+		 FROM workspace_model_policies WHERE workspace_id = $1`,
+		workspaceID,
+	).Scan(&p.WorkspaceID, &modelsJSON, &p.DefaultModel, &p.OnDisallowed, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get workspace model policy: %w", err)
+	}
+	if err := json.Unmarshal(modelsJSON, &p.AllowedModels); err != nil {
+		return nil, fmt.Errorf("get workspace model policy: unmarshal allowed models: %w", err)
+	}
+	return p, nil
+}
+
+func (db *DB) SetWorkspaceModelPolicy(workspaceID string, allowedModels []string, defaultModel, onDisallowed string) error {
+	if allowedModels == nil {
+		allowedModels = []string{}
+	}
+	modelsJSON, err := json.Marshal(allowedModels)
+	if err != nil {
+		return fmt.Errorf("set workspace model policy: marshal allowed models: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO workspace_model_policies (workspace_id, allowed_models, default_model, on_disallowed, updated_at)
+		 VALUES ($1, $2, $3, $4, NOW())
+		 ON CONFLICT (workspace_id) DO UPDATE SET
+		   allowed_models = EXCLUDED.allowed_models,
+		   default_model = EXCLUDED.default_model,
+		   on_disallowed = EXCLUDED.on_disallowed,
+		   updated_at = NOW()`,
+		workspaceID, modelsJSON, defaultModel, onDisallowed,
+	)
+	if err != nil {
+		return fmt.Errorf("set workspace model policy: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteWorkspaceModelPolicy(workspaceID string) error {
+	_, err := db.Exec("DELETE FROM workspace_model_policies WHERE workspace_id = $1", workspaceID)
+	if err != nil {
+		return fmt.Errorf("delete workspace model policy: %w", err)
+	}
+	return nil
+}
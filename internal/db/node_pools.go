@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type NodeToleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"`
+}
+
+type WorkspaceNodePool struct {
+	WorkspaceID  string
+	NodeSelector map[string]string
+	Tolerations  []NodeToleration
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// GetWorkspaceNodePool returns the dedicated node pool of a workspace, or nil
+// if its sandboxes may be scheduled on any node.
+func (db *DB) GetWorkspaceNodePool(workspaceID string) (*WorkspaceNodePool, error) {
+	p := &WorkspaceNodePool{}
+	var selectorJSON, tolerationsJSON []byte
+	err := db.QueryRow(
+		`SELECT workspace_id, node_selector, tolerations, created_at, updated_at
+		 FROM workspace_node_pools WHERE workspace_id = $1`,
+		workspaceID,
+	).Scan(&p.WorkspaceID, &selectorJSON, &tolerationsJSON, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get workspace node pool: %w", err)
+	}
+	if err := json.Unmarshal(selectorJSON, &p.NodeSelector); err != nil {
+		return nil, fmt.Errorf("get workspace node pool: unmarshal node selector: %w", err)
+	}
+	if err := json.Unmarshal(tolerationsJSON, &p.Tolerations); err != nil {
+		return nil, fmt.Errorf("get workspace node pool: unmarshal tolerations: %w", err)
+	}
+	return p, nil
+}
+
+func (db *DB) SetWorkspaceNodePool(workspaceID string, nodeSelector map[string]string, tolerations []NodeToleration) error {
+	if nodeSelector == nil {
+		nodeSelector = map[string]string{}
+	}
+	if tolerations == nil {
+		tolerations = []NodeToleration{}
+	}
+	selectorJSON, err := json.Marshal(nodeSelector)
+	if err != nil {
+		return fmt.Errorf("set workspace node pool: marshal node selector: %w", err)
+	}
+	tolerationsJSON, err := json.Marshal(tolerations)
+	if err != nil {
+		return fmt.Errorf("set workspace node pool: marshal tolerations: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO workspace_node_pools (workspace_id, node_selector, tolerations, updated_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (workspace_id) DO UPDATE SET
+		   node_selector = EXCLUDED.node_selector,
+		   tolerations = EXCLUDED.tolerations,
+		   updated_at = NOW()`,
+		workspaceID, selectorJSON, tolerationsJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("set workspace node pool: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) DeleteWorkspaceNodePool(workspaceID string) error {
+	_, err := db.Exec("DELETE FROM workspace_node_pools WHERE workspace_id = $1", workspaceID)
+	if err != nil {
+		return fmt.Errorf("delete workspace node pool: %w", err)
+	}
+	return nil
+}
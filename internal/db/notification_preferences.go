@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// NotificationPreference turns a notification kind on or off for a user on
+// one delivery channel. Without one, the kind is on.
+type NotificationPreference struct {
+	Kind      string    `json:"kind"`
+	Channel   string    `json:"channel"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListNotificationPreferences returns the preferences a user has set.
+func (db *DB) ListNotificationPreferences(userID string) ([]NotificationPreference, error) {
+	rows, err := db.Query(
+		`SELECT kind, channel, enabled, updated_at FROM notification_preferences
+		 WHERE user_id = $1 ORDER BY kind, channel`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := []NotificationPreference{}
+	for rows.Next() {
+		var p NotificationPreference
+		if err := rows.Scan(&p.Kind, &p.Channel, &p.Enabled, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan notification preference: %w", err)
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+// SetNotificationPreferences stores a user's preferences, replacing those
+// of the same kind and channel.
+func (db *DB) SetNotificationPreferences(userID string, prefs []NotificationPreference) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("set notification preferences: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, p := range prefs {
+		if _, err := tx.Exec(
+			`INSERT INTO notification_preferences (user_id, kind, channel, enabled)
+			 VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (user_id, kind, channel) DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW()`,
+			userID, p.Kind, p.Channel, p.Enabled,
+		); err != nil {
+			return fmt.Errorf("set notification preference %s/%s: %w", p.Kind, p.Channel, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// NotificationEnabled reports whether a user wants notifications of kind
+// over channel: true unless they turned it off.
+func (db *DB) NotificationEnabled(userID, kind, channel string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(
+		`SELECT enabled FROM notification_preferences WHERE user_id = $1 AND kind = $2 AND channel = $3`,
+		userID, kind, channel,
+	).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get notification preference: %w", err)
+	}
+	return enabled, nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	d := newTestDB(t)
+	userID := uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	if ok, err := d.NotificationEnabled(userID, "idle_pause", "email"); err != nil || !ok {
+		t.Fatalf("NotificationEnabled without a preference = %v, %v; want true", ok, err)
+	}
+	if err := d.SetNotificationPreferences(userID, []NotificationPreference{
+		{Kind: "idle_pause", Channel: "email", Enabled: false},
+		{Kind: "quota_exceeded", Channel: "webhook", Enabled: false},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := d.NotificationEnabled(userID, "idle_pause", "email"); err != nil || ok {
+		t.Errorf("NotificationEnabled = %v, %v; want false", ok, err)
+	}
+	if ok, err := d.NotificationEnabled(userID, "idle_pause", "webhook"); err != nil || !ok {
+		t.Errorf("other channel: NotificationEnabled = %v, %v; want true", ok, err)
+	}
+
+	// Setting again updates in place.
+	if err := d.SetNotificationPreferences(userID, []NotificationPreference{{Kind: "idle_pause", Channel: "email", Enabled: true}}); err != nil {
+		t.Fatal(err)
+	}
+	prefs, err := d.ListNotificationPreferences(userID)
+	if err != nil || len(prefs) != 2 {
+		t.Fatalf("ListNotificationPreferences = %+v, %v", prefs, err)
+	}
+	if p := prefs[0]; p.Kind != "idle_pause" || p.Channel != "email" || !p.Enabled {
+		t.Errorf("prefs[0] = %+v", p)
+	}
+	if p := prefs[1]; p.Kind != "quota_exceeded" || p.Enabled {
+		t.Errorf("prefs[1] = %+v", p)
+	}
+}
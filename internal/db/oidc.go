@@ -50,3 +50,25 @@ func (db *DB) UpdateOIDCIdentityEmail(provider, subject, email string) error {
 	}
 	return nil
 }
+
+// ListOIDCIdentitiesByUser returns the OIDC identities linked to a user.
+func (db *DB) ListOIDCIdentitiesByUser(userID string) ([]*OIDCIdentity, error) {
+	rows, err := db.Query(
+		"SELECT provider, subject, user_id, email, created_at FROM oidc_identities WHERE user_id = $1 ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list oidc identities: %w", err)
+	}
+	defer rows.Close()
+
+	var identities []*OIDCIdentity
+	for rows.Next() {
+		oi := &OIDCIdentity{}
+		if err := rows.Scan(&oi.Provider, &oi.Subject, &oi.UserID, &oi.Email, &oi.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan oidc identity: %w", err)
+		}
+		identities = append(identities, oi)
+	}
+	return identities, rows.Err()
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Personal access token scopes.
+const (
+	TokenScopeRead  = "read"  // GET and HEAD requests
+	TokenScopeWrite = "write" // all other requests
+	TokenScopeAdmin = "admin" // the admin API, for admins
+)
+
+// PersonalAccessToken is an API credential a user created. The token itself
+// is never stored, only its hash.
+type PersonalAccessToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const personalAccessTokenColumns = `id, user_id, name, prefix, scopes, expires_at, created_at, last_used_at, last_used_ip, revoked_at`
+
+func scanPersonalAccessToken(row interface{ Scan(...interface{}) error }) (*PersonalAccessToken, error) {
+	t := &PersonalAccessToken{}
+	var scopes pq.StringArray
+	var lastUsed, revoked sql.NullTime
+	var lastIP sql.NullString
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &t.ExpiresAt, &t.CreatedAt,
+		&lastUsed, &lastIP, &revoked); err != nil {
+		return nil, err
+	}
+	t.Scopes = []string(scopes)
+	t.LastUsedIP = lastIP.String
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		t.RevokedAt = &revoked.Time
+	}
+	return t, nil
+}
+
+// CreatePersonalAccessToken stores a new token under the hash of its
+// secret. t.CreatedAt is set from the database.
+func (db *DB) CreatePersonalAccessToken(t *PersonalAccessToken, tokenHash string) error {
+	err := db.QueryRow(
+		`INSERT INTO personal_access_tokens (id, user_id, name, token_hash, prefix, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING created_at`,
+		t.ID, t.UserID, t.Name, tokenHash, t.Prefix, pq.Array(t.Scopes), t.ExpiresAt,
+	).Scan(&t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create personal access token: %w", err)
+	}
+	return nil
+}
+
+// ValidatePersonalAccessToken returns the unexpired, unrevoked token with
+// the given hash, or nil. Tokens of disabled users are invalid.
+func (db *DB) ValidatePersonalAccessToken(tokenHash string) (*PersonalAccessToken, error) {
+	t, err := scanPersonalAccessToken(db.QueryRow(
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens
+		 WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		   AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = personal_access_tokens.user_id AND u.disabled_at IS NOT NULL)`,
+		tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("validate personal access token: %w", err)
+	}
+	return t, nil
+}
+
+// TouchPersonalAccessToken records a use of a token from ip. Uses less
+// than a minute after the last recorded one are not written, so busy
+// tokens don't cost a write per request.
+func (db *DB) TouchPersonalAccessToken(id, ip string) error {
+	_, err := db.Exec(
+		`UPDATE personal_access_tokens SET last_used_at = NOW(), last_used_ip = $2
+		 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip IS DISTINCT FROM $2)`,
+		id, nullIfEmpty(ip))
+	if err != nil {
+		return fmt.Errorf("touch personal access token: %w", err)
+	}
+	return nil
+}
+
+// ListPersonalAccessTokens returns a user's tokens, newest first.
+func (db *DB) ListPersonalAccessTokens(userID string) ([]*PersonalAccessToken, error) {
+	rows, err := db.Query(
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens
+		 WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list personal access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*PersonalAccessToken{}
+	for rows.Next() {
+		t, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan personal access token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// CountActivePersonalAccessTokens returns how many unexpired, unrevoked
+// tokens a user has.
+func (db *DB) CountActivePersonalAccessTokens(userID string) (int, error) {
+	var n int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM personal_access_tokens
+		 WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()`, userID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count personal access tokens: %w", err)
+	}
+	return n, nil
+}
+
+// RevokePersonalAccessToken revokes a token of a user. It reports false if
+// there is no such token or it was already revoked.
+func (db *DB) RevokePersonalAccessToken(userID, id string) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE personal_access_tokens SET revoked_at = NOW()
+		 WHERE user_id = $1 AND id = $2 AND revoked_at IS NULL`,
+		userID, id)
+	if err != nil {
+		return false, fmt.Errorf("revoke personal access token: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
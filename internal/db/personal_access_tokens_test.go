@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	d := newTestDB(t)
+	userID := uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	tok := &PersonalAccessToken{
+		ID: uuid.NewString(), UserID: userID, Name: "ci", Prefix: "pat_12345678",
+		Scopes: []string{TokenScopeRead}, ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := d.CreatePersonalAccessToken(tok, "pat-hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	expired := &PersonalAccessToken{
+		ID: uuid.NewString(), UserID: userID, Name: "old", Prefix: "pat_87654321",
+		Scopes: []string{TokenScopeWrite}, ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	if err := d.CreatePersonalAccessToken(expired, "pat-hash-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := d.ValidatePersonalAccessToken("pat-hash-1")
+	if err != nil || got == nil || got.UserID != userID || !got.HasScope(TokenScopeRead) || got.HasScope(TokenScopeWrite) {
+		t.Fatalf("ValidatePersonalAccessToken = %+v, %v", got, err)
+	}
+	if got, _ := d.ValidatePersonalAccessToken("pat-hash-2"); got != nil {
+		t.Error("expired token accepted")
+	}
+	if n, err := d.CountActivePersonalAccessTokens(userID); err != nil || n != 1 {
+		t.Errorf("CountActivePersonalAccessTokens = %d, %v; want 1", n, err)
+	}
+
+	if err := d.TouchPersonalAccessToken(tok.ID, "203.0.113.7"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.ListPersonalAccessTokens(userID)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListPersonalAccessTokens = %+v, %v", list, err)
+	}
+	for _, l := range list {
+		if l.ID == tok.ID && (l.LastUsedAt == nil || l.LastUsedIP != "203.0.113.7") {
+			t.Errorf("last use not recorded: %+v", l)
+		}
+	}
+
+	if ok, err := d.RevokePersonalAccessToken(uuid.NewString(), tok.ID); err != nil || ok {
+		t.Errorf("revoked another user's token: %v, %v", ok, err)
+	}
+	if ok, err := d.RevokePersonalAccessToken(userID, tok.ID); err != nil || !ok {
+		t.Fatalf("RevokePersonalAccessToken = %v, %v", ok, err)
+	}
+	if ok, _ := d.RevokePersonalAccessToken(userID, tok.ID); ok {
+		t.Error("token revoked twice")
+	}
+	if got, _ := d.ValidatePersonalAccessToken("pat-hash-1"); got != nil {
+		t.Error("revoked token accepted")
+	}
+}
@@ -64,6 +64,47 @@ func (db *DB) CreateSandboxProxyToken(token, sandboxID, workspaceID string) erro
 	return nil
 }
 
+// RotateSandboxTokens replaces a sandbox's proxy, opencode and openclaw
+// tokens; empty ones are left unchanged. The proxy_tokens row is replaced
+// in the same transaction, so the old proxy token stops validating.
+func (db *DB) RotateSandboxTokens(sandboxID, proxyToken, opencodeToken, openclawToken string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("rotate sandbox tokens: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE sandboxes
+		    SET proxy_token = COALESCE(NULLIF($2, ''), proxy_token),
+		        opencode_token = COALESCE(NULLIF($3, ''), opencode_token),
+		        openclaw_token = COALESCE(NULLIF($4, ''), openclaw_token)
+		  WHERE id = $1`,
+		sandboxID, proxyToken, opencodeToken, openclawToken,
+	)
+	if err != nil {
+		return fmt.Errorf("rotate sandbox tokens: %w", err)
+	}
+	if proxyToken != "" {
+		if _, err := tx.Exec(
+			`DELETE FROM proxy_tokens WHERE sandbox_id = $1 AND token_type = 'sandbox'`, sandboxID,
+		); err != nil {
+			return fmt.Errorf("delete sandbox proxy token: %w", err)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO proxy_tokens (token, token_type, sandbox_id, workspace_id)
+			 SELECT $2, 'sandbox', id, workspace_id FROM sandboxes WHERE id = $1`,
+			sandboxID, proxyToken,
+		); err != nil {
+			return fmt.Errorf("insert sandbox proxy token: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("rotate sandbox tokens: %w", err)
+	}
+	return nil
+}
+
 // GetOrCreateWorkspaceToken returns the workspace's persistent proxy token,
 // creating one if none exists. Idempotent: concurrent callers race-free
 // thanks to the unique index on (workspace_id) WHERE token_type='workspace'.
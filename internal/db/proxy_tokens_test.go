@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRotateSandboxTokens(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "rotate"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	oldProxy := "proxy-" + uuid.NewString()
+	if err := d.CreateSandbox(sbxID, wsID, "rotate", "opencode", "", "old-pw", oldProxy, "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	newProxy := "proxy-" + uuid.NewString()
+	if err := d.RotateSandboxTokens(sbxID, newProxy, "new-pw", ""); err != nil {
+		t.Fatal(err)
+	}
+	sbx, err := d.GetSandbox(sbxID)
+	if err != nil || sbx == nil {
+		t.Fatalf("GetSandbox = %v, %v", sbx, err)
+	}
+	if sbx.ProxyToken.String != newProxy || sbx.OpencodeToken.String != "new-pw" || sbx.OpenclawToken.String != "" {
+		t.Errorf("tokens = %q, %q, %q", sbx.ProxyToken.String, sbx.OpencodeToken.String, sbx.OpenclawToken.String)
+	}
+	if pt, err := d.GetProxyToken(oldProxy); err != nil || pt != nil {
+		t.Errorf("old proxy token = %+v, %v; want gone", pt, err)
+	}
+	pt, err := d.GetProxyToken(newProxy)
+	if err != nil || pt == nil || pt.SandboxID.String != sbxID || pt.WorkspaceID != wsID || pt.TokenType != ProxyTokenSandbox {
+		t.Errorf("new proxy token = %+v, %v", pt, err)
+	}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// SandboxQuarantineEvent records a quarantine or release action.
+type SandboxQuarantineEvent struct {
+	ID          int64
+	SandboxID   string
+	WorkspaceID string
+	Action      string // "quarantine" or "release"
+	Reason      *string
+	ActorID     *string
+	CreatedAt   time.Time
+}
+
+// SetSandboxQuarantined marks (or clears) a sandbox's quarantine flag and
+// records the action in one transaction.
+func (db *DB) SetSandboxQuarantined(sandboxID, workspaceID string, quarantined bool, reason, actorID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("set sandbox quarantined: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	action := "release"
+	query := "UPDATE sandboxes SET quarantined_at = NULL WHERE id = $1"
+	if quarantined {
+		action = "quarantine"
+		query = "UPDATE sandboxes SET quarantined_at = NOW() WHERE id = $1"
+	}
+	if _, err := tx.Exec(query, sandboxID); err != nil {
+		return fmt.Errorf("set sandbox quarantined: %w", err)
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO sandbox_quarantine_events (sandbox_id, workspace_id, action, reason, actor_id)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		sandboxID, workspaceID, action, nullIfEmpty(reason), nullIfEmpty(actorID),
+	); err != nil {
+		return fmt.Errorf("record quarantine event: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (db *DB) ListSandboxQuarantineEvents(sandboxID string) ([]*SandboxQuarantineEvent, error) {
+	rows, err := db.Query(
+		`SELECT id, sandbox_id, workspace_id, action, reason, actor_id, created_at
+		 FROM sandbox_quarantine_events WHERE sandbox_id = $1
+		 ORDER BY created_at DESC`,
+		sandboxID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox quarantine events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*SandboxQuarantineEvent
+	for rows.Next() {
+		e := &SandboxQuarantineEvent{}
+		if err := rows.Scan(&e.ID, &e.SandboxID, &e.WorkspaceID, &e.Action, &e.Reason, &e.ActorID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan sandbox quarantine event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
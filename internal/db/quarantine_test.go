@@ -0,0 +1,76 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSetSandboxQuarantined(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "quarantine"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM sandbox_quarantine_events WHERE sandbox_id = $1`, sbxID)
+	})
+	if err := d.CreateSandbox(sbxID, wsID, "suspect", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	d.Exec(`UPDATE sandboxes SET status = 'running', last_activity_at = NOW() - INTERVAL '2 hours' WHERE id = $1`, sbxID)
+	idle := func() bool {
+		sandboxes, err := d.ListIdleSandboxes(60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range sandboxes {
+			if s.ID == sbxID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := d.SetSandboxQuarantined(sbxID, wsID, true, "crypto miner", "admin-1"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := d.GetSandbox(sbxID); s == nil || !s.QuarantinedAt.Valid {
+		t.Fatal("quarantined_at not set")
+	}
+	// Left running for forensics, not paused for idleness.
+	if idle() {
+		t.Error("quarantined sandbox listed as idle")
+	}
+
+	if err := d.SetSandboxQuarantined(sbxID, wsID, false, "", "admin-2"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := d.GetSandbox(sbxID); s == nil || s.QuarantinedAt.Valid {
+		t.Error("quarantined_at not cleared")
+	}
+	if !idle() {
+		t.Error("released sandbox not listed as idle")
+	}
+
+	events, err := d.ListSandboxQuarantineEvents(sbxID)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("ListSandboxQuarantineEvents = %d events, %v; want 2", len(events), err)
+	}
+	if e := events[0]; e.Action != "release" || e.Reason != nil || e.ActorID == nil || *e.ActorID != "admin-2" {
+		t.Errorf("newest event = %+v", e)
+	}
+	if e := events[1]; e.Action != "quarantine" || e.Reason == nil || *e.Reason != "crypto miner" || e.WorkspaceID != wsID {
+		t.Errorf("oldest event = %+v", e)
+	}
+
+	// Events outlive the sandbox.
+	if err := d.DeleteSandbox(sbxID); err != nil {
+		t.Fatal(err)
+	}
+	if events, err := d.ListSandboxQuarantineEvents(sbxID); err != nil || len(events) != 2 {
+		t.Errorf("after delete: %d events, %v", len(events), err)
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -21,7 +22,11 @@ type WorkspaceQuota struct {
 	MaxTotalCPU      *int   // millicores
 	MaxTotalMemory   *int64 // bytes
 	MaxDriveSize     *int64 // bytes
-	UpdatedAt        time.Time
+	PausedRetention  *int   // seconds; 0 keeps paused sandboxes forever
+	// MaxSandboxesByType overrides the per-type sandbox limits; nil keeps
+	// the server defaults.
+	MaxSandboxesByType map[string]int
+	UpdatedAt          time.Time
 }
 
 func (db *DB) GetSystemSetting(key string) (string, error) {
@@ -111,6 +116,29 @@ func (db *DB) CountSandboxesByWorkspace(workspaceID string) (int, error) {
 	return count, nil
 }
 
+// CountSandboxesByWorkspaceType returns the number of sandboxes of each
+// type in a workspace.
+func (db *DB) CountSandboxesByWorkspaceType(workspaceID string) (map[string]int, error) {
+	rows, err := db.Query(
+		"SELECT type, COUNT(*) FROM sandboxes WHERE workspace_id = $1 GROUP BY type",
+		workspaceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("count sandboxes by workspace type: %w", err)
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var typ string
+		var n int
+		if err := rows.Scan(&typ, &n); err != nil {
+			return nil, fmt.Errorf("scan sandbox count: %w", err)
+		}
+		counts[typ] = n
+	}
+	return counts, rows.Err()
+}
+
 // SumWorkspaceSandboxResources returns the total CPU (millicores) and memory (bytes)
 // allocated by non-offline sandboxes in a workspace.
 func (db *DB) SumWorkspaceSandboxResources(workspaceID string) (cpuMillis int64, memBytes int64, err error) {
@@ -127,28 +155,43 @@ func (db *DB) SumWorkspaceSandboxResources(workspaceID string) (cpuMillis int64,
 
 func (db *DB) GetWorkspaceQuota(workspaceID string) (*WorkspaceQuota, error) {
 	q := &WorkspaceQuota{}
+	var byType []byte
 	err := db.QueryRow(
 		`SELECT workspace_id, max_sandboxes, max_sandbox_cpu, max_sandbox_memory, max_idle_timeout,
-		        max_total_cpu, max_total_memory, max_drive_size, updated_at
+		        max_total_cpu, max_total_memory, max_drive_size, paused_retention, max_sandboxes_by_type, updated_at
 		 FROM workspace_quotas WHERE workspace_id = $1`,
 		workspaceID,
 	).Scan(&q.WorkspaceID, &q.MaxSandboxes, &q.MaxSandboxCPU, &q.MaxSandboxMemory, &q.MaxIdleTimeout,
-		&q.MaxTotalCPU, &q.MaxTotalMemory, &q.MaxDriveSize, &q.UpdatedAt)
+		&q.MaxTotalCPU, &q.MaxTotalMemory, &q.MaxDriveSize, &q.PausedRetention, &byType, &q.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("get workspace quota: %w", err)
 	}
+	if byType != nil {
+		if err := json.Unmarshal(byType, &q.MaxSandboxesByType); err != nil {
+			return nil, fmt.Errorf("get workspace quota: parse max_sandboxes_by_type: %w", err)
+		}
+	}
 	return q, nil
 }
 
 func (db *DB) SetWorkspaceQuota(workspaceID string, maxSandboxes *int,
-	maxSandboxCPU *int, maxSandboxMemory *int64, maxIdleTimeout *int, maxTotalCPU *int, maxTotalMemory *int64, maxDriveSize *int64) error {
+	maxSandboxCPU *int, maxSandboxMemory *int64, maxIdleTimeout *int, maxTotalCPU *int, maxTotalMemory *int64, maxDriveSize *int64, pausedRetention *int,
+	maxSandboxesByType map[string]int) error {
+	var byType []byte
+	if maxSandboxesByType != nil {
+		b, err := json.Marshal(maxSandboxesByType)
+		if err != nil {
+			return fmt.Errorf("set workspace quota: marshal max_sandboxes_by_type: %w", err)
+		}
+		byType = b
+	}
 	_, err := db.Exec(
 		`INSERT INTO workspace_quotas (workspace_id, max_sandboxes, max_sandbox_cpu, max_sandbox_memory,
-		   max_idle_timeout, max_total_cpu, max_total_memory, max_drive_size, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		   max_idle_timeout, max_total_cpu, max_total_memory, max_drive_size, paused_retention, max_sandboxes_by_type, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
 		 ON CONFLICT (workspace_id) DO UPDATE SET
 		   max_sandboxes = EXCLUDED.max_sandboxes,
 		   max_sandbox_cpu = EXCLUDED.max_sandbox_cpu,
@@ -157,9 +200,11 @@ func (db *DB) SetWorkspaceQuota(workspaceID string, maxSandboxes *int,
 		   max_total_cpu = EXCLUDED.max_total_cpu,
 		   max_total_memory = EXCLUDED.max_total_memory,
 		   max_drive_size = EXCLUDED.max_drive_size,
+		   paused_retention = EXCLUDED.paused_retention,
+		   max_sandboxes_by_type = EXCLUDED.max_sandboxes_by_type,
 		   updated_at = NOW()`,
 		workspaceID, maxSandboxes, maxSandboxCPU, maxSandboxMemory, maxIdleTimeout,
-		maxTotalCPU, maxTotalMemory, maxDriveSize,
+		maxTotalCPU, maxTotalMemory, maxDriveSize, pausedRetention, byType,
 	)
 	if err != nil {
 		return fmt.Errorf("set workspace quota: %w", err)
@@ -174,3 +219,118 @@ func (db *DB) DeleteWorkspaceQuota(workspaceID string) error {
 	}
 	return nil
 }
+
+// LLM spend limit scopes.
+const (
+	SpendScopeUser      = "user"
+	SpendScopeWorkspace = "workspace"
+)
+
+// spendLimitTables maps spend limit scopes to their table and key column.
+var spendLimitTables = map[string][2]string{
+	SpendScopeUser:      {"user_llm_spend_limits", "user_id"},
+	SpendScopeWorkspace: {"workspace_llm_spend_limits", "workspace_id"},
+}
+
+// LLMSpendLimit is the monthly LLM budget of a user or workspace. Nil limits
+// are unlimited.
+type LLMSpendLimit struct {
+	MaxTokensPerMonth *int64
+	MaxUSDPerMonth    *float64
+	ResetAt           *time.Time
+	UpdatedAt         time.Time
+}
+
+// WindowStart returns when the current budget window began: the start of
+// the month (UTC) of now, or the last reset if later.
+func (l *LLMSpendLimit) WindowStart(now time.Time) time.Time {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if l.ResetAt != nil && l.ResetAt.After(start) {
+		start = *l.ResetAt
+	}
+	return start
+}
+
+func spendLimitTable(scope string) (table, key string, err error) {
+	t, ok := spendLimitTables[scope]
+	if !ok {
+		return "", "", fmt.Errorf("invalid spend limit scope %q", scope)
+	}
+	return t[0], t[1], nil
+}
+
+// GetLLMSpendLimit returns the spend limit of a user or workspace (scope),
+// or nil if it has none.
+func (db *DB) GetLLMSpendLimit(scope, id string) (*LLMSpendLimit, error) {
+	table, key, err := spendLimitTable(scope)
+	if err != nil {
+		return nil, err
+	}
+	l := &LLMSpendLimit{}
+	var resetAt sql.NullTime
+	err = db.QueryRow(
+		`SELECT max_tokens_per_month, max_usd_per_month, reset_at, updated_at
+		 FROM `+table+` WHERE `+key+` = $1`,
+		id,
+	).Scan(&l.MaxTokensPerMonth, &l.MaxUSDPerMonth, &resetAt, &l.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get llm spend limit: %w", err)
+	}
+	if resetAt.Valid {
+		l.ResetAt = &resetAt.Time
+	}
+	return l, nil
+}
+
+// SetLLMSpendLimit sets the spend limit of a user or workspace, keeping its
+// last reset.
+func (db *DB) SetLLMSpendLimit(scope, id string, maxTokens *int64, maxUSD *float64) error {
+	table, key, err := spendLimitTable(scope)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO `+table+` (`+key+`, max_tokens_per_month, max_usd_per_month, updated_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (`+key+`) DO UPDATE SET
+		   max_tokens_per_month = EXCLUDED.max_tokens_per_month,
+		   max_usd_per_month = EXCLUDED.max_usd_per_month,
+		   updated_at = NOW()`,
+		id, maxTokens, maxUSD,
+	)
+	if err != nil {
+		return fmt.Errorf("set llm spend limit: %w", err)
+	}
+	return nil
+}
+
+// DeleteLLMSpendLimit removes the spend limit of a user or workspace.
+func (db *DB) DeleteLLMSpendLimit(scope, id string) error {
+	table, key, err := spendLimitTable(scope)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM `+table+` WHERE `+key+` = $1`, id); err != nil {
+		return fmt.Errorf("delete llm spend limit: %w", err)
+	}
+	return nil
+}
+
+// ResetLLMSpendLimit restarts counting spend against a limit now. It
+// reports false if there is no limit.
+func (db *DB) ResetLLMSpendLimit(scope, id string) (bool, error) {
+	table, key, err := spendLimitTable(scope)
+	if err != nil {
+		return false, err
+	}
+	res, err := db.Exec(`UPDATE `+table+` SET reset_at = NOW(), updated_at = NOW() WHERE `+key+` = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("reset llm spend limit: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
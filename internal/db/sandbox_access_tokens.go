@@ -0,0 +1,139 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Operations a sandbox access token can allow.
+const (
+	AccessOpExec      = "exec"      // run the token's command
+	AccessOpArtifacts = "artifacts" // download files under the artifacts path
+)
+
+// SandboxAccessToken is a short-lived credential scoped to one sandbox and
+// a few operations on it. The token itself is never stored, only its hash.
+type SandboxAccessToken struct {
+	ID            string     `json:"id"`
+	SandboxID     string     `json:"sandbox_id"`
+	WorkspaceID   string     `json:"workspace_id"`
+	Operations    []string   `json:"operations"`
+	Command       string     `json:"command,omitempty"`
+	ArtifactsPath string     `json:"artifacts_path,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	MaxUses       int        `json:"max_uses"` // 0: unlimited
+	Uses          int        `json:"uses"`
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Allows reports whether the token grants op.
+func (t *SandboxAccessToken) Allows(op string) bool {
+	for _, o := range t.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+const sandboxAccessTokenColumns = `id, sandbox_id, workspace_id, operations, command, artifacts_path, expires_at,
+	max_uses, uses, created_by, created_at, last_used_at, revoked_at`
+
+func scanSandboxAccessToken(row interface{ Scan(...interface{}) error }) (*SandboxAccessToken, error) {
+	t := &SandboxAccessToken{}
+	var ops pq.StringArray
+	var command, artifactsPath sql.NullString
+	var lastUsed, revoked sql.NullTime
+	if err := row.Scan(&t.ID, &t.SandboxID, &t.WorkspaceID, &ops, &command, &artifactsPath, &t.ExpiresAt,
+		&t.MaxUses, &t.Uses, &t.CreatedBy, &t.CreatedAt, &lastUsed, &revoked); err != nil {
+		return nil, err
+	}
+	t.Operations = []string(ops)
+	t.Command, t.ArtifactsPath = command.String, artifactsPath.String
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		t.RevokedAt = &revoked.Time
+	}
+	return t, nil
+}
+
+// CreateSandboxAccessToken stores a new token under the hash of its
+// secret. t.CreatedAt is set from the database.
+func (db *DB) CreateSandboxAccessToken(t *SandboxAccessToken, tokenHash string) error {
+	err := db.QueryRow(
+		`INSERT INTO sandbox_access_tokens
+		 (id, sandbox_id, workspace_id, token_hash, operations, command, artifacts_path, expires_at, max_uses, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING created_at`,
+		t.ID, t.SandboxID, t.WorkspaceID, tokenHash, pq.Array(t.Operations), nullIfEmpty(t.Command),
+		nullIfEmpty(t.ArtifactsPath), t.ExpiresAt, t.MaxUses, t.CreatedBy,
+	).Scan(&t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create sandbox access token: %w", err)
+	}
+	return nil
+}
+
+// UseSandboxAccessToken counts a use of the token with the given hash for
+// op on a sandbox and returns it. It returns nil if there is no such token
+// for the sandbox, or it is expired, revoked, used up, doesn't allow op, or
+// its creator is disabled.
+func (db *DB) UseSandboxAccessToken(tokenHash, sandboxID, op string) (*SandboxAccessToken, error) {
+	t, err := scanSandboxAccessToken(db.QueryRow(
+		`UPDATE sandbox_access_tokens SET uses = uses + 1, last_used_at = NOW()
+		 WHERE token_hash = $1 AND sandbox_id = $2 AND $3 = ANY(operations)
+		   AND revoked_at IS NULL AND expires_at > NOW() AND (max_uses = 0 OR uses < max_uses)
+		   AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = sandbox_access_tokens.created_by AND u.disabled_at IS NOT NULL)
+		 RETURNING `+sandboxAccessTokenColumns,
+		tokenHash, sandboxID, op))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("use sandbox access token: %w", err)
+	}
+	return t, nil
+}
+
+// ListSandboxAccessTokens returns a sandbox's tokens, newest first.
+func (db *DB) ListSandboxAccessTokens(sandboxID string) ([]*SandboxAccessToken, error) {
+	rows, err := db.Query(
+		`SELECT `+sandboxAccessTokenColumns+` FROM sandbox_access_tokens
+		 WHERE sandbox_id = $1 ORDER BY created_at DESC`, sandboxID)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []*SandboxAccessToken{}
+	for rows.Next() {
+		t, err := scanSandboxAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox access token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeSandboxAccessToken revokes a token of a sandbox. It reports false
+// if there is no such token or it was already revoked.
+func (db *DB) RevokeSandboxAccessToken(sandboxID, id string) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE sandbox_access_tokens SET revoked_at = NOW()
+		 WHERE sandbox_id = $1 AND id = $2 AND revoked_at IS NULL`,
+		sandboxID, id)
+	if err != nil {
+		return false, fmt.Errorf("revoke sandbox access token: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxAccessTokens(t *testing.T) {
+	d := newTestDB(t)
+	wsID, sbxID, userID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "ci"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+	if err := d.CreateSandbox(sbxID, wsID, "ci", "opencode", "", "", "", "", "", 0, 0, nil, json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	tok := &SandboxAccessToken{
+		ID: uuid.NewString(), SandboxID: sbxID, WorkspaceID: wsID, Operations: []string{AccessOpExec},
+		Command: "make test", ExpiresAt: time.Now().Add(time.Hour), MaxUses: 2, CreatedBy: userID,
+	}
+	if err := d.CreateSandboxAccessToken(tok, "hash-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := d.UseSandboxAccessToken("hash-1", sbxID, AccessOpArtifacts); err != nil || got != nil {
+		t.Errorf("token used for an operation it doesn't allow: %+v, %v", got, err)
+	}
+	if got, err := d.UseSandboxAccessToken("hash-1", uuid.NewString(), AccessOpExec); err != nil || got != nil {
+		t.Errorf("token used for another sandbox: %+v, %v", got, err)
+	}
+	for i := 1; i <= 2; i++ {
+		got, err := d.UseSandboxAccessToken("hash-1", sbxID, AccessOpExec)
+		if err != nil || got == nil || got.Uses != i || got.Command != "make test" || got.LastUsedAt == nil {
+			t.Fatalf("use %d = %+v, %v", i, got, err)
+		}
+	}
+	if got, _ := d.UseSandboxAccessToken("hash-1", sbxID, AccessOpExec); got != nil {
+		t.Error("token used beyond max_uses")
+	}
+
+	expired := &SandboxAccessToken{
+		ID: uuid.NewString(), SandboxID: sbxID, WorkspaceID: wsID, Operations: []string{AccessOpArtifacts},
+		ArtifactsPath: "/workspace/out", ExpiresAt: time.Now().Add(-time.Minute), CreatedBy: userID,
+	}
+	if err := d.CreateSandboxAccessToken(expired, "hash-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := d.UseSandboxAccessToken("hash-2", sbxID, AccessOpArtifacts); got != nil {
+		t.Error("expired token accepted")
+	}
+
+	// A disabled creator's tokens stop working until they are re-enabled.
+	ci := &SandboxAccessToken{
+		ID: uuid.NewString(), SandboxID: sbxID, WorkspaceID: wsID, Operations: []string{AccessOpExec},
+		Command: "make test", ExpiresAt: time.Now().Add(time.Hour), CreatedBy: userID,
+	}
+	if err := d.CreateSandboxAccessToken(ci, "hash-3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.SetUserDisabled(userID, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := d.UseSandboxAccessToken("hash-3", sbxID, AccessOpExec); got != nil {
+		t.Error("token of a disabled user accepted")
+	}
+	if _, err := d.SetUserDisabled(userID, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := d.UseSandboxAccessToken("hash-3", sbxID, AccessOpExec); err != nil || got == nil || got.Uses != 1 {
+		t.Errorf("token of a re-enabled user = %+v, %v", got, err)
+	}
+
+	tokens, err := d.ListSandboxAccessTokens(sbxID)
+	if err != nil || len(tokens) != 3 || tokens[0].ID != ci.ID || tokens[1].ArtifactsPath != "/workspace/out" || tokens[0].ID != expired.ID || tokens[0].ArtifactsPath != "/workspace/out" {
+		t.Fatalf("ListSandboxAccessTokens = %+v, %v", tokens, err)
+	}
+	if ok, err := d.RevokeSandboxAccessToken(sbxID, tok.ID); err != nil || !ok {
+		t.Fatalf("RevokeSandboxAccessToken = %v, %v", ok, err)
+	}
+	if ok, _ := d.RevokeSandboxAccessToken(sbxID, tok.ID); ok {
+		t.Error("token revoked twice")
+	}
+
+	// Tokens go with their creator.
+	d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if tokens, _ := d.ListSandboxAccessTokens(sbxID); len(tokens) != 0 {
+		t.Errorf("%d tokens left after deleting their creator", len(tokens))
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Kinds of sandbox activity.
+const (
+	SandboxActivityUser  = "user"  // requests of the user to the sandbox
+	SandboxActivityAgent = "agent" // opencode events relayed to the user
+)
+
+// SandboxActivityGap is how long an activity interval stays open without
+// marks; a mark after a longer pause starts a new interval.
+const SandboxActivityGap = 5 * time.Minute
+
+// SandboxActivityGrain is the time a single mark stands for. Marks are
+// recorded at most this often, so an interval counts up to one grain past
+// its last mark.
+const SandboxActivityGrain = time.Minute
+
+// SandboxActivityMark is one observation of activity in a sandbox.
+type SandboxActivityMark struct {
+	SandboxID   string
+	SandboxName string
+	WorkspaceID string
+	UserID      string
+	Kind        string
+	At          time.Time
+}
+
+// RecordSandboxActivity extends the open interval of the mark's sandbox,
+// user and kind, or starts a new one if the last mark is more than
+// SandboxActivityGap ago.
+func (db *DB) RecordSandboxActivity(m SandboxActivityMark) error {
+	res, err := db.Exec(
+		`UPDATE sandbox_activity SET last_seen_at = GREATEST(last_seen_at, $4), sandbox_name = $5
+		 WHERE id = (
+		   SELECT id FROM sandbox_activity
+		   WHERE sandbox_id = $1 AND user_id = $2 AND kind = $3
+		     AND last_seen_at >= $4 - make_interval(secs => $6) AND started_at <= $4
+		   ORDER BY last_seen_at DESC LIMIT 1
+		 )`,
+		m.SandboxID, m.UserID, m.Kind, m.At, m.SandboxName, SandboxActivityGap.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("record sandbox activity: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	_, err = db.Exec(
+		`INSERT INTO sandbox_activity (sandbox_id, sandbox_name, workspace_id, user_id, kind, started_at, last_seen_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $6)`,
+		m.SandboxID, m.SandboxName, m.WorkspaceID, m.UserID, m.Kind, m.At,
+	)
+	if err != nil {
+		return fmt.Errorf("record sandbox activity: %w", err)
+	}
+	return nil
+}
+
+// SandboxActivityFilter selects the activity summed by SandboxActivityTotals.
+// Empty IDs match everything.
+type SandboxActivityFilter struct {
+	WorkspaceID string
+	SandboxID   string
+	UserID      string
+	Since       time.Time
+	Until       time.Time
+}
+
+// SandboxActivityTotal is the active time of one user in one sandbox.
+type SandboxActivityTotal struct {
+	SandboxID    string `json:"sandbox_id"`
+	SandboxName  string `json:"sandbox_name"`
+	WorkspaceID  string `json:"workspace_id"`
+	UserID       string `json:"user_id"`
+	Email        string `json:"email,omitempty"`
+	UserSeconds  int64  `json:"user_seconds"`
+	AgentSeconds int64  `json:"agent_seconds"`
+}
+
+// SandboxActivityTotals sums the activity intervals within [Since, Until)
+// per sandbox and user, clipping intervals at the period's bounds. Rows are
+// ordered by sandbox name and email.
+func (db *DB) SandboxActivityTotals(f SandboxActivityFilter) ([]SandboxActivityTotal, error) {
+	rows, err := db.Query(
+		`WITH a AS (
+		   SELECT sandbox_id, sandbox_name, workspace_id, user_id, kind, id,
+		          EXTRACT(EPOCH FROM LEAST(last_seen_at + make_interval(secs => $6), $5) - GREATEST(started_at, $4)) AS seconds
+		   FROM sandbox_activity
+		   WHERE ($1 = '' OR workspace_id = $1) AND ($2 = '' OR sandbox_id = $2) AND ($3 = '' OR user_id = $3)
+		     AND started_at < $5 AND last_seen_at + make_interval(secs => $6) > $4
+		 )
+		 SELECT a.sandbox_id, (ARRAY_AGG(a.sandbox_name ORDER BY a.id DESC))[1], a.workspace_id, a.user_id, COALESCE(u.email, ''),
+		        COALESCE(SUM(a.seconds) FILTER (WHERE a.kind = 'user'), 0)::BIGINT,
+		        COALESCE(SUM(a.seconds) FILTER (WHERE a.kind = 'agent'), 0)::BIGINT
+		 FROM a LEFT JOIN users u ON u.id = a.user_id
+		 GROUP BY a.sandbox_id, a.workspace_id, a.user_id, u.email
+		 ORDER BY 2, 5, a.user_id`,
+		f.WorkspaceID, f.SandboxID, f.UserID, f.Since, f.Until, SandboxActivityGrain.Seconds(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox activity totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := []SandboxActivityTotal{}
+	for rows.Next() {
+		var t SandboxActivityTotal
+		if err := rows.Scan(&t.SandboxID, &t.SandboxName, &t.WorkspaceID, &t.UserID, &t.Email, &t.UserSeconds, &t.AgentSeconds); err != nil {
+			return nil, fmt.Errorf("scan sandbox activity total: %w", err)
+		}
+		totals = append(totals, t)
+	}
+	return totals, rows.Err()
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxActivity(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "activity"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	sbxID, userID := uuid.NewString(), uuid.NewString()
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	mark := func(kind string, offset time.Duration) {
+		t.Helper()
+		err := d.RecordSandboxActivity(SandboxActivityMark{
+			SandboxID: sbxID, SandboxName: "web", WorkspaceID: wsID, UserID: userID, Kind: kind, At: start.Add(offset),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 09:00-09:10 in one interval, a pause, then a lone mark at 10:00.
+	for m := 0; m <= 10; m += 2 {
+		mark(SandboxActivityUser, time.Duration(m)*time.Minute)
+	}
+	mark(SandboxActivityUser, time.Hour)
+	mark(SandboxActivityAgent, 3*time.Minute)
+	mark(SandboxActivityAgent, 7*time.Minute)
+
+	var intervals int
+	d.QueryRow(`SELECT COUNT(*) FROM sandbox_activity WHERE sandbox_id = $1`, sbxID).Scan(&intervals)
+	if intervals != 3 {
+		t.Errorf("intervals = %d, want 3", intervals)
+	}
+
+	totals, err := d.SandboxActivityTotals(SandboxActivityFilter{WorkspaceID: wsID, Since: start, Until: start.Add(24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 || totals[0].SandboxName != "web" || totals[0].UserSeconds != 12*60 || totals[0].AgentSeconds != 5*60 {
+		t.Fatalf("totals = %+v", totals)
+	}
+
+	// Intervals are clipped at the period's bounds.
+	totals, err = d.SandboxActivityTotals(SandboxActivityFilter{SandboxID: sbxID, Since: start.Add(5 * time.Minute), Until: start.Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 || totals[0].UserSeconds != 6*60 || totals[0].AgentSeconds != 3*60 {
+		t.Errorf("clipped totals = %+v", totals)
+	}
+	if totals, _ := d.SandboxActivityTotals(SandboxActivityFilter{UserID: uuid.NewString(), Since: start, Until: start.Add(time.Hour)}); len(totals) != 0 {
+		t.Errorf("totals of another user = %+v", totals)
+	}
+}
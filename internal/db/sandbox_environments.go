@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SandboxEnvironment is the last tool-version manifest probed in a sandbox.
+type SandboxEnvironment struct {
+	SandboxID       string
+	SandboxType     string
+	Tools           []byte // JSON object: tool -> version
+	InitialTools    []byte // first capture, as created from the image
+	CapturedAt      time.Time
+	FirstCapturedAt time.Time
+}
+
+// UpsertSandboxEnvironment stores the current manifest of a sandbox. The
+// first manifest stored is also kept as its initial manifest.
+func (db *DB) UpsertSandboxEnvironment(sandboxID, sandboxType string, tools []byte) error {
+	_, err := db.Exec(
+		`INSERT INTO sandbox_environments (sandbox_id, sandbox_type, tools, initial_tools)
+		 VALUES ($1, $2, $3, $3)
+		 ON CONFLICT (sandbox_id) DO UPDATE SET tools = EXCLUDED.tools, captured_at = NOW()`,
+		sandboxID, sandboxType, tools,
+	)
+	if err != nil {
+		return fmt.Errorf("upsert sandbox environment: %w", err)
+	}
+	return nil
+}
+
+// GetSandboxEnvironment returns the manifest of a sandbox, or nil if it was
+// never probed.
+func (db *DB) GetSandboxEnvironment(sandboxID string) (*SandboxEnvironment, error) {
+	e := &SandboxEnvironment{}
+	err := db.QueryRow(
+		`SELECT sandbox_id, sandbox_type, tools, initial_tools, captured_at, first_captured_at
+		 FROM sandbox_environments WHERE sandbox_id = $1`,
+		sandboxID,
+	).Scan(&e.SandboxID, &e.SandboxType, &e.Tools, &e.InitialTools, &e.CapturedAt, &e.FirstCapturedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox environment: %w", err)
+	}
+	return e, nil
+}
+
+// GetEnvironmentBaseline returns the initial manifest of the most recently
+// probed new sandbox of a type, i.e. what the current image ships, and the
+// sandbox it came from. It returns nil if no sandbox of the type was probed.
+func (db *DB) GetEnvironmentBaseline(sandboxType string) (tools []byte, sandboxID string, err error) {
+	err = db.QueryRow(
+		`SELECT initial_tools, sandbox_id FROM sandbox_environments
+		 WHERE sandbox_type = $1 ORDER BY first_captured_at DESC LIMIT 1`,
+		sandboxType,
+	).Scan(&tools, &sandboxID)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("get environment baseline: %w", err)
+	}
+	return tools, sandboxID, nil
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxHookRunsPerSandbox bounds the hook runs kept per sandbox.
+const maxHookRunsPerSandbox = 50
+
+// SandboxHookRun is one run of a template's post-start hook in a sandbox.
+type SandboxHookRun struct {
+	ID         int64     `json:"id"`
+	SandboxID  string    `json:"sandbox_id"`
+	TemplateID string    `json:"template_id"`
+	Hook       string    `json:"hook"`
+	Trigger    string    `json:"trigger"` // "start" or "resume"
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// CreateSandboxHookRun records a hook run and drops the sandbox's runs
+// beyond the newest maxHookRunsPerSandbox.
+func (db *DB) CreateSandboxHookRun(run *SandboxHookRun) error {
+	err := db.QueryRow(
+		`INSERT INTO sandbox_hook_runs
+		 (sandbox_id, template_id, hook, trigger, success, error, output, started_at, finished_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id`,
+		run.SandboxID, run.TemplateID, run.Hook, run.Trigger, run.Success, run.Error, run.Output,
+		run.StartedAt, run.FinishedAt,
+	).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("create sandbox hook run: %w", err)
+	}
+	_, err = db.Exec(
+		`DELETE FROM sandbox_hook_runs WHERE sandbox_id = $1 AND id NOT IN (
+		   SELECT id FROM sandbox_hook_runs WHERE sandbox_id = $1 ORDER BY id DESC LIMIT $2)`,
+		run.SandboxID, maxHookRunsPerSandbox)
+	if err != nil {
+		return fmt.Errorf("prune sandbox hook runs: %w", err)
+	}
+	return nil
+}
+
+// ListSandboxHookRuns returns a sandbox's hook runs, newest first.
+func (db *DB) ListSandboxHookRuns(sandboxID string) ([]*SandboxHookRun, error) {
+	rows, err := db.Query(
+		`SELECT id, sandbox_id, template_id, hook, trigger, success, error, output, started_at, finished_at
+		 FROM sandbox_hook_runs WHERE sandbox_id = $1 ORDER BY id DESC`, sandboxID)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox hook runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []*SandboxHookRun{}
+	for rows.Next() {
+		run := &SandboxHookRun{}
+		if err := rows.Scan(&run.ID, &run.SandboxID, &run.TemplateID, &run.Hook, &run.Trigger, &run.Success,
+			&run.Error, &run.Output, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("scan sandbox hook run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
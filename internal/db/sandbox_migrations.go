@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Sandbox migration statuses.
+const (
+	SandboxMigrationRunning   = "running"
+	SandboxMigrationCompleted = "completed"
+	SandboxMigrationFailed    = "failed"
+)
+
+// SandboxMigration is an admin-triggered move of a sandbox to another node
+// pool, tracked as a job.
+type SandboxMigration struct {
+	ID          string          `json:"id"`
+	SandboxID   string          `json:"sandbox_id"`
+	Target      json.RawMessage `json:"target"`
+	Status      string          `json:"status"`
+	Phase       string          `json:"phase"`
+	Error       string          `json:"error,omitempty"`
+	SnapshotID  string          `json:"snapshot_id,omitempty"` // session-data snapshot taken on the way
+	RequestedBy string          `json:"requested_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+const sandboxMigrationColumns = `id, sandbox_id, target, status, phase, error, snapshot_id, requested_by, created_at, updated_at, completed_at`
+
+func scanSandboxMigration(row interface{ Scan(...interface{}) error }) (*SandboxMigration, error) {
+	m := &SandboxMigration{}
+	var errMsg, snapshotID, requestedBy sql.NullString
+	var completedAt sql.NullTime
+	if err := row.Scan(&m.ID, &m.SandboxID, &m.Target, &m.Status, &m.Phase, &errMsg, &snapshotID, &requestedBy,
+		&m.CreatedAt, &m.UpdatedAt, &completedAt); err != nil {
+		return nil, err
+	}
+	m.Error, m.SnapshotID, m.RequestedBy = errMsg.String, snapshotID.String, requestedBy.String
+	if completedAt.Valid {
+		m.CompletedAt = &completedAt.Time
+	}
+	return m, nil
+}
+
+// CreateSandboxMigration records a running migration. It fails if the
+// sandbox already has one running.
+func (db *DB) CreateSandboxMigration(id, sandboxID string, target json.RawMessage, requestedBy string) (*SandboxMigration, error) {
+	m, err := scanSandboxMigration(db.QueryRow(
+		`INSERT INTO sandbox_migrations (id, sandbox_id, target, requested_by)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+sandboxMigrationColumns,
+		id, sandboxID, target, nullIfEmpty(requestedBy),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create sandbox migration: %w", err)
+	}
+	return m, nil
+}
+
+// UpdateSandboxMigrationPhase records the step a running migration is in.
+func (db *DB) UpdateSandboxMigrationPhase(id, phase string) error {
+	if _, err := db.Exec(
+		`UPDATE sandbox_migrations SET phase = $2, updated_at = NOW()
+		 WHERE id = $1 AND status = 'running'`, id, phase); err != nil {
+		return fmt.Errorf("update sandbox migration phase: %w", err)
+	}
+	return nil
+}
+
+// SetSandboxMigrationSnapshot records the snapshot a migration took.
+func (db *DB) SetSandboxMigrationSnapshot(id, snapshotID string) error {
+	if _, err := db.Exec(
+		`UPDATE sandbox_migrations SET snapshot_id = $2, updated_at = NOW() WHERE id = $1`,
+		id, snapshotID); err != nil {
+		return fmt.Errorf("set sandbox migration snapshot: %w", err)
+	}
+	return nil
+}
+
+// FinishSandboxMigration marks a running migration completed, or failed
+// with errMsg if it is not empty.
+func (db *DB) FinishSandboxMigration(id, errMsg string) error {
+	status := SandboxMigrationCompleted
+	if errMsg != "" {
+		status = SandboxMigrationFailed
+	}
+	if _, err := db.Exec(
+		`UPDATE sandbox_migrations
+		 SET status = $2, error = $3, updated_at = NOW(), completed_at = NOW()
+		 WHERE id = $1 AND status = 'running'`,
+		id, status, nullIfEmpty(errMsg)); err != nil {
+		return fmt.Errorf("finish sandbox migration: %w", err)
+	}
+	return nil
+}
+
+// FailInterruptedSandboxMigrations marks migrations still running failed.
+// Migrations run inside the server process, so at startup any running one
+// was interrupted by a restart. It returns the number marked.
+func (db *DB) FailInterruptedSandboxMigrations() (int64, error) {
+	res, err := db.Exec(
+		`UPDATE sandbox_migrations
+		 SET status = 'failed', error = 'interrupted by a server restart', updated_at = NOW(), completed_at = NOW()
+		 WHERE status = 'running'`)
+	if err != nil {
+		return 0, fmt.Errorf("fail interrupted sandbox migrations: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// GetSandboxMigration returns a migration, or nil if not found.
+func (db *DB) GetSandboxMigration(id string) (*SandboxMigration, error) {
+	m, err := scanSandboxMigration(db.QueryRow(
+		`SELECT `+sandboxMigrationColumns+` FROM sandbox_migrations WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox migration: %w", err)
+	}
+	return m, nil
+}
+
+// ListSandboxMigrations returns the migrations of a sandbox, or of all
+// sandboxes if sandboxID is empty, newest first. status filters if set.
+func (db *DB) ListSandboxMigrations(sandboxID, status string, limit int) ([]*SandboxMigration, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	rows, err := db.Query(
+		`SELECT `+sandboxMigrationColumns+` FROM sandbox_migrations
+		 WHERE ($1 = '' OR sandbox_id = $1) AND ($2 = '' OR status = $2)
+		 ORDER BY created_at DESC, id LIMIT $3`, sandboxID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox migrations: %w", err)
+	}
+	defer rows.Close()
+
+	migrations := []*SandboxMigration{}
+	for rows.Next() {
+		m, err := scanSandboxMigration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox migration: %w", err)
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, rows.Err()
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxMigrations(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "migrate"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateSandbox(sbxID, wsID, "migrate", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.DeleteSandbox(sbxID) })
+
+	target := json.RawMessage(`{"node_pool":{"node_selector":{"pool":"b"}}}`)
+	m, err := d.CreateSandboxMigration(uuid.NewString(), sbxID, target, "admin-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != SandboxMigrationRunning || m.Phase != "pending" {
+		t.Errorf("new migration = %+v", m)
+	}
+	if _, err := d.CreateSandboxMigration(uuid.NewString(), sbxID, target, "admin-1"); err == nil {
+		t.Error("second running migration accepted")
+	}
+	if err := d.UpdateSandboxMigrationPhase(m.ID, "restoring"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.FinishSandboxMigration(m.ID, "restore failed"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.GetSandboxMigration(m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != SandboxMigrationFailed || got.Phase != "restoring" || got.Error != "restore failed" || got.CompletedAt == nil {
+		t.Errorf("failed migration = %+v", got)
+	}
+	// A finished migration stays finished.
+	if err := d.UpdateSandboxMigrationPhase(m.ID, "starting"); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := d.CreateSandboxMigration(uuid.NewString(), sbxID, target, "")
+	if err != nil {
+		t.Fatalf("migration after a failed one: %v", err)
+	}
+	if n, err := d.FailInterruptedSandboxMigrations(); err != nil || n < 1 {
+		t.Errorf("FailInterruptedSandboxMigrations = %d, %v", n, err)
+	}
+	list, err := d.ListSandboxMigrations(sbxID, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != next.ID || list[0].Status != SandboxMigrationFailed || list[1].Phase != "restoring" {
+		t.Errorf("migrations = %+v", list)
+	}
+
+	if err := d.SetSandboxMetadataString(sbxID, "region", "eu-west-1"); err != nil {
+		t.Fatal(err)
+	}
+	sbx, err := d.GetSandbox(sbxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(sbx.Metadata) != `{"region": "eu-west-1"}` {
+		t.Errorf("metadata = %s", sbx.Metadata)
+	}
+	if err := d.SetSandboxMetadataString(sbxID, "region", ""); err != nil {
+		t.Fatal(err)
+	}
+	if sbx, _ = d.GetSandbox(sbxID); string(sbx.Metadata) != `{}` {
+		t.Errorf("metadata after removal = %s", sbx.Metadata)
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPinnedSandboxIsNotIdle(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "pin"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateSandbox(sbxID, wsID, "pinned", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	d.Exec(`UPDATE sandboxes SET status = 'running', last_activity_at = NOW() - INTERVAL '2 hours' WHERE id = $1`, sbxID)
+
+	idle := func() bool {
+		sandboxes, err := d.ListIdleSandboxes(60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range sandboxes {
+			if s.ID == sbxID {
+				return true
+			}
+		}
+		return false
+	}
+	if !idle() {
+		t.Fatal("unpinned sandbox not listed as idle")
+	}
+	if err := d.SetSandboxPinned(sbxID, true, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if idle() {
+		t.Error("pinned sandbox listed as idle")
+	}
+	if s, _ := d.GetSandbox(sbxID); s == nil || !s.PinnedAt.Valid {
+		t.Error("pinned_at not set")
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SandboxPort is a sandbox port exposed as a preview URL.
+type SandboxPort struct {
+	SandboxID string    `json:"sandbox_id"`
+	Port      int       `json:"port"`
+	Label     string    `json:"label"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddSandboxPort exposes a port, or updates the label of an exposed one.
+// It reports whether the port was newly exposed; p.CreatedAt is set from
+// the database.
+func (db *DB) AddSandboxPort(p *SandboxPort) (bool, error) {
+	var created bool
+	err := db.QueryRow(
+		`INSERT INTO sandbox_ports (sandbox_id, port, label, created_by) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (sandbox_id, port) DO UPDATE SET label = EXCLUDED.label
+		 RETURNING created_at, (xmax = 0)`,
+		p.SandboxID, p.Port, p.Label, nullIfEmpty(p.CreatedBy),
+	).Scan(&p.CreatedAt, &created)
+	if err != nil {
+		return false, fmt.Errorf("add sandbox port: %w", err)
+	}
+	return created, nil
+}
+
+// ListSandboxPorts returns a sandbox's exposed ports in order.
+func (db *DB) ListSandboxPorts(sandboxID string) ([]*SandboxPort, error) {
+	rows, err := db.Query(
+		`SELECT sandbox_id, port, label, created_by, created_at FROM sandbox_ports
+		 WHERE sandbox_id = $1 ORDER BY port`, sandboxID)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox ports: %w", err)
+	}
+	defer rows.Close()
+
+	ports := []*SandboxPort{}
+	for rows.Next() {
+		p := &SandboxPort{}
+		var createdBy sql.NullString
+		if err := rows.Scan(&p.SandboxID, &p.Port, &p.Label, &createdBy, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan sandbox port: %w", err)
+		}
+		p.CreatedBy = createdBy.String
+		ports = append(ports, p)
+	}
+	return ports, rows.Err()
+}
+
+// IsSandboxPortExposed reports whether a port of a sandbox is exposed.
+func (db *DB) IsSandboxPortExposed(sandboxID string, port int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM sandbox_ports WHERE sandbox_id = $1 AND port = $2)`,
+		sandboxID, port,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check sandbox port: %w", err)
+	}
+	return exists, nil
+}
+
+// DeleteSandboxPort stops exposing a port.
+func (db *DB) DeleteSandboxPort(sandboxID string, port int) (bool, error) {
+	res, err := db.Exec(`DELETE FROM sandbox_ports WHERE sandbox_id = $1 AND port = $2`, sandboxID, port)
+	if err != nil {
+		return false, fmt.Errorf("delete sandbox port: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
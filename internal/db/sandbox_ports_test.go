@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxPorts(t *testing.T) {
+	d := newTestDB(t)
+	wsID, sbxID := uuid.NewString(), uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "ports"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateSandbox(sbxID, wsID, "preview", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.DeleteSandbox(sbxID) })
+
+	if created, err := d.AddSandboxPort(&SandboxPort{SandboxID: sbxID, Port: 5173, Label: "vite", CreatedBy: "u-1"}); err != nil || !created {
+		t.Fatalf("AddSandboxPort = %v, %v", created, err)
+	}
+	if created, err := d.AddSandboxPort(&SandboxPort{SandboxID: sbxID, Port: 5173, Label: "web", CreatedBy: "u-2"}); err != nil || created {
+		t.Errorf("relabel AddSandboxPort = %v, %v", created, err)
+	}
+	if _, err := d.AddSandboxPort(&SandboxPort{SandboxID: sbxID, Port: 3000}); err != nil {
+		t.Fatal(err)
+	}
+
+	ports, err := d.ListSandboxPorts(sbxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 2 || ports[0].Port != 3000 || ports[1].Label != "web" || ports[1].CreatedBy != "u-1" {
+		t.Errorf("ListSandboxPorts = %+v", ports)
+	}
+	if ok, err := d.IsSandboxPortExposed(sbxID, 5173); err != nil || !ok {
+		t.Errorf("IsSandboxPortExposed(5173) = %v, %v", ok, err)
+	}
+	if ok, _ := d.IsSandboxPortExposed(sbxID, 8080); ok {
+		t.Error("unexposed port reported exposed")
+	}
+
+	if ok, err := d.DeleteSandboxPort(sbxID, 5173); err != nil || !ok {
+		t.Errorf("DeleteSandboxPort = %v, %v", ok, err)
+	}
+	if ok, _ := d.DeleteSandboxPort(sbxID, 5173); ok {
+		t.Error("second delete reported a port")
+	}
+}
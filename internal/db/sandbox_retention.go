@@ -0,0 +1,143 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SandboxRetention is the retention state of a paused sandbox: an admin
+// hold that keeps it from deletion, and when its deletion warning was last
+// sent.
+type SandboxRetention struct {
+	SandboxID      string     `json:"sandbox_id"`
+	Held           bool       `json:"held"`
+	HoldUntil      *time.Time `json:"hold_until,omitempty"` // nil: held indefinitely
+	WarnedAt       *time.Time `json:"warned_at,omitempty"`
+	WarnedPausedAt *time.Time `json:"-"` // the pause that WarnedAt warned of
+}
+
+func scanSandboxRetention(row interface{ Scan(...interface{}) error }) (*SandboxRetention, error) {
+	sr := &SandboxRetention{}
+	var holdUntil, warnedAt, warned sql.NullTime
+	if err := row.Scan(&sr.SandboxID, &sr.Held, &holdUntil, &warnedAt, &warned); err != nil {
+		return nil, err
+	}
+	if holdUntil.Valid {
+		sr.HoldUntil = &holdUntil.Time
+	}
+	if warnedAt.Valid {
+		sr.WarnedAt = &warnedAt.Time
+	}
+	if warned.Valid {
+		sr.WarnedPausedAt = &warned.Time
+	}
+	return sr, nil
+}
+
+// GetSandboxRetention returns the retention state of a sandbox, or nil if
+// it has none.
+func (db *DB) GetSandboxRetention(sandboxID string) (*SandboxRetention, error) {
+	sr, err := scanSandboxRetention(db.QueryRow(
+		`SELECT sandbox_id, held, hold_until, warned_at, warned_paused_at FROM sandbox_retention WHERE sandbox_id = $1`,
+		sandboxID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox retention: %w", err)
+	}
+	return sr, nil
+}
+
+// ListSandboxRetention returns the retention state of the sandboxes of a
+// workspace, or of all sandboxes if workspaceID is empty, by sandbox ID.
+func (db *DB) ListSandboxRetention(workspaceID string) (map[string]*SandboxRetention, error) {
+	rows, err := db.Query(
+		`SELECT r.sandbox_id, r.held, r.hold_until, r.warned_at, r.warned_paused_at
+		 FROM sandbox_retention r JOIN sandboxes s ON s.id = r.sandbox_id
+		 WHERE $1 = '' OR s.workspace_id = $1`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox retention: %w", err)
+	}
+	defer rows.Close()
+
+	m := make(map[string]*SandboxRetention)
+	for rows.Next() {
+		sr, err := scanSandboxRetention(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox retention: %w", err)
+		}
+		m[sr.SandboxID] = sr
+	}
+	return m, rows.Err()
+}
+
+// SetSandboxRetentionHold keeps a sandbox from deletion while paused, until
+// until, or indefinitely if until is nil.
+func (db *DB) SetSandboxRetentionHold(sandboxID string, until *time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO sandbox_retention (sandbox_id, held, hold_until) VALUES ($1, TRUE, $2)
+		 ON CONFLICT (sandbox_id) DO UPDATE SET held = TRUE, hold_until = EXCLUDED.hold_until`,
+		sandboxID, until,
+	)
+	if err != nil {
+		return fmt.Errorf("set sandbox retention hold: %w", err)
+	}
+	return nil
+}
+
+// ClearSandboxRetentionHold removes the hold of a sandbox. It reports false
+// if the sandbox was not held.
+func (db *DB) ClearSandboxRetentionHold(sandboxID string) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE sandbox_retention SET held = FALSE, hold_until = NULL WHERE sandbox_id = $1 AND held`,
+		sandboxID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("clear sandbox retention hold: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// MarkSandboxRetentionWarned records that the deletion warning for the
+// pause of a sandbox at pausedAt was sent at warnedAt.
+func (db *DB) MarkSandboxRetentionWarned(sandboxID string, pausedAt, warnedAt time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO sandbox_retention (sandbox_id, warned_at, warned_paused_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (sandbox_id) DO UPDATE SET warned_at = EXCLUDED.warned_at, warned_paused_at = EXCLUDED.warned_paused_at`,
+		sandboxID, warnedAt, pausedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("mark sandbox retention warned: %w", err)
+	}
+	return nil
+}
+
+// ListPausedSandboxes returns the paused cloud sandboxes.
+func (db *DB) ListPausedSandboxes() ([]*Sandbox, error) {
+	rows, err := db.Query(
+		`SELECT ` + sandboxColumns + `
+		 FROM sandboxes
+		 WHERE status = 'paused' AND is_local = FALSE AND paused_at IS NOT NULL
+		 ORDER BY paused_at`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list paused sandboxes: %w", err)
+	}
+	defer rows.Close()
+
+	var sandboxes []*Sandbox
+	for rows.Next() {
+		s, err := scanSandbox(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan paused sandbox: %w", err)
+		}
+		sandboxes = append(sandboxes, s)
+	}
+	return sandboxes, rows.Err()
+}
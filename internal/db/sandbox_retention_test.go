@@ -0,0 +1,70 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxRetention(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "retention"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateSandbox(sbxID, wsID, "paused", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.UpdateSandboxStatus(sbxID, "paused"); err != nil {
+		t.Fatal(err)
+	}
+
+	paused, err := d.ListPausedSandboxes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range paused {
+		found = found || s.ID == sbxID
+	}
+	if !found {
+		t.Fatal("paused sandbox not listed")
+	}
+
+	if sr, err := d.GetSandboxRetention(sbxID); err != nil || sr != nil {
+		t.Fatalf("GetSandboxRetention before any state = %+v, %v", sr, err)
+	}
+	pausedAt := time.Now().Truncate(time.Second)
+	if err := d.MarkSandboxRetentionWarned(sbxID, pausedAt, pausedAt.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	until := pausedAt.Add(48 * time.Hour)
+	if err := d.SetSandboxRetentionHold(sbxID, &until); err != nil {
+		t.Fatal(err)
+	}
+	m, err := d.ListSandboxRetention(wsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr := m[sbxID]
+	if sr == nil || !sr.Held || sr.HoldUntil == nil || !sr.HoldUntil.Equal(until) || sr.WarnedPausedAt == nil || !sr.WarnedPausedAt.Equal(pausedAt) || sr.WarnedAt == nil {
+		t.Fatalf("ListSandboxRetention = %+v", sr)
+	}
+	if other, err := d.ListSandboxRetention(uuid.NewString()); err != nil || len(other) != 0 {
+		t.Errorf("retention listed for another workspace: %v, %v", other, err)
+	}
+
+	if ok, err := d.ClearSandboxRetentionHold(sbxID); err != nil || !ok {
+		t.Fatalf("ClearSandboxRetentionHold = %v, %v", ok, err)
+	}
+	if ok, _ := d.ClearSandboxRetentionHold(sbxID); ok {
+		t.Error("cleared a hold twice")
+	}
+	sr, err = d.GetSandboxRetention(sbxID)
+	if err != nil || sr == nil || sr.Held || sr.HoldUntil != nil || sr.WarnedPausedAt == nil {
+		t.Errorf("GetSandboxRetention after clear = %+v, %v", sr, err)
+	}
+}
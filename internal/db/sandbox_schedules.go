@@ -0,0 +1,139 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SandboxSchedule pauses or resumes a sandbox, or all cloud sandboxes of a
+// workspace, on a cron schedule.
+type SandboxSchedule struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	SandboxID   string     `json:"sandbox_id,omitempty"` // empty: the whole workspace
+	Action      string     `json:"action"`               // pause or resume
+	Cron        string     `json:"cron"`
+	Timezone    string     `json:"timezone"`
+	Enabled     bool       `json:"enabled"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+}
+
+const sandboxScheduleColumns = `id, workspace_id, sandbox_id, action, cron, timezone, enabled, created_by, created_at, last_run_at`
+
+func scanSandboxSchedule(row interface{ Scan(...interface{}) error }) (*SandboxSchedule, error) {
+	sc := &SandboxSchedule{}
+	var sandboxID, createdBy sql.NullString
+	var lastRun sql.NullTime
+	if err := row.Scan(&sc.ID, &sc.WorkspaceID, &sandboxID, &sc.Action, &sc.Cron, &sc.Timezone, &sc.Enabled,
+		&createdBy, &sc.CreatedAt, &lastRun); err != nil {
+		return nil, err
+	}
+	sc.SandboxID, sc.CreatedBy = sandboxID.String, createdBy.String
+	if lastRun.Valid {
+		sc.LastRunAt = &lastRun.Time
+	}
+	return sc, nil
+}
+
+func (db *DB) querySandboxSchedules(query string, args ...interface{}) ([]*SandboxSchedule, error) {
+	rows, err := db.Query(`SELECT `+sandboxScheduleColumns+` FROM sandbox_schedules `+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []*SandboxSchedule{}
+	for rows.Next() {
+		sc, err := scanSandboxSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox schedule: %w", err)
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, rows.Err()
+}
+
+// CreateSandboxSchedule stores a new schedule. sc.CreatedAt is set from
+// the database.
+func (db *DB) CreateSandboxSchedule(sc *SandboxSchedule) error {
+	err := db.QueryRow(
+		`INSERT INTO sandbox_schedules (id, workspace_id, sandbox_id, action, cron, timezone, enabled, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING created_at`,
+		sc.ID, sc.WorkspaceID, nullIfEmpty(sc.SandboxID), sc.Action, sc.Cron, sc.Timezone, sc.Enabled, nullIfEmpty(sc.CreatedBy),
+	).Scan(&sc.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create sandbox schedule: %w", err)
+	}
+	return nil
+}
+
+// UpdateSandboxSchedule replaces a schedule's sandbox, action, cron
+// expression, time zone and enabled flag. Its last run is set to now, so
+// runs missed under the old settings or while disabled are not caught up.
+func (db *DB) UpdateSandboxSchedule(sc *SandboxSchedule) error {
+	_, err := db.Exec(
+		`UPDATE sandbox_schedules SET sandbox_id = $2, action = $3, cron = $4, timezone = $5, enabled = $6, last_run_at = NOW()
+		 WHERE id = $1`,
+		sc.ID, nullIfEmpty(sc.SandboxID), sc.Action, sc.Cron, sc.Timezone, sc.Enabled,
+	)
+	if err != nil {
+		return fmt.Errorf("update sandbox schedule: %w", err)
+	}
+	return nil
+}
+
+// GetSandboxSchedule returns a schedule of a workspace, or nil if not
+// found.
+func (db *DB) GetSandboxSchedule(workspaceID, id string) (*SandboxSchedule, error) {
+	sc, err := scanSandboxSchedule(db.QueryRow(
+		`SELECT `+sandboxScheduleColumns+` FROM sandbox_schedules WHERE workspace_id = $1 AND id = $2`,
+		workspaceID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox schedule: %w", err)
+	}
+	return sc, nil
+}
+
+// ListSandboxSchedules returns a workspace's schedules, oldest first.
+func (db *DB) ListSandboxSchedules(workspaceID string) ([]*SandboxSchedule, error) {
+	return db.querySandboxSchedules(`WHERE workspace_id = $1 ORDER BY created_at, id`, workspaceID)
+}
+
+// ListEnabledSandboxSchedules returns the enabled schedules of all
+// workspaces.
+func (db *DB) ListEnabledSandboxSchedules() ([]*SandboxSchedule, error) {
+	return db.querySandboxSchedules(`WHERE enabled ORDER BY created_at, id`)
+}
+
+// DeleteSandboxSchedule removes a schedule.
+func (db *DB) DeleteSandboxSchedule(workspaceID, id string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM sandbox_schedules WHERE workspace_id = $1 AND id = $2`, workspaceID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete sandbox schedule: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ClaimSandboxScheduleRun sets a schedule's last run to at if it is still
+// prev, and reports whether it did; of several schedulers seeing the same
+// due run, only one claims it.
+func (db *DB) ClaimSandboxScheduleRun(id string, prev *time.Time, at time.Time) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE sandbox_schedules SET last_run_at = $3
+		 WHERE id = $1 AND last_run_at IS NOT DISTINCT FROM $2`,
+		id, prev, at,
+	)
+	if err != nil {
+		return false, fmt.Errorf("claim sandbox schedule run: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
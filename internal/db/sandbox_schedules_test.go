@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxSchedules(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "schedules"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	sc := &SandboxSchedule{ID: uuid.NewString(), WorkspaceID: wsID, Action: "pause", Cron: "0 19 * * mon-fri", Timezone: "Europe/Berlin", Enabled: true, CreatedBy: "u-1"}
+	if err := d.CreateSandboxSchedule(sc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.GetSandboxSchedule(wsID, sc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.SandboxID != "" || got.Cron != sc.Cron || got.Timezone != "Europe/Berlin" || !got.Enabled || got.LastRunAt != nil {
+		t.Fatalf("GetSandboxSchedule = %+v", got)
+	}
+	if other, err := d.GetSandboxSchedule(uuid.NewString(), sc.ID); err != nil || other != nil {
+		t.Errorf("schedule visible from another workspace: %+v, %v", other, err)
+	}
+
+	// Only one of two schedulers that saw the same last run claims it.
+	at := time.Now().Truncate(time.Second)
+	if ok, err := d.ClaimSandboxScheduleRun(sc.ID, nil, at); err != nil || !ok {
+		t.Fatalf("first claim = %v, %v", ok, err)
+	}
+	if ok, _ := d.ClaimSandboxScheduleRun(sc.ID, nil, at.Add(time.Second)); ok {
+		t.Error("second claim of the same run succeeded")
+	}
+	if ok, err := d.ClaimSandboxScheduleRun(sc.ID, &at, at.Add(time.Minute)); err != nil || !ok {
+		t.Errorf("claim of the next run = %v, %v", ok, err)
+	}
+
+	got.Enabled, got.Action = false, "resume"
+	if err := d.UpdateSandboxSchedule(got); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.ListSandboxSchedules(wsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Enabled || list[0].Action != "resume" || list[0].LastRunAt == nil {
+		t.Errorf("ListSandboxSchedules = %+v", list)
+	}
+	enabled, err := d.ListEnabledSandboxSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range enabled {
+		if e.ID == sc.ID {
+			t.Error("disabled schedule listed as enabled")
+		}
+	}
+
+	if ok, err := d.DeleteSandboxSchedule(wsID, sc.ID); err != nil || !ok {
+		t.Errorf("DeleteSandboxSchedule = %v, %v", ok, err)
+	}
+}
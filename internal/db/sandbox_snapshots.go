@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SandboxSnapshot is a named copy of a sandbox's session data.
+type SandboxSnapshot struct {
+	ID          string    `json:"id"`
+	SandboxID   string    `json:"sandbox_id"`
+	WorkspaceID string    `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Ref         string    `json:"-"` // VolumeSnapshot or Docker volume name
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const sandboxSnapshotColumns = `id, sandbox_id, workspace_id, name, ref, created_by, created_at`
+
+func scanSandboxSnapshot(row interface{ Scan(...interface{}) error }) (*SandboxSnapshot, error) {
+	s := &SandboxSnapshot{}
+	var createdBy sql.NullString
+	if err := row.Scan(&s.ID, &s.SandboxID, &s.WorkspaceID, &s.Name, &s.Ref, &createdBy, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.CreatedBy = createdBy.String
+	return s, nil
+}
+
+// CreateSandboxSnapshot records a snapshot taken by the sandbox backend.
+func (db *DB) CreateSandboxSnapshot(id, sandboxID, workspaceID, name, ref, createdBy string) (*SandboxSnapshot, error) {
+	s, err := scanSandboxSnapshot(db.QueryRow(
+		`INSERT INTO sandbox_snapshots (id, sandbox_id, workspace_id, name, ref, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+sandboxSnapshotColumns,
+		id, sandboxID, workspaceID, name, ref, nullIfEmpty(createdBy),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create sandbox snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// GetSandboxSnapshot returns a snapshot of a sandbox by ID or name, or nil
+// if not found.
+func (db *DB) GetSandboxSnapshot(sandboxID, idOrName string) (*SandboxSnapshot, error) {
+	s, err := scanSandboxSnapshot(db.QueryRow(
+		`SELECT `+sandboxSnapshotColumns+` FROM sandbox_snapshots
+		 WHERE sandbox_id = $1 AND (id = $2 OR name = $2)
+		 ORDER BY id = $2 DESC LIMIT 1`,
+		sandboxID, idOrName,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// ListSandboxSnapshots returns a sandbox's snapshots, newest first.
+func (db *DB) ListSandboxSnapshots(sandboxID string) ([]*SandboxSnapshot, error) {
+	rows, err := db.Query(
+		`SELECT `+sandboxSnapshotColumns+` FROM sandbox_snapshots
+		 WHERE sandbox_id = $1 ORDER BY created_at DESC, id`, sandboxID)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []*SandboxSnapshot{}
+	for rows.Next() {
+		s, err := scanSandboxSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// DeleteSandboxSnapshot removes a snapshot record.
+func (db *DB) DeleteSandboxSnapshot(id string) error {
+	if _, err := db.Exec(`DELETE FROM sandbox_snapshots WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("delete sandbox snapshot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxSnapshots(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "snap"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateSandbox(sbxID, wsID, "snap", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := d.CreateSandboxSnapshot(uuid.NewString(), sbxID, wsID, "before-upgrade", "ref-1", "u-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.CreateSandboxSnapshot(uuid.NewString(), sbxID, wsID, "before-upgrade", "ref-2", "u-1"); err == nil {
+		t.Error("duplicate snapshot name accepted")
+	}
+	for _, key := range []string{first.ID, "before-upgrade"} {
+		got, err := d.GetSandboxSnapshot(sbxID, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.ID != first.ID || got.Ref != "ref-1" || got.CreatedBy != "u-1" {
+			t.Errorf("GetSandboxSnapshot(%q) = %+v", key, got)
+		}
+	}
+
+	if err := d.DeleteSandbox(sbxID); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := d.ListSandboxSnapshots(sbxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 0 {
+		t.Errorf("snapshots after sandbox delete = %+v, want none", snapshots)
+	}
+}
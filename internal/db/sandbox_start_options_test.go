@@ -0,0 +1,73 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxStartOptions(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "start-options"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateSandbox(sbxID, wsID, "pending", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if opts, err := d.GetSandboxStartOptions(sbxID); err != nil || opts != nil {
+		t.Fatalf("start options of a new sandbox = %s, %v", opts, err)
+	}
+	if err := d.SetSandboxStartOptions(sbxID, json.RawMessage(`{"CPU":500}`)); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := d.GetSandboxStartOptions(sbxID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ CPU int }
+	if err := json.Unmarshal(opts, &got); err != nil || got.CPU != 500 {
+		t.Errorf("start options = %s, %v", opts, err)
+	}
+	if err := d.ClearSandboxStartOptions(sbxID); err != nil {
+		t.Fatal(err)
+	}
+	if opts, err := d.GetSandboxStartOptions(sbxID); err != nil || opts != nil {
+		t.Errorf("start options after clear = %s, %v", opts, err)
+	}
+}
+
+func TestFailInterruptedSandboxStorage(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	provisioning, running := uuid.NewString(), uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "storage"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	for id, status := range map[string]string{provisioning: "provisioning-storage", running: "running"} {
+		if err := d.CreateSandbox(id, wsID, status, "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.UpdateSandboxStatus(id, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := d.FailInterruptedSandboxStorage(); err != nil || n < 1 {
+		t.Errorf("FailInterruptedSandboxStorage = %d, %v", n, err)
+	}
+	for id, want := range map[string]string{provisioning: "storage-failed", running: "running"} {
+		sbx, err := d.GetSandbox(id)
+		if err != nil || sbx == nil {
+			t.Fatalf("GetSandbox = %v, %v", sbx, err)
+		}
+		if sbx.Status != want {
+			t.Errorf("sandbox %s is %s, want %s", id, sbx.Status, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SandboxTemplate is a workspace's standardized sandbox environment.
+type SandboxTemplate struct {
+	ID             string            `json:"id"`
+	WorkspaceID    string            `json:"workspace_id"`
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	Type           string            `json:"type"`
+	Image          string            `json:"image,omitempty"`
+	CPU            *int              `json:"cpu,omitempty"`    // millicores
+	Memory         *int64            `json:"memory,omitempty"` // bytes
+	StartupCommand string            `json:"startup_command,omitempty"`
+	Env            map[string]string `json:"env"`
+	PostStartHooks []TemplateHook    `json:"post_start_hooks"`
+	CreatedBy      string            `json:"created_by,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// TemplateHook is a script run with sh -c in a sandbox after it starts.
+type TemplateHook struct {
+	Name string `json:"name"`
+	Run  string `json:"run"`
+}
+
+const sandboxTemplateColumns = `id, workspace_id, name, description, type, image, cpu, memory, startup_command, env, post_start_hooks, created_by, created_at, updated_at`
+
+func scanSandboxTemplate(row interface{ Scan(...interface{}) error }) (*SandboxTemplate, error) {
+	t := &SandboxTemplate{}
+	var cpu sql.NullInt64
+	var memory sql.NullInt64
+	var env, hooks []byte
+	var createdBy sql.NullString
+	if err := row.Scan(&t.ID, &t.WorkspaceID, &t.Name, &t.Description, &t.Type, &t.Image, &cpu, &memory,
+		&t.StartupCommand, &env, &hooks, &createdBy, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if cpu.Valid {
+		v := int(cpu.Int64)
+		t.CPU = &v
+	}
+	if memory.Valid {
+		t.Memory = &memory.Int64
+	}
+	t.Env = map[string]string{}
+	if err := json.Unmarshal(env, &t.Env); err != nil {
+		return nil, fmt.Errorf("decode env: %w", err)
+	}
+	t.PostStartHooks = []TemplateHook{}
+	if err := json.Unmarshal(hooks, &t.PostStartHooks); err != nil {
+		return nil, fmt.Errorf("decode post-start hooks: %w", err)
+	}
+	t.CreatedBy = createdBy.String
+	return t, nil
+}
+
+func (t *SandboxTemplate) envJSON() []byte {
+	env := t.Env
+	if env == nil {
+		env = map[string]string{}
+	}
+	b, _ := json.Marshal(env)
+	return b
+}
+
+func (t *SandboxTemplate) hooksJSON() []byte {
+	hooks := t.PostStartHooks
+	if hooks == nil {
+		hooks = []TemplateHook{}
+	}
+	b, _ := json.Marshal(hooks)
+	return b
+}
+
+// CreateSandboxTemplate stores a new template. t.CreatedAt and t.UpdatedAt
+// are set from the database.
+func (db *DB) CreateSandboxTemplate(t *SandboxTemplate) error {
+	err := db.QueryRow(
+		`INSERT INTO sandbox_templates
+		 (id, workspace_id, name, description, type, image, cpu, memory, startup_command, env, post_start_hooks, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		 RETURNING created_at, updated_at`,
+		t.ID, t.WorkspaceID, t.Name, t.Description, t.Type, t.Image, t.CPU, t.Memory,
+		t.StartupCommand, t.envJSON(), t.hooksJSON(), nullIfEmpty(t.CreatedBy),
+	).Scan(&t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("create sandbox template: %w", err)
+	}
+	return nil
+}
+
+// UpdateSandboxTemplate replaces a template's fields. t.UpdatedAt is set
+// from the database.
+func (db *DB) UpdateSandboxTemplate(t *SandboxTemplate) error {
+	err := db.QueryRow(
+		`UPDATE sandbox_templates SET
+		   name = $2, description = $3, type = $4, image = $5, cpu = $6, memory = $7,
+		   startup_command = $8, env = $9, post_start_hooks = $10, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING updated_at`,
+		t.ID, t.Name, t.Description, t.Type, t.Image, t.CPU, t.Memory, t.StartupCommand, t.envJSON(),
+		t.hooksJSON(),
+	).Scan(&t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("update sandbox template: %w", err)
+	}
+	return nil
+}
+
+// GetSandboxTemplate returns a template of a workspace, or nil if not
+// found.
+func (db *DB) GetSandboxTemplate(workspaceID, id string) (*SandboxTemplate, error) {
+	t, err := scanSandboxTemplate(db.QueryRow(
+		`SELECT `+sandboxTemplateColumns+` FROM sandbox_templates WHERE workspace_id = $1 AND id = $2`,
+		workspaceID, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox template: %w", err)
+	}
+	return t, nil
+}
+
+// ListSandboxTemplates returns a workspace's templates by name.
+func (db *DB) ListSandboxTemplates(workspaceID string) ([]*SandboxTemplate, error) {
+	rows, err := db.Query(
+		`SELECT `+sandboxTemplateColumns+` FROM sandbox_templates
+		 WHERE workspace_id = $1 ORDER BY name`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []*SandboxTemplate{}
+	for rows.Next() {
+		t, err := scanSandboxTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// DeleteSandboxTemplate removes a template. Sandboxes created from it keep
+// their settings.
+func (db *DB) DeleteSandboxTemplate(workspaceID, id string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM sandbox_templates WHERE workspace_id = $1 AND id = $2`, workspaceID, id)
+	if err != nil {
+		return false, fmt.Errorf("delete sandbox template: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
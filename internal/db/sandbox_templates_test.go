@@ -0,0 +1,70 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxTemplates(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "templates"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	cpu := 2000
+	tmpl := &SandboxTemplate{
+		ID:             uuid.NewString(),
+		WorkspaceID:    wsID,
+		Name:           "node20",
+		Type:           "opencode",
+		Image:          "registry.example.com/node20:1",
+		CPU:            &cpu,
+		Env:            map[string]string{"NODE_ENV": "development"},
+		PostStartHooks: []TemplateHook{{Name: "seed", Run: "make seed"}},
+		CreatedBy:      "u-1",
+	}
+	if err := d.CreateSandboxTemplate(tmpl); err != nil {
+		t.Fatal(err)
+	}
+	dup := *tmpl
+	dup.ID = uuid.NewString()
+	if err := d.CreateSandboxTemplate(&dup); err == nil {
+		t.Error("duplicate template name accepted")
+	}
+
+	got, err := d.GetSandboxTemplate(wsID, tmpl.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Image != tmpl.Image || got.CPU == nil || *got.CPU != 2000 || got.Memory != nil || got.Env["NODE_ENV"] != "development" ||
+		len(got.PostStartHooks) != 1 || got.PostStartHooks[0].Run != "make seed" {
+		t.Fatalf("GetSandboxTemplate = %+v", got)
+	}
+	if other, err := d.GetSandboxTemplate(uuid.NewString(), tmpl.ID); err != nil || other != nil {
+		t.Errorf("template visible from another workspace: %+v, %v", other, err)
+	}
+
+	memory := int64(4 << 30)
+	got.Memory, got.CPU, got.Env, got.StartupCommand = &memory, nil, nil, "exec opencode serve"
+	got.PostStartHooks = nil
+	if err := d.UpdateSandboxTemplate(got); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.ListSandboxTemplates(wsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].CPU != nil || *list[0].Memory != memory || len(list[0].Env) != 0 || len(list[0].PostStartHooks) != 0 || list[0].StartupCommand != "exec opencode serve" {
+		t.Errorf("ListSandboxTemplates after update = %+v", list)
+	}
+
+	if ok, err := d.DeleteSandboxTemplate(wsID, tmpl.ID); err != nil || !ok {
+		t.Errorf("DeleteSandboxTemplate = %v, %v", ok, err)
+	}
+	if ok, _ := d.DeleteSandboxTemplate(wsID, tmpl.ID); ok {
+		t.Error("second delete reported a template")
+	}
+}
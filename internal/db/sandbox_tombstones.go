@@ -0,0 +1,158 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Reasons a sandbox was deleted.
+const (
+	DeletionReasonUser            = "user_request"      // DELETE /api/sandboxes/{id}
+	DeletionReasonWorkspace       = "workspace_deleted" // its workspace was deleted
+	DeletionReasonUserErased      = "user_erased"       // its workspace went with an erased user
+	DeletionReasonPausedRetention = "paused_retention"  // paused longer than the retention period
+	DeletionReasonStartFailed     = "start_failed"      // its container failed to start
+)
+
+// SandboxDeletion says why, and by whom, a sandbox is deleted.
+type SandboxDeletion struct {
+	Reason    string
+	DeletedBy string // user ID; empty when the server deletes it
+}
+
+// SandboxTombstone is the final state of a deleted sandbox.
+type SandboxTombstone struct {
+	SandboxID     string    `json:"sandbox_id"`
+	WorkspaceID   string    `json:"workspace_id"`
+	Name          string    `json:"name"`
+	Type          string    `json:"type"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	DeletedBy     string    `json:"deleted_by,omitempty"`
+	DeletedAt     time.Time `json:"deleted_at"`
+	Reason        string    `json:"reason"`
+	FinalStatus   string    `json:"final_status"`
+	CPU           int       `json:"cpu"`    // millicores
+	Memory        int64     `json:"memory"` // bytes
+	ComputeHours  float64   `json:"compute_hours"`
+	CPUHours      float64   `json:"cpu_hours"`
+	MemoryGBHours float64   `json:"memory_gb_hours"`
+}
+
+// SandboxTombstoneFilter selects tombstones. Zero fields match all.
+type SandboxTombstoneFilter struct {
+	WorkspaceID string
+	Reason      string
+	Since       time.Time
+	Limit       int // default 100, at most 1000
+}
+
+// insertSandboxTombstones records the sandboxes matched by where (over
+// sandboxes s) as deleted, with their compute totals. A sandbox that
+// already has a tombstone keeps it.
+const insertSandboxTombstones = `
+	INSERT INTO sandbox_tombstones
+	  (sandbox_id, workspace_id, name, type, created_by, created_at, deleted_by, reason, final_status,
+	   cpu, memory, compute_hours, cpu_hours, memory_gb_hours)
+	SELECT s.id, s.workspace_id, s.name, s.type, s.created_by, s.created_at, $2::text, $3::text, s.status,
+	       COALESCE(s.cpu, 0), COALESCE(s.memory, 0),
+	       COALESCE(u.hours, 0), COALESCE(u.cpu_hours, 0), COALESCE(u.memory_gb_hours, 0)
+	FROM sandboxes s
+	LEFT JOIN LATERAL (
+	  SELECT SUM(h) AS hours, SUM(h * cpu / 1000.0) AS cpu_hours, SUM(h * memory / 1073741824.0) AS memory_gb_hours
+	  FROM (SELECT cpu, memory, EXTRACT(EPOCH FROM COALESCE(stopped_at, NOW()) - started_at) / 3600 AS h
+	        FROM sandbox_runs WHERE sandbox_id = s.id) r
+	) u ON TRUE
+	WHERE s.%s = $1
+	ON CONFLICT (sandbox_id) DO NOTHING`
+
+// RecordSandboxTombstone records the final state of a sandbox that is
+// about to be deleted.
+func (db *DB) RecordSandboxTombstone(sandboxID string, del SandboxDeletion) error {
+	_, err := db.Exec(fmt.Sprintf(insertSandboxTombstones, "id"), sandboxID, nullIfEmpty(del.DeletedBy), del.Reason)
+	if err != nil {
+		return fmt.Errorf("record sandbox tombstone: %w", err)
+	}
+	return nil
+}
+
+// RecordWorkspaceSandboxTombstones records the final state of all
+// sandboxes of a workspace that is about to be deleted.
+func (db *DB) RecordWorkspaceSandboxTombstones(workspaceID string, del SandboxDeletion) error {
+	_, err := db.Exec(fmt.Sprintf(insertSandboxTombstones, "workspace_id"), workspaceID, nullIfEmpty(del.DeletedBy), del.Reason)
+	if err != nil {
+		return fmt.Errorf("record workspace sandbox tombstones: %w", err)
+	}
+	return nil
+}
+
+const sandboxTombstoneColumns = `sandbox_id, workspace_id, name, type, created_by, created_at, deleted_by, deleted_at,
+	reason, final_status, cpu, memory, compute_hours, cpu_hours, memory_gb_hours`
+
+func scanSandboxTombstone(row interface{ Scan(...interface{}) error }) (*SandboxTombstone, error) {
+	t := &SandboxTombstone{}
+	var createdBy, deletedBy sql.NullString
+	if err := row.Scan(&t.SandboxID, &t.WorkspaceID, &t.Name, &t.Type, &createdBy, &t.CreatedAt, &deletedBy, &t.DeletedAt,
+		&t.Reason, &t.FinalStatus, &t.CPU, &t.Memory, &t.ComputeHours, &t.CPUHours, &t.MemoryGBHours); err != nil {
+		return nil, err
+	}
+	t.CreatedBy, t.DeletedBy = createdBy.String, deletedBy.String
+	return t, nil
+}
+
+// GetSandboxTombstone returns the tombstone of a deleted sandbox, or nil.
+func (db *DB) GetSandboxTombstone(sandboxID string) (*SandboxTombstone, error) {
+	t, err := scanSandboxTombstone(db.QueryRow(
+		`SELECT `+sandboxTombstoneColumns+` FROM sandbox_tombstones WHERE sandbox_id = $1`, sandboxID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox tombstone: %w", err)
+	}
+	return t, nil
+}
+
+// ListSandboxTombstones returns tombstones, most recently deleted first.
+func (db *DB) ListSandboxTombstones(f SandboxTombstoneFilter) ([]*SandboxTombstone, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	rows, err := db.Query(
+		`SELECT `+sandboxTombstoneColumns+` FROM sandbox_tombstones
+		 WHERE ($1 = '' OR workspace_id = $1)
+		   AND ($2 = '' OR reason = $2)
+		   AND deleted_at >= $3
+		 ORDER BY deleted_at DESC
+		 LIMIT $4`,
+		f.WorkspaceID, f.Reason, f.Since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox tombstones: %w", err)
+	}
+	defer rows.Close()
+
+	tombstones := []*SandboxTombstone{}
+	for rows.Next() {
+		t, err := scanSandboxTombstone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sandbox tombstone: %w", err)
+		}
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, rows.Err()
+}
+
+// DeleteSandboxTombstonesBefore deletes tombstones of sandboxes deleted
+// before cutoff.
+func (db *DB) DeleteSandboxTombstonesBefore(cutoff time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM sandbox_tombstones WHERE deleted_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("delete sandbox tombstones: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSandboxTombstones(t *testing.T) {
+	d := newTestDB(t)
+	wsID, sbxID, otherID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "tombstones"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM sandbox_tombstones WHERE workspace_id = $1`, wsID)
+	})
+	for _, id := range []string{sbxID, otherID} {
+		if err := d.CreateSandbox(id, wsID, "sbx", "opencode", "", "", "", "", "", 2000, 1<<30, nil, json.RawMessage(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two hours of running at 2 vCPUs and 1 GiB.
+	if _, err := d.Exec(
+		`INSERT INTO sandbox_runs (sandbox_id, workspace_id, cpu, memory, started_at, stopped_at)
+		 VALUES ($1, $2, 2000, 1073741824, NOW() - INTERVAL '3 hours', NOW() - INTERVAL '1 hour')`,
+		sbxID, wsID); err != nil {
+		t.Fatal(err)
+	}
+
+	del := SandboxDeletion{Reason: DeletionReasonUser}
+	if err := d.RecordSandboxTombstone(sbxID, del); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteSandbox(sbxID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.GetSandboxTombstone(sbxID)
+	if err != nil || got == nil {
+		t.Fatalf("GetSandboxTombstone = %+v, %v", got, err)
+	}
+	if got.Reason != DeletionReasonUser || got.DeletedBy != "" || got.FinalStatus != "creating" || got.CPU != 2000 {
+		t.Errorf("tombstone = %+v", got)
+	}
+	if got.ComputeHours < 1.99 || got.ComputeHours > 2.01 || got.CPUHours < 3.99 || got.MemoryGBHours < 1.99 {
+		t.Errorf("usage = %v h, %v vCPU-h, %v GiB-h; want 2, 4, 2", got.ComputeHours, got.CPUHours, got.MemoryGBHours)
+	}
+
+	if err := d.RecordWorkspaceSandboxTombstones(wsID, SandboxDeletion{Reason: DeletionReasonWorkspace}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteWorkspace(wsID); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.ListSandboxTombstones(SandboxTombstoneFilter{WorkspaceID: wsID})
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListSandboxTombstones = %+v, %v; want both sandboxes after the workspace is deleted", list, err)
+	}
+	if list, _ := d.ListSandboxTombstones(SandboxTombstoneFilter{WorkspaceID: wsID, Reason: DeletionReasonWorkspace}); len(list) != 1 || list[0].SandboxID != otherID {
+		t.Errorf("by reason = %+v, want the other sandbox", list)
+	}
+
+	if _, err := d.Exec(`UPDATE sandbox_tombstones SET deleted_at = NOW() - INTERVAL '91 days' WHERE sandbox_id = $1`, sbxID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.DeleteSandboxTombstonesBefore(time.Now().Add(-90 * 24 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("DeleteSandboxTombstonesBefore = %d, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateSandbox(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "update"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	idle := 600
+	if err := d.CreateSandbox(sbxID, wsID, "before", "opencode", "", "", "", "", "", 0, 0, &idle,
+		json.RawMessage(`{"region":"eu","description":"old"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "after"
+	err := d.UpdateSandbox(sbxID, SandboxUpdate{
+		Name:     &name,
+		Metadata: map[string]interface{}{"labels": map[string]string{"team": "a"}, "description": nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := d.GetSandbox(sbxID)
+	var meta map[string]interface{}
+	json.Unmarshal(s.Metadata, &meta)
+	if s.Name != "after" || s.IdleTimeout == nil || *s.IdleTimeout != 600 {
+		t.Errorf("name %q, idle timeout %v", s.Name, s.IdleTimeout)
+	}
+	if _, ok := meta["description"]; ok || meta["region"] != "eu" || meta["labels"] == nil {
+		t.Errorf("metadata = %v", meta)
+	}
+
+	// Clearing the idle timeout leaves the rest alone.
+	if err := d.UpdateSandbox(sbxID, SandboxUpdate{SetIdleTimeout: true}); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = d.GetSandbox(sbxID)
+	if s.IdleTimeout != nil || s.Name != "after" || len(s.Metadata) == 0 {
+		t.Errorf("after clearing idle timeout: %+v", s)
+	}
+}
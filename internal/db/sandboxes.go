@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Sandbox struct {
@@ -30,6 +32,10 @@ type Sandbox struct {
 	Memory      *int64
 	IdleTimeout *int
 	Metadata    json.RawMessage
+	QuarantinedAt sql.NullTime
+	StatusMessage sql.NullString
+	PinnedAt      sql.NullTime
+	CreatedBy     sql.NullString
 }
 
 func (db *DB) CreateSandbox(id, workspaceID, name, sandboxType, sandboxName, opencodeToken, proxyToken, openclawToken, shortID string, cpu int, memory int64, idleTimeout *int, metadata json.RawMessage) error {
@@ -65,11 +71,11 @@ func (db *DB) CreateSandbox(id, workspaceID, name, sandboxType, sandboxName, ope
 }
 
 // sandboxColumns is the list of columns selected for sandbox queries.
-const sandboxColumns = `id, workspace_id, name, type, status, is_local, short_id, sandbox_name, pod_ip, proxy_token, opencode_token, openclaw_token, tunnel_token, last_activity_at, created_at, paused_at, last_heartbeat_at, cpu, memory, idle_timeout, nanoclaw_bridge_secret, metadata`
+const sandboxColumns = `id, workspace_id, name, type, status, is_local, short_id, sandbox_name, pod_ip, proxy_token, opencode_token, openclaw_token, tunnel_token, last_activity_at, created_at, paused_at, last_heartbeat_at, cpu, memory, idle_timeout, nanoclaw_bridge_secret, metadata, quarantined_at, status_message, pinned_at, created_by`
 
 func scanSandbox(scanner interface{ Scan(...interface{}) error }) (*Sandbox, error) {
 	s := &Sandbox{}
-	err := scanner.Scan(&s.ID, &s.WorkspaceID, &s.Name, &s.Type, &s.Status, &s.IsLocal, &s.ShortID, &s.SandboxName, &s.PodIP, &s.ProxyToken, &s.OpencodeToken, &s.OpenclawToken, &s.TunnelToken, &s.LastActivityAt, &s.CreatedAt, &s.PausedAt, &s.LastHeartbeatAt, &s.CPU, &s.Memory, &s.IdleTimeout, &s.NanoclawBridgeSecret, &s.Metadata)
+	err := scanner.Scan(&s.ID, &s.WorkspaceID, &s.Name, &s.Type, &s.Status, &s.IsLocal, &s.ShortID, &s.SandboxName, &s.PodIP, &s.ProxyToken, &s.OpencodeToken, &s.OpenclawToken, &s.TunnelToken, &s.LastActivityAt, &s.CreatedAt, &s.PausedAt, &s.LastHeartbeatAt, &s.CPU, &s.Memory, &s.IdleTimeout, &s.NanoclawBridgeSecret, &s.Metadata, &s.QuarantinedAt, &s.StatusMessage, &s.PinnedAt, &s.CreatedBy)
 	return s, err
 }
 
@@ -125,7 +131,7 @@ func (db *DB) DeleteSandbox(id string) error {
 	if err != nil {
 		return fmt.Errorf("delete sandbox: %w", err)
 	}
-	return nil
+	return db.closeSandboxRun(id)
 }
 
 func (db *DB) UpdateSandboxName(id, name string) error {
@@ -136,20 +142,122 @@ func (db *DB) UpdateSandboxName(id, name string) error {
 	return nil
 }
 
+// SandboxUpdate is a partial update of a sandbox's user-editable fields;
+// nil fields are unchanged.
+type SandboxUpdate struct {
+	Name *string
+	// IdleTimeout sets the idle timeout override if SetIdleTimeout; nil
+	// clears it.
+	SetIdleTimeout bool
+	IdleTimeout    *int
+	// Metadata sets metadata keys; nil values remove the key.
+	Metadata map[string]interface{}
+}
+
+// UpdateSandbox applies u in one statement.
+func (db *DB) UpdateSandbox(id string, u SandboxUpdate) error {
+	set := map[string]interface{}{}
+	remove := []string{}
+	for k, v := range u.Metadata {
+		if v == nil {
+			remove = append(remove, k)
+		} else {
+			set[k] = v
+		}
+	}
+	setJSON, err := json.Marshal(set)
+	if err != nil {
+		return fmt.Errorf("update sandbox: encode metadata: %w", err)
+	}
+	_, err = db.Exec(
+		`UPDATE sandboxes SET
+		   name = COALESCE($2, name),
+		   idle_timeout = CASE WHEN $3 THEN $4 ELSE idle_timeout END,
+		   metadata = (COALESCE(metadata, '{}'::jsonb) || $5::jsonb) - $6::text[]
+		 WHERE id = $1`,
+		id, u.Name, u.SetIdleTimeout, u.IdleTimeout, string(setJSON), pq.Array(remove),
+	)
+	if err != nil {
+		return fmt.Errorf("update sandbox: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) UpdateSandboxStatus(id, status string) error {
 	var query string
 	switch status {
 	case "paused":
-		query = "UPDATE sandboxes SET status = $2, paused_at = NOW() WHERE id = $1"
+		query = "UPDATE sandboxes SET status = $2, status_message = NULL, paused_at = NOW() WHERE id = $1"
 	case "running":
-		query = "UPDATE sandboxes SET status = $2, paused_at = NULL WHERE id = $1"
+		query = "UPDATE sandboxes SET status = $2, status_message = NULL, paused_at = NULL WHERE id = $1"
 	default:
-		query = "UPDATE sandboxes SET status = $2 WHERE id = $1"
+		query = "UPDATE sandboxes SET status = $2, status_message = NULL WHERE id = $1"
 	}
 	_, err := db.Exec(query, id, status)
 	if err != nil {
 		return fmt.Errorf("update sandbox status: %w", err)
 	}
+	return db.recordSandboxRun(id, status)
+}
+
+// UpdateSandboxStatusMessage sets the status together with a detail message,
+// e.g. the error that moved the sandbox into a failed state.
+func (db *DB) UpdateSandboxStatusMessage(id, status, message string) error {
+	_, err := db.Exec(
+		"UPDATE sandboxes SET status = $2, status_message = $3 WHERE id = $1",
+		id, status, nullIfEmpty(message),
+	)
+	if err != nil {
+		return fmt.Errorf("update sandbox status message: %w", err)
+	}
+	return db.recordSandboxRun(id, status)
+}
+
+// ListUserSandboxIDs returns the sandboxes a user created and those of the
+// workspaces they are the only member of.
+func (db *DB) ListUserSandboxIDs(userID string) ([]string, error) {
+	rows, err := db.Query(
+		`SELECT id FROM sandboxes
+		 WHERE created_by = $1
+		    OR workspace_id IN (SELECT workspace_id FROM workspace_members
+		                        GROUP BY workspace_id HAVING COUNT(*) = 1 AND bool_and(user_id = $1))`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("list user sandboxes: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan sandbox id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetSandboxCreatedBy records the user who created a sandbox.
+func (db *DB) SetSandboxCreatedBy(id, userID string) error {
+	if _, err := db.Exec("UPDATE sandboxes SET created_by = $2 WHERE id = $1", id, nullIfEmpty(userID)); err != nil {
+		return fmt.Errorf("set sandbox created by: %w", err)
+	}
+	return nil
+}
+
+// SetSandboxPinned pins a sandbox, exempting it from idle pause and
+// automatic cleanup, or unpins it.
+func (db *DB) SetSandboxPinned(id string, pinned bool, actorID string) error {
+	var err error
+	if pinned {
+		_, err = db.Exec("UPDATE sandboxes SET pinned_at = NOW(), pinned_by = $2 WHERE id = $1", id, nullIfEmpty(actorID))
+	} else {
+		_, err = db.Exec("UPDATE sandboxes SET pinned_at = NULL, pinned_by = NULL WHERE id = $1", id)
+	}
+	if err != nil {
+		return fmt.Errorf("set sandbox pinned: %w", err)
+	}
 	return nil
 }
 
@@ -174,6 +282,19 @@ func (db *DB) UpdateSandboxPodIP(id, podIP string) error {
 	return nil
 }
 
+// SetSandboxMetadataString sets a string key of a sandbox's metadata, or
+// removes the key if value is empty.
+func (db *DB) SetSandboxMetadataString(id, key, value string) error {
+	_, err := db.Exec(
+		`UPDATE sandboxes SET metadata = CASE WHEN $3 = '' THEN metadata - $2
+		 ELSE jsonb_set(metadata, ARRAY[$2], to_jsonb($3::text)) END
+		 WHERE id = $1`, id, key, value)
+	if err != nil {
+		return fmt.Errorf("set sandbox metadata: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) UpdateSandboxSandboxName(id, sandboxName string) error {
 	_, err := db.Exec("UPDATE sandboxes SET sandbox_name = $2 WHERE id = $1", id, sandboxName)
 	if err != nil {
@@ -183,13 +304,18 @@ func (db *DB) UpdateSandboxSandboxName(id, sandboxName string) error {
 }
 
 func (db *DB) ListIdleSandboxes(defaultTimeoutSeconds int) ([]*Sandbox, error) {
+	return db.ListIdleSandboxesAt(time.Now(), defaultTimeoutSeconds)
+}
+
+// ListIdleSandboxesAt is ListIdleSandboxes with idleness measured at now.
+func (db *DB) ListIdleSandboxesAt(now time.Time, defaultTimeoutSeconds int) ([]*Sandbox, error) {
 	rows, err := db.Query(
 		`SELECT `+sandboxColumns+`
 		 FROM sandboxes
-		 WHERE status = 'running' AND is_local = FALSE
+		 WHERE status = 'running' AND is_local = FALSE AND quarantined_at IS NULL AND pinned_at IS NULL
 		   AND COALESCE(idle_timeout, $1) > 0
-		   AND last_activity_at < NOW() - (COALESCE(idle_timeout, $1) || ' seconds')::interval`,
-		defaultTimeoutSeconds,
+		   AND last_activity_at < $2::timestamptz - (COALESCE(idle_timeout, $1) || ' seconds')::interval`,
+		defaultTimeoutSeconds, now,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list idle sandboxes: %w", err)
@@ -371,6 +497,54 @@ func (db *DB) ConsumeAgentRegistrationCode(code string) (*AgentRegistrationCode,
 }
 
 // UpdateSandboxNanoclawBridgeSecret stores the bridge secret for a nanoclaw sandbox.
+// SetSandboxStartOptions stores the start options (JSON) of a sandbox
+// waiting for a storage or start retry.
+func (db *DB) SetSandboxStartOptions(id string, opts json.RawMessage) error {
+	_, err := db.Exec("UPDATE sandboxes SET start_options = $2 WHERE id = $1", id, opts)
+	if err != nil {
+		return fmt.Errorf("set sandbox start options: %w", err)
+	}
+	return nil
+}
+
+// ClearSandboxStartOptions removes the stored start options of a sandbox.
+func (db *DB) ClearSandboxStartOptions(id string) error {
+	_, err := db.Exec("UPDATE sandboxes SET start_options = NULL WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("clear sandbox start options: %w", err)
+	}
+	return nil
+}
+
+// GetSandboxStartOptions returns the stored start options of a sandbox, or
+// nil if there are none.
+func (db *DB) GetSandboxStartOptions(id string) (json.RawMessage, error) {
+	var opts []byte
+	err := db.QueryRow("SELECT start_options FROM sandboxes WHERE id = $1", id).Scan(&opts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox start options: %w", err)
+	}
+	return opts, nil
+}
+
+// FailInterruptedSandboxStorage marks sandboxes still provisioning their
+// workspace drive storage-failed, so they can be retried. Provisioning runs
+// inside the server process, so at startup any such sandbox was interrupted
+// by a restart. It returns the number marked.
+func (db *DB) FailInterruptedSandboxStorage() (int64, error) {
+	res, err := db.Exec(
+		`UPDATE sandboxes
+		 SET status = 'storage-failed', status_message = 'workspace drive provisioning interrupted by a server restart'
+		 WHERE status = 'provisioning-storage'`)
+	if err != nil {
+		return 0, fmt.Errorf("fail interrupted sandbox storage: %w", err)
+	}
+	return res.RowsAffected()
+}
+
 func (db *DB) UpdateSandboxNanoclawBridgeSecret(id, secret string) error {
 	_, err := db.Exec(
 		`UPDATE sandboxes SET nanoclaw_bridge_secret = $1 WHERE id = $2`,
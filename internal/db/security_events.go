@@ -0,0 +1,93 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SecurityEvent is a row of the append-only security event log.
+type SecurityEvent struct {
+	ID        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"` // info, warning or critical
+	ActorID   string                 `json:"actor_id,omitempty"`
+	TargetID  string                 `json:"target_id,omitempty"`
+	IP        string                 `json:"ip,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// SecurityEventFilter selects security events; zero fields match all.
+type SecurityEventFilter struct {
+	Type     string
+	ActorID  string
+	TargetID string
+	Since    time.Time
+	Limit    int // default 100, at most 1000
+}
+
+// RecordSecurityEvent appends an event and sets its ID and time.
+func (db *DB) RecordSecurityEvent(ev *SecurityEvent) error {
+	details := ev.Details
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("record security event: marshal details: %w", err)
+	}
+	err = db.QueryRow(
+		`INSERT INTO security_events (event_type, severity, actor_id, target_id, ip, details)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, created_at`,
+		ev.Type, ev.Severity, nullIfEmpty(ev.ActorID), nullIfEmpty(ev.TargetID), nullIfEmpty(ev.IP), detailsJSON,
+	).Scan(&ev.ID, &ev.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("record security event: %w", err)
+	}
+	return nil
+}
+
+// ListSecurityEvents returns the events matching f, newest first.
+func (db *DB) ListSecurityEvents(f SecurityEventFilter) ([]*SecurityEvent, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	rows, err := db.Query(
+		`SELECT id, event_type, severity, actor_id, target_id, ip, details, created_at
+		 FROM security_events
+		 WHERE ($1 = '' OR event_type = $1)
+		   AND ($2 = '' OR actor_id = $2)
+		   AND ($3 = '' OR target_id = $3)
+		   AND created_at >= $4
+		 ORDER BY created_at DESC, id DESC
+		 LIMIT $5`,
+		f.Type, f.ActorID, f.TargetID, f.Since, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list security events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*SecurityEvent{}
+	for rows.Next() {
+		ev := &SecurityEvent{}
+		var actorID, targetID, ip sql.NullString
+		var detailsJSON []byte
+		if err := rows.Scan(&ev.ID, &ev.Type, &ev.Severity, &actorID, &targetID, &ip, &detailsJSON, &ev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan security event: %w", err)
+		}
+		ev.ActorID, ev.TargetID, ev.IP = actorID.String, targetID.String, ip.String
+		if err := json.Unmarshal(detailsJSON, &ev.Details); err != nil {
+			return nil, fmt.Errorf("unmarshal security event details: %w", err)
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SessionHistoryExport is an export of a sandbox's opencode session storage.
+type SessionHistoryExport struct {
+	ID           string    `json:"id"`
+	WorkspaceID  string    `json:"workspace_id"`
+	SandboxID    string    `json:"sandbox_id"`
+	SandboxName  string    `json:"sandbox_name"`
+	Store        string    `json:"store"`
+	ObjectKey    string    `json:"-"`
+	SizeBytes    int64     `json:"size_bytes"`
+	SHA256       string    `json:"sha256"`
+	SessionCount int       `json:"session_count"`
+	CreatedBy    string    `json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+const sessionHistoryColumns = `id, workspace_id, sandbox_id, sandbox_name, store, object_key, size_bytes, sha256, session_count, created_by, created_at`
+
+func scanSessionHistoryExport(row interface{ Scan(...interface{}) error }) (*SessionHistoryExport, error) {
+	e := &SessionHistoryExport{}
+	var createdBy sql.NullString
+	if err := row.Scan(&e.ID, &e.WorkspaceID, &e.SandboxID, &e.SandboxName, &e.Store, &e.ObjectKey,
+		&e.SizeBytes, &e.SHA256, &e.SessionCount, &createdBy, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.CreatedBy = createdBy.String
+	return e, nil
+}
+
+// CreateSessionHistoryExport records an export whose archive has been
+// written to the store. e.CreatedAt is set from the database.
+func (db *DB) CreateSessionHistoryExport(e *SessionHistoryExport) error {
+	err := db.QueryRow(
+		`INSERT INTO session_history_exports
+		 (id, workspace_id, sandbox_id, sandbox_name, store, object_key, size_bytes, sha256, session_count, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING created_at`,
+		e.ID, e.WorkspaceID, e.SandboxID, e.SandboxName, e.Store, e.ObjectKey, e.SizeBytes, e.SHA256,
+		e.SessionCount, nullIfEmpty(e.CreatedBy),
+	).Scan(&e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create session history export: %w", err)
+	}
+	return nil
+}
+
+// GetSessionHistoryExport returns an export by ID, or nil if not found.
+func (db *DB) GetSessionHistoryExport(id string) (*SessionHistoryExport, error) {
+	e, err := scanSessionHistoryExport(db.QueryRow(
+		`SELECT `+sessionHistoryColumns+` FROM session_history_exports WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session history export: %w", err)
+	}
+	return e, nil
+}
+
+// LatestSessionHistoryExport returns the newest export of a sandbox, or nil
+// if it has none.
+func (db *DB) LatestSessionHistoryExport(sandboxID string) (*SessionHistoryExport, error) {
+	e, err := scanSessionHistoryExport(db.QueryRow(
+		`SELECT `+sessionHistoryColumns+` FROM session_history_exports
+		 WHERE sandbox_id = $1 ORDER BY created_at DESC, id LIMIT 1`, sandboxID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest session history export: %w", err)
+	}
+	return e, nil
+}
+
+// ListSessionHistoryExports returns a workspace's exports, newest first,
+// optionally only those of one sandbox. Exports of deleted sandboxes are
+// included.
+func (db *DB) ListSessionHistoryExports(workspaceID, sandboxID string) ([]*SessionHistoryExport, error) {
+	rows, err := db.Query(
+		`SELECT `+sessionHistoryColumns+` FROM session_history_exports
+		 WHERE workspace_id = $1 AND ($2 = '' OR sandbox_id = $2)
+		 ORDER BY created_at DESC, id`, workspaceID, sandboxID)
+	if err != nil {
+		return nil, fmt.Errorf("list session history exports: %w", err)
+	}
+	defer rows.Close()
+	return scanSessionHistoryExports(rows)
+}
+
+// ExpiredSessionHistoryExports returns the exports of a sandbox beyond the
+// newest keep, oldest first.
+func (db *DB) ExpiredSessionHistoryExports(sandboxID string, keep int) ([]*SessionHistoryExport, error) {
+	rows, err := db.Query(
+		`SELECT `+sessionHistoryColumns+` FROM session_history_exports
+		 WHERE sandbox_id = $1 ORDER BY created_at DESC, id OFFSET $2`, sandboxID, keep)
+	if err != nil {
+		return nil, fmt.Errorf("list expired session history exports: %w", err)
+	}
+	defer rows.Close()
+	exports, err := scanSessionHistoryExports(rows)
+	for i, j := 0, len(exports)-1; i < j; i, j = i+1, j-1 {
+		exports[i], exports[j] = exports[j], exports[i]
+	}
+	return exports, err
+}
+
+func scanSessionHistoryExports(rows *sql.Rows) ([]*SessionHistoryExport, error) {
+	exports := []*SessionHistoryExport{}
+	for rows.Next() {
+		e, err := scanSessionHistoryExport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan session history export: %w", err)
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// DeleteSessionHistoryExport removes an export record. The archive must be
+// deleted from its store separately.
+func (db *DB) DeleteSessionHistoryExport(id string) error {
+	if _, err := db.Exec(`DELETE FROM session_history_exports WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("delete session history export: %w", err)
+	}
+	return nil
+}
+
+// PutSessionHistoryBlob stores an archive for the database store.
+func (db *DB) PutSessionHistoryBlob(key string, data []byte) error {
+	_, err := db.Exec(
+		`INSERT INTO session_history_blobs (key, data) VALUES ($1, $2)
+		 ON CONFLICT (key) DO UPDATE SET data = EXCLUDED.data`, key, data)
+	if err != nil {
+		return fmt.Errorf("put session history blob: %w", err)
+	}
+	return nil
+}
+
+// GetSessionHistoryBlob returns an archive of the database store, or nil if
+// not found.
+func (db *DB) GetSessionHistoryBlob(key string) ([]byte, error) {
+	var data []byte
+	err := db.QueryRow(`SELECT data FROM session_history_blobs WHERE key = $1`, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session history blob: %w", err)
+	}
+	return data, nil
+}
+
+// DeleteSessionHistoryBlob removes an archive of the database store.
+func (db *DB) DeleteSessionHistoryBlob(key string) error {
+	if _, err := db.Exec(`DELETE FROM session_history_blobs WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("delete session history blob: %w", err)
+	}
+	return nil
+}
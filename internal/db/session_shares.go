@@ -0,0 +1,93 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type SessionShare struct {
+	ID          string
+	WorkspaceID string
+	SandboxID   string
+	SandboxName string
+	SessionID   string
+	Title       string
+	Transcript  []byte // opencode messages (JSON); not loaded by ListSessionShares
+	CreatedBy   *string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// UpsertSessionShare stores a transcript snapshot. If the session was shared
+// before, the snapshot is replaced and the existing share ID is returned, so
+// links stay stable.
+func (db *DB) UpsertSessionShare(id, workspaceID, sandboxID, sandboxName, sessionID, title string, transcript []byte, createdBy string) (string, error) {
+	var shareID string
+	err := db.QueryRow(
+		`INSERT INTO session_shares (id, workspace_id, sandbox_id, sandbox_name, session_id, title, transcript, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+		 ON CONFLICT (sandbox_id, session_id) DO UPDATE SET
+		   sandbox_name = EXCLUDED.sandbox_name,
+		   title = EXCLUDED.title,
+		   transcript = EXCLUDED.transcript,
+		   updated_at = NOW()
+		 RETURNING id`,
+		id, workspaceID, sandboxID, sandboxName, sessionID, title, transcript, createdBy,
+	).Scan(&shareID)
+	if err != nil {
+		return "", fmt.Errorf("upsert session share: %w", err)
+	}
+	return shareID, nil
+}
+
+func (db *DB) GetSessionShare(id string) (*SessionShare, error) {
+	s := &SessionShare{}
+	err := db.QueryRow(
+		`SELECT id, workspace_id, sandbox_id, sandbox_name, session_id, title, transcript, created_by, created_at, updated_at
+		 FROM session_shares WHERE id = $1`,
+		id,
+	).Scan(&s.ID, &s.WorkspaceID, &s.SandboxID, &s.SandboxName, &s.SessionID, &s.Title, &s.Transcript,
+		&s.CreatedBy, &s.CreatedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get session share: %w", err)
+	}
+	return s, nil
+}
+
+// ListSessionShares returns a workspace's shares, newest first, without
+// their transcripts.
+func (db *DB) ListSessionShares(workspaceID string) ([]SessionShare, error) {
+	rows, err := db.Query(
+		`SELECT id, workspace_id, sandbox_id, sandbox_name, session_id, title, created_by, created_at, updated_at
+		 FROM session_shares WHERE workspace_id = $1
+		 ORDER BY created_at DESC`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list session shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []SessionShare
+	for rows.Next() {
+		var s SessionShare
+		if err := rows.Scan(&s.ID, &s.WorkspaceID, &s.SandboxID, &s.SandboxName, &s.SessionID, &s.Title,
+			&s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan session share: %w", err)
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
+
+func (db *DB) DeleteSessionShare(id string) error {
+	_, err := db.Exec("DELETE FROM session_shares WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("delete session share: %w", err)
+	}
+	return nil
+}
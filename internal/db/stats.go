@@ -0,0 +1,81 @@
+package db
+
+import "fmt"
+
+// supportStatsTables are counted in SupportStats.
+var supportStatsTables = []string{
+	"users",
+	"auth_tokens",
+	"workspaces",
+	"workspace_members",
+	"sandboxes",
+	"proxy_tokens",
+	"credential_bindings",
+	"workspace_im_channels",
+	"agent_sessions",
+	"agent_tasks",
+	"operations",
+}
+
+// SupportStats summarizes the database for a support bundle. It holds
+// counts only, never row contents.
+type SupportStats struct {
+	LatestMigration   string           `json:"latest_migration"`
+	MigrationsApplied int              `json:"migrations_applied"`
+	TableRows         map[string]int64 `json:"table_rows"`
+	SandboxesByStatus map[string]int64 `json:"sandboxes_by_status"`
+	SandboxesByType   map[string]int64 `json:"sandboxes_by_type"`
+	SystemSettingKeys []string         `json:"system_setting_keys"`
+}
+
+func (db *DB) SupportStats() (*SupportStats, error) {
+	st := &SupportStats{
+		TableRows:         make(map[string]int64),
+		SandboxesByStatus: make(map[string]int64),
+		SandboxesByType:   make(map[string]int64),
+	}
+	if err := db.QueryRow(
+		"SELECT COALESCE(MAX(version), ''), COUNT(*) FROM schema_migrations",
+	).Scan(&st.LatestMigration, &st.MigrationsApplied); err != nil {
+		return nil, fmt.Errorf("support stats: migrations: %w", err)
+	}
+	for _, table := range supportStatsTables {
+		var n int64
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			return nil, fmt.Errorf("support stats: count %s: %w", table, err)
+		}
+		st.TableRows[table] = n
+	}
+	for column, counts := range map[string]map[string]int64{
+		"status": st.SandboxesByStatus,
+		"type":   st.SandboxesByType,
+	} {
+		rows, err := db.Query("SELECT " + column + ", COUNT(*) FROM sandboxes GROUP BY " + column)
+		if err != nil {
+			return nil, fmt.Errorf("support stats: sandboxes by %s: %w", column, err)
+		}
+		for rows.Next() {
+			var key string
+			var n int64
+			if err := rows.Scan(&key, &n); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("support stats: sandboxes by %s: %w", column, err)
+			}
+			counts[key] = n
+		}
+		rows.Close()
+	}
+	rows, err := db.Query("SELECT key FROM system_settings ORDER BY key")
+	if err != nil {
+		return nil, fmt.Errorf("support stats: system settings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("support stats: system settings: %w", err)
+		}
+		st.SystemSettingKeys = append(st.SystemSettingKeys, key)
+	}
+	return st, rows.Err()
+}
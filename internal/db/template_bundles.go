@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TemplateBundle is an imported sandbox template bundle.
+type TemplateBundle struct {
+	Name       string
+	Version    string
+	Bundle     []byte // JSON document
+	SignedBy   string
+	ImportedBy string
+	ImportedAt time.Time
+}
+
+const templateBundleColumns = `name, version, bundle, signed_by, imported_by, imported_at`
+
+func scanTemplateBundle(row interface{ Scan(...interface{}) error }) (*TemplateBundle, error) {
+	t := &TemplateBundle{}
+	var version, signedBy, importedBy sql.NullString
+	if err := row.Scan(&t.Name, &version, &t.Bundle, &signedBy, &importedBy, &t.ImportedAt); err != nil {
+		return nil, err
+	}
+	t.Version, t.SignedBy, t.ImportedBy = version.String, signedBy.String, importedBy.String
+	return t, nil
+}
+
+// UpsertTemplateBundle stores an imported bundle, replacing an earlier
+// import of the same name.
+func (db *DB) UpsertTemplateBundle(name, version string, bundle []byte, signedBy, importedBy string) error {
+	_, err := db.Exec(
+		`INSERT INTO template_bundles (name, version, bundle, signed_by, imported_by)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (name) DO UPDATE SET
+		   version = EXCLUDED.version,
+		   bundle = EXCLUDED.bundle,
+		   signed_by = EXCLUDED.signed_by,
+		   imported_by = EXCLUDED.imported_by,
+		   imported_at = NOW()`,
+		name, nullIfEmpty(version), bundle, nullIfEmpty(signedBy), nullIfEmpty(importedBy),
+	)
+	if err != nil {
+		return fmt.Errorf("upsert template bundle: %w", err)
+	}
+	return nil
+}
+
+// GetTemplateBundle returns an imported bundle, or nil if not found.
+func (db *DB) GetTemplateBundle(name string) (*TemplateBundle, error) {
+	t, err := scanTemplateBundle(db.QueryRow(
+		`SELECT `+templateBundleColumns+` FROM template_bundles WHERE name = $1`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get template bundle: %w", err)
+	}
+	return t, nil
+}
+
+// ListTemplateBundles returns the imported bundles by name.
+func (db *DB) ListTemplateBundles() ([]*TemplateBundle, error) {
+	rows, err := db.Query(`SELECT ` + templateBundleColumns + ` FROM template_bundles ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list template bundles: %w", err)
+	}
+	defer rows.Close()
+
+	var bundles []*TemplateBundle
+	for rows.Next() {
+		t, err := scanTemplateBundle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan template bundle: %w", err)
+		}
+		bundles = append(bundles, t)
+	}
+	return bundles, rows.Err()
+}
+
+// DeleteTemplateBundle removes an imported bundle. It reports false if
+// there was none.
+func (db *DB) DeleteTemplateBundle(name string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM template_bundles WHERE name = $1`, name)
+	if err != nil {
+		return false, fmt.Errorf("delete template bundle: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
@@ -20,7 +20,8 @@ func (db *DB) CreateToken(token, userID string, expiresAt time.Time) error {
 func (db *DB) ValidateToken(token string) (string, error) {
 	var userID string
 	err := db.QueryRow(
-		"SELECT user_id FROM auth_tokens WHERE token = $1 AND expires_at > NOW()",
+		`SELECT t.user_id FROM auth_tokens t JOIN users u ON u.id = t.user_id
+		 WHERE t.token = $1 AND t.expires_at > NOW() AND u.disabled_at IS NULL`,
 		token,
 	).Scan(&userID)
 	if err == sql.ErrNoRows {
@@ -32,6 +33,46 @@ func (db *DB) ValidateToken(token string) (string, error) {
 	return userID, nil
 }
 
+// CreateImpersonationToken stores a login token for userID issued to the
+// admin adminID.
+func (db *DB) CreateImpersonationToken(token, userID, adminID string, expiresAt time.Time) error {
+	_, err := db.Exec(
+		"INSERT INTO auth_tokens (token, user_id, expires_at, impersonated_by) VALUES ($1, $2, $3, $4)",
+		token, userID, expiresAt, adminID,
+	)
+	if err != nil {
+		return fmt.Errorf("create impersonation token: %w", err)
+	}
+	return nil
+}
+
+// ValidateSessionToken returns the user of an unexpired token and, for an
+// impersonation token, the admin impersonating them. userID is empty if
+// the token is invalid or the user disabled.
+func (db *DB) ValidateSessionToken(token string) (userID, impersonatedBy string, err error) {
+	var admin sql.NullString
+	err = db.QueryRow(
+		`SELECT t.user_id, t.impersonated_by FROM auth_tokens t JOIN users u ON u.id = t.user_id
+		 WHERE t.token = $1 AND t.expires_at > NOW() AND u.disabled_at IS NULL`,
+		token,
+	).Scan(&userID, &admin)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("validate token: %w", err)
+	}
+	return userID, admin.String, nil
+}
+
+// DeleteToken deletes a login token.
+func (db *DB) DeleteToken(token string) error {
+	if _, err := db.Exec("DELETE FROM auth_tokens WHERE token = $1", token); err != nil {
+		return fmt.Errorf("delete token: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) DeleteExpiredTokens() error {
 	_, err := db.Exec("DELETE FROM auth_tokens WHERE expires_at < NOW()")
 	if err != nil {
@@ -40,3 +81,107 @@ func (db *DB) DeleteExpiredTokens() error {
 	return nil
 }
 
+
+// EvictOldestTokens deletes a user's oldest active tokens so that at most
+// keep remain. Impersonation tokens are neither counted nor deleted. It
+// returns the number of tokens deleted.
+func (db *DB) EvictOldestTokens(userID string, keep int) (int64, error) {
+	res, err := db.Exec(
+		`DELETE FROM auth_tokens WHERE token IN (
+			SELECT token FROM auth_tokens
+			WHERE user_id = $1 AND expires_at > NOW() AND impersonated_by IS NULL
+			ORDER BY created_at DESC
+			OFFSET $2
+		)`,
+		userID, keep,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("evict oldest tokens: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Session is a login session, without its token. Impersonation sessions
+// aren't listed.
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"` // the session of the request
+}
+
+// TouchToken records the client a login token is used from, at most once a
+// minute unless the client changes.
+func (db *DB) TouchToken(token, ip, userAgent string) error {
+	_, err := db.Exec(
+		`UPDATE auth_tokens SET last_used_at = NOW(), last_used_ip = $2, user_agent = $3
+		 WHERE token = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute'
+		   OR last_used_ip IS DISTINCT FROM $2 OR user_agent IS DISTINCT FROM $3)`,
+		token, nullIfEmpty(ip), nullIfEmpty(userAgent))
+	if err != nil {
+		return fmt.Errorf("touch token: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns a user's active login sessions, newest first.
+// currentToken marks the session of the request.
+func (db *DB) ListSessions(userID, currentToken string) ([]*Session, error) {
+	rows, err := db.Query(
+		`SELECT id, user_agent, last_used_ip, last_used_at, created_at, expires_at, token = $2
+		 FROM auth_tokens
+		 WHERE user_id = $1 AND expires_at > NOW() AND impersonated_by IS NULL
+		 ORDER BY created_at DESC`,
+		userID, currentToken)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		s := &Session{}
+		var userAgent, ip sql.NullString
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&s.ID, &userAgent, &ip, &lastUsed, &s.CreatedAt, &s.ExpiresAt, &s.Current); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		s.UserAgent, s.LastUsedIP = userAgent.String, ip.String
+		if lastUsed.Valid {
+			s.LastUsedAt = &lastUsed.Time
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession deletes one of a user's login sessions. found is false if
+// the user has no such session; current is true if it was currentToken's.
+func (db *DB) DeleteSession(userID, id, currentToken string) (found, current bool, err error) {
+	err = db.QueryRow(
+		`DELETE FROM auth_tokens WHERE user_id = $1 AND id = $2 AND impersonated_by IS NULL
+		 RETURNING token = $3`,
+		userID, id, currentToken).Scan(&current)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("delete session: %w", err)
+	}
+	return true, current, nil
+}
+
+// DeleteSessions deletes all of a user's login sessions except keepToken's
+// and returns how many were deleted. Impersonation sessions are kept.
+func (db *DB) DeleteSessions(userID, keepToken string) (int64, error) {
+	res, err := db.Exec(
+		`DELETE FROM auth_tokens WHERE user_id = $1 AND token <> $2 AND impersonated_by IS NULL`,
+		userID, keepToken)
+	if err != nil {
+		return 0, fmt.Errorf("delete sessions: %w", err)
+	}
+	return res.RowsAffected()
+}
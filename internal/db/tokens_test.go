@@ -0,0 +1,120 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestImpersonationTokens(t *testing.T) {
+	d := newTestDB(t)
+	userID, adminID := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{userID, adminID} {
+		if err := d.CreateUser(id, id+"@example.com", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id IN ($1, $2)`, userID, adminID) })
+
+	session, impersonation := "tok-"+uuid.NewString(), "tok-"+uuid.NewString()
+	if err := d.CreateToken(session, userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateImpersonationToken(impersonation, userID, adminID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if uid, admin, err := d.ValidateSessionToken(session); err != nil || uid != userID || admin != "" {
+		t.Errorf("ValidateSessionToken(session) = %q, %q, %v", uid, admin, err)
+	}
+	if uid, admin, err := d.ValidateSessionToken(impersonation); err != nil || uid != userID || admin != adminID {
+		t.Errorf("ValidateSessionToken(impersonation) = %q, %q, %v", uid, admin, err)
+	}
+
+	// Evicting the user's sessions leaves the impersonation session alone.
+	if _, err := d.EvictOldestTokens(userID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if uid, _, _ := d.ValidateSessionToken(session); uid != "" {
+		t.Error("session not evicted")
+	}
+	if uid, _, _ := d.ValidateSessionToken(impersonation); uid != userID {
+		t.Error("impersonation session evicted")
+	}
+
+	if err := d.DeleteToken(impersonation); err != nil {
+		t.Fatal(err)
+	}
+	if uid, _, _ := d.ValidateSessionToken(impersonation); uid != "" {
+		t.Error("impersonation session valid after DeleteToken")
+	}
+}
+
+func TestSessions(t *testing.T) {
+	d := newTestDB(t)
+	userID, adminID := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{userID, adminID} {
+		if err := d.CreateUser(id, id+"@example.com", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id IN ($1, $2)`, userID, adminID) })
+
+	laptop, phone, other := "tok-"+uuid.NewString(), "tok-"+uuid.NewString(), "tok-"+uuid.NewString()
+	for _, tok := range []string{laptop, phone, other} {
+		if err := d.CreateToken(tok, userID, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.CreateImpersonationToken("tok-"+uuid.NewString(), userID, adminID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.TouchToken(phone, "203.0.113.7", "Mobile Safari"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := d.ListSessions(userID, laptop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("ListSessions = %d sessions, want 3 without the impersonation session", len(sessions))
+	}
+	var current, phoneID string
+	for _, s := range sessions {
+		if s.Current {
+			current = s.ID
+		}
+		if s.UserAgent == "Mobile Safari" {
+			phoneID = s.ID
+			if s.LastUsedIP != "203.0.113.7" || s.LastUsedAt == nil {
+				t.Errorf("phone session = %+v, want its last use", s)
+			}
+		}
+	}
+	if current == "" || phoneID == "" {
+		t.Fatalf("sessions = %+v, want the current and the touched one", sessions)
+	}
+
+	if found, cur, err := d.DeleteSession(userID, phoneID, laptop); err != nil || !found || cur {
+		t.Errorf("DeleteSession(phone) = %v, %v, %v", found, cur, err)
+	}
+	if uid, _, _ := d.ValidateSessionToken(phone); uid != "" {
+		t.Error("phone session valid after DeleteSession")
+	}
+	if found, _, _ := d.DeleteSession(adminID, current, laptop); found {
+		t.Error("deleted another user's session")
+	}
+
+	n, err := d.DeleteSessions(userID, laptop)
+	if err != nil || n != 1 {
+		t.Errorf("DeleteSessions = %d, %v; want 1", n, err)
+	}
+	if uid, _, _ := d.ValidateSessionToken(laptop); uid != userID {
+		t.Error("kept session deleted")
+	}
+	if found, cur, err := d.DeleteSession(userID, current, laptop); err != nil || !found || !cur {
+		t.Errorf("DeleteSession(current) = %v, %v, %v", found, cur, err)
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// TunnelStats is the traffic of a local agent's tunnel.
+type TunnelStats struct {
+	SandboxID     string     `json:"-"`
+	Connected     bool       `json:"connected"`
+	ConnectedAt   *time.Time `json:"connected_at,omitempty"`
+	BytesIn       int64      `json:"bytes_in"`
+	BytesOut      int64      `json:"bytes_out"`
+	ActiveStreams int        `json:"active_streams"`
+	Requests      int64      `json:"requests"`
+	RequestErrors int64      `json:"request_errors"`
+	AvgLatencyMs  int        `json:"avg_latency_ms"`
+	BytesInTotal  int64      `json:"bytes_in_total"`
+	BytesOutTotal int64      `json:"bytes_out_total"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SaveTunnelStats records the current connection's counters of a tunnel.
+// deltaIn and deltaOut are the bytes transferred since the last save and
+// are added to the lifetime totals.
+func (db *DB) SaveTunnelStats(st *TunnelStats, deltaIn, deltaOut int64) error {
+	_, err := db.Exec(`
+		INSERT INTO tunnel_stats (sandbox_id, connected, connected_at, bytes_in, bytes_out, active_streams,
+			requests, request_errors, avg_latency_ms, bytes_in_total, bytes_out_total, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		ON CONFLICT (sandbox_id) DO UPDATE SET
+			connected = EXCLUDED.connected,
+			connected_at = EXCLUDED.connected_at,
+			bytes_in = EXCLUDED.bytes_in,
+			bytes_out = EXCLUDED.bytes_out,
+			active_streams = EXCLUDED.active_streams,
+			requests = EXCLUDED.requests,
+			request_errors = EXCLUDED.request_errors,
+			avg_latency_ms = EXCLUDED.avg_latency_ms,
+			bytes_in_total = tunnel_stats.bytes_in_total + $10,
+			bytes_out_total = tunnel_stats.bytes_out_total + $11,
+			updated_at = NOW()`,
+		st.SandboxID, st.Connected, st.ConnectedAt, st.BytesIn, st.BytesOut, st.ActiveStreams,
+		st.Requests, st.RequestErrors, st.AvgLatencyMs, deltaIn, deltaOut,
+	)
+	if err != nil {
+		return fmt.Errorf("save tunnel stats: %w", err)
+	}
+	return nil
+}
+
+// ListTunnelStatsByWorkspace returns the tunnel stats of a workspace's
+// local agents, keyed by sandbox ID.
+func (db *DB) ListTunnelStatsByWorkspace(workspaceID string) (map[string]*TunnelStats, error) {
+	rows, err := db.Query(`
+		SELECT t.sandbox_id, t.connected, t.connected_at, t.bytes_in, t.bytes_out, t.active_streams,
+			t.requests, t.request_errors, t.avg_latency_ms, t.bytes_in_total, t.bytes_out_total, t.updated_at
+		FROM tunnel_stats t JOIN sandboxes s ON s.id = t.sandbox_id
+		WHERE s.workspace_id = $1`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list tunnel stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]*TunnelStats)
+	for rows.Next() {
+		var st TunnelStats
+		var connectedAt sql.NullTime
+		if err := rows.Scan(&st.SandboxID, &st.Connected, &connectedAt, &st.BytesIn, &st.BytesOut, &st.ActiveStreams,
+			&st.Requests, &st.RequestErrors, &st.AvgLatencyMs, &st.BytesInTotal, &st.BytesOutTotal, &st.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan tunnel stats: %w", err)
+		}
+		if connectedAt.Valid {
+			st.ConnectedAt = &connectedAt.Time
+		}
+		stats[st.SandboxID] = &st
+	}
+	return stats, rows.Err()
+}
+
+// GetSandboxTunnelBandwidthLimit returns the tunnel bandwidth cap of a
+// sandbox in bytes per second, 0 if it uses the default.
+func (db *DB) GetSandboxTunnelBandwidthLimit(id string) (int64, error) {
+	var limit int64
+	err := db.QueryRow(`SELECT tunnel_bandwidth_limit FROM sandboxes WHERE id = $1`, id).Scan(&limit)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get tunnel bandwidth limit: %w", err)
+	}
+	return limit, nil
+}
+
+// SetSandboxTunnelBandwidthLimit sets the tunnel bandwidth cap of a sandbox;
+// 0 restores the default.
+func (db *DB) SetSandboxTunnelBandwidthLimit(id string, bytesPerSec int64) error {
+	_, err := db.Exec(`UPDATE sandboxes SET tunnel_bandwidth_limit = $2 WHERE id = $1`, id, bytesPerSec)
+	if err != nil {
+		return fmt.Errorf("set tunnel bandwidth limit: %w", err)
+	}
+	return nil
+}
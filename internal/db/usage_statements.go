@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// recordSandboxRun opens a sandbox_runs interval when a sandbox becomes
+// running and closes it on any other status.
+func (db *DB) recordSandboxRun(id, status string) error {
+	if status != "running" {
+		return db.closeSandboxRun(id)
+	}
+	_, err := db.Exec(
+		`INSERT INTO sandbox_runs (sandbox_id, workspace_id, cpu, memory)
+		 SELECT id, workspace_id, COALESCE(cpu, 0), COALESCE(memory, 0) FROM sandboxes WHERE id = $1
+		 ON CONFLICT (sandbox_id) WHERE stopped_at IS NULL DO NOTHING`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("open sandbox run: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) closeSandboxRun(id string) error {
+	_, err := db.Exec("UPDATE sandbox_runs SET stopped_at = NOW() WHERE sandbox_id = $1 AND stopped_at IS NULL", id)
+	if err != nil {
+		return fmt.Errorf("close sandbox run: %w", err)
+	}
+	return nil
+}
+
+// ComputeUsage is the running time of a workspace's sandboxes in a period.
+type ComputeUsage struct {
+	ComputeHours  float64 // sandbox-hours
+	CPUHours      float64 // vCPU-hours
+	MemoryGBHours float64 // GiB-hours
+}
+
+// WorkspaceComputeUsage sums the sandbox running time of a workspace within
+// [since, until). Intervals still open count up to now.
+func (db *DB) WorkspaceComputeUsage(workspaceID string, since, until time.Time) (*ComputeUsage, error) {
+	u := &ComputeUsage{}
+	err := db.QueryRow(
+		`WITH r AS (
+		   SELECT cpu, memory,
+		          EXTRACT(EPOCH FROM LEAST(COALESCE(stopped_at, NOW()), $3) - GREATEST(started_at, $2)) / 3600 AS hours
+		   FROM sandbox_runs
+		   WHERE workspace_id = $1 AND started_at < $3 AND COALESCE(stopped_at, NOW()) > $2
+		 )
+		 SELECT COALESCE(SUM(hours), 0),
+		        COALESCE(SUM(hours * cpu / 1000.0), 0),
+		        COALESCE(SUM(hours * memory / 1073741824.0), 0)
+		 FROM r`,
+		workspaceID, since, until,
+	).Scan(&u.ComputeHours, &u.CPUHours, &u.MemoryGBHours)
+	if err != nil {
+		return nil, fmt.Errorf("workspace compute usage: %w", err)
+	}
+	return u, nil
+}
+
+// StatementModelUsage is the LLM usage of one model in a statement.
+type StatementModelUsage struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+	CacheTokens  int64  `json:"cache_tokens"`
+	Requests     int64  `json:"requests"`
+}
+
+// UsageStatement is the monthly usage rollup of a workspace.
+type UsageStatement struct {
+	WorkspaceID   string
+	Month         time.Time // first day of the month, UTC
+	InputTokens   int64
+	OutputTokens  int64
+	CacheTokens   int64 // cache creation plus cache read input tokens
+	LLMRequests   int64
+	Models        []StatementModelUsage
+	ComputeHours  float64
+	CPUHours      float64
+	MemoryGBHours float64
+	StorageBytes  int64 // provisioned workspace drive capacity
+	GeneratedAt   time.Time
+	EmailedAt     *time.Time
+}
+
+const usageStatementColumns = `workspace_id, month, input_tokens, output_tokens, cache_tokens, llm_requests, models,
+	compute_hours, cpu_hours, memory_gb_hours, storage_bytes, generated_at, emailed_at`
+
+func scanUsageStatement(row interface{ Scan(...interface{}) error }) (*UsageStatement, error) {
+	st := &UsageStatement{}
+	var modelsJSON []byte
+	if err := row.Scan(&st.WorkspaceID, &st.Month, &st.InputTokens, &st.OutputTokens, &st.CacheTokens,
+		&st.LLMRequests, &modelsJSON, &st.ComputeHours, &st.CPUHours, &st.MemoryGBHours,
+		&st.StorageBytes, &st.GeneratedAt, &st.EmailedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(modelsJSON, &st.Models); err != nil {
+		return nil, fmt.Errorf("unmarshal models: %w", err)
+	}
+	st.Month = st.Month.UTC()
+	return st, nil
+}
+
+// SaveUsageStatement creates or replaces the statement of a workspace month.
+// A replaced statement keeps its emailed_at.
+func (db *DB) SaveUsageStatement(st *UsageStatement) error {
+	models := st.Models
+	if models == nil {
+		models = []StatementModelUsage{}
+	}
+	modelsJSON, err := json.Marshal(models)
+	if err != nil {
+		return fmt.Errorf("save usage statement: marshal models: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO usage_statements (workspace_id, month, input_tokens, output_tokens, cache_tokens, llm_requests, models,
+		   compute_hours, cpu_hours, memory_gb_hours, storage_bytes, generated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		 ON CONFLICT (workspace_id, month) DO UPDATE SET
+		   input_tokens = EXCLUDED.input_tokens,
+		   output_tokens = EXCLUDED.output_tokens,
+		   cache_tokens = EXCLUDED.cache_tokens,
+		   llm_requests = EXCLUDED.llm_requests,
+		   models = EXCLUDED.models,
+		   compute_hours = EXCLUDED.compute_hours,
+		   cpu_hours = EXCLUDED.cpu_hours,
+		   memory_gb_hours = EXCLUDED.memory_gb_hours,
+		   storage_bytes = EXCLUDED.storage_bytes,
+		   generated_at = NOW()`,
+		st.WorkspaceID, st.Month.Format("2006-01-02"), st.InputTokens, st.OutputTokens, st.CacheTokens, st.LLMRequests,
+		modelsJSON, st.ComputeHours, st.CPUHours, st.MemoryGBHours, st.StorageBytes,
+	)
+	if err != nil {
+		return fmt.Errorf("save usage statement: %w", err)
+	}
+	return nil
+}
+
+// GetUsageStatement returns the statement of a workspace month, or nil if it
+// hasn't been generated.
+func (db *DB) GetUsageStatement(workspaceID string, month time.Time) (*UsageStatement, error) {
+	st, err := scanUsageStatement(db.QueryRow(
+		`SELECT `+usageStatementColumns+` FROM usage_statements WHERE workspace_id = $1 AND month = $2`,
+		workspaceID, month.Format("2006-01-02"),
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get usage statement: %w", err)
+	}
+	return st, nil
+}
+
+// ListUsageStatements returns the statements of a workspace, newest first.
+func (db *DB) ListUsageStatements(workspaceID string) ([]*UsageStatement, error) {
+	rows, err := db.Query(
+		`SELECT `+usageStatementColumns+` FROM usage_statements WHERE workspace_id = $1 ORDER BY month DESC`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list usage statements: %w", err)
+	}
+	defer rows.Close()
+
+	var statements []*UsageStatement
+	for rows.Next() {
+		st, err := scanUsageStatement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan usage statement: %w", err)
+		}
+		statements = append(statements, st)
+	}
+	return statements, rows.Err()
+}
+
+func (db *DB) MarkUsageStatementEmailed(workspaceID string, month time.Time) error {
+	_, err := db.Exec(
+		"UPDATE usage_statements SET emailed_at = NOW() WHERE workspace_id = $1 AND month = $2",
+		workspaceID, month.Format("2006-01-02"),
+	)
+	if err != nil {
+		return fmt.Errorf("mark usage statement emailed: %w", err)
+	}
+	return nil
+}
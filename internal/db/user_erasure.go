@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// UserErasureRequest is a user's request to have their personal data
+// erased, pending until an admin decides on it.
+type UserErasureRequest struct {
+	ID           int64      `json:"id"`
+	UserID       string     `json:"user_id"` // the pseudonym once completed
+	Email        string     `json:"email,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+	Status       string     `json:"status"` // pending, rejected or completed
+	RequestedAt  time.Time  `json:"requested_at"`
+	DecidedBy    string     `json:"decided_by,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	DecisionNote string     `json:"decision_note,omitempty"`
+}
+
+const userErasureColumns = `id, user_id, email, reason, status, requested_at, decided_by, decided_at, decision_note`
+
+func scanUserErasureRequest(row interface{ Scan(...interface{}) error }) (*UserErasureRequest, error) {
+	e := &UserErasureRequest{}
+	var email, reason, decidedBy, note sql.NullString
+	var decidedAt sql.NullTime
+	if err := row.Scan(&e.ID, &e.UserID, &email, &reason, &e.Status, &e.RequestedAt, &decidedBy, &decidedAt, &note); err != nil {
+		return nil, err
+	}
+	e.Email, e.Reason, e.DecidedBy, e.DecisionNote = email.String, reason.String, decidedBy.String, note.String
+	if decidedAt.Valid {
+		e.DecidedAt = &decidedAt.Time
+	}
+	return e, nil
+}
+
+// CreateUserErasureRequest records a pending erasure request. It fails if
+// the user already has one pending.
+func (db *DB) CreateUserErasureRequest(userID, email, reason string) (*UserErasureRequest, error) {
+	e, err := scanUserErasureRequest(db.QueryRow(
+		`INSERT INTO user_erasure_requests (user_id, email, reason)
+		 VALUES ($1, $2, $3)
+		 RETURNING `+userErasureColumns,
+		userID, nullIfEmpty(email), nullIfEmpty(reason),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create user erasure request: %w", err)
+	}
+	return e, nil
+}
+
+// GetUserErasureRequest returns an erasure request, or nil if not found.
+func (db *DB) GetUserErasureRequest(id int64) (*UserErasureRequest, error) {
+	e, err := scanUserErasureRequest(db.QueryRow(
+		`SELECT `+userErasureColumns+` FROM user_erasure_requests WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get user erasure request: %w", err)
+	}
+	return e, nil
+}
+
+// LatestUserErasureRequest returns the user's most recent erasure request,
+// or nil if they never made one.
+func (db *DB) LatestUserErasureRequest(userID string) (*UserErasureRequest, error) {
+	e, err := scanUserErasureRequest(db.QueryRow(
+		`SELECT `+userErasureColumns+` FROM user_erasure_requests
+		 WHERE user_id = $1 ORDER BY requested_at DESC, id DESC LIMIT 1`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get latest user erasure request: %w", err)
+	}
+	return e, nil
+}
+
+// ListUserErasureRequests returns erasure requests with the given status
+// (all if empty), oldest first.
+func (db *DB) ListUserErasureRequests(status string) ([]*UserErasureRequest, error) {
+	rows, err := db.Query(
+		`SELECT `+userErasureColumns+` FROM user_erasure_requests
+		 WHERE $1 = '' OR status = $1
+		 ORDER BY requested_at, id`, status)
+	if err != nil {
+		return nil, fmt.Errorf("list user erasure requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []*UserErasureRequest{}
+	for rows.Next() {
+		e, err := scanUserErasureRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan user erasure request: %w", err)
+		}
+		requests = append(requests, e)
+	}
+	return requests, rows.Err()
+}
+
+// RejectUserErasureRequest marks a pending request rejected. It reports
+// false if the request is not pending.
+func (db *DB) RejectUserErasureRequest(id int64, adminID, note string) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE user_erasure_requests
+		 SET status = 'rejected', decided_by = $2, decided_at = NOW(), decision_note = $3
+		 WHERE id = $1 AND status = 'pending'`,
+		id, adminID, nullIfEmpty(note),
+	)
+	if err != nil {
+		return false, fmt.Errorf("reject user erasure request: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// EraseUser completes a pending erasure request in one transaction: the
+// user's ID in audit records (security events, quarantine actions,
+// operations, pins, sandbox creators, shares, announcements, sessions,
+// snapshots, template imports, migrations, session history exports,
+// workspace templates and secrets, exposed sandbox ports, sandbox activity,
+// sandbox schedules, drive mirrors, sandbox tombstones, workspace
+// invitations) is replaced by pseudonym, the email is removed from
+// failed-login events, invitations to it are deleted, and the user row is
+// deleted along with everything that cascades from it (credentials,
+// sessions, identities, memberships, tokens). Workspaces the user was the
+// only member of must be deleted beforehand.
+func (db *DB) EraseUser(requestID int64, userID, email, adminID, pseudonym string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("erase user: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.Exec(`SET LOCAL agentserver.erasure = 'on'`); err != nil {
+		return fmt.Errorf("erase user: %w", err)
+	}
+	stmts := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE security_events SET actor_id = $2, ip = NULL WHERE actor_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE security_events SET target_id = $2 WHERE target_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE security_events SET details = details - 'email' WHERE details->>'email' = $1`, []interface{}{email}},
+		{`UPDATE sandbox_quarantine_events SET actor_id = $2 WHERE actor_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE operations SET user_id = $2 WHERE user_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandboxes SET pinned_by = $2 WHERE pinned_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandboxes SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE session_shares SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE announcements SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE agent_sessions SET creator_user_id = $2 WHERE creator_user_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_snapshots SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE template_bundles SET imported_by = $2 WHERE imported_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_migrations SET requested_by = $2 WHERE requested_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE session_history_exports SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_templates SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE workspace_secrets SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE workspace_secrets SET updated_by = $2 WHERE updated_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_ports SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_activity SET user_id = $2 WHERE user_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_schedules SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE drive_mirrors SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_tombstones SET created_by = $2 WHERE created_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE sandbox_tombstones SET deleted_by = $2 WHERE deleted_by = $1`, []interface{}{userID, pseudonym}},
+		{`DELETE FROM workspace_invitations WHERE lower(email) = lower($1)`, []interface{}{email}},
+		{`UPDATE workspace_invitations SET invited_by = $2 WHERE invited_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE workspace_invitations SET accepted_by = $2 WHERE accepted_by = $1`, []interface{}{userID, pseudonym}},
+		{`DELETE FROM codex_remote_tokens WHERE user_id = $1`, []interface{}{userID}},
+		{`DELETE FROM workspace_modelserver_tokens WHERE user_id = $1`, []interface{}{userID}},
+		{`DELETE FROM users WHERE id = $1`, []interface{}{userID}},
+		{`UPDATE user_erasure_requests SET user_id = $2, email = NULL WHERE user_id = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE user_erasure_requests SET decided_by = $2 WHERE decided_by = $1`, []interface{}{userID, pseudonym}},
+		{`UPDATE user_erasure_requests
+		  SET status = 'completed', decided_by = $2, decided_at = NOW()
+		  WHERE id = $1`, []interface{}{requestID, adminID}},
+	}
+	for _, st := range stmts {
+		if _, err := tx.Exec(st.query, st.args...); err != nil {
+			return fmt.Errorf("erase user: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEraseUserPseudonymizesSecurityEvents(t *testing.T) {
+	d := newTestDB(t)
+	userID := uuid.NewString()
+	email := userID + "@example.com"
+	if err := d.CreateUser(userID, email, "hash"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	own := &SecurityEvent{Type: "role_changed", Severity: "warning", ActorID: userID, IP: "203.0.113.7"}
+	failed := &SecurityEvent{Type: "login_failed", Severity: "warning", Details: map[string]interface{}{"email": email}}
+	for _, ev := range []*SecurityEvent{own, failed} {
+		if err := d.RecordSecurityEvent(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Exec(`UPDATE security_events SET severity = 'info' WHERE id = $1`, own.ID); err == nil {
+		t.Error("security event updated outside an erasure")
+	}
+	if _, err := d.Exec(`DELETE FROM security_events WHERE id = $1`, own.ID); err == nil {
+		t.Error("security event deleted")
+	}
+
+	er, err := d.CreateUserErasureRequest(userID, email, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.CreateUserErasureRequest(userID, email, ""); err == nil {
+		t.Error("second pending erasure request accepted")
+	}
+	pseudonym := "erased-" + uuid.NewString()[:8]
+	if err := d.EraseUser(er.ID, userID, email, "admin-1", pseudonym); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, err := d.GetUserByID(userID); err != nil || u != nil {
+		t.Errorf("user after erasure = %+v, %v; want deleted", u, err)
+	}
+	events, err := d.ListSecurityEvents(SecurityEventFilter{ActorID: pseudonym})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != own.ID || events[0].IP != "" {
+		t.Errorf("pseudonymized events = %+v, want event %d without IP", events, own.ID)
+	}
+	events, err = d.ListSecurityEvents(SecurityEventFilter{Type: "login_failed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev.ID == failed.ID && ev.Details["email"] != nil {
+			t.Errorf("failed login event still has the email: %+v", ev.Details)
+		}
+	}
+	got, err := d.GetUserErasureRequest(er.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "completed" || got.UserID != pseudonym || got.Email != "" || got.DecidedBy != "admin-1" {
+		t.Errorf("erasure request after erasure = %+v", got)
+	}
+}
@@ -14,6 +14,8 @@ type User struct {
 	Role      string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// DisabledAt is when an admin disabled the user; nil if active.
+	DisabledAt *time.Time
 }
 
 func (db *DB) CreateUser(id, email, passwordHash string) error {
@@ -69,9 +71,9 @@ func (db *DB) CreateUserWithEmail(id string, passwordHash *string, email string)
 func (db *DB) GetUserByID(id string) (*User, error) {
 	u := &User{}
 	err := db.QueryRow(
-		"SELECT id, email, name, picture, role, created_at, updated_at FROM users WHERE id = $1",
+		"SELECT id, email, name, picture, role, created_at, updated_at, disabled_at FROM users WHERE id = $1",
 		id,
-	).Scan(&u.ID, &u.Email, &u.Name, &u.Picture, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	).Scan(&u.ID, &u.Email, &u.Name, &u.Picture, &u.Role, &u.CreatedAt, &u.UpdatedAt, &u.DisabledAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -84,9 +86,9 @@ func (db *DB) GetUserByID(id string) (*User, error) {
 func (db *DB) GetUserByEmail(email string) (*User, error) {
 	u := &User{}
 	err := db.QueryRow(
-		"SELECT id, email, name, picture, role, created_at, updated_at FROM users WHERE email = $1",
+		"SELECT id, email, name, picture, role, created_at, updated_at, disabled_at FROM users WHERE email = $1",
 		email,
-	).Scan(&u.ID, &u.Email, &u.Name, &u.Picture, &u.Role, &u.CreatedAt, &u.UpdatedAt)
+	).Scan(&u.ID, &u.Email, &u.Name, &u.Picture, &u.Role, &u.CreatedAt, &u.UpdatedAt, &u.DisabledAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -106,7 +108,7 @@ func (db *DB) UpdateUserEmail(userID, email string) error {
 
 func (db *DB) ListAllUsers() ([]*User, error) {
 	rows, err := db.Query(
-		"SELECT id, email, name, picture, role, created_at, updated_at FROM users ORDER BY created_at ASC",
+		"SELECT id, email, name, picture, role, created_at, updated_at, disabled_at FROM users ORDER BY created_at ASC",
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list all users: %w", err)
@@ -116,7 +118,7 @@ func (db *DB) ListAllUsers() ([]*User, error) {
 	var users []*User
 	for rows.Next() {
 		u := &User{}
-		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Picture, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.Name, &u.Picture, &u.Role, &u.CreatedAt, &u.UpdatedAt, &u.DisabledAt); err != nil {
 			return nil, fmt.Errorf("scan user: %w", err)
 		}
 		users = append(users, u)
@@ -156,3 +158,31 @@ func (db *DB) UpdateUserName(userID, name string) error {
 	}
 	return nil
 }
+
+// SetUserDisabled disables or re-enables a user. Disabling also deletes
+// their login sessions, impersonation sessions included. It reports false
+// if the user doesn't exist.
+func (db *DB) SetUserDisabled(userID string, disabled bool) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("set user disabled: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`UPDATE users SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) END, updated_at = NOW()
+		 WHERE id = $1`,
+		userID, disabled)
+	if err != nil {
+		return false, fmt.Errorf("set user disabled: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if disabled {
+		if _, err := tx.Exec("DELETE FROM auth_tokens WHERE user_id = $1", userID); err != nil {
+			return false, fmt.Errorf("delete sessions of disabled user: %w", err)
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSetUserDisabled(t *testing.T) {
+	d := newTestDB(t)
+	userID := uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+
+	session := "tok-" + uuid.NewString()
+	if err := d.CreateToken(session, userID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	pat := &PersonalAccessToken{
+		ID: uuid.NewString(), UserID: userID, Name: "ci", Prefix: "pat_disabled",
+		Scopes: []string{TokenScopeRead}, ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := d.CreatePersonalAccessToken(pat, "pat-hash-"+userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := d.SetUserDisabled(userID, true); err != nil || !found {
+		t.Fatalf("SetUserDisabled(true) = %v, %v", found, err)
+	}
+	if u, err := d.GetUserByID(userID); err != nil || u.DisabledAt == nil {
+		t.Errorf("user after disabling = %+v, %v", u, err)
+	}
+	if uid, _, _ := d.ValidateSessionToken(session); uid != "" {
+		t.Error("session of disabled user valid")
+	}
+	if got, _ := d.ValidatePersonalAccessToken("pat-hash-" + userID); got != nil {
+		t.Error("token of disabled user accepted")
+	}
+
+	if found, err := d.SetUserDisabled(userID, false); err != nil || !found {
+		t.Fatalf("SetUserDisabled(false) = %v, %v", found, err)
+	}
+	if u, _ := d.GetUserByID(userID); u.DisabledAt != nil {
+		t.Error("user still disabled")
+	}
+	// Sessions stay deleted; tokens work again.
+	if uid, _, _ := d.ValidateSessionToken(session); uid != "" {
+		t.Error("session restored by enabling")
+	}
+	if got, _ := d.ValidatePersonalAccessToken("pat-hash-" + userID); got == nil {
+		t.Error("token rejected after enabling")
+	}
+
+	if found, err := d.SetUserDisabled(uuid.NewString(), true); err != nil || found {
+		t.Errorf("SetUserDisabled(unknown) = %v, %v", found, err)
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSetWorkspaceArchived(t *testing.T) {
+	d := newTestDB(t)
+	userID, active, archived := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM users WHERE id = $1`, userID) })
+	for _, id := range []string{active, archived} {
+		if err := d.CreateWorkspace(id, "archive"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, id) })
+		if err := d.AddWorkspaceMember(id, userID, "owner"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(includeArchived bool) []string {
+		workspaces, err := d.ListWorkspacesByUser(userID, includeArchived)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, w := range workspaces {
+			ids = append(ids, w.ID)
+		}
+		return ids
+	}
+
+	if err := d.SetWorkspaceArchived(archived, true, []string{"snap-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if ws, _ := d.GetWorkspace(archived); ws == nil || !ws.ArchivedAt.Valid {
+		t.Fatal("archived_at not set")
+	}
+	var snapshots string
+	d.QueryRow(`SELECT archive_snapshots FROM workspaces WHERE id = $1`, archived).Scan(&snapshots)
+	if snapshots != `["snap-1"]` {
+		t.Errorf("archive_snapshots = %s", snapshots)
+	}
+	if got := ids(false); len(got) != 1 || got[0] != active {
+		t.Errorf("ListWorkspacesByUser without archived = %v, want [%s]", got, active)
+	}
+	if got := ids(true); len(got) != 2 {
+		t.Errorf("ListWorkspacesByUser with archived = %v, want both", got)
+	}
+
+	if err := d.SetWorkspaceArchived(archived, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ws, _ := d.GetWorkspace(archived); ws == nil || ws.ArchivedAt.Valid {
+		t.Error("archived_at not cleared")
+	}
+	var cleared *string
+	d.QueryRow(`SELECT archive_snapshots FROM workspaces WHERE id = $1`, archived).Scan(&cleared)
+	if cleared != nil {
+		t.Errorf("archive_snapshots = %s after unarchive, want NULL", *cleared)
+	}
+	if got := ids(false); len(got) != 2 {
+		t.Errorf("ListWorkspacesByUser after unarchive = %v, want both", got)
+	}
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WorkspaceInvitation invites whoever signs in with Email to join a
+// workspace with Role. The invite token is never stored, only its hash.
+type WorkspaceInvitation struct {
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspace_id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	InvitedBy   string     `json:"invited_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy  string     `json:"accepted_by,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Open reports whether the invitation can still be accepted.
+func (inv *WorkspaceInvitation) Open(now time.Time) bool {
+	return inv.AcceptedAt == nil && inv.RevokedAt == nil && now.Before(inv.ExpiresAt)
+}
+
+const workspaceInvitationColumns = `id, workspace_id, email, role, invited_by, created_at, expires_at, accepted_at, accepted_by, revoked_at`
+
+func scanWorkspaceInvitation(row interface{ Scan(...interface{}) error }) (*WorkspaceInvitation, error) {
+	inv := &WorkspaceInvitation{}
+	var invitedBy, acceptedBy sql.NullString
+	var accepted, revoked sql.NullTime
+	if err := row.Scan(&inv.ID, &inv.WorkspaceID, &inv.Email, &inv.Role, &invitedBy, &inv.CreatedAt, &inv.ExpiresAt,
+		&accepted, &acceptedBy, &revoked); err != nil {
+		return nil, err
+	}
+	inv.InvitedBy = invitedBy.String
+	inv.AcceptedBy = acceptedBy.String
+	if accepted.Valid {
+		inv.AcceptedAt = &accepted.Time
+	}
+	if revoked.Valid {
+		inv.RevokedAt = &revoked.Time
+	}
+	return inv, nil
+}
+
+// CreateWorkspaceInvitation stores an invitation and sets its CreatedAt.
+// An earlier invitation of the email to the workspace that is still open
+// is revoked, so inviting again resends the invitation with a new token.
+func (db *DB) CreateWorkspaceInvitation(inv *WorkspaceInvitation, tokenHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("create workspace invitation: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.Exec(
+		`UPDATE workspace_invitations SET revoked_at = NOW()
+		 WHERE workspace_id = $1 AND lower(email) = lower($2) AND accepted_at IS NULL AND revoked_at IS NULL`,
+		inv.WorkspaceID, inv.Email,
+	); err != nil {
+		return fmt.Errorf("revoke earlier workspace invitation: %w", err)
+	}
+	err = tx.QueryRow(
+		`INSERT INTO workspace_invitations (id, workspace_id, email, role, token_hash, invited_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		 RETURNING created_at`,
+		inv.ID, inv.WorkspaceID, inv.Email, inv.Role, tokenHash, inv.InvitedBy, inv.ExpiresAt,
+	).Scan(&inv.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create workspace invitation: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListWorkspaceInvitations returns the invitations of a workspace that were
+// neither accepted nor revoked, expired ones included, newest first.
+func (db *DB) ListWorkspaceInvitations(workspaceID string) ([]*WorkspaceInvitation, error) {
+	rows, err := db.Query(
+		`SELECT `+workspaceInvitationColumns+` FROM workspace_invitations
+		 WHERE workspace_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+		 ORDER BY created_at DESC`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list workspace invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invs := []*WorkspaceInvitation{}
+	for rows.Next() {
+		inv, err := scanWorkspaceInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan workspace invitation: %w", err)
+		}
+		invs = append(invs, inv)
+	}
+	return invs, rows.Err()
+}
+
+// GetWorkspaceInvitationByToken returns the invitation of a token hash, or
+// nil if there is none.
+func (db *DB) GetWorkspaceInvitationByToken(tokenHash string) (*WorkspaceInvitation, error) {
+	inv, err := scanWorkspaceInvitation(db.QueryRow(
+		`SELECT `+workspaceInvitationColumns+` FROM workspace_invitations WHERE token_hash = $1`,
+		tokenHash,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get workspace invitation: %w", err)
+	}
+	return inv, nil
+}
+
+// RevokeWorkspaceInvitation revokes an open invitation of a workspace. It
+// reports false if there is no such invitation.
+func (db *DB) RevokeWorkspaceInvitation(workspaceID, id string) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE workspace_invitations SET revoked_at = NOW()
+		 WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL`,
+		id, workspaceID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("revoke workspace invitation: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// AcceptWorkspaceInvitation marks an invitation accepted by a user and adds
+// them to the workspace with the invitation's role; a member keeps their
+// role. It reports false if the invitation was accepted, revoked or expired
+// meanwhile.
+func (db *DB) AcceptWorkspaceInvitation(id, userID string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("accept workspace invitation: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var workspaceID, role string
+	err = tx.QueryRow(
+		`UPDATE workspace_invitations SET accepted_at = NOW(), accepted_by = $2
+		 WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		 RETURNING workspace_id, role`,
+		id, userID,
+	).Scan(&workspaceID, &role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("accept workspace invitation: %w", err)
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+		 ON CONFLICT (workspace_id, user_id) DO NOTHING`,
+		workspaceID, userID, role,
+	); err != nil {
+		return false, fmt.Errorf("add workspace member: %w", err)
+	}
+	return true, tx.Commit()
+}
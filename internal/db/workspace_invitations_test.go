@@ -0,0 +1,71 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWorkspaceInvitations(t *testing.T) {
+	d := newTestDB(t)
+	wsID, userID := uuid.NewString(), uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "invitations"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateUser(userID, userID+"@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+
+	invite := func(hash string, ttl time.Duration) *WorkspaceInvitation {
+		t.Helper()
+		inv := &WorkspaceInvitation{ID: uuid.NewString(), WorkspaceID: wsID, Email: userID + "@Example.com",
+			Role: "developer", InvitedBy: "u-owner", ExpiresAt: time.Now().Add(ttl)}
+		if err := d.CreateWorkspaceInvitation(inv, hash); err != nil {
+			t.Fatal(err)
+		}
+		return inv
+	}
+
+	first := invite("inv-hash-1-"+wsID, time.Hour)
+	// Inviting again revokes the first invitation.
+	second := invite("inv-hash-2-"+wsID, time.Hour)
+	if got, err := d.GetWorkspaceInvitationByToken("inv-hash-1-" + wsID); err != nil || got == nil || got.RevokedAt == nil {
+		t.Errorf("first invitation = %+v, %v; want revoked", got, err)
+	}
+	list, err := d.ListWorkspaceInvitations(wsID)
+	if err != nil || len(list) != 1 || list[0].ID != second.ID || list[0].InvitedBy != "u-owner" {
+		t.Fatalf("ListWorkspaceInvitations = %+v, %v", list, err)
+	}
+	if ok, err := d.AcceptWorkspaceInvitation(first.ID, userID); err != nil || ok {
+		t.Errorf("accepting a revoked invitation = %v, %v", ok, err)
+	}
+
+	if ok, err := d.AcceptWorkspaceInvitation(second.ID, userID); err != nil || !ok {
+		t.Fatalf("AcceptWorkspaceInvitation = %v, %v", ok, err)
+	}
+	if role, err := d.GetWorkspaceMemberRole(wsID, userID); err != nil || role != "developer" {
+		t.Errorf("role after accepting = %q, %v", role, err)
+	}
+	if ok, _ := d.AcceptWorkspaceInvitation(second.ID, userID); ok {
+		t.Error("invitation accepted twice")
+	}
+	if list, _ := d.ListWorkspaceInvitations(wsID); len(list) != 0 {
+		t.Errorf("accepted invitation listed: %+v", list)
+	}
+
+	expired := invite("inv-hash-3-"+wsID, -time.Minute)
+	if ok, _ := d.AcceptWorkspaceInvitation(expired.ID, userID); ok {
+		t.Error("expired invitation accepted")
+	}
+	if found, err := d.RevokeWorkspaceInvitation(wsID, expired.ID); err != nil || !found {
+		t.Errorf("RevokeWorkspaceInvitation = %v, %v", found, err)
+	}
+	if found, _ := d.RevokeWorkspaceInvitation(wsID, expired.ID); found {
+		t.Error("invitation revoked twice")
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WorkspaceSecret is a secret shared by a workspace's members. Value is
+// the encrypted value; it is never serialized.
+type WorkspaceSecret struct {
+	WorkspaceID string    `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Target      string    `json:"target"` // "env" or "file"
+	Description string    `json:"description"`
+	Value       []byte    `json:"-"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PutWorkspaceSecret creates a secret or replaces the one with the same
+// name, keeping its creator. It reports whether the secret was created;
+// the timestamps and creator of sec are set from the database.
+func (db *DB) PutWorkspaceSecret(sec *WorkspaceSecret) (bool, error) {
+	var createdBy sql.NullString
+	var created bool
+	err := db.QueryRow(
+		`INSERT INTO workspace_secrets (workspace_id, name, target, description, value, created_by, updated_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $6)
+		 ON CONFLICT (workspace_id, name) DO UPDATE SET
+		   target = EXCLUDED.target, description = EXCLUDED.description, value = EXCLUDED.value,
+		   updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		 RETURNING created_by, created_at, updated_at, xmax = 0`,
+		sec.WorkspaceID, sec.Name, sec.Target, sec.Description, sec.Value, nullIfEmpty(sec.UpdatedBy),
+	).Scan(&createdBy, &sec.CreatedAt, &sec.UpdatedAt, &created)
+	if err != nil {
+		return false, fmt.Errorf("put workspace secret: %w", err)
+	}
+	sec.CreatedBy = createdBy.String
+	return created, nil
+}
+
+// ListWorkspaceSecrets returns a workspace's secrets by name, with their
+// encrypted values.
+func (db *DB) ListWorkspaceSecrets(workspaceID string) ([]*WorkspaceSecret, error) {
+	rows, err := db.Query(
+		`SELECT workspace_id, name, target, description, value, created_by, updated_by, created_at, updated_at
+		 FROM workspace_secrets WHERE workspace_id = $1 ORDER BY name`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("list workspace secrets: %w", err)
+	}
+	defer rows.Close()
+
+	secrets := []*WorkspaceSecret{}
+	for rows.Next() {
+		sec := &WorkspaceSecret{}
+		var createdBy, updatedBy sql.NullString
+		if err := rows.Scan(&sec.WorkspaceID, &sec.Name, &sec.Target, &sec.Description, &sec.Value,
+			&createdBy, &updatedBy, &sec.CreatedAt, &sec.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan workspace secret: %w", err)
+		}
+		sec.CreatedBy, sec.UpdatedBy = createdBy.String, updatedBy.String
+		secrets = append(secrets, sec)
+	}
+	return secrets, rows.Err()
+}
+
+// DeleteWorkspaceSecret removes a secret. Running sandboxes keep the value
+// they started with.
+func (db *DB) DeleteWorkspaceSecret(workspaceID, name string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM workspace_secrets WHERE workspace_id = $1 AND name = $2`, workspaceID, name)
+	if err != nil {
+		return false, fmt.Errorf("delete workspace secret: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
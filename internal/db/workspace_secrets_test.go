@@ -0,0 +1,43 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWorkspaceSecrets(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "secrets"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	sec := &WorkspaceSecret{WorkspaceID: wsID, Name: "NPM_TOKEN", Target: "env", Value: []byte{1, 2, 3}, UpdatedBy: "u-1"}
+	if created, err := d.PutWorkspaceSecret(sec); err != nil || !created {
+		t.Fatalf("PutWorkspaceSecret = %v, %v", created, err)
+	}
+	if sec.CreatedBy != "u-1" {
+		t.Errorf("created_by = %q", sec.CreatedBy)
+	}
+
+	replaced := &WorkspaceSecret{WorkspaceID: wsID, Name: "NPM_TOKEN", Target: "file", Value: []byte{4}, UpdatedBy: "u-2"}
+	if created, err := d.PutWorkspaceSecret(replaced); err != nil || created {
+		t.Fatalf("replacing PutWorkspaceSecret = %v, %v", created, err)
+	}
+	list, err := d.ListWorkspaceSecrets(wsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Target != "file" || string(list[0].Value) != "\x04" || list[0].CreatedBy != "u-1" || list[0].UpdatedBy != "u-2" {
+		t.Errorf("ListWorkspaceSecrets = %+v", list)
+	}
+
+	if ok, err := d.DeleteWorkspaceSecret(wsID, "NPM_TOKEN"); err != nil || !ok {
+		t.Errorf("DeleteWorkspaceSecret = %v, %v", ok, err)
+	}
+	if ok, _ := d.DeleteWorkspaceSecret(wsID, "NPM_TOKEN"); ok {
+		t.Error("second delete reported a secret")
+	}
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// WorkspaceSettings are a workspace's defaults for new sandboxes. nil
+// fields fall back to the system defaults.
+type WorkspaceSettings struct {
+	WorkspaceID        string     `json:"-"`
+	DefaultSandboxType *string    `json:"default_sandbox_type"`
+	DefaultCPU         *int       `json:"default_cpu"`          // millicores
+	DefaultMemory      *int64     `json:"default_memory"`       // bytes
+	DefaultIdleTimeout *int       `json:"default_idle_timeout"` // seconds
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+// GetWorkspaceSettings returns a workspace's settings, or nil if it has
+// none.
+func (db *DB) GetWorkspaceSettings(workspaceID string) (*WorkspaceSettings, error) {
+	s := &WorkspaceSettings{}
+	err := db.QueryRow(
+		`SELECT workspace_id, default_sandbox_type, default_cpu, default_memory, default_idle_timeout, updated_at
+		 FROM workspace_settings WHERE workspace_id = $1`,
+		workspaceID,
+	).Scan(&s.WorkspaceID, &s.DefaultSandboxType, &s.DefaultCPU, &s.DefaultMemory, &s.DefaultIdleTimeout, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get workspace settings: %w", err)
+	}
+	return s, nil
+}
+
+// SetWorkspaceSettings replaces a workspace's settings. s.UpdatedAt is set
+// from the database.
+func (db *DB) SetWorkspaceSettings(s *WorkspaceSettings) error {
+	err := db.QueryRow(
+		`INSERT INTO workspace_settings (workspace_id, default_sandbox_type, default_cpu, default_memory, default_idle_timeout, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW())
+		 ON CONFLICT (workspace_id) DO UPDATE SET
+		   default_sandbox_type = EXCLUDED.default_sandbox_type,
+		   default_cpu = EXCLUDED.default_cpu,
+		   default_memory = EXCLUDED.default_memory,
+		   default_idle_timeout = EXCLUDED.default_idle_timeout,
+		   updated_at = NOW()
+		 RETURNING updated_at`,
+		s.WorkspaceID, s.DefaultSandboxType, s.DefaultCPU, s.DefaultMemory, s.DefaultIdleTimeout,
+	).Scan(&s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("set workspace settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWorkspaceSettings(t *testing.T) {
+	d := newTestDB(t)
+	wsID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "settings"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+
+	if s, err := d.GetWorkspaceSettings(wsID); err != nil || s != nil {
+		t.Fatalf("settings of a new workspace = %+v, %v", s, err)
+	}
+	typ, cpu := "claudecode", 500
+	if err := d.SetWorkspaceSettings(&WorkspaceSettings{WorkspaceID: wsID, DefaultSandboxType: &typ, DefaultCPU: &cpu}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := d.GetWorkspaceSettings(wsID)
+	if err != nil || s == nil || *s.DefaultSandboxType != "claudecode" || *s.DefaultCPU != 500 || s.DefaultMemory != nil {
+		t.Fatalf("GetWorkspaceSettings = %+v, %v", s, err)
+	}
+
+	idle := 600
+	s.DefaultCPU, s.DefaultIdleTimeout = nil, &idle
+	if err := d.SetWorkspaceSettings(s); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = d.GetWorkspaceSettings(wsID)
+	if s.DefaultCPU != nil || *s.DefaultIdleTimeout != 600 || *s.DefaultSandboxType != "claudecode" {
+		t.Errorf("after update: %+v", s)
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -12,6 +13,7 @@ type Workspace struct {
 	K8sNamespace sql.NullString
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	ArchivedAt   sql.NullTime
 }
 
 type WorkspaceVolume struct {
@@ -43,9 +45,9 @@ func (db *DB) CreateWorkspace(id, name string) error {
 func (db *DB) GetWorkspace(id string) (*Workspace, error) {
 	w := &Workspace{}
 	err := db.QueryRow(
-		`SELECT id, name, k8s_namespace, created_at, updated_at FROM workspaces WHERE id = $1`,
+		`SELECT id, name, k8s_namespace, created_at, updated_at, archived_at FROM workspaces WHERE id = $1`,
 		id,
-	).Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt)
+	).Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt, &w.ArchivedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -71,14 +73,16 @@ func (db *DB) UpdateWorkspaceName(id, name string) error {
 	return nil
 }
 
-func (db *DB) ListWorkspacesByUser(userID string) ([]*Workspace, error) {
+// ListWorkspacesByUser returns the workspaces the user is a member of.
+// Archived workspaces are omitted unless includeArchived is set.
+func (db *DB) ListWorkspacesByUser(userID string, includeArchived bool) ([]*Workspace, error) {
 	rows, err := db.Query(
-		`SELECT w.id, w.name, w.k8s_namespace, w.created_at, w.updated_at
+		`SELECT w.id, w.name, w.k8s_namespace, w.created_at, w.updated_at, w.archived_at
 		 FROM workspaces w
 		 JOIN workspace_members wm ON w.id = wm.workspace_id
-		 WHERE wm.user_id = $1
+		 WHERE wm.user_id = $1 AND ($2 OR w.archived_at IS NULL)
 		 ORDER BY w.created_at ASC`,
-		userID,
+		userID, includeArchived,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list workspaces by user: %w", err)
@@ -88,7 +92,7 @@ func (db *DB) ListWorkspacesByUser(userID string) ([]*Workspace, error) {
 	var workspaces []*Workspace
 	for rows.Next() {
 		w := &Workspace{}
-		if err := rows.Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		if err := rows.Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt, &w.ArchivedAt); err != nil {
 			return nil, fmt.Errorf("scan workspace: %w", err)
 		}
 		workspaces = append(workspaces, w)
@@ -225,7 +229,7 @@ func (db *DB) GetAllWorkspaceNamespaces() ([]string, error) {
 
 func (db *DB) ListWorkspacesWithoutNamespace() ([]*Workspace, error) {
 	rows, err := db.Query(
-		`SELECT id, name, k8s_namespace, created_at, updated_at
+		`SELECT id, name, k8s_namespace, created_at, updated_at, archived_at
 		 FROM workspaces
 		 WHERE k8s_namespace IS NULL OR k8s_namespace = ''`,
 	)
@@ -237,7 +241,7 @@ func (db *DB) ListWorkspacesWithoutNamespace() ([]*Workspace, error) {
 	var workspaces []*Workspace
 	for rows.Next() {
 		w := &Workspace{}
-		if err := rows.Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		if err := rows.Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt, &w.ArchivedAt); err != nil {
 			return nil, fmt.Errorf("scan workspace: %w", err)
 		}
 		workspaces = append(workspaces, w)
@@ -247,7 +251,7 @@ func (db *DB) ListWorkspacesWithoutNamespace() ([]*Workspace, error) {
 
 func (db *DB) ListAllWorkspaces() ([]*Workspace, error) {
 	rows, err := db.Query(
-		`SELECT id, name, k8s_namespace, created_at, updated_at
+		`SELECT id, name, k8s_namespace, created_at, updated_at, archived_at
 		 FROM workspaces ORDER BY created_at ASC`,
 	)
 	if err != nil {
@@ -258,7 +262,7 @@ func (db *DB) ListAllWorkspaces() ([]*Workspace, error) {
 	var workspaces []*Workspace
 	for rows.Next() {
 		w := &Workspace{}
-		if err := rows.Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		if err := rows.Scan(&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt, &w.ArchivedAt); err != nil {
 			return nil, fmt.Errorf("scan workspace: %w", err)
 		}
 		workspaces = append(workspaces, w)
@@ -278,7 +282,7 @@ type AdminWorkspaceInfo struct {
 
 func (db *DB) ListAllWorkspacesAdmin() ([]*AdminWorkspaceInfo, error) {
 	rows, err := db.Query(
-		`SELECT w.id, w.name, w.k8s_namespace, w.created_at, w.updated_at,
+		`SELECT w.id, w.name, w.k8s_namespace, w.created_at, w.updated_at, w.archived_at,
 		        u.id, u.email, u.name, u.picture,
 		        (SELECT COUNT(*) FROM sandboxes s WHERE s.workspace_id = w.id)
 		 FROM workspaces w
@@ -295,7 +299,7 @@ func (db *DB) ListAllWorkspacesAdmin() ([]*AdminWorkspaceInfo, error) {
 	for rows.Next() {
 		w := &AdminWorkspaceInfo{}
 		if err := rows.Scan(
-			&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt,
+			&w.ID, &w.Name, &w.K8sNamespace, &w.CreatedAt, &w.UpdatedAt, &w.ArchivedAt,
 			&w.OwnerID, &w.OwnerEmail, &w.OwnerName, &w.OwnerPicture,
 			&w.SandboxCount,
 		); err != nil {
@@ -337,3 +341,70 @@ func (db *DB) ListWorkspaceVolumes(workspaceID string) ([]WorkspaceVolume, error
 	}
 	return volumes, rows.Err()
 }
+
+// ListAllWorkspaceVolumes returns the volumes of all workspaces.
+func (db *DB) ListAllWorkspaceVolumes() ([]WorkspaceVolume, error) {
+	rows, err := db.Query(`SELECT id, workspace_id, pvc_name, mount_path, created_at FROM workspace_volumes ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("list all workspace volumes: %w", err)
+	}
+	defer rows.Close()
+
+	var volumes []WorkspaceVolume
+	for rows.Next() {
+		var v WorkspaceVolume
+		if err := rows.Scan(&v.ID, &v.WorkspaceID, &v.PVCName, &v.MountPath, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan workspace volume: %w", err)
+		}
+		volumes = append(volumes, v)
+	}
+	return volumes, rows.Err()
+}
+
+// SetWorkspaceArchived marks (or clears) a workspace as archived. snapshots
+// lists the drive snapshots taken at archive time, if any.
+func (db *DB) SetWorkspaceArchived(id string, archived bool, snapshots []string) error {
+	var err error
+	if archived {
+		var blob []byte
+		blob, err = json.Marshal(snapshots)
+		if err != nil {
+			return fmt.Errorf("marshal archive snapshots: %w", err)
+		}
+		_, err = db.Exec(
+			`UPDATE workspaces SET archived_at = NOW(), archive_snapshots = $2, updated_at = NOW() WHERE id = $1`,
+			id, blob,
+		)
+	} else {
+		_, err = db.Exec(
+			`UPDATE workspaces SET archived_at = NULL, archive_snapshots = NULL, updated_at = NOW() WHERE id = $1`,
+			id,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("set workspace archived: %w", err)
+	}
+	return nil
+}
+
+// GetWorkspacePriorityTier returns the scheduling priority tier of a
+// workspace ("normal" unless an admin changed it).
+func (db *DB) GetWorkspacePriorityTier(workspaceID string) (string, error) {
+	var tier string
+	err := db.QueryRow("SELECT priority_tier FROM workspaces WHERE id = $1", workspaceID).Scan(&tier)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get workspace priority tier: %w", err)
+	}
+	return tier, nil
+}
+
+func (db *DB) SetWorkspacePriorityTier(workspaceID, tier string) error {
+	_, err := db.Exec("UPDATE workspaces SET priority_tier = $2, updated_at = NOW() WHERE id = $1", workspaceID, tier)
+	if err != nil {
+		return fmt.Errorf("set workspace priority tier: %w", err)
+	}
+	return nil
+}
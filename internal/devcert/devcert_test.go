@@ -0,0 +1,57 @@
+package devcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"testing"
+)
+
+func TestEnsure(t *testing.T) {
+	dir := t.TempDir()
+	hosts := []string{"localtest.me", "*.localtest.me", "127.0.0.1"}
+
+	files, err := Ensure(dir, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM, err := os.ReadFile(files.CACert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	leaf, _ := x509.ParseCertificate(pair.Certificate[0])
+	for _, name := range []string{"localtest.me", "code-abc.localtest.me", "127.0.0.1"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("verify %s: %v", name, err)
+		}
+	}
+
+	// A second run reuses both files.
+	before, _ := os.ReadFile(files.Cert)
+	if _, err := Ensure(dir, hosts); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(files.Cert)
+	if string(before) != string(after) {
+		t.Error("certificate was regenerated without need")
+	}
+
+	// New hosts reissue the server certificate from the same CA.
+	if _, err := Ensure(dir, append(hosts, "*.nip.io")); err != nil {
+		t.Fatal(err)
+	}
+	caAfter, _ := os.ReadFile(files.CACert)
+	if string(caPEM) != string(caAfter) {
+		t.Error("CA was regenerated")
+	}
+	after, _ = os.ReadFile(files.Cert)
+	if string(before) == string(after) {
+		t.Error("certificate was not reissued for new hosts")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -19,6 +20,7 @@ const (
 	bridgeRetryDelay       = 2 * time.Second
 	bridgeBackoffDelay     = 30 * time.Second
 	maxConsecutiveFailures = 3
+	nanoclawBridgePort     = "3002"
 	forwardTimeout         = 10 * time.Second
 )
 
@@ -567,7 +569,7 @@ func (b *Bridge) forwardToNanoClaw(ctx context.Context, binding BridgeBinding, m
 		return false, fmt.Errorf("marshal message: %w", err)
 	}
 
-	url := fmt.Sprintf("http://%s:3002/message", podIP)
+	url := "http://" + net.JoinHostPort(podIP, nanoclawBridgePort) + "/message"
 	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
 	defer cancel()
 
@@ -604,7 +606,7 @@ func (b *Bridge) ensureChatRegistered(ctx context.Context, podIP, bridgeSecret,
 		return fmt.Errorf("marshal metadata: %w", err)
 	}
 
-	url := fmt.Sprintf("http://%s:3002/metadata", podIP)
+	url := "http://" + net.JoinHostPort(podIP, nanoclawBridgePort) + "/metadata"
 	ctx, cancel := context.WithTimeout(ctx, forwardTimeout)
 	defer cancel()
 
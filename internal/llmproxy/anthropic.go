@@ -42,6 +42,10 @@ func (s *Server) handleAnthropicProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "sandbox not active", http.StatusForbidden)
 		return
 	}
+	if !sbx.allowsProvider(ProviderAnthropic) {
+		writeAnthropicError(w, http.StatusForbidden, "permission_error", "provider anthropic is not enabled for this sandbox")
+		return
+	}
 
 	// 1a. Determine upstream target.
 	targetURL := s.config.AnthropicBaseURL
@@ -54,26 +58,52 @@ func (s *Server) handleAnthropicProxy(w http.ResponseWriter, r *http.Request) {
 	isMessagesEndpoint := strings.HasSuffix(r.URL.Path, "/messages")
 	if isMessagesEndpoint && !useModelserver {
 		if exceeded, current, max := s.checkRPD(sbx.WorkspaceID); exceeded {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			json.NewEncoder(w).Encode(anthropic.ErrorResponse{
-				Type: "error",
-				Error: anthropic.ErrorObjectUnion{
-					Type:    "rate_limit_error",
-					Message: fmt.Sprintf("workspace requests per day quota exceeded (%d/%d)", current, max),
-				},
-			})
+			writeAnthropicError(w, http.StatusTooManyRequests, "rate_limit_error",
+				fmt.Sprintf("workspace requests per day quota exceeded (%d/%d)", current, max))
 			return
 		}
 	}
 
+	// 1c. Throttle or block sandboxes near or over their daily budget.
+	if isMessagesEndpoint && !s.applySandboxBudget(r.Context(), w, sbx) {
+		return
+	}
+
+	// 1d. Reject requests once a monthly spend limit is used up.
+	if isMessagesEndpoint && !s.applySpendLimits(w, sbx) {
+		return
+	}
+
 	// 2. Read body for trace extraction and stream detection.
 	bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
 	if err != nil {
 		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
 		return
 	}
+
+	// 2a. Apply the workspace model policy (default model, allowlist).
+	if isMessagesEndpoint || strings.HasSuffix(r.URL.Path, "/count_tokens") {
+		rewritten, from, perr := sbx.ModelPolicy.apply(bodyBytes)
+		if perr != nil {
+			s.logger.Warn("request rejected by workspace model policy", "workspace_id", sbx.WorkspaceID, "error", perr.message)
+			writeAnthropicError(w, perr.status, perr.errType, perr.message)
+			return
+		}
+		if from != "" {
+			s.logger.Info("model rewritten by workspace model policy", "workspace_id", sbx.WorkspaceID, "from", from, "to", sbx.ModelPolicy.DefaultModel)
+			w.Header().Set("X-Agentserver-Model-Rewritten", from+" -> "+sbx.ModelPolicy.DefaultModel)
+		}
+		bodyBytes = rewritten
+	}
 	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	r.ContentLength = int64(len(bodyBytes))
+
+	// 2b. Enforce the token's scope (models, max_tokens, endpoints).
+	if serr := sbx.Scope.check(r.URL.Path, bodyBytes); serr != nil {
+		s.logger.Warn("request outside proxy token scope", "sandbox_id", sbx.SandboxID, "error", serr.message)
+		writeAnthropicError(w, serr.status, serr.errType, serr.message)
+		return
+	}
 
 	// Detect streaming from request body.
 	var reqShape struct {
@@ -228,6 +258,7 @@ func (s *Server) recordUsage(sbx *TokenInfo, traceID, requestID, model, msgID st
 		TraceID:                  traceID,
 		SandboxID:                sbx.SandboxID,
 		WorkspaceID:              sbx.WorkspaceID,
+		UserID:                   sbx.UserID,
 		Provider:                 "anthropic", // TODO: track provider as "modelserver" for MS-forwarded requests
 		Model:                    model,
 		MessageID:                msgID,
@@ -240,6 +271,7 @@ func (s *Server) recordUsage(sbx *TokenInfo, traceID, requestID, model, msgID st
 		TTFT:                     ttft,
 		CreatedAt:                time.Now(),
 	}
+	u.CostUSD = s.config.ModelPrices.cost(u)
 
 	if err := s.store.RecordUsage(u); err != nil {
 		logger.Error("failed to record usage", "error", err)
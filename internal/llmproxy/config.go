@@ -1,6 +1,8 @@
 package llmproxy
 
 import (
+	"encoding/json"
+	"log"
 	"os"
 	"strconv"
 )
@@ -15,8 +17,23 @@ type Config struct {
 	AnthropicAuthToken string // alternative: Bearer token auth
 	GeminiBaseURL      string // upstream Gemini API URL
 	GeminiAPIKey       string // real Google API key for Gemini
+	OpenAIBaseURL      string // upstream OpenAI API URL
+	OpenAIAPIKey       string // real OpenAI API key
+	Bedrock            BedrockConfig
 	TraceHeader        string // custom trace header name
 	DefaultMaxRPD      int    // default max requests per day per workspace (0 = unlimited)
+	// ModelPrices prices recorded usage, for spend limits and reports.
+	ModelPrices ModelPrices
+}
+
+// BedrockConfig configures the Bedrock runtime upstream. Requests are
+// SigV4-signed with these credentials.
+type BedrockConfig struct {
+	Region          string
+	BaseURL         string // default https://bedrock-runtime.<region>.amazonaws.com
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
 }
 
 // LoadConfigFromEnv reads configuration from environment variables.
@@ -30,13 +47,28 @@ func LoadConfigFromEnv() Config {
 		AnthropicAuthToken: os.Getenv("ANTHROPIC_AUTH_TOKEN"),
 		GeminiBaseURL:      envOr("GEMINI_BASE_URL", "https://generativelanguage.googleapis.com"),
 		GeminiAPIKey:       os.Getenv("GEMINI_API_KEY"),
+		OpenAIBaseURL:      envOr("OPENAI_BASE_URL", "https://api.openai.com"),
+		OpenAIAPIKey:       os.Getenv("OPENAI_API_KEY"),
 		TraceHeader:        envOr("LLMPROXY_TRACE_HEADER", "X-Trace-Id"),
+		Bedrock: BedrockConfig{
+			Region:          os.Getenv("BEDROCK_REGION"),
+			BaseURL:         os.Getenv("BEDROCK_BASE_URL"),
+			AccessKeyID:     os.Getenv("BEDROCK_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("BEDROCK_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("BEDROCK_SESSION_TOKEN"),
+		},
 	}
 	if v := os.Getenv("LLMPROXY_DEFAULT_MAX_RPD"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
 			cfg.DefaultMaxRPD = n
 		}
 	}
+	if v := os.Getenv("LLMPROXY_MODEL_PRICES"); v != "" {
+		if err := json.Unmarshal([]byte(v), &cfg.ModelPrices); err != nil {
+			log.Printf("Warning: ignoring LLMPROXY_MODEL_PRICES: %v", err)
+			cfg.ModelPrices = nil
+		}
+	}
 	return cfg
 }
 
@@ -43,6 +43,10 @@ func (s *Server) handleGeminiProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "sandbox not active", http.StatusForbidden)
 		return
 	}
+	if !sbx.allowsProvider(ProviderGemini) {
+		http.Error(w, "provider gemini is not enabled for this sandbox", http.StatusForbidden)
+		return
+	}
 
 	// 2. Determine upstream target.
 	targetURL := s.config.GeminiBaseURL
@@ -70,6 +74,9 @@ func (s *Server) handleGeminiProxy(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if isGenerateEndpoint && !s.applySpendLimits(w, sbx) {
+		return
+	}
 
 	// 4. Read body for trace extraction.
 	bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
@@ -219,6 +226,7 @@ func (s *Server) recordGeminiUsage(sbx *TokenInfo, traceID, requestID, model str
 		TraceID:              traceID,
 		SandboxID:            sbx.SandboxID,
 		WorkspaceID:          sbx.WorkspaceID,
+		UserID:               sbx.UserID,
 		Provider:             "gemini",
 		Model:                model,
 		InputTokens:          usage.PromptTokenCount,
@@ -229,6 +237,7 @@ func (s *Server) recordGeminiUsage(sbx *TokenInfo, traceID, requestID, model str
 		TTFT:                 ttft,
 		CreatedAt:            time.Now(),
 	}
+	u.CostUSD = s.config.ModelPrices.cost(u)
 
 	if err := s.store.RecordUsage(u); err != nil {
 		logger.Error("failed to record usage", "error", err)
@@ -0,0 +1,15 @@
+-- Per-workspace daily budgets applied to each sandbox. Past throttle_percent
+-- of a budget requests are delayed (up to max_delay_ms); at 100% they are
+-- rejected. Usage counts from the start of the day (UTC) or reset_at,
+-- whichever is later, so owners can reset a throttled sandbox.
+CREATE TABLE sandbox_budgets (
+    workspace_id         TEXT PRIMARY KEY,
+    max_tokens_per_day   BIGINT,
+    max_requests_per_day INTEGER,
+    throttle_percent     INTEGER NOT NULL DEFAULT 80,
+    max_delay_ms         INTEGER NOT NULL DEFAULT 5000,
+    reset_at             TIMESTAMPTZ,
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_usage_sandbox_created ON usage(sandbox_id, created_at);
@@ -0,0 +1,6 @@
+-- Usage is attributed to the user who created the sandbox, for per-user
+-- billing and abuse detection. NULL for workspace tokens and for usage
+-- recorded before attribution.
+ALTER TABLE usage ADD COLUMN user_id TEXT;
+
+CREATE INDEX idx_usage_user_created ON usage(user_id, created_at);
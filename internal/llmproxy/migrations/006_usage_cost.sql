@@ -0,0 +1,5 @@
+-- Cost of each request in USD, computed from the configured model prices
+-- (LLMPROXY_MODEL_PRICES) when it is recorded. 0 for models without a price.
+ALTER TABLE usage ADD COLUMN cost_usd DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_usage_workspace_created ON usage(workspace_id, created_at);
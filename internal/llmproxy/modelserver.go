@@ -7,12 +7,15 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/agentserver/agentserver/internal/clock"
 )
 
 // modelserverTokenCache is a thread-safe in-memory cache for modelserver access tokens.
 type modelserverTokenCache struct {
 	mu    sync.RWMutex
 	items map[string]cachedToken
+	clock clock.Clock
 }
 
 type cachedToken struct {
@@ -21,9 +24,10 @@ type cachedToken struct {
 	fetchedAt   time.Time
 }
 
-func newModelserverTokenCache() *modelserverTokenCache {
+func newModelserverTokenCache(c clock.Clock) *modelserverTokenCache {
 	return &modelserverTokenCache{
 		items: make(map[string]cachedToken),
+		clock: c,
 	}
 }
 
@@ -38,7 +42,7 @@ func (c *modelserverTokenCache) Get(workspaceID string) (string, bool) {
 		return "", false
 	}
 
-	now := time.Now()
+	now := c.clock.Now()
 
 	// Stale if fetched more than 5 minutes ago.
 	if now.Sub(tok.fetchedAt) > 5*time.Minute {
@@ -61,7 +65,7 @@ func (c *modelserverTokenCache) Set(workspaceID, accessToken string, expiresAt t
 	c.items[workspaceID] = cachedToken{
 		accessToken: accessToken,
 		expiresAt:   expiresAt,
-		fetchedAt:   time.Now(),
+		fetchedAt:   c.clock.Now(),
 	}
 }
 
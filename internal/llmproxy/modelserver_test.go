@@ -0,0 +1,31 @@
+package llmproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/clock"
+)
+
+func TestModelserverTokenCacheExpiry(t *testing.T) {
+	fc := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	c := newModelserverTokenCache(fc)
+
+	c.Set("ws-1", "tok", fc.Now().Add(time.Hour))
+	if tok, ok := c.Get("ws-1"); !ok || tok != "tok" {
+		t.Fatalf("fresh token: Get = %q, %v", tok, ok)
+	}
+	fc.Advance(5*time.Minute + time.Second)
+	if _, ok := c.Get("ws-1"); ok {
+		t.Error("token fetched over 5 minutes ago still served")
+	}
+
+	c.Set("ws-2", "short", fc.Now().Add(90*time.Second))
+	if _, ok := c.Get("ws-2"); !ok {
+		t.Error("token 90s from expiry not served")
+	}
+	fc.Advance(31 * time.Second)
+	if _, ok := c.Get("ws-2"); ok {
+		t.Error("token under 60s from expiry still served")
+	}
+}
@@ -46,6 +46,10 @@ func (s *Server) handleOpenAIProxy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "sandbox not active", http.StatusForbidden)
 		return
 	}
+	if !sbx.allowsProvider(ProviderOpenAI) {
+		http.Error(w, "provider openai is not enabled for this sandbox", http.StatusForbidden)
+		return
+	}
 	if sbx.ModelserverUpstreamURL == "" {
 		http.Error(w, "workspace has no modelserver connection", http.StatusForbidden)
 		return
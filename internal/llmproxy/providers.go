@@ -0,0 +1,334 @@
+package llmproxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/go-chi/chi/v5"
+)
+
+// LLM providers served under /proxy/{provider}/*. The rest of the path is
+// the provider's own API path, so a client only needs its base URL pointed
+// at /proxy/{provider} and its API key set to the proxy token.
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderGemini    = "gemini"
+	ProviderBedrock   = "bedrock"
+)
+
+// handleProviderProxy dispatches /proxy/{provider}/* to the provider's
+// handler with the prefix stripped.
+func (s *Server) handleProviderProxy(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "provider")
+	var h http.HandlerFunc
+	switch name {
+	case ProviderAnthropic:
+		h = s.handleAnthropicProxy
+	case ProviderGemini:
+		h = s.handleGeminiProxy
+	case ProviderOpenAI:
+		h = s.handleOpenAIUpstreamProxy
+	case ProviderBedrock:
+		h = s.handleBedrockProxy
+	default:
+		http.Error(w, "unknown provider: "+name, http.StatusNotFound)
+		return
+	}
+	http.StripPrefix("/proxy/"+name, h).ServeHTTP(w, r)
+}
+
+// allowsProvider reports whether the token may use provider. Tokens without
+// a provider selection may use all of them.
+func (t *TokenInfo) allowsProvider(provider string) bool {
+	return len(t.Providers) == 0 || slices.Contains(t.Providers, provider)
+}
+
+// upstreamProvider is a provider proxied to its public API with a
+// server-side credential.
+type upstreamProvider struct {
+	name    string
+	baseURL string
+	// authorize sets the server credential on the outgoing request, whose
+	// body is body.
+	authorize func(req *http.Request, body []byte) error
+	// model returns the model a request is for, if any.
+	model func(r *http.Request, body []byte) string
+}
+
+// handleOpenAIUpstreamProxy proxies /proxy/openai/* to the OpenAI API
+// (OPENAI_BASE_URL) with OPENAI_API_KEY.
+func (s *Server) handleOpenAIUpstreamProxy(w http.ResponseWriter, r *http.Request) {
+	if s.config.OpenAIAPIKey == "" {
+		http.Error(w, "openai not configured", http.StatusServiceUnavailable)
+		return
+	}
+	s.proxyUpstream(w, r, upstreamProvider{
+		name:    ProviderOpenAI,
+		baseURL: s.config.OpenAIBaseURL,
+		authorize: func(req *http.Request, _ []byte) error {
+			req.Header.Set("Authorization", "Bearer "+s.config.OpenAIAPIKey)
+			return nil
+		},
+		model: bodyModel,
+	})
+}
+
+// handleBedrockProxy proxies /proxy/bedrock/* to the Bedrock runtime API of
+// BEDROCK_REGION, signing requests with the server's AWS credentials.
+// Clients authenticate with the proxy token as a Bedrock API key
+// (AWS_BEARER_TOKEN_BEDROCK), which SDKs send as a Bearer token.
+func (s *Server) handleBedrockProxy(w http.ResponseWriter, r *http.Request) {
+	cfg := s.config.Bedrock
+	if cfg.Region == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		http.Error(w, "bedrock not configured", http.StatusServiceUnavailable)
+		return
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://bedrock-runtime." + cfg.Region + ".amazonaws.com"
+	}
+	creds := aws.Credentials{
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+	}
+	s.proxyUpstream(w, r, upstreamProvider{
+		name:    ProviderBedrock,
+		baseURL: baseURL,
+		authorize: func(req *http.Request, body []byte) error {
+			// Sign only the headers Bedrock needs: everything present is
+			// signed, and hop-by-hop headers are dropped after signing.
+			h := make(http.Header)
+			for k, v := range req.Header {
+				if k == "Content-Type" || k == "Accept" || strings.HasPrefix(k, "X-Amzn-Bedrock-") {
+					h[k] = v
+				}
+			}
+			req.Header = h
+			sum := sha256.Sum256(body)
+			return v4.NewSigner().SignHTTP(req.Context(), creds, req, hex.EncodeToString(sum[:]),
+				"bedrock", cfg.Region, time.Now())
+		},
+		model: bedrockModel,
+	})
+}
+
+// proxyUpstream validates the proxy token, enforces the token's provider
+// selection, scope and workspace model allowlist, and forwards the request
+// to p. Usage is recorded for non-streaming responses that report it.
+func (s *Server) proxyUpstream(w http.ResponseWriter, r *http.Request, p upstreamProvider) {
+	proxyToken := extractProxyToken(r.Header)
+	if proxyToken == "" {
+		http.Error(w, "missing api key", http.StatusUnauthorized)
+		return
+	}
+	sbx, err := s.ValidateProxyToken(r.Context(), proxyToken)
+	if err != nil {
+		s.logger.Error(p.name+": token validation failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if sbx == nil {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return
+	}
+	if sbx.TokenType == "sandbox" && sbx.Status != "running" && sbx.Status != "creating" {
+		http.Error(w, "sandbox not active", http.StatusForbidden)
+		return
+	}
+	if !sbx.allowsProvider(p.name) {
+		http.Error(w, "provider "+p.name+" is not enabled for this sandbox", http.StatusForbidden)
+		return
+	}
+	if !s.applySandboxBudget(r.Context(), w, sbx) || !s.applySpendLimits(w, sbx) {
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	r.ContentLength = int64(len(bodyBytes))
+
+	if serr := sbx.Scope.check(r.URL.Path, bodyBytes); serr != nil {
+		s.logger.Warn("request outside proxy token scope", "sandbox_id", sbx.SandboxID, "error", serr.message)
+		http.Error(w, serr.message, serr.status)
+		return
+	}
+	// The default model of a workspace policy names a model of one
+	// provider, so other providers only get the allowlist.
+	model := p.model(r, bodyBytes)
+	if msg := modelDisallowed(sbx, model); msg != "" {
+		http.Error(w, msg, http.StatusForbidden)
+		return
+	}
+
+	target, err := url.Parse(p.baseURL)
+	if err != nil {
+		s.logger.Error(p.name+": invalid upstream URL", "error", err, "url", p.baseURL)
+		http.Error(w, "invalid upstream URL", http.StatusInternalServerError)
+		return
+	}
+	requestID := GenerateRequestID()
+	logger := s.logger.With(
+		"provider", p.name,
+		"request_id", requestID,
+		"sandbox_id", sbx.SandboxID,
+		"workspace_id", sbx.WorkspaceID,
+	)
+	startTime := time.Now()
+
+	reqPath, rawQuery := r.URL.Path, r.URL.RawQuery
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = target.Scheme
+			req.URL.Host = target.Host
+			req.URL.Path = joinPaths(target.Path, reqPath)
+			req.URL.RawPath = ""
+			req.URL.RawQuery = rawQuery
+			req.Host = target.Host
+			req.Header.Del("x-api-key")
+			req.Header.Del("Authorization")
+			if err := p.authorize(req, bodyBytes); err != nil {
+				logger.Error("failed to authorize upstream request", "error", err)
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode < 200 || resp.StatusCode >= 300 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+				return nil
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			if err != nil {
+				logger.Error("failed to read response body", "error", err)
+				return nil
+			}
+			if u, ok := parseProviderUsage(body); ok {
+				if u.Model == "" {
+					u.Model = model
+				}
+				s.recordProviderUsage(sbx, p.name, requestID, u, time.Since(startTime).Milliseconds(), logger)
+			}
+			return nil
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			logger.Error("proxy error", "error", err)
+			http.Error(w, "proxy error", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// modelDisallowed returns why model is not allowed by the workspace model
+// allowlist, or "" if it is (or the request names no model).
+func modelDisallowed(sbx *TokenInfo, model string) string {
+	p := sbx.ModelPolicy
+	if model == "" || p == nil || len(p.AllowedModels) == 0 || matchAny(p.AllowedModels, model) {
+		return ""
+	}
+	return fmt.Sprintf("model %s is not allowed in this workspace; allowed models: %s",
+		model, strings.Join(p.AllowedModels, ", "))
+}
+
+// bodyModel returns the "model" field of a JSON request body.
+func bodyModel(_ *http.Request, body []byte) string {
+	var req struct {
+		Model string `json:"model"`
+	}
+	json.Unmarshal(body, &req) // best-effort; requests without a body name no model
+	return req.Model
+}
+
+// bedrockModel returns the model ID of a Bedrock runtime path such as
+// /model/{modelId}/invoke or /model/{modelId}/converse.
+func bedrockModel(r *http.Request, _ []byte) string {
+	rest, ok := strings.CutPrefix(r.URL.Path, "/model/")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	if m, err := url.PathUnescape(id); err == nil {
+		return m
+	}
+	return id
+}
+
+// providerUsage is the token usage reported by an upstream response.
+type providerUsage struct {
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// parseProviderUsage extracts token usage from a JSON response in the
+// OpenAI chat completions or responses shape, or the Bedrock converse or
+// Anthropic-on-Bedrock invoke shape.
+func parseProviderUsage(body []byte) (providerUsage, bool) {
+	var resp struct {
+		Model string `json:"model"`
+		Usage *struct {
+			PromptTokens     int64 `json:"prompt_tokens"`
+			CompletionTokens int64 `json:"completion_tokens"`
+			InputTokens      int64 `json:"input_tokens"`
+			OutputTokens     int64 `json:"output_tokens"`
+			InputTokensBR    int64 `json:"inputTokens"`
+			OutputTokensBR   int64 `json:"outputTokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Usage == nil {
+		return providerUsage{}, false
+	}
+	u := resp.Usage
+	return providerUsage{
+		Model:        resp.Model,
+		InputTokens:  u.PromptTokens + u.InputTokens + u.InputTokensBR,
+		OutputTokens: u.CompletionTokens + u.OutputTokens + u.OutputTokensBR,
+	}, true
+}
+
+// recordProviderUsage logs and stores the usage of a proxied request.
+func (s *Server) recordProviderUsage(sbx *TokenInfo, provider, requestID string, u providerUsage, duration int64, logger *slog.Logger) {
+	logger.Info("request completed",
+		"model", u.Model,
+		"input_tokens", u.InputTokens,
+		"output_tokens", u.OutputTokens,
+		"duration", duration,
+	)
+	if s.store == nil {
+		return
+	}
+	usage := TokenUsage{
+		ID:           requestID,
+		SandboxID:    sbx.SandboxID,
+		WorkspaceID:  sbx.WorkspaceID,
+		UserID:       sbx.UserID,
+		Provider:     provider,
+		Model:        u.Model,
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		Duration:     duration,
+		CreatedAt:    time.Now(),
+	}
+	usage.CostUSD = s.config.ModelPrices.cost(usage)
+	if err := s.store.RecordUsage(usage); err != nil {
+		logger.Error("failed to record usage", "error", err)
+	}
+}
@@ -0,0 +1,128 @@
+package llmproxy
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProviderProxy(t *testing.T) {
+	agentserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ProxyToken string `json:"proxy_token"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ProxyToken != "proxy-token" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(TokenInfo{
+			TokenType:   "sandbox",
+			SandboxID:   "sbx-1",
+			WorkspaceID: "ws-1",
+			Status:      "running",
+			Providers:   []string{ProviderOpenAI, ProviderBedrock},
+		})
+	}))
+	defer agentserver.Close()
+
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"model":"gpt-test","usage":{"prompt_tokens":3,"completion_tokens":4}}`)
+	}))
+	defer upstream.Close()
+
+	s := NewServer(Config{
+		AgentserverURL: agentserver.URL,
+		OpenAIBaseURL:  upstream.URL,
+		OpenAIAPIKey:   "sk-server",
+		Bedrock: BedrockConfig{
+			Region:          "us-east-1",
+			BaseURL:         upstream.URL,
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "secret",
+		},
+	}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h := s.Routes()
+
+	do := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(`{"model":"gpt-test"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("/proxy/openai/v1/chat/completions", "proxy-token"); rec.Code != http.StatusOK {
+		t.Fatalf("openai: status %d: %s", rec.Code, rec.Body)
+	}
+	if got.URL.Path != "/v1/chat/completions" || got.Header.Get("Authorization") != "Bearer sk-server" {
+		t.Errorf("openai upstream request: path %q, authorization %q", got.URL.Path, got.Header.Get("Authorization"))
+	}
+
+	got = nil
+	if rec := do("/proxy/bedrock/model/anthropic.claude-v2%3A1/invoke", "proxy-token"); rec.Code != http.StatusOK {
+		t.Fatalf("bedrock: status %d: %s", rec.Code, rec.Body)
+	}
+	if auth := got.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/us-east-1/bedrock/") {
+		t.Errorf("bedrock upstream authorization = %q, want SigV4", auth)
+	}
+
+	tests := []struct {
+		path, token string
+		status      int
+	}{
+		{"/proxy/openai/v1/chat/completions", "", http.StatusUnauthorized},
+		{"/proxy/openai/v1/chat/completions", "wrong", http.StatusUnauthorized},
+		{"/proxy/gemini/v1beta/models/gemini-pro:generateContent", "proxy-token", http.StatusForbidden},
+		{"/proxy/anthropic/v1/messages", "proxy-token", http.StatusForbidden},
+		{"/proxy/unknown/v1/chat", "proxy-token", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := do(tt.path, tt.token); rec.Code != tt.status {
+			t.Errorf("%s (token %q): status %d, want %d", tt.path, tt.token, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestBedrockModel(t *testing.T) {
+	for path, want := range map[string]string{
+		"/model/anthropic.claude-3-5-sonnet-20240620-v1:0/invoke": "anthropic.claude-3-5-sonnet-20240620-v1:0",
+		"/model/amazon.nova-pro-v1:0/converse-stream":             "amazon.nova-pro-v1:0",
+		"/guardrail/abc/version/1/apply":                          "",
+	} {
+		r := httptest.NewRequest("POST", path, nil)
+		if got := bedrockModel(r, nil); got != want {
+			t.Errorf("bedrockModel(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseProviderUsage(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    providerUsage
+		wantErr bool
+	}{
+		{`{"model":"gpt-4o","usage":{"prompt_tokens":10,"completion_tokens":5}}`, providerUsage{"gpt-4o", 10, 5}, false},
+		{`{"model":"gpt-5","usage":{"input_tokens":7,"output_tokens":2}}`, providerUsage{"gpt-5", 7, 2}, false},
+		{`{"output":{},"usage":{"inputTokens":4,"outputTokens":9}}`, providerUsage{"", 4, 9}, false},
+		{`{"data":[]}`, providerUsage{}, true},
+		{`not json`, providerUsage{}, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseProviderUsage([]byte(tt.body))
+		if ok == tt.wantErr || got != tt.want {
+			t.Errorf("parseProviderUsage(%s) = %+v, %v; want %+v", tt.body, got, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package llmproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ProxyScope restricts what a proxy token may do upstream. It is returned
+// by token validation; empty fields don't restrict.
+type ProxyScope struct {
+	Models    []string `json:"models,omitempty"`     // allowed models (path.Match patterns)
+	MaxTokens int      `json:"max_tokens,omitempty"` // cap on max_tokens per request
+	Endpoints []string `json:"endpoints,omitempty"`  // allowed paths (path.Match patterns)
+}
+
+// scopeError is a request rejected by a scope, reported to the agent as an
+// Anthropic API error.
+type scopeError struct {
+	status  int
+	errType string
+	message string
+}
+
+func (e *scopeError) Error() string { return e.message }
+
+// check reports whether the request to urlPath with the given body is within
+// the scope.
+func (sc *ProxyScope) check(urlPath string, body []byte) *scopeError {
+	if sc == nil {
+		return nil
+	}
+	if len(sc.Endpoints) > 0 && !matchAny(sc.Endpoints, urlPath) {
+		return &scopeError{http.StatusForbidden, "permission_error",
+			fmt.Sprintf("endpoint %s is not allowed for this sandbox", urlPath)}
+	}
+	if len(sc.Models) == 0 && sc.MaxTokens == 0 {
+		return nil
+	}
+	fields, err := scopedFields(body)
+	if err != nil {
+		return &scopeError{http.StatusBadRequest, "invalid_request_error", err.Error()}
+	}
+	var model string
+	var maxTokens float64
+	if raw, ok := fields["model"]; ok && json.Unmarshal(raw, &model) != nil {
+		return &scopeError{http.StatusBadRequest, "invalid_request_error", "model must be a string"}
+	}
+	if raw, ok := fields["max_tokens"]; ok && json.Unmarshal(raw, &maxTokens) != nil {
+		return &scopeError{http.StatusBadRequest, "invalid_request_error", "max_tokens must be a number"}
+	}
+	if model != "" && len(sc.Models) > 0 && !matchAny(sc.Models, model) {
+		return &scopeError{http.StatusForbidden, "permission_error",
+			fmt.Sprintf("model %s is not allowed for this sandbox; allowed: %v", model, sc.Models)}
+	}
+	if sc.MaxTokens > 0 && maxTokens > float64(sc.MaxTokens) {
+		return &scopeError{http.StatusBadRequest, "invalid_request_error",
+			fmt.Sprintf("max_tokens %v exceeds the limit of %d for this sandbox", maxTokens, sc.MaxTokens)}
+	}
+	return nil
+}
+
+// scopedFieldNames are the body fields a scope checks.
+var scopedFieldNames = []string{"model", "max_tokens"}
+
+// scopedFields returns the top-level fields of a JSON object body; bodies
+// that aren't objects have none. The body is forwarded as it is, and
+// upstream parsers may match names case-insensitively, so checked fields
+// are returned under their name in any case, and one that appears twice is
+// refused.
+func scopedFields(body []byte) (map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil
+	}
+	fields := make(map[string]json.RawMessage)
+	seen := make(map[string]bool)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, nil
+		}
+		for _, name := range scopedFieldNames {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("%s must appear once in the request body", name)
+			}
+			seen[name] = true
+			key = name
+		}
+		fields[key] = raw
+	}
+	return fields, nil
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAnthropicError writes an error in the Anthropic API format, so agents
+// surface the message.
+func writeAnthropicError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(anthropic.ErrorResponse{
+		Type: "error",
+		Error: anthropic.ErrorObjectUnion{
+			Type:    errType,
+			Message: message,
+		},
+	})
+}
+
+// ModelPolicy is a workspace's model allowlist and default model, returned
+// by token validation.
+type ModelPolicy struct {
+	AllowedModels []string `json:"allowed_models"`
+	DefaultModel  string   `json:"default_model,omitempty"`
+	OnDisallowed  string   `json:"on_disallowed,omitempty"` // "reject" or "rewrite"
+}
+
+// apply enforces the policy on a request body: a missing model is set to
+// the default, and a disallowed one is rejected or, with "rewrite",
+// replaced by the default. It returns the (possibly rewritten) body and the
+// original model if it was replaced.
+func (p *ModelPolicy) apply(body []byte) ([]byte, string, *scopeError) {
+	if p == nil || len(body) == 0 {
+		return body, "", nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body, "", nil // not a JSON object; nothing to enforce
+	}
+	var model string
+	if raw, ok := fields["model"]; ok {
+		json.Unmarshal(raw, &model)
+	}
+
+	switch {
+	case model == "":
+		if p.DefaultModel == "" {
+			return body, "", nil
+		}
+	case len(p.AllowedModels) == 0 || matchAny(p.AllowedModels, model):
+		return body, "", nil
+	case p.OnDisallowed == "rewrite" && p.DefaultModel != "":
+	default:
+		msg := fmt.Sprintf("model %s is not allowed in this workspace; allowed models: %s",
+			model, strings.Join(p.AllowedModels, ", "))
+		if p.DefaultModel != "" {
+			msg += "; default: " + p.DefaultModel
+		}
+		return body, "", &scopeError{http.StatusForbidden, "permission_error", msg}
+	}
+
+	fields["model"], _ = json.Marshal(p.DefaultModel)
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return body, "", nil
+	}
+	return out, model, nil
+}
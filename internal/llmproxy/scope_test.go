@@ -0,0 +1,96 @@
+package llmproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProxyScopeCheck(t *testing.T) {
+	sc := &ProxyScope{
+		Models:    []string{"claude-haiku-*", "claude-sonnet-4-5"},
+		MaxTokens: 8192,
+		Endpoints: []string{"/v1/messages", "/v1/messages/count_tokens"},
+	}
+	tests := []struct {
+		path, body string
+		status     int // 0: allowed
+	}{
+		{"/v1/messages", `{"model":"claude-haiku-4-5","max_tokens":1024}`, 0},
+		{"/v1/messages", `{"model":"claude-sonnet-4-5","max_tokens":8192}`, 0},
+		{"/v1/messages", `{"model":"claude-opus-4-1","max_tokens":1024}`, http.StatusForbidden},
+		{"/v1/messages", `{"model":"claude-haiku-4-5","max_tokens":64000}`, http.StatusBadRequest},
+		{"/v1/messages/batches", `{"requests":[]}`, http.StatusForbidden},
+		{"/v1/messages/count_tokens", `{"model":"claude-haiku-4-5"}`, 0},
+		// The body is forwarded unchanged, so every spelling of a checked
+		// field must be seen.
+		{"/v1/messages", `{"model":"claude-opus-4-1","MODEL":"claude-haiku-4-5"}`, http.StatusBadRequest},
+		{"/v1/messages", `{"Model":"claude-haiku-4-5","model":"claude-opus-4-1"}`, http.StatusBadRequest},
+		{"/v1/messages", `{"model":"claude-haiku-4-5","model":"claude-opus-4-1"}`, http.StatusBadRequest},
+		{"/v1/messages", `{"model":"claude-haiku-4-5","max_tokens":1024,"Max_Tokens":64000}`, http.StatusBadRequest},
+		{"/v1/messages", `{"MODEL":"claude-opus-4-1"}`, http.StatusForbidden},
+		{"/v1/messages", `{"model":"claude-haiku-4-5","max_tokens":1e6}`, http.StatusBadRequest},
+		{"/v1/messages", `{"model":["claude-opus-4-1"]}`, http.StatusBadRequest},
+		{"/v1/messages", `{"model":"claude-haiku-4-5","metadata":{"model":"x","MODEL":"y"}}`, 0},
+	}
+	for _, tt := range tests {
+		err := sc.check(tt.path, []byte(tt.body))
+		status := 0
+		if err != nil {
+			status = err.status
+		}
+		if status != tt.status {
+			t.Errorf("%s %s: status %d, want %d (%v)", tt.path, tt.body, status, tt.status, err)
+		}
+	}
+
+	var none *ProxyScope
+	if err := none.check("/v1/messages/batches", nil); err != nil {
+		t.Errorf("nil scope rejected: %v", err)
+	}
+}
+
+func TestModelPolicyApply(t *testing.T) {
+	reject := &ModelPolicy{AllowedModels: []string{"claude-haiku-*", "claude-sonnet-4-5"}, DefaultModel: "claude-haiku-4-5", OnDisallowed: "reject"}
+	rewrite := &ModelPolicy{AllowedModels: reject.AllowedModels, DefaultModel: "claude-haiku-4-5", OnDisallowed: "rewrite"}
+	tests := []struct {
+		name      string
+		policy    *ModelPolicy
+		body      string
+		wantModel string // model in the forwarded body
+		wantFrom  string
+		status    int
+	}{
+		{"allowed", reject, `{"model":"claude-sonnet-4-5"}`, "claude-sonnet-4-5", "", 0},
+		{"pattern", reject, `{"model":"claude-haiku-4-5-20251001"}`, "claude-haiku-4-5-20251001", "", 0},
+		{"default", reject, `{"max_tokens":10}`, "claude-haiku-4-5", "", 0},
+		{"rejected", reject, `{"model":"claude-opus-4-1"}`, "", "", http.StatusForbidden},
+		{"rewritten", rewrite, `{"model":"claude-opus-4-1","max_tokens":10}`, "claude-haiku-4-5", "claude-opus-4-1", 0},
+		{"no policy", nil, `{"model":"claude-opus-4-1"}`, "claude-opus-4-1", "", 0},
+	}
+	for _, tt := range tests {
+		out, from, err := tt.policy.apply([]byte(tt.body))
+		if tt.status != 0 {
+			if err == nil || err.status != tt.status {
+				t.Errorf("%s: err = %v, want status %d", tt.name, err, tt.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		var got struct {
+			Model     string `json:"model"`
+			MaxTokens int    `json:"max_tokens"`
+		}
+		json.Unmarshal(out, &got)
+		if got.Model != tt.wantModel || from != tt.wantFrom {
+			t.Errorf("%s: model %q from %q, want %q from %q", tt.name, got.Model, from, tt.wantModel, tt.wantFrom)
+		}
+		if strings.Contains(tt.body, "max_tokens") && got.MaxTokens != 10 {
+			t.Errorf("%s: max_tokens lost: %s", tt.name, out)
+		}
+	}
+}
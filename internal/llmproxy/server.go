@@ -9,6 +9,8 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/agentserver/agentserver/internal/clock"
 )
 
 // Server is the LLM proxy HTTP server.
@@ -18,6 +20,7 @@ type Server struct {
 	logger       *slog.Logger
 	httpClient   *http.Client // for calling agentserver API
 	msTokenCache *modelserverTokenCache
+	clock        clock.Clock
 }
 
 // NewServer creates a new LLM proxy server.
@@ -29,7 +32,8 @@ func NewServer(cfg Config, store *Store, logger *slog.Logger) *Server {
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
 		},
-		msTokenCache: newModelserverTokenCache(),
+		msTokenCache: newModelserverTokenCache(clock.Real),
+		clock:        clock.Real,
 	}
 }
 
@@ -63,15 +67,25 @@ func (s *Server) Routes() http.Handler {
 	// Gemini API proxy (all /v1beta/* paths).
 	r.HandleFunc("/v1beta/*", s.handleGeminiProxy)
 
+	// Provider proxies: /proxy/anthropic/v1/messages, /proxy/openai/v1/...,
+	// /proxy/gemini/v1beta/..., /proxy/bedrock/model/....
+	r.HandleFunc("/proxy/{provider}/*", s.handleProviderProxy)
+
 	// Internal API (requires database, network-isolated — only agentserver can reach these).
 	r.Route("/internal", func(r chi.Router) {
 		r.Use(s.requireStore)
 		r.Get("/usage", s.handleQueryUsage)
+		r.Get("/spend", s.handleQuerySpend)
+		r.Post("/users/{user_id}/pseudonymize", s.handlePseudonymizeUser)
 		r.Get("/traces", s.handleQueryTraces)
 		r.Get("/traces/{id}", s.handleGetTrace)
 		r.Get("/quotas/{workspace_id}", s.handleGetWorkspaceQuota)
 		r.Put("/quotas/{workspace_id}", s.handleSetWorkspaceQuota)
 		r.Delete("/quotas/{workspace_id}", s.handleDeleteWorkspaceQuota)
+		r.Get("/budgets/{workspace_id}", s.handleGetSandboxBudget)
+		r.Put("/budgets/{workspace_id}", s.handleSetSandboxBudget)
+		r.Delete("/budgets/{workspace_id}", s.handleDeleteSandboxBudget)
+		r.Post("/budgets/{workspace_id}/reset", s.handleResetSandboxBudget)
 	})
 
 	return r
@@ -88,9 +102,14 @@ func (s *Server) requireStore(next http.Handler) http.Handler {
 	})
 }
 
-// handleQueryUsage returns aggregated token usage.
+// handleQueryUsage returns aggregated token usage, optionally grouped by
+// workspace, sandbox or user (?group_by=).
 func (s *Server) handleQueryUsage(w http.ResponseWriter, r *http.Request) {
 	opts := parseQueryOpts(r)
+	if _, ok := usageGroupColumns[opts.GroupBy]; opts.GroupBy != "" && !ok {
+		http.Error(w, "group_by must be workspace, sandbox or user", http.StatusBadRequest)
+		return
+	}
 
 	usage, err := s.store.QueryUsage(opts)
 	if err != nil {
@@ -105,11 +124,38 @@ func (s *Server) handleQueryUsage(w http.ResponseWriter, r *http.Request) {
 	if !opts.Since.IsZero() {
 		resp["since"] = opts.Since.Format(time.RFC3339)
 	}
+	if !opts.Until.IsZero() {
+		resp["until"] = opts.Until.Format(time.RFC3339)
+	}
+	if opts.GroupBy != "" {
+		resp["group_by"] = opts.GroupBy
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handlePseudonymizeUser replaces a user's ID in usage records by the
+// pseudonym in the body, when agentserver erases the user.
+func (s *Server) handlePseudonymizeUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pseudonym string `json:"pseudonym"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Pseudonym == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	n, err := s.store.PseudonymizeUsageUser(chi.URLParam(r, "user_id"), req.Pseudonym)
+	if err != nil {
+		s.logger.Error("pseudonymize user failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"updated": n})
+}
+
 // handleQueryTraces returns traces with aggregated statistics.
 func (s *Server) handleQueryTraces(w http.ResponseWriter, r *http.Request) {
 	opts := parseQueryOpts(r)
@@ -215,16 +261,104 @@ func (s *Server) handleDeleteWorkspaceQuota(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleGetSandboxBudget returns the sandbox budget of a workspace.
+func (s *Server) handleGetSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "workspace_id")
+
+	b, err := s.store.GetSandboxBudget(workspaceID)
+	if err != nil {
+		s.logger.Error("get sandbox budget failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sandbox_budget": b,
+	})
+}
+
+// handleSetSandboxBudget sets the sandbox budget of a workspace.
+func (s *Server) handleSetSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "workspace_id")
+
+	req := SandboxBudget{ThrottlePercent: 80, MaxDelayMs: 5000}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if (req.MaxTokensPerDay != nil && *req.MaxTokensPerDay < 0) || (req.MaxRequestsPerDay != nil && *req.MaxRequestsPerDay < 0) {
+		http.Error(w, "budgets must be >= 0", http.StatusBadRequest)
+		return
+	}
+	if req.ThrottlePercent < 0 || req.ThrottlePercent > 100 {
+		http.Error(w, "throttle_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	if req.MaxDelayMs < 0 || req.MaxDelayMs > 60000 {
+		http.Error(w, "max_delay_ms must be between 0 and 60000", http.StatusBadRequest)
+		return
+	}
+	req.WorkspaceID = workspaceID
+
+	if err := s.store.SetSandboxBudget(req); err != nil {
+		s.logger.Error("set sandbox budget failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteSandboxBudget removes the sandbox budget of a workspace.
+func (s *Server) handleDeleteSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "workspace_id")
+
+	if err := s.store.DeleteSandboxBudget(workspaceID); err != nil {
+		s.logger.Error("delete sandbox budget failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleResetSandboxBudget restarts usage counting for a workspace's sandboxes,
+// lifting throttling until they approach the budget again.
+func (s *Server) handleResetSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "workspace_id")
+
+	ok, err := s.store.ResetSandboxBudget(workspaceID)
+	if err != nil {
+		s.logger.Error("reset sandbox budget failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "no sandbox budget configured", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func parseQueryOpts(r *http.Request) QueryOpts {
 	opts := QueryOpts{
 		WorkspaceID: r.URL.Query().Get("workspace_id"),
 		SandboxID:   r.URL.Query().Get("sandbox_id"),
+		UserID:      r.URL.Query().Get("user_id"),
+		GroupBy:     r.URL.Query().Get("group_by"),
 	}
 	if since := r.URL.Query().Get("since"); since != "" {
 		if t, err := time.Parse(time.RFC3339, since); err == nil {
 			opts.Since = t
 		}
 	}
+	if until := r.URL.Query().Get("until"); until != "" {
+		if t, err := time.Parse(time.RFC3339, until); err == nil {
+			opts.Until = t
+		}
+	}
 	if limit := r.URL.Query().Get("limit"); limit != "" {
 		if n, err := strconv.Atoi(limit); err == nil && n > 0 {
 			opts.Limit = n
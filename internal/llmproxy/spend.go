@@ -0,0 +1,164 @@
+package llmproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+)
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// ModelPrices maps model names or glob patterns (e.g. "claude-sonnet-*")
+// to prices.
+type ModelPrices map[string]ModelPrice
+
+// lookup returns the price of model: an exact entry, or else the longest
+// matching pattern.
+func (p ModelPrices) lookup(model string) (ModelPrice, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+	best, found := "", false
+	for pattern := range p {
+		if ok, _ := path.Match(pattern, model); ok && (!found || len(pattern) > len(best) || len(pattern) == len(best) && pattern < best) {
+			best, found = pattern, true
+		}
+	}
+	return p[best], found
+}
+
+// cost returns the cost of u in USD, or 0 if its model has no price.
+func (p ModelPrices) cost(u TokenUsage) float64 {
+	price, ok := p.lookup(u.Model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.InputTokens)*price.Input +
+		float64(u.OutputTokens)*price.Output +
+		float64(u.CacheCreationInputTokens)*price.CacheWrite +
+		float64(u.CacheReadInputTokens)*price.CacheRead) / 1e6
+}
+
+// SpendLimit is a monthly LLM budget of the token's workspace or user,
+// returned by token validation. Nil limits are unlimited.
+type SpendLimit struct {
+	Scope             string   `json:"scope"` // "workspace" or "user"
+	ID                string   `json:"id"`
+	MaxTokensPerMonth *int64   `json:"max_tokens_per_month,omitempty"`
+	MaxUSDPerMonth    *float64 `json:"max_usd_per_month,omitempty"`
+	// Since is the start of the budget window: the start of the month
+	// (UTC), or the last reset if later.
+	Since time.Time `json:"since"`
+}
+
+// spendLimitError is the body of a request rejected for an exhausted spend
+// limit: an Anthropic API error, which agents surface, with the details of
+// the limit for clients that look for them.
+type spendLimitError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type       string `json:"type"`
+		Message    string `json:"message"`
+		SpendLimit struct {
+			Scope             string   `json:"scope"`
+			ID                string   `json:"id"`
+			UsedTokens        int64    `json:"used_tokens"`
+			UsedUSD           float64  `json:"used_usd"`
+			MaxTokensPerMonth *int64   `json:"max_tokens_per_month,omitempty"`
+			MaxUSDPerMonth    *float64 `json:"max_usd_per_month,omitempty"`
+			ResetsAt          string   `json:"resets_at"`
+		} `json:"spend_limit"`
+	} `json:"error"`
+}
+
+// nextMonth returns the start of the month (UTC) after t.
+func nextMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+}
+
+// applySpendLimits rejects a request with 429 once a spend limit of the
+// token's workspace or user is used up. It returns false if the request was
+// rejected (the error response has been written).
+func (s *Server) applySpendLimits(w http.ResponseWriter, sbx *TokenInfo) bool {
+	if s.store == nil {
+		return true
+	}
+	for _, l := range sbx.SpendLimits {
+		if l.MaxTokensPerMonth == nil && l.MaxUSDPerMonth == nil {
+			continue
+		}
+		tokens, cost, err := s.store.SpendSince(l.Scope, l.ID, l.Since)
+		if err != nil {
+			s.logger.Error("failed to get spend for limit check", "error", err, "scope", l.Scope, "id", l.ID)
+			continue
+		}
+		var msg string
+		switch {
+		case l.MaxTokensPerMonth != nil && tokens >= *l.MaxTokensPerMonth:
+			msg = fmt.Sprintf("%s monthly token budget exhausted (%d/%d tokens)", l.Scope, tokens, *l.MaxTokensPerMonth)
+		case l.MaxUSDPerMonth != nil && cost >= *l.MaxUSDPerMonth:
+			msg = fmt.Sprintf("%s monthly spend budget exhausted ($%.2f/$%.2f)", l.Scope, cost, *l.MaxUSDPerMonth)
+		default:
+			continue
+		}
+		s.logger.Info("spend limit exhausted", "scope", l.Scope, "id", l.ID, "tokens", tokens, "cost_usd", cost)
+
+		resetsAt := nextMonth(s.clock.Now())
+		var body spendLimitError
+		body.Type = "error"
+		body.Error.Type = "rate_limit_error"
+		body.Error.Message = msg + "; it resets at the start of next month (UTC), or an administrator can reset it now"
+		sl := &body.Error.SpendLimit
+		sl.Scope, sl.ID, sl.UsedTokens, sl.UsedUSD = l.Scope, l.ID, tokens, cost
+		sl.MaxTokensPerMonth, sl.MaxUSDPerMonth = l.MaxTokensPerMonth, l.MaxUSDPerMonth
+		sl.ResetsAt = resetsAt.Format(time.RFC3339)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(resetsAt.Sub(s.clock.Now()).Seconds())))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(body)
+		return false
+	}
+	return true
+}
+
+// handleQuerySpend returns the tokens and cost of a workspace or user since
+// a time: GET /internal/spend?scope=workspace|user&id=&since=.
+func (s *Server) handleQuerySpend(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	scope, id := q.Get("scope"), q.Get("id")
+	if _, ok := spendScopeColumns[scope]; !ok || id == "" {
+		http.Error(w, "scope must be workspace or user, with an id", http.StatusBadRequest)
+		return
+	}
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	tokens, cost, err := s.store.SpendSince(scope, id, since)
+	if err != nil {
+		s.logger.Error("query spend failed", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tokens":   tokens,
+		"cost_usd": cost,
+	})
+}
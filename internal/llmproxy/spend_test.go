@@ -0,0 +1,43 @@
+package llmproxy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestModelPricesCost(t *testing.T) {
+	prices := ModelPrices{
+		"claude-*":          {Input: 1, Output: 1},
+		"claude-sonnet-*":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+		"claude-sonnet-4-5": {Input: 2, Output: 10},
+	}
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		// exact entry wins over patterns
+		{"claude-sonnet-4-5", 2 + 10},
+		// longest matching pattern
+		{"claude-sonnet-4", 3 + 15 + 3.75 + 0.3},
+		{"claude-haiku-4-5", 1 + 1 + 0 + 0},
+		{"gpt-4o", 0},
+	}
+	for _, tt := range tests {
+		u := TokenUsage{Model: tt.model, InputTokens: 1e6, OutputTokens: 1e6,
+			CacheCreationInputTokens: 1e6, CacheReadInputTokens: 1e6}
+		if got := prices.cost(u); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("cost(%s) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+	if got := ModelPrices(nil).cost(TokenUsage{Model: "claude-sonnet-4-5", InputTokens: 100}); got != 0 {
+		t.Errorf("cost without prices = %v, want 0", got)
+	}
+}
+
+func TestNextMonth(t *testing.T) {
+	got := nextMonth(time.Date(2026, 12, 31, 23, 0, 0, 0, time.FixedZone("", -5*3600)))
+	if want := time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("nextMonth = %v, want %v", got, want)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // GetOrCreateTrace returns an existing trace or creates a new one.
@@ -37,12 +38,12 @@ func (s *Store) RecordUsage(u TokenUsage) error {
 	_, err := s.db.Exec(
 		`INSERT INTO usage (id, trace_id, sandbox_id, workspace_id, provider, model, message_id,
 			input_tokens, output_tokens, cache_creation_input_tokens, cache_read_input_tokens,
-			streaming, duration, ttft, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+			streaming, duration, ttft, created_at, user_id, cost_usd)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
 		u.ID, nullIfEmpty(u.TraceID), nullIfEmpty(u.SandboxID), u.WorkspaceID, u.Provider, u.Model,
 		nullIfEmpty(u.MessageID), u.InputTokens, u.OutputTokens,
 		u.CacheCreationInputTokens, u.CacheReadInputTokens,
-		u.Streaming, u.Duration, u.TTFT, u.CreatedAt,
+		u.Streaming, u.Duration, u.TTFT, u.CreatedAt, nullIfEmpty(u.UserID), u.CostUSD,
 	)
 	if err != nil {
 		return fmt.Errorf("record usage: %w", err)
@@ -50,8 +51,21 @@ func (s *Store) RecordUsage(u TokenUsage) error {
 	return nil
 }
 
-// QueryUsage returns aggregated usage grouped by provider and model.
+// usageGroupColumns maps QueryOpts.GroupBy to the usage column it groups by.
+var usageGroupColumns = map[string]string{
+	"workspace": "workspace_id",
+	"sandbox":   "sandbox_id",
+	"user":      "user_id",
+}
+
+// QueryUsage returns aggregated usage grouped by provider and model, and by
+// opts.GroupBy if set.
 func (s *Store) QueryUsage(opts QueryOpts) ([]UsageSummary, error) {
+	groupCol, ok := usageGroupColumns[opts.GroupBy]
+	if opts.GroupBy != "" && !ok {
+		return nil, fmt.Errorf("query usage: invalid group_by %q", opts.GroupBy)
+	}
+
 	var conditions []string
 	var args []interface{}
 	argN := 1
@@ -66,27 +80,45 @@ func (s *Store) QueryUsage(opts QueryOpts) ([]UsageSummary, error) {
 		args = append(args, opts.SandboxID)
 		argN++
 	}
+	if opts.UserID != "" {
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", argN))
+		args = append(args, opts.UserID)
+		argN++
+	}
 	if !opts.Since.IsZero() {
 		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argN))
 		args = append(args, opts.Since)
 		argN++
 	}
+	if !opts.Until.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", argN))
+		args = append(args, opts.Until)
+		argN++
+	}
 
 	where := ""
 	if len(conditions) > 0 {
 		where = "WHERE " + strings.Join(conditions, " AND ")
 	}
 
+	// Without a group, select a constant so rows scan the same way.
+	group := "''"
+	groupBy := "provider, model"
+	if groupCol != "" {
+		group = "COALESCE(" + groupCol + ", '')"
+		groupBy = group + ", provider, model"
+	}
 	query := fmt.Sprintf(`
-		SELECT provider, model,
+		SELECT %s, provider, model,
 			COALESCE(SUM(input_tokens), 0),
 			COALESCE(SUM(output_tokens), 0),
 			COALESCE(SUM(cache_creation_input_tokens), 0),
 			COALESCE(SUM(cache_read_input_tokens), 0),
+			COALESCE(SUM(cost_usd), 0),
 			COUNT(*)
 		FROM usage %s
-		GROUP BY provider, model
-		ORDER BY provider, model`, where)
+		GROUP BY %s
+		ORDER BY %s`, group, where, groupBy, groupBy)
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
@@ -97,15 +129,34 @@ func (s *Store) QueryUsage(opts QueryOpts) ([]UsageSummary, error) {
 	var results []UsageSummary
 	for rows.Next() {
 		var u UsageSummary
-		if err := rows.Scan(&u.Provider, &u.Model, &u.InputTokens, &u.OutputTokens,
-			&u.CacheCreationInputTokens, &u.CacheReadInputTokens, &u.RequestCount); err != nil {
+		var key string
+		if err := rows.Scan(&key, &u.Provider, &u.Model, &u.InputTokens, &u.OutputTokens,
+			&u.CacheCreationInputTokens, &u.CacheReadInputTokens, &u.CostUSD, &u.RequestCount); err != nil {
 			return nil, fmt.Errorf("scan usage: %w", err)
 		}
+		switch opts.GroupBy {
+		case "workspace":
+			u.WorkspaceID = key
+		case "sandbox":
+			u.SandboxID = key
+		case "user":
+			u.UserID = key
+		}
 		results = append(results, u)
 	}
 	return results, rows.Err()
 }
 
+// PseudonymizeUsageUser replaces userID in usage records by pseudonym, for
+// erasing a user's personal data. It returns the number of records changed.
+func (s *Store) PseudonymizeUsageUser(userID, pseudonym string) (int64, error) {
+	res, err := s.db.Exec(`UPDATE usage SET user_id = $2 WHERE user_id = $1`, userID, pseudonym)
+	if err != nil {
+		return 0, fmt.Errorf("pseudonymize usage user: %w", err)
+	}
+	return res.RowsAffected()
+}
+
 // QueryTraces returns traces with aggregated statistics and total count.
 func (s *Store) QueryTraces(opts QueryOpts) ([]TraceWithStats, int64, error) {
 	var conditions []string
@@ -127,6 +178,11 @@ func (s *Store) QueryTraces(opts QueryOpts) ([]TraceWithStats, int64, error) {
 		args = append(args, opts.Since)
 		argN++
 	}
+	if !opts.Until.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("t.created_at < $%d", argN))
+		args = append(args, opts.Until)
+		argN++
+	}
 
 	where := ""
 	if len(conditions) > 0 {
@@ -287,3 +343,104 @@ func (s *Store) CountTodayRequests(workspaceID string) (int64, error) {
 	}
 	return count, nil
 }
+
+// GetSandboxBudget returns the sandbox budget of a workspace, or nil if none exists.
+func (s *Store) GetSandboxBudget(workspaceID string) (*SandboxBudget, error) {
+	b := &SandboxBudget{}
+	var resetAt sql.NullTime
+	err := s.db.QueryRow(
+		`SELECT workspace_id, max_tokens_per_day, max_requests_per_day, throttle_percent, max_delay_ms, reset_at, updated_at
+		 FROM sandbox_budgets WHERE workspace_id = $1`,
+		workspaceID,
+	).Scan(&b.WorkspaceID, &b.MaxTokensPerDay, &b.MaxRequestsPerDay, &b.ThrottlePercent, &b.MaxDelayMs, &resetAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sandbox budget: %w", err)
+	}
+	if resetAt.Valid {
+		b.ResetAt = &resetAt.Time
+	}
+	return b, nil
+}
+
+// SetSandboxBudget upserts the sandbox budget of a workspace, keeping its reset time.
+func (s *Store) SetSandboxBudget(b SandboxBudget) error {
+	_, err := s.db.Exec(
+		`INSERT INTO sandbox_budgets (workspace_id, max_tokens_per_day, max_requests_per_day, throttle_percent, max_delay_ms, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW())
+		 ON CONFLICT (workspace_id) DO UPDATE SET
+		   max_tokens_per_day = EXCLUDED.max_tokens_per_day,
+		   max_requests_per_day = EXCLUDED.max_requests_per_day,
+		   throttle_percent = EXCLUDED.throttle_percent,
+		   max_delay_ms = EXCLUDED.max_delay_ms,
+		   updated_at = NOW()`,
+		b.WorkspaceID, b.MaxTokensPerDay, b.MaxRequestsPerDay, b.ThrottlePercent, b.MaxDelayMs,
+	)
+	if err != nil {
+		return fmt.Errorf("set sandbox budget: %w", err)
+	}
+	return nil
+}
+
+// DeleteSandboxBudget removes the sandbox budget of a workspace.
+func (s *Store) DeleteSandboxBudget(workspaceID string) error {
+	_, err := s.db.Exec(`DELETE FROM sandbox_budgets WHERE workspace_id = $1`, workspaceID)
+	if err != nil {
+		return fmt.Errorf("delete sandbox budget: %w", err)
+	}
+	return nil
+}
+
+// ResetSandboxBudget restarts usage counting for the workspace's sandboxes
+// now. It reports false if the workspace has no budget.
+func (s *Store) ResetSandboxBudget(workspaceID string) (bool, error) {
+	res, err := s.db.Exec(
+		`UPDATE sandbox_budgets SET reset_at = NOW(), updated_at = NOW() WHERE workspace_id = $1`,
+		workspaceID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("reset sandbox budget: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// SandboxUsageSince returns the number of requests and tokens (input plus
+// output) a sandbox used since the given time.
+func (s *Store) SandboxUsageSince(sandboxID string, since time.Time) (requests, tokens int64, err error) {
+	err = s.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(input_tokens + output_tokens), 0)
+		 FROM usage WHERE sandbox_id = $1 AND created_at >= $2`,
+		sandboxID, since,
+	).Scan(&requests, &tokens)
+	if err != nil {
+		return 0, 0, fmt.Errorf("sandbox usage since: %w", err)
+	}
+	return requests, tokens, nil
+}
+
+// spendScopeColumns maps spend limit scopes to the usage column they apply to.
+var spendScopeColumns = map[string]string{
+	"workspace": "workspace_id",
+	"user":      "user_id",
+}
+
+// SpendSince returns the tokens (input and output) and cost of the usage of
+// a workspace or user (scope) since a time.
+func (s *Store) SpendSince(scope, id string, since time.Time) (tokens int64, cost float64, err error) {
+	col, ok := spendScopeColumns[scope]
+	if !ok {
+		return 0, 0, fmt.Errorf("spend since: invalid scope %q", scope)
+	}
+	err = s.db.QueryRow(
+		`SELECT COALESCE(SUM(input_tokens + output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		 FROM usage WHERE `+col+` = $1 AND created_at >= $2`,
+		id, since,
+	).Scan(&tokens, &cost)
+	if err != nil {
+		return 0, 0, fmt.Errorf("spend since: %w", err)
+	}
+	return tokens, cost, nil
+}
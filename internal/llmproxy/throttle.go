@@ -0,0 +1,93 @@
+package llmproxy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// budgetWindowStart is when the current budget window began: the start of
+// the day (UTC), or the last owner reset if later.
+func budgetWindowStart(b *SandboxBudget, now time.Time) time.Time {
+	start := now.UTC().Truncate(24 * time.Hour)
+	if b.ResetAt != nil && b.ResetAt.After(start) {
+		start = *b.ResetAt
+	}
+	return start
+}
+
+// budgetDecision decides how to treat a sandbox's next request given its
+// usage in the current window: no delay below ThrottlePercent of a budget,
+// a delay growing linearly to MaxDelayMs as usage approaches the budget, and
+// a rejection (with a message for the agent) once it is used up.
+func budgetDecision(b *SandboxBudget, requests, tokens int64) (time.Duration, string) {
+	var used float64
+	var blocked string
+	if b.MaxRequestsPerDay != nil && *b.MaxRequestsPerDay > 0 {
+		max := int64(*b.MaxRequestsPerDay)
+		used = float64(requests) / float64(max)
+		if requests >= max {
+			blocked = fmt.Sprintf("sandbox request budget exhausted (%d/%d requests today)", requests, max)
+		}
+	}
+	if b.MaxTokensPerDay != nil && *b.MaxTokensPerDay > 0 {
+		max := *b.MaxTokensPerDay
+		if f := float64(tokens) / float64(max); f > used {
+			used = f
+		}
+		if blocked == "" && tokens >= max {
+			blocked = fmt.Sprintf("sandbox token budget exhausted (%d/%d tokens today)", tokens, max)
+		}
+	}
+	if blocked != "" {
+		return 0, blocked + "; it resets at midnight UTC, or a workspace owner can reset it now"
+	}
+
+	threshold := float64(b.ThrottlePercent) / 100
+	if b.MaxDelayMs <= 0 || threshold >= 1 || used < threshold {
+		return 0, ""
+	}
+	frac := (used - threshold) / (1 - threshold)
+	return time.Duration(math.Round(frac*float64(b.MaxDelayMs))) * time.Millisecond, ""
+}
+
+// applySandboxBudget throttles or rejects a request of a sandbox token that
+// is near or over its workspace's sandbox budget. It returns false if the
+// request was rejected (the error response has been written).
+func (s *Server) applySandboxBudget(ctx context.Context, w http.ResponseWriter, sbx *TokenInfo) bool {
+	if s.store == nil || sbx.TokenType != "sandbox" || sbx.SandboxID == "" {
+		return true
+	}
+	b, err := s.store.GetSandboxBudget(sbx.WorkspaceID)
+	if err != nil {
+		s.logger.Error("failed to get sandbox budget", "error", err, "workspace_id", sbx.WorkspaceID)
+		return true
+	}
+	if b == nil {
+		return true
+	}
+	requests, tokens, err := s.store.SandboxUsageSince(sbx.SandboxID, budgetWindowStart(b, s.clock.Now()))
+	if err != nil {
+		s.logger.Error("failed to get sandbox usage for budget check", "error", err, "sandbox_id", sbx.SandboxID)
+		return true
+	}
+	delay, blocked := budgetDecision(b, requests, tokens)
+	if blocked != "" {
+		s.logger.Info("sandbox over budget", "sandbox_id", sbx.SandboxID, "requests", requests, "tokens", tokens)
+		writeAnthropicError(w, http.StatusTooManyRequests, "rate_limit_error", blocked)
+		return false
+	}
+	if delay > 0 {
+		w.Header().Set("X-Agentserver-Throttle-Delay", fmt.Sprintf("%dms", delay.Milliseconds()))
+		t := s.clock.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-t.C():
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package llmproxy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetDecision(t *testing.T) {
+	maxTokens := int64(1000)
+	maxRequests := 100
+	b := &SandboxBudget{MaxTokensPerDay: &maxTokens, MaxRequestsPerDay: &maxRequests, ThrottlePercent: 80, MaxDelayMs: 5000}
+
+	tests := []struct {
+		requests, tokens int64
+		delay            time.Duration
+		blocked          string
+	}{
+		{10, 100, 0, ""},
+		{80, 100, 0, ""}, // at the threshold
+		{90, 100, 2500 * time.Millisecond, ""},
+		{10, 950, 3750 * time.Millisecond, ""}, // tokens dominate
+		{100, 100, 0, "request budget"},
+		{10, 1200, 0, "token budget"},
+	}
+	for _, tt := range tests {
+		delay, blocked := budgetDecision(b, tt.requests, tt.tokens)
+		if delay != tt.delay || (tt.blocked == "") != (blocked == "") || !strings.Contains(blocked, tt.blocked) {
+			t.Errorf("(%d req, %d tok): delay %v blocked %q, want %v %q", tt.requests, tt.tokens, delay, blocked, tt.delay, tt.blocked)
+		}
+	}
+
+	if delay, blocked := budgetDecision(&SandboxBudget{ThrottlePercent: 80, MaxDelayMs: 5000}, 1e6, 1e9); delay != 0 || blocked != "" {
+		t.Errorf("unlimited budget: %v %q", delay, blocked)
+	}
+}
+
+func TestBudgetWindowStart(t *testing.T) {
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)
+	b := &SandboxBudget{}
+	if got := budgetWindowStart(b, now); !got.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("window start = %v", got)
+	}
+	reset := now.Add(-time.Hour)
+	b.ResetAt = &reset
+	if got := budgetWindowStart(b, now); !got.Equal(reset) {
+		t.Errorf("window start after reset = %v", got)
+	}
+	old := now.Add(-48 * time.Hour)
+	b.ResetAt = &old
+	if got := budgetWindowStart(b, now); !got.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("window start with stale reset = %v", got)
+	}
+}
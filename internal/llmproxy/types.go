@@ -17,6 +17,18 @@ type TokenInfo struct {
 	WorkspaceID            string `json:"workspace_id"`
 	Status                 string `json:"status"`
 	ModelserverUpstreamURL string `json:"modelserver_upstream_url,omitempty"`
+	// Scope restricts sandbox tokens; nil means unrestricted.
+	Scope *ProxyScope `json:"scope,omitempty"`
+	// ModelPolicy is the workspace's model allowlist; nil means none.
+	ModelPolicy *ModelPolicy `json:"model_policy,omitempty"`
+	// Providers are the LLM providers a sandbox token may use; empty
+	// allows all.
+	Providers []string `json:"providers,omitempty"`
+	// UserID is the user a sandbox token's usage is attributed to (the
+	// sandbox's creator), if known.
+	UserID string `json:"user_id,omitempty"`
+	// SpendLimits are the monthly budgets of the workspace and user.
+	SpendLimits []SpendLimit `json:"spend_limits,omitempty"`
 }
 
 // Trace represents a logical session/trace spanning multiple API requests.
@@ -35,6 +47,7 @@ type TokenUsage struct {
 	TraceID                  string    `json:"trace_id,omitempty"`
 	SandboxID                string    `json:"sandbox_id"`
 	WorkspaceID              string    `json:"workspace_id"`
+	UserID                   string    `json:"user_id,omitempty"`
 	Provider                 string    `json:"provider"`
 	Model                    string    `json:"model"`
 	MessageID                string    `json:"message_id,omitempty"`
@@ -45,18 +58,24 @@ type TokenUsage struct {
 	Streaming                bool      `json:"streaming"`
 	Duration                 int64     `json:"duration"`
 	TTFT                     int64     `json:"ttft"`
+	CostUSD                  float64   `json:"cost_usd"`
 	CreatedAt                time.Time `json:"created_at"`
 }
 
-// UsageSummary is an aggregated usage row grouped by provider+model.
+// UsageSummary is an aggregated usage row grouped by provider+model, and
+// by workspace, sandbox or user if requested (QueryOpts.GroupBy).
 type UsageSummary struct {
-	Provider                 string `json:"provider"`
-	Model                    string `json:"model"`
-	InputTokens              int64  `json:"input_tokens"`
-	OutputTokens             int64  `json:"output_tokens"`
-	CacheCreationInputTokens int64  `json:"cache_creation_input_tokens"`
-	CacheReadInputTokens     int64  `json:"cache_read_input_tokens"`
-	RequestCount             int64  `json:"request_count"`
+	WorkspaceID              string  `json:"workspace_id,omitempty"`
+	SandboxID                string  `json:"sandbox_id,omitempty"`
+	UserID                   string  `json:"user_id,omitempty"`
+	Provider                 string  `json:"provider"`
+	Model                    string  `json:"model"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	CostUSD                  float64 `json:"cost_usd"`
+	RequestCount             int64   `json:"request_count"`
 }
 
 // TraceWithStats is a trace with aggregated request statistics.
@@ -74,9 +93,13 @@ type TraceWithStats struct {
 type QueryOpts struct {
 	WorkspaceID string
 	SandboxID   string
+	UserID      string
 	Since       time.Time
+	Until       time.Time // exclusive
 	Limit       int
 	Offset      int
+	// GroupBy additionally groups usage by "workspace", "sandbox" or "user".
+	GroupBy string
 }
 
 // WorkspaceQuota holds per-workspace quota overrides stored in the llmproxy DB.
@@ -85,3 +108,15 @@ type WorkspaceQuota struct {
 	MaxRPD      *int      `json:"max_rpd"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// SandboxBudget is a workspace's daily per-sandbox usage budget. Nil limits
+// are unlimited.
+type SandboxBudget struct {
+	WorkspaceID       string     `json:"workspace_id"`
+	MaxTokensPerDay   *int64     `json:"max_tokens_per_day"`
+	MaxRequestsPerDay *int       `json:"max_requests_per_day"`
+	ThrottlePercent   int        `json:"throttle_percent"`
+	MaxDelayMs        int        `json:"max_delay_ms"`
+	ResetAt           *time.Time `json:"reset_at,omitempty"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,297 @@
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DNS filter resources deployed into a workspace namespace. The resolver pod
+// is deliberately NOT labelled managed-by=agentserver so the sandbox egress
+// NetworkPolicy does not apply to it — it needs to reach the cluster DNS.
+const (
+	dnsFilterName         = "agentserver-dns-filter"
+	dnsFilterAppLabel     = "app"
+	defaultDNSFilterImage = "coredns/coredns:1.11.3"
+
+	// dnsFilterModeAnnotation marks the resolver Service of a removed
+	// filter, which forwards every name; see RemoveDNSFilter.
+	dnsFilterModeAnnotation = "agentserver.io/dns-filter-mode"
+	dnsFilterPassthrough    = "passthrough"
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+
+// NormalizeDomains lowercases, de-duplicates and validates a list of DNS
+// names for the allowlist. A leading "*." is accepted and stripped, since
+// every allowed domain already matches its subdomains.
+func NormalizeDomains(domains []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(d, "*.")
+		d = strings.TrimSuffix(d, ".")
+		if d == "" {
+			continue
+		}
+		if !domainPattern.MatchString(d) {
+			return nil, fmt.Errorf("invalid domain %q", d)
+		}
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// buildCorefile renders a CoreDNS config that forwards the allowed domains
+// (plus the cluster domain, so in-cluster services such as the LLM proxy keep
+// resolving) to the pod's upstream resolver and answers NXDOMAIN for
+// everything else.
+func buildCorefile(domains []string) string {
+	zones := append([]string{"cluster.local"}, domains...)
+	var b strings.Builder
+	b.WriteString(strings.Join(zones, " ") + " {\n")
+	b.WriteString("    forward . /etc/resolv.conf\n")
+	b.WriteString("    cache 30\n")
+	b.WriteString("    errors\n")
+	b.WriteString("}\n")
+	b.WriteString(". {\n")
+	b.WriteString("    health :8080\n")
+	b.WriteString("    ready :8181\n")
+	b.WriteString("    reload\n")
+	b.WriteString("    template ANY ANY {\n")
+	b.WriteString("        rcode NXDOMAIN\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// passthroughCorefile is the CoreDNS config of a removed filter: every name
+// is forwarded to the pod's upstream resolver.
+const passthroughCorefile = `. {
+    forward . /etc/resolv.conf
+    cache 30
+    errors
+    health :8080
+    ready :8181
+    reload
+}
+`
+
+// ApplyDNSFilter deploys (or updates) the filtering resolver in the given
+// namespace so that only the listed domains resolve. An empty list removes
+// the filter. The egress NetworkPolicy is re-applied so filtered sandboxes
+// can't query kube-system DNS directly while the filter is active.
+//
+// Sandboxes pick up the resolver at pod creation time via DNSFilterAddress;
+// existing sandboxes keep their resolver until recreated, and those using
+// cluster DNS may still reach it (see buildClusterDNSPolicy).
+func (m *Manager) ApplyDNSFilter(ctx context.Context, namespace string, domains []string) error {
+	if len(domains) == 0 {
+		return m.RemoveDNSFilter(ctx, namespace)
+	}
+
+	if err := m.applyDNSFilterConfig(ctx, namespace, buildCorefile(domains)); err != nil {
+		return err
+	}
+
+	deploy := m.buildDNSFilterDeployment(namespace)
+	existingDeploy, err := m.clientset.AppsV1().Deployments(namespace).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := m.clientset.AppsV1().Deployments(namespace).Create(ctx, deploy, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create dns filter deployment in %s: %w", namespace, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("get dns filter deployment in %s: %w", namespace, err)
+	} else {
+		existingDeploy.Spec = deploy.Spec
+		if _, err := m.clientset.AppsV1().Deployments(namespace).Update(ctx, existingDeploy, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update dns filter deployment in %s: %w", namespace, err)
+		}
+	}
+
+	svc, err := m.clientset.CoreV1().Services(namespace).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := m.clientset.CoreV1().Services(namespace).Create(ctx, buildDNSFilterService(namespace), metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create dns filter service in %s: %w", namespace, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("get dns filter service in %s: %w", namespace, err)
+	} else if svc.Annotations[dnsFilterModeAnnotation] != "" {
+		delete(svc.Annotations, dnsFilterModeAnnotation)
+		if _, err := m.clientset.CoreV1().Services(namespace).Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update dns filter service in %s: %w", namespace, err)
+		}
+	}
+
+	if m.NetworkPolicy().Enabled {
+		if err := m.ApplyNetworkPolicy(ctx, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyDNSFilterConfig creates or updates the resolver's Corefile.
+func (m *Manager) applyDNSFilterConfig(ctx context.Context, namespace, corefile string) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dnsFilterName,
+			Namespace: namespace,
+			Labels:    map[string]string{"managed-by": "agentserver"},
+		},
+		Data: map[string]string{"Corefile": corefile},
+	}
+	existingCM, err := m.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := m.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("create dns filter config in %s: %w", namespace, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get dns filter config in %s: %w", namespace, err)
+	}
+	existingCM.Data = cm.Data
+	if _, err := m.clientset.CoreV1().ConfigMaps(namespace).Update(ctx, existingCM, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update dns filter config in %s: %w", namespace, err)
+	}
+	return nil
+}
+
+// RemoveDNSFilter stops filtering names in the namespace and restores
+// direct access to cluster DNS for new sandboxes. Sandboxes created while
+// the filter was active still resolve through it, so the resolver is kept,
+// forwarding every name, until the namespace is deleted; enabling the
+// filter again reuses it. Idempotent.
+func (m *Manager) RemoveDNSFilter(ctx context.Context, namespace string) error {
+	svc, err := m.clientset.CoreV1().Services(namespace).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("get dns filter service in %s: %w", namespace, err)
+	}
+	if err == nil && svc.Annotations[dnsFilterModeAnnotation] != dnsFilterPassthrough {
+		if err := m.applyDNSFilterConfig(ctx, namespace, passthroughCorefile); err != nil {
+			return err
+		}
+		if svc.Annotations == nil {
+			svc.Annotations = make(map[string]string)
+		}
+		svc.Annotations[dnsFilterModeAnnotation] = dnsFilterPassthrough
+		if _, err := m.clientset.CoreV1().Services(namespace).Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update dns filter service in %s: %w", namespace, err)
+		}
+	}
+	if m.NetworkPolicy().Enabled {
+		if err := m.ApplyNetworkPolicy(ctx, namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DNSFilterAddress returns the ClusterIP of the namespace's filtering
+// resolver, or "" if DNS filtering is not active in the namespace.
+func (m *Manager) DNSFilterAddress(ctx context.Context, namespace string) (string, error) {
+	svc, err := m.clientset.CoreV1().Services(namespace).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get dns filter service in %s: %w", namespace, err)
+	}
+	if svc.Annotations[dnsFilterModeAnnotation] == dnsFilterPassthrough {
+		return "", nil
+	}
+	return svc.Spec.ClusterIP, nil
+}
+
+func (m *Manager) buildDNSFilterDeployment(namespace string) *appsv1.Deployment {
+	replicas := int32(1)
+	labels := map[string]string{dnsFilterAppLabel: dnsFilterName}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dnsFilterName,
+			Namespace: namespace,
+			Labels:    map[string]string{"managed-by": "agentserver"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "coredns",
+						Image: m.config.NetworkPolicy.DNSFilterImage,
+						Args:  []string{"-conf", "/etc/coredns/Corefile"},
+						Ports: []corev1.ContainerPort{
+							{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+							{Name: "dns-tcp", ContainerPort: 53, Protocol: corev1.ProtocolTCP},
+						},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      "config",
+							MountPath: "/etc/coredns",
+							ReadOnly:  true,
+						}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path: "/ready",
+									Port: intstr.FromInt32(8181),
+								},
+							},
+							PeriodSeconds: 5,
+						},
+						LivenessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path: "/health",
+									Port: intstr.FromInt32(8080),
+								},
+							},
+							PeriodSeconds: 10,
+						},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "config",
+						VolumeSource: corev1.VolumeSource{
+							ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: dnsFilterName},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func buildDNSFilterService(namespace string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dnsFilterName,
+			Namespace: namespace,
+			Labels:    map[string]string{"managed-by": "agentserver"},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{dnsFilterAppLabel: dnsFilterName},
+			Ports: []corev1.ServicePort{
+				{Name: "dns", Port: 53, TargetPort: intstr.FromInt32(53), Protocol: corev1.ProtocolUDP},
+				{Name: "dns-tcp", Port: 53, TargetPort: intstr.FromInt32(53), Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+}
@@ -0,0 +1,125 @@
+package namespace
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNormalizeDomains(t *testing.T) {
+	got, err := NormalizeDomains([]string{" GitHub.com ", "*.npmjs.org", "api.anthropic.com.", "", "github.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"api.anthropic.com", "github.com", "npmjs.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNormalizeDomains_Invalid(t *testing.T) {
+	for _, d := range []string{"localhost", "10.0.0.1", "exa mple.com", "-bad.com", "foo..com"} {
+		if _, err := NormalizeDomains([]string{d}); err == nil {
+			t.Errorf("expected error for %q", d)
+		}
+	}
+}
+
+func TestBuildCorefile(t *testing.T) {
+	cf := buildCorefile([]string{"github.com", "registry.npmjs.org"})
+	if !strings.HasPrefix(cf, "cluster.local github.com registry.npmjs.org {") {
+		t.Errorf("allowed zones missing, got:\n%s", cf)
+	}
+	if !strings.Contains(cf, "rcode NXDOMAIN") {
+		t.Errorf("default deny missing, got:\n%s", cf)
+	}
+}
+
+func TestBuildNetworkPolicy_DNSFiltered(t *testing.T) {
+	m := NewManager(nil, Config{NetworkPolicy: NetworkPolicyConfig{Enabled: true}})
+
+	open := m.buildNetworkPolicy("agent-ws-abc", false)
+	filtered := m.buildNetworkPolicy("agent-ws-abc", true)
+	if len(filtered.Spec.Egress) != len(open.Spec.Egress)-1 {
+		t.Fatalf("expected filtered policy to drop one rule, got %d vs %d", len(filtered.Spec.Egress), len(open.Spec.Egress))
+	}
+	for _, rule := range filtered.Spec.Egress {
+		for _, peer := range rule.To {
+			if peer.NamespaceSelector != nil && peer.NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"] == "kube-system" {
+				t.Error("filtered policy must not allow kube-system DNS")
+			}
+		}
+	}
+}
+
+// TestDNSFilterKeepsExistingSandboxesResolving checks that enabling the
+// filter leaves cluster DNS reachable for sandboxes without the filtered
+// label, and that removing it keeps the resolver up for the sandboxes that
+// use it, forwarding every name.
+func TestDNSFilterKeepsExistingSandboxesResolving(t *testing.T) {
+	const ns = "agent-ws-abc"
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	m := NewManager(client, Config{NetworkPolicy: NetworkPolicyConfig{Enabled: true}})
+
+	if err := m.ApplyDNSFilter(ctx, ns, []string{"github.com"}); err != nil {
+		t.Fatal(err)
+	}
+	// The fake clientset doesn't allocate cluster IPs.
+	svc, err := client.CoreV1().Services(ns).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Spec.ClusterIP = "10.96.0.53"
+	if _, err := client.CoreV1().Services(ns).Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ApplyNetworkPolicy(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := client.NetworkingV1().NetworkPolicies(ns).Get(ctx, clusterDNSPolicyName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cluster DNS policy for unfiltered sandboxes: %v", err)
+	}
+	excluded := map[string]bool{}
+	for _, req := range policy.Spec.PodSelector.MatchExpressions {
+		if req.Operator == metav1.LabelSelectorOpDoesNotExist {
+			excluded[req.Key] = true
+		}
+	}
+	if !excluded[LabelDNSFiltered] || !excluded[LabelQuarantined] {
+		t.Errorf("cluster DNS policy selector = %+v", policy.Spec.PodSelector)
+	}
+
+	if err := m.RemoveDNSFilter(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	if addr, err := m.DNSFilterAddress(ctx, ns); err != nil || addr != "" {
+		t.Errorf("DNSFilterAddress after removal = %q, %v; want none", addr, err)
+	}
+	if _, err := client.AppsV1().Deployments(ns).Get(ctx, dnsFilterName, metav1.GetOptions{}); err != nil {
+		t.Errorf("resolver deployment removed: %v", err)
+	}
+	cm, err := client.CoreV1().ConfigMaps(ns).Get(ctx, dnsFilterName, metav1.GetOptions{})
+	if err != nil || cm.Data["Corefile"] != passthroughCorefile {
+		t.Errorf("resolver config after removal = %v, %v", cm, err)
+	}
+	if _, err := client.NetworkingV1().NetworkPolicies(ns).Get(ctx, clusterDNSPolicyName, metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("cluster DNS policy after removal: %v, want not found", err)
+	}
+	if err := m.RemoveDNSFilter(ctx, ns); err != nil {
+		t.Errorf("second removal: %v", err)
+	}
+
+	if err := m.ApplyDNSFilter(ctx, ns, []string{"github.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if addr, err := m.DNSFilterAddress(ctx, ns); err != nil || addr != "10.96.0.53" {
+		t.Errorf("DNSFilterAddress after re-enabling = %q, %v", addr, err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"strings"
+	"sync"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -14,6 +16,22 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	sandboxEgressPolicyName = "agentserver-sandbox-egress"
+	clusterDNSPolicyName    = "agentserver-sandbox-cluster-dns"
+)
+
+// LabelQuarantined is set on sandbox pods that an admin has quarantined. The
+// sandbox egress policy excludes such pods so only the quarantine deny-all
+// policy applies to them.
+const LabelQuarantined = "agentserver.io/quarantined"
+
+// LabelDNSFiltered is set on sandbox pods that resolve names through the
+// workspace DNS filter. While the filter is active, pods without it (created
+// before the filter was enabled) may still query cluster DNS until they are
+// recreated.
+const LabelDNSFiltered = "agentserver.io/dns-filtered"
+
 // Config holds configuration for the namespace manager.
 type Config struct {
 	Prefix        string
@@ -25,12 +43,17 @@ type NetworkPolicyConfig struct {
 	Enabled            bool
 	DenyCIDRs          []string
 	AgentserverNamespace string // Allow egress to agentserver namespace (for Anthropic API proxy).
+	DNSFilterImage     string // CoreDNS image for per-workspace DNS allowlisting.
 }
 
 // Manager handles per-workspace K8s namespace lifecycle.
 type Manager struct {
 	clientset kubernetes.Interface
 	config    Config
+
+	// npMu guards config.NetworkPolicy.Enabled and DenyCIDRs, which can be
+	// changed at runtime through the admin settings API.
+	npMu sync.RWMutex
 }
 
 // NewManager creates a new namespace Manager.
@@ -38,6 +61,9 @@ func NewManager(clientset kubernetes.Interface, config Config) *Manager {
 	if config.Prefix == "" {
 		config.Prefix = "agent-ws"
 	}
+	if config.NetworkPolicy.DNSFilterImage == "" {
+		config.NetworkPolicy.DNSFilterImage = defaultDNSFilterImage
+	}
 	return &Manager{
 		clientset: clientset,
 		config:    config,
@@ -73,15 +99,34 @@ func (m *Manager) EnsureNamespace(ctx context.Context, workspaceID string) (stri
 		return "", fmt.Errorf("create namespace %s: %w", nsName, err)
 	}
 
-	if m.config.NetworkPolicy.Enabled {
+	if m.NetworkPolicy().Enabled {
 		if err := m.ApplyNetworkPolicy(ctx, nsName); err != nil {
 			log.Printf("warning: failed to apply network policy to %s: %v", nsName, err)
 		}
+	} else if err := m.deleteNetworkPolicy(ctx, nsName); err != nil {
+		log.Printf("warning: failed to remove network policy from %s: %v", nsName, err)
 	}
 
 	return nsName, nil
 }
 
+// NetworkPolicy returns the current NetworkPolicy settings.
+func (m *Manager) NetworkPolicy() NetworkPolicyConfig {
+	m.npMu.RLock()
+	defer m.npMu.RUnlock()
+	return m.config.NetworkPolicy
+}
+
+// SetNetworkPolicy changes whether the sandbox egress policy is enforced and
+// which CIDRs it denies. Namespaces pick up the change the next time they
+// are ensured.
+func (m *Manager) SetNetworkPolicy(enabled bool, denyCIDRs []string) {
+	m.npMu.Lock()
+	defer m.npMu.Unlock()
+	m.config.NetworkPolicy.Enabled = enabled
+	m.config.NetworkPolicy.DenyCIDRs = denyCIDRs
+}
+
 // DeleteNamespace deletes the namespace. K8s cascades all resources within it.
 func (m *Manager) DeleteNamespace(ctx context.Context, namespace string) error {
 	err := m.clientset.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{})
@@ -92,92 +137,154 @@ func (m *Manager) DeleteNamespace(ctx context.Context, namespace string) error {
 }
 
 // ApplyNetworkPolicy creates or updates the sandbox egress NetworkPolicy in the given namespace.
+// While a DNS filter is active it also applies the policy letting unfiltered
+// sandboxes reach cluster DNS.
 func (m *Manager) ApplyNetworkPolicy(ctx context.Context, namespace string) error {
-	np := m.buildNetworkPolicy(namespace)
+	dnsAddr, err := m.DNSFilterAddress(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if err := m.applyPolicy(ctx, m.buildNetworkPolicy(namespace, dnsAddr != "")); err != nil {
+		return err
+	}
+	if dnsAddr != "" {
+		return m.applyPolicy(ctx, buildClusterDNSPolicy(namespace))
+	}
+	return m.deletePolicy(ctx, namespace, clusterDNSPolicyName)
+}
 
-	_, err := m.clientset.NetworkingV1().NetworkPolicies(namespace).Get(ctx, np.Name, metav1.GetOptions{})
+// applyPolicy creates np or replaces the existing policy of that name.
+func (m *Manager) applyPolicy(ctx context.Context, np *networkingv1.NetworkPolicy) error {
+	policies := m.clientset.NetworkingV1().NetworkPolicies(np.Namespace)
+	_, err := policies.Get(ctx, np.Name, metav1.GetOptions{})
 	if errors.IsNotFound(err) {
-		_, err = m.clientset.NetworkingV1().NetworkPolicies(namespace).Create(ctx, np, metav1.CreateOptions{})
+		_, err = policies.Create(ctx, np, metav1.CreateOptions{})
 		if err != nil {
-			return fmt.Errorf("create network policy in %s: %w", namespace, err)
+			return fmt.Errorf("create network policy %s in %s: %w", np.Name, np.Namespace, err)
 		}
 		return nil
 	}
 	if err != nil {
-		return fmt.Errorf("get network policy in %s: %w", namespace, err)
+		return fmt.Errorf("get network policy %s in %s: %w", np.Name, np.Namespace, err)
 	}
 
-	_, err = m.clientset.NetworkingV1().NetworkPolicies(namespace).Update(ctx, np, metav1.UpdateOptions{})
+	_, err = policies.Update(ctx, np, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("update network policy in %s: %w", namespace, err)
+		return fmt.Errorf("update network policy %s in %s: %w", np.Name, np.Namespace, err)
 	}
 	return nil
 }
 
-func (m *Manager) buildNetworkPolicy(namespace string) *networkingv1.NetworkPolicy {
+// deleteNetworkPolicy removes the sandbox egress policies if present.
+func (m *Manager) deleteNetworkPolicy(ctx context.Context, namespace string) error {
+	if err := m.deletePolicy(ctx, namespace, sandboxEgressPolicyName); err != nil {
+		return err
+	}
+	return m.deletePolicy(ctx, namespace, clusterDNSPolicyName)
+}
+
+func (m *Manager) deletePolicy(ctx context.Context, namespace, name string) error {
+	err := m.clientset.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete network policy %s in %s: %w", name, namespace, err)
+	}
+	return nil
+}
+
+// clusterDNSRule allows DNS queries to kube-system.
+func clusterDNSRule() networkingv1.NetworkPolicyEgressRule {
 	dnsPort53 := intstr.FromInt32(53)
 	protoUDP := corev1.ProtocolUDP
 	protoTCP := corev1.ProtocolTCP
-
-	egress := []networkingv1.NetworkPolicyEgressRule{
-		// 1. Allow DNS to kube-system.
-		{
-			To: []networkingv1.NetworkPolicyPeer{{
-				NamespaceSelector: &metav1.LabelSelector{
-					MatchLabels: map[string]string{
-						"kubernetes.io/metadata.name": "kube-system",
-					},
+	return networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"kubernetes.io/metadata.name": "kube-system",
 				},
-			}},
-			Ports: []networkingv1.NetworkPolicyPort{
-				{Protocol: &protoUDP, Port: &dnsPort53},
-				{Protocol: &protoTCP, Port: &dnsPort53},
 			},
+		}},
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: &protoUDP, Port: &dnsPort53},
+			{Protocol: &protoTCP, Port: &dnsPort53},
 		},
-		// 2. Allow traffic within the same namespace.
-		{
-			To: []networkingv1.NetworkPolicyPeer{{
-				PodSelector: &metav1.LabelSelector{},
-			}},
+	}
+}
+
+// buildClusterDNSPolicy lets sandboxes that don't use the DNS filter keep
+// resolving through cluster DNS while the filter is active. Policies are
+// additive, so it only widens the egress policy for those pods.
+func buildClusterDNSPolicy(namespace string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      clusterDNSPolicyName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"managed-by": "agentserver",
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"managed-by": "agentserver",
+				},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: LabelQuarantined, Operator: metav1.LabelSelectorOpDoesNotExist},
+					{Key: LabelDNSFiltered, Operator: metav1.LabelSelectorOpDoesNotExist},
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      []networkingv1.NetworkPolicyEgressRule{clusterDNSRule()},
 		},
 	}
+}
+
+// buildNetworkPolicy builds the sandbox egress policy. When dnsFiltered is
+// set, sandboxes may only resolve names through the in-namespace DNS filter
+// (reachable via the same-namespace rule), not kube-system DNS; see
+// buildClusterDNSPolicy for the sandboxes created before the filter.
+func (m *Manager) buildNetworkPolicy(namespace string, dnsFiltered bool) *networkingv1.NetworkPolicy {
+	npCfg := m.NetworkPolicy()
+
+	var egress []networkingv1.NetworkPolicyEgressRule
+	// 1. Allow DNS to kube-system.
+	if !dnsFiltered {
+		egress = append(egress, clusterDNSRule())
+	}
+	// 2. Allow traffic within the same namespace.
+	egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			PodSelector: &metav1.LabelSelector{},
+		}},
+	})
 
 	// 3. Allow traffic to agentserver namespace (for Anthropic API proxy).
-	if m.config.NetworkPolicy.AgentserverNamespace != "" {
+	if npCfg.AgentserverNamespace != "" {
 		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
 			To: []networkingv1.NetworkPolicyPeer{{
 				NamespaceSelector: &metav1.LabelSelector{
 					MatchLabels: map[string]string{
-						"kubernetes.io/metadata.name": m.config.NetworkPolicy.AgentserverNamespace,
+						"kubernetes.io/metadata.name": npCfg.AgentserverNamespace,
 					},
 				},
 			}},
 		})
 	}
 
-	// 4. Allow internet, optionally blocking denied CIDRs.
-	if len(m.config.NetworkPolicy.DenyCIDRs) > 0 {
-		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
-			To: []networkingv1.NetworkPolicyPeer{{
-				IPBlock: &networkingv1.IPBlock{
-					CIDR:   "0.0.0.0/0",
-					Except: m.config.NetworkPolicy.DenyCIDRs,
-				},
-			}},
-		})
-	} else {
-		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
-			To: []networkingv1.NetworkPolicyPeer{{
-				IPBlock: &networkingv1.IPBlock{
-					CIDR: "0.0.0.0/0",
-				},
-			}},
-		})
-	}
+	// 4. Allow internet over both address families, optionally blocking
+	// denied CIDRs. Except entries must lie within the block's CIDR, so the
+	// deny list is split per family.
+	v4Deny, v6Deny := splitCIDRsByFamily(npCfg.DenyCIDRs)
+	egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{
+			{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0", Except: v4Deny}},
+			{IPBlock: &networkingv1.IPBlock{CIDR: "::/0", Except: v6Deny}},
+		},
+	})
 
 	return &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "agentserver-sandbox-egress",
+			Name:      sandboxEgressPolicyName,
 			Namespace: namespace,
 			Labels: map[string]string{
 				"managed-by": "agentserver",
@@ -188,6 +295,10 @@ func (m *Manager) buildNetworkPolicy(namespace string) *networkingv1.NetworkPoli
 				MatchLabels: map[string]string{
 					"managed-by": "agentserver",
 				},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      LabelQuarantined,
+					Operator: metav1.LabelSelectorOpDoesNotExist,
+				}},
 			},
 			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
 			Egress:      egress,
@@ -196,6 +307,7 @@ func (m *Manager) buildNetworkPolicy(namespace string) *networkingv1.NetworkPoli
 }
 
 // ParseDenyCIDRs splits a comma-separated CIDR string into a slice.
+// Both IPv4 and IPv6 CIDRs are accepted; invalid entries are dropped.
 func ParseDenyCIDRs(s string) []string {
 	if s == "" {
 		return nil
@@ -204,9 +316,30 @@ func ParseDenyCIDRs(s string) []string {
 	var cidrs []string
 	for _, p := range parts {
 		p = strings.TrimSpace(p)
-		if p != "" {
-			cidrs = append(cidrs, p)
+		if p == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(p); err != nil {
+			log.Printf("namespace: ignoring invalid deny CIDR %q: %v", p, err)
+			continue
 		}
+		cidrs = append(cidrs, p)
 	}
 	return cidrs
 }
+
+// splitCIDRsByFamily separates IPv4 and IPv6 CIDRs.
+func splitCIDRsByFamily(cidrs []string) (v4, v6 []string) {
+	for _, c := range cidrs {
+		ip, _, err := net.ParseCIDR(c)
+		if err != nil {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, c)
+		} else {
+			v6 = append(v6, c)
+		}
+	}
+	return v4, v6
+}
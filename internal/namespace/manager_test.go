@@ -0,0 +1,64 @@
+package namespace
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestParseDenyCIDRs(t *testing.T) {
+	got := ParseDenyCIDRs(" 10.0.0.0/8, fd00::/8,,not-a-cidr,169.254.169.254/32 ")
+	want := []string{"10.0.0.0/8", "fd00::/8", "169.254.169.254/32"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildNetworkPolicy_DualStack(t *testing.T) {
+	m := NewManager(nil, Config{NetworkPolicy: NetworkPolicyConfig{
+		Enabled:   true,
+		DenyCIDRs: []string{"10.0.0.0/8", "fd00::/8", "169.254.169.254/32", "fe80::/10"},
+	}})
+	np := m.buildNetworkPolicy("ns", false)
+
+	internet := np.Spec.Egress[len(np.Spec.Egress)-1]
+	if len(internet.To) != 2 {
+		t.Fatalf("internet rule has %d peers, want 2", len(internet.To))
+	}
+	v4, v6 := internet.To[0].IPBlock, internet.To[1].IPBlock
+	if v4.CIDR != "0.0.0.0/0" || !reflect.DeepEqual(v4.Except, []string{"10.0.0.0/8", "169.254.169.254/32"}) {
+		t.Errorf("IPv4 block = %+v", v4)
+	}
+	if v6.CIDR != "::/0" || !reflect.DeepEqual(v6.Except, []string{"fd00::/8", "fe80::/10"}) {
+		t.Errorf("IPv6 block = %+v", v6)
+	}
+}
+
+func TestBuildNetworkPolicy_NoDenyList(t *testing.T) {
+	m := NewManager(nil, Config{NetworkPolicy: NetworkPolicyConfig{Enabled: true}})
+	np := m.buildNetworkPolicy("ns", false)
+
+	internet := np.Spec.Egress[len(np.Spec.Egress)-1]
+	for _, peer := range internet.To {
+		if peer.IPBlock == nil || len(peer.IPBlock.Except) != 0 {
+			t.Errorf("unexpected peer %+v", peer)
+		}
+	}
+}
+
+func TestBuildNetworkPolicy_ExcludesQuarantined(t *testing.T) {
+	m := NewManager(nil, Config{NetworkPolicy: NetworkPolicyConfig{Enabled: true}})
+	sel := m.buildNetworkPolicy("ns", false).Spec.PodSelector
+	selector, err := metav1.LabelSelectorAsSelector(&sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !selector.Matches(labels.Set{"managed-by": "agentserver"}) {
+		t.Error("egress policy doesn't select sandbox pods")
+	}
+	if selector.Matches(labels.Set{"managed-by": "agentserver", LabelQuarantined: "true"}) {
+		t.Error("egress policy selects quarantined pods, overriding the deny-all")
+	}
+}
@@ -0,0 +1,122 @@
+// Package notify delivers notifications to users — workspace invitations,
+// idle-pause warnings, quota-exceeded notices and agent-offline alerts —
+// through pluggable backends, such as SMTP email and a webhook. Users turn
+// kinds off per channel; their preferences are kept by a PreferenceStore.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// Notification kinds.
+const (
+	KindInvitation    = "invitation"
+	KindIdlePause     = "idle_pause"
+	KindQuotaExceeded = "quota_exceeded"
+	KindAgentOffline  = "agent_offline"
+)
+
+// Delivery channels, one per backend type.
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// PreferenceKinds are the kinds users can turn off. Invitations are always
+// delivered: the invitee often has no account yet.
+var PreferenceKinds = []string{KindIdlePause, KindQuotaExceeded, KindAgentOffline}
+
+// Channels are the delivery channels users set preferences for.
+var Channels = []string{ChannelEmail, ChannelWebhook}
+
+// Recipient is a user to notify. UserID is empty for someone without an
+// account, e.g. an invitee.
+type Recipient struct {
+	UserID string `json:"user_id,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+// Notification is a message of one kind. Subject and Body are plain text;
+// Data holds the details for machine consumers such as webhooks.
+type Notification struct {
+	Kind    string
+	Subject string
+	Body    string
+	Data    map[string]interface{}
+}
+
+// Backend delivers notifications over one channel.
+type Backend interface {
+	Channel() string
+	Send(ctx context.Context, to []Recipient, n *Notification) error
+}
+
+// PreferenceStore tells whether a user wants notifications of a kind over a
+// channel. Users want them unless they turned them off.
+type PreferenceStore interface {
+	NotificationEnabled(userID, kind, channel string) (bool, error)
+}
+
+// Service sends notifications through its backends to the recipients that
+// want them.
+type Service struct {
+	prefs    PreferenceStore
+	backends []Backend
+}
+
+// New returns a Service. prefs may be nil, which delivers everything.
+func New(prefs PreferenceStore, backends ...Backend) *Service {
+	return &Service{prefs: prefs, backends: backends}
+}
+
+// HasChannel reports whether a backend delivers over channel.
+func (s *Service) HasChannel(channel string) bool {
+	for _, b := range s.backends {
+		if b.Channel() == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify sends n through every backend to the recipients that haven't
+// turned n.Kind off for its channel. A backend that fails doesn't keep the
+// others from sending; the errors are joined.
+func (s *Service) Notify(ctx context.Context, to []Recipient, n *Notification) error {
+	var errs []error
+	for _, b := range s.backends {
+		recipients := s.recipients(b.Channel(), to, n.Kind)
+		if len(recipients) == 0 {
+			continue
+		}
+		if err := b.Send(ctx, recipients, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.Channel(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recipients returns those of to that want kind over channel. Email needs an
+// address. When preferences can't be read, the notification is delivered.
+func (s *Service) recipients(channel string, to []Recipient, kind string) []Recipient {
+	var out []Recipient
+	for _, r := range to {
+		if channel == ChannelEmail && r.Email == "" {
+			continue
+		}
+		if s.prefs != nil && r.UserID != "" && slices.Contains(PreferenceKinds, kind) {
+			ok, err := s.prefs.NotificationEnabled(r.UserID, kind, channel)
+			if err != nil {
+				log.Printf("notify: preferences of %s: %v", r.UserID, err)
+			} else if !ok {
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeBackend struct {
+	channel string
+	err     error
+	got     [][]Recipient
+}
+
+func (b *fakeBackend) Channel() string { return b.channel }
+
+func (b *fakeBackend) Send(_ context.Context, to []Recipient, _ *Notification) error {
+	b.got = append(b.got, to)
+	return b.err
+}
+
+// prefs turns off "user|kind|channel" entries.
+type prefs map[string]bool
+
+func (p prefs) NotificationEnabled(userID, kind, channel string) (bool, error) {
+	if userID == "broken" {
+		return false, errors.New("db down")
+	}
+	return !p[userID+"|"+kind+"|"+channel], nil
+}
+
+func TestServiceNotify(t *testing.T) {
+	email := &fakeBackend{channel: ChannelEmail}
+	hook := &fakeBackend{channel: ChannelWebhook, err: errors.New("boom")}
+	svc := New(prefs{"u1|idle_pause|email": true, "u2|idle_pause|webhook": true}, email, hook)
+
+	to := []Recipient{
+		{UserID: "u1", Email: "u1@example.com"},
+		{UserID: "u2", Email: "u2@example.com"},
+		{UserID: "u3"}, // no address
+		{UserID: "broken", Email: "b@example.com"},
+	}
+	err := svc.Notify(context.Background(), to, &Notification{Kind: KindIdlePause})
+	if err == nil || !strings.Contains(err.Error(), "webhook: boom") {
+		t.Errorf("Notify error = %v, want the webhook's", err)
+	}
+	if len(email.got) != 1 || len(email.got[0]) != 2 || email.got[0][0].UserID != "u2" || email.got[0][1].UserID != "broken" {
+		t.Errorf("email recipients = %+v", email.got)
+	}
+	if len(hook.got) != 1 || len(hook.got[0]) != 3 || hook.got[0][0].UserID != "u1" || hook.got[0][1].UserID != "u3" {
+		t.Errorf("webhook recipients = %+v", hook.got)
+	}
+
+	// Invitations ignore preferences.
+	email.got = nil
+	svc = New(prefs{"u1|invitation|email": true}, email)
+	if err := svc.Notify(context.Background(), to[:1], &Notification{Kind: KindInvitation}); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.got) != 1 {
+		t.Errorf("invitation not sent: %+v", email.got)
+	}
+
+	// Nobody to send to: the backend isn't called.
+	email.got = nil
+	if err := svc.Notify(context.Background(), []Recipient{{UserID: "u3"}}, &Notification{Kind: KindAgentOffline}); err != nil || email.got != nil {
+		t.Errorf("Notify = %v, sent %+v", err, email.got)
+	}
+	if !svc.HasChannel(ChannelEmail) || svc.HasChannel(ChannelWebhook) {
+		t.Error("HasChannel")
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	var sent []string
+	m := &SMTP{Addr: "mail:25", From: "agentserver@example.com",
+		sendMail: func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			if addr != "mail:25" || from != "agentserver@example.com" || len(to) != 1 || a != nil {
+				t.Errorf("sendMail(%q, %v, %q, %v)", addr, a, from, to)
+			}
+			sent = append(sent, string(msg))
+			return nil
+		}}
+	n := &Notification{Subject: "Sandbox dev pauses soon – act now", Body: "line 1\nline 2\n"}
+	if err := m.Send(context.Background(), []Recipient{{Email: "a@example.com"}, {Email: "b@example.com"}}, n); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent %d messages, want one per recipient", len(sent))
+	}
+	msg := sent[1]
+	for _, want := range []string{"To: b@example.com\r\n", "Subject: =?utf-8?q?", "Content-Type: text/plain; charset=utf-8\r\n", "\r\n\r\nline 1\r\nline 2\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "a@example.com") {
+		t.Error("message reveals another recipient")
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	var got webhookPayload
+	var sig string
+	var raw []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ = io.ReadAll(r.Body)
+		sig = r.Header.Get(SignatureHeader)
+		json.Unmarshal(raw, &got)
+		if got.Kind == KindAgentOffline {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	h := &Webhook{URL: srv.URL, Secret: "s3cret"}
+	n := &Notification{Kind: KindQuotaExceeded, Subject: "s", Body: "b", Data: map[string]interface{}{"workspace_id": "ws1"}}
+	if err := h.Send(context.Background(), []Recipient{{UserID: "u1", Email: "u1@example.com"}}, n); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != KindQuotaExceeded || got.Data["workspace_id"] != "ws1" || len(got.Recipients) != 1 ||
+		got.Recipients[0].UserID != "u1" || time.Since(got.SentAt) > time.Minute {
+		t.Errorf("payload = %+v", got)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(raw)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+
+	n.Kind = KindAgentOffline
+	if err := h.Send(context.Background(), nil, n); err == nil {
+		t.Error("Send succeeded on a 502")
+	}
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP emails notifications through an SMTP relay, one plain-text message
+// per recipient so they don't see each other's addresses.
+type SMTP struct {
+	Addr     string // host:port
+	From     string
+	Username string // optional; enables PLAIN auth
+	Password string
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error // smtp.SendMail; replaced in tests
+}
+
+func (m *SMTP) Channel() string { return ChannelEmail }
+
+func (m *SMTP) Send(ctx context.Context, to []Recipient, n *Notification) error {
+	send := m.sendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	var errs []error
+	for _, r := range to {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := send(m.Addr, auth, m.From, []string{r.Email}, m.message(r.Email, n, time.Now())); err != nil {
+			errs = append(errs, fmt.Errorf("send to %s: %w", r.Email, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// message renders the email of n to one address.
+func (m *SMTP) message(to string, n *Notification, now time.Time) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n",
+		m.From, to, mime.QEncoding.Encode("utf-8", n.Subject), now.Format(time.RFC1123Z))
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(n.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return msg.Bytes()
+}
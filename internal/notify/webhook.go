@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed with
+// the webhook's secret, as "sha256=<hex>".
+const SignatureHeader = "X-Agentserver-Signature"
+
+// Webhook POSTs each notification as JSON to a URL, once for all
+// recipients:
+//
+//	{"kind": "...", "subject": "...", "body": "...", "data": {...},
+//	 "recipients": [{"user_id": "...", "email": "..."}], "sent_at": "..."}
+type Webhook struct {
+	URL    string
+	Secret string       // optional; signs the body
+	Client *http.Client // default: a client with a 10s timeout
+}
+
+type webhookPayload struct {
+	Kind       string                 `json:"kind"`
+	Subject    string                 `json:"subject"`
+	Body       string                 `json:"body"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Recipients []Recipient            `json:"recipients"`
+	SentAt     time.Time              `json:"sent_at"`
+}
+
+var defaultWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (h *Webhook) Channel() string { return ChannelWebhook }
+
+func (h *Webhook) Send(ctx context.Context, to []Recipient, n *Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		Kind:       n.Kind,
+		Subject:    n.Subject,
+		Body:       n.Body,
+		Data:       n.Data,
+		Recipients: to,
+		SentAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	client := h.Client
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+// Package operator reconciles agentserver.io Workspace and SandboxClaim
+// custom resources against the agentserver API, so workspaces and
+// sandboxes can be declared alongside other Kubernetes manifests.
+//
+// The database stays the source of truth for everything else: resources
+// map to ordinary workspaces and sandboxes that the UI and API show as
+// usual. The reconciler polls rather than watches, matching the rest of the
+// server's background loops.
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// ErrNotFound is returned by a Backend for a workspace or sandbox that no
+// longer exists.
+var ErrNotFound = errors.New("not found")
+
+// Sandbox is the part of an agentserver sandbox the operator reports.
+type Sandbox struct {
+	ID            string
+	Status        string
+	StatusMessage string
+	URL           string
+}
+
+// CreateSandboxRequest mirrors the REST create-sandbox body.
+type CreateSandboxRequest struct {
+	Name    string
+	Type    string
+	CPU     int   // millicores, 0 = workspace default
+	Memory  int64 // bytes, 0 = workspace default
+	Browser bool
+	// Source identifies the claim ("namespace/name"); stored in the
+	// sandbox metadata.
+	Source string
+}
+
+// Backend performs agentserver operations on behalf of the operator user.
+type Backend interface {
+	CreateWorkspace(ctx context.Context, name string) (string, error)
+	RenameWorkspace(ctx context.Context, id, name string) error
+	DeleteWorkspace(ctx context.Context, id string) error
+	// SetMember adds a member or updates their role.
+	SetMember(ctx context.Context, workspaceID, email, role string) error
+
+	CreateSandbox(ctx context.Context, workspaceID string, req CreateSandboxRequest) (*Sandbox, error)
+	GetSandbox(ctx context.Context, id string) (*Sandbox, error)
+	DeleteSandbox(ctx context.Context, id string) error
+	PauseSandbox(ctx context.Context, id string) error
+	ResumeSandbox(ctx context.Context, id string) error
+}
+
+// Operator reconciles Workspace and SandboxClaim resources.
+type Operator struct {
+	client    client.Client
+	backend   Backend
+	namespace string
+
+	// OnReconcile, if set, is called after each pass with its error.
+	OnReconcile func(err error)
+}
+
+// New returns an Operator for resources in namespace ("" for all
+// namespaces).
+func New(c client.Client, backend Backend, namespace string) *Operator {
+	return &Operator{client: c, backend: backend, namespace: namespace}
+}
+
+// Run reconciles every interval until ctx is cancelled.
+func (o *Operator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := o.Reconcile(ctx)
+		if o.OnReconcile != nil {
+			o.OnReconcile(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile runs one pass over all Workspaces, then all SandboxClaims. It
+// fails only if the resources can't be listed; errors of single resources
+// are logged.
+func (o *Operator) Reconcile(ctx context.Context) error {
+	workspaces, err := o.list(ctx, WorkspaceGVK.Kind)
+	if err != nil {
+		log.Printf("operator: list workspaces: %v", err)
+		return fmt.Errorf("list workspaces: %w", err)
+	}
+	// namespace/name -> agentserver workspace ID
+	workspaceIDs := make(map[string]string, len(workspaces))
+	for i := range workspaces {
+		u := &workspaces[i]
+		if id := o.reconcileWorkspace(ctx, u); id != "" {
+			workspaceIDs[u.GetNamespace()+"/"+u.GetName()] = id
+		}
+	}
+
+	claims, err := o.list(ctx, SandboxClaimGVK.Kind)
+	if err != nil {
+		log.Printf("operator: list sandbox claims: %v", err)
+		return fmt.Errorf("list sandbox claims: %w", err)
+	}
+	for i := range claims {
+		o.reconcileClaim(ctx, &claims[i], workspaceIDs)
+	}
+	return nil
+}
+
+func (o *Operator) list(ctx context.Context, kind string) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(WorkspaceGVK.GroupVersion().WithKind(kind + "List"))
+	var opts []client.ListOption
+	if o.namespace != "" {
+		opts = append(opts, client.InNamespace(o.namespace))
+	}
+	if err := o.client.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// reconcileWorkspace syncs one Workspace and returns its agentserver ID,
+// or "" while it has none.
+func (o *Operator) reconcileWorkspace(ctx context.Context, u *unstructured.Unstructured) string {
+	key := u.GetNamespace() + "/" + u.GetName()
+	var spec WorkspaceSpec
+	var st WorkspaceStatus
+	if err := decode(u, &spec, &st); err != nil {
+		log.Printf("operator: workspace %s: %v", key, err)
+		return ""
+	}
+
+	if u.GetDeletionTimestamp() != nil {
+		if !controllerutil.ContainsFinalizer(u, Finalizer) {
+			return ""
+		}
+		if st.WorkspaceID != "" {
+			if err := o.backend.DeleteWorkspace(ctx, st.WorkspaceID); err != nil && !errors.Is(err, ErrNotFound) {
+				log.Printf("operator: delete workspace %s (%s): %v", key, st.WorkspaceID, err)
+				return ""
+			}
+			log.Printf("operator: deleted workspace %s (%s)", key, st.WorkspaceID)
+		}
+		o.removeFinalizer(ctx, u)
+		return ""
+	}
+	if !o.ensureFinalizer(ctx, u) {
+		return ""
+	}
+
+	name := spec.DisplayName
+	if name == "" {
+		name = u.GetName()
+	}
+	orig := st
+	if st.WorkspaceID == "" {
+		id, err := o.backend.CreateWorkspace(ctx, name)
+		if err != nil {
+			st.Phase, st.Message = PhaseError, err.Error()
+			o.updateStatus(ctx, u, st != orig, &st)
+			return ""
+		}
+		log.Printf("operator: created workspace %s (%s)", key, id)
+		st.WorkspaceID = id
+		// Record the ID before anything else can fail, so the next pass
+		// does not create a second workspace.
+		if !o.updateStatus(ctx, u, st != orig, &st) {
+			return ""
+		}
+		orig = st
+	}
+
+	if st.ObservedGeneration != u.GetGeneration() {
+		if err := o.applyWorkspaceSpec(ctx, st.WorkspaceID, name, spec); err != nil {
+			st.Phase, st.Message = PhaseError, err.Error()
+		} else {
+			st.Phase, st.Message = PhaseReady, ""
+			st.ObservedGeneration = u.GetGeneration()
+		}
+	}
+	o.updateStatus(ctx, u, st != orig, &st)
+	return st.WorkspaceID
+}
+
+func (o *Operator) applyWorkspaceSpec(ctx context.Context, id, name string, spec WorkspaceSpec) error {
+	if err := o.backend.RenameWorkspace(ctx, id, name); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+	for _, m := range spec.Members {
+		role := m.Role
+		if role == "" {
+			role = "developer"
+		}
+		if err := o.backend.SetMember(ctx, id, m.Email, role); err != nil {
+			return fmt.Errorf("member %s: %w", m.Email, err)
+		}
+	}
+	return nil
+}
+
+func (o *Operator) reconcileClaim(ctx context.Context, u *unstructured.Unstructured, workspaceIDs map[string]string) {
+	key := u.GetNamespace() + "/" + u.GetName()
+	var spec SandboxClaimSpec
+	var st SandboxClaimStatus
+	if err := decode(u, &spec, &st); err != nil {
+		log.Printf("operator: sandbox claim %s: %v", key, err)
+		return
+	}
+
+	if u.GetDeletionTimestamp() != nil {
+		if !controllerutil.ContainsFinalizer(u, Finalizer) {
+			return
+		}
+		if st.SandboxID != "" {
+			if err := o.backend.DeleteSandbox(ctx, st.SandboxID); err != nil && !errors.Is(err, ErrNotFound) {
+				log.Printf("operator: delete sandbox %s (%s): %v", key, st.SandboxID, err)
+				return
+			}
+			log.Printf("operator: deleted sandbox %s (%s)", key, st.SandboxID)
+		}
+		o.removeFinalizer(ctx, u)
+		return
+	}
+	if !o.ensureFinalizer(ctx, u) {
+		return
+	}
+
+	orig := st
+	var sbx *Sandbox
+	var err error
+	if st.SandboxID != "" {
+		sbx, err = o.backend.GetSandbox(ctx, st.SandboxID)
+		if errors.Is(err, ErrNotFound) {
+			// Deleted outside the operator; the claim still wants it.
+			log.Printf("operator: sandbox %s (%s) is gone, recreating", key, st.SandboxID)
+			st.SandboxID, sbx, err = "", nil, nil
+		}
+	}
+	if err == nil && st.SandboxID == "" {
+		wsID := workspaceIDs[u.GetNamespace()+"/"+spec.WorkspaceRef]
+		if wsID == "" {
+			st.Phase, st.Message = PhasePending, fmt.Sprintf("waiting for workspace %q", spec.WorkspaceRef)
+			o.updateStatus(ctx, u, st != orig, &st)
+			return
+		}
+		sbx, err = o.createSandbox(ctx, wsID, key, u.GetName(), spec)
+		if err == nil {
+			log.Printf("operator: created sandbox %s (%s)", key, sbx.ID)
+			st.SandboxID = sbx.ID
+		}
+	}
+	if err == nil {
+		switch pauseAction(spec.Paused, sbx.Status) {
+		case "pause":
+			err = o.backend.PauseSandbox(ctx, sbx.ID)
+		case "resume":
+			err = o.backend.ResumeSandbox(ctx, sbx.ID)
+		}
+	}
+
+	if err != nil {
+		st.Phase, st.Message = PhaseError, err.Error()
+	} else {
+		st.Phase, st.Message = PhaseReady, sbx.StatusMessage
+		st.SandboxStatus, st.URL = sbx.Status, sbx.URL
+		st.ObservedGeneration = u.GetGeneration()
+	}
+	o.updateStatus(ctx, u, st != orig, &st)
+}
+
+func (o *Operator) createSandbox(ctx context.Context, workspaceID, key, name string, spec SandboxClaimSpec) (*Sandbox, error) {
+	cpu, memory, err := spec.resources()
+	if err != nil {
+		return nil, err
+	}
+	if spec.DisplayName != "" {
+		name = spec.DisplayName
+	}
+	return o.backend.CreateSandbox(ctx, workspaceID, CreateSandboxRequest{
+		Name:    name,
+		Type:    spec.Type,
+		CPU:     cpu,
+		Memory:  memory,
+		Browser: spec.Browser,
+		Source:  key,
+	})
+}
+
+// pauseAction returns "pause" or "resume" when a sandbox in status must
+// change to honour the claim's paused field, or "" otherwise. Sandboxes in
+// transitional states are left alone until they settle.
+func pauseAction(paused bool, status string) string {
+	switch {
+	case paused && status == "running":
+		return "pause"
+	case !paused && status == "paused":
+		return "resume"
+	}
+	return ""
+}
+
+// decode reads the spec and status of a resource.
+func decode(u *unstructured.Unstructured, spec, status interface{}) error {
+	if m, ok := u.Object["spec"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, spec); err != nil {
+			return fmt.Errorf("decode spec: %w", err)
+		}
+	}
+	if m, ok := u.Object["status"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, status); err != nil {
+			return fmt.Errorf("decode status: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *Operator) ensureFinalizer(ctx context.Context, u *unstructured.Unstructured) bool {
+	if !controllerutil.AddFinalizer(u, Finalizer) {
+		return true
+	}
+	if err := o.client.Update(ctx, u); err != nil {
+		log.Printf("operator: add finalizer to %s %s/%s: %v", u.GetKind(), u.GetNamespace(), u.GetName(), err)
+		return false
+	}
+	return true
+}
+
+func (o *Operator) removeFinalizer(ctx context.Context, u *unstructured.Unstructured) {
+	controllerutil.RemoveFinalizer(u, Finalizer)
+	if err := o.client.Update(ctx, u); err != nil && !apierrors.IsNotFound(err) {
+		log.Printf("operator: remove finalizer from %s %s/%s: %v", u.GetKind(), u.GetNamespace(), u.GetName(), err)
+	}
+}
+
+// updateStatus writes status (a pointer to a status struct) if changed and
+// reports whether the resource is up to date.
+func (o *Operator) updateStatus(ctx context.Context, u *unstructured.Unstructured, changed bool, status interface{}) bool {
+	if !changed {
+		return true
+	}
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		log.Printf("operator: encode status: %v", err)
+		return false
+	}
+	u.Object["status"] = m
+	if err := o.client.Status().Update(ctx, u); err != nil {
+		log.Printf("operator: update status of %s %s/%s: %v", u.GetKind(), u.GetNamespace(), u.GetName(), err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package operator
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPauseAction(t *testing.T) {
+	tests := []struct {
+		paused bool
+		status string
+		want   string
+	}{
+		{true, "running", "pause"},
+		{true, "paused", ""},
+		{true, "creating", ""},
+		{false, "paused", "resume"},
+		{false, "running", ""},
+		{false, "pausing", ""},
+	}
+	for _, tt := range tests {
+		if got := pauseAction(tt.paused, tt.status); got != tt.want {
+			t.Errorf("pauseAction(%v, %q) = %q, want %q", tt.paused, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestClaimResources(t *testing.T) {
+	cpu, mem, err := SandboxClaimSpec{CPU: "1500m", Memory: "2Gi"}.resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu != 1500 || mem != 2<<30 {
+		t.Errorf("got cpu=%d memory=%d, want 1500 and %d", cpu, mem, int64(2<<30))
+	}
+
+	cpu, mem, err = SandboxClaimSpec{}.resources()
+	if err != nil || cpu != 0 || mem != 0 {
+		t.Errorf("empty spec: got %d, %d, %v", cpu, mem, err)
+	}
+
+	if _, _, err := (SandboxClaimSpec{CPU: "lots"}).resources(); err == nil {
+		t.Error("expected error for invalid cpu")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"workspaceRef": "team-a",
+			"type":         "claudecode",
+			"paused":       true,
+		},
+		"status": map[string]interface{}{
+			"sandboxID":          "abc",
+			"observedGeneration": int64(3),
+		},
+	}}
+	var spec SandboxClaimSpec
+	var st SandboxClaimStatus
+	if err := decode(u, &spec, &st); err != nil {
+		t.Fatal(err)
+	}
+	if spec.WorkspaceRef != "team-a" || spec.Type != "claudecode" || !spec.Paused {
+		t.Errorf("spec = %+v", spec)
+	}
+	if st.SandboxID != "abc" || st.ObservedGeneration != 3 {
+		t.Errorf("status = %+v", st)
+	}
+}
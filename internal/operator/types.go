@@ -0,0 +1,98 @@
+package operator
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// API group of the agentserver CRDs (deploy/helm/.../operator-crds.yaml).
+const (
+	Group   = "agentserver.io"
+	Version = "v1alpha1"
+
+	// Finalizer keeps a resource until its workspace or sandbox has been
+	// deleted from agentserver.
+	Finalizer = "agentserver.io/cleanup"
+)
+
+var (
+	WorkspaceGVK    = schema.GroupVersionKind{Group: Group, Version: Version, Kind: "Workspace"}
+	SandboxClaimGVK = schema.GroupVersionKind{Group: Group, Version: Version, Kind: "SandboxClaim"}
+)
+
+// Phases reported in .status.phase.
+const (
+	PhasePending = "Pending"
+	PhaseReady   = "Ready"
+	PhaseError   = "Error"
+)
+
+// WorkspaceSpec declares an agentserver workspace.
+type WorkspaceSpec struct {
+	// DisplayName defaults to the resource name.
+	DisplayName string `json:"displayName,omitempty"`
+	// Members are added to the workspace (or have their role updated).
+	// Removing an entry does not remove the member.
+	Members []WorkspaceMember `json:"members,omitempty"`
+}
+
+type WorkspaceMember struct {
+	Email string `json:"email"`
+	// Role is owner, maintainer, developer (default) or guest.
+	Role string `json:"role,omitempty"`
+}
+
+type WorkspaceStatus struct {
+	WorkspaceID        string `json:"workspaceID,omitempty"`
+	Phase              string `json:"phase,omitempty"`
+	Message            string `json:"message,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+}
+
+// SandboxClaimSpec declares a sandbox in the workspace named by
+// WorkspaceRef. Everything except Paused only applies at creation.
+type SandboxClaimSpec struct {
+	// WorkspaceRef is the name of a Workspace in the same namespace.
+	WorkspaceRef string `json:"workspaceRef"`
+	// DisplayName defaults to the resource name.
+	DisplayName string `json:"displayName,omitempty"`
+	Type        string `json:"type,omitempty"`
+	// CPU and Memory are Kubernetes quantities ("500m", "2Gi"); empty uses
+	// the workspace default.
+	CPU     string `json:"cpu,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+	Browser bool   `json:"browser,omitempty"`
+	Paused  bool   `json:"paused,omitempty"`
+}
+
+type SandboxClaimStatus struct {
+	SandboxID string `json:"sandboxID,omitempty"`
+	Phase     string `json:"phase,omitempty"`
+	// SandboxStatus is the agentserver sandbox status (running, paused, ...).
+	SandboxStatus      string `json:"sandboxStatus,omitempty"`
+	Message            string `json:"message,omitempty"`
+	URL                string `json:"url,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+}
+
+// resources converts the claim's CPU and Memory to millicores and bytes;
+// zero means the workspace default.
+func (s SandboxClaimSpec) resources() (cpu int, memory int64, err error) {
+	if s.CPU != "" {
+		q, err := resource.ParseQuantity(s.CPU)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid cpu %q: %w", s.CPU, err)
+		}
+		cpu = int(q.MilliValue())
+	}
+	if s.Memory != "" {
+		q, err := resource.ParseQuantity(s.Memory)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid memory %q: %w", s.Memory, err)
+		}
+		memory = q.Value()
+	}
+	return cpu, memory, nil
+}
@@ -0,0 +1,313 @@
+// Package policy loads the platform policy file: quota defaults, allowed
+// sandbox types and images, and sandbox templates. It is meant to be
+// managed through GitOps (a ConfigMap mounted into the server), and takes
+// precedence over the equivalent admin settings.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// SandboxTypes are the sandbox types a policy may allow or template.
+var SandboxTypes = []string{"opencode", "openclaw", "nanoclaw", "claudecode", "jupyter"}
+
+// Policy is the parsed policy file. The zero value imposes nothing.
+type Policy struct {
+	Quotas Quotas `json:"quotas"`
+	// SandboxTypes restricts which sandbox types can be created; empty
+	// allows all.
+	SandboxTypes []string `json:"sandboxTypes,omitempty"`
+	// ImageAllowlist restricts the images sandboxes may run, as path.Match
+	// patterns ("ghcr.io/acme/*"); empty allows all.
+	ImageAllowlist []string   `json:"imageAllowlist,omitempty"`
+	Templates      []Template `json:"templates,omitempty"`
+}
+
+// Quotas override the system-wide quota defaults. Unset fields keep the
+// admin or environment value.
+type Quotas struct {
+	MaxWorkspacesPerUser     *int  `json:"maxWorkspacesPerUser,omitempty"`
+	MaxSandboxesPerWorkspace *int  `json:"maxSandboxesPerWorkspace,omitempty"`
+	MaxSandboxCPU            Value `json:"maxSandboxCPU,omitempty"`
+	MaxSandboxMemory         Value `json:"maxSandboxMemory,omitempty"`
+	MaxIdleTimeout           Value `json:"maxIdleTimeout,omitempty"`
+	MaxWorkspaceDriveSize    Value `json:"maxWorkspaceDriveSize,omitempty"`
+	WorkspaceMaxTotalCPU     Value `json:"workspaceMaxTotalCPU,omitempty"`
+	WorkspaceMaxTotalMemory  Value `json:"workspaceMaxTotalMemory,omitempty"`
+	WorkspaceMaxIdleTimeout  Value `json:"workspaceMaxIdleTimeout,omitempty"`
+	// MaxSandboxesByType limits the sandboxes of a type per workspace, on
+	// top of maxSandboxesPerWorkspace; 0 is unlimited.
+	MaxSandboxesByType map[string]int `json:"maxSandboxesByType,omitempty"`
+}
+
+// Template is a named set of sandbox creation defaults.
+type Template struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	CPU         Value  `json:"cpu,omitempty"`
+	Memory      Value  `json:"memory,omitempty"`
+	IdleTimeout Value  `json:"idleTimeout,omitempty"`
+	Browser     bool   `json:"browser,omitempty"`
+}
+
+// Value is a quantity or duration that may be written as a YAML string or
+// number.
+type Value string
+
+func (v *Value) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*v = Value(s)
+		return nil
+	}
+	*v = Value(b)
+	return nil
+}
+
+// Millicores parses a CPU quantity ("2", "500m").
+func (v Value) Millicores() (int, error) {
+	q, err := resource.ParseQuantity(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cpu %q", v)
+	}
+	return int(q.MilliValue()), nil
+}
+
+// Bytes parses a memory quantity ("2Gi", "512M").
+func (v Value) Bytes() (int64, error) {
+	q, err := resource.ParseQuantity(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q", v)
+	}
+	return q.Value(), nil
+}
+
+// Seconds parses a duration ("30m"); a bare number is seconds.
+func (v Value) Seconds() (int, error) {
+	if n, err := strconv.Atoi(string(v)); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return int(d.Seconds()), nil
+}
+
+// Parse decodes and validates a YAML or JSON policy.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.UnmarshalStrict(data, &p); err != nil {
+		return nil, err
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *Policy) validate() error {
+	q := p.Quotas
+	for name, v := range map[string]Value{"maxSandboxCPU": q.MaxSandboxCPU, "workspaceMaxTotalCPU": q.WorkspaceMaxTotalCPU} {
+		if _, err := v.Millicores(); v != "" && err != nil {
+			return fmt.Errorf("quotas.%s: %w", name, err)
+		}
+	}
+	for name, v := range map[string]Value{"maxSandboxMemory": q.MaxSandboxMemory, "maxWorkspaceDriveSize": q.MaxWorkspaceDriveSize, "workspaceMaxTotalMemory": q.WorkspaceMaxTotalMemory} {
+		if _, err := v.Bytes(); v != "" && err != nil {
+			return fmt.Errorf("quotas.%s: %w", name, err)
+		}
+	}
+	for name, v := range map[string]Value{"maxIdleTimeout": q.MaxIdleTimeout, "workspaceMaxIdleTimeout": q.WorkspaceMaxIdleTimeout} {
+		if _, err := v.Seconds(); v != "" && err != nil {
+			return fmt.Errorf("quotas.%s: %w", name, err)
+		}
+	}
+	for _, n := range []*int{q.MaxWorkspacesPerUser, q.MaxSandboxesPerWorkspace} {
+		if n != nil && *n < 0 {
+			return fmt.Errorf("quotas: limits must be >= 0")
+		}
+	}
+	for t, n := range q.MaxSandboxesByType {
+		if !ValidType(t) {
+			return fmt.Errorf("quotas.maxSandboxesByType: unknown type %q", t)
+		}
+		if n < 0 {
+			return fmt.Errorf("quotas: limits must be >= 0")
+		}
+	}
+
+	for _, t := range p.SandboxTypes {
+		if !ValidType(t) {
+			return fmt.Errorf("sandboxTypes: unknown type %q", t)
+		}
+	}
+	for _, pattern := range p.ImageAllowlist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("imageAllowlist: invalid pattern %q", pattern)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range p.Templates {
+		if t.Name == "" {
+			return fmt.Errorf("templates[%d]: name is required", i)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("templates: duplicate name %q", t.Name)
+		}
+		seen[t.Name] = true
+		if !ValidType(t.Type) {
+			return fmt.Errorf("template %s: unknown type %q", t.Name, t.Type)
+		}
+		if !p.TypeAllowed(t.Type) {
+			return fmt.Errorf("template %s: type %s is not in sandboxTypes", t.Name, t.Type)
+		}
+		if _, err := t.CPU.Millicores(); t.CPU != "" && err != nil {
+			return fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		if _, err := t.Memory.Bytes(); t.Memory != "" && err != nil {
+			return fmt.Errorf("template %s: %w", t.Name, err)
+		}
+		if _, err := t.IdleTimeout.Seconds(); t.IdleTimeout != "" && err != nil {
+			return fmt.Errorf("template %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidType reports whether t is a known sandbox type.
+func ValidType(t string) bool {
+	for _, v := range SandboxTypes {
+		if t == v {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeAllowed reports whether sandboxes of type t may be created.
+func (p *Policy) TypeAllowed(t string) bool {
+	if len(p.SandboxTypes) == 0 {
+		return true
+	}
+	for _, v := range p.SandboxTypes {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// ImageAllowed reports whether image matches the allowlist.
+func (p *Policy) ImageAllowed(image string) bool {
+	if len(p.ImageAllowlist) == 0 {
+		return true
+	}
+	for _, pattern := range p.ImageAllowlist {
+		if ok, _ := path.Match(pattern, image); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Template returns the template with the given name, or nil.
+func (p *Policy) Template(name string) *Template {
+	for i := range p.Templates {
+		if p.Templates[i].Name == name {
+			return &p.Templates[i]
+		}
+	}
+	return nil
+}
+
+// Watcher serves the policy file, reloading it when it changes. Mounted
+// ConfigMaps are updated in place by the kubelet, so polling picks up
+// changes without a restart.
+type Watcher struct {
+	path string
+
+	mu     sync.RWMutex
+	policy *Policy
+	raw    []byte
+	// rejected is the last invalid content, so it is reported only once.
+	rejected []byte
+}
+
+// NewWatcher loads the policy file at path. An invalid file is an error
+// here; later invalid edits are logged and the last good policy is kept.
+func NewWatcher(path string) (*Watcher, error) {
+	w := &Watcher{path: path}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get returns the current policy. A nil Watcher returns the empty policy.
+func (w *Watcher) Get() *Policy {
+	if w == nil {
+		return &Policy{}
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.policy
+}
+
+// Run reloads the file every interval until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.reload(); err != nil {
+				log.Printf("policy: keeping previous policy: %v", err)
+			}
+		}
+	}
+}
+
+func (w *Watcher) reload() error {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", w.path, err)
+	}
+	w.mu.RLock()
+	unchanged := (w.policy != nil && bytes.Equal(data, w.raw)) || (w.rejected != nil && bytes.Equal(data, w.rejected))
+	w.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	p, err := Parse(data)
+	if err != nil {
+		w.mu.Lock()
+		w.rejected = data
+		w.mu.Unlock()
+		return fmt.Errorf("%s: %w", w.path, err)
+	}
+	w.mu.Lock()
+	w.policy, w.raw = p, data
+	w.mu.Unlock()
+	log.Printf("policy: loaded %s (%d templates)", w.path, len(p.Templates))
+	return nil
+}
@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const samplePolicy = `
+quotas:
+  maxWorkspacesPerUser: 5
+  maxSandboxCPU: 4
+  maxSandboxMemory: 8Gi
+  maxIdleTimeout: 1h
+  maxSandboxesByType: {openclaw: 2}
+sandboxTypes: [opencode, claudecode]
+imageAllowlist:
+  - ghcr.io/agentserver/*
+templates:
+  - name: small
+    type: opencode
+    cpu: 500m
+    memory: 1Gi
+    idleTimeout: 15m
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(samplePolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Quotas.MaxWorkspacesPerUser == nil || *p.Quotas.MaxWorkspacesPerUser != 5 {
+		t.Errorf("maxWorkspacesPerUser = %v", p.Quotas.MaxWorkspacesPerUser)
+	}
+	if cpu, _ := p.Quotas.MaxSandboxCPU.Millicores(); cpu != 4000 {
+		t.Errorf("maxSandboxCPU = %d millicores, want 4000", cpu)
+	}
+	if mem, _ := p.Quotas.MaxSandboxMemory.Bytes(); mem != 8<<30 {
+		t.Errorf("maxSandboxMemory = %d", mem)
+	}
+	if secs, _ := p.Quotas.MaxIdleTimeout.Seconds(); secs != 3600 {
+		t.Errorf("maxIdleTimeout = %d", secs)
+	}
+	if n := p.Quotas.MaxSandboxesByType["openclaw"]; n != 2 {
+		t.Errorf("maxSandboxesByType[openclaw] = %d, want 2", n)
+	}
+
+	tmpl := p.Template("small")
+	if tmpl == nil {
+		t.Fatal("template small not found")
+	}
+	if cpu, _ := tmpl.CPU.Millicores(); cpu != 500 {
+		t.Errorf("template cpu = %d", cpu)
+	}
+	if p.Template("large") != nil {
+		t.Error("unexpected template large")
+	}
+
+	if !p.TypeAllowed("claudecode") || p.TypeAllowed("jupyter") {
+		t.Error("TypeAllowed does not follow sandboxTypes")
+	}
+	if !p.ImageAllowed("ghcr.io/agentserver/opencode-agent:latest") || p.ImageAllowed("docker.io/library/ubuntu") {
+		t.Error("ImageAllowed does not follow imageAllowlist")
+	}
+}
+
+func TestEmptyPolicyAllowsEverything(t *testing.T) {
+	p, err := Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.TypeAllowed("jupyter") || !p.ImageAllowed("anything") {
+		t.Error("empty policy should allow all types and images")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":       "quota: {}",
+		"bad cpu":             "quotas: {maxSandboxCPU: lots}",
+		"bad duration":        "quotas: {maxIdleTimeout: forever}",
+		"unknown type":        "sandboxTypes: [emacs]",
+		"disallowed tmpl":     "sandboxTypes: [opencode]\ntemplates: [{name: t, type: jupyter}]",
+		"duplicate template":  "templates: [{name: t, type: opencode}, {name: t, type: opencode}]",
+		"bad pattern":         "imageAllowlist: ['[']",
+		"unknown quota type":  "quotas: {maxSandboxesByType: {emacs: 1}}",
+		"negative type limit": "quotas: {maxSandboxesByType: {openclaw: -1}}",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestWatcherKeepsLastGoodPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(samplePolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("sandboxTypes: [emacs]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.reload(); err == nil || !strings.Contains(err.Error(), "emacs") {
+		t.Errorf("reload of invalid file: err = %v", err)
+	}
+	if w.Get().Template("small") == nil {
+		t.Error("invalid edit replaced the policy")
+	}
+
+	if err := os.WriteFile(path, []byte("sandboxTypes: [jupyter]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Get().TypeAllowed("jupyter") || w.Get().TypeAllowed("opencode") {
+		t.Error("valid edit not applied")
+	}
+
+	var nilWatcher *Watcher
+	if !nilWatcher.Get().TypeAllowed("opencode") {
+		t.Error("nil watcher should impose nothing")
+	}
+}
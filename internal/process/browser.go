@@ -0,0 +1,99 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BrowserCDPPort is the Chrome DevTools Protocol port of the browser
+// sidecar. It is bound to localhost in the sandbox's network namespace.
+const BrowserCDPPort = 9222
+
+// DefaultBrowserImage is the headless Chromium image used for the sidecar.
+const DefaultBrowserImage = "chromedp/headless-shell:stable"
+
+// BrowserSidecar configures the optional headless Chromium sidecar that runs
+// next to a sandbox, shares its network namespace and lifecycle, and is
+// reachable from the agent at BROWSER_CDP_URL.
+type BrowserSidecar struct {
+	Image string
+	// Args are appended to the image's entrypoint.
+	Args []string
+	// Types lists the sandbox types that get the sidecar by default ("*"
+	// for all types). Other sandboxes get it only when requested at
+	// creation.
+	Types  []string
+	CPU    int   // limit in millicores
+	Memory int64 // limit in bytes
+}
+
+// Enabled reports whether a sandbox of the given type gets the sidecar.
+func (b BrowserSidecar) Enabled(sandboxType string, requested bool) bool {
+	if b.Image == "" {
+		return false
+	}
+	if requested {
+		return true
+	}
+	for _, t := range b.Types {
+		if t == "*" || t == sandboxType {
+			return true
+		}
+	}
+	return false
+}
+
+// BrowserCDPURL is the CDP endpoint as seen from inside the sandbox.
+func BrowserCDPURL() string {
+	return fmt.Sprintf("http://127.0.0.1:%d", BrowserCDPPort)
+}
+
+// ParseBrowserSidecar builds the sidecar config from the BROWSER_SIDECAR_*
+// environment variables, looked up with getenv. The sidecar is off unless
+// BROWSER_SIDECAR_ENABLED=true or BROWSER_SIDECAR_TYPES is set.
+func ParseBrowserSidecar(getenv func(string) string) (BrowserSidecar, error) {
+	b := BrowserSidecar{
+		Image: DefaultBrowserImage,
+		Args: []string{
+			"--remote-debugging-address=127.0.0.1",
+			fmt.Sprintf("--remote-debugging-port=%d", BrowserCDPPort),
+			"--disable-dev-shm-usage",
+		},
+		CPU:    1000,
+		Memory: 1024 * 1024 * 1024,
+	}
+	if v, ok := lookup(getenv, "BROWSER_SIDECAR_IMAGE"); ok {
+		b.Image = v
+	}
+	if v, ok := lookup(getenv, "BROWSER_SIDECAR_TYPES"); ok {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				b.Types = append(b.Types, t)
+			}
+		}
+	}
+	if v, ok := lookup(getenv, "BROWSER_SIDECAR_CPU"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return b, fmt.Errorf("BROWSER_SIDECAR_CPU: want millicores, got %q", v)
+		}
+		b.CPU = n
+	}
+	if v, ok := lookup(getenv, "BROWSER_SIDECAR_MEMORY"); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return b, fmt.Errorf("BROWSER_SIDECAR_MEMORY: want bytes, got %q", v)
+		}
+		b.Memory = n
+	}
+	if getenv("BROWSER_SIDECAR_ENABLED") != "true" && len(b.Types) == 0 {
+		b.Image = ""
+	}
+	return b, nil
+}
+
+func lookup(getenv func(string) string, key string) (string, bool) {
+	v := strings.TrimSpace(getenv(key))
+	return v, v != ""
+}
@@ -0,0 +1,35 @@
+package process
+
+import "testing"
+
+func TestParseBrowserSidecar(t *testing.T) {
+	env := func(m map[string]string) func(string) string {
+		return func(k string) string { return m[k] }
+	}
+
+	b, err := ParseBrowserSidecar(env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Enabled("opencode", true) {
+		t.Error("sidecar should be off by default")
+	}
+
+	b, err = ParseBrowserSidecar(env(map[string]string{
+		"BROWSER_SIDECAR_TYPES":  "claudecode, jupyter",
+		"BROWSER_SIDECAR_MEMORY": "536870912",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Enabled("jupyter", false) || b.Enabled("opencode", false) || !b.Enabled("opencode", true) {
+		t.Errorf("unexpected per-type enablement: %+v", b)
+	}
+	if b.Memory != 512*1024*1024 || b.CPU != 1000 || b.Image != DefaultBrowserImage {
+		t.Errorf("limits = %+v", b)
+	}
+
+	if _, err := ParseBrowserSidecar(env(map[string]string{"BROWSER_SIDECAR_ENABLED": "true", "BROWSER_SIDECAR_CPU": "1.5"})); err == nil {
+		t.Error("expected error for non-integer CPU")
+	}
+}
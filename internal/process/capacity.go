@@ -0,0 +1,28 @@
+package process
+
+import "errors"
+
+// ErrUnschedulable is returned (wrapped) when a sandbox pod stays
+// unschedulable, e.g. because no node has enough free CPU or memory.
+var ErrUnschedulable = errors.New("sandbox cannot be scheduled")
+
+// Capacity is the cluster capacity available to a sandbox: the nodes it may
+// run on (schedulable, ready, in its node pool) and what pods already
+// request on them.
+type Capacity struct {
+	Nodes             int   `json:"nodes"`
+	AllocatableCPU    int64 `json:"allocatable_cpu"`    // millicores
+	AllocatableMemory int64 `json:"allocatable_memory"` // bytes
+	RequestedCPU      int64 `json:"requested_cpu"`
+	RequestedMemory   int64 `json:"requested_memory"`
+	// Largest free CPU and memory on a single node; a sandbox fits only if
+	// one node has both.
+	MaxFreeCPU    int64 `json:"max_free_cpu"`
+	MaxFreeMemory int64 `json:"max_free_memory"`
+	// PendingPods counts pods waiting to be scheduled.
+	PendingPods int `json:"pending_pods"`
+	// Fits reports whether the requested sandbox can be scheduled now, and
+	// FitsEmptyNode whether it could ever be, on an otherwise empty node.
+	Fits          bool `json:"fits"`
+	FitsEmptyNode bool `json:"fits_empty_node"`
+}
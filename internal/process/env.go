@@ -0,0 +1,50 @@
+package process
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// MaxEnvVars bounds the extra env vars of a sandbox.
+const MaxEnvVars = 100
+
+// SecretFilesDir is where the workspace secrets with target "file" are
+// mounted in the agent container.
+const SecretFilesDir = "/run/secrets/workspace"
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvName reports whether name can be an env var name: a shell
+// identifier.
+func ValidEnvName(name string) bool {
+	return envNameRe.MatchString(name)
+}
+
+// ValidateEnv checks extra env vars for a sandbox: at most MaxEnvVars,
+// names that are valid shell identifiers, values of at most 32 KiB.
+func ValidateEnv(env map[string]string) error {
+	if len(env) > MaxEnvVars {
+		return fmt.Errorf("env: at most %d variables", MaxEnvVars)
+	}
+	for name, value := range env {
+		if !ValidEnvName(name) {
+			return fmt.Errorf("env: invalid variable name %q", name)
+		}
+		if len(value) > 32<<10 {
+			return fmt.Errorf("env: value of %s exceeds 32 KiB", name)
+		}
+	}
+	return nil
+}
+
+// EnvNames returns the names of env in sorted order, so containers get a
+// stable environment.
+func EnvNames(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,37 @@
+package process
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateEnv(t *testing.T) {
+	if err := ValidateEnv(map[string]string{"NODE_ENV": "production", "_X1": ""}); err != nil {
+		t.Errorf("valid env rejected: %v", err)
+	}
+	for _, env := range []map[string]string{
+		{"1X": "v"},
+		{"A-B": "v"},
+		{"": "v"},
+		{"BIG": strings.Repeat("x", 32<<10+1)},
+	} {
+		if err := ValidateEnv(env); err == nil {
+			t.Errorf("ValidateEnv(%v) accepted", env)
+		}
+	}
+	many := make(map[string]string)
+	for i := 0; i <= MaxEnvVars; i++ {
+		many["V"+strings.Repeat("X", i)] = ""
+	}
+	if err := ValidateEnv(many); err == nil {
+		t.Error("too many variables accepted")
+	}
+}
+
+func TestEnvNames(t *testing.T) {
+	got := EnvNames(map[string]string{"B": "1", "A": "2", "C": "3"})
+	if want := []string{"A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvNames = %v, want %v", got, want)
+	}
+}
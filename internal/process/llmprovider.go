@@ -0,0 +1,46 @@
+package process
+
+import (
+	"fmt"
+	"slices"
+)
+
+// LLM providers a sandbox can reach through the LLM proxy
+// (/proxy/{provider}/*).
+const (
+	LLMProviderAnthropic = "anthropic"
+	LLMProviderOpenAI    = "openai"
+	LLMProviderGemini    = "gemini"
+	LLMProviderBedrock   = "bedrock"
+)
+
+// LLMProviders lists the known LLM providers.
+var LLMProviders = []string{LLMProviderAnthropic, LLMProviderOpenAI, LLMProviderGemini, LLMProviderBedrock}
+
+// LLMProvidersMetadataKey is the sandbox metadata key holding the providers
+// the sandbox selected. It is set by the server, never by clients.
+const LLMProvidersMetadataKey = "llm_providers"
+
+// ValidateLLMProviders checks a provider selection: known providers, each
+// listed once.
+func ValidateLLMProviders(providers []string) error {
+	for i, p := range providers {
+		if !slices.Contains(LLMProviders, p) {
+			return fmt.Errorf("unknown llm provider %q (want one of %v)", p, LLMProviders)
+		}
+		if slices.Contains(providers[:i], p) {
+			return fmt.Errorf("llm provider %q listed twice", p)
+		}
+	}
+	return nil
+}
+
+// LLMProviderSelected reports whether a sandbox with the given selection
+// gets credentials for provider. Sandboxes that selected none get Anthropic
+// and Gemini, the providers available before selection existed.
+func LLMProviderSelected(providers []string, provider string) bool {
+	if len(providers) == 0 {
+		return provider == LLMProviderAnthropic || provider == LLMProviderGemini
+	}
+	return slices.Contains(providers, provider)
+}
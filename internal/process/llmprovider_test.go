@@ -0,0 +1,24 @@
+package process
+
+import "testing"
+
+func TestValidateLLMProviders(t *testing.T) {
+	if err := ValidateLLMProviders([]string{"openai", "bedrock"}); err != nil {
+		t.Errorf("valid selection: %v", err)
+	}
+	for _, bad := range [][]string{{"mistral"}, {"openai", "openai"}, {""}} {
+		if err := ValidateLLMProviders(bad); err == nil {
+			t.Errorf("ValidateLLMProviders(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLLMProviderSelected(t *testing.T) {
+	if !LLMProviderSelected(nil, LLMProviderAnthropic) || !LLMProviderSelected(nil, LLMProviderGemini) || LLMProviderSelected(nil, LLMProviderOpenAI) {
+		t.Error("default selection should be anthropic and gemini")
+	}
+	sel := []string{LLMProviderOpenAI}
+	if !LLMProviderSelected(sel, LLMProviderOpenAI) || LLMProviderSelected(sel, LLMProviderAnthropic) {
+		t.Error("explicit selection not honored")
+	}
+}
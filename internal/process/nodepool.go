@@ -0,0 +1,78 @@
+package process
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Toleration mirrors a Kubernetes pod toleration.
+type Toleration struct {
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"` // "Equal" (default) or "Exists"
+	Value    string `json:"value,omitempty"`
+	Effect   string `json:"effect,omitempty"` // "NoSchedule", "PreferNoSchedule", "NoExecute"; empty matches all
+}
+
+// NodePool binds sandbox pods to a dedicated set of nodes: pods must run on
+// nodes carrying every NodeSelector label, and tolerate the taints that keep
+// other workloads off those nodes.
+type NodePool struct {
+	NodeSelector map[string]string `json:"node_selector"`
+	Tolerations  []Toleration      `json:"tolerations"`
+}
+
+var (
+	labelNameRe   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+	labelPrefixRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	labelValueRe  = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+func validLabelKey(k string) bool {
+	prefix, name, ok := strings.Cut(k, "/")
+	if !ok {
+		return labelNameRe.MatchString(k)
+	}
+	return len(prefix) <= 253 && labelPrefixRe.MatchString(prefix) && labelNameRe.MatchString(name)
+}
+
+// Validate checks that the pool is accepted by the Kubernetes API.
+func (p NodePool) Validate() error {
+	if len(p.NodeSelector) == 0 && len(p.Tolerations) == 0 {
+		return fmt.Errorf("node_selector or tolerations is required")
+	}
+	for k, v := range p.NodeSelector {
+		if !validLabelKey(k) {
+			return fmt.Errorf("node_selector: invalid label key %q", k)
+		}
+		if !labelValueRe.MatchString(v) {
+			return fmt.Errorf("node_selector: invalid label value %q for %q", v, k)
+		}
+	}
+	for i, t := range p.Tolerations {
+		if t.Key != "" && !validLabelKey(t.Key) {
+			return fmt.Errorf("tolerations[%d]: invalid key %q", i, t.Key)
+		}
+		switch t.Operator {
+		case "", "Equal":
+			if t.Key == "" {
+				return fmt.Errorf("tolerations[%d]: key is required with operator Equal", i)
+			}
+			if !labelValueRe.MatchString(t.Value) {
+				return fmt.Errorf("tolerations[%d]: invalid value %q", i, t.Value)
+			}
+		case "Exists":
+			if t.Value != "" {
+				return fmt.Errorf("tolerations[%d]: value must be empty with operator Exists", i)
+			}
+		default:
+			return fmt.Errorf("tolerations[%d]: operator must be Equal or Exists", i)
+		}
+		switch t.Effect {
+		case "", "NoSchedule", "PreferNoSchedule", "NoExecute":
+		default:
+			return fmt.Errorf("tolerations[%d]: invalid effect %q", i, t.Effect)
+		}
+	}
+	return nil
+}
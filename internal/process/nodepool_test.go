@@ -0,0 +1,56 @@
+package process
+
+import "testing"
+
+func TestNodePoolValidate(t *testing.T) {
+	ok := NodePool{
+		NodeSelector: map[string]string{"topology.kubernetes.io/region": "eu-west-1", "pool": "team-a"},
+		Tolerations: []Toleration{
+			{Key: "dedicated", Value: "team-a", Effect: "NoSchedule"},
+			{Key: "agentserver.io/eu-only", Operator: "Exists"},
+		},
+	}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("valid pool rejected: %v", err)
+	}
+
+	for name, bad := range map[string]NodePool{
+		"empty":          {},
+		"bad key":        {NodeSelector: map[string]string{"-pool": "a"}},
+		"bad value":      {NodeSelector: map[string]string{"pool": "a b"}},
+		"bad operator":   {Tolerations: []Toleration{{Key: "k", Operator: "In"}}},
+		"exists + value": {Tolerations: []Toleration{{Key: "k", Operator: "Exists", Value: "v"}}},
+		"equal no key":   {Tolerations: []Toleration{{Value: "v"}}},
+		"bad effect":     {Tolerations: []Toleration{{Key: "k", Effect: "NoRun"}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestNodePoolRegion(t *testing.T) {
+	var none *NodePool
+	if got := none.Region(); got != "" {
+		t.Errorf("nil pool region = %q", got)
+	}
+	p := &NodePool{NodeSelector: map[string]string{RegionLabel: "eu-west-1"}}
+	if got := p.Region(); got != "eu-west-1" {
+		t.Errorf("region = %q, want eu-west-1", got)
+	}
+}
+
+func TestParseRegionDomains(t *testing.T) {
+	got, err := ParseRegionDomains(" eu-west-1=EU.example.com , us-east-1=us.example.com,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["eu-west-1"] != "eu.example.com" || got["us-east-1"] != "us.example.com" {
+		t.Errorf("ParseRegionDomains = %v", got)
+	}
+	for _, bad := range []string{"eu", "=eu.example.com", "eu=", "e u=eu.example.com", "eu=a.example.com,eu=b.example.com"} {
+		if _, err := ParseRegionDomains(bad); err == nil {
+			t.Errorf("ParseRegionDomains(%q) succeeded", bad)
+		}
+	}
+}
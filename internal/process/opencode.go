@@ -0,0 +1,127 @@
+package process
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OpencodeProjectsDir is the working directory of opencode sandboxes; the
+// workspace drive is mounted here.
+const OpencodeProjectsDir = "/home/agent/projects"
+
+const (
+	// OpencodeWorkerBasePort is the port of the first extra worker; the
+	// main opencode server keeps its own port (4096).
+	OpencodeWorkerBasePort = 4097
+	// MaxOpencodeWorkers bounds the extra workers per sandbox. Each is a
+	// full opencode server sharing the sandbox's CPU and memory.
+	MaxOpencodeWorkers = 8
+)
+
+// OpencodeWorker is an extra opencode server in an opencode sandbox,
+// serving one project directory. Requests for that directory are routed to
+// it, so long-running work in one project does not block the others.
+type OpencodeWorker struct {
+	Dir  string // relative to OpencodeProjectsDir
+	Port int
+}
+
+var projectDirRe = regexp.MustCompile(`^[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*$`)
+
+// OpencodeWorkers validates project directories and assigns worker ports in
+// order, so the same list always maps to the same ports.
+func OpencodeWorkers(dirs []string) ([]OpencodeWorker, error) {
+	if len(dirs) > MaxOpencodeWorkers {
+		return nil, fmt.Errorf("at most %d projects are supported", MaxOpencodeWorkers)
+	}
+	var workers []OpencodeWorker
+	seen := make(map[string]bool)
+	for i, d := range dirs {
+		if strings.HasPrefix(d, "/") {
+			// Absolute paths must be inside the projects directory.
+			var ok bool
+			if d, ok = strings.CutPrefix(path.Clean(d), OpencodeProjectsDir+"/"); !ok {
+				return nil, fmt.Errorf("projects[%d]: %q is outside %s", i, dirs[i], OpencodeProjectsDir)
+			}
+		}
+		d = path.Clean(d)
+		if !projectDirRe.MatchString(d) || d == "." || d == ".." || strings.HasPrefix(d, "../") {
+			return nil, fmt.Errorf("projects[%d]: invalid directory %q", i, dirs[i])
+		}
+		if seen[d] {
+			return nil, fmt.Errorf("projects: duplicate directory %q", d)
+		}
+		seen[d] = true
+		workers = append(workers, OpencodeWorker{Dir: d, Port: OpencodeWorkerBasePort + i})
+	}
+	return workers, nil
+}
+
+// opencodeSupervisorScript starts a restart loop per worker ("dir port"
+// argument pairs) in the background, then execs the main server on $1 so it
+// stays the container's main process.
+const opencodeSupervisorScript = `main=$1; shift
+while [ $# -ge 2 ]; do
+  dir="` + OpencodeProjectsDir + `/$1"; port=$2; shift 2
+  mkdir -p "$dir"
+  (cd "$dir" && while :; do opencode serve --hostname 0.0.0.0 --port "$port"; sleep 2; done) &
+done
+exec opencode serve --hostname 0.0.0.0 --port "$main"`
+
+// OpencodeCommand returns the container command running the main opencode
+// server on port plus the workers, or nil when there are no workers and the
+// image entrypoint is used unchanged.
+func OpencodeCommand(workers []OpencodeWorker, port int) []string {
+	if len(workers) == 0 {
+		return nil
+	}
+	cmd := []string{"sh", "-c", opencodeSupervisorScript, "sh", strconv.Itoa(port)}
+	for _, w := range workers {
+		cmd = append(cmd, w.Dir, strconv.Itoa(w.Port))
+	}
+	return cmd
+}
+
+// OpencodeWorkerPort returns the port of the worker serving directory (an
+// absolute path in the sandbox), or 0 when the main server handles it.
+// Nested projects go to the most specific worker.
+func OpencodeWorkerPort(workers []OpencodeWorker, directory string) int {
+	if directory == "" {
+		return 0
+	}
+	directory = path.Clean(directory)
+	port, best := 0, -1
+	for _, w := range workers {
+		dir := OpencodeProjectsDir + "/" + w.Dir
+		if (directory == dir || strings.HasPrefix(directory, dir+"/")) && len(dir) > best {
+			port, best = w.Port, len(dir)
+		}
+	}
+	return port
+}
+
+// OpencodeAPIPrefixes lists the path segments of the opencode server API.
+// A path is under a prefix if it equals it (e.g. "/project") or continues
+// with "/" (e.g. "/project/current").
+var OpencodeAPIPrefixes = []string{
+	"/global", "/auth", "/project", "/session", "/pty",
+	"/file", "/find", "/config", "/mcp", "/provider",
+	"/question", "/permission", "/tui", "/experimental",
+	"/doc", "/path", "/vcs", "/command", "/log",
+	"/agent", "/skill", "/lsp", "/formatter", "/event",
+	"/instance",
+}
+
+// OpencodeAPIPrefix returns the API prefix upath (a cleaned path) is under,
+// or "" if it is not an API path.
+func OpencodeAPIPrefix(upath string) string {
+	for _, prefix := range OpencodeAPIPrefixes {
+		if upath == prefix || strings.HasPrefix(upath, prefix+"/") {
+			return prefix
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOpencodeWorkers(t *testing.T) {
+	workers, err := OpencodeWorkers([]string{"api", "/home/agent/projects/web/", "web/admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OpencodeWorker{{"api", 4097}, {"web", 4098}, {"web/admin", 4099}}
+	if !reflect.DeepEqual(workers, want) {
+		t.Errorf("workers = %+v, want %+v", workers, want)
+	}
+
+	for name, dirs := range map[string][]string{
+		"parent":   {"../etc"},
+		"root":     {"/"},
+		"dup":      {"a", "a/"},
+		"shell":    {"a;rm -rf"},
+		"too many": {"a", "b", "c", "d", "e", "f", "g", "h", "i"},
+		"empty":    {""},
+		"outside":  {"/etc/passwd/.."},
+	} {
+		if _, err := OpencodeWorkers(dirs); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestOpencodeWorkerPort(t *testing.T) {
+	workers := []OpencodeWorker{{"api", 4097}, {"web", 4098}, {"web/admin", 4099}}
+	for dir, want := range map[string]int{
+		"":                                   0,
+		"/home/agent/projects":               0,
+		"/home/agent/projects/api":           4097,
+		"/home/agent/projects/api/":          4097,
+		"/home/agent/projects/api/cmd":       4097,
+		"/home/agent/projects/apiserver":     0,
+		"/home/agent/projects/web/admin/src": 4099,
+		"/home/agent/projects/web/public":    4098,
+		"/home/agent/projects/api/../web":    4098,
+	} {
+		if got := OpencodeWorkerPort(workers, dir); got != want {
+			t.Errorf("OpencodeWorkerPort(%q) = %d, want %d", dir, got, want)
+		}
+	}
+}
+
+func TestOpencodeCommand(t *testing.T) {
+	if cmd := OpencodeCommand(nil, 4096); cmd != nil {
+		t.Errorf("no workers: got %v, want nil", cmd)
+	}
+	cmd := OpencodeCommand([]OpencodeWorker{{"api", 4097}, {"web", 4098}}, 4096)
+	if got, want := cmd[3:], []string{"sh", "4096", "api", "4097", "web", "4098"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("args = %v, want %v", got, want)
+	}
+}
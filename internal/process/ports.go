@@ -0,0 +1,58 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PreviewPortPrefix starts the subdomain of a sandbox port exposed as a
+// preview URL: port-{port}-{sandboxID}.{baseDomain}.
+const PreviewPortPrefix = "port-"
+
+// MaxPreviewPorts bounds the ports exposed per sandbox.
+const MaxPreviewPorts = 10
+
+// reservedPorts are the ports of the sandbox apps, which are served under
+// their own subdomains with their own access checks.
+var reservedPorts = map[int]bool{
+	4096:  true, // opencode
+	7681:  true, // ttyd (claudecode)
+	8888:  true, // jupyter
+	18789: true, // openclaw gateway
+}
+
+// ValidatePreviewPort checks that port can be exposed as a preview URL:
+// an unprivileged port that no sandbox app uses.
+func ValidatePreviewPort(port int) error {
+	if port < 1024 || port > 65535 {
+		return fmt.Errorf("port must be between 1024 and 65535")
+	}
+	if reservedPorts[port] || (port >= OpencodeWorkerBasePort && port < OpencodeWorkerBasePort+MaxOpencodeWorkers) {
+		return fmt.Errorf("port %d is reserved for the sandbox apps", port)
+	}
+	return nil
+}
+
+// PreviewPortSubdomain returns the subdomain of a preview URL.
+func PreviewPortSubdomain(port int, sandboxID string) string {
+	return PreviewPortPrefix + strconv.Itoa(port) + "-" + sandboxID
+}
+
+// ParsePreviewPortSubdomain parses the subdomain of a preview URL. It
+// reports false for other subdomains and for ports that can't be exposed.
+func ParsePreviewPortSubdomain(sub string) (port int, sandboxID string, ok bool) {
+	rest, ok := strings.CutPrefix(sub, PreviewPortPrefix)
+	if !ok {
+		return 0, "", false
+	}
+	num, id, ok := strings.Cut(rest, "-")
+	if !ok || id == "" || num == "" || num[0] == '0' {
+		return 0, "", false
+	}
+	port, err := strconv.Atoi(num)
+	if err != nil || ValidatePreviewPort(port) != nil {
+		return 0, "", false
+	}
+	return port, id, true
+}
@@ -0,0 +1,32 @@
+package process
+
+import "testing"
+
+func TestPreviewPorts(t *testing.T) {
+	for _, port := range []int{3000, 5173, 8080, 65535} {
+		if err := ValidatePreviewPort(port); err != nil {
+			t.Errorf("ValidatePreviewPort(%d) = %v", port, err)
+		}
+	}
+	for _, port := range []int{0, 80, 1023, 4096, 4097, 4104, 7681, 8888, 18789, 65536} {
+		if ValidatePreviewPort(port) == nil {
+			t.Errorf("ValidatePreviewPort(%d) accepted", port)
+		}
+	}
+
+	sub := PreviewPortSubdomain(5173, "k3x9a")
+	if sub != "port-5173-k3x9a" {
+		t.Errorf("PreviewPortSubdomain = %q", sub)
+	}
+	if port, id, ok := ParsePreviewPortSubdomain(sub); !ok || port != 5173 || id != "k3x9a" {
+		t.Errorf("ParsePreviewPortSubdomain(%q) = %d, %q, %v", sub, port, id, ok)
+	}
+	if _, id, ok := ParsePreviewPortSubdomain("port-3000-a-b"); !ok || id != "a-b" {
+		t.Errorf("sandbox ID with a dash = %q, %v", id, ok)
+	}
+	for _, bad := range []string{"port-3000-", "port--abc", "port-03000-abc", "port-x-abc", "port-4096-abc", "code-abc", "port-3000"} {
+		if _, _, ok := ParsePreviewPortSubdomain(bad); ok {
+			t.Errorf("ParsePreviewPortSubdomain(%q) accepted", bad)
+		}
+	}
+}
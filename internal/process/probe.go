@@ -0,0 +1,147 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Probe types.
+const (
+	ProbeTCP  = "tcp"
+	ProbeHTTP = "http"
+)
+
+// DefaultStartupTimeout is how long a backend waits for a new or resumed
+// sandbox to become ready unless configured otherwise.
+const DefaultStartupTimeout = 5 * time.Minute
+
+// ProbeConfig describes how a backend decides that a sandbox is ready and
+// how long it waits for that to happen.
+type ProbeConfig struct {
+	Type             string // ProbeTCP or ProbeHTTP
+	Path             string // HTTP path, ProbeHTTP only
+	Port             int    // 0 = the sandbox type's main port
+	InitialDelay     time.Duration
+	Period           time.Duration
+	FailureThreshold int
+	StartupTimeout   time.Duration
+}
+
+// DefaultProbe returns the built-in probe for a sandbox type.
+func DefaultProbe(sandboxType string) ProbeConfig {
+	if sandboxType == "nanoclaw" {
+		return ProbeConfig{
+			Type:             ProbeHTTP,
+			Path:             "/health",
+			InitialDelay:     5 * time.Second,
+			Period:           5 * time.Second,
+			FailureThreshold: 30,
+			StartupTimeout:   DefaultStartupTimeout,
+		}
+	}
+	return ProbeConfig{
+		Type:             ProbeTCP,
+		InitialDelay:     2 * time.Second,
+		Period:           2 * time.Second,
+		FailureThreshold: 30,
+		StartupTimeout:   DefaultStartupTimeout,
+	}
+}
+
+// ResolveProbe returns the probe for a sandbox type: the built-in default,
+// overlaid by the "*" override and then the type-specific override. Only
+// non-zero override fields replace the defaults.
+func ResolveProbe(overrides map[string]ProbeConfig, sandboxType string) ProbeConfig {
+	pc := DefaultProbe(sandboxType)
+	for _, key := range []string{"*", sandboxType} {
+		o, ok := overrides[key]
+		if !ok {
+			continue
+		}
+		if o.Type != "" {
+			pc.Type = o.Type
+			if o.Type == ProbeTCP {
+				pc.Path = ""
+			}
+		}
+		if o.Path != "" {
+			pc.Path = o.Path
+		}
+		if o.Port != 0 {
+			pc.Port = o.Port
+		}
+		if o.InitialDelay != 0 {
+			pc.InitialDelay = o.InitialDelay
+		}
+		if o.Period != 0 {
+			pc.Period = o.Period
+		}
+		if o.FailureThreshold != 0 {
+			pc.FailureThreshold = o.FailureThreshold
+		}
+		if o.StartupTimeout != 0 {
+			pc.StartupTimeout = o.StartupTimeout
+		}
+	}
+	return pc
+}
+
+// ParseProbeConfigs parses SANDBOX_PROBES, a JSON object keyed by sandbox
+// type ("*" applies to all types), e.g.
+//
+//	{"*": {"startup_timeout": "10m"},
+//	 "jupyter": {"type": "http", "path": "/api", "period": "5s", "failure_threshold": 60}}
+func ParseProbeConfigs(spec string) (map[string]ProbeConfig, error) {
+	out := map[string]ProbeConfig{}
+	if spec == "" {
+		return out, nil
+	}
+	var raw map[string]struct {
+		Type             string `json:"type"`
+		Path             string `json:"path"`
+		Port             int    `json:"port"`
+		InitialDelay     string `json:"initial_delay"`
+		Period           string `json:"period"`
+		FailureThreshold int    `json:"failure_threshold"`
+		StartupTimeout   string `json:"startup_timeout"`
+	}
+	if err := json.Unmarshal([]byte(spec), &raw); err != nil {
+		return nil, fmt.Errorf("parse probe config: %w", err)
+	}
+	for key, r := range raw {
+		pc := ProbeConfig{Type: r.Type, Path: r.Path, Port: r.Port, FailureThreshold: r.FailureThreshold}
+		switch r.Type {
+		case "", ProbeTCP:
+		case ProbeHTTP:
+			if r.Path == "" {
+				pc.Path = "/"
+			}
+		default:
+			return nil, fmt.Errorf("probe %q: invalid type %q (want tcp or http)", key, r.Type)
+		}
+		if r.Port < 0 || r.Port > 65535 || r.FailureThreshold < 0 {
+			return nil, fmt.Errorf("probe %q: port and failure_threshold must be in range", key)
+		}
+		for _, d := range []struct {
+			name string
+			src  string
+			dst  *time.Duration
+		}{
+			{"initial_delay", r.InitialDelay, &pc.InitialDelay},
+			{"period", r.Period, &pc.Period},
+			{"startup_timeout", r.StartupTimeout, &pc.StartupTimeout},
+		} {
+			if d.src == "" {
+				continue
+			}
+			v, err := time.ParseDuration(d.src)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("probe %q: invalid %s %q", key, d.name, d.src)
+			}
+			*d.dst = v
+		}
+		out[key] = pc
+	}
+	return out, nil
+}
@@ -0,0 +1,47 @@
+package process
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAndResolveProbe(t *testing.T) {
+	overrides, err := ParseProbeConfigs(`{
+		"*": {"startup_timeout": "10m"},
+		"jupyter": {"type": "http", "path": "/api", "period": "5s", "failure_threshold": 60}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc := ResolveProbe(overrides, "jupyter")
+	if pc.Type != ProbeHTTP || pc.Path != "/api" || pc.Period != 5*time.Second || pc.FailureThreshold != 60 {
+		t.Errorf("jupyter probe = %+v", pc)
+	}
+	if pc.StartupTimeout != 10*time.Minute {
+		t.Errorf("jupyter startup timeout = %v, want 10m from \"*\"", pc.StartupTimeout)
+	}
+
+	pc = ResolveProbe(overrides, "nanoclaw")
+	if pc.Type != ProbeHTTP || pc.Path != "/health" {
+		t.Errorf("nanoclaw should keep its default http probe, got %+v", pc)
+	}
+
+	pc = ResolveProbe(nil, "opencode")
+	if pc != DefaultProbe("opencode") || pc.StartupTimeout != DefaultStartupTimeout {
+		t.Errorf("opencode without overrides = %+v", pc)
+	}
+}
+
+func TestParseProbeConfigsErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"x": {"type": "grpc"}}`,
+		`{"x": {"period": "soon"}}`,
+		`{"x": {"port": 70000}}`,
+		`not json`,
+	} {
+		if _, err := ParseProbeConfigs(bad); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package process
 
+import "time"
+
 // Process represents a running process with PTY-like I/O.
 type Process interface {
 	Read(buf []byte) (int, error)
@@ -12,6 +14,10 @@ type Process interface {
 type VolumeMount struct {
 	PVCName   string // PVC name (K8s) or Docker volume name
 	MountPath string // container mount path
+
+	// SingleAttach is set for ReadWriteOnce PVCs: every pod mounting the
+	// volume must run on the same node.
+	SingleAttach bool
 }
 
 // LLMModel describes a model available to a sandbox.
@@ -38,6 +44,17 @@ type StartOptions struct {
 	SandboxID            string        // sandbox ID (used for nanoclaw bridge URL construction)
 	WorkspaceID          string        // workspace ID (used for claudecode MCP bridge config)
 	AssistantName        string        // nanoclaw only: configurable assistant name (default "Andy")
+	DNSNameserver        string        // K8s only: workspace DNS filter address (empty uses cluster DNS)
+	NodePool             *NodePool     // K8s only: dedicated node pool for the workspace (nil schedules anywhere)
+	PriorityClassName    string        // K8s only: PriorityClass of the sandbox pod (empty uses the cluster default)
+	Browser              bool          // request the headless browser sidecar (see BrowserSidecar)
+	Image                string        // run this image instead of the type's (a template image, or a hibernated sandbox on Docker)
+	OpencodeWorkers      []OpencodeWorker // opencode only: extra servers for project directories
+	LLMProviders         []string      // LLM providers to inject proxy credentials for (see LLMProviderSelected)
+	Env                  map[string]string // extra agent container env (a workspace template's); variables the backend sets win
+	SecretEnv            map[string]string // workspace secrets as env vars; kept out of the pod spec where the backend can; win over Env
+	SecretFiles          map[string]string // workspace secrets as read-only files in SecretFilesDir, by file name
+	Command              []string      // replaces the agent container's command (a workspace template's startup command)
 }
 
 // Manager manages process lifecycles.
@@ -52,3 +69,21 @@ type Manager interface {
 	Resume(id, sandboxName, command string, args []string) (Process, error)
 	Close() error
 }
+
+// CreateEvent is a provisioning event observed while a sandbox starts, e.g.
+// scheduling, volume binding, image pulls or init container progress.
+type CreateEvent struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`           // e.g. "default-scheduler", "kubelet", "init"
+	Object  string    `json:"object,omitempty"` // e.g. "Pod/agent-sandbox-1234abcd"
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Warning bool      `json:"warning,omitempty"`
+	Count   int32     `json:"count,omitempty"`
+}
+
+// DiagnosticFile is one entry of a sandbox diagnostics bundle.
+type DiagnosticFile struct {
+	Name string
+	Data []byte
+}
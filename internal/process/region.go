@@ -0,0 +1,50 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegionLabel is the well-known node label naming the region a node runs in.
+// A workspace node pool selecting on it places the workspace's sandboxes in
+// that region.
+const RegionLabel = "topology.kubernetes.io/region"
+
+// RegionMetadataKey is the sandbox metadata key holding the region the
+// sandbox was placed in. It is set by the server, never by clients.
+const RegionMetadataKey = "region"
+
+// Region returns the region the pool pins pods to, or "" if it does not
+// select on RegionLabel. A nil pool has no region.
+func (p *NodePool) Region() string {
+	if p == nil {
+		return ""
+	}
+	return p.NodeSelector[RegionLabel]
+}
+
+// ParseRegionDomains parses a comma-separated list of region=domain pairs
+// (e.g. "eu-west-1=eu.agentserver.dev,us-east-1=us.agentserver.dev"): the
+// base domain sandbox subdomains of each region are served under.
+func ParseRegionDomains(s string) (map[string]string, error) {
+	domains := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		region, domain, ok := strings.Cut(pair, "=")
+		region, domain = strings.TrimSpace(region), strings.ToLower(strings.TrimSpace(domain))
+		if !ok || region == "" || domain == "" {
+			return nil, fmt.Errorf("invalid region domain %q (want region=domain)", pair)
+		}
+		if !labelValueRe.MatchString(region) {
+			return nil, fmt.Errorf("invalid region %q", region)
+		}
+		if _, dup := domains[region]; dup {
+			return nil, fmt.Errorf("region %q listed twice", region)
+		}
+		domains[region] = domain
+	}
+	return domains, nil
+}
@@ -0,0 +1,361 @@
+// Package proxycapture records proxied sandbox traffic and replays it in
+// tests. Recording is an internal debugging mode of the sandbox proxy: it
+// captures the requests the proxy sends upstream (after header rewriting
+// and auth injection) and the responses it streams back, chunk by chunk so
+// SSE framing is preserved. Credentials are redacted before anything is
+// written. A Replayer serves a recording as a fake upstream, so a
+// regression in the proxy or tunnel stack can be reproduced
+// deterministically.
+package proxycapture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCaptureBody bounds the bytes recorded per request or response body;
+// traffic beyond it is still proxied but not recorded.
+const maxCaptureBody = 1 << 20
+
+// Redacted replaces credentials in recordings.
+const Redacted = "[REDACTED]"
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	Time      time.Time   `json:"time"`
+	SandboxID string      `json:"sandbox_id"`
+	Route     string      `json:"route"` // e.g. "subdomain", "tunnel"
+	Method    string      `json:"method"`
+	URL       string      `json:"url"` // path and query
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body,omitempty"`
+	// BodyTruncated is set when the request body exceeded maxCaptureBody.
+	BodyTruncated bool `json:"body_truncated,omitempty"`
+
+	Status         int         `json:"status,omitempty"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	Chunks         []Chunk     `json:"chunks,omitempty"`
+	// ResponseTruncated is set when the response exceeded maxCaptureBody.
+	ResponseTruncated bool   `json:"response_truncated,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// Chunk is one read of the response body, at its offset from the response
+// headers.
+type Chunk struct {
+	Offset time.Duration `json:"offset"`
+	Data   []byte        `json:"data"`
+}
+
+// ResponseBody returns the recorded response body.
+func (e *Exchange) ResponseBody() []byte {
+	var b []byte
+	for _, c := range e.Chunks {
+		b = append(b, c.Data...)
+	}
+	return b
+}
+
+// sensitiveHeaders are redacted; for Authorization the scheme is kept so a
+// recording still shows which auth was injected.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// sensitiveParams are query parameters that carry credentials.
+var sensitiveParams = map[string]bool{
+	"token":        true,
+	"access_token": true,
+	"api_key":      true,
+	"key":          true,
+	"password":     true,
+	"secret":       true,
+}
+
+// SanitizeHeader returns a copy of h with credentials redacted.
+func SanitizeHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vals := range h {
+		ck := http.CanonicalHeaderKey(k)
+		if !sensitiveHeaders[ck] {
+			out[ck] = append([]string(nil), vals...)
+			continue
+		}
+		red := make([]string, len(vals))
+		for i, v := range vals {
+			red[i] = Redacted
+			if scheme, _, ok := strings.Cut(v, " "); ok && (ck == "Authorization" || ck == "Proxy-Authorization") {
+				red[i] = scheme + " " + Redacted
+			}
+		}
+		out[ck] = red
+	}
+	return out
+}
+
+// SanitizeURL redacts credential query parameters of a request URI.
+func SanitizeURL(uri string) string {
+	p, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return p + "?" + Redacted
+	}
+	changed := false
+	for k := range q {
+		if sensitiveParams[strings.ToLower(k)] {
+			q[k] = []string{Redacted}
+			changed = true
+		}
+	}
+	if !changed {
+		return uri
+	}
+	return p + "?" + q.Encode()
+}
+
+// redactSecrets replaces each non-empty secret in b.
+func redactSecrets(b []byte, secrets []string) []byte {
+	for _, s := range secrets {
+		if len(s) >= 8 {
+			b = bytes.ReplaceAll(b, []byte(s), []byte(Redacted))
+		}
+	}
+	return b
+}
+
+// Recorder appends exchanges to one JSON Lines file per sandbox in a
+// directory. A nil Recorder records nothing.
+type Recorder struct {
+	dir       string
+	all       bool
+	sandboxes map[string]bool
+
+	mu sync.Mutex
+}
+
+// NewRecorder records traffic of the given sandboxes ("*" for all) to dir.
+func NewRecorder(dir string, sandboxes []string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create capture dir: %w", err)
+	}
+	r := &Recorder{dir: dir, sandboxes: make(map[string]bool)}
+	for _, id := range sandboxes {
+		if id == "*" {
+			r.all = true
+		}
+		r.sandboxes[id] = true
+	}
+	return r, nil
+}
+
+// Enabled reports whether traffic of sandboxID is recorded.
+func (r *Recorder) Enabled(sandboxID string) bool {
+	return r != nil && (r.all || r.sandboxes[sandboxID])
+}
+
+// Path returns the recording file of a sandbox.
+func (r *Recorder) Path(sandboxID string) string {
+	return filepath.Join(r.dir, filepath.Base(sandboxID)+".jsonl")
+}
+
+func (r *Recorder) write(e *Exchange) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.Path(e.SandboxID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Capture is an exchange being recorded. Its methods are no-ops on nil, so
+// callers need not check whether recording is enabled.
+type Capture struct {
+	r       *Recorder
+	secrets []string
+	ex      Exchange
+	start   time.Time
+	size    int
+	once    sync.Once
+}
+
+// Start begins recording a request. secrets (e.g. the sandbox's tokens) are
+// additionally redacted wherever they appear in bodies. It returns nil when
+// the sandbox is not recorded.
+func (r *Recorder) Start(sandboxID, route, method, uri string, header http.Header, body []byte, secrets ...string) *Capture {
+	if !r.Enabled(sandboxID) {
+		return nil
+	}
+	c := &Capture{r: r, secrets: secrets, start: time.Now()}
+	c.ex = Exchange{
+		Time:      c.start.UTC(),
+		SandboxID: sandboxID,
+		Route:     route,
+		Method:    method,
+		URL:       SanitizeURL(uri),
+		Header:    SanitizeHeader(header),
+	}
+	if len(body) > maxCaptureBody {
+		body = body[:maxCaptureBody]
+		c.ex.BodyTruncated = true
+	}
+	if len(body) > 0 {
+		c.ex.Body = redactSecrets(append([]byte(nil), body...), secrets)
+	}
+	return c
+}
+
+// Response records the response status and headers.
+func (c *Capture) Response(status int, header http.Header) {
+	if c == nil {
+		return
+	}
+	c.start = time.Now()
+	c.ex.Status = status
+	c.ex.ResponseHeader = SanitizeHeader(header)
+}
+
+// Chunk records a piece of the response body.
+func (c *Capture) Chunk(data []byte) {
+	if c == nil || len(data) == 0 || c.ex.ResponseTruncated {
+		return
+	}
+	if c.size+len(data) > maxCaptureBody {
+		c.ex.ResponseTruncated = true
+		return
+	}
+	c.size += len(data)
+	c.ex.Chunks = append(c.ex.Chunks, Chunk{
+		Offset: time.Since(c.start),
+		Data:   redactSecrets(append([]byte(nil), data...), c.secrets),
+	})
+}
+
+// Done writes the exchange; err is the proxy error, if any. Later calls are
+// ignored.
+func (c *Capture) Done(err error) {
+	if c == nil {
+		return
+	}
+	c.once.Do(func() {
+		if err != nil && err != io.EOF {
+			c.ex.Error = err.Error()
+		}
+		if werr := c.r.write(&c.ex); werr != nil {
+			log.Printf("proxycapture: write %s: %v", c.r.Path(c.ex.SandboxID), werr)
+		}
+	})
+}
+
+// Transport wraps base so that requests it sends are recorded.
+func (r *Recorder) Transport(base http.RoundTripper, sandboxID, route string, secrets ...string) http.RoundTripper {
+	if !r.Enabled(sandboxID) {
+		return base
+	}
+	return &captureTransport{base: base, r: r, sandboxID: sandboxID, route: route, secrets: secrets}
+}
+
+type captureTransport struct {
+	base      http.RoundTripper
+	r         *Recorder
+	sandboxID string
+	route     string
+	secrets   []string
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		// Record a prefix of the body and forward all of it.
+		var err error
+		body, err = io.ReadAll(io.LimitReader(req.Body, maxCaptureBody+1))
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+	}
+	c := t.r.Start(t.sandboxID, t.route, req.Method, req.URL.RequestURI(), req.Header, body, t.secrets...)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		c.Done(err)
+		return nil, err
+	}
+	c.Response(resp.StatusCode, resp.Header)
+	resp.Body = &captureBody{ReadCloser: resp.Body, c: c}
+	return resp, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureBody records response reads and finishes the capture at EOF or
+// close.
+type captureBody struct {
+	io.ReadCloser
+	c *Capture
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.c.Chunk(p[:n])
+	if err != nil {
+		b.c.Done(err)
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	b.c.Done(nil)
+	return b.ReadCloser.Close()
+}
+
+// Load reads a recording file.
+func Load(path string) ([]Exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []Exchange
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*maxCaptureBody)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var e Exchange
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,143 @@
+package proxycapture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// volatileHeaders differ between a recording and its replay and are not
+// compared.
+var volatileHeaders = map[string]bool{
+	"X-Forwarded-For": true,
+	"Accept-Encoding": true,
+	"Traceparent":     true,
+	"Tracestate":      true,
+}
+
+// Replayer is an http.Handler that plays back recorded exchanges as the
+// upstream of a proxy under test. Each request is matched to the first
+// unused exchange with the same method and (sanitized) URL; the recorded
+// request headers and body are compared with what arrived, and the
+// recorded response is written chunk by chunk with a flush after each.
+type Replayer struct {
+	// RealTime replays chunks at their recorded offsets instead of
+	// back to back.
+	RealTime bool
+
+	mu         sync.Mutex
+	exchanges  []Exchange
+	used       []bool
+	mismatches []string
+}
+
+// NewReplayer returns a Replayer for the given exchanges.
+func NewReplayer(exchanges []Exchange) *Replayer {
+	return &Replayer{exchanges: exchanges, used: make([]bool, len(exchanges))}
+}
+
+func (p *Replayer) mismatch(format string, args ...interface{}) {
+	p.mismatches = append(p.mismatches, fmt.Sprintf(format, args...))
+}
+
+func (p *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uri := SanitizeURL(r.URL.RequestURI())
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxCaptureBody))
+
+	p.mu.Lock()
+	idx := -1
+	for i, e := range p.exchanges {
+		if !p.used[i] && e.Method == r.Method && e.URL == uri {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		p.mismatch("unexpected request %s %s", r.Method, uri)
+		p.mu.Unlock()
+		http.Error(w, "proxycapture: no recorded exchange", http.StatusNotImplemented)
+		return
+	}
+	p.used[idx] = true
+	e := p.exchanges[idx]
+	p.compare(&e, r.Header, body)
+	p.mu.Unlock()
+
+	if e.Error != "" {
+		// The upstream failed during recording; fail the connection.
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		http.Error(w, e.Error, http.StatusBadGateway)
+		return
+	}
+	for k, vals := range e.ResponseHeader {
+		// Redaction can change the body length.
+		if k != "Content-Length" {
+			w.Header()[k] = vals
+		}
+	}
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	flusher, _ := w.(http.Flusher)
+	start := time.Now()
+	for _, c := range e.Chunks {
+		if p.RealTime {
+			if d := c.Offset - time.Since(start); d > 0 {
+				select {
+				case <-time.After(d):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+		w.Write(c.Data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// compare records differences between the recorded request e and the one
+// received. Only headers present in the recording are compared.
+func (p *Replayer) compare(e *Exchange, header http.Header, body []byte) {
+	got := SanitizeHeader(header)
+	for k, want := range e.Header {
+		if volatileHeaders[k] {
+			continue
+		}
+		if g := got[k]; strings.Join(g, ", ") != strings.Join(want, ", ") {
+			p.mismatch("%s %s: header %s = %q, recorded %q", e.Method, e.URL, k, g, want)
+		}
+	}
+	if !e.BodyTruncated && !bytes.Equal(body, e.Body) && !bytes.Contains(e.Body, []byte(Redacted)) {
+		p.mismatch("%s %s: request body differs from the recording", e.Method, e.URL)
+	}
+}
+
+// Err reports requests that did not match the recording and recorded
+// exchanges that were never requested.
+func (p *Replayer) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	problems := append([]string(nil), p.mismatches...)
+	for i, e := range p.exchanges {
+		if !p.used[i] {
+			problems = append(problems, fmt.Sprintf("recorded request %s %s was not replayed", e.Method, e.URL))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("replay: %s", strings.Join(problems, "; "))
+}
@@ -0,0 +1,38 @@
+package sandbox
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// labelWorkspaceDrive marks pods mounting a single-attach workspace drive;
+// the value is the PVC name.
+const labelWorkspaceDrive = "agentserver.io/workspace-drive"
+
+// applySingleAttachAffinity co-schedules pods that share a ReadWriteOnce
+// workspace drive: the pod is labelled with the PVC name and required to run
+// on the same node as any other pod carrying that label. The first pod is
+// free to land anywhere (a pod may satisfy its own affinity term).
+func applySingleAttachAffinity(labels map[string]string, spec *corev1.PodSpec, vols []process.VolumeMount) {
+	for _, vol := range vols {
+		if !vol.SingleAttach {
+			continue
+		}
+		labels[labelWorkspaceDrive] = vol.PVCName
+		if spec.Affinity == nil {
+			spec.Affinity = &corev1.Affinity{}
+		}
+		spec.Affinity.PodAffinity = &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{labelWorkspaceDrive: vol.PVCName},
+				},
+				TopologyKey: "kubernetes.io/hostname",
+			}},
+		}
+		// Workspaces have a single drive; one term is enough.
+		return
+	}
+}
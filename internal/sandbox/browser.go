@@ -0,0 +1,43 @@
+package sandbox
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+const browserContainerName = "browser"
+
+// applyBrowserSidecar adds the headless Chromium sidecar to a sandbox pod.
+// It runs as a native sidecar (an init container with restartPolicy Always),
+// so it starts before the main container, is restarted if Chromium crashes
+// even though the pod's restartPolicy is Never, and goes away with the pod on
+// pause and delete. Containers in a pod share the network namespace, so the
+// agent reaches CDP on localhost. Chromium listens on localhost only, so
+// there is no startup probe; clients retry until CDP answers.
+func applyBrowserSidecar(spec *corev1.PodSpec, cfg process.BrowserSidecar) {
+	always := corev1.ContainerRestartPolicyAlways
+	noEscalation := false
+	spec.InitContainers = append(spec.InitContainers, corev1.Container{
+		Name:          browserContainerName,
+		Image:         cfg.Image,
+		Args:          cfg.Args,
+		RestartPolicy: &always,
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: memoryQuantity(cfg.Memory),
+				corev1.ResourceCPU:    cpuQuantity(cfg.CPU),
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: &noEscalation,
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+	})
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == sandboxContainerName {
+			spec.Containers[i].Env = append(spec.Containers[i].Env,
+				corev1.EnvVar{Name: "BROWSER_CDP_URL", Value: process.BrowserCDPURL()})
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// unschedulableGrace is how long a sandbox pod may stay unschedulable
+// before waitForReady gives up, leaving time for preemption or a cluster
+// autoscaler to make room.
+const unschedulableGrace = 90 * time.Second
+
+// Capacity reports the cluster capacity available to a sandbox requesting
+// cpu millicores and memory bytes on the given node pool (nil for any node).
+func (m *Manager) Capacity(ctx context.Context, cpu int, memory int64, pool *process.NodePool) (*process.Capacity, error) {
+	nodes, err := m.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+	pods, err := m.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	return evaluateCapacity(nodes.Items, pods.Items, int64(cpu), memory, pool), nil
+}
+
+// evaluateCapacity sums allocatable and requested resources over the nodes
+// a sandbox may be scheduled on and checks whether the request fits.
+func evaluateCapacity(nodes []corev1.Node, pods []corev1.Pod, cpu, memory int64, pool *process.NodePool) *process.Capacity {
+	type usage struct{ cpu, memory int64 }
+	requested := make(map[string]usage)
+	c := &process.Capacity{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Spec.NodeName == "" {
+			c.PendingPods++
+			continue
+		}
+		podCPU, podMemory := podRequests(pod)
+		u := requested[pod.Spec.NodeName]
+		requested[pod.Spec.NodeName] = usage{u.cpu + podCPU, u.memory + podMemory}
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		if !nodeEligible(node, pool) {
+			continue
+		}
+		allocCPU := node.Status.Allocatable.Cpu().MilliValue()
+		allocMemory := node.Status.Allocatable.Memory().Value()
+		u := requested[node.Name]
+		freeCPU, freeMemory := allocCPU-u.cpu, allocMemory-u.memory
+
+		c.Nodes++
+		c.AllocatableCPU += allocCPU
+		c.AllocatableMemory += allocMemory
+		c.RequestedCPU += u.cpu
+		c.RequestedMemory += u.memory
+		if freeCPU > c.MaxFreeCPU {
+			c.MaxFreeCPU = freeCPU
+		}
+		if freeMemory > c.MaxFreeMemory {
+			c.MaxFreeMemory = freeMemory
+		}
+		if freeCPU >= cpu && freeMemory >= memory {
+			c.Fits = true
+		}
+		if allocCPU >= cpu && allocMemory >= memory {
+			c.FitsEmptyNode = true
+		}
+	}
+	return c
+}
+
+// podRequests returns the effective CPU (millicores) and memory requests of
+// a pod: the larger of its containers' sum and any single init container.
+func podRequests(pod *corev1.Pod) (cpu, memory int64) {
+	for _, c := range pod.Spec.Containers {
+		cpu += c.Resources.Requests.Cpu().MilliValue()
+		memory += c.Resources.Requests.Memory().Value()
+	}
+	for _, c := range pod.Spec.InitContainers {
+		if v := c.Resources.Requests.Cpu().MilliValue(); v > cpu {
+			cpu = v
+		}
+		if v := c.Resources.Requests.Memory().Value(); v > memory {
+			memory = v
+		}
+	}
+	return cpu, memory
+}
+
+// nodeEligible reports whether a sandbox pod bound to pool could be
+// scheduled on node: it is ready and schedulable, carries the pool's
+// selector labels, and has no taint the pod doesn't tolerate.
+func nodeEligible(node *corev1.Node, pool *process.NodePool) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	ready := false
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+	if !ready {
+		return false
+	}
+	var tolerations []process.Toleration
+	if pool != nil {
+		for k, v := range pool.NodeSelector {
+			if node.Labels[k] != v {
+				return false
+			}
+		}
+		tolerations = pool.Tolerations
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !tolerated(taint, tolerations) {
+			return false
+		}
+	}
+	return true
+}
+
+func tolerated(taint corev1.Taint, tolerations []process.Toleration) bool {
+	for _, t := range tolerations {
+		if t.Effect != "" && t.Effect != string(taint.Effect) {
+			continue
+		}
+		if t.Operator == "Exists" {
+			if t.Key == "" || t.Key == taint.Key {
+				return true
+			}
+			continue
+		}
+		if t.Key == taint.Key && t.Value == taint.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// unschedulableMessage returns the scheduler's message if the pod has been
+// unschedulable for longer than unschedulableGrace, or "".
+func unschedulableMessage(pod *corev1.Pod, now time.Time) string {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse &&
+			cond.Reason == corev1.PodReasonUnschedulable &&
+			now.Sub(cond.LastTransitionTime.Time) > unschedulableGrace {
+			return cond.Message
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package sandbox
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+func testNode(name, cpu, memory string, labels map[string]string, taints ...corev1.Taint) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{Taints: taints},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func testPod(node, cpu, memory string) corev1.Pod {
+	return corev1.Pod{
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(memory),
+				}},
+			}},
+		},
+	}
+}
+
+func TestEvaluateCapacity(t *testing.T) {
+	nodes := []corev1.Node{
+		testNode("a", "4", "8Gi", nil),
+		testNode("b", "2", "4Gi", nil),
+	}
+	pods := []corev1.Pod{
+		testPod("a", "3", "2Gi"),
+		testPod("b", "1", "3Gi"),
+		testPod("", "1", "1Gi"),
+	}
+
+	c := evaluateCapacity(nodes, pods, 1000, 1<<30, nil)
+	if c.Nodes != 2 || c.AllocatableCPU != 6000 || c.RequestedCPU != 4000 || c.PendingPods != 1 {
+		t.Fatalf("unexpected totals: %+v", c)
+	}
+	if c.MaxFreeCPU != 1000 || c.MaxFreeMemory != 6<<30 {
+		t.Fatalf("unexpected max free: %+v", c)
+	}
+	if !c.Fits {
+		t.Error("1 CPU / 1Gi should fit on node a")
+	}
+
+	// Free CPU and memory are on different nodes: a 2 CPU / 2Gi sandbox
+	// doesn't fit now but would on an empty node.
+	c = evaluateCapacity(nodes, pods, 2000, 2<<30, nil)
+	if c.Fits || !c.FitsEmptyNode {
+		t.Errorf("2 CPU / 2Gi: fits=%v fitsEmpty=%v, want false/true", c.Fits, c.FitsEmptyNode)
+	}
+
+	c = evaluateCapacity(nodes, pods, 8000, 1<<30, nil)
+	if c.Fits || c.FitsEmptyNode {
+		t.Errorf("8 CPU should never fit: %+v", c)
+	}
+}
+
+func TestEvaluateCapacityNodePool(t *testing.T) {
+	dedicated := corev1.Taint{Key: "dedicated", Value: "team-a", Effect: corev1.TaintEffectNoSchedule}
+	nodes := []corev1.Node{
+		testNode("shared", "2", "4Gi", nil),
+		testNode("team-a", "8", "16Gi", map[string]string{"pool": "team-a"}, dedicated),
+	}
+
+	// Without the pool, the tainted node is not eligible.
+	c := evaluateCapacity(nodes, nil, 4000, 1<<30, nil)
+	if c.Nodes != 1 || c.FitsEmptyNode {
+		t.Errorf("no pool: %+v", c)
+	}
+
+	pool := &process.NodePool{
+		NodeSelector: map[string]string{"pool": "team-a"},
+		Tolerations:  []process.Toleration{{Key: "dedicated", Value: "team-a", Effect: "NoSchedule"}},
+	}
+	c = evaluateCapacity(nodes, nil, 4000, 1<<30, pool)
+	if c.Nodes != 1 || !c.Fits {
+		t.Errorf("team-a pool: %+v", c)
+	}
+}
+
+func TestUnschedulableMessage(t *testing.T) {
+	now := time.Now()
+	pod := &corev1.Pod{Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+		Type:               corev1.PodScheduled,
+		Status:             corev1.ConditionFalse,
+		Reason:             corev1.PodReasonUnschedulable,
+		Message:            "0/3 nodes are available: 3 Insufficient cpu.",
+		LastTransitionTime: metav1.NewTime(now.Add(-time.Minute)),
+	}}}}
+	if msg := unschedulableMessage(pod, now); msg != "" {
+		t.Errorf("within grace: got %q", msg)
+	}
+	if msg := unschedulableMessage(pod, now.Add(unschedulableGrace)); msg != "0/3 nodes are available: 3 Insufficient cpu." {
+		t.Errorf("after grace: got %q", msg)
+	}
+}
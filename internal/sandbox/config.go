@@ -27,6 +27,7 @@ type Config struct {
 	NanoclawBridgeBaseURL    string // agentserver internal URL for NanoClaw pods to call back (e.g. "http://agentserver:8080")
 	NanoclawModel            string // Claude Code model override (e.g. "claude-opus-4-6")
 	GeminiProxyBaseURL       string // Gemini proxy base URL without path (e.g. "http://llmproxy:8081")
+	LLMProxyURL              string // LLM proxy root URL for /proxy/{provider} (e.g. "http://llmproxy:8081")
 	ClaudeCodeImage            string
 	ClaudeCodeRuntimeClassName string
 	ClaudeCodePort             int    // default 7681 (ttyd)
@@ -41,6 +42,11 @@ type Config struct {
 	CodexExecGatewayURL string
 	AgentServerInternalURL     string // agentserver API URL for sandbox MCP bridge (e.g. "http://agentserver.agentserver.svc:8080")
 	CredproxyPublicURL         string // URL sandboxes use to reach credentialproxy (e.g. "http://credentialproxy.agentserver.svc:8083")
+	// Probes overrides readiness probes and startup timeouts per sandbox
+	// type ("*" for all types); see process.ParseProbeConfigs.
+	Probes map[string]process.ProbeConfig
+	// Browser configures the optional headless Chromium sidecar.
+	Browser process.BrowserSidecar
 }
 
 // DefaultConfig returns a Config populated from environment variables with sensible defaults.
@@ -63,6 +69,7 @@ func DefaultConfig() Config {
 		NanoclawBridgeBaseURL:    os.Getenv("NANOCLAW_BRIDGE_BASE_URL"),
 		NanoclawModel:            os.Getenv("NANOCLAW_MODEL"),
 		GeminiProxyBaseURL:       os.Getenv("GOOGLE_GEMINI_BASE_URL"),
+		LLMProxyURL:              os.Getenv("LLMPROXY_URL"),
 		ClaudeCodeImage:            os.Getenv("CLAUDECODE_IMAGE"),
 		ClaudeCodeRuntimeClassName: os.Getenv("CLAUDECODE_RUNTIME_CLASS"),
 		ClaudeCodePort:             7681,
@@ -191,7 +198,7 @@ func BuildOpenclawConfig(proxyBaseURL, proxyToken, gatewayToken string, customMo
 	}
 	// Trust cluster-internal proxy IPs so the gateway reads our injected
 	// Authorization header and X-Forwarded-For on WebSocket upgrades.
-	c.Gateway.TrustedProxies = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+	c.Gateway.TrustedProxies = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
 	c.Gateway.ControlUI.Enabled = true
 	c.Gateway.ControlUI.AllowInsecureAuth = true
 	c.Gateway.ControlUI.AllowOriginFallback = true
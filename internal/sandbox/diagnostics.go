@@ -0,0 +1,171 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+const diagnosticsLogLines int64 = 1000
+
+// opencodeLogCmd prints the tail of opencode's most recent log file.
+var opencodeLogCmd = []string{"sh", "-c", `f=$(ls -t "$HOME"/.local/share/opencode/log/*.log 2>/dev/null | head -n 1); [ -n "$f" ] && tail -n 1000 "$f"`}
+
+// Diagnostics collects the state of a sandbox's pod for a diagnostics
+// bundle: the pod object, events, container logs (including the previous
+// instance of restarted containers), resource usage and, for opencode, its
+// own log. Parts that cannot be collected are recorded as "<name>.error"
+// files so the bundle is still useful for a crashed or missing pod.
+func (m *Manager) Diagnostics(ctx context.Context, id, sandboxType string) ([]process.DiagnosticFile, error) {
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return nil, err
+	}
+	sandboxName := "agent-sandbox-" + shortID(id)
+
+	var files []process.DiagnosticFile
+	add := func(name string, data []byte, err error) {
+		if err != nil {
+			files = append(files, process.DiagnosticFile{Name: name + ".error", Data: []byte(err.Error())})
+			return
+		}
+		files = append(files, process.DiagnosticFile{Name: name, Data: data})
+	}
+
+	events, err := m.CreateEvents(id)
+	add("events.json", marshalIndent(events), err)
+
+	pods, err := m.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: sandboxNameHashLabel + "=" + nameHash(sandboxName),
+	})
+	if err != nil {
+		add("pods", nil, fmt.Errorf("list sandbox pods: %w", err))
+		return files, nil
+	}
+	if len(pods.Items) == 0 {
+		add("pods", nil, fmt.Errorf("no pods for sandbox %s (paused or not scheduled)", sandboxName))
+		return files, nil
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		dir := "pods/" + pod.Name + "/"
+		pod.ManagedFields = nil
+		add(dir+"pod.json", marshalIndent(redactPodEnv(pod)), nil)
+		add(dir+"describe.txt", []byte(describePod(pod)), nil)
+
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			logs, err := m.containerLogs(ctx, ns, pod.Name, cs.Name, false)
+			add(dir+"logs/"+cs.Name+".log", logs, err)
+			if cs.RestartCount > 0 {
+				logs, err := m.containerLogs(ctx, ns, pod.Name, cs.Name, true)
+				add(dir+"logs/"+cs.Name+".previous.log", logs, err)
+			}
+		}
+
+		usage, err := m.clientset.CoreV1().RESTClient().Get().
+			AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", ns, "pods", pod.Name).
+			DoRaw(ctx)
+		add(dir+"usage.json", usage, err)
+
+		if sandboxType == "opencode" && pod.Status.Phase == corev1.PodRunning {
+			out, err := m.execInPod(ctx, ns, pod.Name, opencodeLogCmd, nil)
+			add(dir+"opencode.log", []byte(out), err)
+		}
+	}
+	return files, nil
+}
+
+// redactPodEnv returns a copy of pod with literal env values blanked; they
+// carry proxy tokens and other credentials.
+func redactPodEnv(pod *corev1.Pod) *corev1.Pod {
+	out := pod.DeepCopy()
+	redact := func(containers []corev1.Container) {
+		for i := range containers {
+			for j := range containers[i].Env {
+				if containers[i].Env[j].Value != "" {
+					containers[i].Env[j].Value = "[redacted]"
+				}
+			}
+		}
+	}
+	redact(out.Spec.InitContainers)
+	redact(out.Spec.Containers)
+	return out
+}
+
+func (m *Manager) containerLogs(ctx context.Context, ns, pod, container string, previous bool) ([]byte, error) {
+	tail := diagnosticsLogLines
+	return m.clientset.CoreV1().Pods(ns).GetLogs(pod, &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  &tail,
+		Previous:   previous,
+		Timestamps: true,
+	}).DoRaw(ctx)
+}
+
+// describePod renders a short, kubectl-describe-like summary of a pod.
+func describePod(pod *corev1.Pod) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Name:       %s\n", pod.Name)
+	fmt.Fprintf(&b, "Namespace:  %s\n", pod.Namespace)
+	fmt.Fprintf(&b, "Node:       %s\n", pod.Spec.NodeName)
+	fmt.Fprintf(&b, "Phase:      %s\n", pod.Status.Phase)
+	fmt.Fprintf(&b, "IP:         %s\n", pod.Status.PodIP)
+	if pod.Status.StartTime != nil {
+		fmt.Fprintf(&b, "Started:    %s\n", pod.Status.StartTime.Format(time.RFC3339))
+	}
+	if pod.Status.Reason != "" {
+		fmt.Fprintf(&b, "Reason:     %s: %s\n", pod.Status.Reason, pod.Status.Message)
+	}
+	b.WriteString("Conditions:\n")
+	for _, c := range pod.Status.Conditions {
+		fmt.Fprintf(&b, "  %-16s %s %s\n", c.Type, c.Status, c.Message)
+	}
+	b.WriteString("Containers:\n")
+	resources := make(map[string]corev1.ResourceRequirements)
+	for _, c := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		resources[c.Name] = c.Resources
+	}
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, cs := range statuses {
+		fmt.Fprintf(&b, "  %s:\n", cs.Name)
+		fmt.Fprintf(&b, "    Image:     %s\n", cs.Image)
+		fmt.Fprintf(&b, "    Ready:     %v\n", cs.Ready)
+		fmt.Fprintf(&b, "    Restarts:  %d\n", cs.RestartCount)
+		fmt.Fprintf(&b, "    State:     %s\n", containerState(cs.State))
+		if cs.LastTerminationState.Terminated != nil {
+			fmt.Fprintf(&b, "    Last:      %s\n", containerState(cs.LastTerminationState))
+		}
+		if r, ok := resources[cs.Name]; ok {
+			fmt.Fprintf(&b, "    Requests:  cpu=%s memory=%s\n", r.Requests.Cpu(), r.Requests.Memory())
+			fmt.Fprintf(&b, "    Limits:    cpu=%s memory=%s\n", r.Limits.Cpu(), r.Limits.Memory())
+		}
+	}
+	return b.String()
+}
+
+func containerState(s corev1.ContainerState) string {
+	switch {
+	case s.Running != nil:
+		return "Running since " + s.Running.StartedAt.Format(time.RFC3339)
+	case s.Waiting != nil:
+		return "Waiting: " + s.Waiting.Reason + " " + s.Waiting.Message
+	case s.Terminated != nil:
+		return fmt.Sprintf("Terminated: %s (exit %d) %s", s.Terminated.Reason, s.Terminated.ExitCode, s.Terminated.Message)
+	}
+	return "Unknown"
+}
+
+func marshalIndent(v interface{}) []byte {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return data
+}
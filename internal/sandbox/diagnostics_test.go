@@ -0,0 +1,53 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedactPodEnv(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Env: []corev1.EnvVar{{Name: "A", Value: "secret"}}}},
+		Containers: []corev1.Container{{Name: "agent", Env: []corev1.EnvVar{
+			{Name: "PROXY_TOKEN", Value: "tok"},
+			{Name: "FROM_SECRET", ValueFrom: &corev1.EnvVarSource{}},
+		}}},
+	}}
+	out := redactPodEnv(pod)
+	if out.Spec.InitContainers[0].Env[0].Value != "[redacted]" || out.Spec.Containers[0].Env[0].Value != "[redacted]" {
+		t.Errorf("env values not redacted: %+v", out.Spec)
+	}
+	if out.Spec.Containers[0].Env[1].Value != "" {
+		t.Errorf("valueFrom entry got a value: %q", out.Spec.Containers[0].Env[1].Value)
+	}
+	if pod.Spec.Containers[0].Env[0].Value != "tok" {
+		t.Error("original pod was modified")
+	}
+}
+
+func TestDescribePod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-sandbox-1234", Namespace: "agent-ws-abcd"},
+		Spec:       corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{Name: "agent"}}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "agent",
+				RestartCount: 3,
+				State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason: "OOMKilled", ExitCode: 137,
+				}},
+			}},
+		},
+	}
+	out := describePod(pod)
+	for _, want := range []string{"node-1", "Restarts:  3", "CrashLoopBackOff", "OOMKilled (exit 137)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("describe output missing %q:\n%s", want, out)
+		}
+	}
+}
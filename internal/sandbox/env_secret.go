@@ -0,0 +1,208 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/process"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Credentials passed to a sandbox at boot (opencode passwords, proxy and
+// gateway tokens, configs embedding API keys) are kept in a per-sandbox
+// Secret and referenced with secretKeyRef, so they don't show up in the
+// Sandbox CR or `kubectl describe pod`. The Secret is created before the
+// Sandbox CR and deleted with it, and its token values are replaced by
+// RotateEnvSecret while the sandbox is paused.
+
+// envSecretName returns the name of the Secret holding a sandbox's
+// sensitive environment variables.
+func envSecretName(sandboxName string) string {
+	return sandboxName + "-env"
+}
+
+// sensitiveEnvSuffixes mark environment variables whose values are secrets.
+var sensitiveEnvSuffixes = []string{"_TOKEN", "_KEY", "_PASSWORD", "_SECRET", "_CONFIG_CONTENT"}
+
+// sensitiveEnv reports whether the variable name carries a secret value.
+func sensitiveEnv(name string) bool {
+	if name == "__OPENCLAW_INJECT_CFG" {
+		return true
+	}
+	for _, s := range sensitiveEnvSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSecretEnv moves the values of sensitive variables in env, and of
+// those in secretNames (workspace secrets), into a Secret data map,
+// replacing them with references to secretName. Variable names are valid
+// Secret keys, so they are used as-is. It returns env unchanged and a nil
+// map when nothing is sensitive.
+func splitSecretEnv(env []corev1.EnvVar, secretName string, secretNames map[string]bool) ([]corev1.EnvVar, map[string][]byte) {
+	var data map[string][]byte
+	out := make([]corev1.EnvVar, 0, len(env))
+	for _, e := range env {
+		if e.ValueFrom != nil || e.Value == "" || !(sensitiveEnv(e.Name) || secretNames[e.Name]) {
+			out = append(out, e)
+			continue
+		}
+		if data == nil {
+			data = make(map[string][]byte)
+		}
+		data[e.Name] = []byte(e.Value)
+		out = append(out, corev1.EnvVar{
+			Name: e.Name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  e.Name,
+				},
+			},
+		})
+	}
+	if data == nil {
+		return env, nil
+	}
+	return out, data
+}
+
+// secretEnv stores the sensitive variables of env, and those named in
+// secretNames, in the sandbox's env Secret and returns env referencing it.
+// The sandbox must not start if the Secret can't be created, since the
+// credentials would otherwise end up in the pod spec.
+func (m *Manager) secretEnv(ctx context.Context, namespace, sandboxName string, env []corev1.EnvVar, secretNames map[string]bool) ([]corev1.EnvVar, error) {
+	name := envSecretName(sandboxName)
+	out, data := splitSecretEnv(env, name, secretNames)
+	if data == nil {
+		return env, nil
+	}
+	// A leftover Secret from an earlier sandbox with the same name would
+	// make the create fail.
+	m.deleteEnvSecret(ctx, namespace, sandboxName)
+	if err := m.createCredentialSecret(ctx, namespace, name, sandboxName, data); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RotateEnvSecret replaces tokens in the env and credential Secrets of a
+// sandbox: every occurrence of a key of replacements is replaced with its
+// value, which also covers the configs embedding a token. The pod reads the
+// Secrets when it starts, so the sandbox must be paused and picks up the new
+// tokens when it is resumed.
+func (m *Manager) RotateEnvSecret(ctx context.Context, id string, replacements map[string]string) error {
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return fmt.Errorf("resolve namespace for token rotation: %w", err)
+	}
+	return m.rotateSecrets(ctx, ns, "agent-sandbox-"+shortID(id), replacements)
+}
+
+// rotateSecrets applies replacements to the env Secret of sandboxName and,
+// if it exists, its credential Secret. A missing env Secret is an error:
+// the sandbox was started with its tokens in the pod spec, where they can't
+// be replaced.
+func (m *Manager) rotateSecrets(ctx context.Context, namespace, sandboxName string, replacements map[string]string) error {
+	secrets := m.clientset.CoreV1().Secrets(namespace)
+	for _, name := range []string{envSecretName(sandboxName), sandboxName + "-creds"} {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) && name != envSecretName(sandboxName) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("get secret %s/%s: %w", namespace, name, err)
+		}
+		if !replaceSecretValues(secret.Data, replacements) {
+			continue
+		}
+		if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update secret %s/%s: %w", namespace, name, err)
+		}
+	}
+	return nil
+}
+
+// replaceSecretValues replaces every occurrence of the non-empty keys of
+// replacements in the values of data with the corresponding value, and
+// reports whether any value changed.
+func replaceSecretValues(data map[string][]byte, replacements map[string]string) bool {
+	changed := false
+	for k, v := range data {
+		nv := v
+		for old, repl := range replacements {
+			if old != "" {
+				nv = bytes.ReplaceAll(nv, []byte(old), []byte(repl))
+			}
+		}
+		if !bytes.Equal(nv, v) {
+			data[k] = nv
+			changed = true
+		}
+	}
+	return changed
+}
+
+// deleteEnvSecret deletes the env Secret of a sandbox if it exists.
+func (m *Manager) deleteEnvSecret(ctx context.Context, namespace, sandboxName string) {
+	name := envSecretName(sandboxName)
+	err := m.clientset.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Printf("delete env secret %s/%s: %v", namespace, name, err)
+	}
+}
+
+// secretFilesName returns the name of the Secret holding a sandbox's
+// workspace secret files.
+func secretFilesName(sandboxName string) string {
+	return sandboxName + "-secrets"
+}
+
+// secretFilesVolume stores files in the sandbox's secret files Secret and
+// returns the read-only volume and mount exposing them in
+// process.SecretFilesDir. ok is false if there are no files or the Secret
+// can't be created; the sandbox then starts without them.
+func (m *Manager) secretFilesVolume(ctx context.Context, namespace, sandboxName string, files map[string]string) (corev1.Volume, corev1.VolumeMount, bool) {
+	if len(files) == 0 {
+		return corev1.Volume{}, corev1.VolumeMount{}, false
+	}
+	name := secretFilesName(sandboxName)
+	data := make(map[string][]byte, len(files))
+	for k, v := range files {
+		data[k] = []byte(v)
+	}
+	m.deleteSecretFiles(ctx, namespace, sandboxName)
+	if err := m.createCredentialSecret(ctx, namespace, name, sandboxName, data); err != nil {
+		log.Printf("warning: create secret files, starting without them: %v", err)
+		return corev1.Volume{}, corev1.VolumeMount{}, false
+	}
+	// The agent runs as a non-root user, so the files must be world
+	// readable; the pod has no other users.
+	mode := int32(0o444)
+	vol := corev1.Volume{
+		Name: "workspace-secrets",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{SecretName: name, DefaultMode: &mode},
+		},
+	}
+	mount := corev1.VolumeMount{Name: "workspace-secrets", MountPath: process.SecretFilesDir, ReadOnly: true}
+	return vol, mount, true
+}
+
+// deleteSecretFiles deletes the secret files Secret of a sandbox if it
+// exists.
+func (m *Manager) deleteSecretFiles(ctx context.Context, namespace, sandboxName string) {
+	name := secretFilesName(sandboxName)
+	err := m.clientset.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Printf("delete secret files %s/%s: %v", namespace, name, err)
+	}
+}
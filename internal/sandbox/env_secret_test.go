@@ -0,0 +1,111 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSplitSecretEnv(t *testing.T) {
+	env := []corev1.EnvVar{
+		{Name: "TERM", Value: "xterm-256color"},
+		{Name: "OPENCODE_SERVER_PASSWORD", Value: "pw"},
+		{Name: "OPENCODE_CONFIG_CONTENT", Value: `{"provider":{}}`},
+		{Name: "ANTHROPIC_BASE_URL", Value: "https://proxy"},
+		{Name: "AGENTSERVER_TOKEN", Value: "tok"},
+		{Name: "JUPYTER_TOKEN", Value: ""},
+		{Name: "__OPENCLAW_INJECT_CFG", Value: "cfg"},
+		{Name: "NPM_REGISTRY_AUTH", Value: "ws-secret"},
+	}
+	out, data := splitSecretEnv(env, "agent-sandbox-x-env", map[string]bool{"NPM_REGISTRY_AUTH": true})
+	if len(out) != len(env) {
+		t.Fatalf("got %d vars, want %d", len(out), len(env))
+	}
+	secret := map[string]bool{"OPENCODE_SERVER_PASSWORD": true, "OPENCODE_CONFIG_CONTENT": true, "AGENTSERVER_TOKEN": true, "__OPENCLAW_INJECT_CFG": true, "NPM_REGISTRY_AUTH": true}
+	for i, e := range out {
+		if !secret[e.Name] {
+			if e != env[i] {
+				t.Errorf("%s changed: %+v", e.Name, e)
+			}
+			continue
+		}
+		if e.Value != "" || e.ValueFrom == nil || e.ValueFrom.SecretKeyRef == nil {
+			t.Errorf("%s not moved to the secret: %+v", e.Name, e)
+			continue
+		}
+		ref := e.ValueFrom.SecretKeyRef
+		if ref.Name != "agent-sandbox-x-env" || ref.Key != e.Name {
+			t.Errorf("%s ref = %s/%s", e.Name, ref.Name, ref.Key)
+		}
+		if string(data[e.Name]) != env[i].Value {
+			t.Errorf("%s secret value = %q", e.Name, data[e.Name])
+		}
+	}
+	if len(data) != len(secret) {
+		t.Errorf("secret keys = %d, want %d", len(data), len(secret))
+	}
+
+	plain := []corev1.EnvVar{{Name: "TERM", Value: "xterm-256color"}}
+	if out, data := splitSecretEnv(plain, "s", nil); data != nil || len(out) != 1 {
+		t.Errorf("plain env split: %v, %v", out, data)
+	}
+}
+
+func TestRotateSecrets(t *testing.T) {
+	secret := func(name string, data map[string]string) *corev1.Secret {
+		s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ws"}, Data: map[string][]byte{}}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+	m := &Manager{clientset: fake.NewSimpleClientset(
+		secret("agent-sandbox-x-env", map[string]string{
+			"AGENTSERVER_TOKEN":        "old-proxy",
+			"OPENCODE_SERVER_PASSWORD": "old-pw",
+			"OPENCODE_CONFIG_CONTENT":  `{"apiKey":"old-proxy"}`,
+			"NPM_REGISTRY_AUTH":        "ws-secret",
+		}),
+		secret("agent-sandbox-x-creds", map[string]string{"kubeconfig": "token: old-proxy"}),
+	)}
+	ctx := context.Background()
+	replacements := map[string]string{"old-proxy": "new-proxy", "old-pw": "new-pw", "": "ignored"}
+	if err := m.rotateSecrets(ctx, "ws", "agent-sandbox-x", replacements); err != nil {
+		t.Fatal(err)
+	}
+	get := func(name string) map[string][]byte {
+		s, err := m.clientset.CoreV1().Secrets("ws").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Data
+	}
+	env := get("agent-sandbox-x-env")
+	for k, want := range map[string]string{
+		"AGENTSERVER_TOKEN":        "new-proxy",
+		"OPENCODE_SERVER_PASSWORD": "new-pw",
+		"OPENCODE_CONFIG_CONTENT":  `{"apiKey":"new-proxy"}`,
+		"NPM_REGISTRY_AUTH":        "ws-secret",
+	} {
+		if got := string(env[k]); got != want {
+			t.Errorf("env secret %s = %q, want %q", k, got, want)
+		}
+	}
+	if got := string(get("agent-sandbox-x-creds")["kubeconfig"]); got != "token: new-proxy" {
+		t.Errorf("credential secret kubeconfig = %q", got)
+	}
+
+	// No credential Secret is fine; no env Secret is not.
+	if err := m.clientset.CoreV1().Secrets("ws").Delete(ctx, "agent-sandbox-x-creds", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.rotateSecrets(ctx, "ws", "agent-sandbox-x", map[string]string{"new-proxy": "newer-proxy"}); err != nil {
+		t.Errorf("rotation without a credential secret: %v", err)
+	}
+	if err := m.rotateSecrets(ctx, "ws", "agent-sandbox-y", replacements); err == nil {
+		t.Error("rotation of a sandbox without an env secret succeeded")
+	}
+}
@@ -0,0 +1,106 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// CreateEvents returns the Kubernetes events for a sandbox's objects (the
+// Sandbox CR, its pod and session PVC) plus the state of its init
+// containers, oldest first. It is polled while the sandbox is starting.
+func (m *Manager) CreateEvents(id string) ([]process.CreateEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return nil, err
+	}
+	sandboxName := "agent-sandbox-" + shortID(id)
+
+	list, err := m.clientset.CoreV1().Events(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	var events []process.CreateEvent
+	for _, ev := range list.Items {
+		if !strings.Contains(ev.InvolvedObject.Name, sandboxName) {
+			continue
+		}
+		t := ev.LastTimestamp.Time
+		if t.IsZero() {
+			t = ev.EventTime.Time
+		}
+		if t.IsZero() {
+			t = ev.CreationTimestamp.Time
+		}
+		source := ev.Source.Component
+		if source == "" {
+			source = ev.ReportingController
+		}
+		events = append(events, process.CreateEvent{
+			Time:    t,
+			Source:  source,
+			Object:  ev.InvolvedObject.Kind + "/" + ev.InvolvedObject.Name,
+			Reason:  ev.Reason,
+			Message: ev.Message,
+			Warning: ev.Type == corev1.EventTypeWarning,
+			Count:   ev.Count,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	pods, err := m.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: sandboxNameHashLabel + "=" + nameHash(sandboxName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list sandbox pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		events = append(events, initContainerEvents(&pod)...)
+	}
+	return events, nil
+}
+
+// initContainerEvents turns init container states into events, so that
+// e.g. a slow chown of a large workspace drive is visible.
+func initContainerEvents(pod *corev1.Pod) []process.CreateEvent {
+	var events []process.CreateEvent
+	for _, cs := range pod.Status.InitContainerStatuses {
+		ev := process.CreateEvent{
+			Time:   time.Now(),
+			Source: "init",
+			Object: "Pod/" + pod.Name,
+		}
+		switch {
+		case cs.State.Waiting != nil:
+			if cs.State.Waiting.Reason == "PodInitializing" {
+				continue
+			}
+			ev.Reason = cs.State.Waiting.Reason
+			ev.Message = fmt.Sprintf("init container %s waiting: %s", cs.Name, cs.State.Waiting.Message)
+			ev.Warning = cs.State.Waiting.Reason != "ContainerCreating"
+		case cs.State.Running != nil:
+			ev.Time = cs.State.Running.StartedAt.Time
+			ev.Reason = "InitRunning"
+			ev.Message = fmt.Sprintf("init container %s running", cs.Name)
+		case cs.State.Terminated != nil:
+			ev.Time = cs.State.Terminated.FinishedAt.Time
+			ev.Reason = "InitTerminated"
+			ev.Message = fmt.Sprintf("init container %s exited with code %d", cs.Name, cs.State.Terminated.ExitCode)
+			ev.Warning = cs.State.Terminated.ExitCode != 0
+		default:
+			continue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
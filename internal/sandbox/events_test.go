@@ -0,0 +1,34 @@
+package sandbox
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInitContainerEvents(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-sandbox-1234abcd"},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{Name: "fix-perms", State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "not found"},
+				}},
+				{Name: "other", State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "PodInitializing"},
+				}},
+			},
+		},
+	}
+	events := initContainerEvents(pod)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if events[0].Reason != "ErrImagePull" || !events[0].Warning {
+		t.Errorf("event = %+v", events[0])
+	}
+	if events[0].Object != "Pod/agent-sandbox-1234abcd" {
+		t.Errorf("object = %q", events[0].Object)
+	}
+}
@@ -102,8 +102,9 @@ func createExecutor(config *rest.Config, clientset kubernetes.Interface, namespa
 	})
 }
 
-// startExec creates an execProcess and runs the remotecommand stream in a goroutine.
-func startExec(config *rest.Config, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (*execProcess, error) {
+// startExec creates an execProcess and runs the remotecommand stream in a
+// goroutine. The stream ends when ctx is cancelled or the process is closed.
+func startExec(ctx context.Context, config *rest.Config, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (*execProcess, error) {
 	executor, err := createExecutor(config, clientset, namespace, podName, containerName, command)
 	if err != nil {
 		return nil, err
@@ -112,7 +113,7 @@ func startExec(config *rest.Config, clientset kubernetes.Interface, namespace, p
 	stdinR, stdinW := io.Pipe()
 	stdoutR, stdoutW := io.Pipe()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 
 	p := &execProcess{
 		stdinR:  stdinR,
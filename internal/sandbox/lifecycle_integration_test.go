@@ -0,0 +1,205 @@
+//go:build integration
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+func TestMain(m *testing.M) { os.Exit(testenv.Run(m)) }
+
+var sandboxGVK = schema.GroupVersionKind{Group: "agents.x-k8s.io", Version: "v1alpha1", Kind: "Sandbox"}
+
+// fakeController stands in for the agent-sandbox controller and the kubelet,
+// which envtest doesn't run: it gives every Sandbox wanting a replica a
+// running pod and Ready condition, and removes the pod of a paused one.
+type fakeController struct {
+	m      *Manager
+	ns     string
+	nextIP atomic.Int32
+}
+
+func (c *fakeController) run(ctx context.Context, t *testing.T) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(200 * time.Millisecond):
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(sandboxGVK.GroupVersion().WithKind("SandboxList"))
+		if err := c.m.k8s.List(ctx, list, client.InNamespace(c.ns)); err != nil {
+			if ctx.Err() == nil {
+				t.Logf("fake controller: list sandboxes: %v", err)
+			}
+			continue
+		}
+		for i := range list.Items {
+			if err := c.reconcile(ctx, &list.Items[i]); err != nil && ctx.Err() == nil {
+				t.Logf("fake controller: %s: %v", list.Items[i].GetName(), err)
+			}
+		}
+	}
+}
+
+func (c *fakeController) reconcile(ctx context.Context, sb *unstructured.Unstructured) error {
+	replicas, found, _ := unstructured.NestedInt64(sb.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	pods := c.m.clientset.CoreV1().Pods(c.ns)
+	name := sb.GetName()
+	pod, err := pods.Get(ctx, name, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	switch {
+	case replicas > 0 && !exists:
+		pod, err = pods.Create(ctx, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{sandboxNameHashLabel: nameHash(name)},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: sandboxContainerName, Image: "agent"}},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.PodIP = fmt.Sprintf("10.0.0.%d", c.nextIP.Add(1))
+		if _, err := pods.UpdateStatus(ctx, pod, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		return c.setReady(ctx, sb, 1)
+	case replicas == 0 && exists:
+		if err := pods.Delete(ctx, name, *metav1.NewDeleteOptions(0)); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		return c.setReady(ctx, sb, 0)
+	}
+	return nil
+}
+
+func (c *fakeController) setReady(ctx context.Context, sb *unstructured.Unstructured, replicas int64) error {
+	status, reason := "True", "DependenciesReady"
+	if replicas == 0 {
+		status, reason = "False", "SandboxPaused"
+	}
+	unstructured.SetNestedField(sb.Object, replicas, "status", "replicas")
+	unstructured.SetNestedSlice(sb.Object, []interface{}{map[string]interface{}{
+		"type":               "Ready",
+		"status":             status,
+		"reason":             reason,
+		"message":            "",
+		"lastTransitionTime": time.Now().UTC().Format(time.RFC3339),
+	}}, "status", "conditions")
+	return c.m.k8s.Status().Update(ctx, sb)
+}
+
+// TestIntegration_SandboxLifecycle drives a K8s sandbox through
+// create → ready → pause → resume → delete against envtest.
+func TestIntegration_SandboxLifecycle(t *testing.T) {
+	d := testenv.DB(t)
+	restCfg := testenv.Kube(t)
+
+	m, err := NewManagerForConfig(Config{Image: "agent", SessionStorageSize: "1Gi"}, d, restCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	ns := "ws-" + strings.ToLower(wsID[:8])
+	if err := d.CreateWorkspace(wsID, "lifecycle"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.SetWorkspaceNamespace(wsID, ns); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateSandbox(sbxID, wsID, "lifecycle", "opencode", "agent-sandbox-"+shortID(sbxID), "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	fc := &fakeController{m: m, ns: ns}
+	go fc.run(ctx, t)
+
+	sandboxName := "agent-sandbox-" + shortID(sbxID)
+	key := client.ObjectKey{Namespace: ns, Name: sandboxName}
+
+	ip, err := m.StartContainerWithIP(sbxID, process.StartOptions{Namespace: ns, SandboxType: "opencode", SandboxID: sbxID, WorkspaceID: wsID})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if ip != "10.0.0.1" {
+		t.Errorf("start returned pod IP %q, want 10.0.0.1", ip)
+	}
+
+	if err := m.Pause(sbxID); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	waitFor(t, "pod to be removed on pause", func() bool {
+		_, err := m.clientset.CoreV1().Pods(ns).Get(ctx, sandboxName, metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	})
+	sb := &unstructured.Unstructured{}
+	sb.SetGroupVersionKind(sandboxGVK)
+	if err := m.k8s.Get(ctx, key, sb); err != nil {
+		t.Fatalf("paused sandbox CR: %v", err)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(sb.Object, "spec", "replicas"); replicas != 0 {
+		t.Errorf("paused sandbox replicas = %d, want 0", replicas)
+	}
+
+	ip, err = m.ResumeContainerWithIP(sbxID)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if ip != "10.0.0.2" {
+		t.Errorf("resume returned pod IP %q, want 10.0.0.2", ip)
+	}
+
+	if err := m.Stop(sbxID); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	waitFor(t, "sandbox CR to be deleted", func() bool {
+		return apierrors.IsNotFound(m.k8s.Get(ctx, key, sb))
+	})
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"hash/fnv"
+	"io"
 	"log"
 	"os"
 	"sync"
@@ -17,7 +18,6 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -32,6 +32,7 @@ import (
 
 	credprovider "github.com/agentserver/agentserver/internal/credentialproxy/provider"
 	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/namespace"
 	"github.com/agentserver/agentserver/internal/process"
 )
 
@@ -41,7 +42,6 @@ const (
 	sandboxNameHashLabel = "agents.x-k8s.io/sandbox-name-hash"
 	sandboxContainerName = "agent"
 	pollInterval         = 2 * time.Second
-	pollTimeout          = 5 * time.Minute
 )
 
 // Compile-time interface check.
@@ -70,7 +70,12 @@ func NewManager(cfg Config, database *db.DB) (*Manager, error) {
 	if err != nil {
 		return nil, fmt.Errorf("k8s config: %w", err)
 	}
+	return NewManagerForConfig(cfg, database, restCfg)
+}
 
+// NewManagerForConfig creates a sandbox Manager talking to the API server of
+// restCfg.
+func NewManagerForConfig(cfg Config, database *db.DB, restCfg *rest.Config) (*Manager, error) {
 	s := runtime.NewScheme()
 	utilruntime.Must(clientgoscheme.AddToScheme(s))
 	utilruntime.Must(sandboxv1alpha1.AddToScheme(s))
@@ -239,6 +244,11 @@ mkdir -p /mnt/session-data/projects
 		vcts[0].Spec.StorageClassName = &m.cfg.StorageClassName
 	}
 
+	containerEnv, err := m.secretEnv(ctx, ns, sandboxName, containerEnv, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create env secret: %w", err)
+	}
+
 	// Create the Sandbox CR.
 	sb := &sandboxv1alpha1.Sandbox{
 		ObjectMeta: metav1.ObjectMeta{
@@ -277,14 +287,23 @@ mkdir -p /mnt/session-data/projects
 		},
 	}
 
+	if m.cfg.Browser.Enabled(opts.SandboxType, opts.Browser) {
+		applyBrowserSidecar(&sb.Spec.PodTemplate.Spec, m.cfg.Browser)
+	}
+	applyNodePool(&sb.Spec.PodTemplate.Spec, opts.NodePool)
+	sb.Spec.PodTemplate.Spec.PriorityClassName = opts.PriorityClassName
+	applySingleAttachAffinity(sb.Spec.PodTemplate.ObjectMeta.Labels, &sb.Spec.PodTemplate.Spec, opts.WorkspaceVolumes)
+
 	if err := m.k8s.Create(ctx, sb); err != nil {
+		m.deleteEnvSecret(ctx, ns, sandboxName)
 		return nil, fmt.Errorf("create sandbox CR: %w", err)
 	}
 
 	// Wait for sandbox to become ready.
-	podName, _, err := m.waitForReady(ctx, ns, sandboxName)
+	podName, _, err := m.waitForReady(ctx, ns, sandboxName, process.ResolveProbe(m.cfg.Probes, opts.SandboxType).StartupTimeout)
 	if err != nil {
 		_ = m.k8s.Delete(ctx, sb)
+		m.deleteEnvSecret(ctx, ns, sandboxName)
 		return nil, fmt.Errorf("sandbox not ready: %w", err)
 	}
 
@@ -292,7 +311,7 @@ mkdir -p /mnt/session-data/projects
 	fullCmd := append([]string{command}, args...)
 
 	// Start remotecommand exec into the pod.
-	proc, err := startExec(m.restCfg, m.clientset, ns, podName, sandboxContainerName, fullCmd)
+	proc, err := startExec(context.Background(), m.restCfg, m.clientset, ns, podName, sandboxContainerName, fullCmd)
 	if err != nil {
 		_ = m.k8s.Delete(ctx, sb)
 		return nil, fmt.Errorf("exec into sandbox: %w", err)
@@ -328,7 +347,7 @@ func (m *Manager) StartContainerWithIP(id string, opts process.StartOptions) (st
 			corev1.EnvVar{Name: "ANTHROPIC_API_KEY", Value: opts.BYOKAPIKey},
 			corev1.EnvVar{Name: "ANTHROPIC_BASE_URL", Value: opts.BYOKBaseURL},
 		)
-	} else if opts.ProxyToken != "" && proxyBaseURL != "" {
+	} else if opts.ProxyToken != "" && proxyBaseURL != "" && process.LLMProviderSelected(opts.LLMProviders, process.LLMProviderAnthropic) {
 		// NanoClaw's agent-runner inherits process.env (not .env file values).
 		// ANTHROPIC_BASE_URL must be a real env var so Claude Code can find the proxy.
 		// Strip /v1 because the Anthropic SDK appends it automatically.
@@ -339,12 +358,32 @@ func (m *Manager) StartContainerWithIP(id string, opts process.StartOptions) (st
 	}
 	// Inject Gemini proxy credentials as real env vars (same reason as Anthropic above).
 	// Skip when BYOK is active — BYOK bypasses the proxy entirely.
-	if m.cfg.GeminiProxyBaseURL != "" && opts.ProxyToken != "" && opts.BYOKBaseURL == "" {
+	if m.cfg.GeminiProxyBaseURL != "" && opts.ProxyToken != "" && opts.BYOKBaseURL == "" && process.LLMProviderSelected(opts.LLMProviders, process.LLMProviderGemini) {
 		containerEnv = append(containerEnv,
 			corev1.EnvVar{Name: "GEMINI_API_KEY", Value: opts.ProxyToken},
 			corev1.EnvVar{Name: "GOOGLE_GEMINI_BASE_URL", Value: m.cfg.GeminiProxyBaseURL},
 		)
 	}
+	// OpenAI and Bedrock go through the LLM proxy's /proxy/{provider} routes
+	// and are only injected when the sandbox selected them.
+	if m.cfg.LLMProxyURL != "" && opts.ProxyToken != "" && opts.BYOKBaseURL == "" {
+		base := strings.TrimSuffix(m.cfg.LLMProxyURL, "/") + "/proxy/"
+		if process.LLMProviderSelected(opts.LLMProviders, process.LLMProviderOpenAI) {
+			containerEnv = append(containerEnv,
+				corev1.EnvVar{Name: "OPENAI_API_KEY", Value: opts.ProxyToken},
+				corev1.EnvVar{Name: "OPENAI_BASE_URL", Value: base + "openai/v1"},
+			)
+		}
+		if process.LLMProviderSelected(opts.LLMProviders, process.LLMProviderBedrock) {
+			// Bedrock API keys are sent as Bearer tokens, which the proxy
+			// accepts; it signs the upstream request itself.
+			containerEnv = append(containerEnv,
+				corev1.EnvVar{Name: "AWS_BEARER_TOKEN_BEDROCK", Value: opts.ProxyToken},
+				corev1.EnvVar{Name: "AWS_ENDPOINT_URL_BEDROCK_RUNTIME", Value: base + "bedrock"},
+				corev1.EnvVar{Name: "ANTHROPIC_BEDROCK_BASE_URL", Value: base + "bedrock"},
+			)
+		}
+	}
 
 	// Select image, port, and command based on sandbox type.
 	sandboxImage := m.cfg.Image
@@ -504,6 +543,13 @@ fs.writeFileSync(path, JSON.stringify(existing, null, 2));
 		}
 		opcodeConfig := BuildOpencodeConfig(m.cfg.OpencodeConfigContent, apiKey, overrideURL)
 		containerEnv = append(containerEnv, corev1.EnvVar{Name: "OPENCODE_CONFIG_CONTENT", Value: opcodeConfig})
+		containerCmd = process.OpencodeCommand(opts.OpencodeWorkers, containerPort)
+	}
+	if opts.Image != "" {
+		sandboxImage = opts.Image
+	}
+	if len(opts.Command) > 0 {
+		containerCmd = opts.Command
 	}
 
 	// Volume mounts for the main container.
@@ -635,6 +681,40 @@ chown -R 1000:1000 /mnt/session-data
 		}
 	}
 
+	// Workspace secrets, then template env vars; the variables set above
+	// win.
+	envSet := make(map[string]bool, len(containerEnv))
+	for _, e := range containerEnv {
+		envSet[e.Name] = true
+	}
+	secretNames := make(map[string]bool, len(opts.SecretEnv))
+	for _, name := range process.EnvNames(opts.SecretEnv) {
+		if !envSet[name] {
+			containerEnv = append(containerEnv, corev1.EnvVar{Name: name, Value: opts.SecretEnv[name]})
+			envSet[name], secretNames[name] = true, true
+		}
+	}
+	for _, name := range process.EnvNames(opts.Env) {
+		if !envSet[name] {
+			containerEnv = append(containerEnv, corev1.EnvVar{Name: name, Value: opts.Env[name]})
+		}
+	}
+	if vol, mount, ok := m.secretFilesVolume(ctx, ns, sandboxName, opts.SecretFiles); ok {
+		volumes = append(volumes, vol)
+		volumeMounts = append(volumeMounts, mount)
+	}
+
+	containerEnv, err := m.secretEnv(ctx, ns, sandboxName, containerEnv, secretNames)
+	if err != nil {
+		if credSecretName != "" {
+			m.deleteCredentialSecret(ctx, ns, sandboxName)
+		}
+		m.deleteSecretFiles(ctx, ns, sandboxName)
+		m.deleteClonedSessionData(ctx, ns, sandboxName)
+		return "", fmt.Errorf("create env secret: %w", err)
+	}
+
+	probe := process.ResolveProbe(m.cfg.Probes, opts.SandboxType)
 	mainContainer := corev1.Container{
 		Name:            sandboxContainerName,
 		Image:           sandboxImage,
@@ -646,16 +726,7 @@ chown -R 1000:1000 /mnt/session-data
 			ContainerPort: int32(containerPort),
 			Protocol:      corev1.ProtocolTCP,
 		}},
-		ReadinessProbe: &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				TCPSocket: &corev1.TCPSocketAction{
-					Port: intstr.FromInt32(int32(containerPort)),
-				},
-			},
-			InitialDelaySeconds: 2,
-			PeriodSeconds:       2,
-			FailureThreshold:    30,
-		},
+		ReadinessProbe: readinessProbe(probe, containerPort),
 		Resources: corev1.ResourceRequirements{
 			Limits: corev1.ResourceList{
 				corev1.ResourceMemory: memoryQuantity(opts.Memory),
@@ -663,22 +734,15 @@ chown -R 1000:1000 /mnt/session-data
 			},
 		},
 	}
-	if opts.SandboxType == "nanoclaw" {
-		mainContainer.ReadinessProbe = &corev1.Probe{
-			ProbeHandler: corev1.ProbeHandler{
-				HTTPGet: &corev1.HTTPGetAction{
-					Path: "/health",
-					Port: intstr.FromInt32(int32(containerPort)),
-				},
-			},
-			InitialDelaySeconds: 5,
-			PeriodSeconds:       5,
-			FailureThreshold:    30,
-		}
-	}
 	if len(containerCmd) > 0 {
 		mainContainer.Command = containerCmd
 	}
+	for _, wk := range opts.OpencodeWorkers {
+		mainContainer.Ports = append(mainContainer.Ports, corev1.ContainerPort{
+			ContainerPort: int32(wk.Port),
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
 
 	sb := &sandboxv1alpha1.Sandbox{
 		ObjectMeta: metav1.ObjectMeta{
@@ -703,13 +767,39 @@ chown -R 1000:1000 /mnt/session-data
 		},
 	}
 
+	// Route name resolution through the workspace DNS filter when the
+	// workspace has a DNS allowlist. The label keeps the pod out of the
+	// policy that lets older sandboxes use cluster DNS.
+	if opts.DNSNameserver != "" {
+		sb.Spec.PodTemplate.ObjectMeta.Labels[namespace.LabelDNSFiltered] = "true"
+		sb.Spec.PodTemplate.Spec.DNSPolicy = corev1.DNSNone
+		sb.Spec.PodTemplate.Spec.DNSConfig = &corev1.PodDNSConfig{
+			Nameservers: []string{opts.DNSNameserver},
+			Searches:    []string{ns + ".svc.cluster.local", "svc.cluster.local", "cluster.local"},
+			Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: strPtr("5")}},
+		}
+	}
+
+	if m.cfg.Browser.Enabled(opts.SandboxType, opts.Browser) {
+		applyBrowserSidecar(&sb.Spec.PodTemplate.Spec, m.cfg.Browser)
+	}
+	applyNodePool(&sb.Spec.PodTemplate.Spec, opts.NodePool)
+	sb.Spec.PodTemplate.Spec.PriorityClassName = opts.PriorityClassName
+	applySingleAttachAffinity(sb.Spec.PodTemplate.ObjectMeta.Labels, &sb.Spec.PodTemplate.Spec, opts.WorkspaceVolumes)
+
 	if err := m.k8s.Create(ctx, sb); err != nil {
+		m.deleteEnvSecret(ctx, ns, sandboxName)
+		m.deleteSecretFiles(ctx, ns, sandboxName)
+		m.deleteClonedSessionData(ctx, ns, sandboxName)
 		return "", fmt.Errorf("create sandbox CR: %w", err)
 	}
 
-	_, podIP, err := m.waitForReady(ctx, ns, sandboxName)
+	_, podIP, err := m.waitForReady(ctx, ns, sandboxName, probe.StartupTimeout)
 	if err != nil {
 		_ = m.k8s.Delete(ctx, sb)
+		m.deleteEnvSecret(ctx, ns, sandboxName)
+		m.deleteSecretFiles(ctx, ns, sandboxName)
+		m.deleteClonedSessionData(ctx, ns, sandboxName)
 		return "", fmt.Errorf("sandbox not ready: %w", err)
 	}
 
@@ -747,7 +837,7 @@ func (m *Manager) ResumeContainerWithIP(id string) (string, error) {
 	}
 
 	// Wait for pod to be ready.
-	_, podIP, err := m.waitForReady(ctx, ns, sandboxName)
+	_, podIP, err := m.waitForReady(ctx, ns, sandboxName, m.startupTimeout(id))
 	if err != nil {
 		return "", fmt.Errorf("sandbox not ready after resume: %w", err)
 	}
@@ -821,14 +911,14 @@ func (m *Manager) Resume(id, sandboxName, command string, args []string) (proces
 	}
 
 	// Wait for pod to be ready.
-	podName, _, err := m.waitForReady(ctx, ns, sandboxName)
+	podName, _, err := m.waitForReady(ctx, ns, sandboxName, m.startupTimeout(id))
 	if err != nil {
 		return nil, fmt.Errorf("sandbox not ready after resume: %w", err)
 	}
 
 	// Start remotecommand exec.
 	fullCmd := append([]string{command}, args...)
-	proc, err := startExec(m.restCfg, m.clientset, ns, podName, sandboxContainerName, fullCmd)
+	proc, err := startExec(context.Background(), m.restCfg, m.clientset, ns, podName, sandboxContainerName, fullCmd)
 	if err != nil {
 		return nil, fmt.Errorf("exec into resumed sandbox: %w", err)
 	}
@@ -841,8 +931,9 @@ func (m *Manager) Resume(id, sandboxName, command string, args []string) (proces
 }
 
 // waitForReady polls until the Sandbox has Ready=True and returns the backing pod name and IP.
-func (m *Manager) waitForReady(ctx context.Context, namespace, sandboxName string) (podName string, podIP string, err error) {
-	deadline := time.Now().Add(pollTimeout)
+// It fails early with process.ErrUnschedulable if the pod stays unschedulable.
+func (m *Manager) waitForReady(ctx context.Context, namespace, sandboxName string, timeout time.Duration) (podName string, podIP string, err error) {
+	deadline := time.Now().Add(timeout)
 	nameHash := nameHash(sandboxName)
 
 	for time.Now().Before(deadline) {
@@ -853,18 +944,21 @@ func (m *Manager) waitForReady(ctx context.Context, namespace, sandboxName strin
 			continue
 		}
 
-		if isSandboxReady(&sb) {
-			podList, err := m.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-				LabelSelector: sandboxNameHashLabel + "=" + nameHash,
-			})
-			if err != nil {
-				time.Sleep(pollInterval)
-				continue
+		ready := isSandboxReady(&sb)
+		podList, err := m.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: sandboxNameHashLabel + "=" + nameHash,
+		})
+		if err != nil {
+			time.Sleep(pollInterval)
+			continue
+		}
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			if ready && pod.Status.Phase == corev1.PodRunning {
+				return pod.Name, pod.Status.PodIP, nil
 			}
-			for _, pod := range podList.Items {
-				if pod.Status.Phase == corev1.PodRunning {
-					return pod.Name, pod.Status.PodIP, nil
-				}
+			if msg := unschedulableMessage(pod, time.Now()); msg != "" {
+				return "", "", fmt.Errorf("%w: %s", process.ErrUnschedulable, msg)
 			}
 		}
 		time.Sleep(pollInterval)
@@ -915,6 +1009,26 @@ func memoryQuantity(bytes int64) resource.Quantity {
 	return *resource.NewQuantity(bytes, resource.BinarySI)
 }
 
+// ImageForType returns the image a sandbox of the given type runs, or ""
+// when that type has no image configured.
+func (m *Manager) ImageForType(sandboxType string) string {
+	switch sandboxType {
+	case "openclaw":
+		if m.cfg.OpenclawImage != "" {
+			return m.cfg.OpenclawImage
+		}
+		return m.cfg.Image
+	case "claudecode":
+		return m.cfg.ClaudeCodeImage
+	case "nanoclaw":
+		return m.cfg.NanoclawImage
+	case "jupyter":
+		return m.cfg.JupyterImage
+	default:
+		return m.cfg.Image
+	}
+}
+
 func (m *Manager) runtimeClassName() *string {
 	if m.cfg.RuntimeClassName == "" {
 		return nil
@@ -1020,8 +1134,11 @@ func (m *Manager) Stop(id string) error {
 		log.Printf("failed to delete sandbox %s: %v", sandboxName, err)
 	}
 
-	// Clean up credential Secret (if any).
+	// Clean up credential Secrets (if any).
 	m.deleteCredentialSecret(ctx, ns, sandboxName)
+	m.deleteEnvSecret(ctx, ns, sandboxName)
+	m.deleteSecretFiles(ctx, ns, sandboxName)
+	m.deleteClonedSessionData(ctx, ns, sandboxName)
 
 	return nil
 }
@@ -1037,24 +1154,59 @@ func (m *Manager) StopBySandboxName(namespace, sandboxName string) error {
 			Namespace: namespace,
 		},
 	}
-	return m.k8s.Delete(ctx, sb)
+	if err := m.k8s.Delete(ctx, sb); err != nil {
+		return err
+	}
+	m.deleteEnvSecret(ctx, namespace, sandboxName)
+	m.deleteSecretFiles(ctx, namespace, sandboxName)
+	m.deleteClonedSessionData(ctx, namespace, sandboxName)
+	return nil
 }
 
 // ExecSimple runs a command in a sandbox pod and returns its stdout.
 // It is a one-shot exec (no stdin/TTY) intended for short-lived commands
 // like writing config files or restarting a gateway.
 func (m *Manager) ExecSimple(ctx context.Context, sandboxID string, command []string) (string, error) {
+	return m.ExecInput(ctx, sandboxID, command, nil)
+}
+
+// ExecInput is ExecSimple with stdin streamed to the command; a nil stdin
+// attaches none.
+func (m *Manager) ExecInput(ctx context.Context, sandboxID string, command []string, stdin io.Reader) (string, error) {
 	// Resolve pod namespace and name.
 	ns, err := m.lookupNamespace(sandboxID)
 	if err != nil {
 		return "", err
 	}
 	sandboxName := "agent-sandbox-" + shortID(sandboxID)
-	podName, _, err := m.waitForReady(ctx, ns, sandboxName)
+	podName, _, err := m.waitForReady(ctx, ns, sandboxName, process.DefaultStartupTimeout)
 	if err != nil {
 		return "", fmt.Errorf("pod not ready: %w", err)
 	}
+	return m.execInPod(ctx, ns, podName, command, stdin)
+}
+
+// CopyFromSandbox reads a regular file out of a sandbox pod (exec cat).
+func (m *Manager) CopyFromSandbox(ctx context.Context, sandboxID, filePath string) ([]byte, error) {
+	out, err := m.ExecInput(ctx, sandboxID, []string{"sh", "-c", `[ -f "$1" ] && exec cat -- "$1"`, "sh", filePath}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// CopyToSandbox writes a file into a sandbox pod from the exec's stdin,
+// through a temporary file renamed into place. The parent directory must
+// exist.
+func (m *Manager) CopyToSandbox(ctx context.Context, sandboxID, filePath string, data []byte) error {
+	const script = `t="$1.upload-$$"; cat > "$t" && mv -f -- "$t" "$1" || { rm -f -- "$t"; exit 1; }`
+	_, err := m.ExecInput(ctx, sandboxID, []string{"sh", "-c", script, "sh", filePath}, bytes.NewReader(data))
+	return err
+}
 
+// execInPod runs a one-shot command in the agent container of a pod and
+// returns its stdout.
+func (m *Manager) execInPod(ctx context.Context, ns, podName string, command []string, stdin io.Reader) (string, error) {
 	req := m.clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
@@ -1063,6 +1215,7 @@ func (m *Manager) ExecSimple(ctx context.Context, sandboxID string, command []st
 		VersionedParams(&corev1.PodExecOptions{
 			Container: sandboxContainerName,
 			Command:   command,
+			Stdin:     stdin != nil,
 			Stdout:    true,
 			Stderr:    true,
 		}, scheme.ParameterCodec)
@@ -1082,6 +1235,7 @@ func (m *Manager) ExecSimple(ctx context.Context, sandboxID string, command []st
 
 	var stdout, stderr bytes.Buffer
 	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
 		Stdout: &stdout,
 		Stderr: &stderr,
 	}); err != nil {
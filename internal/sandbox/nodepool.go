@@ -0,0 +1,65 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	sandboxv1alpha1 "sigs.k8s.io/agent-sandbox/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// applyNodePool restricts a sandbox pod to the workspace's dedicated node
+// pool. Selector labels are merged over any existing selector, and the pool's
+// tolerations are added so the pod can land on tainted dedicated nodes.
+func applyNodePool(spec *corev1.PodSpec, pool *process.NodePool) {
+	if pool == nil {
+		return
+	}
+	if len(pool.NodeSelector) > 0 {
+		if spec.NodeSelector == nil {
+			spec.NodeSelector = make(map[string]string, len(pool.NodeSelector))
+		}
+		for k, v := range pool.NodeSelector {
+			spec.NodeSelector[k] = v
+		}
+	}
+	for _, t := range pool.Tolerations {
+		op := corev1.TolerationOpEqual
+		if t.Operator == "Exists" {
+			op = corev1.TolerationOpExists
+		}
+		spec.Tolerations = append(spec.Tolerations, corev1.Toleration{
+			Key:      t.Key,
+			Operator: op,
+			Value:    t.Value,
+			Effect:   corev1.TaintEffect(t.Effect),
+		})
+	}
+}
+
+// SetNodePool moves paused sandbox id to another node pool: the node
+// selector and tolerations of its pod template are replaced by pool's (nil
+// schedules anywhere). The pod is placed accordingly when the sandbox is
+// resumed.
+func (m *Manager) SetNodePool(ctx context.Context, id string, pool *process.NodePool) error {
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return err
+	}
+	var sb sandboxv1alpha1.Sandbox
+	key := client.ObjectKey{Namespace: ns, Name: "agent-sandbox-" + shortID(id)}
+	if err := m.k8s.Get(ctx, key, &sb); err != nil {
+		return fmt.Errorf("get sandbox CR: %w", err)
+	}
+	spec := &sb.Spec.PodTemplate.Spec
+	spec.NodeSelector = nil
+	spec.Tolerations = nil
+	applyNodePool(spec, pool)
+	if err := m.k8s.Update(ctx, &sb); err != nil {
+		return fmt.Errorf("update sandbox CR node pool: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package sandbox
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// readinessProbe converts a probe config into a pod readiness probe against
+// the container's main port (unless the config names another port).
+func readinessProbe(pc process.ProbeConfig, containerPort int) *corev1.Probe {
+	port := containerPort
+	if pc.Port != 0 {
+		port = pc.Port
+	}
+	p := &corev1.Probe{
+		InitialDelaySeconds: int32(pc.InitialDelay.Seconds()),
+		PeriodSeconds:       int32(pc.Period.Seconds()),
+		FailureThreshold:    int32(pc.FailureThreshold),
+	}
+	if pc.Type == process.ProbeHTTP {
+		p.HTTPGet = &corev1.HTTPGetAction{Path: pc.Path, Port: intstr.FromInt32(int32(port))}
+	} else {
+		p.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt32(int32(port))}
+	}
+	return p
+}
+
+// startupTimeout returns how long to wait for an existing sandbox (e.g. on
+// resume) to become ready, based on its type.
+func (m *Manager) startupTimeout(id string) time.Duration {
+	var sandboxType string
+	if m.db != nil {
+		if sbx, err := m.db.GetSandbox(id); err == nil && sbx != nil {
+			sandboxType = sbx.Type
+		}
+	}
+	return process.ResolveProbe(m.cfg.Probes, sandboxType).StartupTimeout
+}
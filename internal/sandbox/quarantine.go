@@ -0,0 +1,137 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	sandboxv1alpha1 "sigs.k8s.io/agent-sandbox/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/agentserver/agentserver/internal/namespace"
+)
+
+func quarantinePolicyName(sandboxName string) string {
+	return "agentserver-quarantine-" + sandboxName
+}
+
+// quarantinePolicy denies all ingress and egress of the sandbox's pods.
+func quarantinePolicy(ns, sandboxName string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      quarantinePolicyName(sandboxName),
+			Namespace: ns,
+			Labels:    map[string]string{labelManagedBy: labelValue},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{sandboxNameHashLabel: nameHash(sandboxName)},
+			},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+		},
+	}
+}
+
+// quarantineLabelPatches returns the merge patches that set (or remove) the
+// quarantine label on a pod and on the pod template of a Sandbox.
+func quarantineLabelPatches(quarantined bool) (pod, sandbox []byte) {
+	var value interface{} // null removes the label
+	if quarantined {
+		value = "true"
+	}
+	labels := map[string]interface{}{
+		"labels": map[string]interface{}{namespace.LabelQuarantined: value},
+	}
+	pod, _ = json.Marshal(map[string]interface{}{"metadata": labels})
+	sandbox, _ = json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"podTemplate": map[string]interface{}{"metadata": labels}},
+	})
+	return pod, sandbox
+}
+
+// QuarantineContainer isolates a sandbox pod from the network without
+// touching its volumes: a deny-all (ingress + egress) NetworkPolicy is
+// applied to the pod, and the pod is labelled so the regular sandbox egress
+// policy no longer selects it (NetworkPolicies are additive, so the deny-all
+// would otherwise be overridden). The label also goes on the Sandbox's pod
+// template, so a pod recreated after an eviction stays isolated.
+func (m *Manager) QuarantineContainer(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return err
+	}
+	sandboxName := "agent-sandbox-" + shortID(id)
+
+	_, err = m.clientset.NetworkingV1().NetworkPolicies(ns).Create(ctx, quarantinePolicy(ns, sandboxName), metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("create quarantine policy: %w", err)
+	}
+	return m.setQuarantineLabel(ctx, ns, sandboxName, true)
+}
+
+// ReleaseContainer reverses QuarantineContainer.
+func (m *Manager) ReleaseContainer(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return err
+	}
+	sandboxName := "agent-sandbox-" + shortID(id)
+
+	if err := m.setQuarantineLabel(ctx, ns, sandboxName, false); err != nil {
+		return err
+	}
+	err = m.clientset.NetworkingV1().NetworkPolicies(ns).Delete(ctx, quarantinePolicyName(sandboxName), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete quarantine policy: %w", err)
+	}
+	return nil
+}
+
+// setQuarantineLabel sets or removes the quarantine label on the Sandbox's
+// pod template, then on its current pods. The template goes first, so a
+// pod recreated in between is labelled too.
+func (m *Manager) setQuarantineLabel(ctx context.Context, ns, sandboxName string, quarantined bool) error {
+	podPatch, sandboxPatch := quarantineLabelPatches(quarantined)
+	sb := &sandboxv1alpha1.Sandbox{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sandboxName,
+			Namespace: ns,
+		},
+	}
+	// Without a Sandbox there is no pod to (re)create.
+	if err := m.k8s.Patch(ctx, sb, client.RawPatch(types.MergePatchType, sandboxPatch)); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("label sandbox pod template: %w", err)
+	}
+	return m.patchSandboxPodLabel(ctx, ns, sandboxName, podPatch)
+}
+
+// patchSandboxPodLabel applies a merge patch to every pod of the sandbox.
+// A paused sandbox has no pods, which is not an error.
+func (m *Manager) patchSandboxPodLabel(ctx context.Context, ns, sandboxName string, patch []byte) error {
+	pods, err := m.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: sandboxNameHashLabel + "=" + nameHash(sandboxName),
+	})
+	if err != nil {
+		return fmt.Errorf("list sandbox pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if _, err := m.clientset.CoreV1().Pods(ns).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("label pod %s: %w", pod.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	"github.com/agentserver/agentserver/internal/namespace"
+)
+
+func TestQuarantinePolicy(t *testing.T) {
+	np := quarantinePolicy("ws-ns", "agent-sandbox-1234abcd")
+	if np.Name != "agentserver-quarantine-agent-sandbox-1234abcd" || np.Namespace != "ws-ns" {
+		t.Errorf("policy %s/%s", np.Namespace, np.Name)
+	}
+	if got := np.Spec.PodSelector.MatchLabels[sandboxNameHashLabel]; got != nameHash("agent-sandbox-1234abcd") || len(np.Spec.PodSelector.MatchLabels) != 1 {
+		t.Errorf("pod selector = %v", np.Spec.PodSelector.MatchLabels)
+	}
+	// Both directions are listed without rules, which denies all traffic.
+	if len(np.Spec.PolicyTypes) != 2 || np.Spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress || np.Spec.PolicyTypes[1] != networkingv1.PolicyTypeEgress {
+		t.Errorf("policy types = %v", np.Spec.PolicyTypes)
+	}
+	if len(np.Spec.Ingress) != 0 || len(np.Spec.Egress) != 0 {
+		t.Errorf("policy allows traffic: %+v", np.Spec)
+	}
+}
+
+func TestQuarantineLabelPatches(t *testing.T) {
+	pod, sandbox := quarantineLabelPatches(true)
+	var p struct {
+		Metadata struct {
+			Labels map[string]*string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(pod, &p); err != nil {
+		t.Fatal(err)
+	}
+	if v := p.Metadata.Labels[namespace.LabelQuarantined]; v == nil || *v != "true" {
+		t.Errorf("pod patch = %s", pod)
+	}
+	var sb struct {
+		Spec struct {
+			PodTemplate struct {
+				Metadata struct {
+					Labels map[string]*string `json:"labels"`
+				} `json:"metadata"`
+			} `json:"podTemplate"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(sandbox, &sb); err != nil {
+		t.Fatal(err)
+	}
+	if v := sb.Spec.PodTemplate.Metadata.Labels[namespace.LabelQuarantined]; v == nil || *v != "true" {
+		t.Errorf("sandbox patch = %s", sandbox)
+	}
+
+	// A merge patch with null deletes the label and leaves the others.
+	pod, sandbox = quarantineLabelPatches(false)
+	if want := `{"metadata":{"labels":{"` + namespace.LabelQuarantined + `":null}}}`; string(pod) != want {
+		t.Errorf("release pod patch = %s, want %s", pod, want)
+	}
+	if want := `{"spec":{"podTemplate":{"metadata":{"labels":{"` + namespace.LabelQuarantined + `":null}}}}}`; string(sandbox) != want {
+		t.Errorf("release sandbox patch = %s, want %s", sandbox, want)
+	}
+}
@@ -0,0 +1,228 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Session-data snapshots are CSI VolumeSnapshots of the sandbox's
+// session-data PVC, taken in the workspace namespace with the cluster's
+// default VolumeSnapshotClass. Restoring recreates the PVC from the
+// snapshot, so the sandbox must be paused. Clones are CSI volume clones:
+// the new sandbox's PVC is created from the source's PVC before the
+// sandbox, and the controller uses it instead of provisioning an empty one.
+
+const pvcDeleteTimeout = 2 * time.Minute
+
+// clonedFromAnnotation marks a session-data PVC cloned from another
+// sandbox's. The controller didn't create it, so it isn't garbage
+// collected with the sandbox.
+const clonedFromAnnotation = "agentserver/cloned-from"
+
+// sessionDataPVC returns the name of the PVC the agent-sandbox controller
+// creates from a sandbox's session-data volume claim template.
+func sessionDataPVC(sandboxName string) string {
+	return "session-data-" + sandboxName
+}
+
+func snapshotName(sandboxName, snapshotID string) string {
+	return sandboxName + "-snap-" + shortID(snapshotID)
+}
+
+// SnapshotSessionData creates a VolumeSnapshot of the session-data PVC of
+// sandbox id and returns its name.
+func (m *Manager) SnapshotSessionData(ctx context.Context, id, snapshotID string) (string, error) {
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return "", err
+	}
+	sandboxName := "agent-sandbox-" + shortID(id)
+	name := snapshotName(sandboxName, snapshotID)
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": ns,
+			"labels": map[string]string{
+				labelManagedBy: labelValue,
+				"sandbox-id":   id,
+			},
+		},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{"persistentVolumeClaimName": sessionDataPVC(sandboxName)},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal volume snapshot: %w", err)
+	}
+	err = m.clientset.Discovery().RESTClient().Post().
+		AbsPath("/apis/snapshot.storage.k8s.io/v1/namespaces", ns, "volumesnapshots").
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do(ctx).
+		Error()
+	if err != nil {
+		return "", fmt.Errorf("create volume snapshot %s: %w", name, err)
+	}
+	log.Printf("sandbox %s: created volume snapshot %s", id, name)
+	return name, nil
+}
+
+// RestoreSessionData replaces the session-data PVC of paused sandbox id with
+// a new PVC provisioned from VolumeSnapshot ref. The new PVC keeps the old
+// one's spec, labels and owner references, so the controller adopts it on
+// resume and deletes it with the sandbox.
+func (m *Manager) RestoreSessionData(ctx context.Context, id, ref string) error {
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return err
+	}
+	pvcName := sessionDataPVC("agent-sandbox-" + shortID(id))
+	pvcs := m.clientset.CoreV1().PersistentVolumeClaims(ns)
+	old, err := pvcs.Get(ctx, pvcName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get PVC %s: %w", pvcName, err)
+	}
+	if err := pvcs.Delete(ctx, pvcName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete PVC %s: %w", pvcName, err)
+	}
+	// The PVC lingers until the pod of the paused sandbox is gone.
+	deadline := time.Now().Add(pvcDeleteTimeout)
+	for {
+		_, err := pvcs.Get(ctx, pvcName, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("PVC %s not deleted after %s", pvcName, pvcDeleteTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	apiGroup := "snapshot.storage.k8s.io"
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            pvcName,
+			Namespace:       ns,
+			Labels:          old.Labels,
+			Annotations:     map[string]string{"agentserver/restored-from": ref},
+			OwnerReferences: old.OwnerReferences,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      old.Spec.AccessModes,
+			StorageClassName: old.Spec.StorageClassName,
+			VolumeMode:       old.Spec.VolumeMode,
+			Resources:        old.Spec.Resources,
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     ref,
+			},
+		},
+	}
+	if _, err := pvcs.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("recreate PVC %s from snapshot %s: %w", pvcName, ref, err)
+	}
+	log.Printf("sandbox %s: restored PVC %s from volume snapshot %s", id, pvcName, ref)
+	return nil
+}
+
+// DeleteSessionDataSnapshot deletes VolumeSnapshot ref of sandbox id. A
+// snapshot that is already gone is not an error.
+func (m *Manager) DeleteSessionDataSnapshot(ctx context.Context, id, ref string) error {
+	ns, err := m.lookupNamespace(id)
+	if err != nil {
+		return err
+	}
+	err = m.clientset.Discovery().RESTClient().Delete().
+		AbsPath("/apis/snapshot.storage.k8s.io/v1/namespaces", ns, "volumesnapshots", ref).
+		Do(ctx).
+		Error()
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete volume snapshot %s: %w", ref, err)
+	}
+	return nil
+}
+
+// CloneSessionData creates the session-data PVC of sandbox dstID, which
+// must not have started yet, as a clone of that of sandbox srcID in the
+// same workspace. The clone has the source's storage class and size.
+func (m *Manager) CloneSessionData(ctx context.Context, srcID, dstID string) error {
+	ns, err := m.lookupNamespace(srcID)
+	if err != nil {
+		return err
+	}
+	srcName := sessionDataPVC("agent-sandbox-" + shortID(srcID))
+	pvcs := m.clientset.CoreV1().PersistentVolumeClaims(ns)
+	src, err := pvcs.Get(ctx, srcName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get PVC %s: %w", srcName, err)
+	}
+	name := sessionDataPVC("agent-sandbox-" + shortID(dstID))
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				labelManagedBy: labelValue,
+				"sandbox-id":   dstID,
+			},
+			Annotations: map[string]string{clonedFromAnnotation: srcID},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      src.Spec.AccessModes,
+			StorageClassName: src.Spec.StorageClassName,
+			VolumeMode:       src.Spec.VolumeMode,
+			Resources:        src.Spec.Resources,
+			DataSource: &corev1.TypedLocalObjectReference{
+				Kind: "PersistentVolumeClaim",
+				Name: srcName,
+			},
+		},
+	}
+	if _, err := pvcs.Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
+		if errors.IsAlreadyExists(err) {
+			// A retried start: the clone of the first attempt is kept
+			// unless the failed start deleted it.
+			if old, err := pvcs.Get(ctx, name, metav1.GetOptions{}); err == nil && old.DeletionTimestamp != nil {
+				return fmt.Errorf("PVC %s of the previous attempt is still being deleted", name)
+			}
+			return nil
+		}
+		return fmt.Errorf("clone PVC %s to %s: %w", srcName, name, err)
+	}
+	log.Printf("sandbox %s: cloned PVC %s from %s", dstID, name, srcName)
+	return nil
+}
+
+// deleteClonedSessionData deletes the session-data PVC of a deleted
+// sandbox if it was cloned and the controller didn't adopt it.
+func (m *Manager) deleteClonedSessionData(ctx context.Context, ns, sandboxName string) {
+	pvcs := m.clientset.CoreV1().PersistentVolumeClaims(ns)
+	name := sessionDataPVC(sandboxName)
+	pvc, err := pvcs.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Printf("failed to get PVC %s: %v", name, err)
+		}
+		return
+	}
+	if _, cloned := pvc.Annotations[clonedFromAnnotation]; !cloned || len(pvc.OwnerReferences) > 0 {
+		return
+	}
+	if err := pvcs.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		log.Printf("failed to delete cloned PVC %s: %v", name, err)
+	}
+}
@@ -0,0 +1,25 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// OpenTerminal starts an interactive command with a TTY in the agent
+// container of a running sandbox, e.g. a shell for the web terminal. It is
+// independent of the sandbox's session process and ends when ctx is
+// cancelled.
+func (m *Manager) OpenTerminal(ctx context.Context, sandboxID string, command []string) (process.Process, error) {
+	ns, err := m.lookupNamespace(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	sandboxName := "agent-sandbox-" + shortID(sandboxID)
+	podName, _, err := m.waitForReady(ctx, ns, sandboxName, process.DefaultStartupTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("pod not ready: %w", err)
+	}
+	return startExec(ctx, m.restCfg, m.clientset, ns, podName, sandboxContainerName, command)
+}
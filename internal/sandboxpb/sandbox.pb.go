@@ -0,0 +1,825 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v7.34.1
+// source: internal/sandboxpb/sandbox.proto
+
+// Sandbox lifecycle API for platform integrations. It mirrors the REST
+// endpoints under /api/workspaces/{wid}/sandboxes and /api/sandboxes/{id}
+// and applies the same permission checks; authenticate by sending the
+// session token as "authorization: Bearer <token>" metadata.
+
+package sandboxpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SandboxEvent_Type int32
+
+const (
+	SandboxEvent_TYPE_UNSPECIFIED SandboxEvent_Type = 0
+	SandboxEvent_ADDED            SandboxEvent_Type = 1
+	SandboxEvent_MODIFIED         SandboxEvent_Type = 2
+	SandboxEvent_DELETED          SandboxEvent_Type = 3
+)
+
+// Enum value maps for SandboxEvent_Type.
+var (
+	SandboxEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "ADDED",
+		2: "MODIFIED",
+		3: "DELETED",
+	}
+	SandboxEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"ADDED":            1,
+		"MODIFIED":         2,
+		"DELETED":          3,
+	}
+)
+
+func (x SandboxEvent_Type) Enum() *SandboxEvent_Type {
+	p := new(SandboxEvent_Type)
+	*p = x
+	return p
+}
+
+func (x SandboxEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SandboxEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_sandboxpb_sandbox_proto_enumTypes[0].Descriptor()
+}
+
+func (SandboxEvent_Type) Type() protoreflect.EnumType {
+	return &file_internal_sandboxpb_sandbox_proto_enumTypes[0]
+}
+
+func (x SandboxEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SandboxEvent_Type.Descriptor instead.
+func (SandboxEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{7, 0}
+}
+
+type Sandbox struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ShortId       string                 `protobuf:"bytes,2,opt,name=short_id,json=shortId,proto3" json:"short_id,omitempty"`
+	WorkspaceId   string                 `protobuf:"bytes,3,opt,name=workspace_id,json=workspaceId,proto3" json:"workspace_id,omitempty"`
+	Name          string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	Status        string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	StatusMessage string                 `protobuf:"bytes,7,opt,name=status_message,json=statusMessage,proto3" json:"status_message,omitempty"`
+	// Browser URL of the sandbox UI, when it has one.
+	Url            string `protobuf:"bytes,8,opt,name=url,proto3" json:"url,omitempty"`
+	IsLocal        bool   `protobuf:"varint,9,opt,name=is_local,json=isLocal,proto3" json:"is_local,omitempty"`
+	Cpu            int32  `protobuf:"varint,10,opt,name=cpu,proto3" json:"cpu,omitempty"`                                              // millicores
+	Memory         int64  `protobuf:"varint,11,opt,name=memory,proto3" json:"memory,omitempty"`                                        // bytes
+	CreatedAt      string `protobuf:"bytes,12,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`                  // RFC 3339
+	LastActivityAt string `protobuf:"bytes,13,opt,name=last_activity_at,json=lastActivityAt,proto3" json:"last_activity_at,omitempty"` // RFC 3339, empty if never active
+	PausedAt       string `protobuf:"bytes,14,opt,name=paused_at,json=pausedAt,proto3" json:"paused_at,omitempty"`                     // RFC 3339, empty unless paused
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Sandbox) Reset() {
+	*x = Sandbox{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sandbox) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sandbox) ProtoMessage() {}
+
+func (x *Sandbox) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sandbox.ProtoReflect.Descriptor instead.
+func (*Sandbox) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sandbox) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Sandbox) GetShortId() string {
+	if x != nil {
+		return x.ShortId
+	}
+	return ""
+}
+
+func (x *Sandbox) GetWorkspaceId() string {
+	if x != nil {
+		return x.WorkspaceId
+	}
+	return ""
+}
+
+func (x *Sandbox) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Sandbox) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Sandbox) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Sandbox) GetStatusMessage() string {
+	if x != nil {
+		return x.StatusMessage
+	}
+	return ""
+}
+
+func (x *Sandbox) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Sandbox) GetIsLocal() bool {
+	if x != nil {
+		return x.IsLocal
+	}
+	return false
+}
+
+func (x *Sandbox) GetCpu() int32 {
+	if x != nil {
+		return x.Cpu
+	}
+	return 0
+}
+
+func (x *Sandbox) GetMemory() int64 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *Sandbox) GetCreatedAt() string {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return ""
+}
+
+func (x *Sandbox) GetLastActivityAt() string {
+	if x != nil {
+		return x.LastActivityAt
+	}
+	return ""
+}
+
+func (x *Sandbox) GetPausedAt() string {
+	if x != nil {
+		return x.PausedAt
+	}
+	return ""
+}
+
+type SandboxRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxRequest) Reset() {
+	*x = SandboxRequest{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxRequest) ProtoMessage() {}
+
+func (x *SandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxRequest.ProtoReflect.Descriptor instead.
+func (*SandboxRequest) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SandboxRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type ListSandboxesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkspaceId   string                 `protobuf:"bytes,1,opt,name=workspace_id,json=workspaceId,proto3" json:"workspace_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSandboxesRequest) Reset() {
+	*x = ListSandboxesRequest{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSandboxesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSandboxesRequest) ProtoMessage() {}
+
+func (x *ListSandboxesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSandboxesRequest.ProtoReflect.Descriptor instead.
+func (*ListSandboxesRequest) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListSandboxesRequest) GetWorkspaceId() string {
+	if x != nil {
+		return x.WorkspaceId
+	}
+	return ""
+}
+
+type ListSandboxesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sandboxes     []*Sandbox             `protobuf:"bytes,1,rep,name=sandboxes,proto3" json:"sandboxes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSandboxesResponse) Reset() {
+	*x = ListSandboxesResponse{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSandboxesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSandboxesResponse) ProtoMessage() {}
+
+func (x *ListSandboxesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSandboxesResponse.ProtoReflect.Descriptor instead.
+func (*ListSandboxesResponse) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListSandboxesResponse) GetSandboxes() []*Sandbox {
+	if x != nil {
+		return x.Sandboxes
+	}
+	return nil
+}
+
+type CreateSandboxRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	WorkspaceId string                 `protobuf:"bytes,1,opt,name=workspace_id,json=workspaceId,proto3" json:"workspace_id,omitempty"`
+	Name        string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// opencode (default), openclaw, nanoclaw, claudecode or jupyter.
+	Type string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	// Resource overrides; 0 uses the workspace default.
+	Cpu           int32 `protobuf:"varint,4,opt,name=cpu,proto3" json:"cpu,omitempty"`
+	Memory        int64 `protobuf:"varint,5,opt,name=memory,proto3" json:"memory,omitempty"`
+	Browser       bool  `protobuf:"varint,6,opt,name=browser,proto3" json:"browser,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSandboxRequest) Reset() {
+	*x = CreateSandboxRequest{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSandboxRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSandboxRequest) ProtoMessage() {}
+
+func (x *CreateSandboxRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSandboxRequest.ProtoReflect.Descriptor instead.
+func (*CreateSandboxRequest) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreateSandboxRequest) GetWorkspaceId() string {
+	if x != nil {
+		return x.WorkspaceId
+	}
+	return ""
+}
+
+func (x *CreateSandboxRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateSandboxRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CreateSandboxRequest) GetCpu() int32 {
+	if x != nil {
+		return x.Cpu
+	}
+	return 0
+}
+
+func (x *CreateSandboxRequest) GetMemory() int64 {
+	if x != nil {
+		return x.Memory
+	}
+	return 0
+}
+
+func (x *CreateSandboxRequest) GetBrowser() bool {
+	if x != nil {
+		return x.Browser
+	}
+	return false
+}
+
+type DeleteSandboxResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSandboxResponse) Reset() {
+	*x = DeleteSandboxResponse{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSandboxResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSandboxResponse) ProtoMessage() {}
+
+func (x *DeleteSandboxResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSandboxResponse.ProtoReflect.Descriptor instead.
+func (*DeleteSandboxResponse) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{5}
+}
+
+type WatchSandboxesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	WorkspaceId   string                 `protobuf:"bytes,1,opt,name=workspace_id,json=workspaceId,proto3" json:"workspace_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchSandboxesRequest) Reset() {
+	*x = WatchSandboxesRequest{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchSandboxesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchSandboxesRequest) ProtoMessage() {}
+
+func (x *WatchSandboxesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchSandboxesRequest.ProtoReflect.Descriptor instead.
+func (*WatchSandboxesRequest) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchSandboxesRequest) GetWorkspaceId() string {
+	if x != nil {
+		return x.WorkspaceId
+	}
+	return ""
+}
+
+type SandboxEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          SandboxEvent_Type      `protobuf:"varint,1,opt,name=type,proto3,enum=agentserver.sandbox.v1.SandboxEvent_Type" json:"type,omitempty"`
+	Sandbox       *Sandbox               `protobuf:"bytes,2,opt,name=sandbox,proto3" json:"sandbox,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SandboxEvent) Reset() {
+	*x = SandboxEvent{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SandboxEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SandboxEvent) ProtoMessage() {}
+
+func (x *SandboxEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SandboxEvent.ProtoReflect.Descriptor instead.
+func (*SandboxEvent) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SandboxEvent) GetType() SandboxEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return SandboxEvent_TYPE_UNSPECIFIED
+}
+
+func (x *SandboxEvent) GetSandbox() *Sandbox {
+	if x != nil {
+		return x.Sandbox
+	}
+	return nil
+}
+
+type ExecRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Id      string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Command []string               `protobuf:"bytes,2,rep,name=command,proto3" json:"command,omitempty"`
+	// Seconds before the command is cancelled; 0 means 60, at most 600.
+	TimeoutSeconds int32 `protobuf:"varint,3,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ExecRequest) Reset() {
+	*x = ExecRequest{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecRequest) ProtoMessage() {}
+
+func (x *ExecRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecRequest.ProtoReflect.Descriptor instead.
+func (*ExecRequest) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ExecRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ExecRequest) GetCommand() []string {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *ExecRequest) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+type ExecResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Output        []byte                 `protobuf:"bytes,1,opt,name=output,proto3" json:"output,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExecResponse) Reset() {
+	*x = ExecResponse{}
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExecResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExecResponse) ProtoMessage() {}
+
+func (x *ExecResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_sandboxpb_sandbox_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExecResponse.ProtoReflect.Descriptor instead.
+func (*ExecResponse) Descriptor() ([]byte, []int) {
+	return file_internal_sandboxpb_sandbox_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExecResponse) GetOutput() []byte {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+var File_internal_sandboxpb_sandbox_proto protoreflect.FileDescriptor
+
+const file_internal_sandboxpb_sandbox_proto_rawDesc = "" +
+	"\n" +
+	" internal/sandboxpb/sandbox.proto\x12\x16agentserver.sandbox.v1\"\xfb\x02\n" +
+	"\aSandbox\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\bshort_id\x18\x02 \x01(\tR\ashortId\x12!\n" +
+	"\fworkspace_id\x18\x03 \x01(\tR\vworkspaceId\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x05 \x01(\tR\x04type\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\x12%\n" +
+	"\x0estatus_message\x18\a \x01(\tR\rstatusMessage\x12\x10\n" +
+	"\x03url\x18\b \x01(\tR\x03url\x12\x19\n" +
+	"\bis_local\x18\t \x01(\bR\aisLocal\x12\x10\n" +
+	"\x03cpu\x18\n" +
+	" \x01(\x05R\x03cpu\x12\x16\n" +
+	"\x06memory\x18\v \x01(\x03R\x06memory\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\f \x01(\tR\tcreatedAt\x12(\n" +
+	"\x10last_activity_at\x18\r \x01(\tR\x0elastActivityAt\x12\x1b\n" +
+	"\tpaused_at\x18\x0e \x01(\tR\bpausedAt\" \n" +
+	"\x0eSandboxRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"9\n" +
+	"\x14ListSandboxesRequest\x12!\n" +
+	"\fworkspace_id\x18\x01 \x01(\tR\vworkspaceId\"V\n" +
+	"\x15ListSandboxesResponse\x12=\n" +
+	"\tsandboxes\x18\x01 \x03(\v2\x1f.agentserver.sandbox.v1.SandboxR\tsandboxes\"\xa5\x01\n" +
+	"\x14CreateSandboxRequest\x12!\n" +
+	"\fworkspace_id\x18\x01 \x01(\tR\vworkspaceId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x10\n" +
+	"\x03cpu\x18\x04 \x01(\x05R\x03cpu\x12\x16\n" +
+	"\x06memory\x18\x05 \x01(\x03R\x06memory\x12\x18\n" +
+	"\abrowser\x18\x06 \x01(\bR\abrowser\"\x17\n" +
+	"\x15DeleteSandboxResponse\":\n" +
+	"\x15WatchSandboxesRequest\x12!\n" +
+	"\fworkspace_id\x18\x01 \x01(\tR\vworkspaceId\"\xcc\x01\n" +
+	"\fSandboxEvent\x12=\n" +
+	"\x04type\x18\x01 \x01(\x0e2).agentserver.sandbox.v1.SandboxEvent.TypeR\x04type\x129\n" +
+	"\asandbox\x18\x02 \x01(\v2\x1f.agentserver.sandbox.v1.SandboxR\asandbox\"B\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\t\n" +
+	"\x05ADDED\x10\x01\x12\f\n" +
+	"\bMODIFIED\x10\x02\x12\v\n" +
+	"\aDELETED\x10\x03\"`\n" +
+	"\vExecRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\acommand\x18\x02 \x03(\tR\acommand\x12'\n" +
+	"\x0ftimeout_seconds\x18\x03 \x01(\x05R\x0etimeoutSeconds\"&\n" +
+	"\fExecResponse\x12\x16\n" +
+	"\x06output\x18\x01 \x01(\fR\x06output2\x8c\x06\n" +
+	"\x0eSandboxService\x12l\n" +
+	"\rListSandboxes\x12,.agentserver.sandbox.v1.ListSandboxesRequest\x1a-.agentserver.sandbox.v1.ListSandboxesResponse\x12U\n" +
+	"\n" +
+	"GetSandbox\x12&.agentserver.sandbox.v1.SandboxRequest\x1a\x1f.agentserver.sandbox.v1.Sandbox\x12^\n" +
+	"\rCreateSandbox\x12,.agentserver.sandbox.v1.CreateSandboxRequest\x1a\x1f.agentserver.sandbox.v1.Sandbox\x12f\n" +
+	"\rDeleteSandbox\x12&.agentserver.sandbox.v1.SandboxRequest\x1a-.agentserver.sandbox.v1.DeleteSandboxResponse\x12W\n" +
+	"\fPauseSandbox\x12&.agentserver.sandbox.v1.SandboxRequest\x1a\x1f.agentserver.sandbox.v1.Sandbox\x12X\n" +
+	"\rResumeSandbox\x12&.agentserver.sandbox.v1.SandboxRequest\x1a\x1f.agentserver.sandbox.v1.Sandbox\x12g\n" +
+	"\x0eWatchSandboxes\x12-.agentserver.sandbox.v1.WatchSandboxesRequest\x1a$.agentserver.sandbox.v1.SandboxEvent0\x01\x12Q\n" +
+	"\x04Exec\x12#.agentserver.sandbox.v1.ExecRequest\x1a$.agentserver.sandbox.v1.ExecResponseBAZ?github.com/agentserver/agentserver/internal/sandboxpb;sandboxpbb\x06proto3"
+
+var (
+	file_internal_sandboxpb_sandbox_proto_rawDescOnce sync.Once
+	file_internal_sandboxpb_sandbox_proto_rawDescData []byte
+)
+
+func file_internal_sandboxpb_sandbox_proto_rawDescGZIP() []byte {
+	file_internal_sandboxpb_sandbox_proto_rawDescOnce.Do(func() {
+		file_internal_sandboxpb_sandbox_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_sandboxpb_sandbox_proto_rawDesc), len(file_internal_sandboxpb_sandbox_proto_rawDesc)))
+	})
+	return file_internal_sandboxpb_sandbox_proto_rawDescData
+}
+
+var file_internal_sandboxpb_sandbox_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_internal_sandboxpb_sandbox_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_internal_sandboxpb_sandbox_proto_goTypes = []any{
+	(SandboxEvent_Type)(0),        // 0: agentserver.sandbox.v1.SandboxEvent.Type
+	(*Sandbox)(nil),               // 1: agentserver.sandbox.v1.Sandbox
+	(*SandboxRequest)(nil),        // 2: agentserver.sandbox.v1.SandboxRequest
+	(*ListSandboxesRequest)(nil),  // 3: agentserver.sandbox.v1.ListSandboxesRequest
+	(*ListSandboxesResponse)(nil), // 4: agentserver.sandbox.v1.ListSandboxesResponse
+	(*CreateSandboxRequest)(nil),  // 5: agentserver.sandbox.v1.CreateSandboxRequest
+	(*DeleteSandboxResponse)(nil), // 6: agentserver.sandbox.v1.DeleteSandboxResponse
+	(*WatchSandboxesRequest)(nil), // 7: agentserver.sandbox.v1.WatchSandboxesRequest
+	(*SandboxEvent)(nil),          // 8: agentserver.sandbox.v1.SandboxEvent
+	(*ExecRequest)(nil),           // 9: agentserver.sandbox.v1.ExecRequest
+	(*ExecResponse)(nil),          // 10: agentserver.sandbox.v1.ExecResponse
+}
+var file_internal_sandboxpb_sandbox_proto_depIdxs = []int32{
+	1,  // 0: agentserver.sandbox.v1.ListSandboxesResponse.sandboxes:type_name -> agentserver.sandbox.v1.Sandbox
+	0,  // 1: agentserver.sandbox.v1.SandboxEvent.type:type_name -> agentserver.sandbox.v1.SandboxEvent.Type
+	1,  // 2: agentserver.sandbox.v1.SandboxEvent.sandbox:type_name -> agentserver.sandbox.v1.Sandbox
+	3,  // 3: agentserver.sandbox.v1.SandboxService.ListSandboxes:input_type -> agentserver.sandbox.v1.ListSandboxesRequest
+	2,  // 4: agentserver.sandbox.v1.SandboxService.GetSandbox:input_type -> agentserver.sandbox.v1.SandboxRequest
+	5,  // 5: agentserver.sandbox.v1.SandboxService.CreateSandbox:input_type -> agentserver.sandbox.v1.CreateSandboxRequest
+	2,  // 6: agentserver.sandbox.v1.SandboxService.DeleteSandbox:input_type -> agentserver.sandbox.v1.SandboxRequest
+	2,  // 7: agentserver.sandbox.v1.SandboxService.PauseSandbox:input_type -> agentserver.sandbox.v1.SandboxRequest
+	2,  // 8: agentserver.sandbox.v1.SandboxService.ResumeSandbox:input_type -> agentserver.sandbox.v1.SandboxRequest
+	7,  // 9: agentserver.sandbox.v1.SandboxService.WatchSandboxes:input_type -> agentserver.sandbox.v1.WatchSandboxesRequest
+	9,  // 10: agentserver.sandbox.v1.SandboxService.Exec:input_type -> agentserver.sandbox.v1.ExecRequest
+	4,  // 11: agentserver.sandbox.v1.SandboxService.ListSandboxes:output_type -> agentserver.sandbox.v1.ListSandboxesResponse
+	1,  // 12: agentserver.sandbox.v1.SandboxService.GetSandbox:output_type -> agentserver.sandbox.v1.Sandbox
+	1,  // 13: agentserver.sandbox.v1.SandboxService.CreateSandbox:output_type -> agentserver.sandbox.v1.Sandbox
+	6,  // 14: agentserver.sandbox.v1.SandboxService.DeleteSandbox:output_type -> agentserver.sandbox.v1.DeleteSandboxResponse
+	1,  // 15: agentserver.sandbox.v1.SandboxService.PauseSandbox:output_type -> agentserver.sandbox.v1.Sandbox
+	1,  // 16: agentserver.sandbox.v1.SandboxService.ResumeSandbox:output_type -> agentserver.sandbox.v1.Sandbox
+	8,  // 17: agentserver.sandbox.v1.SandboxService.WatchSandboxes:output_type -> agentserver.sandbox.v1.SandboxEvent
+	10, // 18: agentserver.sandbox.v1.SandboxService.Exec:output_type -> agentserver.sandbox.v1.ExecResponse
+	11, // [11:19] is the sub-list for method output_type
+	3,  // [3:11] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_internal_sandboxpb_sandbox_proto_init() }
+func file_internal_sandboxpb_sandbox_proto_init() {
+	if File_internal_sandboxpb_sandbox_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_sandboxpb_sandbox_proto_rawDesc), len(file_internal_sandboxpb_sandbox_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_internal_sandboxpb_sandbox_proto_goTypes,
+		DependencyIndexes: file_internal_sandboxpb_sandbox_proto_depIdxs,
+		EnumInfos:         file_internal_sandboxpb_sandbox_proto_enumTypes,
+		MessageInfos:      file_internal_sandboxpb_sandbox_proto_msgTypes,
+	}.Build()
+	File_internal_sandboxpb_sandbox_proto = out.File
+	file_internal_sandboxpb_sandbox_proto_goTypes = nil
+	file_internal_sandboxpb_sandbox_proto_depIdxs = nil
+}
@@ -0,0 +1,63 @@
+package sandboxproxy
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io/fs"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// assetRefRe extracts the src or href of a <script> or <link> tag.
+var assetRefRe = regexp.MustCompile(`\b(?:src|href)="([^"]+)"`)
+
+// assetIntegrity returns the Subresource Integrity value ("sha384-...") for
+// the embedded asset a <script> or <link> tag refers to, or "" if the tag
+// does not point at an embedded file on the asset domain or this origin.
+func (s *Server) assetIntegrity(tag []byte) string {
+	m := assetRefRe.FindSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	u, err := url.Parse(string(m[1]))
+	if err != nil || (u.Host != "" && u.Host != s.OpencodeAssetDomain) {
+		return ""
+	}
+	name := strings.TrimPrefix(path.Clean(u.Path), "/")
+	data, err := fs.ReadFile(s.OpencodeStaticFS, name)
+	if err != nil {
+		return ""
+	}
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// isSandboxOrigin reports whether an Origin header belongs to a host under
+// one of the base domains, i.e. a sandbox subdomain that may load assets.
+func (s *Server) isSandboxOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	for _, d := range s.hostDomains() {
+		d = domainHost(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// assetDomainSameSite reports whether the asset domain is under one of the
+// base domains, so sandbox pages loading from it are same-site.
+func (s *Server) assetDomainSameSite(assetDomain string) bool {
+	for _, d := range s.BaseDomains {
+		if strings.HasSuffix(domainHost(assetDomain), "."+domainHost(d)) {
+			return true
+		}
+	}
+	return false
+}
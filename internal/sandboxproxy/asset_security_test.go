@@ -0,0 +1,75 @@
+package sandboxproxy
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInitOpencodeAssetIndexAddsIntegrity(t *testing.T) {
+	js := []byte("console.log(1)")
+	sum := sha512.Sum384(js)
+	want := `integrity="sha384-` + base64.StdEncoding.EncodeToString(sum[:]) + `"`
+
+	s := &Server{
+		OpencodeAssetDomain: "opencodeapp.example.com",
+		OpencodeStaticFS: fstest.MapFS{
+			"index.html": {Data: []byte(`<html><head>` +
+				`<script type="module" src="https://opencodeapp.example.com/assets/index-abc.js"></script>` +
+				`<link rel="stylesheet" href="/assets/index-abc.css">` +
+				`<script src="https://cdn.example.org/x.js"></script>` +
+				`</head></html>`)},
+			"assets/index-abc.js":  {Data: js},
+			"assets/index-abc.css": {Data: []byte("body{}")},
+		},
+	}
+	s.initOpencodeAssetIndex()
+
+	data, err := fs.ReadFile(s.OpencodeStaticFS, "index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	if !strings.Contains(html, `index-abc.js" crossorigin="anonymous" `+want+`>`) {
+		t.Errorf("script tag not patched:\n%s", html)
+	}
+	if strings.Count(html, "integrity=") != 2 {
+		t.Errorf("expected integrity on the two embedded assets only:\n%s", html)
+	}
+	if strings.Count(html, `crossorigin="anonymous"`) != 3 {
+		t.Errorf("expected crossorigin on all three tags:\n%s", html)
+	}
+}
+
+func TestAssetCORSHeaders(t *testing.T) {
+	s := &Server{BaseDomains: []string{"example.com"}, OpencodeAssetDomain: "opencodeapp.example.com"}
+
+	r := httptest.NewRequest("GET", "/assets/x.js", nil)
+	r.Header.Set("Origin", "https://code-abc.example.com")
+	w := httptest.NewRecorder()
+	s.setAssetCORSHeaders(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://code-abc.example.com" {
+		t.Errorf("sandbox origin: ACAO = %q", got)
+	}
+	if got := w.Header().Get("Cross-Origin-Resource-Policy"); got != "same-site" {
+		t.Errorf("CORP = %q", got)
+	}
+
+	r.Header.Set("Origin", "https://evil.example.org")
+	w = httptest.NewRecorder()
+	s.setAssetCORSHeaders(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("foreign origin: ACAO = %q", got)
+	}
+
+	r.Header.Set("Origin", "https://notexample.com")
+	w = httptest.NewRecorder()
+	s.setAssetCORSHeaders(w, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("suffix-lookalike origin: ACAO = %q", got)
+	}
+}
@@ -0,0 +1,155 @@
+package sandboxproxy
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// authCheckPath is the auth_request / ForwardAuth endpoint. It is served on
+// hosts outside the base domains, i.e. on the proxy's internal address.
+const authCheckPath = "/auth-check"
+
+// authCheckTokenHeader carries AuthCheckToken. The forwarded headers
+// (host, URI, method) are only trusted from a caller that knows it.
+const authCheckTokenHeader = "X-Auth-Check-Token"
+
+// appCookieKeys are the per-host auth cookies of the sandbox apps.
+var appCookieKeys = map[string]string{
+	appOpencode:   subdomainCookieKey,
+	appOpenclaw:   clawCookieKey,
+	appClaudeCode: claudecodeCookieKey,
+	appJupyter:    jupyterCookieKey,
+	appPort:       portCookieKey,
+}
+
+// handleAuthCheck lets an edge proxy that terminates TLS and routes sandbox
+// hosts itself (nginx auth_request, Traefik ForwardAuth) delegate the
+// authorization of each request. The original host comes from
+// X-Forwarded-Host, the path and method from X-Forwarded-Uri /
+// X-Original-URI and X-Forwarded-Method / X-Original-Method, and the
+// subdomain cookie from the forwarded Cookie header.
+//
+// It answers 200 with the user, sandbox and upstream in X-Auth-* headers,
+// 401 with X-Auth-Login when there is no valid cookie, and 403 otherwise;
+// nginx treats any other status as an error. The apps' credentials are
+// never returned: the edge proxy has its own, or leaves those apps to the
+// sandbox proxy.
+func (s *Server) handleAuthCheck(w http.ResponseWriter, r *http.Request) {
+	if s.AuthCheckToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.Header.Get(authCheckTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.AuthCheckToken)) != 1 {
+		http.Error(w, "invalid auth check token", http.StatusForbidden)
+		return
+	}
+	host := forwardedHeader(r, "X-Forwarded-Host")
+	if host == "" {
+		http.Error(w, "missing X-Forwarded-Host", http.StatusBadRequest)
+		return
+	}
+	rt := newHostRouter(s.hostDomains(), s.routing()).route(host)
+	if rt.kind != hostSandbox {
+		http.Error(w, "not a sandbox host", http.StatusForbidden)
+		return
+	}
+
+	cookie, err := r.Cookie(appCookieKeys[rt.app])
+	if err != nil {
+		w.Header().Set("X-Auth-Login", "https://"+rt.domain+"/")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, ok := s.Auth.ValidateToken(cookie.Value)
+	if !ok {
+		w.Header().Set("X-Auth-Login", "https://"+rt.domain+"/")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sbx, found := s.Sandboxes.Resolve(rt.sandboxID)
+	if !found {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
+	if err != nil || !isMember {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox quarantined", http.StatusForbidden)
+		return
+	}
+	if rt.app == appPort {
+		exposed, err := s.DB.IsSandboxPortExposed(sbx.ID, rt.port)
+		if err != nil {
+			log.Printf("auth check: failed to check port %d of sandbox %s: %v", rt.port, sbx.ID, err)
+		}
+		if !exposed {
+			http.Error(w, "port not exposed", http.StatusForbidden)
+			return
+		}
+	}
+	fr := forwardedRequest(r)
+	if rt.app == appOpencode && s.proxyPathDenied(fr, sbx.WorkspaceID, userID) {
+		http.Error(w, "forbidden for your workspace role", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("X-Auth-User", userID)
+	w.Header().Set("X-Auth-Sandbox", sbx.ID)
+	w.Header().Set("X-Auth-Workspace", sbx.WorkspaceID)
+	if !sbx.IsLocal && sbx.PodIP != "" && sbx.Status == "running" {
+		var port string
+		switch rt.app {
+		case appOpencode:
+			port = opencodeTargetPort(sbx, fr)
+		case appOpenclaw:
+			port = openclawPort
+		case appClaudeCode:
+			port = claudecodePort
+		case appJupyter:
+			port = jupyterPort
+		case appPort:
+			port = strconv.Itoa(rt.port)
+		}
+		w.Header().Set("X-Auth-Upstream", net.JoinHostPort(sbx.PodIP, port))
+	}
+	s.throttledActivity(sbx.ID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// forwardedHeader returns the first value of a forwarded header, which
+// proxies may have appended to.
+func forwardedHeader(r *http.Request, key string) string {
+	v, _, _ := strings.Cut(r.Header.Get(key), ",")
+	return strings.TrimSpace(v)
+}
+
+// forwardedRequest returns r with the method and URI of the original
+// request an auth check is made for.
+func forwardedRequest(r *http.Request) *http.Request {
+	fr := r.Clone(r.Context())
+	if m := forwardedHeader(r, "X-Forwarded-Method"); m != "" {
+		fr.Method = m
+	} else if m := r.Header.Get("X-Original-Method"); m != "" {
+		fr.Method = m
+	}
+	uri := r.Header.Get("X-Forwarded-Uri")
+	if uri == "" {
+		uri = r.Header.Get("X-Original-URI")
+	}
+	if u, err := url.ParseRequestURI(uri); err == nil {
+		fr.URL = u
+	} else {
+		fr.URL = &url.URL{Path: "/"}
+	}
+	return fr
+}
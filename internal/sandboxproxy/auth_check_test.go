@@ -0,0 +1,87 @@
+package sandboxproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthCheckRequiresToken(t *testing.T) {
+	for _, tt := range []struct {
+		configured, sent string
+		want             int
+	}{
+		{"", "", http.StatusNotFound},
+		{"", "anything", http.StatusNotFound},
+		{"s3cret", "", http.StatusForbidden},
+		{"s3cret", "wrong", http.StatusForbidden},
+	} {
+		s := &Server{BaseDomains: []string{"example.com"}, OpencodeSubdomainPrefix: "code", AuthCheckToken: tt.configured}
+		req := httptest.NewRequest(http.MethodGet, "http://sandbox-proxy:8082"+authCheckPath, nil)
+		req.Header.Set("X-Forwarded-Host", "code-abc123.example.com")
+		req.AddCookie(&http.Cookie{Name: subdomainCookieKey, Value: "session"})
+		if tt.sent != "" {
+			req.Header.Set(authCheckTokenHeader, tt.sent)
+		}
+		rec := httptest.NewRecorder()
+		s.Router().ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("token %q, sent %q: %d, want %d", tt.configured, tt.sent, rec.Code, tt.want)
+		}
+		for k := range rec.Header() {
+			if strings.HasPrefix(k, "X-Auth-") {
+				t.Errorf("token %q, sent %q: unauthenticated caller got %s", tt.configured, tt.sent, k)
+			}
+		}
+	}
+}
+
+func TestAuthCheckRejectsBeforeLookup(t *testing.T) {
+	s := &Server{BaseDomains: []string{"example.com"}, OpencodeSubdomainPrefix: "code", AuthCheckToken: "s3cret"}
+	h := s.Router()
+	tests := []struct {
+		forwardedHost string
+		want          int
+		login         string
+	}{
+		{"", http.StatusBadRequest, ""},
+		{"example.com", http.StatusForbidden, ""},
+		{"www.example.com", http.StatusForbidden, ""},
+		{"code-abc123.example.com", http.StatusUnauthorized, "https://example.com/"},
+		{"port-3000-abc123.example.com, proxy.internal", http.StatusUnauthorized, "https://example.com/"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://sandbox-proxy:8082"+authCheckPath, nil)
+		req.Header.Set(authCheckTokenHeader, "s3cret")
+		if tt.forwardedHost != "" {
+			req.Header.Set("X-Forwarded-Host", tt.forwardedHost)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want || rec.Header().Get("X-Auth-Login") != tt.login {
+			t.Errorf("X-Forwarded-Host %q: %d login=%q, want %d login=%q",
+				tt.forwardedHost, rec.Code, rec.Header().Get("X-Auth-Login"), tt.want, tt.login)
+		}
+	}
+}
+
+func TestForwardedRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, authCheckPath, nil)
+	r.Header.Set("X-Forwarded-Method", "POST")
+	r.Header.Set("X-Forwarded-Uri", "/file/content?path=a")
+	fr := forwardedRequest(r)
+	if fr.Method != "POST" || fr.URL.Path != "/file/content" || fr.URL.Query().Get("path") != "a" {
+		t.Errorf("Traefik headers: %s %s", fr.Method, fr.URL)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, authCheckPath, nil)
+	r.Header.Set("X-Original-Method", "DELETE")
+	r.Header.Set("X-Original-URI", "/session/1")
+	if fr := forwardedRequest(r); fr.Method != "DELETE" || fr.URL.Path != "/session/1" {
+		t.Errorf("nginx headers: %s %s", fr.Method, fr.URL)
+	}
+	if fr := forwardedRequest(httptest.NewRequest(http.MethodGet, authCheckPath, nil)); fr.URL.Path != "/" {
+		t.Errorf("no forwarded URI: %s", fr.URL)
+	}
+}
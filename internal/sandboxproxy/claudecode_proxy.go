@@ -4,11 +4,13 @@ import (
 	"context"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"time"
 
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
 	"github.com/agentserver/agentserver/internal/sbxstore"
 	"github.com/agentserver/agentserver/internal/tunnel"
 	"nhooyr.io/websocket"
@@ -41,12 +43,12 @@ func (s *Server) handleClaudeCodeSubdomainProxy(w http.ResponseWriter, r *http.R
 		}
 		sbx, found := s.Sandboxes.Resolve(sandboxID)
 		if !found {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 		if err != nil || !isMember {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		http.SetCookie(w, &http.Cookie{
@@ -54,7 +56,7 @@ func (s *Server) handleClaudeCodeSubdomainProxy(w http.ResponseWriter, r *http.R
 			Value:    token,
 			Path:     "/",
 			HttpOnly: true,
-			Secure:   true,
+			Secure:   auth.SecureCookies(),
 			SameSite: http.SameSiteLaxMode,
 			MaxAge:   int((7 * 24 * time.Hour).Seconds()),
 		})
@@ -78,21 +80,26 @@ func (s *Server) handleClaudeCodeSubdomainProxy(w http.ResponseWriter, r *http.R
 
 	sbx, found := s.Sandboxes.Resolve(sandboxID)
 	if !found {
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 	if err != nil || !isMember {
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 
+	if sbx.QuarantinedAt != nil {
+		s.writeErrorPage(w, r, errPageSandboxQuarantined, sbx)
+		return
+	}
 	if sbx.Status != "running" {
-		writeErrorPage(w, errPageSandboxNotRunning)
+		s.writeErrorPage(w, r, notRunningPage(sbx), sbx)
 		return
 	}
 
 	s.throttledActivity(sbx.ID)
+	s.recordUsage(sbx, userID, db.SandboxActivityUser)
 
 	// Cloud sandbox: reverse proxy to ttyd on pod IP.
 	if sbx.PodIP != "" {
@@ -117,11 +124,9 @@ func (s *Server) handleClaudeCodeSubdomainProxy(w http.ResponseWriter, r *http.R
 
 		target := &url.URL{
 			Scheme: "http",
-			Host:   sbx.PodIP + ":" + claudecodePort,
+			Host:   net.JoinHostPort(sbx.PodIP, claudecodePort),
 		}
-		proxy := httputil.NewSingleHostReverseProxy(target)
-		proxy.FlushInterval = -1 // streaming support for WebSocket upgrade
-		proxy.ServeHTTP(w, r)
+		s.newPodProxy(target, "claudecode", sbx).ServeHTTP(w, r)
 		return
 	}
 
@@ -139,7 +144,7 @@ func (s *Server) handleClaudeCodeSubdomainProxy(w http.ResponseWriter, r *http.R
 func (s *Server) handleTerminalWS(w http.ResponseWriter, r *http.Request, sbx *sbxstore.Sandbox) {
 	t, ok := s.TunnelRegistry.Get(sbx.ID)
 	if !ok {
-		writeErrorPage(w, errPageAgentOffline)
+		s.writeErrorPage(w, r, errPageAgentOffline, sbx)
 		return
 	}
 
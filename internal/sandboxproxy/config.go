@@ -1,8 +1,15 @@
 package sandboxproxy
 
 import (
+	"log"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/process"
 )
 
 // Config holds sandbox-proxy configuration loaded from environment variables.
@@ -15,6 +22,33 @@ type Config struct {
 	OpenclawSubdomainPrefix   string
 	ClaudeCodeSubdomainPrefix string
 	JupyterSubdomainPrefix    string
+	ProxyRetryAttempts        int
+	ProxyRetryBackoff         time.Duration
+	// CaptureDir, when set, records the traffic of CaptureSandboxes (IDs,
+	// or "*" for all) there for replay in tests; see proxycapture.
+	CaptureDir       string
+	CaptureSandboxes []string
+	// TunnelBandwidthLimit caps each local agent tunnel, in bytes per
+	// second and direction, unless the sandbox sets its own cap; 0 is
+	// unlimited.
+	TunnelBandwidthLimit int64
+	// MetricsToken enables /metrics, which requires it as a bearer token.
+	MetricsToken string
+	// AuthCheckToken enables /auth-check, which requires it in the
+	// X-Auth-Check-Token header.
+	AuthCheckToken string
+	// Region is the region this proxy runs in; RegionDomains maps regions
+	// to the base domain their sandboxes are served under (REGION_DOMAINS).
+	Region        string
+	RegionDomains map[string]string
+	// Branding customizes error pages (ERROR_PAGE_BRAND_NAME,
+	// ERROR_PAGE_LOGO_URL, ERROR_PAGE_SUPPORT_URL).
+	Branding ErrorPageBranding
+	// LegacyDomains maps former base domains to current ones
+	// (LEGACY_BASE_DOMAINS) and LegacyPrefixes former subdomain prefixes
+	// to current ones (LEGACY_SUBDOMAIN_PREFIXES); their hosts redirect.
+	LegacyDomains  map[string]string
+	LegacyPrefixes map[string]string
 }
 
 // LoadConfigFromEnv reads configuration from environment variables.
@@ -29,8 +63,51 @@ func LoadConfigFromEnv() Config {
 		OpenclawSubdomainPrefix:   os.Getenv("OPENCLAW_SUBDOMAIN_PREFIX"),
 		ClaudeCodeSubdomainPrefix: os.Getenv("CLAUDECODE_SUBDOMAIN_PREFIX"),
 		JupyterSubdomainPrefix:    os.Getenv("JUPYTER_SUBDOMAIN_PREFIX"),
+		ProxyRetryAttempts:        defaultProxyRetryAttempts,
+		ProxyRetryBackoff:         defaultProxyRetryBackoff,
+	}
+
+	if v := os.Getenv("PROXY_RETRY_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ProxyRetryAttempts = n
+		}
+	}
+	if v := os.Getenv("PROXY_RETRY_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ProxyRetryBackoff = d
+		}
+	}
+
+	if dir := os.Getenv("PROXY_CAPTURE_DIR"); dir != "" {
+		cfg.CaptureDir = dir
+		for _, id := range strings.Split(os.Getenv("PROXY_CAPTURE_SANDBOXES"), ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				cfg.CaptureSandboxes = append(cfg.CaptureSandboxes, id)
+			}
+		}
+	}
+
+	if v := os.Getenv("TUNNEL_BANDWIDTH_LIMIT"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.TunnelBandwidthLimit = n
+		}
+	}
+	cfg.MetricsToken = os.Getenv("METRICS_TOKEN")
+	cfg.AuthCheckToken = os.Getenv("AUTH_CHECK_TOKEN")
+	cfg.Region = os.Getenv("REGION")
+	if raw := os.Getenv("REGION_DOMAINS"); raw != "" {
+		domains, err := process.ParseRegionDomains(raw)
+		if err != nil {
+			log.Printf("ignoring REGION_DOMAINS: %v", err)
+		} else {
+			cfg.RegionDomains = domains
+		}
 	}
 
+	cfg.Branding.Name = os.Getenv("ERROR_PAGE_BRAND_NAME")
+	cfg.Branding.LogoURL = brandingURL("ERROR_PAGE_LOGO_URL", "http", "https")
+	cfg.Branding.SupportURL = brandingURL("ERROR_PAGE_SUPPORT_URL", "http", "https", "mailto")
+
 	// Parse comma-separated base domains.
 	if raw := os.Getenv("BASE_DOMAIN"); raw != "" {
 		for _, d := range strings.Split(raw, ",") {
@@ -41,6 +118,27 @@ func LoadConfigFromEnv() Config {
 		}
 	}
 
+	if raw := os.Getenv("LEGACY_BASE_DOMAINS"); raw != "" {
+		legacy, err := parseHostMap(raw)
+		if err != nil {
+			log.Printf("ignoring LEGACY_BASE_DOMAINS: %v", err)
+		} else {
+			var errs []error
+			cfg.LegacyDomains, errs = legacyDomains(legacy, cfg.BaseDomains)
+			for _, err := range errs {
+				log.Printf("ignoring LEGACY_BASE_DOMAINS entry: %v", err)
+			}
+		}
+	}
+	if raw := os.Getenv("LEGACY_SUBDOMAIN_PREFIXES"); raw != "" {
+		prefixes, err := parseHostMap(raw)
+		if err != nil {
+			log.Printf("ignoring LEGACY_SUBDOMAIN_PREFIXES: %v", err)
+		} else {
+			cfg.LegacyPrefixes = prefixes
+		}
+	}
+
 	if cfg.ListenAddr == "" {
 		cfg.ListenAddr = ":8082"
 	}
@@ -61,3 +159,16 @@ func LoadConfigFromEnv() Config {
 	}
 	return cfg
 }
+
+// brandingURL returns the URL in env if its scheme is one of schemes.
+func brandingURL(env string, schemes ...string) string {
+	v := os.Getenv(env)
+	if v == "" {
+		return ""
+	}
+	if u, err := url.Parse(v); err != nil || !slices.Contains(schemes, u.Scheme) {
+		log.Printf("ignoring %s: URL scheme must be one of %v", env, schemes)
+		return ""
+	}
+	return v
+}
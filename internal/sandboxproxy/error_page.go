@@ -1,12 +1,22 @@
 package sandboxproxy
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"html"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
 )
 
 // errorPageInfo defines the content for a styled HTML error page.
 type errorPageInfo struct {
+	Code        string // machine-readable error code for the JSON variant
 	Icon        string // inline SVG for the hero area
 	IconSpin    bool   // whether to add a spin animation to the icon
 	Title       string // e.g. "Sandbox Not Found"
@@ -16,24 +26,42 @@ type errorPageInfo struct {
 
 var (
 	errPageSandboxNotFound = errorPageInfo{
+		Code:        "sandbox_not_found",
 		Icon:        iconCircleX,
 		Title:       "Sandbox Not Found",
 		Description: "The sandbox you're looking for doesn't exist or you don't have access to it.",
 		StatusCode:  http.StatusNotFound,
 	}
 	errPageSandboxNotRunning = errorPageInfo{
+		Code:        "sandbox_not_running",
 		Icon:        iconPause,
 		Title:       "Sandbox Not Running",
 		Description: "This sandbox is currently paused or stopped. Resume it from the dashboard to continue.",
 		StatusCode:  http.StatusServiceUnavailable,
 	}
 	errPageAgentOffline = errorPageInfo{
+		Code:        "agent_offline",
 		Icon:        iconWifiOff,
 		Title:       "Agent Offline",
 		Description: "The local agent is not connected. Reconnect it to access this sandbox.",
 		StatusCode:  http.StatusServiceUnavailable,
 	}
+	errPageSandboxQuarantined = errorPageInfo{
+		Code:        "sandbox_quarantined",
+		Icon:        iconShieldAlert,
+		Title:       "Sandbox Quarantined",
+		Description: "An administrator has isolated this sandbox for review. Contact your administrator for details.",
+		StatusCode:  http.StatusForbidden,
+	}
+	errPagePortNotExposed = errorPageInfo{
+		Code:        "port_not_exposed",
+		Icon:        iconCircleX,
+		Title:       "Port Not Exposed",
+		Description: "This sandbox port has no preview URL. Expose it from the dashboard or the API first.",
+		StatusCode:  http.StatusNotFound,
+	}
 	errPagePodNotReady = errorPageInfo{
+		Code:        "sandbox_starting",
 		Icon:        iconSpinner,
 		IconSpin:    true,
 		Title:       "Sandbox Starting",
@@ -42,14 +70,153 @@ var (
 	}
 )
 
-// writeErrorPage renders a styled full-page HTML error to the response.
-func writeErrorPage(w http.ResponseWriter, info errorPageInfo) {
+// notRunningPage picks the page for a sandbox whose status is not
+// "running": sandboxes on their way up get the auto-refreshing starting
+// page, everything else the not-running page.
+func notRunningPage(sbx *sbxstore.Sandbox) errorPageInfo {
+	switch sbx.Status {
+	case sbxstore.StatusCreating, sbxstore.StatusResuming, sbxstore.StatusProvisioningStorage:
+		return errPagePodNotReady
+	}
+	return errPageSandboxNotRunning
+}
+
+// ErrorPageBranding customizes error pages for self-hosted deployments.
+// Empty fields are left out.
+type ErrorPageBranding struct {
+	Name       string // product name, shown in the page title and header
+	LogoURL    string // logo shown above the error, with Name as its alt text
+	SupportURL string // "Contact support" link, also in the JSON variant
+}
+
+// errorAction is a next step offered on an error page.
+type errorAction struct {
+	Label  string `json:"label"`
+	URL    string `json:"url"`
+	Method string `json:"method,omitempty"` // "POST" for API calls; empty for links
+}
+
+// errorResponse is the JSON variant of an error page, served to XHR and
+// fetch requests.
+type errorResponse struct {
+	Error         string        `json:"error"`
+	Message       string        `json:"message"`
+	CorrelationID string        `json:"correlation_id"`
+	SandboxID     string        `json:"sandbox_id,omitempty"`
+	Status        string        `json:"sandbox_status,omitempty"`
+	Hint          string        `json:"hint,omitempty"`
+	Actions       []errorAction `json:"actions,omitempty"`
+	SupportURL    string        `json:"support_url,omitempty"`
+}
+
+// wantsJSON reports whether the request comes from script rather than
+// a browser navigation: XHR and fetch requests, and clients whose Accept
+// header prefers JSON over HTML.
+func wantsJSON(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" || r.Header.Get("Sec-Fetch-Dest") == "empty" {
+		return true
+	}
+	jsonQ, htmlQ := acceptQuality(r.Header.Get("Accept"))
+	return jsonQ > htmlQ
+}
+
+// acceptQuality returns the quality an Accept header gives JSON (including
+// +json types such as application/problem+json) and HTML. Wildcards count
+// for both, so they never tip the balance.
+func acceptQuality(accept string) (jsonQ, htmlQ float64) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return jsonQ, htmlQ
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errorNextSteps returns the self-service hint and actions for an error
+// page. sbx is nil when the sandbox must not be revealed.
+func (s *Server) errorNextSteps(r *http.Request, info errorPageInfo, sbx *sbxstore.Sandbox) (string, []errorAction) {
+	if sbx == nil {
+		return "", nil
+	}
+	base := "https://" + s.matchedBaseDomain(r)
+	dashboard := errorAction{Label: "Open dashboard", URL: base + "/w/" + sbx.WorkspaceID + "/sandboxes/" + sbx.ID}
+	switch info.Code {
+	case errPageSandboxNotRunning.Code:
+		if sbx.Status == sbxstore.StatusPaused {
+			return "", []errorAction{
+				{Label: "Resume sandbox", URL: base + "/api/sandboxes/" + sbx.ID + "/resume", Method: http.MethodPost},
+				dashboard,
+			}
+		}
+	case errPageAgentOffline.Code:
+		return "On the machine running the agent, run \"agentserver connect\" to reconnect. This page reloads once it is back.", []errorAction{dashboard}
+	}
+	return "", []errorAction{dashboard}
+}
+
+// writeErrorPage renders a styled full-page HTML error to the response, or
+// its JSON variant for XHR requests. Each error gets a correlation ID that
+// is logged with the request details so support can find it. sbx may be nil.
+func (s *Server) writeErrorPage(w http.ResponseWriter, r *http.Request, info errorPageInfo, sbx *sbxstore.Sandbox) {
+	id := newCorrelationID()
+	sandboxID, sandboxStatus := "", ""
+	if sbx != nil {
+		sandboxID, sandboxStatus = sbx.ShortID, sbx.Status
+		if sandboxID == "" {
+			sandboxID = sbx.ID
+		}
+	}
+	log.Printf("error page %s: %s (HTTP %d) host=%s path=%s sandbox=%s status=%s",
+		id, info.Code, info.StatusCode, r.Host, r.URL.Path, sandboxID, sandboxStatus)
+	hint, actions := s.errorNextSteps(r, info, sbx)
+
+	w.Header().Set("X-Correlation-ID", id)
+	w.Header().Set("Cache-Control", "no-store")
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(info.StatusCode)
+		json.NewEncoder(w).Encode(errorResponse{
+			Error:         info.Code,
+			Message:       info.Title,
+			CorrelationID: id,
+			SandboxID:     sandboxID,
+			Status:        sandboxStatus,
+			Hint:          hint,
+			Actions:       actions,
+			SupportURL:    s.Branding.SupportURL,
+		})
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(info.StatusCode)
 
-	autoRefresh := ""
-	if info.StatusCode == http.StatusServiceUnavailable {
+	// Paused sandboxes stay paused until someone acts, so only pages that
+	// resolve on their own refresh. The page says so, as screen reader
+	// users would otherwise lose their place without warning.
+	autoRefresh, refreshNotice := "", ""
+	if info.StatusCode == http.StatusServiceUnavailable && sandboxStatus != sbxstore.StatusPaused {
 		autoRefresh = `<meta http-equiv="refresh" content="5">`
+		refreshNotice = `<p class="hint">This page refreshes every 5 seconds.</p>`
 	}
 
 	iconClass := "icon"
@@ -57,12 +224,56 @@ func writeErrorPage(w http.ResponseWriter, info errorPageInfo) {
 		iconClass = "icon icon-spin"
 	}
 
+	var next strings.Builder
+	next.WriteString(refreshNotice)
+	if hint != "" {
+		fmt.Fprintf(&next, `<p class="hint">%s</p>`, html.EscapeString(hint))
+	}
+	if len(actions) > 0 {
+		next.WriteString(`<div class="actions">`)
+		for _, a := range actions {
+			if a.Method == http.MethodPost {
+				fmt.Fprintf(&next, `<button type="button" class="action primary" data-url="%s">%s</button>`, html.EscapeString(a.URL), html.EscapeString(a.Label))
+			} else {
+				fmt.Fprintf(&next, `<a class="action" href="%s">%s</a>`, html.EscapeString(a.URL), html.EscapeString(a.Label))
+			}
+		}
+		next.WriteString(`</div>`)
+	}
+
+	details := "ID " + id
+	if sandboxID != "" {
+		details = "sandbox " + sandboxID + " &middot; " + details
+	}
+
+	title := info.Title
+	b := s.Branding
+	if b.Name != "" {
+		title += " · " + b.Name
+	}
+	brand := ""
+	switch {
+	case b.LogoURL != "":
+		brand = fmt.Sprintf(`<img class="brand-logo" src="%s" alt="%s">`, html.EscapeString(b.LogoURL), html.EscapeString(b.Name))
+	case b.Name != "":
+		brand = `<p class="brand">` + html.EscapeString(b.Name) + `</p>`
+	}
+	support := ""
+	if b.SupportURL != "" {
+		support = fmt.Sprintf(` <span aria-hidden="true">&middot;</span> <a href="%s" class="back-link">Contact support</a>`, html.EscapeString(b.SupportURL))
+	}
+
 	fmt.Fprintf(w, errorPageTemplate,
 		autoRefresh,
+		html.EscapeString(title),
+		brand,
 		iconClass, info.Icon,
 		info.Title,
 		info.Description,
+		next.String(),
 		info.StatusCode,
+		html.EscapeString(details),
+		support,
 	)
 }
 
@@ -73,6 +284,8 @@ const iconPause = `<svg xmlns="http://www.w3.org/2000/svg" width="48" height="48
 
 const iconWifiOff = `<svg xmlns="http://www.w3.org/2000/svg" width="48" height="48" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round"><path d="M12 20h.01"/><path d="M8.5 16.429a5 5 0 0 1 7 0"/><path d="M5 12.859a10 10 0 0 1 5.17-2.69"/><path d="M13.83 10.17A10 10 0 0 1 19 12.86"/><path d="M2 8.82a15 15 0 0 1 4.17-2.65"/><path d="M10.66 5c4.01-.36 8.14.9 11.34 3.76"/><line x1="2" x2="22" y1="2" y2="22"/></svg>`
 
+const iconShieldAlert = `<svg xmlns="http://www.w3.org/2000/svg" width="48" height="48" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round"><path d="M20 13c0 5-3.5 7.5-7.66 8.95a1 1 0 0 1-.67-.01C7.5 20.5 4 18 4 13V6a1 1 0 0 1 1-1c2 0 4.5-1.2 6.24-2.72a1.17 1.17 0 0 1 1.52 0C14.51 3.81 17 5 19 5a1 1 0 0 1 1 1z"/><path d="M12 8v4"/><path d="M12 16h.01"/></svg>`
+
 const iconSpinner = `<svg xmlns="http://www.w3.org/2000/svg" width="48" height="48" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round"><path d="M21 12a9 9 0 1 1-6.219-8.56"/></svg>`
 
 const errorPageTemplate = `<!DOCTYPE html>
@@ -81,7 +294,7 @@ const errorPageTemplate = `<!DOCTYPE html>
 <meta charset="utf-8">
 <meta name="viewport" content="width=device-width, initial-scale=1">
 %s
-<title>Error</title>
+<title>%s</title>
 <style>
   *, *::before, *::after { box-sizing: border-box; margin: 0; padding: 0; }
 
@@ -134,6 +347,20 @@ const errorPageTemplate = `<!DOCTYPE html>
 
   @keyframes spin { to { transform: rotate(360deg); } }
   .icon-spin svg { animation: spin 1s linear infinite; }
+  @media (prefers-reduced-motion: reduce) {
+    .icon-spin svg { animation: none; }
+  }
+
+  .brand {
+    font-weight: 600;
+    margin-bottom: 1.5rem;
+  }
+  .brand-logo {
+    display: block;
+    max-height: 2.5rem;
+    max-width: 12rem;
+    margin: 0 auto 1.5rem;
+  }
 
   h1 {
     font-size: 1.375rem;
@@ -161,6 +388,43 @@ const errorPageTemplate = `<!DOCTYPE html>
     border: 1px solid var(--border);
   }
 
+  .hint {
+    color: var(--muted);
+    font-size: 0.875rem;
+    line-height: 1.6;
+    margin-bottom: 1.25rem;
+  }
+
+  .actions {
+    display: flex;
+    gap: 0.5rem;
+    justify-content: center;
+    margin-bottom: 1.5rem;
+  }
+
+  .action {
+    font: inherit;
+    font-size: 0.875rem;
+    padding: 0.5rem 1rem;
+    border-radius: 6px;
+    border: 1px solid var(--border);
+    background: var(--bg);
+    color: var(--fg);
+    text-decoration: none;
+    cursor: pointer;
+  }
+  .action.primary { background: var(--fg); color: var(--bg); border-color: var(--fg); }
+  .action:disabled { opacity: 0.6; cursor: default; }
+  .action:focus-visible, .back-link:focus-visible { outline: 2px solid var(--fg); outline-offset: 2px; }
+
+  .details {
+    color: var(--muted);
+    font-size: 0.75rem;
+    font-family: 'SF Mono', SFMono-Regular, ui-monospace, monospace;
+    margin-top: 0.75rem;
+    user-select: all;
+  }
+
   .divider {
     width: 3rem;
     height: 1px;
@@ -178,13 +442,26 @@ const errorPageTemplate = `<!DOCTYPE html>
 </style>
 </head>
 <body>
-  <div class="container">
-    <div class="%s">%s</div>
-    <h1>%s</h1>
+  <main class="container" aria-labelledby="error-title">
+    %s
+    <div class="%s" aria-hidden="true">%s</div>
+    <h1 id="error-title">%s</h1>
     <p class="description">%s</p>
-    <span class="badge">HTTP %d</span>
-    <div class="divider"></div>
-    <a href="javascript:history.back()" class="back-link">&larr; Go back</a>
-  </div>
+    %s
+    <p><span class="badge">HTTP %d</span></p>
+    <p class="details">%s</p>
+    <div class="divider" aria-hidden="true"></div>
+    <p><a href="javascript:history.back()" class="back-link"><span aria-hidden="true">&larr;</span> Go back</a>%s</p>
+  </main>
+  <script>
+    document.querySelectorAll("button[data-url]").forEach(function (b) {
+      b.addEventListener("click", function () {
+        b.disabled = true;
+        b.setAttribute("aria-busy", "true");
+        fetch(b.dataset.url, { method: "POST", mode: "no-cors", credentials: "include" })
+          .finally(function () { setTimeout(function () { location.reload(); }, 2000); });
+      });
+    });
+  </script>
 </body>
 </html>`
@@ -0,0 +1,131 @@
+package sandboxproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestWriteErrorPageJSON(t *testing.T) {
+	s := &Server{BaseDomains: []string{"example.com"}}
+	sbx := &sbxstore.Sandbox{ID: "sbx-1", ShortID: "abc", WorkspaceID: "ws-1", Status: sbxstore.StatusPaused}
+
+	r := httptest.NewRequest("GET", "/session", nil)
+	r.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	s.writeErrorPage(w, r, notRunningPage(sbx), sbx)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var resp errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "sandbox_not_running" || resp.SandboxID != "abc" || resp.CorrelationID == "" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if w.Header().Get("X-Correlation-ID") != resp.CorrelationID {
+		t.Errorf("header and body correlation IDs differ")
+	}
+	if len(resp.Actions) == 0 || resp.Actions[0].URL != "https://example.com/api/sandboxes/sbx-1/resume" || resp.Actions[0].Method != "POST" {
+		t.Errorf("missing resume action: %+v", resp.Actions)
+	}
+}
+
+func TestWriteErrorPageHTML(t *testing.T) {
+	s := &Server{BaseDomains: []string{"example.com"}}
+
+	w := httptest.NewRecorder()
+	s.writeErrorPage(w, httptest.NewRequest("GET", "/", nil), errPageSandboxNotFound, nil)
+	body := w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("content type = %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, w.Header().Get("X-Correlation-ID")) {
+		t.Errorf("page does not show the correlation ID")
+	}
+	if strings.Contains(body, "class=\"action") {
+		t.Errorf("not-found page offers actions")
+	}
+
+	sbx := &sbxstore.Sandbox{ID: "sbx-1", WorkspaceID: "ws-1", Status: sbxstore.StatusRunning, IsLocal: true}
+	w = httptest.NewRecorder()
+	s.writeErrorPage(w, httptest.NewRequest("GET", "/", nil), errPageAgentOffline, sbx)
+	if body := w.Body.String(); !strings.Contains(body, "agentserver connect") || !strings.Contains(body, "sbx-1") {
+		t.Errorf("offline page lacks reconnect instructions or sandbox ID")
+	}
+}
+
+func TestNotRunningPage(t *testing.T) {
+	if p := notRunningPage(&sbxstore.Sandbox{Status: sbxstore.StatusResuming}); p.Code != errPagePodNotReady.Code {
+		t.Errorf("resuming: got %s", p.Code)
+	}
+	if p := notRunningPage(&sbxstore.Sandbox{Status: sbxstore.StatusPaused}); p.Code != errPageSandboxNotRunning.Code {
+		t.Errorf("paused: got %s", p.Code)
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/json", true},
+		{"application/json, text/plain, */*", true},
+		{"application/problem+json", true},
+		{"application/json, text/html", false},
+		{"text/html;q=0.5, application/json", true},
+		{"application/json;q=0.1, text/html", false},
+		{"*/*", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := wantsJSON(r); got != tt.want {
+			t.Errorf("wantsJSON(Accept: %q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestWriteErrorPageBranding(t *testing.T) {
+	s := &Server{BaseDomains: []string{"example.com"}, Branding: ErrorPageBranding{
+		Name:       "Acme <Cloud>",
+		LogoURL:    "https://acme.example/logo.svg",
+		SupportURL: "https://acme.example/support",
+	}}
+
+	w := httptest.NewRecorder()
+	s.writeErrorPage(w, httptest.NewRequest("GET", "/", nil), errPageSandboxNotFound, nil)
+	body := w.Body.String()
+	for _, want := range []string{
+		`<title>Sandbox Not Found · Acme &lt;Cloud&gt;</title>`,
+		`<img class="brand-logo" src="https://acme.example/logo.svg" alt="Acme &lt;Cloud&gt;">`,
+		`href="https://acme.example/support"`,
+		`<h1 id="error-title">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page lacks %s", want)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
+	var resp errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.SupportURL != "https://acme.example/support" {
+		t.Errorf("support_url = %q", resp.SupportURL)
+	}
+}
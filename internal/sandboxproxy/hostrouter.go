@@ -0,0 +1,153 @@
+package sandboxproxy
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+// hostKind classifies what a request's Host addresses.
+type hostKind int
+
+const (
+	// hostOther is not a sandbox host: the apex of a base domain, or a host
+	// outside all base domains. The regular routes serve it.
+	hostOther hostKind = iota
+	// hostAsset is the opencode asset domain.
+	hostAsset
+	// hostSandbox is {prefix}-{sandboxID}.{baseDomain}.
+	hostSandbox
+	// hostUnknown is under a base domain but names no sandbox: an unknown
+	// prefix, an empty ID or a nested subdomain.
+	hostUnknown
+)
+
+// Sandbox apps addressable by subdomain prefix.
+const (
+	appOpencode   = "opencode"
+	appOpenclaw   = "openclaw"
+	appClaudeCode = "claudecode"
+	appJupyter    = "jupyter"
+	// appPort is a sandbox port exposed as a preview URL,
+	// port-{port}-{sandboxID}.{baseDomain}.
+	appPort = "port"
+)
+
+// hostRoute is where a request Host routes to.
+type hostRoute struct {
+	kind      hostKind
+	domain    string // matched base domain as configured (with any port); "" if none
+	app       string // app of a hostSandbox route
+	sandboxID string
+	port      int // sandbox port of an appPort route
+}
+
+type hostDomain struct {
+	host   string // normalized, without port
+	domain string // as configured
+}
+
+type hostPrefix struct {
+	prefix string // including the trailing "-"
+	app    string
+}
+
+// hostRouter maps request Hosts onto sandboxes. Hosts are matched
+// case-insensitively, ignoring any port and a trailing dot.
+type hostRouter struct {
+	domains   []hostDomain // longest first, so nested base domains win
+	prefixes  []hostPrefix // longest first, so "code-" doesn't shadow "codex-"
+	assetHost string
+}
+
+// newHostRouter returns a router for baseDomains and the subdomain prefixes
+// and asset domain of cfg. Empty prefixes are ignored.
+func newHostRouter(baseDomains []string, cfg settings.Settings) *hostRouter {
+	h := &hostRouter{}
+	for _, d := range baseDomains {
+		if host, ok := normalizeHost(d); ok {
+			h.domains = append(h.domains, hostDomain{host: host, domain: d})
+		}
+	}
+	sort.SliceStable(h.domains, func(i, j int) bool { return len(h.domains[i].host) > len(h.domains[j].host) })
+	for _, p := range []hostPrefix{
+		{cfg.OpencodeSubdomainPrefix, appOpencode},
+		{cfg.OpenclawSubdomainPrefix, appOpenclaw},
+		{cfg.ClaudeCodeSubdomainPrefix, appClaudeCode},
+		{cfg.JupyterSubdomainPrefix, appJupyter},
+	} {
+		if p.prefix != "" {
+			h.prefixes = append(h.prefixes, hostPrefix{prefix: strings.ToLower(p.prefix) + "-", app: p.app})
+		}
+	}
+	sort.SliceStable(h.prefixes, func(i, j int) bool { return len(h.prefixes[i].prefix) > len(h.prefixes[j].prefix) })
+	if cfg.OpencodeAssetDomain != "" {
+		h.assetHost, _ = normalizeHost(cfg.OpencodeAssetDomain)
+	}
+	return h
+}
+
+// normalizeHost returns the lowercase host name of a Host header value
+// without port or trailing dot. It reports false for empty hosts and IP
+// literals, which never address a sandbox.
+func normalizeHost(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || strings.HasPrefix(host, "[") {
+		return "", false
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return "", false
+	}
+	return host, true
+}
+
+// route classifies the Host header value host.
+func (h *hostRouter) route(host string) hostRoute {
+	host, ok := normalizeHost(host)
+	if !ok {
+		return hostRoute{kind: hostOther}
+	}
+	var rt hostRoute
+	var sub string
+	for _, d := range h.domains {
+		if host == d.host {
+			rt.domain = d.domain
+			break
+		}
+		if strings.HasSuffix(host, "."+d.host) {
+			rt.domain = d.domain
+			sub = strings.TrimSuffix(host, "."+d.host)
+			break
+		}
+	}
+	if h.assetHost != "" && host == h.assetHost {
+		rt.kind = hostAsset
+		return rt
+	}
+	if sub == "" {
+		rt.kind = hostOther
+		return rt
+	}
+	rt.kind = hostUnknown
+	if strings.Contains(sub, ".") {
+		return rt
+	}
+	if port, id, ok := process.ParsePreviewPortSubdomain(sub); ok {
+		rt.kind, rt.app, rt.sandboxID, rt.port = hostSandbox, appPort, id, port
+		return rt
+	}
+	for _, p := range h.prefixes {
+		if id := strings.TrimPrefix(sub, p.prefix); id != sub && id != "" {
+			rt.kind, rt.app, rt.sandboxID = hostSandbox, p.app, id
+			return rt
+		}
+	}
+	return rt
+}
@@ -0,0 +1,76 @@
+package sandboxproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+func TestHostRouterRoute(t *testing.T) {
+	h := newHostRouter([]string{"example.com", "dev.example.com", "localhost:8080"}, settings.Settings{
+		OpencodeSubdomainPrefix:   "code",
+		OpenclawSubdomainPrefix:   "claw",
+		ClaudeCodeSubdomainPrefix: "claudecode",
+		JupyterSubdomainPrefix:    "jupyter",
+		OpencodeAssetDomain:       "opencodeapp.example.com",
+	})
+	tests := []struct {
+		host string
+		want hostRoute
+	}{
+		{"code-abc123.example.com", hostRoute{hostSandbox, "example.com", appOpencode, "abc123", 0}},
+		{"CODE-ABC123.Example.COM", hostRoute{hostSandbox, "example.com", appOpencode, "abc123", 0}},
+		{"code-abc123.example.com.", hostRoute{hostSandbox, "example.com", appOpencode, "abc123", 0}},
+		{"code-abc123.example.com.:443", hostRoute{hostSandbox, "example.com", appOpencode, "abc123", 0}},
+		{"claw-abc123.example.com:8443", hostRoute{hostSandbox, "example.com", appOpenclaw, "abc123", 0}},
+		{"claudecode-abc123.example.com", hostRoute{hostSandbox, "example.com", appClaudeCode, "abc123", 0}},
+		{"jupyter-abc123.dev.example.com", hostRoute{hostSandbox, "dev.example.com", appJupyter, "abc123", 0}},
+		{"code-abc123.localhost:8080", hostRoute{hostSandbox, "localhost:8080", appOpencode, "abc123", 0}},
+		{"port-3000-abc123.example.com", hostRoute{hostSandbox, "example.com", appPort, "abc123", 3000}},
+		{"port-4096-abc123.example.com", hostRoute{hostUnknown, "example.com", "", "", 0}},
+		{"port-03000-abc123.example.com", hostRoute{hostUnknown, "example.com", "", "", 0}},
+		{"opencodeapp.example.com", hostRoute{hostAsset, "example.com", "", "", 0}},
+		{"example.com", hostRoute{hostOther, "example.com", "", "", 0}},
+		{"dev.example.com:80", hostRoute{hostOther, "dev.example.com", "", "", 0}},
+		{"www.example.com", hostRoute{hostUnknown, "example.com", "", "", 0}},
+		{"code-.example.com", hostRoute{hostUnknown, "example.com", "", "", 0}},
+		{"a.code-abc123.example.com", hostRoute{hostUnknown, "example.com", "", "", 0}},
+		{"code-abc123.example.org", hostRoute{hostOther, "", "", "", 0}},
+		{"code-abc123.notexample.com", hostRoute{hostOther, "", "", "", 0}},
+		{"[::1]:8080", hostRoute{hostOther, "", "", "", 0}},
+		{"::1", hostRoute{hostOther, "", "", "", 0}},
+		{"127.0.0.1:8080", hostRoute{hostOther, "", "", "", 0}},
+		{"", hostRoute{hostOther, "", "", "", 0}},
+	}
+	for _, tt := range tests {
+		if got := h.route(tt.host); got != tt.want {
+			t.Errorf("route(%q) = %+v, want %+v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestRouterMisdirectedAndUnknownHosts(t *testing.T) {
+	s := &Server{BaseDomains: []string{"example.com"}, OpencodeSubdomainPrefix: "code"}
+	h := s.Router()
+	tests := []struct {
+		host, path string
+		want       int
+	}{
+		{"www.example.com", "/", http.StatusNotFound},
+		{"example.com", "/", http.StatusNotFound},
+		{"other.org", "/", http.StatusMisdirectedRequest},
+		{"[::1]:8080", "/", http.StatusMisdirectedRequest},
+		{"other.org", "/healthz", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = tt.host
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s%s: status %d, want %d", tt.host, tt.path, rr.Code, tt.want)
+		}
+	}
+}
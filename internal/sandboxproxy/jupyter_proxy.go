@@ -1,11 +1,13 @@
 package sandboxproxy
 
 import (
-	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
 )
 
 const (
@@ -44,20 +46,24 @@ func (s *Server) handleJupyterSubdomainProxy(w http.ResponseWriter, r *http.Requ
 
 	sbx, found := s.Sandboxes.Resolve(sandboxID)
 	if !found || sbx.Type != "jupyter" {
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 	if err != nil || !isMember {
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
+		return
+	}
+	if sbx.QuarantinedAt != nil {
+		s.writeErrorPage(w, r, errPageSandboxQuarantined, sbx)
 		return
 	}
 	if sbx.Status != "running" {
-		writeErrorPage(w, errPageSandboxNotRunning)
+		s.writeErrorPage(w, r, notRunningPage(sbx), sbx)
 		return
 	}
 	if sbx.PodIP == "" {
-		writeErrorPage(w, errPagePodNotReady)
+		s.writeErrorPage(w, r, errPagePodNotReady, sbx)
 		return
 	}
 
@@ -76,15 +82,10 @@ func (s *Server) handleJupyterSubdomainProxy(w http.ResponseWriter, r *http.Requ
 	}
 
 	s.throttledActivity(sandboxID)
+	s.recordUsage(sbx, userID, db.SandboxActivityUser)
 
-	target := &url.URL{Scheme: "http", Host: sbx.PodIP + ":" + jupyterPort}
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	proxy.FlushInterval = -1 // SSE + WebSocket streaming
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("jupyter proxy error for sandbox %s: %v", sandboxID, err)
-		http.Error(w, "proxy error", http.StatusBadGateway)
-	}
-	proxy.ServeHTTP(w, r)
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort(sbx.PodIP, jupyterPort)}
+	s.newPodProxy(target, "jupyter", sbx).ServeHTTP(w, r)
 }
 
 func (s *Server) exchangeJupyterToken(w http.ResponseWriter, r *http.Request, sandboxID string) {
@@ -100,12 +101,12 @@ func (s *Server) exchangeJupyterToken(w http.ResponseWriter, r *http.Request, sa
 	}
 	sbx, found := s.Sandboxes.Resolve(sandboxID)
 	if !found || sbx.Type != "jupyter" {
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 	if err != nil || !isMember {
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 	http.SetCookie(w, &http.Cookie{
@@ -113,7 +114,7 @@ func (s *Server) exchangeJupyterToken(w http.ResponseWriter, r *http.Request, sa
 		Value:    tok,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   auth.SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   int(jupyterCookieMaxTTL.Seconds()),
 	})
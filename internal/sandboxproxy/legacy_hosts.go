@@ -0,0 +1,169 @@
+package sandboxproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Legacy hosts: after a change of BASE_DOMAIN or a subdomain prefix, the old
+// hosts keep working as redirects. LegacyDomains maps an old base domain to
+// its replacement and LegacyPrefixes an old prefix to the current one, so
+// code-abc.old.example.com and oc-abc.example.com both move to
+// code-abc.example.com.
+
+// parseHostMap parses "old=new,..." pairs of hosts or prefixes, lowercased.
+func parseHostMap(raw string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.ToLower(strings.TrimSpace(from)), strings.ToLower(strings.TrimSpace(to))
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid mapping %q (want old=new)", pair)
+		}
+		if from == to {
+			return nil, fmt.Errorf("%q maps to itself", from)
+		}
+		if _, dup := m[from]; dup {
+			return nil, fmt.Errorf("%q listed twice", from)
+		}
+		m[from] = to
+	}
+	return m, nil
+}
+
+// legacyDomains resolves the targets of legacy base domains to the base
+// domains as configured (keeping any port). Mappings to a domain that is
+// not a base domain are returned as errors and left out.
+func legacyDomains(legacy map[string]string, baseDomains []string) (map[string]string, []error) {
+	resolved := make(map[string]string, len(legacy))
+	var errs []error
+	for from, to := range legacy {
+		host, ok := normalizeHost(from)
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid legacy domain %q", from))
+			continue
+		}
+		target := ""
+		for _, d := range baseDomains {
+			if strings.EqualFold(d, to) || strings.EqualFold(domainHost(d), to) {
+				target = d
+				break
+			}
+		}
+		if target == "" {
+			errs = append(errs, fmt.Errorf("legacy domain %s maps to %s, which is not a base domain", from, to))
+			continue
+		}
+		for _, d := range baseDomains {
+			if strings.EqualFold(domainHost(d), host) {
+				errs = append(errs, fmt.Errorf("legacy domain %s is still a base domain", from))
+				target = ""
+				break
+			}
+		}
+		if target != "" {
+			resolved[host] = target
+		}
+	}
+	return resolved, errs
+}
+
+// legacyHost returns the current host of a request Host under a legacy
+// base domain or with a legacy subdomain prefix, and false for current and
+// unrelated hosts. h routes the current hosts.
+func (h *hostRouter) legacyHost(host string, domains, prefixes map[string]string) (string, bool) {
+	host, ok := normalizeHost(host)
+	if !ok {
+		return "", false
+	}
+
+	// Longest legacy domain first, so nested legacy domains win.
+	legacy := make([]string, 0, len(domains))
+	for d := range domains {
+		legacy = append(legacy, d)
+	}
+	sort.Slice(legacy, func(i, j int) bool { return len(legacy[i]) > len(legacy[j]) })
+	for _, d := range legacy {
+		if host == d {
+			return domains[d], true
+		}
+		if sub, ok := strings.CutSuffix(host, "."+d); ok {
+			return h.currentPrefix(sub, prefixes) + "." + domains[d], true
+		}
+	}
+
+	rt := h.route(host)
+	if rt.kind != hostUnknown {
+		return "", false
+	}
+	sub := strings.TrimSuffix(host, "."+strings.ToLower(domainHost(rt.domain)))
+	if renamed := h.currentPrefix(sub, prefixes); renamed != sub {
+		return renamed + "." + rt.domain, true
+	}
+	return "", false
+}
+
+// currentPrefix replaces a legacy prefix of the subdomain sub by the
+// current one. Subdomains with a current prefix, and legacy prefixes
+// mapped to a prefix that is no longer current, are left alone.
+func (h *hostRouter) currentPrefix(sub string, prefixes map[string]string) string {
+	if strings.Contains(sub, ".") {
+		return sub
+	}
+	for _, p := range h.prefixes {
+		if strings.HasPrefix(sub, p.prefix) {
+			return sub
+		}
+	}
+	for from, to := range prefixes {
+		id, ok := strings.CutPrefix(sub, from+"-")
+		if !ok || id == "" {
+			continue
+		}
+		for _, p := range h.prefixes {
+			if p.prefix == to+"-" {
+				return to + "-" + id
+			}
+		}
+	}
+	return sub
+}
+
+// sandboxCookieKeys are the per-subdomain auth cookies of the sandbox apps.
+var sandboxCookieKeys = []string{subdomainCookieKey, clawCookieKey, claudecodeCookieKey, jupyterCookieKey, portCookieKey}
+
+// redirectLegacyHost redirects requests on legacy hosts to the current
+// host, keeping method and path. The per-subdomain auth cookies don't
+// carry over to the new host, so a GET with a still-valid one goes to the
+// new sandbox host's /auth instead, which sets the cookie there without a
+// detour through the login page. It reports whether it redirected.
+func (s *Server) redirectLegacyHost(w http.ResponseWriter, r *http.Request, h *hostRouter) bool {
+	if len(s.LegacyDomains) == 0 && len(s.LegacyPrefixes) == 0 {
+		return false
+	}
+	host, ok := h.legacyHost(r.Host, s.LegacyDomains, s.LegacyPrefixes)
+	if !ok {
+		return false
+	}
+	if r.Method == http.MethodGet && h.route(host).kind == hostSandbox {
+		for _, key := range sandboxCookieKeys {
+			c, err := r.Cookie(key)
+			if err != nil {
+				continue
+			}
+			if _, ok := s.Auth.ValidateToken(c.Value); ok {
+				http.Redirect(w, r, "https://"+host+"/auth?token="+url.QueryEscape(c.Value), http.StatusFound)
+				return true
+			}
+		}
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	return true
+}
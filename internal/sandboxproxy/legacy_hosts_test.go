@@ -0,0 +1,90 @@
+package sandboxproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+func TestParseHostMap(t *testing.T) {
+	m, err := parseHostMap(" Old.Example.com = example.com ,oc=code,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m["old.example.com"] != "example.com" || m["oc"] != "code" {
+		t.Errorf("parseHostMap = %v", m)
+	}
+	for _, bad := range []string{"old.example.com", "=example.com", "a=b,a=c", "code=code"} {
+		if _, err := parseHostMap(bad); err == nil {
+			t.Errorf("parseHostMap(%q) accepted", bad)
+		}
+	}
+}
+
+func TestLegacyDomains(t *testing.T) {
+	got, errs := legacyDomains(map[string]string{
+		"old.example.com": "example.com",
+		"dev.old.test":    "localhost",
+		"gone.example":    "nowhere.example",
+		"example.org":     "example.com",
+	}, []string{"example.com", "localhost:8443", "example.org"})
+	if len(got) != 2 || got["old.example.com"] != "example.com" || got["dev.old.test"] != "localhost:8443" {
+		t.Errorf("legacyDomains = %v", got)
+	}
+	if len(errs) != 2 {
+		t.Errorf("errors = %v, want 2 (unknown target, still a base domain)", errs)
+	}
+}
+
+func TestLegacyHost(t *testing.T) {
+	h := newHostRouter([]string{"example.com"}, settings.Settings{OpencodeSubdomainPrefix: "code", OpenclawSubdomainPrefix: "claw"})
+	domains := map[string]string{"old.example.com": "example.com", "legacy.test": "example.com"}
+	prefixes := map[string]string{"oc": "code", "gone": "retired"}
+	for _, tc := range []struct {
+		host, want string
+	}{
+		{"code-abc.old.example.com", "code-abc.example.com"},
+		{"OC-abc.Legacy.Test:443", "code-abc.example.com"},
+		{"old.example.com", "example.com"},
+		{"oc-abc.example.com", "code-abc.example.com"},
+		{"claw-abc.legacy.test", "claw-abc.example.com"},
+		{"gone-abc.legacy.test", "gone-abc.example.com"},
+		{"x.y.legacy.test", "x.y.example.com"},
+	} {
+		got, ok := h.legacyHost(tc.host, domains, prefixes)
+		if !ok || got != tc.want {
+			t.Errorf("legacyHost(%q) = %q, %v, want %q", tc.host, got, ok, tc.want)
+		}
+	}
+	for _, host := range []string{"code-abc.example.com", "example.com", "gone-abc.example.com", "other.test", "10.0.0.1"} {
+		if got, ok := h.legacyHost(host, domains, prefixes); ok {
+			t.Errorf("legacyHost(%q) = %q, want no redirect", host, got)
+		}
+	}
+}
+
+func TestRedirectLegacyHost(t *testing.T) {
+	s := &Server{
+		BaseDomains:             []string{"example.com"},
+		OpencodeSubdomainPrefix: "code",
+		LegacyDomains:           map[string]string{"old.example.com": "example.com"},
+	}
+	h := newHostRouter(s.hostDomains(), s.routing())
+	r := httptest.NewRequest("POST", "/session?x=1", nil)
+	r.Host = "code-abc.old.example.com"
+	w := httptest.NewRecorder()
+	if !s.redirectLegacyHost(w, r, h) {
+		t.Fatal("legacy host not redirected")
+	}
+	if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "https://code-abc.example.com/session?x=1" {
+		t.Errorf("redirect = %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Host = "code-abc.example.com"
+	if s.redirectLegacyHost(httptest.NewRecorder(), r, h) {
+		t.Error("current host redirected")
+	}
+}
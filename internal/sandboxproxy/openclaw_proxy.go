@@ -2,10 +2,12 @@ package sandboxproxy
 
 import (
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
 )
 
 const (
@@ -36,12 +38,12 @@ func (s *Server) handleOpenclawSubdomainProxy(w http.ResponseWriter, r *http.Req
 		// Verify workspace membership.
 		sbx, found := s.Sandboxes.Resolve(sandboxID)
 		if !found {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 		if err != nil || !isMember {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		// Set a per-subdomain auth cookie (no Domain attr — scoped to this subdomain only).
@@ -82,23 +84,27 @@ func (s *Server) handleOpenclawSubdomainProxy(w http.ResponseWriter, r *http.Req
 	sbx, found := s.Sandboxes.Resolve(sandboxID)
 	if !found {
 		log.Printf("openclaw proxy: sandbox %s not found in store", sandboxID)
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 	if err != nil || !isMember {
 		log.Printf("openclaw proxy: user %s not a member of workspace %s for sandbox %s", userID, sbx.WorkspaceID, sandboxID)
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 
+	if sbx.QuarantinedAt != nil {
+		s.writeErrorPage(w, r, errPageSandboxQuarantined, sbx)
+		return
+	}
 	if sbx.Status != "running" {
-		writeErrorPage(w, errPageSandboxNotRunning)
+		s.writeErrorPage(w, r, notRunningPage(sbx), sbx)
 		return
 	}
 
 	if sbx.PodIP == "" {
-		writeErrorPage(w, errPagePodNotReady)
+		s.writeErrorPage(w, r, errPagePodNotReady, sbx)
 		return
 	}
 
@@ -109,17 +115,12 @@ func (s *Server) handleOpenclawSubdomainProxy(w http.ResponseWriter, r *http.Req
 
 	// Track activity for idle watcher.
 	s.throttledActivity(sandboxID)
+	s.recordUsage(sbx, userID, db.SandboxActivityUser)
 
 	// Reverse proxy to the sandbox pod.
 	target := &url.URL{
 		Scheme: "http",
-		Host:   sbx.PodIP + ":" + openclawPort,
-	}
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	proxy.FlushInterval = -1 // Enable SSE streaming.
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("openclaw proxy error for sandbox %s: %v", sandboxID, err)
-		http.Error(w, "proxy error", http.StatusBadGateway)
+		Host:   net.JoinHostPort(sbx.PodIP, openclawPort),
 	}
-	proxy.ServeHTTP(w, r)
+	s.newPodProxy(target, "openclaw", sbx).ServeHTTP(w, r)
 }
@@ -6,13 +6,17 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
 )
 
 const (
@@ -59,12 +63,12 @@ func (s *Server) handleSubdomainProxy(w http.ResponseWriter, r *http.Request, sa
 		// Verify workspace membership.
 		sbx, found := s.Sandboxes.Resolve(sandboxID)
 		if !found {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 		if err != nil || !isMember {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		// Set a per-subdomain auth cookie (no Domain attr — scoped to this subdomain only).
@@ -99,36 +103,46 @@ func (s *Server) handleSubdomainProxy(w http.ResponseWriter, r *http.Request, sa
 	sbx, found := s.Sandboxes.Resolve(sandboxID)
 	if !found {
 		log.Printf("subdomain proxy: sandbox %s not found in store", sandboxID)
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
 	if err != nil || !isMember {
 		log.Printf("subdomain proxy: user %s not a member of workspace %s for sandbox %s", userID, sbx.WorkspaceID, sandboxID)
-		writeErrorPage(w, errPageSandboxNotFound)
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 		return
 	}
 
+	if sbx.QuarantinedAt != nil {
+		s.writeErrorPage(w, r, errPageSandboxQuarantined, sbx)
+		return
+	}
 	if sbx.Status != "running" {
-		writeErrorPage(w, errPageSandboxNotRunning)
+		s.writeErrorPage(w, r, notRunningPage(sbx), sbx)
 		return
 	}
+	w = s.trackUsage(w, r, sbx, userID)
 
 	// Custom agents skip opencode SPA fallback — go straight to tunnel proxy.
 	if sbx.Type == "custom" {
 		if !sbx.IsLocal {
-			writeErrorPage(w, errPageSandboxNotFound)
+			s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
 			return
 		}
 		tunnel, ok := s.TunnelRegistry.Get(sbx.ID)
 		if !ok {
-			writeErrorPage(w, errPageAgentOffline)
+			s.writeErrorPage(w, r, errPageAgentOffline, sbx)
 			return
 		}
 		s.proxyViaTunnel(w, r, sbx, tunnel)
 		return
 	}
 
+	if s.proxyPathDenied(r, sbx.WorkspaceID, userID) {
+		http.Error(w, "forbidden for your workspace role", http.StatusForbidden)
+		return
+	}
+
 	// Try SPA fallback from embedded opencode frontend before proxying to pod.
 	// Real static files were already served above (before auth); here we only
 	// handle SPA client-side routes that need index.html.
@@ -142,7 +156,7 @@ func (s *Server) handleSubdomainProxy(w http.ResponseWriter, r *http.Request, sa
 	if sbx.IsLocal {
 		tunnel, ok := s.TunnelRegistry.Get(sbx.ID)
 		if !ok {
-			writeErrorPage(w, errPageAgentOffline)
+			s.writeErrorPage(w, r, errPageAgentOffline, sbx)
 			return
 		}
 		s.proxyViaTunnel(w, r, sbx, tunnel)
@@ -150,7 +164,7 @@ func (s *Server) handleSubdomainProxy(w http.ResponseWriter, r *http.Request, sa
 	}
 
 	if sbx.PodIP == "" {
-		writeErrorPage(w, errPagePodNotReady)
+		s.writeErrorPage(w, r, errPagePodNotReady, sbx)
 		return
 	}
 
@@ -166,28 +180,35 @@ func (s *Server) handleSubdomainProxy(w http.ResponseWriter, r *http.Request, sa
 	// Reverse proxy to the sandbox pod.
 	target := &url.URL{
 		Scheme: "http",
-		Host:   sbx.PodIP + ":" + opencodePort,
+		Host:   net.JoinHostPort(sbx.PodIP, opencodeTargetPort(sbx, r)),
 	}
-	proxy := httputil.NewSingleHostReverseProxy(target)
-	proxy.FlushInterval = -1 // Enable SSE streaming.
-	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("subdomain proxy error for sandbox %s: %v", sandboxID, err)
-		http.Error(w, "proxy error", http.StatusBadGateway)
-	}
-	proxy.ServeHTTP(w, r)
+	s.newPodProxy(target, "subdomain", sbx).ServeHTTP(w, r)
 }
 
-// opencodeAPIPrefixes lists path segments that should always be proxied to
-// the opencode pod rather than served from the embedded frontend. A request
-// matches if its path equals the prefix exactly (e.g. "/project") or starts
-// with the prefix followed by "/" (e.g. "/project/current").
-var opencodeAPIPrefixes = []string{
-	"/global", "/auth", "/project", "/session", "/pty",
-	"/file", "/find", "/config", "/mcp", "/provider",
-	"/question", "/permission", "/tui", "/experimental",
-	"/doc", "/path", "/vcs", "/command", "/log",
-	"/agent", "/skill", "/lsp", "/formatter", "/event",
-	"/instance",
+// opencodeTargetPort picks the opencode server for a request. Sandboxes
+// created with projects run an extra server per project directory; requests
+// whose directory (the x-opencode-directory header or directory query
+// parameter the opencode client sends) is inside one go to that server.
+func opencodeTargetPort(sbx *sbxstore.Sandbox, r *http.Request) string {
+	dirs := sbx.MetadataStrings("opencode_projects")
+	if len(dirs) == 0 {
+		return opencodePort
+	}
+	workers, err := process.OpencodeWorkers(dirs)
+	if err != nil {
+		return opencodePort
+	}
+	directory := r.Header.Get("x-opencode-directory")
+	if directory == "" {
+		directory = r.URL.Query().Get("directory")
+	}
+	if decoded, err := url.PathUnescape(directory); err == nil {
+		directory = decoded
+	}
+	if port := process.OpencodeWorkerPort(workers, directory); port != 0 {
+		return strconv.Itoa(port)
+	}
+	return opencodePort
 }
 
 // tryServeOpencodeSPAFallback handles SPA client-side routes by serving
@@ -200,10 +221,8 @@ func (s *Server) tryServeOpencodeSPAFallback(w http.ResponseWriter, r *http.Requ
 	upath := path.Clean(r.URL.Path)
 
 	// If the path starts with a known API prefix, let the proxy handle it.
-	for _, prefix := range opencodeAPIPrefixes {
-		if upath == prefix || strings.HasPrefix(upath, prefix+"/") {
-			return false
-		}
+	if process.OpencodeAPIPrefix(upath) != "" {
+		return false
 	}
 
 	// If the path has a file extension but didn't match a real file, proxy it.
@@ -301,10 +320,20 @@ func (s *Server) handleAssetDomainRequest(w http.ResponseWriter, r *http.Request
 	s.serveOpencodeFile(w, r, filePath)
 }
 
-// setAssetCORSHeaders sets CORS headers for the shared asset domain.
-// Uses wildcard origin since these are public, cacheable static assets.
+// setAssetCORSHeaders sets CORS headers for the shared asset domain. Only
+// pages on the base domains get CORS access, and a same-site
+// Cross-Origin-Resource-Policy keeps other sites from loading the assets to
+// probe whether a visitor's cache holds them.
 func (s *Server) setAssetCORSHeaders(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Add("Vary", "Origin")
+	if s.assetDomainSameSite(s.routing().OpencodeAssetDomain) {
+		w.Header().Set("Cross-Origin-Resource-Policy", "same-site")
+	}
+	origin := r.Header.Get("Origin")
+	if !s.isSandboxOrigin(origin) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Origin, Accept, Content-Type")
 	w.Header().Set("Access-Control-Max-Age", "86400")
@@ -315,8 +344,10 @@ func (s *Server) setAssetCORSHeaders(w http.ResponseWriter, r *http.Request) {
 var crossoriginTagRe = regexp.MustCompile(`(<(?:script|link)\b[^>]*?)(/?>)`)
 
 // initOpencodeAssetIndex processes the embedded index.html at startup to add
-// crossorigin attributes to <script> and <link> tags. This is needed because
-// assets are loaded cross-origin from the shared asset domain.
+// crossorigin attributes to <script> and <link> tags, needed because assets
+// are loaded cross-origin from the shared asset domain, and Subresource
+// Integrity hashes for the embedded files they reference, so a tampered or
+// cache-poisoned asset on the shared domain is refused by the browser.
 func (s *Server) initOpencodeAssetIndex() {
 	if s.OpencodeStaticFS == nil || s.OpencodeAssetDomain == "" {
 		return
@@ -333,30 +364,33 @@ func (s *Server) initOpencodeAssetIndex() {
 		return
 	}
 
-	// Add crossorigin="anonymous" to <script> and <link> tags that reference
-	// the asset domain and don't already have crossorigin.
 	modified := crossoriginTagRe.ReplaceAllFunc(data, func(match []byte) []byte {
-		// Skip if already has crossorigin.
-		if bytes.Contains(match, []byte("crossorigin")) {
-			return match
-		}
-		// Only process tags that reference the asset domain or have src/href.
+		// Only process tags that reference a resource.
 		if !bytes.Contains(match, []byte("src=")) && !bytes.Contains(match, []byte("href=")) {
 			return match
 		}
-		// Insert crossorigin="anonymous" before the closing "/>".
 		parts := crossoriginTagRe.FindSubmatch(match)
 		if len(parts) < 3 {
 			return match
 		}
-		return append(append(parts[1], []byte(` crossorigin="anonymous"`)...), parts[2]...)
+		// Copy: parts alias data, which appending would overwrite.
+		tag := append([]byte{}, parts[1]...)
+		if !bytes.Contains(match, []byte("crossorigin")) {
+			tag = append(tag, ` crossorigin="anonymous"`...)
+		}
+		if !bytes.Contains(match, []byte("integrity=")) {
+			if sri := s.assetIntegrity(match); sri != "" {
+				tag = append(tag, ` integrity="`+sri+`"`...)
+			}
+		}
+		return append(tag, parts[2]...)
 	})
 
 	if bytes.Equal(data, modified) {
 		return
 	}
 
-	log.Printf("opencode: patched index.html with crossorigin attributes for asset domain %s", s.OpencodeAssetDomain)
+	log.Printf("opencode: patched index.html with crossorigin and integrity attributes for asset domain %s", s.OpencodeAssetDomain)
 
 	// Replace the embedded FS with a patched version that overlays index.html.
 	s.OpencodeStaticFS = &patchedFS{
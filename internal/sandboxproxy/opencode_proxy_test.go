@@ -0,0 +1,37 @@
+package sandboxproxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestOpencodeTargetPort(t *testing.T) {
+	sbx := &sbxstore.Sandbox{Metadata: map[string]interface{}{
+		"opencode_projects": []interface{}{"api", "web"},
+	}}
+	tests := []struct {
+		url, header string
+		want        string
+	}{
+		{"/session", "", "4096"},
+		{"/session", "/home/agent/projects/api", "4097"},
+		{"/session", "%2Fhome%2Fagent%2Fprojects%2Fweb%2Fsrc", "4098"},
+		{"/event?directory=%2Fhome%2Fagent%2Fprojects%2Fweb", "", "4098"},
+		{"/session", "/home/agent/projects/other", "4096"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.header != "" {
+			r.Header.Set("x-opencode-directory", tt.header)
+		}
+		if got := opencodeTargetPort(sbx, r); got != tt.want {
+			t.Errorf("%s (directory %q) = %s, want %s", tt.url, tt.header, got, tt.want)
+		}
+	}
+
+	if got := opencodeTargetPort(&sbxstore.Sandbox{}, httptest.NewRequest("GET", "/session", nil)); got != opencodePort {
+		t.Errorf("no projects: got %s", got)
+	}
+}
@@ -0,0 +1,45 @@
+package sandboxproxy
+
+import (
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+// pathACLDenies reports whether acls refuse role the request's opencode API
+// path.
+func pathACLDenies(acls map[string][]settings.PathRule, role string, r *http.Request) bool {
+	rules := acls[role]
+	if len(rules) == 0 {
+		return false
+	}
+	prefix := process.OpencodeAPIPrefix(path.Clean("/" + r.URL.Path))
+	if prefix == "" {
+		return false
+	}
+	for _, rule := range rules {
+		if rule.Denies(r.Method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyPathDenied applies the admin's proxy path ACLs to a workspace
+// member's request to an opencode sandbox. The member's role is only looked
+// up when ACLs are set; if that fails, the request is refused.
+func (s *Server) proxyPathDenied(r *http.Request, workspaceID, userID string) bool {
+	acls := s.routing().ProxyPathACLs
+	if len(acls) == 0 {
+		return false
+	}
+	role, err := s.DB.GetWorkspaceMemberRole(workspaceID, userID)
+	if err != nil {
+		log.Printf("subdomain proxy: failed to get role of user %s in workspace %s: %v", userID, workspaceID, err)
+		return true
+	}
+	return pathACLDenies(acls, role, r)
+}
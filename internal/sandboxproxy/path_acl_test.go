@@ -0,0 +1,38 @@
+package sandboxproxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+func TestPathACLDenies(t *testing.T) {
+	acls := map[string][]settings.PathRule{
+		"guest":     {{Prefix: "/pty"}, {Prefix: "/file", WriteOnly: true}},
+		"developer": {{Prefix: "/config"}},
+	}
+	tests := []struct {
+		role, method, url string
+		want              bool
+	}{
+		{"guest", "GET", "/pty/abc/connect", true},
+		{"guest", "GET", "/pty", true},
+		{"guest", "GET", "/file/content?path=a", false},
+		{"guest", "POST", "/file/content", true},
+		{"guest", "GET", "/session/./../pty", true},
+		{"guest", "GET", "/ptyx", false},
+		{"guest", "GET", "/config", false},
+		{"developer", "PATCH", "/config", true},
+		{"developer", "GET", "/config/providers", true},
+		{"developer", "GET", "/pty", false},
+		{"owner", "DELETE", "/config", false},
+		{"guest", "GET", "/", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.url, nil)
+		if got := pathACLDenies(acls, tt.role, r); got != tt.want {
+			t.Errorf("%s %s %s = %v, want %v", tt.role, tt.method, tt.url, got, tt.want)
+		}
+	}
+}
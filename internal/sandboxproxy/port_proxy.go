@@ -0,0 +1,143 @@
+package sandboxproxy
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+const portCookieKey = "port-token"
+
+// handlePortSubdomainProxy handles all requests on
+// port-{port}-{sandboxID}.{baseDomain}, the preview URL of a port exposed
+// with POST /api/sandboxes/{id}/ports.
+//
+// Auth follows the other apps: GET /auth?token=<main-site session> sets a
+// cookie scoped to this host, every other request is validated from it.
+// The request then goes to the port on the pod IP, or through the tunnel
+// for local agents, which serve it from their own host. Whatever runs on
+// the port is user code, so the session cookie is not forwarded to it.
+func (s *Server) handlePortSubdomainProxy(w http.ResponseWriter, r *http.Request, sandboxID string, port int) {
+	if r.URL.Path == "/auth" && r.Method == http.MethodGet && r.URL.Query().Has("token") {
+		s.exchangePortToken(w, r, sandboxID, port)
+		return
+	}
+
+	cookie, err := r.Cookie(portCookieKey)
+	if err != nil {
+		http.Redirect(w, r, "https://"+s.matchedBaseDomain(r)+"/", http.StatusFound)
+		return
+	}
+	userID, ok := s.Auth.ValidateToken(cookie.Value)
+	if !ok {
+		http.Redirect(w, r, "https://"+s.matchedBaseDomain(r)+"/", http.StatusFound)
+		return
+	}
+
+	sbx, found := s.Sandboxes.Resolve(sandboxID)
+	if !found {
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
+		return
+	}
+	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
+	if err != nil || !isMember {
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
+		return
+	}
+	exposed, err := s.DB.IsSandboxPortExposed(sbx.ID, port)
+	if err != nil {
+		log.Printf("port proxy: failed to check port %d of sandbox %s: %v", port, sbx.ID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !exposed {
+		s.writeErrorPage(w, r, errPagePortNotExposed, sbx)
+		return
+	}
+	if sbx.QuarantinedAt != nil {
+		s.writeErrorPage(w, r, errPageSandboxQuarantined, sbx)
+		return
+	}
+	if sbx.Status != "running" {
+		s.writeErrorPage(w, r, notRunningPage(sbx), sbx)
+		return
+	}
+
+	dropCookie(r, portCookieKey)
+	r.Header.Del("Authorization")
+	s.recordUsage(sbx, userID, db.SandboxActivityUser)
+
+	if sbx.IsLocal {
+		tunnel, ok := s.TunnelRegistry.Get(sbx.ID)
+		if !ok {
+			s.writeErrorPage(w, r, errPageAgentOffline, sbx)
+			return
+		}
+		s.proxyPortViaTunnel(w, r, sbx, tunnel, port)
+		return
+	}
+	if sbx.PodIP == "" {
+		s.writeErrorPage(w, r, errPagePodNotReady, sbx)
+		return
+	}
+
+	s.throttledActivity(sbx.ID)
+
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort(sbx.PodIP, strconv.Itoa(port))}
+	s.newPodProxy(target, "port", sbx).ServeHTTP(w, r)
+}
+
+func (s *Server) exchangePortToken(w http.ResponseWriter, r *http.Request, sandboxID string, port int) {
+	tok := r.URL.Query().Get("token")
+	if tok == "" {
+		http.Error(w, "missing token", http.StatusBadRequest)
+		return
+	}
+	userID, ok := s.Auth.ValidateToken(tok)
+	if !ok {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	sbx, found := s.Sandboxes.Resolve(sandboxID)
+	if !found {
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
+		return
+	}
+	isMember, err := s.DB.IsWorkspaceMember(sbx.WorkspaceID, userID)
+	if err != nil || !isMember {
+		s.writeErrorPage(w, r, errPageSandboxNotFound, nil)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     portCookieKey,
+		Value:    tok,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int((7 * 24 * time.Hour).Seconds()),
+	})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// dropCookie removes the cookie name from the request's Cookie headers.
+func dropCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	var kept []string
+	for _, c := range cookies {
+		if c.Name != name {
+			kept = append(kept, c.Name+"="+c.Value)
+		}
+	}
+	if len(kept) > 0 {
+		r.Header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
@@ -0,0 +1,82 @@
+package sandboxproxy
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// Defaults for retrying pod connections that are refused, which happens
+// right after a resume while the pod is ready but the agent is still
+// binding its port.
+const (
+	defaultProxyRetryAttempts = 3
+	defaultProxyRetryBackoff  = 200 * time.Millisecond
+)
+
+// retryTransport retries requests whose connection to the pod was refused,
+// doubling the delay between attempts. Only requests without a body are
+// retried: a refused dial never sends anything, but the transport closes
+// the body on failure so it cannot be replayed.
+type retryTransport struct {
+	base     http.RoundTripper
+	attempts int
+	backoff  time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := t.backoff
+	for i := 0; ; i++ {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil || i >= t.attempts || !isConnRefused(err) || (req.Body != nil && req.Body != http.NoBody) {
+			return resp, err
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// newPodProxy returns a reverse proxy to a sandbox pod that retries refused
+// connections and, if the pod still refuses them, renders the "starting"
+// page instead of a bare 502.
+func (s *Server) newPodProxy(target *url.URL, name string, sbx *sbxstore.Sandbox) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1 // Enable SSE streaming.
+	if s.ProxyRetryAttempts > 0 {
+		proxy.Transport = &retryTransport{
+			base:     http.DefaultTransport,
+			attempts: s.ProxyRetryAttempts,
+			backoff:  s.ProxyRetryBackoff,
+		}
+	}
+	if s.Capture.Enabled(sbx.ID) {
+		base := proxy.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		proxy.Transport = s.Capture.Transport(base, sbx.ID, name, sbx.OpencodeToken, sbx.ProxyToken, sbx.OpenclawToken)
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("%s proxy error for sandbox %s: %v", name, sbx.ID, err)
+		if isConnRefused(err) {
+			s.writeErrorPage(w, r, errPagePodNotReady, sbx)
+			return
+		}
+		http.Error(w, "proxy error", http.StatusBadGateway)
+	}
+	return proxy
+}
@@ -0,0 +1,89 @@
+package sandboxproxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func refused() error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connect: %w", syscall.ECONNREFUSED)}
+}
+
+func TestRetryTransportRetriesRefused(t *testing.T) {
+	calls := 0
+	rt := &retryTransport{
+		base: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			if calls < 3 {
+				return nil, refused()
+			}
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}),
+		attempts: 3,
+		backoff:  time.Millisecond,
+	}
+	resp, err := rt.RoundTrip(httptest.NewRequest("GET", "/", nil))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %v, %v", resp, err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	calls := 0
+	rt := &retryTransport{
+		base: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			calls++
+			return nil, refused()
+		}),
+		attempts: 2,
+		backoff:  time.Millisecond,
+	}
+	if _, err := rt.RoundTrip(httptest.NewRequest("GET", "/", nil)); !isConnRefused(err) {
+		t.Fatalf("err = %v, want connection refused", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+
+	// Requests with a body cannot be replayed.
+	calls = 0
+	rt.RoundTrip(httptest.NewRequest("POST", "/", strings.NewReader("x")))
+	if calls != 1 {
+		t.Errorf("calls with body = %d, want 1", calls)
+	}
+}
+
+func TestPodProxyRefusedShowsStartingPage(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	s := &Server{}
+	w := httptest.NewRecorder()
+	s.newPodProxy(&url.URL{Scheme: "http", Host: addr}, "test", &sbxstore.Sandbox{ID: "sbx"}).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), errPagePodNotReady.Title) {
+		t.Errorf("body does not contain the starting page")
+	}
+}
@@ -0,0 +1,59 @@
+package sandboxproxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// hostDomains returns the domains sandbox hosts are served under: the base
+// domains, then the region domains not already among them.
+func (s *Server) hostDomains() []string {
+	if len(s.RegionDomains) == 0 {
+		return s.BaseDomains
+	}
+	domains := append([]string(nil), s.BaseDomains...)
+	seen := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		seen[strings.ToLower(d)] = true
+	}
+	for _, d := range s.RegionDomains {
+		if !seen[d] {
+			seen[d] = true
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// redirectToRegion sends a request for a sandbox placed in another region
+// to the same subdomain under that region's domain, which resolves to the
+// proxy co-located with the sandbox. Requests already on the region domain,
+// or for sandboxes of this proxy's region or without a region domain, are
+// served here. It reports whether it redirected.
+func (s *Server) redirectToRegion(w http.ResponseWriter, r *http.Request, rt hostRoute) bool {
+	if len(s.RegionDomains) == 0 {
+		return false
+	}
+	sbx, ok := s.Sandboxes.Resolve(rt.sandboxID)
+	if !ok {
+		return false
+	}
+	region := sbx.MetadataString(process.RegionMetadataKey)
+	domain := s.RegionDomains[region]
+	if domain == "" || region == s.Region || strings.EqualFold(rt.domain, domain) {
+		return false
+	}
+	target := regionURL(r, rt, domain)
+	http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	return true
+}
+
+// regionURL is the request's URL with the matched base domain of its host
+// replaced by domain.
+func regionURL(r *http.Request, rt hostRoute, domain string) string {
+	host, _ := normalizeHost(r.Host)
+	sub := strings.TrimSuffix(host, "."+strings.ToLower(domainHost(rt.domain)))
+	return "https://" + sub + "." + domain + r.URL.RequestURI()
+}
@@ -0,0 +1,37 @@
+package sandboxproxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+func TestRegionURL(t *testing.T) {
+	s := &Server{
+		BaseDomains:   []string{"example.com"},
+		RegionDomains: map[string]string{"eu-west-1": "eu.example.com", "us-east-1": "example.us"},
+	}
+	h := newHostRouter(s.hostDomains(), settings.Settings{OpencodeSubdomainPrefix: "code"})
+	for _, tc := range []struct {
+		host, domain, want string
+	}{
+		{"code-abc.example.com", "eu.example.com", "https://code-abc.eu.example.com/p?q=1"},
+		{"Code-ABC.Example.com:443", "example.us", "https://code-abc.example.us/p?q=1"},
+		{"code-abc.eu.example.com", "example.us", "https://code-abc.example.us/p?q=1"},
+	} {
+		r := httptest.NewRequest("GET", "/p?q=1", nil)
+		r.Host = tc.host
+		rt := h.route(tc.host)
+		if rt.kind != hostSandbox || rt.sandboxID != "abc" {
+			t.Errorf("route(%q) = %+v, want sandbox abc", tc.host, rt)
+			continue
+		}
+		if got := regionURL(r, rt, tc.domain); got != tc.want {
+			t.Errorf("regionURL(%q, %q) = %q, want %q", tc.host, tc.domain, got, tc.want)
+		}
+	}
+	if got := h.route("code-abc.eu.example.com").domain; got != "eu.example.com" {
+		t.Errorf("region domain host matched %q, want eu.example.com", got)
+	}
+}
@@ -3,14 +3,18 @@ package sandboxproxy
 import (
 	"context"
 	"io/fs"
+	"log"
+	"net"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/clock"
 	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/proxycapture"
 	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/settings"
 	"github.com/agentserver/agentserver/internal/tunnel"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -32,6 +36,16 @@ func (s *Server) matchedBaseDomain(r *http.Request) string {
 	return ""
 }
 
+// domainHost strips an optional ":port" from a configured domain. Base
+// domains carry a port only in local development (`serve --dev`), where it
+// must survive into redirects but not take part in host matching.
+func domainHost(d string) string {
+	if host, _, err := net.SplitHostPort(d); err == nil {
+		return host
+	}
+	return d
+}
+
 // Server is the sandbox-proxy HTTP server that handles subdomain traffic
 // proxying and WebSocket tunnel connections.
 type Server struct {
@@ -46,9 +60,44 @@ type Server struct {
 	OpenclawSubdomainPrefix   string
 	ClaudeCodeSubdomainPrefix string
 	JupyterSubdomainPrefix    string
+	// Settings, when set, supplies admin overrides of the prefixes and
+	// asset domain above.
+	Settings *settings.Manager
+	// ProxyRetryAttempts is how many times a refused pod connection is
+	// retried, starting ProxyRetryBackoff apart and doubling; 0 disables
+	// retries.
+	ProxyRetryAttempts int
+	ProxyRetryBackoff  time.Duration
+	// Capture, when set, records proxied traffic of selected sandboxes.
+	Capture *proxycapture.Recorder
+	// TunnelBandwidthLimit is the default tunnel cap in bytes per second.
+	TunnelBandwidthLimit int64
+	// MetricsToken guards /metrics; empty disables it.
+	MetricsToken string
+	// AuthCheckToken guards /auth-check, so only the edge proxy can ask;
+	// empty disables it.
+	AuthCheckToken string
+	// Region is the region this proxy runs in. Requests for sandboxes of
+	// other regions listed in RegionDomains are redirected to their
+	// region's domain.
+	Region        string
+	RegionDomains map[string]string
+	// Clock drives activity throttling and tunnel heartbeats; nil is the
+	// system clock.
+	Clock clock.Clock
+	// Branding customizes error pages.
+	Branding ErrorPageBranding
+	// LegacyDomains and LegacyPrefixes map former base domains and
+	// subdomain prefixes to current ones; requests on their hosts are
+	// redirected.
+	LegacyDomains  map[string]string
+	LegacyPrefixes map[string]string
 
 	activityMu   sync.Mutex
 	activityLast map[string]time.Time
+
+	usageMu   sync.Mutex
+	usageLast map[string]time.Time
 }
 
 // New creates a new sandbox-proxy server.
@@ -65,17 +114,60 @@ func New(cfg Config, authSvc *auth.Auth, database *db.DB, sandboxStore *sbxstore
 		OpenclawSubdomainPrefix:   cfg.OpenclawSubdomainPrefix,
 		ClaudeCodeSubdomainPrefix: cfg.ClaudeCodeSubdomainPrefix,
 		JupyterSubdomainPrefix:    cfg.JupyterSubdomainPrefix,
+		ProxyRetryAttempts:        cfg.ProxyRetryAttempts,
+		ProxyRetryBackoff:         cfg.ProxyRetryBackoff,
+		TunnelBandwidthLimit:      cfg.TunnelBandwidthLimit,
+		MetricsToken:              cfg.MetricsToken,
+		AuthCheckToken:            cfg.AuthCheckToken,
+		Region:                    cfg.Region,
+		RegionDomains:             cfg.RegionDomains,
+		Branding:                  cfg.Branding,
+		LegacyDomains:             cfg.LegacyDomains,
+		LegacyPrefixes:            cfg.LegacyPrefixes,
 		activityLast:            make(map[string]time.Time),
+		usageLast:               make(map[string]time.Time),
+	}
+	if database != nil {
+		s.Settings = settings.NewManager(database, settings.Settings{
+			OpencodeSubdomainPrefix:   cfg.OpencodeSubdomainPrefix,
+			OpenclawSubdomainPrefix:   cfg.OpenclawSubdomainPrefix,
+			ClaudeCodeSubdomainPrefix: cfg.ClaudeCodeSubdomainPrefix,
+			JupyterSubdomainPrefix:    cfg.JupyterSubdomainPrefix,
+			OpencodeAssetDomain:       cfg.OpencodeAssetDomain,
+		})
+	}
+	if cfg.CaptureDir != "" && len(cfg.CaptureSandboxes) > 0 {
+		rec, err := proxycapture.NewRecorder(cfg.CaptureDir, cfg.CaptureSandboxes)
+		if err != nil {
+			log.Printf("traffic capture disabled: %v", err)
+		} else {
+			log.Printf("WARNING: recording proxied traffic of %v to %s", cfg.CaptureSandboxes, cfg.CaptureDir)
+			s.Capture = rec
+		}
 	}
 	s.initOpencodeAssetIndex()
 	return s
 }
 
+// routing returns the current subdomain prefixes and asset domain.
+func (s *Server) routing() settings.Settings {
+	if s.Settings != nil {
+		return s.Settings.Get()
+	}
+	return settings.Settings{
+		OpencodeSubdomainPrefix:   s.OpencodeSubdomainPrefix,
+		OpenclawSubdomainPrefix:   s.OpenclawSubdomainPrefix,
+		ClaudeCodeSubdomainPrefix: s.ClaudeCodeSubdomainPrefix,
+		JupyterSubdomainPrefix:    s.JupyterSubdomainPrefix,
+		OpencodeAssetDomain:       s.OpencodeAssetDomain,
+	}
+}
+
 // throttledActivity updates activity at most once per 30 seconds per sandbox.
 func (s *Server) throttledActivity(sandboxID string) {
 	s.activityMu.Lock()
 	last, ok := s.activityLast[sandboxID]
-	now := time.Now()
+	now := clock.Or(s.Clock).Now()
 	if ok && now.Sub(last) < 30*time.Second {
 		s.activityMu.Unlock()
 		return
@@ -93,63 +185,61 @@ func (s *Server) Router() http.Handler {
 
 	// Subdomain middleware: if the Host matches {prefix}-{sandboxID}.{baseDomain},
 	// proxy the entire request to the sandbox and skip all other routes.
-	// Supports multiple base domains.
+	// Supports multiple base domains. Hosts under legacy base domains or
+	// with legacy prefixes are redirected to their current host. Other hosts
+	// under a base domain get a 404, and hosts outside them a 421 unless a
+	// route below matches.
 	if len(s.BaseDomains) > 0 {
 		r.Use(func(next http.Handler) http.Handler {
-			type domainEntry struct {
-				suffix string
-				domain string
-			}
-			entries := make([]domainEntry, len(s.BaseDomains))
-			for i, d := range s.BaseDomains {
-				entries[i] = domainEntry{suffix: "." + d, domain: d}
-			}
-			opcodePrefix := s.OpencodeSubdomainPrefix + "-"
-			clawPrefix := s.OpenclawSubdomainPrefix + "-"
-			claudePrefix := s.ClaudeCodeSubdomainPrefix + "-"
-			jupyterPrefix := s.JupyterSubdomainPrefix + "-"
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				host := r.Host
-				if idx := strings.LastIndex(host, ":"); idx != -1 {
-					host = host[:idx]
+				router := newHostRouter(s.hostDomains(), s.routing())
+				if s.redirectLegacyHost(w, r, router) {
+					return
 				}
-				for _, e := range entries {
-					if !strings.HasSuffix(host, e.suffix) {
-						continue
-					}
-					sub := strings.TrimSuffix(host, e.suffix)
+				rt := router.route(r.Host)
+				if rt.domain != "" {
 					// Store matched domain in context for login redirects.
-					ctx := context.WithValue(r.Context(), matchedDomainKey, e.domain)
-					r = r.WithContext(ctx)
-
-					if s.OpencodeAssetDomain != "" && host == s.OpencodeAssetDomain {
-						s.handleAssetDomainRequest(w, r)
-						return
-					}
-					if strings.HasPrefix(sub, opcodePrefix) {
-						sandboxID := sub[len(opcodePrefix):]
-						s.handleSubdomainProxy(w, r, sandboxID)
-						return
-					}
-					if strings.HasPrefix(sub, clawPrefix) {
-						sandboxID := sub[len(clawPrefix):]
-						s.handleOpenclawSubdomainProxy(w, r, sandboxID)
+					r = r.WithContext(context.WithValue(r.Context(), matchedDomainKey, rt.domain))
+				}
+				switch rt.kind {
+				case hostAsset:
+					s.handleAssetDomainRequest(w, r)
+					return
+				case hostUnknown:
+					http.Error(w, "unknown sandbox host", http.StatusNotFound)
+					return
+				case hostSandbox:
+					if s.redirectToRegion(w, r, rt) {
 						return
 					}
-					if strings.HasPrefix(sub, claudePrefix) {
-						sandboxID := sub[len(claudePrefix):]
-						s.handleClaudeCodeSubdomainProxy(w, r, sandboxID)
+					if r.URL.Path == statusPath {
+						s.handleSandboxStatus(w, r, rt.sandboxID)
 						return
 					}
-					if strings.HasPrefix(sub, jupyterPrefix) {
-						sandboxID := sub[len(jupyterPrefix):]
-						s.handleJupyterSubdomainProxy(w, r, sandboxID)
-						return
+					switch rt.app {
+					case appOpencode:
+						s.handleSubdomainProxy(w, r, rt.sandboxID)
+					case appOpenclaw:
+						s.handleOpenclawSubdomainProxy(w, r, rt.sandboxID)
+					case appClaudeCode:
+						s.handleClaudeCodeSubdomainProxy(w, r, rt.sandboxID)
+					case appJupyter:
+						s.handleJupyterSubdomainProxy(w, r, rt.sandboxID)
+					case appPort:
+						s.handlePortSubdomainProxy(w, r, rt.sandboxID, rt.port)
 					}
+					return
 				}
 				next.ServeHTTP(w, r)
 			})
 		})
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(matchedDomainKey).(string); !ok {
+				http.Error(w, "misdirected request", http.StatusMisdirectedRequest)
+				return
+			}
+			http.NotFound(w, r)
+		})
 	}
 
 	// Health check.
@@ -157,6 +247,13 @@ func (s *Server) Router() http.Handler {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// Prometheus metrics of local agent tunnels (bearer METRICS_TOKEN).
+	r.Get("/metrics", s.handleMetrics)
+
+	// Authorization checks for edge proxies (nginx auth_request, Traefik
+	// ForwardAuth; X-Auth-Check-Token AUTH_CHECK_TOKEN).
+	r.Get(authCheckPath, s.handleAuthCheck)
+
 	// Tunnel endpoint (auth via tunnel token, no cookie auth needed).
 	r.HandleFunc("/api/tunnel/{sandboxId}", s.handleTunnel)
 
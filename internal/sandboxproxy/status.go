@@ -0,0 +1,104 @@
+package sandboxproxy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// statusPath is served on every sandbox subdomain without authentication.
+const statusPath = "/__status"
+
+// Coarse sandbox states reported on statusPath.
+const (
+	sandboxReachable   = "reachable"   // running and answering on its port
+	sandboxUnreachable = "unreachable" // running but not answering
+	sandboxStarting    = "starting"
+	sandboxPaused      = "paused"
+	sandboxOffline     = "offline" // local agent disconnected, or stopped
+	sandboxUnavailable = "unavailable"
+	sandboxUnknown     = "unknown"
+)
+
+// sandboxPort returns the port the proxy dials for a cloud sandbox type.
+func sandboxPort(sandboxType string) string {
+	switch sandboxType {
+	case "openclaw":
+		return openclawPort
+	case "claudecode":
+		return claudecodePort
+	case "jupyter":
+		return jupyterPort
+	default:
+		return opencodePort
+	}
+}
+
+// sandboxState maps a sandbox to the coarse state published on statusPath.
+// dial checks whether a running cloud sandbox accepts connections.
+func (s *Server) sandboxState(ctx context.Context, sbx *sbxstore.Sandbox, dial func(ctx context.Context, addr string) error) string {
+	if sbx.QuarantinedAt != nil {
+		return sandboxUnavailable
+	}
+	switch sbx.Status {
+	case sbxstore.StatusRunning:
+		if sbx.IsLocal {
+			if _, ok := s.TunnelRegistry.Get(sbx.ID); ok {
+				return sandboxReachable
+			}
+			return sandboxOffline
+		}
+		if sbx.PodIP == "" {
+			return sandboxStarting
+		}
+		if err := dial(ctx, net.JoinHostPort(sbx.PodIP, sandboxPort(sbx.Type))); err != nil {
+			return sandboxUnreachable
+		}
+		return sandboxReachable
+	case sbxstore.StatusCreating, sbxstore.StatusResuming, sbxstore.StatusProvisioningStorage:
+		return sandboxStarting
+	case sbxstore.StatusPaused, sbxstore.StatusPausing:
+		return sandboxPaused
+	case sbxstore.StatusOffline:
+		return sandboxOffline
+	default: // deleting, storage-failed, unschedulable
+		return sandboxUnavailable
+	}
+}
+
+func dialTCP(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// handleSandboxStatus reports whether a sandbox is up, paused or offline so
+// users can tell a sandbox problem from a platform outage. It is public and
+// deliberately reveals nothing beyond the coarse state: no names, owners,
+// addresses or error details.
+func (s *Server) handleSandboxStatus(w http.ResponseWriter, r *http.Request, sandboxID string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	state := sandboxUnknown
+	code := http.StatusNotFound
+	if sbx, ok := s.Sandboxes.Resolve(sandboxID); ok {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		state = s.sandboxState(ctx, sbx, dialTCP)
+		cancel()
+		code = http.StatusServiceUnavailable
+		if state == sandboxReachable {
+			code = http.StatusOK
+		}
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"status": state})
+}
@@ -0,0 +1,40 @@
+package sandboxproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/tunnel"
+)
+
+func TestSandboxState(t *testing.T) {
+	s := &Server{TunnelRegistry: tunnel.NewRegistry()}
+	up := func(context.Context, string) error { return nil }
+	down := func(context.Context, string) error { return errors.New("refused") }
+	now := time.Now()
+
+	cases := []struct {
+		name string
+		sbx  sbxstore.Sandbox
+		dial func(context.Context, string) error
+		want string
+	}{
+		{"running", sbxstore.Sandbox{Status: sbxstore.StatusRunning, PodIP: "10.0.0.1"}, up, sandboxReachable},
+		{"running, not answering", sbxstore.Sandbox{Status: sbxstore.StatusRunning, PodIP: "10.0.0.1"}, down, sandboxUnreachable},
+		{"running, no pod yet", sbxstore.Sandbox{Status: sbxstore.StatusRunning}, up, sandboxStarting},
+		{"local, no tunnel", sbxstore.Sandbox{ID: "x", Status: sbxstore.StatusRunning, IsLocal: true}, up, sandboxOffline},
+		{"paused", sbxstore.Sandbox{Status: sbxstore.StatusPaused}, up, sandboxPaused},
+		{"resuming", sbxstore.Sandbox{Status: sbxstore.StatusResuming}, up, sandboxStarting},
+		{"offline", sbxstore.Sandbox{Status: sbxstore.StatusOffline}, up, sandboxOffline},
+		{"storage failed", sbxstore.Sandbox{Status: sbxstore.StatusStorageFailed}, up, sandboxUnavailable},
+		{"quarantined", sbxstore.Sandbox{Status: sbxstore.StatusRunning, PodIP: "10.0.0.1", QuarantinedAt: &now}, up, sandboxUnavailable},
+	}
+	for _, c := range cases {
+		if got := s.sandboxState(context.Background(), &c.sbx, c.dial); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
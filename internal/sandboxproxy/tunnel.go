@@ -6,12 +6,15 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"encoding/base64"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/clock"
 	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/proxycapture"
 	"github.com/agentserver/agentserver/internal/sbxstore"
 	"github.com/agentserver/agentserver/internal/tunnel"
 	"nhooyr.io/websocket"
@@ -39,6 +42,10 @@ func (s *Server) handleTunnel(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if sbx.QuarantinedAt.Valid {
+		http.Error(w, "sandbox quarantined", http.StatusForbidden)
+		return
+	}
 
 	// Accept WebSocket upgrade.
 	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{
@@ -51,6 +58,8 @@ func (s *Server) handleTunnel(w http.ResponseWriter, r *http.Request) {
 
 	// Register tunnel with WSConn + yamux.
 	t := s.TunnelRegistry.Register(r.Context(), sandboxID, ws)
+	s.applyTunnelBandwidthLimit(t)
+	flush := &tunnelFlush{}
 
 	// Set up agent info callback.
 	t.OnAgentInfo = func(data json.RawMessage) {
@@ -90,7 +99,7 @@ func (s *Server) handleTunnel(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	go func() {
-		ticker := time.NewTicker(20 * time.Second)
+		ticker := clock.Or(s.Clock).NewTicker(20 * time.Second)
 		defer ticker.Stop()
 		for {
 			select {
@@ -98,8 +107,10 @@ func (s *Server) handleTunnel(w http.ResponseWriter, r *http.Request) {
 				return
 			case <-t.Done():
 				return
-			case <-ticker.C:
+			case <-ticker.C():
 				s.DB.UpdateSandboxHeartbeat(sandboxID)
+				s.flushTunnelStats(t, flush, true)
+				s.applyTunnelBandwidthLimit(t)
 				// WebSocket-level ping (handled by nhooyr/websocket automatically).
 				pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
 				if err := ws.Ping(pingCtx); err != nil {
@@ -122,6 +133,11 @@ func (s *Server) handleTunnel(w http.ResponseWriter, r *http.Request) {
 	// Cleanup: only set offline if this tunnel is still the active one.
 	wasActive := s.TunnelRegistry.Unregister(sandboxID, t)
 	t.Close()
+	// A replaced tunnel's last seconds of traffic are dropped rather than
+	// overwriting its successor's row.
+	if wasActive {
+		s.flushTunnelStats(t, flush, false)
+	}
 
 	if wasActive {
 		s.Sandboxes.UpdateStatus(sandboxID, sbxstore.StatusOffline)
@@ -131,6 +147,17 @@ func (s *Server) handleTunnel(w http.ResponseWriter, r *http.Request) {
 
 // proxyViaTunnel forwards an HTTP request through the yamux tunnel to the local agent.
 func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbxstore.Sandbox, t *tunnel.Tunnel) {
+	s.proxyPortViaTunnel(w, r, sbx, t, 0)
+}
+
+// proxyPortViaTunnel forwards an HTTP request through the tunnel to port on
+// the local agent's host, or to the agent's own handler if port is 0.
+func (s *Server) proxyPortViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbxstore.Sandbox, t *tunnel.Tunnel, port int) {
+	if isWebSocketUpgrade(r) {
+		s.proxyWebSocketViaTunnel(w, r, sbx, t, port)
+		return
+	}
+
 	// Read request body.
 	var body []byte
 	if r.Body != nil {
@@ -151,7 +178,7 @@ func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbx
 	}
 
 	// Inject opencode Basic Auth (only for opencode type sandboxes).
-	if sbx.Type == "opencode" && sbx.OpencodeToken != "" {
+	if port == 0 && sbx.Type == "opencode" && sbx.OpencodeToken != "" {
 		cred := base64.StdEncoding.EncodeToString([]byte("opencode:" + sbx.OpencodeToken))
 		headers["Authorization"] = "Basic " + cred
 	}
@@ -160,6 +187,7 @@ func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbx
 		Method:  r.Method,
 		Path:    r.URL.RequestURI(),
 		Headers: headers,
+		Port:    port,
 	}
 
 	// Track activity.
@@ -168,8 +196,18 @@ func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbx
 	ctx, cancel := context.WithTimeout(r.Context(), 120*time.Second)
 	defer cancel()
 
+	var capture *proxycapture.Capture
+	if s.Capture.Enabled(sbx.ID) {
+		capHeader := make(http.Header, len(headers))
+		for k, v := range headers {
+			capHeader.Set(k, v)
+		}
+		capture = s.Capture.Start(sbx.ID, "tunnel", meta.Method, meta.Path, capHeader, body, sbx.OpencodeToken, sbx.ProxyToken)
+	}
+
 	respMeta, respBody, err := t.OpenHTTPStream(ctx, meta, body)
 	if err != nil {
+		capture.Done(err)
 		log.Printf("tunnel proxy error for %s: %v", t.SandboxID, err)
 		http.Error(w, "tunnel proxy error", http.StatusBadGateway)
 		return
@@ -183,6 +221,8 @@ func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbx
 	if respMeta.Status > 0 {
 		w.WriteHeader(respMeta.Status)
 	}
+	capture.Response(respMeta.Status, w.Header())
+	defer capture.Done(nil)
 
 	// Stream response body with flushing for SSE support.
 	flusher, _ := w.(http.Flusher)
@@ -190,6 +230,7 @@ func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbx
 	for {
 		n, readErr := respBody.Read(buf)
 		if n > 0 {
+			capture.Chunk(buf[:n])
 			w.Write(buf[:n])
 			if flusher != nil {
 				flusher.Flush()
@@ -200,3 +241,84 @@ func (s *Server) proxyViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbx
 		}
 	}
 }
+
+// isWebSocketUpgrade reports whether r asks to upgrade to a WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// proxyWebSocketViaTunnel forwards a browser WebSocket through the tunnel:
+// the agent dials the WebSocket on its side first, and only once it is up
+// is the browser's upgrade accepted, with the subprotocol the agent got.
+// Messages are then passed through one by one until either side closes.
+func (s *Server) proxyWebSocketViaTunnel(w http.ResponseWriter, r *http.Request, sbx *sbxstore.Sandbox, t *tunnel.Tunnel, port int) {
+	headers := make(map[string]string)
+	for key, vals := range r.Header {
+		if len(vals) > 0 && !tunnel.IsWebSocketHandshakeHeader(key) {
+			headers[key] = vals[0]
+		}
+	}
+	if port == 0 && sbx.Type == "opencode" && sbx.OpencodeToken != "" {
+		cred := base64.StdEncoding.EncodeToString([]byte("opencode:" + sbx.OpencodeToken))
+		headers["Authorization"] = "Basic " + cred
+	}
+	meta := tunnel.HTTPStreamMeta{
+		Method:  r.Method,
+		Path:    r.URL.RequestURI(),
+		Headers: headers,
+		Port:    port,
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	respMeta, stream, err := t.OpenWebSocketStream(ctx, meta)
+	cancel()
+	if err != nil {
+		log.Printf("tunnel websocket error for %s: %v", t.SandboxID, err)
+		http.Error(w, "tunnel proxy error", http.StatusBadGateway)
+		return
+	}
+	defer stream.Close()
+	if respMeta.Status != http.StatusSwitchingProtocols {
+		for k, v := range respMeta.Headers {
+			w.Header().Set(k, v)
+		}
+		status := respMeta.Status
+		if status == 0 {
+			status = http.StatusBadGateway
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	var subprotocols []string
+	if p := respMeta.Headers["Sec-WebSocket-Protocol"]; p != "" {
+		subprotocols = []string{p}
+	}
+	browserWS, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: subprotocols})
+	if err != nil {
+		log.Printf("tunnel websocket accept error for %s: %v", t.SandboxID, err)
+		return
+	}
+
+	// Keep the sandbox active while the socket is open, as for terminals.
+	s.throttledActivity(sbx.ID)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.throttledActivity(sbx.ID)
+			}
+		}
+	}()
+
+	// Independent of the request context, like the terminal bridge.
+	if err := tunnel.BridgeWebSocket(context.Background(), browserWS, stream); err != nil {
+		log.Printf("tunnel websocket for %s ended: %v", t.SandboxID, err)
+	}
+}
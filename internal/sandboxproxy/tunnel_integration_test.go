@@ -0,0 +1,136 @@
+//go:build integration
+
+package sandboxproxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"nhooyr.io/websocket"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+	"github.com/agentserver/agentserver/internal/tunnel"
+)
+
+func TestMain(m *testing.M) { os.Exit(testenv.Run(m)) }
+
+// TestIntegration_TunnelRegistration connects a fake local agent to the
+// tunnel endpoint and checks token auth, registration, proxying through the
+// tunnel and the cleanup on disconnect.
+func TestIntegration_TunnelRegistration(t *testing.T) {
+	d := testenv.DB(t)
+	reg := tunnel.NewRegistry()
+	s := New(Config{}, nil, d, sbxstore.NewStore(d), reg, nil)
+	srv := httptest.NewServer(s.Router())
+	defer srv.Close()
+
+	wsID := uuid.NewString()
+	sbxID := uuid.NewString()
+	if err := d.CreateWorkspace(wsID, "tunnel"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID) })
+	if err := d.CreateLocalSandbox(sbxID, wsID, "laptop", "opencode", "oc-token", "proxy-token", "tunnel-token", ""); err != nil {
+		t.Fatal(err)
+	}
+	tunnelURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/tunnel/" + sbxID + "?token="
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, resp, err := websocket.Dial(ctx, tunnelURL+"wrong", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial with wrong token: resp=%v err=%v, want 401", resp, err)
+	}
+
+	ws, _, err := websocket.Dial(ctx, tunnelURL+"tunnel-token", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	session, err := tunnel.ClientMux(tunnel.NewWSConn(ctx, ws))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go serveAgent(session)
+
+	var tun *tunnel.Tunnel
+	waitFor(t, "tunnel registration", func() bool {
+		var ok bool
+		tun, ok = reg.Get(sbxID)
+		return ok
+	})
+	sbx, err := d.GetSandbox(sbxID)
+	if err != nil || sbx == nil || sbx.Status != sbxstore.StatusRunning {
+		t.Fatalf("sandbox after connect = %+v, %v; want running", sbx, err)
+	}
+
+	meta, body, err := tun.OpenHTTPStream(ctx, tunnel.HTTPStreamMeta{Method: "GET", Path: "/"}, nil)
+	if err != nil {
+		t.Fatalf("request through tunnel: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if meta.Status != http.StatusOK || string(got) != "ok" {
+		t.Errorf("tunnel response = %d %q, want 200 \"ok\"", meta.Status, got)
+	}
+
+	session.Close()
+	waitFor(t, "tunnel unregistration", func() bool {
+		_, ok := reg.Get(sbxID)
+		return !ok
+	})
+	waitFor(t, "sandbox to go offline", func() bool {
+		sbx, err := d.GetSandbox(sbxID)
+		return err == nil && sbx != nil && sbx.Status == sbxstore.StatusOffline
+	})
+	stats, err := d.ListTunnelStatsByWorkspace(wsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := stats[sbxID]
+	if st == nil || st.Connected || st.Requests != 1 || st.BytesInTotal == 0 {
+		t.Errorf("tunnel stats after disconnect = %+v", st)
+	}
+}
+
+// serveAgent answers every HTTP stream the server opens with "ok".
+func serveAgent(session interface{ Accept() (net.Conn, error) }) {
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer stream.Close()
+			_, raw, err := tunnel.ReadStreamHeader(stream)
+			if err != nil {
+				return
+			}
+			var meta tunnel.HTTPStreamMeta
+			tunnel.UnmarshalStreamMeta(raw, &meta)
+			io.CopyN(io.Discard, stream, int64(meta.BodyLen))
+			resp, _ := tunnel.MarshalStreamMeta(tunnel.HTTPResponseMeta{Status: http.StatusOK})
+			tunnel.WriteStreamHeader(stream, tunnel.StreamTypeHTTP, resp)
+			stream.Write([]byte("ok"))
+		}()
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,112 @@
+package sandboxproxy
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/tunnel"
+)
+
+// tunnelFlush remembers what a tunnel's stats were at the last flush, so
+// only the difference is added to the lifetime totals.
+type tunnelFlush struct {
+	mu   sync.Mutex
+	last tunnel.Stats
+}
+
+// flushTunnelStats saves a tunnel's counters for the agents API.
+func (s *Server) flushTunnelStats(t *tunnel.Tunnel, f *tunnelFlush, connected bool) {
+	if s.DB == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := t.Stats()
+	connectedAt := st.ConnectedAt
+	rec := &db.TunnelStats{
+		SandboxID:     t.SandboxID,
+		Connected:     connected,
+		ConnectedAt:   &connectedAt,
+		BytesIn:       st.BytesIn,
+		BytesOut:      st.BytesOut,
+		ActiveStreams: int(st.ActiveStreams),
+		Requests:      st.Requests,
+		RequestErrors: st.RequestErrors,
+		AvgLatencyMs:  int(st.AvgLatency().Milliseconds()),
+	}
+	if err := s.DB.SaveTunnelStats(rec, st.BytesIn-f.last.BytesIn, st.BytesOut-f.last.BytesOut); err != nil {
+		log.Printf("tunnel %s: failed to save stats: %v", t.SandboxID, err)
+		return
+	}
+	f.last = st
+}
+
+// applyTunnelBandwidthLimit caps a tunnel at the sandbox's own limit, or
+// the proxy default.
+func (s *Server) applyTunnelBandwidthLimit(t *tunnel.Tunnel) {
+	limit := s.TunnelBandwidthLimit
+	if s.DB != nil {
+		n, err := s.DB.GetSandboxTunnelBandwidthLimit(t.SandboxID)
+		if err != nil {
+			log.Printf("tunnel %s: %v", t.SandboxID, err)
+		} else if n > 0 {
+			limit = n
+		}
+	}
+	t.SetBandwidthLimit(limit)
+}
+
+// handleMetrics serves tunnel metrics in the Prometheus text format.
+// GET /metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.MetricsToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeTunnelMetrics(w, s.TunnelRegistry.All())
+}
+
+func writeTunnelMetrics(w io.Writer, tunnels []*tunnel.Tunnel) {
+	sort.Slice(tunnels, func(i, j int) bool { return tunnels[i].SandboxID < tunnels[j].SandboxID })
+	stats := make([]tunnel.Stats, len(tunnels))
+	for i, t := range tunnels {
+		stats[i] = t.Stats()
+	}
+
+	metric := func(name, typ, help string, value func(st tunnel.Stats) string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for i, t := range tunnels {
+			fmt.Fprintf(w, "%s{sandbox_id=%q} %s\n", name, t.SandboxID, value(stats[i]))
+		}
+	}
+	fmt.Fprintf(w, "# HELP agentserver_tunnels Connected local agent tunnels.\n# TYPE agentserver_tunnels gauge\nagentserver_tunnels %d\n", len(tunnels))
+
+	fmt.Fprintf(w, "# HELP agentserver_tunnel_bytes_total Bytes transferred over a tunnel, including framing.\n# TYPE agentserver_tunnel_bytes_total counter\n")
+	for i, t := range tunnels {
+		fmt.Fprintf(w, "agentserver_tunnel_bytes_total{sandbox_id=%q,direction=\"in\"} %d\n", t.SandboxID, stats[i].BytesIn)
+		fmt.Fprintf(w, "agentserver_tunnel_bytes_total{sandbox_id=%q,direction=\"out\"} %d\n", t.SandboxID, stats[i].BytesOut)
+	}
+	metric("agentserver_tunnel_active_streams", "gauge", "Open HTTP and terminal streams.",
+		func(st tunnel.Stats) string { return fmt.Sprint(st.ActiveStreams) })
+	metric("agentserver_tunnel_requests_total", "counter", "HTTP requests proxied over a tunnel.",
+		func(st tunnel.Stats) string { return fmt.Sprint(st.Requests) })
+	metric("agentserver_tunnel_request_errors_total", "counter", "HTTP requests that failed before a response header.",
+		func(st tunnel.Stats) string { return fmt.Sprint(st.RequestErrors) })
+	metric("agentserver_tunnel_request_duration_seconds_sum", "counter", "Total time to response header of successful requests.",
+		func(st tunnel.Stats) string { return fmt.Sprint(st.LatencySum.Seconds()) })
+	metric("agentserver_tunnel_bandwidth_limit_bytes", "gauge", "Bandwidth cap per direction in bytes per second; 0 is unlimited.",
+		func(st tunnel.Stats) string { return fmt.Sprint(st.BandwidthLimit) })
+}
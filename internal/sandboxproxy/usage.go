@@ -0,0 +1,101 @@
+package sandboxproxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/clock"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// Time tracking: besides a sandbox's last activity for the idle watcher,
+// the proxy records who is active in which sandbox, for the activity
+// reports of the main server. Requests of an authenticated user mark user
+// activity; the events an opencode server streams to them mark agent
+// activity, so an agent working through a long task still counts while
+// the user only watches.
+
+// recordUsage marks activity of a user in a sandbox, at most once per
+// db.SandboxActivityGrain per sandbox, user and kind.
+func (s *Server) recordUsage(sbx *sbxstore.Sandbox, userID, kind string) {
+	if s.DB == nil || userID == "" {
+		return
+	}
+	key := sbx.ID + "|" + userID + "|" + kind
+	now := clock.Or(s.Clock).Now()
+	s.usageMu.Lock()
+	if last, ok := s.usageLast[key]; ok && now.Sub(last) < db.SandboxActivityGrain {
+		s.usageMu.Unlock()
+		return
+	}
+	s.usageLast[key] = now
+	s.usageMu.Unlock()
+
+	mark := db.SandboxActivityMark{
+		SandboxID:   sbx.ID,
+		SandboxName: sbx.Name,
+		WorkspaceID: sbx.WorkspaceID,
+		UserID:      userID,
+		Kind:        kind,
+		At:          now,
+	}
+	go func() {
+		if err := s.DB.RecordSandboxActivity(mark); err != nil {
+			log.Printf("failed to record activity of sandbox %s: %v", sbx.ID, err)
+		}
+	}()
+}
+
+// trackUsage records a proxied request of a user. An opencode event stream
+// is a long-lived background request rather than user activity; the
+// returned writer marks agent activity for the events passing through it
+// instead.
+func (s *Server) trackUsage(w http.ResponseWriter, r *http.Request, sbx *sbxstore.Sandbox, userID string) http.ResponseWriter {
+	if !isOpencodeEventStream(sbx, r) {
+		s.recordUsage(sbx, userID, db.SandboxActivityUser)
+		return w
+	}
+	return &agentEventWriter{ResponseWriter: w, onEvent: func() {
+		s.recordUsage(sbx, userID, db.SandboxActivityAgent)
+	}}
+}
+
+func isOpencodeEventStream(sbx *sbxstore.Sandbox, r *http.Request) bool {
+	return sbx.Type == "opencode" && r.Method == http.MethodGet &&
+		(r.URL.Path == "/event" || r.URL.Path == "/global/event")
+}
+
+// agentEventWriter calls onEvent for each server-sent event written through
+// it, except opencode's own connection and heartbeat events.
+type agentEventWriter struct {
+	http.ResponseWriter
+	onEvent func()
+}
+
+var (
+	sseData        = []byte("data:")
+	sseServerEvent = []byte(`"type":"server.`)
+)
+
+func (a *agentEventWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		if bytes.HasPrefix(line, sseData) && !bytes.Contains(line, sseServerEvent) {
+			a.onEvent()
+			break
+		}
+	}
+	return a.ResponseWriter.Write(p)
+}
+
+func (a *agentEventWriter) Flush() {
+	if f, ok := a.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (a *agentEventWriter) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
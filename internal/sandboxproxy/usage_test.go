@@ -0,0 +1,54 @@
+package sandboxproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestAgentEventWriter(t *testing.T) {
+	events := 0
+	rec := httptest.NewRecorder()
+	w := &agentEventWriter{ResponseWriter: rec, onEvent: func() { events++ }}
+
+	for _, chunk := range []string{
+		"data: {\"type\":\"server.connected\",\"properties\":{}}\n\n",
+		"data: {\"type\":\"server.heartbeat\",\"properties\":{}}\n\n",
+		": keep-alive\n\n",
+		"data: {\"type\":\"message.part.updated\",\"properties\":{}}\n\n",
+		"event: message\ndata: {\"type\":\"session.idle\"}\n\n",
+	} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events != 2 {
+		t.Errorf("events = %d, want 2", events)
+	}
+	if http.NewResponseController(w).Flush() != nil || !rec.Flushed {
+		t.Error("flush did not reach the underlying writer")
+	}
+}
+
+func TestIsOpencodeEventStream(t *testing.T) {
+	opencode := &sbxstore.Sandbox{Type: "opencode"}
+	for _, tc := range []struct {
+		sbx    *sbxstore.Sandbox
+		method string
+		target string
+		want   bool
+	}{
+		{opencode, "GET", "/event?directory=%2Fhome%2Fagent", true},
+		{opencode, "GET", "/global/event", true},
+		{opencode, "POST", "/event", false},
+		{opencode, "GET", "/session", false},
+		{&sbxstore.Sandbox{Type: "openclaw"}, "GET", "/event", false},
+	} {
+		r := httptest.NewRequest(tc.method, tc.target, nil)
+		if got := isOpencodeEventStream(tc.sbx, r); got != tc.want {
+			t.Errorf("isOpencodeEventStream(%s, %s %s) = %v", tc.sbx.Type, tc.method, tc.target, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package sbxstore
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published to workspace subscribers.
+const (
+	EventStatus    = "status"    // a sandbox changed status; Data has status and message
+	EventDeleted   = "deleted"   // a sandbox was removed
+	EventHeartbeat = "heartbeat" // a local agent sent a heartbeat; Data has last_heartbeat_at
+	EventQuota     = "quota"     // a quota of the workspace was hit or changed
+	EventUpdated   = "updated"   // a sandbox's name, description, labels or idle timeout changed; Data has fields
+
+	EventDeletionWarning = "deletion_warning" // a paused sandbox is due for deletion; Data has delete_at
+)
+
+// Event is a change in a workspace, fanned out to its subscribers.
+type Event struct {
+	Type        string                 `json:"-"`
+	WorkspaceID string                 `json:"workspace_id"`
+	SandboxID   string                 `json:"sandbox_id,omitempty"`
+	Data        map[string]interface{} `json:"data,omitempty"`
+	At          time.Time              `json:"at"`
+}
+
+// eventBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it.
+const eventBuffer = 64
+
+// eventBus fans events out to per-workspace subscribers. It only reaches
+// subscribers in this process; changes made elsewhere (the sandbox proxy
+// records heartbeats and tunnel status) have to be picked up by polling.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[string]map[chan Event]struct{}
+}
+
+// Subscribe returns a channel of the events of a workspace and a function
+// that ends the subscription. Events are dropped while the channel is full.
+func (s *Store) Subscribe(workspaceID string) (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+	b := &s.events
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[string]map[chan Event]struct{})
+	}
+	if b.subs[workspaceID] == nil {
+		b.subs[workspaceID] = make(map[chan Event]struct{})
+	}
+	b.subs[workspaceID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[workspaceID], ch)
+			if len(b.subs[workspaceID]) == 0 {
+				delete(b.subs, workspaceID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish sends an event to the subscribers of its workspace without
+// blocking.
+func (s *Store) Publish(ev Event) {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+	b := &s.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[ev.WorkspaceID] {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// hasSubscribers reports whether anyone listens to events, so publishers
+// can skip the lookups an event needs.
+func (s *Store) hasSubscribers() bool {
+	s.events.mu.Lock()
+	defer s.events.mu.Unlock()
+	return len(s.events.subs) > 0
+}
+
+// publishStatus publishes the current status of a sandbox.
+func (s *Store) publishStatus(id string) {
+	if !s.hasSubscribers() {
+		return
+	}
+	sbx, ok := s.Get(id)
+	if !ok {
+		return
+	}
+	s.Publish(Event{
+		Type:        EventStatus,
+		WorkspaceID: sbx.WorkspaceID,
+		SandboxID:   sbx.ID,
+		Data:        map[string]interface{}{"status": sbx.Status, "message": sbx.StatusMessage},
+	})
+}
@@ -0,0 +1,43 @@
+package sbxstore
+
+import "testing"
+
+func TestEventBus(t *testing.T) {
+	s := NewStore(nil)
+	if s.hasSubscribers() {
+		t.Fatal("new store has subscribers")
+	}
+	a, cancelA := s.Subscribe("ws-a")
+	b, cancelB := s.Subscribe("ws-b")
+	defer cancelB()
+
+	s.Publish(Event{Type: EventQuota, WorkspaceID: "ws-a", Data: map[string]interface{}{"max": 5}})
+	select {
+	case ev := <-a:
+		if ev.Type != EventQuota || ev.At.IsZero() {
+			t.Errorf("event = %+v", ev)
+		}
+	default:
+		t.Fatal("subscriber of ws-a got no event")
+	}
+	select {
+	case ev := <-b:
+		t.Errorf("subscriber of ws-b got %+v", ev)
+	default:
+	}
+
+	// A subscriber that does not keep up loses events instead of blocking.
+	for i := 0; i < eventBuffer+10; i++ {
+		s.Publish(Event{Type: EventStatus, WorkspaceID: "ws-a"})
+	}
+	if len(a) != eventBuffer {
+		t.Errorf("buffered %d events, want %d", len(a), eventBuffer)
+	}
+
+	cancelA()
+	cancelA()
+	s.Publish(Event{Type: EventStatus, WorkspaceID: "ws-a"})
+	if len(a) != eventBuffer {
+		t.Error("cancelled subscriber still receives events")
+	}
+}
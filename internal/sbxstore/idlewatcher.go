@@ -1,9 +1,11 @@
 package sbxstore
 
 import (
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/agentserver/agentserver/internal/clock"
 	"github.com/agentserver/agentserver/internal/db"
 	"github.com/agentserver/agentserver/internal/process"
 )
@@ -15,7 +17,13 @@ type IdleWatcher struct {
 	store      *Store
 	getTimeout func() time.Duration
 	onPrePause func(sandboxID string) // called before pausing a sandbox (e.g. to stop bridge pollers)
+	onCheck    func(err error)        // called after each check cycle (heartbeat)
+	clock      clock.Clock
 	stop       chan struct{}
+
+	warnLead  time.Duration
+	onWarning func(sbx *db.Sandbox, pauseAt time.Time)
+	warned    map[string]time.Time // sandbox ID -> last activity it was warned at
 }
 
 // NewIdleWatcher creates a new idle sandbox watcher.
@@ -27,6 +35,7 @@ func NewIdleWatcher(database *db.DB, procMgr process.Manager, store *Store, getT
 		procMgr:    procMgr,
 		store:      store,
 		getTimeout: getTimeout,
+		clock:      clock.Real,
 		stop:       make(chan struct{}),
 	}
 }
@@ -37,6 +46,25 @@ func (w *IdleWatcher) SetOnPrePause(fn func(sandboxID string)) {
 	w.onPrePause = fn
 }
 
+// SetOnPauseWarning sets a callback that is invoked once per idle period
+// when a sandbox will be paused within lead, e.g. to notify its users.
+func (w *IdleWatcher) SetOnPauseWarning(lead time.Duration, fn func(sbx *db.Sandbox, pauseAt time.Time)) {
+	w.warnLead = lead
+	w.onWarning = fn
+}
+
+// SetOnCheck sets a callback that is invoked after each check cycle with
+// the cycle's error, so a stalled or failing watcher can be noticed.
+func (w *IdleWatcher) SetOnCheck(fn func(err error)) {
+	w.onCheck = fn
+}
+
+// SetClock replaces the clock that drives the check loop and measures
+// idleness. Call it before Start.
+func (w *IdleWatcher) SetClock(c clock.Clock) {
+	w.clock = c
+}
+
 // Start begins the idle check loop. Call Stop() to terminate.
 func (w *IdleWatcher) Start() {
 	go w.loop()
@@ -48,29 +76,38 @@ func (w *IdleWatcher) Stop() {
 }
 
 func (w *IdleWatcher) loop() {
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := w.clock.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-w.stop:
 			return
-		case <-ticker.C:
-			w.check()
+		case <-ticker.C():
+			err := w.check()
+			if w.onCheck != nil {
+				w.onCheck(err)
+			}
 		}
 	}
 }
 
-func (w *IdleWatcher) check() {
+// check pauses the idle sandboxes. It fails only if they can't be listed;
+// failures to pause single sandboxes are logged.
+func (w *IdleWatcher) check() error {
 	timeout := w.getTimeout()
 	if timeout <= 0 {
-		return // idle checking disabled
+		return nil // idle checking disabled
 	}
 
-	sandboxes, err := w.db.ListIdleSandboxes(int(timeout.Seconds()))
+	now := w.clock.Now()
+	sandboxes, err := w.db.ListIdleSandboxesAt(now, int(timeout.Seconds()))
 	if err != nil {
 		log.Printf("idle watcher: failed to list idle sandboxes: %v", err)
-		return
+		return fmt.Errorf("list idle sandboxes: %w", err)
+	}
+	if w.onWarning != nil && w.warnLead > 0 {
+		w.warn(now, int(timeout.Seconds()), sandboxes)
 	}
 
 	for _, sbx := range sandboxes {
@@ -105,4 +142,37 @@ func (w *IdleWatcher) check() {
 			log.Printf("idle watcher: failed to set paused status for %s: %v", sbx.ID, err)
 		}
 	}
+	return nil
+}
+
+// warn calls onWarning for the sandboxes that will be idle long enough to be
+// paused within warnLead, except those pausing now. A sandbox is warned
+// again only after new activity.
+func (w *IdleWatcher) warn(now time.Time, defaultTimeout int, pausing []*db.Sandbox) {
+	soon, err := w.db.ListIdleSandboxesAt(now.Add(w.warnLead), defaultTimeout)
+	if err != nil {
+		log.Printf("idle watcher: failed to list sandboxes idle soon: %v", err)
+		return
+	}
+	skip := make(map[string]bool, len(pausing))
+	for _, sbx := range pausing {
+		skip[sbx.ID] = true
+	}
+	warned := make(map[string]time.Time, len(soon))
+	for _, sbx := range soon {
+		if skip[sbx.ID] || !sbx.LastActivityAt.Valid {
+			continue
+		}
+		last := sbx.LastActivityAt.Time
+		warned[sbx.ID] = last
+		if prev, ok := w.warned[sbx.ID]; ok && prev.Equal(last) {
+			continue
+		}
+		t := defaultTimeout
+		if sbx.IdleTimeout != nil {
+			t = *sbx.IdleTimeout
+		}
+		w.onWarning(sbx, last.Add(time.Duration(t)*time.Second))
+	}
+	w.warned = warned
 }
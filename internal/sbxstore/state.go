@@ -9,13 +9,29 @@ const (
 	StatusResuming = "resuming"
 	StatusDeleting = "deleting"
 	StatusOffline  = "offline"
+
+	// StatusProvisioningStorage: the workspace drive is being provisioned;
+	// the container starts once it is ready.
+	StatusProvisioningStorage = "provisioning-storage"
+	// StatusStorageFailed: drive provisioning failed. The sandbox can be
+	// retried or deleted.
+	StatusStorageFailed = "storage-failed"
+	// StatusUnschedulable: the cluster had no room for the sandbox pod. The
+	// start can be retried or the sandbox deleted.
+	StatusUnschedulable = "unschedulable"
 )
 
 // ValidTransition checks whether a status transition is allowed.
 func ValidTransition(from, to string) bool {
 	switch from {
+	case StatusProvisioningStorage:
+		return to == StatusCreating || to == StatusStorageFailed || to == StatusDeleting
+	case StatusStorageFailed:
+		return to == StatusProvisioningStorage || to == StatusDeleting
 	case StatusCreating:
-		return to == StatusRunning || to == StatusDeleting
+		return to == StatusRunning || to == StatusUnschedulable || to == StatusDeleting
+	case StatusUnschedulable:
+		return to == StatusCreating || to == StatusDeleting
 	case StatusRunning:
 		return to == StatusPausing || to == StatusDeleting || to == StatusOffline
 	case StatusPausing:
@@ -0,0 +1,40 @@
+package sbxstore
+
+import "testing"
+
+func TestValidTransition_Storage(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusProvisioningStorage, StatusCreating, true},
+		{StatusProvisioningStorage, StatusStorageFailed, true},
+		{StatusProvisioningStorage, StatusRunning, false},
+		{StatusStorageFailed, StatusProvisioningStorage, true},
+		{StatusStorageFailed, StatusDeleting, true},
+		{StatusStorageFailed, StatusRunning, false},
+	}
+	for _, c := range cases {
+		if got := ValidTransition(c.from, c.to); got != c.want {
+			t.Errorf("ValidTransition(%q, %q) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestValidTransition_Unschedulable(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusCreating, StatusUnschedulable, true},
+		{StatusUnschedulable, StatusCreating, true},
+		{StatusUnschedulable, StatusDeleting, true},
+		{StatusUnschedulable, StatusRunning, false},
+		{StatusRunning, StatusUnschedulable, false},
+	}
+	for _, c := range cases {
+		if got := ValidTransition(c.from, c.to); got != c.want {
+			t.Errorf("ValidTransition(%q, %q) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
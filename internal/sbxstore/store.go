@@ -2,6 +2,7 @@ package sbxstore
 
 import (
 	"encoding/json"
+	"log"
 	"time"
 
 	"github.com/agentserver/agentserver/internal/db"
@@ -31,11 +32,15 @@ type Sandbox struct {
 	Memory          int64                  `json:"memory,omitempty"`
 	IdleTimeout     *int                   `json:"idle_timeout,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	QuarantinedAt   *time.Time             `json:"quarantined_at,omitempty"`
+	StatusMessage   string                 `json:"status_message,omitempty"`
+	PinnedAt        *time.Time             `json:"pinned_at,omitempty"`
 }
 
 // Store manages sandboxes via PostgreSQL.
 type Store struct {
-	db *db.DB
+	db     *db.DB
+	events eventBus
 }
 
 func NewStore(database *db.DB) *Store {
@@ -129,14 +134,44 @@ func (s *Store) ListByWorkspace(workspaceID string) []*Sandbox {
 	return out
 }
 
-// UpdateStatus transitions a sandbox to a new status.
+// UpdateStatus transitions a sandbox to a new status and notifies the
+// subscribers of its workspace.
 func (s *Store) UpdateStatus(id, status string) error {
-	return s.db.UpdateSandboxStatus(id, status)
+	if err := s.db.UpdateSandboxStatus(id, status); err != nil {
+		return err
+	}
+	s.publishStatus(id)
+	return nil
 }
 
-// Delete removes a sandbox from the DB.
-func (s *Store) Delete(id string) error {
-	return s.db.DeleteSandbox(id)
+// UpdateStatusMessage updates the sandbox status and records a detail message.
+func (s *Store) UpdateStatusMessage(id, status, message string) error {
+	if err := s.db.UpdateSandboxStatusMessage(id, status, message); err != nil {
+		return err
+	}
+	s.publishStatus(id)
+	return nil
+}
+
+// Delete removes a sandbox from the DB, leaving a tombstone with its final
+// state. Failing to record the tombstone doesn't stop the deletion.
+func (s *Store) Delete(id string, del db.SandboxDeletion) error {
+	var workspaceID string
+	if s.hasSubscribers() {
+		if sbx, ok := s.Get(id); ok {
+			workspaceID = sbx.WorkspaceID
+		}
+	}
+	if err := s.db.RecordSandboxTombstone(id, del); err != nil {
+		log.Printf("sbxstore: %v", err)
+	}
+	if err := s.db.DeleteSandbox(id); err != nil {
+		return err
+	}
+	if workspaceID != "" {
+		s.Publish(Event{Type: EventDeleted, WorkspaceID: workspaceID, SandboxID: id})
+	}
+	return nil
 }
 
 // UpdateActivity records user activity on a sandbox.
@@ -184,6 +219,7 @@ func dbSandboxToSandbox(ds *db.Sandbox) *Sandbox {
 		sbx.TunnelToken = ds.TunnelToken.String
 	}
 	sbx.NanoclawBridgeSecret = ds.NanoclawBridgeSecret.String
+	sbx.StatusMessage = ds.StatusMessage.String
 	if ds.LastHeartbeatAt.Valid {
 		t := ds.LastHeartbeatAt.Time
 		sbx.LastHeartbeatAt = &t
@@ -195,12 +231,39 @@ func dbSandboxToSandbox(ds *db.Sandbox) *Sandbox {
 		sbx.Memory = *ds.Memory
 	}
 	sbx.IdleTimeout = ds.IdleTimeout
+	if ds.QuarantinedAt.Valid {
+		t := ds.QuarantinedAt.Time
+		sbx.QuarantinedAt = &t
+	}
+	if ds.PinnedAt.Valid {
+		t := ds.PinnedAt.Time
+		sbx.PinnedAt = &t
+	}
 	if len(ds.Metadata) > 0 {
 		_ = json.Unmarshal(ds.Metadata, &sbx.Metadata)
 	}
 	return sbx
 }
 
+// MetadataStrings returns a metadata value as a string list, or nil if not
+// set. It accepts both []string (set in memory) and []interface{} (decoded
+// from the database).
+func (s *Sandbox) MetadataStrings(key string) []string {
+	switch v := s.Metadata[key].(type) {
+	case []string:
+		return v
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if str, ok := e.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
 // MetadataString returns a metadata value as a string, or "" if not set.
 func (s *Sandbox) MetadataString(key string) string {
 	if v, ok := s.Metadata[key]; ok {
@@ -0,0 +1,120 @@
+// Package secrets fetches secrets from an external store instead of
+// environment variables and database rows.
+//
+// A secret is referenced as "vault:<path>#<key>". Environment variables
+// holding a reference (e.g. ANTHROPIC_API_KEY=vault:agentserver/llm#anthropic)
+// are resolved at startup by ResolveEnv; credential bindings holding one are
+// resolved per request, relative to the workspace's own path.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// RefPrefix marks a value as a reference to a secret in the backend.
+const RefPrefix = "vault:"
+
+// Backend reads secrets: the key/value pairs stored at a path.
+type Backend interface {
+	Get(ctx context.Context, path string) (map[string]string, error)
+}
+
+// Ref is a parsed secret reference.
+type Ref struct {
+	Path string
+	Key  string
+}
+
+// IsRef reports whether value is a secret reference.
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, RefPrefix)
+}
+
+// ParseRef parses "vault:<path>#<key>".
+func ParseRef(value string) (Ref, error) {
+	if !IsRef(value) {
+		return Ref{}, fmt.Errorf("not a secret reference")
+	}
+	p, key, ok := strings.Cut(strings.TrimPrefix(value, RefPrefix), "#")
+	p = strings.Trim(p, "/")
+	if !ok || p == "" || key == "" {
+		return Ref{}, fmt.Errorf("invalid secret reference %q: want %s<path>#<key>", value, RefPrefix)
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return Ref{}, fmt.Errorf("invalid secret reference %q: bad path", value)
+		}
+	}
+	return Ref{Path: p, Key: key}, nil
+}
+
+// Lookup reads the secret a reference points to.
+func Lookup(ctx context.Context, b Backend, ref Ref) (string, error) {
+	data, err := b.Get(ctx, ref.Path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %q", ref.Path, ref.Key)
+	}
+	return v, nil
+}
+
+// Resolve returns value, or the secret it references if it is a reference.
+func Resolve(ctx context.Context, b Backend, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	if b == nil {
+		return "", fmt.Errorf("secret reference %q but no secrets backend is configured", value)
+	}
+	ref, err := ParseRef(value)
+	if err != nil {
+		return "", err
+	}
+	return Lookup(ctx, b, ref)
+}
+
+// ResolveWorkspace resolves a reference stored by a workspace (e.g. as a
+// credential binding's secret). The path is taken relative to
+// <prefix>/<workspaceID>, so a workspace can't read other secrets.
+func ResolveWorkspace(ctx context.Context, b Backend, prefix, workspaceID, value string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+	if b == nil {
+		return "", fmt.Errorf("secret reference but no secrets backend is configured")
+	}
+	ref, err := ParseRef(value)
+	if err != nil {
+		return "", err
+	}
+	if workspaceID == "" || strings.ContainsAny(workspaceID, "/.") {
+		return "", fmt.Errorf("invalid workspace id %q", workspaceID)
+	}
+	ref.Path = path.Join(strings.Trim(prefix, "/"), workspaceID, ref.Path)
+	return Lookup(ctx, b, ref)
+}
+
+// ResolveEnv replaces every environment variable whose value is a secret
+// reference with the secret, so configuration loaded afterwards sees the
+// real values. It is a no-op when b is nil and no variable is a reference.
+func ResolveEnv(ctx context.Context, b Backend) error {
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !IsRef(value) {
+			continue
+		}
+		v, err := Resolve(ctx, b, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		os.Setenv(name, v)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	ref, err := ParseRef("vault:agentserver/llm#anthropic")
+	if err != nil || ref.Path != "agentserver/llm" || ref.Key != "anthropic" {
+		t.Fatalf("ParseRef = %+v, %v", ref, err)
+	}
+	for _, bad := range []string{"agentserver/llm#k", "vault:agentserver/llm", "vault:#k", "vault:a/../b#k", "vault:a//b#k"} {
+		if _, err := ParseRef(bad); err == nil {
+			t.Errorf("ParseRef(%q) succeeded", bad)
+		}
+	}
+}
+
+// fakeVault serves KV v2 reads and kubernetes logins.
+func fakeVault(t *testing.T, reads *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/auth/kubernetes/login":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["role"] != "agentserver" || body["jwt"] != "sa-jwt" {
+				http.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"auth": map[string]any{"client_token": "s.k8s", "lease_duration": 3600}})
+		case strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+			if tok := r.Header.Get("X-Vault-Token"); tok != "s.root" && tok != "s.k8s" {
+				http.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+			atomic.AddInt32(reads, 1)
+			switch strings.TrimPrefix(r.URL.Path, "/v1/secret/data/") {
+			case "agentserver/llm":
+				json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"anthropic": "sk-ant-1"}}})
+			case "workspaces/ws1/github":
+				json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": map[string]any{"token": "ghp-1"}}})
+			default:
+				http.NotFound(w, r)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestVault(t *testing.T) {
+	var reads int32
+	srv := fakeVault(t, &reads)
+	defer srv.Close()
+	ctx := context.Background()
+
+	v := &Vault{Addr: srv.URL, Token: "s.root", CacheTTL: 1 << 40}
+	for i := 0; i < 2; i++ {
+		got, err := Resolve(ctx, v, "vault:agentserver/llm#anthropic")
+		if err != nil || got != "sk-ant-1" {
+			t.Fatalf("Resolve = %q, %v", got, err)
+		}
+	}
+	if reads != 1 {
+		t.Errorf("reads = %d, want 1 (cached)", reads)
+	}
+	if _, err := Resolve(ctx, v, "vault:agentserver/llm#missing"); err == nil {
+		t.Error("missing key resolved")
+	}
+	if _, err := Resolve(ctx, v, "vault:nope#k"); err == nil {
+		t.Error("missing path resolved")
+	}
+	if got, _ := Resolve(ctx, nil, "plain"); got != "plain" {
+		t.Errorf("plain value = %q", got)
+	}
+
+	got, err := ResolveWorkspace(ctx, v, "workspaces", "ws1", "vault:github#token")
+	if err != nil || got != "ghp-1" {
+		t.Errorf("ResolveWorkspace = %q, %v", got, err)
+	}
+	if _, err := ResolveWorkspace(ctx, v, "workspaces", "ws2", "vault:../ws1/github#token"); err == nil {
+		t.Error("workspace escaped its prefix")
+	}
+
+	jwt := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(jwt, []byte("sa-jwt\n"), 0o600)
+	k := &Vault{Addr: srv.URL, Role: "agentserver", TokenPath: jwt}
+	if got, err := Resolve(ctx, k, "vault:agentserver/llm#anthropic"); err != nil || got != "sk-ant-1" {
+		t.Errorf("kubernetes auth Resolve = %q, %v", got, err)
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	var reads int32
+	srv := fakeVault(t, &reads)
+	defer srv.Close()
+
+	t.Setenv("SECRETS_TEST_KEY", "vault:agentserver/llm#anthropic")
+	t.Setenv("SECRETS_TEST_PLAIN", "plain")
+	if err := ResolveEnv(context.Background(), &Vault{Addr: srv.URL, Token: "s.root"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("SECRETS_TEST_KEY"); got != "sk-ant-1" {
+		t.Errorf("SECRETS_TEST_KEY = %q", got)
+	}
+	if got := os.Getenv("SECRETS_TEST_PLAIN"); got != "plain" {
+		t.Errorf("SECRETS_TEST_PLAIN = %q", got)
+	}
+
+	t.Setenv("SECRETS_TEST_KEY", "vault:agentserver/llm#anthropic")
+	if err := ResolveEnv(context.Background(), nil); err == nil {
+		t.Error("reference resolved without a backend")
+	}
+}
@@ -0,0 +1,223 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultK8sTokenPath is where Kubernetes mounts the pod's service account
+// token, used to log in with Vault's kubernetes auth method.
+const defaultK8sTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Vault reads secrets from a HashiCorp Vault KV version 2 engine.
+// Reads are cached for CacheTTL (or the secret's lease, if shorter).
+type Vault struct {
+	Addr      string // e.g. https://vault.example.com:8200
+	Namespace string // Vault Enterprise namespace, optional
+	Mount     string // KV v2 mount, default "secret"
+
+	// Token authenticates directly. Otherwise Role logs in with the
+	// kubernetes auth method at AuthMount using the service account token
+	// in TokenPath, and logs in again when the token's lease expires.
+	Token     string
+	Role      string
+	AuthMount string // default "kubernetes"
+	TokenPath string // default: the pod's service account token
+
+	CacheTTL time.Duration
+	Client   *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time // zero: never expires
+	cache       map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	data    map[string]string
+	expires time.Time
+}
+
+// VaultFromEnv configures Vault from VAULT_ADDR, VAULT_TOKEN, VAULT_ROLE,
+// VAULT_AUTH_MOUNT, VAULT_NAMESPACE, VAULT_KV_MOUNT and VAULT_CACHE_TTL.
+// It returns nil when VAULT_ADDR is unset.
+func VaultFromEnv() (*Vault, error) {
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return nil, nil
+	}
+	v := &Vault{
+		Addr:      addr,
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Mount:     os.Getenv("VAULT_KV_MOUNT"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		Role:      os.Getenv("VAULT_ROLE"),
+		AuthMount: os.Getenv("VAULT_AUTH_MOUNT"),
+		CacheTTL:  5 * time.Minute,
+	}
+	if v.Token == "" && v.Role == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN or VAULT_ROLE is required when VAULT_ADDR is set")
+	}
+	if s := os.Getenv("VAULT_CACHE_TTL"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VAULT_CACHE_TTL: %w", err)
+		}
+		v.CacheTTL = d
+	}
+	return v, nil
+}
+
+// FromEnv returns the configured secrets backend, or nil if none is.
+func FromEnv() (Backend, error) {
+	v, err := VaultFromEnv()
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Get reads the latest version of the secret at path.
+func (v *Vault) Get(ctx context.Context, path string) (map[string]string, error) {
+	v.mu.Lock()
+	if c, ok := v.cache[path]; ok && time.Now().Before(c.expires) {
+		v.mu.Unlock()
+		return c.data, nil
+	}
+	v.mu.Unlock()
+
+	data, lease, err := v.read(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	ttl := v.CacheTTL
+	if lease > 0 && lease < ttl {
+		ttl = lease
+	}
+	if ttl > 0 {
+		v.mu.Lock()
+		if v.cache == nil {
+			v.cache = make(map[string]cachedSecret)
+		}
+		v.cache[path] = cachedSecret{data: data, expires: time.Now().Add(ttl)}
+		v.mu.Unlock()
+	}
+	return data, nil
+}
+
+func (v *Vault) read(ctx context.Context, path string) (map[string]string, time.Duration, error) {
+	token, err := v.authToken(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	mount := v.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	var resp struct {
+		LeaseDuration int `json:"lease_duration"`
+		Data          struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	url := fmt.Sprintf("%s/v1/%s/data/%s", v.Addr, strings.Trim(mount, "/"), path)
+	if err := v.do(ctx, http.MethodGet, url, token, nil, &resp); err != nil {
+		return nil, 0, fmt.Errorf("vault read %s: %w", path, err)
+	}
+	data := make(map[string]string, len(resp.Data.Data))
+	for k, val := range resp.Data.Data {
+		switch val := val.(type) {
+		case string:
+			data[k] = val
+		default:
+			b, _ := json.Marshal(val)
+			data[k] = string(b)
+		}
+	}
+	return data, time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// authToken returns a Vault token, logging in with the kubernetes auth
+// method when no static token is configured or the lease has run out.
+func (v *Vault) authToken(ctx context.Context) (string, error) {
+	if v.Token != "" {
+		return v.Token, nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token != "" && (v.tokenExpiry.IsZero() || time.Now().Before(v.tokenExpiry)) {
+		return v.token, nil
+	}
+
+	tokenPath := v.TokenPath
+	if tokenPath == "" {
+		tokenPath = defaultK8sTokenPath
+	}
+	jwt, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("vault login: read service account token: %w", err)
+	}
+	authMount := v.AuthMount
+	if authMount == "" {
+		authMount = "kubernetes"
+	}
+	body, _ := json.Marshal(map[string]string{"role": v.Role, "jwt": strings.TrimSpace(string(jwt))})
+	var resp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	url := fmt.Sprintf("%s/v1/auth/%s/login", v.Addr, strings.Trim(authMount, "/"))
+	if err := v.do(ctx, http.MethodPost, url, "", body, &resp); err != nil {
+		return "", fmt.Errorf("vault login: %w", err)
+	}
+	if resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault login: no client token in response")
+	}
+	v.token = resp.Auth.ClientToken
+	v.tokenExpiry = time.Time{}
+	if lease := time.Duration(resp.Auth.LeaseDuration) * time.Second; lease > 0 {
+		// Log in again a little before the lease runs out.
+		v.tokenExpiry = time.Now().Add(lease * 9 / 10)
+	}
+	return v.token, nil
+}
+
+func (v *Vault) do(ctx context.Context, method, url, token string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -7,11 +7,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
 )
 
 // requireAdmin is a middleware that checks if the authenticated user has the admin role.
@@ -27,6 +29,10 @@ func (s *Server) requireAdmin(next http.Handler) http.Handler {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
+		if t := auth.PATFromContext(r.Context()); t != nil && !t.HasScope(db.TokenScopeAdmin) {
+			http.Error(w, "token lacks the admin scope", http.StatusForbidden)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -40,21 +46,25 @@ func (s *Server) handleAdminListUsers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type adminUserResponse struct {
-		ID        string  `json:"id"`
-		Email     string  `json:"email"`
-		Name      *string `json:"name"`
-		Role      string  `json:"role"`
-		CreatedAt string  `json:"created_at"`
+		ID         string     `json:"id"`
+		Email      string     `json:"email"`
+		Name       *string    `json:"name"`
+		Role       string     `json:"role"`
+		Status     string     `json:"status"`
+		DisabledAt *time.Time `json:"disabled_at,omitempty"`
+		CreatedAt  string     `json:"created_at"`
 	}
 
 	resp := make([]adminUserResponse, len(users))
 	for i, u := range users {
 		resp[i] = adminUserResponse{
-			ID:        u.ID,
-			Email:     u.Email,
-			Name:      u.Name,
-			Role:      u.Role,
-			CreatedAt: u.CreatedAt.Format(time.RFC3339),
+			ID:         u.ID,
+			Email:      u.Email,
+			Name:       u.Name,
+			Role:       u.Role,
+			Status:     userStatus(u),
+			DisabledAt: u.DisabledAt,
+			CreatedAt:  u.CreatedAt.Format(time.RFC3339),
 		}
 	}
 
@@ -138,6 +148,7 @@ func (s *Server) handleAdminListSandboxes(w http.ResponseWriter, r *http.Request
 		CreatedAt      string  `json:"created_at"`
 		LastActivityAt *string `json:"last_activity_at"`
 		IsLocal        bool    `json:"is_local"`
+		QuarantinedAt  *string `json:"quarantined_at,omitempty"`
 	}
 
 	resp := make([]adminSandboxResponse, len(sandboxes))
@@ -155,6 +166,10 @@ func (s *Server) handleAdminListSandboxes(w http.ResponseWriter, r *http.Request
 			s := sbx.LastActivityAt.Time.Format(time.RFC3339)
 			r.LastActivityAt = &s
 		}
+		if sbx.QuarantinedAt.Valid {
+			s := sbx.QuarantinedAt.Time.Format(time.RFC3339)
+			r.QuarantinedAt = &s
+		}
 		resp[i] = r
 	}
 
@@ -177,29 +192,51 @@ func (s *Server) handleAdminUpdateUserRole(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	var previousRole string
+	if u, err := s.DB.GetUserByID(targetID); err == nil && u != nil {
+		previousRole = u.Role
+	}
 	if err := s.DB.UpdateUserRole(targetID, req.Role); err != nil {
 		log.Printf("admin: failed to update user role: %v", err)
 		http.Error(w, "failed to update user role", http.StatusInternalServerError)
 		return
 	}
+	severity := SecuritySeverityWarning
+	if req.Role == "admin" {
+		severity = SecuritySeverityCritical
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventRoleChanged,
+		Severity: severity,
+		TargetID: targetID,
+		Details:  map[string]interface{}{"role": req.Role, "previous_role": previousRole},
+	})
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) handleAdminGetQuotaDefaults(w http.ResponseWriter, r *http.Request) {
-	rd := s.getResourceDefaults()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"max_workspaces_per_user":      rd.MaxWorkspacesPerUser,
-		"max_sandboxes_per_workspace":  rd.MaxSandboxesPerWorkspace,
-		"max_workspace_drive_size":     rd.MaxWorkspaceDriveSize,
-		"max_sandbox_cpu":              rd.MaxSandboxCPU,
-		"max_sandbox_memory":           rd.MaxSandboxMemory,
-		"max_idle_timeout":             rd.MaxIdleTimeout,
-		"ws_max_total_cpu":             rd.WsMaxTotalCPU,
-		"ws_max_total_memory":          rd.WsMaxTotalMemory,
-		"ws_max_idle_timeout":          rd.WsMaxIdleTimeout,
-	})
+	json.NewEncoder(w).Encode(s.quotaDefaults())
+}
+
+// quotaDefaults returns the effective quota defaults, keyed like the
+// admin quota defaults request.
+func (s *Server) quotaDefaults() map[string]interface{} {
+	rd := s.getResourceDefaults()
+	return map[string]interface{}{
+		"max_workspaces_per_user":     rd.MaxWorkspacesPerUser,
+		"max_sandboxes_per_workspace": rd.MaxSandboxesPerWorkspace,
+		"max_workspace_drive_size":    rd.MaxWorkspaceDriveSize,
+		"max_sandbox_cpu":             rd.MaxSandboxCPU,
+		"max_sandbox_memory":          rd.MaxSandboxMemory,
+		"max_idle_timeout":            rd.MaxIdleTimeout,
+		"ws_max_total_cpu":            rd.WsMaxTotalCPU,
+		"ws_max_total_memory":         rd.WsMaxTotalMemory,
+		"ws_max_idle_timeout":         rd.WsMaxIdleTimeout,
+		"max_sandboxes_by_type":       s.sandboxTypeLimits(),
+		"managed_by_policy":           policyManagedQuotas(s.Policy.Get().Quotas),
+	}
 }
 
 func (s *Server) handleAdminSetQuotaDefaults(w http.ResponseWriter, r *http.Request) {
@@ -213,11 +250,38 @@ func (s *Server) handleAdminSetQuotaDefaults(w http.ResponseWriter, r *http.Requ
 		WsMaxTotalCPU            *int   `json:"ws_max_total_cpu"`
 		WsMaxTotalMemory         *int64 `json:"ws_max_total_memory"`
 		WsMaxIdleTimeout         *int   `json:"ws_max_idle_timeout"`
+		// Replaces all per-type limits; {} removes them.
+		MaxSandboxesByType map[string]int `json:"max_sandboxes_by_type"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
+	json.Unmarshal(body, &fields)
+	for _, name := range policyManagedQuotas(s.Policy.Get().Quotas) {
+		if _, ok := fields[name]; ok {
+			http.Error(w, name+" is managed by the policy file", http.StatusConflict)
+			return
+		}
+	}
+	if err := validateSandboxTypeLimits(req.MaxSandboxesByType); err != nil {
+		http.Error(w, "max_sandboxes_by_type: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if isDryRun(r) {
+		if (req.MaxWorkspacesPerUser != nil && *req.MaxWorkspacesPerUser < 0) || (req.MaxSandboxesPerWorkspace != nil && *req.MaxSandboxesPerWorkspace < 0) {
+			http.Error(w, "max_workspaces_per_user and max_sandboxes_per_workspace must be >= 0", http.StatusBadRequest)
+			return
+		}
+		writeDryRun(w, quotaDryRunActions("set_quota", "defaults", quotaChangeDetails(s.quotaDefaults(), req, false)))
+		return
+	}
 
 	if req.MaxWorkspacesPerUser != nil {
 		if *req.MaxWorkspacesPerUser < 0 {
@@ -290,20 +354,18 @@ func (s *Server) handleAdminSetQuotaDefaults(w http.ResponseWriter, r *http.Requ
 			return
 		}
 	}
+	if req.MaxSandboxesByType != nil {
+		b, _ := json.Marshal(req.MaxSandboxesByType)
+		if err := s.DB.SetSystemSetting(settingKeyMaxSandboxesByType, string(b)); err != nil {
+			log.Printf("admin: failed to set quota default: %v", err)
+			http.Error(w, "failed to save setting", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.recordQuotaChange(r, "defaults", "", "set", req)
 
-	rd := s.getResourceDefaults()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"max_workspaces_per_user":      rd.MaxWorkspacesPerUser,
-		"max_sandboxes_per_workspace":  rd.MaxSandboxesPerWorkspace,
-		"max_workspace_drive_size":     rd.MaxWorkspaceDriveSize,
-		"max_sandbox_cpu":              rd.MaxSandboxCPU,
-		"max_sandbox_memory":           rd.MaxSandboxMemory,
-		"max_idle_timeout":             rd.MaxIdleTimeout,
-		"ws_max_total_cpu":             rd.WsMaxTotalCPU,
-		"ws_max_total_memory":          rd.WsMaxTotalMemory,
-		"ws_max_idle_timeout":          rd.WsMaxIdleTimeout,
-	})
+	json.NewEncoder(w).Encode(s.quotaDefaults())
 }
 
 func (s *Server) handleAdminGetUserQuota(w http.ResponseWriter, r *http.Request) {
@@ -314,21 +376,11 @@ func (s *Server) handleAdminGetUserQuota(w http.ResponseWriter, r *http.Request)
 		"max_workspaces_per_user": rd.MaxWorkspacesPerUser,
 	}
 
-	uq, err := s.DB.GetUserQuota(targetID)
-	if err != nil {
-		log.Printf("admin: failed to get user quota: %v", err)
-		http.Error(w, "failed to get user quota", http.StatusInternalServerError)
+	overrides, ok := s.userQuotaOverrides(w, targetID)
+	if !ok {
 		return
 	}
 
-	var overrides interface{}
-	if uq != nil {
-		overrides = map[string]interface{}{
-			"max_workspaces": uq.MaxWorkspaces,
-			"updated_at":     uq.UpdatedAt.Format(time.RFC3339),
-		}
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"defaults":  defaults,
@@ -336,6 +388,24 @@ func (s *Server) handleAdminGetUserQuota(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// userQuotaOverrides returns a user's quota overrides, or nil if there are
+// none. It writes the error response and returns false on failure.
+func (s *Server) userQuotaOverrides(w http.ResponseWriter, userID string) (map[string]interface{}, bool) {
+	uq, err := s.DB.GetUserQuota(userID)
+	if err != nil {
+		log.Printf("admin: failed to get user quota: %v", err)
+		http.Error(w, "failed to get user quota", http.StatusInternalServerError)
+		return nil, false
+	}
+	if uq == nil {
+		return nil, true
+	}
+	return map[string]interface{}{
+		"max_workspaces": uq.MaxWorkspaces,
+		"updated_at":     uq.UpdatedAt.Format(time.RFC3339),
+	}, true
+}
+
 func (s *Server) handleAdminSetUserQuota(w http.ResponseWriter, r *http.Request) {
 	targetID := chi.URLParam(r, "id")
 
@@ -351,24 +421,42 @@ func (s *Server) handleAdminSetUserQuota(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "max_workspaces must be >= 0", http.StatusBadRequest)
 		return
 	}
+	if isDryRun(r) {
+		current, ok := s.userQuotaOverrides(w, targetID)
+		if !ok {
+			return
+		}
+		writeDryRun(w, quotaDryRunActions("set_quota", targetID, quotaChangeDetails(current, req, true)))
+		return
+	}
 
 	if err := s.DB.SetUserQuota(targetID, req.MaxWorkspaces); err != nil {
 		log.Printf("admin: failed to set user quota: %v", err)
 		http.Error(w, fmt.Sprintf("failed to set user quota: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.recordQuotaChange(r, "user", targetID, "set", req)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) handleAdminDeleteUserQuota(w http.ResponseWriter, r *http.Request) {
 	targetID := chi.URLParam(r, "id")
+	if isDryRun(r) {
+		current, ok := s.userQuotaOverrides(w, targetID)
+		if !ok {
+			return
+		}
+		writeDryRun(w, quotaDryRunActions("delete_quota", targetID, current))
+		return
+	}
 
 	if err := s.DB.DeleteUserQuota(targetID); err != nil {
 		log.Printf("admin: failed to delete user quota: %v", err)
 		http.Error(w, "failed to delete user quota", http.StatusInternalServerError)
 		return
 	}
+	s.recordQuotaChange(r, "user", targetID, "delete", nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -385,6 +473,8 @@ func (s *Server) handleAdminGetWorkspaceQuota(w http.ResponseWriter, r *http.Req
 		"max_total_cpu":      rd.WsMaxTotalCPU,
 		"max_total_memory":   rd.WsMaxTotalMemory,
 		"max_drive_size":     rd.MaxWorkspaceDriveSize,
+
+		"max_sandboxes_by_type": s.sandboxTypeLimits(),
 	}
 
 	wq, err := s.DB.GetWorkspaceQuota(workspaceID)
@@ -396,16 +486,7 @@ func (s *Server) handleAdminGetWorkspaceQuota(w http.ResponseWriter, r *http.Req
 
 	var overrides interface{}
 	if wq != nil {
-		overrides = map[string]interface{}{
-			"max_sandboxes":      wq.MaxSandboxes,
-			"max_sandbox_cpu":    wq.MaxSandboxCPU,
-			"max_sandbox_memory": wq.MaxSandboxMemory,
-			"max_idle_timeout":   wq.MaxIdleTimeout,
-			"max_total_cpu":      wq.MaxTotalCPU,
-			"max_total_memory":   wq.MaxTotalMemory,
-			"max_drive_size":     wq.MaxDriveSize,
-			"updated_at":         wq.UpdatedAt.Format(time.RFC3339),
-		}
+		overrides = workspaceQuotaOverrides(wq)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -415,6 +496,24 @@ func (s *Server) handleAdminGetWorkspaceQuota(w http.ResponseWriter, r *http.Req
 	})
 }
 
+// workspaceQuotaOverrides returns a workspace's quota overrides, keyed like
+// the admin workspace quota request.
+func workspaceQuotaOverrides(wq *db.WorkspaceQuota) map[string]interface{} {
+	return map[string]interface{}{
+		"max_sandboxes":      wq.MaxSandboxes,
+		"max_sandbox_cpu":    wq.MaxSandboxCPU,
+		"max_sandbox_memory": wq.MaxSandboxMemory,
+		"max_idle_timeout":   wq.MaxIdleTimeout,
+		"max_total_cpu":      wq.MaxTotalCPU,
+		"max_total_memory":   wq.MaxTotalMemory,
+		"max_drive_size":     wq.MaxDriveSize,
+		"paused_retention":   wq.PausedRetention,
+		"updated_at":         wq.UpdatedAt.Format(time.RFC3339),
+
+		"max_sandboxes_by_type": wq.MaxSandboxesByType,
+	}
+}
+
 func (s *Server) handleAdminSetWorkspaceQuota(w http.ResponseWriter, r *http.Request) {
 	workspaceID := chi.URLParam(r, "id")
 
@@ -426,16 +525,27 @@ func (s *Server) handleAdminSetWorkspaceQuota(w http.ResponseWriter, r *http.Req
 		MaxTotalCPU      *int   `json:"max_total_cpu"`
 		MaxTotalMemory   *int64 `json:"max_total_memory"`
 		MaxDriveSize     *int64 `json:"max_drive_size"`
+		PausedRetention  *int   `json:"paused_retention"`
+		// Replaces the workspace's per-type limits; {} removes them.
+		MaxSandboxesByType map[string]int `json:"max_sandboxes_by_type"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
 
+	if err := validateSandboxTypeLimits(req.MaxSandboxesByType); err != nil {
+		http.Error(w, "max_sandboxes_by_type: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 	if req.MaxSandboxes != nil && *req.MaxSandboxes < 0 {
 		http.Error(w, "max_sandboxes must be >= 0", http.StatusBadRequest)
 		return
 	}
+	if req.PausedRetention != nil && *req.PausedRetention < 0 {
+		http.Error(w, "paused_retention must be >= 0", http.StatusBadRequest)
+		return
+	}
 
 	// Fetch existing to merge partial updates.
 	existing, err := s.DB.GetWorkspaceQuota(workspaceID)
@@ -452,6 +562,17 @@ func (s *Server) handleAdminSetWorkspaceQuota(w http.ResponseWriter, r *http.Req
 	mergedMaxCPU := req.MaxTotalCPU
 	mergedMaxMemory := req.MaxTotalMemory
 	mergedDrive := req.MaxDriveSize
+	mergedRetention := req.PausedRetention
+	mergedByType := req.MaxSandboxesByType
+
+	if isDryRun(r) {
+		var current map[string]interface{}
+		if existing != nil {
+			current = workspaceQuotaOverrides(existing)
+		}
+		writeDryRun(w, quotaDryRunActions("set_quota", workspaceID, quotaChangeDetails(current, req, false)))
+		return
+	}
 
 	if existing != nil {
 		if mergedSbx == nil {
@@ -475,27 +596,54 @@ func (s *Server) handleAdminSetWorkspaceQuota(w http.ResponseWriter, r *http.Req
 		if mergedDrive == nil {
 			mergedDrive = existing.MaxDriveSize
 		}
+		if mergedRetention == nil {
+			mergedRetention = existing.PausedRetention
+		}
+		if mergedByType == nil {
+			mergedByType = existing.MaxSandboxesByType
+		}
+	}
+	if len(mergedByType) == 0 {
+		mergedByType = nil
 	}
 
 	if err := s.DB.SetWorkspaceQuota(workspaceID, mergedSbx,
 		mergedCPU, mergedMemory, mergedIdle,
-		mergedMaxCPU, mergedMaxMemory, mergedDrive); err != nil {
+		mergedMaxCPU, mergedMaxMemory, mergedDrive, mergedRetention, mergedByType); err != nil {
 		log.Printf("admin: failed to set workspace quota: %v", err)
 		http.Error(w, fmt.Sprintf("failed to set workspace quota: %v", err), http.StatusInternalServerError)
 		return
 	}
+	s.recordQuotaChange(r, "workspace", workspaceID, "set", req)
+	s.publishQuotaEvent(workspaceID, "quota_changed", nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Server) handleAdminDeleteWorkspaceQuota(w http.ResponseWriter, r *http.Request) {
 	workspaceID := chi.URLParam(r, "id")
+	if isDryRun(r) {
+		wq, err := s.DB.GetWorkspaceQuota(workspaceID)
+		if err != nil {
+			log.Printf("admin: failed to get workspace quota: %v", err)
+			http.Error(w, "failed to get workspace quota", http.StatusInternalServerError)
+			return
+		}
+		var current map[string]interface{}
+		if wq != nil {
+			current = workspaceQuotaOverrides(wq)
+		}
+		writeDryRun(w, quotaDryRunActions("delete_quota", workspaceID, current))
+		return
+	}
 
 	if err := s.DB.DeleteWorkspaceQuota(workspaceID); err != nil {
 		log.Printf("admin: failed to delete workspace quota: %v", err)
 		http.Error(w, "failed to delete workspace quota", http.StatusInternalServerError)
 		return
 	}
+	s.recordQuotaChange(r, "workspace", workspaceID, "delete", nil)
+	s.publishQuotaEvent(workspaceID, "quota_changed", nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -547,3 +695,192 @@ func (s *Server) handleAdminDeleteWorkspaceLLMQuota(w http.ResponseWriter, r *ht
 	workspaceID := chi.URLParam(r, "id")
 	s.proxyLLMProxyRequest(w, http.MethodDelete, "/internal/quotas/"+workspaceID, nil)
 }
+
+// llmSpendLimitTarget checks that the user or workspace (scope) a spend
+// limit request is for exists. It writes the error response and returns
+// false otherwise.
+func (s *Server) llmSpendLimitTarget(w http.ResponseWriter, scope, id string) bool {
+	var found bool
+	var err error
+	switch scope {
+	case db.SpendScopeUser:
+		var u *db.User
+		u, err = s.DB.GetUserByID(id)
+		found = u != nil
+	case db.SpendScopeWorkspace:
+		var ws *db.Workspace
+		ws, err = s.DB.GetWorkspace(id)
+		found = ws != nil
+	}
+	if err != nil {
+		log.Printf("admin: failed to look up %s %s: %v", scope, id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return false
+	}
+	if !found {
+		http.Error(w, scope+" not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// llmSpendLimitFields returns a spend limit keyed like the admin request,
+// or nil if there is none.
+func llmSpendLimitFields(l *db.LLMSpendLimit) map[string]interface{} {
+	if l == nil {
+		return nil
+	}
+	m := map[string]interface{}{
+		"max_tokens_per_month": l.MaxTokensPerMonth,
+		"max_usd_per_month":    l.MaxUSDPerMonth,
+		"updated_at":           l.UpdatedAt.Format(time.RFC3339),
+	}
+	if l.ResetAt != nil {
+		m["reset_at"] = l.ResetAt.Format(time.RFC3339)
+	}
+	return m
+}
+
+// GET /api/admin/{users,workspaces}/{id}/llm-spend-limit returns the limit
+// and, when the LLM proxy is configured, the spend in its current window.
+func (s *Server) handleAdminGetLLMSpendLimit(scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		l, err := s.DB.GetLLMSpendLimit(scope, id)
+		if err != nil {
+			log.Printf("admin: failed to get llm spend limit: %v", err)
+			http.Error(w, "failed to get llm spend limit", http.StatusInternalServerError)
+			return
+		}
+		resp := map[string]interface{}{"limit": llmSpendLimitFields(l)}
+		if s.LLMProxyURL != "" {
+			window := (&db.LLMSpendLimit{}).WindowStart(time.Now())
+			if l != nil {
+				window = l.WindowStart(time.Now())
+			}
+			spend, err := s.fetchLLMSpend(scope, id, window)
+			if err != nil {
+				log.Printf("admin: failed to get llm spend of %s %s: %v", scope, id, err)
+			} else {
+				spend["since"] = window.Format(time.RFC3339)
+				resp["spend"] = spend
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// fetchLLMSpend returns the tokens and cost of a user or workspace since a
+// time from the LLM proxy.
+func (s *Server) fetchLLMSpend(scope, id string, since time.Time) (map[string]interface{}, error) {
+	q := url.Values{"scope": {scope}, "id": {id}, "since": {since.Format(time.RFC3339)}}
+	resp, err := http.Get(s.LLMProxyURL + "/internal/spend?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llmproxy returned %s", resp.Status)
+	}
+	var spend map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&spend); err != nil {
+		return nil, err
+	}
+	return spend, nil
+}
+
+// PUT /api/admin/{users,workspaces}/{id}/llm-spend-limit sets the limit:
+// {"max_tokens_per_month": 5000000, "max_usd_per_month": 100}. Null fields
+// are unlimited.
+func (s *Server) handleAdminSetLLMSpendLimit(scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		var req struct {
+			MaxTokensPerMonth *int64   `json:"max_tokens_per_month"`
+			MaxUSDPerMonth    *float64 `json:"max_usd_per_month"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.MaxTokensPerMonth != nil && *req.MaxTokensPerMonth < 0 {
+			http.Error(w, "max_tokens_per_month must be >= 0", http.StatusBadRequest)
+			return
+		}
+		if req.MaxUSDPerMonth != nil && *req.MaxUSDPerMonth < 0 {
+			http.Error(w, "max_usd_per_month must be >= 0", http.StatusBadRequest)
+			return
+		}
+		if !s.llmSpendLimitTarget(w, scope, id) {
+			return
+		}
+		if isDryRun(r) {
+			current, err := s.DB.GetLLMSpendLimit(scope, id)
+			if err != nil {
+				log.Printf("admin: failed to get llm spend limit: %v", err)
+				http.Error(w, "failed to get llm spend limit", http.StatusInternalServerError)
+				return
+			}
+			writeDryRun(w, quotaDryRunActions("set_llm_spend_limit", id,
+				quotaChangeDetails(llmSpendLimitFields(current), req, true)))
+			return
+		}
+
+		if err := s.DB.SetLLMSpendLimit(scope, id, req.MaxTokensPerMonth, req.MaxUSDPerMonth); err != nil {
+			log.Printf("admin: failed to set llm spend limit: %v", err)
+			http.Error(w, "failed to set llm spend limit", http.StatusInternalServerError)
+			return
+		}
+		s.recordQuotaChange(r, scope+"_llm_spend", id, "set", req)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DELETE /api/admin/{users,workspaces}/{id}/llm-spend-limit removes the limit.
+func (s *Server) handleAdminDeleteLLMSpendLimit(scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if isDryRun(r) {
+			current, err := s.DB.GetLLMSpendLimit(scope, id)
+			if err != nil {
+				log.Printf("admin: failed to get llm spend limit: %v", err)
+				http.Error(w, "failed to get llm spend limit", http.StatusInternalServerError)
+				return
+			}
+			writeDryRun(w, quotaDryRunActions("delete_llm_spend_limit", id, llmSpendLimitFields(current)))
+			return
+		}
+		if err := s.DB.DeleteLLMSpendLimit(scope, id); err != nil {
+			log.Printf("admin: failed to delete llm spend limit: %v", err)
+			http.Error(w, "failed to delete llm spend limit", http.StatusInternalServerError)
+			return
+		}
+		s.recordQuotaChange(r, scope+"_llm_spend", id, "delete", nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// POST /api/admin/{users,workspaces}/{id}/llm-spend-limit/reset restarts
+// counting spend now, lifting an exhausted limit until the spend reaches it
+// again.
+func (s *Server) handleAdminResetLLMSpendLimit(scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		ok, err := s.DB.ResetLLMSpendLimit(scope, id)
+		if err != nil {
+			log.Printf("admin: failed to reset llm spend limit: %v", err)
+			http.Error(w, "failed to reset llm spend limit", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "no llm spend limit set", http.StatusNotFound)
+			return
+		}
+		s.recordQuotaChange(r, scope+"_llm_spend", id, "reset", nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,460 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/storage"
+)
+
+// Admin digests: a periodic summary for the admins of new users, sandbox
+// counts and failures, the top LLM token consumers, storage growth and
+// orphaned resources. Each digest is stored, so the next one starts where
+// it ended and storage growth is measured against it; when a mailer is
+// configured it is emailed to every admin.
+
+// digestTopTokenUsers is the number of token consumers listed in a digest.
+const digestTopTokenUsers = 10
+
+// defaultAdminDigestInterval is the digest period previewed when the
+// digest loop is disabled.
+const defaultAdminDigestInterval = 7 * 24 * time.Hour
+
+// adminDigestSummary is the content of a digest, stored as its summary.
+type adminDigestSummary struct {
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	db.DigestActivity
+	TopTokenUsers []digestTokenUser `json:"top_token_users"`
+	// StorageBytes is the provisioned workspace drive storage; the growth
+	// is against the previous digest and absent for the first one.
+	StorageBytes       int64  `json:"storage_bytes"`
+	StorageGrowthBytes *int64 `json:"storage_growth_bytes,omitempty"`
+	// OrphanVolumes is only known on the Docker backend.
+	OrphanVolumes *digestOrphanVolumes `json:"orphan_volumes,omitempty"`
+	// Errors lists the sections that couldn't be gathered.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// digestTokenUser is a user's LLM usage in a digest period.
+type digestTokenUser struct {
+	UserID       string `json:"user_id"`
+	Email        string `json:"email,omitempty"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+	CacheTokens  int64  `json:"cache_tokens"`
+	Requests     int64  `json:"requests"`
+}
+
+type digestOrphanVolumes struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// llmUsageRow is a row of the LLM proxy's /internal/usage.
+type llmUsageRow struct {
+	UserID                   string `json:"user_id"`
+	InputTokens              int64  `json:"input_tokens"`
+	OutputTokens             int64  `json:"output_tokens"`
+	CacheCreationInputTokens int64  `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64  `json:"cache_read_input_tokens"`
+	RequestCount             int64  `json:"request_count"`
+}
+
+// topTokenUsers sums per-model usage rows per user and returns the n users
+// with the most input and output tokens. Usage without a user (workspace
+// tokens) is skipped.
+func topTokenUsers(rows []llmUsageRow, n int) []digestTokenUser {
+	byUser := make(map[string]*digestTokenUser)
+	for _, r := range rows {
+		if r.UserID == "" {
+			continue
+		}
+		u := byUser[r.UserID]
+		if u == nil {
+			u = &digestTokenUser{UserID: r.UserID}
+			byUser[r.UserID] = u
+		}
+		u.InputTokens += r.InputTokens
+		u.OutputTokens += r.OutputTokens
+		u.CacheTokens += r.CacheCreationInputTokens + r.CacheReadInputTokens
+		u.Requests += r.RequestCount
+	}
+	users := make([]digestTokenUser, 0, len(byUser))
+	for _, u := range byUser {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		ti, tj := users[i].InputTokens+users[i].OutputTokens, users[j].InputTokens+users[j].OutputTokens
+		if ti != tj {
+			return ti > tj
+		}
+		return users[i].UserID < users[j].UserID
+	})
+	if len(users) > n {
+		users = users[:n]
+	}
+	return users
+}
+
+// fetchTopTokenUsers returns the top LLM token consumers in [since, until)
+// from the LLM proxy, with their email addresses.
+func (s *Server) fetchTopTokenUsers(since, until time.Time) ([]digestTokenUser, error) {
+	q := url.Values{}
+	q.Set("group_by", "user")
+	q.Set("since", since.Format(time.RFC3339))
+	q.Set("until", until.Format(time.RFC3339))
+	resp, err := http.Get(s.LLMProxyURL + "/internal/usage?" + q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("llmproxy usage: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llmproxy usage: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Usage []llmUsageRow `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("llmproxy usage: decode: %w", err)
+	}
+	users := topTokenUsers(body.Usage, digestTopTokenUsers)
+	for i := range users {
+		if u, err := s.DB.GetUserByID(users[i].UserID); err == nil && u != nil {
+			users[i].Email = u.Email
+		}
+	}
+	return users, nil
+}
+
+// buildAdminDigest gathers the digest of [since, until). previous is the
+// last stored digest, if any. Only database errors fail the digest; the
+// LLM proxy and drive manager sections are skipped and noted on error.
+func (s *Server) buildAdminDigest(ctx context.Context, since, until time.Time, previous *db.AdminDigest) (*adminDigestSummary, error) {
+	activity, err := s.DB.AdminDigestActivity(since, until)
+	if err != nil {
+		return nil, err
+	}
+	sum := &adminDigestSummary{
+		PeriodStart:    since.UTC(),
+		PeriodEnd:      until.UTC(),
+		DigestActivity: *activity,
+		TopTokenUsers:  []digestTokenUser{},
+	}
+
+	if s.LLMProxyURL != "" {
+		users, err := s.fetchTopTokenUsers(since, until)
+		if err != nil {
+			sum.Errors = append(sum.Errors, "token usage: "+err.Error())
+		} else {
+			sum.TopTokenUsers = users
+		}
+	}
+
+	for wsID, n := range activity.VolumesByWorkspace {
+		wd, err := s.effectiveWorkspaceDefaults(wsID)
+		if err != nil {
+			return nil, err
+		}
+		sum.StorageBytes += n * wd.MaxDriveSize
+	}
+	if previous != nil {
+		var prev adminDigestSummary
+		if err := json.Unmarshal(previous.Summary, &prev); err == nil {
+			growth := sum.StorageBytes - prev.StorageBytes
+			sum.StorageGrowthBytes = &growth
+		}
+	}
+
+	if lister, ok := s.DriveManager.(storage.DockerVolumeLister); ok {
+		volumes, err := lister.ListDockerVolumes(ctx)
+		if err != nil {
+			sum.Errors = append(sum.Errors, "docker volumes: "+err.Error())
+		} else {
+			sum.OrphanVolumes = &digestOrphanVolumes{}
+			for _, v := range volumes {
+				if v.Orphan {
+					sum.OrphanVolumes.Count++
+					if v.Size > 0 {
+						sum.OrphanVolumes.Bytes += v.Size
+					}
+				}
+			}
+		}
+	}
+	return sum, nil
+}
+
+// renderAdminDigest renders a digest as the plain-text email body.
+func renderAdminDigest(sum *adminDigestSummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Agentserver digest, %s to %s (UTC)\n",
+		sum.PeriodStart.Format("2006-01-02 15:04"), sum.PeriodEnd.Format("2006-01-02 15:04"))
+
+	fmt.Fprintf(&b, "\nUsers\n  new: %d, total: %d\n", sum.NewUsers, sum.UsersTotal)
+	for _, email := range sum.NewUserEmails {
+		fmt.Fprintf(&b, "  + %s\n", email)
+	}
+	if n := sum.NewUsers - int64(len(sum.NewUserEmails)); n > 0 {
+		fmt.Fprintf(&b, "  ... and %d more\n", n)
+	}
+
+	statuses := make([]string, 0, len(sum.SandboxesByStatus))
+	for status := range sum.SandboxesByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(&b, "\nSandboxes\n  created: %d, run: %d, compute: %.1f sandbox-hours\n",
+		sum.SandboxesCreated, sum.SandboxesRun, sum.ComputeHours)
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "  %-22s %d\n", status, sum.SandboxesByStatus[status])
+	}
+
+	fmt.Fprintf(&b, "\nFailures\n  failed post-start hooks: %d, failed migrations: %d\n",
+		sum.FailedHookRuns, sum.FailedMigrations)
+	for _, f := range sum.FailingSandboxes {
+		fmt.Fprintf(&b, "  %s (%s, workspace %s) %s: %s\n", f.Name, f.SandboxID, f.WorkspaceID, f.Status, f.Message)
+	}
+
+	b.WriteString("\nTop token consumers\n")
+	if len(sum.TopTokenUsers) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, u := range sum.TopTokenUsers {
+		who := u.Email
+		if who == "" {
+			who = u.UserID
+		}
+		fmt.Fprintf(&b, "  %-40s %14d in %12d out %8d requests\n", who, u.InputTokens, u.OutputTokens, u.Requests)
+	}
+
+	fmt.Fprintf(&b, "\nStorage\n  provisioned: %s", formatBytes(sum.StorageBytes))
+	if sum.StorageGrowthBytes != nil {
+		sign := "+"
+		growth := *sum.StorageGrowthBytes
+		if growth < 0 {
+			sign, growth = "-", -growth
+		}
+		fmt.Fprintf(&b, " (%s%s since the last digest)", sign, formatBytes(growth))
+	}
+	b.WriteString("\n")
+
+	if sum.OrphanVolumes != nil {
+		fmt.Fprintf(&b, "\nOrphaned resources\n  docker volumes: %d (%s)\n",
+			sum.OrphanVolumes.Count, formatBytes(sum.OrphanVolumes.Bytes))
+	}
+
+	if len(sum.Errors) > 0 {
+		b.WriteString("\nIncomplete sections\n")
+		for _, e := range sum.Errors {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	return b.String()
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// createAdminDigest builds and stores the digest of [since, until).
+func (s *Server) createAdminDigest(ctx context.Context, since, until time.Time, previous *db.AdminDigest) (*db.AdminDigest, *adminDigestSummary, error) {
+	sum, err := s.buildAdminDigest(ctx, since, until, previous)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary, err := json.Marshal(sum)
+	if err != nil {
+		return nil, nil, err
+	}
+	d := &db.AdminDigest{PeriodStart: sum.PeriodStart, PeriodEnd: sum.PeriodEnd, Summary: summary}
+	if err := s.DB.CreateAdminDigest(d); err != nil {
+		return nil, nil, err
+	}
+	return d, sum, nil
+}
+
+// emailAdminDigest sends a stored digest to the admins and marks it sent.
+func (s *Server) emailAdminDigest(d *db.AdminDigest) error {
+	var sum adminDigestSummary
+	if err := json.Unmarshal(d.Summary, &sum); err != nil {
+		return fmt.Errorf("decode digest %d: %w", d.ID, err)
+	}
+	to, err := s.DB.ListAdminEmails()
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no admin with an email address")
+	}
+	subject := fmt.Sprintf("Agentserver digest %s to %s",
+		sum.PeriodStart.Format("2006-01-02"), sum.PeriodEnd.Format("2006-01-02"))
+	if err := s.StatementMailer.Send(to, subject, renderAdminDigest(&sum), "", nil); err != nil {
+		return err
+	}
+	if err := s.DB.MarkAdminDigestSent(d.ID); err != nil {
+		return err
+	}
+	now := time.Now()
+	d.SentAt = &now
+	return nil
+}
+
+// digestPeriodStart is where the next digest starts: the end of the
+// previous one, or one interval before now for the first.
+func digestPeriodStart(previous *db.AdminDigest, now time.Time, every time.Duration) time.Time {
+	if previous != nil {
+		return previous.PeriodEnd
+	}
+	return now.Add(-every)
+}
+
+// runAdminDigestOnce creates and emails a digest once a full period has
+// passed since the last one, and retries sending the last one if it
+// failed.
+func (s *Server) runAdminDigestOnce(ctx context.Context, now time.Time, every time.Duration) {
+	latest, err := s.DB.LatestAdminDigest()
+	if err != nil {
+		log.Printf("admin digest: %v", err)
+		return
+	}
+	if latest != nil && now.Sub(latest.PeriodEnd) < every {
+		if s.StatementMailer != nil && latest.SentAt == nil {
+			if err := s.emailAdminDigest(latest); err != nil {
+				log.Printf("admin digest: email digest %d: %v", latest.ID, err)
+			}
+		}
+		return
+	}
+	d, _, err := s.createAdminDigest(ctx, digestPeriodStart(latest, now, every), now, latest)
+	if err != nil {
+		log.Printf("admin digest: %v", err)
+		return
+	}
+	if s.StatementMailer != nil {
+		if err := s.emailAdminDigest(d); err != nil {
+			log.Printf("admin digest: email digest %d: %v", d.ID, err)
+		}
+	}
+}
+
+// StartAdminDigestLoop is the exported entry point for the server's main
+// lifecycle to launch the admin digest loop in a goroutine. A no-op when
+// AdminDigestInterval is 0.
+func (s *Server) StartAdminDigestLoop(ctx context.Context) {
+	every := s.AdminDigestInterval
+	if every <= 0 {
+		return
+	}
+	check := time.Hour
+	if every < check {
+		check = every
+	}
+	t := time.NewTicker(check)
+	defer t.Stop()
+	for {
+		s.runAdminDigestOnce(ctx, time.Now(), every)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// GET /api/admin/digests lists the 20 latest digests.
+func (s *Server) handleAdminListDigests(w http.ResponseWriter, r *http.Request) {
+	digests, err := s.DB.ListAdminDigests(20)
+	if err != nil {
+		log.Printf("admin: failed to list digests: %v", err)
+		http.Error(w, "failed to list digests", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"digests": digests})
+}
+
+// GET /api/admin/digests/preview?format=json|text builds the digest of the
+// period since the last one without storing or sending it.
+func (s *Server) handleAdminPreviewDigest(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "text" {
+		http.Error(w, "format must be json or text", http.StatusBadRequest)
+		return
+	}
+	latest, err := s.DB.LatestAdminDigest()
+	if err != nil {
+		log.Printf("admin: failed to get latest digest: %v", err)
+		http.Error(w, "failed to build digest", http.StatusInternalServerError)
+		return
+	}
+	every := s.AdminDigestInterval
+	if every <= 0 {
+		every = defaultAdminDigestInterval
+	}
+	now := time.Now()
+	sum, err := s.buildAdminDigest(r.Context(), digestPeriodStart(latest, now, every), now, latest)
+	if err != nil {
+		log.Printf("admin: failed to build digest: %v", err)
+		http.Error(w, "failed to build digest", http.StatusInternalServerError)
+		return
+	}
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, renderAdminDigest(sum))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sum)
+}
+
+// POST /api/admin/digests creates the digest of the period since the last
+// one now and emails it when a mailer is configured. The next scheduled
+// digest starts from it.
+func (s *Server) handleAdminCreateDigest(w http.ResponseWriter, r *http.Request) {
+	latest, err := s.DB.LatestAdminDigest()
+	if err != nil {
+		log.Printf("admin: failed to get latest digest: %v", err)
+		http.Error(w, "failed to create digest", http.StatusInternalServerError)
+		return
+	}
+	every := s.AdminDigestInterval
+	if every <= 0 {
+		every = defaultAdminDigestInterval
+	}
+	now := time.Now()
+	d, _, err := s.createAdminDigest(r.Context(), digestPeriodStart(latest, now, every), now, latest)
+	if err != nil {
+		log.Printf("admin: failed to create digest: %v", err)
+		http.Error(w, "failed to create digest", http.StatusInternalServerError)
+		return
+	}
+	if s.StatementMailer != nil {
+		if err := s.emailAdminDigest(d); err != nil {
+			log.Printf("admin: failed to email digest %d: %v", d.ID, err)
+			http.Error(w, "digest saved but sending failed", http.StatusBadGateway)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestTopTokenUsers(t *testing.T) {
+	rows := []llmUsageRow{
+		{UserID: "u-1", InputTokens: 100, OutputTokens: 10, RequestCount: 2},
+		{UserID: "u-2", InputTokens: 500, OutputTokens: 50, CacheReadInputTokens: 7, RequestCount: 1},
+		{UserID: "u-1", InputTokens: 1000, OutputTokens: 100, CacheCreationInputTokens: 3, RequestCount: 3},
+		{UserID: "", InputTokens: 1 << 40},
+		{UserID: "u-3", InputTokens: 1},
+	}
+	got := topTokenUsers(rows, 2)
+	if len(got) != 2 {
+		t.Fatalf("topTokenUsers = %+v, want 2 users", got)
+	}
+	if got[0].UserID != "u-1" || got[0].InputTokens != 1100 || got[0].OutputTokens != 110 || got[0].CacheTokens != 3 || got[0].Requests != 5 {
+		t.Errorf("first = %+v", got[0])
+	}
+	if got[1].UserID != "u-2" || got[1].CacheTokens != 7 {
+		t.Errorf("second = %+v", got[1])
+	}
+}
+
+func TestRenderAdminDigest(t *testing.T) {
+	growth := int64(-2 << 30)
+	sum := &adminDigestSummary{
+		PeriodStart: time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		DigestActivity: db.DigestActivity{
+			UsersTotal:        40,
+			NewUsers:          3,
+			NewUserEmails:     []string{"a@example.com", "b@example.com"},
+			SandboxesByStatus: map[string]int64{"running": 4, "paused": 9},
+			FailingSandboxes:  []db.DigestSandboxFailure{{SandboxID: "s-1", Name: "api", WorkspaceID: "w-1", Status: "storage-failed", Message: "pvc pending"}},
+		},
+		TopTokenUsers:      []digestTokenUser{{UserID: "u-1", Email: "a@example.com", InputTokens: 1200}, {UserID: "u-9"}},
+		StorageBytes:       10 << 30,
+		StorageGrowthBytes: &growth,
+		OrphanVolumes:      &digestOrphanVolumes{Count: 2, Bytes: 3 << 20},
+		Errors:             []string{"token usage: llmproxy usage: status 502"},
+	}
+	text := renderAdminDigest(sum)
+	for _, want := range []string{
+		"2026-10-05 00:00 to 2026-10-12 00:00",
+		"new: 3, total: 40",
+		"+ b@example.com",
+		"... and 1 more",
+		"api (s-1, workspace w-1) storage-failed: pvc pending",
+		"a@example.com",
+		"u-9",
+		"provisioned: 10.0 GiB (-2.0 GiB since the last digest)",
+		"docker volumes: 2 (3.0 MiB)",
+		"status 502",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digest lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "paused") > strings.Index(text, "running") {
+		t.Errorf("statuses not sorted:\n%s", text)
+	}
+}
+
+func TestDigestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	if got := digestPeriodStart(nil, now, 24*time.Hour); !got.Equal(now.Add(-24 * time.Hour)) {
+		t.Errorf("first digest starts at %s", got)
+	}
+	prev := &db.AdminDigest{PeriodEnd: now.Add(-30 * 24 * time.Hour)}
+	if got := digestPeriodStart(prev, now, 24*time.Hour); !got.Equal(prev.PeriodEnd) {
+		t.Errorf("digest after a gap starts at %s, want %s", got, prev.PeriodEnd)
+	}
+}
@@ -23,6 +23,12 @@ func (s *Server) handleListAgentCards(w http.ResponseWriter, r *http.Request) {
 		cards = []db.AgentCard{}
 	}
 
+	// Tunnel traffic of local agents, flushed by sandbox-proxy.
+	tunnels, err := s.DB.ListTunnelStatsByWorkspace(wid)
+	if err != nil {
+		log.Printf("list tunnel stats: %v", err)
+	}
+
 	type cardResponse struct {
 		AgentID     string          `json:"agent_id"`
 		DisplayName string          `json:"display_name"`
@@ -31,6 +37,7 @@ func (s *Server) handleListAgentCards(w http.ResponseWriter, r *http.Request) {
 		Status      string          `json:"status"`
 		Card        json.RawMessage `json:"card"`
 		Version     int             `json:"version"`
+		Tunnel      *db.TunnelStats `json:"tunnel,omitempty"`
 	}
 
 	result := make([]cardResponse, len(cards))
@@ -43,6 +50,7 @@ func (s *Server) handleListAgentCards(w http.ResponseWriter, r *http.Request) {
 			Status:      c.AgentStatus,
 			Card:        c.CardJSON,
 			Version:     c.Version,
+			Tunnel:      tunnels[c.SandboxID],
 		}
 	}
 
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/agentserver/agentserver/internal/clock"
 	"github.com/agentserver/agentserver/internal/db"
 )
 
@@ -13,6 +14,11 @@ type AgentHealthMonitor struct {
 	db       *db.DB
 	interval time.Duration // sweep interval (30s)
 	offline  time.Duration // heartbeat threshold (60s)
+	clock    clock.Clock
+
+	// OnOffline, if set, is called with the agents each sweep marked
+	// offline.
+	OnOffline func(cards []db.AgentCard)
 }
 
 func NewAgentHealthMonitor(database *db.DB) *AgentHealthMonitor {
@@ -20,23 +26,27 @@ func NewAgentHealthMonitor(database *db.DB) *AgentHealthMonitor {
 		db:       database,
 		interval: 30 * time.Second,
 		offline:  60 * time.Second,
+		clock:    clock.Real,
 	}
 }
 
 // Run blocks until ctx is cancelled.
 func (m *AgentHealthMonitor) Run(ctx context.Context) {
-	ticker := time.NewTicker(m.interval)
+	ticker := m.clock.NewTicker(m.interval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			n, err := m.db.MarkStaleAgentCardsOffline(m.offline)
+		case <-ticker.C():
+			cards, err := m.db.MarkAgentCardsOffline(m.clock.Now().Add(-m.offline))
 			if err != nil {
 				log.Printf("agent-health: mark offline error: %v", err)
-			} else if n > 0 {
-				log.Printf("agent-health: marked %d agents offline", n)
+			} else if len(cards) > 0 {
+				log.Printf("agent-health: marked %d agents offline", len(cards))
+				if m.OnOffline != nil {
+					m.OnOffline(cards)
+				}
 			}
 		}
 	}
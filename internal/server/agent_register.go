@@ -48,6 +48,16 @@ func (s *Server) handleAgentRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	userID := introspection.Subject
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil {
+		log.Printf("agent register: get user: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.DisabledAt != nil {
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
 
 	// Verify workspace membership (defense in depth).
 	role, err := s.DB.GetWorkspaceMemberRole(workspaceID, userID)
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Announcements are banners (maintenance windows, policy changes) shown to
+// users inside the product. Admins manage global and workspace
+// announcements; workspace owners manage their workspace's. Users get the
+// ones currently addressed to them from /api/announcements.
+
+var (
+	announcementSeverities = map[string]bool{"info": true, "warning": true, "critical": true}
+	// Audience roles: platform roles for global announcements, workspace
+	// roles for workspace ones.
+	globalAnnouncementRoles    = map[string]bool{"admin": true, "user": true}
+	workspaceAnnouncementRoles = map[string]bool{"owner": true, "maintainer": true, "developer": true, "guest": true}
+)
+
+const (
+	maxAnnouncementTitle = 200
+	maxAnnouncementBody  = 4000
+)
+
+type announcementRequest struct {
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	Severity string     `json:"severity"`
+	Roles    []string   `json:"roles"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// WorkspaceID scopes an admin-created announcement to a workspace;
+	// ignored elsewhere.
+	WorkspaceID string `json:"workspace_id"`
+}
+
+func (req *announcementRequest) validate(workspaceScoped bool) error {
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if len(req.Title) > maxAnnouncementTitle {
+		return fmt.Errorf("title is too long (max %d)", maxAnnouncementTitle)
+	}
+	if len(req.Body) > maxAnnouncementBody {
+		return fmt.Errorf("body is too long (max %d)", maxAnnouncementBody)
+	}
+	if req.Severity == "" {
+		req.Severity = "info"
+	}
+	if !announcementSeverities[req.Severity] {
+		return fmt.Errorf("severity must be info, warning or critical")
+	}
+	valid := globalAnnouncementRoles
+	if workspaceScoped {
+		valid = workspaceAnnouncementRoles
+	}
+	for _, role := range req.Roles {
+		if !valid[role] {
+			return fmt.Errorf("invalid audience role %q", role)
+		}
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+func (req *announcementRequest) apply(a *db.Announcement) {
+	a.Title = req.Title
+	a.Body = req.Body
+	a.Severity = req.Severity
+	a.Roles = req.Roles
+	a.StartsAt = time.Now()
+	if req.StartsAt != nil {
+		a.StartsAt = *req.StartsAt
+	}
+	a.EndsAt = req.EndsAt
+}
+
+func announcementJSON(a *db.Announcement) map[string]interface{} {
+	roles := a.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	resp := map[string]interface{}{
+		"id":         a.ID,
+		"title":      a.Title,
+		"body":       a.Body,
+		"severity":   a.Severity,
+		"roles":      roles,
+		"starts_at":  a.StartsAt.Format(time.RFC3339),
+		"created_at": a.CreatedAt.Format(time.RFC3339),
+		"updated_at": a.UpdatedAt.Format(time.RFC3339),
+	}
+	if a.WorkspaceID != nil {
+		resp["workspace_id"] = *a.WorkspaceID
+	}
+	if a.EndsAt != nil {
+		resp["ends_at"] = a.EndsAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func writeAnnouncements(w http.ResponseWriter, announcements []*db.Announcement) {
+	resp := make([]map[string]interface{}, 0, len(announcements))
+	for _, a := range announcements {
+		resp = append(resp, announcementJSON(a))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"announcements": resp})
+}
+
+// GET /api/announcements?workspace_id=
+func (s *Server) handleListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil || user == nil {
+		log.Printf("failed to get user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	announcements, err := s.DB.ListActiveAnnouncements(userID, user.Role, r.URL.Query().Get("workspace_id"))
+	if err != nil {
+		log.Printf("failed to list announcements for user %s: %v", userID, err)
+		http.Error(w, "failed to list announcements", http.StatusInternalServerError)
+		return
+	}
+	writeAnnouncements(w, announcements)
+}
+
+// GET /api/workspaces/{id}/announcements
+func (s *Server) handleListWorkspaceAnnouncements(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	s.listAnnouncements(w, wsID)
+}
+
+// POST /api/workspaces/{id}/announcements
+func (s *Server) handleCreateWorkspaceAnnouncement(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	var req announcementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.createAnnouncement(w, r, &req, wsID)
+}
+
+// PUT /api/workspaces/{id}/announcements/{announcementId}
+func (s *Server) handleUpdateWorkspaceAnnouncement(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	if a, ok := s.workspaceAnnouncement(w, wsID, chi.URLParam(r, "announcementId")); ok {
+		s.updateAnnouncement(w, r, a)
+	}
+}
+
+// DELETE /api/workspaces/{id}/announcements/{announcementId}
+func (s *Server) handleDeleteWorkspaceAnnouncement(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	if a, ok := s.workspaceAnnouncement(w, wsID, chi.URLParam(r, "announcementId")); ok {
+		s.deleteAnnouncement(w, a.ID)
+	}
+}
+
+// workspaceAnnouncement looks up an announcement of a workspace, writing a
+// 404 if it doesn't exist or belongs elsewhere.
+func (s *Server) workspaceAnnouncement(w http.ResponseWriter, wsID, id string) (*db.Announcement, bool) {
+	a, err := s.DB.GetAnnouncement(id)
+	if err != nil {
+		log.Printf("failed to get announcement %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if a == nil || a.WorkspaceID == nil || *a.WorkspaceID != wsID {
+		http.Error(w, "announcement not found", http.StatusNotFound)
+		return nil, false
+	}
+	return a, true
+}
+
+// GET /api/admin/announcements?workspace_id=
+func (s *Server) handleAdminListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	s.listAnnouncements(w, r.URL.Query().Get("workspace_id"))
+}
+
+// POST /api/admin/announcements
+func (s *Server) handleAdminCreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var req announcementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.WorkspaceID != "" {
+		ws, err := s.DB.GetWorkspace(req.WorkspaceID)
+		if err != nil {
+			log.Printf("admin: failed to get workspace %s: %v", req.WorkspaceID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if ws == nil {
+			http.Error(w, "workspace not found", http.StatusNotFound)
+			return
+		}
+	}
+	s.createAnnouncement(w, r, &req, req.WorkspaceID)
+}
+
+// PUT /api/admin/announcements/{announcementId}
+func (s *Server) handleAdminUpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "announcementId")
+	a, err := s.DB.GetAnnouncement(id)
+	if err != nil {
+		log.Printf("admin: failed to get announcement %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if a == nil {
+		http.Error(w, "announcement not found", http.StatusNotFound)
+		return
+	}
+	s.updateAnnouncement(w, r, a)
+}
+
+// DELETE /api/admin/announcements/{announcementId}
+func (s *Server) handleAdminDeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	s.deleteAnnouncement(w, chi.URLParam(r, "announcementId"))
+}
+
+func (s *Server) listAnnouncements(w http.ResponseWriter, wsID string) {
+	announcements, err := s.DB.ListAnnouncements(wsID)
+	if err != nil {
+		log.Printf("failed to list announcements: %v", err)
+		http.Error(w, "failed to list announcements", http.StatusInternalServerError)
+		return
+	}
+	writeAnnouncements(w, announcements)
+}
+
+// createAnnouncement creates an announcement in workspace wsID, or a global
+// one if wsID is empty.
+func (s *Server) createAnnouncement(w http.ResponseWriter, r *http.Request, req *announcementRequest, wsID string) {
+	if err := req.validate(wsID != ""); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	a := &db.Announcement{ID: uuid.New().String(), CreatedBy: &userID}
+	if wsID != "" {
+		a.WorkspaceID = &wsID
+	}
+	req.apply(a)
+	if err := s.DB.CreateAnnouncement(a); err != nil {
+		log.Printf("failed to create announcement: %v", err)
+		http.Error(w, "failed to create announcement", http.StatusInternalServerError)
+		return
+	}
+	s.writeAnnouncement(w, http.StatusCreated, a.ID)
+}
+
+func (s *Server) updateAnnouncement(w http.ResponseWriter, r *http.Request, a *db.Announcement) {
+	var req announcementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(a.WorkspaceID != nil); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.apply(a)
+	if err := s.DB.UpdateAnnouncement(a); err != nil {
+		log.Printf("failed to update announcement %s: %v", a.ID, err)
+		http.Error(w, "failed to update announcement", http.StatusInternalServerError)
+		return
+	}
+	s.writeAnnouncement(w, http.StatusOK, a.ID)
+}
+
+func (s *Server) deleteAnnouncement(w http.ResponseWriter, id string) {
+	if err := s.DB.DeleteAnnouncement(id); err != nil {
+		log.Printf("failed to delete announcement %s: %v", id, err)
+		http.Error(w, "failed to delete announcement", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) writeAnnouncement(w http.ResponseWriter, status int, id string) {
+	a, err := s.DB.GetAnnouncement(id)
+	if err != nil || a == nil {
+		log.Printf("failed to get announcement %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(announcementJSON(a))
+}
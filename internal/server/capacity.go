@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	capacityCheckTimeout = 10 * time.Second
+	// capacityRetryAfter is the Retry-After hint sent when the cluster is
+	// full; pods free up as sandboxes are paused or deleted.
+	capacityRetryAfter = 60
+)
+
+// capacityChecker is implemented by backends that can report cluster
+// capacity (K8s).
+type capacityChecker interface {
+	Capacity(ctx context.Context, cpu int, memory int64, pool *process.NodePool) (*process.Capacity, error)
+}
+
+// sandboxCapacity returns the cluster capacity for a sandbox, or nil if the
+// backend can't tell. Errors are logged and treated as unknown, so a
+// failing capacity query never blocks sandbox creation.
+func (s *Server) sandboxCapacity(ctx context.Context, cpu int, memory int64, pool *process.NodePool) *process.Capacity {
+	cc, ok := s.ProcessManager.(capacityChecker)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, capacityCheckTimeout)
+	defer cancel()
+	c, err := cc.Capacity(ctx, cpu, memory, pool)
+	if err != nil {
+		log.Printf("capacity check failed: %v", err)
+		return nil
+	}
+	return c
+}
+
+// rejectIfNoCapacity fails fast when a sandbox can't be scheduled: 422 if
+// it is larger than any eligible node, 503 with Retry-After if the nodes
+// are currently full and the scheduling queue doesn't wait for capacity.
+// It reports whether it wrote a response.
+func (s *Server) rejectIfNoCapacity(w http.ResponseWriter, r *http.Request, cpu int, memory int64, pool *process.NodePool) bool {
+	c := s.sandboxCapacity(r.Context(), cpu, memory, pool)
+	if c == nil || c.Fits {
+		return false
+	}
+	if c.FitsEmptyNode && s.scheduleQueue != nil && s.scheduleQueue.fits != nil {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !c.FitsEmptyNode {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "exceeds_node_capacity",
+			"message":  fmt.Sprintf("No node can fit a sandbox with %dm CPU and %d MiB memory. Request fewer resources.", cpu, memory>>20),
+			"capacity": c,
+		})
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(capacityRetryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": "insufficient_capacity",
+		"message": fmt.Sprintf("The cluster has no room for a sandbox with %dm CPU and %d MiB memory right now (largest free: %dm CPU, %d MiB). Pause or delete sandboxes, request fewer resources, or retry later.",
+			cpu, memory>>20, c.MaxFreeCPU, c.MaxFreeMemory>>20),
+		"capacity": c,
+	})
+	return true
+}
+
+// handleSandboxStartFailed records a failed container start. Unschedulable
+// sandboxes are kept in StatusUnschedulable with the scheduler's message so
+// the start can be retried; other failures delete the record as before.
+func (s *Server) handleSandboxStartFailed(id string, opts process.StartOptions, err error) {
+	log.Printf("failed to start container for sandbox %s: %v", id, err)
+	if !errors.Is(err, process.ErrUnschedulable) {
+		s.Sandboxes.Delete(id, db.SandboxDeletion{Reason: db.DeletionReasonStartFailed})
+		return
+	}
+	s.savePendingStart(id, opts)
+	if err := s.Sandboxes.UpdateStatusMessage(id, sbxstore.StatusUnschedulable, err.Error()); err != nil {
+		log.Printf("failed to update status for sandbox %s: %v", id, err)
+	}
+}
+
+// POST /api/sandboxes/{id}/retry-start retries starting a sandbox in
+// StatusUnschedulable.
+func (s *Server) handleRetrySandboxStart(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if sbx.Status != sbxstore.StatusUnschedulable {
+		http.Error(w, "sandbox start cannot be retried in current state: "+sbx.Status, http.StatusConflict)
+		return
+	}
+	opts, ok := s.loadPendingStart(w, sbx)
+	if !ok {
+		return
+	}
+	if s.rejectIfNoCapacity(w, r, opts.CPU, opts.Memory, opts.NodePool) {
+		return
+	}
+
+	if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusCreating); err != nil {
+		http.Error(w, "failed to update status", http.StatusInternalServerError)
+		return
+	}
+	s.clearPendingStart(id)
+	// The drive was provisioned (and its mounts kept in opts) on the first
+	// attempt.
+	go s.provisionAndStart(id, sbx.WorkspaceID, opts, false)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": sbxstore.StatusCreating})
+}
+
+// GET /api/admin/capacity?cpu=&memory=&workspace_id=
+// Reports cluster capacity, optionally for a sandbox of the given size on
+// a workspace's node pool.
+func (s *Server) handleAdminCapacity(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.ProcessManager.(capacityChecker); !ok {
+		http.Error(w, "capacity reporting is not supported by this backend", http.StatusNotImplemented)
+		return
+	}
+	q := r.URL.Query()
+	cpu, _ := strconv.Atoi(q.Get("cpu"))
+	memory, _ := strconv.ParseInt(q.Get("memory"), 10, 64)
+	var pool *process.NodePool
+	if wsID := q.Get("workspace_id"); wsID != "" {
+		var err error
+		if pool, err = s.workspaceNodePool(wsID); err != nil {
+			log.Printf("admin: failed to get node pool for workspace %s: %v", wsID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	c := s.sandboxCapacity(r.Context(), cpu, memory, pool)
+	if c == nil {
+		http.Error(w, "failed to query cluster capacity", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
@@ -55,6 +55,9 @@ func (s *Server) handleCodexSessionOpen(w http.ResponseWriter, r *http.Request)
 		writeVerifyUnauthorized(w)
 		return
 	}
+	if row.RevokedAt != nil {
+		s.recordRevokedCodexTokenUse(r, row.ID, row.UserID, req.ClientIP)
+	}
 	if row.RevokedAt != nil || time.Now().UTC().After(row.ExpiresAt) {
 		writeVerifyUnauthorized(w)
 		return
@@ -44,6 +44,9 @@ func (s *Server) handleVerifyCodexToken(w http.ResponseWriter, r *http.Request)
 		writeVerifyUnauthorized(w)
 		return
 	}
+	if row.RevokedAt != nil {
+		s.recordRevokedCodexTokenUse(r, row.ID, row.UserID, "")
+	}
 	if row.RevokedAt != nil || time.Now().UTC().After(row.ExpiresAt) {
 		writeVerifyUnauthorized(w)
 		return
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// List endpoints that clients poll carry an ETag, so a poll whose result
+// didn't change costs a 304 without a body, and hints telling clients how
+// often to poll and where the event stream that makes polling unnecessary
+// is.
+
+// defaultClientPollInterval is the suggested poll interval when
+// ClientPollInterval is not set.
+const defaultClientPollInterval = 2 * time.Second
+
+// clientHints tells clients how to keep a list up to date.
+type clientHints struct {
+	PollIntervalSeconds int    `json:"poll_interval_seconds"`
+	EventsURL           string `json:"events_url,omitempty"` // SSE stream of the changes
+}
+
+// clientHints returns the hints for a list, with the SSE stream of the
+// workspace wsID if it is not empty.
+func (s *Server) clientHints(wsID string) clientHints {
+	interval := s.ClientPollInterval
+	if interval <= 0 {
+		interval = defaultClientPollInterval
+	}
+	h := clientHints{PollIntervalSeconds: int((interval + time.Second - 1) / time.Second)}
+	if wsID != "" {
+		h.EventsURL = "/api/workspaces/" + wsID + "/events"
+	}
+	return h
+}
+
+// setClientHintHeaders sends hints as headers, for responses whose body is
+// a bare list: X-Poll-Interval in seconds and a Link to the event stream.
+func setClientHintHeaders(w http.ResponseWriter, h clientHints) {
+	w.Header().Set("X-Poll-Interval", strconv.Itoa(h.PollIntervalSeconds))
+	if h.EventsURL != "" {
+		w.Header().Set("Link", "<"+h.EventsURL+`>; rel="events"`)
+	}
+}
+
+// bufferedResponse holds a response until its ETag is known.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// comparison applies, as for GET.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalGET buffers successful GET responses, tags them with an ETag
+// of their body and answers 304 Not Modified when the client already has
+// it. Responses are private to the user and must be revalidated on every
+// use, as they change with the sandboxes' state. Only for small JSON
+// responses; streams and downloads must not pass through it.
+func (s *Server) conditionalGET(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		buf := &bufferedResponse{header: w.Header()}
+		next.ServeHTTP(buf, r)
+		if buf.status == 0 {
+			buf.status = http.StatusOK
+		}
+		if buf.status != http.StatusOK {
+			w.WriteHeader(buf.status)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Add("Vary", "Cookie, Authorization")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(buf.body.Len()))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.body.Bytes())
+	})
+}
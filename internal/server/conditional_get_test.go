@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConditionalGET(t *testing.T) {
+	s := &Server{}
+	body := `[{"id":"a"}]`
+	h := s.conditionalGET(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != body || etag == "" {
+		t.Fatalf("first GET: %d %q etag %q", rec.Code, rec.Body.String(), etag)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("matching GET: %d %q", rec.Code, rec.Body.String())
+	}
+
+	body = `[{"id":"b"}]`
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Fatalf("changed GET: %d etag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/?fail=1", nil)
+	req.Header.Set("If-None-Match", "*")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("ETag") != "" {
+		t.Fatalf("failed GET: %d etag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestClientHints(t *testing.T) {
+	s := &Server{}
+	if h := s.clientHints(""); h.PollIntervalSeconds != 2 || h.EventsURL != "" {
+		t.Errorf("default hints = %+v", h)
+	}
+	s.ClientPollInterval = 4500 * time.Millisecond
+	h := s.clientHints("ws1")
+	if h.PollIntervalSeconds != 5 || h.EventsURL != "/api/workspaces/ws1/events" {
+		t.Errorf("hints = %+v", h)
+	}
+	rec := httptest.NewRecorder()
+	setClientHintHeaders(rec, h)
+	if got := rec.Header().Get("X-Poll-Interval"); got != "5" {
+		t.Errorf("X-Poll-Interval = %q", got)
+	}
+	if got := rec.Header().Get("Link"); got != `</api/workspaces/ws1/events>; rel="events"` {
+		t.Errorf("Link = %q", got)
+	}
+}
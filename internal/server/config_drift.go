@@ -0,0 +1,226 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/policy"
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+// Config drift: a setting can be configured in several places (the policy
+// file, admin overrides in system_settings, the environment), and the
+// higher layer silently wins. GET /api/admin/config-drift shows, for each
+// setting, what every layer says and which value is effective, and the
+// server logs the conflicts at startup.
+
+// Layers of the configuration chain.
+const (
+	configSourcePolicy  = "policy"
+	configSourceDB      = "system_settings"
+	configSourceEnv     = "env"
+	configSourceDefault = "default"
+)
+
+// configResolution is how a setting got its effective value.
+type configResolution struct {
+	Name         string      `json:"name"`
+	Env          string      `json:"env,omitempty"`
+	EnvValue     string      `json:"env_value,omitempty"`
+	Setting      string      `json:"setting,omitempty"` // system_settings key
+	SettingValue string      `json:"setting_value,omitempty"`
+	PolicyValue  interface{} `json:"policy_value,omitempty"`
+	Default      interface{} `json:"default"`
+	Effective    interface{} `json:"effective"`
+	Source       string      `json:"source"` // the layer the effective value comes from
+	// Shadowed lists the lower layers that set a different value, which
+	// is ignored.
+	Shadowed []string `json:"shadowed,omitempty"`
+	// Invalid lists the layers whose value doesn't parse and is skipped.
+	Invalid  []string `json:"invalid,omitempty"`
+	Conflict bool     `json:"conflict"`
+
+	value int64 // Effective of a resource default
+}
+
+// resolveResourceDefault resolves a system-wide default from the env and
+// system_settings values ("" if unset) and the policy file.
+func resolveResourceDefault(d resourceDefault, envValue, settingValue string, q policy.Quotas) configResolution {
+	r := configResolution{
+		Name: d.name, Env: d.env, EnvValue: envValue, Setting: d.setting, SettingValue: settingValue,
+		Default: d.fallback, Source: configSourceDefault, value: d.fallback,
+	}
+	type layer struct {
+		source string
+		value  int64
+	}
+	var set []layer
+	apply := func(source string, n int64) {
+		set = append(set, layer{source, n})
+		r.Source, r.value = source, n
+	}
+	if envValue != "" {
+		if n, ok := d.parse(envValue); ok {
+			apply(configSourceEnv, n)
+		} else {
+			r.Invalid = append(r.Invalid, configSourceEnv)
+		}
+	}
+	if settingValue != "" {
+		if n, ok := d.parse(settingValue); ok {
+			apply(configSourceDB, n)
+		} else {
+			r.Invalid = append(r.Invalid, configSourceDB)
+		}
+	}
+	if n, ok := d.policy(q); ok {
+		r.PolicyValue = n
+		apply(configSourcePolicy, n)
+	}
+	for _, l := range set {
+		if l.source != r.Source && l.value != r.value {
+			r.Shadowed = append(r.Shadowed, l.source)
+		}
+	}
+	r.Effective = r.value
+	r.Conflict = len(r.Shadowed) > 0
+	return r
+}
+
+// serverSettingEnv maps the server toggles (settings.Settings JSON names)
+// to the env vars their defaults come from.
+var serverSettingEnv = map[string]string{
+	"password_auth_enabled":       "PASSWORD_AUTH_ENABLED",
+	"opencode_subdomain_prefix":   "OPENCODE_SUBDOMAIN_PREFIX",
+	"openclaw_subdomain_prefix":   "OPENCLAW_SUBDOMAIN_PREFIX",
+	"claudecode_subdomain_prefix": "CLAUDECODE_SUBDOMAIN_PREFIX",
+	"jupyter_subdomain_prefix":    "JUPYTER_SUBDOMAIN_PREFIX",
+	"opencode_asset_domain":       "OPENCODE_ASSET_DOMAIN",
+	"network_policy_enabled":      "NETWORKPOLICY_ENABLED",
+	"network_policy_deny_cidrs":   "NETWORKPOLICY_DENY_CIDRS",
+	"proxy_path_acls":             "",
+}
+
+// resolveServerSettings resolves the server toggles from their startup
+// defaults, the admin overrides, and env (the env var values, by name).
+// Only the overrides stored in system_settings can shadow a default.
+func resolveServerSettings(defaults settings.Settings, overrides settings.Overrides, env func(string) string) []configResolution {
+	var def, over map[string]json.RawMessage
+	b, _ := json.Marshal(defaults)
+	json.Unmarshal(b, &def)
+	b, _ = json.Marshal(overrides)
+	json.Unmarshal(b, &over)
+
+	names := make([]string, 0, len(serverSettingEnv))
+	for name := range serverSettingEnv {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	res := make([]configResolution, 0, len(names))
+	for _, name := range names {
+		r := configResolution{Name: name, Default: def[name], Effective: def[name], Source: configSourceDefault}
+		if r.Env = serverSettingEnv[name]; r.Env != "" {
+			if r.EnvValue = env(r.Env); r.EnvValue != "" {
+				r.Source = configSourceEnv
+			}
+		}
+		if o, ok := over[name]; ok {
+			r.Setting, r.SettingValue = settings.Key, string(o)
+			r.Effective = o
+			if r.Source == configSourceEnv && !jsonEqual(o, def[name]) {
+				r.Shadowed = []string{configSourceEnv}
+			}
+			r.Source = configSourceDB
+		}
+		r.Conflict = len(r.Shadowed) > 0
+		res = append(res, r)
+	}
+	return res
+}
+
+// jsonEqual reports whether two JSON documents encode the same value.
+func jsonEqual(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return string(a) == string(b)
+	}
+	ax, _ := json.Marshal(x)
+	by, _ := json.Marshal(y)
+	return string(ax) == string(by)
+}
+
+// configDrift resolves every layered setting.
+func (s *Server) configDrift() []configResolution {
+	q := s.Policy.Get().Quotas
+	res := make([]configResolution, 0, len(resourceDefaults)+len(serverSettingEnv))
+	for _, d := range resourceDefaults {
+		res = append(res, resolveResourceDefault(d, os.Getenv(d.env), s.systemSetting(d.setting), q))
+	}
+	if s.Settings != nil {
+		res = append(res, resolveServerSettings(s.Settings.Defaults(), s.Settings.Overrides(), os.Getenv)...)
+	}
+	return res
+}
+
+// handleAdminConfigDrift reports how each layered setting is resolved.
+// ?conflicts=true limits the report to settings with a shadowed or invalid
+// value.
+// GET /api/admin/config-drift
+func (s *Server) handleAdminConfigDrift(w http.ResponseWriter, r *http.Request) {
+	onlyConflicts := r.URL.Query().Get("conflicts") == "true"
+	all := s.configDrift()
+	res := make([]configResolution, 0, len(all))
+	conflicts := 0
+	for _, c := range all {
+		if c.Conflict {
+			conflicts++
+		}
+		if onlyConflicts && !c.Conflict && len(c.Invalid) == 0 {
+			continue
+		}
+		res = append(res, c)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings":  res,
+		"conflicts": conflicts,
+	})
+}
+
+// LogConfigDrift logs the settings whose env or system_settings value is
+// shadowed by a higher layer or invalid, with the effective value.
+func (s *Server) LogConfigDrift() {
+	for _, c := range s.configDrift() {
+		for _, l := range c.Shadowed {
+			log.Printf("config drift: %s: %s is ignored, %s wins (effective %s)",
+				c.Name, c.layerValue(l), c.layerValue(c.Source), jsonString(c.Effective))
+		}
+		for _, l := range c.Invalid {
+			log.Printf("config drift: %s: invalid %s is ignored (effective %s from %s)",
+				c.Name, c.layerValue(l), jsonString(c.Effective), c.Source)
+		}
+	}
+}
+
+// layerValue describes what a layer sets, e.g. IDLE_TIMEOUT=1h.
+func (c configResolution) layerValue(source string) string {
+	switch source {
+	case configSourceEnv:
+		return c.Env + "=" + c.EnvValue
+	case configSourceDB:
+		return "system_settings " + c.Setting + "=" + c.SettingValue
+	case configSourcePolicy:
+		return "policy file value " + jsonString(c.PolicyValue)
+	}
+	return "default " + jsonString(c.Default)
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/policy"
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+func TestResolveResourceDefault(t *testing.T) {
+	var idle resourceDefault
+	for _, d := range resourceDefaults {
+		if d.name == "max_idle_timeout" {
+			idle = d
+		}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		env, stored string
+		policy      policy.Value
+		want        int64
+		source      string
+		shadowed    []string
+		invalid     []string
+	}{
+		{"default", "", "", "", 1800, configSourceDefault, nil, nil},
+		{"env", "1h", "", "", 3600, configSourceEnv, nil, nil},
+		{"setting over env", "1h", "7200", "", 7200, configSourceDB, []string{configSourceEnv}, nil},
+		{"setting agreeing with env", "1h", "3600", "", 3600, configSourceDB, nil, nil},
+		{"policy over both", "1h", "7200", "15m", 900, configSourcePolicy, []string{configSourceEnv, configSourceDB}, nil},
+		{"invalid env", "soon", "", "", 1800, configSourceDefault, nil, []string{configSourceEnv}},
+		{"invalid setting", "1h", "x", "", 3600, configSourceEnv, nil, []string{configSourceDB}},
+	} {
+		r := resolveResourceDefault(idle, tc.env, tc.stored, policy.Quotas{MaxIdleTimeout: tc.policy})
+		if r.Effective != tc.want || r.Source != tc.source || !slices.Equal(r.Shadowed, tc.shadowed) || !slices.Equal(r.Invalid, tc.invalid) {
+			t.Errorf("%s: got %v from %s, shadowed %v, invalid %v", tc.name, r.Effective, r.Source, r.Shadowed, r.Invalid)
+		}
+		if r.Conflict != (len(tc.shadowed) > 0) {
+			t.Errorf("%s: conflict = %v", tc.name, r.Conflict)
+		}
+	}
+
+	// The chain agrees with getResourceDefaults field by field.
+	var rd ResourceDefaults
+	for _, d := range resourceDefaults {
+		d.set(&rd, resolveResourceDefault(d, "", "", policy.Quotas{}).value)
+	}
+	if rd.MaxWorkspacesPerUser != defaultMaxWorkspaces || rd.MaxSandboxesPerWorkspace != defaultMaxSandboxes ||
+		rd.MaxSandboxCPU != 2000 || rd.MaxIdleTimeout != 1800 || rd.MaxSandboxMemory != 2<<30 || rd.WsMaxTotalCPU != 0 {
+		t.Errorf("fallbacks = %+v", rd)
+	}
+}
+
+func TestResolveServerSettings(t *testing.T) {
+	off, prefix := false, "code"
+	defaults := settings.Settings{PasswordAuthEnabled: true, OpencodeSubdomainPrefix: "code", JupyterSubdomainPrefix: "nb"}
+	overrides := settings.Overrides{PasswordAuthEnabled: &off, OpencodeSubdomainPrefix: &prefix}
+	env := map[string]string{"PASSWORD_AUTH_ENABLED": "true", "OPENCODE_SUBDOMAIN_PREFIX": "code", "JUPYTER_SUBDOMAIN_PREFIX": "nb"}
+
+	got := map[string]configResolution{}
+	for _, r := range resolveServerSettings(defaults, overrides, func(k string) string { return env[k] }) {
+		got[r.Name] = r
+	}
+	if r := got["password_auth_enabled"]; !r.Conflict || r.Source != configSourceDB || string(r.Effective.(json.RawMessage)) != "false" {
+		t.Errorf("password_auth_enabled = %+v", r)
+	}
+	if r := got["opencode_subdomain_prefix"]; r.Conflict || r.Source != configSourceDB {
+		t.Errorf("override equal to env reported as conflict: %+v", r)
+	}
+	if r := got["jupyter_subdomain_prefix"]; r.Conflict || r.Source != configSourceEnv {
+		t.Errorf("jupyter_subdomain_prefix = %+v", r)
+	}
+	if r := got["openclaw_subdomain_prefix"]; r.Source != configSourceDefault {
+		t.Errorf("openclaw_subdomain_prefix = %+v", r)
+	}
+}
@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Background controllers (the idle watcher, orphan cleanup, the operator
+// and the server's own loops) beat a heartbeat after each run. One without
+// a successful run for controllerStallIntervals of its intervals is
+// stalled: GET /api/admin/controllers and /metrics show it, and the
+// watchdog logs it and emails the admins, so a dead or failing loop is
+// noticed before sandboxes stop pausing. Heartbeats are per replica.
+
+// Controllers started outside the server package.
+const (
+	ControllerIdleWatcher = "idle_watcher"
+	ControllerOrphanClean = "orphan_clean" // runs once at startup
+	ControllerOperator    = "operator"
+)
+
+const (
+	controllerCredentialPrune  = "credential_prune"
+	controllerSandboxSchedules = "sandbox_schedules"
+	controllerPausedReaper     = "paused_sandbox_reaper"
+	controllerSchedulingQueue  = "scheduling_queue"
+)
+
+const controllerStallIntervals = 3
+
+// controllerHealth is the API view of a controller.
+type controllerHealth struct {
+	Name          string     `json:"name"`
+	Interval      string     `json:"interval,omitempty"` // empty for a one-off run
+	Runs          int64      `json:"runs"`
+	Failures      int64      `json:"failures"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	Stalled       bool       `json:"stalled"`
+
+	interval   time.Duration
+	registered time.Time
+	alerted    bool // the watchdog reported the stall
+}
+
+// stalled reports whether the controller has gone without a successful
+// run for controllerStallIntervals intervals.
+func (h *controllerHealth) stalled(now time.Time) bool {
+	if h.interval <= 0 {
+		return false
+	}
+	last := h.registered
+	if h.LastSuccessAt != nil {
+		last = *h.LastSuccessAt
+	}
+	return now.Sub(last) > controllerStallIntervals*h.interval
+}
+
+type controllerRegistry struct {
+	mu          sync.Mutex
+	controllers map[string]*controllerHealth
+}
+
+// register adds a controller, or restarts the stall clock of a known one.
+func (c *controllerRegistry) register(name string, interval time.Duration, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.controllers == nil {
+		c.controllers = make(map[string]*controllerHealth)
+	}
+	h := c.controllers[name]
+	if h == nil {
+		h = &controllerHealth{Name: name}
+		c.controllers[name] = h
+	}
+	h.interval, h.registered = interval, now
+	h.Interval = ""
+	if interval > 0 {
+		h.Interval = interval.String()
+	}
+}
+
+func (c *controllerRegistry) unregister(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.controllers, name)
+}
+
+func (c *controllerRegistry) beat(name string, at time.Time, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.controllers[name]
+	if h == nil {
+		return
+	}
+	h.Runs++
+	h.LastRunAt = &at
+	if err != nil {
+		h.Failures++
+		h.LastError = err.Error()
+		return
+	}
+	h.LastSuccessAt = &at
+	h.LastError = ""
+}
+
+// snapshot returns the controllers as of now, sorted by name.
+func (c *controllerRegistry) snapshot(now time.Time) []controllerHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]controllerHealth, 0, len(c.controllers))
+	for _, h := range c.controllers {
+		v := *h
+		v.Stalled = h.stalled(now)
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// transitions returns the controllers that stalled, and those that
+// recovered, since the last call.
+func (c *controllerRegistry) transitions(now time.Time) (stalled, recovered []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, h := range c.controllers {
+		st := h.stalled(now)
+		switch {
+		case st && !h.alerted:
+			stalled = append(stalled, name)
+		case !st && h.alerted:
+			recovered = append(recovered, name)
+		}
+		h.alerted = st
+	}
+	sort.Strings(stalled)
+	sort.Strings(recovered)
+	return stalled, recovered
+}
+
+// ControllerHeartbeat registers a background controller that runs every
+// interval (0 for one that runs once) and returns the heartbeat to call
+// after each run, with the run's error.
+func (s *Server) ControllerHeartbeat(name string, interval time.Duration) func(error) {
+	s.controllers.register(name, interval, time.Now())
+	return func(err error) {
+		s.controllers.beat(name, time.Now(), err)
+	}
+}
+
+// ForgetController removes a controller that stopped on purpose, such as
+// the operator on a replica that lost leadership.
+func (s *Server) ForgetController(name string) {
+	s.controllers.unregister(name)
+}
+
+// StartControllerWatchdogLoop is the exported entry point for the server's
+// main lifecycle to check for stalled controllers every `every`. Stalls
+// and recoveries are logged and, with SMTP configured, emailed to the
+// admins.
+func (s *Server) StartControllerWatchdogLoop(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.runControllerWatchdogOnce(time.Now())
+		}
+	}
+}
+
+func (s *Server) runControllerWatchdogOnce(now time.Time) {
+	stalled, recovered := s.controllers.transitions(now)
+	for _, name := range stalled {
+		log.Printf("controller watchdog: %s stalled", name)
+	}
+	for _, name := range recovered {
+		log.Printf("controller watchdog: %s recovered", name)
+	}
+	if s.StatementMailer == nil || len(stalled)+len(recovered) == 0 {
+		return
+	}
+	to, err := s.DB.ListAdminEmails()
+	if err != nil {
+		log.Printf("controller watchdog: list admin emails: %v", err)
+		return
+	}
+	if len(to) == 0 {
+		return
+	}
+	subject, body := controllerAlert(stalled, recovered, s.controllers.snapshot(now))
+	if err := s.StatementMailer.Send(to, subject, body, "", nil); err != nil {
+		log.Printf("controller watchdog: email admins: %v", err)
+	}
+}
+
+// controllerAlert renders the email of a watchdog check.
+func controllerAlert(stalled, recovered []string, controllers []controllerHealth) (subject, body string) {
+	if len(stalled) > 0 {
+		subject = "Agentserver: controller stalled: " + strings.Join(stalled, ", ")
+	} else {
+		subject = "Agentserver: controller recovered: " + strings.Join(recovered, ", ")
+	}
+	var b strings.Builder
+	if len(stalled) > 0 {
+		fmt.Fprintf(&b, "Stalled: %s\n", strings.Join(stalled, ", "))
+	}
+	if len(recovered) > 0 {
+		fmt.Fprintf(&b, "Recovered: %s\n", strings.Join(recovered, ", "))
+	}
+	b.WriteString("\n")
+	for _, h := range controllers {
+		last := "never"
+		if h.LastSuccessAt != nil {
+			last = h.LastSuccessAt.UTC().Format(time.RFC3339)
+		}
+		state := "ok"
+		if h.Stalled {
+			state = "STALLED"
+		}
+		fmt.Fprintf(&b, "%-24s %-8s last success %s", h.Name, state, last)
+		if h.LastError != "" {
+			fmt.Fprintf(&b, ", last error: %s", h.LastError)
+		}
+		b.WriteString("\n")
+	}
+	return subject, b.String()
+}
+
+// GET /api/admin/controllers
+func (s *Server) handleAdminControllers(w http.ResponseWriter, r *http.Request) {
+	st := s.scheduleQueue.state()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"controllers": s.controllers.snapshot(time.Now()),
+		"scheduling_queue": map[string]interface{}{
+			"enabled": st.Enabled,
+			"depth":   len(st.Entries),
+			"active":  st.Active,
+		},
+	})
+}
+
+// handleMetrics serves controller health in the Prometheus text format.
+// GET /metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.MetricsToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.MetricsToken)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeControllerMetrics(w, s.controllers.snapshot(time.Now()), s.scheduleQueue.state())
+}
+
+func writeControllerMetrics(w io.Writer, controllers []controllerHealth, queue scheduleQueueState) {
+	unix := func(t *time.Time) string {
+		if t == nil {
+			return "0"
+		}
+		return fmt.Sprint(t.Unix())
+	}
+	metric := func(name, typ, help string, value func(h *controllerHealth) string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for i := range controllers {
+			fmt.Fprintf(w, "%s{controller=%q} %s\n", name, controllers[i].Name, value(&controllers[i]))
+		}
+	}
+	metric("agentserver_controller_last_run_timestamp_seconds", "gauge", "Unix time of the controller's last run; 0 if it hasn't run.",
+		func(h *controllerHealth) string { return unix(h.LastRunAt) })
+	metric("agentserver_controller_last_success_timestamp_seconds", "gauge", "Unix time of the controller's last successful run; 0 if none.",
+		func(h *controllerHealth) string { return unix(h.LastSuccessAt) })
+	metric("agentserver_controller_runs_total", "counter", "Runs of the controller.",
+		func(h *controllerHealth) string { return fmt.Sprint(h.Runs) })
+	metric("agentserver_controller_failures_total", "counter", "Failed runs of the controller.",
+		func(h *controllerHealth) string { return fmt.Sprint(h.Failures) })
+	metric("agentserver_controller_stalled", "gauge", "1 if the controller has gone three intervals without a successful run.",
+		func(h *controllerHealth) string {
+			if h.Stalled {
+				return "1"
+			}
+			return "0"
+		})
+	fmt.Fprintf(w, "# HELP agentserver_scheduling_queue_depth Sandbox starts waiting in the scheduling queue.\n# TYPE agentserver_scheduling_queue_depth gauge\nagentserver_scheduling_queue_depth %d\n", len(queue.Entries))
+	fmt.Fprintf(w, "# HELP agentserver_scheduling_queue_active Sandbox starts in progress.\n# TYPE agentserver_scheduling_queue_active gauge\nagentserver_scheduling_queue_active %d\n", queue.Active)
+}
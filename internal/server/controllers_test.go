@@ -0,0 +1,93 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControllerRegistryStalls(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var c controllerRegistry
+	c.register("idle", time.Minute, start)
+	c.register("once", 0, start)
+
+	stalled := func(at time.Time) map[string]bool {
+		m := map[string]bool{}
+		for _, h := range c.snapshot(at) {
+			m[h.Name] = h.Stalled
+		}
+		return m
+	}
+
+	if got := stalled(start.Add(3 * time.Minute)); got["idle"] || got["once"] {
+		t.Errorf("stalled after 3 intervals: %v", got)
+	}
+	if got := stalled(start.Add(4 * time.Minute)); !got["idle"] || got["once"] {
+		t.Errorf("want only idle stalled without a run: %v", got)
+	}
+
+	c.beat("idle", start.Add(4*time.Minute), nil)
+	if got := stalled(start.Add(5 * time.Minute)); got["idle"] {
+		t.Errorf("stalled right after a run: %v", got)
+	}
+	// Failing runs don't count as progress.
+	for i := 5; i <= 8; i++ {
+		c.beat("idle", start.Add(time.Duration(i)*time.Minute), errors.New("db down"))
+	}
+	list := c.snapshot(start.Add(8 * time.Minute))
+	if h := list[0]; h.Name != "idle" || !h.Stalled || h.Runs != 5 || h.Failures != 4 || h.LastError != "db down" {
+		t.Errorf("idle = %+v, want stalled with 5 runs, 4 failures", h)
+	}
+}
+
+func TestControllerRegistryTransitions(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var c controllerRegistry
+	c.register("idle", time.Minute, start)
+
+	if s, r := c.transitions(start.Add(time.Minute)); len(s)+len(r) != 0 {
+		t.Errorf("transitions = %v, %v; want none", s, r)
+	}
+	if s, _ := c.transitions(start.Add(5 * time.Minute)); len(s) != 1 || s[0] != "idle" {
+		t.Errorf("stalled = %v, want [idle]", s)
+	}
+	// Reported once.
+	if s, _ := c.transitions(start.Add(6 * time.Minute)); len(s) != 0 {
+		t.Errorf("stalled = %v, want it reported once", s)
+	}
+	c.beat("idle", start.Add(7*time.Minute), nil)
+	if _, r := c.transitions(start.Add(7 * time.Minute)); len(r) != 1 || r[0] != "idle" {
+		t.Errorf("recovered = %v, want [idle]", r)
+	}
+
+	c.unregister("idle")
+	if list := c.snapshot(start); len(list) != 0 {
+		t.Errorf("snapshot after unregister = %+v", list)
+	}
+}
+
+func TestWriteControllerMetrics(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	var b strings.Builder
+	writeControllerMetrics(&b, []controllerHealth{
+		{Name: "idle_watcher", Runs: 3, Failures: 1, LastRunAt: &at, LastSuccessAt: &at},
+		{Name: "operator", Stalled: true},
+	}, scheduleQueueState{Active: 2, Entries: make([]scheduleQueueEntry, 4)})
+	out := b.String()
+	for _, want := range []string{
+		`agentserver_controller_last_run_timestamp_seconds{controller="idle_watcher"} 1700000000`,
+		`agentserver_controller_last_success_timestamp_seconds{controller="operator"} 0`,
+		`agentserver_controller_runs_total{controller="idle_watcher"} 3`,
+		`agentserver_controller_failures_total{controller="idle_watcher"} 1`,
+		`agentserver_controller_stalled{controller="operator"} 1`,
+		`agentserver_controller_stalled{controller="idle_watcher"} 0`,
+		"agentserver_scheduling_queue_depth 4",
+		"agentserver_scheduling_queue_active 2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+const createEventsPollInterval = 2 * time.Second
+
+type createEventsSource interface {
+	CreateEvents(id string) ([]process.CreateEvent, error)
+}
+
+// handleSandboxCreateEvents streams provisioning progress over SSE while a
+// sandbox is starting. Events:
+//
+//	event: status  data: {"status": "..."}   on every status change
+//	event: k8s     data: process.CreateEvent  scheduler/kubelet/init events
+//	event: done    data: {"status": "..."}   once the sandbox left the
+//	                                          starting states; stream ends
+//
+// Backends without event support (Docker) only emit status events.
+func (s *Server) handleSandboxCreateEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	src, _ := s.ProcessManager.(createEventsSource)
+	seen := make(map[string]bool)
+	lastStatus := ""
+	ticker := time.NewTicker(createEventsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		sbx, ok := s.Sandboxes.Get(id)
+		if !ok {
+			send("done", map[string]string{"status": sbxstore.StatusDeleting})
+			return
+		}
+		if sbx.Status != lastStatus {
+			lastStatus = sbx.Status
+			send("status", map[string]string{"status": sbx.Status, "message": sbx.StatusMessage})
+		}
+
+		if src != nil && !sbx.IsLocal && sbx.Status == sbxstore.StatusCreating {
+			events, err := src.CreateEvents(id)
+			if err != nil {
+				log.Printf("create-events: sandbox %s: %v", id, err)
+			}
+			for _, ev := range events {
+				key := ev.Object + "|" + ev.Reason + "|" + ev.Message + "|" + strconv.Itoa(int(ev.Count))
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				send("k8s", ev)
+			}
+		}
+
+		if !isStartingStatus(sbx.Status) {
+			send("done", map[string]string{"status": sbx.Status})
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func isStartingStatus(status string) bool {
+	return status == sbxstore.StatusCreating || status == sbxstore.StatusProvisioningStorage
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// credentialPruneGrace is how long expired credentials are kept before the
+// prune job deletes them, so recently expired ones still show in listings.
+const credentialPruneGrace = 24 * time.Hour
+
+// credentialPruneStats are the metrics of the credential prune job, served
+// at /api/admin/credential-prune.
+type credentialPruneStats struct {
+	mu           sync.Mutex
+	Runs         int64            `json:"runs"`
+	Failures     int64            `json:"failures"`
+	LastRunAt    *time.Time       `json:"last_run_at,omitempty"`
+	LastDuration string           `json:"last_duration,omitempty"`
+	LastError    string           `json:"last_error,omitempty"`
+	LastDeleted  map[string]int64 `json:"last_deleted"`
+	TotalDeleted map[string]int64 `json:"total_deleted"`
+}
+
+func (st *credentialPruneStats) record(start time.Time, deleted map[string]int64, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.Runs++
+	st.LastRunAt = &start
+	st.LastDuration = time.Since(start).Round(time.Millisecond).String()
+	st.LastError = ""
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+	}
+	st.LastDeleted = deleted
+	if st.TotalDeleted == nil {
+		st.TotalDeleted = make(map[string]int64)
+	}
+	for table, n := range deleted {
+		st.TotalDeleted[table] += n
+	}
+}
+
+// runCredentialPruneOnce deletes credentials that expired more than
+// credentialPruneGrace ago and records the run in the metrics.
+func (s *Server) runCredentialPruneOnce(ctx context.Context) (map[string]int64, error) {
+	start := time.Now()
+	deleted, err := s.DB.PruneExpiredCredentials(ctx, start.Add(-credentialPruneGrace))
+	s.credentialPrune.record(start, deleted, err)
+	return deleted, err
+}
+
+// StartCredentialPruneLoop is the exported entry point for the server's main
+// lifecycle to launch the credential prune loop in a goroutine.
+func (s *Server) StartCredentialPruneLoop(ctx context.Context, every time.Duration) {
+	s.startCredentialPruneLoop(ctx, every)
+}
+
+// startCredentialPruneLoop prunes expired credentials every `every` until
+// ctx is cancelled. Errors are logged; the next tick retries.
+func (s *Server) startCredentialPruneLoop(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Hour
+	}
+	beat := s.ControllerHeartbeat(controllerCredentialPrune, every)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			deleted, err := s.runCredentialPruneOnce(ctx)
+			beat(err)
+			if err != nil {
+				log.Printf("credential prune: %v", err)
+				continue
+			}
+			var total int64
+			for _, n := range deleted {
+				total += n
+			}
+			if total > 0 {
+				log.Printf("credential prune: deleted %d rows %v", total, deleted)
+			}
+		}
+	}
+}
+
+// GET /api/admin/credential-prune
+func (s *Server) handleAdminCredentialPruneStats(w http.ResponseWriter, r *http.Request) {
+	s.credentialPrune.mu.Lock()
+	defer s.credentialPrune.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&s.credentialPrune)
+}
+
+// POST /api/admin/credential-prune/run
+func (s *Server) handleAdminRunCredentialPrune(w http.ResponseWriter, r *http.Request) {
+	deleted, err := s.runCredentialPruneOnce(r.Context())
+	if err != nil {
+		log.Printf("credential prune: %v", err)
+		http.Error(w, "prune failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+}
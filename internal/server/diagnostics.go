@@ -0,0 +1,96 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+const diagnosticsTimeout = 60 * time.Second
+
+type diagnosticsCollector interface {
+	Diagnostics(ctx context.Context, id, sandboxType string) ([]process.DiagnosticFile, error)
+}
+
+// handleSandboxDiagnostics collects a diagnostics bundle for a sandbox and
+// returns it as a .tar.gz download: the sandbox record, tunnel state, and
+// whatever the backend can gather (pod/container state, events, recent and
+// previous-crash logs, resource usage, opencode's log). Env values and
+// tokens are left out so the bundle can be attached to a bug report.
+func (s *Server) handleSandboxDiagnostics(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+
+	files := []process.DiagnosticFile{{Name: "sandbox.json", Data: mustIndent(sbx)}}
+
+	tunnelState := map[string]interface{}{
+		"is_local":          sbx.IsLocal,
+		"last_heartbeat_at": sbx.LastHeartbeatAt,
+	}
+	if s.TunnelRegistry != nil {
+		_, connected := s.TunnelRegistry.Get(id)
+		tunnelState["connected_to_this_replica"] = connected
+	}
+	files = append(files, process.DiagnosticFile{Name: "tunnel.json", Data: mustIndent(tunnelState)})
+
+	if !sbx.IsLocal {
+		if c, ok := s.ProcessManager.(diagnosticsCollector); ok {
+			ctx, cancel := context.WithTimeout(r.Context(), diagnosticsTimeout)
+			backend, err := c.Diagnostics(ctx, id, sbx.Type)
+			cancel()
+			if err != nil {
+				log.Printf("diagnostics: backend collection for %s failed: %v", id, err)
+				backend = append(backend, process.DiagnosticFile{Name: "error", Data: []byte(err.Error())})
+			}
+			for _, f := range backend {
+				files = append(files, process.DiagnosticFile{Name: "backend/" + f.Name, Data: f.Data})
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("sandbox-%s-diagnostics-%s", id, now.Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.tar.gz"`)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    name + "/" + f.Name,
+			Mode:    0o644,
+			Size:    int64(len(f.Data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			log.Printf("diagnostics: write bundle for %s: %v", id, err)
+			return
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			log.Printf("diagnostics: write bundle for %s: %v", id, err)
+			return
+		}
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func mustIndent(v interface{}) []byte {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return data
+}
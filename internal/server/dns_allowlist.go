@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/namespace"
+)
+
+// workspaceNamespaceForDNS resolves the K8s namespace of a workspace for DNS
+// filter changes. Writes an error response and returns ok=false when the
+// workspace does not exist or the backend has no namespace support.
+func (s *Server) workspaceNamespaceForDNS(w http.ResponseWriter, workspaceID string) (string, bool) {
+	if s.NamespaceManager == nil {
+		http.Error(w, "DNS allowlisting requires the Kubernetes backend", http.StatusNotImplemented)
+		return "", false
+	}
+	ws, err := s.DB.GetWorkspace(workspaceID)
+	if err != nil {
+		log.Printf("admin: failed to get workspace %s: %v", workspaceID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return "", false
+	}
+	if ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return "", false
+	}
+	if !ws.K8sNamespace.Valid || ws.K8sNamespace.String == "" {
+		http.Error(w, "workspace has no namespace", http.StatusConflict)
+		return "", false
+	}
+	return ws.K8sNamespace.String, true
+}
+
+func (s *Server) handleAdminGetWorkspaceDNSAllowlist(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "id")
+
+	al, err := s.DB.GetWorkspaceDNSAllowlist(workspaceID)
+	if err != nil {
+		log.Printf("admin: failed to get dns allowlist: %v", err)
+		http.Error(w, "failed to get dns allowlist", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"enabled": al != nil,
+		"domains": []string{},
+	}
+	if al != nil {
+		resp["domains"] = al.Domains
+		resp["updated_at"] = al.UpdatedAt.Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleAdminSetWorkspaceDNSAllowlist(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "id")
+
+	var req struct {
+		Domains []string `json:"domains"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	domains, err := namespace.NormalizeDomains(req.Domains)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(domains) == 0 {
+		http.Error(w, "domains must not be empty; use DELETE to disable DNS filtering", http.StatusBadRequest)
+		return
+	}
+
+	ns, ok := s.workspaceNamespaceForDNS(w, workspaceID)
+	if !ok {
+		return
+	}
+	if err := s.DB.SetWorkspaceDNSAllowlist(workspaceID, domains); err != nil {
+		log.Printf("admin: failed to set dns allowlist: %v", err)
+		http.Error(w, "failed to set dns allowlist", http.StatusInternalServerError)
+		return
+	}
+	if err := s.NamespaceManager.ApplyDNSFilter(r.Context(), ns, domains); err != nil {
+		log.Printf("admin: failed to apply dns filter to %s: %v", ns, err)
+		http.Error(w, "failed to apply dns filter", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": true,
+		"domains": domains,
+	})
+}
+
+func (s *Server) handleAdminDeleteWorkspaceDNSAllowlist(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "id")
+
+	ns, ok := s.workspaceNamespaceForDNS(w, workspaceID)
+	if !ok {
+		return
+	}
+	if err := s.NamespaceManager.RemoveDNSFilter(r.Context(), ns); err != nil {
+		log.Printf("admin: failed to remove dns filter from %s: %v", ns, err)
+		http.Error(w, "failed to remove dns filter", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.DeleteWorkspaceDNSAllowlist(workspaceID); err != nil {
+		log.Printf("admin: failed to delete dns allowlist: %v", err)
+		http.Error(w, "failed to delete dns allowlist", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
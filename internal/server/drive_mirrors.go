@@ -0,0 +1,505 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Drive mirrors keep a directory of the workspace drive in sync with a
+// branch of a remote git repository, so agent work is continuously backed
+// up to the team's source of truth. Every interval, changes on the drive
+// are committed with the mirror bot identity, the remote branch is fetched
+// and merged, and the result is pushed. Like drive sync, this runs through
+// a running sandbox of the workspace; without one the sync is skipped. A
+// merge conflict aborts the merge and is reported; the mirror keeps
+// failing until someone resolves it on the drive or the remote.
+
+const (
+	// maxDriveMirrors bounds the mirrors of a workspace.
+	maxDriveMirrors = 20
+	// defaultMirrorInterval, minMirrorInterval and maxMirrorInterval bound
+	// how often a mirror syncs.
+	defaultMirrorInterval = 15 * time.Minute
+	minMirrorInterval     = time.Minute
+	maxMirrorInterval     = 24 * time.Hour
+	// mirrorSyncTimeout bounds one sync.
+	mirrorSyncTimeout = 5 * time.Minute
+	// maxMirrorMessage bounds the error output kept of a sync.
+	maxMirrorMessage = 2000
+)
+
+// mirrorBranchRe matches the branch names mirrors accept: a conservative
+// subset of git ref names.
+var mirrorBranchRe = regexp.MustCompile(`^[A-Za-z0-9._][A-Za-z0-9._/-]{0,199}$`)
+
+// driveMirrorRequest is the body of creating or replacing a mirror.
+type driveMirrorRequest struct {
+	Path            string `json:"path"`
+	RemoteURL       string `json:"remote_url"`
+	Branch          string `json:"branch"`
+	TokenSecret     string `json:"token_secret"`
+	IntervalSeconds int    `json:"interval_seconds"`
+	Enabled         *bool  `json:"enabled"`
+}
+
+// validateMirrorRemote checks a mirror's remote URL. Only HTTPS remotes
+// are supported, and credentials belong in a workspace secret rather than
+// the URL, where they would end up in the drive's .git/config.
+func validateMirrorRemote(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("remote_url must be an https:// URL")
+	}
+	if u.User != nil {
+		return errors.New("remote_url must not contain credentials; use token_secret")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("remote_url must not have a query or fragment")
+	}
+	return nil
+}
+
+// validMirrorBranch reports whether branch is a branch name mirrors accept.
+func validMirrorBranch(branch string) bool {
+	return mirrorBranchRe.MatchString(branch) && !strings.Contains(branch, "..") &&
+		!strings.Contains(branch, "//") && !strings.HasSuffix(branch, "/") &&
+		!strings.HasSuffix(branch, ".lock") && !strings.HasSuffix(branch, ".")
+}
+
+// mirrorPathsOverlap reports whether one of two drive paths contains the
+// other; a repository inside another mirror's would be committed by both.
+func mirrorPathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// decodeDriveMirror reads and validates a mirror request into m. The path
+// is only taken when creating. It writes the error response and returns
+// false on failure.
+func (s *Server) decodeDriveMirror(w http.ResponseWriter, r *http.Request, m *db.DriveMirror, create bool) bool {
+	var req driveMirrorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	if create {
+		m.Path = cleanDrivePath(req.Path)
+		if m.Path == "." {
+			http.Error(w, "path must be a directory of the drive, not its root", http.StatusBadRequest)
+			return false
+		}
+	}
+	if err := validateMirrorRemote(req.RemoteURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if req.Branch == "" {
+		req.Branch = "main"
+	}
+	if !validMirrorBranch(req.Branch) {
+		http.Error(w, "invalid branch name", http.StatusBadRequest)
+		return false
+	}
+	interval := defaultMirrorInterval
+	if req.IntervalSeconds != 0 {
+		interval = time.Duration(req.IntervalSeconds) * time.Second
+	}
+	if interval < minMirrorInterval || interval > maxMirrorInterval {
+		http.Error(w, fmt.Sprintf("interval_seconds must be between %d and %d",
+			int(minMirrorInterval.Seconds()), int(maxMirrorInterval.Seconds())), http.StatusBadRequest)
+		return false
+	}
+	if req.TokenSecret != "" {
+		if !s.requireSecretsKey(w) {
+			return false
+		}
+		secrets, err := s.DB.ListWorkspaceSecrets(m.WorkspaceID)
+		if err != nil {
+			log.Printf("failed to list secrets of workspace %s: %v", m.WorkspaceID, err)
+			http.Error(w, "failed to check token secret", http.StatusInternalServerError)
+			return false
+		}
+		found := false
+		for _, sec := range secrets {
+			found = found || sec.Name == req.TokenSecret
+		}
+		if !found {
+			http.Error(w, "token_secret is not a secret of this workspace", http.StatusBadRequest)
+			return false
+		}
+	}
+	m.RemoteURL, m.Branch, m.TokenSecret = req.RemoteURL, req.Branch, req.TokenSecret
+	m.IntervalSeconds = int(interval.Seconds())
+	m.Enabled = req.Enabled == nil || *req.Enabled
+	return true
+}
+
+// GET /api/workspaces/{id}/mirrors lists the workspace's mirrors with the
+// result of their last sync.
+func (s *Server) handleListDriveMirrors(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	mirrors, err := s.DB.ListDriveMirrors(wsID)
+	if err != nil {
+		log.Printf("failed to list mirrors of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to list mirrors", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mirrors)
+}
+
+// POST /api/workspaces/{id}/mirrors creates a mirror (owner/maintainer).
+// It first syncs at the next run of the mirror loop.
+func (s *Server) handleCreateDriveMirror(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	m := &db.DriveMirror{
+		ID:          uuid.New().String(),
+		WorkspaceID: wsID,
+		CreatedBy:   auth.UserIDFromContext(r.Context()),
+	}
+	if !s.decodeDriveMirror(w, r, m, true) {
+		return
+	}
+	existing, err := s.DB.ListDriveMirrors(wsID)
+	if err != nil {
+		log.Printf("failed to list mirrors of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to create mirror", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxDriveMirrors {
+		http.Error(w, fmt.Sprintf("a workspace can have at most %d mirrors", maxDriveMirrors), http.StatusConflict)
+		return
+	}
+	for _, e := range existing {
+		if mirrorPathsOverlap(e.Path, m.Path) {
+			http.Error(w, fmt.Sprintf("path overlaps the mirror of %s", e.Path), http.StatusConflict)
+			return
+		}
+	}
+	created, err := s.DB.CreateDriveMirror(m)
+	if err != nil {
+		log.Printf("failed to create mirror in workspace %s: %v", wsID, err)
+		http.Error(w, "failed to create mirror", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		http.Error(w, "path is already mirrored", http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(m)
+}
+
+// driveMirror resolves the mirror {mirrorID} of workspace {id}. It writes
+// the error response and returns nil on failure.
+func (s *Server) driveMirror(w http.ResponseWriter, r *http.Request) *db.DriveMirror {
+	wsID := chi.URLParam(r, "id")
+	m, err := s.DB.GetDriveMirror(wsID, chi.URLParam(r, "mirrorID"))
+	if err != nil {
+		log.Printf("failed to get mirror of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to get mirror", http.StatusInternalServerError)
+		return nil
+	}
+	if m == nil {
+		http.Error(w, "mirror not found", http.StatusNotFound)
+		return nil
+	}
+	return m
+}
+
+// GET /api/workspaces/{id}/mirrors/{mirrorID} returns a mirror.
+func (s *Server) handleGetDriveMirror(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireWorkspaceMember(w, r, chi.URLParam(r, "id")); !ok {
+		return
+	}
+	m := s.driveMirror(w, r)
+	if m == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// PUT /api/workspaces/{id}/mirrors/{mirrorID} replaces a mirror's remote,
+// branch, token secret, interval and enabled flag (owner/maintainer). Its
+// path can't change.
+func (s *Server) handleUpdateDriveMirror(w http.ResponseWriter, r *http.Request) {
+	if !s.requireWorkspaceRole(w, r, chi.URLParam(r, "id"), "owner", "maintainer") {
+		return
+	}
+	m := s.driveMirror(w, r)
+	if m == nil {
+		return
+	}
+	if !s.decodeDriveMirror(w, r, m, false) {
+		return
+	}
+	if err := s.DB.UpdateDriveMirror(m); err != nil {
+		log.Printf("failed to update mirror %s: %v", m.ID, err)
+		http.Error(w, "failed to update mirror", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// DELETE /api/workspaces/{id}/mirrors/{mirrorID} deletes a mirror
+// (owner/maintainer). The directory and its git history stay on the drive.
+func (s *Server) handleDeleteDriveMirror(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	ok, err := s.DB.DeleteDriveMirror(wsID, chi.URLParam(r, "mirrorID"))
+	if err != nil {
+		log.Printf("failed to delete mirror of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to delete mirror", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "mirror not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/workspaces/{id}/mirrors/{mirrorID}/sync syncs a mirror now
+// (owner/maintainer), even if disabled, and returns it with the result.
+func (s *Server) handleSyncDriveMirror(w http.ResponseWriter, r *http.Request) {
+	if !s.requireWorkspaceRole(w, r, chi.URLParam(r, "id"), "owner", "maintainer") {
+		return
+	}
+	m := s.driveMirror(w, r)
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	claimed, err := s.DB.ClaimDriveMirrorSync(m.ID, m.LastSyncAt, now)
+	if err != nil {
+		log.Printf("failed to claim sync of mirror %s: %v", m.ID, err)
+		http.Error(w, "failed to sync mirror", http.StatusInternalServerError)
+		return
+	}
+	if !claimed || !s.runDriveMirrorSync(r.Context(), m) {
+		http.Error(w, "a sync of this mirror is in progress", http.StatusConflict)
+		return
+	}
+	m.LastSyncAt = &now
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// driveMirrorDue reports whether a mirror's next sync is due at now.
+func driveMirrorDue(m *db.DriveMirror, now time.Time) bool {
+	if m.LastSyncAt == nil {
+		return true
+	}
+	return !now.Before(m.LastSyncAt.Add(time.Duration(m.IntervalSeconds) * time.Second))
+}
+
+// StartDriveMirrorLoop syncs due drive mirrors every interval until ctx is
+// done.
+func (s *Server) StartDriveMirrorLoop(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.runDriveMirrorsOnce(ctx, time.Now())
+		}
+	}
+}
+
+// runDriveMirrorsOnce starts the syncs due at now. Each sync is claimed in
+// the database first, so it runs once across replicas.
+func (s *Server) runDriveMirrorsOnce(ctx context.Context, now time.Time) {
+	mirrors, err := s.DB.ListEnabledDriveMirrors()
+	if err != nil {
+		log.Printf("drive mirrors: list: %v", err)
+		return
+	}
+	for _, m := range mirrors {
+		if !driveMirrorDue(m, now) {
+			continue
+		}
+		if _, busy := s.mirrorSyncs.Load(m.ID); busy {
+			continue
+		}
+		claimed, err := s.DB.ClaimDriveMirrorSync(m.ID, m.LastSyncAt, now)
+		if err != nil {
+			log.Printf("drive mirrors: %v", err)
+			continue
+		}
+		if claimed {
+			go s.runDriveMirrorSync(ctx, m)
+		}
+	}
+}
+
+// runDriveMirrorSync syncs a mirror and records the result in m and the
+// database. It returns false if a sync of the mirror is already running
+// on this server.
+func (s *Server) runDriveMirrorSync(ctx context.Context, m *db.DriveMirror) bool {
+	if _, busy := s.mirrorSyncs.LoadOrStore(m.ID, struct{}{}); busy {
+		return false
+	}
+	defer s.mirrorSyncs.Delete(m.ID)
+
+	ctx, cancel := context.WithTimeout(ctx, mirrorSyncTimeout)
+	defer cancel()
+	res := s.syncDriveMirror(ctx, m)
+	if res.Status != db.MirrorStatusOK && res.Status != db.MirrorStatusSkipped {
+		log.Printf("drive mirrors: sync of mirror %s (%s in workspace %s): %s: %s",
+			m.ID, m.Path, m.WorkspaceID, res.Status, res.Message)
+	}
+	if err := s.DB.RecordDriveMirrorResult(m.ID, res.Status, res.Message, res.Commit); err != nil {
+		log.Printf("drive mirrors: %v", err)
+	}
+	m.LastStatus, m.LastMessage = res.Status, res.Message
+	if res.Commit != "" {
+		m.LastCommit = res.Commit
+	}
+	return true
+}
+
+// mirrorSyncResult is the outcome of a sync.
+type mirrorSyncResult struct {
+	Status  string
+	Message string
+	Commit  string
+}
+
+// mirrorSyncScript syncs the drive directory $1 with branch $3 of remote
+// $2, committing as $4 <$5>. The access token, if any, is read from stdin
+// and passed as an HTTP header through the environment, so it is neither
+// stored in the repository nor visible in the process list. It prints
+// "status=", then "commit=" on success, then "message=" followed by the
+// rest of the output.
+const mirrorSyncScript = `read -r token || true
+fail() { printf 'status=%s\nmessage=%s\n' "$1" "$2"; exit 0; }
+command -v git >/dev/null 2>&1 || fail error "git is not installed in the sandbox"
+cd ` + driveRoot + ` || fail error "drive not mounted"
+case "$(realpath -m -- "$1")" in ` + driveRoot + `/*) ;; *) fail error "path leaves the drive";; esac
+mkdir -p -- "$1" && cd -- "$1" || fail error "cannot create $1"
+export GIT_TERMINAL_PROMPT=0 GIT_AUTHOR_NAME="$4" GIT_AUTHOR_EMAIL="$5" GIT_COMMITTER_NAME="$4" GIT_COMMITTER_EMAIL="$5"
+if [ -n "$token" ]; then
+	export GIT_CONFIG_COUNT=1 GIT_CONFIG_KEY_0=http.extraHeader
+	export GIT_CONFIG_VALUE_0="Authorization: Basic $(printf 'x-access-token:%s' "$token" | base64 | tr -d '\n')"
+fi
+if [ ! -d .git ]; then
+	git init -q && git symbolic-ref HEAD "refs/heads/$3" || fail error "git init failed"
+fi
+if git remote get-url origin >/dev/null 2>&1; then git remote set-url origin "$2"; else git remote add origin "$2"; fi
+out=$(git add -A 2>&1) || fail error "$out"
+if ! git diff --cached --quiet 2>/dev/null; then
+	out=$(git commit -q -m "Sync workspace drive" 2>&1) || fail error "$out"
+fi
+out=$(git ls-remote --exit-code --heads origin "$3" 2>&1)
+case $? in
+0)
+	out=$(git fetch -q origin "$3" 2>&1) || fail error "$out"
+	if ! out=$(git merge -q --no-edit --allow-unrelated-histories FETCH_HEAD 2>&1); then
+		git merge --abort >/dev/null 2>&1
+		fail conflict "$out"
+	fi;;
+2) ;;
+*) fail error "$out";;
+esac
+git rev-parse -q --verify HEAD >/dev/null || fail ok ""
+out=$(git push -q origin "HEAD:refs/heads/$3" 2>&1) || fail error "$out"
+printf 'status=ok\ncommit=%s\nmessage=\n' "$(git rev-parse HEAD)"
+`
+
+// parseMirrorSyncOutput parses the output of mirrorSyncScript. Output
+// without a status, e.g. of a failed shell, is an error with the output as
+// message.
+func parseMirrorSyncOutput(out string) mirrorSyncResult {
+	var res mirrorSyncResult
+	rest := out
+	for rest != "" {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		if msg, ok := strings.CutPrefix(line, "message="); ok {
+			res.Message = strings.TrimSpace(msg + "\n" + rest)
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "status="); ok {
+			res.Status = v
+		} else if v, ok := strings.CutPrefix(line, "commit="); ok {
+			res.Commit = v
+		}
+	}
+	switch res.Status {
+	case db.MirrorStatusOK, db.MirrorStatusConflict, db.MirrorStatusError:
+	default:
+		res = mirrorSyncResult{Status: db.MirrorStatusError, Message: strings.TrimSpace(out)}
+	}
+	if len(res.Message) > maxMirrorMessage {
+		res.Message = res.Message[:maxMirrorMessage]
+	}
+	return res
+}
+
+// syncDriveMirror runs one sync of a mirror through a running sandbox of
+// its workspace.
+func (s *Server) syncDriveMirror(ctx context.Context, m *db.DriveMirror) mirrorSyncResult {
+	execer, ok := s.ProcessManager.(driveExecer)
+	if !ok {
+		return mirrorSyncResult{Status: db.MirrorStatusError, Message: "drive mirrors are not supported by this backend"}
+	}
+	host := s.driveHost(m.WorkspaceID)
+	if host == nil {
+		return mirrorSyncResult{Status: db.MirrorStatusSkipped, Message: "no running sandbox in the workspace mounts the drive"}
+	}
+	var token string
+	if m.TokenSecret != "" {
+		env, files, err := s.workspaceSecretValues(m.WorkspaceID)
+		if err != nil {
+			log.Printf("drive mirrors: secrets of workspace %s: %v", m.WorkspaceID, err)
+			return mirrorSyncResult{Status: db.MirrorStatusError, Message: "failed to read token secret"}
+		}
+		v, ok := env[m.TokenSecret]
+		if !ok {
+			v, ok = files[m.TokenSecret]
+		}
+		if !ok {
+			return mirrorSyncResult{Status: db.MirrorStatusError, Message: fmt.Sprintf("token secret %s not found", m.TokenSecret)}
+		}
+		token = strings.TrimSpace(v)
+	}
+	name, email := s.MirrorAuthorName, s.MirrorAuthorEmail
+	if name == "" {
+		name = "agentserver"
+	}
+	if email == "" {
+		email = "agentserver@localhost"
+	}
+	out, err := execer.ExecInput(ctx, host.ID,
+		[]string{"sh", "-c", mirrorSyncScript, "sh", m.Path, m.RemoteURL, m.Branch, name, email},
+		strings.NewReader(token+"\n"))
+	if err != nil {
+		return mirrorSyncResult{Status: db.MirrorStatusError, Message: fmt.Sprintf("sync via sandbox %s failed: %v", host.ID, err)}
+	}
+	return parseMirrorSyncOutput(out)
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// Workspace drive sync for local agents. A local agent mirrors part of the
+// workspace drive to the user's machine (see agentsdk.SyncDrive). The drive
+// is only mounted in cloud sandboxes, so these endpoints read and write it
+// through a running sandbox of the workspace. Writes and deletes are
+// conditional on the file's last synced hash; a mismatch is a 409 and the
+// agent resolves the conflict.
+
+// driveRoot is where the workspace drive is mounted in cloud sandboxes.
+const driveRoot = "/home/agent/projects"
+
+const (
+	// maxDriveSyncFiles bounds a manifest; sync a narrower path beyond it.
+	maxDriveSyncFiles = 20000
+	// maxDriveSyncFileSize bounds uploads.
+	maxDriveSyncFileSize = 32 << 20
+)
+
+type driveExecer interface {
+	ExecInput(ctx context.Context, sandboxID string, command []string, stdin io.Reader) (string, error)
+}
+
+type driveFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// cleanDrivePath normalizes a drive-relative path. The result never leaves
+// the drive; the drive root itself is ".".
+func cleanDrivePath(p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// driveHost returns a running cloud sandbox of the workspace that mounts
+// the workspace drive.
+func (s *Server) driveHost(workspaceID string) *sbxstore.Sandbox {
+	for _, sbx := range s.Sandboxes.ListByWorkspace(workspaceID) {
+		if sbx.Status == sbxstore.StatusRunning && !sbx.IsLocal && sbx.Type != "jupyter" && sbx.QuarantinedAt == nil {
+			return sbx
+		}
+	}
+	return nil
+}
+
+// driveSyncTarget authenticates the agent and resolves the exec backend and
+// host sandbox. It writes the error response and returns nil on failure.
+func (s *Server) driveSyncTarget(w http.ResponseWriter, r *http.Request) (driveExecer, *sbxstore.Sandbox) {
+	agent := s.extractProxyTokenSandbox(w, r)
+	if agent == nil {
+		return nil, nil
+	}
+	execer, ok := s.ProcessManager.(driveExecer)
+	if !ok {
+		http.Error(w, "drive sync is not supported by this backend", http.StatusNotImplemented)
+		return nil, nil
+	}
+	host := s.driveHost(agent.WorkspaceID)
+	if host == nil {
+		http.Error(w, "no running sandbox in the workspace mounts the drive; start one to sync", http.StatusConflict)
+		return nil, nil
+	}
+	return execer, host
+}
+
+// driveManifestScript prints "<sha256>  <path>" for every regular file
+// under $1, relative to the drive root. Symlinks are skipped.
+const driveManifestScript = `cd ` + driveRoot + ` && [ -d "$1" ] || exit 0; find "$1" -type f -exec sha256sum {} +`
+
+// handleDriveManifest lists the files under ?path= with their hashes.
+// GET /api/agent/drive/manifest
+func (s *Server) handleDriveManifest(w http.ResponseWriter, r *http.Request) {
+	execer, host := s.driveSyncTarget(w, r)
+	if host == nil {
+		return
+	}
+	dir := cleanDrivePath(r.URL.Query().Get("path"))
+	out, err := execer.ExecInput(r.Context(), host.ID, []string{"sh", "-c", driveManifestScript, "sh", dir}, nil)
+	if err != nil {
+		log.Printf("drive sync: manifest of %s via sandbox %s: %v", dir, host.ID, err)
+		http.Error(w, "failed to list drive files", http.StatusBadGateway)
+		return
+	}
+	files := parseDriveManifest(out)
+	if len(files) > maxDriveSyncFiles {
+		http.Error(w, "too many files; sync a narrower path", http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+}
+
+// parseDriveManifest parses sha256sum output. Lines that are not a hash
+// and a path (e.g. file names containing newlines) are dropped.
+func parseDriveManifest(out string) []driveFile {
+	files := []driveFile{}
+	for _, line := range strings.Split(out, "\n") {
+		hash, name, ok := strings.Cut(line, "  ")
+		if !ok || len(hash) != sha256.Size*2 || name == "" {
+			continue
+		}
+		files = append(files, driveFile{Path: cleanDrivePath(name), SHA256: hash})
+	}
+	return files
+}
+
+// driveReadScript prints the file $1, refusing anything that resolves
+// outside the drive.
+const driveReadScript = `cd ` + driveRoot + ` && case "$(realpath -- "$1")" in ` + driveRoot + `/*) ;; *) exit 2;; esac; [ -f "$1" ] || exit 3; cat -- "$1"`
+
+// handleDriveGetFile returns the content of ?path=, with its hash in
+// X-Content-SHA256.
+// GET /api/agent/drive/file
+func (s *Server) handleDriveGetFile(w http.ResponseWriter, r *http.Request) {
+	execer, host := s.driveSyncTarget(w, r)
+	if host == nil {
+		return
+	}
+	p := cleanDrivePath(r.URL.Query().Get("path"))
+	if p == "." {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	out, err := execer.ExecInput(r.Context(), host.ID, []string{"sh", "-c", driveReadScript, "sh", p}, nil)
+	if err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	sum := sha256.Sum256([]byte(out))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Content-SHA256", hex.EncodeToString(sum[:]))
+	io.WriteString(w, out)
+}
+
+// driveWriteScript replaces $1 with stdin if its current hash is $2 ("" for
+// a new file), and $3 = "delete" removes it instead. It prints "ok", or
+// "conflict <current hash>" without touching the file.
+const driveWriteScript = `cd ` + driveRoot + ` || exit 1
+d=$(dirname -- "$1")
+case "$(realpath -m -- "$d")" in ` + driveRoot + `|` + driveRoot + `/*) ;; *) exit 2;; esac
+[ -L "$1" ] && exit 2
+mkdir -p -- "$d" || exit 1
+cur=""; [ -f "$1" ] && cur=$(sha256sum -- "$1" | cut -d' ' -f1)
+if [ "$cur" != "$2" ]; then echo "conflict $cur"; exit 0; fi
+if [ "$3" = delete ]; then rm -f -- "$1"; else cat > "$1.agentserver-sync" && mv -f -- "$1.agentserver-sync" "$1"; fi && echo ok`
+
+// handleDrivePutFile writes ?path= from the request body. If-Match carries
+// the hash the agent last synced; If-None-Match: * creates a new file.
+// PUT /api/agent/drive/file
+func (s *Server) handleDrivePutFile(w http.ResponseWriter, r *http.Request) {
+	s.driveWrite(w, r, false)
+}
+
+// handleDriveDeleteFile deletes ?path= if it still has the If-Match hash.
+// DELETE /api/agent/drive/file
+func (s *Server) handleDriveDeleteFile(w http.ResponseWriter, r *http.Request) {
+	s.driveWrite(w, r, true)
+}
+
+func (s *Server) driveWrite(w http.ResponseWriter, r *http.Request, remove bool) {
+	execer, host := s.driveSyncTarget(w, r)
+	if host == nil {
+		return
+	}
+	p := cleanDrivePath(r.URL.Query().Get("path"))
+	if p == "." {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	base := strings.Trim(r.Header.Get("If-Match"), `"`)
+	if base == "" && r.Header.Get("If-None-Match") != "*" {
+		http.Error(w, "If-Match or If-None-Match: * is required", http.StatusPreconditionRequired)
+		return
+	}
+	var body []byte
+	mode := "write"
+	if remove {
+		mode = "delete"
+	} else {
+		var err error
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxDriveSyncFileSize))
+		if err != nil {
+			http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	var stdin io.Reader
+	if !remove {
+		stdin = bytes.NewReader(body)
+	}
+	out, err := execer.ExecInput(r.Context(), host.ID, []string{"sh", "-c", driveWriteScript, "sh", p, base, mode}, stdin)
+	if err != nil {
+		log.Printf("drive sync: %s %s via sandbox %s: %v", mode, p, host.ID, err)
+		http.Error(w, "failed to "+mode+" file", http.StatusBadGateway)
+		return
+	}
+	out = strings.TrimSpace(out)
+	if current, ok := strings.CutPrefix(out, "conflict"); ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "conflict", "sha256": strings.TrimSpace(current)})
+		return
+	}
+	if out != "ok" {
+		log.Printf("drive sync: %s %s via sandbox %s: unexpected output %q", mode, p, host.ID, out)
+		http.Error(w, "failed to "+mode+" file", http.StatusBadGateway)
+		return
+	}
+	if !remove {
+		sum := sha256.Sum256(body)
+		w.Header().Set("X-Content-SHA256", hex.EncodeToString(sum[:]))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
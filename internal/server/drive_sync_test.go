@@ -0,0 +1,34 @@
+package server
+
+import "testing"
+
+func TestCleanDrivePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                 ".",
+		".":                ".",
+		"/":                ".",
+		"src/main.go":      "src/main.go",
+		"./src//a/../b":    "src/b",
+		"../../etc/passwd": "etc/passwd",
+		"/abs/path":        "abs/path",
+	} {
+		if got := cleanDrivePath(in); got != want {
+			t.Errorf("cleanDrivePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseDriveManifest(t *testing.T) {
+	h := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	out := h + "  ./a.txt\n" + h + "  src/b c.go\nnot a line\n" + "abc  short.txt\n"
+	files := parseDriveManifest(out)
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2: %+v", len(files), files)
+	}
+	if files[0].Path != "a.txt" || files[1].Path != "src/b c.go" || files[0].SHA256 != h {
+		t.Errorf("files = %+v", files)
+	}
+	if files := parseDriveManifest(""); files == nil || len(files) != 0 {
+		t.Errorf("empty manifest = %#v, want empty slice", files)
+	}
+}
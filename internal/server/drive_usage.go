@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// driveUsageWarnPercent is the share of the drive limit at which usage
+	// is reported as a warning.
+	driveUsageWarnPercent = 90
+	driveUsageTimeout     = 30 * time.Second
+)
+
+// driveUsageReport is the usage of a workspace's drives against its limit:
+// the drives' capacity where the backend caps them (K8s PVCs), otherwise
+// the workspace's drive size quota (Docker volumes, which are not capped,
+// so they can grow past it).
+type driveUsageReport struct {
+	Drives     []storage.DriveUsage `json:"drives"`
+	UsedBytes  int64                `json:"used_bytes"`
+	LimitBytes int64                `json:"limit_bytes"`
+	Percent    int                  `json:"percent"`
+	Status     string               `json:"status"` // ok, warning, exceeded or unknown
+}
+
+func summarizeDriveUsage(drives []storage.DriveUsage, quota int64) driveUsageReport {
+	rep := driveUsageReport{Drives: drives, Status: "unknown"}
+	known := false
+	for _, d := range drives {
+		if d.UsedBytes >= 0 {
+			rep.UsedBytes += d.UsedBytes
+			known = true
+		}
+		limit := d.CapacityBytes
+		if limit <= 0 {
+			limit = quota
+		}
+		rep.LimitBytes += limit
+	}
+	if !known || rep.LimitBytes <= 0 {
+		return rep
+	}
+	rep.Percent = int(rep.UsedBytes * 100 / rep.LimitBytes)
+	switch {
+	case rep.UsedBytes >= rep.LimitBytes:
+		rep.Status = "exceeded"
+	case rep.Percent >= driveUsageWarnPercent:
+		rep.Status = "warning"
+	default:
+		rep.Status = "ok"
+	}
+	return rep
+}
+
+// GET /api/workspaces/{id}/drive-usage
+func (s *Server) handleWorkspaceDriveUsage(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	reporter, ok := s.DriveManager.(storage.DriveUsageReporter)
+	if !ok {
+		http.Error(w, "drive usage is not supported by this backend", http.StatusNotImplemented)
+		return
+	}
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil || ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+	var namespace string
+	if ws.K8sNamespace.Valid {
+		namespace = ws.K8sNamespace.String
+	}
+	wd, err := s.effectiveWorkspaceDefaults(wsID)
+	if err != nil {
+		log.Printf("failed to get quota of workspace %s: %v", wsID, err)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), driveUsageTimeout)
+	defer cancel()
+	drives, err := reporter.DriveUsage(ctx, wsID, namespace)
+	if err != nil {
+		log.Printf("failed to get drive usage of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to get drive usage", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summarizeDriveUsage(drives, wd.MaxDriveSize))
+}
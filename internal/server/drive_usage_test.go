@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/storage"
+)
+
+func TestSummarizeDriveUsage(t *testing.T) {
+	const gi = int64(1) << 30
+	tests := []struct {
+		name   string
+		drives []storage.DriveUsage
+		quota  int64
+		status string
+		pct    int
+	}{
+		{"capped drive", []storage.DriveUsage{{UsedBytes: 5 * gi, CapacityBytes: 10 * gi}}, 20 * gi, "ok", 50},
+		{"quota for uncapped drive", []storage.DriveUsage{{UsedBytes: 19 * gi}}, 20 * gi, "warning", 95},
+		{"over quota", []storage.DriveUsage{{UsedBytes: 25 * gi}}, 20 * gi, "exceeded", 125},
+		{"unknown usage", []storage.DriveUsage{{UsedBytes: -1, CapacityBytes: 10 * gi}}, 0, "unknown", 0},
+		{"no limit", []storage.DriveUsage{{UsedBytes: gi}}, 0, "unknown", 0},
+		{"no drives", []storage.DriveUsage{}, 20 * gi, "unknown", 0},
+	}
+	for _, tt := range tests {
+		rep := summarizeDriveUsage(tt.drives, tt.quota)
+		if rep.Status != tt.status || rep.Percent != tt.pct {
+			t.Errorf("%s: status %s, percent %d; want %s, %d", tt.name, rep.Status, rep.Percent, tt.status, tt.pct)
+		}
+	}
+}
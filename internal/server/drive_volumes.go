@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/storage"
+)
+
+// driveDeleteTimeout bounds drive removal when a workspace is deleted.
+const driveDeleteTimeout = 30 * time.Second
+
+// deleteWorkspaceDrives removes the drives of a workspace whose drive
+// manager doesn't remove them with the workspace namespace (Docker volumes).
+// Failures are logged; the orphan drive GC retries later.
+func (s *Server) deleteWorkspaceDrives(workspaceID, namespace string) {
+	dd, ok := s.DriveManager.(storage.DriveDeleter)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), driveDeleteTimeout)
+	defer cancel()
+	if err := dd.DeleteDrive(ctx, workspaceID, namespace); err != nil {
+		log.Printf("failed to delete drives of workspace %s: %v", workspaceID, err)
+	}
+}
+
+// StartDriveGCLoop is the exported entry point for the server's main
+// lifecycle to garbage-collect drives of deleted workspaces. A no-op for
+// drive managers without orphan collection.
+func (s *Server) StartDriveGCLoop(ctx context.Context, every time.Duration) {
+	gc, ok := s.DriveManager.(storage.DriveGarbageCollector)
+	if !ok {
+		return
+	}
+	if every <= 0 {
+		every = time.Hour
+	}
+	collect := func() {
+		removed, err := gc.CollectOrphanDrives(ctx)
+		if err != nil {
+			log.Printf("drive GC: %v", err)
+			return
+		}
+		if len(removed) > 0 {
+			log.Printf("drive GC: removed %d orphan drive(s)", len(removed))
+		}
+	}
+	collect()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			collect()
+		}
+	}
+}
+
+// GET /api/admin/docker-volumes lists workspace drive volumes on the Docker
+// host with their disk usage and whether they are orphaned.
+func (s *Server) handleAdminListDockerVolumes(w http.ResponseWriter, r *http.Request) {
+	lister, ok := s.DriveManager.(storage.DockerVolumeLister)
+	if !ok {
+		http.Error(w, "docker volumes are only available on the docker backend", http.StatusNotImplemented)
+		return
+	}
+	volumes, err := lister.ListDockerVolumes(r.Context())
+	if err != nil {
+		log.Printf("admin: failed to list docker volumes: %v", err)
+		http.Error(w, "failed to list docker volumes", http.StatusBadGateway)
+		return
+	}
+	var total, orphaned int64
+	for _, v := range volumes {
+		if v.Size > 0 {
+			total += v.Size
+			if v.Orphan {
+				orphaned += v.Size
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"volumes":     volumes,
+		"total_size":  total,
+		"orphan_size": orphaned,
+	})
+}
+
+// POST /api/admin/docker-volumes/gc removes orphan workspace drive volumes
+// now instead of waiting for the next GC run.
+func (s *Server) handleAdminCollectDockerVolumes(w http.ResponseWriter, r *http.Request) {
+	gc, ok := s.DriveManager.(storage.DriveGarbageCollector)
+	if !ok {
+		http.Error(w, "drive GC is not supported by this backend", http.StatusNotImplemented)
+		return
+	}
+	removed, err := gc.CollectOrphanDrives(r.Context())
+	if err != nil {
+		log.Printf("admin: drive GC failed: %v", err)
+		http.Error(w, "drive GC failed", http.StatusInternalServerError)
+		return
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"removed": removed})
+}
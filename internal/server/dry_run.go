@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/storage"
+)
+
+// Destructive endpoints (deleting a workspace or sandbox, changing quotas)
+// accept ?dry_run=true: the request is authorized and validated as usual,
+// but instead of executing it the server returns the actions it would take,
+// so integrators can build against the API without side effects.
+
+// dryRunAction is one step a request would take.
+type dryRunAction struct {
+	Action  string                 `json:"action"`
+	Target  string                 `json:"target,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// isDryRun reports whether the request asks for a dry run.
+func isDryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dry_run") == "true"
+}
+
+// writeDryRun writes the plan of a dry run.
+func writeDryRun(w http.ResponseWriter, actions []dryRunAction) {
+	if actions == nil {
+		actions = []dryRunAction{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run": true,
+		"actions": actions,
+	})
+}
+
+// sandboxDeleteActions returns what deleting sbx does, in order.
+func (s *Server) sandboxDeleteActions(sbx *sbxstore.Sandbox) []dryRunAction {
+	var actions []dryRunAction
+	if sbx.IsLocal {
+		actions = append(actions, dryRunAction{Action: "close_tunnel", Target: sbx.ID})
+	} else {
+		if _, ok := s.ProcessManager.(sessionSnapshotter); ok {
+			snapshots, err := s.DB.ListSandboxSnapshots(sbx.ID)
+			if err != nil {
+				log.Printf("failed to list snapshots of sandbox %s: %v", sbx.ID, err)
+			}
+			if len(snapshots) > 0 {
+				actions = append(actions, dryRunAction{Action: "delete_snapshots", Target: sbx.ID,
+					Details: map[string]interface{}{"count": len(snapshots)}})
+			}
+		}
+		switch sbx.Status {
+		case sbxstore.StatusRunning:
+			actions = append(actions, dryRunAction{Action: "stop_sandbox", Target: sbx.ID})
+		case sbxstore.StatusPaused:
+			if sbx.SandboxName != "" {
+				actions = append(actions, dryRunAction{Action: "delete_paused_sandbox", Target: sbx.ID,
+					Details: map[string]interface{}{"sandbox_name": sbx.SandboxName}})
+			}
+		}
+	}
+	if sbx.Type == "nanoclaw" {
+		actions = append(actions, dryRunAction{Action: "unbind_im_channel", Target: sbx.ID})
+	}
+	return append(actions, dryRunAction{Action: "delete_sandbox", Target: sbx.ID,
+		Details: map[string]interface{}{"name": sbx.Name, "status": sbx.Status}})
+}
+
+// workspaceDeleteActions returns what deleteWorkspace does, in order.
+func (s *Server) workspaceDeleteActions(id string, ws *db.Workspace) []dryRunAction {
+	var actions []dryRunAction
+	for _, sbx := range s.Sandboxes.ListByWorkspace(id) {
+		actions = append(actions, s.sandboxDeleteActions(sbx)...)
+	}
+	if s.NamespaceManager != nil && ws != nil && ws.K8sNamespace.Valid {
+		actions = append(actions, dryRunAction{Action: "delete_namespace", Target: ws.K8sNamespace.String})
+	}
+	if _, ok := s.DriveManager.(storage.DriveDeleter); ok {
+		actions = append(actions, dryRunAction{Action: "delete_drives", Target: id})
+	}
+	if s.SessionHistory != nil {
+		actions = append(actions, dryRunAction{Action: "delete_session_history", Target: id})
+	}
+	details := map[string]interface{}{}
+	if ws != nil {
+		details["name"] = ws.Name
+	}
+	return append(actions, dryRunAction{Action: "delete_workspace", Target: id, Details: details})
+}
+
+// quotaChangeDetails returns the fields a quota request sets, each with its
+// current and requested value. req is the decoded request body; null fields
+// are included only if setNull (the request replaces rather than merges).
+func quotaChangeDetails(current map[string]interface{}, req interface{}, setNull bool) map[string]interface{} {
+	b, _ := json.Marshal(req)
+	var fields map[string]interface{}
+	json.Unmarshal(b, &fields)
+	details := make(map[string]interface{})
+	for name, v := range fields {
+		if v == nil && !setNull {
+			continue
+		}
+		details[name] = map[string]interface{}{"from": current[name], "to": v}
+	}
+	return details
+}
+
+// quotaDryRunActions returns the plan of a quota change of target ("defaults",
+// a user or a workspace ID): the change itself and its security event.
+func quotaDryRunActions(action, target string, details map[string]interface{}) []dryRunAction {
+	return []dryRunAction{
+		{Action: action, Target: target, Details: details},
+		{Action: "record_security_event", Details: map[string]interface{}{"type": SecurityEventQuotaChanged}},
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestSandboxDeleteActions(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		sbx  *sbxstore.Sandbox
+		want []string
+	}{
+		{&sbxstore.Sandbox{ID: "a", IsLocal: true}, []string{"close_tunnel", "delete_sandbox"}},
+		{&sbxstore.Sandbox{ID: "b", Status: sbxstore.StatusRunning}, []string{"stop_sandbox", "delete_sandbox"}},
+		{&sbxstore.Sandbox{ID: "c", Status: sbxstore.StatusPaused, SandboxName: "agent-sandbox-c", Type: "nanoclaw"},
+			[]string{"delete_paused_sandbox", "unbind_im_channel", "delete_sandbox"}},
+	}
+	for _, tt := range tests {
+		actions := s.sandboxDeleteActions(tt.sbx)
+		var got []string
+		for _, a := range actions {
+			got = append(got, a.Action)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("sandbox %s: actions %v, want %v", tt.sbx.ID, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("sandbox %s: actions %v, want %v", tt.sbx.ID, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestQuotaChangeDetails(t *testing.T) {
+	n := 5
+	req := struct {
+		MaxSandboxes  *int `json:"max_sandboxes"`
+		MaxSandboxCPU *int `json:"max_sandbox_cpu"`
+	}{MaxSandboxes: &n}
+	current := map[string]interface{}{"max_sandboxes": 3}
+
+	merged := quotaChangeDetails(current, req, false)
+	if len(merged) != 1 {
+		t.Fatalf("merge details = %v, want only max_sandboxes", merged)
+	}
+	change := merged["max_sandboxes"].(map[string]interface{})
+	if change["from"] != 3 || change["to"] != float64(5) {
+		t.Errorf("max_sandboxes change = %v", change)
+	}
+	if replaced := quotaChangeDetails(current, req, true); len(replaced) != 2 {
+		t.Errorf("replace details = %v, want both fields", replaced)
+	}
+}
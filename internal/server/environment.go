@@ -0,0 +1,205 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Sandbox environment manifests: the versions of the tools agents rely on,
+// probed inside running sandboxes and compared with a baseline — the
+// initial manifest of the newest sandbox of the same type, i.e. what the
+// current image ships. Drift explains "works in my sandbox" differences:
+// an old image, or tools upgraded by hand.
+
+// envProbeTimeout bounds one sandbox probe.
+const envProbeTimeout = 20 * time.Second
+
+// envProbeScript prints "<tool> <version>" for each tool present. Versions
+// are the first line of --version output.
+const envProbeScript = `for t in node npm python3 pip3 go git claude opencode jupyter; do
+  command -v "$t" >/dev/null 2>&1 || continue
+  v=$("$t" --version 2>&1 | head -n 1)
+  printf '%s %s\n' "$t" "$v"
+done
+[ -r /etc/os-release ] && . /etc/os-release && printf 'os %s\n' "$PRETTY_NAME"
+exit 0`
+
+// parseToolVersions parses envProbeScript output.
+func parseToolVersions(out string) map[string]string {
+	tools := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || name == "" {
+			continue
+		}
+		tools[name] = strings.TrimSpace(version)
+	}
+	return tools
+}
+
+// ToolDrift is a tool whose version differs from the baseline; an empty
+// version means the tool is missing on that side.
+type ToolDrift struct {
+	Tool     string `json:"tool"`
+	Baseline string `json:"baseline"`
+	Current  string `json:"current"`
+}
+
+// diffTools returns the tools that differ between baseline and current,
+// sorted by name.
+func diffTools(baseline, current map[string]string) []ToolDrift {
+	drift := []ToolDrift{}
+	for tool, want := range baseline {
+		if got := current[tool]; got != want {
+			drift = append(drift, ToolDrift{Tool: tool, Baseline: want, Current: got})
+		}
+	}
+	for tool, got := range current {
+		if _, ok := baseline[tool]; !ok {
+			drift = append(drift, ToolDrift{Tool: tool, Current: got})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Tool < drift[j].Tool })
+	return drift
+}
+
+// probeEnvironment captures and stores the manifest of a running sandbox.
+func (s *Server) probeEnvironment(ctx context.Context, sandboxID, sandboxType string) error {
+	execer, ok := s.ProcessManager.(interface {
+		ExecSimple(ctx context.Context, sandboxID string, command []string) (string, error)
+	})
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, envProbeTimeout)
+	defer cancel()
+	out, err := execer.ExecSimple(ctx, sandboxID, []string{"sh", "-c", envProbeScript})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(parseToolVersions(out))
+	if err != nil {
+		return err
+	}
+	return s.DB.UpsertSandboxEnvironment(sandboxID, sandboxType, data)
+}
+
+// StartEnvironmentProbeLoop probes every running cloud sandbox each
+// interval until ctx is cancelled. interval <= 0 disables it.
+func (s *Server) StartEnvironmentProbeLoop(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	log.Printf("environment probe loop: interval=%s", interval)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.probeAllEnvironments(ctx)
+		}
+	}
+}
+
+func (s *Server) probeAllEnvironments(ctx context.Context) {
+	sandboxes, err := s.DB.ListAllSandboxes()
+	if err != nil {
+		log.Printf("environment probe: list sandboxes: %v", err)
+		return
+	}
+	for _, sbx := range sandboxes {
+		if ctx.Err() != nil {
+			return
+		}
+		if sbx.Status != "running" || sbx.IsLocal || sbx.QuarantinedAt.Valid {
+			continue
+		}
+		if err := s.probeEnvironment(ctx, sbx.ID, sbx.Type); err != nil {
+			log.Printf("environment probe: sandbox %s: %v", sbx.ID, err)
+		}
+	}
+}
+
+// handleSandboxEnvironment returns the sandbox's tool versions and their
+// drift from the baseline. ?refresh=true probes the sandbox first.
+// GET /api/sandboxes/{id}/environment
+func (s *Server) handleSandboxEnvironment(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
+		return
+	}
+
+	if r.URL.Query().Get("refresh") == "true" {
+		if sbx.Status != "running" || sbx.IsLocal {
+			http.Error(w, "only running cloud sandboxes can be probed", http.StatusConflict)
+			return
+		}
+		if err := s.probeEnvironment(r.Context(), sbx.ID, sbx.Type); err != nil {
+			log.Printf("environment probe: sandbox %s: %v", sbx.ID, err)
+			http.Error(w, "failed to probe sandbox", http.StatusBadGateway)
+			return
+		}
+	}
+
+	env, err := s.DB.GetSandboxEnvironment(id)
+	if err != nil {
+		log.Printf("failed to get environment of sandbox %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if env == nil {
+		http.Error(w, "sandbox has not been probed yet", http.StatusNotFound)
+		return
+	}
+	resp, err := environmentResponse(s.DB, env)
+	if err != nil {
+		log.Printf("failed to get environment baseline for %s: %v", env.SandboxType, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type environmentResp struct {
+	Tools             map[string]string `json:"tools"`
+	CapturedAt        time.Time         `json:"captured_at"`
+	Baseline          map[string]string `json:"baseline"`
+	BaselineSandboxID string            `json:"baseline_sandbox_id"`
+	Drift             []ToolDrift       `json:"drift"`
+	// Changed lists tools that differ from this sandbox's own first
+	// capture, e.g. upgraded by hand.
+	Changed []ToolDrift `json:"changed"`
+}
+
+func environmentResponse(database *db.DB, env *db.SandboxEnvironment) (*environmentResp, error) {
+	resp := &environmentResp{CapturedAt: env.CapturedAt}
+	var initial map[string]string
+	json.Unmarshal(env.Tools, &resp.Tools)
+	json.Unmarshal(env.InitialTools, &initial)
+
+	baseline, baselineID, err := database.GetEnvironmentBaseline(env.SandboxType)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(baseline, &resp.Baseline)
+	resp.BaselineSandboxID = baselineID
+	resp.Drift = diffTools(resp.Baseline, resp.Tools)
+	resp.Changed = diffTools(initial, resp.Tools)
+	return resp, nil
+}
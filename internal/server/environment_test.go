@@ -0,0 +1,35 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseToolVersions(t *testing.T) {
+	out := "node v22.3.0\npython3 Python 3.12.4\nclaude 1.0.17 (Claude Code)\n\nos Debian GNU/Linux 12 (bookworm)\n"
+	want := map[string]string{
+		"node":    "v22.3.0",
+		"python3": "Python 3.12.4",
+		"claude":  "1.0.17 (Claude Code)",
+		"os":      "Debian GNU/Linux 12 (bookworm)",
+	}
+	if got := parseToolVersions(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseToolVersions = %v, want %v", got, want)
+	}
+}
+
+func TestDiffTools(t *testing.T) {
+	baseline := map[string]string{"node": "v22.3.0", "opencode": "0.5.1", "git": "2.39"}
+	current := map[string]string{"node": "v20.1.0", "opencode": "0.5.1", "go": "go1.22"}
+	want := []ToolDrift{
+		{Tool: "git", Baseline: "2.39"},
+		{Tool: "go", Current: "go1.22"},
+		{Tool: "node", Baseline: "v22.3.0", Current: "v20.1.0"},
+	}
+	if got := diffTools(baseline, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTools = %+v, want %+v", got, want)
+	}
+	if got := diffTools(current, current); len(got) != 0 {
+		t.Errorf("identical manifests drift: %+v", got)
+	}
+}
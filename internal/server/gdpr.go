@@ -0,0 +1,410 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+// GDPR data subject rights: users download their data as a JSON archive,
+// and request erasure, which an admin approves or rejects. Erasure deletes
+// the user's personal data and the workspaces they were alone in, and
+// replaces their ID in audit records by a random pseudonym so the records
+// stay consistent without identifying them.
+
+// userExport is the archive returned by GET /api/users/me/export.
+type userExport struct {
+	ExportedAt     time.Time                 `json:"exported_at"`
+	Profile        map[string]interface{}    `json:"profile"`
+	Identities     []map[string]interface{}  `json:"identities"`
+	Memberships    []userExportMembership    `json:"memberships"`
+	CodexTokens    []map[string]interface{}  `json:"codex_tokens"`
+	AccessTokens   []*db.PersonalAccessToken `json:"access_tokens"`
+	SecurityEvents []*db.SecurityEvent       `json:"security_events"`
+	// Activity is the user's tracked time per sandbox.
+	Activity []db.SandboxActivityTotal `json:"activity"`
+	// NotificationPreferences are the notification kinds the user set.
+	NotificationPreferences []db.NotificationPreference `json:"notification_preferences"`
+}
+
+type userExportMembership struct {
+	WorkspaceID   string                   `json:"workspace_id"`
+	WorkspaceName string                   `json:"workspace_name"`
+	Role          string                   `json:"role"`
+	Sandboxes     []map[string]interface{} `json:"sandboxes"`
+	// Usage statements are included for workspaces the user owns.
+	UsageStatements []map[string]interface{} `json:"usage_statements,omitempty"`
+}
+
+// handleExportMyData returns everything stored about the current user:
+// profile, linked identities, workspace memberships with the metadata of
+// their sandboxes, usage statements of owned workspaces, codex tokens
+// (without secrets), security events they caused and their tracked time.
+func (s *Server) handleExportMyData(w http.ResponseWriter, r *http.Request) {
+	if refuseImpersonation(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	export, err := s.buildUserExport(r, userID)
+	if err != nil {
+		log.Printf("failed to export data of user %s: %v", userID, err)
+		http.Error(w, "failed to export user data", http.StatusInternalServerError)
+		return
+	}
+	if export == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="agentserver-export-`+userID+`.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(export)
+}
+
+func (s *Server) buildUserExport(r *http.Request, userID string) (*userExport, error) {
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	export := &userExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: map[string]interface{}{
+			"id":         user.ID,
+			"email":      user.Email,
+			"name":       user.Name,
+			"picture":    user.Picture,
+			"role":       user.Role,
+			"created_at": user.CreatedAt,
+			"updated_at": user.UpdatedAt,
+		},
+		Identities:  []map[string]interface{}{},
+		Memberships: []userExportMembership{},
+		CodexTokens: []map[string]interface{}{},
+	}
+
+	identities, err := s.DB.ListOIDCIdentitiesByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, oi := range identities {
+		export.Identities = append(export.Identities, map[string]interface{}{
+			"provider":   oi.Provider,
+			"subject":    oi.Subject,
+			"email":      oi.Email,
+			"created_at": oi.CreatedAt,
+		})
+	}
+
+	workspaces, err := s.DB.ListWorkspacesByUser(userID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, ws := range workspaces {
+		role, err := s.DB.GetWorkspaceMemberRole(ws.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		m := userExportMembership{
+			WorkspaceID:   ws.ID,
+			WorkspaceName: ws.Name,
+			Role:          role,
+			Sandboxes:     []map[string]interface{}{},
+		}
+		for _, sbx := range s.Sandboxes.ListByWorkspace(ws.ID) {
+			m.Sandboxes = append(m.Sandboxes, map[string]interface{}{
+				"id":               sbx.ID,
+				"name":             sbx.Name,
+				"type":             sbx.Type,
+				"status":           sbx.Status,
+				"is_local":         sbx.IsLocal,
+				"cpu":              sbx.CPU,
+				"memory":           sbx.Memory,
+				"created_at":       sbx.CreatedAt,
+				"last_activity_at": sbx.LastActivityAt,
+			})
+		}
+		if role == "owner" {
+			statements, err := s.DB.ListUsageStatements(ws.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, st := range statements {
+				m.UsageStatements = append(m.UsageStatements, statementJSON(st))
+			}
+		}
+		export.Memberships = append(export.Memberships, m)
+
+		tokens, err := s.DB.ListCodexTokensForWorkspace(r.Context(), ws.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tokens {
+			if t.UserID != userID {
+				continue
+			}
+			export.CodexTokens = append(export.CodexTokens, map[string]interface{}{
+				"id":           t.ID,
+				"workspace_id": t.WorkspaceID,
+				"name":         t.Name,
+				"created_at":   t.CreatedAt,
+				"expires_at":   t.ExpiresAt,
+				"last_used_at": t.LastUsedAt,
+				"revoked_at":   t.RevokedAt,
+			})
+		}
+	}
+
+	export.AccessTokens, err = s.DB.ListPersonalAccessTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	export.SecurityEvents, err = s.DB.ListSecurityEvents(db.SecurityEventFilter{ActorID: userID, Limit: 1000})
+	if err != nil {
+		return nil, err
+	}
+	export.Activity, err = s.DB.SandboxActivityTotals(db.SandboxActivityFilter{UserID: userID, Since: time.Unix(0, 0), Until: export.ExportedAt})
+	if err != nil {
+		return nil, err
+	}
+	export.NotificationPreferences, err = s.DB.ListNotificationPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// handleRequestErasure files an erasure request for the current user,
+// pending admin approval. Body: {"reason": "..."} (optional).
+func (s *Server) handleRequestErasure(w http.ResponseWriter, r *http.Request) {
+	if refuseImpersonation(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	latest, err := s.DB.LatestUserErasureRequest(userID)
+	if err != nil {
+		log.Printf("failed to get erasure request of user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if latest != nil && latest.Status == "pending" {
+		http.Error(w, "an erasure request is already pending", http.StatusConflict)
+		return
+	}
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil || user == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	er, err := s.DB.CreateUserErasureRequest(userID, user.Email, req.Reason)
+	if err != nil {
+		log.Printf("failed to create erasure request of user %s: %v", userID, err)
+		http.Error(w, "failed to create erasure request", http.StatusInternalServerError)
+		return
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventErasureRequested,
+		Severity: SecuritySeverityWarning,
+		TargetID: userID,
+		Details:  map[string]interface{}{"request_id": er.ID},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(er)
+}
+
+// handleGetMyErasureRequest returns the current user's latest erasure request.
+func (s *Server) handleGetMyErasureRequest(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	er, err := s.DB.LatestUserErasureRequest(userID)
+	if err != nil {
+		log.Printf("failed to get erasure request of user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if er == nil {
+		http.Error(w, "no erasure request", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(er)
+}
+
+// GET /api/admin/erasure-requests?status= lists erasure requests, oldest first.
+func (s *Server) handleAdminListErasureRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := s.DB.ListUserErasureRequests(r.URL.Query().Get("status"))
+	if err != nil {
+		log.Printf("admin: failed to list erasure requests: %v", err)
+		http.Error(w, "failed to list erasure requests", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// pendingErasureRequest loads the {id} erasure request, writing an error
+// unless it is pending.
+func (s *Server) pendingErasureRequest(w http.ResponseWriter, r *http.Request) (*db.UserErasureRequest, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid request id", http.StatusBadRequest)
+		return nil, false
+	}
+	er, err := s.DB.GetUserErasureRequest(id)
+	if err != nil {
+		log.Printf("admin: failed to get erasure request %d: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if er == nil {
+		http.Error(w, "erasure request not found", http.StatusNotFound)
+		return nil, false
+	}
+	if er.Status != "pending" {
+		http.Error(w, "erasure request is "+er.Status, http.StatusConflict)
+		return nil, false
+	}
+	return er, true
+}
+
+// handleAdminApproveErasure erases the user of a pending request. Workspaces
+// the user is the only member of are deleted with their sandboxes; shared
+// workspaces they are the only owner of must be handed over first (409).
+// Admins cannot approve their own request.
+func (s *Server) handleAdminApproveErasure(w http.ResponseWriter, r *http.Request) {
+	adminID := auth.UserIDFromContext(r.Context())
+	er, ok := s.pendingErasureRequest(w, r)
+	if !ok {
+		return
+	}
+	if er.UserID == adminID {
+		http.Error(w, "another admin must approve your erasure request", http.StatusForbidden)
+		return
+	}
+	s.eraseUser(w, r, er, adminID)
+}
+
+// eraseUser completes the pending erasure request er on behalf of adminID
+// and writes the response.
+func (s *Server) eraseUser(w http.ResponseWriter, r *http.Request, er *db.UserErasureRequest, adminID string) {
+	workspaces, err := s.DB.ListWorkspacesByUser(er.UserID, true)
+	if err != nil {
+		log.Printf("admin: failed to list workspaces of user %s: %v", er.UserID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	var toDelete []*db.Workspace
+	for _, ws := range workspaces {
+		members, err := s.DB.ListWorkspaceMembers(ws.ID)
+		if err != nil {
+			log.Printf("admin: failed to list members of workspace %s: %v", ws.ID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if len(members) <= 1 {
+			if sbx := firstPinned(s.Sandboxes.ListByWorkspace(ws.ID)); sbx != nil {
+				http.Error(w, "sandbox "+sbx.Name+" of workspace "+ws.Name+" is pinned; unpin it before erasure", http.StatusConflict)
+				return
+			}
+			if sbx := firstQuarantined(s.Sandboxes.ListByWorkspace(ws.ID)); sbx != nil {
+				http.Error(w, "sandbox "+sbx.Name+" of workspace "+ws.Name+" is quarantined; release it before erasure", http.StatusConflict)
+				return
+			}
+			toDelete = append(toDelete, ws)
+			continue
+		}
+		if !hasOtherOwner(members, er.UserID) {
+			http.Error(w, "user is the only owner of shared workspace "+ws.Name+"; transfer ownership before erasure", http.StatusConflict)
+			return
+		}
+	}
+
+	for _, ws := range toDelete {
+		del := db.SandboxDeletion{Reason: db.DeletionReasonUserErased, DeletedBy: adminID}
+		if err := s.deleteWorkspace(r.Context(), ws.ID, ws, del); err != nil {
+			log.Printf("admin: failed to delete workspace %s for erasure of user %s: %v", ws.ID, er.UserID, err)
+			http.Error(w, "failed to delete workspace "+ws.Name, http.StatusInternalServerError)
+			return
+		}
+	}
+	pseudonym, err := newErasurePseudonym()
+	if err != nil {
+		log.Printf("admin: failed to generate erasure pseudonym: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.EraseUser(er.ID, er.UserID, er.Email, adminID, pseudonym); err != nil {
+		log.Printf("admin: failed to erase user %s: %v", er.UserID, err)
+		http.Error(w, "failed to erase user", http.StatusInternalServerError)
+		return
+	}
+	// LLM usage lives in the proxy's database. The erasure has completed
+	// by now, so a failure there is only logged.
+	if err := s.pseudonymizeLLMUsage(er.UserID, pseudonym); err != nil {
+		log.Printf("admin: failed to pseudonymize LLM usage of erased user %s: %v", er.UserID, err)
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventUserErased,
+		Severity: SecuritySeverityCritical,
+		TargetID: pseudonym,
+		Details:  map[string]interface{}{"request_id": er.ID, "deleted_workspaces": len(toDelete)},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminRejectErasure rejects a pending request. Body: {"note": "..."}.
+func (s *Server) handleAdminRejectErasure(w http.ResponseWriter, r *http.Request) {
+	er, ok := s.pendingErasureRequest(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	rejected, err := s.DB.RejectUserErasureRequest(er.ID, auth.UserIDFromContext(r.Context()), req.Note)
+	if err != nil {
+		log.Printf("admin: failed to reject erasure request %d: %v", er.ID, err)
+		http.Error(w, "failed to reject erasure request", http.StatusInternalServerError)
+		return
+	}
+	if !rejected {
+		http.Error(w, "erasure request is no longer pending", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hasOtherOwner reports whether a workspace has an owner besides userID.
+func hasOtherOwner(members []*db.WorkspaceMember, userID string) bool {
+	for _, m := range members {
+		if m.Role == "owner" && m.UserID != userID {
+			return true
+		}
+	}
+	return false
+}
+
+// newErasurePseudonym returns the random ID that replaces an erased user's
+// ID in audit records.
+func newErasurePseudonym() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "erased-" + hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sandboxpb"
+)
+
+// grpcWatchInterval is how often WatchSandboxes re-reads the workspace's
+// sandboxes to find changes.
+const grpcWatchInterval = 2 * time.Second
+
+// grpcExecTimeout bounds an Exec call when the request sets no timeout.
+const grpcExecTimeout = 60 * time.Second
+
+// grpcMaxExecTimeout caps the timeout an Exec request may ask for.
+const grpcMaxExecTimeout = 10 * time.Minute
+
+// GRPCServer returns a gRPC server exposing the sandbox lifecycle API.
+// Callers authenticate with the same session tokens the REST API accepts,
+// sent as "authorization: Bearer <token>" metadata.
+//
+// Create, list, get, delete, pause and resume are served by the REST
+// handlers themselves, so permissions, quotas and validation cannot drift
+// between the two APIs.
+func (s *Server) GRPCServer() *grpc.Server {
+	g := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.grpcUnaryAuth),
+		grpc.ChainStreamInterceptor(s.grpcStreamAuth),
+	)
+	sandboxpb.RegisterSandboxServiceServer(g, &sandboxService{s: s})
+	return g
+}
+
+// grpcAuthenticate validates the bearer token in the incoming metadata and
+// returns a context carrying the caller's user ID and token.
+func (s *Server) grpcAuthenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if vals := md.Get("authorization"); len(vals) > 0 {
+		token, _ = strings.CutPrefix(vals[0], "Bearer ")
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	userID, ok := s.Auth.ValidateToken(token)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return withRESTCaller(ctx, userID, token), nil
+}
+
+func (s *Server) grpcUnaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.grpcAuthenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) grpcStreamAuth(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.grpcAuthenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream overrides the context of a server stream with the
+// authenticated one.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *authedStream) Context() context.Context { return a.ctx }
+
+// grpcError converts an error of callREST to a gRPC status error.
+func grpcError(err error) error {
+	var re *restError
+	if errors.As(err, &re) {
+		return status.Error(grpcCode(re.Code), re.Message)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// grpcCode maps an HTTP status of a REST handler to a gRPC code.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+func toSandboxPB(r sandboxResponse) *sandboxpb.Sandbox {
+	pb := &sandboxpb.Sandbox{
+		Id:            r.ID,
+		ShortId:       r.ShortID,
+		WorkspaceId:   r.WorkspaceID,
+		Name:          r.Name,
+		Type:          r.Type,
+		Status:        r.Status,
+		StatusMessage: r.StatusMessage,
+		IsLocal:       r.IsLocal,
+		Cpu:           int32(r.CPU),
+		Memory:        r.Memory,
+		CreatedAt:     r.CreatedAt,
+	}
+	for _, u := range []string{r.OpencodeURL, r.OpenclawURL, r.ClaudeCodeURL, r.JupyterURL, r.CustomURL} {
+		if u != "" {
+			pb.Url = u
+			break
+		}
+	}
+	if r.LastActivityAt != nil {
+		pb.LastActivityAt = *r.LastActivityAt
+	}
+	if r.PausedAt != nil {
+		pb.PausedAt = *r.PausedAt
+	}
+	return pb
+}
+
+// sandboxService implements sandboxpb.SandboxServiceServer.
+type sandboxService struct {
+	sandboxpb.UnimplementedSandboxServiceServer
+	s *Server
+}
+
+func (g *sandboxService) list(ctx context.Context, workspaceID string) ([]*sandboxpb.Sandbox, error) {
+	if workspaceID == "" {
+		return nil, status.Error(codes.InvalidArgument, "workspace_id is required")
+	}
+	var resp []sandboxResponse
+	if err := g.s.callREST(ctx, g.s.handleListSandboxes, http.MethodGet, map[string]string{"wid": workspaceID}, nil, &resp); err != nil {
+		return nil, grpcError(err)
+	}
+	out := make([]*sandboxpb.Sandbox, len(resp))
+	for i := range resp {
+		out[i] = toSandboxPB(resp[i])
+	}
+	return out, nil
+}
+
+func (g *sandboxService) ListSandboxes(ctx context.Context, req *sandboxpb.ListSandboxesRequest) (*sandboxpb.ListSandboxesResponse, error) {
+	sandboxes, err := g.list(ctx, req.GetWorkspaceId())
+	if err != nil {
+		return nil, err
+	}
+	return &sandboxpb.ListSandboxesResponse{Sandboxes: sandboxes}, nil
+}
+
+func (g *sandboxService) GetSandbox(ctx context.Context, req *sandboxpb.SandboxRequest) (*sandboxpb.Sandbox, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	var resp sandboxResponse
+	if err := g.s.callREST(ctx, g.s.handleGetSandbox, http.MethodGet, map[string]string{"id": req.GetId()}, nil, &resp); err != nil {
+		return nil, grpcError(err)
+	}
+	return toSandboxPB(resp), nil
+}
+
+func (g *sandboxService) CreateSandbox(ctx context.Context, req *sandboxpb.CreateSandboxRequest) (*sandboxpb.Sandbox, error) {
+	if req.GetWorkspaceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "workspace_id is required")
+	}
+	body := map[string]interface{}{
+		"name":    req.GetName(),
+		"type":    req.GetType(),
+		"browser": req.GetBrowser(),
+	}
+	if req.GetCpu() != 0 {
+		body["cpu"] = req.GetCpu()
+	}
+	if req.GetMemory() != 0 {
+		body["memory"] = req.GetMemory()
+	}
+	var resp sandboxResponse
+	if err := g.s.callREST(ctx, g.s.handleCreateSandbox, http.MethodPost, map[string]string{"wid": req.GetWorkspaceId()}, body, &resp); err != nil {
+		return nil, grpcError(err)
+	}
+	return toSandboxPB(resp), nil
+}
+
+func (g *sandboxService) DeleteSandbox(ctx context.Context, req *sandboxpb.SandboxRequest) (*sandboxpb.DeleteSandboxResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := g.s.callREST(ctx, g.s.handleDeleteSandbox, http.MethodDelete, map[string]string{"id": req.GetId()}, nil, nil); err != nil {
+		return nil, grpcError(err)
+	}
+	return &sandboxpb.DeleteSandboxResponse{}, nil
+}
+
+func (g *sandboxService) PauseSandbox(ctx context.Context, req *sandboxpb.SandboxRequest) (*sandboxpb.Sandbox, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := g.s.callREST(ctx, g.s.handlePauseSandbox, http.MethodPost, map[string]string{"id": req.GetId()}, nil, nil); err != nil {
+		return nil, grpcError(err)
+	}
+	return g.GetSandbox(ctx, req)
+}
+
+func (g *sandboxService) ResumeSandbox(ctx context.Context, req *sandboxpb.SandboxRequest) (*sandboxpb.Sandbox, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := g.s.callREST(ctx, g.s.handleResumeSandbox, http.MethodPost, map[string]string{"id": req.GetId()}, nil, nil); err != nil {
+		return nil, grpcError(err)
+	}
+	return g.GetSandbox(ctx, req)
+}
+
+func (g *sandboxService) WatchSandboxes(req *sandboxpb.WatchSandboxesRequest, stream grpc.ServerStreamingServer[sandboxpb.SandboxEvent]) error {
+	ctx := stream.Context()
+	seen := map[string]*sandboxpb.Sandbox{}
+	ticker := time.NewTicker(grpcWatchInterval)
+	defer ticker.Stop()
+	for {
+		current, err := g.list(ctx, req.GetWorkspaceId())
+		if err != nil {
+			return err
+		}
+		for _, ev := range diffSandboxes(seen, current) {
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// diffSandboxes returns the events that turn seen into current and updates
+// seen in place. A sandbox is MODIFIED when its status, status message or
+// name changed.
+func diffSandboxes(seen map[string]*sandboxpb.Sandbox, current []*sandboxpb.Sandbox) []*sandboxpb.SandboxEvent {
+	var events []*sandboxpb.SandboxEvent
+	present := make(map[string]bool, len(current))
+	for _, sbx := range current {
+		present[sbx.Id] = true
+		prev, ok := seen[sbx.Id]
+		switch {
+		case !ok:
+			events = append(events, &sandboxpb.SandboxEvent{Type: sandboxpb.SandboxEvent_ADDED, Sandbox: sbx})
+		case prev.Status != sbx.Status || prev.StatusMessage != sbx.StatusMessage || prev.Name != sbx.Name:
+			events = append(events, &sandboxpb.SandboxEvent{Type: sandboxpb.SandboxEvent_MODIFIED, Sandbox: sbx})
+		}
+		seen[sbx.Id] = sbx
+	}
+	for id, sbx := range seen {
+		if !present[id] {
+			events = append(events, &sandboxpb.SandboxEvent{Type: sandboxpb.SandboxEvent_DELETED, Sandbox: sbx})
+			delete(seen, id)
+		}
+	}
+	return events
+}
+
+// execTimeout returns the timeout of an Exec request asking for seconds:
+// grpcExecTimeout if it sets none, at most grpcMaxExecTimeout.
+func execTimeout(seconds int32) time.Duration {
+	if seconds <= 0 {
+		return grpcExecTimeout
+	}
+	if timeout := time.Duration(seconds) * time.Second; timeout < grpcMaxExecTimeout {
+		return timeout
+	}
+	return grpcMaxExecTimeout
+}
+
+func (g *sandboxService) Exec(ctx context.Context, req *sandboxpb.ExecRequest) (*sandboxpb.ExecResponse, error) {
+	if req.GetId() == "" || len(req.GetCommand()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id and command are required")
+	}
+	sbx, ok := g.s.Sandboxes.Get(req.GetId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "sandbox not found")
+	}
+	role, err := g.s.DB.GetWorkspaceMemberRole(sbx.WorkspaceID, auth.UserIDFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if role != "owner" && role != "maintainer" && role != "developer" {
+		return nil, status.Error(codes.PermissionDenied, "insufficient permissions")
+	}
+	if sbx.IsLocal {
+		return nil, status.Error(codes.FailedPrecondition, "exec is not available for local agents")
+	}
+	if sbx.QuarantinedAt != nil {
+		return nil, status.Error(codes.PermissionDenied, "sandbox is quarantined")
+	}
+	if sbx.Status != "running" {
+		return nil, status.Errorf(codes.FailedPrecondition, "sandbox is %s", sbx.Status)
+	}
+	execer, ok := g.s.ProcessManager.(interface {
+		ExecSimple(ctx context.Context, sandboxID string, command []string) (string, error)
+	})
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "exec is not supported by this backend")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execTimeout(req.GetTimeoutSeconds()))
+	defer cancel()
+	out, err := execer.ExecSimple(ctx, sbx.ID, req.GetCommand())
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, status.Error(codes.DeadlineExceeded, "command timed out")
+		}
+		log.Printf("grpc exec in sandbox %s: %v", sbx.ID, err)
+		return nil, status.Error(codes.Aborted, err.Error())
+	}
+	return &sandboxpb.ExecResponse{Output: []byte(out)}, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/agentserver/agentserver/internal/sandboxpb"
+)
+
+func TestDiffSandboxes(t *testing.T) {
+	seen := map[string]*sandboxpb.Sandbox{}
+
+	events := diffSandboxes(seen, []*sandboxpb.Sandbox{
+		{Id: "a", Status: "creating"},
+		{Id: "b", Status: "running"},
+	})
+	if len(events) != 2 || events[0].Type != sandboxpb.SandboxEvent_ADDED || events[1].Type != sandboxpb.SandboxEvent_ADDED {
+		t.Fatalf("initial events = %v, want two ADDED", events)
+	}
+
+	if events := diffSandboxes(seen, []*sandboxpb.Sandbox{
+		{Id: "a", Status: "creating"},
+		{Id: "b", Status: "running"},
+	}); len(events) != 0 {
+		t.Fatalf("unchanged list produced events: %v", events)
+	}
+
+	events = diffSandboxes(seen, []*sandboxpb.Sandbox{{Id: "a", Status: "running"}})
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Type != sandboxpb.SandboxEvent_MODIFIED || events[0].Sandbox.Id != "a" {
+		t.Errorf("event 0 = %v, want MODIFIED a", events[0])
+	}
+	if events[1].Type != sandboxpb.SandboxEvent_DELETED || events[1].Sandbox.Id != "b" {
+		t.Errorf("event 1 = %v, want DELETED b", events[1])
+	}
+	if _, ok := seen["b"]; ok {
+		t.Error("deleted sandbox still tracked")
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   codes.Code
+	}{
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusConflict, codes.FailedPrecondition},
+		{http.StatusBadGateway, codes.Unavailable},
+		{http.StatusInternalServerError, codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := grpcCode(tt.status); got != tt.want {
+			t.Errorf("grpcCode(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestRestErrorMessage(t *testing.T) {
+	if got := restErrorMessage([]byte("sandbox not found\n")); got != "sandbox not found" {
+		t.Errorf("plain: got %q", got)
+	}
+	if got := restErrorMessage([]byte(`{"error":"quota_exceeded","message":"Sandbox limit reached (3/3)."}`)); got != "Sandbox limit reached (3/3)." {
+		t.Errorf("json: got %q", got)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		seconds int32
+		want    time.Duration
+	}{
+		{0, grpcExecTimeout},
+		{-5, grpcExecTimeout},
+		{30, 30 * time.Second},
+		{600, grpcMaxExecTimeout},
+		{1 << 30, grpcMaxExecTimeout},
+	} {
+		if got := execTimeout(tt.seconds); got != tt.want {
+			t.Errorf("execTimeout(%d) = %v, want %v", tt.seconds, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/policy"
+)
+
+// Sandbox images are scanned for vulnerabilities on a schedule with Trivy
+// or Grype (IMAGE_SCANNER), or scan results are submitted by an external
+// pipeline through the admin API. The admin scan policy can block sandbox
+// creation from images whose latest scan has findings at or above a
+// severity.
+
+const (
+	settingKeyImageScanPolicy = "image_scan_policy"
+	imageScanTimeout          = 10 * time.Minute
+	// maxStoredFindings caps the findings kept per scan, most severe first.
+	maxStoredFindings = 500
+	// imageScanRetention is how long superseded scan results are kept.
+	imageScanRetention = 90 * 24 * time.Hour
+)
+
+var imageScanners = map[string]bool{"trivy": true, "grype": true}
+
+var severityRank = map[string]int{"CRITICAL": 4, "HIGH": 3, "MEDIUM": 2, "LOW": 1}
+
+// imageScanPolicy decides which scan results block sandbox creation.
+type imageScanPolicy struct {
+	// BlockSeverity blocks images with findings at or above this severity
+	// ("critical" or "high"); empty blocks nothing.
+	BlockSeverity string `json:"block_severity"`
+	// BlockUnscanned also blocks images without a completed scan.
+	BlockUnscanned bool `json:"block_unscanned"`
+}
+
+func (s *Server) getImageScanPolicy() imageScanPolicy {
+	var p imageScanPolicy
+	raw, err := s.DB.GetSystemSetting(settingKeyImageScanPolicy)
+	if err != nil {
+		log.Printf("failed to get image scan policy: %v", err)
+		return p
+	}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &p); err != nil {
+			log.Printf("invalid image scan policy %q: %v", raw, err)
+		}
+	}
+	return p
+}
+
+// imageScanBlock returns why sandboxes may not be created from image under
+// the scan policy, or "" if they may.
+func (s *Server) imageScanBlock(image string) (string, error) {
+	p := s.getImageScanPolicy()
+	if p.BlockSeverity == "" && !p.BlockUnscanned {
+		return "", nil
+	}
+	scan, err := s.DB.LatestImageScan(image)
+	if err != nil {
+		return "", err
+	}
+	if scan == nil {
+		if p.BlockUnscanned {
+			return "image " + image + " has not been scanned for vulnerabilities", nil
+		}
+		return "", nil
+	}
+	if p.BlockSeverity == "" {
+		return "", nil
+	}
+	n := scan.Critical
+	if p.BlockSeverity == "high" {
+		n += scan.High
+	}
+	if n > 0 {
+		return fmt.Sprintf("image %s has %d %s-or-worse vulnerabilities (scanned %s)", image, n, p.BlockSeverity, scan.ScannedAt.Format(time.RFC3339)), nil
+	}
+	return "", nil
+}
+
+// imageScanTargets returns the sandbox images to scan: the image of every
+// sandbox type, and the policy allowlist entries that name a single image.
+func (s *Server) imageScanTargets() []string {
+	seen := make(map[string]bool)
+	var images []string
+	add := func(image string) {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	if im, ok := s.ProcessManager.(interface{ ImageForType(string) string }); ok {
+		for _, t := range policy.SandboxTypes {
+			add(im.ImageForType(t))
+		}
+	}
+	for _, pattern := range s.Policy.Get().ImageAllowlist {
+		if !strings.ContainsAny(pattern, "*?[") {
+			add(pattern)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// scanImage runs the configured scanner on an image.
+func (s *Server) scanImage(ctx context.Context, image string) *db.ImageScan {
+	scan := &db.ImageScan{Image: image, Scanner: s.ImageScanner, Status: "completed"}
+	ctx, cancel := context.WithTimeout(ctx, imageScanTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch s.ImageScanner {
+	case "trivy":
+		cmd = exec.CommandContext(ctx, "trivy", "image", "--quiet", "--format", "json", "--scanners", "vuln", image)
+	case "grype":
+		cmd = exec.CommandContext(ctx, "grype", image, "--output", "json", "--quiet")
+	default:
+		scan.Status, scan.Error = "failed", "no image scanner configured"
+		return scan
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		scan.Status = "failed"
+		scan.Error = strings.TrimSpace(fmt.Sprintf("%v: %s", err, stderr.String()))
+		return scan
+	}
+	findings, err := parseScanReport(s.ImageScanner, out)
+	if err != nil {
+		scan.Status, scan.Error = "failed", err.Error()
+		return scan
+	}
+	setScanFindings(scan, findings)
+	return scan
+}
+
+// parseScanReport parses a Trivy or Grype JSON report.
+func parseScanReport(scanner string, data []byte) ([]db.ImageFinding, error) {
+	switch scanner {
+	case "trivy":
+		return parseTrivyReport(data)
+	case "grype":
+		return parseGrypeReport(data)
+	}
+	return nil, fmt.Errorf("unknown scanner %q", scanner)
+}
+
+func parseTrivyReport(data []byte) ([]db.ImageFinding, error) {
+	var report struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string
+				PkgName          string
+				InstalledVersion string
+				FixedVersion     string
+				Severity         string
+				Title            string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse trivy report: %w", err)
+	}
+	var findings []db.ImageFinding
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			findings = append(findings, db.ImageFinding{
+				ID:               v.VulnerabilityID,
+				Severity:         strings.ToUpper(v.Severity),
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Title:            v.Title,
+			})
+		}
+	}
+	return findings, nil
+}
+
+func parseGrypeReport(data []byte) ([]db.ImageFinding, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse grype report: %w", err)
+	}
+	var findings []db.ImageFinding
+	for _, m := range report.Matches {
+		findings = append(findings, db.ImageFinding{
+			ID:               m.Vulnerability.ID,
+			Severity:         strings.ToUpper(m.Vulnerability.Severity),
+			Package:          m.Artifact.Name,
+			InstalledVersion: m.Artifact.Version,
+			FixedVersion:     strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Title:            m.Vulnerability.Description,
+		})
+	}
+	return findings, nil
+}
+
+// setScanFindings counts findings by severity and stores the most severe
+// ones on the scan.
+func setScanFindings(scan *db.ImageScan, findings []db.ImageFinding) {
+	scan.Critical, scan.High, scan.Medium, scan.Low = 0, 0, 0, 0
+	for _, f := range findings {
+		switch f.Severity {
+		case "CRITICAL":
+			scan.Critical++
+		case "HIGH":
+			scan.High++
+		case "MEDIUM":
+			scan.Medium++
+		case "LOW":
+			scan.Low++
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] > severityRank[findings[j].Severity]
+	})
+	if len(findings) > maxStoredFindings {
+		findings = findings[:maxStoredFindings]
+	}
+	scan.Findings = findings
+}
+
+// runImageScansOnce scans every target image and records the results.
+func (s *Server) runImageScansOnce(ctx context.Context) {
+	for _, image := range s.imageScanTargets() {
+		if ctx.Err() != nil {
+			return
+		}
+		scan := s.scanImage(ctx, image)
+		if scan.Status == "failed" {
+			log.Printf("image scan: %s: %s", image, scan.Error)
+		}
+		if err := s.DB.SaveImageScan(scan); err != nil {
+			log.Printf("image scan: failed to save result for %s: %v", image, err)
+		}
+	}
+	if _, err := s.DB.DeleteImageScansBefore(time.Now().Add(-imageScanRetention)); err != nil {
+		log.Printf("image scan: %v", err)
+	}
+}
+
+// StartImageScanLoop is the exported entry point for the server's main
+// lifecycle to scan sandbox images every `every`, starting now. It returns
+// at once when no scanner is configured.
+func (s *Server) StartImageScanLoop(ctx context.Context, every time.Duration) {
+	if s.ImageScanner == "" {
+		return
+	}
+	if every <= 0 {
+		every = 24 * time.Hour
+	}
+	s.runImageScansOnce(ctx)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.runImageScansOnce(ctx)
+		}
+	}
+}
+
+func imageScanJSON(scan *db.ImageScan, withFindings bool) map[string]interface{} {
+	resp := map[string]interface{}{
+		"image":      scan.Image,
+		"scanner":    scan.Scanner,
+		"status":     scan.Status,
+		"critical":   scan.Critical,
+		"high":       scan.High,
+		"medium":     scan.Medium,
+		"low":        scan.Low,
+		"scanned_at": scan.ScannedAt.Format(time.RFC3339),
+	}
+	if scan.Error != "" {
+		resp["error"] = scan.Error
+	}
+	if withFindings {
+		findings := scan.Findings
+		if findings == nil {
+			findings = []db.ImageFinding{}
+		}
+		resp["findings"] = findings
+	}
+	return resp
+}
+
+// GET /api/admin/image-scans lists the latest scan of every scanned image
+// and the target images not scanned yet.
+func (s *Server) handleAdminListImageScans(w http.ResponseWriter, r *http.Request) {
+	scans, err := s.DB.ListLatestImageScans()
+	if err != nil {
+		log.Printf("admin: failed to list image scans: %v", err)
+		http.Error(w, "failed to list image scans", http.StatusInternalServerError)
+		return
+	}
+	scanned := make(map[string]bool, len(scans))
+	resp := make([]map[string]interface{}, 0, len(scans))
+	for _, scan := range scans {
+		scanned[scan.Image] = true
+		resp = append(resp, imageScanJSON(scan, false))
+	}
+	unscanned := []string{}
+	for _, image := range s.imageScanTargets() {
+		if !scanned[image] {
+			unscanned = append(unscanned, image)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scanner":   s.ImageScanner,
+		"policy":    s.getImageScanPolicy(),
+		"scans":     resp,
+		"unscanned": unscanned,
+	})
+}
+
+// GET /api/admin/image-scans/findings?image= returns the latest completed
+// scan of an image with its findings.
+func (s *Server) handleAdminGetImageScanFindings(w http.ResponseWriter, r *http.Request) {
+	image := r.URL.Query().Get("image")
+	if image == "" {
+		http.Error(w, "image is required", http.StatusBadRequest)
+		return
+	}
+	scan, err := s.DB.LatestImageScan(image)
+	if err != nil {
+		log.Printf("admin: failed to get image scan of %s: %v", image, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if scan == nil {
+		http.Error(w, "no completed scan for image", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imageScanJSON(scan, true))
+}
+
+// POST /api/admin/image-scans records a scan done elsewhere (e.g. in CI).
+// The body carries either a raw Trivy/Grype JSON report or findings.
+func (s *Server) handleAdminSubmitImageScan(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Image    string            `json:"image"`
+		Scanner  string            `json:"scanner"`
+		Report   json.RawMessage   `json:"report"`
+		Findings []db.ImageFinding `json:"findings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Image == "" || req.Scanner == "" {
+		http.Error(w, "image and scanner are required", http.StatusBadRequest)
+		return
+	}
+	findings := req.Findings
+	if len(req.Report) > 0 {
+		if !imageScanners[req.Scanner] {
+			http.Error(w, "reports must come from trivy or grype; submit other scanners' results as findings", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if findings, err = parseScanReport(req.Scanner, req.Report); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	for i := range findings {
+		findings[i].Severity = strings.ToUpper(findings[i].Severity)
+	}
+	scan := &db.ImageScan{Image: req.Image, Scanner: req.Scanner, Status: "completed"}
+	setScanFindings(scan, findings)
+	if err := s.DB.SaveImageScan(scan); err != nil {
+		log.Printf("admin: failed to save image scan of %s: %v", req.Image, err)
+		http.Error(w, "failed to save image scan", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(imageScanJSON(scan, false))
+}
+
+// POST /api/admin/image-scans/run scans the target images now, in the
+// background.
+func (s *Server) handleAdminRunImageScans(w http.ResponseWriter, r *http.Request) {
+	if s.ImageScanner == "" {
+		http.Error(w, "no image scanner configured (IMAGE_SCANNER)", http.StatusConflict)
+		return
+	}
+	go s.runImageScansOnce(context.Background())
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GET /api/admin/image-scan-policy
+func (s *Server) handleAdminGetImageScanPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.getImageScanPolicy())
+}
+
+// PUT /api/admin/image-scan-policy
+func (s *Server) handleAdminSetImageScanPolicy(w http.ResponseWriter, r *http.Request) {
+	var p imageScanPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	p.BlockSeverity = strings.ToLower(p.BlockSeverity)
+	if p.BlockSeverity != "" && p.BlockSeverity != "critical" && p.BlockSeverity != "high" {
+		http.Error(w, "block_severity must be empty, critical or high", http.StatusBadRequest)
+		return
+	}
+	raw, _ := json.Marshal(p)
+	if err := s.DB.SetSystemSetting(settingKeyImageScanPolicy, string(raw)); err != nil {
+		log.Printf("admin: failed to set image scan policy: %v", err)
+		http.Error(w, "failed to set image scan policy", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+const testTrivyReport = `{
+  "SchemaVersion": 2,
+  "ArtifactName": "ghcr.io/agentserver/opencode-agent:latest",
+  "Results": [
+    {"Target": "debian 12", "Vulnerabilities": [
+      {"VulnerabilityID": "CVE-2026-0001", "PkgName": "openssl", "InstalledVersion": "3.0.1", "FixedVersion": "3.0.9", "Severity": "CRITICAL", "Title": "overflow"},
+      {"VulnerabilityID": "CVE-2026-0002", "PkgName": "zlib", "InstalledVersion": "1.2", "Severity": "LOW"}
+    ]},
+    {"Target": "node-pkg"}
+  ]
+}`
+
+const testGrypeReport = `{
+  "matches": [
+    {"vulnerability": {"id": "GHSA-xxxx", "severity": "High", "description": "prototype pollution", "fix": {"versions": ["4.17.21"]}},
+     "artifact": {"name": "lodash", "version": "4.17.15"}},
+    {"vulnerability": {"id": "CVE-2026-0003", "severity": "Medium", "fix": {"versions": []}},
+     "artifact": {"name": "curl", "version": "8.0"}}
+  ]
+}`
+
+func TestParseTrivyReport(t *testing.T) {
+	findings, err := parseScanReport("trivy", []byte(testTrivyReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, want 2", len(findings))
+	}
+	f := findings[0]
+	if f.ID != "CVE-2026-0001" || f.Severity != "CRITICAL" || f.Package != "openssl" || f.FixedVersion != "3.0.9" {
+		t.Errorf("finding = %+v", f)
+	}
+}
+
+func TestParseGrypeReport(t *testing.T) {
+	findings, err := parseScanReport("grype", []byte(testGrypeReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, want 2", len(findings))
+	}
+	f := findings[0]
+	if f.ID != "GHSA-xxxx" || f.Severity != "HIGH" || f.Package != "lodash" || f.InstalledVersion != "4.17.15" || f.FixedVersion != "4.17.21" {
+		t.Errorf("finding = %+v", f)
+	}
+	if _, err := parseScanReport("clair", []byte(`{}`)); err == nil {
+		t.Error("expected an error for an unknown scanner")
+	}
+}
+
+func TestSetScanFindings(t *testing.T) {
+	scan := &db.ImageScan{}
+	setScanFindings(scan, []db.ImageFinding{
+		{ID: "a", Severity: "LOW"},
+		{ID: "b", Severity: "CRITICAL"},
+		{ID: "c", Severity: "HIGH"},
+		{ID: "d", Severity: "CRITICAL"},
+		{ID: "e", Severity: "UNKNOWN"},
+	})
+	if scan.Critical != 2 || scan.High != 1 || scan.Medium != 0 || scan.Low != 1 {
+		t.Errorf("counts = %d/%d/%d/%d", scan.Critical, scan.High, scan.Medium, scan.Low)
+	}
+	var ids string
+	for _, f := range scan.Findings {
+		ids += f.ID
+	}
+	if ids != "bdcae" {
+		t.Errorf("findings order = %s, want most severe first", ids)
+	}
+}
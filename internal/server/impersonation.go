@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Support access: an admin can act as a user for a while to debug what the
+// user reported, without their password. The admin's session is replaced
+// by an impersonation session, which logging out ends. Starting and ending
+// it are security events, and events recorded meanwhile carry the admin in
+// details.impersonated_by. Other admins can't be impersonated.
+
+const maxImpersonationReasonLen = 500
+
+type impersonateRequest struct {
+	Reason          string `json:"reason"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+// validate checks the request and returns the session's lifetime.
+func (req *impersonateRequest) validate() (time.Duration, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return 0, fmt.Errorf("reason is required")
+	}
+	if utf8.RuneCountInString(req.Reason) > maxImpersonationReasonLen {
+		return 0, fmt.Errorf("reason must be at most %d characters", maxImpersonationReasonLen)
+	}
+	ttl := auth.DefaultImpersonationTTL
+	if req.DurationMinutes != 0 {
+		ttl = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	if ttl <= 0 || ttl > auth.MaxImpersonationTTL {
+		return 0, fmt.Errorf("duration_minutes must be between 1 and %d", int(auth.MaxImpersonationTTL/time.Minute))
+	}
+	return ttl, nil
+}
+
+// POST /api/admin/impersonate/{userId} {"reason": "...", "duration_minutes": 30}
+// starts an impersonation session: the session cookie is replaced and the
+// token returned.
+func (s *Server) handleAdminImpersonate(w http.ResponseWriter, r *http.Request) {
+	adminID := auth.UserIDFromContext(r.Context())
+	userID := chi.URLParam(r, "userId")
+	if userID == adminID {
+		http.Error(w, "you can't impersonate yourself", http.StatusBadRequest)
+		return
+	}
+	var req impersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	ttl, err := req.validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil {
+		log.Printf("admin: failed to get user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if user.Role == "admin" {
+		http.Error(w, "admins can't be impersonated", http.StatusForbidden)
+		return
+	}
+
+	token, expiresAt, err := s.Auth.IssueImpersonationToken(adminID, userID, ttl)
+	if err != nil {
+		log.Printf("admin: failed to issue impersonation token for %s: %v", userID, err)
+		http.Error(w, "failed to start impersonation", http.StatusInternalServerError)
+		return
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventImpersonationStarted,
+		Severity: SecuritySeverityWarning,
+		TargetID: userID,
+		Details:  map[string]interface{}{"reason": req.Reason, "expires_at": expiresAt},
+	})
+	log.Printf("admin: %s impersonates user %s until %s", adminID, userID, expiresAt.Format(time.RFC3339))
+
+	auth.SetImpersonationCookie(w, token, expiresAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      token,
+		"user_id":    user.ID,
+		"email":      user.Email,
+		"expires_at": expiresAt,
+	})
+}
+
+// endImpersonation ends the impersonation session of a logout request, if
+// it is one.
+func (s *Server) endImpersonation(r *http.Request) {
+	token := auth.SessionToken(r)
+	if token == "" {
+		return
+	}
+	userID, adminID, ok := s.Auth.Impersonation(token)
+	if !ok {
+		return
+	}
+	if err := s.Auth.EndImpersonation(token); err != nil {
+		log.Printf("failed to end impersonation of %s by %s: %v", userID, adminID, err)
+		return
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventImpersonationEnded,
+		Severity: SecuritySeverityInfo,
+		ActorID:  adminID,
+		TargetID: userID,
+	})
+}
+
+// refuseImpersonation answers 403 if the request is made in an
+// impersonation session, for actions only the user may take.
+func refuseImpersonation(w http.ResponseWriter, r *http.Request) bool {
+	if auth.ImpersonatorFromContext(r.Context()) == "" {
+		return false
+	}
+	http.Error(w, "not allowed while impersonating", http.StatusForbidden)
+	return true
+}
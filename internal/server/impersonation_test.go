@@ -0,0 +1,44 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+)
+
+func TestImpersonateRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     impersonateRequest
+		wantErr bool
+		ttl     time.Duration
+	}{
+		{"default", impersonateRequest{Reason: " ticket 42 "}, false, auth.DefaultImpersonationTTL},
+		{"duration", impersonateRequest{Reason: "ticket 42", DurationMinutes: 90}, false, 90 * time.Minute},
+		{"max", impersonateRequest{Reason: "ticket 42", DurationMinutes: 120}, false, auth.MaxImpersonationTTL},
+		{"too long", impersonateRequest{Reason: "ticket 42", DurationMinutes: 121}, true, 0},
+		{"negative", impersonateRequest{Reason: "ticket 42", DurationMinutes: -5}, true, 0},
+		{"no reason", impersonateRequest{Reason: "  "}, true, 0},
+		{"long reason", impersonateRequest{Reason: strings.Repeat("x", maxImpersonationReasonLen+1)}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			ttl, err := req.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if ttl != tt.ttl {
+				t.Errorf("ttl = %v, want %v", ttl, tt.ttl)
+			}
+			if req.Reason != "ticket 42" {
+				t.Errorf("reason = %q, want trimmed", req.Reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/notify"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Workspace invitations let owners add people by email, before they have
+// an account. The invitee gets a link with a random token, signs in (or
+// registers) with the invited email and accepts, which makes them a member
+// with the invited role. Only the token's hash is stored.
+
+const (
+	invitationTokenPrefix = "inv_"
+	invitationTTL         = 7 * 24 * time.Hour
+)
+
+var invitationRoles = map[string]bool{"owner": true, "maintainer": true, "developer": true, "guest": true}
+
+type createInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (req *createInvitationRequest) validate() error {
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || !strings.Contains(req.Email, "@") || strings.ContainsAny(req.Email, " \r\n,;") {
+		return fmt.Errorf("a valid email is required")
+	}
+	if req.Role == "" {
+		req.Role = "developer"
+	}
+	if !invitationRoles[req.Role] {
+		return fmt.Errorf("invalid role %q", req.Role)
+	}
+	return nil
+}
+
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return invitationTokenPrefix + hex.EncodeToString(b), nil
+}
+
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// invitationStatus is open, accepted, revoked or expired.
+func invitationStatus(inv *db.WorkspaceInvitation, now time.Time) string {
+	switch {
+	case inv.AcceptedAt != nil:
+		return "accepted"
+	case inv.RevokedAt != nil:
+		return "revoked"
+	case !now.Before(inv.ExpiresAt):
+		return "expired"
+	}
+	return "open"
+}
+
+// POST /api/workspaces/{id}/invitations {"email": "...", "role": "developer"}
+// invites an email. Inviting it again replaces the open invitation.
+func (s *Server) handleCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	var req createInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil || ws == nil {
+		log.Printf("failed to get workspace %s: %v", wsID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if ws.ArchivedAt.Valid {
+		http.Error(w, "workspace is archived", http.StatusConflict)
+		return
+	}
+	if u, err := s.DB.GetUserByEmail(req.Email); err != nil {
+		log.Printf("failed to look up user %s: %v", req.Email, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	} else if u != nil {
+		if member, err := s.DB.IsWorkspaceMember(wsID, u.ID); err != nil {
+			log.Printf("failed to check membership of %s in %s: %v", u.ID, wsID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		} else if member {
+			http.Error(w, "already a member", http.StatusConflict)
+			return
+		}
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		log.Printf("failed to generate invitation token: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	inviterID := auth.UserIDFromContext(r.Context())
+	inv := &db.WorkspaceInvitation{
+		ID:          uuid.New().String(),
+		WorkspaceID: wsID,
+		Email:       req.Email,
+		Role:        req.Role,
+		InvitedBy:   inviterID,
+		ExpiresAt:   time.Now().Add(invitationTTL),
+	}
+	if err := s.DB.CreateWorkspaceInvitation(inv, hashInvitationToken(token)); err != nil {
+		log.Printf("failed to create invitation to %s: %v", wsID, err)
+		http.Error(w, "failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	acceptURL := requestOrigin(r) + "/invitations/" + token
+	emailed := false
+	if s.Notifier != nil {
+		inviter := inviterID
+		if u, err := s.DB.GetUserByID(inviterID); err == nil && u != nil {
+			inviter = u.Email
+		}
+		subject, body := invitationEmail(inviter, ws.Name, inv, acceptURL)
+		err := s.Notifier.Notify(r.Context(), []notify.Recipient{{Email: inv.Email}}, &notify.Notification{
+			Kind:    notify.KindInvitation,
+			Subject: subject,
+			Body:    body,
+			Data: map[string]interface{}{
+				"workspace_id":  wsID,
+				"invitation_id": inv.ID,
+				"role":          inv.Role,
+				"accept_url":    acceptURL,
+			},
+		})
+		if err != nil {
+			log.Printf("failed to send invitation %s: %v", inv.ID, err)
+		} else {
+			emailed = s.Notifier.HasChannel(notify.ChannelEmail)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invitation": inv,
+		"token":      token,
+		"accept_url": acceptURL,
+		"emailed":    emailed,
+	})
+}
+
+// invitationEmail renders the email of an invitation.
+func invitationEmail(inviter, workspace string, inv *db.WorkspaceInvitation, acceptURL string) (subject, body string) {
+	subject = fmt.Sprintf("You're invited to the workspace %s", workspace)
+	body = fmt.Sprintf("%s invited you to join the workspace %s as %s.\n\n"+
+		"Sign in with %s and accept the invitation:\n%s\n\n"+
+		"The invitation expires on %s.\n",
+		inviter, workspace, inv.Role, inv.Email, acceptURL, inv.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	return subject, body
+}
+
+// GET /api/workspaces/{id}/invitations lists the invitations not yet
+// accepted or revoked.
+func (s *Server) handleListInvitations(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	invs, err := s.DB.ListWorkspaceInvitations(wsID)
+	if err != nil {
+		log.Printf("failed to list invitations of %s: %v", wsID, err)
+		http.Error(w, "failed to list invitations", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invs)
+}
+
+// DELETE /api/workspaces/{id}/invitations/{invitationId}
+func (s *Server) handleRevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	id := chi.URLParam(r, "invitationId")
+	found, err := s.DB.RevokeWorkspaceInvitation(wsID, id)
+	if err != nil {
+		log.Printf("failed to revoke invitation %s: %v", id, err)
+		http.Error(w, "failed to revoke invitation", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "invitation not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// invitationByToken returns the invitation of the request's token, or
+// answers 404.
+func (s *Server) invitationByToken(w http.ResponseWriter, r *http.Request) (*db.WorkspaceInvitation, bool) {
+	token := chi.URLParam(r, "token")
+	if !strings.HasPrefix(token, invitationTokenPrefix) {
+		http.Error(w, "invitation not found", http.StatusNotFound)
+		return nil, false
+	}
+	inv, err := s.DB.GetWorkspaceInvitationByToken(hashInvitationToken(token))
+	if err != nil {
+		log.Printf("failed to get invitation: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if inv == nil {
+		http.Error(w, "invitation not found", http.StatusNotFound)
+		return nil, false
+	}
+	return inv, true
+}
+
+// GET /api/invitations/{token} shows an invitation to the invitee before
+// they accept it.
+func (s *Server) handleGetInvitation(w http.ResponseWriter, r *http.Request) {
+	inv, ok := s.invitationByToken(w, r)
+	if !ok {
+		return
+	}
+	resp := map[string]interface{}{
+		"workspace_id": inv.WorkspaceID,
+		"email":        inv.Email,
+		"role":         inv.Role,
+		"expires_at":   inv.ExpiresAt,
+		"status":       invitationStatus(inv, time.Now()),
+	}
+	if ws, err := s.DB.GetWorkspace(inv.WorkspaceID); err == nil && ws != nil {
+		resp["workspace_name"] = ws.Name
+	}
+	if inv.InvitedBy != "" {
+		if u, err := s.DB.GetUserByID(inv.InvitedBy); err == nil && u != nil {
+			resp["invited_by"] = u.Email
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/invitations/{token}/accept adds the signed-in user to the
+// workspace. The user's email must be the invited one.
+func (s *Server) handleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	if refuseImpersonation(w, r) {
+		return
+	}
+	inv, ok := s.invitationByToken(w, r)
+	if !ok {
+		return
+	}
+	if st := invitationStatus(inv, time.Now()); st != "open" {
+		http.Error(w, "invitation is "+st, http.StatusGone)
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil || user == nil {
+		log.Printf("failed to get user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !strings.EqualFold(user.Email, inv.Email) {
+		http.Error(w, "the invitation is for another email address", http.StatusForbidden)
+		return
+	}
+	accepted, err := s.DB.AcceptWorkspaceInvitation(inv.ID, userID)
+	if err != nil {
+		log.Printf("failed to accept invitation %s: %v", inv.ID, err)
+		http.Error(w, "failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	if !accepted {
+		http.Error(w, "invitation is no longer open", http.StatusGone)
+		return
+	}
+	role, err := s.DB.GetWorkspaceMemberRole(inv.WorkspaceID, userID)
+	if err != nil {
+		log.Printf("failed to get role of %s in %s: %v", userID, inv.WorkspaceID, err)
+	}
+	log.Printf("user %s accepted invitation %s to workspace %s", userID, inv.ID, inv.WorkspaceID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace_id": inv.WorkspaceID,
+		"role":         role,
+	})
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestCreateInvitationRequestValidate(t *testing.T) {
+	req := createInvitationRequest{Email: " dev@example.com "}
+	if err := req.validate(); err != nil || req.Email != "dev@example.com" || req.Role != "developer" {
+		t.Errorf("validate() = %v, request %+v", err, req)
+	}
+	for _, bad := range []createInvitationRequest{
+		{Email: ""},
+		{Email: "nobody"},
+		{Email: "a@example.com, b@example.com"},
+		{Email: "a@example.com\r\nBcc: b@example.com"},
+		{Email: "a@example.com", Role: "admin"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestInvitationStatus(t *testing.T) {
+	now := time.Now()
+	inv := &db.WorkspaceInvitation{ExpiresAt: now.Add(time.Hour)}
+	if got := invitationStatus(inv, now); got != "open" {
+		t.Errorf("status = %q, want open", got)
+	}
+	if got := invitationStatus(inv, now.Add(2*time.Hour)); got != "expired" {
+		t.Errorf("status = %q, want expired", got)
+	}
+	inv.RevokedAt = &now
+	if got := invitationStatus(inv, now); got != "revoked" {
+		t.Errorf("status = %q, want revoked", got)
+	}
+	inv.AcceptedAt = &now
+	if got := invitationStatus(inv, now); got != "accepted" {
+		t.Errorf("status = %q, want accepted", got)
+	}
+}
+
+func TestInvitationToken(t *testing.T) {
+	a, err := generateInvitationToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := generateInvitationToken()
+	if !strings.HasPrefix(a, invitationTokenPrefix) || a == b {
+		t.Errorf("tokens %q, %q", a, b)
+	}
+	if hashInvitationToken(a) == hashInvitationToken(b) || len(hashInvitationToken(a)) != 64 {
+		t.Error("bad token hash")
+	}
+}
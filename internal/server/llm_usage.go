@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LLM token usage is recorded by the LLM proxy per request, attributed to
+// the sandbox, its workspace and the user who created the sandbox. These
+// handlers expose rollups of it for billing and abuse detection.
+
+// usageQuery copies the usage filters of r (since, until, group_by) into a
+// query for the LLM proxy's /internal/usage.
+func usageQuery(r *http.Request, filters ...string) url.Values {
+	q := url.Values{}
+	for _, k := range append([]string{"since", "until", "group_by"}, filters...) {
+		if v := r.URL.Query().Get(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+	return q
+}
+
+// GET /api/workspaces/{id}/usage?since=&until=&group_by=sandbox|user&user_id=
+// returns the workspace's LLM token usage by provider and model, optionally
+// per sandbox or user.
+func (s *Server) handleWorkspaceUsage(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	if g := r.URL.Query().Get("group_by"); g != "" && g != "sandbox" && g != "user" {
+		http.Error(w, "group_by must be sandbox or user", http.StatusBadRequest)
+		return
+	}
+	if s.LLMProxyURL == "" {
+		http.Error(w, "llmproxy not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q := usageQuery(r, "user_id", "sandbox_id")
+	q.Set("workspace_id", wsID)
+	s.proxyLLMRequest(w, s.LLMProxyURL+"/internal/usage?"+q.Encode())
+}
+
+// GET /api/admin/usage?since=&until=&group_by=workspace|sandbox|user&workspace_id=&user_id=
+// returns LLM token usage across all workspaces.
+func (s *Server) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	if s.LLMProxyURL == "" {
+		http.Error(w, "llmproxy not configured", http.StatusServiceUnavailable)
+		return
+	}
+	q := usageQuery(r, "workspace_id", "user_id", "sandbox_id")
+	s.proxyLLMRequest(w, s.LLMProxyURL+"/internal/usage?"+q.Encode())
+}
+
+// pseudonymizeLLMUsage replaces an erased user's ID in the LLM proxy's
+// usage records by pseudonym.
+func (s *Server) pseudonymizeLLMUsage(userID, pseudonym string) error {
+	if s.LLMProxyURL == "" {
+		return nil
+	}
+	body, _ := json.Marshal(map[string]string{"pseudonym": pseudonym})
+	resp, err := http.Post(s.LLMProxyURL+"/internal/users/"+url.PathEscape(userID)+"/pseudonymize",
+		"application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("llmproxy returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsageQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/admin/usage?since=2026-01-01T00:00:00Z&group_by=user&workspace_id=ws-1&user_id=&other=x", nil)
+	got := usageQuery(r, "workspace_id", "user_id").Encode()
+	want := "group_by=user&since=2026-01-01T00%3A00%3A00Z&workspace_id=ws-1"
+	if got != want {
+		t.Errorf("usageQuery = %q, want %q", got, want)
+	}
+}
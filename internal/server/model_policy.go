@@ -0,0 +1,181 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Workspace model policy: which Anthropic models the workspace's sandboxes
+// may use and the default model. It is returned with proxy token validation
+// and enforced by the LLM proxy. Owners and admins configure it; members can
+// read it.
+
+// modelPolicy is the wire form of db.WorkspaceModelPolicy.
+type modelPolicy struct {
+	AllowedModels []string `json:"allowed_models"`
+	DefaultModel  string   `json:"default_model,omitempty"`
+	// OnDisallowed is "reject" (default) or "rewrite": replace a disallowed
+	// model with DefaultModel instead of failing the request.
+	OnDisallowed string `json:"on_disallowed,omitempty"`
+}
+
+const maxAllowedModels = 100
+
+func (p *modelPolicy) validate() error {
+	if len(p.AllowedModels) > maxAllowedModels {
+		return fmt.Errorf("too many allowed_models (max %d)", maxAllowedModels)
+	}
+	for _, m := range p.AllowedModels {
+		if strings.TrimSpace(m) == "" {
+			return fmt.Errorf("allowed_models must not contain empty names")
+		}
+		if _, err := path.Match(m, ""); err != nil {
+			return fmt.Errorf("invalid allowed_models pattern %q", m)
+		}
+	}
+	switch p.OnDisallowed {
+	case "":
+		p.OnDisallowed = "reject"
+	case "reject":
+	case "rewrite":
+		if p.DefaultModel == "" {
+			return fmt.Errorf("on_disallowed rewrite requires default_model")
+		}
+	default:
+		return fmt.Errorf("on_disallowed must be reject or rewrite")
+	}
+	if p.DefaultModel != "" && !modelAllowed(p.AllowedModels, p.DefaultModel) {
+		return fmt.Errorf("default_model %s is not in allowed_models", p.DefaultModel)
+	}
+	if len(p.AllowedModels) == 0 && p.DefaultModel == "" {
+		return fmt.Errorf("set allowed_models and/or default_model")
+	}
+	return nil
+}
+
+// modelAllowed reports whether model matches one of the patterns; no
+// patterns allow every model.
+func modelAllowed(patterns []string, model string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func modelPolicyFromDB(p *db.WorkspaceModelPolicy) *modelPolicy {
+	if p == nil {
+		return nil
+	}
+	return &modelPolicy{AllowedModels: p.AllowedModels, DefaultModel: p.DefaultModel, OnDisallowed: p.OnDisallowed}
+}
+
+// GET /api/workspaces/{id}/model-policy
+func (s *Server) handleGetWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	s.writeWorkspaceModelPolicy(w, wsID)
+}
+
+// PUT /api/workspaces/{id}/model-policy
+func (s *Server) handleSetWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	s.setWorkspaceModelPolicy(w, r, wsID)
+}
+
+// DELETE /api/workspaces/{id}/model-policy
+func (s *Server) handleDeleteWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	s.deleteWorkspaceModelPolicy(w, wsID)
+}
+
+func (s *Server) handleAdminGetWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request) {
+	s.writeWorkspaceModelPolicy(w, chi.URLParam(r, "id"))
+}
+
+func (s *Server) handleAdminSetWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil {
+		log.Printf("admin: failed to get workspace %s: %v", wsID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+	s.setWorkspaceModelPolicy(w, r, wsID)
+}
+
+func (s *Server) handleAdminDeleteWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request) {
+	s.deleteWorkspaceModelPolicy(w, chi.URLParam(r, "id"))
+}
+
+func (s *Server) writeWorkspaceModelPolicy(w http.ResponseWriter, wsID string) {
+	p, err := s.DB.GetWorkspaceModelPolicy(wsID)
+	if err != nil {
+		log.Printf("failed to get model policy of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to get model policy", http.StatusInternalServerError)
+		return
+	}
+	resp := map[string]interface{}{
+		"enabled":        p != nil,
+		"allowed_models": []string{},
+	}
+	if p != nil {
+		resp["allowed_models"] = p.AllowedModels
+		resp["default_model"] = p.DefaultModel
+		resp["on_disallowed"] = p.OnDisallowed
+		resp["updated_at"] = p.UpdatedAt.Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) setWorkspaceModelPolicy(w http.ResponseWriter, r *http.Request, wsID string) {
+	var req modelPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.DB.SetWorkspaceModelPolicy(wsID, req.AllowedModels, req.DefaultModel, req.OnDisallowed); err != nil {
+		log.Printf("failed to set model policy of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to set model policy", http.StatusInternalServerError)
+		return
+	}
+	s.writeWorkspaceModelPolicy(w, wsID)
+}
+
+func (s *Server) deleteWorkspaceModelPolicy(w http.ResponseWriter, wsID string) {
+	if err := s.DB.DeleteWorkspaceModelPolicy(wsID); err != nil {
+		log.Printf("failed to delete model policy of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to delete model policy", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
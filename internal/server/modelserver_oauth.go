@@ -68,7 +68,7 @@ func (s *Server) handleModelserverConnect(w http.ResponseWriter, r *http.Request
 		Value:    state,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   auth.SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   modelserverCookieMaxAge,
 	})
@@ -77,7 +77,7 @@ func (s *Server) handleModelserverConnect(w http.ResponseWriter, r *http.Request
 		Value:    wsID,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   auth.SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   modelserverCookieMaxAge,
 	})
@@ -86,7 +86,7 @@ func (s *Server) handleModelserverConnect(w http.ResponseWriter, r *http.Request
 		Value:    codeVerifier,
 		Path:     "/",
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   auth.SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   modelserverCookieMaxAge,
 	})
@@ -137,7 +137,7 @@ func (s *Server) handleModelserverCallback(w http.ResponseWriter, r *http.Reques
 			Path:     "/",
 			MaxAge:   -1,
 			HttpOnly: true,
-			Secure:   true,
+			Secure:   auth.SecureCookies(),
 		})
 	}
 
@@ -11,6 +11,8 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/sync/singleflight"
+
+	"github.com/agentserver/agentserver/internal/clock"
 )
 
 var modelserverTokenRefresh singleflight.Group
@@ -27,7 +29,7 @@ func (s *Server) getValidModelserverToken(workspaceID string) (token string, exp
 	}
 
 	// If token is still valid with 60s buffer, return it immediately.
-	if time.Now().Add(60 * time.Second).Before(conn.TokenExpiresAt) {
+	if clock.Or(s.Clock).Now().Add(60 * time.Second).Before(conn.TokenExpiresAt) {
 		return conn.AccessToken, conn.TokenExpiresAt, nil
 	}
 
@@ -45,7 +47,7 @@ func (s *Server) getValidModelserverToken(workspaceID string) (token string, exp
 		if fresh == nil {
 			return nil, fmt.Errorf("no modelserver connection for workspace %s", workspaceID)
 		}
-		if time.Now().Add(60 * time.Second).Before(fresh.TokenExpiresAt) {
+		if clock.Or(s.Clock).Now().Add(60 * time.Second).Before(fresh.TokenExpiresAt) {
 			return result{token: fresh.AccessToken, expiresAt: fresh.TokenExpiresAt}, nil
 		}
 
@@ -72,7 +74,7 @@ func (s *Server) getValidModelserverToken(workspaceID string) (token string, exp
 			return nil, fmt.Errorf("decode refresh token response: %w", err)
 		}
 
-		newExpiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+		newExpiresAt := clock.Or(s.Clock).Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
 
 		// Handle refresh token rotation: use new one if returned, otherwise keep the old one.
 		newRefreshToken := tokenResp.RefreshToken
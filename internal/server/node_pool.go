@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// workspaceNodePool returns the dedicated node pool for new sandboxes of a
+// workspace, or nil if they may run on any node.
+func (s *Server) workspaceNodePool(workspaceID string) (*process.NodePool, error) {
+	np, err := s.DB.GetWorkspaceNodePool(workspaceID)
+	if err != nil || np == nil {
+		return nil, err
+	}
+	pool := &process.NodePool{NodeSelector: np.NodeSelector}
+	for _, t := range np.Tolerations {
+		pool.Tolerations = append(pool.Tolerations, process.Toleration{
+			Key: t.Key, Operator: t.Operator, Value: t.Value, Effect: t.Effect,
+		})
+	}
+	return pool, nil
+}
+
+func (s *Server) handleAdminGetWorkspaceNodePool(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "id")
+
+	np, err := s.DB.GetWorkspaceNodePool(workspaceID)
+	if err != nil {
+		log.Printf("admin: failed to get node pool: %v", err)
+		http.Error(w, "failed to get node pool", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"enabled":       np != nil,
+		"node_selector": map[string]string{},
+		"tolerations":   []db.NodeToleration{},
+	}
+	if np != nil {
+		resp["node_selector"] = np.NodeSelector
+		resp["tolerations"] = np.Tolerations
+		resp["updated_at"] = np.UpdatedAt.Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminSetWorkspaceNodePool binds a workspace to a dedicated node pool.
+// It applies to sandboxes created afterwards; existing sandboxes keep their
+// placement until they are recreated.
+func (s *Server) handleAdminSetWorkspaceNodePool(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "id")
+
+	if s.NamespaceManager == nil {
+		http.Error(w, "node pools require the Kubernetes backend", http.StatusNotImplemented)
+		return
+	}
+
+	var req process.NodePool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.DB.GetWorkspace(workspaceID)
+	if err != nil {
+		log.Printf("admin: failed to get workspace %s: %v", workspaceID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+
+	tolerations := make([]db.NodeToleration, len(req.Tolerations))
+	for i, t := range req.Tolerations {
+		tolerations[i] = db.NodeToleration{Key: t.Key, Operator: t.Operator, Value: t.Value, Effect: t.Effect}
+	}
+	if err := s.DB.SetWorkspaceNodePool(workspaceID, req.NodeSelector, tolerations); err != nil {
+		log.Printf("admin: failed to set node pool: %v", err)
+		http.Error(w, "failed to set node pool", http.StatusInternalServerError)
+		return
+	}
+
+	if req.NodeSelector == nil {
+		req.NodeSelector = map[string]string{}
+	}
+	if req.Tolerations == nil {
+		req.Tolerations = []process.Toleration{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":       true,
+		"node_selector": req.NodeSelector,
+		"tolerations":   req.Tolerations,
+	})
+}
+
+func (s *Server) handleAdminDeleteWorkspaceNodePool(w http.ResponseWriter, r *http.Request) {
+	workspaceID := chi.URLParam(r, "id")
+
+	if err := s.DB.DeleteWorkspaceNodePool(workspaceID); err != nil {
+		log.Printf("admin: failed to delete node pool: %v", err)
+		http.Error(w, "failed to delete node pool", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/notify"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// Notifications: besides invitations, Notifier tells users that their
+// sandbox is about to be paused for idleness, that their workspace hit a
+// quota, and that an agent went offline. Users turn kinds off per channel
+// under /api/users/me/notifications.
+
+// quotaNoticeInterval is how often the owners of a workspace are notified
+// of the same quota being hit.
+const quotaNoticeInterval = 24 * time.Hour
+
+const notifyTimeout = 30 * time.Second
+
+// sendNotification delivers n in the background, so a slow mail relay or
+// webhook never holds up its caller.
+func (s *Server) sendNotification(to []notify.Recipient, n *notify.Notification) {
+	if s.Notifier == nil || len(to) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		if err := s.Notifier.Notify(ctx, to, n); err != nil {
+			log.Printf("notify: %s: %v", n.Kind, err)
+		}
+	}()
+}
+
+// workspaceOwnerRecipients returns the owners of a workspace.
+func (s *Server) workspaceOwnerRecipients(wsID string) ([]notify.Recipient, error) {
+	members, err := s.DB.ListWorkspaceMembers(wsID)
+	if err != nil {
+		return nil, err
+	}
+	var to []notify.Recipient
+	for _, m := range members {
+		if m.Role != "owner" {
+			continue
+		}
+		u, err := s.DB.GetUserByID(m.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil && u.DisabledAt == nil {
+			to = append(to, notify.Recipient{UserID: u.ID, Email: u.Email})
+		}
+	}
+	return to, nil
+}
+
+// sandboxRecipients returns who to notify about a sandbox: its creator
+// while they are a member of its workspace, otherwise the workspace
+// owners.
+func (s *Server) sandboxRecipients(wsID, createdBy string) ([]notify.Recipient, error) {
+	if createdBy != "" {
+		u, err := s.DB.GetUserByID(createdBy)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil && u.DisabledAt == nil {
+			if member, err := s.DB.IsWorkspaceMember(wsID, u.ID); err != nil {
+				return nil, err
+			} else if member {
+				return []notify.Recipient{{UserID: u.ID, Email: u.Email}}, nil
+			}
+		}
+	}
+	return s.workspaceOwnerRecipients(wsID)
+}
+
+// NotifyIdlePause warns that an idle sandbox will be paused at pauseAt. It
+// is the idle watcher's pause warning callback.
+func (s *Server) NotifyIdlePause(sbx *db.Sandbox, pauseAt time.Time) {
+	if s.Notifier == nil {
+		return
+	}
+	to, err := s.sandboxRecipients(sbx.WorkspaceID, sbx.CreatedBy.String)
+	if err != nil {
+		log.Printf("notify: recipients of idle sandbox %s: %v", sbx.ID, err)
+		return
+	}
+	subject, body := idlePauseNotice(sbx.Name, pauseAt)
+	s.sendNotification(to, &notify.Notification{
+		Kind:    notify.KindIdlePause,
+		Subject: subject,
+		Body:    body,
+		Data: map[string]interface{}{
+			"workspace_id": sbx.WorkspaceID,
+			"sandbox_id":   sbx.ID,
+			"pause_at":     pauseAt.UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func idlePauseNotice(sandbox string, pauseAt time.Time) (subject, body string) {
+	subject = "Idle sandbox " + sandbox + " will be paused"
+	body = fmt.Sprintf("The sandbox %s has been idle and will be paused at %s.\n\n"+
+		"Use it, or pin it, to keep it running. A paused sandbox keeps its data and resumes when opened.\n",
+		sandbox, pauseAt.UTC().Format("2006-01-02 15:04 MST"))
+	return subject, body
+}
+
+// notifyQuotaExceeded tells the owners of a workspace that a request was
+// refused because of a quota, at most once per quota and day.
+func (s *Server) notifyQuotaExceeded(wsID, reason string, data map[string]interface{}) {
+	if s.Notifier == nil || !s.takeQuotaNotice(wsID+"/"+reason, time.Now()) {
+		return
+	}
+	to, err := s.workspaceOwnerRecipients(wsID)
+	if err != nil {
+		log.Printf("notify: owners of workspace %s: %v", wsID, err)
+		return
+	}
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil || ws == nil {
+		log.Printf("notify: workspace %s: %v", wsID, err)
+		return
+	}
+	details := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		details[k] = v
+	}
+	details["workspace_id"] = wsID
+	subject, body := quotaNotice(ws.Name, reason, data)
+	s.sendNotification(to, &notify.Notification{
+		Kind:    notify.KindQuotaExceeded,
+		Subject: subject,
+		Body:    body,
+		Data:    details,
+	})
+}
+
+// takeQuotaNotice reports whether the notice key is due, and if so records
+// it as sent at now.
+func (s *Server) takeQuotaNotice(key string, now time.Time) bool {
+	s.quotaNoticesMu.Lock()
+	defer s.quotaNoticesMu.Unlock()
+	if last, ok := s.quotaNotices[key]; ok && now.Sub(last) < quotaNoticeInterval {
+		return false
+	}
+	if s.quotaNotices == nil {
+		s.quotaNotices = make(map[string]time.Time)
+	}
+	s.quotaNotices[key] = now
+	return true
+}
+
+func quotaNotice(workspace, reason string, data map[string]interface{}) (subject, body string) {
+	subject = "Workspace " + workspace + " reached a quota"
+	var limit string
+	switch reason {
+	case "quota_exceeded":
+		limit = fmt.Sprintf("its sandbox limit (%v/%v)", data["sandboxes"], data["max_sandboxes"])
+		if t, ok := data["type"]; ok {
+			limit = fmt.Sprintf("its limit of %v sandboxes (%v/%v)", t, data["sandboxes"], data["max_sandboxes"])
+		}
+	case "resource_budget_exceeded":
+		limit = "its CPU and memory budget"
+	default:
+		limit = "a quota (" + reason + ")"
+	}
+	body = fmt.Sprintf("The workspace %s reached %s, so a request was refused.\n\n"+
+		"Delete sandboxes you no longer need, or ask an admin to raise the quota.\n",
+		workspace, limit)
+	return subject, body
+}
+
+// NotifyAgentsOffline alerts about agents that went offline. Agents whose
+// sandbox was paused or stopped are expected to go offline and are left
+// out. It is the agent health monitor's OnOffline callback.
+func (s *Server) NotifyAgentsOffline(cards []db.AgentCard) {
+	if s.Notifier == nil {
+		return
+	}
+	for _, c := range cards {
+		sbx, err := s.DB.GetSandbox(c.SandboxID)
+		if err != nil || sbx == nil {
+			continue
+		}
+		if !sbx.IsLocal && sbx.Status != sbxstore.StatusRunning {
+			continue
+		}
+		to, err := s.sandboxRecipients(sbx.WorkspaceID, sbx.CreatedBy.String)
+		if err != nil {
+			log.Printf("notify: recipients of agent %s: %v", c.SandboxID, err)
+			continue
+		}
+		name := c.DisplayName
+		if name == "" {
+			name = sbx.Name
+		}
+		subject, body := agentOfflineNotice(name, sbx.Name, sbx.LastHeartbeatAt.Time, sbx.LastHeartbeatAt.Valid)
+		s.sendNotification(to, &notify.Notification{
+			Kind:    notify.KindAgentOffline,
+			Subject: subject,
+			Body:    body,
+			Data: map[string]interface{}{
+				"workspace_id": sbx.WorkspaceID,
+				"sandbox_id":   sbx.ID,
+				"agent_type":   c.AgentType,
+			},
+		})
+	}
+}
+
+func agentOfflineNotice(agent, sandbox string, lastHeartbeat time.Time, seen bool) (subject, body string) {
+	subject = "Agent " + agent + " is offline"
+	last := "It never sent a heartbeat."
+	if seen {
+		last = "Its last heartbeat was at " + lastHeartbeat.UTC().Format("2006-01-02 15:04 MST") + "."
+	}
+	body = fmt.Sprintf("The agent %s of the sandbox %s stopped sending heartbeats. %s\n\n"+
+		"Check that the sandbox, or the machine of a local agent, is up.\n",
+		agent, sandbox, last)
+	return subject, body
+}
+
+// notificationPreferences lists every kind users can turn off on every
+// channel, with the user's settings applied.
+func notificationPreferences(set []db.NotificationPreference) []db.NotificationPreference {
+	var prefs []db.NotificationPreference
+	for _, kind := range notify.PreferenceKinds {
+		for _, ch := range notify.Channels {
+			p := db.NotificationPreference{Kind: kind, Channel: ch, Enabled: true}
+			for _, sp := range set {
+				if sp.Kind == kind && sp.Channel == ch {
+					p = sp
+				}
+			}
+			prefs = append(prefs, p)
+		}
+	}
+	return prefs
+}
+
+func validateNotificationPreferences(prefs []db.NotificationPreference) error {
+	if len(prefs) == 0 {
+		return fmt.Errorf("no preferences given")
+	}
+	for _, p := range prefs {
+		if !slices.Contains(notify.PreferenceKinds, p.Kind) {
+			return fmt.Errorf("unknown notification kind %q (want %s)", p.Kind, strings.Join(notify.PreferenceKinds, ", "))
+		}
+		if !slices.Contains(notify.Channels, p.Channel) {
+			return fmt.Errorf("unknown channel %q (want %s)", p.Channel, strings.Join(notify.Channels, ", "))
+		}
+	}
+	return nil
+}
+
+// GET /api/users/me/notifications returns the user's notification
+// preferences, one per kind and channel.
+func (s *Server) handleGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+	set, err := s.DB.ListNotificationPreferences(userID)
+	if err != nil {
+		log.Printf("failed to list notification preferences of %s: %v", userID, err)
+		http.Error(w, "failed to get notification preferences", http.StatusInternalServerError)
+		return
+	}
+	s.writeNotificationPreferences(w, set)
+}
+
+// PUT /api/users/me/notifications
+// {"preferences": [{"kind": "idle_pause", "channel": "email", "enabled": false}]}
+// sets the given preferences and leaves the others.
+func (s *Server) handleSetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	if refuseImpersonation(w, r) {
+		return
+	}
+	var req struct {
+		Preferences []db.NotificationPreference `json:"preferences"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateNotificationPreferences(req.Preferences); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	if err := s.DB.SetNotificationPreferences(userID, req.Preferences); err != nil {
+		log.Printf("failed to set notification preferences of %s: %v", userID, err)
+		http.Error(w, "failed to set notification preferences", http.StatusInternalServerError)
+		return
+	}
+	set, err := s.DB.ListNotificationPreferences(userID)
+	if err != nil {
+		log.Printf("failed to list notification preferences of %s: %v", userID, err)
+		http.Error(w, "failed to get notification preferences", http.StatusInternalServerError)
+		return
+	}
+	s.writeNotificationPreferences(w, set)
+}
+
+func (s *Server) writeNotificationPreferences(w http.ResponseWriter, set []db.NotificationPreference) {
+	channels := []string{}
+	for _, ch := range notify.Channels {
+		if s.Notifier != nil && s.Notifier.HasChannel(ch) {
+			channels = append(channels, ch)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"preferences": notificationPreferences(set),
+		// Channels the server delivers over; the others have no effect.
+		"channels": channels,
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestTakeQuotaNotice(t *testing.T) {
+	s := &Server{}
+	now := time.Now()
+	if !s.takeQuotaNotice("ws1/quota_exceeded", now) {
+		t.Fatal("first notice not due")
+	}
+	if s.takeQuotaNotice("ws1/quota_exceeded", now.Add(time.Hour)) {
+		t.Error("repeated notice within a day is due")
+	}
+	if !s.takeQuotaNotice("ws1/resource_budget_exceeded", now.Add(time.Hour)) {
+		t.Error("notice of another quota not due")
+	}
+	if !s.takeQuotaNotice("ws1/quota_exceeded", now.Add(quotaNoticeInterval)) {
+		t.Error("notice not due again after a day")
+	}
+}
+
+func TestQuotaNotice(t *testing.T) {
+	_, body := quotaNotice("team", "quota_exceeded", map[string]interface{}{"sandboxes": 3, "max_sandboxes": 3, "type": "jupyter"})
+	if !strings.Contains(body, "its limit of jupyter sandboxes (3/3)") {
+		t.Errorf("body = %q", body)
+	}
+	subject, body := quotaNotice("team", "resource_budget_exceeded", nil)
+	if subject != "Workspace team reached a quota" || !strings.Contains(body, "CPU and memory budget") {
+		t.Errorf("notice = %q, %q", subject, body)
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	prefs := notificationPreferences([]db.NotificationPreference{{Kind: "agent_offline", Channel: "webhook", Enabled: false}})
+	if len(prefs) != 6 {
+		t.Fatalf("got %d preferences, want 3 kinds x 2 channels", len(prefs))
+	}
+	for _, p := range prefs {
+		if want := !(p.Kind == "agent_offline" && p.Channel == "webhook"); p.Enabled != want {
+			t.Errorf("%s/%s enabled = %v, want %v", p.Kind, p.Channel, p.Enabled, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		prefs []db.NotificationPreference
+		ok    bool
+	}{
+		{[]db.NotificationPreference{{Kind: "idle_pause", Channel: "email"}}, true},
+		{nil, false},
+		{[]db.NotificationPreference{{Kind: "invitation", Channel: "email"}}, false},
+		{[]db.NotificationPreference{{Kind: "idle_pause", Channel: "sms"}}, false},
+	} {
+		if err := validateNotificationPreferences(tc.prefs); (err == nil) != tc.ok {
+			t.Errorf("validateNotificationPreferences(%+v) = %v", tc.prefs, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/operator"
+)
+
+// OperatorBackend returns an operator.Backend acting as userID, who becomes
+// the owner of every workspace the operator creates. Operations go through
+// the REST handlers, so quotas and validation apply as for API callers.
+func (s *Server) OperatorBackend(userID string) operator.Backend {
+	return &operatorBackend{s: s, userID: userID}
+}
+
+type operatorBackend struct {
+	s      *Server
+	userID string
+}
+
+func (b *operatorBackend) call(ctx context.Context, h http.HandlerFunc, method string, params map[string]string, body, out interface{}) error {
+	err := b.s.callREST(withRESTCaller(ctx, b.userID, ""), h, method, params, body, out)
+	var re *restError
+	if errors.As(err, &re) && re.Code == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", operator.ErrNotFound, re.Message)
+	}
+	return err
+}
+
+func (b *operatorBackend) CreateWorkspace(ctx context.Context, name string) (string, error) {
+	var resp workspaceResponse
+	if err := b.call(ctx, b.s.handleCreateWorkspace, http.MethodPost, nil, map[string]string{"name": name}, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (b *operatorBackend) RenameWorkspace(ctx context.Context, id, name string) error {
+	return b.call(ctx, b.s.handleUpdateWorkspace, http.MethodPatch, map[string]string{"id": id}, map[string]string{"name": name}, nil)
+}
+
+func (b *operatorBackend) DeleteWorkspace(ctx context.Context, id string) error {
+	ws, err := b.s.DB.GetWorkspace(id)
+	if err != nil {
+		return err
+	}
+	if ws == nil {
+		return operator.ErrNotFound
+	}
+	return b.call(ctx, b.s.handleDeleteWorkspace, http.MethodDelete, map[string]string{"id": id}, nil, nil)
+}
+
+func (b *operatorBackend) SetMember(ctx context.Context, workspaceID, email, role string) error {
+	user, err := b.s.Auth.GetUserByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	current, err := b.s.DB.GetWorkspaceMemberRole(workspaceID, user.ID)
+	if err != nil {
+		return err
+	}
+	switch current {
+	case role:
+		return nil
+	case "":
+		return b.call(ctx, b.s.handleAddMember, http.MethodPost, map[string]string{"id": workspaceID},
+			map[string]string{"email": email, "role": role}, nil)
+	default:
+		return b.call(ctx, b.s.handleUpdateMemberRole, http.MethodPut, map[string]string{"id": workspaceID, "userId": user.ID},
+			map[string]string{"role": role}, nil)
+	}
+}
+
+func (b *operatorBackend) CreateSandbox(ctx context.Context, workspaceID string, req operator.CreateSandboxRequest) (*operator.Sandbox, error) {
+	body := map[string]interface{}{
+		"name":     req.Name,
+		"type":     req.Type,
+		"browser":  req.Browser,
+		"metadata": map[string]interface{}{"k8s_claim": req.Source},
+	}
+	if req.CPU != 0 {
+		body["cpu"] = req.CPU
+	}
+	if req.Memory != 0 {
+		body["memory"] = req.Memory
+	}
+	var resp sandboxResponse
+	if err := b.call(ctx, b.s.handleCreateSandbox, http.MethodPost, map[string]string{"wid": workspaceID}, body, &resp); err != nil {
+		return nil, err
+	}
+	return toOperatorSandbox(resp), nil
+}
+
+func (b *operatorBackend) GetSandbox(ctx context.Context, id string) (*operator.Sandbox, error) {
+	var resp sandboxResponse
+	if err := b.call(ctx, b.s.handleGetSandbox, http.MethodGet, map[string]string{"id": id}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return toOperatorSandbox(resp), nil
+}
+
+func (b *operatorBackend) DeleteSandbox(ctx context.Context, id string) error {
+	return b.call(ctx, b.s.handleDeleteSandbox, http.MethodDelete, map[string]string{"id": id}, nil, nil)
+}
+
+func (b *operatorBackend) PauseSandbox(ctx context.Context, id string) error {
+	return b.call(ctx, b.s.handlePauseSandbox, http.MethodPost, map[string]string{"id": id}, nil, nil)
+}
+
+func (b *operatorBackend) ResumeSandbox(ctx context.Context, id string) error {
+	return b.call(ctx, b.s.handleResumeSandbox, http.MethodPost, map[string]string{"id": id}, nil, nil)
+}
+
+// toOperatorSandbox converts a REST sandbox. URLs are built without a
+// session token, so the "/auth?token=" login suffix is dropped.
+func toOperatorSandbox(r sandboxResponse) *operator.Sandbox {
+	sbx := &operator.Sandbox{ID: r.ID, Status: r.Status, StatusMessage: r.StatusMessage}
+	for _, u := range []string{r.OpencodeURL, r.OpenclawURL, r.ClaudeCodeURL, r.JupyterURL, r.CustomURL} {
+		if u != "" {
+			sbx.URL = strings.TrimSuffix(u, "/auth?token=")
+			break
+		}
+	}
+	return sbx
+}
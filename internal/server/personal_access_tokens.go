@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Personal access tokens let CI jobs and CLIs call the API as a user with
+// "Authorization: Bearer pat_...", without a browser login. auth.Middleware
+// accepts them and checks their read/write scope; requireAdmin checks the
+// admin scope. Tokens can't manage tokens, so a leaked one can't outlive
+// its expiry.
+
+const (
+	defaultPATLifetime = 30 * 24 * time.Hour
+	maxPATLifetime     = 365 * 24 * time.Hour
+	// maxActivePATs bounds a user's unexpired, unrevoked tokens.
+	maxActivePATs = 50
+	maxPATNameLen = 100
+)
+
+var patScopes = []string{db.TokenScopeRead, db.TokenScopeWrite, db.TokenScopeAdmin}
+
+type createPATRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// validate checks the request, normalizes its scopes and returns the
+// token's lifetime.
+func (req *createPATRequest) validate(isAdmin bool) (time.Duration, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return 0, fmt.Errorf("name is required")
+	}
+	if utf8.RuneCountInString(req.Name) > maxPATNameLen {
+		return 0, fmt.Errorf("name must be at most %d characters", maxPATNameLen)
+	}
+	if len(req.Scopes) == 0 {
+		return 0, fmt.Errorf("scopes is required")
+	}
+	for _, sc := range req.Scopes {
+		if !slices.Contains(patScopes, sc) {
+			return 0, fmt.Errorf("unknown scope %q; scopes are %s", sc, strings.Join(patScopes, ", "))
+		}
+	}
+	if slices.Contains(req.Scopes, db.TokenScopeAdmin) && !isAdmin {
+		return 0, fmt.Errorf("only admins can create tokens with the admin scope")
+	}
+	var scopes []string
+	for _, sc := range patScopes {
+		if slices.Contains(req.Scopes, sc) {
+			scopes = append(scopes, sc)
+		}
+	}
+	req.Scopes = scopes
+
+	lifetime := defaultPATLifetime
+	if req.ExpiresInDays != 0 {
+		lifetime = time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	}
+	if lifetime <= 0 || lifetime > maxPATLifetime {
+		return 0, fmt.Errorf("expires_in_days must be between 1 and %d", int(maxPATLifetime/(24*time.Hour)))
+	}
+	return lifetime, nil
+}
+
+// refusePAT answers 403 if the request was authenticated with a personal
+// access token rather than a login session.
+func refusePAT(w http.ResponseWriter, r *http.Request) bool {
+	if auth.PATFromContext(r.Context()) == nil {
+		return false
+	}
+	http.Error(w, "personal access tokens can't manage tokens; sign in instead", http.StatusForbidden)
+	return true
+}
+
+// POST /api/users/me/tokens creates a personal access token. The token is
+// returned once, in "token".
+func (s *Server) handleCreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	if refusePAT(w, r) || refuseImpersonation(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	user, err := s.Auth.GetUserByID(userID)
+	if err != nil || user == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	var req createPATRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	lifetime, err := req.validate(user.Role == "admin")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	active, err := s.DB.CountActivePersonalAccessTokens(userID)
+	if err != nil {
+		log.Printf("failed to count tokens of %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if active >= maxActivePATs {
+		http.Error(w, fmt.Sprintf("at most %d active tokens; revoke one first", maxActivePATs), http.StatusConflict)
+		return
+	}
+
+	token, prefix, err := auth.GeneratePAT()
+	if err != nil {
+		log.Printf("failed to generate personal access token: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	t := &db.PersonalAccessToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		Scopes:    req.Scopes,
+		ExpiresAt: time.Now().Add(lifetime),
+	}
+	if err := s.DB.CreatePersonalAccessToken(t, auth.HashPAT(token)); err != nil {
+		log.Printf("failed to create personal access token for %s: %v", userID, err)
+		http.Error(w, "failed to create token", http.StatusInternalServerError)
+		return
+	}
+	severity := SecuritySeverityInfo
+	if t.HasScope(db.TokenScopeAdmin) {
+		severity = SecuritySeverityWarning
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventTokenCreated,
+		Severity: severity,
+		TargetID: t.ID,
+		Details:  map[string]interface{}{"name": t.Name, "scopes": t.Scopes, "expires_at": t.ExpiresAt},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		*db.PersonalAccessToken
+		Token string `json:"token"`
+	}{t, token})
+}
+
+// GET /api/users/me/tokens lists the user's personal access tokens,
+// newest first, without their secrets.
+func (s *Server) handleListPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	if refusePAT(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	tokens, err := s.DB.ListPersonalAccessTokens(userID)
+	if err != nil {
+		log.Printf("failed to list tokens of %s: %v", userID, err)
+		http.Error(w, "failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// DELETE /api/users/me/tokens/{tokenID} revokes a personal access token.
+func (s *Server) handleRevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	if refusePAT(w, r) || refuseImpersonation(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	id := chi.URLParam(r, "tokenID")
+	ok, err := s.DB.RevokePersonalAccessToken(userID, id)
+	if err != nil {
+		log.Printf("failed to revoke token %s of %s: %v", id, userID, err)
+		http.Error(w, "failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "token not found", http.StatusNotFound)
+		return
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventTokenRevoked,
+		Severity: SecuritySeverityInfo,
+		TargetID: id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
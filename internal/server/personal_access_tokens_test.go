@@ -0,0 +1,53 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCreatePATRequestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      createPATRequest
+		isAdmin  bool
+		wantErr  bool
+		lifetime time.Duration
+		scopes   []string
+	}{
+		{"defaults", createPATRequest{Name: " ci ", Scopes: []string{"write", "read"}}, false, false, defaultPATLifetime, []string{"read", "write"}},
+		{"expiry", createPATRequest{Name: "ci", Scopes: []string{"read"}, ExpiresInDays: 7}, false, false, 7 * 24 * time.Hour, []string{"read"}},
+		{"no name", createPATRequest{Scopes: []string{"read"}}, false, true, 0, nil},
+		{"no scopes", createPATRequest{Name: "ci"}, false, true, 0, nil},
+		{"unknown scope", createPATRequest{Name: "ci", Scopes: []string{"delete"}}, false, true, 0, nil},
+		{"admin scope of user", createPATRequest{Name: "ci", Scopes: []string{"read", "admin"}}, false, true, 0, nil},
+		{"admin scope of admin", createPATRequest{Name: "ci", Scopes: []string{"admin", "read"}}, true, false, defaultPATLifetime, []string{"read", "admin"}},
+		{"too long", createPATRequest{Name: "ci", Scopes: []string{"read"}, ExpiresInDays: 366}, false, true, 0, nil},
+		{"negative", createPATRequest{Name: "ci", Scopes: []string{"read"}, ExpiresInDays: -1}, false, true, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			lifetime, err := req.validate(tt.isAdmin)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if lifetime != tt.lifetime {
+				t.Errorf("lifetime = %v, want %v", lifetime, tt.lifetime)
+			}
+			if req.Name != "ci" {
+				t.Errorf("name = %q, want trimmed", req.Name)
+			}
+			if len(req.Scopes) != len(tt.scopes) {
+				t.Fatalf("scopes = %v, want %v", req.Scopes, tt.scopes)
+			}
+			for i := range req.Scopes {
+				if req.Scopes[i] != tt.scopes[i] {
+					t.Errorf("scopes = %v, want %v", req.Scopes, tt.scopes)
+				}
+			}
+		})
+	}
+}
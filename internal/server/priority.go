@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Priority tiers order sandbox scheduling when cluster capacity is tight. An
+// admin assigns a tier to a workspace; its sandboxes get the PriorityClass
+// mapped to the tier (SANDBOX_PRIORITY_CLASSES, e.g.
+// "critical=agentserver-critical,low=agentserver-low"), so the scheduler can
+// preempt lower tiers, and starts and resumes waiting in the scheduling
+// queue are dispatched highest tier first.
+
+const defaultPriorityTier = "normal"
+
+// priorityTiers ranks the tiers; higher starts first.
+var priorityTiers = map[string]int{
+	"low":      0,
+	"normal":   1,
+	"high":     2,
+	"critical": 3,
+}
+
+// parsePriorityClasses parses "tier=PriorityClass,..." pairs.
+func parsePriorityClasses(spec string) (map[string]string, error) {
+	classes := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tier, class, ok := strings.Cut(pair, "=")
+		tier, class = strings.TrimSpace(tier), strings.TrimSpace(class)
+		if !ok || class == "" {
+			return nil, fmt.Errorf("invalid priority class mapping %q, want tier=PriorityClass", pair)
+		}
+		if _, known := priorityTiers[tier]; !known {
+			return nil, fmt.Errorf("unknown priority tier %q", tier)
+		}
+		classes[tier] = class
+	}
+	return classes, nil
+}
+
+// workspacePriority returns the priority tier of a workspace and its rank.
+func (s *Server) workspacePriority(workspaceID string) (string, int) {
+	tier, err := s.DB.GetWorkspacePriorityTier(workspaceID)
+	if err != nil {
+		log.Printf("failed to get priority tier of workspace %s: %v", workspaceID, err)
+	}
+	rank, ok := priorityTiers[tier]
+	if !ok {
+		tier, rank = defaultPriorityTier, priorityTiers[defaultPriorityTier]
+	}
+	return tier, rank
+}
+
+// GET /api/admin/workspaces/{id}/priority
+func (s *Server) handleAdminGetWorkspacePriority(w http.ResponseWriter, r *http.Request) {
+	tier, _ := s.workspacePriority(chi.URLParam(r, "id"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tier":           tier,
+		"priority_class": s.PriorityClasses[tier],
+	})
+}
+
+// PUT /api/admin/workspaces/{id}/priority sets the tier. The PriorityClass
+// applies to sandboxes created afterwards; start ordering applies at once.
+func (s *Server) handleAdminSetWorkspacePriority(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	var req struct {
+		Tier string `json:"tier"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := priorityTiers[req.Tier]; !ok {
+		http.Error(w, "tier must be low, normal, high or critical", http.StatusBadRequest)
+		return
+	}
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil {
+		log.Printf("admin: failed to get workspace %s: %v", wsID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+	if err := s.DB.SetWorkspacePriorityTier(wsID, req.Tier); err != nil {
+		log.Printf("admin: failed to set priority tier of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to set priority", http.StatusInternalServerError)
+		return
+	}
+	s.handleAdminGetWorkspacePriority(w, r)
+}
@@ -0,0 +1,18 @@
+package server
+
+import "testing"
+
+func TestParsePriorityClasses(t *testing.T) {
+	classes, err := parsePriorityClasses(" critical=agentserver-critical, low=agentserver-low ,")
+	if err != nil {
+		t.Fatalf("parsePriorityClasses: %v", err)
+	}
+	if len(classes) != 2 || classes["critical"] != "agentserver-critical" || classes["low"] != "agentserver-low" {
+		t.Fatalf("got %v", classes)
+	}
+	for _, spec := range []string{"urgent=x", "critical", "critical="} {
+		if _, err := parsePriorityClasses(spec); err == nil {
+			t.Errorf("parsePriorityClasses(%q): expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// proxyScope restricts what a sandbox's LLM proxy token may do upstream, so
+// a compromised sandbox can't switch to the most expensive model or to batch
+// endpoints. It is stored in the sandbox metadata under proxyScopeKey and
+// returned by /internal/validate-proxy-token; the LLM proxy enforces it.
+// Empty fields don't restrict.
+type proxyScope struct {
+	// Models are allowed model names; path.Match patterns such as
+	// "claude-haiku-*" are accepted.
+	Models []string `json:"models,omitempty"`
+	// MaxTokens caps max_tokens per request.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Endpoints are allowed upstream paths (patterns), e.g. "/v1/messages".
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// proxyScopeKey is the sandbox metadata key holding the proxy scope. Only the
+// server sets it.
+const proxyScopeKey = "proxy_scope"
+
+func (sc *proxyScope) validate() error {
+	if sc.MaxTokens < 0 {
+		return fmt.Errorf("proxy_scope.max_tokens must not be negative")
+	}
+	for _, p := range append(append([]string{}, sc.Models...), sc.Endpoints...) {
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("proxy_scope: empty pattern")
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("proxy_scope: invalid pattern %q", p)
+		}
+	}
+	for _, e := range sc.Endpoints {
+		if !strings.HasPrefix(e, "/") {
+			return fmt.Errorf("proxy_scope: endpoint %q must start with /", e)
+		}
+	}
+	return nil
+}
+
+func (sc *proxyScope) empty() bool {
+	return len(sc.Models) == 0 && sc.MaxTokens == 0 && len(sc.Endpoints) == 0
+}
+
+// sandboxProxyScope returns the proxy scope stored in sandbox metadata, or
+// nil if there is none.
+func sandboxProxyScope(metadata json.RawMessage) *proxyScope {
+	if len(metadata) == 0 {
+		return nil
+	}
+	var m struct {
+		Scope *proxyScope `json:"proxy_scope"`
+	}
+	if err := json.Unmarshal(metadata, &m); err != nil || m.Scope == nil || m.Scope.empty() {
+		return nil
+	}
+	return m.Scope
+}
+
+// sandboxLLMProviders returns the LLM providers the sandbox selected, or nil
+// if it selected none (and may use all).
+func sandboxLLMProviders(metadata json.RawMessage) []string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	var m struct {
+		Providers []string `json:"llm_providers"`
+	}
+	if err := json.Unmarshal(metadata, &m); err != nil {
+		return nil
+	}
+	return m.Providers
+}
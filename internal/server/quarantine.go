@@ -0,0 +1,145 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+type quarantiner interface {
+	QuarantineContainer(id string) error
+	ReleaseContainer(id string) error
+}
+
+// handleAdminQuarantineSandbox isolates a sandbox: its network is cut off
+// (deny-all NetworkPolicy on K8s, network disconnect on Docker), proxy and
+// tunnel traffic is refused, its proxy token stops validating, and it cannot
+// be resumed. Volumes are left untouched for forensics.
+func (s *Server) handleAdminQuarantineSandbox(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox is already quarantined", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if !sbx.IsLocal {
+		// Refresh the namespace egress policy first so it excludes
+		// quarantined pods (older namespaces predate the exclusion).
+		if s.NamespaceManager != nil {
+			if _, err := s.NamespaceManager.EnsureNamespace(r.Context(), sbx.WorkspaceID); err != nil {
+				log.Printf("admin: failed to refresh namespace for quarantine of %s: %v", id, err)
+			}
+		}
+		if q, ok := s.ProcessManager.(quarantiner); ok {
+			if err := q.QuarantineContainer(id); err != nil {
+				log.Printf("admin: failed to isolate sandbox %s: %v", id, err)
+				http.Error(w, "failed to isolate sandbox", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	actorID := auth.UserIDFromContext(r.Context())
+	if err := s.DB.SetSandboxQuarantined(id, sbx.WorkspaceID, true, req.Reason, actorID); err != nil {
+		log.Printf("admin: failed to record quarantine of %s: %v", id, err)
+		http.Error(w, "failed to quarantine sandbox", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin: sandbox %s quarantined by %s: %s", id, actorID, req.Reason)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// firstQuarantined returns the first quarantined sandbox of a list, or nil.
+// Quarantined sandboxes are kept for forensics and can't be deleted until
+// released.
+func firstQuarantined(sandboxes []*sbxstore.Sandbox) *sbxstore.Sandbox {
+	for _, sbx := range sandboxes {
+		if sbx.QuarantinedAt != nil {
+			return sbx
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleAdminReleaseSandbox(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if sbx.QuarantinedAt == nil {
+		http.Error(w, "sandbox is not quarantined", http.StatusConflict)
+		return
+	}
+
+	if !sbx.IsLocal {
+		if q, ok := s.ProcessManager.(quarantiner); ok {
+			if err := q.ReleaseContainer(id); err != nil {
+				log.Printf("admin: failed to release sandbox %s: %v", id, err)
+				http.Error(w, "failed to release sandbox", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	actorID := auth.UserIDFromContext(r.Context())
+	if err := s.DB.SetSandboxQuarantined(id, sbx.WorkspaceID, false, "", actorID); err != nil {
+		log.Printf("admin: failed to record release of %s: %v", id, err)
+		http.Error(w, "failed to release sandbox", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin: sandbox %s released from quarantine by %s", id, actorID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleAdminListQuarantineEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	events, err := s.DB.ListSandboxQuarantineEvents(id)
+	if err != nil {
+		log.Printf("admin: failed to list quarantine events: %v", err)
+		http.Error(w, "failed to list quarantine events", http.StatusInternalServerError)
+		return
+	}
+
+	type eventResponse struct {
+		Action    string  `json:"action"`
+		Reason    *string `json:"reason"`
+		ActorID   *string `json:"actor_id"`
+		CreatedAt string  `json:"created_at"`
+	}
+	resp := make([]eventResponse, len(events))
+	for i, e := range events {
+		resp[i] = eventResponse{
+			Action:    e.Action,
+			Reason:    e.Reason,
+			ActorID:   e.ActorID,
+			CreatedAt: e.CreatedAt.Format(time.RFC3339),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
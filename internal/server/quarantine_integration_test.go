@@ -0,0 +1,156 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+// quarantineBackend records isolation calls and can exec, so the file
+// handlers get past the backend check. Other Manager methods are not used.
+type quarantineBackend struct {
+	process.Manager
+	quarantined, released []string
+	execs                 int
+}
+
+func (b *quarantineBackend) QuarantineContainer(id string) error {
+	b.quarantined = append(b.quarantined, id)
+	return nil
+}
+
+func (b *quarantineBackend) ReleaseContainer(id string) error {
+	b.released = append(b.released, id)
+	return nil
+}
+
+func (b *quarantineBackend) ExecInput(ctx context.Context, sandboxID string, command []string, stdin io.Reader) (string, error) {
+	b.execs++
+	return "", nil
+}
+
+// TestIntegration_SandboxQuarantine checks that only admins can quarantine
+// and release a sandbox, that both refuse the wrong starting state, and
+// that a quarantined sandbox cannot be deleted, resumed or reached by
+// terminal or file requests.
+func TestIntegration_SandboxQuarantine(t *testing.T) {
+	d := testenv.DB(t)
+	backend := &quarantineBackend{}
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d), Auth: auth.New(d), ProcessManager: backend}
+
+	wsID := uuid.NewString()
+	adminID := uuid.NewString()
+	ownerID := uuid.NewString()
+	sbxID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, ownerID, "owner")
+	seedWorkspaceMember(t, d, wsID, adminID, "developer")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id IN ($1, $2)`, adminID, ownerID)
+		d.Exec(`DELETE FROM sandbox_quarantine_events WHERE sandbox_id = $1`, sbxID)
+	})
+	if err := d.UpdateUserRole(adminID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateSandbox(sbxID, wsID, "suspect", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusRunning)
+
+	r := chi.NewRouter()
+	r.With(s.requireAdmin).Post("/api/admin/sandboxes/{id}/quarantine", s.handleAdminQuarantineSandbox)
+	r.With(s.requireAdmin).Delete("/api/admin/sandboxes/{id}/quarantine", s.handleAdminReleaseSandbox)
+	r.Post("/api/sandboxes/{id}/resume", s.handleResumeSandbox)
+	r.Get("/api/sandboxes/{id}/terminal", s.handleSandboxTerminal)
+	r.Get("/api/sandboxes/{id}/files", s.handleListSandboxFiles)
+	r.Delete("/api/sandboxes/{id}", s.handleDeleteSandbox)
+	r.Delete("/api/workspaces/{id}", s.handleDeleteWorkspace)
+	do := func(userID, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body)).
+			WithContext(auth.ContextWithUserID(context.Background(), userID))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	quarantinePath := "/api/admin/sandboxes/" + sbxID + "/quarantine"
+
+	if rr := do(ownerID, http.MethodPost, quarantinePath, `{}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("quarantine by workspace owner: status %d, want 403", rr.Code)
+	}
+	if rr := do(adminID, http.MethodDelete, quarantinePath, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("release of a sandbox not quarantined: status %d, want 409", rr.Code)
+	}
+	if rr := do(adminID, http.MethodPost, "/api/admin/sandboxes/"+uuid.NewString()+"/quarantine", `{}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("quarantine of a missing sandbox: status %d, want 404", rr.Code)
+	}
+	if rr := do(adminID, http.MethodPost, quarantinePath, `{"reason":`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("quarantine with a malformed body: status %d, want 400", rr.Code)
+	}
+	if rr := do(adminID, http.MethodPost, quarantinePath, `{"reason":"crypto miner"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("quarantine: status %d (%s), want 204", rr.Code, rr.Body.String())
+	}
+	if len(backend.quarantined) != 1 || backend.quarantined[0] != sbxID {
+		t.Errorf("QuarantineContainer calls = %v", backend.quarantined)
+	}
+	if sbx, _ := s.Sandboxes.Get(sbxID); sbx == nil || sbx.QuarantinedAt == nil {
+		t.Fatal("sandbox not marked quarantined")
+	}
+	if rr := do(adminID, http.MethodPost, quarantinePath, `{}`); rr.Code != http.StatusConflict {
+		t.Errorf("second quarantine: status %d, want 409", rr.Code)
+	}
+
+	for _, path := range []string{
+		"/api/sandboxes/" + sbxID + "/terminal",
+		"/api/sandboxes/" + sbxID + "/files?path=/home/agent",
+	} {
+		if rr := do(ownerID, http.MethodGet, path, ""); rr.Code != http.StatusForbidden {
+			t.Errorf("GET %s while quarantined: status %d, want 403", path, rr.Code)
+		}
+	}
+	if backend.execs != 0 {
+		t.Errorf("%d execs in a quarantined sandbox", backend.execs)
+	}
+	// Kept for forensics until released.
+	for _, path := range []string{"/api/sandboxes/" + sbxID, "/api/workspaces/" + wsID} {
+		if rr := do(ownerID, http.MethodDelete, path, ""); rr.Code != http.StatusConflict {
+			t.Errorf("DELETE %s while quarantined: status %d, want 409", path, rr.Code)
+		}
+	}
+	if _, ok := s.Sandboxes.Get(sbxID); !ok {
+		t.Fatal("quarantined sandbox deleted")
+	}
+	s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusPaused)
+	if rr := do(ownerID, http.MethodPost, "/api/sandboxes/"+sbxID+"/resume", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("resume while quarantined: status %d, want 403", rr.Code)
+	}
+
+	if rr := do(ownerID, http.MethodDelete, quarantinePath, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("release by workspace owner: status %d, want 403", rr.Code)
+	}
+	if rr := do(adminID, http.MethodDelete, quarantinePath, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("release: status %d (%s), want 204", rr.Code, rr.Body.String())
+	}
+	if len(backend.released) != 1 || backend.released[0] != sbxID {
+		t.Errorf("ReleaseContainer calls = %v", backend.released)
+	}
+	if sbx, _ := s.Sandboxes.Get(sbxID); sbx == nil || sbx.QuarantinedAt != nil {
+		t.Error("sandbox still marked quarantined after release")
+	}
+	events, err := d.ListSandboxQuarantineEvents(sbxID)
+	if err != nil || len(events) != 2 || events[0].Action != "release" || events[1].Action != "quarantine" {
+		t.Errorf("quarantine events = %+v, %v", events, err)
+	}
+}
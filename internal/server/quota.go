@@ -1,10 +1,15 @@
 package server
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/policy"
 )
 
 const (
@@ -17,6 +22,8 @@ const (
 	settingKeyWsMaxTotalCPU        = "default_ws_max_total_cpu"
 	settingKeyWsMaxTotalMemory     = "default_ws_max_total_memory"
 	settingKeyWsMaxIdleTimeout     = "default_ws_max_idle_timeout"
+	// JSON object of sandbox type to limit, see sandboxTypeLimits.
+	settingKeyMaxSandboxesByType = "quota_max_sandboxes_by_type"
 
 	defaultMaxWorkspaces = 10
 	defaultMaxSandboxes  = 20
@@ -35,93 +42,224 @@ type ResourceDefaults struct {
 	WsMaxIdleTimeout         int   // seconds
 }
 
-// getResourceDefaults resolves all defaults via the 3-layer priority chain:
-// 1. DB system_settings (highest)
-// 2. Environment variables
-// 3. Hardcoded fallback (lowest)
+// resourceDefault is one system-wide default and where each layer of the
+// resolution chain reads it from.
+type resourceDefault struct {
+	name     string // field of the admin quota defaults
+	env      string
+	setting  string // system_settings key
+	fallback int64
+	// parse reads an env or system_settings value; false means invalid.
+	parse func(string) (int64, bool)
+	// policy reads the policy file value; false means unset.
+	policy func(policy.Quotas) (int64, bool)
+	set    func(*ResourceDefaults, int64)
+}
+
+// resourceDefaults lists the system-wide defaults in the order of
+// ResourceDefaults.
+var resourceDefaults = []resourceDefault{
+	{"max_workspaces_per_user", "QUOTA_MAX_WORKSPACES_PER_USER", settingKeyMaxWorkspaces, defaultMaxWorkspaces, parseCountSetting,
+		func(q policy.Quotas) (int64, bool) { return policyCount(q.MaxWorkspacesPerUser) },
+		func(rd *ResourceDefaults, n int64) { rd.MaxWorkspacesPerUser = int(n) }},
+	{"max_sandboxes_per_workspace", "QUOTA_MAX_SANDBOXES_PER_WORKSPACE", settingKeyMaxSandboxes, defaultMaxSandboxes, parseCountSetting,
+		func(q policy.Quotas) (int64, bool) { return policyCount(q.MaxSandboxesPerWorkspace) },
+		func(rd *ResourceDefaults, n int64) { rd.MaxSandboxesPerWorkspace = int(n) }},
+	{"max_workspace_drive_size", "USER_DRIVE_SIZE", settingKeyMaxWorkspaceDriveSize, 10 * 1024 * 1024 * 1024, parseMemorySetting, // 10Gi
+		func(q policy.Quotas) (int64, bool) { return policyBytes(q.MaxWorkspaceDriveSize) },
+		func(rd *ResourceDefaults, n int64) { rd.MaxWorkspaceDriveSize = n }},
+	{"max_sandbox_cpu", "QUOTA_DEFAULT_SANDBOX_CPU", settingKeyMaxSandboxCPU, 2000, parseCPUSetting, // 2 cores
+		func(q policy.Quotas) (int64, bool) { return policyMillicores(q.MaxSandboxCPU) },
+		func(rd *ResourceDefaults, n int64) { rd.MaxSandboxCPU = int(n) }},
+	{"max_sandbox_memory", "QUOTA_DEFAULT_SANDBOX_MEMORY", settingKeyMaxSandboxMemory, 2 * 1024 * 1024 * 1024, parseMemorySetting, // 2Gi
+		func(q policy.Quotas) (int64, bool) { return policyBytes(q.MaxSandboxMemory) },
+		func(rd *ResourceDefaults, n int64) { rd.MaxSandboxMemory = n }},
+	{"max_idle_timeout", "IDLE_TIMEOUT", settingKeyMaxIdleTimeout, 1800, parseDurationSetting, // 30m
+		func(q policy.Quotas) (int64, bool) { return policySeconds(q.MaxIdleTimeout) },
+		func(rd *ResourceDefaults, n int64) { rd.MaxIdleTimeout = int(n) }},
+	{"ws_max_total_cpu", "QUOTA_WS_MAX_TOTAL_CPU", settingKeyWsMaxTotalCPU, 0, parseCPUSetting,
+		func(q policy.Quotas) (int64, bool) { return policyMillicores(q.WorkspaceMaxTotalCPU) },
+		func(rd *ResourceDefaults, n int64) { rd.WsMaxTotalCPU = int(n) }},
+	{"ws_max_total_memory", "QUOTA_WS_MAX_TOTAL_MEMORY", settingKeyWsMaxTotalMemory, 0, parseMemorySetting,
+		func(q policy.Quotas) (int64, bool) { return policyBytes(q.WorkspaceMaxTotalMemory) },
+		func(rd *ResourceDefaults, n int64) { rd.WsMaxTotalMemory = n }},
+	{"ws_max_idle_timeout", "QUOTA_WS_MAX_IDLE_TIMEOUT", settingKeyWsMaxIdleTimeout, 0, parseDurationSetting,
+		func(q policy.Quotas) (int64, bool) { return policySeconds(q.WorkspaceMaxIdleTimeout) },
+		func(rd *ResourceDefaults, n int64) { rd.WsMaxIdleTimeout = int(n) }},
+}
+
+// getResourceDefaults resolves all defaults via the 4-layer priority chain:
+// 1. Policy file quotas (highest)
+// 2. DB system_settings
+// 3. Environment variables
+// 4. Hardcoded fallback (lowest)
+// Invalid env and system_settings values are skipped; see
+// handleAdminConfigDrift for how each default was resolved.
 func (s *Server) getResourceDefaults() ResourceDefaults {
-	rd := ResourceDefaults{
-		MaxWorkspacesPerUser:     defaultMaxWorkspaces,
-		MaxSandboxesPerWorkspace: defaultMaxSandboxes,
-		MaxWorkspaceDriveSize:    10 * 1024 * 1024 * 1024, // 10Gi
-		MaxSandboxCPU:            2000,                     // 2 cores
-		MaxSandboxMemory:         2 * 1024 * 1024 * 1024,  // 2Gi
-		MaxIdleTimeout:           1800,                     // 30m
-		WsMaxTotalCPU:            0,
-		WsMaxTotalMemory:         0,
-		WsMaxIdleTimeout:         0,
-	}
-
-	// Layer 2: environment variables override hardcoded defaults.
-	if v := os.Getenv("QUOTA_MAX_WORKSPACES_PER_USER"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			rd.MaxWorkspacesPerUser = n
-		}
+	var rd ResourceDefaults
+	q := s.Policy.Get().Quotas
+	for _, d := range resourceDefaults {
+		d.set(&rd, resolveResourceDefault(d, os.Getenv(d.env), s.systemSetting(d.setting), q).value)
 	}
-	if v := os.Getenv("QUOTA_MAX_SANDBOXES_PER_WORKSPACE"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			rd.MaxSandboxesPerWorkspace = n
-		}
+	return rd
+}
+
+// systemSetting returns a system_settings value, or "" if it is unset or
+// can't be read.
+func (s *Server) systemSetting(key string) string {
+	v, err := s.DB.GetSystemSetting(key)
+	if err != nil {
+		return ""
 	}
-	if v := os.Getenv("USER_DRIVE_SIZE"); v != "" {
-		rd.MaxWorkspaceDriveSize = parseResourceInt64(v, rd.MaxWorkspaceDriveSize, parseMemoryBytes)
+	return v
+}
+
+// parseCountSetting parses a plain integer.
+func parseCountSetting(v string) (int64, bool) {
+	n, err := strconv.Atoi(v)
+	return int64(n), err == nil
+}
+
+// parseCPUSetting parses millicores, or a legacy K8s CPU string.
+func parseCPUSetting(v string) (int64, bool) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return int64(n), true
 	}
-	if v := os.Getenv("QUOTA_DEFAULT_SANDBOX_CPU"); v != "" {
-		rd.MaxSandboxCPU = parseResourceInt(v, rd.MaxSandboxCPU, parseCPUMillicores)
+	n := parseCPUMillicores(v)
+	return int64(n), n != 0
+}
+
+// parseMemorySetting parses bytes, or a legacy K8s memory string.
+func parseMemorySetting(v string) (int64, bool) {
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, true
 	}
-	if v := os.Getenv("QUOTA_DEFAULT_SANDBOX_MEMORY"); v != "" {
-		rd.MaxSandboxMemory = parseResourceInt64(v, rd.MaxSandboxMemory, parseMemoryBytes)
+	n := parseMemoryBytes(v)
+	return n, n != 0
+}
+
+// parseDurationSetting parses seconds, or a legacy Go duration string.
+func parseDurationSetting(v string) (int64, bool) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return int64(n), true
 	}
-	if v := os.Getenv("IDLE_TIMEOUT"); v != "" {
-		rd.MaxIdleTimeout = parseResourceInt(v, rd.MaxIdleTimeout, parseDurationSeconds)
+	n := parseDurationSeconds(v)
+	return int64(n), n != 0
+}
+
+// Policy file values were validated when the file was loaded.
+
+func policyCount(n *int) (int64, bool) {
+	if n == nil {
+		return 0, false
 	}
-	if v := os.Getenv("QUOTA_WS_MAX_TOTAL_CPU"); v != "" {
-		rd.WsMaxTotalCPU = parseResourceInt(v, rd.WsMaxTotalCPU, parseCPUMillicores)
+	return int64(*n), true
+}
+
+func policyMillicores(v policy.Value) (int64, bool) {
+	if v == "" {
+		return 0, false
 	}
-	if v := os.Getenv("QUOTA_WS_MAX_TOTAL_MEMORY"); v != "" {
-		rd.WsMaxTotalMemory = parseResourceInt64(v, rd.WsMaxTotalMemory, parseMemoryBytes)
+	n, _ := v.Millicores()
+	return int64(n), true
+}
+
+func policyBytes(v policy.Value) (int64, bool) {
+	if v == "" {
+		return 0, false
 	}
-	if v := os.Getenv("QUOTA_WS_MAX_IDLE_TIMEOUT"); v != "" {
-		rd.WsMaxIdleTimeout = parseResourceInt(v, rd.WsMaxIdleTimeout, parseDurationSeconds)
+	n, _ := v.Bytes()
+	return n, true
+}
+
+func policySeconds(v policy.Value) (int64, bool) {
+	if v == "" {
+		return 0, false
 	}
+	n, _ := v.Seconds()
+	return int64(n), true
+}
 
-	// Layer 1: DB system_settings take highest priority.
-	if v, err := s.DB.GetSystemSetting(settingKeyMaxWorkspaces); err == nil && v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			rd.MaxWorkspacesPerUser = n
+// policyManagedQuotas returns the admin quota-default fields (JSON names)
+// that the policy file sets and admins therefore cannot change.
+func policyManagedQuotas(q policy.Quotas) []string {
+	names := []string{}
+	add := func(set bool, name string) {
+		if set {
+			names = append(names, name)
 		}
 	}
-	if v, err := s.DB.GetSystemSetting(settingKeyMaxSandboxes); err == nil && v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			rd.MaxSandboxesPerWorkspace = n
+	add(q.MaxWorkspacesPerUser != nil, "max_workspaces_per_user")
+	add(q.MaxSandboxesPerWorkspace != nil, "max_sandboxes_per_workspace")
+	add(q.MaxWorkspaceDriveSize != "", "max_workspace_drive_size")
+	add(q.MaxSandboxCPU != "", "max_sandbox_cpu")
+	add(q.MaxSandboxMemory != "", "max_sandbox_memory")
+	add(q.MaxIdleTimeout != "", "max_idle_timeout")
+	add(q.WorkspaceMaxTotalCPU != "", "ws_max_total_cpu")
+	add(q.WorkspaceMaxTotalMemory != "", "ws_max_total_memory")
+	add(q.WorkspaceMaxIdleTimeout != "", "ws_max_idle_timeout")
+	add(q.MaxSandboxesByType != nil, "max_sandboxes_by_type")
+	return names
+}
+
+// sandboxTypeLimits resolves the system-wide per-type sandbox limits: the
+// policy file, the admin setting, then QUOTA_MAX_SANDBOXES_BY_TYPE
+// ("openclaw=2,jupyter=5"). Each layer sets the whole map; invalid values
+// are skipped. Types without a limit, or a limit of 0, are only bounded by
+// max_sandboxes_per_workspace.
+func (s *Server) sandboxTypeLimits() map[string]int {
+	if m := s.Policy.Get().Quotas.MaxSandboxesByType; m != nil {
+		return m
+	}
+	if v := s.systemSetting(settingKeyMaxSandboxesByType); v != "" {
+		var m map[string]int
+		if err := json.Unmarshal([]byte(v), &m); err == nil && validateSandboxTypeLimits(m) == nil {
+			return m
 		}
 	}
-	if v, err := s.DB.GetSystemSetting(settingKeyMaxWorkspaceDriveSize); err == nil && v != "" {
-		rd.MaxWorkspaceDriveSize = parseResourceInt64(v, rd.MaxWorkspaceDriveSize, parseMemoryBytes)
+	if m, err := parseSandboxTypeLimits(os.Getenv("QUOTA_MAX_SANDBOXES_BY_TYPE")); err == nil {
+		return m
 	}
-	if v, err := s.DB.GetSystemSetting(settingKeyMaxSandboxCPU); err == nil && v != "" {
-		rd.MaxSandboxCPU = parseResourceInt(v, rd.MaxSandboxCPU, parseCPUMillicores)
-	}
-	if v, err := s.DB.GetSystemSetting(settingKeyMaxSandboxMemory); err == nil && v != "" {
-		rd.MaxSandboxMemory = parseResourceInt64(v, rd.MaxSandboxMemory, parseMemoryBytes)
-	}
-	if v, err := s.DB.GetSystemSetting(settingKeyMaxIdleTimeout); err == nil && v != "" {
-		rd.MaxIdleTimeout = parseResourceInt(v, rd.MaxIdleTimeout, parseDurationSeconds)
-	}
-	if v, err := s.DB.GetSystemSetting(settingKeyWsMaxTotalCPU); err == nil && v != "" {
-		rd.WsMaxTotalCPU = parseResourceInt(v, rd.WsMaxTotalCPU, parseCPUMillicores)
-	}
-	if v, err := s.DB.GetSystemSetting(settingKeyWsMaxTotalMemory); err == nil && v != "" {
-		rd.WsMaxTotalMemory = parseResourceInt64(v, rd.WsMaxTotalMemory, parseMemoryBytes)
-	}
-	if v, err := s.DB.GetSystemSetting(settingKeyWsMaxIdleTimeout); err == nil && v != "" {
-		rd.WsMaxIdleTimeout = parseResourceInt(v, rd.WsMaxIdleTimeout, parseDurationSeconds)
+	return map[string]int{}
+}
+
+// parseSandboxTypeLimits parses "type=limit,..." pairs.
+func parseSandboxTypeLimits(v string) (map[string]int, error) {
+	m := map[string]int{}
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		t, n, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q: want type=limit", pair)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid limit", pair)
+		}
+		m[strings.TrimSpace(t)] = limit
 	}
+	return m, validateSandboxTypeLimits(m)
+}
 
-	return rd
+// validateSandboxTypeLimits checks that per-type limits name known sandbox
+// types and are >= 0.
+func validateSandboxTypeLimits(m map[string]int) error {
+	for t, n := range m {
+		if !policy.ValidType(t) {
+			return fmt.Errorf("unknown sandbox type %q; must be one of %s", t, strings.Join(policy.SandboxTypes, ", "))
+		}
+		if n < 0 {
+			return fmt.Errorf("limit of %s must be >= 0", t)
+		}
+	}
+	return nil
 }
 
-// WorkspaceDefaults holds workspace-level resolved defaults (system defaults <- workspace_quotas override).
+// WorkspaceDefaults holds workspace-level resolved defaults (system defaults <- workspace_quotas override,
+// plus the workspace_settings defaults for new sandboxes).
 type WorkspaceDefaults struct {
 	MaxSandboxes     int
 	MaxSandboxCPU    int   // millicores
@@ -130,9 +268,21 @@ type WorkspaceDefaults struct {
 	MaxTotalCPU      int   // millicores
 	MaxTotalMemory   int64 // bytes
 	MaxDriveSize     int64 // bytes
+	PausedRetention  int   // seconds; 0 keeps paused sandboxes forever
+	// MaxSandboxesByType limits the sandboxes of a type; a missing type or
+	// 0 is only bounded by MaxSandboxes.
+	MaxSandboxesByType map[string]int
+
+	// Defaults for what a create request leaves out, within the limits
+	// above.
+	DefaultSandboxType   string // "" is opencode
+	DefaultSandboxCPU    int    // millicores
+	DefaultSandboxMemory int64  // bytes
+	DefaultIdleTimeout   int    // seconds; 0 uses the server's idle timeout
 }
 
-// effectiveWorkspaceDefaults merges system defaults with workspace_quotas overrides.
+// effectiveWorkspaceDefaults merges system defaults with workspace_quotas overrides
+// and the workspace's settings.
 func (s *Server) effectiveWorkspaceDefaults(workspaceID string) (WorkspaceDefaults, error) {
 	rd := s.getResourceDefaults()
 	wd := WorkspaceDefaults{
@@ -143,41 +293,83 @@ func (s *Server) effectiveWorkspaceDefaults(workspaceID string) (WorkspaceDefaul
 		MaxTotalCPU:      rd.WsMaxTotalCPU,
 		MaxTotalMemory:   rd.WsMaxTotalMemory,
 		MaxDriveSize:     rd.MaxWorkspaceDriveSize,
+		PausedRetention:  int(s.PausedRetention / time.Second),
+	}
+	wd.MaxSandboxesByType = make(map[string]int)
+	for t, n := range s.sandboxTypeLimits() {
+		wd.MaxSandboxesByType[t] = n
 	}
 
 	wq, err := s.DB.GetWorkspaceQuota(workspaceID)
 	if err != nil {
 		return wd, err
 	}
-	if wq == nil {
-		return wd, nil
+	if wq != nil {
+		if wq.MaxSandboxes != nil {
+			wd.MaxSandboxes = *wq.MaxSandboxes
+		}
+		if wq.MaxSandboxCPU != nil {
+			wd.MaxSandboxCPU = *wq.MaxSandboxCPU
+		}
+		if wq.MaxSandboxMemory != nil {
+			wd.MaxSandboxMemory = *wq.MaxSandboxMemory
+		}
+		if wq.MaxIdleTimeout != nil {
+			wd.MaxIdleTimeout = *wq.MaxIdleTimeout
+		}
+		if wq.MaxTotalCPU != nil {
+			wd.MaxTotalCPU = *wq.MaxTotalCPU
+		}
+		if wq.MaxTotalMemory != nil {
+			wd.MaxTotalMemory = *wq.MaxTotalMemory
+		}
+		if wq.MaxDriveSize != nil {
+			wd.MaxDriveSize = *wq.MaxDriveSize
+		}
+		if wq.PausedRetention != nil {
+			wd.PausedRetention = *wq.PausedRetention
+		}
+		// A workspace's per-type limits replace the defaults type by type.
+		for t, n := range wq.MaxSandboxesByType {
+			wd.MaxSandboxesByType[t] = n
+		}
 	}
 
-	if wq.MaxSandboxes != nil {
-		wd.MaxSandboxes = *wq.MaxSandboxes
-	}
-	if wq.MaxSandboxCPU != nil {
-		wd.MaxSandboxCPU = *wq.MaxSandboxCPU
-	}
-	if wq.MaxSandboxMemory != nil {
-		wd.MaxSandboxMemory = *wq.MaxSandboxMemory
-	}
-	if wq.MaxIdleTimeout != nil {
-		wd.MaxIdleTimeout = *wq.MaxIdleTimeout
-	}
-	if wq.MaxTotalCPU != nil {
-		wd.MaxTotalCPU = *wq.MaxTotalCPU
-	}
-	if wq.MaxTotalMemory != nil {
-		wd.MaxTotalMemory = *wq.MaxTotalMemory
-	}
-	if wq.MaxDriveSize != nil {
-		wd.MaxDriveSize = *wq.MaxDriveSize
+	ws, err := s.DB.GetWorkspaceSettings(workspaceID)
+	if err != nil {
+		return wd, err
 	}
-
+	applyWorkspaceSettings(&wd, ws)
 	return wd, nil
 }
 
+// applyWorkspaceSettings sets the defaults for new sandboxes from a
+// workspace's settings (nil for none). Sandboxes get the maximum resources
+// unless the settings ask for less; a setting above a limit that was
+// lowered since is capped to it.
+func applyWorkspaceSettings(wd *WorkspaceDefaults, ws *db.WorkspaceSettings) {
+	wd.DefaultSandboxCPU = wd.MaxSandboxCPU
+	wd.DefaultSandboxMemory = wd.MaxSandboxMemory
+	if ws == nil {
+		return
+	}
+	if ws.DefaultSandboxType != nil {
+		wd.DefaultSandboxType = *ws.DefaultSandboxType
+	}
+	if ws.DefaultCPU != nil && *ws.DefaultCPU < wd.MaxSandboxCPU {
+		wd.DefaultSandboxCPU = *ws.DefaultCPU
+	}
+	if ws.DefaultMemory != nil && *ws.DefaultMemory < wd.MaxSandboxMemory {
+		wd.DefaultSandboxMemory = *ws.DefaultMemory
+	}
+	if ws.DefaultIdleTimeout != nil {
+		wd.DefaultIdleTimeout = *ws.DefaultIdleTimeout
+		if wd.MaxIdleTimeout > 0 && wd.DefaultIdleTimeout > wd.MaxIdleTimeout {
+			wd.DefaultIdleTimeout = wd.MaxIdleTimeout
+		}
+	}
+}
+
 // effectiveQuota returns the effective max-workspaces quota for a user.
 // Per-user overrides take precedence over system defaults.
 func (s *Server) effectiveQuota(userID string) (maxWs int, err error) {
@@ -195,6 +387,42 @@ func (s *Server) effectiveQuota(userID string) (maxWs int, err error) {
 	return maxWs, nil
 }
 
+// llmSpendLimit is a monthly LLM budget as the LLM proxy enforces it.
+type llmSpendLimit struct {
+	Scope             string    `json:"scope"`
+	ID                string    `json:"id"`
+	MaxTokensPerMonth *int64    `json:"max_tokens_per_month,omitempty"`
+	MaxUSDPerMonth    *float64  `json:"max_usd_per_month,omitempty"`
+	Since             time.Time `json:"since"`
+}
+
+// llmSpendLimits returns the spend limits that apply to LLM requests of a
+// workspace and, if known, the user they are attributed to.
+func (s *Server) llmSpendLimits(workspaceID, userID string) ([]llmSpendLimit, error) {
+	var limits []llmSpendLimit
+	now := time.Now()
+	for _, scope := range [][2]string{{db.SpendScopeWorkspace, workspaceID}, {db.SpendScopeUser, userID}} {
+		if scope[1] == "" {
+			continue
+		}
+		l, err := s.DB.GetLLMSpendLimit(scope[0], scope[1])
+		if err != nil {
+			return nil, err
+		}
+		if l == nil || (l.MaxTokensPerMonth == nil && l.MaxUSDPerMonth == nil) {
+			continue
+		}
+		limits = append(limits, llmSpendLimit{
+			Scope:             scope[0],
+			ID:                scope[1],
+			MaxTokensPerMonth: l.MaxTokensPerMonth,
+			MaxUSDPerMonth:    l.MaxUSDPerMonth,
+			Since:             l.WindowStart(now),
+		})
+	}
+	return limits, nil
+}
+
 // checkWorkspaceQuota checks if a user can create another workspace.
 // Returns whether creation is allowed, the current count, and the max.
 // max=0 means unlimited.
@@ -216,25 +444,34 @@ func (s *Server) checkWorkspaceQuota(userID string) (allowed bool, current, max
 	return current < maxWs, current, maxWs, nil
 }
 
-// checkSandboxQuota checks if a workspace can have another sandbox.
-// Uses workspace-level quotas.
+// checkSandboxQuota checks if a workspace can have another sandbox of
+// sandboxType, against its total and per-type limits. When refused,
+// limitType is the type whose limit was reached, or "" for the total;
+// current and max are that limit's count and maximum.
 // max=0 means unlimited.
-func (s *Server) checkSandboxQuota(workspaceID string) (allowed bool, current, max int, err error) {
+func (s *Server) checkSandboxQuota(workspaceID, sandboxType string) (allowed bool, limitType string, current, max int, err error) {
 	wd, err := s.effectiveWorkspaceDefaults(workspaceID)
 	if err != nil {
-		return false, 0, 0, err
+		return false, "", 0, 0, err
 	}
 
 	current, err = s.DB.CountSandboxesByWorkspace(workspaceID)
 	if err != nil {
-		return false, 0, 0, err
+		return false, "", 0, 0, err
+	}
+	if wd.MaxSandboxes > 0 && current >= wd.MaxSandboxes {
+		return false, "", current, wd.MaxSandboxes, nil
 	}
 
-	if wd.MaxSandboxes == 0 {
-		return true, current, 0, nil
+	if typeMax := wd.MaxSandboxesByType[sandboxType]; typeMax > 0 {
+		counts, err := s.DB.CountSandboxesByWorkspaceType(workspaceID)
+		if err != nil {
+			return false, "", 0, 0, err
+		}
+		return counts[sandboxType] < typeMax, sandboxType, counts[sandboxType], typeMax, nil
 	}
 
-	return current < wd.MaxSandboxes, current, wd.MaxSandboxes, nil
+	return true, "", current, wd.MaxSandboxes, nil
 }
 
 // checkWorkspaceResourceBudget checks if adding a sandbox with the given resources
@@ -282,30 +519,6 @@ func (s *Server) GetEffectiveIdleTimeout() time.Duration {
 	return s.getEffectiveIdleTimeout()
 }
 
-// parseResourceInt tries strconv.Atoi first (new integer format),
-// then falls back to the legacy K8s format parser for backward compatibility.
-func parseResourceInt(s string, fallback int, legacyParser func(string) int) int {
-	if n, err := strconv.Atoi(s); err == nil {
-		return n
-	}
-	if n := legacyParser(s); n != 0 {
-		return n
-	}
-	return fallback
-}
-
-// parseResourceInt64 tries strconv.ParseInt first (new integer format),
-// then falls back to the legacy K8s format parser for backward compatibility.
-func parseResourceInt64(s string, fallback int64, legacyParser func(string) int64) int64 {
-	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
-		return n
-	}
-	if n := legacyParser(s); n != 0 {
-		return n
-	}
-	return fallback
-}
-
 // parseCPUMillicores converts a K8s CPU string to millicores.
 // Examples: "2" -> 2000, "500m" -> 500, "1.5" -> 1500, "0" -> 0
 func parseCPUMillicores(s string) int {
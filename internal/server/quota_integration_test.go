@@ -0,0 +1,115 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+func TestMain(m *testing.M) { os.Exit(testenv.Run(m)) }
+
+// TestIntegration_SandboxQuotaEnforced checks that sandbox creation is
+// refused once a workspace reaches its sandbox count quota, and that the
+// CPU budget counts the workspace's existing sandboxes.
+func TestIntegration_SandboxQuotaEnforced(t *testing.T) {
+	d := testenv.DB(t)
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d)}
+
+	wsID := uuid.NewString()
+	userID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, userID, "owner")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+	maxSandboxes, maxTotalCPU := 2, 3000
+	if err := d.SetWorkspaceQuota(wsID, &maxSandboxes, nil, nil, nil, &maxTotalCPU, nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	createSandbox := func() *httptest.ResponseRecorder {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("wid", wsID)
+		ctx := context.WithValue(auth.ContextWithUserID(context.Background(), userID), chi.RouteCtxKey, rctx)
+		req := httptest.NewRequest(http.MethodPost, "/api/workspaces/"+wsID+"/sandboxes", strings.NewReader(`{"name":"q","type":"opencode"}`)).WithContext(ctx)
+		rr := httptest.NewRecorder()
+		s.handleCreateSandbox(rr, req)
+		return rr
+	}
+
+	for i := 0; i < maxSandboxes; i++ {
+		if allowed, _, current, _, err := s.checkSandboxQuota(wsID, "opencode"); err != nil || !allowed {
+			t.Fatalf("quota with %d sandboxes: allowed=%v err=%v", current, allowed, err)
+		}
+		if err := d.CreateSandbox(uuid.NewString(), wsID, "q", "opencode", "", "", "", "", "", 1500, 0, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	allowed, limitType, current, max, err := s.checkSandboxQuota(wsID, "opencode")
+	if err != nil || allowed || limitType != "" || current != 2 || max != 2 {
+		t.Fatalf("checkSandboxQuota = %v, %q %d/%d, %v; want refused at 2/2", allowed, limitType, current, max, err)
+	}
+	rr := createSandbox()
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("create over quota: status %d (%s), want 403", rr.Code, rr.Body.String())
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	if body.Error != "quota_exceeded" {
+		t.Errorf("create over quota: error %q, want quota_exceeded", body.Error)
+	}
+
+	// Two 1500m sandboxes use the whole 3000m budget.
+	if ok, err := s.checkWorkspaceResourceBudget(wsID, 500, 0); err != nil || ok {
+		t.Errorf("checkWorkspaceResourceBudget(500m) = %v, %v; want over budget", ok, err)
+	}
+	if ok, err := s.checkWorkspaceResourceBudget(wsID, 0, 0); err != nil || !ok {
+		t.Errorf("checkWorkspaceResourceBudget(0) = %v, %v; want within budget", ok, err)
+	}
+}
+
+// TestIntegration_SandboxTypeQuota checks that a per-type limit refuses
+// sandboxes of that type while others are still allowed.
+func TestIntegration_SandboxTypeQuota(t *testing.T) {
+	d := testenv.DB(t)
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d)}
+
+	wsID := uuid.NewString()
+	userID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, userID, "owner")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+	maxSandboxes := 10
+	if err := d.SetWorkspaceQuota(wsID, &maxSandboxes, nil, nil, nil, nil, nil, nil, nil, map[string]int{"openclaw": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateSandbox(uuid.NewString(), wsID, "bot", "openclaw", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	allowed, limitType, current, max, err := s.checkSandboxQuota(wsID, "openclaw")
+	if err != nil || allowed || limitType != "openclaw" || current != 1 || max != 1 {
+		t.Errorf("checkSandboxQuota(openclaw) = %v, %q %d/%d, %v; want refused at openclaw 1/1", allowed, limitType, current, max, err)
+	}
+	if allowed, _, _, _, err := s.checkSandboxQuota(wsID, "opencode"); err != nil || !allowed {
+		t.Errorf("checkSandboxQuota(opencode) = %v, %v; want allowed", allowed, err)
+	}
+}
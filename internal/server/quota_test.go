@@ -0,0 +1,18 @@
+package server
+
+import "testing"
+
+func TestParseSandboxTypeLimits(t *testing.T) {
+	m, err := parseSandboxTypeLimits(" openclaw=2, jupyter = 5 ,")
+	if err != nil || len(m) != 2 || m["openclaw"] != 2 || m["jupyter"] != 5 {
+		t.Errorf("parseSandboxTypeLimits = %v, %v; want openclaw=2 jupyter=5", m, err)
+	}
+	if m, err := parseSandboxTypeLimits(""); err != nil || len(m) != 0 {
+		t.Errorf("parseSandboxTypeLimits(\"\") = %v, %v; want empty", m, err)
+	}
+	for _, v := range []string{"openclaw", "openclaw=lots", "emacs=1", "openclaw=-1"} {
+		if _, err := parseSandboxTypeLimits(v); err == nil {
+			t.Errorf("parseSandboxTypeLimits(%q): expected error", v)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	resp := map[string]interface{}{"status": "ok"}
+	if err := s.DB.PingContext(r.Context()); err != nil {
+		status = http.StatusServiceUnavailable
+		resp["status"] = "unavailable"
+		resp["database"] = "unreachable"
+	}
+	if !s.StorageReport.Healthy() {
+		status = http.StatusServiceUnavailable
+		resp["status"] = "unavailable"
+		resp["storage"] = "preflight failed; see /api/admin/storage/status"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminStorageStatus returns the storage preflight report.
+func (s *Server) handleAdminStorageStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"healthy": s.StorageReport.Healthy(),
+		"report":  s.StorageReport,
+	})
+}
@@ -0,0 +1,19 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// sandboxDomain returns the base domain a sandbox's subdomains are served
+// under: its region's domain when REGION_DOMAINS has one, so browsers talk
+// to the sandbox proxy co-located with it, else the domain matching the
+// request.
+func (s *Server) sandboxDomain(r *http.Request, sbx *sbxstore.Sandbox) string {
+	if d := s.RegionDomains[sbx.MetadataString(process.RegionMetadataKey)]; d != "" {
+		return d
+	}
+	return s.baseDomainForRequest(r)
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/agentserver/agentserver/internal/auth"
+)
+
+// restError is a non-2xx response of a REST handler invoked in-process.
+type restError struct {
+	Code    int
+	Message string
+}
+
+func (e *restError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// restResult is the captured response of a REST handler invoked in-process.
+type restResult struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *restResult) Header() http.Header { return r.header }
+
+func (r *restResult) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *restResult) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+type restTokenKey struct{}
+
+// withRESTCaller returns a context under which callREST acts as userID.
+// token, if set, is passed to the handler as the session cookie; handlers
+// use it to build sandbox URLs.
+func withRESTCaller(ctx context.Context, userID, token string) context.Context {
+	ctx = auth.ContextWithUserID(ctx, userID)
+	return context.WithValue(ctx, restTokenKey{}, token)
+}
+
+// callREST runs a REST handler in-process on behalf of the caller in ctx
+// (see withRESTCaller) and decodes a successful JSON response into out (if
+// non-nil). Non-2xx responses are returned as *restError.
+//
+// Alternative APIs use this instead of reimplementing the handlers, so
+// permissions, quotas and validation stay in one place.
+func (s *Server) callREST(ctx context.Context, h http.HandlerFunc, method string, params map[string]string, body, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	rctx := chi.NewRouteContext()
+	for k, v := range params {
+		rctx.URLParams.Add(k, v)
+	}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx), method, "/", &reqBody)
+	if err != nil {
+		return err
+	}
+	if len(s.BaseDomains) > 0 {
+		req.Host = s.BaseDomains[0]
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token, _ := ctx.Value(restTokenKey{}).(string); token != "" {
+		req.AddCookie(&http.Cookie{Name: "agentserver-token", Value: token})
+	}
+
+	res := &restResult{header: http.Header{}}
+	h(res, req)
+	if res.code == 0 {
+		res.code = http.StatusOK
+	}
+	if res.code < 200 || res.code > 299 {
+		return &restError{Code: res.code, Message: restErrorMessage(res.body.Bytes())}
+	}
+	if out != nil {
+		if err := json.Unmarshal(res.body.Bytes(), out); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// restErrorMessage extracts the message of a REST error body: the
+// "message" field of a JSON error, or the plain text of http.Error.
+func restErrorMessage(body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,515 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Sandbox access tokens let a CI job run one command in a sandbox or fetch
+// its artifacts without holding a user token. A developer mints a token
+// scoped to one sandbox, a set of operations, and (for exec) a fixed
+// command; it expires within an hour, can be limited to a number of uses,
+// and is rate limited. The /api/ci endpoints take the token instead of a
+// login, as a Bearer header or a ?token= query parameter, so the URLs
+// returned at mint time work as-is.
+
+const (
+	accessTokenPrefix = "sat_"
+	accessTokenLen    = 40
+
+	accessTokenDefaultTTL = 15 * time.Minute
+	accessTokenMaxTTL     = time.Hour
+
+	// accessTokenRateLimit is how many requests a token may make per
+	// accessTokenRateWindow.
+	accessTokenRateLimit  = 30
+	accessTokenRateWindow = time.Minute
+
+	// accessExecTimeout bounds a command run with a token.
+	accessExecTimeout = 10 * time.Minute
+	// maxAccessExecOutput bounds the output returned by an exec; longer
+	// output keeps its tail.
+	maxAccessExecOutput = 1 << 20
+	// maxAccessCommandLen bounds the command of a token.
+	maxAccessCommandLen = 4096
+)
+
+// hashAccessToken returns the hex SHA-256 under which a token is stored.
+// Tokens are random, so an unsalted fast hash suffices.
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// accessTokenFromRequest returns the token of a /api/ci request, from the
+// Authorization header or the token query parameter.
+func accessTokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
+
+// requestOrigin returns the scheme and host the request came in on.
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// accessTokenLimiter counts requests per token in fixed windows. The zero
+// value is ready to use.
+type accessTokenLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*accessTokenWindow
+}
+
+type accessTokenWindow struct {
+	start time.Time
+	count int
+}
+
+// allow records a request for key at now and reports whether it is within
+// the limit; if not, it also returns when the window resets.
+func (l *accessTokenLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.windows == nil {
+		l.windows = make(map[string]*accessTokenWindow)
+	}
+	win, ok := l.windows[key]
+	if !ok || now.Sub(win.start) >= accessTokenRateWindow {
+		if len(l.windows) >= 1024 {
+			for k, w := range l.windows {
+				if now.Sub(w.start) >= accessTokenRateWindow {
+					delete(l.windows, k)
+				}
+			}
+		}
+		win = &accessTokenWindow{start: now}
+		l.windows[key] = win
+	}
+	if win.count >= accessTokenRateLimit {
+		return false, win.start.Add(accessTokenRateWindow).Sub(now)
+	}
+	win.count++
+	return true, 0
+}
+
+// resolveArtifactPath resolves the path of an artifacts request against
+// the token's artifacts path: relative paths are taken from it, absolute
+// ones must lie inside it.
+func resolveArtifactPath(root, p string) (string, error) {
+	if p == "" {
+		return root, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = root + "/" + p
+	}
+	p, err := cleanSandboxPath(p)
+	if err != nil {
+		return "", err
+	}
+	if p != root && !strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/") {
+		return "", fmt.Errorf("path is outside %s", root)
+	}
+	return p, nil
+}
+
+// artifactRealpathScript prints the real paths of the artifacts path $1 and
+// of $2, and nothing if $2 doesn't exist.
+const artifactRealpathScript = `[ -e "$2" ] || exit 0; realpath -- "$1" "$2"`
+
+// realArtifactPath parses the output of artifactRealpathScript and returns
+// the real path of the file. It returns false if the file resolves outside
+// the real artifacts path, e.g. through a symlink, or the output doesn't
+// parse (a real path containing a newline).
+func realArtifactPath(out string) (string, bool) {
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "/") || !strings.HasPrefix(lines[1], "/") {
+		return "", false
+	}
+	if _, err := resolveArtifactPath(lines[0], lines[1]); err != nil {
+		return "", false
+	}
+	return lines[1], true
+}
+
+type createAccessTokenRequest struct {
+	Operations    []string `json:"operations"`
+	Command       string   `json:"command"`
+	ArtifactsPath string   `json:"artifacts_path"`
+	TTLSeconds    int      `json:"ttl_seconds"`
+	MaxUses       int      `json:"max_uses"`
+}
+
+// validate checks the request and fills in defaults.
+func (req *createAccessTokenRequest) validate() error {
+	if len(req.Operations) == 0 {
+		return fmt.Errorf("operations is required")
+	}
+	seen := make(map[string]bool, len(req.Operations))
+	for _, op := range req.Operations {
+		if op != db.AccessOpExec && op != db.AccessOpArtifacts {
+			return fmt.Errorf("unknown operation %q", op)
+		}
+		seen[op] = true
+	}
+	req.Operations = req.Operations[:0]
+	for _, op := range []string{db.AccessOpExec, db.AccessOpArtifacts} {
+		if seen[op] {
+			req.Operations = append(req.Operations, op)
+		}
+	}
+	if seen[db.AccessOpExec] {
+		if strings.TrimSpace(req.Command) == "" {
+			return fmt.Errorf("command is required for exec")
+		}
+		if len(req.Command) > maxAccessCommandLen {
+			return fmt.Errorf("command must be at most %d bytes", maxAccessCommandLen)
+		}
+	} else if req.Command != "" {
+		return fmt.Errorf("command requires the exec operation")
+	}
+	if seen[db.AccessOpArtifacts] {
+		p, err := cleanSandboxPath(req.ArtifactsPath)
+		if err != nil {
+			return fmt.Errorf("artifacts_path: %v", err)
+		}
+		req.ArtifactsPath = p
+	} else if req.ArtifactsPath != "" {
+		return fmt.Errorf("artifacts_path requires the artifacts operation")
+	}
+	if req.TTLSeconds == 0 {
+		req.TTLSeconds = int(accessTokenDefaultTTL / time.Second)
+	}
+	if req.TTLSeconds < 1 || req.TTLSeconds > int(accessTokenMaxTTL/time.Second) {
+		return fmt.Errorf("ttl_seconds must be between 1 and %d", int(accessTokenMaxTTL/time.Second))
+	}
+	if req.MaxUses < 0 {
+		return fmt.Errorf("max_uses must not be negative")
+	}
+	return nil
+}
+
+type accessTokenResponse struct {
+	*db.SandboxAccessToken
+	Token        string `json:"token"`
+	ExecURL      string `json:"exec_url,omitempty"`
+	ArtifactsURL string `json:"artifacts_url,omitempty"`
+}
+
+// POST /api/sandboxes/{id}/access-tokens mints a token (developer+). The
+// token is returned once, with the URLs of the operations it allows.
+func (s *Server) handleCreateSandboxAccessToken(w http.ResponseWriter, r *http.Request) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if sbx.IsLocal {
+		http.Error(w, "access tokens are not supported for local sandboxes", http.StatusBadRequest)
+		return
+	}
+	var req createAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := randomBase36(accessTokenLen)
+	if err != nil {
+		log.Printf("failed to generate access token: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	token := accessTokenPrefix + secret
+	t := &db.SandboxAccessToken{
+		ID:            uuid.NewString(),
+		SandboxID:     sbx.ID,
+		WorkspaceID:   sbx.WorkspaceID,
+		Operations:    req.Operations,
+		Command:       req.Command,
+		ArtifactsPath: req.ArtifactsPath,
+		ExpiresAt:     time.Now().Add(time.Duration(req.TTLSeconds) * time.Second),
+		MaxUses:       req.MaxUses,
+		CreatedBy:     auth.UserIDFromContext(r.Context()),
+	}
+	if err := s.DB.CreateSandboxAccessToken(t, hashAccessToken(token)); err != nil {
+		log.Printf("failed to create access token for sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to create access token", http.StatusInternalServerError)
+		return
+	}
+
+	resp := accessTokenResponse{SandboxAccessToken: t, Token: token}
+	base := requestOrigin(r) + "/api/ci/sandboxes/" + sbx.ID
+	if t.Allows(db.AccessOpExec) {
+		resp.ExecURL = base + "/exec?token=" + token
+	}
+	if t.Allows(db.AccessOpArtifacts) {
+		resp.ArtifactsURL = base + "/artifacts?token=" + token
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GET /api/sandboxes/{id}/access-tokens lists a sandbox's tokens, newest
+// first, without their secrets (developer+).
+func (s *Server) handleListSandboxAccessTokens(w http.ResponseWriter, r *http.Request) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	tokens, err := s.DB.ListSandboxAccessTokens(sbx.ID)
+	if err != nil {
+		log.Printf("failed to list access tokens of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to list access tokens", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// DELETE /api/sandboxes/{id}/access-tokens/{tokenID} revokes a token
+// (developer+).
+func (s *Server) handleRevokeSandboxAccessToken(w http.ResponseWriter, r *http.Request) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	ok, err := s.DB.RevokeSandboxAccessToken(sbx.ID, chi.URLParam(r, "tokenID"))
+	if err != nil {
+		log.Printf("failed to revoke access token of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to revoke access token", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "access token not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// accessTokenTarget authenticates a /api/ci request for op and resolves
+// its sandbox: the token must be valid for the sandbox in the URL and op,
+// within its rate limit, its creator enabled and still a developer+ of the
+// workspace, and the sandbox a running cloud sandbox. A valid request counts
+// as a use. It writes the error response and returns nil on failure.
+func (s *Server) accessTokenTarget(w http.ResponseWriter, r *http.Request, op string) (*db.SandboxAccessToken, *sbxstore.Sandbox, driveExecer) {
+	token := accessTokenFromRequest(r)
+	if !strings.HasPrefix(token, accessTokenPrefix) {
+		http.Error(w, "access token required", http.StatusUnauthorized)
+		return nil, nil, nil
+	}
+	hash := hashAccessToken(token)
+	if ok, retry := s.accessTokenLimiter.allow(hash, time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return nil, nil, nil
+	}
+	sandboxID := chi.URLParam(r, "id")
+	t, err := s.DB.UseSandboxAccessToken(hash, sandboxID, op)
+	if err != nil {
+		log.Printf("failed to check access token for sandbox %s: %v", sandboxID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, nil, nil
+	}
+	if t == nil {
+		http.Error(w, "invalid or expired access token", http.StatusUnauthorized)
+		return nil, nil, nil
+	}
+	role, err := s.DB.GetWorkspaceMemberRole(t.WorkspaceID, t.CreatedBy)
+	if err != nil {
+		log.Printf("failed to get role of %s in workspace %s: %v", t.CreatedBy, t.WorkspaceID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return nil, nil, nil
+	}
+	if role != "owner" && role != "maintainer" && role != "developer" {
+		http.Error(w, "the token's creator can no longer access this sandbox", http.StatusForbidden)
+		return nil, nil, nil
+	}
+	sbx, ok := s.Sandboxes.Get(sandboxID)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return nil, nil, nil
+	}
+	execer, ok := s.ProcessManager.(driveExecer)
+	if !ok || sbx.IsLocal {
+		http.Error(w, "access tokens are not supported for this sandbox", http.StatusNotImplemented)
+		return nil, nil, nil
+	}
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox is quarantined", http.StatusForbidden)
+		return nil, nil, nil
+	}
+	if sbx.Status != sbxstore.StatusRunning {
+		http.Error(w, "sandbox is not running", http.StatusConflict)
+		return nil, nil, nil
+	}
+	return t, sbx, execer
+}
+
+// POST /api/ci/sandboxes/{id}/exec runs the token's command in the sandbox
+// and returns its combined output and exit code. A failing command is not
+// an error: the response is 200 with a non-zero exit_code.
+func (s *Server) handleAccessTokenExec(w http.ResponseWriter, r *http.Request) {
+	t, sbx, execer := s.accessTokenTarget(w, r, db.AccessOpExec)
+	if t == nil {
+		return
+	}
+	log.Printf("sandbox %s: access token %s runs its command", sbx.ID, t.ID)
+	ctx, cancel := context.WithTimeout(r.Context(), accessExecTimeout)
+	defer cancel()
+	out, err := execer.ExecInput(ctx, sbx.ID, hookCommand(t.Command), nil)
+	output, status, ok := parseHookOutput(out)
+	if err != nil || !ok {
+		log.Printf("sandbox %s: access token %s exec failed: %v", sbx.ID, t.ID, err)
+		http.Error(w, "failed to run command", http.StatusBadGateway)
+		return
+	}
+	truncated := len(output) > maxAccessExecOutput
+	if truncated {
+		output = output[len(output)-maxAccessExecOutput:]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"exit_code": status,
+		"output":    strings.ToValidUTF8(output, "�"),
+		"truncated": truncated,
+	})
+}
+
+// GET /api/ci/sandboxes/{id}/artifacts?path= downloads a file under the
+// token's artifacts path, or lists a directory. path is relative to the
+// artifacts path and defaults to it.
+func (s *Server) handleAccessTokenArtifacts(w http.ResponseWriter, r *http.Request) {
+	t, sbx, execer := s.accessTokenTarget(w, r, db.AccessOpArtifacts)
+	if t == nil {
+		return
+	}
+	p, err := resolveArtifactPath(t.ArtifactsPath, r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxFileTimeout)
+	defer cancel()
+	// The checks above are lexical: resolve symlinks in the sandbox and
+	// check again, then only use the real path.
+	out, err := execer.ExecInput(ctx, sbx.ID, []string{"sh", "-c", artifactRealpathScript, "sh", t.ArtifactsPath, p}, nil)
+	if err != nil {
+		log.Printf("sandbox %s: access token %s: resolve %s: %v", sbx.ID, t.ID, p, err)
+		http.Error(w, "failed to read artifact", http.StatusBadGateway)
+		return
+	}
+	if out == "" {
+		http.Error(w, "no such file or directory", http.StatusNotFound)
+		return
+	}
+	realPath, ok := realArtifactPath(out)
+	if !ok {
+		log.Printf("sandbox %s: access token %s: %s resolves outside %s: %q", sbx.ID, t.ID, p, t.ArtifactsPath, out)
+		http.Error(w, "path is outside "+t.ArtifactsPath, http.StatusBadRequest)
+		return
+	}
+	f, err := statSandboxFile(ctx, execer, sbx.ID, realPath)
+	if err != nil {
+		log.Printf("sandbox %s: access token %s: stat %s: %v", sbx.ID, t.ID, p, err)
+		http.Error(w, "failed to read artifact", http.StatusBadGateway)
+		return
+	}
+	if f == nil {
+		http.Error(w, "no such file or directory", http.StatusNotFound)
+		return
+	}
+
+	switch f.Type {
+	case "dir":
+		out, err := execer.ExecInput(ctx, sbx.ID, []string{"sh", "-c", sandboxListScript, "sh", realPath}, nil)
+		if err != nil {
+			log.Printf("sandbox %s: access token %s: list %s: %v", sbx.ID, t.ID, p, err)
+			http.Error(w, "failed to list artifacts", http.StatusBadGateway)
+			return
+		}
+		files := []sandboxFile{}
+		for _, line := range strings.Split(out, "\n") {
+			if f, ok := parseSandboxFile(p, line); ok {
+				files = append(files, f)
+			}
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+		truncated := len(files) > maxSandboxDirEntries
+		if truncated {
+			files = files[:maxSandboxDirEntries]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":      p,
+			"files":     files,
+			"truncated": truncated,
+		})
+	case "file":
+		copier, ok := s.ProcessManager.(sandboxFileCopier)
+		if !ok {
+			http.Error(w, "file download is not supported by this backend", http.StatusNotImplemented)
+			return
+		}
+		if f.Size > maxSandboxFileSize {
+			http.Error(w, fmt.Sprintf("file is larger than %d bytes", maxSandboxFileSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		data, err := copier.CopyFromSandbox(ctx, sbx.ID, realPath)
+		if err != nil {
+			log.Printf("sandbox %s: access token %s: download %s: %v", sbx.ID, t.ID, p, err)
+			http.Error(w, "failed to download artifact", http.StatusBadGateway)
+			return
+		}
+		if len(data) > maxSandboxFileSize {
+			http.Error(w, fmt.Sprintf("file is larger than %d bytes", maxSandboxFileSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(p)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	default:
+		// Symlinks are not followed, so they can't point out of the
+		// artifacts path.
+		http.Error(w, "not a regular file or directory", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestResolveArtifactPath(t *testing.T) {
+	tests := []struct {
+		root, path, want string
+		wantErr          bool
+	}{
+		{"/workspace/out", "", "/workspace/out", false},
+		{"/workspace/out", "report.xml", "/workspace/out/report.xml", false},
+		{"/workspace/out", "logs/../junit.xml", "/workspace/out/junit.xml", false},
+		{"/workspace/out", "/workspace/out/a/b", "/workspace/out/a/b", false},
+		{"/workspace/out", "/workspace/out", "/workspace/out", false},
+		{"/workspace/out", "../secret", "", true},
+		{"/workspace/out", "/workspace/outside", "", true},
+		{"/workspace/out", "/etc/passwd", "", true},
+		{"/workspace/out", "a\x00b", "", true},
+		{"/", "etc/hosts", "/etc/hosts", false},
+	}
+	for _, tt := range tests {
+		got, err := resolveArtifactPath(tt.root, tt.path)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolveArtifactPath(%q, %q) = %q, %v; want %q", tt.root, tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestCreateAccessTokenRequestValidate(t *testing.T) {
+	req := createAccessTokenRequest{Operations: []string{"artifacts", "exec", "exec"}, Command: "make test", ArtifactsPath: "/workspace/out/"}
+	if err := req.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Operations) != 2 || req.Operations[0] != db.AccessOpExec || req.Operations[1] != db.AccessOpArtifacts {
+		t.Errorf("operations = %v", req.Operations)
+	}
+	if req.ArtifactsPath != "/workspace/out" || req.TTLSeconds != int(accessTokenDefaultTTL/time.Second) {
+		t.Errorf("artifacts path %q, ttl %d", req.ArtifactsPath, req.TTLSeconds)
+	}
+
+	for name, bad := range map[string]createAccessTokenRequest{
+		"no operations":         {},
+		"unknown operation":     {Operations: []string{"shell"}},
+		"exec without command":  {Operations: []string{"exec"}},
+		"command without exec":  {Operations: []string{"artifacts"}, ArtifactsPath: "/out", Command: "ls"},
+		"relative path":         {Operations: []string{"artifacts"}, ArtifactsPath: "out"},
+		"path without artifact": {Operations: []string{"exec"}, Command: "ls", ArtifactsPath: "/out"},
+		"ttl too long":          {Operations: []string{"exec"}, Command: "ls", TTLSeconds: 7200},
+		"negative max uses":     {Operations: []string{"exec"}, Command: "ls", MaxUses: -1},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestAccessTokenLimiter(t *testing.T) {
+	var l accessTokenLimiter
+	now := time.Now()
+	for i := 0; i < accessTokenRateLimit; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	ok, retry := l.allow("a", now.Add(10*time.Second))
+	if ok || retry != accessTokenRateWindow-10*time.Second {
+		t.Errorf("over the limit: allow = %v, retry after %v", ok, retry)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("other token limited")
+	}
+	if ok, _ := l.allow("a", now.Add(accessTokenRateWindow)); !ok {
+		t.Error("still limited in the next window")
+	}
+}
+
+func TestAccessTokenFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/ci/sandboxes/x/artifacts?token=sat_query", nil)
+	if got := accessTokenFromRequest(r); got != "sat_query" {
+		t.Errorf("query token = %q", got)
+	}
+	r.Header.Set("Authorization", "Bearer sat_header")
+	if got := accessTokenFromRequest(r); got != "sat_header" {
+		t.Errorf("header token = %q", got)
+	}
+}
+
+func TestRealArtifactPath(t *testing.T) {
+	tests := []struct {
+		out, want string
+		ok        bool
+	}{
+		{"/workspace/out\n/workspace/out/report.xml\n", "/workspace/out/report.xml", true},
+		{"/workspace/out\n/workspace/out\n", "/workspace/out", true},
+		// A symlink out of the artifacts path.
+		{"/workspace/out\n/home/agent/.ssh/id_ed25519\n", "", false},
+		{"/workspace/out\n/workspace/outside\n", "", false},
+		// The artifacts path is itself a symlink.
+		{"/data/out\n/data/out/junit.xml\n", "/data/out/junit.xml", true},
+		// A real path containing a newline.
+		{"/workspace/out\n/workspace/out/a\n/etc/passwd\n", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := realArtifactPath(tt.out)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("realArtifactPath(%q) = %q, %v; want %q, %v", tt.out, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestArtifactRealpathScript(t *testing.T) {
+	if _, err := exec.LookPath("realpath"); err != nil {
+		t.Skip("realpath not installed")
+	}
+	dir := t.TempDir()
+	root := filepath.Join(dir, "out")
+	if err := os.MkdirAll(filepath.Join(root, "logs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "secret"), []byte("x"), 0o600)
+	os.WriteFile(filepath.Join(root, "report.xml"), []byte("x"), 0o644)
+	os.Symlink(filepath.Join(dir, "secret"), filepath.Join(root, "leak"))
+	os.Symlink("..", filepath.Join(root, "logs", "up"))
+	os.Symlink("report.xml", filepath.Join(root, "latest.xml"))
+
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{root + "/report.xml", root + "/report.xml", true},
+		{root + "/latest.xml", root + "/report.xml", true},
+		{root + "/leak", "", false},
+		{root + "/logs/up/report.xml", root + "/report.xml", true},
+		{root + "/logs/up/logs/up/..", "", false},
+	}
+	for _, tt := range tests {
+		out, err := exec.Command("sh", "-c", artifactRealpathScript, "sh", root, tt.path).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		got, ok := realArtifactPath(string(out))
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+	if out, _ := exec.Command("sh", "-c", artifactRealpathScript, "sh", root, root+"/missing").Output(); len(out) != 0 {
+		t.Errorf("missing file: output %q, want none", out)
+	}
+}
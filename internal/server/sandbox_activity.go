@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Time tracking: the sandbox proxy records intervals of user activity
+// (requests to a sandbox) and agent activity (opencode events streamed to
+// a user), see db.RecordSandboxActivity. These handlers sum them per
+// sandbox and user for a period, so teams can report the time spent on a
+// project. Owners and maintainers see everyone's time, other members only
+// their own.
+
+// defaultActivityPeriod is the period reported when since is not given.
+const defaultActivityPeriod = 30 * 24 * time.Hour
+
+// maxActivityPeriod bounds the period of an activity report.
+const maxActivityPeriod = 366 * 24 * time.Hour
+
+// activityRow is a line of an activity report. Rows grouped by sandbox
+// leave out the user, rows grouped by user the sandbox.
+type activityRow struct {
+	SandboxID    string `json:"sandbox_id,omitempty"`
+	SandboxName  string `json:"sandbox_name,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+	Email        string `json:"email,omitempty"`
+	UserSeconds  int64  `json:"user_seconds"`
+	AgentSeconds int64  `json:"agent_seconds"`
+}
+
+// groupActivity sums per-sandbox, per-user totals by sandbox or by user;
+// an empty groupBy keeps one row per sandbox and user. Rows keep the order
+// of their first total.
+func groupActivity(totals []db.SandboxActivityTotal, groupBy string) []activityRow {
+	rows := []activityRow{}
+	index := make(map[string]int)
+	for _, t := range totals {
+		row := activityRow{SandboxID: t.SandboxID, SandboxName: t.SandboxName, UserID: t.UserID, Email: t.Email}
+		key := t.SandboxID + "|" + t.UserID
+		switch groupBy {
+		case "sandbox":
+			row.UserID, row.Email, key = "", "", t.SandboxID
+		case "user":
+			row.SandboxID, row.SandboxName, key = "", "", t.UserID
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			rows = append(rows, row)
+		}
+		rows[i].UserSeconds += t.UserSeconds
+		rows[i].AgentSeconds += t.AgentSeconds
+	}
+	return rows
+}
+
+// parseActivityPeriod reads since and until (RFC 3339) of an activity
+// report. until defaults to now and since to 30 days before until.
+func parseActivityPeriod(r *http.Request, now time.Time) (since, until time.Time, err error) {
+	q := r.URL.Query()
+	until = now
+	if v := q.Get("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			return since, until, fmt.Errorf("until: %v", err)
+		}
+	}
+	since = until.Add(-defaultActivityPeriod)
+	if v := q.Get("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			return since, until, fmt.Errorf("since: %v", err)
+		}
+	}
+	if !since.Before(until) {
+		return since, until, fmt.Errorf("since must be before until")
+	}
+	if until.Sub(since) > maxActivityPeriod {
+		return since, until, fmt.Errorf("period must be at most 366 days")
+	}
+	return since, until, nil
+}
+
+func writeActivityCSV(w io.Writer, since, until time.Time, rows []activityRow) error {
+	hours := func(seconds int64) string { return strconv.FormatFloat(float64(seconds)/3600, 'f', 2, 64) }
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"since", since.UTC().Format(time.RFC3339), "", "", "", ""})
+	cw.Write([]string{"until", until.UTC().Format(time.RFC3339), "", "", "", ""})
+	cw.Write([]string{"sandbox_id", "sandbox_name", "user_id", "email", "user_hours", "agent_hours"})
+	for _, row := range rows {
+		cw.Write([]string{row.SandboxID, row.SandboxName, row.UserID, row.Email, hours(row.UserSeconds), hours(row.AgentSeconds)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// serveActivity writes the activity report of f (workspace and optionally
+// sandbox set) for a member with the given role.
+func (s *Server) serveActivity(w http.ResponseWriter, r *http.Request, f db.SandboxActivityFilter, role string) {
+	var err error
+	f.Since, f.Until, err = parseActivityPeriod(r, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy != "" && groupBy != "sandbox" && groupBy != "user" {
+		http.Error(w, "group_by must be sandbox or user", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	if role != "owner" && role != "maintainer" {
+		f.UserID = auth.UserIDFromContext(r.Context())
+	}
+
+	totals, err := s.DB.SandboxActivityTotals(f)
+	if err != nil {
+		log.Printf("failed to sum activity of workspace %s: %v", f.WorkspaceID, err)
+		http.Error(w, "failed to get activity", http.StatusInternalServerError)
+		return
+	}
+	rows := groupActivity(totals, groupBy)
+	if format == "csv" {
+		name := shortID(f.WorkspaceID)
+		if f.SandboxID != "" {
+			name = shortID(f.SandboxID)
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="activity-%s-%s.csv"`, name, f.Until.UTC().Format("2006-01-02")))
+		writeActivityCSV(w, f.Since, f.Until, rows)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":    f.Since,
+		"until":    f.Until,
+		"group_by": groupBy,
+		"activity": rows,
+	})
+}
+
+// GET /api/workspaces/{id}/activity?since=&until=&group_by=sandbox|user&format=json|csv
+// returns the active time in the workspace's sandboxes, including deleted
+// ones.
+func (s *Server) handleWorkspaceActivity(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	role, ok := s.requireWorkspaceMember(w, r, wsID)
+	if !ok {
+		return
+	}
+	s.serveActivity(w, r, db.SandboxActivityFilter{WorkspaceID: wsID}, role)
+}
+
+// GET /api/sandboxes/{id}/activity?since=&until=&group_by=user&format=json|csv
+// returns the active time in a sandbox.
+func (s *Server) handleSandboxActivity(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	role, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID)
+	if !ok {
+		return
+	}
+	s.serveActivity(w, r, db.SandboxActivityFilter{WorkspaceID: sbx.WorkspaceID, SandboxID: id}, role)
+}
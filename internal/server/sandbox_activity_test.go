@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestGroupActivity(t *testing.T) {
+	totals := []db.SandboxActivityTotal{
+		{SandboxID: "s1", SandboxName: "api", UserID: "u1", Email: "a@example.com", UserSeconds: 600, AgentSeconds: 60},
+		{SandboxID: "s1", SandboxName: "api", UserID: "u2", Email: "b@example.com", UserSeconds: 300},
+		{SandboxID: "s2", SandboxName: "web", UserID: "u1", Email: "a@example.com", AgentSeconds: 120},
+	}
+	if rows := groupActivity(totals, ""); len(rows) != 3 || rows[1].UserID != "u2" || rows[1].SandboxName != "api" {
+		t.Errorf("ungrouped = %+v", rows)
+	}
+	bySandbox := groupActivity(totals, "sandbox")
+	if len(bySandbox) != 2 || bySandbox[0] != (activityRow{SandboxID: "s1", SandboxName: "api", UserSeconds: 900, AgentSeconds: 60}) {
+		t.Errorf("by sandbox = %+v", bySandbox)
+	}
+	byUser := groupActivity(totals, "user")
+	if len(byUser) != 2 || byUser[0] != (activityRow{UserID: "u1", Email: "a@example.com", UserSeconds: 600, AgentSeconds: 180}) {
+		t.Errorf("by user = %+v", byUser)
+	}
+
+	var buf bytes.Buffer
+	since := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := writeActivityCSV(&buf, since, since.AddDate(0, 1, 0), byUser); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ",u1,a@example.com,0.17,0.05\n") {
+		t.Errorf("csv = %q", buf.String())
+	}
+}
+
+func TestParseActivityPeriod(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	since, until, err := parseActivityPeriod(httptest.NewRequest("GET", "/activity", nil), now)
+	if err != nil || !until.Equal(now) || !since.Equal(now.Add(-defaultActivityPeriod)) {
+		t.Errorf("defaults = %v, %v, %v", since, until, err)
+	}
+	since, until, err = parseActivityPeriod(httptest.NewRequest("GET", "/activity?since=2030-05-01T00:00:00Z&until=2030-05-08T00:00:00Z", nil), now)
+	if err != nil || until.Sub(since) != 7*24*time.Hour {
+		t.Errorf("explicit = %v, %v, %v", since, until, err)
+	}
+	for _, q := range []string{
+		"since=yesterday",
+		"since=2030-05-08T00:00:00Z&until=2030-05-01T00:00:00Z",
+		"since=2020-01-01T00:00:00Z",
+	} {
+		if _, _, err := parseActivityPeriod(httptest.NewRequest("GET", "/activity?"+q, nil), now); err == nil {
+			t.Errorf("%s accepted", q)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// sessionCloner is implemented by backends that can copy one sandbox's
+// session data (home directory) to a new sandbox before it starts: a PVC
+// cloned from the source's session-data PVC on K8s, a volume copy on
+// Docker.
+type sessionCloner interface {
+	CloneSessionData(ctx context.Context, srcID, dstID string) error
+}
+
+// clonedFromKey is the metadata key recording the sandbox a clone was made
+// from.
+const clonedFromKey = "cloned_from"
+
+// cloneSourceKey carries the source sandbox of a clone into createSandbox.
+type cloneSourceKey struct{}
+
+// cloneCreateRequest builds the create request of a clone of src: the same
+// type, resources, idle timeout, template, projects, proxy scope and LLM
+// providers, and the rest of its metadata.
+func cloneCreateRequest(src *sbxstore.Sandbox, name string) map[string]interface{} {
+	if name == "" {
+		name = src.Name + " (copy)"
+	}
+	req := map[string]interface{}{"name": name, "type": src.Type}
+	if src.CPU > 0 {
+		req["cpu"] = src.CPU
+	}
+	if src.Memory > 0 {
+		req["memory"] = src.Memory
+	}
+	if src.IdleTimeout != nil {
+		req["idle_timeout"] = *src.IdleTimeout
+	}
+	metadata := make(map[string]interface{}, len(src.Metadata)+1)
+	for k, v := range src.Metadata {
+		switch k {
+		case workspaceTemplateKey:
+			req["template_id"] = v
+		case sandboxTemplateKey:
+			req["template"] = v
+		case "opencode_projects":
+			req["projects"] = v
+		case proxyScopeKey:
+			req["proxy_scope"] = v
+		case process.LLMProvidersMetadataKey:
+			req["llm_providers"] = v
+		case process.RegionMetadataKey, clonedFromKey:
+			// Set by createSandbox and below.
+		default:
+			metadata[k] = v
+		}
+	}
+	// Both are recorded for a workspace template that came from a bundle;
+	// they are mutually exclusive in a request.
+	if _, ok := req["template_id"]; ok {
+		delete(req, "template")
+	}
+	metadata[clonedFromKey] = src.ID
+	req["metadata"] = metadata
+	return req
+}
+
+// POST /api/sandboxes/{id}/clone {"name": "..."} creates a sandbox in the
+// same workspace with the settings of the sandbox and a copy of its session
+// data, to try something else in parallel. The name defaults to that of the
+// sandbox with " (copy)". Running sandboxes get a crash-consistent copy.
+func (s *Server) handleCloneSandbox(w http.ResponseWriter, r *http.Request) {
+	src, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, src.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if src.IsLocal {
+		http.Error(w, "local sandboxes cannot be cloned", http.StatusBadRequest)
+		return
+	}
+	if src.QuarantinedAt != nil {
+		http.Error(w, "sandbox is quarantined", http.StatusForbidden)
+		return
+	}
+	if _, ok := s.ProcessManager.(sessionCloner); !ok {
+		http.Error(w, "sandbox cloning is not supported by this backend", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if src.Status != sbxstore.StatusRunning && src.Status != sbxstore.StatusPaused {
+		http.Error(w, "sandbox cannot be cloned in current state: "+src.Status, http.StatusConflict)
+		return
+	}
+	if _, busy := s.snapshotOps.Load(src.ID); busy {
+		http.Error(w, "sandbox is being snapshotted, restored or migrated", http.StatusConflict)
+		return
+	}
+
+	// Create the clone like any other sandbox, so permissions, quotas and
+	// policy apply; provisionAndStart copies the data before it starts.
+	body, err := json.Marshal(cloneCreateRequest(src, req.Name))
+	if err != nil {
+		log.Printf("failed to build clone request of sandbox %s: %v", src.ID, err)
+		http.Error(w, "failed to clone sandbox", http.StatusInternalServerError)
+		return
+	}
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("wid", src.WorkspaceID)
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	ctx = context.WithValue(ctx, cloneSourceKey{}, src.ID)
+	cr := r.Clone(ctx)
+	cr.Body = io.NopCloser(bytes.NewReader(body))
+	s.createSandbox(w, cr, false)
+}
+
+// cloneSessionData copies the session data of the sandbox a new sandbox is
+// cloned from, if it is a clone. The source is marked busy meanwhile, so it
+// isn't restored or migrated under the copy.
+func (s *Server) cloneSessionData(id string) error {
+	v, ok := s.cloneSources.Load(id)
+	if !ok {
+		return nil
+	}
+	srcID := v.(string)
+	cloner, ok := s.ProcessManager.(sessionCloner)
+	if !ok {
+		return fmt.Errorf("clone of %s: backend cannot clone session data", srcID)
+	}
+	if _, busy := s.snapshotOps.LoadOrStore(srcID, struct{}{}); busy {
+		return fmt.Errorf("clone of %s: source sandbox is being snapshotted, restored or migrated", srcID)
+	}
+	defer s.snapshotOps.Delete(srcID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sandboxSnapshotTimeout)
+	defer cancel()
+	if err := cloner.CloneSessionData(ctx, srcID, id); err != nil {
+		return fmt.Errorf("clone session data of %s: %w", srcID, err)
+	}
+	return nil
+}
+
+// finishClone forgets the source of a clone once its start succeeded or
+// failed for good. A failed start removes the copied data, so a start that
+// is retried (unschedulable) copies it again.
+func (s *Server) finishClone(id string, startErr error) {
+	if startErr == nil || !errors.Is(startErr, process.ErrUnschedulable) {
+		s.cloneSources.Delete(id)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+// TestIntegration_CloneRefusesQuarantinedSource checks that a quarantined
+// sandbox cannot be cloned, so its data doesn't escape into a sandbox with
+// network access.
+func TestIntegration_CloneRefusesQuarantinedSource(t *testing.T) {
+	d := testenv.DB(t)
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d)}
+
+	wsID := uuid.NewString()
+	userID := uuid.NewString()
+	sbxID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, userID, "owner")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+		d.Exec(`DELETE FROM sandbox_quarantine_events WHERE sandbox_id = $1`, sbxID)
+	})
+	if err := d.CreateSandbox(sbxID, wsID, "suspect", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusRunning)
+	if err := d.SetSandboxQuarantined(sbxID, wsID, true, "exfiltration", ""); err != nil {
+		t.Fatal(err)
+	}
+	countSandboxes := func() int {
+		var n int
+		d.QueryRow(`SELECT COUNT(*) FROM sandboxes WHERE workspace_id = $1`, wsID).Scan(&n)
+		return n
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", sbxID)
+	ctx := context.WithValue(auth.ContextWithUserID(context.Background(), userID), chi.RouteCtxKey, rctx)
+	req := httptest.NewRequest(http.MethodPost, "/api/sandboxes/"+sbxID+"/clone", strings.NewReader(`{"name":"copy"}`)).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	s.handleCloneSandbox(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("clone of a quarantined sandbox: status %d (%s), want 403", rr.Code, rr.Body.String())
+	}
+	if n := countSandboxes(); n != 1 {
+		t.Errorf("%d sandboxes in workspace after refused clone, want 1", n)
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestCloneCreateRequest(t *testing.T) {
+	idle := 600
+	src := &sbxstore.Sandbox{
+		ID: "src", Name: "dev", Type: "opencode", CPU: 2000, Memory: 4 << 30, IdleTimeout: &idle,
+		Metadata: map[string]interface{}{
+			workspaceTemplateKey:            "tmpl1",
+			sandboxTemplateKey:              "python",
+			"opencode_projects":             []interface{}{"/home/agent/api"},
+			process.LLMProvidersMetadataKey: []interface{}{"anthropic"},
+			process.RegionMetadataKey:       "eu",
+			clonedFromKey:                   "older",
+			"assistant_name":                "Andy",
+		},
+	}
+	want := map[string]interface{}{
+		"name":          "dev (copy)",
+		"type":          "opencode",
+		"cpu":           2000,
+		"memory":        int64(4 << 30),
+		"idle_timeout":  600,
+		"template_id":   "tmpl1",
+		"projects":      []interface{}{"/home/agent/api"},
+		"llm_providers": []interface{}{"anthropic"},
+		"metadata":      map[string]interface{}{"assistant_name": "Andy", clonedFromKey: "src"},
+	}
+	if got := cloneCreateRequest(src, ""); !reflect.DeepEqual(got, want) {
+		t.Errorf("cloneCreateRequest = %#v\nwant %#v", got, want)
+	}
+
+	got := cloneCreateRequest(&sbxstore.Sandbox{ID: "src", Name: "dev", Type: "jupyter",
+		Metadata: map[string]interface{}{sandboxTemplateKey: "python"}}, "experiment")
+	if got["name"] != "experiment" || got["template"] != "python" || got["cpu"] != nil || got["idle_timeout"] != nil {
+		t.Errorf("cloneCreateRequest = %#v", got)
+	}
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// File browser: list, download, upload and delete files of a running cloud
+// sandbox, so the web UI can offer a file explorer and users can pull
+// artifacts out without a terminal. Listing, stat and delete run as execs
+// in the sandbox; file contents are copied by the backend (docker cp on
+// Docker, an exec on Kubernetes).
+
+const (
+	// maxSandboxFileSize bounds downloads and uploads.
+	maxSandboxFileSize = 32 << 20
+	// maxSandboxDirEntries bounds a directory listing.
+	maxSandboxDirEntries = 5000
+	// sandboxFileTimeout bounds each file operation.
+	sandboxFileTimeout = 2 * time.Minute
+)
+
+// sandboxFileCopier copies file contents in and out of a sandbox.
+type sandboxFileCopier interface {
+	CopyFromSandbox(ctx context.Context, sandboxID, filePath string) ([]byte, error)
+	CopyToSandbox(ctx context.Context, sandboxID, filePath string, data []byte) error
+}
+
+// sandboxFile is an entry of a sandbox directory.
+type sandboxFile struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Type       string    `json:"type"` // file, dir, symlink or other
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// sandboxFileStatFormat is the stat -c format parsed by parseSandboxFile.
+// The name goes last so that it may contain the separator.
+const sandboxFileStatFormat = `%F|%s|%a|%Y|%n`
+
+// sandboxStatScript prints the stat line of $1, and nothing if it doesn't
+// exist.
+const sandboxStatScript = `[ -e "$1" ] || [ -L "$1" ] || exit 0; stat -c '` + sandboxFileStatFormat + `' -- "$1"`
+
+// sandboxListScript prints the stat line of every entry of the directory
+// $1, including hidden ones.
+const sandboxListScript = `cd -- "$1" || exit 1; for f in * .[!.]* ..?*; do [ -e "$f" ] || [ -L "$f" ] || continue; stat -c '` + sandboxFileStatFormat + `' -- "$f"; done`
+
+// parseSandboxFile parses a stat line of an entry of dir. Lines that don't
+// parse (e.g. a name containing a newline) return false.
+func parseSandboxFile(dir, line string) (sandboxFile, bool) {
+	parts := strings.SplitN(line, "|", 5)
+	if len(parts) != 5 || parts[4] == "" {
+		return sandboxFile{}, false
+	}
+	size, err1 := strconv.ParseInt(parts[1], 10, 64)
+	mtime, err2 := strconv.ParseInt(parts[3], 10, 64)
+	if err1 != nil || err2 != nil {
+		return sandboxFile{}, false
+	}
+	f := sandboxFile{
+		Name:       path.Base(parts[4]),
+		Path:       path.Join(dir, parts[4]),
+		Size:       size,
+		Mode:       parts[2],
+		ModifiedAt: time.Unix(mtime, 0).UTC(),
+	}
+	switch parts[0] {
+	case "regular file", "regular empty file":
+		f.Type = "file"
+	case "directory":
+		f.Type = "dir"
+	case "symbolic link":
+		f.Type = "symlink"
+	default:
+		f.Type = "other"
+	}
+	return f, true
+}
+
+// cleanSandboxPath validates an absolute path of a sandbox file.
+func cleanSandboxPath(p string) (string, error) {
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path must be absolute")
+	}
+	if strings.ContainsAny(p, "\x00\n") {
+		return "", fmt.Errorf("invalid path")
+	}
+	return path.Clean(p), nil
+}
+
+// sandboxFileTarget resolves the sandbox and path of a file request
+// (developer+, running cloud sandboxes only). It writes the error response
+// and returns nil on failure.
+func (s *Server) sandboxFileTarget(w http.ResponseWriter, r *http.Request) (driveExecer, *sbxstore.Sandbox, string) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return nil, nil, ""
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return nil, nil, ""
+	}
+	execer, ok := s.ProcessManager.(driveExecer)
+	if !ok || sbx.IsLocal {
+		http.Error(w, "file browsing is not supported for this sandbox", http.StatusNotImplemented)
+		return nil, nil, ""
+	}
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox is quarantined", http.StatusForbidden)
+		return nil, nil, ""
+	}
+	if sbx.Status != sbxstore.StatusRunning {
+		http.Error(w, "sandbox is not running", http.StatusConflict)
+		return nil, nil, ""
+	}
+	p, err := cleanSandboxPath(r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, ""
+	}
+	return execer, sbx, p
+}
+
+// statSandboxFile returns the entry at p, or nil if there is none.
+func statSandboxFile(ctx context.Context, execer driveExecer, sandboxID, p string) (*sandboxFile, error) {
+	out, err := execer.ExecInput(ctx, sandboxID, []string{"sh", "-c", sandboxStatScript, "sh", p}, nil)
+	if err != nil {
+		return nil, err
+	}
+	out = strings.TrimSuffix(out, "\n")
+	if out == "" {
+		return nil, nil
+	}
+	f, ok := parseSandboxFile(path.Dir(p), out)
+	if !ok {
+		return nil, fmt.Errorf("unexpected stat output %q", out)
+	}
+	f.Path = p
+	return &f, nil
+}
+
+// GET /api/sandboxes/{id}/files?path= lists a directory, directories first.
+func (s *Server) handleListSandboxFiles(w http.ResponseWriter, r *http.Request) {
+	execer, sbx, p := s.sandboxFileTarget(w, r)
+	if sbx == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxFileTimeout)
+	defer cancel()
+	dir, err := statSandboxFile(ctx, execer, sbx.ID, p)
+	if err != nil {
+		log.Printf("file browser: stat %s in sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to list files", http.StatusBadGateway)
+		return
+	}
+	if dir == nil {
+		http.Error(w, "no such file or directory", http.StatusNotFound)
+		return
+	}
+	if dir.Type != "dir" {
+		http.Error(w, "not a directory", http.StatusBadRequest)
+		return
+	}
+	out, err := execer.ExecInput(ctx, sbx.ID, []string{"sh", "-c", sandboxListScript, "sh", p}, nil)
+	if err != nil {
+		log.Printf("file browser: list %s in sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to list files", http.StatusBadGateway)
+		return
+	}
+	files := []sandboxFile{}
+	for _, line := range strings.Split(out, "\n") {
+		if f, ok := parseSandboxFile(p, line); ok {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if (files[i].Type == "dir") != (files[j].Type == "dir") {
+			return files[i].Type == "dir"
+		}
+		return files[i].Name < files[j].Name
+	})
+	truncated := len(files) > maxSandboxDirEntries
+	if truncated {
+		files = files[:maxSandboxDirEntries]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"path":      p,
+		"files":     files,
+		"truncated": truncated,
+	})
+}
+
+// GET /api/sandboxes/{id}/files/download?path= downloads a regular file.
+func (s *Server) handleDownloadSandboxFile(w http.ResponseWriter, r *http.Request) {
+	execer, sbx, p := s.sandboxFileTarget(w, r)
+	if sbx == nil {
+		return
+	}
+	copier, ok := s.ProcessManager.(sandboxFileCopier)
+	if !ok {
+		http.Error(w, "file download is not supported by this backend", http.StatusNotImplemented)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxFileTimeout)
+	defer cancel()
+	f, err := statSandboxFile(ctx, execer, sbx.ID, p)
+	if err != nil {
+		log.Printf("file browser: stat %s in sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to download file", http.StatusBadGateway)
+		return
+	}
+	if f == nil {
+		http.Error(w, "no such file", http.StatusNotFound)
+		return
+	}
+	if f.Type != "file" {
+		http.Error(w, "not a regular file", http.StatusBadRequest)
+		return
+	}
+	if f.Size > maxSandboxFileSize {
+		http.Error(w, fmt.Sprintf("file is larger than %d bytes", maxSandboxFileSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	data, err := copier.CopyFromSandbox(ctx, sbx.ID, p)
+	if err != nil {
+		log.Printf("file browser: download %s from sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to download file", http.StatusBadGateway)
+		return
+	}
+	if len(data) > maxSandboxFileSize {
+		http.Error(w, fmt.Sprintf("file is larger than %d bytes", maxSandboxFileSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", f.Name))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// PUT /api/sandboxes/{id}/files/upload?path= writes the request body to a
+// file, replacing it if it exists. The parent directory must exist.
+func (s *Server) handleUploadSandboxFile(w http.ResponseWriter, r *http.Request) {
+	execer, sbx, p := s.sandboxFileTarget(w, r)
+	if sbx == nil {
+		return
+	}
+	copier, ok := s.ProcessManager.(sandboxFileCopier)
+	if !ok {
+		http.Error(w, "file upload is not supported by this backend", http.StatusNotImplemented)
+		return
+	}
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, maxSandboxFileSize)); err != nil {
+		http.Error(w, fmt.Sprintf("file must be at most %d bytes", maxSandboxFileSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxFileTimeout)
+	defer cancel()
+	parent, err := statSandboxFile(ctx, execer, sbx.ID, path.Dir(p))
+	if err != nil {
+		log.Printf("file browser: stat %s in sandbox %s: %v", path.Dir(p), sbx.ID, err)
+		http.Error(w, "failed to upload file", http.StatusBadGateway)
+		return
+	}
+	if parent == nil || parent.Type != "dir" {
+		http.Error(w, "parent directory does not exist", http.StatusConflict)
+		return
+	}
+	if existing, err := statSandboxFile(ctx, execer, sbx.ID, p); err == nil && existing != nil && existing.Type != "file" {
+		http.Error(w, "path exists and is not a regular file", http.StatusConflict)
+		return
+	}
+	if err := copier.CopyToSandbox(ctx, sbx.ID, p, body.Bytes()); err != nil {
+		log.Printf("file browser: upload %s to sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to upload file", http.StatusBadGateway)
+		return
+	}
+	f, err := statSandboxFile(ctx, execer, sbx.ID, p)
+	if err != nil || f == nil {
+		f = &sandboxFile{Name: path.Base(p), Path: p, Type: "file", Size: int64(body.Len())}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
+}
+
+// DELETE /api/sandboxes/{id}/files?path= deletes a file, an empty
+// directory, or with recursive=true a directory and its contents.
+func (s *Server) handleDeleteSandboxFile(w http.ResponseWriter, r *http.Request) {
+	execer, sbx, p := s.sandboxFileTarget(w, r)
+	if sbx == nil {
+		return
+	}
+	if p == "/" {
+		http.Error(w, "refusing to delete /", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxFileTimeout)
+	defer cancel()
+	f, err := statSandboxFile(ctx, execer, sbx.ID, p)
+	if err != nil {
+		log.Printf("file browser: stat %s in sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to delete file", http.StatusBadGateway)
+		return
+	}
+	if f == nil {
+		http.Error(w, "no such file or directory", http.StatusNotFound)
+		return
+	}
+	cmd := []string{"rm", "-f", "--", p}
+	if f.Type == "dir" {
+		cmd = []string{"rmdir", "--", p}
+		if r.URL.Query().Get("recursive") == "true" {
+			cmd = []string{"rm", "-rf", "--", p}
+		}
+	}
+	if _, err := execer.ExecInput(ctx, sbx.ID, cmd, nil); err != nil {
+		if f.Type == "dir" && cmd[0] == "rmdir" {
+			http.Error(w, "directory is not empty; pass recursive=true", http.StatusConflict)
+			return
+		}
+		log.Printf("file browser: delete %s in sandbox %s: %v", p, sbx.ID, err)
+		http.Error(w, "failed to delete file", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSandboxFile(t *testing.T) {
+	tests := []struct {
+		line string
+		want sandboxFile
+		ok   bool
+	}{
+		{"directory|4096|755|1700000000|src", sandboxFile{Name: "src", Path: "/home/agent/src", Type: "dir", Size: 4096, Mode: "755", ModifiedAt: time.Unix(1700000000, 0).UTC()}, true},
+		{"regular empty file|0|644|1700000000|.env", sandboxFile{Name: ".env", Path: "/home/agent/.env", Type: "file", Mode: "644", ModifiedAt: time.Unix(1700000000, 0).UTC()}, true},
+		{"regular file|12|600|1700000000|a|b.txt", sandboxFile{Name: "a|b.txt", Path: "/home/agent/a|b.txt", Type: "file", Size: 12, Mode: "600", ModifiedAt: time.Unix(1700000000, 0).UTC()}, true},
+		{"symbolic link|9|777|1700000000|latest", sandboxFile{Name: "latest", Path: "/home/agent/latest", Type: "symlink", Size: 9, Mode: "777", ModifiedAt: time.Unix(1700000000, 0).UTC()}, true},
+		{"socket|0|755|1700000000|sock", sandboxFile{Name: "sock", Path: "/home/agent/sock", Type: "other", Mode: "755", ModifiedAt: time.Unix(1700000000, 0).UTC()}, true},
+		{"continued name", sandboxFile{}, false},
+		{"regular file|x|644|1700000000|f", sandboxFile{}, false},
+		{"", sandboxFile{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSandboxFile("/home/agent", tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSandboxFile(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCleanSandboxPath(t *testing.T) {
+	for in, want := range map[string]string{"/home/agent/../agent/./x/": "/home/agent/x", "/": "/"} {
+		if got, err := cleanSandboxPath(in); err != nil || got != want {
+			t.Errorf("cleanSandboxPath(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "relative/path", "/a\x00b", "/a\nb"} {
+		if _, err := cleanSandboxPath(in); err == nil {
+			t.Errorf("cleanSandboxPath(%q) accepted", in)
+		}
+	}
+}
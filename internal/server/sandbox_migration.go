@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// A migration moves a sandbox to another node pool, e.g. off nodes being
+// drained or upgraded: the sandbox is paused, its session data snapshotted,
+// its pod template pointed at the target pool, its session-data volume
+// recreated from the snapshot (so it is provisioned where the pod lands),
+// and the sandbox resumed. The proxy routes to the new pod IP as soon as
+// the sandbox is running again.
+
+// sandboxRelocator is implemented by backends that can move a paused
+// sandbox to other nodes (K8s).
+type sandboxRelocator interface {
+	sessionSnapshotter
+	SetNodePool(ctx context.Context, sandboxID string, pool *process.NodePool) error
+}
+
+// sandboxMigrationTimeout bounds a whole migration.
+const sandboxMigrationTimeout = 30 * time.Minute
+
+// Sandbox migration phases, in order.
+const (
+	migrationPhasePausing      = "pausing"
+	migrationPhaseSnapshotting = "snapshotting"
+	migrationPhaseMoving       = "moving"
+	migrationPhaseRestoring    = "restoring"
+	migrationPhaseResuming     = "resuming"
+	migrationPhaseDone         = "done"
+)
+
+// migrationTarget is where a sandbox migrates to.
+type migrationTarget struct {
+	// NodePool is the target pool; nil uses the workspace's node pool, or
+	// any node if the workspace has none.
+	NodePool *process.NodePool `json:"node_pool,omitempty"`
+	// Cluster names another cluster. Not supported: a server manages a
+	// single cluster.
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// POST /api/admin/sandboxes/{id}/migrate {"node_pool": {...}} starts
+// migrating a running or paused sandbox and returns the migration job.
+func (s *Server) handleAdminMigrateSandbox(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	var target migrationTarget
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if target.Cluster != "" {
+		http.Error(w, "migration to another cluster is not supported", http.StatusBadRequest)
+		return
+	}
+	if target.NodePool != nil {
+		if err := target.NodePool.Validate(); err != nil {
+			http.Error(w, "node_pool: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		pool, err := s.workspaceNodePool(sbx.WorkspaceID)
+		if err != nil {
+			log.Printf("failed to get node pool for workspace %s: %v", sbx.WorkspaceID, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		target.NodePool = pool
+	}
+	if sbx.IsLocal {
+		http.Error(w, "local sandboxes cannot be migrated", http.StatusBadRequest)
+		return
+	}
+	relocator, ok := s.ProcessManager.(sandboxRelocator)
+	if !ok {
+		http.Error(w, "sandbox migration is not supported by this backend", http.StatusBadRequest)
+		return
+	}
+	if sbx.Status != sbxstore.StatusRunning && sbx.Status != sbxstore.StatusPaused {
+		http.Error(w, "sandbox cannot be migrated in current state: "+sbx.Status, http.StatusConflict)
+		return
+	}
+	if _, busy := s.snapshotOps.LoadOrStore(id, struct{}{}); busy {
+		http.Error(w, "a snapshot operation or migration is already in progress for this sandbox", http.StatusConflict)
+		return
+	}
+
+	targetJSON, _ := json.Marshal(target)
+	actorID := auth.UserIDFromContext(r.Context())
+	job, err := s.DB.CreateSandboxMigration(uuid.New().String(), id, targetJSON, actorID)
+	if err != nil {
+		s.snapshotOps.Delete(id)
+		log.Printf("failed to create migration of sandbox %s: %v", id, err)
+		http.Error(w, "failed to start migration", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sandbox %s: migration %s to node pool %v started by %s", id, job.ID, target.NodePool, actorID)
+	go func() {
+		defer s.snapshotOps.Delete(id)
+		s.runSandboxMigration(job, sbx, target.NodePool, relocator)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// runSandboxMigration performs a migration and records its progress. A
+// sandbox that was running is resumed on the target pool; a failure after
+// the sandbox was paused leaves it paused with the reason as its status
+// message.
+func (s *Server) runSandboxMigration(job *db.SandboxMigration, sbx *sbxstore.Sandbox, pool *process.NodePool, relocator sandboxRelocator) {
+	ctx, cancel := context.WithTimeout(context.Background(), sandboxMigrationTimeout)
+	defer cancel()
+	id, jobID := sbx.ID, job.ID
+	phase := func(p string) {
+		if err := s.DB.UpdateSandboxMigrationPhase(jobID, p); err != nil {
+			log.Printf("migration %s: failed to record phase %s: %v", jobID, p, err)
+		}
+	}
+	fail := func(err error, paused bool) {
+		log.Printf("sandbox %s: migration %s failed: %v", id, jobID, err)
+		if paused {
+			s.Sandboxes.UpdateStatusMessage(id, sbxstore.StatusPaused, "migration failed: "+err.Error())
+		}
+		if err := s.DB.FinishSandboxMigration(jobID, err.Error()); err != nil {
+			log.Printf("migration %s: failed to record failure: %v", jobID, err)
+		}
+	}
+
+	wasRunning := sbx.Status == sbxstore.StatusRunning
+	if wasRunning {
+		phase(migrationPhasePausing)
+		if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusPausing); err != nil {
+			fail(fmt.Errorf("pause: %w", err), false)
+			return
+		}
+		if err := s.pauseSandbox(id); err != nil {
+			fail(fmt.Errorf("pause: %w", err), false)
+			return
+		}
+	}
+
+	phase(migrationPhaseSnapshotting)
+	snapshotID := uuid.New().String()
+	ref, err := relocator.SnapshotSessionData(ctx, id, snapshotID)
+	if err != nil {
+		fail(fmt.Errorf("snapshot session data: %w", err), true)
+		return
+	}
+	// Keep the snapshot as a regular one, so the pre-migration state can be
+	// restored if something went wrong.
+	name := "migration-" + jobID[:8]
+	if _, err := s.DB.CreateSandboxSnapshot(snapshotID, id, sbx.WorkspaceID, name, ref, job.RequestedBy); err != nil {
+		log.Printf("sandbox %s: failed to record migration snapshot %s: %v", id, ref, err)
+	} else if err := s.DB.SetSandboxMigrationSnapshot(jobID, snapshotID); err != nil {
+		log.Printf("migration %s: failed to record snapshot: %v", jobID, err)
+	}
+
+	phase(migrationPhaseMoving)
+	if err := relocator.SetNodePool(ctx, id, pool); err != nil {
+		fail(fmt.Errorf("move to node pool: %w", err), true)
+		return
+	}
+	// Sandbox URLs follow the region of the new pool.
+	if err := s.DB.SetSandboxMetadataString(id, process.RegionMetadataKey, pool.Region()); err != nil {
+		log.Printf("sandbox %s: failed to update region: %v", id, err)
+	}
+
+	phase(migrationPhaseRestoring)
+	if err := relocator.RestoreSessionData(ctx, id, ref); err != nil {
+		fail(fmt.Errorf("restore session data: %w", err), true)
+		return
+	}
+
+	if wasRunning {
+		phase(migrationPhaseResuming)
+		if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusResuming); err != nil {
+			fail(fmt.Errorf("resume: %w", err), true)
+			return
+		}
+		if err := s.resumeSandbox(sbx, pool); err != nil {
+			fail(fmt.Errorf("resume: %w", err), false)
+			return
+		}
+	}
+
+	phase(migrationPhaseDone)
+	if err := s.DB.FinishSandboxMigration(jobID, ""); err != nil {
+		log.Printf("migration %s: failed to record completion: %v", jobID, err)
+	}
+	log.Printf("sandbox %s: migration %s completed", id, jobID)
+}
+
+// GET /api/admin/sandbox-migrations?sandbox_id=&status=&limit= lists
+// migrations, newest first.
+func (s *Server) handleAdminListSandboxMigrations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit: invalid", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	migrations, err := s.DB.ListSandboxMigrations(q.Get("sandbox_id"), q.Get("status"), limit)
+	if err != nil {
+		log.Printf("failed to list sandbox migrations: %v", err)
+		http.Error(w, "failed to list migrations", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(migrations)
+}
+
+// GET /api/admin/sandbox-migrations/{migrationID} returns a migration.
+func (s *Server) handleAdminGetSandboxMigration(w http.ResponseWriter, r *http.Request) {
+	m, err := s.DB.GetSandboxMigration(chi.URLParam(r, "migrationID"))
+	if err != nil {
+		log.Printf("failed to get sandbox migration: %v", err)
+		http.Error(w, "failed to get migration", http.StatusInternalServerError)
+		return
+	}
+	if m == nil {
+		http.Error(w, "migration not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
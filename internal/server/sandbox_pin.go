@@ -0,0 +1,51 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// handlePinSandbox pins a sandbox for long-running demo or production-like
+// agents: the idle watcher never pauses it, and it (and its workspace) can't
+// be deleted or archived until it is unpinned.
+func (s *Server) handlePinSandbox(w http.ResponseWriter, r *http.Request) {
+	s.setSandboxPinned(w, r, true)
+}
+
+func (s *Server) handleUnpinSandbox(w http.ResponseWriter, r *http.Request) {
+	s.setSandboxPinned(w, r, false)
+}
+
+func (s *Server) setSandboxPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer") {
+		return
+	}
+	actorID := auth.UserIDFromContext(r.Context())
+	if err := s.DB.SetSandboxPinned(id, pinned, actorID); err != nil {
+		log.Printf("failed to set pinned=%v on sandbox %s: %v", pinned, id, err)
+		http.Error(w, "failed to update sandbox", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sandbox %s pinned=%v by %s", id, pinned, actorID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// firstPinned returns the first pinned sandbox of a list, or nil.
+func firstPinned(sandboxes []*sbxstore.Sandbox) *sbxstore.Sandbox {
+	for _, sbx := range sandboxes {
+		if sbx.PinnedAt != nil {
+			return sbx
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// Preview ports: a sandbox port exposed with POST /api/sandboxes/{id}/ports
+// is served by the sandbox proxy at port-{port}-{shortid}.{baseDomain}, to
+// workspace members only, so users can open dev servers running inside a
+// sandbox. Local agents serve it through their tunnel.
+
+// maxPortLabelLength bounds the label of an exposed port.
+const maxPortLabelLength = 100
+
+// sandboxPortResponse is an exposed port with its preview URL.
+type sandboxPortResponse struct {
+	*db.SandboxPort
+	URL string `json:"url,omitempty"`
+}
+
+// sandboxPortResponse adds the preview URL to an exposed port. There is
+// none without a base domain.
+func (s *Server) sandboxPortResponse(r *http.Request, sbx *sbxstore.Sandbox, p *db.SandboxPort) sandboxPortResponse {
+	resp := sandboxPortResponse{SandboxPort: p}
+	if len(s.BaseDomains) > 0 {
+		subID := sbx.ShortID
+		if subID == "" {
+			subID = sbx.ID
+		}
+		resp.URL = "https://" + process.PreviewPortSubdomain(p.Port, subID) + "." + s.sandboxDomain(r, sbx) +
+			"/auth?token=" + authTokenFromRequest(r)
+	}
+	return resp
+}
+
+// GET /api/sandboxes/{id}/ports lists a sandbox's exposed ports.
+func (s *Server) handleListSandboxPorts(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
+		return
+	}
+	ports, err := s.DB.ListSandboxPorts(id)
+	if err != nil {
+		log.Printf("failed to list ports of sandbox %s: %v", id, err)
+		http.Error(w, "failed to list ports", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]sandboxPortResponse, 0, len(ports))
+	for _, p := range ports {
+		resp = append(resp, s.sandboxPortResponse(r, sbx, p))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/sandboxes/{id}/ports exposes a port, or relabels an exposed
+// one (owner/maintainer/developer).
+func (s *Server) handleExposeSandboxPort(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	var req struct {
+		Port  int    `json:"port"`
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := process.ValidatePreviewPort(req.Port); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Label = strings.TrimSpace(req.Label)
+	if len(req.Label) > maxPortLabelLength {
+		http.Error(w, fmt.Sprintf("label must be at most %d characters", maxPortLabelLength), http.StatusBadRequest)
+		return
+	}
+
+	ports, err := s.DB.ListSandboxPorts(id)
+	if err != nil {
+		log.Printf("failed to list ports of sandbox %s: %v", id, err)
+		http.Error(w, "failed to expose port", http.StatusInternalServerError)
+		return
+	}
+	exposed := false
+	for _, p := range ports {
+		exposed = exposed || p.Port == req.Port
+	}
+	if !exposed && len(ports) >= process.MaxPreviewPorts {
+		http.Error(w, fmt.Sprintf("at most %d ports can be exposed per sandbox", process.MaxPreviewPorts), http.StatusConflict)
+		return
+	}
+
+	p := &db.SandboxPort{
+		SandboxID: id,
+		Port:      req.Port,
+		Label:     req.Label,
+		CreatedBy: auth.UserIDFromContext(r.Context()),
+	}
+	created, err := s.DB.AddSandboxPort(p)
+	if err != nil {
+		log.Printf("failed to expose port %d of sandbox %s: %v", req.Port, id, err)
+		http.Error(w, "failed to expose port", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(s.sandboxPortResponse(r, sbx, p))
+}
+
+// DELETE /api/sandboxes/{id}/ports/{port} stops exposing a port
+// (owner/maintainer/developer).
+func (s *Server) handleUnexposeSandboxPort(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	port, err := strconv.Atoi(chi.URLParam(r, "port"))
+	if err != nil {
+		http.Error(w, "invalid port", http.StatusBadRequest)
+		return
+	}
+	ok, err = s.DB.DeleteSandboxPort(id, port)
+	if err != nil {
+		log.Printf("failed to unexpose port %d of sandbox %s: %v", port, id, err)
+		http.Error(w, "failed to unexpose port", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "port not exposed", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
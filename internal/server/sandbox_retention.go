@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// Paused sandboxes keep their volumes (PVCs on Kubernetes) until deleted.
+// The retention reaper deletes sandboxes paused longer than the retention
+// period of their workspace (PausedRetention, overridable per workspace
+// with the paused_retention quota). The workspace is warned first, and the
+// deletion comes at least PausedRetentionWarning after the warning. Pinned
+// and quarantined sandboxes are never deleted, and admins can hold a
+// sandbox, indefinitely or until a given time.
+
+// defaultPausedRetentionWarning is the warning period when
+// PausedRetentionWarning is not set.
+const defaultPausedRetentionWarning = 72 * time.Hour
+
+func (s *Server) pausedRetentionWarning() time.Duration {
+	if s.PausedRetentionWarning > 0 {
+		return s.PausedRetentionWarning
+	}
+	return defaultPausedRetentionWarning
+}
+
+// pausedRetention returns the retention period of paused sandboxes in a
+// workspace, 0 if they are kept forever.
+func (s *Server) pausedRetention(wsID string) (time.Duration, error) {
+	wd, err := s.effectiveWorkspaceDefaults(wsID)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wd.PausedRetention) * time.Second, nil
+}
+
+// pausedSandboxDeletionAt returns when a paused sandbox is due for
+// deletion, given its workspace's retention period and warning period and
+// its retention state (nil if none), and false if it is never deleted. A
+// sandbox whose pause was not warned of yet is due no earlier than the
+// warning period after now.
+func pausedSandboxDeletionAt(sbx *sbxstore.Sandbox, retention, warning time.Duration, state *db.SandboxRetention, now time.Time) (time.Time, bool) {
+	if sbx.IsLocal || sbx.Status != sbxstore.StatusPaused || sbx.PausedAt == nil ||
+		sbx.PinnedAt != nil || sbx.QuarantinedAt != nil || retention <= 0 {
+		return time.Time{}, false
+	}
+	at := sbx.PausedAt.Add(retention)
+	warnedAt := now
+	if state != nil {
+		if state.Held {
+			if state.HoldUntil == nil {
+				return time.Time{}, false
+			}
+			if state.HoldUntil.After(at) {
+				at = *state.HoldUntil
+			}
+		}
+		if state.WarnedAt != nil && state.WarnedPausedAt != nil && state.WarnedPausedAt.Equal(*sbx.PausedAt) {
+			warnedAt = *state.WarnedAt
+		}
+	}
+	if earliest := warnedAt.Add(warning); earliest.After(at) {
+		at = earliest
+	}
+	return at, true
+}
+
+// warnedOfPause reports whether the deletion warning for the current pause
+// of sbx was sent.
+func warnedOfPause(sbx *sbxstore.Sandbox, state *db.SandboxRetention) bool {
+	return state != nil && state.WarnedAt != nil && state.WarnedPausedAt != nil &&
+		sbx.PausedAt != nil && state.WarnedPausedAt.Equal(*sbx.PausedAt)
+}
+
+// sandboxDeletionWarnings returns when the sandboxes of a workspace that
+// are within the warning period of their deletion will be deleted, by
+// sandbox ID.
+func (s *Server) sandboxDeletionWarnings(wsID string, sandboxes []*sbxstore.Sandbox, now time.Time) map[string]time.Time {
+	paused := false
+	for _, sbx := range sandboxes {
+		paused = paused || sbx.Status == sbxstore.StatusPaused
+	}
+	if !paused {
+		return nil
+	}
+	retention, err := s.pausedRetention(wsID)
+	if err != nil {
+		log.Printf("failed to get paused retention of workspace %s: %v", wsID, err)
+		return nil
+	}
+	if retention <= 0 {
+		return nil
+	}
+	states, err := s.DB.ListSandboxRetention(wsID)
+	if err != nil {
+		log.Printf("failed to list sandbox retention of workspace %s: %v", wsID, err)
+		return nil
+	}
+	warning := s.pausedRetentionWarning()
+	warnings := make(map[string]time.Time)
+	for _, sbx := range sandboxes {
+		at, ok := pausedSandboxDeletionAt(sbx, retention, warning, states[sbx.ID], now)
+		if ok && !now.Before(at.Add(-warning)) {
+			warnings[sbx.ID] = at
+		}
+	}
+	return warnings
+}
+
+// setDeletionWarning sets the scheduled deletion of a sandbox response
+// from sandboxDeletionWarnings.
+func setDeletionWarning(resp *sandboxResponse, warnings map[string]time.Time) {
+	if at, ok := warnings[resp.ID]; ok {
+		t := at.UTC().Format(time.RFC3339)
+		resp.DeletionScheduledAt = &t
+	}
+}
+
+// StartPausedSandboxReaperLoop warns of and deletes long-paused sandboxes
+// every `every` until ctx is cancelled.
+func (s *Server) StartPausedSandboxReaperLoop(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Hour
+	}
+	beat := s.ControllerHeartbeat(controllerPausedReaper, every)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		s.runPausedSandboxReaperOnce(time.Now())
+		beat(nil)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Server) runPausedSandboxReaperOnce(now time.Time) {
+	paused, err := s.DB.ListPausedSandboxes()
+	if err != nil {
+		log.Printf("paused sandbox reaper: list paused sandboxes: %v", err)
+		return
+	}
+	if len(paused) == 0 {
+		return
+	}
+	states, err := s.DB.ListSandboxRetention("")
+	if err != nil {
+		log.Printf("paused sandbox reaper: list retention: %v", err)
+		return
+	}
+	warning := s.pausedRetentionWarning()
+	retentions := make(map[string]time.Duration)
+	for _, p := range paused {
+		retention, ok := retentions[p.WorkspaceID]
+		if !ok {
+			if retention, err = s.pausedRetention(p.WorkspaceID); err != nil {
+				log.Printf("paused sandbox reaper: retention of workspace %s: %v", p.WorkspaceID, err)
+				continue
+			}
+			retentions[p.WorkspaceID] = retention
+		}
+		if retention <= 0 {
+			continue
+		}
+		sbx, ok := s.Sandboxes.Get(p.ID)
+		if !ok {
+			continue
+		}
+		state := states[sbx.ID]
+		at, ok := pausedSandboxDeletionAt(sbx, retention, warning, state, now)
+		switch {
+		case !ok:
+		case !warnedOfPause(sbx, state):
+			if !now.Before(at.Add(-warning)) {
+				s.warnPausedSandboxDeletion(sbx, at, now)
+			}
+		case !now.Before(at):
+			s.reapPausedSandbox(sbx, retention)
+		}
+	}
+}
+
+// warnPausedSandboxDeletion tells the workspace of sbx that it will be
+// deleted at at: on the workspace event stream and, when a mailer is
+// configured, by email to the workspace owners.
+func (s *Server) warnPausedSandboxDeletion(sbx *sbxstore.Sandbox, at, now time.Time) {
+	if err := s.DB.MarkSandboxRetentionWarned(sbx.ID, *sbx.PausedAt, now); err != nil {
+		log.Printf("paused sandbox reaper: mark %s warned: %v", sbx.ID, err)
+		return
+	}
+	log.Printf("paused sandbox reaper: sandbox %s (paused since %s) will be deleted at %s",
+		sbx.ID, sbx.PausedAt.Format(time.RFC3339), at.Format(time.RFC3339))
+	s.Sandboxes.Publish(sbxstore.Event{
+		Type:        sbxstore.EventDeletionWarning,
+		WorkspaceID: sbx.WorkspaceID,
+		SandboxID:   sbx.ID,
+		Data:        map[string]interface{}{"delete_at": at.UTC().Format(time.RFC3339)},
+		At:          now,
+	})
+	if s.StatementMailer == nil {
+		return
+	}
+	to, err := s.workspaceOwnerEmails(sbx.WorkspaceID)
+	if err != nil {
+		log.Printf("paused sandbox reaper: owners of workspace %s: %v", sbx.WorkspaceID, err)
+		return
+	}
+	if len(to) == 0 {
+		return
+	}
+	body := fmt.Sprintf("The sandbox %s has been paused since %s and will be deleted with its data on %s.\n\n"+
+		"Resume or pin the sandbox to keep it.\n",
+		sbx.Name, sbx.PausedAt.UTC().Format("2006-01-02"), at.UTC().Format("2006-01-02 15:04 MST"))
+	if err := s.StatementMailer.Send(to, "Paused sandbox "+sbx.Name+" will be deleted", body, "", nil); err != nil {
+		log.Printf("paused sandbox reaper: email warning for %s: %v", sbx.ID, err)
+	}
+}
+
+// workspaceOwnerEmails returns the email addresses of the owners of a
+// workspace.
+func (s *Server) workspaceOwnerEmails(wsID string) ([]string, error) {
+	members, err := s.DB.ListWorkspaceMembers(wsID)
+	if err != nil {
+		return nil, err
+	}
+	var to []string
+	for _, m := range members {
+		if m.Role != "owner" {
+			continue
+		}
+		u, err := s.DB.GetUserByID(m.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil && u.Email != "" {
+			to = append(to, u.Email)
+		}
+	}
+	return to, nil
+}
+
+// reapPausedSandbox deletes a paused sandbox and its volumes.
+func (s *Server) reapPausedSandbox(sbx *sbxstore.Sandbox, retention time.Duration) {
+	log.Printf("paused sandbox reaper: deleting sandbox %s of workspace %s, paused since %s (retention %s)",
+		sbx.ID, sbx.WorkspaceID, sbx.PausedAt.Format(time.RFC3339), retention)
+	s.deleteSandboxSnapshots(context.Background(), sbx.ID)
+	if sbx.SandboxName != "" {
+		var sbxNs string
+		if ws, err := s.DB.GetWorkspace(sbx.WorkspaceID); err == nil && ws != nil && ws.K8sNamespace.Valid {
+			sbxNs = ws.K8sNamespace.String
+		}
+		switch mgr := s.ProcessManager.(type) {
+		case interface{ StopBySandboxName(string, string) error }:
+			mgr.StopBySandboxName(sbxNs, sbx.SandboxName)
+		case interface{ StopByContainerName(string) error }:
+			mgr.StopByContainerName(sbx.SandboxName)
+		}
+	}
+	if sbx.Type == "nanoclaw" {
+		if err := s.DB.UnbindSandboxFromChannel(sbx.ID); err != nil {
+			log.Printf("paused sandbox reaper: unbind %s from IM channel: %v", sbx.ID, err)
+		}
+	}
+	if err := s.Sandboxes.Delete(sbx.ID, db.SandboxDeletion{Reason: db.DeletionReasonPausedRetention}); err != nil {
+		log.Printf("paused sandbox reaper: delete %s: %v", sbx.ID, err)
+	}
+}
+
+// PUT /api/admin/sandboxes/{id}/retention-hold
+func (s *Server) handleAdminHoldSandboxRetention(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := s.Sandboxes.Get(id); !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Until *time.Time `json:"until"` // omitted: indefinitely
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		http.Error(w, "until must be in the future", http.StatusBadRequest)
+		return
+	}
+	if err := s.DB.SetSandboxRetentionHold(id, req.Until); err != nil {
+		log.Printf("admin: failed to hold sandbox %s: %v", id, err)
+		http.Error(w, "failed to hold sandbox", http.StatusInternalServerError)
+		return
+	}
+	until := "indefinitely"
+	if req.Until != nil {
+		until = "until " + req.Until.Format(time.RFC3339)
+	}
+	log.Printf("admin: sandbox %s held from paused deletion %s by %s", id, until, auth.UserIDFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/admin/sandboxes/{id}/retention-hold
+func (s *Server) handleAdminReleaseSandboxRetention(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	ok, err := s.DB.ClearSandboxRetentionHold(id)
+	if err != nil {
+		log.Printf("admin: failed to release hold of sandbox %s: %v", id, err)
+		http.Error(w, "failed to release hold", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "sandbox is not held", http.StatusNotFound)
+		return
+	}
+	log.Printf("admin: sandbox %s released for paused deletion by %s", id, auth.UserIDFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestPausedSandboxDeletionAt(t *testing.T) {
+	now := time.Date(2030, 3, 1, 12, 0, 0, 0, time.UTC)
+	pausedAt := now.Add(-29 * 24 * time.Hour)
+	retention := 30 * 24 * time.Hour
+	warning := 72 * time.Hour
+	paused := func() *sbxstore.Sandbox {
+		p := pausedAt
+		return &sbxstore.Sandbox{ID: "s", Status: sbxstore.StatusPaused, PausedAt: &p}
+	}
+	warnedAt := now.Add(-24 * time.Hour)
+	warned := &db.SandboxRetention{WarnedAt: &warnedAt, WarnedPausedAt: &pausedAt}
+	later := now.Add(10 * 24 * time.Hour)
+	oldPause := pausedAt.Add(-time.Hour)
+
+	pinned := paused()
+	pinned.PinnedAt = &now
+	quarantined := paused()
+	quarantined.QuarantinedAt = &now
+	running := paused()
+	running.Status = sbxstore.StatusRunning
+
+	for _, tc := range []struct {
+		name      string
+		sbx       *sbxstore.Sandbox
+		retention time.Duration
+		state     *db.SandboxRetention
+		want      time.Time // zero: never deleted
+	}{
+		{"not warned yet", paused(), retention, nil, now.Add(warning)},
+		{"warned", paused(), retention, warned, warnedAt.Add(warning)},
+		{"warned long ago", paused(), 7 * 24 * time.Hour,
+			&db.SandboxRetention{WarnedAt: &pausedAt, WarnedPausedAt: &pausedAt}, pausedAt.Add(7 * 24 * time.Hour)},
+		{"warned of an earlier pause", paused(), retention,
+			&db.SandboxRetention{WarnedAt: &warnedAt, WarnedPausedAt: &oldPause}, now.Add(warning)},
+		{"held until later", paused(), retention,
+			&db.SandboxRetention{Held: true, HoldUntil: &later, WarnedAt: &warnedAt, WarnedPausedAt: &pausedAt}, later},
+		{"held indefinitely", paused(), retention, &db.SandboxRetention{Held: true}, time.Time{}},
+		{"retention disabled", paused(), 0, nil, time.Time{}},
+		{"pinned", pinned, retention, nil, time.Time{}},
+		{"quarantined", quarantined, retention, nil, time.Time{}},
+		{"running", running, retention, nil, time.Time{}},
+	} {
+		at, ok := pausedSandboxDeletionAt(tc.sbx, tc.retention, warning, tc.state, now)
+		if ok != !tc.want.IsZero() || !at.Equal(tc.want) {
+			t.Errorf("%s: got %v, %v; want %v", tc.name, at, ok, tc.want)
+		}
+	}
+
+	if !warnedOfPause(paused(), warned) {
+		t.Error("warning of the current pause not recognized")
+	}
+	if warnedOfPause(paused(), &db.SandboxRetention{WarnedAt: &warnedAt, WarnedPausedAt: &oldPause}) {
+		t.Error("warning of an earlier pause counted")
+	}
+}
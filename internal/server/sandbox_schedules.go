@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/cron"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Sandbox schedules pause or resume cloud sandboxes at fixed times, e.g.
+// every weekday evening and morning, on top of idle pausing. A schedule
+// targets one sandbox or all cloud sandboxes of its workspace; pinned
+// sandboxes are never paused by a schedule.
+
+// sandboxScheduleCatchUp is how late a due run is still carried out, e.g.
+// after a restart; runs missed by longer are skipped.
+const sandboxScheduleCatchUp = time.Hour
+
+// maxSandboxSchedules bounds the schedules of a workspace.
+const maxSandboxSchedules = 50
+
+// sandboxScheduleRequest is the body of creating or replacing a schedule.
+type sandboxScheduleRequest struct {
+	SandboxID string `json:"sandbox_id"`
+	Action    string `json:"action"`
+	Cron      string `json:"cron"`
+	Timezone  string `json:"timezone"`
+	Enabled   *bool  `json:"enabled"`
+}
+
+// sandboxScheduleResponse is a schedule with its next run.
+type sandboxScheduleResponse struct {
+	*db.SandboxSchedule
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+func newSandboxScheduleResponse(sc *db.SandboxSchedule, now time.Time) sandboxScheduleResponse {
+	resp := sandboxScheduleResponse{SandboxSchedule: sc}
+	if !sc.Enabled {
+		return resp
+	}
+	c, err := cron.Parse(sc.Cron)
+	if err != nil {
+		return resp
+	}
+	loc, err := time.LoadLocation(sc.Timezone)
+	if err != nil {
+		return resp
+	}
+	if next := c.Next(now.In(loc)); !next.IsZero() {
+		resp.NextRunAt = &next
+	}
+	return resp
+}
+
+// dueSandboxScheduleRun returns the run of a schedule that is due at now:
+// the first activation after its last run, or after its creation if it
+// never ran. It returns the zero time if none is due.
+func dueSandboxScheduleRun(sc *db.SandboxSchedule, now time.Time) (time.Time, error) {
+	c, err := cron.Parse(sc.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(sc.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	from := sc.CreatedAt
+	if sc.LastRunAt != nil {
+		from = *sc.LastRunAt
+	}
+	next := c.Next(from.In(loc))
+	if next.IsZero() || next.After(now) {
+		return time.Time{}, nil
+	}
+	return next, nil
+}
+
+// decodeSandboxSchedule reads and validates a schedule request into sc. It
+// writes the error response and returns false on failure.
+func (s *Server) decodeSandboxSchedule(w http.ResponseWriter, r *http.Request, sc *db.SandboxSchedule) bool {
+	var req sandboxScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+	if req.Action != "pause" && req.Action != "resume" {
+		http.Error(w, "action must be pause or resume", http.StatusBadRequest)
+		return false
+	}
+	if _, err := cron.Parse(req.Cron); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		http.Error(w, "unknown timezone: "+req.Timezone, http.StatusBadRequest)
+		return false
+	}
+	if req.SandboxID != "" {
+		sbx, ok := s.Sandboxes.Get(req.SandboxID)
+		if !ok || sbx.WorkspaceID != sc.WorkspaceID {
+			http.Error(w, "sandbox not found in this workspace", http.StatusBadRequest)
+			return false
+		}
+		if sbx.IsLocal {
+			http.Error(w, "local sandboxes cannot be scheduled", http.StatusBadRequest)
+			return false
+		}
+	}
+	sc.SandboxID, sc.Action, sc.Cron, sc.Timezone = req.SandboxID, req.Action, req.Cron, req.Timezone
+	sc.Enabled = req.Enabled == nil || *req.Enabled
+	return true
+}
+
+// GET /api/workspaces/{id}/schedules lists the workspace's schedules with
+// their next run.
+func (s *Server) handleListSandboxSchedules(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	schedules, err := s.DB.ListSandboxSchedules(wsID)
+	if err != nil {
+		log.Printf("failed to list schedules of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to list schedules", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	resp := make([]sandboxScheduleResponse, 0, len(schedules))
+	for _, sc := range schedules {
+		resp = append(resp, newSandboxScheduleResponse(sc, now))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/workspaces/{id}/schedules creates a schedule (owner/maintainer).
+func (s *Server) handleCreateSandboxSchedule(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	sc := &db.SandboxSchedule{
+		ID:          uuid.New().String(),
+		WorkspaceID: wsID,
+		CreatedBy:   auth.UserIDFromContext(r.Context()),
+	}
+	if !s.decodeSandboxSchedule(w, r, sc) {
+		return
+	}
+	existing, err := s.DB.ListSandboxSchedules(wsID)
+	if err != nil {
+		log.Printf("failed to list schedules of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxSandboxSchedules {
+		http.Error(w, fmt.Sprintf("a workspace can have at most %d schedules", maxSandboxSchedules), http.StatusConflict)
+		return
+	}
+	if err := s.DB.CreateSandboxSchedule(sc); err != nil {
+		log.Printf("failed to create schedule in workspace %s: %v", wsID, err)
+		http.Error(w, "failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newSandboxScheduleResponse(sc, time.Now()))
+}
+
+// sandboxSchedule resolves the schedule {scheduleID} of workspace {id}. It
+// writes the error response and returns nil on failure.
+func (s *Server) sandboxSchedule(w http.ResponseWriter, r *http.Request) *db.SandboxSchedule {
+	wsID := chi.URLParam(r, "id")
+	sc, err := s.DB.GetSandboxSchedule(wsID, chi.URLParam(r, "scheduleID"))
+	if err != nil {
+		log.Printf("failed to get schedule of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to get schedule", http.StatusInternalServerError)
+		return nil
+	}
+	if sc == nil {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return nil
+	}
+	return sc
+}
+
+// PUT /api/workspaces/{id}/schedules/{scheduleID} replaces a schedule
+// (owner/maintainer). Runs missed before the change are not caught up.
+func (s *Server) handleUpdateSandboxSchedule(w http.ResponseWriter, r *http.Request) {
+	if !s.requireWorkspaceRole(w, r, chi.URLParam(r, "id"), "owner", "maintainer") {
+		return
+	}
+	sc := s.sandboxSchedule(w, r)
+	if sc == nil {
+		return
+	}
+	if !s.decodeSandboxSchedule(w, r, sc) {
+		return
+	}
+	if err := s.DB.UpdateSandboxSchedule(sc); err != nil {
+		log.Printf("failed to update schedule %s: %v", sc.ID, err)
+		http.Error(w, "failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	sc.LastRunAt = &now
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newSandboxScheduleResponse(sc, now))
+}
+
+// DELETE /api/workspaces/{id}/schedules/{scheduleID} deletes a schedule
+// (owner/maintainer).
+func (s *Server) handleDeleteSandboxSchedule(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	ok, err := s.DB.DeleteSandboxSchedule(wsID, chi.URLParam(r, "scheduleID"))
+	if err != nil {
+		log.Printf("failed to delete schedule of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runSandboxSchedulesOnce carries out the schedule runs due at now. Each
+// run is claimed in the database first, so it happens once across
+// replicas.
+func (s *Server) runSandboxSchedulesOnce(now time.Time) {
+	schedules, err := s.DB.ListEnabledSandboxSchedules()
+	if err != nil {
+		log.Printf("sandbox schedules: list: %v", err)
+		return
+	}
+	for _, sc := range schedules {
+		due, err := dueSandboxScheduleRun(sc, now)
+		if err != nil {
+			log.Printf("sandbox schedules: schedule %s: %v", sc.ID, err)
+			continue
+		}
+		if due.IsZero() {
+			continue
+		}
+		claimed, err := s.DB.ClaimSandboxScheduleRun(sc.ID, sc.LastRunAt, now)
+		if err != nil {
+			log.Printf("sandbox schedules: %v", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if now.Sub(due) > sandboxScheduleCatchUp {
+			log.Printf("sandbox schedules: skipping run of schedule %s missed since %s", sc.ID, due.Format(time.RFC3339))
+			continue
+		}
+		s.applySandboxSchedule(sc)
+	}
+}
+
+// applySandboxSchedule pauses the running or resumes the paused sandboxes
+// a schedule targets. Pinned sandboxes are not paused; quarantined ones,
+// those busy with a snapshot or migration, and those of archived
+// workspaces are not resumed.
+func (s *Server) applySandboxSchedule(sc *db.SandboxSchedule) {
+	var targets []*sbxstore.Sandbox
+	if sc.SandboxID != "" {
+		if sbx, ok := s.Sandboxes.Get(sc.SandboxID); ok {
+			targets = append(targets, sbx)
+		}
+	} else {
+		targets = s.Sandboxes.ListByWorkspace(sc.WorkspaceID)
+	}
+	if sc.Action == "resume" {
+		ws, err := s.DB.GetWorkspace(sc.WorkspaceID)
+		if err != nil {
+			log.Printf("sandbox schedules: get workspace %s: %v", sc.WorkspaceID, err)
+			return
+		}
+		if ws == nil || ws.ArchivedAt.Valid {
+			return
+		}
+	}
+
+	n := 0
+	for _, sbx := range targets {
+		if sbx.IsLocal {
+			continue
+		}
+		switch sc.Action {
+		case "pause":
+			if sbx.Status != sbxstore.StatusRunning || sbx.PinnedAt != nil {
+				continue
+			}
+			if err := s.Sandboxes.UpdateStatus(sbx.ID, sbxstore.StatusPausing); err != nil {
+				log.Printf("sandbox schedules: failed to set pausing status for %s: %v", sbx.ID, err)
+				continue
+			}
+			go s.pauseSandbox(sbx.ID)
+		case "resume":
+			if sbx.Status != sbxstore.StatusPaused || sbx.QuarantinedAt != nil {
+				continue
+			}
+			if _, busy := s.snapshotOps.Load(sbx.ID); busy {
+				continue
+			}
+			if err := s.Sandboxes.UpdateStatus(sbx.ID, sbxstore.StatusResuming); err != nil {
+				log.Printf("sandbox schedules: failed to set resuming status for %s: %v", sbx.ID, err)
+				continue
+			}
+			go func(sbx *sbxstore.Sandbox) {
+				pool, _ := s.workspaceNodePool(sbx.WorkspaceID)
+				s.resumeSandbox(sbx, pool)
+			}(sbx)
+		}
+		n++
+	}
+	if n > 0 {
+		log.Printf("sandbox schedules: schedule %s: %s %d sandboxes of workspace %s", sc.ID, sc.Action, n, sc.WorkspaceID)
+	}
+}
+
+// StartSandboxScheduleLoop is the exported entry point for the server's
+// main lifecycle to carry out sandbox schedules, checking every interval.
+func (s *Server) StartSandboxScheduleLoop(ctx context.Context, every time.Duration) {
+	beat := s.ControllerHeartbeat(controllerSandboxSchedules, every)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.runSandboxSchedulesOnce(time.Now())
+			beat(nil)
+		}
+	}
+}
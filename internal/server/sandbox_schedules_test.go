@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestDueSandboxScheduleRun(t *testing.T) {
+	created := time.Date(2030, 1, 4, 12, 0, 0, 0, time.UTC) // a Friday
+	sc := &db.SandboxSchedule{Cron: "0 19 * * mon-fri", Timezone: "UTC", Enabled: true, CreatedAt: created}
+
+	if due, err := dueSandboxScheduleRun(sc, created.Add(6*time.Hour)); err != nil || !due.IsZero() {
+		t.Errorf("before 19:00: %v, %v", due, err)
+	}
+	due, err := dueSandboxScheduleRun(sc, created.Add(7*time.Hour+30*time.Second))
+	if err != nil || !due.Equal(time.Date(2030, 1, 4, 19, 0, 0, 0, time.UTC)) {
+		t.Errorf("at 19:00: %v, %v", due, err)
+	}
+
+	// After the run, the next one is on Monday.
+	ran := created.Add(7 * time.Hour)
+	sc.LastRunAt = &ran
+	if due, _ := dueSandboxScheduleRun(sc, time.Date(2030, 1, 6, 19, 0, 0, 0, time.UTC)); !due.IsZero() {
+		t.Errorf("on Sunday: %v", due)
+	}
+	if due, _ := dueSandboxScheduleRun(sc, time.Date(2030, 1, 7, 19, 0, 0, 0, time.UTC)); due.IsZero() {
+		t.Error("Monday's run not due")
+	}
+
+	sc.Timezone = "Mars/Olympus"
+	if _, err := dueSandboxScheduleRun(sc, created); err == nil {
+		t.Error("unknown time zone accepted")
+	}
+}
+
+func TestSandboxScheduleResponse(t *testing.T) {
+	now := time.Date(2030, 1, 4, 20, 0, 0, 0, time.UTC)
+	sc := &db.SandboxSchedule{Cron: "0 8 * * 1-5", Timezone: "UTC", Enabled: true}
+	resp := newSandboxScheduleResponse(sc, now)
+	if resp.NextRunAt == nil || !resp.NextRunAt.Equal(time.Date(2030, 1, 7, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("next run = %v", resp.NextRunAt)
+	}
+	sc.Enabled = false
+	if resp := newSandboxScheduleResponse(sc, now); resp.NextRunAt != nil {
+		t.Errorf("disabled schedule has next run %v", resp.NextRunAt)
+	}
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// sessionSnapshotter is implemented by backends that can snapshot and
+// restore a sandbox's session data (home directory): VolumeSnapshots of the
+// session-data PVC on K8s, volume copies on Docker.
+type sessionSnapshotter interface {
+	SnapshotSessionData(ctx context.Context, sandboxID, snapshotID string) (string, error)
+	RestoreSessionData(ctx context.Context, sandboxID, ref string) error
+	DeleteSessionDataSnapshot(ctx context.Context, sandboxID, ref string) error
+}
+
+// sandboxSnapshotTimeout bounds taking or restoring a snapshot; a Docker
+// copy of a large home directory takes a while.
+const sandboxSnapshotTimeout = 10 * time.Minute
+
+// snapshotSandbox resolves the sandbox of a snapshot request and checks the
+// caller's role and the backend. It writes the error response and returns
+// false on failure.
+func (s *Server) snapshotSandbox(w http.ResponseWriter, r *http.Request, roles ...string) (*sbxstore.Sandbox, sessionSnapshotter, bool) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if len(roles) == 0 {
+		if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
+			return nil, nil, false
+		}
+	} else if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, roles...) {
+		return nil, nil, false
+	}
+	if sbx.IsLocal {
+		http.Error(w, "local sandboxes cannot be snapshotted", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	snapshotter, ok := s.ProcessManager.(sessionSnapshotter)
+	if !ok {
+		http.Error(w, "sandbox snapshots are not supported by this backend", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return sbx, snapshotter, true
+}
+
+// beginSnapshotOp marks a snapshot operation on a sandbox in progress, so
+// snapshots, restores and resumes of the same sandbox don't overlap. It
+// writes a 409 and returns false if one is already running.
+func (s *Server) beginSnapshotOp(w http.ResponseWriter, id string) bool {
+	if _, busy := s.snapshotOps.LoadOrStore(id, struct{}{}); busy {
+		http.Error(w, "a snapshot operation is already in progress for this sandbox", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// POST /api/sandboxes/{id}/snapshots {"name": "..."} snapshots the
+// sandbox's session data. Running sandboxes get a crash-consistent copy.
+func (s *Server) handleCreateSandboxSnapshot(w http.ResponseWriter, r *http.Request) {
+	sbx, snapshotter, ok := s.snapshotSandbox(w, r, "owner", "maintainer", "developer")
+	if !ok {
+		return
+	}
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Name) > 100 {
+		http.Error(w, "name must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	if sbx.Status != sbxstore.StatusRunning && sbx.Status != sbxstore.StatusPaused {
+		http.Error(w, "sandbox cannot be snapshotted in current state: "+sbx.Status, http.StatusConflict)
+		return
+	}
+	if existing, err := s.DB.GetSandboxSnapshot(sbx.ID, req.Name); err != nil {
+		log.Printf("failed to look up snapshot %q of sandbox %s: %v", req.Name, sbx.ID, err)
+		http.Error(w, "failed to create snapshot", http.StatusInternalServerError)
+		return
+	} else if existing != nil {
+		http.Error(w, "a snapshot with this name already exists", http.StatusConflict)
+		return
+	}
+	if !s.beginSnapshotOp(w, sbx.ID) {
+		return
+	}
+	defer s.snapshotOps.Delete(sbx.ID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxSnapshotTimeout)
+	defer cancel()
+	snapshotID := uuid.New().String()
+	ref, err := snapshotter.SnapshotSessionData(ctx, sbx.ID, snapshotID)
+	if err != nil {
+		log.Printf("failed to snapshot sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to create snapshot", http.StatusInternalServerError)
+		return
+	}
+	snap, err := s.DB.CreateSandboxSnapshot(snapshotID, sbx.ID, sbx.WorkspaceID, req.Name, ref, auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		log.Printf("failed to record snapshot %s of sandbox %s: %v", ref, sbx.ID, err)
+		if err := snapshotter.DeleteSessionDataSnapshot(context.Background(), sbx.ID, ref); err != nil {
+			log.Printf("failed to delete unrecorded snapshot %s: %v", ref, err)
+		}
+		http.Error(w, "failed to create snapshot", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sandbox %s: snapshot %q (%s) created", sbx.ID, snap.Name, snap.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(snap)
+}
+
+// GET /api/sandboxes/{id}/snapshots lists the sandbox's snapshots, newest
+// first.
+func (s *Server) handleListSandboxSnapshots(w http.ResponseWriter, r *http.Request) {
+	sbx, _, ok := s.snapshotSandbox(w, r)
+	if !ok {
+		return
+	}
+	snapshots, err := s.DB.ListSandboxSnapshots(sbx.ID)
+	if err != nil {
+		log.Printf("failed to list snapshots of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to list snapshots", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+// POST /api/sandboxes/{id}/snapshots/{snapshot}/restore replaces the session
+// data of a paused sandbox with a snapshot. {snapshot} is an ID or name.
+func (s *Server) handleRestoreSandboxSnapshot(w http.ResponseWriter, r *http.Request) {
+	sbx, snapshotter, ok := s.snapshotSandbox(w, r, "owner", "maintainer", "developer")
+	if !ok {
+		return
+	}
+	snap, err := s.DB.GetSandboxSnapshot(sbx.ID, chi.URLParam(r, "snapshot"))
+	if err != nil {
+		log.Printf("failed to get snapshot of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to restore snapshot", http.StatusInternalServerError)
+		return
+	}
+	if snap == nil {
+		http.Error(w, "snapshot not found", http.StatusNotFound)
+		return
+	}
+	if sbx.Status != sbxstore.StatusPaused {
+		http.Error(w, "pause the sandbox before restoring a snapshot", http.StatusConflict)
+		return
+	}
+	if !s.beginSnapshotOp(w, sbx.ID) {
+		return
+	}
+	defer s.snapshotOps.Delete(sbx.ID)
+
+	ctx, cancel := context.WithTimeout(r.Context(), sandboxSnapshotTimeout)
+	defer cancel()
+	if err := snapshotter.RestoreSessionData(ctx, sbx.ID, snap.Ref); err != nil {
+		log.Printf("failed to restore sandbox %s from snapshot %s: %v", sbx.ID, snap.ID, err)
+		http.Error(w, "failed to restore snapshot", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sandbox %s: restored from snapshot %q (%s) by %s", sbx.ID, snap.Name, snap.ID, auth.UserIDFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/sandboxes/{id}/snapshots/{snapshot} deletes a snapshot.
+func (s *Server) handleDeleteSandboxSnapshot(w http.ResponseWriter, r *http.Request) {
+	sbx, snapshotter, ok := s.snapshotSandbox(w, r, "owner", "maintainer", "developer")
+	if !ok {
+		return
+	}
+	snap, err := s.DB.GetSandboxSnapshot(sbx.ID, chi.URLParam(r, "snapshot"))
+	if err != nil {
+		log.Printf("failed to get snapshot of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to delete snapshot", http.StatusInternalServerError)
+		return
+	}
+	if snap == nil {
+		http.Error(w, "snapshot not found", http.StatusNotFound)
+		return
+	}
+	if err := snapshotter.DeleteSessionDataSnapshot(r.Context(), sbx.ID, snap.Ref); err != nil {
+		log.Printf("failed to delete snapshot %s of sandbox %s: %v", snap.Ref, sbx.ID, err)
+		http.Error(w, "failed to delete snapshot", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.DeleteSandboxSnapshot(snap.ID); err != nil {
+		log.Printf("failed to delete snapshot record %s: %v", snap.ID, err)
+		http.Error(w, "failed to delete snapshot", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteSandboxSnapshots removes the backend copies of a sandbox's
+// snapshots before the sandbox is deleted; the records cascade with it.
+// Failures are logged.
+func (s *Server) deleteSandboxSnapshots(ctx context.Context, sandboxID string) {
+	snapshotter, ok := s.ProcessManager.(sessionSnapshotter)
+	if !ok {
+		return
+	}
+	snapshots, err := s.DB.ListSandboxSnapshots(sandboxID)
+	if err != nil {
+		log.Printf("failed to list snapshots of sandbox %s: %v", sandboxID, err)
+		return
+	}
+	for _, snap := range snapshots {
+		if err := snapshotter.DeleteSessionDataSnapshot(ctx, sandboxID, snap.Ref); err != nil {
+			log.Printf("failed to delete snapshot %s of sandbox %s: %v", snap.Ref, sandboxID, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/storage"
+)
+
+// provisionAndStart ensures the workspace drive exists (when needsDrive) and
+// then starts the sandbox container. A drive failure leaves the sandbox in
+// StatusStorageFailed with the error as its status message, so it can be
+// inspected and retried instead of silently starting without a drive.
+func (s *Server) provisionAndStart(id, wsID string, opts process.StartOptions, needsDrive bool) {
+	if needsDrive {
+		mounts, err := storage.EnsureDriveWithTimeout(s.DriveManager, wsID, opts.Namespace)
+		if err != nil {
+			log.Printf("failed to ensure workspace drive for sandbox %s (workspace %s): %v", id, wsID, err)
+			s.savePendingStart(id, opts)
+			if err := s.Sandboxes.UpdateStatusMessage(id, sbxstore.StatusStorageFailed, "workspace drive provisioning failed: "+err.Error()); err != nil {
+				log.Printf("failed to update status for sandbox %s: %v", id, err)
+			}
+			return
+		}
+		s.clearPendingStart(id)
+		opts.WorkspaceVolumes = mounts
+		if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusCreating); err != nil {
+			log.Printf("failed to update status for sandbox %s: %v", id, err)
+		}
+	}
+
+	release := s.acquireStart("create", id, wsID, opts.CPU, opts.Memory, opts.NodePool)
+	defer release()
+
+	// A clone gets a copy of its source's session data first.
+	if err := s.cloneSessionData(id); err != nil {
+		s.finishClone(id, err)
+		s.handleSandboxStartFailed(id, opts, err)
+		return
+	}
+
+	var podIP string
+	// Use StartContainerWithIP if available (K8s backend) to get the pod IP.
+	if sc, ok := s.ProcessManager.(interface {
+		StartContainerWithIP(string, process.StartOptions) (string, error)
+	}); ok {
+		var err error
+		podIP, err = sc.StartContainerWithIP(id, opts)
+		if err != nil {
+			s.finishClone(id, err)
+			s.handleSandboxStartFailed(id, opts, err)
+			return
+		}
+	} else {
+		if err := s.ProcessManager.StartContainer(id, opts); err != nil {
+			s.finishClone(id, err)
+			s.handleSandboxStartFailed(id, opts, err)
+			return
+		}
+	}
+	s.finishClone(id, nil)
+	if podIP != "" {
+		if err := s.DB.UpdateSandboxPodIP(id, podIP); err != nil {
+			log.Printf("failed to update pod IP for sandbox %s: %v", id, err)
+		}
+	}
+	s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
+	s.runTemplateInitScripts(id)
+	s.runPostStartHooks(id, "start")
+}
+
+// handleRetrySandboxStorage retries workspace drive provisioning for a
+// sandbox in StatusStorageFailed and starts it on success.
+func (s *Server) handleRetrySandboxStorage(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if sbx.Status != sbxstore.StatusStorageFailed {
+		http.Error(w, "sandbox storage cannot be retried in current state: "+sbx.Status, http.StatusConflict)
+		return
+	}
+
+	opts, ok := s.loadPendingStart(w, sbx)
+	if !ok {
+		return
+	}
+
+	if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusProvisioningStorage); err != nil {
+		http.Error(w, "failed to update status", http.StatusInternalServerError)
+		return
+	}
+	go s.provisionAndStart(id, sbx.WorkspaceID, opts, true)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": sbxstore.StatusProvisioningStorage})
+}
+
+// savePendingStart stores the start options of a sandbox waiting for a
+// storage or start retry in its row, so the retry survives a restart. The
+// credentials are left out and looked up again by loadPendingStart.
+func (s *Server) savePendingStart(id string, opts process.StartOptions) {
+	opts.OpencodeToken, opts.ProxyToken, opts.OpenclawToken, opts.NanoclawBridgeSecret = "", "", "", ""
+	opts.BYOKAPIKey = ""
+	opts.SecretEnv, opts.SecretFiles = nil, nil
+	data, err := json.Marshal(opts)
+	if err == nil {
+		err = s.DB.SetSandboxStartOptions(id, data)
+	}
+	if err != nil {
+		log.Printf("failed to store start options of sandbox %s: %v", id, err)
+	}
+}
+
+func (s *Server) clearPendingStart(id string) {
+	if err := s.DB.ClearSandboxStartOptions(id); err != nil {
+		log.Printf("failed to clear start options of sandbox %s: %v", id, err)
+	}
+}
+
+// loadPendingStart returns the stored start options of sbx with its
+// credentials filled in: its tokens, the workspace secrets and BYOK key.
+// Otherwise it writes the error and returns false.
+func (s *Server) loadPendingStart(w http.ResponseWriter, sbx *sbxstore.Sandbox) (process.StartOptions, bool) {
+	var opts process.StartOptions
+	data, err := s.DB.GetSandboxStartOptions(sbx.ID)
+	if err != nil {
+		log.Printf("failed to load start options of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to load sandbox start options", http.StatusInternalServerError)
+		return opts, false
+	}
+	if data == nil {
+		http.Error(w, "sandbox start options are no longer available; delete and recreate the sandbox", http.StatusConflict)
+		return opts, false
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		log.Printf("failed to decode start options of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to load sandbox start options", http.StatusInternalServerError)
+		return opts, false
+	}
+	opts.OpencodeToken, opts.ProxyToken, opts.OpenclawToken = sbx.OpencodeToken, sbx.ProxyToken, sbx.OpenclawToken
+	opts.NanoclawBridgeSecret = sbx.NanoclawBridgeSecret
+	if opts.SecretEnv, opts.SecretFiles, err = s.workspaceSecretValues(sbx.WorkspaceID); err != nil {
+		log.Printf("failed to load secrets of workspace %s: %v", sbx.WorkspaceID, err)
+		http.Error(w, "failed to load workspace secrets", http.StatusInternalServerError)
+		return opts, false
+	}
+	if opts.BYOKBaseURL != "" {
+		cfg, err := s.DB.GetWorkspaceLLMConfig(sbx.WorkspaceID)
+		if err != nil {
+			log.Printf("failed to get BYOK config for workspace %s: %v", sbx.WorkspaceID, err)
+			http.Error(w, "failed to load workspace LLM config", http.StatusInternalServerError)
+			return opts, false
+		}
+		if cfg != nil {
+			opts.BYOKAPIKey = cfg.APIKey
+		}
+	}
+	return opts, true
+}
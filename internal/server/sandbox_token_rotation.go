@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// envSecretRotator is implemented by backends that keep a sandbox's tokens
+// in a Secret its pod reads at start (K8s), so they can be replaced while
+// the sandbox is paused.
+type envSecretRotator interface {
+	RotateEnvSecret(ctx context.Context, sandboxID string, replacements map[string]string) error
+}
+
+// handleRotateSandboxTokens replaces the proxy, opencode and openclaw tokens
+// of a paused sandbox. The old tokens stop working at once; the sandbox
+// reads the new ones from its env Secret when it is resumed.
+func (s *Server) handleRotateSandboxTokens(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer") {
+		return
+	}
+	if sbx.IsLocal {
+		http.Error(w, "local sandboxes have no server-managed tokens", http.StatusBadRequest)
+		return
+	}
+	rotator, ok := s.ProcessManager.(envSecretRotator)
+	if !ok {
+		http.Error(w, "token rotation is not supported by this backend", http.StatusBadRequest)
+		return
+	}
+	if _, busy := s.snapshotOps.LoadOrStore(id, struct{}{}); busy {
+		http.Error(w, "sandbox is being snapshotted, restored or migrated", http.StatusConflict)
+		return
+	}
+	defer s.snapshotOps.Delete(id)
+	// Resumes are refused while the operation is registered; re-read the
+	// status in case one started before.
+	if sbx, ok = s.Sandboxes.Get(id); !ok || sbx.Status != sbxstore.StatusPaused {
+		http.Error(w, "sandbox must be paused to rotate its tokens", http.StatusConflict)
+		return
+	}
+
+	replacements := make(map[string]string)
+	rotate := func(old string) string {
+		if old == "" {
+			return ""
+		}
+		token := generatePassword()
+		replacements[old] = token
+		return token
+	}
+	proxyToken := rotate(sbx.ProxyToken)
+	opencodeToken := rotate(sbx.OpencodeToken)
+	openclawToken := rotate(sbx.OpenclawToken)
+	if len(replacements) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := rotator.RotateEnvSecret(r.Context(), id, replacements); err != nil {
+		log.Printf("failed to rotate env secret of sandbox %s: %v", id, err)
+		http.Error(w, "failed to rotate tokens", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.RotateSandboxTokens(id, proxyToken, opencodeToken, openclawToken); err != nil {
+		log.Printf("failed to store rotated tokens of sandbox %s: %v", id, err)
+		// Put the old tokens back so the Secret matches the database.
+		restore := make(map[string]string, len(replacements))
+		for old, token := range replacements {
+			restore[token] = old
+		}
+		if err := rotator.RotateEnvSecret(context.Background(), id, restore); err != nil {
+			log.Printf("failed to restore env secret of sandbox %s: %v", id, err)
+		}
+		http.Error(w, "failed to rotate tokens", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sandbox %s tokens rotated by %s", id, auth.UserIDFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
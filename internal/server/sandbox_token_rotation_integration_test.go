@@ -0,0 +1,104 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+// rotatorBackend records the env Secret replacements. Other Manager methods
+// are not used.
+type rotatorBackend struct {
+	process.Manager
+	rotations []map[string]string
+}
+
+func (b *rotatorBackend) RotateEnvSecret(ctx context.Context, sandboxID string, replacements map[string]string) error {
+	b.rotations = append(b.rotations, replacements)
+	return nil
+}
+
+// TestIntegration_RotateSandboxTokens checks that a maintainer can rotate
+// the tokens of a paused sandbox, that the env Secret and the database get
+// the same new tokens, and that the old proxy token stops validating.
+func TestIntegration_RotateSandboxTokens(t *testing.T) {
+	d := testenv.DB(t)
+	backend := &rotatorBackend{}
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d), ProcessManager: backend}
+
+	wsID := uuid.NewString()
+	ownerID := uuid.NewString()
+	devID := uuid.NewString()
+	sbxID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, ownerID, "owner")
+	seedWorkspaceMember(t, d, wsID, devID, "developer")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id IN ($1, $2)`, ownerID, devID)
+	})
+	oldProxy := generatePassword()
+	if err := d.CreateSandbox(sbxID, wsID, "rotate", "opencode", "", "old-pw", oldProxy, "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusRunning)
+
+	r := chi.NewRouter()
+	r.Post("/api/sandboxes/{id}/rotate-tokens", s.handleRotateSandboxTokens)
+	do := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/sandboxes/"+sbxID+"/rotate-tokens", nil).
+			WithContext(auth.ContextWithUserID(context.Background(), userID))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(devID); rr.Code != http.StatusForbidden {
+		t.Fatalf("rotation by developer: status %d, want 403", rr.Code)
+	}
+	if rr := do(ownerID); rr.Code != http.StatusConflict {
+		t.Fatalf("rotation of a running sandbox: status %d, want 409", rr.Code)
+	}
+	s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusPaused)
+	s.snapshotOps.Store(sbxID, struct{}{})
+	if rr := do(ownerID); rr.Code != http.StatusConflict {
+		t.Fatalf("rotation during a snapshot: status %d, want 409", rr.Code)
+	}
+	s.snapshotOps.Delete(sbxID)
+	if len(backend.rotations) != 0 {
+		t.Fatalf("env secret rotated on refused requests: %v", backend.rotations)
+	}
+
+	if rr := do(ownerID); rr.Code != http.StatusNoContent {
+		t.Fatalf("rotation: status %d (%s), want 204", rr.Code, rr.Body.String())
+	}
+	sbx, _ := s.Sandboxes.Get(sbxID)
+	if sbx == nil || sbx.ProxyToken == oldProxy || sbx.ProxyToken == "" || sbx.OpencodeToken == "old-pw" || sbx.OpencodeToken == "" {
+		t.Fatalf("tokens not rotated: %+v", sbx)
+	}
+	if sbx.OpenclawToken != "" {
+		t.Errorf("openclaw token set on a sandbox without one: %q", sbx.OpenclawToken)
+	}
+	if len(backend.rotations) != 1 {
+		t.Fatalf("env secret rotations = %v", backend.rotations)
+	}
+	if got := backend.rotations[0]; len(got) != 2 || got[oldProxy] != sbx.ProxyToken || got["old-pw"] != sbx.OpencodeToken {
+		t.Errorf("env secret replacements = %v", got)
+	}
+	if pt, err := d.GetProxyToken(oldProxy); err != nil || pt != nil {
+		t.Errorf("old proxy token still valid: %+v, %v", pt, err)
+	}
+	if pt, err := d.GetProxyToken(sbx.ProxyToken); err != nil || pt == nil || pt.SandboxID.String != sbxID {
+		t.Errorf("new proxy token = %+v, %v", pt, err)
+	}
+}
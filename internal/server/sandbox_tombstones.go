@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Deleting a sandbox leaves a tombstone with who deleted it, when and why,
+// its last status and its compute totals, so billing can be reconciled and
+// "where did my sandbox go" answered after the sandbox and its runs are
+// gone. Tombstones are kept for sandboxTombstoneRetention, also after the
+// workspace is deleted (then only admins can read them).
+
+const sandboxTombstoneRetention = 90 * 24 * time.Hour
+
+// tombstoneFilter parses the reason, since and limit query parameters.
+func tombstoneFilter(r *http.Request) (db.SandboxTombstoneFilter, string) {
+	q := r.URL.Query()
+	f := db.SandboxTombstoneFilter{Reason: q.Get("reason")}
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, "since must be RFC3339"
+		}
+		f.Since = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, "limit: invalid"
+		}
+		f.Limit = n
+	}
+	return f, ""
+}
+
+func (s *Server) writeSandboxTombstones(w http.ResponseWriter, f db.SandboxTombstoneFilter) {
+	tombstones, err := s.DB.ListSandboxTombstones(f)
+	if err != nil {
+		log.Printf("failed to list sandbox tombstones: %v", err)
+		http.Error(w, "failed to list deleted sandboxes", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tombstones)
+}
+
+func (s *Server) writeSandboxTombstone(w http.ResponseWriter, sandboxID, workspaceID string) {
+	t, err := s.DB.GetSandboxTombstone(sandboxID)
+	if err != nil {
+		log.Printf("failed to get tombstone of sandbox %s: %v", sandboxID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if t == nil || (workspaceID != "" && t.WorkspaceID != workspaceID) {
+		http.Error(w, "deleted sandbox not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// GET /api/workspaces/{id}/deleted-sandboxes?reason=&since=&limit=
+func (s *Server) handleListDeletedSandboxes(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, id); !ok {
+		return
+	}
+	f, msg := tombstoneFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	f.WorkspaceID = id
+	s.writeSandboxTombstones(w, f)
+}
+
+// GET /api/workspaces/{id}/deleted-sandboxes/{sandboxID}
+func (s *Server) handleGetDeletedSandbox(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, id); !ok {
+		return
+	}
+	s.writeSandboxTombstone(w, chi.URLParam(r, "sandboxID"), id)
+}
+
+// GET /api/admin/sandbox-tombstones?workspace_id=&reason=&since=&limit=
+func (s *Server) handleAdminListSandboxTombstones(w http.ResponseWriter, r *http.Request) {
+	f, msg := tombstoneFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	f.WorkspaceID = r.URL.Query().Get("workspace_id")
+	s.writeSandboxTombstones(w, f)
+}
+
+// GET /api/admin/sandbox-tombstones/{id}
+func (s *Server) handleAdminGetSandboxTombstone(w http.ResponseWriter, r *http.Request) {
+	s.writeSandboxTombstone(w, chi.URLParam(r, "id"), "")
+}
+
+// StartSandboxTombstonePruneLoop is the exported entry point for the
+// server's main lifecycle to delete tombstones older than
+// sandboxTombstoneRetention every `every`. Returns when ctx is cancelled.
+func (s *Server) StartSandboxTombstonePruneLoop(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.DB.DeleteSandboxTombstonesBefore(time.Now().Add(-sandboxTombstoneRetention))
+			if err != nil {
+				log.Printf("sandbox tombstones: prune failed: %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("sandbox tombstones: pruned %d older than %s", n, sandboxTombstoneRetention)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// PATCH /api/sandboxes/{id} updates the user-editable fields of a sandbox:
+// its name, description, labels and idle timeout override. The
+// description and labels are kept in the sandbox's metadata.
+
+const (
+	sandboxDescriptionKey = "description"
+	sandboxLabelsKey      = "labels"
+
+	maxSandboxNameLen        = 100
+	maxSandboxDescriptionLen = 2000
+	maxSandboxLabels         = 32
+	maxSandboxLabelValueLen  = 63
+)
+
+// sandboxLabelKeyRe matches label keys: lowercase, optionally with a
+// "prefix/" like Kubernetes labels.
+var sandboxLabelKeyRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9.-]{0,61}[a-z0-9])?/)?[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+
+// sandboxLabels returns the labels of a sandbox, or nil if it has none.
+// Like Sandbox.MetadataStrings, it accepts both the in-memory and the
+// decoded form.
+func sandboxLabels(sbx *sbxstore.Sandbox) map[string]string {
+	switch v := sbx.Metadata[sandboxLabelsKey].(type) {
+	case map[string]string:
+		if len(v) > 0 {
+			return v
+		}
+	case map[string]interface{}:
+		labels := make(map[string]string, len(v))
+		for k, e := range v {
+			if str, ok := e.(string); ok {
+				labels[k] = str
+			}
+		}
+		if len(labels) > 0 {
+			return labels
+		}
+	}
+	return nil
+}
+
+// mergeSandboxLabels applies a label patch to the current labels: keys
+// with a value are set, keys with null removed. It returns the new labels
+// or the reason the patch is invalid.
+func mergeSandboxLabels(current map[string]string, patch map[string]*string) (map[string]string, error) {
+	labels := make(map[string]string, len(current)+len(patch))
+	for k, v := range current {
+		labels[k] = v
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(labels, k)
+			continue
+		}
+		if !sandboxLabelKeyRe.MatchString(k) {
+			return nil, fmt.Errorf("invalid label key %q", k)
+		}
+		if len(*v) > maxSandboxLabelValueLen || !utf8.ValidString(*v) || strings.ContainsAny(*v, "\n\r\t") {
+			return nil, fmt.Errorf("label %s: value must be at most %d characters on one line", k, maxSandboxLabelValueLen)
+		}
+		labels[k] = *v
+	}
+	if len(labels) > maxSandboxLabels {
+		return nil, fmt.Errorf("a sandbox can have at most %d labels", maxSandboxLabels)
+	}
+	return labels, nil
+}
+
+// handleUpdateSandbox updates a sandbox's name, description, labels or
+// idle timeout; omitted fields are unchanged. "labels" is merged into the
+// current labels, with null removing a label; "idle_timeout": null reverts
+// to the workspace default. Guests can't update sandboxes.
+func (s *Server) handleUpdateSandbox(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var u db.SandboxUpdate
+	for key, raw := range fields {
+		switch key {
+		case "name":
+			var name string
+			if err := json.Unmarshal(raw, &name); err != nil || strings.TrimSpace(name) == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			name = strings.TrimSpace(name)
+			if utf8.RuneCountInString(name) > maxSandboxNameLen {
+				http.Error(w, fmt.Sprintf("name must be at most %d characters", maxSandboxNameLen), http.StatusBadRequest)
+				return
+			}
+			u.Name = &name
+		case "description":
+			var desc *string
+			if err := json.Unmarshal(raw, &desc); err != nil {
+				http.Error(w, "description must be a string", http.StatusBadRequest)
+				return
+			}
+			if desc != nil && utf8.RuneCountInString(*desc) > maxSandboxDescriptionLen {
+				http.Error(w, fmt.Sprintf("description must be at most %d characters", maxSandboxDescriptionLen), http.StatusBadRequest)
+				return
+			}
+			if u.Metadata == nil {
+				u.Metadata = make(map[string]interface{})
+			}
+			if desc == nil || *desc == "" {
+				u.Metadata[sandboxDescriptionKey] = nil
+			} else {
+				u.Metadata[sandboxDescriptionKey] = *desc
+			}
+		case "labels":
+			var patch map[string]*string
+			if err := json.Unmarshal(raw, &patch); err != nil {
+				http.Error(w, "labels must be an object of strings", http.StatusBadRequest)
+				return
+			}
+			labels, err := mergeSandboxLabels(sandboxLabels(sbx), patch)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if u.Metadata == nil {
+				u.Metadata = make(map[string]interface{})
+			}
+			if len(labels) == 0 {
+				u.Metadata[sandboxLabelsKey] = nil
+			} else {
+				u.Metadata[sandboxLabelsKey] = labels
+			}
+		case "idle_timeout":
+			var idle *int
+			if err := json.Unmarshal(raw, &idle); err != nil {
+				http.Error(w, "idle_timeout must be a number of seconds or null", http.StatusBadRequest)
+				return
+			}
+			if sbx.IsLocal {
+				http.Error(w, "local sandboxes have no idle timeout", http.StatusBadRequest)
+				return
+			}
+			if idle != nil {
+				wd, err := s.effectiveWorkspaceDefaults(sbx.WorkspaceID)
+				if err != nil {
+					log.Printf("failed to get workspace defaults for %s: %v", sbx.WorkspaceID, err)
+					http.Error(w, "internal error", http.StatusInternalServerError)
+					return
+				}
+				if *idle < 0 || (wd.MaxIdleTimeout > 0 && (*idle == 0 || *idle > wd.MaxIdleTimeout)) {
+					http.Error(w, fmt.Sprintf("idle_timeout must be between 1 and %d seconds", wd.MaxIdleTimeout), http.StatusBadRequest)
+					return
+				}
+			}
+			u.SetIdleTimeout, u.IdleTimeout = true, idle
+		default:
+			http.Error(w, fmt.Sprintf("unknown field %q", key), http.StatusBadRequest)
+			return
+		}
+	}
+	if u.Name == nil && u.Metadata == nil && !u.SetIdleTimeout {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.DB.UpdateSandbox(id, u); err != nil {
+		log.Printf("failed to update sandbox %s: %v", id, err)
+		http.Error(w, "failed to update sandbox", http.StatusInternalServerError)
+		return
+	}
+	if updated, ok := s.Sandboxes.Get(id); ok {
+		sbx = updated
+	}
+	changed := make([]string, 0, len(fields))
+	for key := range fields {
+		changed = append(changed, key)
+	}
+	slices.Sort(changed)
+	s.Sandboxes.Publish(sbxstore.Event{
+		Type:        sbxstore.EventUpdated,
+		WorkspaceID: sbx.WorkspaceID,
+		SandboxID:   sbx.ID,
+		Data:        map[string]interface{}{"fields": changed},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.toSandboxResponse(r, sbx, authTokenFromRequest(r)))
+}
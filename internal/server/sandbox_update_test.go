@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestMergeSandboxLabels(t *testing.T) {
+	str := func(s string) *string { return &s }
+	current := map[string]string{"team": "a", "env": "dev"}
+
+	got, err := mergeSandboxLabels(current, map[string]*string{"env": nil, "owner": str("bob"), "example.com/tier": str("gold")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"team": "a", "owner": "bob", "example.com/tier": "gold"}
+	if !maps.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if current["env"] != "dev" {
+		t.Error("current labels modified")
+	}
+
+	for _, patch := range []map[string]*string{
+		{"Team": str("a")},
+		{"-x": str("a")},
+		{"a b": str("a")},
+		{"ok": str("line\nbreak")},
+		{"ok": str(string(make([]byte, maxSandboxLabelValueLen+1)))},
+	} {
+		if _, err := mergeSandboxLabels(nil, patch); err == nil {
+			t.Errorf("patch %v accepted", patch)
+		}
+	}
+
+	many := map[string]*string{}
+	for i := 0; i <= maxSandboxLabels; i++ {
+		many[fmt.Sprintf("l%d", i)] = str("v")
+	}
+	if _, err := mergeSandboxLabels(nil, many); err == nil {
+		t.Error("too many labels accepted")
+	}
+}
+
+func TestSandboxLabels(t *testing.T) {
+	decoded := &sbxstore.Sandbox{Metadata: map[string]interface{}{"labels": map[string]interface{}{"team": "a", "n": 1.0}}}
+	if got := sandboxLabels(decoded); !maps.Equal(got, map[string]string{"team": "a"}) {
+		t.Errorf("decoded labels = %v", got)
+	}
+	if got := sandboxLabels(&sbxstore.Sandbox{}); got != nil {
+		t.Errorf("labels of a sandbox without = %v", got)
+	}
+}
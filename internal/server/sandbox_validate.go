@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// POST /api/workspaces/{wid}/sandboxes:validate takes a sandbox creation
+// request and runs the checks of POST /api/workspaces/{wid}/sandboxes
+// (quotas, resource budget, template, type and image policy, capacity)
+// without creating anything. Instead of stopping at the first failed
+// check, it returns every problem found, each with a machine-readable
+// code, as far as the checks don't depend on each other: an unknown
+// template, for example, ends the validation.
+
+// createProblem is a reason a sandbox creation request would be refused.
+type createProblem struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Status  int                    `json:"status"` // what creating would answer
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// createPlan is what a valid creation request would create.
+type createPlan struct {
+	Type        string `json:"type"`
+	CPU         int    `json:"cpu"`
+	Memory      int64  `json:"memory"`
+	IdleTimeout *int   `json:"idle_timeout,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Region      string `json:"region,omitempty"`
+}
+
+// sandboxCreateCheck reports the failed checks of a sandbox creation: by
+// refusing the request when creating, and by collecting them when
+// validating.
+type sandboxCreateCheck struct {
+	w        http.ResponseWriter
+	validate bool
+	problems []createProblem
+}
+
+// reject refuses the request with a plain-text error, or records the
+// problem when validating. It reports whether the handler must stop.
+func (c *sandboxCreateCheck) reject(status int, code, message string) bool {
+	if c.validate {
+		c.problems = append(c.problems, createProblem{Code: code, Message: message, Status: status})
+		return false
+	}
+	http.Error(c.w, message, status)
+	return true
+}
+
+// rejectJSON is reject with a JSON error carrying code, message and the
+// fields of details.
+func (c *sandboxCreateCheck) rejectJSON(status int, code, message string, details map[string]interface{}) bool {
+	if c.validate {
+		c.problems = append(c.problems, createProblem{Code: code, Message: message, Status: status, Details: details})
+		return false
+	}
+	body := map[string]interface{}{"error": code, "message": message}
+	for k, v := range details {
+		body[k] = v
+	}
+	c.w.Header().Set("Content-Type", "application/json")
+	c.w.WriteHeader(status)
+	json.NewEncoder(c.w).Encode(body)
+	return true
+}
+
+// fatal is reject for a problem that later checks depend on: validation
+// ends with it.
+func (c *sandboxCreateCheck) fatal(status int, code, message string) bool {
+	if !c.reject(status, code, message) {
+		c.writeResult(nil)
+	}
+	return true
+}
+
+// writer returns where a reject helper that writes its own response
+// (rejectIfNoCapacity, ...) should write: the client when creating, a
+// buffer for rejected when validating.
+func (c *sandboxCreateCheck) writer() http.ResponseWriter {
+	if !c.validate {
+		return c.w
+	}
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+// rejected handles a refusal that a reject helper wrote to rw, from
+// writer. When validating, a JSON error becomes a problem with its error
+// code, and a plain-text one a problem with code; server errors are passed
+// on. It reports whether the handler must stop.
+func (c *sandboxCreateCheck) rejected(rw http.ResponseWriter, code string) bool {
+	buf, ok := rw.(*bufferedResponse)
+	if !ok {
+		return true
+	}
+	if buf.status >= http.StatusInternalServerError {
+		for k, v := range buf.header {
+			c.w.Header()[k] = v
+		}
+		c.w.WriteHeader(buf.status)
+		c.w.Write(buf.body.Bytes())
+		return true
+	}
+	p := createProblem{Code: code, Message: strings.TrimSpace(buf.body.String()), Status: buf.status}
+	var body map[string]interface{}
+	if json.Unmarshal(buf.body.Bytes(), &body) == nil {
+		for k, v := range body {
+			switch s, _ := v.(string); k {
+			case "error":
+				p.Code = s
+			case "message":
+				p.Message = s
+			default:
+				if p.Details == nil {
+					p.Details = make(map[string]interface{})
+				}
+				p.Details[k] = v
+			}
+		}
+	}
+	c.problems = append(c.problems, p)
+	return false
+}
+
+// writeResult sends the validation result, with the sandbox that would be
+// created if the request is valid.
+func (c *sandboxCreateCheck) writeResult(plan *createPlan) {
+	problems := c.problems
+	if problems == nil {
+		problems = []createProblem{}
+	}
+	if len(problems) > 0 {
+		plan = nil
+	}
+	c.w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(c.w).Encode(map[string]interface{}{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+		"sandbox":  plan,
+	})
+}
+
+func (s *Server) handleValidateSandbox(w http.ResponseWriter, r *http.Request) {
+	s.createSandbox(w, r, true)
+}
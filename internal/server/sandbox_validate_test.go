@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSandboxCreateCheckCreating(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := &sandboxCreateCheck{w: rec}
+	if !c.rejectJSON(http.StatusForbidden, "quota_exceeded", "limit reached", map[string]interface{}{"quota": 3}) {
+		t.Fatal("rejectJSON did not stop the creation")
+	}
+	var body map[string]interface{}
+	if rec.Code != http.StatusForbidden || json.Unmarshal(rec.Body.Bytes(), &body) != nil ||
+		body["error"] != "quota_exceeded" || body["message"] != "limit reached" || body["quota"] != float64(3) {
+		t.Errorf("response = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	c = &sandboxCreateCheck{w: rec}
+	if rw := c.writer(); rw != http.ResponseWriter(rec) {
+		t.Error("writer buffers when creating")
+	}
+	if !c.reject(http.StatusBadRequest, "invalid_cpu", "bad cpu") || rec.Code != http.StatusBadRequest ||
+		strings.TrimSpace(rec.Body.String()) != "bad cpu" {
+		t.Errorf("reject response = %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSandboxCreateCheckValidating(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := &sandboxCreateCheck{w: rec, validate: true}
+	if c.reject(http.StatusBadRequest, "invalid_cpu", "bad cpu") {
+		t.Fatal("reject stopped the validation")
+	}
+	rw := c.writer()
+	http.Error(rw, "workspace is archived", http.StatusConflict)
+	if c.rejected(rw, "workspace_archived") {
+		t.Fatal("rejected stopped the validation")
+	}
+	rw = c.writer()
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(rw).Encode(map[string]interface{}{"error": "insufficient_capacity", "message": "no room", "capacity": map[string]int{"max_free_cpu": 500}})
+	if !c.rejected(rw, "capacity") {
+		t.Fatal("a server error did not stop the validation")
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("server error not passed on: %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	c.w = rec
+	c.problems = c.problems[:2]
+	c.fatal(http.StatusBadRequest, "unknown_template", "unknown template: x")
+	var res struct {
+		Valid    bool            `json:"valid"`
+		Problems []createProblem `json:"problems"`
+		Sandbox  *createPlan     `json:"sandbox"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	var codes []string
+	for _, p := range res.Problems {
+		codes = append(codes, p.Code)
+	}
+	if res.Valid || res.Sandbox != nil || strings.Join(codes, ",") != "invalid_cpu,workspace_archived,unknown_template" {
+		t.Errorf("result = %s", rec.Body.String())
+	}
+	if res.Problems[1].Message != "workspace is archived" || res.Problems[1].Status != http.StatusConflict {
+		t.Errorf("captured problem = %+v", res.Problems[1])
+	}
+
+	rec = httptest.NewRecorder()
+	c = &sandboxCreateCheck{w: rec, validate: true}
+	c.writeResult(&createPlan{Type: "opencode", CPU: 1000, Memory: 1 << 30})
+	if !strings.Contains(rec.Body.String(), `"valid":true`) || !strings.Contains(rec.Body.String(), `"type":"opencode"`) {
+		t.Errorf("valid result = %s", rec.Body.String())
+	}
+}
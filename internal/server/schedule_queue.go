@@ -0,0 +1,352 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/go-chi/chi/v5"
+)
+
+// The scheduling queue holds sandbox starts and resumes while the cluster
+// is constrained: more than SANDBOX_MAX_CONCURRENT_STARTS in flight, or
+// (with SANDBOX_SCHEDULING_QUEUE=true on the k8s backend) no node with room
+// for the sandbox. Entries are dispatched by workspace priority tier, then
+// by weighted fair share across workspaces, so one team's burst can't
+// starve the others; within a workspace they are dispatched in order.
+//
+// Fair share uses virtual time: each dispatch advances the workspace's
+// clock by 1/weight, and the workspace with the earliest clock goes next.
+// The weight is the workspace's sandbox quota.
+
+const (
+	// scheduleRecheckInterval is how often a queue blocked on capacity
+	// re-checks the cluster.
+	scheduleRecheckInterval = 15 * time.Second
+	// defaultStartDuration seeds the start duration estimate used for
+	// estimated waits until starts have been observed.
+	defaultStartDuration = 30 * time.Second
+)
+
+// scheduleRequest describes a queued sandbox start or resume.
+type scheduleRequest struct {
+	SandboxID   string
+	WorkspaceID string
+	Kind        string // "create" or "resume"
+	Rank        int    // priority tier rank
+	Weight      int    // fair-share weight, >= 1
+	CPU         int
+	Memory      int64
+	NodePool    *process.NodePool
+}
+
+type scheduleEntry struct {
+	scheduleRequest
+	enqueuedAt time.Time
+	seq        uint64
+	ready      chan struct{}
+}
+
+// scheduleQueue is the scheduling queue. A nil queue, or one with no
+// concurrency limit and no capacity check, dispatches immediately.
+type scheduleQueue struct {
+	limit int
+	// fits reports whether a sandbox fits in the cluster now; nil disables
+	// capacity-based queueing.
+	fits func(ctx context.Context, req *scheduleRequest) bool
+
+	mu       sync.Mutex
+	active   int
+	seq      uint64
+	waiting  []*scheduleEntry
+	vtime    map[string]float64 // workspace -> virtual finish time
+	clock    float64            // virtual time of the last dispatch
+	avgStart time.Duration      // moving average of start durations
+	rerun    bool               // dispatch requested while one was running
+
+	dispatching sync.Mutex
+}
+
+func newScheduleQueue(limit int, fits func(ctx context.Context, req *scheduleRequest) bool) *scheduleQueue {
+	return &scheduleQueue{
+		limit:    limit,
+		fits:     fits,
+		vtime:    make(map[string]float64),
+		avgStart: defaultStartDuration,
+	}
+}
+
+func (q *scheduleQueue) enabled() bool {
+	return q != nil && (q.limit > 0 || q.fits != nil)
+}
+
+// acquire queues req and blocks until it is dispatched. The returned
+// function must be called when the start finished, successfully or not.
+func (q *scheduleQueue) acquire(req scheduleRequest) (release func()) {
+	if !q.enabled() {
+		return func() {}
+	}
+	if req.Weight < 1 {
+		req.Weight = 1
+	}
+	q.mu.Lock()
+	q.seq++
+	e := &scheduleEntry{scheduleRequest: req, enqueuedAt: time.Now(), seq: q.seq, ready: make(chan struct{})}
+	q.waiting = append(q.waiting, e)
+	q.mu.Unlock()
+
+	go q.dispatch(context.Background())
+	<-e.ready
+
+	started := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.active--
+			q.avgStart = (q.avgStart*4 + time.Since(started)) / 5
+			q.mu.Unlock()
+			go q.dispatch(context.Background())
+		})
+	}
+}
+
+// dispatch starts queued entries while there are free start slots and the
+// next entry fits in the cluster. The next entry blocks those behind it
+// until it fits, so large sandboxes aren't starved by smaller ones. Only
+// one dispatcher runs at a time; calls made meanwhile make it run again.
+func (q *scheduleQueue) dispatch(ctx context.Context) {
+	q.mu.Lock()
+	q.rerun = true
+	q.mu.Unlock()
+	for {
+		if !q.dispatching.TryLock() {
+			return
+		}
+		q.dispatchOnce(ctx)
+		q.dispatching.Unlock()
+
+		q.mu.Lock()
+		again := q.rerun
+		q.mu.Unlock()
+		if !again {
+			return
+		}
+	}
+}
+
+func (q *scheduleQueue) dispatchOnce(ctx context.Context) {
+	for {
+		q.mu.Lock()
+		q.rerun = false
+		if len(q.waiting) == 0 || (q.limit > 0 && q.active >= q.limit) {
+			q.mu.Unlock()
+			return
+		}
+		next := q.order(1)[0]
+		q.mu.Unlock()
+
+		if q.fits != nil && !q.fits(ctx, &next.scheduleRequest) {
+			return
+		}
+
+		q.mu.Lock()
+		for i, e := range q.waiting {
+			if e == next {
+				q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+				break
+			}
+		}
+		start := q.vtime[next.WorkspaceID]
+		if start < q.clock {
+			start = q.clock
+		}
+		q.clock = start
+		q.vtime[next.WorkspaceID] = start + 1/float64(next.Weight)
+		q.active++
+		q.mu.Unlock()
+		close(next.ready)
+	}
+}
+
+// order returns up to n waiting entries in dispatch order. q.mu must be
+// held.
+func (q *scheduleQueue) order(n int) []*scheduleEntry {
+	vtime := make(map[string]float64, len(q.vtime))
+	for ws, t := range q.vtime {
+		vtime[ws] = t
+	}
+	clock := q.clock
+	remaining := append([]*scheduleEntry(nil), q.waiting...)
+	var out []*scheduleEntry
+	for len(remaining) > 0 && len(out) < n {
+		best := -1
+		var bestStart float64
+		for i, e := range remaining {
+			start := vtime[e.WorkspaceID]
+			if start < clock {
+				start = clock
+			}
+			if best < 0 {
+				best, bestStart = i, start
+				continue
+			}
+			b := remaining[best]
+			switch {
+			case e.Rank != b.Rank:
+				if e.Rank > b.Rank {
+					best, bestStart = i, start
+				}
+			case start != bestStart:
+				if start < bestStart {
+					best, bestStart = i, start
+				}
+			case e.seq < b.seq:
+				best, bestStart = i, start
+			}
+		}
+		e := remaining[best]
+		out = append(out, e)
+		clock = bestStart
+		vtime[e.WorkspaceID] = bestStart + 1/float64(e.Weight)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return out
+}
+
+// run re-checks a queue blocked on cluster capacity until ctx is cancelled,
+// calling beat after each check.
+func (q *scheduleQueue) run(ctx context.Context, every time.Duration, beat func(error)) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			q.dispatch(ctx)
+			beat(nil)
+		}
+	}
+}
+
+// scheduleQueueEntry is the API view of a queued start.
+type scheduleQueueEntry struct {
+	SandboxID            string    `json:"sandbox_id"`
+	WorkspaceID          string    `json:"workspace_id"`
+	Kind                 string    `json:"kind"`
+	Position             int       `json:"position"` // 1-based, in dispatch order
+	CPU                  int       `json:"cpu"`
+	Memory               int64     `json:"memory"`
+	EnqueuedAt           time.Time `json:"enqueued_at"`
+	EstimatedWaitSeconds int       `json:"estimated_wait_seconds"`
+}
+
+type scheduleQueueState struct {
+	Enabled bool                 `json:"enabled"`
+	Limit   int                  `json:"limit"`
+	Active  int                  `json:"active"`
+	Entries []scheduleQueueEntry `json:"entries"`
+}
+
+// state returns the queue in dispatch order. Estimated waits assume starts
+// keep taking as long as they did recently and ignore capacity changes.
+func (q *scheduleQueue) state() scheduleQueueState {
+	st := scheduleQueueState{Enabled: q.enabled(), Entries: []scheduleQueueEntry{}}
+	if !st.Enabled {
+		return st
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st.Limit, st.Active = q.limit, q.active
+	slots := q.limit
+	if slots <= 0 {
+		slots = 1
+	}
+	for i, e := range q.order(len(q.waiting)) {
+		st.Entries = append(st.Entries, scheduleQueueEntry{
+			SandboxID:            e.SandboxID,
+			WorkspaceID:          e.WorkspaceID,
+			Kind:                 e.Kind,
+			Position:             i + 1,
+			CPU:                  e.CPU,
+			Memory:               e.Memory,
+			EnqueuedAt:           e.enqueuedAt,
+			EstimatedWaitSeconds: int((time.Duration(i/slots+1) * q.avgStart).Seconds()),
+		})
+	}
+	return st
+}
+
+// queuePosition returns the position of a sandbox in the queue, or 0.
+func (q *scheduleQueue) queuePosition(sandboxID string) int {
+	if !q.enabled() {
+		return 0
+	}
+	for _, e := range q.state().Entries {
+		if e.SandboxID == sandboxID {
+			return e.Position
+		}
+	}
+	return 0
+}
+
+// acquireStart queues a sandbox start or resume in the scheduling queue,
+// with the workspace's priority tier and fair-share weight.
+func (s *Server) acquireStart(kind, sandboxID, workspaceID string, cpu int, memory int64, pool *process.NodePool) (release func()) {
+	if !s.scheduleQueue.enabled() {
+		return func() {}
+	}
+	_, rank := s.workspacePriority(workspaceID)
+	weight := 1
+	if wd, err := s.effectiveWorkspaceDefaults(workspaceID); err == nil && wd.MaxSandboxes > 0 {
+		weight = wd.MaxSandboxes
+	}
+	return s.scheduleQueue.acquire(scheduleRequest{
+		SandboxID:   sandboxID,
+		WorkspaceID: workspaceID,
+		Kind:        kind,
+		Rank:        rank,
+		Weight:      weight,
+		CPU:         cpu,
+		Memory:      memory,
+		NodePool:    pool,
+	})
+}
+
+// StartSchedulingQueueLoop is the exported entry point for the server's
+// main lifecycle to re-check a capacity-blocked scheduling queue.
+func (s *Server) StartSchedulingQueueLoop(ctx context.Context) {
+	if s.scheduleQueue == nil || s.scheduleQueue.fits == nil {
+		return
+	}
+	s.scheduleQueue.run(ctx, scheduleRecheckInterval, s.ControllerHeartbeat(controllerSchedulingQueue, scheduleRecheckInterval))
+}
+
+// GET /api/admin/scheduling-queue
+func (s *Server) handleAdminSchedulingQueue(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.scheduleQueue.state())
+}
+
+// GET /api/workspaces/{id}/scheduling-queue lists the workspace's queued
+// starts with their positions in the whole queue.
+func (s *Server) handleWorkspaceSchedulingQueue(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	st := s.scheduleQueue.state()
+	entries := []scheduleQueueEntry{}
+	for _, e := range st.Entries {
+		if e.WorkspaceID == wsID {
+			entries = append(entries, e)
+		}
+	}
+	st.Entries = entries
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func queueOrder(q *scheduleQueue) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var ids []string
+	for _, e := range q.order(len(q.waiting)) {
+		ids = append(ids, e.SandboxID)
+	}
+	return ids
+}
+
+func enqueue(q *scheduleQueue, reqs ...scheduleRequest) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, req := range reqs {
+		q.seq++
+		q.waiting = append(q.waiting, &scheduleEntry{scheduleRequest: req, seq: q.seq, ready: make(chan struct{})})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScheduleQueueFairShare(t *testing.T) {
+	q := newScheduleQueue(1, nil)
+	enqueue(q,
+		scheduleRequest{SandboxID: "a1", WorkspaceID: "a", Weight: 1},
+		scheduleRequest{SandboxID: "a2", WorkspaceID: "a", Weight: 1},
+		scheduleRequest{SandboxID: "a3", WorkspaceID: "a", Weight: 1},
+		scheduleRequest{SandboxID: "a4", WorkspaceID: "a", Weight: 1},
+		scheduleRequest{SandboxID: "b1", WorkspaceID: "b", Weight: 1},
+		scheduleRequest{SandboxID: "b2", WorkspaceID: "b", Weight: 1},
+	)
+	want := []string{"a1", "b1", "a2", "b2", "a3", "a4"}
+	if got := queueOrder(q); !equalStrings(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestScheduleQueueWeights(t *testing.T) {
+	q := newScheduleQueue(1, nil)
+	enqueue(q,
+		scheduleRequest{SandboxID: "a1", WorkspaceID: "a", Weight: 2},
+		scheduleRequest{SandboxID: "a2", WorkspaceID: "a", Weight: 2},
+		scheduleRequest{SandboxID: "a3", WorkspaceID: "a", Weight: 2},
+		scheduleRequest{SandboxID: "b1", WorkspaceID: "b", Weight: 1},
+		scheduleRequest{SandboxID: "b2", WorkspaceID: "b", Weight: 1},
+		scheduleRequest{SandboxID: "b3", WorkspaceID: "b", Weight: 1},
+	)
+	want := []string{"a1", "b1", "a2", "a3", "b2", "b3"}
+	if got := queueOrder(q); !equalStrings(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestScheduleQueuePriorityFirst(t *testing.T) {
+	q := newScheduleQueue(1, nil)
+	enqueue(q,
+		scheduleRequest{SandboxID: "low", WorkspaceID: "a", Rank: priorityTiers["low"], Weight: 10},
+		scheduleRequest{SandboxID: "crit", WorkspaceID: "b", Rank: priorityTiers["critical"], Weight: 1},
+		scheduleRequest{SandboxID: "crit2", WorkspaceID: "b", Rank: priorityTiers["critical"], Weight: 1},
+	)
+	want := []string{"crit", "crit2", "low"}
+	if got := queueOrder(q); !equalStrings(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestScheduleQueueLimit(t *testing.T) {
+	q := newScheduleQueue(1, nil)
+	release := q.acquire(scheduleRequest{SandboxID: "first", WorkspaceID: "a"})
+
+	done := make(chan struct{})
+	go func() {
+		q.acquire(scheduleRequest{SandboxID: "second", WorkspaceID: "b"})()
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for q.queuePosition("second") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("second start was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if st := q.state(); st.Active != 1 || len(st.Entries) != 1 || st.Entries[0].EstimatedWaitSeconds <= 0 {
+		t.Errorf("state = %+v", st)
+	}
+
+	release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("second start was not dispatched after release")
+	}
+}
+
+func TestScheduleQueueWaitsForCapacity(t *testing.T) {
+	var room atomic.Bool
+	q := newScheduleQueue(0, func(context.Context, *scheduleRequest) bool { return room.Load() })
+
+	done := make(chan struct{})
+	go func() {
+		q.acquire(scheduleRequest{SandboxID: "big", WorkspaceID: "a", CPU: 4000})()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("dispatched without capacity")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	room.Store(true)
+	q.dispatch(context.Background())
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("not dispatched once capacity was available")
+	}
+}
+
+func TestScheduleQueueDisabled(t *testing.T) {
+	var q *scheduleQueue
+	q.acquire(scheduleRequest{})()
+	newScheduleQueue(0, nil).acquire(scheduleRequest{})()
+	if st := q.state(); st.Enabled || len(st.Entries) != 0 {
+		t.Errorf("state = %+v", st)
+	}
+}
@@ -0,0 +1,388 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/clientmeta"
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+// Security-relevant events are appended to the security_events table, which
+// the database keeps append-only, and optionally forwarded to syslog or a
+// SIEM webhook (SECURITY_EVENT_SINK) so admins are alerted without polling
+// the admin API.
+
+// Security event types.
+const (
+	SecurityEventLoginFailed      = "login_failed"
+	SecurityEventRevokedTokenUse  = "revoked_token_use"
+	SecurityEventRoleChanged      = "role_changed"
+	SecurityEventQuotaChanged     = "quota_changed"
+	SecurityEventErasureRequested = "erasure_requested"
+	SecurityEventUserErased       = "user_erased"
+	SecurityEventUserCreated      = "user_created"
+	SecurityEventUserDisabled     = "user_disabled"
+	SecurityEventUserEnabled      = "user_enabled"
+	SecurityEventTemplateImported = "template_imported"
+	SecurityEventTokenCreated     = "token_created"
+	SecurityEventTokenRevoked     = "token_revoked"
+	SecurityEventSessionRevoked   = "session_revoked"
+
+	SecurityEventImpersonationStarted = "impersonation_started"
+	SecurityEventImpersonationEnded   = "impersonation_ended"
+
+	// SecurityEventTest is a synthetic event sent to the sink on request;
+	// it is never recorded.
+	SecurityEventTest = "test"
+)
+
+// Security event severities, least severe first.
+const (
+	SecuritySeverityInfo     = "info"
+	SecuritySeverityWarning  = "warning"
+	SecuritySeverityCritical = "critical"
+)
+
+var securitySeverityRank = map[string]int{
+	SecuritySeverityInfo:     0,
+	SecuritySeverityWarning:  1,
+	SecuritySeverityCritical: 2,
+}
+
+// securityEventQueueSize bounds the events waiting to be forwarded; events
+// beyond it are dropped (they remain in the database).
+const securityEventQueueSize = 256
+
+// SecurityEventSink forwards security events outside agentserver.
+type SecurityEventSink interface {
+	// Send queues ev for delivery without blocking.
+	Send(ev *db.SecurityEvent)
+}
+
+// recordSecurityEvent stores ev and forwards it to the configured sink.
+// The actor and client IP default to the request's user and address.
+// Failures are logged: a security event never fails the request.
+func (s *Server) recordSecurityEvent(r *http.Request, ev *db.SecurityEvent) {
+	if ev.ActorID == "" {
+		ev.ActorID = auth.UserIDFromContext(r.Context())
+	}
+	if ev.IP == "" {
+		ev.IP = clientmeta.ClientIP(r)
+	}
+	if ev.Severity == "" {
+		ev.Severity = SecuritySeverityInfo
+	}
+	if adminID := auth.ImpersonatorFromContext(r.Context()); adminID != "" {
+		if ev.Details == nil {
+			ev.Details = map[string]interface{}{}
+		}
+		ev.Details["impersonated_by"] = adminID
+	}
+	if err := s.DB.RecordSecurityEvent(ev); err != nil {
+		log.Printf("failed to record security event %s: %v", ev.Type, err)
+		ev.CreatedAt = time.Now().UTC()
+	}
+	if s.SecurityEventSink != nil {
+		s.SecurityEventSink.Send(ev)
+	}
+}
+
+// recordQuotaChange records an admin change of the quota overrides of a
+// scope ("defaults", "user" or "workspace"); quota is the request body.
+func (s *Server) recordQuotaChange(r *http.Request, scope, targetID, action string, quota interface{}) {
+	details := map[string]interface{}{"scope": scope, "action": action}
+	if quota != nil {
+		details["quota"] = quota
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventQuotaChanged,
+		Severity: SecuritySeverityInfo,
+		TargetID: targetID,
+		Details:  details,
+	})
+}
+
+// recordRevokedCodexTokenUse records a presentation of a revoked codex
+// token with a valid secret: the secret leaked or a client kept it after
+// revocation. clientIP is the originating client as reported by the
+// gateway; empty uses the request address.
+func (s *Server) recordRevokedCodexTokenUse(r *http.Request, tokenID, ownerID, clientIP string) {
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventRevokedTokenUse,
+		Severity: SecuritySeverityCritical,
+		ActorID:  ownerID,
+		TargetID: tokenID,
+		IP:       clientIP,
+		Details:  map[string]interface{}{"token_kind": "codex"},
+	})
+}
+
+// NewSecurityEventSink returns a sink for rawURL, forwarding events at or
+// above minSeverity (default info):
+//
+//	syslog://host:514      RFC 5424 over UDP
+//	syslog+tcp://host:514  RFC 5424 over TCP, newline-framed
+//	https://siem/ingest    JSON POST of each event
+func NewSecurityEventSink(rawURL, minSeverity string) (SecurityEventSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid security event sink: %w", err)
+	}
+	var send func(ctx context.Context, ev *db.SecurityEvent) error
+	switch u.Scheme {
+	case "syslog", "syslog+udp", "syslog+tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("syslog sink needs host:port")
+		}
+		network := "udp"
+		if u.Scheme == "syslog+tcp" {
+			network = "tcp"
+		}
+		send = (&syslogSender{network: network, addr: u.Host}).send
+	case "http", "https":
+		send = (&webhookSender{url: rawURL, client: &http.Client{Timeout: 10 * time.Second}}).send
+	default:
+		return nil, fmt.Errorf("unsupported security event sink scheme %q (want syslog, syslog+tcp, http or https)", u.Scheme)
+	}
+	if minSeverity == "" {
+		minSeverity = SecuritySeverityInfo
+	}
+	min, ok := securitySeverityRank[minSeverity]
+	if !ok {
+		return nil, fmt.Errorf("unknown severity %q (want info, warning or critical)", minSeverity)
+	}
+	q := &queuedSink{send: send, minRank: min, ch: make(chan *db.SecurityEvent, securityEventQueueSize)}
+	go q.run()
+	return q, nil
+}
+
+// queuedSink delivers events one at a time from a bounded queue, so a slow
+// or unreachable SIEM never stalls request handling.
+type queuedSink struct {
+	send    func(ctx context.Context, ev *db.SecurityEvent) error
+	minRank int
+	ch      chan *db.SecurityEvent
+	mu      sync.Mutex // serializes send between the queue and Test
+}
+
+func (q *queuedSink) Send(ev *db.SecurityEvent) {
+	if securitySeverityRank[ev.Severity] < q.minRank {
+		return
+	}
+	select {
+	case q.ch <- ev:
+	default:
+		log.Printf("security event sink queue full, dropping %s event %d", ev.Type, ev.ID)
+	}
+}
+
+// Test delivers ev now, regardless of the minimum severity.
+func (q *queuedSink) Test(ctx context.Context, ev *db.SecurityEvent) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.send(ctx, ev)
+}
+
+func (q *queuedSink) run() {
+	for ev := range q.ch {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		q.mu.Lock()
+		err := q.send(ctx, ev)
+		q.mu.Unlock()
+		if err != nil {
+			log.Printf("failed to forward security event %d: %v", ev.ID, err)
+		}
+		cancel()
+	}
+}
+
+// securityEventTester is implemented by sinks that can deliver an event
+// synchronously and report the result.
+type securityEventTester interface {
+	Test(ctx context.Context, ev *db.SecurityEvent) error
+}
+
+// syslogSender writes RFC 5424 messages with facility authpriv.
+type syslogSender struct {
+	network string
+	addr    string
+	conn    net.Conn // reused for TCP
+}
+
+var syslogSeverity = map[string]int{
+	SecuritySeverityInfo:     6,
+	SecuritySeverityWarning:  4,
+	SecuritySeverityCritical: 2,
+}
+
+const syslogFacilityAuthpriv = 10
+
+func (s *syslogSender) send(ctx context.Context, ev *db.SecurityEvent) error {
+	msg, err := formatSyslog(ev)
+	if err != nil {
+		return err
+	}
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	if s.network == "udp" {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return nil
+}
+
+// formatSyslog formats ev as an RFC 5424 message whose MSGID is the event
+// type and whose body is the event as JSON.
+func formatSyslog(ev *db.SecurityEvent) ([]byte, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	sev, ok := syslogSeverity[ev.Severity]
+	if !ok {
+		sev = syslogSeverity[SecuritySeverityInfo]
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s agentserver - %s - ", syslogFacilityAuthpriv*8+sev,
+		ev.CreatedAt.UTC().Format(time.RFC3339Nano), hostname, ev.Type)
+	b.Write(body)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// webhookSender POSTs each event as JSON.
+type webhookSender struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookSender) send(ctx context.Context, ev *db.SecurityEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// GET /api/admin/security-events?type=&actor_id=&target_id=&since=&limit=
+// returns security events, newest first.
+func (s *Server) handleAdminListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := db.SecurityEventFilter{
+		Type:     q.Get("type"),
+		ActorID:  q.Get("actor_id"),
+		TargetID: q.Get("target_id"),
+	}
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be RFC3339", http.StatusBadRequest)
+			return
+		}
+		f.Since = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit: invalid", http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+	events, err := s.DB.ListSecurityEvents(f)
+	if err != nil {
+		log.Printf("admin: failed to list security events: %v", err)
+		http.Error(w, "failed to list security events", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// POST /api/admin/security-events/test {"severity": "warning"} sends a
+// synthetic "test" event to the configured sink and reports whether it was
+// delivered, so integrators can check their SIEM webhook or syslog setup.
+// The event is not recorded.
+func (s *Server) handleAdminTestSecurityEvent(w http.ResponseWriter, r *http.Request) {
+	tester, ok := s.SecurityEventSink.(securityEventTester)
+	if !ok {
+		http.Error(w, "no security event sink configured", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		Severity string `json:"severity"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Severity == "" {
+		req.Severity = SecuritySeverityInfo
+	}
+	if _, ok := securitySeverityRank[req.Severity]; !ok {
+		http.Error(w, "severity must be info, warning or critical", http.StatusBadRequest)
+		return
+	}
+	ev := &db.SecurityEvent{
+		Type:      SecurityEventTest,
+		Severity:  req.Severity,
+		ActorID:   auth.UserIDFromContext(r.Context()),
+		IP:        clientmeta.ClientIP(r),
+		Details:   map[string]interface{}{"test": true},
+		CreatedAt: time.Now().UTC(),
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	if err := tester.Test(ctx, ev); err != nil {
+		log.Printf("admin: test security event delivery failed: %v", err)
+		http.Error(w, "delivery failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"delivered": true, "event": ev})
+}
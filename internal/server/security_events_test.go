@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func TestFormatSyslog(t *testing.T) {
+	ev := &db.SecurityEvent{
+		ID:        7,
+		Type:      SecurityEventRevokedTokenUse,
+		Severity:  SecuritySeverityCritical,
+		TargetID:  "tok1",
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	msg, err := formatSyslog(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// authpriv (10) * 8 + crit (2)
+	want := "<82>1 2026-03-01T12:00:00Z "
+	if !strings.HasPrefix(string(msg), want) {
+		t.Errorf("message %q does not start with %q", msg, want)
+	}
+	if !strings.Contains(string(msg), " agentserver - revoked_token_use - {") || !strings.HasSuffix(string(msg), "}\n") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestNewSecurityEventSink(t *testing.T) {
+	for _, bad := range []string{"ftp://x", "syslog://", "::"} {
+		if _, err := NewSecurityEventSink(bad, ""); err == nil {
+			t.Errorf("NewSecurityEventSink(%q) succeeded", bad)
+		}
+	}
+	if _, err := NewSecurityEventSink("syslog://localhost:514", "urgent"); err == nil {
+		t.Error("unknown min severity accepted")
+	}
+}
+
+func TestWebhookSinkMinSeverity(t *testing.T) {
+	got := make(chan db.SecurityEvent, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var ev db.SecurityEvent
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Errorf("webhook body %q: %v", body, err)
+		}
+		got <- ev
+	}))
+	defer srv.Close()
+
+	sink, err := NewSecurityEventSink(srv.URL, SecuritySeverityWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Send(&db.SecurityEvent{ID: 1, Type: SecurityEventQuotaChanged, Severity: SecuritySeverityInfo})
+	sink.Send(&db.SecurityEvent{ID: 2, Type: SecurityEventLoginFailed, Severity: SecuritySeverityWarning})
+	select {
+	case ev := <-got:
+		if ev.ID != 2 || ev.Type != SecurityEventLoginFailed {
+			t.Errorf("forwarded %+v, want the login_failed event", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not forwarded")
+	}
+}
+
+func TestSinkTestDeliversSynchronously(t *testing.T) {
+	var got db.SecurityEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	sink, err := NewSecurityEventSink(srv.URL, SecuritySeverityCritical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Below the minimum severity: Test still delivers it.
+	ev := &db.SecurityEvent{Type: SecurityEventTest, Severity: SecuritySeverityInfo}
+	if err := sink.(securityEventTester).Test(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != SecurityEventTest {
+		t.Errorf("delivered %+v, want the test event", got)
+	}
+
+	srv.Close()
+	if err := sink.(securityEventTester).Test(context.Background(), ev); err == nil {
+		t.Error("delivery to a closed webhook succeeded")
+	}
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -13,6 +15,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,13 +24,18 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/clock"
 	"github.com/agentserver/agentserver/internal/codexauth"
 	"github.com/agentserver/agentserver/internal/db"
 	"github.com/agentserver/agentserver/internal/namespace"
+	"github.com/agentserver/agentserver/internal/notify"
+	"github.com/agentserver/agentserver/internal/policy"
 	"github.com/agentserver/agentserver/internal/process"
 	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/settings"
 	"github.com/agentserver/agentserver/internal/shortid"
 	"github.com/agentserver/agentserver/internal/storage"
+	"github.com/agentserver/agentserver/internal/templatebundle"
 	"github.com/agentserver/agentserver/internal/tunnel"
 )
 
@@ -42,11 +50,18 @@ type Server struct {
 	TunnelRegistry   *tunnel.Registry
 	StaticFS         fs.FS
 	BaseDomains              []string // e.g. ["agentserver.dev", "agent.cs.ac.cn"] (first is primary)
+	RegionDomains            map[string]string // sandbox region → base domain of its subdomains (REGION_DOMAINS)
 	OpencodeSubdomainPrefix  string   // e.g. "code" — subdomain: code-{id}.{baseDomain}
 	OpenclawSubdomainPrefix    string // e.g. "claw" — subdomain: claw-{id}.{baseDomain}
 	ClaudeCodeSubdomainPrefix  string // e.g. "claude" — subdomain: claude-{id}.{baseDomain}
 	JupyterSubdomainPrefix     string // e.g. "jupyter" — subdomain: jupyter-{id}.{baseDomain}
-	PasswordAuthEnabled      bool   // when false, /api/auth/login and /api/auth/register are not registered
+	PasswordAuthEnabled      bool   // when false, /api/auth/login and /api/auth/register return 404
+	// Settings overlays admin overrides (system_settings) on the fields
+	// above and on the namespace manager's NetworkPolicy config.
+	Settings *settings.Manager
+	// Policy is the GitOps-managed policy file (POLICY_FILE); nil when not
+	// configured. Its quotas take precedence over the admin settings.
+	Policy *policy.Watcher
 	LLMProxyURL              string // base URL for the llmproxy service (e.g. "http://agentserver-llmproxy:8081")
 
 	// IMBridgeURL is the base URL of the standalone imbridge service
@@ -87,15 +102,128 @@ type Server struct {
 	// the right `codex login --issuer` / token-refresh endpoints.
 	CodexAuthIssuerURL string
 
+	// StorageReport is the storage preflight result from startup (K8s
+	// backend only). Failed error checks make /readyz return 503.
+	StorageReport *storage.PreflightReport
+
 	// OperationsRetention is the TTL for rows in the operations table.
 	// 0 disables the background retention loop. Configurable via
 	// AGENTSERVER_OPERATIONS_RETENTION_DAYS (default 90).
 	OperationsRetention time.Duration
 
+	// StatementMailer emails monthly usage statements to workspace owners.
+	// nil disables email delivery. Configured via SMTP_ADDR and friends.
+	StatementMailer *Mailer
+
+	// Notifier delivers invitations, idle-pause warnings, quota-exceeded
+	// notices and agent-offline alerts by email and webhook. nil disables
+	// them; owners then share the invitation link returned on creation.
+	Notifier *notify.Service
+
+	// AdminDigestInterval is the period of the admin digest, emailed to
+	// the admins through StatementMailer. 0 disables the digest loop.
+	// Configurable via ADMIN_DIGEST_INTERVAL (default 168h).
+	AdminDigestInterval time.Duration
+
+	// ClientPollInterval is the poll interval suggested to the web UI and
+	// CLI on list endpoints. 0 means the default of 2s. Configurable via
+	// CLIENT_POLL_INTERVAL.
+	ClientPollInterval time.Duration
+
+	// PausedRetention is how long sandboxes may stay paused before they are
+	// deleted, unless their workspace quota overrides it. 0 keeps them
+	// forever. Configurable via PAUSED_SANDBOX_RETENTION.
+	PausedRetention time.Duration
+
+	// MetricsToken guards /metrics; empty disables it. Configured via
+	// METRICS_TOKEN.
+	MetricsToken string
+
+	// PausedRetentionWarning is how long before deleting a paused sandbox
+	// its workspace is warned. 0 means the default of 72h. Configurable via
+	// PAUSED_SANDBOX_RETENTION_WARNING.
+	PausedRetentionWarning time.Duration
+
+	// MirrorAuthorName and MirrorAuthorEmail are the identity of the
+	// commits drive mirrors push. Empty means "agentserver" and
+	// "agentserver@localhost". Configurable via DRIVE_MIRROR_AUTHOR.
+	MirrorAuthorName  string
+	MirrorAuthorEmail string
+
+	// PriorityClasses maps workspace priority tiers to the K8s
+	// PriorityClass of their sandboxes. Configured via
+	// SANDBOX_PRIORITY_CLASSES; unmapped tiers use the cluster default.
+	PriorityClasses map[string]string
+
+	// ImageScanner is the vulnerability scanner run on sandbox images
+	// ("trivy" or "grype", from IMAGE_SCANNER); empty disables scheduled
+	// scans. Results can still be submitted through the admin API.
+	ImageScanner string
+
+	// Clock tells time for token expiry checks; nil is the system clock.
+	Clock clock.Clock
+
+	// SecurityEventSink forwards security events to syslog or a SIEM
+	// (SECURITY_EVENT_SINK); nil only records them in the database.
+	SecurityEventSink SecurityEventSink
+
+	// Template bundles: exports of policy file templates are signed with
+	// TemplateSigningKey; imports must be signed by a TemplateTrustedKeys
+	// key (or the signing key) unless AllowUnsignedTemplates.
+	TemplateSigningKey     ed25519.PrivateKey
+	TemplateSigningKeyID   string
+	TemplateTrustedKeys    map[string]ed25519.PublicKey
+	AllowUnsignedTemplates bool
+
+	// SessionHistory stores periodic exports of opencode session storage
+	// (SESSION_HISTORY_STORE); nil disables them. SessionHistoryRetention
+	// is the number of exports kept per sandbox (0 is the default, 10).
+	SessionHistory          SessionHistoryStore
+	SessionHistoryRetention int
+
+	// SecretsKey is the AES-256 master key of workspace secrets
+	// (WORKSPACE_SECRETS_KEY); nil disables them.
+	SecretsKey []byte
+
 	// In-memory pending device code flows (OIDC credential creation).
 	deviceFlows   map[string]*pendingDeviceFlow
 	deviceFlowsMu sync.Mutex
 
+	// Sandboxes with a snapshot or restore in progress (sandbox ID ->
+	// struct{}).
+	snapshotOps sync.Map
+
+	// Sources of cloned sandboxes whose session data is copied before they
+	// start (sandbox ID -> source sandbox ID).
+	cloneSources sync.Map
+
+	// Drive mirrors with a sync in progress (mirror ID -> struct{}).
+	mirrorSyncs sync.Map
+
+	// Per-token request counts of the sandbox access token endpoints.
+	accessTokenLimiter accessTokenLimiter
+
+	// Metrics of the expired-credential prune job.
+	credentialPrune credentialPruneStats
+
+	// Heartbeats of the background controllers.
+	controllers controllerRegistry
+
+	// Queues sandbox starts and resumes while the cluster is constrained
+	// (SANDBOX_MAX_CONCURRENT_STARTS, SANDBOX_SCHEDULING_QUEUE). nil
+	// dispatches immediately.
+	scheduleQueue *scheduleQueue
+
+	// When workspace owners were last notified of a quota being hit
+	// (workspace ID + reason -> time), to notify at most once a day.
+	quotaNoticesMu sync.Mutex
+	quotaNotices   map[string]time.Time
+
+	// Cached result of the public platform status page.
+	statusMu  sync.Mutex
+	statusAt  time.Time
+	statusRes platformStatus
+
 	// codexHandler is set by Router() when CODEX_APP_GATEWAY_URL is
 	// configured. Kept here so Close() can stop its dispatcher.
 	codexHandler *codexInboundHandler
@@ -148,9 +276,70 @@ func New(a *auth.Auth, oidcMgr *auth.OIDCManager, database *db.DB, sandboxStore
 		PasswordAuthEnabled:       passwordAuthEnabled,
 		deviceFlows:               make(map[string]*pendingDeviceFlow),
 	}
+	if raw := os.Getenv("SANDBOX_PRIORITY_CLASSES"); raw != "" {
+		classes, err := parsePriorityClasses(raw)
+		if err != nil {
+			log.Printf("ignoring SANDBOX_PRIORITY_CLASSES: %v", err)
+		} else {
+			s.PriorityClasses = classes
+		}
+	}
+	if raw := os.Getenv("REGION_DOMAINS"); raw != "" {
+		domains, err := process.ParseRegionDomains(raw)
+		if err != nil {
+			log.Printf("ignoring REGION_DOMAINS: %v", err)
+		} else {
+			s.RegionDomains = domains
+		}
+	}
+	if scanner := os.Getenv("IMAGE_SCANNER"); imageScanners[scanner] {
+		s.ImageScanner = scanner
+	} else if scanner != "" {
+		log.Printf("ignoring unknown IMAGE_SCANNER %q (want trivy or grype)", scanner)
+	}
+	var maxStarts int
+	if raw := os.Getenv("SANDBOX_MAX_CONCURRENT_STARTS"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			maxStarts = n
+		} else {
+			log.Printf("ignoring invalid SANDBOX_MAX_CONCURRENT_STARTS %q", raw)
+		}
+	}
+	var fits func(context.Context, *scheduleRequest) bool
+	if _, ok := processManager.(capacityChecker); ok && os.Getenv("SANDBOX_SCHEDULING_QUEUE") == "true" {
+		fits = func(ctx context.Context, req *scheduleRequest) bool {
+			c := s.sandboxCapacity(ctx, req.CPU, req.Memory, req.NodePool)
+			return c == nil || c.Fits
+		}
+	}
+	if maxStarts > 0 || fits != nil {
+		s.scheduleQueue = newScheduleQueue(maxStarts, fits)
+	}
 	if s.OIDC != nil {
 		s.OIDC.OnUserCreated = s.createDefaultWorkspace
 	}
+	if database != nil {
+		defaults := settings.Settings{
+			PasswordAuthEnabled:       passwordAuthEnabled,
+			OpencodeSubdomainPrefix:   opcodePrefix,
+			OpenclawSubdomainPrefix:   openclawPrefix,
+			ClaudeCodeSubdomainPrefix: claudecodePrefix,
+			JupyterSubdomainPrefix:    jupyterPrefix,
+			OpencodeAssetDomain:       os.Getenv("OPENCODE_ASSET_DOMAIN"),
+		}
+		if defaults.OpencodeAssetDomain == "" && len(baseDomains) > 0 {
+			defaults.OpencodeAssetDomain = "opencodeapp." + baseDomains[0]
+		}
+		if nsMgr != nil {
+			np := nsMgr.NetworkPolicy()
+			defaults.NetworkPolicyEnabled = np.Enabled
+			defaults.NetworkPolicyDenyCIDRs = np.DenyCIDRs
+		}
+		s.Settings = settings.NewManager(database, defaults)
+		if nsMgr != nil {
+			s.watchNetworkPolicy()
+		}
+	}
 	// Background sweep for expired device code flows (OIDC).
 	go s.sweepExpiredDeviceFlows()
 	return s
@@ -189,6 +378,26 @@ func (s *Server) createDefaultWorkspace(userID string) {
 	}
 }
 
+// BootstrapAdmin ensures the configured admins exist, giving a newly
+// created local admin a default workspace.
+func (s *Server) BootstrapAdmin(b auth.AdminBootstrap) error {
+	id, err := s.Auth.BootstrapAdmin(b)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		s.createDefaultWorkspace(id)
+	}
+	return nil
+}
+
+// EnableTrustedHeaderAuth delegates authentication to a fronting auth
+// proxy; users it creates get a default workspace.
+func (s *Server) EnableTrustedHeaderAuth(t *auth.TrustedHeader) {
+	t.OnUserCreated = s.createDefaultWorkspace
+	s.Auth.SetTrustedHeader(t)
+}
+
 func (s *Server) Router() http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
@@ -199,6 +408,16 @@ func (s *Server) Router() http.Handler {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// Readiness endpoint: database reachable and storage preflight healthy.
+	r.Get("/readyz", s.handleReadyz)
+
+	// Prometheus metrics of the background controllers (bearer METRICS_TOKEN).
+	r.Get("/metrics", s.handleMetrics)
+
+	// Public platform status page (no auth, no details).
+	r.Get("/status", s.handleStatusPage)
+	r.Get("/api/status", s.handleStatus)
+
 	// Internal API for LLM proxy token validation (no cookie auth).
 	r.Post("/internal/validate-proxy-token", s.handleValidateProxyToken)
 
@@ -381,21 +600,34 @@ func (s *Server) Router() http.Handler {
 	r.Post("/api/agent/tasks", s.handleAgentCreateTask)
 	r.Get("/api/agent/tasks/{id}", s.handleAgentGetTask)
 
+	// Workspace drive sync for local agents (auth via proxy_token).
+	r.Get("/api/agent/drive/manifest", s.handleDriveManifest)
+	r.Get("/api/agent/drive/file", s.handleDriveGetFile)
+	r.Put("/api/agent/drive/file", s.handleDrivePutFile)
+	r.Delete("/api/agent/drive/file", s.handleDriveDeleteFile)
+
 	// Auth endpoints (no auth required)
-	if s.PasswordAuthEnabled {
-		r.Post("/api/auth/login", s.handleLogin)
-		r.Post("/api/auth/register", s.handleRegister)
-	}
+	r.With(s.requirePasswordAuth).Post("/api/auth/login", s.handleLogin)
+	r.With(s.requirePasswordAuth).Post("/api/auth/register", s.handleRegister)
 	r.Get("/api/auth/check", s.handleAuthCheck)
 	r.Post("/api/auth/logout", s.handleLogout)
 
 	// OIDC endpoints (no auth required)
 	if s.OIDC != nil {
 		r.Get("/api/auth/oidc/providers", func(w http.ResponseWriter, r *http.Request) {
+			names := s.OIDC.ProviderNamesForHost(r.Host)
+			labels := make(map[string]string)
+			for _, n := range names {
+				if l := s.OIDC.ProviderLabel(n); l != "" {
+					labels[n] = l
+				}
+			}
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
-				"providers":     s.OIDC.ProviderNamesForHost(r.Host),
-				"password_auth": s.PasswordAuthEnabled,
+				"providers":      names,
+				"labels":         labels,
+				"password_auth":  s.effectiveSettings().PasswordAuthEnabled,
+				"trusted_header": s.Auth.TrustedHeaderEnabled(),
 			})
 		})
 		r.Get("/api/auth/oidc/{provider}/login", s.handleOIDCLogin)
@@ -405,42 +637,105 @@ func (s *Server) Router() http.Handler {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
 				"providers":      []string{},
-				"password_auth": s.PasswordAuthEnabled,
+				"labels":         map[string]string{},
+				"password_auth":  s.effectiveSettings().PasswordAuthEnabled,
+				"trusted_header": s.Auth.TrustedHeaderEnabled(),
 			})
 		})
 	}
 
+	// Shared session transcripts (no auth required; the ID is unguessable).
+	r.Get("/share/{shareID}", s.handleGetSharedSession)
+	r.Get("/api/share/{shareID}", s.handleGetSharedSession)
+
+	// CI access to a single sandbox (a sandbox access token instead of a login).
+	r.Post("/api/ci/sandboxes/{id}/exec", s.handleAccessTokenExec)
+	r.Get("/api/ci/sandboxes/{id}/artifacts", s.handleAccessTokenArtifacts)
+
 	// Protected API routes
 	r.Group(func(r chi.Router) {
 		r.Use(s.Auth.Middleware)
 
 		r.Get("/api/auth/me", s.handleMe)
 
+		// GDPR data export and erasure requests
+		r.Get("/api/users/me/export", s.handleExportMyData)
+		r.Get("/api/users/me/tokens", s.handleListPersonalAccessTokens)
+		r.Post("/api/users/me/tokens", s.handleCreatePersonalAccessToken)
+		r.Delete("/api/users/me/tokens/{tokenID}", s.handleRevokePersonalAccessToken)
+		r.Get("/api/users/me/sessions", s.handleListSessions)
+		r.Delete("/api/users/me/sessions", s.handleRevokeAllSessions)
+		r.Delete("/api/users/me/sessions/{sessionID}", s.handleRevokeSession)
+		r.Get("/api/users/me/notifications", s.handleGetNotificationPreferences)
+		r.Put("/api/users/me/notifications", s.handleSetNotificationPreferences)
+		r.Get("/api/users/me/erasure", s.handleGetMyErasureRequest)
+		r.Post("/api/users/me/erasure", s.handleRequestErasure)
+
 		// Workspace routes
-		r.Get("/api/workspaces", s.handleListWorkspaces)
+		r.With(s.conditionalGET).Get("/api/workspaces", s.handleListWorkspaces)
 		r.Post("/api/workspaces", s.handleCreateWorkspace)
+		r.Get("/api/sandbox-templates", s.handleListSandboxTemplates)
+		r.Get("/api/sandbox-templates/{name}/export", s.handleExportSandboxTemplate)
 		r.Get("/api/workspaces/quota", s.handleGetWorkspacesQuota)
 		r.Get("/api/workspaces/{id}", s.handleGetWorkspace)
-		r.Patch("/api/workspaces/{id}", s.handleRenameWorkspace)
+		r.Patch("/api/workspaces/{id}", s.handleUpdateWorkspace)
 		r.Delete("/api/workspaces/{id}", s.handleDeleteWorkspace)
+		r.Post("/api/workspaces/{id}/archive", s.handleArchiveWorkspace)
+		r.Post("/api/workspaces/{id}/unarchive", s.handleUnarchiveWorkspace)
 
 		// Workspace member routes
-		r.Get("/api/workspaces/{id}/members", s.handleListMembers)
+		r.With(s.conditionalGET).Get("/api/workspaces/{id}/members", s.handleListMembers)
 		r.Post("/api/workspaces/{id}/members", s.handleAddMember)
 		r.Put("/api/workspaces/{id}/members/{userId}", s.handleUpdateMemberRole)
 		r.Delete("/api/workspaces/{id}/members/{userId}", s.handleRemoveMember)
 
+		// Workspace invitations (owners invite by email, invitees accept)
+		r.Get("/api/workspaces/{id}/invitations", s.handleListInvitations)
+		r.Post("/api/workspaces/{id}/invitations", s.handleCreateInvitation)
+		r.Delete("/api/workspaces/{id}/invitations/{invitationId}", s.handleRevokeInvitation)
+		r.Get("/api/invitations/{token}", s.handleGetInvitation)
+		r.Post("/api/invitations/{token}/accept", s.handleAcceptInvitation)
+
 		// Workspace operations log (read-only, member-gated, wraps /internal/operations)
 		r.Get("/api/workspaces/{id}/operations", s.getWorkspaceOperations)
 
 		// Workspace LLM quota (read-only for members)
 		r.Get("/api/workspaces/{id}/llm-quota", s.handleGetWorkspaceLLMQuota)
 
+		// Per-sandbox daily LLM budget with throttling (owner sets and resets)
+		r.Get("/api/workspaces/{id}/sandbox-budget", s.handleGetWorkspaceSandboxBudget)
+		r.Put("/api/workspaces/{id}/sandbox-budget", s.handleSetWorkspaceSandboxBudget)
+		r.Delete("/api/workspaces/{id}/sandbox-budget", s.handleDeleteWorkspaceSandboxBudget)
+		r.Post("/api/workspaces/{id}/sandbox-budget/reset", s.handleResetWorkspaceSandboxBudget)
+
+		// Announcement banners: active ones for the current user, and the
+		// workspace's own (owner manages)
+		r.Get("/api/announcements", s.handleListAnnouncements)
+		r.Get("/api/workspaces/{id}/announcements", s.handleListWorkspaceAnnouncements)
+		r.Post("/api/workspaces/{id}/announcements", s.handleCreateWorkspaceAnnouncement)
+		r.Put("/api/workspaces/{id}/announcements/{announcementId}", s.handleUpdateWorkspaceAnnouncement)
+		r.Delete("/api/workspaces/{id}/announcements/{announcementId}", s.handleDeleteWorkspaceAnnouncement)
+
+		// Monthly usage statements (members download, owner regenerates and emails)
+		r.Get("/api/workspaces/{id}/statements", s.handleListUsageStatements)
+		r.Get("/api/workspaces/{id}/scheduling-queue", s.handleWorkspaceSchedulingQueue)
+		r.Get("/api/workspaces/{id}/drive-usage", s.handleWorkspaceDriveUsage)
+		r.Get("/api/workspaces/{id}/deleted-sandboxes", s.handleListDeletedSandboxes)
+		r.Get("/api/workspaces/{id}/deleted-sandboxes/{sandboxID}", s.handleGetDeletedSandbox)
+		r.Get("/api/workspaces/{id}/statements/{month}", s.handleGetUsageStatement)
+		r.Post("/api/workspaces/{id}/statements/{month}/generate", s.handleGenerateUsageStatement)
+		r.Post("/api/workspaces/{id}/statements/{month}/email", s.handleEmailUsageStatement)
+
 		// Workspace BYOK LLM config (owner/maintainer only)
 		r.Get("/api/workspaces/{id}/llm-config", s.handleGetWorkspaceLLMConfig)
 		r.Put("/api/workspaces/{id}/llm-config", s.handleSetWorkspaceLLMConfig)
 		r.Delete("/api/workspaces/{id}/llm-config", s.handleDeleteWorkspaceLLMConfig)
 
+		// Workspace model allowlist and default model (owner sets, members read)
+		r.Get("/api/workspaces/{id}/model-policy", s.handleGetWorkspaceModelPolicy)
+		r.Put("/api/workspaces/{id}/model-policy", s.handleSetWorkspaceModelPolicy)
+		r.Delete("/api/workspaces/{id}/model-policy", s.handleDeleteWorkspaceModelPolicy)
+
 		// Codex remote-access tokens (per-user, per-workspace, DB-backed).
 		r.Post("/api/codex/tokens", s.handleMintCodexToken)
 		r.Get("/api/codex/tokens", s.handleListCodexTokens)
@@ -453,15 +748,72 @@ func (s *Server) Router() http.Handler {
 		r.Get("/api/auth/modelserver/callback", s.handleModelserverCallback)
 
 		// Sandbox routes
-		r.Get("/api/workspaces/{wid}/sandboxes", s.handleListSandboxes)
+		r.With(s.conditionalGET).Get("/api/workspaces/{wid}/sandboxes", s.handleListSandboxes)
 		r.Post("/api/workspaces/{wid}/sandboxes", s.handleCreateSandbox)
+		r.Post("/api/workspaces/{wid}/sandboxes:validate", s.handleValidateSandbox)
 		r.Get("/api/workspaces/{wid}/defaults", s.handleGetWorkspaceDefaults)
 		r.Get("/api/sandboxes/{id}", s.handleGetSandbox)
-		r.Patch("/api/sandboxes/{id}", s.handleRenameSandbox)
+		r.Patch("/api/sandboxes/{id}", s.handleUpdateSandbox)
 		r.Delete("/api/sandboxes/{id}", s.handleDeleteSandbox)
 		r.Post("/api/sandboxes/{id}/pause", s.handlePauseSandbox)
 		r.Post("/api/sandboxes/{id}/resume", s.handleResumeSandbox)
+		r.Post("/api/sandboxes/{id}/retry-storage", s.handleRetrySandboxStorage)
+		r.Post("/api/sandboxes/{id}/retry-start", s.handleRetrySandboxStart)
+		r.Put("/api/sandboxes/{id}/pin", s.handlePinSandbox)
+		r.Delete("/api/sandboxes/{id}/pin", s.handleUnpinSandbox)
+		r.Post("/api/sandboxes/{id}/rotate-tokens", s.handleRotateSandboxTokens)
+		r.Put("/api/sandboxes/{id}/tunnel-bandwidth", s.handleSetTunnelBandwidth)
+		r.With(s.conditionalGET).Get("/api/sandboxes/{id}/ports", s.handleListSandboxPorts)
+		r.Post("/api/sandboxes/{id}/ports", s.handleExposeSandboxPort)
+		r.Delete("/api/sandboxes/{id}/ports/{port}", s.handleUnexposeSandboxPort)
+		r.Get("/api/sandboxes/{id}/files", s.handleListSandboxFiles)
+		r.Delete("/api/sandboxes/{id}/files", s.handleDeleteSandboxFile)
+		r.Get("/api/sandboxes/{id}/files/download", s.handleDownloadSandboxFile)
+		r.Put("/api/sandboxes/{id}/files/upload", s.handleUploadSandboxFile)
+		r.Get("/api/sandboxes/{id}/access-tokens", s.handleListSandboxAccessTokens)
+		r.Post("/api/sandboxes/{id}/access-tokens", s.handleCreateSandboxAccessToken)
+		r.Delete("/api/sandboxes/{id}/access-tokens/{tokenID}", s.handleRevokeSandboxAccessToken)
+		r.Get("/api/sandboxes/{id}/create-events", s.handleSandboxCreateEvents)
+		r.Get("/api/sandboxes/{id}/terminal", s.handleSandboxTerminal)
+		r.Get("/api/sandboxes/{id}/snapshots", s.handleListSandboxSnapshots)
+		r.Post("/api/sandboxes/{id}/snapshots", s.handleCreateSandboxSnapshot)
+		r.Post("/api/sandboxes/{id}/snapshots/{snapshot}/restore", s.handleRestoreSandboxSnapshot)
+		r.Delete("/api/sandboxes/{id}/snapshots/{snapshot}", s.handleDeleteSandboxSnapshot)
+		r.Post("/api/sandboxes/{id}/clone", s.handleCloneSandbox)
+		r.Post("/api/sandboxes/{id}/diagnostics", s.handleSandboxDiagnostics)
+		r.Get("/api/sandboxes/{id}/environment", s.handleSandboxEnvironment)
+		r.Post("/api/sandboxes/{id}/session-shares", s.handleShareSandboxSessions)
+		r.Get("/api/workspaces/{id}/session-shares", s.handleListSessionShares)
+		r.Delete("/api/session-shares/{shareID}", s.handleDeleteSessionShare)
+		r.Post("/api/sandboxes/{id}/session-history", s.handleExportSessionHistory)
+		r.Post("/api/sandboxes/{id}/session-history/restore", s.handleRestoreSessionHistory)
+		r.Get("/api/workspaces/{id}/session-history", s.handleListSessionHistory)
+		r.Get("/api/workspaces/{id}/session-history/{exportID}", s.handleGetSessionHistory)
+		r.Delete("/api/workspaces/{id}/session-history/{exportID}", s.handleDeleteSessionHistory)
+		r.With(s.conditionalGET).Get("/api/workspaces/{id}/templates", s.handleListWorkspaceTemplates)
+		r.Post("/api/workspaces/{id}/templates", s.handleCreateWorkspaceTemplate)
+		r.Get("/api/workspaces/{id}/templates/{templateID}", s.handleGetWorkspaceTemplate)
+		r.Put("/api/workspaces/{id}/templates/{templateID}", s.handleUpdateWorkspaceTemplate)
+		r.Delete("/api/workspaces/{id}/templates/{templateID}", s.handleDeleteWorkspaceTemplate)
+		r.With(s.conditionalGET).Get("/api/workspaces/{id}/schedules", s.handleListSandboxSchedules)
+		r.Post("/api/workspaces/{id}/schedules", s.handleCreateSandboxSchedule)
+		r.Put("/api/workspaces/{id}/schedules/{scheduleID}", s.handleUpdateSandboxSchedule)
+		r.Delete("/api/workspaces/{id}/schedules/{scheduleID}", s.handleDeleteSandboxSchedule)
+		r.With(s.conditionalGET).Get("/api/workspaces/{id}/mirrors", s.handleListDriveMirrors)
+		r.Post("/api/workspaces/{id}/mirrors", s.handleCreateDriveMirror)
+		r.Get("/api/workspaces/{id}/mirrors/{mirrorID}", s.handleGetDriveMirror)
+		r.Put("/api/workspaces/{id}/mirrors/{mirrorID}", s.handleUpdateDriveMirror)
+		r.Delete("/api/workspaces/{id}/mirrors/{mirrorID}", s.handleDeleteDriveMirror)
+		r.Post("/api/workspaces/{id}/mirrors/{mirrorID}/sync", s.handleSyncDriveMirror)
+		r.Get("/api/sandboxes/{id}/hook-runs", s.handleListSandboxHookRuns)
+		r.Get("/api/workspaces/{id}/secrets", s.handleListWorkspaceSecrets)
+		r.Post("/api/workspaces/{id}/secrets", s.handlePutWorkspaceSecret)
+		r.Delete("/api/workspaces/{id}/secrets/{name}", s.handleDeleteWorkspaceSecret)
 		r.Get("/api/sandboxes/{id}/usage", s.handleSandboxUsage)
+		r.Get("/api/workspaces/{id}/usage", s.handleWorkspaceUsage)
+		r.Get("/api/sandboxes/{id}/activity", s.handleSandboxActivity)
+		r.Get("/api/workspaces/{id}/activity", s.handleWorkspaceActivity)
+		r.Get("/api/workspaces/{id}/events", s.handleWorkspaceEvents)
 		r.Get("/api/sandboxes/{id}/traces", s.handleSandboxTraces)
 		r.Get("/api/sandboxes/{id}/traces/{traceId}", s.handleTraceDetail)
 		r.Get("/api/workspaces/{wid}/traces", s.handleWorkspaceTraces)
@@ -529,9 +881,51 @@ func (s *Server) Router() http.Handler {
 		r.Route("/api/admin", func(r chi.Router) {
 			r.Use(s.requireAdmin)
 			r.Get("/users", s.handleAdminListUsers)
+			r.Post("/users", s.handleAdminCreateUser)
+			r.Put("/users/{id}/status", s.handleAdminSetUserStatus)
+			r.Delete("/users/{id}", s.handleAdminDeleteUser)
 			r.Get("/workspaces", s.handleAdminListWorkspaces)
 			r.Get("/sandboxes", s.handleAdminListSandboxes)
 			r.Put("/users/{id}/role", s.handleAdminUpdateUserRole)
+			r.Post("/impersonate/{userId}", s.handleAdminImpersonate)
+			r.Get("/security-events", s.handleAdminListSecurityEvents)
+			r.Get("/sandbox-tombstones", s.handleAdminListSandboxTombstones)
+			r.Get("/sandbox-tombstones/{id}", s.handleAdminGetSandboxTombstone)
+			r.Post("/security-events/test", s.handleAdminTestSecurityEvent)
+			r.Get("/sandbox-templates/imported", s.handleAdminListTemplateBundles)
+			r.Post("/sandbox-templates/import", s.handleAdminImportTemplateBundle)
+			r.Delete("/sandbox-templates/{name}", s.handleAdminDeleteTemplateBundle)
+			r.Get("/erasure-requests", s.handleAdminListErasureRequests)
+			r.Post("/erasure-requests/{id}/approve", s.handleAdminApproveErasure)
+			r.Post("/erasure-requests/{id}/reject", s.handleAdminRejectErasure)
+			r.Post("/sandboxes/{id}/quarantine", s.handleAdminQuarantineSandbox)
+			r.Delete("/sandboxes/{id}/quarantine", s.handleAdminReleaseSandbox)
+			r.Get("/sandboxes/{id}/quarantine/events", s.handleAdminListQuarantineEvents)
+			r.Put("/sandboxes/{id}/retention-hold", s.handleAdminHoldSandboxRetention)
+			r.Delete("/sandboxes/{id}/retention-hold", s.handleAdminReleaseSandboxRetention)
+			r.Post("/sandboxes/{id}/migrate", s.handleAdminMigrateSandbox)
+			r.Get("/sandbox-migrations", s.handleAdminListSandboxMigrations)
+			r.Get("/sandbox-migrations/{migrationID}", s.handleAdminGetSandboxMigration)
+			r.Get("/usage", s.handleAdminUsage)
+
+			r.Get("/storage/status", s.handleAdminStorageStatus)
+
+			// Global and workspace announcements
+			r.Get("/announcements", s.handleAdminListAnnouncements)
+			r.Post("/announcements", s.handleAdminCreateAnnouncement)
+			r.Put("/announcements/{announcementId}", s.handleAdminUpdateAnnouncement)
+			r.Delete("/announcements/{announcementId}", s.handleAdminDeleteAnnouncement)
+
+			// Expired token and registration code cleanup
+			r.Get("/credential-prune", s.handleAdminCredentialPruneStats)
+			r.Post("/credential-prune/run", s.handleAdminRunCredentialPrune)
+
+			// Login session limits
+			r.Get("/session-policy", s.handleAdminGetSessionPolicy)
+			r.Put("/session-policy", s.handleAdminSetSessionPolicy)
+			r.Get("/settings", s.handleAdminGetSettings)
+			r.Put("/settings", s.handleAdminUpdateSettings)
+			r.Get("/config-drift", s.handleAdminConfigDrift)
 
 			// Quota management
 			r.Get("/quotas/defaults", s.handleAdminGetQuotaDefaults)
@@ -545,10 +939,47 @@ func (s *Server) Router() http.Handler {
 			r.Put("/workspaces/{id}/quota", s.handleAdminSetWorkspaceQuota)
 			r.Delete("/workspaces/{id}/quota", s.handleAdminDeleteWorkspaceQuota)
 
+			// Workspace DNS egress allowlist
+			r.Get("/workspaces/{id}/dns-allowlist", s.handleAdminGetWorkspaceDNSAllowlist)
+			r.Put("/workspaces/{id}/dns-allowlist", s.handleAdminSetWorkspaceDNSAllowlist)
+			r.Delete("/workspaces/{id}/dns-allowlist", s.handleAdminDeleteWorkspaceDNSAllowlist)
+			r.Get("/workspaces/{id}/node-pool", s.handleAdminGetWorkspaceNodePool)
+			r.Put("/workspaces/{id}/node-pool", s.handleAdminSetWorkspaceNodePool)
+			r.Delete("/workspaces/{id}/node-pool", s.handleAdminDeleteWorkspaceNodePool)
+			r.Get("/workspaces/{id}/priority", s.handleAdminGetWorkspacePriority)
+			r.Get("/capacity", s.handleAdminCapacity)
+			r.Get("/docker-volumes", s.handleAdminListDockerVolumes)
+			r.Post("/docker-volumes/gc", s.handleAdminCollectDockerVolumes)
+			r.Get("/digests", s.handleAdminListDigests)
+			r.Post("/digests", s.handleAdminCreateDigest)
+			r.Get("/digests/preview", s.handleAdminPreviewDigest)
+			r.Get("/scheduling-queue", s.handleAdminSchedulingQueue)
+			r.Get("/controllers", s.handleAdminControllers)
+			r.Get("/image-scans", s.handleAdminListImageScans)
+			r.Post("/image-scans", s.handleAdminSubmitImageScan)
+			r.Get("/image-scans/findings", s.handleAdminGetImageScanFindings)
+			r.Post("/image-scans/run", s.handleAdminRunImageScans)
+			r.Get("/image-scan-policy", s.handleAdminGetImageScanPolicy)
+			r.Put("/image-scan-policy", s.handleAdminSetImageScanPolicy)
+			r.Put("/workspaces/{id}/priority", s.handleAdminSetWorkspacePriority)
+			r.Get("/workspaces/{id}/model-policy", s.handleAdminGetWorkspaceModelPolicy)
+			r.Put("/workspaces/{id}/model-policy", s.handleAdminSetWorkspaceModelPolicy)
+			r.Delete("/workspaces/{id}/model-policy", s.handleAdminDeleteWorkspaceModelPolicy)
+
 			// Workspace LLM quota management (proxied to llmproxy)
 			r.Get("/workspaces/{id}/llm-quota", s.handleAdminGetWorkspaceLLMQuota)
 			r.Put("/workspaces/{id}/llm-quota", s.handleAdminSetWorkspaceLLMQuota)
 			r.Delete("/workspaces/{id}/llm-quota", s.handleAdminDeleteWorkspaceLLMQuota)
+
+			// Monthly LLM spend limits, enforced by the LLM proxy
+			r.Get("/users/{id}/llm-spend-limit", s.handleAdminGetLLMSpendLimit(db.SpendScopeUser))
+			r.Put("/users/{id}/llm-spend-limit", s.handleAdminSetLLMSpendLimit(db.SpendScopeUser))
+			r.Delete("/users/{id}/llm-spend-limit", s.handleAdminDeleteLLMSpendLimit(db.SpendScopeUser))
+			r.Post("/users/{id}/llm-spend-limit/reset", s.handleAdminResetLLMSpendLimit(db.SpendScopeUser))
+			r.Get("/workspaces/{id}/llm-spend-limit", s.handleAdminGetLLMSpendLimit(db.SpendScopeWorkspace))
+			r.Put("/workspaces/{id}/llm-spend-limit", s.handleAdminSetLLMSpendLimit(db.SpendScopeWorkspace))
+			r.Delete("/workspaces/{id}/llm-spend-limit", s.handleAdminDeleteLLMSpendLimit(db.SpendScopeWorkspace))
+			r.Post("/workspaces/{id}/llm-spend-limit/reset", s.handleAdminResetLLMSpendLimit(db.SpendScopeWorkspace))
 		})
 	})
 
@@ -609,6 +1040,11 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	token, _, ok := s.Auth.Login(req.Email, req.Password)
 	if !ok {
+		s.recordSecurityEvent(r, &db.SecurityEvent{
+			Type:     SecurityEventLoginFailed,
+			Severity: SecuritySeverityWarning,
+			Details:  map[string]interface{}{"email": req.Email},
+		})
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
@@ -649,12 +1085,7 @@ func (s *Server) handleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// First registered user becomes admin.
-	if count, err := s.DB.CountUsers(); err == nil && count == 1 {
-		if err := s.DB.UpdateUserRole(id, "admin"); err != nil {
-			log.Printf("failed to set first user as admin: %v", err)
-		}
-	}
+	s.Auth.AssignNewUserRole(id, req.Email)
 
 	s.createDefaultWorkspace(id)
 
@@ -673,18 +1104,14 @@ func (s *Server) handleAuthCheck(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
-	// Cookie Domain must match the issuance side (auth.SetTokenCookie)
-	// or the browser won't actually clear the cross-subdomain cookie.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "agentserver-token",
-		Value:    "",
-		Path:     "/",
-		Domain:   os.Getenv("AGENTSERVER_COOKIE_DOMAIN"),
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	s.endImpersonation(r)
+	// Revoke the session too, so a copy of the cookie stops working.
+	if token := auth.SessionToken(r); token != "" {
+		if err := s.DB.DeleteToken(token); err != nil {
+			log.Printf("logout: %v", err)
+		}
+	}
+	auth.ClearTokenCookie(w)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
@@ -696,23 +1123,36 @@ func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	resp := map[string]interface{}{
 		"id":      user.ID,
 		"email":   user.Email,
 		"name":    user.Name,
 		"picture": user.Picture,
 		"role":    user.Role,
-	})
+	}
+	if adminID := auth.ImpersonatorFromContext(r.Context()); adminID != "" {
+		impersonator := map[string]string{"id": adminID}
+		if admin, err := s.Auth.GetUserByID(adminID); err == nil && admin != nil {
+			impersonator["email"] = admin.Email
+		}
+		resp["impersonated_by"] = impersonator
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 // --- Response types ---
 
 type workspaceResponse struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	CreatedAt  string  `json:"created_at"`
+	UpdatedAt  string  `json:"updated_at"`
+	ArchivedAt *string `json:"archived_at,omitempty"`
+
+	// Settings is only set on a single workspace (GET or PATCH
+	// /api/workspaces/{id}).
+	Settings *db.WorkspaceSettings `json:"settings,omitempty"`
 }
 
 type workspaceMemberResponse struct {
@@ -758,6 +1198,7 @@ type sandboxResponse struct {
 	ClaudeCodeURL   string  `json:"claudecode_url,omitempty"`
 	JupyterURL      string  `json:"jupyter_url,omitempty"`
 	CustomURL       string  `json:"custom_url,omitempty"`
+	StatusMessage   string  `json:"status_message,omitempty"`
 	CreatedAt       string  `json:"created_at"`
 	LastActivityAt  *string `json:"last_activity_at"`
 	PausedAt        *string `json:"paused_at"`
@@ -770,15 +1211,28 @@ type sandboxResponse struct {
 	WeixinBindings  []imBindingResponse    `json:"weixin_bindings,omitempty"`
 	IMBindings      []imBindingResponse    `json:"im_bindings,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	QuarantinedAt   *string                `json:"quarantined_at,omitempty"`
+	Pinned          bool                   `json:"pinned"`
+	PinnedAt        *string                `json:"pinned_at,omitempty"`
+	// DeletionScheduledAt is set on paused sandboxes that are due for
+	// deletion within the retention warning period.
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
+	Description         string            `json:"description,omitempty"`
+	Labels              map[string]string `json:"labels,omitempty"`
 }
 
 func (s *Server) toWorkspaceResponse(ws *db.Workspace) workspaceResponse {
-	return workspaceResponse{
+	resp := workspaceResponse{
 		ID:        ws.ID,
 		Name:      ws.Name,
 		CreatedAt: ws.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: ws.UpdatedAt.Format(time.RFC3339),
 	}
+	if ws.ArchivedAt.Valid {
+		t := ws.ArchivedAt.Time.Format(time.RFC3339)
+		resp.ArchivedAt = &t
+	}
+	return resp
 }
 
 // baseDomainForRequest returns the base domain that best matches the request's
@@ -801,39 +1255,41 @@ func (s *Server) baseDomainForRequest(r *http.Request) string {
 
 func (s *Server) toSandboxResponse(r *http.Request, sbx *sbxstore.Sandbox, authToken string) sandboxResponse {
 	resp := sandboxResponse{
-		ID:          sbx.ID,
-		ShortID:     sbx.ShortID,
-		WorkspaceID: sbx.WorkspaceID,
-		Name:        sbx.Name,
-		Type:        sbx.Type,
-		Status:      sbx.Status,
-		CreatedAt:   sbx.CreatedAt.Format(time.RFC3339),
-		IsLocal:     sbx.IsLocal,
-		CPU:         sbx.CPU,
-		Memory:      sbx.Memory,
-		IdleTimeout: sbx.IdleTimeout,
+		ID:            sbx.ID,
+		ShortID:       sbx.ShortID,
+		WorkspaceID:   sbx.WorkspaceID,
+		Name:          sbx.Name,
+		Type:          sbx.Type,
+		Status:        sbx.Status,
+		StatusMessage: sbx.StatusMessage,
+		CreatedAt:     sbx.CreatedAt.Format(time.RFC3339),
+		IsLocal:       sbx.IsLocal,
+		CPU:           sbx.CPU,
+		Memory:        sbx.Memory,
+		IdleTimeout:   sbx.IdleTimeout,
 	}
 	if len(s.BaseDomains) > 0 {
-		domain := s.baseDomainForRequest(r)
+		cfg := s.effectiveSettings()
+		domain := s.sandboxDomain(r, sbx)
 		subID := sbx.ShortID
 		if subID == "" {
 			subID = sbx.ID
 		}
 		switch sbx.Type {
 		case "openclaw":
-			resp.OpenclawURL = "https://" + s.OpenclawSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
+			resp.OpenclawURL = "https://" + cfg.OpenclawSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
 		case "nanoclaw":
 			// NanoClaw has no Web UI — no URL to generate
 		case "claudecode":
-			resp.ClaudeCodeURL = "https://" + s.ClaudeCodeSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
+			resp.ClaudeCodeURL = "https://" + cfg.ClaudeCodeSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
 		case "jupyter":
-			resp.JupyterURL = "https://" + s.JupyterSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
+			resp.JupyterURL = "https://" + cfg.JupyterSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
 		case "custom":
 			// Custom agents use the opencode subdomain prefix (code-{id}.domain)
 			// but skip SPA fallback in the proxy handler.
-			resp.CustomURL = "https://" + s.OpencodeSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
+			resp.CustomURL = "https://" + cfg.OpencodeSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
 		default: // "opencode"
-			resp.OpencodeURL = "https://" + s.OpencodeSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
+			resp.OpencodeURL = "https://" + cfg.OpencodeSubdomainPrefix + "-" + subID + "." + domain + "/auth?token=" + authToken
 		}
 	}
 	if sbx.LastActivityAt != nil {
@@ -848,6 +1304,15 @@ func (s *Server) toSandboxResponse(r *http.Request, sbx *sbxstore.Sandbox, authT
 		s := sbx.LastHeartbeatAt.Format(time.RFC3339)
 		resp.LastHeartbeatAt = &s
 	}
+	if sbx.QuarantinedAt != nil {
+		s := sbx.QuarantinedAt.Format(time.RFC3339)
+		resp.QuarantinedAt = &s
+	}
+	if sbx.PinnedAt != nil {
+		s := sbx.PinnedAt.Format(time.RFC3339)
+		resp.Pinned = true
+		resp.PinnedAt = &s
+	}
 	if sbx.IsLocal {
 		if ai, err := s.DB.GetAgentInfo(sbx.ID); err == nil && ai != nil {
 			resp.AgentInfo = &agentInfoResponse{
@@ -871,6 +1336,7 @@ func (s *Server) toSandboxResponse(r *http.Request, sbx *sbxstore.Sandbox, authT
 	if len(sbx.Metadata) > 0 {
 		resp.Metadata = sbx.Metadata
 	}
+	resp.Description, resp.Labels = sbx.MetadataString(sandboxDescriptionKey), sandboxLabels(sbx)
 	return resp
 }
 
@@ -957,7 +1423,8 @@ func (s *Server) handleGetWorkspacesQuota(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
 	userID := auth.UserIDFromContext(r.Context())
-	workspaces, err := s.DB.ListWorkspacesByUser(userID)
+	includeArchived := r.URL.Query().Get("include_archived") == "true"
+	workspaces, err := s.DB.ListWorkspacesByUser(userID, includeArchived)
 	if err != nil {
 		log.Printf("failed to list workspaces: %v", err)
 		http.Error(w, "failed to list workspaces", http.StatusInternalServerError)
@@ -967,6 +1434,7 @@ func (s *Server) handleListWorkspaces(w http.ResponseWriter, r *http.Request) {
 	for i, ws := range workspaces {
 		resp[i] = s.toWorkspaceResponse(ws)
 	}
+	setClientHintHeaders(w, s.clientHints(""))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
@@ -1057,35 +1525,15 @@ func (s *Server) handleGetWorkspace(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "workspace not found", http.StatusNotFound)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.toWorkspaceResponse(ws))
-}
-
-func (s *Server) handleRenameWorkspace(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if !s.requireWorkspaceRole(w, r, id, "owner", "maintainer") {
-		return
-	}
-	var req struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
-		return
-	}
-	if err := s.DB.UpdateWorkspaceName(id, req.Name); err != nil {
-		log.Printf("failed to rename workspace %s: %v", id, err)
-		http.Error(w, "failed to rename workspace", http.StatusInternalServerError)
-		return
-	}
-	ws, err := s.DB.GetWorkspace(id)
-	if err != nil || ws == nil {
-		http.Error(w, "failed to get workspace", http.StatusInternalServerError)
+	resp := s.toWorkspaceResponse(ws)
+	if resp.Settings, err = s.workspaceSettings(id); err != nil {
+		log.Printf("failed to get settings of workspace %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.toWorkspaceResponse(ws))
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
@@ -1101,7 +1549,31 @@ func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to delete workspace", http.StatusInternalServerError)
 		return
 	}
+	if sbx := firstPinned(s.Sandboxes.ListByWorkspace(id)); sbx != nil {
+		http.Error(w, "sandbox "+sbx.Name+" is pinned; unpin it before deleting the workspace", http.StatusConflict)
+		return
+	}
+	if sbx := firstQuarantined(s.Sandboxes.ListByWorkspace(id)); sbx != nil {
+		http.Error(w, "sandbox "+sbx.Name+" is quarantined; release it before deleting the workspace", http.StatusConflict)
+		return
+	}
+	if isDryRun(r) {
+		writeDryRun(w, s.workspaceDeleteActions(id, ws))
+		return
+	}
+	del := db.SandboxDeletion{Reason: db.DeletionReasonWorkspace, DeletedBy: auth.UserIDFromContext(r.Context())}
+	if err := s.deleteWorkspace(r.Context(), id, ws, del); err != nil {
+		log.Printf("failed to delete workspace %s: %v", id, err)
+		http.Error(w, "failed to delete workspace", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
+// deleteWorkspace stops the sandboxes of a workspace (ws may be nil), then
+// deletes its namespace, drives and database rows, leaving tombstones of
+// its sandboxes recorded with del. Callers check pins and quarantines.
+func (s *Server) deleteWorkspace(ctx context.Context, id string, ws *db.Workspace, del db.SandboxDeletion) error {
 	// Resolve namespace for StopBySandboxName calls.
 	var wsNamespace string
 	if ws != nil && ws.K8sNamespace.Valid {
@@ -1109,8 +1581,7 @@ func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Stop all sandboxes in the workspace.
-	sandboxes := s.Sandboxes.ListByWorkspace(id)
-	for _, sbx := range sandboxes {
+	for _, sbx := range s.Sandboxes.ListByWorkspace(id) {
 		if sbx.IsLocal {
 			// TODO: tunnel close is now a no-op here; sandbox-proxy owns tunnel connections.
 			// Tunnel will terminate when the agent's next heartbeat finds the sandbox deleted.
@@ -1119,6 +1590,7 @@ func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
 			}
 			continue
 		}
+		s.deleteSandboxSnapshots(ctx, sbx.ID)
 		switch sbx.Status {
 		case sbxstore.StatusRunning:
 			s.ProcessManager.Stop(sbx.ID)
@@ -1136,17 +1608,19 @@ func (s *Server) handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) {
 
 	// Delete the K8s namespace (cascades all resources).
 	if s.NamespaceManager != nil && wsNamespace != "" {
-		if err := s.NamespaceManager.DeleteNamespace(r.Context(), wsNamespace); err != nil {
+		if err := s.NamespaceManager.DeleteNamespace(ctx, wsNamespace); err != nil {
 			log.Printf("failed to delete namespace %s for workspace %s: %v", wsNamespace, id, err)
 		}
 	}
+	// Remove drives that don't go with the namespace (Docker volumes). This
+	// needs the workspace's volume records, so it runs before the DB delete.
+	s.deleteWorkspaceDrives(id, wsNamespace)
+	s.deleteWorkspaceSessionHistory(ctx, id)
 
-	if err := s.DB.DeleteWorkspace(id); err != nil {
-		log.Printf("failed to delete workspace %s: %v", id, err)
-		http.Error(w, "failed to delete workspace", http.StatusInternalServerError)
-		return
+	if err := s.DB.RecordWorkspaceSandboxTombstones(id, del); err != nil {
+		log.Printf("failed to record sandbox tombstones of workspace %s: %v", id, err)
 	}
-	w.WriteHeader(http.StatusNoContent)
+	return s.DB.DeleteWorkspace(id)
 }
 
 // --- Member handlers ---
@@ -1274,6 +1748,48 @@ func (s *Server) handleGetWorkspaceLLMQuota(w http.ResponseWriter, r *http.Reque
 	s.proxyLLMProxyRequest(w, http.MethodGet, "/internal/quotas/"+wsID, nil)
 }
 
+// handleGetWorkspaceSandboxBudget returns the per-sandbox LLM budget of a workspace.
+func (s *Server) handleGetWorkspaceSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	s.proxyLLMProxyRequest(w, http.MethodGet, "/internal/budgets/"+wsID, nil)
+}
+
+// handleSetWorkspaceSandboxBudget sets the per-sandbox LLM budget (owner only).
+func (s *Server) handleSetWorkspaceSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s.proxyLLMProxyRequest(w, http.MethodPut, "/internal/budgets/"+wsID, body)
+}
+
+// handleDeleteWorkspaceSandboxBudget removes the per-sandbox LLM budget (owner only).
+func (s *Server) handleDeleteWorkspaceSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	s.proxyLLMProxyRequest(w, http.MethodDelete, "/internal/budgets/"+wsID, nil)
+}
+
+// handleResetWorkspaceSandboxBudget restarts budget counting for the
+// workspace's sandboxes, lifting throttling (owner only).
+func (s *Server) handleResetWorkspaceSandboxBudget(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	s.proxyLLMProxyRequest(w, http.MethodPost, "/internal/budgets/"+wsID+"/reset", nil)
+}
+
 // --- Workspace BYOK LLM config handlers ---
 
 func maskAPIKey(key string) string {
@@ -1399,6 +1915,12 @@ func (s *Server) handleGetWorkspaceDefaults(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	currentByType, err := s.DB.CountSandboxesByWorkspaceType(wsID)
+	if err != nil {
+		log.Printf("failed to count sandboxes: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1407,9 +1929,38 @@ func (s *Server) handleGetWorkspaceDefaults(w http.ResponseWriter, r *http.Reque
 		"max_idle_timeout":   wd.MaxIdleTimeout,
 		"max_sandboxes":      wd.MaxSandboxes,
 		"current_sandboxes":  currentSandboxes,
+
+		"max_sandboxes_by_type":     wd.MaxSandboxesByType,
+		"current_sandboxes_by_type": currentByType,
+
+		"default_sandbox_type":   wd.DefaultSandboxType,
+		"default_sandbox_cpu":    wd.DefaultSandboxCPU,
+		"default_sandbox_memory": wd.DefaultSandboxMemory,
+		"default_idle_timeout":   wd.DefaultIdleTimeout,
 	})
 }
 
+// handleListSandboxTemplates lists the templates defined in the policy
+// file, then imported template bundles; pass a name as "template" when
+// creating a sandbox.
+func (s *Server) handleListSandboxTemplates(w http.ResponseWriter, r *http.Request) {
+	pol := s.Policy.Get()
+	templates := append([]policy.Template{}, pol.Templates...)
+	bundles, err := s.DB.ListTemplateBundles()
+	if err != nil {
+		log.Printf("failed to list template bundles: %v", err)
+	}
+	for _, row := range bundles {
+		var b templatebundle.Bundle
+		if err := json.Unmarshal(row.Bundle, &b); err != nil || pol.Template(row.Name) != nil {
+			continue
+		}
+		templates = append(templates, b.Template())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
 func (s *Server) handleListSandboxes(w http.ResponseWriter, r *http.Request) {
 	wsID := chi.URLParam(r, "wid")
 	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
@@ -1418,36 +1969,39 @@ func (s *Server) handleListSandboxes(w http.ResponseWriter, r *http.Request) {
 
 	sandboxes := s.Sandboxes.ListByWorkspace(wsID)
 	token := authTokenFromRequest(r)
+	warnings := s.sandboxDeletionWarnings(wsID, sandboxes, time.Now())
 	resp := make([]sandboxResponse, len(sandboxes))
 	for i, sbx := range sandboxes {
 		resp[i] = s.toSandboxResponse(r, sbx, token)
 		s.attachIMBindings(&resp[i])
+		setDeletionWarning(&resp[i], warnings)
 	}
+	hints := s.clientHints(wsID)
+	setClientHintHeaders(w, hints)
 	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("hints") == "true" {
+		json.NewEncoder(w).Encode(struct {
+			Sandboxes []sandboxResponse `json:"sandboxes"`
+			Hints     clientHints       `json:"hints"`
+		}{resp, hints})
+		return
+	}
 	json.NewEncoder(w).Encode(resp)
 }
 
 func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
+	s.createSandbox(w, r, false)
+}
+
+// createSandbox creates a sandbox, or with validate only checks that it
+// could, see handleValidateSandbox.
+func (s *Server) createSandbox(w http.ResponseWriter, r *http.Request, validate bool) {
 	wsID := chi.URLParam(r, "wid")
 	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer", "developer") {
 		return
 	}
-
-	// Quota check.
-	allowed, current, max, err := s.checkSandboxQuota(wsID)
-	if err != nil {
-		log.Printf("failed to check sandbox quota: %v", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-	if !allowed {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "quota_exceeded",
-			"message": fmt.Sprintf("Sandbox limit reached (%d/%d). Contact an admin to increase your quota.", current, max),
-			"quota":   map[string]int{"current": current, "max": max},
-		})
+	check := &sandboxCreateCheck{w: w, validate: validate}
+	if rw := check.writer(); s.rejectIfWorkspaceArchived(rw, wsID) && check.rejected(rw, "workspace_archived") {
 		return
 	}
 
@@ -1459,8 +2013,8 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cpuMillis := wd.MaxSandboxCPU   // already int millicores
-	memBytes := wd.MaxSandboxMemory // already int64 bytes
+	cpuMillis := wd.DefaultSandboxCPU   // already int millicores
+	memBytes := wd.DefaultSandboxMemory // already int64 bytes
 
 	var req struct {
 		Name          string                 `json:"name"`
@@ -1469,6 +2023,13 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		Memory        *int64                 `json:"memory"`
 		IdleTimeout   *int                   `json:"idle_timeout"`
 		Metadata      map[string]interface{} `json:"metadata"`
+		Browser       bool                   `json:"browser"`
+		Template      string                 `json:"template"`
+		TemplateID    string                 `json:"template_id"`
+		FromSandbox   string                 `json:"from_sandbox"`
+		Projects      []string               `json:"projects"`
+		ProxyScope    *proxyScope            `json:"proxy_scope"`
+		LLMProviders  []string               `json:"llm_providers"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		req.Name = "New Sandbox"
@@ -1476,36 +2037,292 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 	if req.Name == "" {
 		req.Name = "New Sandbox"
 	}
+	// Only handleCloneSandbox records the source of a clone.
+	if _, clone := r.Context().Value(cloneSourceKey{}).(string); !clone {
+		delete(req.Metadata, clonedFromKey)
+	}
+	pol := s.Policy.Get()
+	if req.Template != "" && req.TemplateID != "" {
+		check.fatal(http.StatusBadRequest, "invalid_template", "template and template_id are mutually exclusive")
+		return
+	}
+	// A workspace template sets the image, resources, startup command and
+	// env; explicit fields win.
+	var wsTemplate *db.SandboxTemplate
+	if req.TemplateID != "" {
+		t, err := s.DB.GetSandboxTemplate(wsID, req.TemplateID)
+		if err != nil {
+			log.Printf("failed to load template %s of workspace %s: %v", req.TemplateID, wsID, err)
+			http.Error(w, "failed to load template", http.StatusInternalServerError)
+			return
+		}
+		if t == nil {
+			check.fatal(http.StatusBadRequest, "unknown_template", "unknown template_id: "+req.TemplateID)
+			return
+		}
+		wsTemplate = t
+		if req.Type == "" {
+			req.Type = t.Type
+		}
+		if req.CPU == nil {
+			req.CPU = t.CPU
+		}
+		if req.Memory == nil {
+			req.Memory = t.Memory
+		}
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata[workspaceTemplateKey] = t.ID
+	} else {
+		delete(req.Metadata, workspaceTemplateKey)
+	}
+	// An imported template bundle may also set the image and init scripts.
+	var bundle *templatebundle.Bundle
+	if req.Template != "" {
+		// Template values are defaults; explicit fields win.
+		tmpl := pol.Template(req.Template)
+		if tmpl == nil {
+			b, err := s.importedTemplate(req.Template)
+			if err != nil {
+				log.Printf("failed to load template bundle %s: %v", req.Template, err)
+				http.Error(w, "failed to load template", http.StatusInternalServerError)
+				return
+			}
+			if b != nil {
+				t := b.Template()
+				tmpl, bundle = &t, b
+			}
+		}
+		if tmpl == nil {
+			check.fatal(http.StatusBadRequest, "unknown_template", "unknown template: "+req.Template)
+			return
+		}
+		if req.Type == "" {
+			req.Type = tmpl.Type
+		}
+		if req.CPU == nil && tmpl.CPU != "" {
+			v, _ := tmpl.CPU.Millicores()
+			req.CPU = &v
+		}
+		if req.Memory == nil && tmpl.Memory != "" {
+			v, _ := tmpl.Memory.Bytes()
+			req.Memory = &v
+		}
+		if req.IdleTimeout == nil && tmpl.IdleTimeout != "" {
+			v, _ := tmpl.IdleTimeout.Seconds()
+			req.IdleTimeout = &v
+		}
+		req.Browser = req.Browser || tmpl.Browser
+	}
+	if bundle != nil {
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata[sandboxTemplateKey] = bundle.Metadata.Name
+	}
+	// Start from another sandbox's hibernated image (Docker backend), so a
+	// configured environment can be shared within the workspace.
+	var baseImage string
+	if req.FromSandbox != "" {
+		src, ok := s.Sandboxes.Get(req.FromSandbox)
+		if !ok || src.WorkspaceID != wsID {
+			check.fatal(http.StatusBadRequest, "invalid_from_sandbox", "from_sandbox not found in this workspace")
+			return
+		}
+		if req.Type != "" && req.Type != src.Type {
+			check.fatal(http.StatusBadRequest, "invalid_from_sandbox", "type must match the from_sandbox type ("+src.Type+")")
+			return
+		}
+		req.Type = src.Type
+		hi, ok := s.ProcessManager.(interface {
+			HibernatedImage(context.Context, string) (string, error)
+		})
+		if !ok {
+			check.fatal(http.StatusBadRequest, "invalid_from_sandbox", "from_sandbox requires the docker backend")
+			return
+		}
+		img, err := hi.HibernatedImage(r.Context(), src.ID)
+		if err != nil {
+			log.Printf("failed to get hibernated image of sandbox %s: %v", src.ID, err)
+			http.Error(w, "failed to get hibernated image", http.StatusInternalServerError)
+			return
+		}
+		if img == "" {
+			check.fatal(http.StatusConflict, "invalid_from_sandbox", "from_sandbox has no hibernated image; pause it with AGENT_HIBERNATE_TO_IMAGE enabled")
+			return
+		}
+		baseImage = img
+	}
 	sandboxType := req.Type
+	if sandboxType == "" {
+		sandboxType = wd.DefaultSandboxType
+	}
 	if sandboxType == "" {
 		sandboxType = "opencode"
 	}
 	if sandboxType != "opencode" && sandboxType != "openclaw" && sandboxType != "nanoclaw" && sandboxType != "claudecode" && sandboxType != "jupyter" {
-		http.Error(w, "invalid sandbox type: must be opencode, openclaw, nanoclaw, claudecode, or jupyter", http.StatusBadRequest)
+		check.fatal(http.StatusBadRequest, "invalid_type", "invalid sandbox type: must be opencode, openclaw, nanoclaw, claudecode, or jupyter")
+		return
+	}
+
+	// Quota check, total and for the type.
+	allowed, limitType, current, max, err := s.checkSandboxQuota(wsID, sandboxType)
+	if err != nil {
+		log.Printf("failed to check sandbox quota: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	if !allowed {
+		quota := map[string]interface{}{"current": current, "max": max}
+		msg := fmt.Sprintf("Sandbox limit reached (%d/%d). Contact an admin to increase your quota.", current, max)
+		event := map[string]interface{}{"sandboxes": current, "max_sandboxes": max}
+		if limitType != "" {
+			quota["type"] = limitType
+			msg = fmt.Sprintf("Limit of %s sandboxes reached (%d/%d). Contact an admin to increase your quota.", limitType, current, max)
+			event["type"] = limitType
+		}
+		if !validate {
+			s.publishQuotaEvent(wsID, "quota_exceeded", event)
+		}
+		if check.rejectJSON(http.StatusForbidden, "quota_exceeded", msg, map[string]interface{}{"quota": quota}) {
+			return
+		}
+	}
+	// Extra opencode workers, one per project directory; the subdomain
+	// proxy routes requests for those directories to them.
+	var opencodeWorkers []process.OpencodeWorker
+	if len(req.Projects) > 0 {
+		if sandboxType != "opencode" {
+			check.fatal(http.StatusBadRequest, "invalid_projects", "projects is only supported for opencode sandboxes")
+			return
+		}
+		workers, err := process.OpencodeWorkers(req.Projects)
+		if err != nil {
+			check.fatal(http.StatusBadRequest, "invalid_projects", err.Error())
+			return
+		}
+		opencodeWorkers = workers
+		dirs := make([]string, len(workers))
+		for i, wk := range workers {
+			dirs[i] = wk.Dir
+		}
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata["opencode_projects"] = dirs
+	} else {
+		// Only set from projects; the proxy routes by it.
+		delete(req.Metadata, "opencode_projects")
+	}
+	// The LLM proxy enforces the scope, so it can't come from metadata.
+	delete(req.Metadata, proxyScopeKey)
+	if req.ProxyScope != nil && !req.ProxyScope.empty() {
+		if err := req.ProxyScope.validate(); err != nil && check.reject(http.StatusBadRequest, "invalid_proxy_scope", err.Error()) {
+			return
+		}
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata[proxyScopeKey] = req.ProxyScope
+	}
+	// Likewise the providers the sandbox may use through the LLM proxy.
+	delete(req.Metadata, process.LLMProvidersMetadataKey)
+	if len(req.LLMProviders) > 0 {
+		if err := process.ValidateLLMProviders(req.LLMProviders); err != nil && check.reject(http.StatusBadRequest, "invalid_llm_providers", err.Error()) {
+			return
+		}
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata[process.LLMProvidersMetadataKey] = req.LLMProviders
+	}
+	if !pol.TypeAllowed(sandboxType) && check.reject(http.StatusForbidden, "type_not_allowed", "sandbox type "+sandboxType+" is not allowed by policy") {
+		return
+	}
+	// A bundle's image replaces the type's image, unless the type was
+	// overridden or the sandbox starts from a hibernated image.
+	var templateImage string
+	if bundle != nil && bundle.Spec.Image != "" && baseImage == "" && sandboxType == bundle.Spec.Type {
+		templateImage = bundle.Spec.Image
+	}
+	// Likewise a workspace template's image and startup command.
+	var templateCommand []string
+	if wsTemplate != nil && baseImage == "" && sandboxType == wsTemplate.Type {
+		templateImage = wsTemplate.Image
+		templateCommand = startupCommand(wsTemplate)
+		if templateCommand != nil && len(opencodeWorkers) > 0 &&
+			check.reject(http.StatusBadRequest, "invalid_projects", "projects can't be combined with a template startup command") {
+			return
+		}
+	}
+	if im, ok := s.ProcessManager.(interface{ ImageForType(string) string }); ok && len(pol.ImageAllowlist) > 0 {
+		image := im.ImageForType(sandboxType)
+		if templateImage != "" {
+			image = templateImage
+		}
+		if !pol.ImageAllowed(image) {
+			if !validate {
+				log.Printf("policy: refusing %s sandbox, image %q is not allowlisted", sandboxType, image)
+			}
+			if check.reject(http.StatusForbidden, "image_not_allowed", "the "+sandboxType+" image is not allowed by policy") {
+				return
+			}
+		}
+	}
+	var image string
+	if im, ok := s.ProcessManager.(interface{ ImageForType(string) string }); ok {
+		image = im.ImageForType(sandboxType)
+		if templateImage != "" {
+			image = templateImage
+		}
+		reason, err := s.imageScanBlock(image)
+		if err != nil {
+			log.Printf("failed to check scan results of image %s: %v", image, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if reason != "" {
+			if !validate {
+				log.Printf("image scan policy: refusing %s sandbox: %s", sandboxType, reason)
+			}
+			if check.rejectJSON(http.StatusForbidden, "image_vulnerable", reason, nil) {
+				return
+			}
+		}
+	}
 	// Override resource values if user provided them, with validation.
 	if req.CPU != nil {
 		if *req.CPU <= 0 || *req.CPU > wd.MaxSandboxCPU {
-			http.Error(w, fmt.Sprintf("cpu must be between 1 and %d millicores", wd.MaxSandboxCPU), http.StatusBadRequest)
-			return
+			if check.reject(http.StatusBadRequest, "invalid_cpu", fmt.Sprintf("cpu must be between 1 and %d millicores", wd.MaxSandboxCPU)) {
+				return
+			}
+		} else {
+			cpuMillis = *req.CPU
 		}
-		cpuMillis = *req.CPU
 	}
 	if req.Memory != nil {
 		if *req.Memory <= 0 || *req.Memory > wd.MaxSandboxMemory {
-			http.Error(w, fmt.Sprintf("memory must be between 1 and %d bytes", wd.MaxSandboxMemory), http.StatusBadRequest)
-			return
+			if check.reject(http.StatusBadRequest, "invalid_memory", fmt.Sprintf("memory must be between 1 and %d bytes", wd.MaxSandboxMemory)) {
+				return
+			}
+		} else {
+			memBytes = *req.Memory
 		}
-		memBytes = *req.Memory
+	}
+	if req.IdleTimeout == nil && wd.DefaultIdleTimeout > 0 {
+		v := wd.DefaultIdleTimeout
+		req.IdleTimeout = &v
 	}
 	var idleTimeout *int
 	if req.IdleTimeout != nil {
 		if *req.IdleTimeout < 0 || (wd.MaxIdleTimeout > 0 && (*req.IdleTimeout == 0 || *req.IdleTimeout > wd.MaxIdleTimeout)) {
-			http.Error(w, fmt.Sprintf("idle_timeout must be between 1 and %d seconds", wd.MaxIdleTimeout), http.StatusBadRequest)
-			return
+			if check.reject(http.StatusBadRequest, "invalid_idle_timeout", fmt.Sprintf("idle_timeout must be between 1 and %d seconds", wd.MaxIdleTimeout)) {
+				return
+			}
+		} else {
+			idleTimeout = req.IdleTimeout
 		}
-		idleTimeout = req.IdleTimeout
 	}
 
 	// Check workspace resource budget.
@@ -1516,13 +2333,13 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !budgetOk {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "resource_budget_exceeded",
-			"message": "Workspace resource budget exceeded. Delete or pause existing sandboxes to free resources.",
-		})
-		return
+		if !validate {
+			s.publishQuotaEvent(wsID, "resource_budget_exceeded", map[string]interface{}{"cpu": cpuMillis, "memory": memBytes})
+		}
+		if check.rejectJSON(http.StatusForbidden, "resource_budget_exceeded",
+			"Workspace resource budget exceeded. Delete or pause existing sandboxes to free resources.", nil) {
+			return
+		}
 	}
 
 	// Look up workspace namespace.
@@ -1537,17 +2354,59 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		wsNamespace = ws.K8sNamespace.String
 	}
 
-	// Ensure workspace drive exists. Jupyter sandboxes are intentionally
+	// Sandboxes are pinned to the workspace's dedicated node pool, if any.
+	// Fail closed: the pool may be a compliance boundary.
+	nodePool, err := s.workspaceNodePool(wsID)
+	if err != nil {
+		log.Printf("failed to get node pool for workspace %s: %v", wsID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Fail fast when the cluster has no room for the sandbox instead of
+	// waiting for the pod to time out.
+	if rw := check.writer(); s.rejectIfNoCapacity(rw, r, cpuMillis, memBytes, nodePool) && check.rejected(rw, "insufficient_capacity") {
+		return
+	}
+	if region := nodePool.Region(); region != "" {
+		if req.Metadata == nil {
+			req.Metadata = make(map[string]interface{})
+		}
+		req.Metadata[process.RegionMetadataKey] = region
+	} else {
+		delete(req.Metadata, process.RegionMetadataKey)
+	}
+	if validate {
+		check.writeResult(&createPlan{Type: sandboxType, CPU: cpuMillis, Memory: memBytes,
+			IdleTimeout: idleTimeout, Image: image, Region: nodePool.Region()})
+		return
+	}
+
+	// The workspace drive is provisioned asynchronously before the container
+	// starts (see provisionAndStart). Jupyter sandboxes are intentionally
 	// isolated to their own session-data PVC (no shared workspace drive),
 	// so skip provisioning for that type — see design spec
 	// docs/superpowers/specs/2026-05-19-jupyter-sandbox-type-design.md
 	// ("Non-goals: Mounting workspace-drive in jupyter sandboxes").
-	var workspaceVolumes []process.VolumeMount
-	if sandboxType != "jupyter" {
-		workspaceVolumes, err = s.DriveManager.EnsureDrive(r.Context(), wsID, wsNamespace)
+	_, noDrive := s.DriveManager.(storage.NilDriveManager)
+	needsDrive := sandboxType != "jupyter" && !noDrive
+
+	secretEnv, secretFiles, err := s.workspaceSecretValues(wsID)
+	if err != nil {
+		log.Printf("failed to load secrets of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to load workspace secrets", http.StatusInternalServerError)
+		return
+	}
+
+	// Route DNS through the workspace filter if a DNS allowlist is active.
+	// Without the lookup the sandbox would escape the allowlist.
+	var dnsAddr string
+	if s.NamespaceManager != nil && wsNamespace != "" {
+		dnsAddr, err = s.NamespaceManager.DNSFilterAddress(r.Context(), wsNamespace)
 		if err != nil {
-			log.Printf("failed to ensure workspace drive for %s: %v", wsID, err)
-			// Non-fatal: sandbox can still work without workspace drive.
+			log.Printf("failed to look up dns filter for workspace %s: %v", wsID, err)
+			http.Error(w, "failed to look up workspace dns filter", http.StatusInternalServerError)
+			return
 		}
 	}
 
@@ -1596,6 +2455,10 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to create sandbox", http.StatusInternalServerError)
 		return
 	}
+	// Attribute the sandbox's LLM usage to its creator.
+	if err := s.DB.SetSandboxCreatedBy(id, auth.UserIDFromContext(r.Context())); err != nil {
+		log.Printf("failed to record creator of sandbox %s: %v", id, err)
+	}
 
 	// Generate and store bridge secret for nanoclaw sandboxes.
 	if sandboxType == "nanoclaw" {
@@ -1609,7 +2472,6 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 	// Build start options.
 	startOpts := process.StartOptions{
 		Namespace:        wsNamespace,
-		WorkspaceVolumes: workspaceVolumes,
 		OpencodeToken:    opencodeToken,
 		ProxyToken:       proxyToken,
 		SandboxType:      sandboxType,
@@ -1623,6 +2485,8 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		startOpts.WorkspaceID = wsID
 		startOpts.AssistantName = sbx.MetadataString("assistant_name")
 	}
+	startOpts.OpencodeWorkers = opencodeWorkers
+	startOpts.LLMProviders = req.LLMProviders
 	if sandboxType == "claudecode" {
 		startOpts.SandboxID = id
 		startOpts.WorkspaceID = wsID
@@ -1631,6 +2495,20 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		startOpts.SandboxID = id
 		startOpts.WorkspaceID = wsID
 	}
+	startOpts.DNSNameserver = dnsAddr
+	startOpts.NodePool = nodePool
+	tier, _ := s.workspacePriority(wsID)
+	startOpts.PriorityClassName = s.PriorityClasses[tier]
+	startOpts.Browser = req.Browser
+	startOpts.Image = baseImage
+	if templateImage != "" {
+		startOpts.Image = templateImage
+	}
+	startOpts.Command = templateCommand
+	if wsTemplate != nil {
+		startOpts.Env = wsTemplate.Env
+	}
+	startOpts.SecretEnv, startOpts.SecretFiles = secretEnv, secretFiles
 	// Priority: modelserver > BYOK > platform default
 	if msConn != nil {
 		// Modelserver connection: sandbox routes through llmproxy (no BYOK injection)
@@ -1647,34 +2525,22 @@ func (s *Server) handleCreateSandbox(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Start container asynchronously.
-	go func() {
-		var podIP string
-		// Use StartContainerWithIP if available (K8s backend) to get the pod IP.
-		if sc, ok := s.ProcessManager.(interface {
-			StartContainerWithIP(string, process.StartOptions) (string, error)
-		}); ok {
-			var err error
-			podIP, err = sc.StartContainerWithIP(id, startOpts)
-			if err != nil {
-				log.Printf("failed to start container for sandbox %s: %v", id, err)
-				s.Sandboxes.Delete(id)
-				return
-			}
-		} else {
-			if err := s.ProcessManager.StartContainer(id, startOpts); err != nil {
-				log.Printf("failed to start container for sandbox %s: %v", id, err)
-				s.Sandboxes.Delete(id)
-				return
-			}
+	if needsDrive {
+		// Kept until the drive is ready, so provisioning interrupted by a
+		// restart can be retried.
+		s.savePendingStart(id, startOpts)
+		if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusProvisioningStorage); err != nil {
+			log.Printf("failed to update status for sandbox %s: %v", id, err)
 		}
-		if podIP != "" {
-			if err := s.DB.UpdateSandboxPodIP(id, podIP); err != nil {
-				log.Printf("failed to update pod IP for sandbox %s: %v", id, err)
-			}
-		}
-		s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
-	}()
+		sbx.Status = sbxstore.StatusProvisioningStorage
+	}
+
+	if srcID, ok := r.Context().Value(cloneSourceKey{}).(string); ok {
+		s.cloneSources.Store(id, srcID)
+	}
+
+	// Provision storage and start container asynchronously.
+	go s.provisionAndStart(id, wsID, startOpts, needsDrive)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -1693,11 +2559,12 @@ func (s *Server) handleGetSandbox(w http.ResponseWriter, r *http.Request) {
 	}
 	resp := s.toSandboxResponse(r, sbx, authTokenFromRequest(r))
 	s.attachIMBindings(&resp)
+	setDeletionWarning(&resp, s.sandboxDeletionWarnings(sbx.WorkspaceID, []*sbxstore.Sandbox{sbx}, time.Now()))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (s *Server) handleRenameSandbox(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleDeleteSandbox(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	sbx, ok := s.Sandboxes.Get(id)
 	if !ok {
@@ -1707,34 +2574,53 @@ func (s *Server) handleRenameSandbox(w http.ResponseWriter, r *http.Request) {
 	if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
 		return
 	}
-	var req struct {
-		Name string `json:"name"`
+	if sbx.PinnedAt != nil {
+		http.Error(w, "sandbox is pinned; unpin it before deleting", http.StatusConflict)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
-		http.Error(w, "name is required", http.StatusBadRequest)
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox is quarantined; release it before deleting", http.StatusConflict)
 		return
 	}
-	if err := s.DB.UpdateSandboxName(id, req.Name); err != nil {
-		log.Printf("failed to rename sandbox %s: %v", id, err)
-		http.Error(w, "failed to rename sandbox", http.StatusInternalServerError)
+	if isDryRun(r) {
+		var actions []dryRunAction
+		if r.URL.Query().Get("export_sessions") == "true" && canExportSessions(sbx) {
+			actions = append(actions, dryRunAction{Action: "export_sessions", Target: id})
+		}
+		if s.canExportSessionHistory(sbx) {
+			actions = append(actions, dryRunAction{Action: "export_session_history", Target: id})
+		}
+		writeDryRun(w, append(actions, s.sandboxDeleteActions(sbx)...))
 		return
 	}
-	sbx.Name = req.Name
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.toSandboxResponse(r, sbx, authTokenFromRequest(r)))
-}
 
-func (s *Server) handleDeleteSandbox(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	sbx, ok := s.Sandboxes.Get(id)
-	if !ok {
-		http.Error(w, "sandbox not found", http.StatusNotFound)
-		return
+	// Take a last session history export, so the sandbox's sessions can be
+	// restored elsewhere. Best effort: the periodic exports are the backup.
+	if s.canExportSessionHistory(sbx) {
+		ctx, cancel := context.WithTimeout(r.Context(), sessionHistoryExportTimeout)
+		if _, _, err := s.exportSessionHistory(ctx, sbx, auth.UserIDFromContext(r.Context())); err != nil {
+			log.Printf("failed to export session history of sandbox %s before delete: %v", id, err)
+		}
+		cancel()
 	}
-	if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
-		return
+
+	// Optionally snapshot opencode sessions first so their share links keep
+	// working after the sandbox is gone.
+	var shares []sessionShareResponse
+	if r.URL.Query().Get("export_sessions") == "true" && canExportSessions(sbx) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+		var err error
+		shares, err = s.exportOpencodeSessions(ctx, r, sbx, "")
+		cancel()
+		if err != nil {
+			log.Printf("failed to export sessions of sandbox %s before delete: %v", id, err)
+			http.Error(w, "failed to export sessions; the sandbox was not deleted", http.StatusBadGateway)
+			return
+		}
 	}
 
+	s.cloneSources.Delete(id)
+
 	// Handle based on sandbox status.
 	if sbx.IsLocal {
 		// TODO: tunnel close is now a no-op here; sandbox-proxy owns tunnel connections.
@@ -1743,6 +2629,7 @@ func (s *Server) handleDeleteSandbox(w http.ResponseWriter, r *http.Request) {
 			t.Close()
 		}
 	} else {
+		s.deleteSandboxSnapshots(r.Context(), id)
 		switch sbx.Status {
 		case sbxstore.StatusRunning:
 			s.ProcessManager.Stop(id)
@@ -1770,11 +2657,17 @@ func (s *Server) handleDeleteSandbox(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := s.Sandboxes.Delete(id); err != nil {
+	del := db.SandboxDeletion{Reason: db.DeletionReasonUser, DeletedBy: auth.UserIDFromContext(r.Context())}
+	if err := s.Sandboxes.Delete(id, del); err != nil {
 		log.Printf("failed to delete sandbox %s: %v", id, err)
 		http.Error(w, "failed to delete sandbox", http.StatusInternalServerError)
 		return
 	}
+	if shares != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"session_shares": shares})
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -1810,23 +2703,28 @@ func (s *Server) handlePauseSandbox(w http.ResponseWriter, r *http.Request) {
 	// The binding is preserved so messages resume flowing when the sandbox is resumed.
 
 	// Pause asynchronously.
-	go func() {
-		if err := s.ProcessManager.Pause(id); err != nil {
-			log.Printf("failed to pause sandbox %s: %v", id, err)
-			s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
-			return
-		}
-		// Clear pod IP so the proxy won't connect to a stale address.
-		if err := s.DB.UpdateSandboxPodIP(id, ""); err != nil {
-			log.Printf("failed to clear pod IP for sandbox %s: %v", id, err)
-		}
-		s.Sandboxes.UpdateStatus(id, sbxstore.StatusPaused)
-	}()
+	go s.pauseSandbox(id)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "pausing"})
 }
 
+// pauseSandbox pauses a sandbox in the pausing state and marks it paused.
+// On failure it is marked running again and the error returned.
+func (s *Server) pauseSandbox(id string) error {
+	if err := s.ProcessManager.Pause(id); err != nil {
+		log.Printf("failed to pause sandbox %s: %v", id, err)
+		s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
+		return err
+	}
+	// Clear pod IP so the proxy won't connect to a stale address.
+	if err := s.DB.UpdateSandboxPodIP(id, ""); err != nil {
+		log.Printf("failed to clear pod IP for sandbox %s: %v", id, err)
+	}
+	s.Sandboxes.UpdateStatus(id, sbxstore.StatusPaused)
+	return nil
+}
+
 func (s *Server) handleResumeSandbox(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	sbx, ok := s.Sandboxes.Get(id)
@@ -1843,10 +2741,22 @@ func (s *Server) handleResumeSandbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox is quarantined", http.StatusForbidden)
+		return
+	}
+	if s.rejectIfWorkspaceArchived(w, sbx.WorkspaceID) {
+		return
+	}
+
 	if !sbxstore.ValidTransition(sbx.Status, sbxstore.StatusResuming) {
 		http.Error(w, "sandbox cannot be resumed in current state: "+sbx.Status, http.StatusConflict)
 		return
 	}
+	if _, busy := s.snapshotOps.Load(id); busy {
+		http.Error(w, "sandbox is being snapshotted, restored or migrated", http.StatusConflict)
+		return
+	}
 
 	// Transition to resuming.
 	if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusResuming); err != nil {
@@ -1856,47 +2766,66 @@ func (s *Server) handleResumeSandbox(w http.ResponseWriter, r *http.Request) {
 
 	// Resume asynchronously.
 	go func() {
-		var err error
-		var podIP string
-		// Use ResumeContainerWithIP if available (K8s backend).
-		if rc, ok := s.ProcessManager.(interface {
-			ResumeContainerWithIP(string) (string, error)
-		}); ok {
-			podIP, err = rc.ResumeContainerWithIP(id)
-		} else if rc, ok := s.ProcessManager.(interface{ ResumeContainer(string) error }); ok {
-			err = rc.ResumeContainer(id)
-		} else {
-			err = s.ProcessManager.StartContainer(id, process.StartOptions{})
-		}
-		if err != nil {
-			log.Printf("failed to resume sandbox %s: %v", id, err)
-			s.Sandboxes.UpdateStatus(id, sbxstore.StatusPaused)
-			return
-		}
-		if podIP != "" {
-			if err := s.DB.UpdateSandboxPodIP(id, podIP); err != nil {
-				log.Printf("failed to update pod IP for sandbox %s: %v", id, err)
-			}
-		}
-		s.Sandboxes.UpdateActivity(id)
-		s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
-
-		// Restart IM bridge pollers for nanoclaw sandboxes after resume.
-		// The Pod has a new IP; notify imbridge to restart pollers.
-		sbxNow, ok := s.Sandboxes.Get(id)
-		if ok && sbxNow.Type == "nanoclaw" && s.IMBridgeURL != "" {
-			go s.notifyIMBridgePollerRestore(id)
-		}
-
-		// WeChat credentials for openclaw sandboxes persist on PVC across
-		// pause/resume, and the config merge preserves plugin metadata.
-		// No re-injection needed.
+		pool, _ := s.workspaceNodePool(sbx.WorkspaceID)
+		s.resumeSandbox(sbx, pool)
 	}()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "resuming"})
 }
 
+// resumeSandbox resumes a sandbox in the resuming state on node pool pool
+// (for the capacity check) and marks it running. On failure it is marked
+// paused again (with the reason when the cluster could not place it) and
+// the error returned.
+func (s *Server) resumeSandbox(sbx *sbxstore.Sandbox, pool *process.NodePool) error {
+	id := sbx.ID
+	release := s.acquireStart("resume", id, sbx.WorkspaceID, sbx.CPU, sbx.Memory, pool)
+	defer release()
+
+	var err error
+	var podIP string
+	// Use ResumeContainerWithIP if available (K8s backend).
+	if rc, ok := s.ProcessManager.(interface {
+		ResumeContainerWithIP(string) (string, error)
+	}); ok {
+		podIP, err = rc.ResumeContainerWithIP(id)
+	} else if rc, ok := s.ProcessManager.(interface{ ResumeContainer(string) error }); ok {
+		err = rc.ResumeContainer(id)
+	} else {
+		err = s.ProcessManager.StartContainer(id, process.StartOptions{})
+	}
+	if err != nil {
+		log.Printf("failed to resume sandbox %s: %v", id, err)
+		if errors.Is(err, process.ErrUnschedulable) {
+			s.Sandboxes.UpdateStatusMessage(id, sbxstore.StatusPaused, "resume failed: "+err.Error())
+			return err
+		}
+		s.Sandboxes.UpdateStatus(id, sbxstore.StatusPaused)
+		return err
+	}
+	if podIP != "" {
+		if err := s.DB.UpdateSandboxPodIP(id, podIP); err != nil {
+			log.Printf("failed to update pod IP for sandbox %s: %v", id, err)
+		}
+	}
+	s.Sandboxes.UpdateActivity(id)
+	s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
+
+	// Restart IM bridge pollers for nanoclaw sandboxes after resume.
+	// The Pod has a new IP; notify imbridge to restart pollers.
+	sbxNow, ok := s.Sandboxes.Get(id)
+	if ok && sbxNow.Type == "nanoclaw" && s.IMBridgeURL != "" {
+		go s.notifyIMBridgePollerRestore(id)
+	}
+
+	// WeChat credentials for openclaw sandboxes persist on PVC across
+	// pause/resume, and the config merge preserves plugin metadata.
+	// No re-injection needed.
+	go s.runPostStartHooks(id, "resume")
+	return nil
+}
+
 func (s *Server) handleSandboxUsage(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	sbx, ok := s.Sandboxes.Get(id)
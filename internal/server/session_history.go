@@ -0,0 +1,507 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Session history: opencode keeps its projects, sessions and messages as
+// files under ~/.local/share/opencode/storage. The server periodically
+// exports that directory from every running cloud opencode sandbox into
+// the configured SessionHistoryStore (the database or object storage), so
+// conversations can be browsed and restored into a fresh sandbox after the
+// original was deleted or its image upgraded. An export is skipped when the
+// storage is unchanged since the sandbox's last one; the newest
+// SessionHistoryRetention exports per sandbox are kept.
+
+const (
+	// maxSessionHistoryBytes caps one compressed export.
+	maxSessionHistoryBytes = 256 << 20
+	// sessionHistoryExportTimeout bounds exporting or restoring one sandbox.
+	sessionHistoryExportTimeout = 5 * time.Minute
+	// defaultSessionHistoryRetention is the number of exports kept per
+	// sandbox when SessionHistoryRetention is unset.
+	defaultSessionHistoryRetention = 10
+)
+
+// sessionHistoryExportScript writes a tar.gz of opencode's storage to
+// stdout, or nothing if the sandbox has none yet.
+const sessionHistoryExportScript = `d="$HOME/.local/share/opencode"; [ -d "$d/storage" ] || exit 0; tar -czf - -C "$d" storage`
+
+// sessionHistoryRestoreScript unpacks a tar.gz from stdin into opencode's
+// data directory. Sessions in the archive replace those with the same ID;
+// others are kept.
+const sessionHistoryRestoreScript = `d="$HOME/.local/share/opencode"; mkdir -p "$d" && tar -xzf - -C "$d" && echo ok`
+
+// archivedSession is an opencode session found in an export.
+type archivedSession struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Directory string `json:"directory,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+}
+
+// readSessionArchive checks that an export only contains regular files and
+// directories below storage/ and returns the sessions in it, most recently
+// updated first, and the SHA-256 of the uncompressed tar (gzip headers carry
+// a timestamp, so only the tar tells whether the storage changed).
+func readSessionArchive(data []byte) ([]archivedSession, string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("read archive: %w", err)
+	}
+	defer zr.Close()
+	h := sha256.New()
+	tarStream := io.TeeReader(zr, h)
+	tr := tar.NewReader(tarStream)
+	sessions := []archivedSession{}
+	updated := map[string]int64{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("read archive: %w", err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if clean := path.Clean(name); clean != strings.TrimSuffix(name, "/") || (clean != "storage" && !strings.HasPrefix(clean, "storage/")) {
+			return nil, "", fmt.Errorf("unexpected archive entry %q", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, "", fmt.Errorf("unexpected archive entry %q: not a regular file", hdr.Name)
+		}
+		base := path.Base(name)
+		if !strings.HasPrefix(name, "storage/session/") || !strings.HasPrefix(base, "ses_") || !strings.HasSuffix(base, ".json") {
+			continue
+		}
+		var info struct {
+			ID        string `json:"id"`
+			Title     string `json:"title"`
+			Directory string `json:"directory"`
+			Time      struct {
+				Updated int64 `json:"updated"`
+			} `json:"time"`
+		}
+		if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(&info); err != nil || info.ID == "" {
+			continue
+		}
+		sess := archivedSession{ID: info.ID, Title: info.Title, Directory: info.Directory}
+		if info.Time.Updated > 0 {
+			sess.UpdatedAt = time.UnixMilli(info.Time.Updated).UTC().Format(time.RFC3339)
+		}
+		updated[sess.ID] = info.Time.Updated
+		sessions = append(sessions, sess)
+	}
+	if _, err := io.Copy(io.Discard, tarStream); err != nil {
+		return nil, "", fmt.Errorf("read archive: %w", err)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return updated[sessions[i].ID] > updated[sessions[j].ID] })
+	return sessions, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sessionHistoryKey is the store key of an export's archive.
+func sessionHistoryKey(e *db.SessionHistoryExport) string {
+	return e.WorkspaceID + "/" + e.SandboxID + "/" + e.ID + ".tar.gz"
+}
+
+// canExportSessionHistory reports whether a sandbox's opencode storage can
+// be read and written now.
+func (s *Server) canExportSessionHistory(sbx *sbxstore.Sandbox) bool {
+	_, ok := s.ProcessManager.(driveExecer)
+	return ok && s.SessionHistory != nil && sbx.Type == "opencode" && !sbx.IsLocal &&
+		sbx.Status == sbxstore.StatusRunning && sbx.QuarantinedAt == nil
+}
+
+// exportSessionHistory exports a sandbox's opencode storage. It returns the
+// new export, or the latest one and false if the storage is unchanged, or
+// nil and false if the sandbox has no storage yet.
+func (s *Server) exportSessionHistory(ctx context.Context, sbx *sbxstore.Sandbox, createdBy string) (*db.SessionHistoryExport, bool, error) {
+	execer := s.ProcessManager.(driveExecer)
+	out, err := execer.ExecInput(ctx, sbx.ID, []string{"sh", "-c", sessionHistoryExportScript}, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("read opencode storage: %w", err)
+	}
+	if out == "" {
+		return nil, false, nil
+	}
+	if len(out) > maxSessionHistoryBytes {
+		return nil, false, fmt.Errorf("opencode storage exceeds %d bytes compressed", maxSessionHistoryBytes)
+	}
+	data := []byte(out)
+	sessions, hash, err := readSessionArchive(data)
+	if err != nil {
+		return nil, false, err
+	}
+	latest, err := s.DB.LatestSessionHistoryExport(sbx.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	if latest != nil && latest.SHA256 == hash && latest.Store == s.SessionHistory.Name() {
+		return latest, false, nil
+	}
+
+	e := &db.SessionHistoryExport{
+		ID:           uuid.New().String(),
+		WorkspaceID:  sbx.WorkspaceID,
+		SandboxID:    sbx.ID,
+		SandboxName:  sbx.Name,
+		Store:        s.SessionHistory.Name(),
+		SizeBytes:    int64(len(data)),
+		SHA256:       hash,
+		SessionCount: len(sessions),
+		CreatedBy:    createdBy,
+	}
+	e.ObjectKey = sessionHistoryKey(e)
+	if err := s.SessionHistory.Put(ctx, e.ObjectKey, data); err != nil {
+		return nil, false, fmt.Errorf("store archive: %w", err)
+	}
+	if err := s.DB.CreateSessionHistoryExport(e); err != nil {
+		if err := s.SessionHistory.Delete(context.Background(), e.ObjectKey); err != nil {
+			log.Printf("failed to delete unrecorded session history archive %s: %v", e.ObjectKey, err)
+		}
+		return nil, false, err
+	}
+	s.pruneSessionHistory(ctx, sbx.ID)
+	return e, true, nil
+}
+
+// pruneSessionHistory deletes a sandbox's exports beyond the retention.
+func (s *Server) pruneSessionHistory(ctx context.Context, sandboxID string) {
+	keep := s.SessionHistoryRetention
+	if keep <= 0 {
+		keep = defaultSessionHistoryRetention
+	}
+	expired, err := s.DB.ExpiredSessionHistoryExports(sandboxID, keep)
+	if err != nil {
+		log.Printf("failed to list expired session history of sandbox %s: %v", sandboxID, err)
+		return
+	}
+	for _, e := range expired {
+		if err := s.deleteSessionHistoryExport(ctx, e); err != nil {
+			log.Printf("failed to delete session history export %s: %v", e.ID, err)
+		}
+	}
+}
+
+// deleteSessionHistoryExport deletes an export and its archive. Archives
+// in a store other than the configured one are left behind.
+func (s *Server) deleteSessionHistoryExport(ctx context.Context, e *db.SessionHistoryExport) error {
+	if s.SessionHistory != nil && e.Store == s.SessionHistory.Name() {
+		if err := s.SessionHistory.Delete(ctx, e.ObjectKey); err != nil {
+			return err
+		}
+	}
+	return s.DB.DeleteSessionHistoryExport(e.ID)
+}
+
+// deleteWorkspaceSessionHistory deletes the archives of a workspace being
+// deleted; the export records go with the workspace.
+func (s *Server) deleteWorkspaceSessionHistory(ctx context.Context, workspaceID string) {
+	if s.SessionHistory == nil {
+		return
+	}
+	exports, err := s.DB.ListSessionHistoryExports(workspaceID, "")
+	if err != nil {
+		log.Printf("failed to list session history of workspace %s: %v", workspaceID, err)
+		return
+	}
+	for _, e := range exports {
+		if e.Store != s.SessionHistory.Name() {
+			continue
+		}
+		if err := s.SessionHistory.Delete(ctx, e.ObjectKey); err != nil {
+			log.Printf("failed to delete session history archive %s: %v", e.ObjectKey, err)
+		}
+	}
+}
+
+// StartSessionHistoryLoop is the exported entry point for the server's main
+// lifecycle to export the session history of running opencode sandboxes
+// every `every`. It returns at once when no store is configured.
+func (s *Server) StartSessionHistoryLoop(ctx context.Context, every time.Duration) {
+	if s.SessionHistory == nil {
+		return
+	}
+	if _, ok := s.ProcessManager.(driveExecer); !ok {
+		log.Printf("session history: export is not supported by this backend")
+		return
+	}
+	if every <= 0 {
+		every = time.Hour
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.exportAllSessionHistory(ctx)
+		}
+	}
+}
+
+func (s *Server) exportAllSessionHistory(ctx context.Context) {
+	sandboxes, err := s.DB.ListAllSandboxes()
+	if err != nil {
+		log.Printf("session history: list sandboxes: %v", err)
+		return
+	}
+	exported := 0
+	for _, ds := range sandboxes {
+		if ctx.Err() != nil {
+			return
+		}
+		if ds.Type != "opencode" || ds.Status != sbxstore.StatusRunning {
+			continue
+		}
+		sbx, ok := s.Sandboxes.Get(ds.ID)
+		if !ok || !s.canExportSessionHistory(sbx) {
+			continue
+		}
+		sctx, cancel := context.WithTimeout(ctx, sessionHistoryExportTimeout)
+		_, created, err := s.exportSessionHistory(sctx, sbx, "")
+		cancel()
+		if err != nil {
+			log.Printf("session history: export of sandbox %s: %v", sbx.ID, err)
+			continue
+		}
+		if created {
+			exported++
+		}
+	}
+	if exported > 0 {
+		log.Printf("session history: exported %d sandbox(es)", exported)
+	}
+}
+
+// POST /api/sandboxes/{id}/session-history exports the sandbox's session
+// history now: 201 with the new export, or 200 with the latest one if
+// nothing changed since.
+func (s *Server) handleExportSessionHistory(w http.ResponseWriter, r *http.Request) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if s.SessionHistory == nil {
+		http.Error(w, "session history storage is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !s.canExportSessionHistory(sbx) {
+		http.Error(w, "session history export requires a running cloud opencode sandbox", http.StatusConflict)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sessionHistoryExportTimeout)
+	defer cancel()
+	e, created, err := s.exportSessionHistory(ctx, sbx, auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		log.Printf("failed to export session history of sandbox %s: %v", sbx.ID, err)
+		http.Error(w, "failed to export session history", http.StatusBadGateway)
+		return
+	}
+	if e == nil {
+		http.Error(w, "the sandbox has no opencode sessions yet", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(e)
+}
+
+// GET /api/workspaces/{id}/session-history?sandbox_id= lists the
+// workspace's exports, including those of deleted sandboxes.
+func (s *Server) handleListSessionHistory(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	exports, err := s.DB.ListSessionHistoryExports(wsID, r.URL.Query().Get("sandbox_id"))
+	if err != nil {
+		log.Printf("failed to list session history of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to list session history", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exports)
+}
+
+// sessionHistoryExport resolves the export of a request in the workspace
+// {id}. It writes the error response and returns nil on failure.
+func (s *Server) sessionHistoryExport(w http.ResponseWriter, r *http.Request, workspaceID string) *db.SessionHistoryExport {
+	e, err := s.DB.GetSessionHistoryExport(chi.URLParam(r, "exportID"))
+	if err != nil {
+		log.Printf("failed to get session history export: %v", err)
+		http.Error(w, "failed to get session history export", http.StatusInternalServerError)
+		return nil
+	}
+	if e == nil || e.WorkspaceID != workspaceID {
+		http.Error(w, "session history export not found", http.StatusNotFound)
+		return nil
+	}
+	return e
+}
+
+// loadSessionHistory reads an export's archive from the store. It writes
+// the error response and returns nil on failure.
+func (s *Server) loadSessionHistory(w http.ResponseWriter, ctx context.Context, e *db.SessionHistoryExport) []byte {
+	if s.SessionHistory == nil || e.Store != s.SessionHistory.Name() {
+		http.Error(w, "the export is in the "+e.Store+" store, which is not configured", http.StatusConflict)
+		return nil
+	}
+	data, err := s.SessionHistory.Get(ctx, e.ObjectKey)
+	if errors.Is(err, errSessionHistoryNotFound) {
+		http.Error(w, "session history archive is missing", http.StatusGone)
+		return nil
+	}
+	if err != nil {
+		log.Printf("failed to read session history archive %s: %v", e.ObjectKey, err)
+		http.Error(w, "failed to read session history", http.StatusBadGateway)
+		return nil
+	}
+	return data
+}
+
+// GET /api/workspaces/{id}/session-history/{exportID} returns an export
+// with the sessions in it.
+func (s *Server) handleGetSessionHistory(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	e := s.sessionHistoryExport(w, r, wsID)
+	if e == nil {
+		return
+	}
+	data := s.loadSessionHistory(w, r.Context(), e)
+	if data == nil {
+		return
+	}
+	sessions, _, err := readSessionArchive(data)
+	if err != nil {
+		log.Printf("session history export %s: %v", e.ID, err)
+		http.Error(w, "session history archive is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		*db.SessionHistoryExport
+		Sessions []archivedSession `json:"sessions"`
+	}{e, sessions})
+}
+
+// DELETE /api/workspaces/{id}/session-history/{exportID} deletes an export.
+func (s *Server) handleDeleteSessionHistory(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	e := s.sessionHistoryExport(w, r, wsID)
+	if e == nil {
+		return
+	}
+	if isDryRun(r) {
+		writeDryRun(w, []dryRunAction{{Action: "delete_session_history", Target: e.ID,
+			Details: map[string]interface{}{"sandbox_id": e.SandboxID, "store": e.Store}}})
+		return
+	}
+	if err := s.deleteSessionHistoryExport(r.Context(), e); err != nil {
+		log.Printf("failed to delete session history export %s: %v", e.ID, err)
+		http.Error(w, "failed to delete session history export", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/sandboxes/{id}/session-history/restore {"export_id": "..."}
+// unpacks an export of any sandbox of the workspace into this running
+// opencode sandbox. Sessions in the export replace those with the same ID.
+func (s *Server) handleRestoreSessionHistory(w http.ResponseWriter, r *http.Request) {
+	sbx, ok := s.Sandboxes.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	var req struct {
+		ExportID string `json:"export_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ExportID == "" {
+		http.Error(w, "export_id is required", http.StatusBadRequest)
+		return
+	}
+	e, err := s.DB.GetSessionHistoryExport(req.ExportID)
+	if err != nil {
+		log.Printf("failed to get session history export: %v", err)
+		http.Error(w, "failed to get session history export", http.StatusInternalServerError)
+		return
+	}
+	if e == nil || e.WorkspaceID != sbx.WorkspaceID {
+		http.Error(w, "session history export not found", http.StatusNotFound)
+		return
+	}
+	if !s.canExportSessionHistory(sbx) {
+		http.Error(w, "session history restore requires a running cloud opencode sandbox", http.StatusConflict)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), sessionHistoryExportTimeout)
+	defer cancel()
+	data := s.loadSessionHistory(w, ctx, e)
+	if data == nil {
+		return
+	}
+	if _, _, err := readSessionArchive(data); err != nil {
+		log.Printf("session history export %s: %v", e.ID, err)
+		http.Error(w, "session history archive is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	if !s.beginSnapshotOp(w, sbx.ID) {
+		return
+	}
+	defer s.snapshotOps.Delete(sbx.ID)
+
+	execer := s.ProcessManager.(driveExecer)
+	out, err := execer.ExecInput(ctx, sbx.ID, []string{"sh", "-c", sessionHistoryRestoreScript}, bytes.NewReader(data))
+	if err == nil && strings.TrimSpace(out) != "ok" {
+		err = fmt.Errorf("unexpected output %q", out)
+	}
+	if err != nil {
+		log.Printf("failed to restore session history %s into sandbox %s: %v", e.ID, sbx.ID, err)
+		http.Error(w, "failed to restore session history", http.StatusBadGateway)
+		return
+	}
+	log.Printf("sandbox %s: restored session history %s (from sandbox %s) by %s", sbx.ID, e.ID, e.SandboxID, auth.UserIDFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// errSessionHistoryNotFound is returned by SessionHistoryStore.Get when a
+// key is absent.
+var errSessionHistoryNotFound = errors.New("session history archive not found")
+
+// SessionHistoryStore holds exported opencode session archives by key.
+// Name identifies the store in export records ("db", "s3"), so exports
+// taken with another store are recognized as unavailable.
+type SessionHistoryStore interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewSessionHistoryStore returns the store named by SESSION_HISTORY_STORE:
+// "db" keeps archives in the database, "s3" in the bucket of cfg.
+func NewSessionHistoryStore(kind string, database *db.DB, cfg S3SessionHistoryConfig) (SessionHistoryStore, error) {
+	switch kind {
+	case "db":
+		return &dbSessionHistoryStore{db: database}, nil
+	case "s3":
+		return newS3SessionHistoryStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown session history store %q (want db or s3)", kind)
+	}
+}
+
+type dbSessionHistoryStore struct {
+	db *db.DB
+}
+
+func (s *dbSessionHistoryStore) Name() string { return "db" }
+
+func (s *dbSessionHistoryStore) Put(_ context.Context, key string, data []byte) error {
+	return s.db.PutSessionHistoryBlob(key, data)
+}
+
+func (s *dbSessionHistoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := s.db.GetSessionHistoryBlob(key)
+	if err == nil && data == nil {
+		return nil, errSessionHistoryNotFound
+	}
+	return data, err
+}
+
+func (s *dbSessionHistoryStore) Delete(_ context.Context, key string) error {
+	return s.db.DeleteSessionHistoryBlob(key)
+}
+
+// S3SessionHistoryConfig locates the bucket of the "s3" store. Endpoint is
+// empty for AWS; Prefix is prepended to every key.
+type S3SessionHistoryConfig struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+}
+
+type s3SessionHistoryStore struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3SessionHistoryStore(cfg S3SessionHistoryConfig) (*s3SessionHistoryStore, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 session history store: bucket required")
+	}
+	awsCfg := aws.Config{Region: cfg.Region}
+	if cfg.AccessKeyID != "" {
+		awsCfg.Credentials = credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = &cfg.Endpoint
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+	return &s3SessionHistoryStore{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (s *s3SessionHistoryStore) Name() string { return "s3" }
+
+func (s *s3SessionHistoryStore) Put(ctx context.Context, key string, data []byte) error {
+	key = s.prefix + key
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *s3SessionHistoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	key = s.prefix + key
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &key})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, errSessionHistoryNotFound
+		}
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func (s *s3SessionHistoryStore) Delete(ctx context.Context, key string) error {
+	key = s.prefix + key
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: &s.bucket, Key: &key})
+	return err
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
+
+type archiveEntry struct {
+	name     string
+	typeflag byte
+	body     string
+}
+
+func sessionArchive(t *testing.T, modTime time.Time, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.ModTime = modTime
+	tw := tar.NewWriter(zw)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Mode: 0o644, Size: int64(len(e.body))}
+		if e.typeflag == tar.TypeSymlink {
+			hdr.Linkname, hdr.Size = "/etc/passwd", 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadSessionArchive(t *testing.T) {
+	entries := []archiveEntry{
+		{"storage/", tar.TypeDir, ""},
+		{"storage/session/proj1/ses_old.json", tar.TypeReg, `{"id":"ses_old","title":"Old","directory":"/home/agent/projects/a","time":{"updated":1700000000000}}`},
+		{"storage/session/proj1/ses_new.json", tar.TypeReg, `{"id":"ses_new","title":"New","time":{"updated":1800000000000}}`},
+		{"storage/message/ses_new/msg_1.json", tar.TypeReg, `{"id":"msg_1"}`},
+		{"storage/session/proj1/ses_broken.json", tar.TypeReg, `not json`},
+	}
+	data := sessionArchive(t, time.Unix(1, 0), entries...)
+	sessions, hash, err := readSessionArchive(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "ses_new" || sessions[1].ID != "ses_old" {
+		t.Fatalf("sessions = %+v, want ses_new, ses_old", sessions)
+	}
+	if sessions[1].Title != "Old" || sessions[1].Directory != "/home/agent/projects/a" || sessions[1].UpdatedAt != "2023-11-14T22:13:20Z" {
+		t.Errorf("ses_old = %+v", sessions[1])
+	}
+
+	// The hash covers the tar, not the gzip header.
+	_, again, err := readSessionArchive(sessionArchive(t, time.Unix(2, 0), entries...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != hash {
+		t.Errorf("hash changed with the gzip timestamp: %s != %s", again, hash)
+	}
+	_, changed, err := readSessionArchive(sessionArchive(t, time.Unix(1, 0), entries[:3]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == hash {
+		t.Error("hash unchanged after removing files")
+	}
+
+	for _, bad := range []archiveEntry{
+		{"storage/../.bashrc", tar.TypeReg, "x"},
+		{"/etc/cron.d/x", tar.TypeReg, "x"},
+		{".ssh/authorized_keys", tar.TypeReg, "x"},
+		{"storage/link", tar.TypeSymlink, ""},
+	} {
+		if _, _, err := readSessionArchive(sessionArchive(t, time.Unix(1, 0), bad)); err == nil {
+			t.Errorf("entry %q (type %c) accepted", bad.name, bad.typeflag)
+		}
+	}
+	if _, _, err := readSessionArchive([]byte("not gzip")); err == nil {
+		t.Error("non-gzip data accepted")
+	}
+}
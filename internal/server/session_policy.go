@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/agentserver/agentserver/internal/auth"
+)
+
+func writeSessionPolicy(w http.ResponseWriter, p auth.SessionPolicy) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"max_active_tokens": p.MaxActiveTokens,
+		"single_session":    p.SingleSession,
+	})
+}
+
+func (s *Server) handleAdminGetSessionPolicy(w http.ResponseWriter, r *http.Request) {
+	writeSessionPolicy(w, s.Auth.SessionPolicy())
+}
+
+// handleAdminSetSessionPolicy updates the per-user login session limits.
+// Limits apply on the next login: issuing a token evicts the user's oldest
+// tokens beyond the limit.
+func (s *Server) handleAdminSetSessionPolicy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MaxActiveTokens *int  `json:"max_active_tokens"`
+		SingleSession   *bool `json:"single_session"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if req.MaxActiveTokens != nil {
+		if *req.MaxActiveTokens < 0 {
+			http.Error(w, "max_active_tokens must be >= 0", http.StatusBadRequest)
+			return
+		}
+		if err := s.DB.SetSystemSetting(auth.SettingKeyMaxActiveTokens, strconv.Itoa(*req.MaxActiveTokens)); err != nil {
+			log.Printf("admin: failed to set session policy: %v", err)
+			http.Error(w, "failed to save setting", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.SingleSession != nil {
+		if err := s.DB.SetSystemSetting(auth.SettingKeySingleSession, strconv.FormatBool(*req.SingleSession)); err != nil {
+			log.Printf("admin: failed to set session policy: %v", err)
+			http.Error(w, "failed to save setting", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeSessionPolicy(w, s.Auth.SessionPolicy())
+}
@@ -0,0 +1,358 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// maxTranscriptBytes caps one exported session transcript.
+const maxTranscriptBytes = 32 << 20
+
+var opencodeExportClient = &http.Client{Timeout: 60 * time.Second}
+
+var errSessionNotFound = errors.New("session not found")
+
+type sessionShareResponse struct {
+	ID          string  `json:"id"`
+	URL         string  `json:"url"`
+	SandboxID   string  `json:"sandbox_id"`
+	SandboxName string  `json:"sandbox_name"`
+	SessionID   string  `json:"session_id"`
+	Title       string  `json:"title"`
+	CreatedBy   *string `json:"created_by,omitempty"`
+	CreatedAt   string  `json:"created_at,omitempty"`
+	UpdatedAt   string  `json:"updated_at,omitempty"`
+}
+
+// shareURL returns the public URL of a session share on the host the
+// request came in on.
+func shareURL(r *http.Request, id string) string {
+	return requestOrigin(r) + "/share/" + id
+}
+
+// opencodeGet calls the opencode server API of a running cloud sandbox.
+func opencodeGet(ctx context.Context, sbx *sbxstore.Sandbox, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+net.JoinHostPort(sbx.PodIP, "4096")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if sbx.OpencodeToken != "" {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("opencode:"+sbx.OpencodeToken)))
+	}
+	resp, err := opencodeExportClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: status %d", path, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTranscriptBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxTranscriptBytes {
+		return nil, fmt.Errorf("GET %s: response exceeds %d bytes", path, maxTranscriptBytes)
+	}
+	return data, nil
+}
+
+// canExportSessions reports whether the opencode API of a sandbox is
+// reachable for export.
+func canExportSessions(sbx *sbxstore.Sandbox) bool {
+	return sbx.Type == "opencode" && !sbx.IsLocal && sbx.Status == sbxstore.StatusRunning && sbx.PodIP != ""
+}
+
+// exportOpencodeSessions snapshots opencode session transcripts of a
+// sandbox into session_shares. With onlySession set only that session is
+// exported. Sessions that were shared before keep their share ID.
+func (s *Server) exportOpencodeSessions(ctx context.Context, r *http.Request, sbx *sbxstore.Sandbox, onlySession string) ([]sessionShareResponse, error) {
+	data, err := opencodeGet(ctx, sbx, "/session")
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var sessions []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("decode sessions: %w", err)
+	}
+
+	userID := auth.UserIDFromContext(r.Context())
+	shares := []sessionShareResponse{}
+	for _, sess := range sessions {
+		if onlySession != "" && sess.ID != onlySession {
+			continue
+		}
+		transcript, err := opencodeGet(ctx, sbx, "/session/"+sess.ID+"/message")
+		if err != nil {
+			return shares, fmt.Errorf("export session %s: %w", sess.ID, err)
+		}
+		id, err := s.DB.UpsertSessionShare(generatePassword(), sbx.WorkspaceID, sbx.ID, sbx.Name, sess.ID, sess.Title, transcript, userID)
+		if err != nil {
+			return shares, err
+		}
+		shares = append(shares, sessionShareResponse{
+			ID:          id,
+			URL:         shareURL(r, id),
+			SandboxID:   sbx.ID,
+			SandboxName: sbx.Name,
+			SessionID:   sess.ID,
+			Title:       sess.Title,
+		})
+	}
+	if onlySession != "" && len(shares) == 0 {
+		return nil, errSessionNotFound
+	}
+	return shares, nil
+}
+
+// handleShareSandboxSessions snapshots a running opencode sandbox's sessions
+// (or one of them) and returns their share links.
+func (s *Server) handleShareSandboxSessions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if !canExportSessions(sbx) {
+		http.Error(w, "session sharing requires a running cloud opencode sandbox", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+	shares, err := s.exportOpencodeSessions(ctx, r, sbx, req.SessionID)
+	if err == errSessionNotFound {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to export sessions of sandbox %s: %v", id, err)
+		http.Error(w, "failed to export sessions", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+func (s *Server) handleListSessionShares(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+
+	shares, err := s.DB.ListSessionShares(wsID)
+	if err != nil {
+		log.Printf("failed to list session shares: %v", err)
+		http.Error(w, "failed to list session shares", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]sessionShareResponse, len(shares))
+	for i, sh := range shares {
+		resp[i] = sessionShareResponse{
+			ID:          sh.ID,
+			URL:         shareURL(r, sh.ID),
+			SandboxID:   sh.SandboxID,
+			SandboxName: sh.SandboxName,
+			SessionID:   sh.SessionID,
+			Title:       sh.Title,
+			CreatedBy:   sh.CreatedBy,
+			CreatedAt:   sh.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:   sh.UpdatedAt.Format(time.RFC3339),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleDeleteSessionShare(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "shareID")
+	share, err := s.DB.GetSessionShare(id)
+	if err != nil {
+		log.Printf("failed to get session share %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if share == nil {
+		http.Error(w, "share not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, share.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if err := s.DB.DeleteSessionShare(id); err != nil {
+		log.Printf("failed to delete session share %s: %v", id, err)
+		http.Error(w, "failed to delete share", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetSharedSession serves a share without authentication: as JSON on
+// /api/share/{shareID}, otherwise as a read-only HTML page.
+func (s *Server) handleGetSharedSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "shareID")
+	share, err := s.DB.GetSessionShare(id)
+	if err != nil {
+		log.Printf("failed to get session share %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if share == nil {
+		http.Error(w, "share not found", http.StatusNotFound)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":           share.ID,
+			"sandbox_name": share.SandboxName,
+			"session_id":   share.SessionID,
+			"title":        share.Title,
+			"updated_at":   share.UpdatedAt.Format(time.RFC3339),
+			"messages":     json.RawMessage(share.Transcript),
+		})
+		return
+	}
+
+	entries, err := transcriptEntries(share.Transcript)
+	if err != nil {
+		log.Printf("failed to render session share %s: %v", id, err)
+		http.Error(w, "failed to render transcript", http.StatusInternalServerError)
+		return
+	}
+	title := share.Title
+	if title == "" {
+		title = "Shared session"
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	shareTemplate.Execute(w, map[string]interface{}{
+		"Title":       title,
+		"SandboxName": share.SandboxName,
+		"UpdatedAt":   share.UpdatedAt.UTC().Format("2006-01-02 15:04 MST"),
+		"Entries":     entries,
+	})
+}
+
+// transcriptEntry is one rendered part of an opencode message.
+type transcriptEntry struct {
+	Role   string // "user" or "assistant"
+	Kind   string // "text", "reasoning" or "tool"
+	Text   string
+	Tool   string
+	Status string
+}
+
+// transcriptEntries flattens an opencode /session/{id}/message response into
+// displayable entries. Unknown part types (snapshots, patches, step markers)
+// are skipped.
+func transcriptEntries(transcript []byte) ([]transcriptEntry, error) {
+	var messages []struct {
+		Info struct {
+			Role string `json:"role"`
+		} `json:"info"`
+		Parts []struct {
+			Type  string `json:"type"`
+			Text  string `json:"text"`
+			Tool  string `json:"tool"`
+			State struct {
+				Status string          `json:"status"`
+				Input  json.RawMessage `json:"input"`
+				Output string          `json:"output"`
+				Error  string          `json:"error"`
+			} `json:"state"`
+		} `json:"parts"`
+	}
+	if err := json.Unmarshal(transcript, &messages); err != nil {
+		return nil, err
+	}
+
+	var entries []transcriptEntry
+	for _, m := range messages {
+		for _, p := range m.Parts {
+			e := transcriptEntry{Role: m.Info.Role, Kind: p.Type}
+			switch p.Type {
+			case "text", "reasoning":
+				if strings.TrimSpace(p.Text) == "" {
+					continue
+				}
+				e.Text = p.Text
+			case "tool":
+				e.Tool = p.Tool
+				e.Status = p.State.Status
+				var b strings.Builder
+				if len(p.State.Input) > 0 && string(p.State.Input) != "null" {
+					b.Write(p.State.Input)
+					b.WriteString("\n\n")
+				}
+				b.WriteString(p.State.Output)
+				b.WriteString(p.State.Error)
+				e.Text = b.String()
+			default:
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+var shareTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 860px; margin: 0 auto; padding: 24px; color: #1f2328; background: #fff; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 16px; }
+header p { color: #656d76; font-size: 14px; }
+.entry { margin: 12px 0; padding: 12px 16px; border-radius: 8px; white-space: pre-wrap; word-wrap: break-word; }
+.user { background: #ddf4ff; }
+.assistant { background: #f6f8fa; }
+.reasoning { color: #656d76; font-style: italic; }
+details { margin: 8px 0; }
+summary { cursor: pointer; font-family: ui-monospace, monospace; font-size: 13px; color: #656d76; }
+pre { background: #f6f8fa; padding: 12px; overflow-x: auto; font-size: 12px; max-height: 480px; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Read-only snapshot of an opencode session{{if .SandboxName}} from sandbox <strong>{{.SandboxName}}</strong>{{end}}, taken {{.UpdatedAt}}.</p>
+</header>
+{{range .Entries}}{{if eq .Kind "tool"}}<details><summary>{{.Tool}} ({{.Status}})</summary><pre>{{.Text}}</pre></details>
+{{else}}<div class="entry {{.Role}}{{if eq .Kind "reasoning"}} reasoning{{end}}">{{.Text}}</div>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testTranscript = `[
+  {"info": {"role": "user"}, "parts": [{"type": "text", "text": "Fix the <flaky> test"}]},
+  {"info": {"role": "assistant"}, "parts": [
+    {"type": "step-start"},
+    {"type": "reasoning", "text": "Look at the test first."},
+    {"type": "tool", "tool": "bash", "state": {"status": "completed", "input": {"command": "go test ./..."}, "output": "ok"}},
+    {"type": "text", "text": "   "},
+    {"type": "text", "text": "Done."}
+  ]}
+]`
+
+func TestTranscriptEntries(t *testing.T) {
+	entries, err := transcriptEntries([]byte(testTranscript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4: %+v", len(entries), entries)
+	}
+	if entries[0].Role != "user" || entries[0].Text != "Fix the <flaky> test" {
+		t.Errorf("user entry = %+v", entries[0])
+	}
+	if entries[1].Kind != "reasoning" {
+		t.Errorf("entry 1 kind = %q, want reasoning", entries[1].Kind)
+	}
+	tool := entries[2]
+	if tool.Tool != "bash" || tool.Status != "completed" || !strings.Contains(tool.Text, `"go test ./..."`) || !strings.HasSuffix(tool.Text, "ok") {
+		t.Errorf("tool entry = %+v", tool)
+	}
+	if entries[3].Text != "Done." {
+		t.Errorf("last entry = %+v", entries[3])
+	}
+
+	if _, err := transcriptEntries([]byte(`{"not": "a list"}`)); err == nil {
+		t.Error("expected error for malformed transcript")
+	}
+}
+
+func TestShareTemplateEscapes(t *testing.T) {
+	entries, _ := transcriptEntries([]byte(testTranscript))
+	var buf bytes.Buffer
+	if err := shareTemplate.Execute(&buf, map[string]interface{}{
+		"Title":   "<script>alert(1)</script>",
+		"Entries": entries,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<script>") || strings.Contains(out, "<flaky>") {
+		t.Error("transcript content must be HTML-escaped")
+	}
+}
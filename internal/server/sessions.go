@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Users can see where they are logged in and revoke login sessions, one or
+// all at once, e.g. after a session cookie leaked. Impersonation sessions
+// belong to the admin and aren't listed. Like tokens, sessions can't be
+// managed with a personal access token.
+
+// GET /api/users/me/sessions
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	if refusePAT(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	sessions, err := s.DB.ListSessions(userID, auth.SessionToken(r))
+	if err != nil {
+		log.Printf("failed to list sessions of %s: %v", userID, err)
+		http.Error(w, "failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// DELETE /api/users/me/sessions/{sessionID} logs a session out. Revoking
+// the current session also clears the cookie.
+func (s *Server) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	if refusePAT(w, r) || refuseImpersonation(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	id := chi.URLParam(r, "sessionID")
+	found, current, err := s.DB.DeleteSession(userID, id, auth.SessionToken(r))
+	if err != nil {
+		log.Printf("failed to revoke session %s of %s: %v", id, userID, err)
+		http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventSessionRevoked,
+		Severity: SecuritySeverityInfo,
+		TargetID: id,
+	})
+	if current {
+		auth.ClearTokenCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/users/me/sessions[?keep_current=true] logs out everywhere,
+// including the current session unless keep_current is set.
+func (s *Server) handleRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	if refusePAT(w, r) || refuseImpersonation(w, r) {
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	keepCurrent := r.URL.Query().Get("keep_current") == "true"
+	keep := ""
+	if keepCurrent {
+		keep = auth.SessionToken(r)
+	}
+	n, err := s.DB.DeleteSessions(userID, keep)
+	if err != nil {
+		log.Printf("failed to revoke sessions of %s: %v", userID, err)
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventSessionRevoked,
+		Severity: SecuritySeverityInfo,
+		TargetID: userID,
+		Details:  map[string]interface{}{"all": true, "keep_current": keepCurrent, "revoked": n},
+	})
+	if !keepCurrent {
+		auth.ClearTokenCookie(w)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"revoked": n})
+}
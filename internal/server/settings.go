@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/settings"
+)
+
+// effectiveSettings returns the current server toggles, falling back to the
+// startup configuration when no settings manager is attached.
+func (s *Server) effectiveSettings() settings.Settings {
+	if s.Settings != nil {
+		return s.Settings.Get()
+	}
+	st := settings.Settings{
+		PasswordAuthEnabled:       s.PasswordAuthEnabled,
+		OpencodeSubdomainPrefix:   s.OpencodeSubdomainPrefix,
+		OpenclawSubdomainPrefix:   s.OpenclawSubdomainPrefix,
+		ClaudeCodeSubdomainPrefix: s.ClaudeCodeSubdomainPrefix,
+		JupyterSubdomainPrefix:    s.JupyterSubdomainPrefix,
+	}
+	if s.NamespaceManager != nil {
+		np := s.NamespaceManager.NetworkPolicy()
+		st.NetworkPolicyEnabled = np.Enabled
+		st.NetworkPolicyDenyCIDRs = np.DenyCIDRs
+	}
+	return st
+}
+
+// watchNetworkPolicy keeps the namespace manager's NetworkPolicy config in
+// sync with the settings and, when an admin changes it, re-applies it to
+// every workspace namespace.
+func (s *Server) watchNetworkPolicy() {
+	var (
+		mu   sync.Mutex
+		last *settings.Settings
+	)
+	s.Settings.OnChange(func(st settings.Settings) {
+		mu.Lock()
+		defer mu.Unlock()
+		s.NamespaceManager.SetNetworkPolicy(st.NetworkPolicyEnabled, st.NetworkPolicyDenyCIDRs)
+		changed := last != nil && (last.NetworkPolicyEnabled != st.NetworkPolicyEnabled ||
+			!slices.Equal(last.NetworkPolicyDenyCIDRs, st.NetworkPolicyDenyCIDRs))
+		last = &st
+		if changed {
+			go s.reensureNamespaces()
+		}
+	})
+}
+
+func (s *Server) reensureNamespaces() {
+	workspaces, err := s.DB.ListAllWorkspaces()
+	if err != nil {
+		log.Printf("settings: failed to list workspaces for network policy update: %v", err)
+		return
+	}
+	for _, ws := range workspaces {
+		if !ws.K8sNamespace.Valid {
+			continue
+		}
+		if _, err := s.NamespaceManager.EnsureNamespace(context.Background(), ws.ID); err != nil {
+			log.Printf("settings: failed to update namespace for workspace %s: %v", ws.ID, err)
+		}
+	}
+}
+
+// requirePasswordAuth hides the password login routes while password auth
+// is disabled.
+func (s *Server) requirePasswordAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.effectiveSettings().PasswordAuthEnabled {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) writeSettings(w http.ResponseWriter) {
+	resp := map[string]interface{}{
+		"settings": s.effectiveSettings(),
+	}
+	if s.Settings != nil {
+		resp["defaults"] = s.Settings.Defaults()
+		resp["overrides"] = s.Settings.Overrides()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleAdminGetSettings returns the effective server toggles along with
+// their environment defaults and the admin overrides in effect.
+func (s *Server) handleAdminGetSettings(w http.ResponseWriter, r *http.Request) {
+	s.writeSettings(w)
+}
+
+// handleAdminUpdateSettings overrides server toggles. Fields omitted from
+// the body keep their current value; names listed in "reset" revert to the
+// environment default. Other replicas and the sandbox proxy pick up changes
+// within 30 seconds; NetworkPolicy changes are re-applied to every
+// workspace namespace in the background.
+func (s *Server) handleAdminUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	if s.Settings == nil {
+		http.Error(w, "settings are not available", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		settings.Overrides
+		Reset []string `json:"reset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.NetworkPolicyEnabled != nil && s.NamespaceManager == nil {
+		http.Error(w, "network policies require the k8s backend", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.Settings.Update(req.Overrides, req.Reset); err != nil {
+		if errors.Is(err, settings.ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("admin: failed to update settings: %v", err)
+		http.Error(w, "failed to save settings", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin: server settings updated by %s", auth.UserIDFromContext(r.Context()))
+
+	s.writeSettings(w)
+}
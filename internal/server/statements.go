@@ -0,0 +1,539 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// Monthly usage statements: a per-workspace rollup of LLM tokens (from the
+// LLM proxy), sandbox compute-hours (from sandbox_runs) and provisioned drive
+// storage, persisted so team leads can download them as CSV or PDF. A
+// background loop generates last month's statements and, when a mailer is
+// configured, emails them to the workspace owners.
+
+const statementMonthLayout = "2006-01"
+
+// parseStatementMonth parses a "YYYY-MM" month into its first day (UTC).
+func parseStatementMonth(s string) (time.Time, error) {
+	t, err := time.Parse(statementMonthLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("month must be YYYY-MM")
+	}
+	return t.UTC(), nil
+}
+
+// previousMonth returns the first day of the month before the one containing now.
+func previousMonth(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+}
+
+// generateUsageStatement computes and saves the statement of a workspace month.
+func (s *Server) generateUsageStatement(wsID string, month time.Time) (*db.UsageStatement, error) {
+	until := month.AddDate(0, 1, 0)
+	st := &db.UsageStatement{WorkspaceID: wsID, Month: month}
+
+	if s.LLMProxyURL != "" {
+		models, err := s.fetchStatementTokens(wsID, month, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range models {
+			st.InputTokens += m.InputTokens
+			st.OutputTokens += m.OutputTokens
+			st.CacheTokens += m.CacheTokens
+			st.LLMRequests += m.Requests
+		}
+		st.Models = models
+	}
+
+	compute, err := s.DB.WorkspaceComputeUsage(wsID, month, until)
+	if err != nil {
+		return nil, err
+	}
+	st.ComputeHours = compute.ComputeHours
+	st.CPUHours = compute.CPUHours
+	st.MemoryGBHours = compute.MemoryGBHours
+
+	volumes, err := s.DB.ListWorkspaceVolumes(wsID)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) > 0 {
+		wd, err := s.effectiveWorkspaceDefaults(wsID)
+		if err != nil {
+			return nil, err
+		}
+		st.StorageBytes = int64(len(volumes)) * wd.MaxDriveSize
+	}
+
+	if err := s.DB.SaveUsageStatement(st); err != nil {
+		return nil, err
+	}
+	return s.DB.GetUsageStatement(wsID, month)
+}
+
+// fetchStatementTokens returns the per-model LLM usage of a workspace in
+// [since, until) from the LLM proxy.
+func (s *Server) fetchStatementTokens(wsID string, since, until time.Time) ([]db.StatementModelUsage, error) {
+	q := url.Values{}
+	q.Set("workspace_id", wsID)
+	q.Set("since", since.Format(time.RFC3339))
+	q.Set("until", until.Format(time.RFC3339))
+	resp, err := http.Get(s.LLMProxyURL + "/internal/usage?" + q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("llmproxy usage: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("llmproxy usage: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Usage []struct {
+			Provider                 string `json:"provider"`
+			Model                    string `json:"model"`
+			InputTokens              int64  `json:"input_tokens"`
+			OutputTokens             int64  `json:"output_tokens"`
+			CacheCreationInputTokens int64  `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64  `json:"cache_read_input_tokens"`
+			RequestCount             int64  `json:"request_count"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("llmproxy usage: decode: %w", err)
+	}
+	models := make([]db.StatementModelUsage, 0, len(body.Usage))
+	for _, u := range body.Usage {
+		models = append(models, db.StatementModelUsage{
+			Provider:     u.Provider,
+			Model:        u.Model,
+			InputTokens:  u.InputTokens,
+			OutputTokens: u.OutputTokens,
+			CacheTokens:  u.CacheCreationInputTokens + u.CacheReadInputTokens,
+			Requests:     u.RequestCount,
+		})
+	}
+	return models, nil
+}
+
+func statementJSON(st *db.UsageStatement) map[string]interface{} {
+	resp := map[string]interface{}{
+		"month":           st.Month.Format(statementMonthLayout),
+		"input_tokens":    st.InputTokens,
+		"output_tokens":   st.OutputTokens,
+		"cache_tokens":    st.CacheTokens,
+		"llm_requests":    st.LLMRequests,
+		"models":          st.Models,
+		"compute_hours":   st.ComputeHours,
+		"cpu_hours":       st.CPUHours,
+		"memory_gb_hours": st.MemoryGBHours,
+		"storage_bytes":   st.StorageBytes,
+		"generated_at":    st.GeneratedAt.Format(time.RFC3339),
+	}
+	if st.EmailedAt != nil {
+		resp["emailed_at"] = st.EmailedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// statementRows are the line items of a statement, shared by the CSV and PDF
+// renderings.
+func statementRows(st *db.UsageStatement) [][3]string {
+	i := func(n int64) string { return strconv.FormatInt(n, 10) }
+	f := func(n float64) string { return strconv.FormatFloat(n, 'f', 2, 64) }
+	rows := [][3]string{
+		{"llm_requests", i(st.LLMRequests), "requests"},
+		{"input_tokens", i(st.InputTokens), "tokens"},
+		{"output_tokens", i(st.OutputTokens), "tokens"},
+		{"cache_tokens", i(st.CacheTokens), "tokens"},
+		{"compute_hours", f(st.ComputeHours), "sandbox-hours"},
+		{"cpu_hours", f(st.CPUHours), "vCPU-hours"},
+		{"memory_gb_hours", f(st.MemoryGBHours), "GiB-hours"},
+		{"storage", i(st.StorageBytes), "bytes"},
+	}
+	for _, m := range st.Models {
+		name := m.Provider + "/" + m.Model
+		rows = append(rows,
+			[3]string{"llm_requests:" + name, i(m.Requests), "requests"},
+			[3]string{"input_tokens:" + name, i(m.InputTokens), "tokens"},
+			[3]string{"output_tokens:" + name, i(m.OutputTokens), "tokens"},
+			[3]string{"cache_tokens:" + name, i(m.CacheTokens), "tokens"},
+		)
+	}
+	return rows
+}
+
+func writeStatementCSV(w io.Writer, workspaceName string, st *db.UsageStatement) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"workspace", workspaceName, ""})
+	cw.Write([]string{"month", st.Month.Format(statementMonthLayout), ""})
+	cw.Write([]string{"item", "quantity", "unit"})
+	for _, row := range statementRows(st) {
+		cw.Write(row[:])
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func renderStatementPDF(workspaceName string, st *db.UsageStatement) []byte {
+	lines := []string{
+		"Usage statement",
+		"Workspace: " + workspaceName,
+		"Month: " + st.Month.Format(statementMonthLayout),
+		"Generated: " + st.GeneratedAt.UTC().Format(time.RFC3339),
+		"",
+	}
+	for _, row := range statementRows(st) {
+		lines = append(lines, fmt.Sprintf("%-56s %16s %s", row[0], row[1], row[2]))
+	}
+	return renderTextPDF(lines)
+}
+
+// renderTextPDF lays out lines of monospaced text on A4 pages as a minimal
+// PDF document.
+func renderTextPDF(lines []string) []byte {
+	const perPage = 60
+	var pages [][]string
+	for len(lines) > perPage {
+		pages = append(pages, lines[:perPage])
+		lines = lines[perPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and its content
+	// stream per page.
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	)
+	for i, page := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 9 Tf 12 TL 40 800 Td\n")
+		for _, line := range page {
+			content.WriteString("(" + pdfEscape(line) + ") Tj T*\n")
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfEscape escapes a string for a PDF literal string, replacing characters
+// outside printable ASCII, which the standard fonts can't show.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// GET /api/workspaces/{id}/statements
+func (s *Server) handleListUsageStatements(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	statements, err := s.DB.ListUsageStatements(wsID)
+	if err != nil {
+		log.Printf("failed to list usage statements of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to list statements", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]map[string]interface{}, 0, len(statements))
+	for _, st := range statements {
+		resp = append(resp, statementJSON(st))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"statements": resp})
+}
+
+// GET /api/workspaces/{id}/statements/{month}?format=json|csv|pdf
+func (s *Server) handleGetUsageStatement(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	month, err := parseStatementMonth(chi.URLParam(r, "month"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	st, err := s.DB.GetUsageStatement(wsID, month)
+	if err != nil {
+		log.Printf("failed to get usage statement %s of workspace %s: %v", month.Format(statementMonthLayout), wsID, err)
+		http.Error(w, "failed to get statement", http.StatusInternalServerError)
+		return
+	}
+	if st == nil {
+		http.Error(w, "statement not found", http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statementJSON(st))
+		return
+	}
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil || ws == nil {
+		log.Printf("failed to get workspace %s: %v", wsID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("statement-%s-%s.%s", shortID(wsID), month.Format(statementMonthLayout), format)
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		writeStatementCSV(w, ws.Name, st)
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.Write(renderStatementPDF(ws.Name, st))
+	default:
+		http.Error(w, "format must be json, csv or pdf", http.StatusBadRequest)
+	}
+}
+
+// POST /api/workspaces/{id}/statements/{month}/generate
+func (s *Server) handleGenerateUsageStatement(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	month, err := parseStatementMonth(chi.URLParam(r, "month"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if month.After(time.Now()) {
+		http.Error(w, "month is in the future", http.StatusBadRequest)
+		return
+	}
+	st, err := s.generateUsageStatement(wsID, month)
+	if err != nil {
+		log.Printf("failed to generate usage statement %s of workspace %s: %v", month.Format(statementMonthLayout), wsID, err)
+		http.Error(w, "failed to generate statement", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statementJSON(st))
+}
+
+// POST /api/workspaces/{id}/statements/{month}/email
+func (s *Server) handleEmailUsageStatement(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner") {
+		return
+	}
+	if s.StatementMailer == nil {
+		http.Error(w, "email delivery is not configured", http.StatusNotImplemented)
+		return
+	}
+	month, err := parseStatementMonth(chi.URLParam(r, "month"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	st, err := s.DB.GetUsageStatement(wsID, month)
+	if err != nil {
+		log.Printf("failed to get usage statement %s of workspace %s: %v", month.Format(statementMonthLayout), wsID, err)
+		http.Error(w, "failed to get statement", http.StatusInternalServerError)
+		return
+	}
+	if st == nil {
+		http.Error(w, "statement not found", http.StatusNotFound)
+		return
+	}
+	if err := s.emailUsageStatement(st); err != nil {
+		log.Printf("failed to email usage statement %s of workspace %s: %v", month.Format(statementMonthLayout), wsID, err)
+		http.Error(w, "failed to send statement", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// emailUsageStatement sends a statement, with its PDF attached, to the owners
+// of its workspace.
+func (s *Server) emailUsageStatement(st *db.UsageStatement) error {
+	ws, err := s.DB.GetWorkspace(st.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	if ws == nil {
+		return fmt.Errorf("workspace %s not found", st.WorkspaceID)
+	}
+	to, err := s.workspaceOwnerEmails(st.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("workspace has no owner with an email address")
+	}
+
+	month := st.Month.Format(statementMonthLayout)
+	var body strings.Builder
+	fmt.Fprintf(&body, "Usage statement for workspace %s, %s:\n\n", ws.Name, month)
+	for _, row := range statementRows(st)[:8] {
+		fmt.Fprintf(&body, "  %-16s %16s %s\n", row[0], row[1], row[2])
+	}
+	body.WriteString("\nThe full statement is attached.\n")
+	err = s.StatementMailer.Send(to, fmt.Sprintf("Usage statement %s: %s", month, ws.Name), body.String(),
+		fmt.Sprintf("statement-%s.pdf", month), renderStatementPDF(ws.Name, st))
+	if err != nil {
+		return err
+	}
+	return s.DB.MarkUsageStatementEmailed(st.WorkspaceID, st.Month)
+}
+
+// StartStatementLoop is the exported entry point for the server's main
+// lifecycle to launch the statement loop in a goroutine.
+func (s *Server) StartStatementLoop(ctx context.Context, every time.Duration) {
+	s.startStatementLoop(ctx, every)
+}
+
+// startStatementLoop ticks every `every` and generates last month's statement
+// for every workspace that doesn't have one yet, emailing it when a mailer is
+// configured. Returns when ctx is cancelled.
+func (s *Server) startStatementLoop(ctx context.Context, every time.Duration) {
+	if every <= 0 {
+		every = time.Hour
+	}
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		s.runStatementsOnce(previousMonth(time.Now()))
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *Server) runStatementsOnce(month time.Time) {
+	workspaces, err := s.DB.ListAllWorkspaces()
+	if err != nil {
+		log.Printf("usage statements: list workspaces: %v", err)
+		return
+	}
+	for _, ws := range workspaces {
+		if ws.CreatedAt.After(month.AddDate(0, 1, 0)) {
+			continue
+		}
+		st, err := s.DB.GetUsageStatement(ws.ID, month)
+		if err != nil {
+			log.Printf("usage statements: get %s of workspace %s: %v", month.Format(statementMonthLayout), ws.ID, err)
+			continue
+		}
+		if st == nil {
+			if st, err = s.generateUsageStatement(ws.ID, month); err != nil {
+				log.Printf("usage statements: generate %s of workspace %s: %v", month.Format(statementMonthLayout), ws.ID, err)
+				continue
+			}
+		}
+		if s.StatementMailer != nil && st.EmailedAt == nil {
+			if err := s.emailUsageStatement(st); err != nil {
+				log.Printf("usage statements: email %s of workspace %s: %v", month.Format(statementMonthLayout), ws.ID, err)
+			}
+		}
+	}
+}
+
+// Mailer sends email through an SMTP relay.
+type Mailer struct {
+	Addr     string // host:port
+	From     string
+	Username string // optional; enables PLAIN auth
+	Password string
+}
+
+// Send sends a plain-text message with one attachment, or none if
+// attachment is empty.
+func (m *Mailer) Send(to []string, subject, body, attachmentName string, attachment []byte) error {
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		m.From, strings.Join(to, ", "), subject, mw.Boundary())
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return err
+	}
+	io.WriteString(part, body)
+	if len(attachment) > 0 {
+		part, err = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"application/pdf"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {`attachment; filename="` + attachmentName + `"`},
+		})
+		if err != nil {
+			return err
+		}
+		encoded := base64.StdEncoding.EncodeToString(attachment)
+		for len(encoded) > 76 {
+			io.WriteString(part, encoded[:76]+"\r\n")
+			encoded = encoded[76:]
+		}
+		io.WriteString(part, encoded)
+	}
+	mw.Close()
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, to, msg.Bytes())
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+)
+
+func testStatement() *db.UsageStatement {
+	return &db.UsageStatement{
+		WorkspaceID:  "ws-1",
+		Month:        time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+		InputTokens:  1500,
+		OutputTokens: 300,
+		CacheTokens:  20,
+		LLMRequests:  7,
+		Models: []db.StatementModelUsage{
+			{Provider: "anthropic", Model: "claude-sonnet-4", InputTokens: 1500, OutputTokens: 300, CacheTokens: 20, Requests: 7},
+		},
+		ComputeHours:  12.5,
+		CPUHours:      25,
+		MemoryGBHours: 50.25,
+		StorageBytes:  10 << 30,
+		GeneratedAt:   time.Date(2026, 10, 1, 0, 5, 0, 0, time.UTC),
+	}
+}
+
+func TestParseStatementMonth(t *testing.T) {
+	m, err := parseStatementMonth("2026-09")
+	if err != nil || !m.Equal(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("parseStatementMonth = %v, %v", m, err)
+	}
+	for _, bad := range []string{"", "2026-13", "2026-09-01", "sept"} {
+		if _, err := parseStatementMonth(bad); err == nil {
+			t.Errorf("parseStatementMonth(%q) succeeded", bad)
+		}
+	}
+	if got := previousMonth(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("previousMonth = %v", got)
+	}
+}
+
+func TestWriteStatementCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeStatementCSV(&buf, "Team, A", testStatement()); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0][1] != "Team, A" || records[1][1] != "2026-09" {
+		t.Errorf("header = %v", records[:2])
+	}
+	got := map[string]string{}
+	for _, rec := range records[3:] {
+		got[rec[0]] = rec[1]
+	}
+	for item, want := range map[string]string{
+		"input_tokens":                           "1500",
+		"compute_hours":                          "12.50",
+		"memory_gb_hours":                        "50.25",
+		"storage":                                "10737418240",
+		"llm_requests:anthropic/claude-sonnet-4": "7",
+	} {
+		if got[item] != want {
+			t.Errorf("%s = %q, want %q", item, got[item], want)
+		}
+	}
+}
+
+func TestRenderStatementPDF(t *testing.T) {
+	pdf := renderStatementPDF("Team (A)", testStatement())
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF document")
+	}
+	if !bytes.Contains(pdf, []byte(`(Workspace: Team \(A\)) Tj`)) {
+		t.Error("workspace name missing or unescaped")
+	}
+
+	// startxref must point at the xref table, and every entry at its object.
+	s := string(pdf)
+	i := strings.LastIndex(s, "startxref\n")
+	xref, _ := strconv.Atoi(strings.Fields(s[i+len("startxref\n"):])[0])
+	if !strings.HasPrefix(s[xref:], "xref\n") {
+		t.Fatalf("startxref %d doesn't point at xref", xref)
+	}
+	entries := strings.Split(s[xref:], "\n")[3:]
+	for n := 1; n <= 5; n++ {
+		off, _ := strconv.Atoi(entries[n-1][:10])
+		if !strings.HasPrefix(s[off:], fmt.Sprintf("%d 0 obj", n)) {
+			t.Errorf("xref entry for object %d points at %q", n, s[off:off+10])
+		}
+	}
+}
+
+func TestRenderTextPDFPages(t *testing.T) {
+	lines := make([]string, 130)
+	if pdf := string(renderTextPDF(lines)); !strings.Contains(pdf, "/Count 3") {
+		t.Error("130 lines should take 3 pages")
+	}
+}
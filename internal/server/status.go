@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+const (
+	componentOperational = "operational"
+	componentDown        = "down"
+
+	// statusCacheTTL bounds how often the public status page probes the
+	// platform's dependencies, since anyone can request it.
+	statusCacheTTL     = 10 * time.Second
+	statusProbeTimeout = 2 * time.Second
+)
+
+type statusComponent struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type platformStatus struct {
+	Status     string            `json:"status"`
+	Components []statusComponent `json:"components"`
+	CheckedAt  time.Time         `json:"checked_at"`
+}
+
+// currentStatus reports the health of each platform component. Only
+// "operational" or "down" is exposed; errors are logged, not returned.
+func (s *Server) currentStatus(ctx context.Context) platformStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	if time.Since(s.statusAt) < statusCacheTTL {
+		return s.statusRes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, statusProbeTimeout)
+	defer cancel()
+
+	res := platformStatus{Status: componentOperational, CheckedAt: time.Now().UTC()}
+	add := func(name string, healthy bool) {
+		c := statusComponent{Name: name, Status: componentOperational}
+		if !healthy {
+			c.Status = componentDown
+			res.Status = "degraded"
+		}
+		res.Components = append(res.Components, c)
+	}
+
+	add("api", true)
+	add("database", s.DB.PingContext(ctx) == nil)
+	add("storage", s.StorageReport.Healthy())
+	if s.LLMProxyURL != "" {
+		add("llm_proxy", probeHealthz(ctx, s.LLMProxyURL))
+	}
+	if s.IMBridgeURL != "" {
+		add("im_bridge", probeHealthz(ctx, s.IMBridgeURL))
+	}
+
+	s.statusRes = res
+	s.statusAt = time.Now()
+	return res
+}
+
+// probeHealthz reports whether baseURL/healthz answers with 2xx.
+func probeHealthz(ctx context.Context, baseURL string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/healthz", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	res := s.currentStatus(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) handleStatusPage(w http.ResponseWriter, r *http.Request) {
+	res := s.currentStatus(r.Context())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	statusTemplate.Execute(w, res)
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="30">
+<title>Platform status</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.banner { padding: .75rem 1rem; border-radius: 6px; margin-bottom: 1.5rem; }
+.operational { background: #e6f4ea; color: #1e6b34; }
+.degraded, .down { background: #fdecea; color: #a12622; }
+table { width: 100%; border-collapse: collapse; }
+td { padding: .5rem 0; border-bottom: 1px solid #eee; }
+td:last-child { text-align: right; }
+footer { margin-top: 1.5rem; color: #888; font-size: .85rem; }
+</style>
+</head>
+<body>
+<h1>Platform status</h1>
+<div class="banner {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else}}Some components are down{{end}}</div>
+<table>
+{{range .Components}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td></tr>
+{{end}}</table>
+<p>If the platform is operational but your sandbox is not responding, check <code>/__status</code> on the sandbox's own address.</p>
+<footer>Checked {{.CheckedAt.Format "2006-01-02 15:04:05 MST"}}</footer>
+</body>
+</html>
+`))
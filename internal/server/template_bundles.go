@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/templatebundle"
+	"github.com/go-chi/chi/v5"
+	"sigs.k8s.io/yaml"
+)
+
+// maxTemplateBundleSize bounds an imported bundle document.
+const maxTemplateBundleSize = 1 << 20
+
+// initScriptTimeout bounds each init script of a template bundle.
+const initScriptTimeout = 10 * time.Minute
+
+// sandboxTemplateKey is the sandbox metadata key holding the name of the
+// imported template bundle it was created from.
+const sandboxTemplateKey = "template_bundle"
+
+// importedTemplate returns an imported bundle, or nil if there is none.
+func (s *Server) importedTemplate(name string) (*templatebundle.Bundle, error) {
+	row, err := s.DB.GetTemplateBundle(name)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	var b templatebundle.Bundle
+	if err := json.Unmarshal(row.Bundle, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// trustedTemplateKeys returns the keys imports may be signed with: the
+// configured trusted keys and this instance's own signing key.
+func (s *Server) trustedTemplateKeys() map[string]ed25519.PublicKey {
+	keys := make(map[string]ed25519.PublicKey, len(s.TemplateTrustedKeys)+1)
+	for id, k := range s.TemplateTrustedKeys {
+		keys[id] = k
+	}
+	if s.TemplateSigningKey != nil {
+		keys[s.TemplateSigningKeyID] = s.TemplateSigningKey.Public().(ed25519.PublicKey)
+	}
+	return keys
+}
+
+// GET /api/sandbox-templates/{name}/export?format=yaml returns a template
+// as a bundle. Policy file templates are signed with this instance's key
+// when one is configured; imported bundles are exported as imported, with
+// their original signature.
+func (s *Server) handleExportSandboxTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	var b *templatebundle.Bundle
+	if t := s.Policy.Get().Template(name); t != nil {
+		b = templatebundle.FromTemplate(*t)
+		if s.TemplateSigningKey != nil {
+			if err := b.Sign(s.TemplateSigningKeyID, s.TemplateSigningKey); err != nil {
+				log.Printf("failed to sign template bundle %s: %v", name, err)
+				http.Error(w, "failed to export template", http.StatusInternalServerError)
+				return
+			}
+		}
+	} else {
+		var err error
+		b, err = s.importedTemplate(name)
+		if err != nil {
+			log.Printf("failed to load template bundle %s: %v", name, err)
+			http.Error(w, "failed to export template", http.StatusInternalServerError)
+			return
+		}
+		if b == nil {
+			http.Error(w, "template not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	ext := ".json"
+	contentType := "application/json"
+	if err == nil && r.URL.Query().Get("format") == "yaml" {
+		data, err = yaml.JSONToYAML(data)
+		ext, contentType = ".yaml", "application/yaml"
+	}
+	if err != nil {
+		log.Printf("failed to encode template bundle %s: %v", name, err)
+		http.Error(w, "failed to export template", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+ext+`"`)
+	w.Write(data)
+}
+
+// POST /api/admin/sandbox-templates/import takes a bundle (JSON or YAML)
+// and makes it available as a sandbox template. The bundle must be signed
+// by a trusted key unless unsigned imports are allowed; re-importing a
+// name replaces the earlier bundle.
+func (s *Server) handleAdminImportTemplateBundle(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxTemplateBundleSize+1))
+	if err != nil {
+		http.Error(w, "failed to read bundle", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxTemplateBundleSize {
+		http.Error(w, "bundle too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	b, err := templatebundle.Parse(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pol := s.Policy.Get()
+	if pol.Template(b.Metadata.Name) != nil {
+		http.Error(w, "a policy file template is named "+b.Metadata.Name, http.StatusConflict)
+		return
+	}
+	if !pol.TypeAllowed(b.Spec.Type) {
+		http.Error(w, "sandbox type "+b.Spec.Type+" is not allowed by policy", http.StatusForbidden)
+		return
+	}
+	if b.Spec.Image != "" && !pol.ImageAllowed(b.Spec.Image) {
+		http.Error(w, "image "+b.Spec.Image+" is not allowed by policy", http.StatusForbidden)
+		return
+	}
+	var signedBy string
+	switch err := b.Verify(s.trustedTemplateKeys()); {
+	case err == nil:
+		signedBy = b.Signature.KeyID
+	case errors.Is(err, templatebundle.ErrUnsigned) && s.AllowUnsignedTemplates:
+	case errors.Is(err, templatebundle.ErrUnsigned):
+		http.Error(w, "unsigned bundles are not accepted", http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, "signature verification failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := json.Marshal(b)
+	if err != nil {
+		http.Error(w, "failed to import bundle", http.StatusInternalServerError)
+		return
+	}
+	actorID := auth.UserIDFromContext(r.Context())
+	if err := s.DB.UpsertTemplateBundle(b.Metadata.Name, b.Metadata.Version, stored, signedBy, actorID); err != nil {
+		log.Printf("failed to store template bundle %s: %v", b.Metadata.Name, err)
+		http.Error(w, "failed to import bundle", http.StatusInternalServerError)
+		return
+	}
+	severity := SecuritySeverityInfo
+	if signedBy == "" {
+		severity = SecuritySeverityWarning
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventTemplateImported,
+		Severity: severity,
+		TargetID: b.Metadata.Name,
+		Details:  map[string]interface{}{"version": b.Metadata.Version, "signed_by": signedBy, "image": b.Spec.Image},
+	})
+	log.Printf("template bundle %s (version %q, signed by %q) imported by %s", b.Metadata.Name, b.Metadata.Version, signedBy, actorID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(templateBundleResponse(b, signedBy, actorID, time.Now()))
+}
+
+type templateBundleSummary struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Type        string    `json:"type"`
+	Image       string    `json:"image,omitempty"`
+	InitScripts int       `json:"init_scripts"`
+	Secrets     []string  `json:"secrets"`
+	SignedBy    string    `json:"signed_by,omitempty"`
+	ImportedBy  string    `json:"imported_by,omitempty"`
+	ImportedAt  time.Time `json:"imported_at"`
+}
+
+func templateBundleResponse(b *templatebundle.Bundle, signedBy, importedBy string, importedAt time.Time) templateBundleSummary {
+	secrets := make([]string, len(b.Spec.Secrets))
+	for i, sec := range b.Spec.Secrets {
+		secrets[i] = sec.Name
+	}
+	return templateBundleSummary{
+		Name:        b.Metadata.Name,
+		Version:     b.Metadata.Version,
+		Description: b.Metadata.Description,
+		Type:        b.Spec.Type,
+		Image:       b.Spec.Image,
+		InitScripts: len(b.Spec.InitScripts),
+		Secrets:     secrets,
+		SignedBy:    signedBy,
+		ImportedBy:  importedBy,
+		ImportedAt:  importedAt,
+	}
+}
+
+// GET /api/admin/sandbox-templates/imported lists the imported bundles.
+func (s *Server) handleAdminListTemplateBundles(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.DB.ListTemplateBundles()
+	if err != nil {
+		log.Printf("failed to list template bundles: %v", err)
+		http.Error(w, "failed to list template bundles", http.StatusInternalServerError)
+		return
+	}
+	resp := []templateBundleSummary{}
+	for _, row := range rows {
+		var b templatebundle.Bundle
+		if err := json.Unmarshal(row.Bundle, &b); err != nil {
+			log.Printf("skipping unreadable template bundle %s: %v", row.Name, err)
+			continue
+		}
+		resp = append(resp, templateBundleResponse(&b, row.SignedBy, row.ImportedBy, row.ImportedAt))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DELETE /api/admin/sandbox-templates/{name} removes an imported bundle.
+// Sandboxes created from it are not affected.
+func (s *Server) handleAdminDeleteTemplateBundle(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	ok, err := s.DB.DeleteTemplateBundle(name)
+	if err != nil {
+		log.Printf("failed to delete template bundle %s: %v", name, err)
+		http.Error(w, "failed to delete template bundle", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "template bundle not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runTemplateInitScripts runs the init scripts of the template bundle a
+// new sandbox was created from, in order, stopping at the first failure.
+// Failures are logged; the sandbox keeps running.
+func (s *Server) runTemplateInitScripts(sandboxID string) {
+	sbx, ok := s.Sandboxes.Get(sandboxID)
+	if !ok {
+		return
+	}
+	name := sbx.MetadataString(sandboxTemplateKey)
+	if name == "" {
+		return
+	}
+	execer, ok := s.ProcessManager.(interface {
+		ExecSimple(ctx context.Context, sandboxID string, command []string) (string, error)
+	})
+	if !ok {
+		return
+	}
+	b, err := s.importedTemplate(name)
+	if err != nil {
+		log.Printf("sandbox %s: failed to load template bundle %s: %v", sandboxID, name, err)
+		return
+	}
+	if b == nil {
+		log.Printf("sandbox %s: template bundle %s was deleted; skipping init scripts", sandboxID, name)
+		return
+	}
+	for _, sc := range b.Spec.InitScripts {
+		ctx, cancel := context.WithTimeout(context.Background(), initScriptTimeout)
+		out, err := execer.ExecSimple(ctx, sandboxID, []string{"sh", "-c", sc.Run})
+		cancel()
+		if err != nil {
+			log.Printf("sandbox %s: template %s init script %q failed: %v (output: %s)", sandboxID, name, sc.Name, err, out)
+			return
+		}
+		log.Printf("sandbox %s: template %s init script %q done", sandboxID, name, sc.Name)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/go-chi/chi/v5"
+)
+
+// postStartHookTimeout bounds each post-start hook of a workspace template.
+const postStartHookTimeout = 10 * time.Minute
+
+// maxHookOutputBytes bounds the output kept of a hook run; longer output
+// keeps its tail.
+const maxHookOutputBytes = 64 << 10
+
+// hookExitMarker precedes the exit status the hook wrapper prints after the
+// hook's combined output. The exec itself succeeds whatever the hook's
+// status, so failing hooks keep their output.
+const hookExitMarker = "__agentserver_hook_exit="
+
+// hookCommand runs script with stderr merged into stdout and appends its
+// exit status.
+func hookCommand(script string) []string {
+	return []string{"sh", "-c", `sh -c "$1" 2>&1; status=$?; echo; echo "` + hookExitMarker + `$status"`, "hook", script}
+}
+
+// parseHookOutput splits the output of hookCommand into the hook's output
+// and exit status. ok is false if the status is missing, e.g. because the
+// exec was cut short.
+func parseHookOutput(out string) (output string, status int, ok bool) {
+	i := strings.LastIndex(out, "\n"+hookExitMarker)
+	if i < 0 {
+		return out, 0, false
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(out[i+1+len(hookExitMarker):]))
+	if err != nil {
+		return out, 0, false
+	}
+	return out[:i], status, true
+}
+
+// runPostStartHooks runs the post-start hooks of the workspace template a
+// sandbox was created from, in order, after it starts or resumes (trigger
+// "start" or "resume"). A failing hook stops the rest. Every run is
+// recorded with its output; the sandbox keeps running either way.
+func (s *Server) runPostStartHooks(sandboxID, trigger string) {
+	sbx, ok := s.Sandboxes.Get(sandboxID)
+	if !ok {
+		return
+	}
+	templateID := sbx.MetadataString(workspaceTemplateKey)
+	if templateID == "" {
+		return
+	}
+	execer, ok := s.ProcessManager.(interface {
+		ExecSimple(ctx context.Context, sandboxID string, command []string) (string, error)
+	})
+	if !ok {
+		return
+	}
+	t, err := s.DB.GetSandboxTemplate(sbx.WorkspaceID, templateID)
+	if err != nil {
+		log.Printf("sandbox %s: failed to load template %s: %v", sandboxID, templateID, err)
+		return
+	}
+	if t == nil || len(t.PostStartHooks) == 0 {
+		return
+	}
+	for _, hook := range t.PostStartHooks {
+		run := &db.SandboxHookRun{
+			SandboxID:  sandboxID,
+			TemplateID: t.ID,
+			Hook:       hook.Name,
+			Trigger:    trigger,
+			StartedAt:  time.Now(),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), postStartHookTimeout)
+		out, err := execer.ExecSimple(ctx, sandboxID, hookCommand(hook.Run))
+		cancel()
+		run.FinishedAt = time.Now()
+		output, status, ok := parseHookOutput(out)
+		switch {
+		case err != nil:
+			run.Error = err.Error()
+		case !ok:
+			run.Error = "hook exit status missing"
+		case status != 0:
+			run.Error = fmt.Sprintf("exit status %d", status)
+		default:
+			run.Success = true
+		}
+		if len(output) > maxHookOutputBytes {
+			output = output[len(output)-maxHookOutputBytes:]
+		}
+		run.Output = strings.ToValidUTF8(output, "�")
+		if err := s.DB.CreateSandboxHookRun(run); err != nil {
+			log.Printf("sandbox %s: failed to record hook %q: %v", sandboxID, hook.Name, err)
+		}
+		if !run.Success {
+			log.Printf("sandbox %s: template %s post-start hook %q failed: %s", sandboxID, t.Name, hook.Name, run.Error)
+			return
+		}
+		log.Printf("sandbox %s: template %s post-start hook %q done", sandboxID, t.Name, hook.Name)
+	}
+}
+
+// GET /api/sandboxes/{id}/hook-runs lists the sandbox's post-start hook
+// runs with their output, newest first.
+func (s *Server) handleListSandboxHookRuns(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := s.requireWorkspaceMember(w, r, sbx.WorkspaceID); !ok {
+		return
+	}
+	runs, err := s.DB.ListSandboxHookRuns(id)
+	if err != nil {
+		log.Printf("failed to list hook runs of sandbox %s: %v", id, err)
+		http.Error(w, "failed to list hook runs", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/wsbridge"
+	"github.com/go-chi/chi/v5"
+	"nhooyr.io/websocket"
+)
+
+// terminalOpener is implemented by backends that can start an interactive
+// TTY process in a sandbox besides its session process (K8s exec, Docker
+// exec).
+type terminalOpener interface {
+	OpenTerminal(ctx context.Context, sandboxID string, command []string) (process.Process, error)
+}
+
+// terminalCommand starts a login shell, bash if the image has it.
+var terminalCommand = []string{"env", "TERM=xterm-256color", "sh", "-c",
+	"if command -v bash >/dev/null 2>&1; then exec bash -l; else exec sh -l; fi"}
+
+// terminalActivityInterval throttles the idle-timer resets caused by
+// terminal input.
+const terminalActivityInterval = time.Minute
+
+// terminalControl is a text frame from the client: a resize, or input for
+// clients that don't send binary frames.
+type terminalControl struct {
+	Type string `json:"type"` // "resize" or "input"
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+	Data string `json:"data,omitempty"`
+}
+
+// applyTerminalControl applies a text frame to the terminal process.
+func applyTerminalControl(proc process.Process, frame []byte) error {
+	var c terminalControl
+	if err := json.Unmarshal(frame, &c); err != nil {
+		return fmt.Errorf("invalid control frame: %w", err)
+	}
+	switch c.Type {
+	case "resize":
+		if c.Cols == 0 || c.Rows == 0 {
+			return fmt.Errorf("resize needs cols and rows")
+		}
+		return proc.Resize(c.Rows, c.Cols)
+	case "input":
+		_, err := proc.Write([]byte(c.Data))
+		return err
+	default:
+		return fmt.Errorf("unknown control frame type %q", c.Type)
+	}
+}
+
+// GET /api/sandboxes/{id}/terminal upgrades to a WebSocket bridged to an
+// interactive shell in the sandbox. Binary frames carry terminal input and
+// output; text frames are terminalControl messages. ?cols=&rows= set the
+// initial size. The socket is closed with a normal closure when the shell
+// exits.
+func (s *Server) handleSandboxTerminal(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer", "developer") {
+		return
+	}
+	if sbx.IsLocal {
+		http.Error(w, "local sandboxes have no server-side terminal", http.StatusBadRequest)
+		return
+	}
+	if sbx.QuarantinedAt != nil {
+		http.Error(w, "sandbox is quarantined", http.StatusForbidden)
+		return
+	}
+	if sbx.Status != sbxstore.StatusRunning {
+		http.Error(w, "sandbox is not running", http.StatusConflict)
+		return
+	}
+	opener, ok := s.ProcessManager.(terminalOpener)
+	if !ok {
+		http.Error(w, "terminal is not supported by this backend", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		log.Printf("terminal: websocket accept for sandbox %s: %v", id, err)
+		return
+	}
+	defer conn.CloseNow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	proc, err := opener.OpenTerminal(ctx, id, terminalCommand)
+	if err != nil {
+		log.Printf("terminal: failed to start shell in sandbox %s: %v", id, err)
+		conn.Close(websocket.StatusInternalError, "failed to start terminal")
+		return
+	}
+	q := r.URL.Query()
+	cols, _ := strconv.ParseUint(q.Get("cols"), 10, 16)
+	rows, _ := strconv.ParseUint(q.Get("rows"), 10, 16)
+	if cols > 0 && rows > 0 {
+		proc.Resize(uint16(rows), uint16(cols))
+	}
+	go wsbridge.KeepAlive(ctx, conn, 0)
+	s.Sandboxes.UpdateActivity(id)
+
+	// Output pump; closes the socket when the shell exits.
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := proc.Read(buf)
+			if n > 0 {
+				if werr := conn.Write(ctx, websocket.MessageBinary, buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				conn.Close(websocket.StatusNormalClosure, "process exited")
+				return
+			}
+		}
+	}()
+
+	lastActivity := time.Now()
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		if time.Since(lastActivity) > terminalActivityInterval {
+			s.Sandboxes.UpdateActivity(id)
+			lastActivity = time.Now()
+		}
+		if typ == websocket.MessageBinary {
+			if _, err := proc.Write(data); err != nil {
+				return
+			}
+			continue
+		}
+		if err := applyTerminalControl(proc, data); err != nil {
+			conn.Close(websocket.StatusUnsupportedData, err.Error())
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+)
+
+type fakeTerminal struct {
+	input      bytes.Buffer
+	rows, cols uint16
+}
+
+func (f *fakeTerminal) Read(buf []byte) (int, error)   { return 0, nil }
+func (f *fakeTerminal) Write(data []byte) (int, error) { return f.input.Write(data) }
+func (f *fakeTerminal) Resize(rows, cols uint16) error { f.rows, f.cols = rows, cols; return nil }
+func (f *fakeTerminal) Done() <-chan struct{}          { return nil }
+
+func TestApplyTerminalControl(t *testing.T) {
+	proc := &fakeTerminal{}
+	if err := applyTerminalControl(proc, []byte(`{"type":"resize","cols":120,"rows":40}`)); err != nil {
+		t.Fatal(err)
+	}
+	if proc.rows != 40 || proc.cols != 120 {
+		t.Errorf("size = %dx%d, want 120x40", proc.cols, proc.rows)
+	}
+	if err := applyTerminalControl(proc, []byte(`{"type":"input","data":"ls\n"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if proc.input.String() != "ls\n" {
+		t.Errorf("input = %q", proc.input.String())
+	}
+	for _, bad := range []string{`{"type":"resize","cols":120}`, `{"type":"signal"}`, `ls`} {
+		if err := applyTerminalControl(proc, []byte(bad)); err == nil {
+			t.Errorf("applyTerminalControl(%s) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PUT /api/sandboxes/{id}/tunnel-bandwidth caps a local agent's tunnel, in
+// bytes per second and direction; 0 restores the sandbox-proxy default.
+// The proxy applies it within a heartbeat (20s).
+func (s *Server) handleSetTunnelBandwidth(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	sbx, ok := s.Sandboxes.Get(id)
+	if !ok {
+		http.Error(w, "sandbox not found", http.StatusNotFound)
+		return
+	}
+	if !s.requireWorkspaceRole(w, r, sbx.WorkspaceID, "owner", "maintainer") {
+		return
+	}
+	if !sbx.IsLocal {
+		http.Error(w, "only local agents have tunnels", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		BytesPerSecond int64 `json:"bytes_per_second"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.BytesPerSecond < 0 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := s.DB.SetSandboxTunnelBandwidthLimit(id, req.BytesPerSecond); err != nil {
+		log.Printf("failed to set tunnel bandwidth limit of sandbox %s: %v", id, err)
+		http.Error(w, "failed to update sandbox", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"bytes_per_second": req.BytesPerSecond})
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+// User provisioning for automation, e.g. an IdP sync: admins create users
+// ahead of their first login, disable and re-enable them, and delete them.
+// A disabled user's sessions are deleted, their tokens rejected, logins
+// refused and their sandboxes paused. Deleting a user erases them like an
+// approved erasure request.
+
+const (
+	userStatusActive   = "active"
+	userStatusDisabled = "disabled"
+)
+
+type createUserRequest struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`     // user or admin; default per the admin bootstrap rules
+	Password string `json:"password"` // optional; without one the user logs in through SSO
+	// CreateWorkspace gives the user a default workspace (default true).
+	CreateWorkspace *bool `json:"create_workspace"`
+}
+
+func (req *createUserRequest) validate() error {
+	req.Email = strings.TrimSpace(req.Email)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		return fmt.Errorf("a valid email is required")
+	}
+	if req.Role != "" && req.Role != "user" && req.Role != "admin" {
+		return fmt.Errorf("invalid role: must be 'user' or 'admin'")
+	}
+	return nil
+}
+
+func userStatus(u *db.User) string {
+	if u.DisabledAt != nil {
+		return userStatusDisabled
+	}
+	return userStatusActive
+}
+
+// POST /api/admin/users creates a user.
+func (s *Server) handleAdminCreateUser(w http.ResponseWriter, r *http.Request) {
+	var req createUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := s.DB.GetUserByEmail(req.Email)
+	if err != nil {
+		log.Printf("admin: failed to look up user %s: %v", req.Email, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		http.Error(w, "email already taken", http.StatusConflict)
+		return
+	}
+
+	id := uuid.New().String()
+	if req.Password != "" {
+		err = s.Auth.Register(id, req.Email, req.Password)
+	} else {
+		err = s.DB.CreateUserWithEmail(id, nil, req.Email)
+	}
+	if err != nil {
+		log.Printf("admin: failed to create user %s: %v", req.Email, err)
+		http.Error(w, "failed to create user", http.StatusInternalServerError)
+		return
+	}
+	if req.Role == "" {
+		s.Auth.AssignNewUserRole(id, req.Email)
+	} else if err := s.DB.UpdateUserRole(id, req.Role); err != nil {
+		log.Printf("admin: failed to set role of user %s: %v", id, err)
+	}
+	if req.Name != "" {
+		if err := s.DB.UpdateUserName(id, req.Name); err != nil {
+			log.Printf("admin: failed to set name of user %s: %v", id, err)
+		}
+	}
+	if req.CreateWorkspace == nil || *req.CreateWorkspace {
+		s.createDefaultWorkspace(id)
+	}
+
+	user, err := s.DB.GetUserByID(id)
+	if err != nil || user == nil {
+		log.Printf("admin: failed to get created user %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	severity := SecuritySeverityInfo
+	if user.Role == "admin" {
+		severity = SecuritySeverityCritical
+	}
+	s.recordSecurityEvent(r, &db.SecurityEvent{
+		Type:     SecurityEventUserCreated,
+		Severity: severity,
+		TargetID: id,
+		Details:  map[string]interface{}{"email": user.Email, "role": user.Role},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         user.ID,
+		"email":      user.Email,
+		"name":       user.Name,
+		"role":       user.Role,
+		"status":     userStatus(user),
+		"created_at": user.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+// PUT /api/admin/users/{id}/status {"status": "disabled"} disables a user
+// and pauses their sandboxes; {"status": "active"} enables them again.
+func (s *Server) handleAdminSetUserStatus(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Status != userStatusActive && req.Status != userStatusDisabled {
+		http.Error(w, "status must be 'active' or 'disabled'", http.StatusBadRequest)
+		return
+	}
+	disable := req.Status == userStatusDisabled
+	if disable && userID == auth.UserIDFromContext(r.Context()) {
+		http.Error(w, "you can't disable yourself", http.StatusBadRequest)
+		return
+	}
+	found, err := s.DB.SetUserDisabled(userID, disable)
+	if err != nil {
+		log.Printf("admin: failed to set status of user %s: %v", userID, err)
+		http.Error(w, "failed to update user status", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	paused := 0
+	ev := &db.SecurityEvent{Type: SecurityEventUserEnabled, Severity: SecuritySeverityInfo, TargetID: userID}
+	if disable {
+		paused = s.pauseUserSandboxes(userID)
+		ev.Type, ev.Severity = SecurityEventUserDisabled, SecuritySeverityWarning
+		ev.Details = map[string]interface{}{"paused_sandboxes": paused}
+		log.Printf("admin: disabled user %s, pausing %d sandboxes", userID, paused)
+	}
+	s.recordSecurityEvent(r, ev)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":               userID,
+		"status":           req.Status,
+		"paused_sandboxes": paused,
+	})
+}
+
+// pauseUserSandboxes pauses the running sandboxes a user created and those
+// of the workspaces they are the only member of. Pinned ones are paused
+// too: a pin only keeps a sandbox from being paused for idleness. It
+// returns how many it started pausing.
+func (s *Server) pauseUserSandboxes(userID string) int {
+	ids, err := s.DB.ListUserSandboxIDs(userID)
+	if err != nil {
+		log.Printf("failed to list sandboxes of user %s: %v", userID, err)
+		return 0
+	}
+	n := 0
+	for _, id := range ids {
+		sbx, ok := s.Sandboxes.Get(id)
+		if !ok || sbx.IsLocal || sbx.Status != sbxstore.StatusRunning {
+			continue
+		}
+		if err := s.Sandboxes.UpdateStatus(id, sbxstore.StatusPausing); err != nil {
+			log.Printf("failed to set pausing status for %s: %v", id, err)
+			continue
+		}
+		go s.pauseSandbox(id)
+		n++
+	}
+	return n
+}
+
+// DELETE /api/admin/users/{id} {"reason": "..."} erases a user: an erasure
+// request is filed on their behalf, unless one is pending, and approved.
+// Like an approval, it fails with 409 while the user is the only owner of
+// a shared workspace or has a pinned sandbox; the request then stays
+// pending.
+func (s *Server) handleAdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	adminID := auth.UserIDFromContext(r.Context())
+	userID := chi.URLParam(r, "id")
+	if userID == adminID {
+		http.Error(w, "you can't delete yourself", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	user, err := s.DB.GetUserByID(userID)
+	if err != nil {
+		log.Printf("admin: failed to get user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	er, err := s.DB.LatestUserErasureRequest(userID)
+	if err != nil {
+		log.Printf("admin: failed to get erasure request of user %s: %v", userID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if er == nil || er.Status != "pending" {
+		reason := strings.TrimSpace(req.Reason)
+		if reason == "" {
+			reason = "deleted by an admin"
+		}
+		er, err = s.DB.CreateUserErasureRequest(userID, user.Email, reason)
+		if err != nil {
+			log.Printf("admin: failed to create erasure request of user %s: %v", userID, err)
+			http.Error(w, "failed to delete user", http.StatusInternalServerError)
+			return
+		}
+		s.recordSecurityEvent(r, &db.SecurityEvent{
+			Type:     SecurityEventErasureRequested,
+			Severity: SecuritySeverityWarning,
+			TargetID: userID,
+			Details:  map[string]interface{}{"request_id": er.ID},
+		})
+	}
+	s.eraseUser(w, r, er, adminID)
+}
@@ -0,0 +1,85 @@
+//go:build integration
+
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+// pauseBackend records the sandboxes it pauses and fails to pause those in
+// fail. Other Manager methods are not used.
+type pauseBackend struct {
+	process.Manager
+	fail map[string]bool
+
+	mu     sync.Mutex
+	paused []string
+}
+
+func (b *pauseBackend) Pause(id string) error {
+	if b.fail[id] {
+		return errors.New("pause failed")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused = append(b.paused, id)
+	return nil
+}
+
+// waitForStatus waits for a sandbox paused in the background to reach
+// status.
+func waitForStatus(t *testing.T, s *Server, id, status string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sbx, ok := s.Sandboxes.Get(id)
+		if ok && sbx.Status == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sandbox %s did not reach %s (%+v)", id, status, sbx)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestIntegration_PauseUserSandboxesIncludesPinned checks that disabling a
+// user pauses their pinned sandboxes too.
+func TestIntegration_PauseUserSandboxesIncludesPinned(t *testing.T) {
+	d := testenv.DB(t)
+	backend := &pauseBackend{}
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d), ProcessManager: backend}
+
+	wsID := uuid.NewString()
+	userID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, userID, "owner")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	})
+	pinned, plain := uuid.NewString(), uuid.NewString()
+	for _, id := range []string{pinned, plain} {
+		if err := d.CreateSandbox(id, wsID, "dev", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
+	}
+	if err := d.SetSandboxPinned(pinned, true, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.pauseUserSandboxes(userID); n != 2 {
+		t.Fatalf("pauseUserSandboxes = %d, want 2", n)
+	}
+	waitForStatus(t, s, pinned, sbxstore.StatusPaused)
+	waitForStatus(t, s, plain, sbxstore.StatusPaused)
+}
@@ -58,13 +58,51 @@ func (s *Server) handleValidateProxyToken(w http.ResponseWriter, r *http.Request
 			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
+		if sbx.QuarantinedAt.Valid {
+			// Quarantined sandboxes lose LLM and credential access.
+			http.Error(w, "sandbox quarantined", http.StatusUnauthorized)
+			return
+		}
 		resp["sandbox_id"] = sbx.ID
 		resp["status"] = sbx.Status
+		if scope := sandboxProxyScope(sbx.Metadata); scope != nil {
+			resp["scope"] = scope
+		}
+		if providers := sandboxLLMProviders(sbx.Metadata); len(providers) > 0 {
+			resp["providers"] = providers
+		}
+		// Usage through the sandbox is attributed to its creator.
+		if sbx.CreatedBy.Valid {
+			resp["user_id"] = sbx.CreatedBy.String
+		}
 	case "workspace":
 		// Workspace tokens have no sandbox; status is constant.
 		resp["status"] = "active"
 	}
 
+	// Workspace model allowlist / default model — same for both token types.
+	policy, err := s.DB.GetWorkspaceModelPolicy(pt.WorkspaceID)
+	if err != nil {
+		log.Printf("validate-proxy-token: get model policy: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if mp := modelPolicyFromDB(policy); mp != nil {
+		resp["model_policy"] = mp
+	}
+
+	// Monthly spend limits of the workspace and the sandbox's creator.
+	userID, _ := resp["user_id"].(string)
+	limits, err := s.llmSpendLimits(pt.WorkspaceID, userID)
+	if err != nil {
+		log.Printf("validate-proxy-token: get spend limits: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(limits) > 0 {
+		resp["spend_limits"] = limits
+	}
+
 	// Optional modelserver upstream — same logic for both token types.
 	if s.ModelserverProxyURL != "" {
 		hasMSConn, _ := s.DB.HasModelserverConnection(pt.WorkspaceID)
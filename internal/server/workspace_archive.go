@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/storage"
+)
+
+// rejectIfWorkspaceArchived writes a 409 and returns true when the workspace
+// is archived. Archived workspaces accept no new or resumed sandboxes.
+func (s *Server) rejectIfWorkspaceArchived(w http.ResponseWriter, wsID string) bool {
+	ws, err := s.DB.GetWorkspace(wsID)
+	if err != nil {
+		log.Printf("failed to get workspace %s: %v", wsID, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return true
+	}
+	if ws != nil && ws.ArchivedAt.Valid {
+		http.Error(w, "workspace is archived", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// handleArchiveWorkspace freezes a workspace without deleting it: all running
+// sandboxes are paused, new sandboxes are refused, and the workspace is hidden
+// from the default listing. With {"snapshot_drives": true} the workspace
+// drives are also snapshotted (K8s only). Local sandboxes are left alone.
+func (s *Server) handleArchiveWorkspace(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, id, "owner", "maintainer") {
+		return
+	}
+
+	var req struct {
+		SnapshotDrives bool `json:"snapshot_drives"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	ws, err := s.DB.GetWorkspace(id)
+	if err != nil || ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+	if ws.ArchivedAt.Valid {
+		http.Error(w, "workspace is already archived", http.StatusConflict)
+		return
+	}
+
+	var snapshotter storage.DriveSnapshotter
+	if req.SnapshotDrives {
+		var ok bool
+		snapshotter, ok = s.DriveManager.(storage.DriveSnapshotter)
+		if !ok || !ws.K8sNamespace.Valid {
+			http.Error(w, "drive snapshots are not supported by this backend", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sandboxes := s.Sandboxes.ListByWorkspace(id)
+	if sbx := firstPinned(sandboxes); sbx != nil {
+		http.Error(w, "sandbox "+sbx.Name+" is pinned; unpin it before archiving", http.StatusConflict)
+		return
+	}
+
+	var toPause []*sbxstore.Sandbox
+	for _, sbx := range sandboxes {
+		if sbx.IsLocal {
+			continue
+		}
+		switch sbx.Status {
+		case sbxstore.StatusCreating, sbxstore.StatusProvisioningStorage, sbxstore.StatusResuming, sbxstore.StatusPausing:
+			http.Error(w, "sandbox "+sbx.Name+" is "+sbx.Status+"; retry when it settles", http.StatusConflict)
+			return
+		case sbxstore.StatusRunning:
+			toPause = append(toPause, sbx)
+		}
+	}
+
+	// Mark archived first so no sandbox is created or resumed while the
+	// running ones are being paused.
+	if err := s.DB.SetWorkspaceArchived(id, true, nil); err != nil {
+		log.Printf("failed to archive workspace %s: %v", id, err)
+		http.Error(w, "failed to archive workspace", http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for _, sbx := range toPause {
+		if err := s.Sandboxes.UpdateStatus(sbx.ID, sbxstore.StatusPausing); err != nil {
+			log.Printf("archive: failed to update status of sandbox %s: %v", sbx.ID, err)
+			failed++
+			continue
+		}
+		wg.Add(1)
+		go func(sbxID string) {
+			defer wg.Done()
+			if err := s.ProcessManager.Pause(sbxID); err != nil {
+				log.Printf("archive: failed to pause sandbox %s: %v", sbxID, err)
+				s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusRunning)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				return
+			}
+			if err := s.DB.UpdateSandboxPodIP(sbxID, ""); err != nil {
+				log.Printf("archive: failed to clear pod IP for sandbox %s: %v", sbxID, err)
+			}
+			s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusPaused)
+		}(sbx.ID)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		if err := s.DB.SetWorkspaceArchived(id, false, nil); err != nil {
+			log.Printf("failed to roll back archive of workspace %s: %v", id, err)
+		}
+		http.Error(w, "failed to pause all sandboxes; workspace not archived", http.StatusInternalServerError)
+		return
+	}
+
+	var snapshots []string
+	if snapshotter != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+		snapshots, err = snapshotter.SnapshotDrive(ctx, id, ws.K8sNamespace.String)
+		cancel()
+		if err != nil {
+			log.Printf("failed to snapshot drives for workspace %s: %v", id, err)
+			http.Error(w, "sandboxes paused but drive snapshot failed", http.StatusInternalServerError)
+			return
+		}
+		if err := s.DB.SetWorkspaceArchived(id, true, snapshots); err != nil {
+			log.Printf("failed to record archive snapshots for workspace %s: %v", id, err)
+		}
+	}
+	log.Printf("workspace %s archived (%d sandbox(es) paused, %d snapshot(s))", id, len(toPause), len(snapshots))
+
+	ws, err = s.DB.GetWorkspace(id)
+	if err != nil || ws == nil {
+		http.Error(w, "failed to get workspace", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workspace": s.toWorkspaceResponse(ws),
+		"snapshots": snapshots,
+	})
+}
+
+// handleUnarchiveWorkspace lifts the archive. Sandboxes stay paused until
+// resumed individually; drive snapshots are kept.
+func (s *Server) handleUnarchiveWorkspace(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, id, "owner", "maintainer") {
+		return
+	}
+
+	ws, err := s.DB.GetWorkspace(id)
+	if err != nil || ws == nil {
+		http.Error(w, "workspace not found", http.StatusNotFound)
+		return
+	}
+	if !ws.ArchivedAt.Valid {
+		http.Error(w, "workspace is not archived", http.StatusConflict)
+		return
+	}
+
+	if err := s.DB.SetWorkspaceArchived(id, false, nil); err != nil {
+		log.Printf("failed to unarchive workspace %s: %v", id, err)
+		http.Error(w, "failed to unarchive workspace", http.StatusInternalServerError)
+		return
+	}
+
+	ws, err = s.DB.GetWorkspace(id)
+	if err != nil || ws == nil {
+		http.Error(w, "failed to get workspace", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.toWorkspaceResponse(ws))
+}
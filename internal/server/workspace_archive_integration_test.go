@@ -0,0 +1,172 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/agentserver/agentserver/internal/testenv"
+)
+
+// TestIntegration_ArchiveWorkspace checks that archiving pauses the running
+// sandboxes in parallel, is rolled back when one fails to pause, and that
+// an archived workspace refuses resumes until it is unarchived.
+func TestIntegration_ArchiveWorkspace(t *testing.T) {
+	d := testenv.DB(t)
+	wsID := uuid.NewString()
+	ownerID := uuid.NewString()
+	devID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, ownerID, "owner")
+	seedWorkspaceMember(t, d, wsID, devID, "developer")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id IN ($1, $2)`, ownerID, devID)
+	})
+	running := []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}
+	sort.Strings(running)
+	stuck := running[1]
+	backend := &pauseBackend{fail: map[string]bool{stuck: true}}
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d), ProcessManager: backend}
+	for _, id := range running {
+		if err := d.CreateSandbox(id, wsID, "dev", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		s.Sandboxes.UpdateStatus(id, sbxstore.StatusRunning)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/api/workspaces/{id}/archive", s.handleArchiveWorkspace)
+	r.Post("/api/workspaces/{id}/unarchive", s.handleUnarchiveWorkspace)
+	r.Post("/api/sandboxes/{id}/resume", s.handleResumeSandbox)
+	do := func(userID, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`)).
+			WithContext(auth.ContextWithUserID(context.Background(), userID))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	archived := func() bool {
+		ws, err := d.GetWorkspace(wsID)
+		if err != nil || ws == nil {
+			t.Fatalf("GetWorkspace = %v, %v", ws, err)
+		}
+		return ws.ArchivedAt.Valid
+	}
+	status := func(id string) string {
+		sbx, ok := s.Sandboxes.Get(id)
+		if !ok {
+			t.Fatalf("sandbox %s not found", id)
+		}
+		return sbx.Status
+	}
+
+	if rr := do(devID, "/api/workspaces/"+wsID+"/archive"); rr.Code != http.StatusForbidden {
+		t.Fatalf("archive by developer: status %d, want 403", rr.Code)
+	}
+
+	// One sandbox fails to pause: the archive is rolled back, the sandbox
+	// is running again, and the others stay paused.
+	if rr := do(ownerID, "/api/workspaces/"+wsID+"/archive"); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("archive with a failing pause: status %d (%s), want 500", rr.Code, rr.Body.String())
+	}
+	if archived() {
+		t.Error("workspace archived although a sandbox failed to pause")
+	}
+	if got := status(stuck); got != sbxstore.StatusRunning {
+		t.Errorf("sandbox that failed to pause is %s, want running", got)
+	}
+	for _, id := range []string{running[0], running[2]} {
+		if got := status(id); got != sbxstore.StatusPaused {
+			t.Errorf("sandbox %s is %s, want paused", id, got)
+		}
+	}
+
+	delete(backend.fail, stuck)
+	if rr := do(ownerID, "/api/workspaces/"+wsID+"/archive"); rr.Code != http.StatusOK {
+		t.Fatalf("archive: status %d (%s), want 200", rr.Code, rr.Body.String())
+	}
+	if !archived() {
+		t.Fatal("workspace not archived")
+	}
+	if got := status(stuck); got != sbxstore.StatusPaused {
+		t.Errorf("sandbox %s is %s after archive, want paused", stuck, got)
+	}
+	backend.mu.Lock()
+	paused := append([]string(nil), backend.paused...)
+	backend.mu.Unlock()
+	sort.Strings(paused)
+	if len(paused) != 3 || paused[0] != running[0] || paused[1] != stuck || paused[2] != running[2] {
+		t.Errorf("paused %v, want each of %v once", paused, running)
+	}
+	if rr := do(ownerID, "/api/workspaces/"+wsID+"/archive"); rr.Code != http.StatusConflict {
+		t.Errorf("second archive: status %d, want 409", rr.Code)
+	}
+	if rr := do(ownerID, "/api/sandboxes/"+stuck+"/resume"); rr.Code != http.StatusConflict {
+		t.Errorf("resume in an archived workspace: status %d, want 409", rr.Code)
+	}
+
+	if rr := do(devID, "/api/workspaces/"+wsID+"/unarchive"); rr.Code != http.StatusForbidden {
+		t.Errorf("unarchive by developer: status %d, want 403", rr.Code)
+	}
+	if rr := do(ownerID, "/api/workspaces/"+wsID+"/unarchive"); rr.Code != http.StatusOK {
+		t.Fatalf("unarchive: status %d (%s), want 200", rr.Code, rr.Body.String())
+	}
+	if archived() {
+		t.Error("workspace still archived after unarchive")
+	}
+	if got := status(stuck); got != sbxstore.StatusPaused {
+		t.Errorf("sandbox %s is %s after unarchive, want still paused", stuck, got)
+	}
+	if rr := do(ownerID, "/api/workspaces/"+wsID+"/unarchive"); rr.Code != http.StatusConflict {
+		t.Errorf("second unarchive: status %d, want 409", rr.Code)
+	}
+}
+
+// TestIntegration_ArchiveWorkspaceRefusesPinned checks that a workspace
+// with a pinned sandbox can't be archived and nothing is paused.
+func TestIntegration_ArchiveWorkspaceRefusesPinned(t *testing.T) {
+	d := testenv.DB(t)
+	wsID := uuid.NewString()
+	ownerID := uuid.NewString()
+	sbxID := uuid.NewString()
+	seedWorkspaceMember(t, d, wsID, ownerID, "owner")
+	t.Cleanup(func() {
+		d.Exec(`DELETE FROM workspaces WHERE id = $1`, wsID)
+		d.Exec(`DELETE FROM users WHERE id = $1`, ownerID)
+	})
+	backend := &pauseBackend{}
+	s := &Server{DB: d, Sandboxes: sbxstore.NewStore(d), ProcessManager: backend}
+	if err := d.CreateSandbox(sbxID, wsID, "dev", "opencode", "", "", "", "", "", 0, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Sandboxes.UpdateStatus(sbxID, sbxstore.StatusRunning)
+	if err := d.SetSandboxPinned(sbxID, true, ownerID); err != nil {
+		t.Fatal(err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", wsID)
+	ctx := context.WithValue(auth.ContextWithUserID(context.Background(), ownerID), chi.RouteCtxKey, rctx)
+	req := httptest.NewRequest(http.MethodPost, "/api/workspaces/"+wsID+"/archive", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	s.handleArchiveWorkspace(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("archive with a pinned sandbox: status %d, want 409", rr.Code)
+	}
+	if ws, _ := d.GetWorkspace(wsID); ws == nil || ws.ArchivedAt.Valid {
+		t.Error("workspace archived despite a pinned sandbox")
+	}
+	if len(backend.paused) != 0 {
+		t.Errorf("paused %v", backend.paused)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+	"github.com/go-chi/chi/v5"
+)
+
+// workspaceEventsPollInterval is how often the workspace event stream
+// rereads the workspace's sandboxes, for the changes made outside this
+// process (heartbeats and tunnel status from the sandbox proxy, other
+// server replicas) and events dropped for a slow client. It also keeps
+// the connection alive.
+const workspaceEventsPollInterval = 10 * time.Second
+
+// sandboxEventState is what the workspace event stream tracks per sandbox.
+type sandboxEventState struct {
+	Status          string     `json:"status"`
+	Message         string     `json:"message,omitempty"`
+	LastHeartbeatAt *time.Time `json:"last_heartbeat_at,omitempty"`
+}
+
+func sandboxEventStates(sandboxes []*sbxstore.Sandbox) map[string]sandboxEventState {
+	states := make(map[string]sandboxEventState, len(sandboxes))
+	for _, sbx := range sandboxes {
+		states[sbx.ID] = sandboxEventState{Status: sbx.Status, Message: sbx.StatusMessage, LastHeartbeatAt: sbx.LastHeartbeatAt}
+	}
+	return states
+}
+
+// diffSandboxEvents returns the events that turn the states prev into
+// next, in a stable order of next's sandboxes followed by removed ones.
+func diffSandboxEvents(workspaceID string, order []string, prev, next map[string]sandboxEventState, at time.Time) []sbxstore.Event {
+	var events []sbxstore.Event
+	for _, id := range order {
+		n := next[id]
+		p, known := prev[id]
+		if !known || p.Status != n.Status || p.Message != n.Message {
+			events = append(events, sbxstore.Event{
+				Type: sbxstore.EventStatus, WorkspaceID: workspaceID, SandboxID: id, At: at,
+				Data: map[string]interface{}{"status": n.Status, "message": n.Message},
+			})
+		}
+		if n.LastHeartbeatAt != nil && (p.LastHeartbeatAt == nil || !p.LastHeartbeatAt.Equal(*n.LastHeartbeatAt)) {
+			events = append(events, sbxstore.Event{
+				Type: sbxstore.EventHeartbeat, WorkspaceID: workspaceID, SandboxID: id, At: at,
+				Data: map[string]interface{}{"last_heartbeat_at": n.LastHeartbeatAt},
+			})
+		}
+	}
+	for id := range prev {
+		if _, ok := next[id]; !ok {
+			events = append(events, sbxstore.Event{Type: sbxstore.EventDeleted, WorkspaceID: workspaceID, SandboxID: id, At: at})
+		}
+	}
+	return events
+}
+
+// GET /api/workspaces/{id}/events streams the sandbox changes of a
+// workspace over SSE (members). It starts with a snapshot of all sandboxes:
+//
+//	event: snapshot   data: {"sandboxes": {"<id>": {"status": ..., "last_heartbeat_at": ...}}}
+//	event: status     data: sbxstore.Event with status and message
+//	event: heartbeat  data: sbxstore.Event with last_heartbeat_at
+//	event: deleted    data: sbxstore.Event
+//	event: quota      data: sbxstore.Event with the quota and why it changed
+func (s *Server) handleWorkspaceEvents(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before the snapshot so no change falls in between.
+	events, cancel := s.Sandboxes.Subscribe(wsID)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	}
+	// list reads the workspace's sandboxes; ListByWorkspace returns nil
+	// only on a database error.
+	list := func() ([]string, map[string]sandboxEventState, bool) {
+		sandboxes := s.Sandboxes.ListByWorkspace(wsID)
+		order := make([]string, 0, len(sandboxes))
+		for _, sbx := range sandboxes {
+			order = append(order, sbx.ID)
+		}
+		return order, sandboxEventStates(sandboxes), sandboxes != nil
+	}
+
+	_, states, _ := list()
+	send("snapshot", map[string]interface{}{"sandboxes": states})
+	flusher.Flush()
+
+	ticker := time.NewTicker(workspaceEventsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			switch ev.Type {
+			case sbxstore.EventStatus:
+				st := states[ev.SandboxID]
+				st.Status, _ = ev.Data["status"].(string)
+				st.Message, _ = ev.Data["message"].(string)
+				states[ev.SandboxID] = st
+			case sbxstore.EventDeleted:
+				delete(states, ev.SandboxID)
+			}
+			send(ev.Type, ev)
+			flusher.Flush()
+		case <-ticker.C:
+			if order, next, ok := list(); ok {
+				for _, ev := range diffSandboxEvents(wsID, order, states, next, time.Now()) {
+					send(ev.Type, ev)
+				}
+				states = next
+			}
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// publishQuotaEvent tells the workspace's event subscribers that a quota
+// was hit or changed. reason is the error code of a refused request, or
+// quota_changed. A quota that was hit is also notified to the workspace
+// owners.
+func (s *Server) publishQuotaEvent(wsID, reason string, data map[string]interface{}) {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	if reason != "quota_changed" {
+		s.notifyQuotaExceeded(wsID, reason, data)
+	}
+	data["reason"] = reason
+	s.Sandboxes.Publish(sbxstore.Event{Type: sbxstore.EventQuota, WorkspaceID: wsID, Data: data})
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/sbxstore"
+)
+
+func TestDiffSandboxEvents(t *testing.T) {
+	beat := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	later := beat.Add(20 * time.Second)
+	prev := map[string]sandboxEventState{
+		"a": {Status: "running"},
+		"b": {Status: "running", LastHeartbeatAt: &beat},
+		"c": {Status: "paused"},
+		"d": {Status: "creating"},
+	}
+	next := map[string]sandboxEventState{
+		"a": {Status: "running"},
+		"b": {Status: "offline", LastHeartbeatAt: &later},
+		"c": {Status: "paused"},
+		"e": {Status: "creating"},
+	}
+	now := time.Now()
+	events := diffSandboxEvents("ws", []string{"a", "b", "c", "e"}, prev, next, now)
+
+	var got []string
+	for _, ev := range events {
+		if ev.WorkspaceID != "ws" || !ev.At.Equal(now) {
+			t.Errorf("event %+v", ev)
+		}
+		got = append(got, ev.Type+":"+ev.SandboxID)
+	}
+	want := []string{
+		sbxstore.EventStatus + ":b", sbxstore.EventHeartbeat + ":b",
+		sbxstore.EventStatus + ":e",
+		sbxstore.EventDeleted + ":d",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+	if events[0].Data["status"] != "offline" {
+		t.Errorf("status event data = %v", events[0].Data)
+	}
+}
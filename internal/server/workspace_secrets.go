@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/crypto"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/go-chi/chi/v5"
+)
+
+// Workspace secrets let members share credentials (registry tokens, API
+// keys) without putting them in plaintext sandbox env. Values are encrypted
+// with AES-GCM under the server's master key (WORKSPACE_SECRETS_KEY), are
+// never returned by the API, and are only decrypted to materialize them in
+// the workspace's sandboxes when they are created: as env vars, kept in the
+// sandbox's env Secret on k8s, or as read-only files in
+// process.SecretFilesDir.
+
+const (
+	// maxWorkspaceSecrets bounds the secrets of a workspace; with
+	// maxWorkspaceSecretBytes it keeps a sandbox's secrets within a k8s
+	// Secret's 1 MiB.
+	maxWorkspaceSecrets     = 30
+	maxWorkspaceSecretBytes = 32 << 10
+)
+
+// workspaceSecretRequest is the body of creating or replacing a secret.
+type workspaceSecretRequest struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Target      string `json:"target"`
+	Description string `json:"description"`
+}
+
+// validate checks a secret and defaults its target to "env".
+func (req *workspaceSecretRequest) validate() error {
+	if !process.ValidEnvName(req.Name) || len(req.Name) > 100 {
+		return fmt.Errorf("name must be a valid env var name of at most 100 characters")
+	}
+	if req.Value == "" || len(req.Value) > maxWorkspaceSecretBytes {
+		return fmt.Errorf("value is required and must be at most %d bytes", maxWorkspaceSecretBytes)
+	}
+	if req.Target == "" {
+		req.Target = "env"
+	}
+	if req.Target != "env" && req.Target != "file" {
+		return fmt.Errorf(`target must be "env" or "file"`)
+	}
+	if len(req.Description) > 1000 {
+		return fmt.Errorf("description must be at most 1000 characters")
+	}
+	return nil
+}
+
+// workspaceSecretValues decrypts a workspace's secrets into the env vars
+// and files of a sandbox start. Without a master key there are none.
+func (s *Server) workspaceSecretValues(workspaceID string) (env, files map[string]string, err error) {
+	if s.SecretsKey == nil {
+		return nil, nil, nil
+	}
+	secrets, err := s.DB.ListWorkspaceSecrets(workspaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, sec := range secrets {
+		value, err := crypto.Decrypt(s.SecretsKey, sec.Value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decrypt secret %s: %w", sec.Name, err)
+		}
+		if sec.Target == "file" {
+			if files == nil {
+				files = make(map[string]string)
+			}
+			files[sec.Name] = string(value)
+			continue
+		}
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[sec.Name] = string(value)
+	}
+	return env, files, nil
+}
+
+// requireSecretsKey writes 503 if workspace secrets are not configured.
+func (s *Server) requireSecretsKey(w http.ResponseWriter) bool {
+	if s.SecretsKey == nil {
+		http.Error(w, "workspace secrets are not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// GET /api/workspaces/{id}/secrets lists the workspace's secrets without
+// their values.
+func (s *Server) handleListWorkspaceSecrets(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	if !s.requireSecretsKey(w) {
+		return
+	}
+	secrets, err := s.DB.ListWorkspaceSecrets(wsID)
+	if err != nil {
+		log.Printf("failed to list secrets of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to list secrets", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(secrets)
+}
+
+// POST /api/workspaces/{id}/secrets creates a secret or replaces the one
+// with the same name (developer+). Sandboxes get the new value when they
+// are next created.
+func (s *Server) handlePutWorkspaceSecret(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer", "developer") {
+		return
+	}
+	if !s.requireSecretsKey(w) {
+		return
+	}
+	var req workspaceSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := s.DB.ListWorkspaceSecrets(wsID)
+	if err != nil {
+		log.Printf("failed to list secrets of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to save secret", http.StatusInternalServerError)
+		return
+	}
+	replacing := false
+	for _, sec := range existing {
+		replacing = replacing || sec.Name == req.Name
+	}
+	if !replacing && len(existing) >= maxWorkspaceSecrets {
+		http.Error(w, fmt.Sprintf("a workspace can have at most %d secrets", maxWorkspaceSecrets), http.StatusConflict)
+		return
+	}
+	value, err := crypto.Encrypt(s.SecretsKey, []byte(req.Value))
+	if err != nil {
+		log.Printf("failed to encrypt secret %s of workspace %s: %v", req.Name, wsID, err)
+		http.Error(w, "failed to save secret", http.StatusInternalServerError)
+		return
+	}
+	userID := auth.UserIDFromContext(r.Context())
+	sec := &db.WorkspaceSecret{
+		WorkspaceID: wsID,
+		Name:        req.Name,
+		Target:      req.Target,
+		Description: req.Description,
+		Value:       value,
+		UpdatedBy:   userID,
+	}
+	created, err := s.DB.PutWorkspaceSecret(sec)
+	if err != nil {
+		log.Printf("failed to save secret %s of workspace %s: %v", req.Name, wsID, err)
+		http.Error(w, "failed to save secret", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("workspace %s: secret %s (%s) saved by %s", wsID, sec.Name, sec.Target, userID)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(sec)
+}
+
+// DELETE /api/workspaces/{id}/secrets/{name} deletes a secret
+// (developer+). Running sandboxes keep the value they started with.
+func (s *Server) handleDeleteWorkspaceSecret(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	name := chi.URLParam(r, "name")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer", "developer") {
+		return
+	}
+	if !s.requireSecretsKey(w) {
+		return
+	}
+	if isDryRun(r) {
+		writeDryRun(w, []dryRunAction{{Action: "delete_workspace_secret", Target: name}})
+		return
+	}
+	ok, err := s.DB.DeleteWorkspaceSecret(wsID, name)
+	if err != nil {
+		log.Printf("failed to delete secret %s of workspace %s: %v", name, wsID, err)
+		http.Error(w, "failed to delete secret", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "secret not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("workspace %s: secret %s deleted by %s", wsID, name, auth.UserIDFromContext(r.Context()))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,25 @@
+package server
+
+import "testing"
+
+func TestWorkspaceSecretRequestValidate(t *testing.T) {
+	req := &workspaceSecretRequest{Name: "NPM_TOKEN", Value: "npm_abc"}
+	if err := req.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if req.Target != "env" {
+		t.Errorf("target = %q, want env", req.Target)
+	}
+
+	for name, bad := range map[string]workspaceSecretRequest{
+		"path in name":   {Name: "../etc/passwd", Value: "x"},
+		"digit first":    {Name: "1TOKEN", Value: "x"},
+		"empty value":    {Name: "TOKEN"},
+		"large value":    {Name: "TOKEN", Value: string(make([]byte, maxWorkspaceSecretBytes+1))},
+		"unknown target": {Name: "TOKEN", Value: "x", Target: "volume"},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
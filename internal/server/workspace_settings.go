@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/policy"
+	"github.com/go-chi/chi/v5"
+)
+
+// PATCH /api/workspaces/{id} renames a workspace and changes its settings:
+// the defaults new sandboxes get for what the create request leaves out.
+// The settings are kept in workspace_settings and merged into
+// effectiveWorkspaceDefaults.
+
+const maxWorkspaceNameLen = 100
+
+// workspaceSettingFields are the settings PATCH /api/workspaces/{id}
+// accepts, by JSON name.
+var workspaceSettingFields = []string{"default_sandbox_type", "default_cpu", "default_memory", "default_idle_timeout"}
+
+// applyWorkspaceSettingsPatch sets the fields of a settings patch on ws;
+// null clears a setting. The values are checked against the workspace's
+// limits and the policy file. It returns the reason a value is invalid.
+func applyWorkspaceSettingsPatch(ws *db.WorkspaceSettings, patch map[string]json.RawMessage, wd WorkspaceDefaults, pol *policy.Policy) error {
+	for key, raw := range patch {
+		switch key {
+		case "default_sandbox_type":
+			var t *string
+			if err := json.Unmarshal(raw, &t); err != nil {
+				return fmt.Errorf("default_sandbox_type must be a string")
+			}
+			if t != nil && !slices.Contains(policy.SandboxTypes, *t) {
+				return fmt.Errorf("default_sandbox_type must be one of %s", strings.Join(policy.SandboxTypes, ", "))
+			}
+			if t != nil && !pol.TypeAllowed(*t) {
+				return fmt.Errorf("sandbox type %s is not allowed by policy", *t)
+			}
+			ws.DefaultSandboxType = t
+		case "default_cpu":
+			var n *int
+			if err := json.Unmarshal(raw, &n); err != nil {
+				return fmt.Errorf("default_cpu must be a number of millicores")
+			}
+			if n != nil && (*n <= 0 || *n > wd.MaxSandboxCPU) {
+				return fmt.Errorf("default_cpu must be between 1 and %d millicores", wd.MaxSandboxCPU)
+			}
+			ws.DefaultCPU = n
+		case "default_memory":
+			var n *int64
+			if err := json.Unmarshal(raw, &n); err != nil {
+				return fmt.Errorf("default_memory must be a number of bytes")
+			}
+			if n != nil && (*n <= 0 || *n > wd.MaxSandboxMemory) {
+				return fmt.Errorf("default_memory must be between 1 and %d bytes", wd.MaxSandboxMemory)
+			}
+			ws.DefaultMemory = n
+		case "default_idle_timeout":
+			var n *int
+			if err := json.Unmarshal(raw, &n); err != nil {
+				return fmt.Errorf("default_idle_timeout must be a number of seconds")
+			}
+			if n != nil && (*n <= 0 || (wd.MaxIdleTimeout > 0 && *n > wd.MaxIdleTimeout)) {
+				return fmt.Errorf("default_idle_timeout must be between 1 and %d seconds", wd.MaxIdleTimeout)
+			}
+			ws.DefaultIdleTimeout = n
+		default:
+			return fmt.Errorf("unknown field %q", key)
+		}
+	}
+	return nil
+}
+
+// workspaceSettings returns a workspace's settings, all null if it has
+// none.
+func (s *Server) workspaceSettings(workspaceID string) (*db.WorkspaceSettings, error) {
+	ws, err := s.DB.GetWorkspaceSettings(workspaceID)
+	if err != nil || ws != nil {
+		return ws, err
+	}
+	return &db.WorkspaceSettings{WorkspaceID: workspaceID}, nil
+}
+
+// handleUpdateWorkspace renames a workspace (owner/maintainer) or changes
+// its settings (owner); omitted fields are unchanged.
+func (s *Server) handleUpdateWorkspace(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	role, ok := s.requireWorkspaceMember(w, r, id)
+	if !ok {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var name *string
+	patch := make(map[string]json.RawMessage)
+	for key, raw := range fields {
+		switch {
+		case key == "name":
+			var n string
+			if err := json.Unmarshal(raw, &n); err != nil || strings.TrimSpace(n) == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			n = strings.TrimSpace(n)
+			if utf8.RuneCountInString(n) > maxWorkspaceNameLen {
+				http.Error(w, fmt.Sprintf("name must be at most %d characters", maxWorkspaceNameLen), http.StatusBadRequest)
+				return
+			}
+			name = &n
+		case slices.Contains(workspaceSettingFields, key):
+			patch[key] = raw
+		default:
+			http.Error(w, fmt.Sprintf("unknown field %q", key), http.StatusBadRequest)
+			return
+		}
+	}
+	if name == nil && len(patch) == 0 {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+	if name != nil && role != "owner" && role != "maintainer" {
+		http.Error(w, "insufficient permissions", http.StatusForbidden)
+		return
+	}
+	if len(patch) > 0 && role != "owner" {
+		http.Error(w, "only owners can change workspace settings", http.StatusForbidden)
+		return
+	}
+
+	settings, err := s.workspaceSettings(id)
+	if err != nil {
+		log.Printf("failed to get settings of workspace %s: %v", id, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if len(patch) > 0 {
+		wd, err := s.effectiveWorkspaceDefaults(id)
+		if err != nil {
+			log.Printf("failed to get workspace defaults for %s: %v", id, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if err := applyWorkspaceSettingsPatch(settings, patch, wd, s.Policy.Get()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if name != nil {
+		if err := s.DB.UpdateWorkspaceName(id, *name); err != nil {
+			log.Printf("failed to rename workspace %s: %v", id, err)
+			http.Error(w, "failed to rename workspace", http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(patch) > 0 {
+		if err := s.DB.SetWorkspaceSettings(settings); err != nil {
+			log.Printf("failed to update settings of workspace %s: %v", id, err)
+			http.Error(w, "failed to update workspace settings", http.StatusInternalServerError)
+			return
+		}
+	}
+	ws, err := s.DB.GetWorkspace(id)
+	if err != nil || ws == nil {
+		http.Error(w, "failed to get workspace", http.StatusInternalServerError)
+		return
+	}
+	resp := s.toWorkspaceResponse(ws)
+	resp.Settings = settings
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/policy"
+)
+
+func TestApplyWorkspaceSettings(t *testing.T) {
+	wd := WorkspaceDefaults{MaxSandboxCPU: 2000, MaxSandboxMemory: 4 << 30, MaxIdleTimeout: 1800}
+	applyWorkspaceSettings(&wd, nil)
+	if wd.DefaultSandboxCPU != 2000 || wd.DefaultSandboxMemory != 4<<30 || wd.DefaultSandboxType != "" || wd.DefaultIdleTimeout != 0 {
+		t.Errorf("without settings: %+v", wd)
+	}
+
+	typ, cpu, mem, idle := "claudecode", 500, int64(8<<30), 3600
+	wd = WorkspaceDefaults{MaxSandboxCPU: 2000, MaxSandboxMemory: 4 << 30, MaxIdleTimeout: 1800}
+	applyWorkspaceSettings(&wd, &db.WorkspaceSettings{DefaultSandboxType: &typ, DefaultCPU: &cpu, DefaultMemory: &mem, DefaultIdleTimeout: &idle})
+	// Memory and idle timeout above limits lowered since are capped.
+	if wd.DefaultSandboxType != "claudecode" || wd.DefaultSandboxCPU != 500 || wd.DefaultSandboxMemory != 4<<30 || wd.DefaultIdleTimeout != 1800 {
+		t.Errorf("with settings: %+v", wd)
+	}
+
+	wd = WorkspaceDefaults{MaxSandboxCPU: 2000, MaxSandboxMemory: 4 << 30}
+	applyWorkspaceSettings(&wd, &db.WorkspaceSettings{DefaultIdleTimeout: &idle})
+	if wd.DefaultIdleTimeout != 3600 {
+		t.Errorf("idle timeout without a limit = %d", wd.DefaultIdleTimeout)
+	}
+}
+
+func TestApplyWorkspaceSettingsPatch(t *testing.T) {
+	wd := WorkspaceDefaults{MaxSandboxCPU: 2000, MaxSandboxMemory: 4 << 30, MaxIdleTimeout: 1800}
+	pol := &policy.Policy{SandboxTypes: []string{"opencode", "claudecode"}}
+	patch := func(s string) map[string]json.RawMessage {
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(s), &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	cpu := 1000
+	ws := &db.WorkspaceSettings{DefaultCPU: &cpu}
+	err := applyWorkspaceSettingsPatch(ws, patch(`{"default_sandbox_type":"claudecode","default_memory":1073741824,"default_cpu":null}`), wd, pol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws.DefaultSandboxType == nil || *ws.DefaultSandboxType != "claudecode" || ws.DefaultCPU != nil ||
+		ws.DefaultMemory == nil || *ws.DefaultMemory != 1<<30 || ws.DefaultIdleTimeout != nil {
+		t.Errorf("patched settings = %+v", ws)
+	}
+
+	for _, bad := range []string{
+		`{"default_sandbox_type":"jupyter"}`, // not allowed by policy
+		`{"default_sandbox_type":"vm"}`,
+		`{"default_sandbox_type":1}`,
+		`{"default_cpu":0}`,
+		`{"default_cpu":2001}`,
+		`{"default_memory":-1}`,
+		`{"default_idle_timeout":3600}`,
+		`{"default_idle_timeout":"1h"}`,
+		`{"max_sandboxes":5}`,
+	} {
+		if err := applyWorkspaceSettingsPatch(&db.WorkspaceSettings{}, patch(bad), wd, pol); err == nil {
+			t.Errorf("%s: accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/agentserver/agentserver/internal/auth"
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/policy"
+	"github.com/agentserver/agentserver/internal/process"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Workspace templates are standardized sandbox environments defined by a
+// workspace's owners and maintainers: a container image, resource sizes, a
+// startup command, env vars and post-start hooks. Sandboxes are created from one with
+// template_id; like policy templates, explicit request fields win.
+
+// workspaceTemplateKey is the sandbox metadata key holding the ID of the
+// workspace template a sandbox was created from.
+const workspaceTemplateKey = "workspace_template"
+
+// maxStartupCommandBytes bounds a template's startup command and each of
+// its post-start hooks.
+const maxStartupCommandBytes = 16 << 10
+
+// maxPostStartHooks bounds the post-start hooks of a template; each runs as
+// an exec on every start and resume.
+const maxPostStartHooks = 20
+
+// workspaceTemplateRequest is the body of creating or replacing a template.
+type workspaceTemplateRequest struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description"`
+	Type           string            `json:"type"`
+	Image          string            `json:"image"`
+	CPU            *int              `json:"cpu"`
+	Memory         *int64            `json:"memory"`
+	StartupCommand string            `json:"startup_command"`
+	Env            map[string]string `json:"env"`
+	PostStartHooks []db.TemplateHook `json:"post_start_hooks"`
+}
+
+// validate checks a template against the policy and fills in defaults.
+func (req *workspaceTemplateRequest) validate(pol *policy.Policy) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return fmt.Errorf("name is required and must be at most 100 characters")
+	}
+	if len(req.Description) > 1000 {
+		return fmt.Errorf("description must be at most 1000 characters")
+	}
+	if req.Type == "" {
+		req.Type = "opencode"
+	}
+	valid := false
+	for _, t := range policy.SandboxTypes {
+		valid = valid || req.Type == t
+	}
+	if !valid {
+		return fmt.Errorf("type must be one of %s", strings.Join(policy.SandboxTypes, ", "))
+	}
+	if !pol.TypeAllowed(req.Type) {
+		return fmt.Errorf("sandbox type %s is not allowed by policy", req.Type)
+	}
+	req.Image = strings.TrimSpace(req.Image)
+	if req.Image != "" && !pol.ImageAllowed(req.Image) {
+		return fmt.Errorf("image %s is not allowed by policy", req.Image)
+	}
+	if req.CPU != nil && *req.CPU <= 0 {
+		return fmt.Errorf("cpu must be positive (millicores)")
+	}
+	if req.Memory != nil && *req.Memory <= 0 {
+		return fmt.Errorf("memory must be positive (bytes)")
+	}
+	if len(req.StartupCommand) > maxStartupCommandBytes {
+		return fmt.Errorf("startup_command must be at most %d bytes", maxStartupCommandBytes)
+	}
+	if len(req.PostStartHooks) > maxPostStartHooks {
+		return fmt.Errorf("at most %d post_start_hooks", maxPostStartHooks)
+	}
+	names := make(map[string]bool)
+	for i, h := range req.PostStartHooks {
+		if h.Name == "" || strings.TrimSpace(h.Run) == "" {
+			return fmt.Errorf("post_start_hooks[%d]: name and run are required", i)
+		}
+		if names[h.Name] {
+			return fmt.Errorf("post_start_hooks[%d]: duplicate name %q", i, h.Name)
+		}
+		names[h.Name] = true
+		if len(h.Run) > maxStartupCommandBytes {
+			return fmt.Errorf("post_start_hooks[%d]: run must be at most %d bytes", i, maxStartupCommandBytes)
+		}
+	}
+	return process.ValidateEnv(req.Env)
+}
+
+func (req *workspaceTemplateRequest) apply(t *db.SandboxTemplate) {
+	t.Name, t.Description, t.Type, t.Image = req.Name, req.Description, req.Type, req.Image
+	t.CPU, t.Memory, t.StartupCommand, t.Env = req.CPU, req.Memory, req.StartupCommand, req.Env
+	if t.Env == nil {
+		t.Env = map[string]string{}
+	}
+	t.PostStartHooks = req.PostStartHooks
+	if t.PostStartHooks == nil {
+		t.PostStartHooks = []db.TemplateHook{}
+	}
+}
+
+// isDuplicateName reports whether a create or update failed on a unique
+// name constraint.
+func isDuplicateName(err error) bool {
+	return strings.Contains(err.Error(), "unique") || strings.Contains(err.Error(), "duplicate")
+}
+
+// startupCommand returns the container command of a template's startup
+// command, or nil if it has none.
+func startupCommand(t *db.SandboxTemplate) []string {
+	if strings.TrimSpace(t.StartupCommand) == "" {
+		return nil
+	}
+	return []string{"sh", "-c", t.StartupCommand}
+}
+
+// decodeWorkspaceTemplate reads and validates a template request. It
+// writes the error response and returns nil on failure.
+func (s *Server) decodeWorkspaceTemplate(w http.ResponseWriter, r *http.Request) *workspaceTemplateRequest {
+	var req workspaceTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return nil
+	}
+	if err := req.validate(s.Policy.Get()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	return &req
+}
+
+// GET /api/workspaces/{id}/templates lists the workspace's templates.
+func (s *Server) handleListWorkspaceTemplates(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if _, ok := s.requireWorkspaceMember(w, r, wsID); !ok {
+		return
+	}
+	templates, err := s.DB.ListSandboxTemplates(wsID)
+	if err != nil {
+		log.Printf("failed to list templates of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to list templates", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// workspaceTemplate resolves the template {templateID} of workspace {id}.
+// It writes the error response and returns nil on failure.
+func (s *Server) workspaceTemplate(w http.ResponseWriter, r *http.Request) *db.SandboxTemplate {
+	wsID := chi.URLParam(r, "id")
+	t, err := s.DB.GetSandboxTemplate(wsID, chi.URLParam(r, "templateID"))
+	if err != nil {
+		log.Printf("failed to get template of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to get template", http.StatusInternalServerError)
+		return nil
+	}
+	if t == nil {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return nil
+	}
+	return t
+}
+
+// GET /api/workspaces/{id}/templates/{templateID} returns a template.
+func (s *Server) handleGetWorkspaceTemplate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.requireWorkspaceMember(w, r, chi.URLParam(r, "id")); !ok {
+		return
+	}
+	t := s.workspaceTemplate(w, r)
+	if t == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// POST /api/workspaces/{id}/templates creates a template (owner/maintainer).
+func (s *Server) handleCreateWorkspaceTemplate(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	req := s.decodeWorkspaceTemplate(w, r)
+	if req == nil {
+		return
+	}
+	t := &db.SandboxTemplate{
+		ID:          uuid.New().String(),
+		WorkspaceID: wsID,
+		CreatedBy:   auth.UserIDFromContext(r.Context()),
+	}
+	req.apply(t)
+	if err := s.DB.CreateSandboxTemplate(t); err != nil {
+		if isDuplicateName(err) {
+			http.Error(w, "a template with this name already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("failed to create template in workspace %s: %v", wsID, err)
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// PUT /api/workspaces/{id}/templates/{templateID} replaces a template
+// (owner/maintainer). Existing sandboxes keep their settings.
+func (s *Server) handleUpdateWorkspaceTemplate(w http.ResponseWriter, r *http.Request) {
+	if !s.requireWorkspaceRole(w, r, chi.URLParam(r, "id"), "owner", "maintainer") {
+		return
+	}
+	t := s.workspaceTemplate(w, r)
+	if t == nil {
+		return
+	}
+	req := s.decodeWorkspaceTemplate(w, r)
+	if req == nil {
+		return
+	}
+	req.apply(t)
+	if err := s.DB.UpdateSandboxTemplate(t); err != nil {
+		if isDuplicateName(err) {
+			http.Error(w, "a template with this name already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("failed to update template %s: %v", t.ID, err)
+		http.Error(w, "failed to update template", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// DELETE /api/workspaces/{id}/templates/{templateID} deletes a template
+// (owner/maintainer). Sandboxes created from it keep running.
+func (s *Server) handleDeleteWorkspaceTemplate(w http.ResponseWriter, r *http.Request) {
+	wsID := chi.URLParam(r, "id")
+	if !s.requireWorkspaceRole(w, r, wsID, "owner", "maintainer") {
+		return
+	}
+	ok, err := s.DB.DeleteSandboxTemplate(wsID, chi.URLParam(r, "templateID"))
+	if err != nil {
+		log.Printf("failed to delete template of workspace %s: %v", wsID, err)
+		http.Error(w, "failed to delete template", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
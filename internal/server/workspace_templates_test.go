@@ -0,0 +1,65 @@
+package server
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/agentserver/agentserver/internal/db"
+	"github.com/agentserver/agentserver/internal/policy"
+)
+
+func TestWorkspaceTemplateRequestValidate(t *testing.T) {
+	pol := &policy.Policy{
+		SandboxTypes:   []string{"opencode", "jupyter"},
+		ImageAllowlist: []string{"ghcr.io/acme/*"},
+	}
+
+	req := &workspaceTemplateRequest{Name: "  node20 ", Image: "ghcr.io/acme/node20", Env: map[string]string{"NODE_ENV": "test"}}
+	if err := req.validate(pol); err != nil {
+		t.Fatal(err)
+	}
+	if req.Name != "node20" || req.Type != "opencode" {
+		t.Errorf("validate left name %q, type %q", req.Name, req.Type)
+	}
+
+	zero := 0
+	for name, bad := range map[string]workspaceTemplateRequest{
+		"no name":          {},
+		"unknown type":     {Name: "x", Type: "vm"},
+		"disallowed type":  {Name: "x", Type: "openclaw"},
+		"disallowed image": {Name: "x", Image: "docker.io/library/ubuntu"},
+		"zero cpu":         {Name: "x", CPU: &zero},
+		"bad env name":     {Name: "x", Env: map[string]string{"1BAD": "x"}},
+		"hook without run": {Name: "x", PostStartHooks: []db.TemplateHook{{Name: "seed"}}},
+	} {
+		if err := bad.validate(pol); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestHookCommand(t *testing.T) {
+	tests := []struct {
+		script string
+		output string
+		status int
+	}{
+		{"echo seeded", "seeded\n", 0},
+		{"echo oops >&2; exit 3", "oops\n", 3},
+		{`printf 'no newline'`, "no newline", 0},
+	}
+	for _, tt := range tests {
+		cmd := hookCommand(tt.script)
+		out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+		if err != nil {
+			t.Fatalf("%q: %v", tt.script, err)
+		}
+		output, status, ok := parseHookOutput(string(out))
+		if !ok || output != tt.output || status != tt.status {
+			t.Errorf("%q = %q, %d, %v; want %q, %d", tt.script, output, status, ok, tt.output, tt.status)
+		}
+	}
+	if _, _, ok := parseHookOutput("cut short"); ok {
+		t.Error("output without exit status parsed")
+	}
+}
@@ -0,0 +1,355 @@
+// Package settings holds server toggles that are configured from the
+// environment but can be overridden at runtime by admins. Overrides are
+// stored as one JSON document in system_settings, so every process that
+// reads them (agentserver, sandbox-proxy) sees the same values.
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/process"
+)
+
+// Key is the system_settings key holding the admin overrides.
+const Key = "server_settings"
+
+// ErrInvalid wraps errors caused by an invalid update.
+var ErrInvalid = errors.New("invalid settings")
+
+// refreshInterval bounds how stale a process's view of the overrides can be
+// after another replica changes them.
+const refreshInterval = 30 * time.Second
+
+// Settings are the effective values of the overridable server toggles.
+type Settings struct {
+	PasswordAuthEnabled       bool     `json:"password_auth_enabled"`
+	OpencodeSubdomainPrefix   string   `json:"opencode_subdomain_prefix"`
+	OpenclawSubdomainPrefix   string   `json:"openclaw_subdomain_prefix"`
+	ClaudeCodeSubdomainPrefix string   `json:"claudecode_subdomain_prefix"`
+	JupyterSubdomainPrefix    string   `json:"jupyter_subdomain_prefix"`
+	OpencodeAssetDomain       string   `json:"opencode_asset_domain"`
+	NetworkPolicyEnabled      bool     `json:"network_policy_enabled"`
+	NetworkPolicyDenyCIDRs    []string `json:"network_policy_deny_cidrs"`
+	// ProxyPathACLs maps workspace roles to the opencode API prefixes the
+	// sandbox proxy refuses them.
+	ProxyPathACLs map[string][]PathRule `json:"proxy_path_acls"`
+}
+
+// PathRule denies an opencode API prefix (one of
+// process.OpencodeAPIPrefixes): for every method, or with WriteOnly only for
+// methods other than GET, HEAD and OPTIONS.
+type PathRule struct {
+	Prefix    string `json:"prefix"`
+	WriteOnly bool   `json:"write_only,omitempty"`
+}
+
+// Denies reports whether the rule refuses a request with the given method
+// to an API path under prefix.
+func (r PathRule) Denies(method, prefix string) bool {
+	if r.Prefix != prefix {
+		return false
+	}
+	return !r.WriteOnly || (method != "GET" && method != "HEAD" && method != "OPTIONS")
+}
+
+// workspaceRoles are the roles proxy path ACLs can name.
+var workspaceRoles = []string{"owner", "maintainer", "developer", "guest"}
+
+// Overrides is a partial Settings; nil fields fall back to the environment.
+type Overrides struct {
+	PasswordAuthEnabled       *bool                  `json:"password_auth_enabled,omitempty"`
+	OpencodeSubdomainPrefix   *string                `json:"opencode_subdomain_prefix,omitempty"`
+	OpenclawSubdomainPrefix   *string                `json:"openclaw_subdomain_prefix,omitempty"`
+	ClaudeCodeSubdomainPrefix *string                `json:"claudecode_subdomain_prefix,omitempty"`
+	JupyterSubdomainPrefix    *string                `json:"jupyter_subdomain_prefix,omitempty"`
+	OpencodeAssetDomain       *string                `json:"opencode_asset_domain,omitempty"`
+	NetworkPolicyEnabled      *bool                  `json:"network_policy_enabled,omitempty"`
+	NetworkPolicyDenyCIDRs    *[]string              `json:"network_policy_deny_cidrs,omitempty"`
+	ProxyPathACLs             *map[string][]PathRule `json:"proxy_path_acls,omitempty"`
+}
+
+// Apply returns s with the set overrides applied.
+func (o Overrides) Apply(s Settings) Settings {
+	if o.PasswordAuthEnabled != nil {
+		s.PasswordAuthEnabled = *o.PasswordAuthEnabled
+	}
+	if o.OpencodeSubdomainPrefix != nil {
+		s.OpencodeSubdomainPrefix = *o.OpencodeSubdomainPrefix
+	}
+	if o.OpenclawSubdomainPrefix != nil {
+		s.OpenclawSubdomainPrefix = *o.OpenclawSubdomainPrefix
+	}
+	if o.ClaudeCodeSubdomainPrefix != nil {
+		s.ClaudeCodeSubdomainPrefix = *o.ClaudeCodeSubdomainPrefix
+	}
+	if o.JupyterSubdomainPrefix != nil {
+		s.JupyterSubdomainPrefix = *o.JupyterSubdomainPrefix
+	}
+	if o.OpencodeAssetDomain != nil {
+		s.OpencodeAssetDomain = *o.OpencodeAssetDomain
+	}
+	if o.NetworkPolicyEnabled != nil {
+		s.NetworkPolicyEnabled = *o.NetworkPolicyEnabled
+	}
+	if o.NetworkPolicyDenyCIDRs != nil {
+		s.NetworkPolicyDenyCIDRs = *o.NetworkPolicyDenyCIDRs
+	}
+	if o.ProxyPathACLs != nil {
+		s.ProxyPathACLs = *o.ProxyPathACLs
+	}
+	return s
+}
+
+// merge overlays the set fields of patch onto o.
+func (o Overrides) merge(patch Overrides) Overrides {
+	if patch.PasswordAuthEnabled != nil {
+		o.PasswordAuthEnabled = patch.PasswordAuthEnabled
+	}
+	if patch.OpencodeSubdomainPrefix != nil {
+		o.OpencodeSubdomainPrefix = patch.OpencodeSubdomainPrefix
+	}
+	if patch.OpenclawSubdomainPrefix != nil {
+		o.OpenclawSubdomainPrefix = patch.OpenclawSubdomainPrefix
+	}
+	if patch.ClaudeCodeSubdomainPrefix != nil {
+		o.ClaudeCodeSubdomainPrefix = patch.ClaudeCodeSubdomainPrefix
+	}
+	if patch.JupyterSubdomainPrefix != nil {
+		o.JupyterSubdomainPrefix = patch.JupyterSubdomainPrefix
+	}
+	if patch.OpencodeAssetDomain != nil {
+		o.OpencodeAssetDomain = patch.OpencodeAssetDomain
+	}
+	if patch.NetworkPolicyEnabled != nil {
+		o.NetworkPolicyEnabled = patch.NetworkPolicyEnabled
+	}
+	if patch.NetworkPolicyDenyCIDRs != nil {
+		o.NetworkPolicyDenyCIDRs = patch.NetworkPolicyDenyCIDRs
+	}
+	if patch.ProxyPathACLs != nil {
+		o.ProxyPathACLs = patch.ProxyPathACLs
+	}
+	return o
+}
+
+// reset clears the named overrides (JSON field names).
+func (o Overrides) reset(names []string) (Overrides, error) {
+	for _, name := range names {
+		switch name {
+		case "password_auth_enabled":
+			o.PasswordAuthEnabled = nil
+		case "opencode_subdomain_prefix":
+			o.OpencodeSubdomainPrefix = nil
+		case "openclaw_subdomain_prefix":
+			o.OpenclawSubdomainPrefix = nil
+		case "claudecode_subdomain_prefix":
+			o.ClaudeCodeSubdomainPrefix = nil
+		case "jupyter_subdomain_prefix":
+			o.JupyterSubdomainPrefix = nil
+		case "opencode_asset_domain":
+			o.OpencodeAssetDomain = nil
+		case "network_policy_enabled":
+			o.NetworkPolicyEnabled = nil
+		case "network_policy_deny_cidrs":
+			o.NetworkPolicyDenyCIDRs = nil
+		case "proxy_path_acls":
+			o.ProxyPathACLs = nil
+		default:
+			return o, fmt.Errorf("unknown setting %q", name)
+		}
+	}
+	return o, nil
+}
+
+var (
+	prefixRe   = regexp.MustCompile(`^[a-z0-9]{1,30}$`)
+	hostnameRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// Validate checks that the settings are usable together.
+func (s Settings) Validate() error {
+	prefixes := map[string]string{
+		"opencode_subdomain_prefix":   s.OpencodeSubdomainPrefix,
+		"openclaw_subdomain_prefix":   s.OpenclawSubdomainPrefix,
+		"claudecode_subdomain_prefix": s.ClaudeCodeSubdomainPrefix,
+		"jupyter_subdomain_prefix":    s.JupyterSubdomainPrefix,
+	}
+	seen := make(map[string]string)
+	for name, p := range prefixes {
+		// Prefixes are followed by "-{id}", so a hyphen inside one would
+		// make routing ambiguous.
+		if !prefixRe.MatchString(p) {
+			return fmt.Errorf("%s must be 1-30 lowercase letters or digits", name)
+		}
+		if other, ok := seen[p]; ok {
+			return fmt.Errorf("%s and %s must differ", name, other)
+		}
+		seen[p] = name
+	}
+	if s.OpencodeAssetDomain != "" && !hostnameRe.MatchString(s.OpencodeAssetDomain) {
+		return fmt.Errorf("opencode_asset_domain must be a hostname")
+	}
+	for _, c := range s.NetworkPolicyDenyCIDRs {
+		if _, _, err := net.ParseCIDR(c); err != nil {
+			return fmt.Errorf("network_policy_deny_cidrs: invalid CIDR %q", c)
+		}
+	}
+	for role, rules := range s.ProxyPathACLs {
+		if !slices.Contains(workspaceRoles, role) {
+			return fmt.Errorf("proxy_path_acls: unknown role %q", role)
+		}
+		for _, r := range rules {
+			if !slices.Contains(process.OpencodeAPIPrefixes, r.Prefix) {
+				return fmt.Errorf("proxy_path_acls: %q is not an opencode API prefix", r.Prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// Store persists the overrides document.
+type Store interface {
+	GetSystemSetting(key string) (string, error)
+	SetSystemSetting(key, value string) error
+}
+
+// Manager serves effective settings: environment defaults overlaid with the
+// admin overrides, re-read from the store at most every refreshInterval.
+type Manager struct {
+	store    Store
+	defaults Settings
+
+	mu        sync.Mutex
+	overrides Overrides
+	loadedAt  time.Time
+	onChange  []func(Settings)
+}
+
+// NewManager creates a Manager with the given environment defaults.
+func NewManager(store Store, defaults Settings) *Manager {
+	m := &Manager{store: store, defaults: defaults}
+	m.mu.Lock()
+	m.reloadLocked()
+	m.mu.Unlock()
+	return m
+}
+
+// Defaults returns the environment-derived settings.
+func (m *Manager) Defaults() Settings {
+	return m.defaults
+}
+
+// Overrides returns the admin overrides currently in effect.
+func (m *Manager) Overrides() Overrides {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshLocked()
+	return m.overrides
+}
+
+// Get returns the effective settings.
+func (m *Manager) Get() Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshLocked()
+	return m.overrides.Apply(m.defaults)
+}
+
+// OnChange registers fn to be called with the new settings whenever they
+// change, whether through Update or a refresh from the store.
+func (m *Manager) OnChange(fn func(Settings)) {
+	m.mu.Lock()
+	m.onChange = append(m.onChange, fn)
+	current := m.overrides.Apply(m.defaults)
+	m.mu.Unlock()
+	fn(current)
+}
+
+// Update applies patch and clears the reset overrides, validates the
+// result, and persists it. It returns the new effective settings.
+func (m *Manager) Update(patch Overrides, reset []string) (Settings, error) {
+	m.mu.Lock()
+	m.reloadLocked()
+	next, err := m.overrides.reset(reset)
+	if err != nil {
+		m.mu.Unlock()
+		return Settings{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	next = next.merge(patch)
+	effective := next.Apply(m.defaults)
+	if err := effective.Validate(); err != nil {
+		m.mu.Unlock()
+		return Settings{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	data, err := json.Marshal(next)
+	if err != nil {
+		m.mu.Unlock()
+		return Settings{}, fmt.Errorf("encode settings: %w", err)
+	}
+	if err := m.store.SetSystemSetting(Key, string(data)); err != nil {
+		m.mu.Unlock()
+		return Settings{}, err
+	}
+	notify := m.setLocked(next)
+	m.mu.Unlock()
+
+	notify()
+	return effective, nil
+}
+
+func (m *Manager) refreshLocked() {
+	if time.Since(m.loadedAt) < refreshInterval {
+		return
+	}
+	notify := m.reloadLocked()
+	// Listeners must not run under the lock; they may call Get.
+	m.mu.Unlock()
+	notify()
+	m.mu.Lock()
+}
+
+// reloadLocked reads the overrides from the store. On error the previous
+// overrides stay in effect.
+func (m *Manager) reloadLocked() (notify func()) {
+	m.loadedAt = time.Now()
+	raw, err := m.store.GetSystemSetting(Key)
+	if err != nil {
+		log.Printf("settings: failed to load overrides: %v", err)
+		return func() {}
+	}
+	var o Overrides
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &o); err != nil {
+			log.Printf("settings: ignoring malformed overrides: %v", err)
+			return func() {}
+		}
+	}
+	return m.setLocked(o)
+}
+
+// setLocked stores o and returns a function that notifies listeners if the
+// effective settings changed.
+func (m *Manager) setLocked(o Overrides) func() {
+	before := m.overrides.Apply(m.defaults)
+	m.overrides = o
+	after := o.Apply(m.defaults)
+	b1, _ := json.Marshal(before)
+	b2, _ := json.Marshal(after)
+	if string(b1) == string(b2) {
+		return func() {}
+	}
+	listeners := append([]func(Settings){}, m.onChange...)
+	return func() {
+		for _, fn := range listeners {
+			fn(after)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package settings
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type memStore map[string]string
+
+func (m memStore) GetSystemSetting(key string) (string, error) { return m[key], nil }
+func (m memStore) SetSystemSetting(key, value string) error    { m[key] = value; return nil }
+
+func testDefaults() Settings {
+	return Settings{
+		PasswordAuthEnabled:       true,
+		OpencodeSubdomainPrefix:   "code",
+		OpenclawSubdomainPrefix:   "claw",
+		ClaudeCodeSubdomainPrefix: "claude",
+		JupyterSubdomainPrefix:    "jupyter",
+		OpencodeAssetDomain:       "opencodeapp.example.com",
+	}
+}
+
+func TestManager_UpdateAndReset(t *testing.T) {
+	store := memStore{}
+	m := NewManager(store, testDefaults())
+
+	var notified []Settings
+	m.OnChange(func(s Settings) { notified = append(notified, s) })
+
+	off := false
+	prefix := "oc"
+	cidrs := []string{"10.0.0.0/8", "fd00::/8"}
+	got, err := m.Update(Overrides{
+		PasswordAuthEnabled:     &off,
+		OpencodeSubdomainPrefix: &prefix,
+		NetworkPolicyDenyCIDRs:  &cidrs,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got.PasswordAuthEnabled || got.OpencodeSubdomainPrefix != "oc" || got.JupyterSubdomainPrefix != "jupyter" {
+		t.Errorf("effective settings = %+v", got)
+	}
+	if len(notified) != 2 || !reflect.DeepEqual(notified[1], got) {
+		t.Errorf("listener calls = %+v", notified)
+	}
+
+	// A second manager reading the same store sees the overrides.
+	if other := NewManager(store, testDefaults()).Get(); !reflect.DeepEqual(other, got) {
+		t.Errorf("reloaded settings = %+v, want %+v", other, got)
+	}
+
+	got, err = m.Update(Overrides{}, []string{"password_auth_enabled"})
+	if err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if !got.PasswordAuthEnabled || got.OpencodeSubdomainPrefix != "oc" {
+		t.Errorf("after reset = %+v", got)
+	}
+	if o := m.Overrides(); o.PasswordAuthEnabled != nil || o.OpencodeSubdomainPrefix == nil {
+		t.Errorf("overrides after reset = %+v", o)
+	}
+}
+
+func TestManager_UpdateRejectsInvalid(t *testing.T) {
+	m := NewManager(memStore{}, testDefaults())
+
+	dup := "claw"
+	hyphen := "my-code"
+	domain := "not a host"
+	cidrs := []string{"10.0.0.0/33"}
+	badRole := map[string][]PathRule{"viewer": {{Prefix: "/pty"}}}
+	badPrefix := map[string][]PathRule{"guest": {{Prefix: "/etc"}}}
+	for name, o := range map[string]Overrides{
+		"duplicate prefix": {OpencodeSubdomainPrefix: &dup},
+		"hyphen in prefix": {OpencodeSubdomainPrefix: &hyphen},
+		"bad asset domain": {OpencodeAssetDomain: &domain},
+		"bad cidr":         {NetworkPolicyDenyCIDRs: &cidrs},
+		"unknown acl role": {ProxyPathACLs: &badRole},
+		"non-api prefix":   {ProxyPathACLs: &badPrefix},
+	} {
+		if _, err := m.Update(o, nil); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+		}
+	}
+	if _, err := m.Update(Overrides{}, []string{"nope"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown reset: err = %v, want ErrInvalid", err)
+	}
+	if got := m.Get(); !reflect.DeepEqual(got, testDefaults()) {
+		t.Errorf("settings changed after rejected updates: %+v", got)
+	}
+}
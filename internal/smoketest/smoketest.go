@@ -0,0 +1,397 @@
+// Package smoketest runs `agentserver smoke-test`: it walks a live
+// deployment through the critical path of a user (register, create a
+// sandbox, reach it through the proxy, pause, resume, delete) with a
+// throwaway account and reports how long each step took.
+package smoketest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config configures a run.
+type Config struct {
+	// Server is the base URL of the deployment, e.g. https://cli.example.com.
+	Server string
+	// EmailDomain is the domain of the throwaway account's email.
+	EmailDomain string
+	// SandboxType is the type of the sandbox created, e.g. opencode.
+	SandboxType string
+	// ReadyTimeout bounds each wait for a sandbox to reach a state.
+	ReadyTimeout time.Duration
+	// PollInterval is how often the sandbox status is polled.
+	PollInterval time.Duration
+	// Insecure skips TLS certificate verification.
+	Insecure bool
+}
+
+// Step is the outcome of one step of a run.
+type Step struct {
+	Name       string        `json:"name"`
+	Duration   time.Duration `json:"-"`
+	DurationMS int64         `json:"duration_ms"`
+	Skipped    bool          `json:"skipped,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Server      string    `json:"server"`
+	Account     string    `json:"account,omitempty"`
+	WorkspaceID string    `json:"workspace_id,omitempty"`
+	SandboxID   string    `json:"sandbox_id,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMS  int64     `json:"duration_ms"`
+	OK          bool      `json:"ok"`
+	Steps       []Step    `json:"steps"`
+	// LeftOver lists what the run created but could not delete.
+	LeftOver []string `json:"left_over,omitempty"`
+}
+
+// Failed returns the names of the failed steps.
+func (r *Report) Failed() []string {
+	var names []string
+	for _, s := range r.Steps {
+		if s.Error != "" {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// Steps of a run, in order.
+const (
+	StepHealth          = "health"
+	StepAccount         = "register account"
+	StepWorkspace       = "find workspace"
+	StepCreateSandbox   = "create sandbox"
+	StepReady           = "wait ready"
+	StepProxy           = "proxy request"
+	StepPause           = "pause"
+	StepResume          = "resume"
+	StepDeleteSandbox   = "delete sandbox"
+	StepDeleteWorkspace = "delete workspace"
+)
+
+type runner struct {
+	cfg    Config
+	base   *url.URL
+	client *http.Client
+	report *Report
+	failed bool
+
+	workspaceID string
+	sandbox     sandbox
+	deleted     bool
+}
+
+// sandbox is the part of a sandbox response the run uses.
+type sandbox struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	StatusMessage string `json:"status_message"`
+	OpencodeURL   string `json:"opencode_url"`
+	OpenclawURL   string `json:"openclaw_url"`
+	ClaudeCodeURL string `json:"claudecode_url"`
+	JupyterURL    string `json:"jupyter_url"`
+	CustomURL     string `json:"custom_url"`
+}
+
+// appURL returns the URL of the sandbox's web app through the proxy, or ""
+// if it has none (nanoclaw, or no BASE_DOMAIN).
+func (s sandbox) appURL() string {
+	for _, u := range []string{s.OpencodeURL, s.OpenclawURL, s.ClaudeCodeURL, s.JupyterURL, s.CustomURL} {
+		if u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// Run runs the smoke test. Steps after a failed one are skipped, but the
+// sandbox and workspace created are always deleted. The report is
+// complete even if ctx is canceled.
+func Run(ctx context.Context, cfg Config) *Report {
+	if cfg.EmailDomain == "" {
+		cfg.EmailDomain = "smoke-test.invalid"
+	}
+	if cfg.SandboxType == "" {
+		cfg.SandboxType = "opencode"
+	}
+	if cfg.ReadyTimeout <= 0 {
+		cfg.ReadyTimeout = 5 * time.Minute
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	r := &runner{cfg: cfg, report: &Report{Server: cfg.Server, StartedAt: time.Now().UTC()}}
+
+	base, err := url.Parse(strings.TrimRight(cfg.Server, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		r.step(StepHealth, func() error { return fmt.Errorf("invalid server URL %q", cfg.Server) })
+		return r.finish(ctx)
+	}
+	r.base = base
+	jar, _ := cookiejar.New(nil)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	r.client = &http.Client{Jar: jar, Transport: transport, Timeout: time.Minute}
+
+	r.run(ctx, StepHealth, r.health)
+	r.run(ctx, StepAccount, r.register)
+	r.run(ctx, StepWorkspace, r.findWorkspace)
+	r.run(ctx, StepCreateSandbox, r.createSandbox)
+	r.run(ctx, StepReady, func(ctx context.Context) error { return r.waitStatus(ctx, "running") })
+	r.run(ctx, StepProxy, r.proxyRequest)
+	r.run(ctx, StepPause, func(ctx context.Context) error { return r.transition(ctx, "pause", "paused") })
+	r.run(ctx, StepResume, func(ctx context.Context) error { return r.transition(ctx, "resume", "running") })
+	r.run(ctx, StepDeleteSandbox, r.deleteSandbox)
+	return r.finish(ctx)
+}
+
+// run runs a step unless an earlier one failed or ctx is done.
+func (r *runner) run(ctx context.Context, name string, fn func(context.Context) error) {
+	if r.failed || ctx.Err() != nil {
+		r.report.Steps = append(r.report.Steps, Step{Name: name, Skipped: true})
+		return
+	}
+	r.step(name, func() error { return fn(ctx) })
+}
+
+// errSkipped is returned by a step that doesn't apply to the deployment.
+var errSkipped = errors.New("skipped")
+
+// step runs fn and records its outcome.
+func (r *runner) step(name string, fn func() error) {
+	start := time.Now()
+	err := fn()
+	st := Step{Name: name, Duration: time.Since(start)}
+	st.DurationMS = st.Duration.Milliseconds()
+	switch {
+	case err == errSkipped:
+		st.Skipped = true
+	case err != nil:
+		st.Error = err.Error()
+		r.failed = true
+	}
+	r.report.Steps = append(r.report.Steps, st)
+}
+
+// finish deletes what the run created, with its own deadline so that it
+// also runs after ctx is canceled, and completes the report.
+func (r *runner) finish(ctx context.Context) *Report {
+	cctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if r.sandbox.ID != "" && !r.deleted {
+		if err := r.deleteSandbox(cctx); err != nil {
+			r.report.LeftOver = append(r.report.LeftOver, fmt.Sprintf("sandbox %s (%v)", r.sandbox.ID, err))
+		}
+	}
+	if r.workspaceID != "" {
+		r.step(StepDeleteWorkspace, func() error {
+			return r.do(cctx, http.MethodDelete, "/api/workspaces/"+r.workspaceID, nil, nil)
+		})
+		if last := r.report.Steps[len(r.report.Steps)-1]; last.Error != "" {
+			r.report.LeftOver = append(r.report.LeftOver, "workspace "+r.workspaceID)
+		}
+	}
+	if r.report.Account != "" {
+		// Users can't delete their own account; an admin erases it.
+		r.report.LeftOver = append(r.report.LeftOver, "account "+r.report.Account)
+	}
+
+	r.report.OK = len(r.report.Failed()) == 0 && ctx.Err() == nil
+	r.report.DurationMS = time.Since(r.report.StartedAt).Milliseconds()
+	return r.report
+}
+
+func (r *runner) health(ctx context.Context) error {
+	return r.do(ctx, http.MethodGet, "/healthz", nil, nil)
+}
+
+// register registers a throwaway account and logs in with it.
+func (r *runner) register(ctx context.Context) error {
+	suffix, err := randomHex(6)
+	if err != nil {
+		return err
+	}
+	password, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	creds := map[string]string{"email": "smoke-" + suffix + "@" + r.cfg.EmailDomain, "password": password}
+	if err := r.do(ctx, http.MethodPost, "/api/auth/register", creds, nil); err != nil {
+		return fmt.Errorf("register (password login must be enabled): %w", err)
+	}
+	r.report.Account = creds["email"]
+	if err := r.do(ctx, http.MethodPost, "/api/auth/login", creds, nil); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	return nil
+}
+
+// findWorkspace picks the workspace created with the account, or creates
+// one.
+func (r *runner) findWorkspace(ctx context.Context) error {
+	var workspaces []struct {
+		ID string `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodGet, "/api/workspaces", nil, &workspaces); err != nil {
+		return err
+	}
+	if len(workspaces) > 0 {
+		r.workspaceID = workspaces[0].ID
+		r.report.WorkspaceID = r.workspaceID
+		return nil
+	}
+	var ws struct {
+		ID string `json:"id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/api/workspaces", map[string]string{"name": "smoke-test"}, &ws); err != nil {
+		return err
+	}
+	r.workspaceID = ws.ID
+	r.report.WorkspaceID = ws.ID
+	return nil
+}
+
+func (r *runner) createSandbox(ctx context.Context) error {
+	body := map[string]string{"name": "smoke-test", "type": r.cfg.SandboxType}
+	if err := r.do(ctx, http.MethodPost, "/api/workspaces/"+r.workspaceID+"/sandboxes", body, &r.sandbox); err != nil {
+		return err
+	}
+	if r.sandbox.ID == "" {
+		return fmt.Errorf("no sandbox id in response")
+	}
+	r.report.SandboxID = r.sandbox.ID
+	return nil
+}
+
+// waitStatus polls the sandbox until it has status want.
+func (r *runner) waitStatus(ctx context.Context, want string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.ReadyTimeout)
+	defer cancel()
+	for {
+		if err := r.do(ctx, http.MethodGet, "/api/sandboxes/"+r.sandbox.ID, nil, &r.sandbox); err != nil {
+			return err
+		}
+		if r.sandbox.Status == want {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			msg := ""
+			if r.sandbox.StatusMessage != "" {
+				msg = " (" + r.sandbox.StatusMessage + ")"
+			}
+			return fmt.Errorf("still %s%s after %s", r.sandbox.Status, msg, r.cfg.ReadyTimeout)
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// transition pauses or resumes the sandbox and waits for the status it
+// leads to.
+func (r *runner) transition(ctx context.Context, action, want string) error {
+	if err := r.do(ctx, http.MethodPost, "/api/sandboxes/"+r.sandbox.ID+"/"+action, nil, nil); err != nil {
+		return err
+	}
+	return r.waitStatus(ctx, want)
+}
+
+// proxyRequest loads the sandbox's web app through the sandbox proxy. The
+// app URL logs in to the sandbox's host and redirects to its root, which
+// must be served by the sandbox rather than bounce to the main site.
+func (r *runner) proxyRequest(ctx context.Context) error {
+	app := r.sandbox.appURL()
+	if app == "" {
+		return errSkipped
+	}
+	u, err := url.Parse(app)
+	if err != nil {
+		return fmt.Errorf("invalid app URL: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, app, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	if resp.Request.URL.Host != u.Host {
+		return fmt.Errorf("redirected to %s instead of the sandbox", resp.Request.URL.Host)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s%s: %s", u.Host, resp.Request.URL.Path, resp.Status)
+	}
+	return nil
+}
+
+func (r *runner) deleteSandbox(ctx context.Context) error {
+	if err := r.do(ctx, http.MethodDelete, "/api/sandboxes/"+r.sandbox.ID, nil, nil); err != nil {
+		return err
+	}
+	r.deleted = true
+	return nil
+}
+
+// do sends a JSON request to the API and decodes the response into out,
+// if not nil. Non-2xx responses are returned as errors with their body.
+func (r *runner) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.base.String()+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,157 @@
+package smoketest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is just enough of the agentserver API for a run. Sandboxes
+// reach the state an action leads to on the next poll.
+type fakeServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	status      string
+	createFails bool
+	deleted     map[string]bool
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{deleted: map[string]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /api/auth/register", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("POST /api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "agentserver-token", Value: "session", Path: "/"})
+	})
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if c, err := r.Cookie("agentserver-token"); err != nil || c.Value != "session" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("GET /api/workspaces", authed(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]string{{"id": "ws-1"}})
+	}))
+	mux.HandleFunc("DELETE /api/workspaces/{id}", authed(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.deleted["workspace "+r.PathValue("id")] = true
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("POST /api/workspaces/{wid}/sandboxes", authed(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.createFails {
+			http.Error(w, "quota exceeded", http.StatusForbidden)
+			return
+		}
+		f.status = "creating"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": "sbx-1", "status": f.status})
+	}))
+	mux.HandleFunc("GET /api/sandboxes/{id}", authed(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch f.status {
+		case "creating", "resuming":
+			f.status = "running"
+		case "pausing":
+			f.status = "paused"
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id": "sbx-1", "status": f.status, "opencode_url": f.URL + "/app/auth?token=session",
+		})
+	}))
+	transition := func(status string) http.HandlerFunc {
+		return authed(func(w http.ResponseWriter, r *http.Request) {
+			f.mu.Lock()
+			f.status = status
+			f.mu.Unlock()
+		})
+	}
+	mux.HandleFunc("POST /api/sandboxes/{id}/pause", transition("pausing"))
+	mux.HandleFunc("POST /api/sandboxes/{id}/resume", transition("resuming"))
+	mux.HandleFunc("DELETE /api/sandboxes/{id}", authed(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.deleted["sandbox "+r.PathValue("id")] = true
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("GET /app/auth", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sandbox", Value: r.URL.Query().Get("token"), Path: "/app/"})
+		http.Redirect(w, r, "/app/", http.StatusFound)
+	})
+	mux.HandleFunc("GET /app/", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("sandbox"); err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestRun(t *testing.T) {
+	f := newFakeServer(t)
+	report := Run(context.Background(), Config{Server: f.URL, PollInterval: time.Millisecond, ReadyTimeout: time.Second})
+	if !report.OK {
+		t.Fatalf("run failed: %+v", report)
+	}
+	want := []string{StepHealth, StepAccount, StepWorkspace, StepCreateSandbox, StepReady, StepProxy,
+		StepPause, StepResume, StepDeleteSandbox, StepDeleteWorkspace}
+	if len(report.Steps) != len(want) {
+		t.Fatalf("steps = %+v, want %v", report.Steps, want)
+	}
+	for i, st := range report.Steps {
+		if st.Name != want[i] || st.Skipped || st.Error != "" {
+			t.Errorf("step %d = %+v, want %s to succeed", i, st, want[i])
+		}
+	}
+	if !f.deleted["sandbox sbx-1"] || !f.deleted["workspace ws-1"] {
+		t.Errorf("deleted = %v", f.deleted)
+	}
+	if report.Account == "" || len(report.LeftOver) != 1 {
+		t.Errorf("account %q, left over %v; want the account left over", report.Account, report.LeftOver)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	f := newFakeServer(t)
+	f.createFails = true
+	report := Run(context.Background(), Config{Server: f.URL, PollInterval: time.Millisecond, ReadyTimeout: time.Second})
+	if report.OK {
+		t.Fatal("run succeeded despite a failed step")
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0] != StepCreateSandbox {
+		t.Errorf("failed = %v, want [%s]", failed, StepCreateSandbox)
+	}
+	for _, st := range report.Steps {
+		switch st.Name {
+		case StepReady, StepProxy, StepPause, StepResume, StepDeleteSandbox:
+			if !st.Skipped {
+				t.Errorf("%s not skipped after a failure", st.Name)
+			}
+		}
+	}
+	if !f.deleted["workspace ws-1"] {
+		t.Error("workspace not deleted after a failure")
+	}
+}
+
+func TestRunInvalidServer(t *testing.T) {
+	report := Run(context.Background(), Config{Server: "cli.example.com"})
+	if report.OK || len(report.Steps) != 1 || report.Steps[0].Error == "" {
+		t.Errorf("report = %+v, want a failed health step", report)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AccessModeStrategy selects the access mode of workspace drive PVCs.
+type AccessModeStrategy string
+
+const (
+	// AccessModeAuto uses ReadWriteMany when the storage class provisioner is
+	// known to support it, otherwise ReadWriteOnce.
+	AccessModeAuto AccessModeStrategy = "auto"
+	// AccessModeRWX always requests ReadWriteMany.
+	AccessModeRWX AccessModeStrategy = "rwx"
+	// AccessModeRWO requests ReadWriteOnce. All sandboxes of a workspace are
+	// then co-scheduled on the node the drive is attached to.
+	AccessModeRWO AccessModeStrategy = "rwo"
+)
+
+// rwxProvisioners lists CSI/in-tree provisioners known to support
+// ReadWriteMany. Matching is by substring so vendor prefixes are tolerated.
+var rwxProvisioners = []string{
+	"nfs",
+	"cephfs",
+	"efs.csi.aws.com",
+	"file.csi.azure.com",
+	"kubernetes.io/azure-file",
+	"filestore.csi.storage.gke.io",
+	"glusterfs",
+	"driver.longhorn.io",
+	"juicefs",
+	"smb.csi.k8s.io",
+}
+
+func provisionerSupportsRWX(provisioner string) bool {
+	for _, p := range rwxProvisioners {
+		if strings.Contains(provisioner, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAccessModeStrategies parses WORKSPACE_DRIVE_ACCESS_MODE. The value is
+// either a single strategy ("auto", "rwx", "rwo") applied to every storage
+// class, or a comma-separated list of class=strategy pairs, where the class
+// "*" sets the default, e.g. "nfs-client=rwx,gp3=rwo,*=auto".
+func ParseAccessModeStrategies(spec string) (map[string]AccessModeStrategy, error) {
+	out := map[string]AccessModeStrategy{}
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return out, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		class, value := "*", part
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			class, value = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		st := AccessModeStrategy(strings.ToLower(value))
+		switch st {
+		case AccessModeAuto, AccessModeRWX, AccessModeRWO:
+		default:
+			return nil, fmt.Errorf("invalid access mode strategy %q for storage class %q (want auto, rwx or rwo)", value, class)
+		}
+		out[class] = st
+	}
+	return out, nil
+}
+
+// SetAccessModeStrategies configures the per-storage-class access mode
+// strategies. Call ResolveAccessMode afterwards to apply them.
+func (m *WorkspaceDriveManager) SetAccessModeStrategies(strategies map[string]AccessModeStrategy) {
+	m.accessModeStrategies = strategies
+}
+
+// ResolveAccessMode validates the workspace drive storage class and decides
+// the access mode new drive PVCs will request. It is meant to run once at
+// startup; on error the previous mode (ReadWriteMany by default) is kept.
+func (m *WorkspaceDriveManager) ResolveAccessMode(ctx context.Context) error {
+	sc, err := lookupStorageClass(ctx, m.clientset, m.storageClassName)
+	if err != nil {
+		return err
+	}
+
+	strategy := m.accessModeStrategies[sc.Name]
+	if strategy == "" {
+		strategy = m.accessModeStrategies["*"]
+	}
+	if strategy == "" {
+		strategy = AccessModeAuto
+	}
+
+	rwx := provisionerSupportsRWX(sc.Provisioner)
+	switch strategy {
+	case AccessModeRWX:
+		m.accessMode = corev1.ReadWriteMany
+		if !rwx {
+			log.Printf("Warning: workspace drive storage class %s (provisioner %s) is forced to ReadWriteMany but is not known to support it; mounts may fail", sc.Name, sc.Provisioner)
+		}
+	case AccessModeRWO:
+		m.accessMode = corev1.ReadWriteOnce
+	default:
+		if rwx {
+			m.accessMode = corev1.ReadWriteMany
+		} else {
+			m.accessMode = corev1.ReadWriteOnce
+		}
+	}
+	log.Printf("Workspace drives use storage class %s (provisioner %s) with %s (strategy %s)", sc.Name, sc.Provisioner, m.accessMode, strategy)
+	return nil
+}
+
+// lookupStorageClass returns the named storage class, or the cluster default
+// class when name is empty.
+func lookupStorageClass(ctx context.Context, clientset kubernetes.Interface, name string) (*storagev1.StorageClass, error) {
+	if name != "" {
+		sc, err := clientset.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("get storage class %s: %w", name, err)
+		}
+		return sc, nil
+	}
+	list, err := clientset.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list storage classes: %w", err)
+	}
+	for i := range list.Items {
+		if list.Items[i].Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no storage class configured and no cluster default storage class found")
+}
+
+// singleAttach reports whether a PVC can only be attached to one node.
+func singleAttach(modes []corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == corev1.ReadWriteMany || m == corev1.ReadOnlyMany {
+			return false
+		}
+	}
+	return len(modes) > 0
+}
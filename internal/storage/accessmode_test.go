@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseAccessModeStrategies(t *testing.T) {
+	got, err := ParseAccessModeStrategies("rwo")
+	if err != nil || got["*"] != AccessModeRWO {
+		t.Fatalf("single strategy: got %v, %v", got, err)
+	}
+
+	got, err = ParseAccessModeStrategies("nfs-client=rwx, gp3=RWO ,*=auto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["nfs-client"] != AccessModeRWX || got["gp3"] != AccessModeRWO || got["*"] != AccessModeAuto {
+		t.Errorf("got %v", got)
+	}
+
+	if _, err := ParseAccessModeStrategies("gp3=rwx-please"); err == nil {
+		t.Error("expected error for invalid strategy")
+	}
+}
+
+func TestProvisionerSupportsRWX(t *testing.T) {
+	for p, want := range map[string]bool{
+		"cluster.local/nfs-subdir-external-provisioner": true,
+		"efs.csi.aws.com":       true,
+		"ebs.csi.aws.com":       false,
+		"rancher.io/local-path": false,
+	} {
+		if got := provisionerSupportsRWX(p); got != want {
+			t.Errorf("provisionerSupportsRWX(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestSingleAttach(t *testing.T) {
+	if !singleAttach([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}) {
+		t.Error("RWO should be single-attach")
+	}
+	if singleAttach([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}) {
+		t.Error("RWX should not be single-attach")
+	}
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// Workspace drive volumes ("cli-ws-<id>-disk") outlive the containers that
+// mount them, so they are removed with their workspace, and volumes left
+// behind by workspaces deleted earlier (or while the server was down) are
+// garbage-collected.
+
+const (
+	dockerVolumePrefix = "cli-ws-"
+	dockerVolumeSuffix = "-disk"
+	// orphanVolumeMinAge protects volumes created moments ago whose
+	// workspace volume row may not be committed yet.
+	orphanVolumeMinAge = time.Hour
+)
+
+// DockerVolumeClient is the part of the Docker API client used for workspace
+// drive volumes.
+type DockerVolumeClient interface {
+	VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error)
+	VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error)
+	VolumeRemove(ctx context.Context, volumeID string, force bool) error
+	DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+}
+
+// DriveDeleter is implemented by drive managers whose drives must be deleted
+// explicitly when their workspace is deleted. K8s PVCs go with the
+// workspace namespace instead.
+type DriveDeleter interface {
+	DeleteDrive(ctx context.Context, workspaceID, namespace string) error
+}
+
+// DriveGarbageCollector is implemented by drive managers that can find and
+// delete drives whose workspace no longer exists.
+type DriveGarbageCollector interface {
+	CollectOrphanDrives(ctx context.Context) ([]string, error)
+}
+
+// DockerVolume describes a workspace drive volume on the Docker host.
+type DockerVolume struct {
+	Name        string `json:"name"`
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	CreatedAt   string `json:"created_at,omitempty"`
+	Size        int64  `json:"size"`      // bytes, -1 if unknown
+	RefCount    int64  `json:"ref_count"` // containers using the volume, -1 if unknown
+	Orphan      bool   `json:"orphan"`
+}
+
+func isWorkspaceVolume(name string) bool {
+	return strings.HasPrefix(name, dockerVolumePrefix) && strings.HasSuffix(name, dockerVolumeSuffix)
+}
+
+// createVolume creates a labelled drive volume up front; Docker would
+// otherwise create it unlabelled on first mount.
+func (m *DockerWorkspaceDriveManager) createVolume(ctx context.Context, name, workspaceID string) error {
+	if m.cli == nil {
+		return nil
+	}
+	_, err := m.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name: name,
+		Labels: map[string]string{
+			"managed-by":   "agentserver",
+			"workspace-id": workspaceID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create workspace drive volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteVolumes removes the drive volumes of a workspace. Its containers
+// must be removed first; volumes still in use are reported as errors.
+func (m *DockerWorkspaceDriveManager) DeleteVolumes(ctx context.Context, workspaceID string) error {
+	if m.cli == nil {
+		return nil
+	}
+	volumes, err := m.db.ListWorkspaceVolumes(workspaceID)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, v := range volumes {
+		if err := m.cli.VolumeRemove(ctx, v.PVCName, false); err != nil && !errdefs.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("%s: %v", v.PVCName, err))
+			continue
+		}
+		log.Printf("Removed workspace drive volume %s of workspace %s", v.PVCName, workspaceID)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("remove workspace drive volumes: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// knownVolumes maps the drive volume names recorded in the DB to their
+// workspaces.
+func (m *DockerWorkspaceDriveManager) knownVolumes() (map[string]string, error) {
+	volumes, err := m.db.ListAllWorkspaceVolumes()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]string, len(volumes))
+	for _, v := range volumes {
+		known[v.PVCName] = v.WorkspaceID
+	}
+	return known, nil
+}
+
+// orphanVolumes returns the workspace drive volumes not recorded in known
+// and created before cutoff. Volumes without a parseable creation time are
+// treated as old.
+func orphanVolumes(volumes []*volume.Volume, known map[string]string, cutoff time.Time) []string {
+	var orphans []string
+	for _, v := range volumes {
+		if v == nil || !isWorkspaceVolume(v.Name) {
+			continue
+		}
+		if _, ok := known[v.Name]; ok {
+			continue
+		}
+		if created, err := time.Parse(time.RFC3339, v.CreatedAt); err == nil && created.After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, v.Name)
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// CleanOrphanVolumes removes workspace drive volumes whose workspace no
+// longer exists and returns their names. Volumes still mounted by a
+// container are skipped.
+func (m *DockerWorkspaceDriveManager) CleanOrphanVolumes(ctx context.Context) ([]string, error) {
+	if m.cli == nil {
+		return nil, nil
+	}
+	known, err := m.knownVolumes()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.cli.VolumeList(ctx, volume.ListOptions{Filters: filters.NewArgs(filters.Arg("name", dockerVolumePrefix))})
+	if err != nil {
+		return nil, fmt.Errorf("list docker volumes: %w", err)
+	}
+	var removed []string
+	for _, name := range orphanVolumes(resp.Volumes, known, time.Now().Add(-orphanVolumeMinAge)) {
+		if err := m.cli.VolumeRemove(ctx, name, false); err != nil {
+			log.Printf("failed to remove orphan workspace drive volume %s: %v", name, err)
+			continue
+		}
+		log.Printf("Removed orphan workspace drive volume %s", name)
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// ListVolumes reports the workspace drive volumes on the Docker host with
+// their disk usage, from the same data as `docker system df -v`. Computing
+// sizes walks every volume, so this can take a while on large hosts.
+func (m *DockerWorkspaceDriveManager) ListVolumes(ctx context.Context) ([]DockerVolume, error) {
+	if m.cli == nil {
+		return nil, fmt.Errorf("docker client not configured")
+	}
+	known, err := m.knownVolumes()
+	if err != nil {
+		return nil, err
+	}
+	du, err := m.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("docker disk usage: %w", err)
+	}
+	orphan := make(map[string]bool)
+	for _, name := range orphanVolumes(du.Volumes, known, time.Now()) {
+		orphan[name] = true
+	}
+	out := []DockerVolume{}
+	for _, v := range du.Volumes {
+		if v == nil || !isWorkspaceVolume(v.Name) {
+			continue
+		}
+		dv := DockerVolume{
+			Name:        v.Name,
+			WorkspaceID: known[v.Name],
+			CreatedAt:   v.CreatedAt,
+			Size:        -1,
+			RefCount:    -1,
+			Orphan:      orphan[v.Name],
+		}
+		if dv.WorkspaceID == "" {
+			dv.WorkspaceID = v.Labels["workspace-id"]
+		}
+		if v.UsageData != nil {
+			dv.Size, dv.RefCount = v.UsageData.Size, v.UsageData.RefCount
+		}
+		out = append(out, dv)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// DockerVolumeLister is implemented by drive managers backed by Docker
+// volumes.
+type DockerVolumeLister interface {
+	ListDockerVolumes(ctx context.Context) ([]DockerVolume, error)
+}
+
+func (a *DockerDriveAdapter) DeleteDrive(ctx context.Context, workspaceID, namespace string) error {
+	_ = namespace
+	return a.mgr.DeleteVolumes(ctx, workspaceID)
+}
+
+func (a *DockerDriveAdapter) CollectOrphanDrives(ctx context.Context) ([]string, error) {
+	return a.mgr.CleanOrphanVolumes(ctx)
+}
+
+func (a *DockerDriveAdapter) ListDockerVolumes(ctx context.Context) ([]DockerVolume, error) {
+	return a.mgr.ListVolumes(ctx)
+}
+
+var (
+	_ DriveDeleter          = (*DockerDriveAdapter)(nil)
+	_ DriveGarbageCollector = (*DockerDriveAdapter)(nil)
+	_ DockerVolumeLister    = (*DockerDriveAdapter)(nil)
+)
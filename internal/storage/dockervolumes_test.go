@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/volume"
+)
+
+func TestOrphanVolumes(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	old := now.Add(-2 * time.Hour).Format(time.RFC3339)
+	fresh := now.Add(-time.Minute).Format(time.RFC3339)
+	volumes := []*volume.Volume{
+		{Name: "cli-ws-aaaaaaaa-disk", CreatedAt: old},
+		{Name: "cli-ws-bbbbbbbb-disk", CreatedAt: old},
+		{Name: "cli-ws-cccccccc-disk", CreatedAt: fresh},
+		{Name: "cli-ws-dddddddd-disk"},
+		{Name: "cli-sandbox-1234-data", CreatedAt: old},
+		{Name: "postgres-data", CreatedAt: old},
+		nil,
+	}
+	known := map[string]string{"cli-ws-aaaaaaaa-disk": "aaaaaaaa-1111"}
+
+	got := orphanVolumes(volumes, known, now.Add(-orphanVolumeMinAge))
+	want := []string{"cli-ws-bbbbbbbb-disk", "cli-ws-dddddddd-disk"}
+	if len(got) != len(want) {
+		t.Fatalf("orphans = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("orphans = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dockerUsageTTL is how long `docker system df` volume sizes are reused;
+// computing them walks every volume on the host.
+const dockerUsageTTL = 5 * time.Minute
+
+// DriveUsage is the disk usage of one workspace drive.
+type DriveUsage struct {
+	Name          string `json:"name"`
+	MountPath     string `json:"mount_path"`
+	UsedBytes     int64  `json:"used_bytes"`     // -1 if unknown
+	CapacityBytes int64  `json:"capacity_bytes"` // 0 if the drive is not capped
+}
+
+// DriveUsageReporter is implemented by drive managers that can report how
+// full a workspace's drives are.
+type DriveUsageReporter interface {
+	DriveUsage(ctx context.Context, workspaceID, namespace string) ([]DriveUsage, error)
+}
+
+// kubeletSummary is the part of the kubelet /stats/summary response with
+// pod volume usage.
+type kubeletSummary struct {
+	Pods []struct {
+		Volumes []struct {
+			UsedBytes *int64 `json:"usedBytes"`
+			PVCRef    *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// pvcUsedBytes returns the used bytes of the PVCs in namespace found in a
+// kubelet summary.
+func pvcUsedBytes(summary *kubeletSummary, namespace string) map[string]int64 {
+	used := make(map[string]int64)
+	for _, pod := range summary.Pods {
+		for _, v := range pod.Volumes {
+			if v.PVCRef == nil || v.PVCRef.Namespace != namespace || v.UsedBytes == nil {
+				continue
+			}
+			used[v.PVCRef.Name] = *v.UsedBytes
+		}
+	}
+	return used
+}
+
+// PVCUsage reports the workspace drive PVCs' usage from the kubelet stats of
+// nodes running a pod that mounts them. Usage of a drive no running pod
+// mounts is unknown; its capacity comes from the PVC.
+func (m *WorkspaceDriveManager) PVCUsage(ctx context.Context, workspaceID, namespace string) ([]DriveUsage, error) {
+	volumes, err := m.db.ListWorkspaceVolumes(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) == 0 {
+		return []DriveUsage{}, nil
+	}
+
+	pods, err := m.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{FieldSelector: "status.phase=Running"})
+	if err != nil {
+		return nil, fmt.Errorf("list pods: %w", err)
+	}
+	nodes := make(map[string]bool)
+	for _, pod := range pods.Items {
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil && pod.Spec.NodeName != "" {
+				nodes[pod.Spec.NodeName] = true
+			}
+		}
+	}
+	used := make(map[string]int64)
+	for node := range nodes {
+		raw, err := m.clientset.CoreV1().RESTClient().Get().
+			Resource("nodes").Name(node).SubResource("proxy").Suffix("stats/summary").
+			DoRaw(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("kubelet stats of node %s: %w", node, err)
+		}
+		var summary kubeletSummary
+		if err := json.Unmarshal(raw, &summary); err != nil {
+			return nil, fmt.Errorf("parse kubelet stats of node %s: %w", node, err)
+		}
+		for name, n := range pvcUsedBytes(&summary, namespace) {
+			used[name] = n
+		}
+	}
+
+	out := make([]DriveUsage, 0, len(volumes))
+	for _, v := range volumes {
+		du := DriveUsage{Name: v.PVCName, MountPath: v.MountPath, UsedBytes: -1}
+		if n, ok := used[v.PVCName]; ok {
+			du.UsedBytes = n
+		}
+		pvc, err := m.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, v.PVCName, metav1.GetOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("get PVC %s: %w", v.PVCName, err)
+		}
+		if err == nil {
+			if q, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+				du.CapacityBytes = q.Value()
+			} else if q, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				du.CapacityBytes = q.Value()
+			}
+		}
+		out = append(out, du)
+	}
+	return out, nil
+}
+
+// volumeSizes returns the sizes of all Docker volumes, reusing the last
+// `docker system df` result for dockerUsageTTL.
+func (m *DockerWorkspaceDriveManager) volumeSizes(ctx context.Context) (map[string]int64, error) {
+	m.usageMu.Lock()
+	defer m.usageMu.Unlock()
+	if m.usage != nil && time.Since(m.usageAt) < dockerUsageTTL {
+		return m.usage, nil
+	}
+	du, err := m.cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return nil, fmt.Errorf("docker disk usage: %w", err)
+	}
+	sizes := make(map[string]int64, len(du.Volumes))
+	for _, v := range du.Volumes {
+		if v != nil && v.UsageData != nil && v.UsageData.Size >= 0 {
+			sizes[v.Name] = v.UsageData.Size
+		}
+	}
+	m.usage, m.usageAt = sizes, time.Now()
+	return sizes, nil
+}
+
+// VolumeUsage reports the workspace drive volumes' usage. Docker local
+// volumes are not capped, so capacity is 0.
+func (m *DockerWorkspaceDriveManager) VolumeUsage(ctx context.Context, workspaceID string) ([]DriveUsage, error) {
+	if m.cli == nil {
+		return nil, fmt.Errorf("docker client not configured")
+	}
+	volumes, err := m.db.ListWorkspaceVolumes(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) == 0 {
+		return []DriveUsage{}, nil
+	}
+	sizes, err := m.volumeSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DriveUsage, 0, len(volumes))
+	for _, v := range volumes {
+		du := DriveUsage{Name: v.PVCName, MountPath: v.MountPath, UsedBytes: -1}
+		if n, ok := sizes[v.PVCName]; ok {
+			du.UsedBytes = n
+		}
+		out = append(out, du)
+	}
+	return out, nil
+}
+
+func (a *K8sDriveAdapter) DriveUsage(ctx context.Context, workspaceID, namespace string) ([]DriveUsage, error) {
+	return a.mgr.PVCUsage(ctx, workspaceID, namespace)
+}
+
+func (a *DockerDriveAdapter) DriveUsage(ctx context.Context, workspaceID, namespace string) ([]DriveUsage, error) {
+	_ = namespace
+	return a.mgr.VolumeUsage(ctx, workspaceID)
+}
+
+var (
+	_ DriveUsageReporter = (*K8sDriveAdapter)(nil)
+	_ DriveUsageReporter = (*DockerDriveAdapter)(nil)
+)
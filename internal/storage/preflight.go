@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Preflight check severities. Only failed "error" checks make the report
+// unhealthy; warnings are informational.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	minDriveSize = 1 << 30  // 1Gi
+	maxDriveSize = 10 << 40 // 10Ti
+)
+
+// PreflightCheck is the outcome of a single storage check.
+type PreflightCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// PreflightReport collects the storage checks run at server startup.
+type PreflightReport struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	Checks    []PreflightCheck `json:"checks"`
+}
+
+// Healthy reports whether no error-severity check failed.
+func (r *PreflightReport) Healthy() bool {
+	if r == nil {
+		return true
+	}
+	for _, c := range r.Checks {
+		if !c.OK && c.Severity == SeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+// Log writes failed checks to the server log.
+func (r *PreflightReport) Log() {
+	failed := 0
+	for _, c := range r.Checks {
+		if c.OK {
+			continue
+		}
+		failed++
+		log.Printf("Storage preflight %s: %s: %s", c.Severity, c.Name, c.Message)
+	}
+	if failed == 0 {
+		log.Printf("Storage preflight passed (%d checks)", len(r.Checks))
+	}
+}
+
+func (r *PreflightReport) add(name string, ok bool, severity, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, PreflightCheck{
+		Name:     name,
+		OK:       ok,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Preflighter is implemented by drive managers that can validate their
+// storage configuration against the cluster.
+type Preflighter interface {
+	Preflight(ctx context.Context, sessionStorageClass, sessionStorageSize string) *PreflightReport
+}
+
+// Preflight verifies that the session and workspace drive storage classes
+// exist, that the drive class supports the access mode drives will request
+// and volume expansion, and that the configured sizes are sane.
+func (m *WorkspaceDriveManager) Preflight(ctx context.Context, sessionStorageClass, sessionStorageSize string) *PreflightReport {
+	report := &PreflightReport{CheckedAt: time.Now()}
+
+	if sessionStorageClass != m.storageClassName {
+		if sc, err := lookupStorageClass(ctx, m.clientset, sessionStorageClass); err != nil {
+			report.add("session_storage_class", false, SeverityError, "%v", err)
+		} else {
+			report.add("session_storage_class", true, SeverityError, "storage class %s (provisioner %s)", sc.Name, sc.Provisioner)
+		}
+	}
+
+	sc, err := lookupStorageClass(ctx, m.clientset, m.storageClassName)
+	if err != nil {
+		report.add("drive_storage_class", false, SeverityError, "%v", err)
+	} else {
+		report.add("drive_storage_class", true, SeverityError, "storage class %s (provisioner %s)", sc.Name, sc.Provisioner)
+		checkDriveStorageClass(report, sc, m.accessMode)
+	}
+
+	checkSessionSize(report, sessionStorageSize)
+	checkDriveSize(report, m.storageSize)
+	return report
+}
+
+func (a *K8sDriveAdapter) Preflight(ctx context.Context, sessionStorageClass, sessionStorageSize string) *PreflightReport {
+	return a.mgr.Preflight(ctx, sessionStorageClass, sessionStorageSize)
+}
+
+var _ Preflighter = (*K8sDriveAdapter)(nil)
+
+func checkDriveStorageClass(report *PreflightReport, sc *storagev1.StorageClass, mode corev1.PersistentVolumeAccessMode) {
+	if mode == corev1.ReadWriteMany && !provisionerSupportsRWX(sc.Provisioner) {
+		report.add("drive_access_mode", false, SeverityWarning,
+			"drives request ReadWriteMany but provisioner %s is not known to support it; set WORKSPACE_DRIVE_ACCESS_MODE=rwo if mounts fail", sc.Provisioner)
+	} else {
+		report.add("drive_access_mode", true, SeverityWarning, "drives request %s", mode)
+	}
+
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		report.add("drive_expansion", false, SeverityWarning,
+			"storage class %s does not allow volume expansion; raising drive quotas will not grow existing drives", sc.Name)
+	} else {
+		report.add("drive_expansion", true, SeverityWarning, "volume expansion allowed")
+	}
+}
+
+func checkSessionSize(report *PreflightReport, size string) {
+	q, err := resource.ParseQuantity(size)
+	if err != nil {
+		report.add("session_storage_size", false, SeverityError, "invalid SESSION_STORAGE_SIZE %q: %v", size, err)
+		return
+	}
+	if q.Value() < minDriveSize {
+		report.add("session_storage_size", false, SeverityWarning, "session PVC size %s is below 1Gi; the agent home directory may not fit", size)
+		return
+	}
+	report.add("session_storage_size", true, SeverityWarning, "session PVC size %s", size)
+}
+
+func checkDriveSize(report *PreflightReport, size int64) {
+	switch {
+	case size <= 0:
+		report.add("drive_size", false, SeverityError, "workspace drive size must be positive, got %d", size)
+	case size < minDriveSize:
+		report.add("drive_size", false, SeverityWarning, "workspace drive size %d bytes is below 1Gi", size)
+	case size > maxDriveSize:
+		report.add("drive_size", false, SeverityWarning, "workspace drive size %d bytes exceeds 10Ti; check USER_DRIVE_SIZE units", size)
+	default:
+		report.add("drive_size", true, SeverityWarning, "workspace drive size %s", resource.NewQuantity(size, resource.BinarySI))
+	}
+}
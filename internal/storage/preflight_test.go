@@ -0,0 +1,24 @@
+package storage
+
+import "testing"
+
+func TestPreflightSizeChecks(t *testing.T) {
+	r := &PreflightReport{}
+	checkSessionSize(r, "5Gi")
+	checkDriveSize(r, 10<<30)
+	if !r.Healthy() || !r.Checks[0].OK || !r.Checks[1].OK {
+		t.Fatalf("expected sane sizes to pass: %+v", r.Checks)
+	}
+
+	r = &PreflightReport{}
+	checkSessionSize(r, "five gigs")
+	if r.Healthy() {
+		t.Error("unparseable session size should fail")
+	}
+
+	r = &PreflightReport{}
+	checkDriveSize(r, 100<<20)
+	if !r.Healthy() || r.Checks[0].OK {
+		t.Errorf("small drive should be a warning only: %+v", r.Checks)
+	}
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// DriveSnapshotter is implemented by drive managers that can snapshot a
+// workspace's drives, e.g. before archiving the workspace.
+type DriveSnapshotter interface {
+	SnapshotDrive(ctx context.Context, workspaceID, namespace string) ([]string, error)
+}
+
+// SetSnapshotClassName sets the VolumeSnapshotClass used for drive snapshots.
+// Empty uses the cluster default class.
+func (m *WorkspaceDriveManager) SetSnapshotClassName(name string) {
+	m.snapshotClassName = name
+}
+
+// SnapshotPVCs creates a CSI VolumeSnapshot of every workspace drive PVC and
+// returns the snapshot names. Snapshots are labelled with the workspace ID
+// and survive the PVC, so they can be restored after the workspace is
+// unarchived or even deleted.
+func (m *WorkspaceDriveManager) SnapshotPVCs(ctx context.Context, workspaceID, namespace string) ([]string, error) {
+	volumes, err := m.db.ListWorkspaceVolumes(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := strconv.FormatInt(time.Now().Unix(), 10)
+	var names []string
+	for _, v := range volumes {
+		name := v.PVCName + "-archive-" + suffix
+		spec := map[string]interface{}{
+			"source": map[string]interface{}{"persistentVolumeClaimName": v.PVCName},
+		}
+		if m.snapshotClassName != "" {
+			spec["volumeSnapshotClassName"] = m.snapshotClassName
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+				"labels": map[string]string{
+					"managed-by":   "agentserver",
+					"workspace-id": workspaceID,
+				},
+			},
+			"spec": spec,
+		})
+		if err != nil {
+			return names, fmt.Errorf("marshal volume snapshot: %w", err)
+		}
+		err = m.clientset.Discovery().RESTClient().Post().
+			AbsPath("/apis/snapshot.storage.k8s.io/v1/namespaces", namespace, "volumesnapshots").
+			SetHeader("Content-Type", "application/json").
+			Body(body).
+			Do(ctx).
+			Error()
+		if err != nil {
+			return names, fmt.Errorf("create volume snapshot %s: %w", name, err)
+		}
+		log.Printf("Created volume snapshot %s of PVC %s for workspace %s", name, v.PVCName, workspaceID)
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (a *K8sDriveAdapter) SnapshotDrive(ctx context.Context, workspaceID, namespace string) ([]string, error) {
+	return a.mgr.SnapshotPVCs(ctx, workspaceID, namespace)
+}
+
+var _ DriveSnapshotter = (*K8sDriveAdapter)(nil)
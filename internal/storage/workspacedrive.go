@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,6 +24,11 @@ type WorkspaceDriveManager struct {
 	clientset        kubernetes.Interface
 	storageSize      int64 // bytes
 	storageClassName string
+
+	snapshotClassName string
+
+	accessModeStrategies map[string]AccessModeStrategy
+	accessMode           corev1.PersistentVolumeAccessMode
 }
 
 // NewWorkspaceDriveManager creates a K8s-backed workspace drive manager.
@@ -32,6 +38,7 @@ func NewWorkspaceDriveManager(database *db.DB, clientset kubernetes.Interface, s
 		clientset:        clientset,
 		storageSize:      storageSize,
 		storageClassName: storageClassName,
+		accessMode:       corev1.ReadWriteMany,
 	}
 }
 
@@ -55,11 +62,15 @@ func (m *WorkspaceDriveManager) EnsurePVC(ctx context.Context, workspaceID, name
 		var mounts []process.VolumeMount
 		for _, v := range volumes {
 			// Verify PVC exists.
-			_, err := m.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, v.PVCName, metav1.GetOptions{})
+			pvc, err := m.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, v.PVCName, metav1.GetOptions{})
 			if err != nil && !errors.IsNotFound(err) {
 				return nil, fmt.Errorf("check existing PVC %s: %w", v.PVCName, err)
 			}
-			mounts = append(mounts, process.VolumeMount{PVCName: v.PVCName, MountPath: v.MountPath})
+			mount := process.VolumeMount{PVCName: v.PVCName, MountPath: v.MountPath}
+			if err == nil {
+				mount.SingleAttach = singleAttach(pvc.Spec.AccessModes)
+			}
+			mounts = append(mounts, mount)
 		}
 		return mounts, nil
 	}
@@ -69,13 +80,13 @@ func (m *WorkspaceDriveManager) EnsurePVC(ctx context.Context, workspaceID, name
 	mountPath := "/home/agent/projects"
 
 	// Check if PVC already exists in the target namespace.
-	_, err = m.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
+	existing, err := m.clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
 	if err == nil {
 		// PVC exists, just record in DB.
 		if err := m.db.AddWorkspaceVolume(uuid.New().String(), workspaceID, pvcName, mountPath); err != nil {
 			return nil, err
 		}
-		return []process.VolumeMount{{PVCName: pvcName, MountPath: mountPath}}, nil
+		return []process.VolumeMount{{PVCName: pvcName, MountPath: mountPath, SingleAttach: singleAttach(existing.Spec.AccessModes)}}, nil
 	}
 	if !errors.IsNotFound(err) {
 		return nil, fmt.Errorf("check existing PVC: %w", err)
@@ -93,7 +104,7 @@ func (m *WorkspaceDriveManager) EnsurePVC(ctx context.Context, workspaceID, name
 			},
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			AccessModes: []corev1.PersistentVolumeAccessMode{m.accessMode},
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{corev1.ResourceStorage: storageQty},
 			},
@@ -106,12 +117,12 @@ func (m *WorkspaceDriveManager) EnsurePVC(ctx context.Context, workspaceID, name
 	if _, err := m.clientset.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{}); err != nil {
 		return nil, fmt.Errorf("create workspace drive PVC: %w", err)
 	}
-	log.Printf("Created workspace drive PVC %s for workspace %s", pvcName, workspaceID)
+	log.Printf("Created workspace drive PVC %s (%s) for workspace %s", pvcName, m.accessMode, workspaceID)
 
 	if err := m.db.AddWorkspaceVolume(uuid.New().String(), workspaceID, pvcName, mountPath); err != nil {
 		return nil, err
 	}
-	return []process.VolumeMount{{PVCName: pvcName, MountPath: mountPath}}, nil
+	return []process.VolumeMount{{PVCName: pvcName, MountPath: mountPath, SingleAttach: m.accessMode == corev1.ReadWriteOnce}}, nil
 }
 
 func shortID(id string) string {
@@ -121,18 +132,23 @@ func shortID(id string) string {
 	return id
 }
 
-// DockerWorkspaceDriveManager handles workspace Docker volume creation.
+// DockerWorkspaceDriveManager handles workspace Docker volume lifecycle.
 type DockerWorkspaceDriveManager struct {
-	db *db.DB
+	db  *db.DB
+	cli DockerVolumeClient // nil leaves volumes to be created on first mount and never removed
+
+	usageMu sync.Mutex
+	usage   map[string]int64 // volume sizes from the last `docker system df`
+	usageAt time.Time
 }
 
 // NewDockerWorkspaceDriveManager creates a Docker-backed workspace drive manager.
-func NewDockerWorkspaceDriveManager(database *db.DB) *DockerWorkspaceDriveManager {
-	return &DockerWorkspaceDriveManager{db: database}
+func NewDockerWorkspaceDriveManager(database *db.DB, cli DockerVolumeClient) *DockerWorkspaceDriveManager {
+	return &DockerWorkspaceDriveManager{db: database, cli: cli}
 }
 
 // EnsureVolume ensures a Docker named volume exists for the workspace.
-func (m *DockerWorkspaceDriveManager) EnsureVolume(workspaceID string) ([]process.VolumeMount, error) {
+func (m *DockerWorkspaceDriveManager) EnsureVolume(ctx context.Context, workspaceID string) ([]process.VolumeMount, error) {
 	ws, err := m.db.GetWorkspace(workspaceID)
 	if err != nil {
 		return nil, err
@@ -154,12 +170,15 @@ func (m *DockerWorkspaceDriveManager) EnsureVolume(workspaceID string) ([]proces
 		return mounts, nil
 	}
 
-	volumeName := "cli-ws-" + shortID(workspaceID) + "-disk"
+	volumeName := dockerVolumePrefix + shortID(workspaceID) + dockerVolumeSuffix
 	mountPath := "/home/agent/projects"
 	if err := m.db.AddWorkspaceVolume(uuid.New().String(), workspaceID, volumeName, mountPath); err != nil {
 		return nil, err
 	}
-	// Docker volumes are created automatically when first referenced in a mount.
+	if err := m.createVolume(ctx, volumeName, workspaceID); err != nil {
+		// Docker creates the volume on first mount anyway, just unlabelled.
+		log.Printf("Warning: %v", err)
+	}
 	return []process.VolumeMount{{PVCName: volumeName, MountPath: mountPath}}, nil
 }
 
@@ -191,9 +210,8 @@ func NewDockerDriveAdapter(mgr *DockerWorkspaceDriveManager) DriveManager {
 }
 
 func (a *DockerDriveAdapter) EnsureDrive(ctx context.Context, workspaceID, namespace string) ([]process.VolumeMount, error) {
-	_ = ctx
 	_ = namespace
-	return a.mgr.EnsureVolume(workspaceID)
+	return a.mgr.EnsureVolume(ctx, workspaceID)
 }
 
 // NilDriveManager is a no-op drive manager for when storage is not configured.
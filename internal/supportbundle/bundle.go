@@ -0,0 +1,69 @@
+// Package supportbundle writes the archive produced by
+// `agentserver support-bundle` and sanitizes what goes into it.
+package supportbundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Writer writes a support bundle as a gzipped tar archive. All entries are
+// placed under a single top-level directory.
+type Writer struct {
+	gz  *gzip.Writer
+	tw  *tar.Writer
+	dir string
+	now time.Time
+}
+
+// NewWriter starts a bundle on w with entries under dir.
+func NewWriter(w io.Writer, dir string) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{gz: gz, tw: tar.NewWriter(gz), dir: dir, now: time.Now()}
+}
+
+// Add writes one file to the bundle.
+func (b *Writer) Add(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    b.dir + "/" + name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: b.now,
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// AddJSON writes v as indented JSON.
+func (b *Writer) AddJSON(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+	return b.Add(name, data)
+}
+
+// AddResult writes v as JSON, or err as "<name>.error" if collection failed.
+func (b *Writer) AddResult(name string, v interface{}, err error) error {
+	if err != nil {
+		return b.Add(name+".error", []byte(err.Error()+"\n"))
+	}
+	return b.AddJSON(name, v)
+}
+
+// Close flushes the archive.
+func (b *Writer) Close() error {
+	if err := b.tw.Close(); err != nil {
+		return err
+	}
+	return b.gz.Close()
+}
@@ -0,0 +1,84 @@
+package supportbundle
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveKeyRe matches configuration names whose values are secrets or
+// may embed them (e.g. OPENCODE_CONFIG_CONTENT carries provider settings).
+var sensitiveKeyRe = regexp.MustCompile(`SECRET|TOKEN|PASSWORD|PASSWD|CREDENTIAL|PRIVATE|_KEY$|_KEYS$|_KEY_|^KEY$|CONTENT|COOKIE|AUTH_HEADER`)
+
+// inlineURLRe finds URLs inside free text, e.g. log lines.
+var inlineURLRe = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// bearerRe matches bearer/basic credentials in free text.
+var bearerRe = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]{8,}`)
+
+// SanitizeEnv turns an os.Environ()-style list into a map with secret values
+// redacted and credentials stripped from URLs.
+func SanitizeEnv(environ []string) map[string]string {
+	out := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		out[k] = SanitizeValue(k, v)
+	}
+	return out
+}
+
+// SanitizeValue redacts v if key names a secret, and otherwise strips any
+// credentials embedded in URLs.
+func SanitizeValue(key, v string) string {
+	if v == "" {
+		return v
+	}
+	if sensitiveKeyRe.MatchString(strings.ToUpper(key)) {
+		return redacted
+	}
+	return SanitizeText(v)
+}
+
+// SanitizeText strips URL credentials and bearer/basic tokens from free
+// text such as log output.
+func SanitizeText(s string) string {
+	s = inlineURLRe.ReplaceAllStringFunc(s, redactURL)
+	return bearerRe.ReplaceAllString(s, "$1 "+redacted)
+}
+
+// redactURL removes the password from a URL's userinfo and blanks query
+// parameters that look like secrets.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	changed := false
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			changed = true
+		}
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			if sensitiveKeyRe.MatchString(strings.ToUpper(k)) {
+				q.Set(k, redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return u.String()
+}
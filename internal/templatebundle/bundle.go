@@ -0,0 +1,266 @@
+// Package templatebundle defines the portable sandbox template bundle: a
+// JSON or YAML document describing an agent environment (sandbox type,
+// image, resources, init scripts and the secrets it expects) that can be
+// exported from one agentserver instance and imported into another.
+// Bundles may carry an Ed25519 signature over their canonical JSON form.
+package templatebundle
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/agentserver/agentserver/internal/policy"
+)
+
+// APIVersion and Kind identify a template bundle document.
+const (
+	APIVersion = "agentserver.io/v1"
+	Kind       = "TemplateBundle"
+)
+
+// maxInitScripts bounds the scripts of a bundle; each runs as an exec.
+const maxInitScripts = 20
+
+// Bundle is a portable sandbox template.
+type Bundle struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   Metadata   `json:"metadata"`
+	Spec       Spec       `json:"spec"`
+	Signature  *Signature `json:"signature,omitempty"`
+}
+
+// Metadata names and describes a bundle.
+type Metadata struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	Author      string `json:"author,omitempty"`
+}
+
+// Spec is the environment a bundle creates.
+type Spec struct {
+	Type string `json:"type"`
+	// Image replaces the sandbox type's default image; it must still be
+	// allowed by the importing instance's policy.
+	Image       string       `json:"image,omitempty"`
+	CPU         policy.Value `json:"cpu,omitempty"`
+	Memory      policy.Value `json:"memory,omitempty"`
+	IdleTimeout policy.Value `json:"idleTimeout,omitempty"`
+	Browser     bool         `json:"browser,omitempty"`
+	// InitScripts run in order, once, after the sandbox first starts.
+	InitScripts []Script `json:"initScripts,omitempty"`
+	// Secrets lists the credentials the environment expects. Values are
+	// never part of a bundle.
+	Secrets []SecretRequirement `json:"secrets,omitempty"`
+}
+
+// Script is a shell script run in the sandbox with sh -c.
+type Script struct {
+	Name string `json:"name"`
+	Run  string `json:"run"`
+}
+
+// SecretRequirement describes a credential the environment needs.
+type SecretRequirement struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
+}
+
+// Signature is an Ed25519 signature of the bundle's canonical form.
+type Signature struct {
+	KeyID     string `json:"keyId"`
+	Algorithm string `json:"algorithm"` // "ed25519"
+	Value     string `json:"value"`     // base64
+}
+
+var (
+	namePattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,62}[a-z0-9])?$`)
+	secretPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ErrUnsigned is returned by Verify for a bundle without a signature.
+var ErrUnsigned = errors.New("bundle is not signed")
+
+// Parse decodes and validates a YAML or JSON bundle. Unknown fields are
+// rejected so typos don't silently drop settings.
+func Parse(data []byte) (*Bundle, error) {
+	var b Bundle
+	if err := yaml.UnmarshalStrict(data, &b); err != nil {
+		return nil, fmt.Errorf("parse bundle: %w", err)
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Validate checks the bundle's identity, spec and signature shape.
+func (b *Bundle) Validate() error {
+	if b.APIVersion != APIVersion || b.Kind != Kind {
+		return fmt.Errorf("not a template bundle: want apiVersion %s and kind %s", APIVersion, Kind)
+	}
+	if !namePattern.MatchString(b.Metadata.Name) {
+		return fmt.Errorf("metadata.name %q: want lowercase letters, digits, '.', '-' or '_', at most 64 characters", b.Metadata.Name)
+	}
+	s := b.Spec
+	if !policy.ValidType(s.Type) {
+		return fmt.Errorf("spec.type: unknown sandbox type %q", s.Type)
+	}
+	if strings.ContainsAny(s.Image, " \t\n") {
+		return fmt.Errorf("spec.image: invalid image reference %q", s.Image)
+	}
+	if _, err := s.CPU.Millicores(); s.CPU != "" && err != nil {
+		return fmt.Errorf("spec.cpu: %w", err)
+	}
+	if _, err := s.Memory.Bytes(); s.Memory != "" && err != nil {
+		return fmt.Errorf("spec.memory: %w", err)
+	}
+	if _, err := s.IdleTimeout.Seconds(); s.IdleTimeout != "" && err != nil {
+		return fmt.Errorf("spec.idleTimeout: %w", err)
+	}
+	if len(s.InitScripts) > maxInitScripts {
+		return fmt.Errorf("spec.initScripts: at most %d scripts", maxInitScripts)
+	}
+	for i, sc := range s.InitScripts {
+		if sc.Name == "" || strings.TrimSpace(sc.Run) == "" {
+			return fmt.Errorf("spec.initScripts[%d]: name and run are required", i)
+		}
+	}
+	seen := make(map[string]bool)
+	for i, sec := range s.Secrets {
+		if !secretPattern.MatchString(sec.Name) {
+			return fmt.Errorf("spec.secrets[%d]: name %q must be an environment variable name", i, sec.Name)
+		}
+		if seen[sec.Name] {
+			return fmt.Errorf("spec.secrets: duplicate name %q", sec.Name)
+		}
+		seen[sec.Name] = true
+	}
+	if sig := b.Signature; sig != nil && (sig.Algorithm != "ed25519" || sig.KeyID == "" || sig.Value == "") {
+		return fmt.Errorf("signature: want keyId, algorithm ed25519 and value")
+	}
+	return nil
+}
+
+// canonical returns the signed form of the bundle: its JSON encoding
+// without the signature. encoding/json emits struct fields in declaration
+// order, so the form is stable across instances.
+func (b *Bundle) canonical() ([]byte, error) {
+	c := *b
+	c.Signature = nil
+	return json.Marshal(&c)
+}
+
+// Sign sets the bundle's signature with key, identified by keyID.
+func (b *Bundle) Sign(keyID string, key ed25519.PrivateKey) error {
+	data, err := b.canonical()
+	if err != nil {
+		return err
+	}
+	b.Signature = &Signature{
+		KeyID:     keyID,
+		Algorithm: "ed25519",
+		Value:     base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}
+	return nil
+}
+
+// Verify checks the signature against the trusted keys (key ID -> public
+// key). It returns ErrUnsigned for an unsigned bundle.
+func (b *Bundle) Verify(trusted map[string]ed25519.PublicKey) error {
+	if b.Signature == nil {
+		return ErrUnsigned
+	}
+	pub, ok := trusted[b.Signature.KeyID]
+	if !ok {
+		return fmt.Errorf("signed with untrusted key %q", b.Signature.KeyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(b.Signature.Value)
+	if err != nil {
+		return fmt.Errorf("signature: %w", err)
+	}
+	data, err := b.canonical()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return fmt.Errorf("signature does not match key %q", b.Signature.KeyID)
+	}
+	return nil
+}
+
+// FromTemplate returns an unsigned bundle for a policy template.
+func FromTemplate(t policy.Template) *Bundle {
+	return &Bundle{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Metadata:   Metadata{Name: t.Name, Description: t.Description},
+		Spec: Spec{
+			Type:        t.Type,
+			CPU:         t.CPU,
+			Memory:      t.Memory,
+			IdleTimeout: t.IdleTimeout,
+			Browser:     t.Browser,
+		},
+	}
+}
+
+// Template returns the policy template form of the bundle, as listed by
+// the sandbox templates API.
+func (b *Bundle) Template() policy.Template {
+	return policy.Template{
+		Name:        b.Metadata.Name,
+		Description: b.Metadata.Description,
+		Type:        b.Spec.Type,
+		CPU:         b.Spec.CPU,
+		Memory:      b.Spec.Memory,
+		IdleTimeout: b.Spec.IdleTimeout,
+		Browser:     b.Spec.Browser,
+	}
+}
+
+// ParsePrivateKey decodes a base64 Ed25519 private key or 32-byte seed.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("signing key: want a %d-byte seed or %d-byte key, got %d bytes", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+// ParseTrustedKeys parses "keyID=base64pubkey,..." into a key map.
+func ParseTrustedKeys(s string) (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, enc, ok := strings.Cut(entry, "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("trusted key %q: want keyID=base64", entry)
+		}
+		raw, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("trusted key %q: want a base64 %d-byte Ed25519 public key", id, ed25519.PublicKeySize)
+		}
+		keys[id] = ed25519.PublicKey(raw)
+	}
+	return keys, nil
+}
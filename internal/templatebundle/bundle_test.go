@@ -0,0 +1,101 @@
+package templatebundle
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const sample = `
+apiVersion: agentserver.io/v1
+kind: TemplateBundle
+metadata:
+  name: python-ml
+  version: "1.2"
+  description: Python with the usual ML stack
+spec:
+  type: jupyter
+  image: ghcr.io/acme/jupyter-ml:1.2
+  cpu: 4
+  memory: 8Gi
+  idleTimeout: 2h
+  initScripts:
+    - name: deps
+      run: pip install --user -r ~/requirements.txt
+  secrets:
+    - name: HF_TOKEN
+      description: Hugging Face token for model downloads
+`
+
+func TestParse(t *testing.T) {
+	b, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Metadata.Name != "python-ml" || b.Spec.Type != "jupyter" || b.Spec.CPU != "4" || len(b.Spec.InitScripts) != 1 {
+		t.Errorf("parsed %+v", b)
+	}
+	tmpl := b.Template()
+	if mem, _ := tmpl.Memory.Bytes(); mem != 8<<30 {
+		t.Errorf("template memory = %d", mem)
+	}
+
+	for name, bad := range map[string]string{
+		"kind":    strings.Replace(sample, "TemplateBundle", "Policy", 1),
+		"name":    strings.Replace(sample, "python-ml", "Python ML", 1),
+		"type":    strings.Replace(sample, "type: jupyter", "type: vm", 1),
+		"memory":  strings.Replace(sample, "8Gi", "lots", 1),
+		"secret":  strings.Replace(sample, "HF_TOKEN", "hf-token", 1),
+		"unknown": sample + "extra: true\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%s: invalid bundle accepted", name)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Parse([]byte(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := map[string]ed25519.PublicKey{"acme": pub}
+	if err := b.Verify(trusted); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("Verify unsigned = %v, want ErrUnsigned", err)
+	}
+	if err := b.Sign("acme", priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Verify(trusted); err != nil {
+		t.Fatalf("Verify signed: %v", err)
+	}
+	if err := b.Verify(map[string]ed25519.PublicKey{"other": pub}); err == nil {
+		t.Error("signature by an untrusted key accepted")
+	}
+	b.Spec.Image = "ghcr.io/evil/miner:latest"
+	if err := b.Verify(trusted); err == nil {
+		t.Error("tampered bundle accepted")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	key, err := ParsePrivateKey(base64.StdEncoding.EncodeToString(priv.Seed()))
+	if err != nil || !key.Equal(priv) {
+		t.Fatalf("ParsePrivateKey(seed) = %v, %v", key, err)
+	}
+	keys, err := ParseTrustedKeys("acme=" + base64.StdEncoding.EncodeToString(pub) + ", ")
+	if err != nil || !keys["acme"].Equal(pub) {
+		t.Fatalf("ParseTrustedKeys = %v, %v", keys, err)
+	}
+	if _, err := ParseTrustedKeys("acme=AAAA"); err == nil {
+		t.Error("short public key accepted")
+	}
+}
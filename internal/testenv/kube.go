@@ -0,0 +1,92 @@
+//go:build integration
+
+package testenv
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// sandboxCRDTemplate is the Sandbox CRD as shipped in the Helm chart,
+// relative to the repository root.
+const sandboxCRDTemplate = "deploy/helm/agentserver/templates/agent-sandbox-crd.yaml"
+
+var (
+	kubeOnce sync.Once
+	kubeCfg  *rest.Config
+	kubeErr  error
+)
+
+// Kube returns the config of an envtest control plane with the Sandbox CRD
+// installed, skipping the test if KUBEBUILDER_ASSETS is not set. There is no
+// controller or kubelet: tests play the agent-sandbox controller themselves.
+func Kube(t testing.TB) *rest.Config {
+	t.Helper()
+	kubeOnce.Do(func() {
+		if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+			kubeErr = fmt.Errorf("KUBEBUILDER_ASSETS not set")
+			return
+		}
+		dir, err := os.MkdirTemp("", "testenv-crds-")
+		if err != nil {
+			kubeErr = err
+			return
+		}
+		onTeardown(func() { os.RemoveAll(dir) })
+		if kubeErr = writeSandboxCRD(dir); kubeErr != nil {
+			return
+		}
+		env := &envtest.Environment{
+			CRDDirectoryPaths:     []string{dir},
+			ErrorIfCRDPathMissing: true,
+		}
+		kubeCfg, kubeErr = env.Start()
+		if kubeErr != nil {
+			return
+		}
+		onTeardown(func() {
+			if err := env.Stop(); err != nil {
+				logf("stop envtest: %v", err)
+			}
+		})
+	})
+	if kubeErr != nil {
+		t.Skipf("kubernetes unavailable: %v", kubeErr)
+	}
+	return kubeCfg
+}
+
+// writeSandboxCRD writes the chart's Sandbox CRD to dir without the Helm
+// template directives around it.
+func writeSandboxCRD(dir string) error {
+	_, file, _, _ := runtime.Caller(0)
+	root := filepath.Join(filepath.Dir(file), "..", "..")
+	raw, err := os.ReadFile(filepath.Join(root, sandboxCRDTemplate))
+	if err != nil {
+		return fmt.Errorf("read sandbox CRD: %w", err)
+	}
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if strings.HasPrefix(strings.TrimSpace(sc.Text()), "{{") {
+			continue
+		}
+		out.Write(sc.Bytes())
+		out.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read sandbox CRD: %w", err)
+	}
+	return os.WriteFile(filepath.Join(dir, "sandbox-crd.yaml"), out.Bytes(), 0o644)
+}
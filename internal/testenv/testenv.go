@@ -0,0 +1,53 @@
+//go:build integration
+
+// Package testenv provides the disposable dependencies of the integration
+// tests (go test -tags integration): a Postgres container started through
+// the Docker API and a kube-apiserver + etcd control plane from
+// controller-runtime's envtest.
+//
+// Both are started on first use and shared by the tests of a package; Run
+// tears them down. Tests that need one skip when it is unavailable, so the
+// suite runs as far as the host allows:
+//
+//   - Postgres: TEST_DATABASE_URL if set, otherwise a container (needs a
+//     Docker daemon; TESTENV_POSTGRES_IMAGE overrides the image).
+//   - Kubernetes: needs KUBEBUILDER_ASSETS pointing at kube-apiserver and
+//     etcd binaries (`setup-envtest use -p path`).
+package testenv
+
+import (
+	"log"
+	"sync"
+	"testing"
+)
+
+var (
+	mu       sync.Mutex
+	teardown []func()
+)
+
+// onTeardown registers f to run when the test binary finishes.
+func onTeardown(f func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	teardown = append(teardown, f)
+}
+
+// Run runs the tests and then stops the dependencies they started. Use it
+// from TestMain:
+//
+//	func TestMain(m *testing.M) { os.Exit(testenv.Run(m)) }
+func Run(m *testing.M) int {
+	code := m.Run()
+	mu.Lock()
+	defer mu.Unlock()
+	for i := len(teardown) - 1; i >= 0; i-- {
+		teardown[i]()
+	}
+	teardown = nil
+	return code
+}
+
+func logf(format string, args ...interface{}) {
+	log.Printf("testenv: "+format, args...)
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/yamux"
 	"nhooyr.io/websocket"
@@ -67,6 +69,9 @@ type Tunnel struct {
 	done      chan struct{}
 	closeOnce sync.Once
 
+	stats          tunnelStats
+	bandwidthLimit atomic.Int64
+
 	// OnAgentInfo is called when the agent sends a control message with agent info.
 	OnAgentInfo func(data json.RawMessage)
 }
@@ -90,6 +95,7 @@ func newTunnel(ctx context.Context, sandboxID string, ws *websocket.Conn) *Tunne
 		wsConn:    conn,
 		done:      make(chan struct{}),
 	}
+	t.stats.connectedAt = time.Now()
 	go t.acceptLoop()
 	return t
 }
@@ -143,10 +149,23 @@ func (t *Tunnel) OpenHTTPStream(ctx context.Context, meta HTTPStreamMeta, reqBod
 		return HTTPResponseMeta{}, nil, yamux.ErrSessionShutdown
 	}
 
-	stream, err := t.mux.Open()
+	start := time.Now()
+	t.stats.requests.Add(1)
+	respMeta, stream, err := t.openHTTPStream(meta, reqBody)
+	if err != nil {
+		t.stats.requestErrors.Add(1)
+		return HTTPResponseMeta{}, nil, err
+	}
+	t.stats.latencyNanos.Add(int64(time.Since(start)))
+	return respMeta, stream, nil
+}
+
+func (t *Tunnel) openHTTPStream(meta HTTPStreamMeta, reqBody []byte) (HTTPResponseMeta, io.ReadCloser, error) {
+	s, err := t.mux.Open()
 	if err != nil {
 		return HTTPResponseMeta{}, nil, err
 	}
+	stream := t.trackStream(s)
 
 	// Set body length in metadata so agent knows when request body ends.
 	meta.BodyLen = len(reqBody)
@@ -192,10 +211,11 @@ func (t *Tunnel) OpenTerminalStream() (net.Conn, error) {
 	if t.mux == nil {
 		return nil, yamux.ErrSessionShutdown
 	}
-	stream, err := t.mux.Open()
+	s, err := t.mux.Open()
 	if err != nil {
 		return nil, err
 	}
+	stream := t.trackStream(s)
 	if err := WriteStreamHeader(stream, StreamTypeTerminal, nil); err != nil {
 		stream.Close()
 		return nil, err
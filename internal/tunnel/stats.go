@@ -0,0 +1,153 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minBandwidthBurst keeps the limiter burst large enough for yamux frames
+// under small bandwidth caps.
+const minBandwidthBurst = 64 << 10
+
+// Stats is a snapshot of a tunnel's traffic counters. Bytes are counted at
+// the WebSocket layer, so they include yamux framing.
+type Stats struct {
+	BytesIn        int64         `json:"bytes_in"`  // agent → server
+	BytesOut       int64         `json:"bytes_out"` // server → agent
+	ActiveStreams  int64         `json:"active_streams"`
+	Requests       int64         `json:"requests"` // HTTP streams opened
+	RequestErrors  int64         `json:"request_errors"`
+	LatencySum     time.Duration `json:"-"` // time to response header, over Requests - RequestErrors
+	ConnectedAt    time.Time     `json:"connected_at"`
+	BandwidthLimit int64         `json:"bandwidth_limit"` // bytes/s per direction, 0 = unlimited
+}
+
+// AvgLatency returns the mean time to response header of successful HTTP
+// streams.
+func (s Stats) AvgLatency() time.Duration {
+	ok := s.Requests - s.RequestErrors
+	if ok <= 0 {
+		return 0
+	}
+	return s.LatencySum / time.Duration(ok)
+}
+
+type tunnelStats struct {
+	activeStreams atomic.Int64
+	requests      atomic.Int64
+	requestErrors atomic.Int64
+	latencyNanos  atomic.Int64
+	connectedAt   time.Time
+}
+
+// bandwidth throttles one direction of a tunnel. A nil limiter is unlimited.
+type bandwidth struct {
+	mu      sync.RWMutex
+	limiter *rate.Limiter
+}
+
+func (b *bandwidth) set(bytesPerSec int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if bytesPerSec <= 0 {
+		b.limiter = nil
+		return
+	}
+	burst := int(bytesPerSec)
+	if burst < minBandwidthBurst {
+		burst = minBandwidthBurst
+	}
+	if b.limiter == nil {
+		b.limiter = rate.NewLimiter(rate.Limit(bytesPerSec), burst)
+		return
+	}
+	b.limiter.SetLimit(rate.Limit(bytesPerSec))
+	b.limiter.SetBurst(burst)
+}
+
+// wait blocks until n bytes may pass.
+func (b *bandwidth) wait(ctx context.Context, n int) error {
+	b.mu.RLock()
+	l := b.limiter
+	b.mu.RUnlock()
+	if l == nil {
+		return nil
+	}
+	for n > 0 {
+		chunk := n
+		if burst := l.Burst(); chunk > burst {
+			chunk = burst
+		}
+		if err := l.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// Stats returns a snapshot of the tunnel's counters.
+func (t *Tunnel) Stats() Stats {
+	st := Stats{
+		ActiveStreams:  t.stats.activeStreams.Load(),
+		Requests:       t.stats.requests.Load(),
+		RequestErrors:  t.stats.requestErrors.Load(),
+		LatencySum:     time.Duration(t.stats.latencyNanos.Load()),
+		ConnectedAt:    t.stats.connectedAt,
+		BandwidthLimit: t.bandwidthLimit.Load(),
+	}
+	if t.wsConn != nil {
+		st.BytesIn = t.wsConn.bytesIn.Load()
+		st.BytesOut = t.wsConn.bytesOut.Load()
+	}
+	return st
+}
+
+// SetBandwidthLimit caps the tunnel's throughput in each direction, in
+// bytes per second; 0 removes the cap.
+func (t *Tunnel) SetBandwidthLimit(bytesPerSec int64) {
+	if bytesPerSec < 0 {
+		bytesPerSec = 0
+	}
+	t.bandwidthLimit.Store(bytesPerSec)
+	if t.wsConn != nil {
+		t.wsConn.in.set(bytesPerSec)
+		t.wsConn.out.set(bytesPerSec)
+	}
+}
+
+// trackedStream decrements the tunnel's active stream count once closed.
+type trackedStream struct {
+	net.Conn
+	once  sync.Once
+	stats *tunnelStats
+}
+
+func (t *Tunnel) trackStream(c net.Conn) *trackedStream {
+	t.stats.activeStreams.Add(1)
+	return &trackedStream{Conn: c, stats: &t.stats}
+}
+
+func (s *trackedStream) Close() error {
+	s.once.Do(func() { s.stats.activeStreams.Add(-1) })
+	return s.Conn.Close()
+}
+
+var _ io.ReadCloser = (*trackedStream)(nil)
+
+// All returns the registered tunnels.
+func (r *Registry) All() []*Tunnel {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tunnels := make([]*Tunnel, 0, len(r.tunnels))
+	for _, t := range r.tunnels {
+		tunnels = append(tunnels, t)
+	}
+	return tunnels
+}
@@ -0,0 +1,122 @@
+package tunnel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// connectAgent registers a tunnel served by a fake agent that answers every
+// HTTP stream with "ok".
+func connectAgent(t *testing.T) *Tunnel {
+	t.Helper()
+	reg := NewRegistry()
+	tunnels := make(chan *Tunnel, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		tun := reg.Register(context.Background(), "sbx-1", ws)
+		tunnels <- tun
+		<-tun.Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	ws, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ClientMux(NewWSConn(context.Background(), ws))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	go func() {
+		for {
+			stream, err := session.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer stream.Close()
+				_, meta, err := ReadStreamHeader(stream)
+				if err != nil {
+					return
+				}
+				var m HTTPStreamMeta
+				UnmarshalStreamMeta(meta, &m)
+				io.CopyN(io.Discard, stream, int64(m.BodyLen))
+				resp, _ := MarshalStreamMeta(HTTPResponseMeta{Status: 200})
+				WriteStreamHeader(stream, StreamTypeHTTP, resp)
+				stream.Write([]byte("ok"))
+			}()
+		}
+	}()
+
+	tun := <-tunnels
+	t.Cleanup(tun.Close)
+	return tun
+}
+
+func TestTunnelStats(t *testing.T) {
+	tun := connectAgent(t)
+
+	meta, body, err := tun.OpenHTTPStream(context.Background(), HTTPStreamMeta{Method: "POST", Path: "/"}, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Status != 200 {
+		t.Fatalf("status = %d", meta.Status)
+	}
+	if st := tun.Stats(); st.ActiveStreams != 1 {
+		t.Errorf("active streams = %d while open, want 1", st.ActiveStreams)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	body.Close()
+	if string(data) != "ok" {
+		t.Fatalf("body = %q", data)
+	}
+
+	st := tun.Stats()
+	if st.ActiveStreams != 0 || st.Requests != 1 || st.RequestErrors != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	if st.BytesOut < int64(len("hello")) || st.BytesIn < int64(len("ok")) {
+		t.Errorf("bytes in/out = %d/%d", st.BytesIn, st.BytesOut)
+	}
+	if st.AvgLatency() <= 0 || st.ConnectedAt.IsZero() {
+		t.Errorf("latency %v, connected at %v", st.AvgLatency(), st.ConnectedAt)
+	}
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	var b bandwidth
+	ctx := context.Background()
+	if err := b.wait(ctx, 10<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	b.set(1 << 20)
+	start := time.Now()
+	// The first MiB passes at once (burst); the next half takes ~0.5s.
+	if err := b.wait(ctx, 3<<19); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("1.5 MiB at 1 MiB/s took %v", d)
+	}
+
+	b.set(0)
+	start = time.Now()
+	if err := b.wait(ctx, 10<<20); err != nil || time.Since(start) > 100*time.Millisecond {
+		t.Errorf("unlimited wait took %v (err %v)", time.Since(start), err)
+	}
+}
@@ -9,9 +9,10 @@ import (
 
 // Stream types identify the purpose of each yamux stream.
 const (
-	StreamTypeHTTP     byte = 0x01 // HTTP proxy request (server → agent)
-	StreamTypeTerminal byte = 0x02 // Terminal bidirectional stream (server → agent)
-	StreamTypeControl  byte = 0x03 // Control message: agent info, etc. (agent → server)
+	StreamTypeHTTP      byte = 0x01 // HTTP proxy request (server → agent)
+	StreamTypeTerminal  byte = 0x02 // Terminal bidirectional stream (server → agent)
+	StreamTypeControl   byte = 0x03 // Control message: agent info, etc. (agent → server)
+	StreamTypeWebSocket byte = 0x04 // WebSocket bidirectional message stream (server → agent)
 )
 
 // WriteStreamHeader writes the stream header: [1 byte type][4 bytes metadata len][metadata].
@@ -52,11 +53,14 @@ func ReadStreamHeader(r io.Reader) (streamType byte, metadata []byte, err error)
 
 // HTTPStreamMeta is the metadata for an HTTP proxy stream (server → agent).
 // BodyLen indicates the number of request body bytes that follow the stream header.
+// Port, when set, targets an exposed sandbox port on the agent's host instead
+// of the agent's own HTTP handler.
 type HTTPStreamMeta struct {
 	Method  string            `json:"method"`
 	Path    string            `json:"path"`
 	Headers map[string]string `json:"headers"`
 	BodyLen int               `json:"body_len"`
+	Port    int               `json:"port,omitempty"`
 }
 
 // HTTPResponseMeta is the response header written by the agent on an HTTP stream.
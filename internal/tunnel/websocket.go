@@ -0,0 +1,211 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"nhooyr.io/websocket"
+)
+
+// WebSocket frame opcodes. Each frame carries one whole message, so text
+// and binary message boundaries survive the tunnel.
+const (
+	WSFrameText   byte = 0x01
+	WSFrameBinary byte = 0x02
+	WSFrameClose  byte = 0x08 // payload: 2-byte status code + reason
+)
+
+// MaxWebSocketMessage bounds a message carried through the tunnel.
+const MaxWebSocketMessage = 16 << 20
+
+// WriteWSFrame writes a frame: [1 byte opcode][4 bytes payload len][payload].
+func WriteWSFrame(w io.Writer, op byte, payload []byte) error {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = op
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	if _, err := w.Write(frame); err != nil {
+		return fmt.Errorf("write websocket frame: %w", err)
+	}
+	return nil
+}
+
+// ReadWSFrame reads a frame written by WriteWSFrame.
+func ReadWSFrame(r io.Reader) (op byte, payload []byte, err error) {
+	header := make([]byte, 5)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:5])
+	if n > MaxWebSocketMessage {
+		return 0, nil, fmt.Errorf("websocket frame too large: %d bytes", n)
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("read websocket frame: %w", err)
+	}
+	return header[0], payload, nil
+}
+
+// IsWebSocketHandshakeHeader reports whether a request header belongs to
+// the WebSocket handshake of one hop. Each side of the tunnel makes its
+// own handshake, so these are not forwarded; Sec-WebSocket-Protocol is,
+// to negotiate the subprotocol end to end.
+func IsWebSocketHandshakeHeader(key string) bool {
+	switch http.CanonicalHeaderKey(key) {
+	case "Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions":
+		return true
+	}
+	return false
+}
+
+// OpenWebSocketStream opens a new yamux stream for a WebSocket connection.
+// The caller must close the returned stream when done.
+//
+// Protocol:
+//  1. Server writes: stream header (StreamTypeWebSocket + HTTPStreamMeta of the upgrade request)
+//  2. Agent dials the WebSocket and writes: stream header (StreamTypeWebSocket +
+//     HTTPResponseMeta). Status 101 means connected; any other status ends the stream.
+//  3. Both sides write frames (WriteWSFrame) until a close frame or stream close.
+func (t *Tunnel) OpenWebSocketStream(ctx context.Context, meta HTTPStreamMeta) (HTTPResponseMeta, net.Conn, error) {
+	if t.mux == nil {
+		return HTTPResponseMeta{}, nil, yamux.ErrSessionShutdown
+	}
+	t.stats.requests.Add(1)
+	respMeta, stream, err := t.openWebSocketStream(ctx, meta)
+	if err != nil {
+		t.stats.requestErrors.Add(1)
+		return HTTPResponseMeta{}, nil, err
+	}
+	return respMeta, stream, nil
+}
+
+func (t *Tunnel) openWebSocketStream(ctx context.Context, meta HTTPStreamMeta) (HTTPResponseMeta, net.Conn, error) {
+	s, err := t.mux.Open()
+	if err != nil {
+		return HTTPResponseMeta{}, nil, err
+	}
+	stream := t.trackStream(s)
+
+	meta.BodyLen = 0
+	metaJSON, err := MarshalStreamMeta(meta)
+	if err != nil {
+		stream.Close()
+		return HTTPResponseMeta{}, nil, err
+	}
+	if err := WriteStreamHeader(stream, StreamTypeWebSocket, metaJSON); err != nil {
+		stream.Close()
+		return HTTPResponseMeta{}, nil, err
+	}
+
+	// The agent dials before answering; bound the wait by ctx.
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetReadDeadline(deadline)
+	}
+	_, respMetaJSON, err := ReadStreamHeader(stream)
+	if err != nil {
+		stream.Close()
+		return HTTPResponseMeta{}, nil, err
+	}
+	stream.SetReadDeadline(time.Time{})
+	var respMeta HTTPResponseMeta
+	if err := UnmarshalStreamMeta(respMetaJSON, &respMeta); err != nil {
+		stream.Close()
+		return HTTPResponseMeta{}, nil, err
+	}
+	return respMeta, stream, nil
+}
+
+// BridgeWebSocket forwards messages between a WebSocket connection and a
+// tunnel WebSocket stream until either side closes, passing the close
+// status on. It closes both before returning.
+func BridgeWebSocket(ctx context.Context, ws *websocket.Conn, stream net.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ws.SetReadLimit(MaxWebSocketMessage)
+
+	errCh := make(chan error, 2)
+	go func() { errCh <- wsToStream(ctx, ws, stream) }()
+	go func() { errCh <- streamToWS(ctx, stream, ws) }()
+	err := <-errCh
+	stream.Close()
+	cancel()
+	<-errCh
+	ws.Close(websocket.StatusGoingAway, "")
+	return err
+}
+
+// wsToStream forwards messages read from ws as frames, ending with a close
+// frame.
+func wsToStream(ctx context.Context, ws *websocket.Conn, stream net.Conn) error {
+	for {
+		typ, data, err := ws.Read(ctx)
+		if err != nil {
+			code, reason := websocket.StatusGoingAway, ""
+			var ce websocket.CloseError
+			if errors.As(err, &ce) {
+				code, reason = ce.Code, ce.Reason
+			}
+			WriteWSFrame(stream, WSFrameClose, closePayload(code, reason))
+			if ce.Code != 0 || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+		op := WSFrameText
+		if typ == websocket.MessageBinary {
+			op = WSFrameBinary
+		}
+		if err := WriteWSFrame(stream, op, data); err != nil {
+			return err
+		}
+	}
+}
+
+// streamToWS writes the frames read from stream to ws until a close frame.
+func streamToWS(ctx context.Context, stream net.Conn, ws *websocket.Conn) error {
+	for {
+		op, payload, err := ReadWSFrame(stream)
+		if err != nil {
+			ws.Close(websocket.StatusGoingAway, "tunnel closed")
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		switch op {
+		case WSFrameText, WSFrameBinary:
+			typ := websocket.MessageText
+			if op == WSFrameBinary {
+				typ = websocket.MessageBinary
+			}
+			if err := ws.Write(ctx, typ, payload); err != nil {
+				return err
+			}
+		case WSFrameClose:
+			code, reason := websocket.StatusNormalClosure, ""
+			if len(payload) >= 2 {
+				code, reason = websocket.StatusCode(binary.BigEndian.Uint16(payload)), string(payload[2:])
+			}
+			if err := ws.Close(code, reason); err != nil {
+				// Codes that must not be sent (1005, 1006, ...).
+				ws.Close(websocket.StatusGoingAway, reason)
+			}
+			return nil
+		}
+	}
+}
+
+func closePayload(code websocket.StatusCode, reason string) []byte {
+	p := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(p, uint16(code))
+	copy(p[2:], reason)
+	return p
+}
@@ -0,0 +1,77 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+func TestWSFrameRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	WriteWSFrame(&buf, WSFrameBinary, []byte{0, 1, 2})
+	WriteWSFrame(&buf, WSFrameText, nil)
+	if op, p, err := ReadWSFrame(&buf); err != nil || op != WSFrameBinary || !bytes.Equal(p, []byte{0, 1, 2}) {
+		t.Errorf("first frame = %d %v %v", op, p, err)
+	}
+	if op, p, err := ReadWSFrame(&buf); err != nil || op != WSFrameText || len(p) != 0 {
+		t.Errorf("second frame = %d %v %v", op, p, err)
+	}
+	if _, _, err := ReadWSFrame(&buf); err == nil {
+		t.Error("read past the last frame")
+	}
+}
+
+func TestBridgeWebSocket(t *testing.T) {
+	tunnelSide, agentSide := net.Pipe()
+	defer agentSide.Close()
+	bridged := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		bridged <- BridgeWebSocket(context.Background(), ws, tunnelSide)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	browser, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browser.CloseNow()
+
+	if err := browser.Write(ctx, websocket.MessageText, []byte(`{"type":"ping"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if op, p, err := ReadWSFrame(agentSide); err != nil || op != WSFrameText || string(p) != `{"type":"ping"}` {
+		t.Fatalf("frame from browser = %d %q %v", op, p, err)
+	}
+
+	go WriteWSFrame(agentSide, WSFrameBinary, []byte{0xff, 0x00})
+	if typ, p, err := browser.Read(ctx); err != nil || typ != websocket.MessageBinary || !bytes.Equal(p, []byte{0xff, 0x00}) {
+		t.Fatalf("message from agent = %v %v %v", typ, p, err)
+	}
+
+	go WriteWSFrame(agentSide, WSFrameClose, closePayload(4001, "session ended"))
+	_, _, err = browser.Read(ctx)
+	if code := websocket.CloseStatus(err); code != 4001 {
+		t.Errorf("browser close status = %v (%v), want 4001", code, err)
+	}
+	select {
+	case err := <-bridged:
+		if err != nil {
+			t.Errorf("BridgeWebSocket = %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("bridge did not return after close")
+	}
+}
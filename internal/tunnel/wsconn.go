@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"nhooyr.io/websocket"
@@ -24,6 +25,9 @@ type WSConn struct {
 	wmu    sync.Mutex // serializes writes
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	bytesIn, bytesOut atomic.Int64
+	in, out           bandwidth
 }
 
 // NewWSConn wraps a websocket.Conn into a net.Conn.
@@ -48,6 +52,12 @@ func (c *WSConn) Read(b []byte) (int, error) {
 	for {
 		if c.reader != nil {
 			n, err := c.reader.Read(b)
+			if n > 0 {
+				c.bytesIn.Add(int64(n))
+				if werr := c.in.wait(c.ctx, n); werr != nil {
+					return n, werr
+				}
+			}
 			if err == io.EOF {
 				c.reader = nil
 				if n > 0 {
@@ -71,9 +81,13 @@ func (c *WSConn) Write(b []byte) (int, error) {
 	c.wmu.Lock()
 	defer c.wmu.Unlock()
 
+	if err := c.out.wait(c.ctx, len(b)); err != nil {
+		return 0, err
+	}
 	if err := c.ws.Write(c.ctx, websocket.MessageBinary, b); err != nil {
 		return 0, err
 	}
+	c.bytesOut.Add(int64(len(b)))
 	return len(b), nil
 }
 
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -201,9 +202,25 @@ func (c *Client) handleStream(stream net.Conn, handlers Handlers) {
 
 	switch streamType {
 	case tunnel.StreamTypeHTTP:
-		if handlers.HTTP != nil {
+		var meta tunnel.HTTPStreamMeta
+		if json.Unmarshal(metaBytes, &meta) == nil && meta.Port != 0 {
+			handleHTTPStreamWithMeta(stream, metaBytes, portHandler(meta.Port, handlers.ForwardPorts))
+		} else if handlers.HTTP != nil {
 			handleHTTPStreamWithMeta(stream, metaBytes, handlers.HTTP)
 		}
+	case tunnel.StreamTypeWebSocket:
+		var meta tunnel.HTTPStreamMeta
+		if err := json.Unmarshal(metaBytes, &meta); err != nil {
+			return
+		}
+		target := handlers.WebSocket
+		if meta.Port != 0 {
+			target = ""
+			if handlers.ForwardPorts {
+				target = "ws://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(meta.Port))
+			}
+		}
+		handleWebSocketStream(stream, meta, target)
 	case tunnel.StreamTypeTerminal:
 		// Custom agents don't support terminal; close the stream.
 	}
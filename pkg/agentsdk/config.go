@@ -28,6 +28,8 @@ type Handlers struct {
 	Task         TaskHandler  // Assigned tasks (optional)
 	OnConnect    func()       // Called when tunnel connected
 	OnDisconnect func(error)  // Called when tunnel disconnected
+	ForwardPorts bool         // Serve exposed preview ports from 127.0.0.1 (optional)
+	WebSocket    string       // Base ws:// URL that proxied WebSocket connections are dialed on (optional)
 }
 
 // TaskHandler processes an assigned task. The context is cancelled when the
@@ -6,11 +6,13 @@
 // The SDK supports:
 //   - OAuth Device Flow login (RequestDeviceCode, PollForToken)
 //   - Agent registration and WebSocket+yamux tunnel connection
-//   - HTTP request proxying via http.Handler
+//   - HTTP request proxying via http.Handler, and WebSocket proxying (Handlers.WebSocket)
+//   - Preview URLs of exposed ports on the agent host (Handlers.ForwardPorts)
 //   - Task polling (receive tasks assigned to this agent)
 //   - Agent discovery (find other agents in the workspace)
 //   - Task delegation (assign tasks to other agents)
 //   - Async messaging (send/receive messages between agents)
+//   - Two-way sync of a workspace drive directory (SyncDrive, RunDriveSync)
 //
 // # Quick Start
 //
@@ -100,4 +102,24 @@
 //
 //	// Read incoming messages.
 //	messages, _ := client.ReadInbox(ctx, 10)
+//
+// # Workspace Drive Sync
+//
+// Mirror a directory of the workspace drive to the local machine, so files
+// can be edited locally while cloud agents work on the same tree:
+//
+//	go client.RunDriveSync(ctx, agentsdk.DriveSyncOptions{
+//		LocalDir:   "/home/me/src/app",
+//		RemotePath: "app",
+//	}, 5*time.Second, func(res *agentsdk.DriveSyncResult, err error) {
+//		if err != nil {
+//			log.Printf("drive sync: %v", err)
+//		}
+//		if res == nil {
+//			return
+//		}
+//		for _, c := range res.Conflicts {
+//			log.Printf("conflict in %s, local copy saved as %s", c.Path, c.Copy)
+//		}
+//	})
 package agentsdk
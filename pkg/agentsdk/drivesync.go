@@ -0,0 +1,505 @@
+package agentsdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultDriveSyncExcludes are skipped by SyncDrive unless
+// DriveSyncOptions.Exclude is set.
+var DefaultDriveSyncExcludes = []string{".git", "node_modules", ".DS_Store", "*.swp", "*~"}
+
+// DriveSyncOptions configures SyncDrive.
+type DriveSyncOptions struct {
+	// LocalDir is the local directory that mirrors RemotePath.
+	LocalDir string
+	// RemotePath is the directory of the workspace drive to mirror,
+	// relative to the drive root. Empty mirrors the whole drive.
+	RemotePath string
+	// Exclude lists path.Match patterns; a file is skipped on both sides
+	// if any element of its path matches. Nil uses DefaultDriveSyncExcludes.
+	Exclude []string
+	// StateFile records the hash of every file as of the last sync, which
+	// tells local and remote changes apart. Defaults to
+	// LocalDir/.agentserver-sync.json.
+	StateFile string
+}
+
+// DriveSyncResult reports what a SyncDrive pass did. Paths are relative to
+// LocalDir, with forward slashes.
+type DriveSyncResult struct {
+	Pulled        []string
+	Pushed        []string
+	DeletedLocal  []string
+	DeletedRemote []string
+	// Conflicts lists files changed on both sides. The remote version wins
+	// and the local one is kept next to it as a conflict copy, which the
+	// next pass uploads.
+	Conflicts []DriveConflict
+}
+
+// DriveConflict is a file changed both locally and on the drive.
+type DriveConflict struct {
+	Path string
+	// Copy is where the local version was saved.
+	Copy string
+}
+
+const driveSyncStateFile = ".agentserver-sync.json"
+
+// driveSyncState maps local relative paths to their hash at the last sync.
+type driveSyncState struct {
+	Files map[string]string `json:"files"`
+}
+
+// driveAction is what a sync pass does with one path.
+type driveAction int
+
+const (
+	driveNone driveAction = iota
+	drivePull
+	drivePush
+	driveDeleteLocal
+	driveDeleteRemote
+	driveConflict
+)
+
+// planDriveAction compares the local, remote and last synced hashes of a
+// path ("" when absent). A file deleted on one side and modified on the
+// other is kept.
+func planDriveAction(local, remote, base string) driveAction {
+	switch {
+	case local == remote:
+		return driveNone
+	case local == base:
+		if remote == "" {
+			return driveDeleteLocal
+		}
+		return drivePull
+	case remote == base:
+		if local == "" {
+			return driveDeleteRemote
+		}
+		return drivePush
+	case remote == "":
+		return drivePush
+	case local == "":
+		return drivePull
+	default:
+		return driveConflict
+	}
+}
+
+// SyncDrive runs one two-way sync pass between opts.LocalDir and
+// opts.RemotePath of the workspace drive. Files changed on one side since
+// the last pass are copied to the other, deletions are propagated, and
+// files changed on both sides are resolved as described in
+// DriveSyncResult.Conflicts. The drive is reached through a running cloud
+// sandbox of the workspace; the pass fails if there is none.
+//
+// Per-file failures do not stop the pass; they are joined in the returned
+// error and retried on the next pass.
+func (c *Client) SyncDrive(ctx context.Context, opts DriveSyncOptions) (*DriveSyncResult, error) {
+	if opts.LocalDir == "" {
+		return nil, fmt.Errorf("LocalDir is required")
+	}
+	if opts.Exclude == nil {
+		opts.Exclude = DefaultDriveSyncExcludes
+	}
+	if opts.StateFile == "" {
+		opts.StateFile = filepath.Join(opts.LocalDir, driveSyncStateFile)
+	}
+	remoteDir := strings.Trim(path.Clean("/"+filepath.ToSlash(opts.RemotePath)), "/")
+	if err := os.MkdirAll(opts.LocalDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	state, err := loadDriveSyncState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := c.driveManifest(ctx, remoteDir, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	local, err := scanLocalDir(opts.LocalDir, opts.StateFile, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for _, m := range []map[string]string{local, remote, state.Files} {
+		for p := range m {
+			paths[p] = true
+		}
+	}
+
+	s := &driveSyncer{c: c, opts: opts, remoteDir: remoteDir, state: state, result: &DriveSyncResult{}}
+	var errs []error
+	for p := range paths {
+		if err := s.syncPath(ctx, p, local[p], remote[p]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err := saveDriveSyncState(opts.StateFile, state); err != nil {
+		errs = append(errs, err)
+	}
+	return s.result, errors.Join(errs...)
+}
+
+// RunDriveSync calls SyncDrive every interval until ctx is cancelled.
+// onResult, if set, receives the outcome of each pass.
+func (c *Client) RunDriveSync(ctx context.Context, opts DriveSyncOptions, interval time.Duration, onResult func(*DriveSyncResult, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := c.SyncDrive(ctx, opts)
+		if onResult != nil {
+			onResult(result, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+type driveSyncer struct {
+	c         *Client
+	opts      DriveSyncOptions
+	remoteDir string
+	state     *driveSyncState
+	result    *DriveSyncResult
+}
+
+func (s *driveSyncer) syncPath(ctx context.Context, p, local, remote string) error {
+	base := s.state.Files[p]
+	switch planDriveAction(local, remote, base) {
+	case driveNone:
+		if local == "" {
+			delete(s.state.Files, p)
+		} else {
+			s.state.Files[p] = local
+		}
+	case drivePull:
+		if err := s.pull(ctx, p); err != nil {
+			return err
+		}
+		s.result.Pulled = append(s.result.Pulled, p)
+	case drivePush:
+		if remote == "" {
+			base = ""
+		}
+		conflict, err := s.c.drivePut(ctx, s.remotePath(p), filepath.Join(s.opts.LocalDir, filepath.FromSlash(p)), base)
+		if err != nil {
+			return err
+		}
+		if conflict {
+			return s.conflict(ctx, p)
+		}
+		s.state.Files[p] = local
+		s.result.Pushed = append(s.result.Pushed, p)
+	case driveDeleteLocal:
+		if err := os.Remove(filepath.Join(s.opts.LocalDir, filepath.FromSlash(p))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.state.Files, p)
+		s.result.DeletedLocal = append(s.result.DeletedLocal, p)
+	case driveDeleteRemote:
+		conflict, err := s.c.driveDelete(ctx, s.remotePath(p), base)
+		if err != nil {
+			return err
+		}
+		if conflict {
+			// Changed on the drive since: restore it locally.
+			if err := s.pull(ctx, p); err != nil {
+				return err
+			}
+			s.result.Pulled = append(s.result.Pulled, p)
+			return nil
+		}
+		delete(s.state.Files, p)
+		s.result.DeletedRemote = append(s.result.DeletedRemote, p)
+	case driveConflict:
+		return s.conflict(ctx, p)
+	}
+	return nil
+}
+
+// conflict moves the local file to a conflict copy and pulls the remote
+// version in its place.
+func (s *driveSyncer) conflict(ctx context.Context, p string) error {
+	copyPath := conflictCopyName(p, s.c.config.Name, time.Now())
+	if err := os.Rename(filepath.Join(s.opts.LocalDir, filepath.FromSlash(p)), filepath.Join(s.opts.LocalDir, filepath.FromSlash(copyPath))); err != nil {
+		return err
+	}
+	s.result.Conflicts = append(s.result.Conflicts, DriveConflict{Path: p, Copy: copyPath})
+	return s.pull(ctx, p)
+}
+
+// pull downloads p and records its hash.
+func (s *driveSyncer) pull(ctx context.Context, p string) error {
+	hash, err := s.c.driveGet(ctx, s.remotePath(p), filepath.Join(s.opts.LocalDir, filepath.FromSlash(p)))
+	if err != nil {
+		return err
+	}
+	s.state.Files[p] = hash
+	return nil
+}
+
+func (s *driveSyncer) remotePath(p string) string {
+	if s.remoteDir == "" {
+		return p
+	}
+	return s.remoteDir + "/" + p
+}
+
+// conflictCopyName returns "dir/name.conflict-<agent>-<time>.ext" for p.
+func conflictCopyName(p, agent string, t time.Time) string {
+	ext := path.Ext(p)
+	if ext == path.Base(p) { // dotfile such as ".env"
+		ext = ""
+	}
+	return strings.TrimSuffix(p, ext) + ".conflict-" + agent + "-" + t.Format("20060102-150405") + ext
+}
+
+// driveExcluded reports whether any element of p matches a pattern.
+func driveExcluded(p string, patterns []string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func scanLocalDir(dir, stateFile string, exclude []string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if driveExcluded(rel, exclude) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || p == stateFile || strings.HasSuffix(p, ".agentserver-sync") {
+			return nil
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = hash
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func loadDriveSyncState(p string) (*driveSyncState, error) {
+	state := &driveSyncState{Files: make(map[string]string)}
+	data, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", p, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]string)
+	}
+	return state, nil
+}
+
+func saveDriveSyncState(p string, state *driveSyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// driveManifest returns the hashes of the files under remoteDir, keyed by
+// path relative to remoteDir.
+func (c *Client) driveManifest(ctx context.Context, remoteDir string, exclude []string) (map[string]string, error) {
+	resp, err := c.driveRequest(ctx, http.MethodGet, "/api/agent/drive/manifest", remoteDir, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("drive manifest failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var manifest struct {
+		Files []struct {
+			Path   string `json:"path"`
+			SHA256 string `json:"sha256"`
+		} `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	files := make(map[string]string, len(manifest.Files))
+	for _, f := range manifest.Files {
+		rel := f.Path
+		if remoteDir != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(f.Path, remoteDir+"/"); !ok {
+				continue
+			}
+		}
+		if driveExcluded(rel, exclude) || strings.HasSuffix(rel, ".agentserver-sync") {
+			continue
+		}
+		files[rel] = f.SHA256
+	}
+	return files, nil
+}
+
+// driveGet downloads a drive file to dest and returns its hash.
+func (c *Client) driveGet(ctx context.Context, remotePath, dest string) (string, error) {
+	resp, err := c.driveRequest(ctx, http.MethodGet, "/api/agent/drive/file", remotePath, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("download failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	tmp := dest + ".agentserver-sync"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if err == nil && resp.Header.Get("X-Content-SHA256") != "" && resp.Header.Get("X-Content-SHA256") != hash {
+		err = fmt.Errorf("download corrupted: hash mismatch")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return hash, os.Rename(tmp, dest)
+}
+
+// drivePut uploads src to a drive file last synced with hash base ("" for
+// a new file). It reports a conflict if the drive file has changed since.
+func (c *Client) drivePut(ctx context.Context, remotePath, src, base string) (conflict bool, err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	resp, err := c.driveRequest(ctx, http.MethodPut, "/api/agent/drive/file", remotePath, driveCondition(base), f)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return driveWriteResult(resp, "upload")
+}
+
+// driveDelete deletes a drive file if it still has hash base.
+func (c *Client) driveDelete(ctx context.Context, remotePath, base string) (conflict bool, err error) {
+	resp, err := c.driveRequest(ctx, http.MethodDelete, "/api/agent/drive/file", remotePath, driveCondition(base), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	return driveWriteResult(resp, "delete")
+}
+
+func driveCondition(base string) map[string]string {
+	if base == "" {
+		return map[string]string{"If-None-Match": "*"}
+	}
+	return map[string]string{"If-Match": `"` + base + `"`}
+}
+
+func driveWriteResult(resp *http.Response, op string) (conflict bool, err error) {
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return false, nil
+	case http.StatusConflict:
+		return true, nil
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("%s failed (%d): %s", op, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+// driveRequest makes a proxy-token-authenticated drive sync request for
+// the drive path p.
+func (c *Client) driveRequest(ctx context.Context, method, endpoint, p string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	if c.reg == nil {
+		return nil, fmt.Errorf("not registered: call Register() or SetRegistration() first")
+	}
+	u := strings.TrimRight(c.config.ServerURL, "/") + endpoint + "?" + url.Values{"path": {p}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.reg.ProxyToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return http.DefaultClient.Do(req)
+}
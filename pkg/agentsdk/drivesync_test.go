@@ -0,0 +1,222 @@
+package agentsdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlanDriveAction(t *testing.T) {
+	tests := []struct {
+		local, remote, base string
+		want                driveAction
+	}{
+		{"a", "a", "", driveNone},
+		{"a", "a", "x", driveNone},
+		{"", "", "x", driveNone},
+		{"a", "b", "a", drivePull},
+		{"a", "", "a", driveDeleteLocal},
+		{"b", "a", "a", drivePush},
+		{"", "a", "a", driveDeleteRemote},
+		{"a", "", "", drivePush},
+		{"", "a", "", drivePull},
+		{"b", "", "a", drivePush}, // modified locally, deleted remotely
+		{"", "b", "a", drivePull}, // deleted locally, modified remotely
+		{"b", "c", "a", driveConflict},
+		{"b", "c", "", driveConflict},
+	}
+	for _, tt := range tests {
+		if got := planDriveAction(tt.local, tt.remote, tt.base); got != tt.want {
+			t.Errorf("planDriveAction(%q, %q, %q) = %d, want %d", tt.local, tt.remote, tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestConflictCopyName(t *testing.T) {
+	ts := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	for p, want := range map[string]string{
+		"src/main.go": "src/main.conflict-laptop-20260304-050607.go",
+		"Makefile":    "Makefile.conflict-laptop-20260304-050607",
+		"dir.v2/.env": "dir.v2/.env.conflict-laptop-20260304-050607",
+	} {
+		if got := conflictCopyName(p, "laptop", ts); got != want {
+			t.Errorf("conflictCopyName(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestDriveExcluded(t *testing.T) {
+	for p, want := range map[string]bool{
+		"src/main.go":              false,
+		".git/config":              true,
+		"web/node_modules/x/y.js":  true,
+		"notes.txt.swp":            true,
+		".github/workflows/ci.yml": false,
+	} {
+		if got := driveExcluded(p, DefaultDriveSyncExcludes); got != want {
+			t.Errorf("driveExcluded(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+// fakeDrive implements the drive sync endpoints over an in-memory drive.
+type fakeDrive struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func (d *fakeDrive) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := r.URL.Query().Get("path")
+	switch {
+	case r.URL.Path == "/api/agent/drive/manifest":
+		type file struct {
+			Path   string `json:"path"`
+			SHA256 string `json:"sha256"`
+		}
+		files := []file{}
+		for name, content := range d.files {
+			if p == "." || strings.HasPrefix(name, p+"/") {
+				files = append(files, file{name, hashString(content)})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+	case r.Method == http.MethodGet:
+		content, ok := d.files[p]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Content-SHA256", hashString(content))
+		io.WriteString(w, content)
+	default:
+		cur := ""
+		if content, ok := d.files[p]; ok {
+			cur = hashString(content)
+		}
+		if strings.Trim(r.Header.Get("If-Match"), `"`) != cur {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(d.files, p)
+		} else {
+			body, _ := io.ReadAll(r.Body)
+			d.files[p] = string(body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestSyncDrive(t *testing.T) {
+	drive := &fakeDrive{files: map[string]string{
+		"proj/a.txt":        "remote a",
+		"proj/sub/b.txt":    "remote b",
+		"proj/.git/HEAD":    "ref",
+		"other/ignored.txt": "x",
+	}}
+	srv := httptest.NewServer(drive)
+	defer srv.Close()
+
+	c := NewClient(Config{ServerURL: srv.URL, Name: "laptop"})
+	c.SetRegistration(&Registration{ProxyToken: "tok"})
+	dir := t.TempDir()
+	opts := DriveSyncOptions{LocalDir: dir, RemotePath: "proj"}
+	ctx := context.Background()
+
+	read := func(p string) string {
+		data, err := os.ReadFile(filepath.Join(dir, p))
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	write := func(p, content string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0o755)
+		if err := os.WriteFile(filepath.Join(dir, p), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Initial pass pulls everything except excluded paths.
+	res, err := c.SyncDrive(ctx, opts)
+	if err != nil {
+		t.Fatalf("first sync: %v", err)
+	}
+	if len(res.Pulled) != 2 || read("a.txt") != "remote a" || read("sub/b.txt") != "remote b" {
+		t.Fatalf("first sync pulled %v", res.Pulled)
+	}
+	if read(".git/HEAD") != "<missing>" || read("ignored.txt") != "<missing>" {
+		t.Fatal("excluded or out-of-scope file was pulled")
+	}
+
+	// Local edit and new file are pushed; a remote edit is pulled; a local
+	// delete is propagated.
+	write("a.txt", "local a")
+	write("new.txt", "new")
+	os.Remove(filepath.Join(dir, "sub/b.txt"))
+	drive.mu.Lock()
+	drive.files["proj/c.txt"] = "remote c"
+	drive.mu.Unlock()
+	res, err = c.SyncDrive(ctx, opts)
+	if err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	drive.mu.Lock()
+	if drive.files["proj/a.txt"] != "local a" || drive.files["proj/new.txt"] != "new" {
+		t.Errorf("push failed: %v", drive.files)
+	}
+	if _, ok := drive.files["proj/sub/b.txt"]; ok {
+		t.Error("local delete not propagated")
+	}
+	drive.mu.Unlock()
+	if read("c.txt") != "remote c" {
+		t.Error("remote file not pulled")
+	}
+
+	// Both sides change a.txt: the remote wins, the local edit is kept as
+	// a conflict copy that the next pass uploads.
+	write("a.txt", "local a2")
+	drive.mu.Lock()
+	drive.files["proj/a.txt"] = "remote a2"
+	drive.mu.Unlock()
+	res, err = c.SyncDrive(ctx, opts)
+	if err != nil {
+		t.Fatalf("third sync: %v", err)
+	}
+	if len(res.Conflicts) != 1 || res.Conflicts[0].Path != "a.txt" {
+		t.Fatalf("conflicts = %+v", res.Conflicts)
+	}
+	if read("a.txt") != "remote a2" || read(res.Conflicts[0].Copy) != "local a2" {
+		t.Errorf("conflict resolution: a.txt=%q copy=%q", read("a.txt"), read(res.Conflicts[0].Copy))
+	}
+	res, err = c.SyncDrive(ctx, opts)
+	if err != nil || len(res.Pushed) != 1 {
+		t.Fatalf("fourth sync: %+v, %v", res, err)
+	}
+
+	// Nothing changed: a pass is a no-op.
+	res, err = c.SyncDrive(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(res.Pulled) + len(res.Pushed) + len(res.DeletedLocal) + len(res.DeletedRemote) + len(res.Conflicts); n != 0 {
+		t.Errorf("idle sync did %d operations: %+v", n, res)
+	}
+}
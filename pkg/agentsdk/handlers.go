@@ -6,7 +6,9 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"strconv"
 
 	"github.com/agentserver/agentserver/internal/tunnel"
 )
@@ -71,6 +73,19 @@ func handleHTTPStreamWithMeta(stream net.Conn, metaBytes []byte, handler http.Ha
 	rw.finish(stream)
 }
 
+// portHandler serves a request for an exposed preview port by proxying it
+// to the port on 127.0.0.1, or answers 404 if the agent doesn't forward
+// ports.
+func portHandler(port int, forward bool) http.Handler {
+	if !forward {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "port forwarding is not enabled on this agent", http.StatusNotFound)
+		})
+	}
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(port))}
+	return httputil.NewSingleHostReverseProxy(target)
+}
+
 // streamResponseWriter implements http.ResponseWriter, buffering the response
 // so it can be written to the stream using the tunnel protocol.
 type streamResponseWriter struct {
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -179,3 +181,40 @@ func TestHandleHTTPStream_POST_Echo(t *testing.T) {
 		t.Errorf("expected echoed body %q, got %q", string(reqBody), string(body))
 	}
 }
+
+func TestHandleStream_ForwardPort(t *testing.T) {
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "dev server %s", r.URL.Path)
+	}))
+	defer dev.Close()
+	_, portStr, _ := net.SplitHostPort(dev.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	serve := func(forward bool) (int, string) {
+		metaJSON, _ := json.Marshal(tunnel.HTTPStreamMeta{Method: "GET", Path: "/app", Port: port})
+		var buf bytes.Buffer
+		tunnel.WriteStreamHeader(&buf, tunnel.StreamTypeHTTP, metaJSON)
+		conn := newMockConn(buf.Bytes())
+		agentHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("port stream reached the agent's HTTP handler")
+		})
+		(&Client{}).handleStream(conn, Handlers{HTTP: agentHandler, ForwardPorts: forward})
+
+		respReader := bytes.NewReader(conn.writeBuf.Bytes())
+		_, metaBytes, err := tunnel.ReadStreamHeader(respReader)
+		if err != nil {
+			t.Fatalf("read response header: %v", err)
+		}
+		var respMeta tunnel.HTTPResponseMeta
+		json.Unmarshal(metaBytes, &respMeta)
+		body, _ := io.ReadAll(respReader)
+		return respMeta.Status, string(body)
+	}
+
+	if status, body := serve(true); status != http.StatusOK || body != "dev server /app" {
+		t.Errorf("forwarded port = %d %q", status, body)
+	}
+	if status, _ := serve(false); status != http.StatusNotFound {
+		t.Errorf("port without ForwardPorts = %d, want 404", status)
+	}
+}
@@ -0,0 +1,75 @@
+package agentsdk
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/tunnel"
+	"nhooyr.io/websocket"
+)
+
+// handleWebSocketStream dials the WebSocket a browser opened through the
+// tunnel on target (a ws:// base URL) and forwards messages both ways until
+// either side closes. With no target, or if the dial fails, it answers with
+// an error status and the server refuses the browser's upgrade.
+func handleWebSocketStream(stream net.Conn, meta tunnel.HTTPStreamMeta, target string) {
+	if target == "" {
+		writeWebSocketResponse(stream, http.StatusNotImplemented, nil)
+		return
+	}
+
+	header := make(http.Header)
+	var subprotocols []string
+	for k, v := range meta.Headers {
+		switch {
+		case tunnel.IsWebSocketHandshakeHeader(k) || http.CanonicalHeaderKey(k) == "Host":
+		case http.CanonicalHeaderKey(k) == "Sec-Websocket-Protocol":
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					subprotocols = append(subprotocols, p)
+				}
+			}
+		default:
+			header.Set(k, v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ws, resp, err := websocket.Dial(ctx, strings.TrimSuffix(target, "/")+meta.Path, &websocket.DialOptions{
+		HTTPHeader:   header,
+		Subprotocols: subprotocols,
+	})
+	cancel()
+	if err != nil {
+		status := http.StatusBadGateway
+		if resp != nil && resp.StatusCode >= 400 {
+			status = resp.StatusCode
+		}
+		writeWebSocketResponse(stream, status, nil)
+		return
+	}
+
+	var respHeaders map[string]string
+	if p := ws.Subprotocol(); p != "" {
+		respHeaders = map[string]string{"Sec-WebSocket-Protocol": p}
+	}
+	if err := writeWebSocketResponse(stream, http.StatusSwitchingProtocols, respHeaders); err != nil {
+		ws.CloseNow()
+		return
+	}
+	tunnel.BridgeWebSocket(context.Background(), ws, stream)
+}
+
+// writeWebSocketResponse answers a WebSocket stream: 101 once the
+// WebSocket is up, an error status otherwise.
+func writeWebSocketResponse(stream net.Conn, status int, headers map[string]string) error {
+	metaJSON, err := json.Marshal(tunnel.HTTPResponseMeta{Status: status, Headers: headers})
+	if err != nil {
+		return err
+	}
+	return tunnel.WriteStreamHeader(stream, tunnel.StreamTypeWebSocket, metaJSON)
+}
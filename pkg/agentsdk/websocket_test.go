@@ -0,0 +1,80 @@
+package agentsdk
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentserver/agentserver/internal/tunnel"
+	"nhooyr.io/websocket"
+)
+
+func TestHandleWebSocketStream(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/pty/1/connect" || r.Header.Get("Authorization") != "Basic x" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{Subprotocols: []string{"tty"}})
+		if err != nil {
+			return
+		}
+		defer ws.CloseNow()
+		for {
+			typ, data, err := ws.Read(r.Context())
+			if err != nil {
+				return
+			}
+			ws.Write(r.Context(), typ, append([]byte("echo:"), data...))
+		}
+	}))
+	defer echo.Close()
+	target := "ws" + strings.TrimPrefix(echo.URL, "http")
+
+	open := func(path string) (net.Conn, tunnel.HTTPResponseMeta) {
+		server, agent := net.Pipe()
+		meta := tunnel.HTTPStreamMeta{Method: "GET", Path: path, Headers: map[string]string{
+			"Authorization":          "Basic x",
+			"Sec-WebSocket-Key":      "dGhlIHNhbXBsZSBub25jZQ==",
+			"Sec-WebSocket-Protocol": "tty, other",
+		}}
+		go handleWebSocketStream(agent, meta, target)
+		server.SetDeadline(time.Now().Add(10 * time.Second))
+		_, raw, err := tunnel.ReadStreamHeader(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp tunnel.HTTPResponseMeta
+		json.Unmarshal(raw, &resp)
+		return server, resp
+	}
+
+	server, resp := open("/pty/1/connect")
+	defer server.Close()
+	if resp.Status != http.StatusSwitchingProtocols || resp.Headers["Sec-WebSocket-Protocol"] != "tty" {
+		t.Fatalf("response = %+v, want 101 with subprotocol tty", resp)
+	}
+	tunnel.WriteWSFrame(server, tunnel.WSFrameText, []byte("ls\n"))
+	if op, p, err := tunnel.ReadWSFrame(server); err != nil || op != tunnel.WSFrameText || string(p) != "echo:ls\n" {
+		t.Errorf("frame = %d %q %v", op, p, err)
+	}
+
+	bad, resp := open("/elsewhere")
+	defer bad.Close()
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("failed dial status = %d, want 400", resp.Status)
+	}
+
+	noTarget, agent := net.Pipe()
+	defer noTarget.Close()
+	go handleWebSocketStream(agent, tunnel.HTTPStreamMeta{Path: "/"}, "")
+	_, raw, _ := tunnel.ReadStreamHeader(noTarget)
+	json.Unmarshal(raw, &resp)
+	if resp.Status != http.StatusNotImplemented {
+		t.Errorf("status without target = %d, want 501", resp.Status)
+	}
+}
@@ -4,6 +4,7 @@ import {
   checkAuth,
   listWorkspaces,
   listSandboxes,
+  getSandboxPollInterval,
   getMe,
   pauseSandbox,
   resumeSandbox,
@@ -24,6 +25,7 @@ import { SandboxDetail } from './components/SandboxDetail'
 import { ManageWorkspaces } from './components/ManageWorkspaces'
 import { AdminPanel } from './components/AdminPanel'
 import { WorkspaceDetail, tabFromSlug, type Tab as WorkspaceTab } from './components/WorkspaceDetail'
+import { AcceptInvitation } from './components/AcceptInvitation'
 
 export interface UserInfo {
   id: string
@@ -128,6 +130,12 @@ function OAuthDeviceRoute() {
   return <OAuthDevice challenge={challenge} userCode={userCode} />
 }
 
+function InvitationRoute({ userEmail, onAccepted }: { userEmail?: string; onAccepted: (workspaceId: string) => void }) {
+  const { token } = useParams<{ token: string }>()
+  if (!token) return null
+  return <AcceptInvitation token={token} userEmail={userEmail} onAccepted={onAccepted} />
+}
+
 function OAuthConsentRoute() {
   const [searchParams] = useSearchParams()
   const challenge = searchParams.get('consent_challenge') ?? ''
@@ -225,7 +233,7 @@ export default function App() {
       (s) => s.status === 'creating' || s.status === 'pausing' || s.status === 'resuming',
     )
     if (!hasTransitional) return
-    const id = window.setInterval(refreshSandboxes, 2000)
+    const id = window.setInterval(refreshSandboxes, getSandboxPollInterval())
     return () => window.clearInterval(id)
   }, [sandboxes, refreshSandboxes])
 
@@ -234,6 +242,15 @@ export default function App() {
     navigate(id ? `/w/${id}` : '/')
   }, [navigate])
 
+  // Joining through an invitation: reload the workspaces to include the
+  // new one, then open it.
+  const handleInvitationAccepted = useCallback((id: string) => {
+    listWorkspaces().then((ws) => {
+      setWorkspaces(ws)
+      handleSelectWorkspace(id)
+    }).catch(() => handleSelectWorkspace(id))
+  }, [handleSelectWorkspace])
+
   const handleLogout = useCallback(() => {
     setAuthed(false)
     setUser(null)
@@ -315,6 +332,10 @@ export default function App() {
     if (location.pathname === '/oauth2/device') {
       sessionStorage.setItem(PENDING_DEVICE_PARAMS_KEY, location.search)
     }
+    // Come back to the invitation after signing in, OIDC included.
+    if (location.pathname.startsWith('/invitations/')) {
+      return <Navigate to={`/?next=${encodeURIComponent(location.pathname)}`} replace />
+    }
     return (
       <Login
         onSuccess={() => {
@@ -421,6 +442,10 @@ export default function App() {
           path="/admin/*"
           element={<AdminPanel />}
         />
+        <Route
+          path="/invitations/:token"
+          element={<InvitationRoute userEmail={user?.email} onAccepted={handleInvitationAccepted} />}
+        />
         <Route path="/oauth2/consent" element={<OAuthConsentRoute />} />
         <Route path="/oauth2/device" element={<OAuthDeviceRoute />} />
         <Route path="*" element={selectedWorkspaceId ? <Navigate to={`/w/${selectedWorkspaceId}`} replace /> : null} />
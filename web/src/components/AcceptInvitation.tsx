@@ -0,0 +1,79 @@
+import { useEffect, useState } from 'react'
+import { getInvitation, acceptInvitation, type InvitationPreview } from '../lib/api'
+
+interface AcceptInvitationProps {
+  token: string
+  userEmail?: string
+  onAccepted: (workspaceId: string) => void
+}
+
+export function AcceptInvitation({ token, userEmail, onAccepted }: AcceptInvitationProps) {
+  const [invitation, setInvitation] = useState<InvitationPreview | null>(null)
+  const [error, setError] = useState('')
+  const [submitting, setSubmitting] = useState(false)
+
+  useEffect(() => {
+    getInvitation(token)
+      .then(setInvitation)
+      .catch((err) => setError(err instanceof Error ? err.message : 'Invitation not found'))
+  }, [token])
+
+  const handleAccept = async () => {
+    setSubmitting(true)
+    setError('')
+    try {
+      const { workspace_id } = await acceptInvitation(token)
+      onAccepted(workspace_id)
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to accept the invitation')
+      setSubmitting(false)
+    }
+  }
+
+  const wrongEmail = !!invitation && !!userEmail && invitation.email.toLowerCase() !== userEmail.toLowerCase()
+
+  return (
+    <div className="flex flex-1 items-center justify-center">
+      <div className="w-full max-w-md border border-[var(--border)] rounded-lg p-6 space-y-6">
+        <div className="text-center">
+          <h2 className="text-lg font-semibold">Workspace Invitation</h2>
+          {invitation && (
+            <p className="text-sm text-[var(--muted-foreground)] mt-1">
+              {invitation.invited_by || 'A workspace owner'} invited {invitation.email} to join{' '}
+              <span className="font-medium text-[var(--foreground)]">{invitation.workspace_name || invitation.workspace_id}</span>{' '}
+              as {invitation.role}.
+            </p>
+          )}
+        </div>
+
+        {invitation && invitation.status !== 'open' && (
+          <div className="text-sm text-[var(--muted-foreground)] text-center">
+            This invitation is {invitation.status}. Ask the workspace owner for a new one.
+          </div>
+        )}
+
+        {wrongEmail && invitation?.status === 'open' && (
+          <div className="text-sm text-[var(--muted-foreground)] text-center">
+            You're signed in as {userEmail}. Sign in as {invitation.email} to accept.
+          </div>
+        )}
+
+        {error && (
+          <div className="text-sm text-red-500 text-center">{error}</div>
+        )}
+
+        {invitation?.status === 'open' && !wrongEmail && (
+          <div className="flex justify-end">
+            <button
+              onClick={handleAccept}
+              disabled={submitting}
+              className="px-4 py-2 text-sm bg-[var(--primary)] text-[var(--primary-foreground)] rounded-md hover:opacity-90 disabled:opacity-50"
+            >
+              {submitting ? 'Joining...' : 'Accept and join'}
+            </button>
+          </div>
+        )}
+      </div>
+    </div>
+  )
+}
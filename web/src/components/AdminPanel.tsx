@@ -1,6 +1,6 @@
 import { useState, useEffect } from 'react'
 import { Routes, Route, Navigate, useNavigate, useLocation, useParams } from 'react-router-dom'
-import { ArrowLeft, Loader2, Users, Box, Container, Settings, ChevronRight } from 'lucide-react'
+import { ArrowLeft, Loader2, Users, Box, Container, Settings, ChevronRight, ShieldAlert } from 'lucide-react'
 import {
   type AdminUser,
   type AdminWorkspace,
@@ -9,6 +9,9 @@ import {
   type UserQuotaResponse,
   type WorkspaceQuotaResponse,
   type LLMQuotaResponse,
+  type ImageScan,
+  type ImageScanPolicy,
+  type ImageScansResponse,
   adminListUsers,
   adminListWorkspaces,
   adminListSandboxes,
@@ -24,12 +27,17 @@ import {
   adminGetWorkspaceLLMQuota,
   adminSetWorkspaceLLMQuota,
   adminDeleteWorkspaceLLMQuota,
+  adminListImageScans,
+  adminGetImageScanFindings,
+  adminRunImageScans,
+  adminSetImageScanPolicy,
 } from '../lib/api'
 
 const tabs = [
   { path: 'users', label: 'Users', icon: Users },
   { path: 'workspaces', label: 'Workspaces', icon: Box },
   { path: 'sandboxes', label: 'Sandboxes', icon: Container },
+  { path: 'images', label: 'Images', icon: ShieldAlert },
   { path: 'settings', label: 'Settings', icon: Settings },
 ] as const
 
@@ -76,6 +84,7 @@ export function AdminPanel() {
           <Route path="workspaces" element={<WorkspacesTab />} />
           <Route path="workspaces/:workspaceId/sandboxes" element={<WorkspaceSandboxesTab />} />
           <Route path="sandboxes" element={<SandboxesTab />} />
+          <Route path="images" element={<ImagesTab />} />
           <Route path="settings" element={<SettingsTab />} />
           <Route path="*" element={<Navigate to="users" replace />} />
         </Routes>
@@ -405,6 +414,164 @@ function SandboxesTable({ sandboxes }: { sandboxes: AdminSandbox[] }) {
   )
 }
 
+function ImagesTab() {
+  const [data, setData] = useState<ImageScansResponse | null>(null)
+  const [loading, setLoading] = useState(true)
+  const [selected, setSelected] = useState<ImageScan | null>(null)
+  const [message, setMessage] = useState('')
+
+  const load = () => adminListImageScans().then(setData).catch(() => {}).finally(() => setLoading(false))
+
+  useEffect(() => {
+    load()
+  }, [])
+
+  const handleScanNow = async () => {
+    try {
+      await adminRunImageScans()
+      setMessage('Scans started. Results appear here as they complete.')
+    } catch (e) {
+      setMessage(e instanceof Error ? e.message : 'Failed to start image scans')
+    }
+  }
+
+  const handlePolicyChange = async (policy: ImageScanPolicy) => {
+    try {
+      const updated = await adminSetImageScanPolicy(policy)
+      setData((d) => (d ? { ...d, policy: updated } : d))
+    } catch {
+      setMessage('Failed to save policy')
+    }
+  }
+
+  const handleSelect = (image: string) => {
+    adminGetImageScanFindings(image).then(setSelected).catch(() => setMessage('No completed scan for this image'))
+  }
+
+  if (loading) return <LoadingSpinner />
+  if (!data) return <p className="text-sm text-[var(--muted-foreground)]">Failed to load image scans.</p>
+
+  return (
+    <div className="space-y-6">
+      <div className="flex flex-wrap items-end gap-4">
+        <div>
+          <label className="mb-1 block text-xs font-medium text-[var(--muted-foreground)]">Block creation from images with</label>
+          <select
+            value={data.policy.block_severity}
+            onChange={(e) => handlePolicyChange({ ...data.policy, block_severity: e.target.value as ImageScanPolicy['block_severity'] })}
+            className="rounded-md border border-[var(--border)] bg-[var(--background)] px-2 py-1 text-sm text-[var(--foreground)]"
+          >
+            <option value="">nothing (report only)</option>
+            <option value="critical">critical findings</option>
+            <option value="high">high or critical findings</option>
+          </select>
+        </div>
+        <label className="flex items-center gap-2 text-sm text-[var(--foreground)]">
+          <input
+            type="checkbox"
+            checked={data.policy.block_unscanned}
+            onChange={(e) => handlePolicyChange({ ...data.policy, block_unscanned: e.target.checked })}
+          />
+          Block unscanned images
+        </label>
+        <button
+          onClick={handleScanNow}
+          disabled={!data.scanner}
+          title={data.scanner ? `Scan with ${data.scanner}` : 'No scanner configured (IMAGE_SCANNER)'}
+          className="ml-auto rounded-md border border-[var(--border)] px-3 py-1.5 text-sm font-medium text-[var(--foreground)] hover:bg-[var(--secondary)] disabled:opacity-50"
+        >
+          Scan now
+        </button>
+      </div>
+      {message && <p className="text-sm text-[var(--muted-foreground)]">{message}</p>}
+
+      {data.scans.length === 0 && data.unscanned.length === 0 ? (
+        <p className="text-sm text-[var(--muted-foreground)]">No images scanned.</p>
+      ) : (
+        <div className="overflow-x-auto rounded-lg border border-[var(--border)]">
+          <table className="w-full text-sm">
+            <thead>
+              <tr className="border-b border-[var(--border)] bg-[var(--muted)]">
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Image</th>
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Status</th>
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Critical</th>
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">High</th>
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Medium</th>
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Low</th>
+                <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Scanned At</th>
+              </tr>
+            </thead>
+            <tbody>
+              {data.scans.map((scan) => (
+                <tr
+                  key={scan.image}
+                  onClick={() => scan.status === 'completed' && handleSelect(scan.image)}
+                  className="cursor-pointer border-b border-[var(--border)] last:border-b-0 hover:bg-[var(--secondary)]"
+                >
+                  <td className="px-4 py-3 font-mono text-xs text-[var(--foreground)]">{scan.image}</td>
+                  <td className="px-4 py-3 text-[var(--muted-foreground)]" title={scan.error}>
+                    {scan.scanner} · {scan.status}
+                  </td>
+                  <td className={`px-4 py-3 ${scan.critical > 0 ? 'font-medium text-red-500' : 'text-[var(--muted-foreground)]'}`}>{scan.critical}</td>
+                  <td className={`px-4 py-3 ${scan.high > 0 ? 'text-orange-500' : 'text-[var(--muted-foreground)]'}`}>{scan.high}</td>
+                  <td className="px-4 py-3 text-[var(--muted-foreground)]">{scan.medium}</td>
+                  <td className="px-4 py-3 text-[var(--muted-foreground)]">{scan.low}</td>
+                  <td className="px-4 py-3 text-[var(--muted-foreground)]">{new Date(scan.scanned_at).toLocaleString()}</td>
+                </tr>
+              ))}
+              {data.unscanned.map((image) => (
+                <tr key={image} className="border-b border-[var(--border)] last:border-b-0">
+                  <td className="px-4 py-3 font-mono text-xs text-[var(--foreground)]">{image}</td>
+                  <td className="px-4 py-3 text-[var(--muted-foreground)]" colSpan={6}>not scanned</td>
+                </tr>
+              ))}
+            </tbody>
+          </table>
+        </div>
+      )}
+
+      {selected && (
+        <div>
+          <div className="mb-2 flex items-center justify-between">
+            <h3 className="font-mono text-sm font-medium text-[var(--foreground)]">{selected.image}</h3>
+            <button onClick={() => setSelected(null)} className="text-xs text-[var(--muted-foreground)] hover:text-[var(--foreground)]">
+              Close
+            </button>
+          </div>
+          {(selected.findings ?? []).length === 0 ? (
+            <p className="text-sm text-[var(--muted-foreground)]">No findings.</p>
+          ) : (
+            <div className="overflow-x-auto rounded-lg border border-[var(--border)]">
+              <table className="w-full text-sm">
+                <thead>
+                  <tr className="border-b border-[var(--border)] bg-[var(--muted)]">
+                    <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Severity</th>
+                    <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">ID</th>
+                    <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Package</th>
+                    <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Installed</th>
+                    <th className="px-4 py-3 text-left font-medium text-[var(--muted-foreground)]">Fixed In</th>
+                  </tr>
+                </thead>
+                <tbody>
+                  {selected.findings!.map((f, i) => (
+                    <tr key={`${f.id}-${f.package}-${i}`} className="border-b border-[var(--border)] last:border-b-0">
+                      <td className="px-4 py-3 text-[var(--foreground)]">{f.severity}</td>
+                      <td className="px-4 py-3 font-mono text-xs text-[var(--foreground)]" title={f.title}>{f.id}</td>
+                      <td className="px-4 py-3 text-[var(--muted-foreground)]">{f.package}</td>
+                      <td className="px-4 py-3 text-[var(--muted-foreground)]">{f.installed_version || '—'}</td>
+                      <td className="px-4 py-3 text-[var(--muted-foreground)]">{f.fixed_version || '—'}</td>
+                    </tr>
+                  ))}
+                </tbody>
+              </table>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  )
+}
+
 const inputClass = "w-full rounded-md border border-[var(--border)] bg-[var(--background)] px-3 py-2 text-sm text-[var(--foreground)]"
 
 function SettingsTab() {
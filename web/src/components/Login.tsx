@@ -17,12 +17,14 @@ export function Login({ onSuccess }: LoginProps) {
   const [loading, setLoading] = useState(false)
   const [isRegister, setIsRegister] = useState(false)
   const [oidcProviders, setOidcProviders] = useState<string[]>([])
+  const [oidcLabels, setOidcLabels] = useState<Record<string, string>>({})
   const [passwordAuth, setPasswordAuth] = useState(false)
   const [providersLoaded, setProvidersLoaded] = useState(false)
 
   useEffect(() => {
     getOIDCProviders().then((data) => {
       setOidcProviders(data.providers)
+      setOidcLabels(data.labels)
       setPasswordAuth(data.password_auth)
       setProvidersLoaded(true)
     })
@@ -142,7 +144,7 @@ export function Login({ onSuccess }: LoginProps) {
                     href={href}
                     className="flex w-full items-center justify-center rounded-md border border-[var(--input)] bg-[var(--background)] px-4 py-2 text-sm font-medium text-[var(--foreground)] hover:bg-[var(--accent)] hover:text-[var(--accent-foreground)]"
                   >
-                    {providerLabels[provider] || `Sign in with ${provider}`}
+                    {oidcLabels[provider] || providerLabels[provider] || `Sign in with ${provider}`}
                   </a>
                 )
               })}
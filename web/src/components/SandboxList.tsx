@@ -7,6 +7,7 @@ import {
   deleteSandbox,
   pauseSandbox,
   resumeSandbox,
+  getSandboxPollInterval,
 } from '../lib/api'
 import { CreateSandboxModal } from './CreateSandboxModal'
 import { ConfirmModal } from './Modals'
@@ -66,7 +67,7 @@ export function SandboxList({
     )
     if (hasTransitional) {
       if (!pollRef.current) {
-        pollRef.current = setInterval(onRefreshSandboxes, 2000)
+        pollRef.current = setInterval(onRefreshSandboxes, getSandboxPollInterval())
       }
     } else {
       if (pollRef.current) {
@@ -20,13 +20,16 @@ import {
   Server,
   Brain,
   Activity,
+  HardDrive,
 } from 'lucide-react'
 import {
   listMembers,
   addMember,
+  createInvitation,
   removeMember,
   getWorkspaceDefaults,
   getWorkspaceLLMQuota,
+  getWorkspaceDriveUsage,
   getWorkspaceTraces,
   getWorkspaceTraceDetail,
   getWorkspaceLLMConfig,
@@ -50,6 +53,7 @@ import {
   type WorkspaceMember,
   type WorkspaceSandboxDefaults,
   type WorkspaceLLMQuota,
+  type WorkspaceDriveUsage,
   type WorkspaceLLMConfig,
   type LLMModel,
   type TraceItem,
@@ -302,6 +306,10 @@ function OverviewTab({ workspace, sbxQuota, defaults, llmQuota, onRename }: {
   onRename?: (id: string, name: string) => void
 }) {
   const effectiveMaxRpd = llmQuota?.workspace_quota?.max_rpd ?? llmQuota?.default_max_rpd ?? null
+  const [driveUsage, setDriveUsage] = useState<WorkspaceDriveUsage | null>(null)
+  useEffect(() => {
+    getWorkspaceDriveUsage(workspace.id).then(setDriveUsage).catch(() => setDriveUsage(null))
+  }, [workspace.id])
   const [editing, setEditing] = useState(false)
   const [editName, setEditName] = useState(workspace.name)
   const commit = () => {
@@ -354,6 +362,13 @@ function OverviewTab({ workspace, sbxQuota, defaults, llmQuota, onRename }: {
             value={`${llmQuota?.today_request_count ?? 0} / ${effectiveMaxRpd === 0 ? '∞' : String(effectiveMaxRpd)}`}
           />
         )}
+        {driveUsage && driveUsage.status !== 'unknown' && (
+          <InfoCard
+            icon={<HardDrive size={14} className={driveUsage.status === 'ok' ? undefined : 'text-red-500'} />}
+            label={driveUsage.status === 'exceeded' ? 'Drive (over limit)' : driveUsage.status === 'warning' ? 'Drive (almost full)' : 'Drive'}
+            value={`${(driveUsage.used_bytes / 1024 ** 3).toFixed(1)} / ${(driveUsage.limit_bytes / 1024 ** 3).toFixed(1)} GiB (${driveUsage.percent}%)`}
+          />
+        )}
       </div>
 
       {/* Resource limits */}
@@ -431,19 +446,30 @@ function MembersTab({ workspaceId, members, setMembers }: {
   const [addEmail, setAddEmail] = useState('')
   const [addRole, setAddRole] = useState('developer')
   const [addError, setAddError] = useState<string | null>(null)
+  const [inviteNotice, setInviteNotice] = useState<string | null>(null)
   const [confirmRemove, setConfirmRemove] = useState<WorkspaceMember | null>(null)
 
   const handleAdd = async (e: React.FormEvent) => {
     e.preventDefault()
     if (!addEmail.trim()) return
     setAddError(null)
+    setInviteNotice(null)
     try {
       const m = await addMember(workspaceId, addEmail.trim(), addRole)
       setMembers((prev) => [...prev, m])
       setAddEmail('')
       setShowAdd(false)
     } catch {
-      setAddError('Failed to add member. User may not exist.')
+      // No account with that email yet: invite it instead.
+      try {
+        const inv = await createInvitation(workspaceId, addEmail.trim(), addRole)
+        setInviteNotice(inv.emailed
+          ? `Invitation emailed to ${inv.invitation.email}.`
+          : `Send ${inv.invitation.email} this invitation link: ${inv.accept_url}`)
+        setAddEmail('')
+      } catch (err) {
+        setAddError(err instanceof Error ? `Failed to add member: ${err.message}` : 'Failed to add member.')
+      }
     }
   }
 
@@ -508,6 +534,9 @@ function MembersTab({ workspaceId, members, setMembers }: {
           {addError && (
             <p className="mt-2 text-xs text-red-400">{addError}</p>
           )}
+          {inviteNotice && (
+            <p className="mt-2 break-all text-xs text-[var(--muted-foreground)]">{inviteNotice}</p>
+          )}
         </form>
       )}
 
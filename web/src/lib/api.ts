@@ -6,6 +6,15 @@ export interface Workspace {
   name: string
   created_at: string
   updated_at: string
+  settings?: WorkspaceSettings
+}
+
+export interface WorkspaceSettings {
+  default_sandbox_type: string | null
+  default_cpu: number | null
+  default_memory: number | null
+  default_idle_timeout: number | null
+  updated_at?: string
 }
 
 export interface WorkspaceMember {
@@ -59,6 +68,8 @@ export interface Sandbox {
   cpu?: number
   memory?: number
   idle_timeout?: number
+  description?: string
+  labels?: Record<string, string>
   agent_info?: AgentInfo
   weixin_bindings?: WeixinBinding[]
   im_bindings?: IMBinding[]
@@ -104,17 +115,18 @@ export async function checkAuth(): Promise<boolean> {
   return res.ok
 }
 
-export async function getOIDCProviders(): Promise<{ providers: string[]; password_auth: boolean }> {
+export async function getOIDCProviders(): Promise<{ providers: string[]; labels: Record<string, string>; password_auth: boolean }> {
   const res = await fetch('/api/auth/oidc/providers')
-  if (!res.ok) return { providers: [], password_auth: true }
+  if (!res.ok) return { providers: [], labels: {}, password_auth: true }
   const data = await res.json()
   return {
     providers: data.providers || [],
+    labels: data.labels || {},
     password_auth: data.password_auth !== false,
   }
 }
 
-export async function getMe(): Promise<{ id: string; email: string; name?: string | null; picture?: string | null; role: string }> {
+export async function getMe(): Promise<{ id: string; email: string; name?: string | null; picture?: string | null; role: string; impersonated_by?: { id: string; email?: string } }> {
   const res = await fetch('/api/auth/me')
   if (!res.ok) throw new Error('Failed to get user info')
   return res.json()
@@ -217,6 +229,50 @@ export async function removeMember(workspaceId: string, userId: string): Promise
   if (!res.ok) throw new Error('Failed to remove member')
 }
 
+// Workspace invitation API
+
+export interface WorkspaceInvitation {
+  id: string
+  workspace_id: string
+  email: string
+  role: string
+  invited_by?: string
+  created_at: string
+  expires_at: string
+}
+
+export interface InvitationPreview {
+  workspace_id: string
+  workspace_name?: string
+  email: string
+  role: string
+  invited_by?: string
+  expires_at: string
+  status: 'open' | 'accepted' | 'revoked' | 'expired'
+}
+
+export async function createInvitation(workspaceId: string, email: string, role: string): Promise<{ invitation: WorkspaceInvitation; accept_url: string; emailed: boolean }> {
+  const res = await fetch(`/api/workspaces/${workspaceId}/invitations`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ email, role }),
+  })
+  if (!res.ok) throw new Error((await res.text()).trim() || 'Failed to invite')
+  return res.json()
+}
+
+export async function getInvitation(token: string): Promise<InvitationPreview> {
+  const res = await fetch(`/api/invitations/${encodeURIComponent(token)}`)
+  if (!res.ok) throw new Error((await res.text()).trim() || 'Invitation not found')
+  return res.json()
+}
+
+export async function acceptInvitation(token: string): Promise<{ workspace_id: string; role: string }> {
+  const res = await fetch(`/api/invitations/${encodeURIComponent(token)}/accept`, { method: 'POST' })
+  if (!res.ok) throw new Error((await res.text()).trim() || 'Failed to accept the invitation')
+  return res.json()
+}
+
 // Sandbox API
 
 export interface WorkspaceSandboxDefaults {
@@ -225,6 +281,9 @@ export interface WorkspaceSandboxDefaults {
   max_idle_timeout: number   // seconds
   max_sandboxes: number      // 0 = unlimited
   current_sandboxes: number
+  max_sandboxes_by_type: Record<string, number> // 0 or missing = only max_sandboxes
+  current_sandboxes_by_type: Record<string, number>
+  default_sandbox_type: string
 }
 
 export async function getWorkspaceDefaults(workspaceId: string): Promise<WorkspaceSandboxDefaults> {
@@ -233,6 +292,27 @@ export async function getWorkspaceDefaults(workspaceId: string): Promise<Workspa
   return res.json()
 }
 
+export interface DriveUsage {
+  name: string
+  mount_path: string
+  used_bytes: number     // -1 = unknown
+  capacity_bytes: number // 0 = not capped
+}
+
+export interface WorkspaceDriveUsage {
+  drives: DriveUsage[]
+  used_bytes: number
+  limit_bytes: number
+  percent: number
+  status: 'ok' | 'warning' | 'exceeded' | 'unknown'
+}
+
+export async function getWorkspaceDriveUsage(workspaceId: string): Promise<WorkspaceDriveUsage> {
+  const res = await fetch(`/api/workspaces/${workspaceId}/drive-usage`)
+  if (!res.ok) throw new Error('Failed to get drive usage')
+  return res.json()
+}
+
 export interface WorkspaceLLMQuota {
   default_max_rpd: number
   workspace_quota: { workspace_id: string; max_rpd: number | null; updated_at: string } | null
@@ -303,9 +383,20 @@ export async function disconnectModelserver(workspaceId: string): Promise<void>
   if (!res.ok) throw new Error('Failed to disconnect')
 }
 
+// Poll interval suggested by the server (X-Poll-Interval) on the last
+// sandbox list. Unchanged lists are revalidated through their ETag by the
+// browser cache, so a poll that finds nothing new costs a 304.
+let sandboxPollIntervalMs = 2000
+
+export function getSandboxPollInterval(): number {
+  return sandboxPollIntervalMs
+}
+
 export async function listSandboxes(workspaceId: string): Promise<Sandbox[]> {
   const res = await fetch(`/api/workspaces/${workspaceId}/sandboxes`)
   if (!res.ok) throw new Error('Failed to list sandboxes')
+  const interval = Number(res.headers.get('X-Poll-Interval'))
+  if (interval > 0) sandboxPollIntervalMs = interval * 1000
   return res.json()
 }
 
@@ -789,6 +880,7 @@ export interface QuotaDefaults {
   ws_max_total_cpu: number           // millicores
   ws_max_total_memory: number        // bytes
   ws_max_idle_timeout: number        // seconds
+  max_sandboxes_by_type: Record<string, number>
 }
 
 export interface UserQuotaOverrides {
@@ -809,6 +901,7 @@ export interface WorkspaceQuotaOverrides {
   max_total_cpu: number | null      // millicores
   max_total_memory: number | null   // bytes
   max_drive_size: number | null     // bytes
+  max_sandboxes_by_type: Record<string, number> | null
   updated_at: string
 }
 
@@ -820,6 +913,7 @@ export interface WorkspaceQuotaDefaults {
   max_total_cpu: number             // millicores
   max_total_memory: number          // bytes
   max_drive_size: number            // bytes
+  max_sandboxes_by_type: Record<string, number>
 }
 
 export interface WorkspaceQuotaResponse {
@@ -830,7 +924,7 @@ export interface WorkspaceQuotaResponse {
 export interface QuotaExceededError {
   error: 'quota_exceeded'
   message: string
-  quota: { current: number; max: number }
+  quota: { current: number; max: number; type?: string } // type: the per-type limit that was reached
 }
 
 export interface ResourceBudgetExceededError {
@@ -946,6 +1040,69 @@ export async function adminDeleteWorkspaceLLMQuota(workspaceId: string): Promise
   if (!res.ok) throw new Error('Failed to delete LLM quota')
 }
 
+// --- Image Vulnerability Scanning ---
+
+export interface ImageFinding {
+  id: string
+  severity: string
+  package: string
+  installed_version?: string
+  fixed_version?: string
+  title?: string
+}
+
+export interface ImageScan {
+  image: string
+  scanner: string
+  status: string
+  error?: string
+  critical: number
+  high: number
+  medium: number
+  low: number
+  scanned_at: string
+  findings?: ImageFinding[]
+}
+
+export interface ImageScanPolicy {
+  block_severity: '' | 'critical' | 'high'
+  block_unscanned: boolean
+}
+
+export interface ImageScansResponse {
+  scanner: string
+  policy: ImageScanPolicy
+  scans: ImageScan[]
+  unscanned: string[]
+}
+
+export async function adminListImageScans(): Promise<ImageScansResponse> {
+  const res = await fetch('/api/admin/image-scans')
+  if (!res.ok) throw new Error('Failed to list image scans')
+  return res.json()
+}
+
+export async function adminGetImageScanFindings(image: string): Promise<ImageScan> {
+  const res = await fetch(`/api/admin/image-scans/findings?image=${encodeURIComponent(image)}`)
+  if (!res.ok) throw new Error('Failed to get image scan findings')
+  return res.json()
+}
+
+export async function adminRunImageScans(): Promise<void> {
+  const res = await fetch('/api/admin/image-scans/run', { method: 'POST' })
+  if (!res.ok) throw new Error((await res.text()) || 'Failed to start image scans')
+}
+
+export async function adminSetImageScanPolicy(policy: ImageScanPolicy): Promise<ImageScanPolicy> {
+  const res = await fetch('/api/admin/image-scan-policy', {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(policy),
+  })
+  if (!res.ok) throw new Error('Failed to set image scan policy')
+  return res.json()
+}
+
 // --- OAuth Device Flow ---
 
 export async function listMyWorkspaces(): Promise<Workspace[]> {